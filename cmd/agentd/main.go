@@ -2,26 +2,81 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/flitsinc/go-agents/internal/access"
 	"github.com/flitsinc/go-agents/internal/agenttools"
 	"github.com/flitsinc/go-agents/internal/ai"
 	"github.com/flitsinc/go-agents/internal/api"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/calendar"
+	"github.com/flitsinc/go-agents/internal/callbacks"
 	"github.com/flitsinc/go-agents/internal/config"
+	"github.com/flitsinc/go-agents/internal/contacts"
+	"github.com/flitsinc/go-agents/internal/documents"
+	"github.com/flitsinc/go-agents/internal/egress"
 	"github.com/flitsinc/go-agents/internal/engine"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/eventsink"
+	"github.com/flitsinc/go-agents/internal/facts"
+	"github.com/flitsinc/go-agents/internal/federation"
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/github"
 	"github.com/flitsinc/go-agents/internal/goagents"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/health"
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/maintenance"
+	"github.com/flitsinc/go-agents/internal/mcp"
+	"github.com/flitsinc/go-agents/internal/mcpserver"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/reports"
+	"github.com/flitsinc/go-agents/internal/scheduler"
+	"github.com/flitsinc/go-agents/internal/secrets"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-agents/internal/share"
 	"github.com/flitsinc/go-agents/internal/state"
 	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/templates"
+	"github.com/flitsinc/go-agents/internal/topics"
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"github.com/flitsinc/go-agents/internal/urgency"
+	"github.com/flitsinc/go-agents/internal/usage"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "setup" {
+		os.Exit(runSetup())
+	}
+	if len(os.Args) > 1 && os.Args[1] == "shards" {
+		os.Exit(runShards(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		os.Exit(runState(os.Args[2:]))
+	}
+	mcpMode, err := mcpModeFromArgs(os.Args)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 	cfg := config.Load()
 	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
 		log.Fatalf("create data dir: %v", err)
@@ -34,51 +89,313 @@ func main() {
 		log.Fatalf("ensure go-agents home: %v", err)
 	}
 	_ = os.Setenv("GO_AGENTS_HOME", home)
+	var egressPolicy *egress.Policy
+	if cfg.Egress != nil {
+		// Fail closed: a locked-down deployment must not fall back to
+		// unrestricted egress because of a config typo.
+		egressPolicy, err = egress.New(*cfg.Egress, egress.WithLogger(log.Printf))
+		if err != nil {
+			log.Fatalf("egress policy: %v", err)
+		}
+		egressPolicy.Install()
+	}
+	var shutdownTracing func(context.Context) error
+	if cfg.Tracing != nil {
+		if shutdownTracing, err = tracing.Setup(context.Background(), *cfg.Tracing); err != nil {
+			log.Printf("tracing disabled: %v", err)
+		}
+	}
 
-	db, err := state.Open(cfg.DBPath)
+	state.DefaultTelemetry.SetThresholds(cfg.SlowQueryThreshold, cfg.WriteHoldThreshold)
+	db, dialect, err := state.OpenDriver(cfg.DBDriver, cfg.DBDSN)
 	if err != nil {
 		log.Fatalf("open db: %v", err)
 	}
 	defer db.Close()
 
-	bus := eventbus.NewBus(db)
+	busOpts := []eventbus.Option{eventbus.WithDialect(dialect)}
+	switch cfg.StorageMode {
+	case config.StorageSharded:
+		if dialect != state.SQLite {
+			log.Fatalf("storage_mode %q requires the sqlite driver", config.StorageSharded)
+		}
+		shards, err := state.OpenShards(cfg.ShardDir)
+		if err != nil {
+			log.Fatalf("open shards: %v", err)
+		}
+		defer shards.Close()
+		busOpts = append(busOpts, eventbus.WithShards(shards))
+	case config.StorageSingle:
+	default:
+		log.Printf("unknown storage_mode %q; using %s", cfg.StorageMode, config.StorageSingle)
+	}
+	bus := eventbus.NewBus(db, busOpts...)
 	manager := tasks.NewManager(db, bus)
 	rt := engine.NewRuntime(bus, manager, nil)
 	rt.SetLLMDebugDir(cfg.LLMDebugDir)
+	docs := documents.NewStore(db)
+	rt.Documents = docs
+	windows := maintenance.NewStore(db)
+	rt.Maintenance = windows
+	topicStore := topics.NewStore(db, bus)
+	rt.Topics = topicStore
+	questionStore := questions.NewStore(db)
+	rt.Questions = questionStore
+	shadowStore := shadow.NewStore(db)
+	rt.Shadow = shadowStore
+	labelStore := labels.NewStore(db)
+	rt.Labels = labelStore
+	usageStore := usage.NewStore(db)
+	rt.Usage = usageStore
+	qualityStore := quality.NewStore(db)
+	rt.Quality = qualityStore
+	if err := monitors.Validate(cfg.Monitors); err != nil {
+		log.Printf("monitor config ignored: %v", err)
+	}
+	monitorRegistry := monitors.NewRegistry(monitors.WithConfig(cfg.Monitors), monitors.WithLogger(log.Printf))
+	rt.Monitors = monitorRegistry
+	if cfg.ClassifyUrgency {
+		rt.Urgency = urgency.KeywordClassifier{}
+	}
+	if err := preprocess.Validate(cfg.InboundPreprocessors); err != nil {
+		log.Printf("inbound preprocessors ignored: %v", err)
+	} else {
+		rt.Preprocessors = cfg.InboundPreprocessors
+	}
+	if len(cfg.InterruptCancelTools) > 0 {
+		rt.InterruptedTaskPolicies = map[string]engine.InterruptedTaskPolicy{}
+		for _, name := range cfg.InterruptCancelTools {
+			rt.InterruptedTaskPolicies[name] = engine.InterruptedTaskCancel
+		}
+	}
+	rt.ToolSummaryBudgets = cfg.ToolSummaryBudgets
+	rt.ContextBudgets = cfg.ContextBudgets
+	rt.ModelPrices = cfg.ModelPrices
+	if err := cfg.Budget.Validate(); err != nil {
+		log.Printf("budget ignored: %v", err)
+	} else {
+		rt.Budget = cfg.Budget
+	}
+	rt.ModelOverrides = cfg.ModelOverrides
+	rt.InterruptAlertAfter = cfg.InterruptAlertAfter
+	rt.CheckpointEvery = cfg.CheckpointEvery
+	var archiver *archive.Archiver
+	if cfg.Archive != nil {
+		if store, err := cfg.Archive.Store(); err != nil {
+			log.Printf("archive disabled: %v", err)
+		} else {
+			archiver = archive.New(db, bus, manager, store, *cfg.Archive)
+			rt.Archive = archiver
+		}
+	}
+	if err := provision.Validate(cfg.AgentProvisioning); err != nil {
+		log.Printf("agent provisioning ignored: %v", err)
+	} else {
+		rt.ProvisionRules = cfg.AgentProvisioning
+	}
+	// deliver hands connector messages to their target agent, creating it
+	// first when a provisioning rule covers an unknown ID.
+	deliver := func(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error) {
+		if _, err := rt.ProvisionAgent(ctx, target); err != nil && !errors.Is(err, engine.ErrUnknownAgent) {
+			return eventbus.Event{}, err
+		}
+		rt.EnsureAgentLoop(target)
+		return rt.SendMessageWithMeta(ctx, target, body, source, meta)
+	}
+	var accessStore *access.Store
+	if len(cfg.APIKeys) > 0 {
+		accessStore = access.NewStore(db, cfg.APIKeys)
+	}
+	notifyStore := notify.NewStore(db)
 	execTool := agenttools.ExecTool(manager)
 	awaitTaskTool := agenttools.AwaitTaskTool(manager)
-	sendTaskTool := agenttools.SendTaskTool(manager, bus)
+	var federationNode *federation.Node
+	var remoteSender agenttools.RemoteSender
+	if cfg.Federation != nil {
+		federationNode, err = federation.NewNode(db, bus, manager, *cfg.Federation, federation.WithDeliverer(deliver))
+		if err != nil {
+			log.Printf("federation disabled: %v", err)
+		} else {
+			remoteSender = federationNode
+		}
+	}
+	var secretStore *secrets.Store
+	if key, err := secrets.LoadOrCreateKey(filepath.Join(cfg.DataDir, "secrets.key")); err != nil {
+		log.Printf("secrets store disabled: %v", err)
+	} else if secretStore, err = secrets.NewStore(db, key); err != nil {
+		log.Printf("secrets store disabled: %v", err)
+	}
+	calendarService := calendar.NewService(db, bus, secretStore, calendar.WithDeliverer(deliver))
+	githubService := github.NewService(db, bus, secretStore, github.WithDeliverer(deliver))
+	schedulerService := scheduler.NewService(db, bus, scheduler.WithDeliverer(deliver))
+	sendTaskTool := agenttools.SendTaskTool(manager, bus, remoteSender)
 	// TODO: kill_task currently force-cancels immediately (sets status, no grace period).
 	// Add graceful cancellation as the default behavior (signal task, wait for cleanup)
 	// and a force=true parameter to force-kill stuck tasks.
 	killTaskTool := agenttools.KillTaskTool(manager)
+	retryTaskTool := agenttools.RetryTaskTool(manager)
 	noopTool := agenttools.NoopTool()
 	viewImageTool := agenttools.ViewImageTool()
+	subscribeTopicTool := agenttools.SubscribeTopicTool(topicStore)
+	publishTopicTool := agenttools.PublishTopicTool(topicStore)
+	askUserTool := agenttools.AskUserTool(rt)
+	askAgentTool := agenttools.AskAgentTool(rt)
+	createTeamTool := agenttools.CreateTeamTool(rt)
+	assignToTeamTool := agenttools.AssignToTeamTool(rt)
+	listCalendarEventsTool := agenttools.ListCalendarEventsTool(calendarService)
+	createCalendarEventTool := agenttools.CreateCalendarEventTool(calendarService)
+	setReminderTool := agenttools.SetReminderTool(calendarService)
+	scheduleTaskTool := agenttools.ScheduleTaskTool(schedulerService)
+	commentOnGitHubTool := agenttools.CommentOnGitHubTool(githubService)
+	artifactStore := artifacts.NewStore(db)
+	artifactOffloader := &artifacts.Offloader{Store: artifactStore, Threshold: cfg.ArtifactThreshold}
+	rt.Artifacts = artifactOffloader
+	readArtifactTool := agenttools.ReadArtifactTool(artifactStore)
+	factStore := facts.NewStore(db)
+	rt.Facts = factStore
+	pinFactTool := agenttools.PinFactTool(factStore)
+	unpinFactTool := agenttools.UnpinFactTool(factStore)
+	memoryStore := memory.NewStore(db)
+	rt.Memories = memoryStore
+	rt.Context.Memories = memoryStore
+	rt.Context.MemoryLimit = cfg.MemoryPromptLimit
+	rememberTool := agenttools.RememberTool(memoryStore)
+	recallTool := agenttools.RecallTool(memoryStore)
+	searchMemoryTool := agenttools.SearchMemoryTool(rt)
+	templateStore := templates.NewStore(db)
+	spawnFromTemplateTool := agenttools.SpawnFromTemplateTool(templateStore, manager)
+	contactStore := contacts.NewStore(db)
+	contactDeliverer := contacts.NewDeliverer(contactStore, rt)
+	lookupContactTool := agenttools.LookupContactTool(contactStore)
+	messageContactTool := agenttools.MessageContactTool(contactDeliverer)
 
-	rt.SetPromptTools([]string{
+	promptTools := []string{
+		"ask_agent",
+		"ask_user",
+		"assign_to_team",
 		"await_task",
+		"comment_on_github",
+		"create_calendar_event",
+		"create_team",
 		"exec",
 		"kill_task",
+		"list_calendar_events",
+		"lookup_contact",
+		"message_contact",
 		"noop",
+		"pin_fact",
+		"publish_topic",
+		"read_artifact",
+		"recall",
+		"remember",
+		"retry_task",
+		"schedule_task",
+		"search_memory",
 		"send_task",
+		"set_reminder",
+		"spawn_from_template",
+		"subscribe_topic",
+		"unpin_fact",
 		"view_image",
-	})
+	}
+	rt.SetPromptTools(promptTools)
+	if err := mcp.Validate(cfg.MCPServers); err != nil {
+		log.Printf("mcp servers ignored: %v", err)
+		cfg.MCPServers = nil
+	}
+	mcpManager := mcp.NewManager(cfg.MCPServers, mcp.WithHTTPClient(egressPolicy.Client(0)), mcp.WithLogger(log.Printf),
+		mcp.WithOnChange(func(names []string) {
+			rt.SetPromptTools(append(slices.Clone(promptTools), names...))
+		}))
+	defer mcpManager.Close()
 
+	toolValidation := agenttools.NewValidationStats()
+	var mcpServer *mcpserver.Server
+	if mcpMode != "" {
+		mcpServer = mcpserver.New(agenttools.Validated(toolValidation, sendTaskTool, awaitTaskTool, execTool,
+			agenttools.ReadStreamTool(bus), agenttools.AckStreamTool(bus)),
+			mcpserver.WithLogger(log.Printf),
+			mcpserver.WithWaker(func(ctx context.Context, taskID string) {
+				if task, err := manager.Get(ctx, taskID); err == nil && task.Type == "agent" {
+					rt.EnsureAgentLoop(task.Owner)
+				}
+			}))
+	}
+	var providerGovernor *governor.Governor
+	if cfg.ProviderLimits != nil {
+		if err := cfg.ProviderLimits.Validate(); err != nil {
+			log.Printf("provider limits ignored: %v", err)
+		} else {
+			providerGovernor = governor.New(*cfg.ProviderLimits)
+		}
+	}
+	var fineTuneRedactors []finetune.Redactor
+	if cfg.FineTune != nil {
+		if fineTuneRedactors, err = cfg.FineTune.Redactors(); err != nil {
+			log.Printf("fine-tuning redaction rules ignored: %v", err)
+		}
+	}
 	var llmClient *ai.Client
 	if cfg.LLMModel != "" && cfg.LLMAPIKey != "" {
 		llmClient, err = ai.NewClient(ai.Config{
-			Provider: cfg.LLMProvider,
-			Model:    cfg.LLMModel,
-			APIKey:   cfg.LLMAPIKey,
-		}, execTool, awaitTaskTool, sendTaskTool, killTaskTool, noopTool, viewImageTool)
+			Provider:      cfg.LLMProvider,
+			Model:         cfg.LLMModel,
+			APIKey:        cfg.LLMAPIKey,
+			APIKeys:       cfg.LLMAPIKeys,
+			ProviderTools: cfg.ProviderTools,
+			HTTPClient:    egressPolicy.Client(0),
+			Governor:      providerGovernor,
+		}, agenttools.Traced(agenttools.Offloaded(artifactOffloader, agenttools.Validated(toolValidation, execTool, awaitTaskTool, sendTaskTool, killTaskTool, retryTaskTool, noopTool, viewImageTool, subscribeTopicTool, publishTopicTool, askUserTool, askAgentTool,
+			listCalendarEventsTool, createCalendarEventTool, setReminderTool, readArtifactTool, pinFactTool, unpinFactTool, rememberTool, recallTool, searchMemoryTool, spawnFromTemplateTool,
+			lookupContactTool, messageContactTool, scheduleTaskTool, commentOnGitHubTool,
+			createTeamTool, assignToTeamTool)...)...)...)
 		if err != nil {
-			log.Printf("LLM disabled: %v", err)
+			log.Printf("LLM disabled: %v (run `agentd setup` to configure)", err)
 		}
+	} else {
+		log.Printf("LLM disabled: no model or API key for %s (run `agentd setup` to configure)", cfg.LLMProvider)
 	}
 
 	if llmClient != nil {
+		llmClient.SetToolSource(func() []llmtools.Tool {
+			return agenttools.Traced(agenttools.Offloaded(artifactOffloader, mcpManager.Tools()...)...)
+		})
 		rt.LLM = llmClient
 		rt.LLMFactory = llmClient.NewSession
+		if cfg.Embeddings != nil {
+			if embedder, err := llmClient.Embedder(*cfg.Embeddings); err != nil {
+				log.Printf("semantic memory search disabled: %v", err)
+			} else {
+				rt.Embedder = embedder
+			}
+		}
+		if plain, err := llmClient.WithTools(); err == nil {
+			rt.Titler = labels.LLMTitler{
+				NewSession: func() (*llms.LLM, error) {
+					return plain.NewSessionWithOptions(ai.SessionOptions{Model: "fast", ProviderTools: []string{}})
+				},
+			}
+			artifactOffloader.Summarizer = artifacts.LLMSummarizer{
+				NewSession: func() (*llms.LLM, error) {
+					return plain.NewSessionWithOptions(ai.SessionOptions{Model: "fast", ProviderTools: []string{}})
+				},
+			}
+			rt.FactExtractor = facts.LLMExtractor{
+				NewSession: func() (*llms.LLM, error) {
+					return plain.NewSessionWithOptions(ai.SessionOptions{Model: "fast", ProviderTools: []string{}})
+				},
+			}
+			rt.ContextSummarizer = engine.LLMContextSummarizer{
+				NewSession: func() (*llms.LLM, error) {
+					return plain.NewSessionWithOptions(ai.SessionOptions{Model: "fast", ProviderTools: []string{}})
+				},
+			}
+			rt.Translator = preprocess.LLMTranslator{
+				NewSession: func() (*llms.LLM, error) {
+					return plain.NewSessionWithOptions(ai.SessionOptions{Model: "fast", ProviderTools: []string{}})
+				},
+			}
+		}
 	}
 
 	_ = llmClient // reserved for future runtime wiring.
@@ -94,14 +411,199 @@ func main() {
 		}
 	}
 
+	handoff, err := engine.HandoffFromArgs(os.Args)
+	if err != nil {
+		log.Printf("restart handoff ignored: %v", err)
+	}
+
 	var httpServer *http.Server
 	serverCtx, serverCancel := context.WithCancel(context.Background())
 	rt.Start(serverCtx)
+	mirrors := 0
+	for _, sinkCfg := range cfg.EventSinks {
+		sink, err := eventsink.New(sinkCfg)
+		if err != nil {
+			log.Printf("event sink disabled: %v", err)
+			continue
+		}
+		mirror := eventsink.NewMirror(bus, sink, sinkCfg.Streams, eventsink.WithErrorHandler(func(err error) {
+			log.Printf("event sink: %v", err)
+		}))
+		go mirror.Run(serverCtx)
+		mirrors++
+	}
+	dispatcher := callbacks.NewDispatcher(manager)
+	if err := monitorRegistry.Register("callbacks", callbacks.DefaultPollInterval, func(ctx context.Context) error {
+		_, err := dispatcher.DeliverDue(ctx)
+		return err
+	}); err != nil {
+		log.Printf("callbacks disabled: %v", err)
+	}
+	if federationNode != nil {
+		if err := monitorRegistry.Register("federation", federation.DefaultPollInterval, func(ctx context.Context) error {
+			_, err := federationNode.DeliverDue(ctx)
+			return err
+		}); err != nil {
+			log.Printf("federation delivery disabled: %v", err)
+		}
+	}
+	if err := monitorRegistry.Register("calendar", calendar.DefaultPollInterval, func(ctx context.Context) error {
+		_, err := calendarService.Poll(ctx)
+		return err
+	}); err != nil {
+		log.Printf("calendar wakes disabled: %v", err)
+	}
+	if err := monitorRegistry.Register("scheduler", scheduler.DefaultPollInterval, func(ctx context.Context) error {
+		_, err := schedulerService.Poll(ctx)
+		return err
+	}); err != nil {
+		log.Printf("scheduled wakes disabled: %v", err)
+	}
+	if archiver != nil {
+		if err := monitorRegistry.Register("archive", archive.DefaultPollInterval, func(ctx context.Context) error {
+			_, err := archiver.Run(ctx)
+			return err
+		}); err != nil {
+			log.Printf("archiving disabled: %v", err)
+		}
+	}
+	if len(cfg.MCPServers) > 0 {
+		go func() {
+			if err := mcpManager.Refresh(serverCtx); err != nil {
+				log.Printf("mcp: %v", err)
+			}
+		}()
+		if err := monitorRegistry.Register("mcp", mcp.DefaultRefreshInterval, mcpManager.Refresh); err != nil {
+			log.Printf("mcp refresh disabled: %v", err)
+		}
+	}
+	var pushers map[string]notify.Pusher
+	if cfg.Notifications != nil {
+		pushers, err = notify.NewPushers(*cfg.Notifications)
+		if err != nil {
+			log.Printf("notifications disabled: %v", err)
+		}
+	}
+	if len(pushers) > 0 {
+		opts := []notify.DispatcherOption{notify.WithErrorHandler(func(err error) {
+			log.Printf("notifications: %v", err)
+		})}
+		if accessStore != nil {
+			opts = append(opts, notify.WithAccess(func(ctx context.Context, userID, agentID string) (bool, error) {
+				principal, ok := accessStore.Principal(userID)
+				if !ok {
+					return false, nil
+				}
+				level, _, err := accessStore.Level(ctx, agentID, principal)
+				return level >= access.LevelView, err
+			}))
+		}
+		notifier := notify.NewDispatcher(bus, notifyStore, pushers, opts...)
+		if err := monitorRegistry.Register("notifications", notify.DefaultPollInterval, func(ctx context.Context) error {
+			_, err := notifier.Deliver(ctx)
+			return err
+		}); err != nil {
+			log.Printf("notifications disabled: %v", err)
+		}
+	}
+	monitorRegistry.Start(serverCtx)
+	if cfg.ActivityReports != nil {
+		var opts []reports.Option
+		if llmClient != nil {
+			if plain, err := llmClient.WithTools(); err == nil {
+				opts = append(opts, reports.WithSummarizer(reports.LLMSummarizer{
+					NewSession: func() (*llms.LLM, error) {
+						return plain.NewSessionWithOptions(ai.SessionOptions{ProviderTools: []string{}})
+					},
+				}))
+			}
+		}
+		if generator, err := reports.NewGeneratorFromConfig(manager, bus, *cfg.ActivityReports, opts...); err != nil {
+			log.Printf("activity reports disabled: %v", err)
+		} else {
+			go generator.Run(serverCtx)
+		}
+	}
+	rt.ImportHandoff(handoff)
 
-	apiServer := &api.Server{
-		Tasks:   manager,
-		Bus:     bus,
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	restart := &engine.RestartOrchestrator{
 		Runtime: rt,
+		Restarter: &engine.Restarter{
+			Listener: listener,
+			Args:     os.Args,
+			Env:      os.Environ(),
+		},
+		HandoffPath: filepath.Join(cfg.DataDir, "restart-handoff.json"),
+		Shutdown: func(ctx context.Context) error {
+			return httpServer.Shutdown(ctx)
+		},
+		Exit: func() {
+			stop <- syscall.SIGTERM
+		},
+	}
+
+	var llmProber health.Prober
+	if llmClient != nil {
+		llmProber = llmClient
+	}
+	checker := health.NewChecker([]health.Check{
+		{Name: "db", Critical: true, Func: health.DBCheck(db)},
+		{Name: "bus", Func: health.BusCheck(bus, mirrors)},
+		{Name: "llm", Func: health.Cached(health.LLMCheck(llmProber), time.Minute)},
+		{Name: "disk", Critical: true, Func: health.DiskCheck(cfg.DataDir, 1<<30, 100<<20)},
+		{Name: "monitors", Func: health.MonitorsCheck(monitorRegistry)},
+	})
+
+	var shares *share.Signer
+	if secret, err := share.LoadOrCreateSecret(filepath.Join(cfg.DataDir, "share-secret")); err != nil {
+		log.Printf("share links disabled: %v", err)
+	} else {
+		shares = share.NewSigner(secret)
+	}
+
+	apiServer := &api.Server{
+		Tasks:             manager,
+		Bus:               bus,
+		Runtime:           rt,
+		Documents:         docs,
+		Maintenance:       windows,
+		Restart:           restart,
+		Health:            checker,
+		Monitors:          monitorRegistry,
+		Shares:            shares,
+		Topics:            topicStore,
+		Questions:         questionStore,
+		Shadow:            shadowStore,
+		Labels:            labelStore,
+		Notifications:     notifyStore,
+		Pushers:           pushers,
+		Federation:        federationNode,
+		Calendar:          calendarService,
+		GitHub:            githubService,
+		Scheduler:         schedulerService,
+		Artifacts:         artifactStore,
+		Facts:             factStore,
+		Memory:            memoryStore,
+		Templates:         templateStore,
+		Contacts:          contactStore,
+		ToolValidation:    toolValidation,
+		Priorities:        api.NewPriorityStats(),
+		Storage:           state.DefaultTelemetry,
+		Egress:            egressPolicy,
+		Governor:          providerGovernor,
+		Archive:           archiver,
+		Usage:             usageStore,
+		Quality:           qualityStore,
+		FineTuneRedactors: fineTuneRedactors,
+		Access:            accessStore,
+		RestartToken:      cfg.RestartToken,
+		LegacyAPISunset:   cfg.LegacyAPISunset,
+	}
+	if mcpMode == mcpSSE {
+		apiServer.MCP = mcpServer.Handler()
 	}
 	mux := http.NewServeMux()
 	mux.Handle("/api/", apiServer.Handler())
@@ -120,9 +622,23 @@ func main() {
 			log.Fatalf("http server error: %v", err)
 		}
 	}()
+	if err := engine.SignalReady(os.Args); err != nil {
+		log.Printf("signal ready: %v", err)
+	}
+	if mcpMode == mcpStdio {
+		go func() {
+			log.Printf("mcp server on stdio with tools %v", mcpServer.ToolNames())
+			if err := mcpServer.ServeStdio(serverCtx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("mcp server: %v", err)
+			}
+			// The client closed stdin; agentd exits with it.
+			select {
+			case stop <- syscall.SIGTERM:
+			default:
+			}
+		}()
+	}
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
 	serverCancel()
@@ -133,6 +649,11 @@ func main() {
 		log.Printf("server shutdown error: %v", err)
 	}
 	_ = httpServer.Close()
+	if shutdownTracing != nil {
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("tracing shutdown error: %v", err)
+		}
+	}
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MCP server transports selected with --mcp.
+const (
+	mcpStdio = "stdio"
+	mcpSSE   = "sse"
+)
+
+// mcpModeFromArgs reads --mcp. A bare --mcp, or --mcp=stdio, serves MCP on
+// stdin and stdout; --mcp=sse serves it at /api/mcp. It returns "" when
+// the flag is absent.
+func mcpModeFromArgs(args []string) (string, error) {
+	for _, arg := range args[1:] {
+		if arg == "--mcp" {
+			return mcpStdio, nil
+		}
+		value, ok := strings.CutPrefix(arg, "--mcp=")
+		if !ok {
+			continue
+		}
+		switch value {
+		case mcpStdio, mcpSSE:
+			return value, nil
+		default:
+			return "", fmt.Errorf("unknown --mcp transport %q (expected stdio or sse)", value)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/config"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/setup"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// runSetup runs the interactive setup wizard and returns the exit code.
+func runSetup() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	wizard := &setup.Wizard{
+		In:              os.Stdin,
+		Out:             os.Stdout,
+		CreateAgent:     createSetupAgent,
+		SendTestMessage: sendSetupTestMessage,
+	}
+	if _, err := wizard.Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "setup: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// createSetupAgent creates the first agent in the database of the config
+// setup just wrote, as POST /api/tasks would. An existing agent is kept.
+func createSetupAgent(ctx context.Context, answers setup.Answers) error {
+	// Load picks up a db_path kept from an earlier config.json.
+	// Load picks up a db_path kept from an earlier config.json.
+	cfg := config.Load()
+	db, dialect, err := state.OpenDriver(cfg.DBDriver, cfg.DBDSN)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer db.Close()
+	bus := eventbus.NewBus(db, eventbus.WithDialect(dialect))
+	manager := tasks.NewManager(db, bus)
+	if existing, err := manager.Get(ctx, answers.AgentID); err == nil && existing.ID != "" {
+		return nil
+	}
+	_, err = manager.Spawn(ctx, tasks.Spec{
+		ID:       answers.AgentID,
+		Type:     "agent",
+		Mode:     "async",
+		Metadata: map[string]any{"source": "setup"},
+	})
+	return err
+}
+
+// sendSetupTestMessage makes one real request with the chosen provider,
+// model and key, which is the only way to know the key is accepted.
+func sendSetupTestMessage(ctx context.Context, answers setup.Answers, message string) (string, error) {
+	client, err := ai.NewClient(ai.Config{
+		Provider: answers.Provider,
+		Model:    answers.Model,
+		APIKey:   answers.APIKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	var reply strings.Builder
+	for update := range client.Chat(ctx, []llms.Message{{Role: "user", Content: content.FromText(message)}}) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			reply.WriteString(u.Text)
+		}
+	}
+	if err := client.LLM.Err(); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(reply.String()) == "" {
+		return "", errors.New("model returned an empty reply")
+	}
+	return reply.String(), nil
+}
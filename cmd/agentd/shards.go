@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/flitsinc/go-agents/internal/config"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// runShards implements "agentd shards migrate -to sharded|single", which
+// moves task-scoped events between the main database and per-agent shards.
+// Run it with agentd stopped, then set storage_mode to match.
+func runShards(args []string) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintln(os.Stderr, "usage: agentd shards migrate -to sharded|single")
+		return 2
+	}
+	flags := flag.NewFlagSet("shards migrate", flag.ContinueOnError)
+	to := flags.String("to", "", "target storage mode: sharded or single")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *to != config.StorageSharded && *to != config.StorageSingle {
+		fmt.Fprintln(os.Stderr, "shards migrate: -to must be sharded or single")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg := config.Load()
+	if cfg.DBDriver != config.DBDriverSQLite {
+		fmt.Fprintf(os.Stderr, "shards migrate: only the sqlite driver is supported\n")
+		return 1
+	}
+	db, err := state.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	shards, err := state.OpenShards(cfg.ShardDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open shards: %v\n", err)
+		return 1
+	}
+	defer shards.Close()
+
+	var moved int
+	if *to == config.StorageSharded {
+		moved, err = eventbus.MoveToShards(ctx, db, shards)
+	} else {
+		moved, err = eventbus.MoveFromShards(ctx, db, shards)
+	}
+	fmt.Printf("moved %d events\n", moved)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shards migrate: %v\n", err)
+		return 1
+	}
+	fmt.Printf("set \"storage_mode\": %q in config.json before starting agentd\n", *to)
+	return 0
+}
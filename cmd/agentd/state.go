@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/flitsinc/go-agents/internal/config"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// runState implements "agentd state dump [-out file]", which writes the
+// main database as SQL inserts. Tests load such a dump with
+// agenttest.Options{State: "path/to/dump.sql"} to reproduce a bug from a
+// real state.
+func runState(args []string) int {
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintln(os.Stderr, "usage: agentd state dump [-out file]")
+		return 2
+	}
+	flags := flag.NewFlagSet("state dump", flag.ContinueOnError)
+	out := flags.String("out", "", "file to write; stdout when empty")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg := config.Load()
+	if cfg.DBDriver != config.DBDriverSQLite {
+		fmt.Fprintf(os.Stderr, "state dump: only the sqlite driver is supported\n")
+		return 1
+	}
+	db, err := state.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "state dump: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := state.Dump(ctx, db, w); err != nil {
+		fmt.Fprintf(os.Stderr, "state dump: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+// Command scenario runs declarative agent scenarios and prints a pass/fail
+// report for each.
+//
+//	go run ./cmd/scenario [-json] scenarios/ more.yaml
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/scenario"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print reports as JSON lines")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-json] <scenario.yaml|dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	files, err := scenario.Files(flag.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	failed := 0
+	for _, path := range files {
+		report := runFile(ctx, path)
+		if !report.Passed {
+			failed++
+		}
+		if *asJSON {
+			_ = json.NewEncoder(os.Stdout).Encode(report)
+			continue
+		}
+		status := "PASS"
+		if !report.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("%s %s (%d turns, %s)\n", status, report.Name, len(report.Turns), report.Duration.Round(time.Millisecond))
+		for _, failure := range report.Failures {
+			fmt.Printf("    %s\n", failure)
+		}
+	}
+	if !*asJSON {
+		fmt.Printf("%d passed, %d failed\n", len(files)-failed, failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runFile(ctx context.Context, path string) scenario.Report {
+	sc, err := scenario.Load(path)
+	if err != nil {
+		return scenario.Report{Name: path, Path: path, Failures: []string{err.Error()}}
+	}
+	report, err := scenario.Run(ctx, sc, scenario.Options{})
+	if err != nil {
+		report.Passed = false
+		report.Failures = append(report.Failures, err.Error())
+	}
+	return report
+}
@@ -1,7 +1,8 @@
 #!/usr/bin/env bun
 
+import { readFileSync, statSync } from "node:fs"
 import { mkdir } from "node:fs/promises"
-import { dirname } from "node:path"
+import { basename, dirname, extname } from "node:path"
 import { deserialize, serialize } from "node:v8"
 
 function getArg(name: string): string | undefined {
@@ -136,8 +137,33 @@ function appendBinding(
   return next
 }
 
+type UserMessagePart = {
+  type: "image" | "file"
+  url: string
+  name?: string
+  mime_type?: string
+}
+
 type UserMessage = {
   text: string
+  parts?: UserMessagePart[]
+}
+
+// Attached files are inlined as data URLs, so they are kept small.
+const maxAttachmentBytes = 5 * 1024 * 1024
+
+const attachmentMimeTypes: Record<string, string> = {
+  ".png": "image/png",
+  ".jpg": "image/jpeg",
+  ".jpeg": "image/jpeg",
+  ".gif": "image/gif",
+  ".webp": "image/webp",
+  ".svg": "image/svg+xml",
+  ".pdf": "application/pdf",
+  ".csv": "text/csv",
+  ".json": "application/json",
+  ".md": "text/markdown",
+  ".txt": "text/plain",
 }
 
 function normalizeUserMessage(raw: unknown): string {
@@ -146,12 +172,46 @@ function normalizeUserMessage(raw: unknown): string {
   return String(raw).trim()
 }
 
+// normalizeUserParts accepts attachments as { type?, url?, path?, name?,
+// mime_type? }. A path is read and sent as a data URL; the type defaults to
+// image for image MIME types and file otherwise.
+function normalizeUserParts(raw: unknown): UserMessagePart[] {
+  if (!Array.isArray(raw)) return []
+  const parts: UserMessagePart[] = []
+  for (const item of raw) {
+    if (!item || typeof item !== "object") continue
+    const entry = item as Record<string, unknown>
+    let url = typeof entry.url === "string" ? entry.url.trim() : ""
+    let name = typeof entry.name === "string" ? entry.name.trim() : ""
+    let mimeType = typeof entry.mime_type === "string" ? entry.mime_type.trim() : ""
+    const path = typeof entry.path === "string" ? entry.path.trim() : ""
+    if (url === "" && path !== "") {
+      if (statSync(path).size > maxAttachmentBytes) {
+        throw new Error(`sendToUser: ${path} is larger than ${maxAttachmentBytes} bytes`)
+      }
+      mimeType ||= attachmentMimeTypes[extname(path).toLowerCase()] || "application/octet-stream"
+      url = `data:${mimeType};base64,${readFileSync(path).toString("base64")}`
+      name ||= basename(path)
+    }
+    if (url === "") continue
+    const type = entry.type === "image" || entry.type === "file"
+      ? entry.type
+      : mimeType.startsWith("image/") ? "image" : "file"
+    const part: UserMessagePart = { type, url }
+    if (name !== "") part.name = name
+    if (mimeType !== "") part.mime_type = mimeType
+    parts.push(part)
+  }
+  return parts
+}
+
 const userMessages: UserMessage[] = []
 
-;(globalThis as any).sendToUser = (text: unknown) => {
+;(globalThis as any).sendToUser = (text: unknown, attachments?: unknown) => {
   const normalized = normalizeUserMessage(text)
-  if (normalized === "") return
-  userMessages.push({ text: normalized })
+  const parts = normalizeUserParts(attachments)
+  if (normalized === "" && parts.length === 0) return
+  userMessages.push(parts.length > 0 ? { text: normalized, parts } : { text: normalized })
 }
 
 let store = defaultStore()
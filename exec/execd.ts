@@ -228,6 +228,19 @@ async function sendFail(taskId: string, error: string) {
   })
 }
 
+async function sendLimitExceeded(
+  taskId: string,
+  limit: string,
+  error: string,
+  usage: Record<string, unknown>,
+) {
+  await fetch(`${API_URL}/api/tasks/${taskId}/fail`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ error, limit, usage }),
+  })
+}
+
 async function sendAssistantOutput(
   taskId: string,
   payload: {
@@ -237,6 +250,7 @@ async function sendAssistantOutput(
     request_id?: string
     service_id?: string
     context?: Record<string, unknown>
+    parts?: unknown[]
   },
 ) {
   await fetch(`${API_URL}/api/tasks/${taskId}/assistant_output`, {
@@ -525,6 +539,157 @@ async function forwardStream(
   }
 }
 
+type ExecLimits = {
+  cpu_seconds?: number
+  memory_mb?: number
+  timeout_seconds?: number
+  network?: boolean
+}
+
+function parseLimits(raw: unknown): ExecLimits | null {
+  if (!raw || typeof raw !== "object" || Array.isArray(raw)) return null
+  const input = raw as Record<string, unknown>
+  const limits: ExecLimits = {}
+  const positive = (value: unknown) =>
+    typeof value === "number" && Number.isFinite(value) && value > 0 ? value : undefined
+  limits.cpu_seconds = positive(input.cpu_seconds)
+  limits.memory_mb = positive(input.memory_mb)
+  limits.timeout_seconds = positive(input.timeout_seconds)
+  if (typeof input.network === "boolean") limits.network = input.network
+  const set = Object.values(limits).some((value) => value !== undefined)
+  return set ? limits : null
+}
+
+// Clock ticks per second for /proc/<pid>/stat CPU times. Linux uses 100 on
+// every architecture we run on.
+const CLOCK_TICKS = 100
+const USAGE_SAMPLE_MS = 200
+
+// processTree returns pid and its descendants, using the children lists the
+// kernel keeps per thread.
+function processTree(pid: number): number[] {
+  const out: number[] = []
+  const queue = [pid]
+  while (queue.length > 0) {
+    const current = queue.shift() as number
+    out.push(current)
+    try {
+      for (const tid of readdirSync(`/proc/${current}/task`)) {
+        const children = readFileSync(`/proc/${current}/task/${tid}/children`, "utf-8").trim()
+        if (children === "") continue
+        for (const child of children.split(/\s+/)) queue.push(parseInt(child, 10))
+      }
+    } catch {
+      // process exited or /proc is unavailable
+    }
+  }
+  return out
+}
+
+// sampleUsage reads the resident memory (bytes) and CPU time (seconds) of a
+// process tree from /proc. It returns null where /proc is unavailable.
+function sampleUsage(pid: number): { rssBytes: number; cpuSeconds: number } | null {
+  let rssBytes = 0
+  let cpuSeconds = 0
+  let seen = false
+  for (const current of processTree(pid)) {
+    try {
+      const status = readFileSync(`/proc/${current}/status`, "utf-8")
+      const rss = status.match(/^VmRSS:\s+(\d+) kB/m)
+      if (rss) rssBytes += parseInt(rss[1], 10) * 1024
+      // Fields after the parenthesised command name; utime and stime are
+      // the 14th and 15th fields of the whole line.
+      const stat = readFileSync(`/proc/${current}/stat`, "utf-8")
+      const fields = stat.slice(stat.lastIndexOf(")") + 2).split(" ")
+      cpuSeconds += (parseInt(fields[11], 10) + parseInt(fields[12], 10)) / CLOCK_TICKS
+      seen = true
+    } catch {
+      // process exited between listing and reading
+    }
+  }
+  return seen ? { rssBytes, cpuSeconds } : null
+}
+
+// limitedCommand wraps cmd so the kernel enforces the CPU limit and, when
+// network access is disabled, runs it in a fresh network namespace. Memory
+// and wall-clock limits are enforced by watchLimits.
+function limitedCommand(cmd: string[], limits: ExecLimits | null): string[] {
+  if (!limits) return cmd
+  let out = cmd
+  if (limits.cpu_seconds) {
+    const seconds = Math.ceil(limits.cpu_seconds)
+    out = ["sh", "-c", `ulimit -t ${seconds} && exec "$@"`, "sh", ...out]
+  }
+  if (limits.network === false) {
+    out = ["unshare", "--net", "--map-root-user", ...out]
+  }
+  return out
+}
+
+type LimitBreach = { limit: string; error: string }
+
+// watchLimits samples the process tree until it exits, killing it when it
+// exceeds a limit. usage() reports peak memory, CPU and wall time so far.
+function watchLimits(proc: { pid: number; kill: (signal?: number | string) => void }, limits: ExecLimits | null) {
+  const startedAt = Date.now()
+  let peakRSS = 0
+  let cpuSeconds = 0
+  let breach: LimitBreach | null = null
+  const trip = (limit: string, error: string) => {
+    if (breach) return
+    breach = { limit, error }
+    try {
+      proc.kill("SIGKILL")
+    } catch {
+      // already exited
+    }
+  }
+  const sample = () => {
+    const current = sampleUsage(proc.pid)
+    if (!current) return
+    peakRSS = Math.max(peakRSS, current.rssBytes)
+    cpuSeconds = Math.max(cpuSeconds, current.cpuSeconds)
+    if (limits?.memory_mb && peakRSS > limits.memory_mb * 1024 * 1024) {
+      trip("memory", `exec exceeded its memory limit of ${limits.memory_mb} MB`)
+    }
+    if (limits?.cpu_seconds && cpuSeconds > limits.cpu_seconds) {
+      trip("cpu", `exec exceeded its CPU limit of ${limits.cpu_seconds}s`)
+    }
+  }
+  const interval = setInterval(sample, USAGE_SAMPLE_MS)
+  const timeout = limits?.timeout_seconds
+    ? setTimeout(
+        () => trip("timeout", `exec exceeded its time limit of ${limits.timeout_seconds}s`),
+        limits.timeout_seconds * 1000,
+      )
+    : null
+  return {
+    stop(resourceUsage?: { cpuTime?: { total?: number } }) {
+      clearInterval(interval)
+      if (timeout) clearTimeout(timeout)
+      // Bun reports CPU time in microseconds once the process has exited.
+      const total = resourceUsage?.cpuTime?.total
+      if (typeof total === "number" && total > 0) {
+        cpuSeconds = Math.max(cpuSeconds, total / 1_000_000)
+      }
+    },
+    // checkCPU catches a kernel-enforced CPU limit, which ends the process
+    // with SIGXCPU (or SIGKILL at the hard limit) before a sample sees it.
+    checkCPU(signal: string | null) {
+      if (breach || !limits?.cpu_seconds) return
+      if (signal === "SIGXCPU" || (signal === "SIGKILL" && cpuSeconds >= limits.cpu_seconds * 0.9)) {
+        breach = { limit: "cpu", error: `exec exceeded its CPU limit of ${limits.cpu_seconds}s` }
+      }
+    },
+    breach: () => breach,
+    usage: () => ({
+      peak_memory_mb: Math.round((peakRSS / (1024 * 1024)) * 10) / 10,
+      cpu_seconds: Math.round(cpuSeconds * 100) / 100,
+      wall_seconds: Math.round((Date.now() - startedAt) / 10) / 100,
+    }),
+  }
+}
+
 async function runTask(task: Task) {
   const payload = task.payload || {}
   const code = typeof payload.code === "string" ? payload.code : ""
@@ -533,6 +698,11 @@ async function runTask(task: Task) {
     await sendFail(task.id, "exec task missing code")
     return
   }
+  const limits = parseLimits(payload.limits)
+  if (limits?.network === false && !Bun.which("unshare")) {
+    await sendFail(task.id, "exec task disables network access, but this host has no unshare to isolate it")
+    return
+  }
 
   const execDir = join(tmpdir(), `go-agents-${task.id}`)
   await mkdir(execDir, { recursive: true })
@@ -584,7 +754,7 @@ async function runTask(task: Task) {
 
   const dotEnvVars = loadDotEnv(join(GO_AGENTS_HOME, ".env"))
   const proc = Bun.spawn({
-    cmd,
+    cmd: limitedCommand(cmd, limits),
     cwd: GO_AGENTS_HOME,
     stdout: "pipe",
     stderr: "pipe",
@@ -596,6 +766,8 @@ async function runTask(task: Task) {
     },
   })
 
+  const watcher = watchLimits(proc, limits)
+
   const stdoutPromise = forwardStream(task.id, "stdout", proc.stdout, {
     inlineByteLimit: STREAM_INLINE_BYTE_LIMIT,
     outputFile: artifacts.stdout,
@@ -678,25 +850,30 @@ async function runTask(task: Task) {
   })
 
   const exitCode = await proc.exited
+  watcher.stop(typeof proc.resourceUsage === "function" ? proc.resourceUsage() : undefined)
+  watcher.checkCPU(proc.signalCode ?? null)
   const [stdoutCapture, stderrCapture] = await Promise.all([stdoutPromise, stderrPromise, inputPromise])
+  const usage = watcher.usage()
 
-  await sendUpdate(task.id, "exit", { exit_code: exitCode })
+  await sendUpdate(task.id, "exit", { exit_code: exitCode, usage })
 
   const notifyTarget = taskNotifyTarget(task)
   if (notifyTarget !== "") {
     try {
       const parsed = await Bun.file(userMessagesPath).json().catch(() => ({})) as {
-        messages?: Array<{ text?: string }>
+        messages?: Array<{ text?: string; parts?: unknown[] }>
       }
       const source = task.id ? `exec:${task.id}` : "exec"
       const messages = Array.isArray(parsed.messages) ? parsed.messages : []
       for (const msg of messages) {
         const text = typeof msg?.text === "string" ? msg.text.trim() : ""
-        if (text === "") continue
+        const parts = Array.isArray(msg?.parts) ? msg.parts : []
+        if (text === "" && parts.length === 0) continue
         await sendAssistantOutput(notifyTarget, {
           text,
           source,
           from_task_id: task.id,
+          ...(parts.length > 0 ? { parts } : {}),
         })
       }
     } catch (err) {
@@ -704,6 +881,16 @@ async function runTask(task: Task) {
     }
   }
 
+  const breach = watcher.breach()
+  if (breach) {
+    let error = `${breach.error} (peak memory ${usage.peak_memory_mb} MB, CPU ${usage.cpu_seconds}s, wall ${usage.wall_seconds}s)`
+    if (stderrCapture.captured) {
+      error = `${error}\n${stderrCapture.captured}`.trim()
+    }
+    await sendLimitExceeded(task.id, breach.limit, error, usage)
+    return
+  }
+
   if (exitCode !== 0) {
     let error = `exec failed with exit code ${exitCode}`
     if (stderrCapture.captured) {
@@ -740,6 +927,7 @@ async function runTask(task: Task) {
           result_byte_limit: RESULT_INLINE_BYTE_LIMIT,
         }
       }
+      if (limits) result.resource_usage = usage
       await sendComplete(task.id, result)
       return
     }
@@ -766,6 +954,7 @@ async function runTask(task: Task) {
     result = { error: `failed to read result: ${err}` }
   }
 
+  if (limits) result.resource_usage = usage
   await sendComplete(task.id, result)
 }
 
@@ -0,0 +1,234 @@
+// Package access maps API keys to principals and records who may view,
+// interact with or own each agent.
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Level is a principal's permission on one agent. Each level includes the
+// ones below it.
+type Level int
+
+const (
+	LevelNone Level = iota
+	// LevelView reads history, documents and progress.
+	LevelView
+	// LevelInteract also sends messages and runs replays.
+	LevelInteract
+	// LevelOwner also reconfigures, shares, grants and cancels the agent.
+	LevelOwner
+)
+
+var levelNames = map[Level]string{
+	LevelNone:     "none",
+	LevelView:     "view",
+	LevelInteract: "interact",
+	LevelOwner:    "owner",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "none"
+}
+
+func ParseLevel(value string) (Level, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for level, name := range levelNames {
+		if level != LevelNone && name == value {
+			return level, nil
+		}
+	}
+	return LevelNone, fmt.Errorf("invalid access level %q (expected view, interact or owner)", value)
+}
+
+// Principal is the identity behind an API key. Admins hold every permission
+// within their namespace. A principal with a namespace only sees the tasks
+// and events in it; one without sees every namespace.
+type Principal struct {
+	ID        string `json:"id"`
+	Admin     bool   `json:"admin,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Key configures one API key.
+type Key struct {
+	Key       string `json:"key"`
+	Principal string `json:"principal"`
+	Admin     bool   `json:"admin"`
+	Namespace string `json:"namespace"`
+}
+
+// Grant is one principal's level on an agent.
+type Grant struct {
+	AgentID   string    `json:"agent_id"`
+	Principal string    `json:"principal"`
+	Level     string    `json:"level"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var ErrUnknownKey = errors.New("unknown api key")
+
+type Store struct {
+	db   *sql.DB
+	keys map[[sha256.Size]byte]Principal
+
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+// NewStore returns a store that authenticates the given keys. Keys without a
+// key or principal are skipped.
+func NewStore(db *sql.DB, keys []Key, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		keys:  map[[sha256.Size]byte]Principal{},
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, k := range keys {
+		key, principal := strings.TrimSpace(k.Key), strings.TrimSpace(k.Principal)
+		if key == "" || principal == "" {
+			continue
+		}
+		s.keys[sha256.Sum256([]byte(key))] = Principal{ID: principal, Admin: k.Admin, Namespace: strings.TrimSpace(k.Namespace)}
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	return s.nowFn().UTC()
+}
+
+// Authenticate resolves an API key. Keys are compared by hash so lookups do
+// not leak timing about stored keys.
+func (s *Store) Authenticate(key string) (Principal, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return Principal{}, ErrUnknownKey
+	}
+	p, ok := s.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Principal{}, ErrUnknownKey
+	}
+	return p, nil
+}
+
+// Principal returns the configured principal with id. A principal that
+// appears on several keys is an admin if any of them is, and confined to a
+// namespace only if all of them are.
+func (s *Store) Principal(id string) (Principal, bool) {
+	var out Principal
+	found := false
+	for _, p := range s.keys {
+		if p.ID == id {
+			if !found || p.Namespace == "" {
+				out.Namespace = p.Namespace
+			}
+			out.ID = p.ID
+			out.Admin = out.Admin || p.Admin
+			found = true
+		}
+	}
+	return out, found
+}
+
+// Level returns p's level on agentID. Admins are owners of every agent.
+// found reports whether the agent has any grants at all, so callers can fall
+// back to a parent agent's grants.
+func (s *Store) Level(ctx context.Context, agentID string, p Principal) (level Level, found bool, err error) {
+	if p.Admin {
+		return LevelOwner, true, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT principal, level FROM agent_acl WHERE agent_id = ?`, agentID)
+	if err != nil {
+		return LevelNone, false, fmt.Errorf("query acl: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var principal, name string
+		if err := rows.Scan(&principal, &name); err != nil {
+			return LevelNone, false, fmt.Errorf("scan acl: %w", err)
+		}
+		found = true
+		if principal == p.ID {
+			level, _ = ParseLevel(name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return LevelNone, false, fmt.Errorf("iterate acl: %w", err)
+	}
+	return level, found, nil
+}
+
+// Grant sets principal's level on agentID, replacing any earlier grant.
+func (s *Store) Grant(ctx context.Context, agentID, principal string, level Level) error {
+	agentID, principal = strings.TrimSpace(agentID), strings.TrimSpace(principal)
+	if agentID == "" || principal == "" {
+		return fmt.Errorf("agent id and principal are required")
+	}
+	if level == LevelNone {
+		return s.Revoke(ctx, agentID, principal)
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO agent_acl (agent_id, principal, level, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(agent_id, principal) DO UPDATE SET level = excluded.level
+	`, agentID, principal, level.String(), s.now().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("insert grant: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Revoke(ctx context.Context, agentID, principal string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM agent_acl WHERE agent_id = ? AND principal = ?`, agentID, principal); err != nil {
+		return fmt.Errorf("delete grant: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Grants(ctx context.Context, agentID string) ([]Grant, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT agent_id, principal, level, created_at FROM agent_acl
+		WHERE agent_id = ? ORDER BY created_at, principal
+	`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("query grants: %w", err)
+	}
+	defer rows.Close()
+	var out []Grant
+	for rows.Next() {
+		var g Grant
+		var createdAt string
+		if err := rows.Scan(&g.AgentID, &g.Principal, &g.Level, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan grant: %w", err)
+		}
+		g.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		out = append(out, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate grants: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package access
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreAuthenticatesAndResolvesGrants(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	store := NewStore(db, []Key{
+		{Key: "alice-key", Principal: "alice"},
+		{Key: "root-key", Principal: "root", Admin: true},
+		{Key: "", Principal: "nobody"},
+	})
+	if _, err := store.Authenticate("wrong"); err != ErrUnknownKey {
+		t.Fatalf("expected unknown key, got %v", err)
+	}
+	alice, err := store.Authenticate("alice-key")
+	if err != nil || alice.ID != "alice" || alice.Admin {
+		t.Fatalf("unexpected principal %+v (%v)", alice, err)
+	}
+	root, _ := store.Authenticate("root-key")
+
+	ctx := context.Background()
+	if level, found, _ := store.Level(ctx, "agent-1", alice); level != LevelNone || found {
+		t.Fatalf("expected no grants yet, got %s found=%v", level, found)
+	}
+	if err := store.Grant(ctx, "agent-1", "bob", LevelOwner); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if err := store.Grant(ctx, "agent-1", "alice", LevelView); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if err := store.Grant(ctx, "agent-1", "alice", LevelInteract); err != nil {
+		t.Fatalf("regrant: %v", err)
+	}
+	if level, found, _ := store.Level(ctx, "agent-1", alice); level != LevelInteract || !found {
+		t.Fatalf("expected interact, got %s", level)
+	}
+	if level, _, _ := store.Level(ctx, "agent-1", root); level != LevelOwner {
+		t.Fatalf("expected admin to own every agent, got %s", level)
+	}
+
+	if err := store.Revoke(ctx, "agent-1", "alice"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if level, found, _ := store.Level(ctx, "agent-1", alice); level != LevelNone || !found {
+		t.Fatalf("expected revoked access on a granted agent, got %s found=%v", level, found)
+	}
+	grants, err := store.Grants(ctx, "agent-1")
+	if err != nil || len(grants) != 1 || grants[0].Principal != "bob" || grants[0].Level != "owner" {
+		t.Fatalf("unexpected grants %+v (%v)", grants, err)
+	}
+	if _, err := ParseLevel("write"); err == nil {
+		t.Fatalf("expected invalid level error")
+	}
+}
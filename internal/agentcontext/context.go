@@ -5,6 +5,7 @@ import "context"
 type contextKey string
 
 const taskIDKey contextKey = "task_id"
+const namespaceKey contextKey = "namespace"
 
 func WithTaskID(ctx context.Context, taskID string) context.Context {
 	if taskID == "" {
@@ -22,3 +23,21 @@ func TaskIDFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithNamespace scopes the tasks and events created under ctx to namespace.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	if namespace == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, namespaceKey, namespace)
+}
+
+func NamespaceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if val, ok := ctx.Value(namespaceKey).(string); ok {
+		return val
+	}
+	return ""
+}
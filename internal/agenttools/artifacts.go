@@ -0,0 +1,118 @@
+package agenttools
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/content"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+const (
+	readArtifactDefaultLimit = 8000
+	readArtifactMaxLimit     = 12000
+)
+
+type ReadArtifactParams struct {
+	ArtifactID string `json:"artifact_id" description:"ID of the artifact, e.g. art-..."`
+	Offset     int    `json:"offset,omitempty" description:"Character offset to start reading from"`
+	Limit      int    `json:"limit,omitempty" description:"Maximum characters to return (default 8000, max 12000)"`
+}
+
+type offloadedTool struct {
+	llmtools.Tool
+	offloader *artifacts.Offloader
+}
+
+// Offloaded wraps tools so oversized text results are stored as artifacts
+// and the model gets a summary and the artifact ID instead. Results with
+// errors or images pass through unchanged, as does read_artifact itself.
+func Offloaded(offloader *artifacts.Offloader, tools ...llmtools.Tool) []llmtools.Tool {
+	if offloader == nil {
+		return tools
+	}
+	out := make([]llmtools.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		if tool.FuncName() == "read_artifact" {
+			out = append(out, tool)
+			continue
+		}
+		out = append(out, &offloadedTool{Tool: tool, offloader: offloader})
+	}
+	return out
+}
+
+func (t *offloadedTool) Run(r llmtools.Runner, params json.RawMessage) llmtools.Result {
+	result := t.Tool.Run(r, params)
+	if result == nil || result.Error() != nil {
+		return result
+	}
+	var text strings.Builder
+	for _, item := range result.Content() {
+		part, ok := item.(*content.Text)
+		if !ok {
+			return result
+		}
+		text.WriteString(part.Text)
+	}
+	agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+	if agentID == "" {
+		return result
+	}
+	name := t.FuncName()
+	artifact, ok, err := t.offloader.Offload(r.Context(), agentID, "", "tool:"+name, text.String())
+	if err != nil || !ok {
+		return result
+	}
+	return toolresult.SuccessWithLabel(name, result.Label(), map[string]any{
+		"artifact_id":    artifact.ID,
+		"original_chars": artifact.Chars,
+		"summary":        artifact.Summary,
+		"note":           "The full result was too large to show inline. Use read_artifact to page through it.",
+	})
+}
+
+func ReadArtifactTool(store *artifacts.Store) llmtools.Tool {
+	return llmtools.Func(
+		"ReadArtifact",
+		"Read part of a stored artifact: a large tool result or reply that was replaced by a summary",
+		"read_artifact",
+		func(r llmtools.Runner, p ReadArtifactParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("read_artifact", "artifacts unavailable")
+			}
+			artifact, err := store.Get(r.Context(), p.ArtifactID)
+			if errors.Is(err, artifacts.ErrArtifactNotFound) {
+				return toolresult.Errorf("read_artifact", "artifact %q not found", strings.TrimSpace(p.ArtifactID))
+			}
+			if err != nil {
+				return toolresult.ErrorWithLabel("read_artifact", "read_artifact failed", err)
+			}
+			limit := p.Limit
+			if limit <= 0 {
+				limit = readArtifactDefaultLimit
+			}
+			limit = min(limit, readArtifactMaxLimit)
+			offset := min(max(p.Offset, 0), len(artifact.Content))
+			end := min(offset+limit, len(artifact.Content))
+			out := map[string]any{
+				"artifact_id": artifact.ID,
+				"source":      artifact.Source,
+				"total_chars": artifact.Chars,
+				"offset":      offset,
+				"content":     strings.ToValidUTF8(artifact.Content[offset:end], ""),
+			}
+			if end < len(artifact.Content) {
+				out["next_offset"] = end
+			}
+			return toolresult.Success("read_artifact", out)
+		},
+	)
+}
@@ -0,0 +1,65 @@
+package agenttools
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// AgentReply is another agent's reply to a message sent with Ask.
+type AgentReply struct {
+	AgentID string `json:"agent_id"`
+	// CorrelationID ties the reply to the message; MessageID and ReplyID
+	// are the events of the message and the reply.
+	CorrelationID string `json:"correlation_id"`
+	MessageID     string `json:"message_id"`
+	ReplyID       string `json:"reply_id"`
+	Text          string `json:"text"`
+	// QuestionID is set when the agent answered with a question of its own.
+	QuestionID string `json:"question_id,omitempty"`
+	ElapsedMS  int64  `json:"elapsed_ms"`
+}
+
+// AgentAsker sends a message to an agent and waits for its reply.
+// engine.Runtime implements it.
+type AgentAsker interface {
+	Ask(ctx context.Context, source, target, message string, timeout time.Duration) (AgentReply, error)
+}
+
+type AskAgentParams struct {
+	Agent          string `json:"agent" description:"The id of the agent to ask"`
+	Message        string `json:"message" description:"The request, written to be understood on its own"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" description:"Seconds to wait for the reply; defaults to 120, at most 600"`
+}
+
+func AskAgentTool(asker AgentAsker) llmtools.Tool {
+	return llmtools.Func(
+		"AskAgent",
+		"Send a message to another agent and wait for its reply, which is returned as the result",
+		"ask_agent",
+		func(r llmtools.Runner, p AskAgentParams) llmtools.Result {
+			if asker == nil {
+				return toolresult.Errorf("ask_agent", "agents unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("ask_agent", "calling agent unknown")
+			}
+			if strings.TrimSpace(p.Agent) == "" {
+				return toolresult.Errorf("ask_agent", "agent is required")
+			}
+			if p.TimeoutSeconds < 0 {
+				return toolresult.Errorf("ask_agent", "timeout_seconds must not be negative")
+			}
+			reply, err := asker.Ask(r.Context(), agentID, p.Agent, p.Message, time.Duration(p.TimeoutSeconds)*time.Second)
+			if err != nil {
+				return toolresult.ErrorWithLabel("ask_agent", "ask_agent failed", err)
+			}
+			return toolresult.Success("ask_agent", reply)
+		},
+	)
+}
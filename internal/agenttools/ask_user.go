@@ -0,0 +1,42 @@
+package agenttools
+
+import (
+	"context"
+
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// QuestionAsker sends a question to the source of the current turn and
+// records it until answered. engine.Runtime implements it.
+type QuestionAsker interface {
+	AskUser(ctx context.Context, question string, options []string) (questions.Question, error)
+}
+
+type AskUserParams struct {
+	Question string   `json:"question" description:"The question to ask, phrased so it can be answered on its own"`
+	Options  []string `json:"options,omitempty" description:"Optional suggested answers"`
+}
+
+func AskUserTool(asker QuestionAsker) llmtools.Tool {
+	return llmtools.Func(
+		"AskUser",
+		"Ask the sender of the current message a question; their reply arrives in a later turn marked with in_reply_to",
+		"ask_user",
+		func(r llmtools.Runner, p AskUserParams) llmtools.Result {
+			if asker == nil {
+				return toolresult.Errorf("ask_user", "questions unavailable")
+			}
+			q, err := asker.AskUser(r.Context(), p.Question, p.Options)
+			if err != nil {
+				return toolresult.ErrorWithLabel("ask_user", "ask_user failed", err)
+			}
+			return toolresult.Success("ask_user", map[string]any{
+				"question_id": q.ID,
+				"status":      q.Status,
+				"sent_to":     q.Source,
+			})
+		},
+	)
+}
@@ -0,0 +1,121 @@
+package agenttools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+const (
+	// awaitUpdatesChars bounds the update text await_task returns per task
+	// with include_updates; the most recent output is kept.
+	awaitUpdatesChars = 8000
+	awaitUpdatesPage  = 200
+	// awaitUpdatesMaxPages stops a very chatty task from turning one await
+	// into a long scan.
+	awaitUpdatesMaxPages = 20
+)
+
+// normalizeUpdateKinds trims and dedupes the kinds passed to include_updates.
+func normalizeUpdateKinds(kinds []string) []string {
+	out := make([]string, 0, len(kinds))
+	seen := map[string]struct{}{}
+	for _, kind := range kinds {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if _, ok := seen[kind]; ok {
+			continue
+		}
+		seen[kind] = struct{}{}
+		out = append(out, kind)
+	}
+	return out
+}
+
+// updateBaselines records each task's latest update so that updates
+// produced during the await can be told apart from earlier ones.
+func updateBaselines(ctx context.Context, manager *tasks.Manager, taskIDs []string) map[string]string {
+	out := make(map[string]string, len(taskIDs))
+	for _, id := range taskIDs {
+		if latest, found, err := manager.LatestUpdate(ctx, id, ""); err == nil && found {
+			out[id] = latest.ID
+		}
+	}
+	return out
+}
+
+// streamedUpdates joins the text of taskID's updates of each kind recorded
+// after baseline. Each kind gets an equal share of awaitUpdatesChars; when
+// output is longer, its start is dropped and the dropped length is reported
+// under "omitted_chars". It returns nil when nothing was recorded.
+func streamedUpdates(ctx context.Context, manager *tasks.Manager, taskID, baseline string, kinds []string) map[string]any {
+	if len(kinds) == 0 {
+		return nil
+	}
+	budget := awaitUpdatesChars / len(kinds)
+	out := map[string]any{}
+	omitted := map[string]int{}
+	for _, kind := range kinds {
+		var text strings.Builder
+		dropped := 0
+		after := baseline
+		for page := 0; page < awaitUpdatesMaxPages; page++ {
+			updates, err := manager.ListUpdatesSince(ctx, taskID, after, kind, awaitUpdatesPage)
+			if err != nil || len(updates) == 0 {
+				break
+			}
+			for _, upd := range updates {
+				text.WriteString(updateText(upd))
+			}
+			if text.Len() > 2*budget {
+				kept := text.String()[text.Len()-budget:]
+				dropped += text.Len() - budget
+				text.Reset()
+				text.WriteString(kept)
+			}
+			after = updates[len(updates)-1].ID
+			if len(updates) < awaitUpdatesPage {
+				break
+			}
+		}
+		s := text.String()
+		if len(s) > budget {
+			dropped += len(s) - budget
+			s = s[len(s)-budget:]
+		}
+		if s == "" && dropped == 0 {
+			continue
+		}
+		out[kind] = strings.ToValidUTF8(s, "")
+		if dropped > 0 {
+			omitted[kind] = dropped
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if len(omitted) > 0 {
+		out["omitted_chars"] = omitted
+	}
+	return out
+}
+
+// updateText is an update's text payload, as stdout and stderr chunks carry,
+// or its payload as a JSON line otherwise.
+func updateText(upd tasks.Update) string {
+	if text, ok := upd.Payload["text"].(string); ok {
+		return text
+	}
+	if len(upd.Payload) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(upd.Payload)
+	if err != nil {
+		return ""
+	}
+	return string(data) + "\n"
+}
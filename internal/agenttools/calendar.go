@@ -0,0 +1,176 @@
+package agenttools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/calendar"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type ListCalendarEventsParams struct {
+	From string `json:"from,omitempty" description:"Start of the range as RFC 3339; defaults to now"`
+	To   string `json:"to,omitempty" description:"End of the range as RFC 3339; defaults to 7 days after from"`
+}
+
+type CreateCalendarEventParams struct {
+	Title       string `json:"title" description:"Event title"`
+	Start       string `json:"start" description:"Start time as RFC 3339, or a date (YYYY-MM-DD) for an all-day event"`
+	End         string `json:"end,omitempty" description:"End time in the same form as start; defaults to one hour (or one day) later"`
+	Location    string `json:"location,omitempty" description:"Optional location"`
+	Description string `json:"description,omitempty" description:"Optional notes"`
+}
+
+type SetReminderParams struct {
+	Text      string `json:"text" description:"What to be reminded of"`
+	At        string `json:"at,omitempty" description:"When to fire, as RFC 3339"`
+	InMinutes int    `json:"in_minutes,omitempty" description:"Fire this many minutes from now instead of at"`
+	EventID   string `json:"event_id,omitempty" description:"Optional calendar event the reminder is about"`
+}
+
+func ListCalendarEventsTool(cal *calendar.Service) llmtools.Tool {
+	return llmtools.Func(
+		"ListCalendarEvents",
+		"List events on your calendar in a time range",
+		"list_calendar_events",
+		func(r llmtools.Runner, p ListCalendarEventsParams) llmtools.Result {
+			if cal == nil {
+				return toolresult.Errorf("list_calendar_events", "calendar unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("list_calendar_events", "calling agent unknown")
+			}
+			from := time.Now().UTC()
+			if strings.TrimSpace(p.From) != "" {
+				t, err := time.Parse(time.RFC3339, strings.TrimSpace(p.From))
+				if err != nil {
+					return toolresult.Errorf("list_calendar_events", "from must be RFC 3339: %v", err)
+				}
+				from = t
+			}
+			to := from.AddDate(0, 0, 7)
+			if strings.TrimSpace(p.To) != "" {
+				t, err := time.Parse(time.RFC3339, strings.TrimSpace(p.To))
+				if err != nil {
+					return toolresult.Errorf("list_calendar_events", "to must be RFC 3339: %v", err)
+				}
+				to = t
+			}
+			events, err := cal.ListEvents(r.Context(), agentID, from, to)
+			if err != nil {
+				return toolresult.ErrorWithLabel("list_calendar_events", "list_calendar_events failed", err)
+			}
+			return toolresult.Success("list_calendar_events", map[string]any{
+				"from":   from.UTC(),
+				"to":     to.UTC(),
+				"events": events,
+			})
+		},
+	)
+}
+
+func CreateCalendarEventTool(cal *calendar.Service) llmtools.Tool {
+	return llmtools.Func(
+		"CreateCalendarEvent",
+		"Create an event on your calendar",
+		"create_calendar_event",
+		func(r llmtools.Runner, p CreateCalendarEventParams) llmtools.Result {
+			if cal == nil {
+				return toolresult.Errorf("create_calendar_event", "calendar unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("create_calendar_event", "calling agent unknown")
+			}
+			start, allDay, err := parseEventTime(p.Start)
+			if err != nil {
+				return toolresult.Errorf("create_calendar_event", "start: %v", err)
+			}
+			event := calendar.Event{
+				Title:       p.Title,
+				Start:       start,
+				AllDay:      allDay,
+				Location:    strings.TrimSpace(p.Location),
+				Description: strings.TrimSpace(p.Description),
+			}
+			if strings.TrimSpace(p.End) != "" {
+				end, endAllDay, err := parseEventTime(p.End)
+				if err != nil {
+					return toolresult.Errorf("create_calendar_event", "end: %v", err)
+				}
+				if endAllDay != allDay {
+					return toolresult.Errorf("create_calendar_event", "start and end must both be dates or both be times")
+				}
+				event.End = end
+			}
+			created, err := cal.CreateEvent(r.Context(), agentID, event)
+			if err != nil {
+				return toolresult.ErrorWithLabel("create_calendar_event", "create_calendar_event failed", err)
+			}
+			return toolresult.Success("create_calendar_event", map[string]any{"event": created})
+		},
+	)
+}
+
+func SetReminderTool(cal *calendar.Service) llmtools.Tool {
+	return llmtools.Func(
+		"SetReminder",
+		"Schedule a reminder that wakes you with its text at a given time",
+		"set_reminder",
+		func(r llmtools.Runner, p SetReminderParams) llmtools.Result {
+			if cal == nil {
+				return toolresult.Errorf("set_reminder", "reminders unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("set_reminder", "calling agent unknown")
+			}
+			var at time.Time
+			switch {
+			case strings.TrimSpace(p.At) != "" && p.InMinutes > 0:
+				return toolresult.Errorf("set_reminder", "give at or in_minutes, not both")
+			case strings.TrimSpace(p.At) != "":
+				t, err := time.Parse(time.RFC3339, strings.TrimSpace(p.At))
+				if err != nil {
+					return toolresult.Errorf("set_reminder", "at must be RFC 3339: %v", err)
+				}
+				at = t
+			case p.InMinutes > 0:
+				at = time.Now().UTC().Add(time.Duration(p.InMinutes) * time.Minute)
+			default:
+				return toolresult.Errorf("set_reminder", "at or in_minutes is required")
+			}
+			reminder, err := cal.SetReminder(r.Context(), calendar.Reminder{
+				AgentID:  agentID,
+				Text:     p.Text,
+				EventID:  p.EventID,
+				RemindAt: at,
+			})
+			if err != nil {
+				return toolresult.ErrorWithLabel("set_reminder", "set_reminder failed", err)
+			}
+			return toolresult.Success("set_reminder", map[string]any{
+				"reminder_id": reminder.ID,
+				"remind_at":   reminder.RemindAt,
+			})
+		},
+	)
+}
+
+// parseEventTime reads an RFC 3339 time, or a YYYY-MM-DD date for all-day
+// events.
+func parseEventTime(value string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("want RFC 3339 or YYYY-MM-DD, got %q", value)
+	}
+	return t, false, nil
+}
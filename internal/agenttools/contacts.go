@@ -0,0 +1,110 @@
+package agenttools
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/contacts"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type LookupContactParams struct {
+	Query string `json:"query,omitempty" description:"Name, alias, ID or handle to search for; omit to list every contact"`
+}
+
+type MessageContactParams struct {
+	Contact string `json:"contact" description:"The contact's ID, name or alias"`
+	Text    string `json:"text" description:"The message, written to be read on its own"`
+	Channel string `json:"channel,omitempty" description:"One of the contact's channel names; omit to use their preferred channel"`
+	Urgent  bool   `json:"urgent,omitempty" description:"Send even during the contact's quiet hours"`
+}
+
+func LookupContactTool(store *contacts.Store) llmtools.Tool {
+	return llmtools.Func(
+		"LookupContact",
+		"Find people in the contact book and see their channels, local time and preferences",
+		"lookup_contact",
+		func(r llmtools.Runner, p LookupContactParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("lookup_contact", "contacts unavailable")
+			}
+			list, err := store.Search(r.Context(), p.Query)
+			if err != nil {
+				return toolresult.ErrorWithLabel("lookup_contact", "lookup_contact failed", err)
+			}
+			now := time.Now()
+			out := make([]map[string]any, 0, len(list))
+			for _, c := range list {
+				channels := make([]string, 0, len(c.Channels))
+				for _, ch := range c.Channels {
+					channels = append(channels, ch.Name)
+				}
+				entry := map[string]any{
+					"id":         c.ID,
+					"name":       c.Name,
+					"channels":   channels,
+					"local_time": now.In(c.Location()).Format("Mon 15:04 MST"),
+				}
+				if len(c.Aliases) > 0 {
+					entry["aliases"] = c.Aliases
+				}
+				if c.Timezone != "" {
+					entry["timezone"] = c.Timezone
+				}
+				if c.Preferences.Channel != "" {
+					entry["preferred_channel"] = c.Preferences.Channel
+				}
+				if c.Preferences.QuietHours != "" {
+					entry["quiet_hours"] = c.Preferences.QuietHours
+					if until, quiet := c.QuietUntil(now); quiet {
+						entry["quiet_until"] = until.Format("15:04 MST")
+					}
+				}
+				if c.Notes != "" {
+					entry["notes"] = c.Notes
+				}
+				out = append(out, entry)
+			}
+			return toolresult.Success("lookup_contact", map[string]any{"contacts": out})
+		},
+	)
+}
+
+func MessageContactTool(deliverer *contacts.Deliverer) llmtools.Tool {
+	return llmtools.Func(
+		"MessageContact",
+		"Send a message to a person in the contact book over their preferred or a chosen channel",
+		"message_contact",
+		func(r llmtools.Runner, p MessageContactParams) llmtools.Result {
+			if deliverer == nil {
+				return toolresult.Errorf("message_contact", "contacts unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("message_contact", "calling agent unknown")
+			}
+			delivery, err := deliverer.Send(r.Context(), contacts.Message{
+				AgentID: agentID,
+				Contact: p.Contact,
+				Channel: p.Channel,
+				Text:    p.Text,
+				Urgent:  p.Urgent,
+			})
+			var quiet *contacts.QuietHoursError
+			switch {
+			case errors.As(err, &quiet):
+				return toolresult.Error("message_contact", err)
+			case errors.Is(err, contacts.ErrContactNotFound):
+				return toolresult.Errorf("message_contact", "%v; call lookup_contact to find the right contact", err)
+			case errors.Is(err, contacts.ErrChannelNotFound):
+				return toolresult.Error("message_contact", err)
+			case err != nil:
+				return toolresult.ErrorWithLabel("message_contact", "message_contact failed", err)
+			}
+			return toolresult.Success("message_contact", delivery)
+		},
+	)
+}
@@ -0,0 +1,39 @@
+package agenttools
+
+import (
+	"encoding/json"
+
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type dryRunTool struct {
+	llmtools.Tool
+}
+
+// DryRun wraps tools so they keep their name and schema but never execute.
+// Each call reports the arguments it would have run with.
+func DryRun(tools ...llmtools.Tool) []llmtools.Tool {
+	out := make([]llmtools.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		out = append(out, &dryRunTool{Tool: tool})
+	}
+	return out
+}
+
+func (t *dryRunTool) Run(_ llmtools.Runner, params json.RawMessage) llmtools.Result {
+	var args any
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &args); err != nil {
+			args = string(params)
+		}
+	}
+	return toolresult.Success(t.FuncName(), map[string]any{
+		"dry_run": true,
+		"status":  "not executed",
+		"args":    args,
+	})
+}
@@ -15,9 +15,10 @@ import (
 )
 
 type ExecParams struct {
-	ID          string `json:"id,omitempty" description:"Optional custom task ID (lowercase letters, digits, dashes; max 64 chars)"`
-	Code        string `json:"code" description:"TypeScript code to run in Bun"`
-	WaitSeconds *int   `json:"wait_seconds" description:"Required seconds to wait before returning; use 0 to return immediately"`
+	ID          string        `json:"id,omitempty" description:"Optional custom task ID (lowercase letters, digits, dashes; max 64 chars)"`
+	Code        string        `json:"code" description:"TypeScript code to run in Bun"`
+	WaitSeconds *int          `json:"wait_seconds" description:"Required seconds to wait before returning; use 0 to return immediately"`
+	Limits      *tasks.Limits `json:"limits,omitempty" description:"Optional resource caps; a task that exceeds one ends with status limit_exceeded and its peak usage"`
 }
 
 func ExecTool(manager *tasks.Manager) llmtools.Tool {
@@ -39,6 +40,17 @@ func ExecTool(manager *tasks.Manager) llmtools.Tool {
 			if code == "" {
 				return toolresult.Errorf("exec", "code is required")
 			}
+			payload := map[string]any{
+				"code": code,
+			}
+			if p.Limits != nil {
+				if err := p.Limits.Validate(); err != nil {
+					return toolresult.Error("exec", err)
+				}
+				if !p.Limits.IsZero() {
+					payload["limits"] = p.Limits.Payload()
+				}
+			}
 			metadata := map[string]any{}
 			if tc, ok := llms.GetToolCall(r.Context()); ok {
 				metadata["tool_call_id"] = tc.ID
@@ -57,9 +69,7 @@ func ExecTool(manager *tasks.Manager) llmtools.Tool {
 				Owner:    owner,
 				ParentID: parentID,
 				Metadata: metadata,
-				Payload: map[string]any{
-					"code": code,
-				},
+				Payload:  payload,
 			}
 			task, err := manager.Spawn(r.Context(), spec)
 			if err != nil {
@@ -95,7 +105,7 @@ func ExecTool(manager *tasks.Manager) llmtools.Tool {
 			if awaited.Status == tasks.StatusCompleted {
 				resp["result"] = awaited.Result
 			}
-			if awaited.Status == tasks.StatusFailed || awaited.Status == tasks.StatusCancelled {
+			if awaited.Status == tasks.StatusFailed || awaited.Status == tasks.StatusCancelled || awaited.Status == tasks.StatusLimitExceeded {
 				resp["error"] = awaited.Error
 				if awaited.Result != nil {
 					resp["result"] = awaited.Result
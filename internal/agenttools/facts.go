@@ -0,0 +1,77 @@
+package agenttools
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/facts"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type PinFactParams struct {
+	Text   string `json:"text" description:"The fact as one short self-contained sentence"`
+	FactID string `json:"fact_id,omitempty" description:"ID of a pinned fact to replace instead of adding a new one"`
+}
+
+type UnpinFactParams struct {
+	FactID string `json:"fact_id" description:"ID of the pinned fact to remove"`
+}
+
+func PinFactTool(store *facts.Store) llmtools.Tool {
+	return llmtools.Func(
+		"PinFact",
+		"Pin a durable fact you must remember on every turn, or correct a pinned fact",
+		"pin_fact",
+		func(r llmtools.Runner, p PinFactParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("pin_fact", "facts unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("pin_fact", "calling agent unknown")
+			}
+			if strings.TrimSpace(p.FactID) != "" {
+				fact, err := store.Update(r.Context(), agentID, p.FactID, p.Text, facts.SourceAgent)
+				if errors.Is(err, facts.ErrFactNotFound) {
+					return toolresult.Errorf("pin_fact", "fact %q not found", strings.TrimSpace(p.FactID))
+				}
+				if err != nil {
+					return toolresult.ErrorWithLabel("pin_fact", "pin_fact failed", err)
+				}
+				return toolresult.Success("pin_fact", map[string]any{"fact": fact, "updated": true})
+			}
+			fact, added, err := store.Add(r.Context(), facts.Fact{AgentID: agentID, Text: p.Text, Source: facts.SourceAgent})
+			if err != nil {
+				return toolresult.ErrorWithLabel("pin_fact", "pin_fact failed", err)
+			}
+			return toolresult.Success("pin_fact", map[string]any{"fact": fact, "added": added})
+		},
+	)
+}
+
+func UnpinFactTool(store *facts.Store) llmtools.Tool {
+	return llmtools.Func(
+		"UnpinFact",
+		"Remove a pinned fact that is wrong or no longer true",
+		"unpin_fact",
+		func(r llmtools.Runner, p UnpinFactParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("unpin_fact", "facts unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("unpin_fact", "calling agent unknown")
+			}
+			err := store.Delete(r.Context(), agentID, p.FactID)
+			if errors.Is(err, facts.ErrFactNotFound) {
+				return toolresult.Errorf("unpin_fact", "fact %q not found", strings.TrimSpace(p.FactID))
+			}
+			if err != nil {
+				return toolresult.ErrorWithLabel("unpin_fact", "unpin_fact failed", err)
+			}
+			return toolresult.Success("unpin_fact", map[string]any{"fact_id": strings.TrimSpace(p.FactID), "removed": true})
+		},
+	)
+}
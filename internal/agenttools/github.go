@@ -0,0 +1,38 @@
+package agenttools
+
+import (
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/github"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type CommentOnGitHubParams struct {
+	Repo   string `json:"repo" description:"Repository as owner/name"`
+	Number int    `json:"number" description:"Issue or pull request number"`
+	Body   string `json:"body" description:"Comment text in GitHub Markdown"`
+}
+
+func CommentOnGitHubTool(gh *github.Service) llmtools.Tool {
+	return llmtools.Func(
+		"CommentOnGitHub",
+		"Comment on an issue or pull request in a GitHub repository you watch",
+		"comment_on_github",
+		func(r llmtools.Runner, p CommentOnGitHubParams) llmtools.Result {
+			if gh == nil {
+				return toolresult.Errorf("comment_on_github", "github unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("comment_on_github", "calling agent unknown")
+			}
+			comment, err := gh.Comment(r.Context(), agentID, p.Repo, p.Number, p.Body)
+			if err != nil {
+				return toolresult.ErrorWithLabel("comment_on_github", "comment_on_github failed", err)
+			}
+			return toolresult.Success("comment_on_github", map[string]any{"comment": comment})
+		},
+	)
+}
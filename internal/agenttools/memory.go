@@ -0,0 +1,117 @@
+package agenttools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type RememberParams struct {
+	Key  string   `json:"key,omitempty" description:"A short name for the memory; remembering under the same key again replaces it"`
+	Text string   `json:"text" description:"What to remember, written to be understood on its own later"`
+	Tags []string `json:"tags,omitempty" description:"Tags to group and filter memories by"`
+}
+
+type RecallParams struct {
+	Query string   `json:"query,omitempty" description:"Words to match against your memories; leave empty to list the newest"`
+	Tags  []string `json:"tags,omitempty" description:"Only memories carrying all of these tags"`
+	Limit int      `json:"limit,omitempty" description:"How many memories to return; defaults to 5, at most 50"`
+}
+
+func RememberTool(store *memory.Store) llmtools.Tool {
+	return llmtools.Func(
+		"Remember",
+		"Save a long-term memory you can recall in later conversations",
+		"remember",
+		func(r llmtools.Runner, p RememberParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("remember", "memory unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("remember", "calling agent unknown")
+			}
+			m, created, err := store.Remember(r.Context(), memory.Memory{
+				AgentID: agentID,
+				Key:     p.Key,
+				Text:    p.Text,
+				Tags:    p.Tags,
+				TaskID:  tasks.ParentTaskIDFromContext(r.Context()),
+			})
+			if err != nil {
+				return toolresult.ErrorWithLabel("remember", "remember failed", err)
+			}
+			return toolresult.Success("remember", map[string]any{"memory": m, "created": created})
+		},
+	)
+}
+
+func RecallTool(store *memory.Store) llmtools.Tool {
+	return llmtools.Func(
+		"Recall",
+		"Search your long-term memories by words and tags",
+		"recall",
+		func(r llmtools.Runner, p RecallParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("recall", "memory unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("recall", "calling agent unknown")
+			}
+			if p.Limit < 0 {
+				return toolresult.Errorf("recall", "limit must not be negative")
+			}
+			list, err := store.Recall(r.Context(), agentID, memory.Query{Text: p.Query, Tags: p.Tags, Limit: p.Limit})
+			if err != nil {
+				return toolresult.ErrorWithLabel("recall", "recall failed", err)
+			}
+			return toolresult.Success("recall", map[string]any{"memories": list})
+		},
+	)
+}
+
+// MemorySearcher finds an agent's memories and past messages by meaning.
+// engine.Runtime implements it.
+type MemorySearcher interface {
+	SearchMemory(ctx context.Context, agentID string, q memory.SearchQuery) ([]memory.Hit, error)
+}
+
+type SearchMemoryParams struct {
+	Query   string   `json:"query" description:"What you are looking for, in your own words; matched by meaning rather than exact words"`
+	Limit   int      `json:"limit,omitempty" description:"How many results to return; defaults to 5, at most 50"`
+	Sources []string `json:"sources,omitempty" description:"Search only \"memory\" (what you saved with remember) or \"history\" (past messages); defaults to both"`
+}
+
+func SearchMemoryTool(searcher MemorySearcher) llmtools.Tool {
+	return llmtools.Func(
+		"Search Memory",
+		"Search your long-term memories and past conversation messages by meaning",
+		"search_memory",
+		func(r llmtools.Runner, p SearchMemoryParams) llmtools.Result {
+			if searcher == nil {
+				return toolresult.Errorf("search_memory", "memory unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("search_memory", "calling agent unknown")
+			}
+			if strings.TrimSpace(p.Query) == "" {
+				return toolresult.Errorf("search_memory", "query is required")
+			}
+			if p.Limit < 0 {
+				return toolresult.Errorf("search_memory", "limit must not be negative")
+			}
+			hits, err := searcher.SearchMemory(r.Context(), agentID, memory.SearchQuery{Text: p.Query, Limit: p.Limit, Sources: p.Sources})
+			if err != nil {
+				return toolresult.ErrorWithLabel("search_memory", "search failed", err)
+			}
+			return toolresult.Success("search_memory", map[string]any{"results": hits})
+		},
+	)
+}
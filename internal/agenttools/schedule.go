@@ -0,0 +1,65 @@
+package agenttools
+
+import (
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/scheduler"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type ScheduleTaskParams struct {
+	Cron     string `json:"cron,omitempty" description:"Five-field cron expression (minute hour day-of-month month day-of-week), e.g. \"0 9 * * *\" for every day at 9:00 or \"30 8 * * mon-fri\" for weekdays at 8:30; @hourly, @daily and @weekly also work"`
+	Text     string `json:"text,omitempty" description:"What to do when woken; it is the body of each wake"`
+	Timezone string `json:"timezone,omitempty" description:"IANA timezone the expression is read in, e.g. Europe/Stockholm; defaults to UTC"`
+	CancelID string `json:"cancel_id,omitempty" description:"ID of one of your schedules to delete instead of creating one"`
+}
+
+func ScheduleTaskTool(sched *scheduler.Service) llmtools.Tool {
+	return llmtools.Func(
+		"ScheduleTask",
+		"Ask to be woken on a recurring cron schedule, or cancel one of your schedules",
+		"schedule_task",
+		func(r llmtools.Runner, p ScheduleTaskParams) llmtools.Result {
+			if sched == nil {
+				return toolresult.Errorf("schedule_task", "scheduler unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("schedule_task", "calling agent unknown")
+			}
+			if cancelID := strings.TrimSpace(p.CancelID); cancelID != "" {
+				if strings.TrimSpace(p.Cron) != "" {
+					return toolresult.Errorf("schedule_task", "give cron or cancel_id, not both")
+				}
+				existing, err := sched.Get(r.Context(), cancelID)
+				if err != nil || existing.TaskID != agentID {
+					return toolresult.Errorf("schedule_task", "no schedule %s of yours", cancelID)
+				}
+				if _, err := sched.Delete(r.Context(), cancelID); err != nil {
+					return toolresult.ErrorWithLabel("schedule_task", "schedule_task failed", err)
+				}
+				return toolresult.Success("schedule_task", map[string]any{"cancelled": cancelID})
+			}
+			if strings.TrimSpace(p.Cron) == "" {
+				return toolresult.Errorf("schedule_task", "cron or cancel_id is required")
+			}
+			created, err := sched.Create(r.Context(), scheduler.Schedule{
+				TaskID:   agentID,
+				Cron:     p.Cron,
+				Timezone: p.Timezone,
+				Text:     p.Text,
+				Enabled:  true,
+			})
+			if err != nil {
+				return toolresult.ErrorWithLabel("schedule_task", "schedule_task failed", err)
+			}
+			return toolresult.Success("schedule_task", map[string]any{
+				"schedule_id": created.ID,
+				"cron":        created.Cron,
+				"next_run_at": created.NextRunAt,
+			})
+		},
+	)
+}
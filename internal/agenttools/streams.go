@@ -0,0 +1,146 @@
+package agenttools
+
+import (
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+const maxStreamReadLimit = 200
+
+type ReadStreamParams struct {
+	Stream    string `json:"stream" description:"Stream to read, e.g. task_output, signals or external"`
+	Reader    string `json:"reader,omitempty" description:"Reader whose read state to report; defaults to the caller"`
+	ScopeType string `json:"scope_type,omitempty" description:"Only events with this scope type (task, topic or global)"`
+	ScopeID   string `json:"scope_id,omitempty" description:"Only events with this scope id, e.g. an agent id"`
+	After     string `json:"after,omitempty" description:"Only events after this event id, oldest first"`
+	Unread    bool   `json:"unread,omitempty" description:"Only events the reader has not acked"`
+	Limit     int    `json:"limit,omitempty" description:"Maximum events to return (default 50, max 200)"`
+}
+
+type AckStreamParams struct {
+	Stream string   `json:"stream" description:"Stream the events belong to"`
+	IDs    []string `json:"ids" description:"Event ids to mark read"`
+	Reader string   `json:"reader,omitempty" description:"Reader to mark them read for; defaults to the caller"`
+}
+
+// ReadStreamTool reads events from an event stream. Without a scope it
+// lists events of every scope, so callers outside any agent see everything.
+func ReadStreamTool(bus *eventbus.Bus) llmtools.Tool {
+	return llmtools.Func(
+		"ReadStream",
+		"Read events from an event stream with their read state",
+		"read_stream",
+		func(r llmtools.Runner, p ReadStreamParams) llmtools.Result {
+			if bus == nil {
+				return toolresult.Errorf("read_stream", "event bus unavailable")
+			}
+			stream := strings.TrimSpace(p.Stream)
+			if stream == "" {
+				return toolresult.Errorf("read_stream", "stream is required")
+			}
+			limit := p.Limit
+			if limit <= 0 {
+				limit = 50
+			}
+			limit = min(limit, maxStreamReadLimit)
+			reader := streamReader(r, p.Reader)
+			opts := eventbus.ListOptions{
+				Reader:    reader,
+				Limit:     limit,
+				ScopeType: strings.TrimSpace(p.ScopeType),
+				ScopeID:   strings.TrimSpace(p.ScopeID),
+				After:     strings.TrimSpace(p.After),
+				AllScopes: strings.TrimSpace(p.ScopeType) == "",
+			}
+			summaries, err := bus.List(r.Context(), stream, opts)
+			if err != nil {
+				return toolresult.ErrorWithLabel("read_stream", "read_stream failed", err)
+			}
+			ids := make([]string, 0, len(summaries))
+			for _, summary := range summaries {
+				if p.Unread && summary.Read {
+					continue
+				}
+				ids = append(ids, summary.ID)
+			}
+			events, err := bus.Read(r.Context(), stream, ids, reader)
+			if err != nil {
+				return toolresult.ErrorWithLabel("read_stream", "read_stream failed", err)
+			}
+			byID := make(map[string]eventbus.Event, len(events))
+			for _, evt := range events {
+				byID[evt.ID] = evt
+			}
+			out := make([]map[string]any, 0, len(ids))
+			for _, id := range ids {
+				evt, ok := byID[id]
+				if !ok {
+					continue
+				}
+				item := map[string]any{
+					"id":         evt.ID,
+					"scope_type": evt.ScopeType,
+					"scope_id":   evt.ScopeID,
+					"body":       evt.Body,
+					"created_at": evt.CreatedAt,
+					"read":       evt.Read,
+				}
+				if evt.Subject != "" {
+					item["subject"] = evt.Subject
+				}
+				if len(evt.Metadata) > 0 {
+					item["metadata"] = evt.Metadata
+				}
+				if len(evt.Payload) > 0 {
+					item["payload"] = evt.Payload
+				}
+				out = append(out, item)
+			}
+			return toolresult.Success("read_stream", map[string]any{
+				"stream": stream,
+				"reader": reader,
+				"events": out,
+			})
+		},
+	)
+}
+
+// AckStreamTool marks events read for a reader.
+func AckStreamTool(bus *eventbus.Bus) llmtools.Tool {
+	return llmtools.Func(
+		"AckStream",
+		"Mark events in an event stream as read",
+		"ack_stream",
+		func(r llmtools.Runner, p AckStreamParams) llmtools.Result {
+			if bus == nil {
+				return toolresult.Errorf("ack_stream", "event bus unavailable")
+			}
+			stream := strings.TrimSpace(p.Stream)
+			if stream == "" {
+				return toolresult.Errorf("ack_stream", "stream is required")
+			}
+			if len(p.IDs) == 0 {
+				return toolresult.Errorf("ack_stream", "ids is required")
+			}
+			reader := streamReader(r, p.Reader)
+			if reader == "" {
+				return toolresult.Errorf("ack_stream", "reader is required")
+			}
+			if err := bus.Ack(r.Context(), stream, p.IDs, reader); err != nil {
+				return toolresult.ErrorWithLabel("ack_stream", "ack_stream failed", err)
+			}
+			return toolresult.Success("ack_stream", map[string]any{"ok": true, "reader": reader, "acked": len(p.IDs)})
+		},
+	)
+}
+
+func streamReader(r llmtools.Runner, reader string) string {
+	if reader = strings.TrimSpace(reader); reader != "" {
+		return reader
+	}
+	return strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+}
@@ -12,18 +12,24 @@ import (
 	"github.com/flitsinc/go-agents/internal/schema"
 	"github.com/flitsinc/go-agents/internal/tasks"
 	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/llms"
 	llmtools "github.com/flitsinc/go-llms/tools"
 )
 
 const agentOutputPollInterval = 100 * time.Millisecond
 
 type AwaitTaskParams struct {
-	TaskID      string `json:"task_id" description:"Task id to wait for"`
-	WaitSeconds *int   `json:"wait_seconds" description:"Seconds to wait before returning (must be > 0)"`
+	TaskID          string   `json:"task_id" description:"Task id to wait for"`
+	WaitSeconds     *int     `json:"wait_seconds" description:"Seconds to wait before returning (must be > 0)"`
+	TaskIDs         []string `json:"task_ids,omitempty" description:"Additional task ids to wait for alongside task_id"`
+	Mode            string   `json:"mode,omitempty" description:"With multiple tasks: any (default) returns when one finishes, all waits for every task"`
+	UntilUpdate     []string `json:"until_update,omitempty" description:"Return on the first new update of these kinds (e.g. stdout) instead of waiting for completion"`
+	MinWakePriority string   `json:"min_wake_priority,omitempty" description:"Ignore wakes below this priority: wake (default) or interrupt"`
+	IncludeUpdates  []string `json:"include_updates,omitempty" description:"Also return the text of updates of these kinds (e.g. stdout, stderr) recorded while waiting, keeping the most recent output if it is long"`
 }
 
 type SendTaskParams struct {
-	TaskID string `json:"task_id" description:"Task id to send input to"`
+	TaskID string `json:"task_id" description:"Task id to send input to, or agent@peer for an agent on a federated agentd"`
 	Body   string `json:"body" description:"Content to send to the task"`
 }
 
@@ -32,6 +38,12 @@ type KillTaskParams struct {
 	Reason string `json:"reason,omitempty" description:"Optional reason for kill"`
 }
 
+type RetryTaskParams struct {
+	TaskID string `json:"task_id" description:"Failed or cancelled task id to retry"`
+	Code   string `json:"code,omitempty" description:"Replacement code for exec tasks; the original code is reused when omitted"`
+	Reason string `json:"reason,omitempty" description:"Optional note on what the retry changes"`
+}
+
 func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 	return llmtools.Func(
 		"AwaitTask",
@@ -54,15 +66,33 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 			timeout := time.Duration(waitSeconds) * time.Second
 			startedAt := time.Now()
 
-			task, err := manager.Get(r.Context(), p.TaskID)
+			ctx := r.Context()
+			if raw := strings.TrimSpace(p.MinWakePriority); raw != "" {
+				switch priority := schema.Priority(strings.ToLower(raw)); priority {
+				case schema.PriorityWake:
+				case schema.PriorityInterrupt:
+					ctx = tasks.WithMinWakePriority(ctx, priority)
+				default:
+					return toolresult.Errorf("await_task", "min_wake_priority must be wake or interrupt")
+				}
+			}
+			if len(p.TaskIDs) > 0 || strings.TrimSpace(p.Mode) != "" || len(p.UntilUpdate) > 0 {
+				return awaitTasks(ctx, r, manager, p, timeout)
+			}
+
+			task, err := manager.Get(ctx, p.TaskID)
 			if err != nil {
 				return toolresult.ErrorWithLabel("await_task", "await_task failed", err)
 			}
+			progressBaseline := ""
+			if latest, found, latestErr := manager.LatestUpdate(ctx, p.TaskID, ""); latestErr == nil && found {
+				progressBaseline = latest.ID
+			}
 
 			isAgentTask := task.Type == "agent" || task.Type == "llm"
 			assistantOutputBaseline := ""
 			if isAgentTask {
-				latest, found, latestErr := manager.LatestUpdate(r.Context(), p.TaskID, "assistant_output")
+				latest, found, latestErr := manager.LatestUpdate(ctx, p.TaskID, "assistant_output")
 				if latestErr != nil {
 					return toolresult.ErrorWithLabel("await_task", "await_task failed", latestErr)
 				}
@@ -72,7 +102,7 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 			}
 
 			r.Report("waiting")
-			awaited, awaitErr := manager.Await(r.Context(), p.TaskID, timeout)
+			awaited, awaitErr := manager.Await(ctx, p.TaskID, timeout)
 			if awaited.ID == "" {
 				awaited = task
 			}
@@ -80,7 +110,7 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 			if isAgentTask && awaited.Status == tasks.StatusCompleted {
 				remaining := timeout - time.Since(startedAt)
 				update, found, updateErr := waitForUpdateSince(
-					r.Context(),
+					ctx,
 					manager,
 					p.TaskID,
 					assistantOutputBaseline,
@@ -108,6 +138,9 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 				"task_id": p.TaskID,
 				"status":  awaited.Status,
 			}
+			if awaited.Type == "agent" {
+				resp["generation"] = tasks.Generation(awaited)
+			}
 			includeCompletedResult := awaited.Status == tasks.StatusCompleted
 			if isAgentTask && tasks.IsAwaitTimeout(awaitErr) {
 				includeCompletedResult = false
@@ -115,7 +148,7 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 			if includeCompletedResult {
 				resp["result"] = awaited.Result
 			}
-			if awaited.Status == tasks.StatusFailed || awaited.Status == tasks.StatusCancelled {
+			if awaited.Status == tasks.StatusFailed || awaited.Status == tasks.StatusCancelled || awaited.Status == tasks.StatusLimitExceeded {
 				resp["error"] = awaited.Error
 				if awaited.Result != nil {
 					resp["result"] = awaited.Result
@@ -126,36 +159,165 @@ func AwaitTaskTool(manager *tasks.Manager) llmtools.Tool {
 					resp["await_error"] = tasks.ErrAwaitTimeout.Error()
 					resp["pending"] = true
 					resp["background"] = true
-				} else if wakeErr, ok := tasks.AsWakeError(awaitErr); ok {
-					priority := strings.TrimSpace(wakeErr.Priority)
-					if priority == "" {
-						priority = "wake"
-					}
-					wakeEventID := strings.TrimSpace(wakeErr.Event.ID)
-					if wakeEventID != "" {
-						resp["wake_event_id"] = wakeEventID
+					progress := manager.AwaitProgress(context.Background(), []string{p.TaskID}, map[string]string{p.TaskID: progressBaseline})
+					if updates := progress[p.TaskID]; len(updates) > 0 {
+						resp["progress"] = progressSummary(updates)
 					}
-					if wakeStream := strings.TrimSpace(wakeErr.Event.Stream); wakeStream != "" {
-						resp["wake_stream"] = wakeStream
-					}
-					wakeMsg := fmt.Sprintf("awoken by %s event", priority)
-					if wakeEventID != "" {
-						wakeMsg = fmt.Sprintf("%s %s", wakeMsg, wakeEventID)
-					}
-					resp["await_error"] = wakeMsg
+				} else if wakeErr, ok := tasks.AsWakeError(awaitErr); ok {
+					setWakeResponse(resp, wakeErr)
 					if awaited.Status == tasks.StatusQueued || awaited.Status == tasks.StatusRunning {
 						resp["pending"] = true
 					}
-					resp["background"] = true
 				} else {
 					resp["await_error"] = awaitErr.Error()
 				}
 			}
+			if updates := streamedUpdates(ctx, manager, p.TaskID, progressBaseline, normalizeUpdateKinds(p.IncludeUpdates)); updates != nil {
+				resp["updates"] = updates
+			}
 			return toolresult.Success("await_task", resp)
 		},
 	)
 }
 
+func awaitTasks(ctx context.Context, r llmtools.Runner, manager *tasks.Manager, p AwaitTaskParams, timeout time.Duration) llmtools.Result {
+	ids := append([]string{}, p.TaskID)
+	ids = append(ids, p.TaskIDs...)
+	opts := tasks.AwaitOptions{UpdateKinds: p.UntilUpdate}
+	switch mode := strings.ToLower(strings.TrimSpace(p.Mode)); mode {
+	case "", "any":
+	case "all":
+		opts.All = true
+	default:
+		return toolresult.Errorf("await_task", "mode must be any or all")
+	}
+	for _, id := range ids {
+		if _, err := manager.Get(ctx, id); err != nil {
+			return toolresult.ErrorWithLabel("await_task", "await_task failed", fmt.Errorf("%s: %w", id, err))
+		}
+	}
+
+	includeKinds := normalizeUpdateKinds(p.IncludeUpdates)
+	var baselines map[string]string
+	if len(includeKinds) > 0 {
+		baselines = updateBaselines(ctx, manager, ids)
+	}
+
+	r.Report("waiting")
+	result, awaitErr := manager.AwaitTasks(ctx, ids, timeout, opts)
+	if awaitErr != nil && !tasks.IsAwaitTimeout(awaitErr) {
+		if _, ok := tasks.AsWakeError(awaitErr); !ok {
+			return toolresult.ErrorWithLabel("await_task", "await_task failed", awaitErr)
+		}
+	}
+
+	owner := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+	taskList := make([]map[string]any, 0, len(result.Tasks))
+	for _, task := range result.Tasks {
+		item := map[string]any{
+			"task_id": task.ID,
+			"status":  task.Status,
+		}
+		if task.Type == "agent" {
+			item["generation"] = tasks.Generation(task)
+		}
+		if task.Status == tasks.StatusCompleted || task.Result != nil {
+			item["result"] = task.Result
+		}
+		if task.Status == tasks.StatusFailed || task.Status == tasks.StatusCancelled || task.Status == tasks.StatusLimitExceeded {
+			item["error"] = task.Error
+		}
+		if tasks.IsTerminalStatus(task.Status) && owner != "" {
+			manager.AckTaskOutput(r.Context(), task.ID, owner)
+		}
+		taskList = append(taskList, item)
+	}
+	resp := map[string]any{
+		"task_id":   p.TaskID,
+		"tasks":     taskList,
+		"completed": result.CompletedIDs,
+	}
+	if len(result.PendingIDs) > 0 {
+		resp["pending_ids"] = result.PendingIDs
+		resp["pending"] = true
+	}
+	if result.Update != nil {
+		resp["update"] = map[string]any{
+			"task_id": result.Update.TaskID,
+			"kind":    result.Update.Kind,
+			"payload": result.Update.Payload,
+		}
+	}
+	if tasks.IsAwaitTimeout(awaitErr) {
+		resp["await_error"] = tasks.ErrAwaitTimeout.Error()
+		resp["background"] = true
+		if len(result.Progress) > 0 {
+			progress := make(map[string]any, len(result.Progress))
+			for id, updates := range result.Progress {
+				progress[id] = progressSummary(updates)
+			}
+			resp["progress"] = progress
+		}
+	} else if wakeErr, ok := tasks.AsWakeError(awaitErr); ok {
+		setWakeResponse(resp, wakeErr)
+	}
+	if len(includeKinds) > 0 {
+		streamed := map[string]any{}
+		for _, id := range ids {
+			if updates := streamedUpdates(ctx, manager, id, baselines[id], includeKinds); updates != nil {
+				streamed[id] = updates
+			}
+		}
+		if len(streamed) > 0 {
+			resp["updates"] = streamed
+		}
+	}
+	return toolresult.Success("await_task", resp)
+}
+
+func setWakeResponse(resp map[string]any, wakeErr *tasks.WakeError) {
+	priority := strings.TrimSpace(wakeErr.Priority)
+	if priority == "" {
+		priority = "wake"
+	}
+	wakeEventID := strings.TrimSpace(wakeErr.Event.ID)
+	if wakeEventID != "" {
+		resp["wake_event_id"] = wakeEventID
+	}
+	if wakeStream := strings.TrimSpace(wakeErr.Event.Stream); wakeStream != "" {
+		resp["wake_stream"] = wakeStream
+	}
+	wakeMsg := fmt.Sprintf("awoken by %s event", priority)
+	if wakeEventID != "" {
+		wakeMsg = fmt.Sprintf("%s %s", wakeMsg, wakeEventID)
+	}
+	resp["await_error"] = wakeMsg
+	resp["background"] = true
+	if schema.GetMetaString(wakeErr.Event.Metadata, "task_kind") == tasks.GenerationChangedKind {
+		// The awaited agent compacted its context; whatever it was asked
+		// before may need to be sent again.
+		resp["generation_changed"] = map[string]any{
+			"task_id":             schema.GetMetaString(wakeErr.Event.Metadata, "task_id"),
+			"previous_generation": wakeErr.Event.Payload["previous_generation"],
+			"generation":          wakeErr.Event.Payload["generation"],
+		}
+	}
+}
+
+// progressSummary turns updates into a compact list for timeout responses so
+// the caller can see what happened while it waited.
+func progressSummary(updates []tasks.Update) []map[string]any {
+	out := make([]map[string]any, 0, len(updates))
+	for _, upd := range updates {
+		item := map[string]any{"kind": upd.Kind}
+		if len(upd.Payload) > 0 {
+			item["payload"] = upd.Payload
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
 func waitForUpdateSince(
 	ctx context.Context,
 	manager *tasks.Manager,
@@ -235,7 +397,16 @@ func cloneAnyMap(in map[string]any) map[string]any {
 	return out
 }
 
-func SendTaskTool(manager *tasks.Manager, bus *eventbus.Bus) llmtools.Tool {
+// RemoteSender queues messages for agents on federated peers, addressed as
+// "agent@peer", and returns the outbox ID.
+type RemoteSender interface {
+	SendRemote(ctx context.Context, source, address, body string) (string, error)
+}
+
+// SendTaskTool sends input to tasks. With remote set, task IDs of the form
+// agent@peer are forwarded to that peer; delivery receipts arrive later as
+// signals.
+func SendTaskTool(manager *tasks.Manager, bus *eventbus.Bus, remote RemoteSender) llmtools.Tool {
 	return llmtools.Func(
 		"SendTask",
 		"Send input to a running task",
@@ -251,6 +422,20 @@ func SendTaskTool(manager *tasks.Manager, bus *eventbus.Bus) llmtools.Tool {
 			if body == "" {
 				return toolresult.Errorf("send_task", "body is required")
 			}
+			if strings.Contains(p.TaskID, "@") {
+				if remote == nil {
+					return toolresult.Errorf("send_task", "federation is not configured; cannot reach %s", p.TaskID)
+				}
+				source := agentcontext.TaskIDFromContext(r.Context())
+				if source == "" {
+					source = "system"
+				}
+				id, err := remote.SendRemote(r.Context(), source, p.TaskID, body)
+				if err != nil {
+					return toolresult.ErrorWithLabel("send_task", "send_task failed", err)
+				}
+				return toolresult.Success("send_task", map[string]any{"ok": true, "message_id": id, "status": "queued"})
+			}
 			task, err := manager.Get(r.Context(), p.TaskID)
 			if err != nil {
 				return toolresult.ErrorWithLabel("send_task", "send_task failed", err)
@@ -268,6 +453,10 @@ func SendTaskTool(manager *tasks.Manager, bus *eventbus.Bus) llmtools.Tool {
 				if source == "" {
 					source = "system"
 				}
+				seq, err := bus.NextSequence(r.Context(), eventbus.MessageSequenceKey(source, target))
+				if err != nil {
+					return toolresult.ErrorWithLabel("send_task", "send_task failed", err)
+				}
 				evt, err := bus.Push(r.Context(), eventbus.EventInput{
 					Stream:    schema.StreamTaskInput,
 					ScopeType: "task",
@@ -279,6 +468,7 @@ func SendTaskTool(manager *tasks.Manager, bus *eventbus.Bus) llmtools.Tool {
 						"source":   source,
 						"target":   target,
 						"via_task": p.TaskID,
+						"seq":      seq,
 					},
 				})
 				if err != nil {
@@ -315,3 +505,46 @@ func KillTaskTool(manager *tasks.Manager) llmtools.Tool {
 		},
 	)
 }
+
+func RetryTaskTool(manager *tasks.Manager) llmtools.Tool {
+	return llmtools.Func(
+		"RetryTask",
+		"Retry a failed or cancelled task, optionally with changed payload fields",
+		"retry_task",
+		func(r llmtools.Runner, p RetryTaskParams) llmtools.Result {
+			if manager == nil {
+				return toolresult.Errorf("retry_task", "task manager unavailable")
+			}
+			taskID := strings.TrimSpace(p.TaskID)
+			if taskID == "" {
+				return toolresult.Errorf("retry_task", "task_id is required")
+			}
+			metadata := map[string]any{}
+			if tc, ok := llms.GetToolCall(r.Context()); ok {
+				metadata["tool_call_id"] = tc.ID
+				metadata["tool_name"] = tc.Name
+			}
+			if reason := strings.TrimSpace(p.Reason); reason != "" {
+				metadata["retry_reason"] = reason
+			}
+			var overrides map[string]any
+			if code := strings.TrimSpace(p.Code); code != "" {
+				overrides = map[string]any{"code": code}
+			}
+			task, err := manager.Retry(r.Context(), taskID, tasks.RetryOptions{
+				Payload:  overrides,
+				Metadata: metadata,
+				ParentID: tasks.ParentTaskIDFromContext(r.Context()),
+			})
+			if err != nil {
+				return toolresult.ErrorWithLabel("retry_task", "retry_task failed", err)
+			}
+			return toolresult.Success("retry_task", map[string]any{
+				"task_id":  task.ID,
+				"status":   task.Status,
+				"retry_of": taskID,
+				"attempt":  task.Metadata["retry_attempt"],
+			})
+		},
+	)
+}
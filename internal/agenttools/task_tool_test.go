@@ -3,6 +3,7 @@ package agenttools
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -177,3 +178,272 @@ func TestAwaitTaskToolAgentTimesOutWithoutFreshOutput(t *testing.T) {
 		t.Fatalf("did not expect stale result in timeout response, got %v", payload["result"])
 	}
 }
+
+func TestAwaitTaskToolUntilUpdateReturnsOnFirstMatchingKind(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	tool := AwaitTaskTool(mgr)
+
+	task, err := mgr.Spawn(context.Background(), tasks.Spec{
+		Type:  "exec",
+		Owner: "agent-a",
+	})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	if err := mgr.RecordUpdate(context.Background(), task.ID, "stdout", map[string]any{"text": "stale"}); err != nil {
+		t.Fatalf("record stale stdout: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = mgr.RecordUpdate(context.Background(), task.ID, "stderr", map[string]any{"text": "noise"})
+		_ = mgr.RecordUpdate(context.Background(), task.ID, "stdout", map[string]any{"text": "hello"})
+	}()
+
+	waitSec := 2
+	raw, _ := json.Marshal(AwaitTaskParams{
+		TaskID:      task.ID,
+		WaitSeconds: &waitSec,
+		UntilUpdate: []string{"stdout"},
+	})
+	payload := decodeToolPayload(t, tool.Run(llmtools.NopRunner, raw))
+	update, ok := payload["update"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected update in payload, got %v", payload)
+	}
+	if update["kind"] != "stdout" {
+		t.Fatalf("expected stdout update, got %v", update["kind"])
+	}
+	if got := update["payload"].(map[string]any)["text"]; got != "hello" {
+		t.Fatalf("expected fresh stdout, got %v", got)
+	}
+	if payload["pending"] != true {
+		t.Fatalf("expected pending=true, got %v", payload["pending"])
+	}
+}
+
+func TestAwaitTaskToolIncludeUpdatesReturnsOutputProducedWhileWaiting(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	tool := AwaitTaskTool(mgr)
+
+	ctx := context.Background()
+	task, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a"})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, task.ID, "stdout", map[string]any{"text": "stale\n"}); err != nil {
+		t.Fatalf("record stale stdout: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = mgr.RecordUpdate(ctx, task.ID, "stdout", map[string]any{"text": strings.Repeat("x", awaitUpdatesChars)})
+		_ = mgr.RecordUpdate(ctx, task.ID, "stderr", map[string]any{"text": "warning\n"})
+		_ = mgr.RecordUpdate(ctx, task.ID, "stdout", map[string]any{"text": "done\n"})
+		_ = mgr.Complete(ctx, task.ID, map[string]any{"exit_code": 0})
+	}()
+
+	waitSec := 2
+	raw, _ := json.Marshal(AwaitTaskParams{
+		TaskID:         task.ID,
+		WaitSeconds:    &waitSec,
+		IncludeUpdates: []string{"stdout", "stderr", "stdout"},
+	})
+	payload := decodeToolPayload(t, tool.Run(llmtools.NopRunner, raw))
+	if payload["status"] != string(tasks.StatusCompleted) {
+		t.Fatalf("expected completed task, got %v", payload)
+	}
+	updates, ok := payload["updates"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected updates in payload, got %v", payload)
+	}
+	stdout, _ := updates["stdout"].(string)
+	if !strings.HasSuffix(stdout, "xxdone\n") || strings.Contains(stdout, "stale") || len(stdout) != awaitUpdatesChars/2 {
+		t.Fatalf("expected the bounded tail of fresh stdout, got %d chars ending %q", len(stdout), stdout[max(0, len(stdout)-20):])
+	}
+	if updates["stderr"] != "warning\n" {
+		t.Fatalf("expected stderr, got %v", updates["stderr"])
+	}
+	omitted, _ := updates["omitted_chars"].(map[string]any)
+	if omitted["stdout"] != float64(awaitUpdatesChars+len("done\n")-awaitUpdatesChars/2) {
+		t.Fatalf("expected omitted stdout length, got %v", updates["omitted_chars"])
+	}
+}
+
+func TestAwaitTaskToolAllModeTimesOutWithProgress(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	tool := AwaitTaskTool(mgr)
+
+	ctx := context.Background()
+	done, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a"})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	slow, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a"})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	if err := mgr.Complete(ctx, done.ID, map[string]any{"exit_code": 0}); err != nil {
+		t.Fatalf("complete task: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = mgr.RecordUpdate(ctx, slow.ID, "stdout", map[string]any{"text": "halfway"})
+	}()
+
+	waitSec := 1
+	raw, _ := json.Marshal(AwaitTaskParams{
+		TaskID:      done.ID,
+		TaskIDs:     []string{slow.ID},
+		Mode:        "all",
+		WaitSeconds: &waitSec,
+	})
+	payload := decodeToolPayload(t, tool.Run(llmtools.NopRunner, raw))
+	if payload["await_error"] != tasks.ErrAwaitTimeout.Error() {
+		t.Fatalf("expected await timeout, got %v", payload)
+	}
+	pendingIDs, _ := payload["pending_ids"].([]any)
+	if len(pendingIDs) != 1 || pendingIDs[0] != slow.ID {
+		t.Fatalf("expected %s pending, got %v", slow.ID, payload["pending_ids"])
+	}
+	progress, ok := payload["progress"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected progress on timeout, got %v", payload)
+	}
+	updates, _ := progress[slow.ID].([]any)
+	if len(updates) == 0 {
+		t.Fatalf("expected progress for %s, got %v", slow.ID, progress)
+	}
+	if kind := updates[len(updates)-1].(map[string]any)["kind"]; kind != "stdout" {
+		t.Fatalf("expected last progress kind stdout, got %v", kind)
+	}
+}
+
+func TestAwaitTaskToolMinWakePriorityIgnoresLowerWakes(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	tool := AwaitTaskTool(mgr)
+
+	task, err := mgr.Spawn(context.Background(), tasks.Spec{
+		Type:  "exec",
+		Owner: "agent-a",
+	})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	if _, err := bus.Push(context.Background(), eventbus.EventInput{
+		Stream:    "task_input",
+		ScopeType: "task",
+		ScopeID:   "agent-a",
+		Body:      "not urgent",
+		Metadata: map[string]any{
+			"priority": "wake",
+			"kind":     "message",
+		},
+	}); err != nil {
+		t.Fatalf("push wake event: %v", err)
+	}
+
+	waitSec := 1
+	raw, _ := json.Marshal(AwaitTaskParams{
+		TaskID:          task.ID,
+		WaitSeconds:     &waitSec,
+		MinWakePriority: "interrupt",
+	})
+	payload := decodeToolPayload(t, tool.Run(llmtools.NopRunner, raw))
+	if _, ok := payload["wake_event_id"]; ok {
+		t.Fatalf("expected wake below interrupt to be ignored, got %v", payload)
+	}
+	if payload["await_error"] != tasks.ErrAwaitTimeout.Error() {
+		t.Fatalf("expected await timeout, got %v", payload["await_error"])
+	}
+}
+
+func TestRetryTaskToolOverridesPayload(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	mgr := tasks.NewManager(db, eventbus.NewBus(db))
+	tool := RetryTaskTool(mgr)
+	ctx := context.Background()
+
+	task, err := mgr.Spawn(ctx, tasks.Spec{
+		Type:    "exec",
+		Owner:   "agent-a",
+		Payload: map[string]any{"code": "await slow()"},
+	})
+	if err != nil {
+		t.Fatalf("spawn task: %v", err)
+	}
+	if err := mgr.Fail(ctx, task.ID, "timed out"); err != nil {
+		t.Fatalf("fail task: %v", err)
+	}
+
+	raw, _ := json.Marshal(RetryTaskParams{
+		TaskID: task.ID,
+		Code:   "await slow({ timeout: 30_000 })",
+		Reason: "longer timeout",
+	})
+	payload := decodeToolPayload(t, tool.Run(llmtools.NopRunner, raw))
+	if payload["retry_of"] != task.ID {
+		t.Fatalf("expected retry_of %q, got %v", task.ID, payload["retry_of"])
+	}
+	if payload["attempt"] != float64(1) {
+		t.Fatalf("expected attempt 1, got %v", payload["attempt"])
+	}
+	retryID, _ := payload["task_id"].(string)
+	retry, err := mgr.Get(ctx, retryID)
+	if err != nil {
+		t.Fatalf("get retry: %v", err)
+	}
+	if retry.Payload["code"] != "await slow({ timeout: 30_000 })" {
+		t.Fatalf("unexpected retry payload: %v", retry.Payload)
+	}
+	if retry.Metadata["retry_reason"] != "longer timeout" {
+		t.Fatalf("expected retry_reason, got %v", retry.Metadata)
+	}
+}
+
+type fakeRemoteSender struct {
+	address, body string
+}
+
+func (f *fakeRemoteSender) SendRemote(_ context.Context, _, address, body string) (string, error) {
+	f.address, f.body = address, body
+	return "msg-1", nil
+}
+
+func TestSendTaskToolForwardsPeerAddresses(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+
+	raw, _ := json.Marshal(SendTaskParams{TaskID: "reviewer@beta", Body: "Please review PR 12"})
+	if result := SendTaskTool(mgr, bus, nil).Run(llmtools.NopRunner, raw); result.Error() == nil {
+		t.Fatalf("expected an error without federation")
+	}
+
+	remote := &fakeRemoteSender{}
+	payload := decodeToolPayload(t, SendTaskTool(mgr, bus, remote).Run(llmtools.NopRunner, raw))
+	if payload["message_id"] != "msg-1" || payload["status"] != "queued" {
+		t.Fatalf("unexpected payload %#v", payload)
+	}
+	if remote.address != "reviewer@beta" || remote.body != "Please review PR 12" {
+		t.Fatalf("unexpected remote send %+v", remote)
+	}
+}
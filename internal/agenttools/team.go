@@ -0,0 +1,86 @@
+package agenttools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/teams"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// TeamRunner creates teams and hands out their assignments. engine.Runtime
+// implements it.
+type TeamRunner interface {
+	CreateTeam(ctx context.Context, supervisorID string, workers []teams.WorkerSpec, maxConcurrency int) (teams.Team, error)
+	TeamOf(ctx context.Context, agentID string) (string, bool)
+	AssignToTeam(ctx context.Context, id, from, to, body string) (teams.Assignment, error)
+}
+
+type CreateTeamParams struct {
+	Workers        []teams.WorkerSpec `json:"workers" description:"Workers to spawn, each with an optional id, system prompt and model (fast, balanced or smart); an id naming an existing agent adopts it"`
+	MaxConcurrency int                `json:"max_concurrency,omitempty" description:"Most workers on an assignment at once; defaults to all of them"`
+}
+
+type AssignToTeamParams struct {
+	Body string `json:"body" description:"The assignment for the worker"`
+	To   string `json:"to,omitempty" description:"Worker id; omit to give it to the first free worker"`
+}
+
+func CreateTeamTool(runner TeamRunner) llmtools.Tool {
+	return llmtools.Func(
+		"CreateTeam",
+		"Create a team of worker agents that you supervise",
+		"create_team",
+		func(r llmtools.Runner, p CreateTeamParams) llmtools.Result {
+			if runner == nil {
+				return toolresult.Errorf("create_team", "teams unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("create_team", "calling agent unknown")
+			}
+			team, err := runner.CreateTeam(r.Context(), agentID, p.Workers, p.MaxConcurrency)
+			if err != nil {
+				return toolresult.ErrorWithLabel("create_team", "create_team failed", err)
+			}
+			return toolresult.Success("create_team", map[string]any{"team": team})
+		},
+	)
+}
+
+func AssignToTeamTool(runner TeamRunner) llmtools.Tool {
+	return llmtools.Func(
+		"AssignToTeam",
+		"Give an assignment to a worker of your team; the worker's reply comes back to you",
+		"assign_to_team",
+		func(r llmtools.Runner, p AssignToTeamParams) llmtools.Result {
+			if runner == nil {
+				return toolresult.Errorf("assign_to_team", "teams unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("assign_to_team", "calling agent unknown")
+			}
+			teamID, ok := runner.TeamOf(r.Context(), agentID)
+			if !ok {
+				return toolresult.Errorf("assign_to_team", "you are not on a team; create one with create_team")
+			}
+			assignment, err := runner.AssignToTeam(r.Context(), teamID, agentID, p.To, p.Body)
+			if err != nil {
+				return toolresult.ErrorWithLabel("assign_to_team", "assign_to_team failed", err)
+			}
+			status := "queued"
+			if !assignment.StartedAt.IsZero() {
+				status = "started"
+			}
+			return toolresult.Success("assign_to_team", map[string]any{
+				"assignment_id": assignment.ID,
+				"team_id":       teamID,
+				"to":            assignment.To,
+				"status":        status,
+			})
+		},
+	)
+}
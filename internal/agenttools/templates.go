@@ -0,0 +1,76 @@
+package agenttools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/templates"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type SpawnFromTemplateParams struct {
+	Template string         `json:"template,omitempty" description:"Name of the task template to spawn; omit to list the available templates"`
+	Params   map[string]any `json:"params,omitempty" description:"Values for the template's parameters, keyed by name"`
+}
+
+func SpawnFromTemplateTool(store *templates.Store, manager *tasks.Manager) llmtools.Tool {
+	return llmtools.Func(
+		"SpawnFromTemplate",
+		"Spawn a task from a named task template, or list the templates and their parameters",
+		"spawn_from_template",
+		func(r llmtools.Runner, p SpawnFromTemplateParams) llmtools.Result {
+			if store == nil || manager == nil {
+				return toolresult.Errorf("spawn_from_template", "task templates unavailable")
+			}
+			name := strings.TrimSpace(p.Template)
+			if name == "" {
+				list, err := store.List(r.Context())
+				if err != nil {
+					return toolresult.ErrorWithLabel("spawn_from_template", "spawn_from_template failed", err)
+				}
+				out := make([]map[string]any, 0, len(list))
+				for _, t := range list {
+					out = append(out, map[string]any{"name": t.Name, "description": t.Description, "type": t.Type, "params": t.Params})
+				}
+				return toolresult.Success("spawn_from_template", map[string]any{"templates": out})
+			}
+			tmpl, err := store.Get(r.Context(), name)
+			if errors.Is(err, templates.ErrTemplateNotFound) {
+				return toolresult.Errorf("spawn_from_template", "template %q not found; call spawn_from_template without a template to list them", name)
+			}
+			if err != nil {
+				return toolresult.ErrorWithLabel("spawn_from_template", "spawn_from_template failed", err)
+			}
+			spec, err := templates.Instantiate(tmpl, p.Params)
+			if err != nil {
+				return toolresult.Error("spawn_from_template", err)
+			}
+			if tc, ok := llms.GetToolCall(r.Context()); ok {
+				spec.Metadata["tool_call_id"] = tc.ID
+				spec.Metadata["tool_name"] = tc.Name
+			}
+			owner := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if owner == "" {
+				owner = "agent"
+			}
+			spec.Owner = owner
+			spec.ParentID = tasks.ParentTaskIDFromContext(r.Context())
+			spec.Metadata["notify_target"] = owner
+			task, err := manager.Spawn(r.Context(), spec)
+			if err != nil {
+				return toolresult.ErrorWithLabel("spawn_from_template", "spawn_from_template failed", fmt.Errorf("spawn %s task: %w", spec.Type, err))
+			}
+			return toolresult.Success("spawn_from_template", map[string]any{
+				"task_id":  task.ID,
+				"type":     task.Type,
+				"status":   task.Status,
+				"template": tmpl.Name,
+			})
+		},
+	)
+}
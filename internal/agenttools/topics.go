@@ -0,0 +1,89 @@
+package agenttools
+
+import (
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-agents/internal/topics"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type SubscribeTopicParams struct {
+	Topic       string `json:"topic" description:"Topic name, e.g. deployments"`
+	Unsubscribe bool   `json:"unsubscribe,omitempty" description:"Stop receiving the topic instead of subscribing"`
+}
+
+type PublishTopicParams struct {
+	Topic    string `json:"topic" description:"Topic name to publish to"`
+	Body     string `json:"body" description:"Event content delivered to every subscriber"`
+	Subject  string `json:"subject,omitempty" description:"Optional short subject line"`
+	Priority string `json:"priority,omitempty" description:"low, normal (default) or wake; wake starts a turn for each subscriber"`
+}
+
+func SubscribeTopicTool(store *topics.Store) llmtools.Tool {
+	return llmtools.Func(
+		"SubscribeTopic",
+		"Subscribe to (or unsubscribe from) a shared topic; its events arrive as context updates",
+		"subscribe_topic",
+		func(r llmtools.Runner, p SubscribeTopicParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("subscribe_topic", "topics unavailable")
+			}
+			agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(r.Context()))
+			if agentID == "" {
+				return toolresult.Errorf("subscribe_topic", "calling agent unknown")
+			}
+			if p.Unsubscribe {
+				removed, err := store.Unsubscribe(r.Context(), agentID, p.Topic)
+				if err != nil {
+					return toolresult.ErrorWithLabel("subscribe_topic", "subscribe_topic failed", err)
+				}
+				return toolresult.Success("subscribe_topic", map[string]any{
+					"topic":      strings.ToLower(strings.TrimSpace(p.Topic)),
+					"subscribed": false,
+					"removed":    removed,
+				})
+			}
+			sub, err := store.Subscribe(r.Context(), agentID, p.Topic)
+			if err != nil {
+				return toolresult.ErrorWithLabel("subscribe_topic", "subscribe_topic failed", err)
+			}
+			return toolresult.Success("subscribe_topic", map[string]any{
+				"topic":      sub.Topic,
+				"subscribed": true,
+				"since":      sub.CreatedAt,
+			})
+		},
+	)
+}
+
+func PublishTopicTool(store *topics.Store) llmtools.Tool {
+	return llmtools.Func(
+		"PublishTopic",
+		"Publish an event to every agent subscribed to a shared topic",
+		"publish_topic",
+		func(r llmtools.Runner, p PublishTopicParams) llmtools.Result {
+			if store == nil {
+				return toolresult.Errorf("publish_topic", "topics unavailable")
+			}
+			evt, err := store.Publish(r.Context(), topics.PublishInput{
+				Topic:    p.Topic,
+				Source:   agentcontext.TaskIDFromContext(r.Context()),
+				Subject:  p.Subject,
+				Body:     p.Body,
+				Priority: p.Priority,
+			})
+			if err != nil {
+				return toolresult.ErrorWithLabel("publish_topic", "publish_topic failed", err)
+			}
+			subscribers, _ := store.Subscribers(r.Context(), evt.ScopeID)
+			return toolresult.Success("publish_topic", map[string]any{
+				"ok":          true,
+				"event_id":    evt.ID,
+				"topic":       evt.ScopeID,
+				"subscribers": len(subscribers),
+			})
+		},
+	)
+}
@@ -0,0 +1,50 @@
+package agenttools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedTool struct {
+	llmtools.Tool
+}
+
+// tracedRunner is a runner whose context carries the tool call's span.
+type tracedRunner struct {
+	llmtools.Runner
+	ctx context.Context
+}
+
+func (r tracedRunner) Context() context.Context { return r.ctx }
+
+// Traced wraps tools so every call runs in a span of its own. Tasks the
+// call spawns are part of that span's trace.
+func Traced(tools ...llmtools.Tool) []llmtools.Tool {
+	out := make([]llmtools.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		out = append(out, &tracedTool{Tool: tool})
+	}
+	return out
+}
+
+func (t *tracedTool) Run(r llmtools.Runner, params json.RawMessage) llmtools.Result {
+	ctx, span := tracing.Start(r.Context(), "tool "+t.FuncName(), attribute.String("tool.name", t.FuncName()))
+	if tc, ok := llms.GetToolCall(ctx); ok {
+		span.SetAttributes(attribute.String("tool.call_id", tc.ID))
+	}
+	result := t.Tool.Run(tracedRunner{Runner: r, ctx: ctx}, params)
+	var err error
+	if result != nil {
+		err = result.Error()
+	}
+	tracing.End(span, err)
+	return result
+}
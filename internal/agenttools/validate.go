@@ -0,0 +1,378 @@
+package agenttools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// ArgError describes one tool argument that does not match the tool's
+// schema, with an example of a value that would.
+type ArgError struct {
+	Field    string `json:"field"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+	Example  any    `json:"example,omitempty"`
+}
+
+// ToolValidation counts calls and argument validation failures for one tool.
+type ToolValidation struct {
+	Tool        string  `json:"tool"`
+	Calls       int64   `json:"calls"`
+	Failures    int64   `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// ValidationStats records per-tool validation outcomes. It is safe for
+// concurrent use; the zero value is ready.
+type ValidationStats struct {
+	mu     sync.Mutex
+	counts map[string]*ToolValidation
+}
+
+func NewValidationStats() *ValidationStats {
+	return &ValidationStats{}
+}
+
+func (s *ValidationStats) record(tool string, failed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[string]*ToolValidation{}
+	}
+	c, ok := s.counts[tool]
+	if !ok {
+		c = &ToolValidation{Tool: tool}
+		s.counts[tool] = c
+	}
+	c.Calls++
+	if failed {
+		c.Failures++
+	}
+}
+
+// Snapshot returns the counts for every tool that has been called, sorted
+// by tool name.
+func (s *ValidationStats) Snapshot() []ToolValidation {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ToolValidation, 0, len(s.counts))
+	for _, c := range s.counts {
+		v := *c
+		if v.Calls > 0 {
+			v.FailureRate = float64(v.Failures) / float64(v.Calls)
+		}
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tool < out[j].Tool })
+	return out
+}
+
+type validatedTool struct {
+	llmtools.Tool
+	schema argSchema
+	stats  *ValidationStats
+}
+
+// Validated wraps JSON tools so their arguments are checked against the
+// tool's schema before it runs. Invalid calls never reach the tool; the model
+// gets every mismatch back as a structured error it can correct. Tools
+// without a validating JSON schema are returned unchanged.
+func Validated(stats *ValidationStats, tools ...llmtools.Tool) []llmtools.Tool {
+	out := make([]llmtools.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		schema, ok := toolArgSchema(tool)
+		if !ok {
+			out = append(out, tool)
+			continue
+		}
+		out = append(out, &validatedTool{Tool: tool, schema: schema, stats: stats})
+	}
+	return out
+}
+
+func (t *validatedTool) Run(r llmtools.Runner, params json.RawMessage) llmtools.Result {
+	errs := validateArgs(t.schema, params)
+	t.stats.record(t.FuncName(), len(errs) > 0)
+	if len(errs) == 0 {
+		return t.Tool.Run(r, params)
+	}
+	err := fmt.Errorf("invalid arguments for %s: %s", t.FuncName(), describeArgErrors(errs))
+	return toolresult.ErrorWithDetails(t.FuncName(), "Invalid arguments", err, map[string]any{
+		"invalid_arguments": errs,
+		"example":           exampleValue(t.schema),
+	})
+}
+
+// argSchema is the subset of JSON schema that tool parameters use.
+type argSchema struct {
+	Type                 string               `json:"type,omitempty"`
+	Items                *argSchema           `json:"items,omitempty"`
+	Properties           map[string]argSchema `json:"properties,omitempty"`
+	AdditionalProperties json.RawMessage      `json:"additionalProperties,omitempty"`
+	Required             []string             `json:"required,omitempty"`
+	AnyOf                []argSchema          `json:"anyOf,omitempty"`
+}
+
+func toolArgSchema(tool llmtools.Tool) (argSchema, bool) {
+	grammar, ok := tool.Grammar().(llmtools.JSONGrammar)
+	if !ok || grammar.SkipValidation() || grammar.Schema() == nil {
+		return argSchema{}, false
+	}
+	data, err := json.Marshal(grammar.Schema().Parameters)
+	if err != nil {
+		return argSchema{}, false
+	}
+	var schema argSchema
+	if err := json.Unmarshal(data, &schema); err != nil || schema.Type != "object" {
+		return argSchema{}, false
+	}
+	return schema, true
+}
+
+// validateArgs checks raw tool arguments against schema and returns every
+// mismatch, with nested fields written as dotted paths (items[0].name).
+func validateArgs(schema argSchema, params json.RawMessage) []ArgError {
+	raw := strings.TrimSpace(string(params))
+	if raw == "" {
+		raw = "{}"
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return []ArgError{{
+			Problem:  fmt.Sprintf("arguments are not valid JSON: %v", err),
+			Expected: "object",
+			Example:  exampleValue(schema),
+		}}
+	}
+	var errs []ArgError
+	validateArgValue(schema, "", value, &errs)
+	return errs
+}
+
+func validateArgValue(schema argSchema, path string, value any, errs *[]ArgError) {
+	if len(schema.AnyOf) > 0 {
+		for _, option := range schema.AnyOf {
+			var optionErrs []ArgError
+			validateArgValue(option, path, value, &optionErrs)
+			if len(optionErrs) == 0 {
+				return
+			}
+		}
+		*errs = append(*errs, ArgError{
+			Field:    path,
+			Problem:  "value matches none of the allowed shapes",
+			Expected: schemaTypeName(schema),
+			Got:      jsonTypeName(value),
+			Example:  exampleValue(schema.AnyOf[0]),
+		})
+		return
+	}
+	if !matchesType(schema.Type, value) {
+		*errs = append(*errs, ArgError{
+			Field:    path,
+			Problem:  "wrong type",
+			Expected: schema.Type,
+			Got:      jsonTypeName(value),
+			Example:  exampleValue(schema),
+		})
+		return
+	}
+	switch schema.Type {
+	case "array":
+		if schema.Items == nil {
+			return
+		}
+		for i, item := range value.([]any) {
+			validateArgValue(*schema.Items, fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case "object":
+		obj := value.(map[string]any)
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				prop := schema.Properties[name]
+				*errs = append(*errs, ArgError{
+					Field:    joinArgPath(path, name),
+					Problem:  "missing required field",
+					Expected: prop.Type,
+					Example:  exampleValue(prop),
+				})
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := joinArgPath(path, key)
+			if prop, ok := schema.Properties[key]; ok {
+				validateArgValue(prop, field, obj[key], errs)
+				continue
+			}
+			extra, allowed := additionalSchema(schema.AdditionalProperties)
+			if !allowed {
+				*errs = append(*errs, ArgError{
+					Field:   field,
+					Problem: fmt.Sprintf("unknown field; allowed fields: %s", strings.Join(sortedKeys(schema.Properties), ", ")),
+				})
+				continue
+			}
+			if extra != nil {
+				validateArgValue(*extra, field, obj[key], errs)
+			}
+		}
+	}
+}
+
+// additionalSchema interprets additionalProperties: absent or true allows any
+// extra field, false forbids them, and a schema constrains their values.
+func additionalSchema(raw json.RawMessage) (*argSchema, bool) {
+	trimmed := strings.TrimSpace(string(raw))
+	switch trimmed {
+	case "", "true", "null":
+		return nil, true
+	case "false":
+		return nil, false
+	}
+	var schema argSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, true
+	}
+	return &schema, true
+}
+
+func matchesType(want string, value any) bool {
+	switch want {
+	case "":
+		return true
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaTypeName(schema argSchema) string {
+	if len(schema.AnyOf) == 0 {
+		return schema.Type
+	}
+	names := make([]string, 0, len(schema.AnyOf))
+	for _, option := range schema.AnyOf {
+		names = append(names, schemaTypeName(option))
+	}
+	return strings.Join(names, " | ")
+}
+
+// exampleValue builds a minimal value that satisfies schema, filling in
+// required object fields only.
+func exampleValue(schema argSchema) any {
+	if len(schema.AnyOf) > 0 {
+		return exampleValue(schema.AnyOf[0])
+	}
+	switch schema.Type {
+	case "string":
+		return "text"
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "array":
+		if schema.Items == nil {
+			return []any{}
+		}
+		return []any{exampleValue(*schema.Items)}
+	case "object":
+		out := map[string]any{}
+		for _, name := range schema.Required {
+			out[name] = exampleValue(schema.Properties[name])
+		}
+		return out
+	}
+	return nil
+}
+
+func describeArgErrors(errs []ArgError) string {
+	parts := make([]string, 0, len(errs))
+	for _, e := range errs {
+		field := e.Field
+		if field == "" {
+			field = "arguments"
+		}
+		part := fmt.Sprintf("%s: %s", field, e.Problem)
+		if e.Expected != "" && e.Got != "" {
+			part += fmt.Sprintf(" (expected %s, got %s)", e.Expected, e.Got)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinArgPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]argSchema) []string {
+	out := make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
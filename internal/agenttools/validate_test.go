@@ -0,0 +1,68 @@
+package agenttools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/content"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type validateTestParams struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestValidatedReturnsStructuredArgErrors(t *testing.T) {
+	ran := 0
+	tool := llmtools.Func("Probe", "probe", "probe", func(_ llmtools.Runner, p validateTestParams) llmtools.Result {
+		ran++
+		return toolresult.Success("probe", map[string]any{"ok": true})
+	})
+	stats := NewValidationStats()
+	wrapped := Validated(stats, tool)[0]
+
+	result := wrapped.Run(llmtools.NopRunner, json.RawMessage(`{"count":"3","tags":["a",2],"colour":"red"}`))
+	if result.Error() == nil {
+		t.Fatalf("expected validation error")
+	}
+	if ran != 0 {
+		t.Fatalf("tool ran with invalid arguments")
+	}
+	text, ok := result.Content()[0].(*content.Text)
+	if !ok || !strings.Contains(text.Text, "<details>") || !strings.Contains(text.Text, `"field": "count"`) {
+		t.Fatalf("expected structured details in result, got %#v", result.Content())
+	}
+	errs := validateArgs(wrapped.(*validatedTool).schema, json.RawMessage(`{"count":"3","tags":["a",2],"colour":"red"}`))
+	got := map[string]ArgError{}
+	for _, e := range errs {
+		got[e.Field] = e
+	}
+	if e := got["name"]; e.Problem != "missing required field" || e.Example != "text" {
+		t.Fatalf("expected missing name with example, got %+v", e)
+	}
+	if e := got["count"]; e.Expected != "integer" || e.Got != "string" || e.Example != 1 {
+		t.Fatalf("expected count type mismatch, got %+v", e)
+	}
+	if e := got["tags[1]"]; e.Expected != "string" || e.Got != "integer" {
+		t.Fatalf("expected tags[1] type mismatch, got %+v", e)
+	}
+	if e := got["colour"]; !strings.HasPrefix(e.Problem, "unknown field") {
+		t.Fatalf("expected unknown field error, got %+v", e)
+	}
+
+	if result := wrapped.Run(llmtools.NopRunner, json.RawMessage(`{"name":"x","count":2}`)); result.Error() != nil {
+		t.Fatalf("unexpected error for valid args: %v", result.Error())
+	}
+	if ran != 1 {
+		t.Fatalf("expected tool to run once, ran %d", ran)
+	}
+
+	snap := stats.Snapshot()
+	if len(snap) != 1 || snap[0].Tool != "probe" || snap[0].Calls != 2 || snap[0].Failures != 1 || snap[0].FailureRate != 0.5 {
+		t.Fatalf("unexpected stats: %+v", snap)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 
+	"github.com/flitsinc/go-agents/internal/governor"
 	"github.com/flitsinc/go-llms/anthropic"
 	"github.com/flitsinc/go-llms/google"
 	"github.com/flitsinc/go-llms/llms"
@@ -17,12 +21,38 @@ type Config struct {
 	Provider string
 	Model    string
 	APIKey   string
+	// ProviderTools enables provider-native tools such as web_search.
+	ProviderTools []string
+	// APIKeys holds keys for other providers, keyed by provider name, for
+	// sessions that override Provider.
+	APIKeys map[string]string
+	// HTTPClient makes the provider calls; nil uses http.DefaultClient.
+	HTTPClient *http.Client
+	// Governor queues provider calls beyond its limits; nil leaves them
+	// unlimited.
+	Governor *governor.Governor
+}
+
+// SessionOptions overrides client config for a single session. A nil
+// ProviderTools keeps the client default; an empty slice disables them.
+type SessionOptions struct {
+	// Provider switches the session to another provider, using its key from
+	// Config.APIKeys. Provider-native tools are dropped unless ProviderTools
+	// is set.
+	Provider      string
+	Model         string
+	ProviderTools []string
+	// ToolFilter drops tools it returns false for; nil keeps every tool.
+	ToolFilter func(name string) bool
 }
 
 type Client struct {
 	LLM    *llms.LLM
 	config Config
 	tools  []llmtools.Tool
+
+	sourceMu   sync.RWMutex
+	toolSource func() []llmtools.Tool
 }
 
 func NewClient(cfg Config, tools ...llmtools.Tool) (*Client, error) {
@@ -40,10 +70,14 @@ func (c *Client) NewSession() (*llms.LLM, error) {
 	if c.config.Provider == "" {
 		return nil, errors.New("client config missing provider")
 	}
-	return newLLM(c.config, c.tools...)
+	return newLLM(c.config, c.sessionTools()...)
 }
 
 func (c *Client) NewSessionWithModel(model string) (*llms.LLM, error) {
+	return c.NewSessionWithOptions(SessionOptions{Model: model})
+}
+
+func (c *Client) NewSessionWithOptions(opts SessionOptions) (*llms.LLM, error) {
 	if c == nil {
 		return nil, errors.New("client is nil")
 	}
@@ -51,10 +85,29 @@ func (c *Client) NewSessionWithModel(model string) (*llms.LLM, error) {
 		return nil, errors.New("client config missing provider")
 	}
 	cfg := c.config
-	if strings.TrimSpace(model) != "" {
-		cfg.Model = resolveModelAlias(cfg.Provider, model)
+	if provider := strings.TrimSpace(opts.Provider); provider != "" && provider != cfg.Provider {
+		key := strings.TrimSpace(c.config.APIKeys[provider])
+		if key == "" {
+			return nil, fmt.Errorf("no API key configured for provider %s", provider)
+		}
+		if strings.TrimSpace(opts.Model) == "" {
+			return nil, fmt.Errorf("a model is required when switching to provider %s", provider)
+		}
+		cfg.Provider = provider
+		cfg.APIKey = key
+		cfg.ProviderTools = nil
+	}
+	if strings.TrimSpace(opts.Model) != "" {
+		cfg.Model = resolveModelAlias(cfg.Provider, opts.Model)
+	}
+	if opts.ProviderTools != nil {
+		cfg.ProviderTools = opts.ProviderTools
+	}
+	tools := c.sessionTools()
+	if opts.ToolFilter != nil {
+		tools = slices.DeleteFunc(tools, func(tool llmtools.Tool) bool { return !opts.ToolFilter(tool.FuncName()) })
 	}
-	return newLLM(cfg, c.tools...)
+	return newLLM(cfg, tools...)
 }
 
 func newLLM(cfg Config, tools ...llmtools.Tool) (*llms.LLM, error) {
@@ -67,23 +120,46 @@ func newLLM(cfg Config, tools ...llmtools.Tool) (*llms.LLM, error) {
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("llm api key is required")
 	}
+	providerTools, err := normalizeProviderTools(cfg.Provider, cfg.ProviderTools)
+	if err != nil {
+		return nil, err
+	}
 
 	var provider llms.Provider
 	switch cfg.Provider {
 	case "openai-responses":
-		provider = openai.NewResponsesAPI(cfg.APIKey, cfg.Model)
+		model := openai.NewResponsesAPI(cfg.APIKey, cfg.Model)
+		for _, name := range providerTools {
+			model.WithTool(responseTool(name))
+		}
+		if cfg.HTTPClient != nil {
+			model.SetHTTPClient(cfg.HTTPClient)
+		}
+		provider = model
 	case "openai-chat":
-		provider = openai.NewChatCompletionsAPI(cfg.APIKey, cfg.Model)
+		model := openai.NewChatCompletionsAPI(cfg.APIKey, cfg.Model)
+		if cfg.HTTPClient != nil {
+			model.SetHTTPClient(cfg.HTTPClient)
+		}
+		provider = model
 	case "anthropic":
 		model := anthropic.New(cfg.APIKey, cfg.Model)
-		model.WithMaxTokens(62976)
+		model.WithMaxTokens(anthropicMaxTokens)
 		model.WithThinking(1024)
+		if cfg.HTTPClient != nil {
+			model.SetHTTPClient(cfg.HTTPClient)
+		}
 		provider = model
 	case "google":
-		provider = google.New(cfg.Model).WithGeminiAPI(cfg.APIKey)
+		model := google.New(cfg.Model).WithGeminiAPI(cfg.APIKey)
+		if cfg.HTTPClient != nil {
+			model.SetHTTPClient(cfg.HTTPClient)
+		}
+		provider = model
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", cfg.Provider)
 	}
+	provider = cfg.Governor.Wrap(cfg.Provider, provider)
 
 	if len(tools) > 0 {
 		return llms.New(provider, tools...), nil
@@ -135,3 +211,60 @@ func (c *Client) Chat(ctx context.Context, messages []llms.Message) <-chan llms.
 	}
 	return c.LLM.ChatUsingMessages(ctx, messages)
 }
+
+// Tools returns the tools the client registers on new sessions.
+func (c *Client) Tools() []llmtools.Tool {
+	if c == nil {
+		return nil
+	}
+	return c.sessionTools()
+}
+
+// SetToolSource adds the tools source returns to every new session, after
+// the client's own. The source is asked on each session, so tools it adds or
+// drops apply from the next session on. Source tools named like an earlier
+// tool are skipped. Copies made with WithTools do not inherit it.
+func (c *Client) SetToolSource(source func() []llmtools.Tool) {
+	c.sourceMu.Lock()
+	c.toolSource = source
+	c.sourceMu.Unlock()
+}
+
+func (c *Client) sessionTools() []llmtools.Tool {
+	tools := append([]llmtools.Tool(nil), c.tools...)
+	c.sourceMu.RLock()
+	source := c.toolSource
+	c.sourceMu.RUnlock()
+	if source == nil {
+		return tools
+	}
+	seen := map[string]bool{}
+	for _, tool := range tools {
+		seen[tool.FuncName()] = true
+	}
+	for _, tool := range source() {
+		if tool == nil || seen[tool.FuncName()] {
+			continue
+		}
+		seen[tool.FuncName()] = true
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// WithTools returns a copy of the client whose sessions use tools instead of
+// the original tool set.
+func (c *Client) WithTools(tools ...llmtools.Tool) (*Client, error) {
+	if c == nil {
+		return nil, errors.New("client is nil")
+	}
+	out := &Client{config: c.config, tools: tools}
+	if c.config.Provider != "" {
+		llm, err := newLLM(c.config, tools...)
+		if err != nil {
+			return nil, err
+		}
+		out.LLM = llm
+	}
+	return out, nil
+}
@@ -0,0 +1,200 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/governor"
+)
+
+// EmbeddingConfig picks the provider and model that turn text into vectors.
+// An empty Provider uses the client's own provider; an empty Model uses the
+// provider's default embedding model.
+type EmbeddingConfig struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// defaultEmbeddingModels are the embedding models of providers that have an
+// embeddings API. Anthropic has none.
+var defaultEmbeddingModels = map[string]string{
+	"openai-responses": "text-embedding-3-small",
+	"openai-chat":      "text-embedding-3-small",
+	"google":           "text-embedding-004",
+}
+
+// embeddingEndpoints are the embeddings APIs by provider. Google's takes the
+// model and method appended.
+var embeddingEndpoints = map[string]string{
+	"openai-responses": "https://api.openai.com/v1/embeddings",
+	"openai-chat":      "https://api.openai.com/v1/embeddings",
+	"google":           "https://generativelanguage.googleapis.com/v1beta/models/",
+}
+
+// maxEmbedBatch is how many texts go in one embeddings request.
+const maxEmbedBatch = 96
+
+// Embedder turns text into vectors with a provider's embeddings API.
+type Embedder struct {
+	provider   string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+	governor   *governor.Governor
+}
+
+// Embedder returns an embedder for cfg, using the client's API keys, HTTP
+// client and provider limits.
+func (c *Client) Embedder(cfg EmbeddingConfig) (*Embedder, error) {
+	if c == nil {
+		return nil, errors.New("client is nil")
+	}
+	provider := strings.TrimSpace(cfg.Provider)
+	if provider == "" {
+		provider = c.config.Provider
+	}
+	if _, ok := embeddingEndpoints[provider]; !ok {
+		return nil, fmt.Errorf("provider %q has no embeddings API", provider)
+	}
+	key := c.config.APIKey
+	if provider != c.config.Provider {
+		key = c.config.APIKeys[provider]
+	}
+	if strings.TrimSpace(key) == "" {
+		return nil, fmt.Errorf("no API key configured for provider %s", provider)
+	}
+	model := strings.TrimSpace(cfg.Model)
+	if model == "" {
+		model = defaultEmbeddingModels[provider]
+	}
+	httpClient := c.config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Embedder{provider: provider, model: model, apiKey: key, httpClient: httpClient, governor: c.config.Governor}, nil
+}
+
+// Model names the provider and model, as "provider/model". Vectors of
+// different models are not comparable, so stored vectors record it.
+func (e *Embedder) Model() string {
+	return e.provider + "/" + e.model
+}
+
+// Embed returns one vector per text, in order.
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbedBatch {
+		batch := texts[start:min(start+maxEmbedBatch, len(texts))]
+		vectors, err := e.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("embed: %s returned %d vectors for %d texts", e.provider, len(vectors), len(batch))
+		}
+		out = append(out, vectors...)
+	}
+	return out, nil
+}
+
+func (e *Embedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	permit, err := e.governor.Acquire(ctx, e.provider, agentcontext.TaskIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer permit.Release()
+	if e.provider == "google" {
+		return e.embedGoogle(ctx, texts)
+	}
+	return e.embedOpenAI(ctx, texts)
+}
+
+func (e *Embedder) embedOpenAI(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + e.apiKey}}
+	if err := e.post(ctx, embeddingEndpoints[e.provider], header, map[string]any{"model": e.model, "input": texts}, &resp); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embed: %s returned index %d for %d texts", e.provider, d.Index, len(texts))
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+func (e *Embedder) embedGoogle(ctx context.Context, texts []string) ([][]float32, error) {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type request struct {
+		Model   string `json:"model"`
+		Content struct {
+			Parts []part `json:"parts"`
+		} `json:"content"`
+	}
+	requests := make([]request, len(texts))
+	for i, text := range texts {
+		requests[i].Model = "models/" + e.model
+		requests[i].Content.Parts = []part{{Text: text}}
+	}
+	var resp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	endpoint := embeddingEndpoints[e.provider] + url.PathEscape(e.model) + ":batchEmbedContents"
+	header := http.Header{"X-Goog-Api-Key": {e.apiKey}}
+	if err := e.post(ctx, endpoint, header, map[string]any{"requests": requests}, &resp); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(resp.Embeddings))
+	for i, emb := range resp.Embeddings {
+		out[i] = emb.Values
+	}
+	return out, nil
+}
+
+func (e *Embedder) post(ctx context.Context, endpoint string, header http.Header, body, out any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode embeddings request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("build embeddings request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("embed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return fmt.Errorf("embed: read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("embed: %s returned %s: %s", e.provider, resp.Status, strings.TrimSpace(string(data[:min(len(data), 512)])))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("embed: decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type embedTransport func(*http.Request) (string, error)
+
+func (f embedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := f(req)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestEmbedderCallsTheProviderEmbeddingsAPI(t *testing.T) {
+	var gotURL, gotAuth string
+	var gotBody map[string]any
+	transport := embedTransport(func(req *http.Request) (string, error) {
+		gotURL = req.URL.String()
+		gotAuth = req.Header.Get("Authorization") + req.Header.Get("X-Goog-Api-Key")
+		gotBody = map[string]any{}
+		_ = json.NewDecoder(req.Body).Decode(&gotBody)
+		if strings.Contains(gotURL, "googleapis") {
+			return `{"embeddings": [{"values": [1, 0]}, {"values": [0, 1]}]}`, nil
+		}
+		// OpenAI may return the vectors out of order.
+		return `{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`, nil
+	})
+	client, err := NewClient(Config{
+		Provider:   "anthropic",
+		Model:      "claude-sonnet-4-5",
+		APIKey:     "anthropic-key",
+		APIKeys:    map[string]string{"openai-chat": "openai-key", "google": "google-key"},
+		HTTPClient: &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.Embedder(EmbeddingConfig{}); err == nil || !strings.Contains(err.Error(), "no embeddings API") {
+		t.Fatalf("expected anthropic to have no embeddings, got %v", err)
+	}
+
+	openai, err := client.Embedder(EmbeddingConfig{Provider: "openai-chat"})
+	if err != nil || openai.Model() != "openai-chat/text-embedding-3-small" {
+		t.Fatalf("unexpected openai embedder %v, %v", openai, err)
+	}
+	vectors, err := openai.Embed(context.Background(), []string{"first", "second"})
+	if err != nil || len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("unexpected openai vectors %v, %v", vectors, err)
+	}
+	if gotURL != "https://api.openai.com/v1/embeddings" || gotAuth != "Bearer openai-key" || gotBody["model"] != "text-embedding-3-small" {
+		t.Fatalf("unexpected openai request %s %q %v", gotURL, gotAuth, gotBody)
+	}
+
+	google, err := client.Embedder(EmbeddingConfig{Provider: "google", Model: "gemini-embedding-001"})
+	if err != nil {
+		t.Fatalf("google embedder: %v", err)
+	}
+	vectors, err = google.Embed(context.Background(), []string{"first", "second"})
+	if err != nil || len(vectors) != 2 || vectors[1][1] != 1 {
+		t.Fatalf("unexpected google vectors %v, %v", vectors, err)
+	}
+	if !strings.HasSuffix(gotURL, "/models/gemini-embedding-001:batchEmbedContents") || gotAuth != "google-key" {
+		t.Fatalf("unexpected google request %s %q", gotURL, gotAuth)
+	}
+	if requests, _ := gotBody["requests"].([]any); len(requests) != 2 {
+		t.Fatalf("expected one request per text, got %v", gotBody)
+	}
+}
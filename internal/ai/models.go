@@ -0,0 +1,75 @@
+package ai
+
+import "strings"
+
+// ModelInfo describes what a model accepts. Zero token counts mean the
+// model is not in the table.
+type ModelInfo struct {
+	ContextTokens   int  `json:"context_tokens,omitempty"`
+	MaxOutputTokens int  `json:"max_output_tokens,omitempty"`
+	Vision          bool `json:"vision"`
+	Known           bool `json:"known"`
+}
+
+// anthropicMaxTokens is the output limit requested from Anthropic models.
+const anthropicMaxTokens = 62976
+
+// modelTable maps model name prefixes to their limits, per provider. The
+// longest matching prefix wins.
+var modelTable = map[string]map[string]ModelInfo{
+	"anthropic": {
+		"claude-": {ContextTokens: 200000, MaxOutputTokens: anthropicMaxTokens, Vision: true},
+	},
+	"openai": {
+		"gpt-3.5":     {ContextTokens: 16385, MaxOutputTokens: 4096},
+		"gpt-4o":      {ContextTokens: 128000, MaxOutputTokens: 16384, Vision: true},
+		"gpt-4.1":     {ContextTokens: 1047576, MaxOutputTokens: 32768, Vision: true},
+		"gpt-5":       {ContextTokens: 400000, MaxOutputTokens: 128000, Vision: true},
+		"o1":          {ContextTokens: 200000, MaxOutputTokens: 100000, Vision: true},
+		"o3":          {ContextTokens: 200000, MaxOutputTokens: 100000, Vision: true},
+		"o4-mini":     {ContextTokens: 200000, MaxOutputTokens: 100000, Vision: true},
+		"gpt-4-turbo": {ContextTokens: 128000, MaxOutputTokens: 4096, Vision: true},
+	},
+	"google": {
+		"gemini-1.5-pro": {ContextTokens: 2097152, MaxOutputTokens: 8192, Vision: true},
+		"gemini-1.5":     {ContextTokens: 1048576, MaxOutputTokens: 8192, Vision: true},
+		"gemini-2.0":     {ContextTokens: 1048576, MaxOutputTokens: 8192, Vision: true},
+		"gemini-2.5":     {ContextTokens: 1048576, MaxOutputTokens: 65536, Vision: true},
+	},
+}
+
+// LookupModel returns what is known about model on provider. Model aliases
+// such as "fast" are resolved first.
+func LookupModel(provider, model string) ModelInfo {
+	model = strings.ToLower(strings.TrimSpace(resolveModelAlias(provider, model)))
+	family := provider
+	if strings.HasPrefix(provider, "openai") {
+		family = "openai"
+	}
+	var best string
+	var info ModelInfo
+	for prefix, candidate := range modelTable[family] {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+			info = candidate
+		}
+	}
+	info.Known = best != ""
+	return info
+}
+
+// Model returns the configured model.
+func (c *Client) Model() string {
+	if c == nil {
+		return ""
+	}
+	return c.config.Model
+}
+
+// ProviderTools returns the provider-native tools enabled by default.
+func (c *Client) ProviderTools() []string {
+	if c == nil {
+		return nil
+	}
+	return append([]string(nil), c.config.ProviderTools...)
+}
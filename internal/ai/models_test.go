@@ -0,0 +1,15 @@
+package ai
+
+import "testing"
+
+func TestLookupModelUsesLongestPrefix(t *testing.T) {
+	if info := LookupModel("google", "gemini-1.5-pro-002"); info.ContextTokens != 2097152 {
+		t.Fatalf("expected gemini-1.5-pro limits, got %+v", info)
+	}
+	if info := LookupModel("openai-chat", "fast"); !info.Known || info.ContextTokens != 128000 {
+		t.Fatalf("expected the fast alias to resolve to gpt-4o-mini, got %+v", info)
+	}
+	if info := LookupModel("openai-responses", "gpt-3.5-turbo"); info.Vision || !info.Known {
+		t.Fatalf("expected gpt-3.5 to be text only, got %+v", info)
+	}
+}
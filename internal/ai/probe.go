@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// providerEndpoints are the API hosts probed for reachability.
+var providerEndpoints = map[string]string{
+	"anthropic":        "https://api.anthropic.com",
+	"openai-responses": "https://api.openai.com",
+	"openai-chat":      "https://api.openai.com",
+	"google":           "https://generativelanguage.googleapis.com",
+}
+
+// Provider returns the configured provider name.
+func (c *Client) Provider() string {
+	if c == nil {
+		return ""
+	}
+	return c.config.Provider
+}
+
+// Probe checks that the provider's API host answers HTTP. Any response,
+// including auth errors, counts as reachable; no tokens are spent.
+func (c *Client) Probe(ctx context.Context) error {
+	if c == nil {
+		return errors.New("client is nil")
+	}
+	endpoint, ok := providerEndpoints[c.config.Provider]
+	if !ok {
+		return fmt.Errorf("no probe endpoint for provider %q", c.config.Provider)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("build probe request: %w", err)
+	}
+	client := c.config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("probe %s: %w", c.config.Provider, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/openai"
+)
+
+// Provider-native tools run on the provider's side. Their calls and results
+// stream back with the response instead of going through a local tool.
+const (
+	ProviderToolWebSearch       = "web_search"
+	ProviderToolCodeInterpreter = "code_interpreter"
+	ProviderToolImageGeneration = "image_generation"
+)
+
+// ProviderToolNames lists the provider-native tools a provider supports.
+func ProviderToolNames(provider string) []string {
+	switch provider {
+	case "openai-responses":
+		return []string{ProviderToolCodeInterpreter, ProviderToolImageGeneration, ProviderToolWebSearch}
+	}
+	return nil
+}
+
+func normalizeProviderTools(provider string, names []string) ([]string, error) {
+	supported := map[string]bool{}
+	for _, name := range ProviderToolNames(provider) {
+		supported[name] = true
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(names))
+	for _, raw := range names {
+		name := strings.ToLower(strings.TrimSpace(raw))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !supported[name] {
+			return nil, fmt.Errorf("provider tool %q is not supported by %s", name, provider)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func responseTool(name string) openai.ResponseTool {
+	switch name {
+	case ProviderToolWebSearch:
+		return openai.WebSearchTool{Type: "web_search_preview"}
+	case ProviderToolCodeInterpreter:
+		return openai.CodeInterpreterTool{
+			Type:      "code_interpreter",
+			Container: openai.CodeInterpreterContainerAuto{Type: "auto"},
+		}
+	case ProviderToolImageGeneration:
+		return openai.ImageGenerationTool{Type: "image_generation"}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewClientProviderTools(t *testing.T) {
+	if _, err := NewClient(Config{
+		Provider:      "openai-responses",
+		Model:         "gpt-4o",
+		APIKey:        "test",
+		ProviderTools: []string{"Web_Search", "code_interpreter", "web_search"},
+	}); err != nil {
+		t.Fatalf("expected openai-responses to accept provider tools: %v", err)
+	}
+
+	_, err := NewClient(Config{
+		Provider:      "anthropic",
+		Model:         "claude-sonnet-4-5",
+		APIKey:        "test",
+		ProviderTools: []string{"web_search"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not supported by anthropic") {
+		t.Fatalf("expected unsupported provider tool error, got %v", err)
+	}
+}
+
+func TestNewSessionWithOptionsOverridesProviderTools(t *testing.T) {
+	client, err := NewClient(Config{Provider: "anthropic", Model: "claude-sonnet-4-5", APIKey: "test"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.NewSessionWithOptions(SessionOptions{ProviderTools: []string{"web_search"}}); err == nil {
+		t.Fatalf("expected per-session provider tools to be validated")
+	}
+	if _, err := client.NewSessionWithOptions(SessionOptions{ProviderTools: []string{}}); err != nil {
+		t.Fatalf("expected empty provider tools to be accepted: %v", err)
+	}
+}
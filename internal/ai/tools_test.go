@@ -83,3 +83,38 @@ func TestAddExternalToolsDispatch(t *testing.T) {
 		t.Fatalf("expected external handler to be called")
 	}
 }
+
+func TestToolSourceAddsToolsPerSession(t *testing.T) {
+	tool := func(name string) llmtools.Tool {
+		return llmtools.External(name, &llmtools.FunctionSchema{Name: name, Parameters: llmtools.ValueSchema{Type: "object"}}, func(llmtools.Runner, json.RawMessage) llmtools.Result {
+			return llmtools.SuccessFromString("ok")
+		})
+	}
+	client, err := NewClient(Config{Provider: "anthropic", Model: "m", APIKey: "k"}, tool("exec"))
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	extra := []llmtools.Tool{tool("exec"), tool("docs_search")}
+	client.SetToolSource(func() []llmtools.Tool { return extra })
+
+	names := func() []string {
+		var out []string
+		for _, tool := range client.Tools() {
+			out = append(out, tool.FuncName())
+		}
+		return out
+	}
+	if got := names(); len(got) != 2 || got[0] != "exec" || got[1] != "docs_search" {
+		t.Fatalf("expected the source's tools after the client's, without duplicates, got %v", got)
+	}
+	if _, err := client.NewSession(); err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	extra = nil
+	if got := names(); len(got) != 1 {
+		t.Fatalf("expected dropped source tools to go, got %v", got)
+	}
+	if plain, err := client.WithTools(); err != nil || len(plain.Tools()) != 0 {
+		t.Fatalf("expected WithTools copies to leave the source out, got %v", err)
+	}
+}
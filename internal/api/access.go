@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+)
+
+type principalKey struct{}
+
+// maxAccessDepth bounds the walk from a task up to the agent whose grants
+// govern it.
+const maxAccessDepth = 8
+
+// adminPaths expose every agent's data at once, so only admins may use them
+// once API keys are configured. Others use the per-agent endpoints.
+var adminPaths = []string{
+	"/api/state",
+	"/api/streams",
+	"/api/threads",
+	"/api/tasks/queue",
+	"/api/maintenance",
+	"/api/topics",
+	"/api/admin",
+	"/api/federation",
+	"/api/mcp",
+}
+
+// serverPaths act on the whole instance rather than on tasks and events,
+// so admins confined to a namespace may not use them.
+var serverPaths = []string{
+	"/api/maintenance",
+	"/api/topics",
+	"/api/admin",
+	"/api/federation",
+	"/api/mcp",
+	"/api/templates",
+	"/api/contacts",
+}
+
+// publicPaths need no API key: health probes, version discovery and the
+// API description, share links which carry their own signed token, and
+// federated messages and GitHub webhooks which their senders sign.
+var publicPaths = []string{
+	"/api/health",
+	"/api/versions",
+	"/api/openapi.json",
+	"/api/docs",
+	"/api/share",
+	"/api/federation/inbound",
+	"/api/github/webhook",
+}
+
+func principalFrom(ctx context.Context) (access.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(access.Principal)
+	return p, ok
+}
+
+// withAccess authenticates requests by API key, sent as a bearer token or in
+// X-API-Key. Without an access store every request passes.
+func (s *Server) withAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Access == nil || hasPathPrefix(r.URL.Path, publicPaths) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+		if key == "" {
+			key = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		}
+		principal, err := s.Access.Authenticate(key)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if !principal.Admin && hasPathPrefix(r.URL.Path, adminPaths) {
+			writeError(w, http.StatusForbidden, errors.New("admin access required"))
+			return
+		}
+		if principal.Namespace != "" && hasPathPrefix(r.URL.Path, serverPaths) {
+			writeError(w, http.StatusForbidden, errors.New("admin access without a namespace required"))
+			return
+		}
+		// Everything the request creates lands in the key's namespace.
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		ctx = agentcontext.WithNamespace(ctx, principal.Namespace)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// requestNamespace is the namespace the caller is confined to, or "" when
+// it sees every namespace.
+func requestNamespace(r *http.Request) string {
+	return agentcontext.NamespaceFromContext(r.Context())
+}
+
+// accessLevel resolves the caller's level on a task. Tasks in another
+// namespace than the caller's are invisible. Otherwise grants on the task or
+// its nearest granted ancestor apply. Agents without grants, and tasks under
+// them, are admin-only; other ungoverned tasks (queue work) stay open.
+func (s *Server) accessLevel(ctx context.Context, taskID string) (access.Level, error) {
+	principal, ok := principalFrom(ctx)
+	if s.Access == nil || !ok {
+		return access.LevelOwner, nil
+	}
+	if principal.Namespace != "" && s.Tasks != nil {
+		if task, err := s.Tasks.Get(ctx, taskID); err == nil && task.Namespace != principal.Namespace {
+			return access.LevelNone, nil
+		}
+	}
+	if principal.Admin {
+		return access.LevelOwner, nil
+	}
+	sawAgent := false
+	for depth := 0; taskID != "" && depth < maxAccessDepth; depth++ {
+		level, found, err := s.Access.Level(ctx, taskID, principal)
+		if err != nil {
+			return access.LevelNone, err
+		}
+		if found {
+			return level, nil
+		}
+		if s.Tasks == nil {
+			break
+		}
+		task, err := s.Tasks.Get(ctx, taskID)
+		if err != nil {
+			break
+		}
+		sawAgent = sawAgent || task.Type == "agent"
+		taskID = task.ParentID
+	}
+	if sawAgent {
+		return access.LevelNone, nil
+	}
+	return access.LevelOwner, nil
+}
+
+// inNamespace reports whether taskID is a task in namespace.
+func (s *Server) inNamespace(ctx context.Context, taskID, namespace string) bool {
+	if s.Tasks == nil {
+		return false
+	}
+	task, err := s.Tasks.Get(ctx, taskID)
+	return err == nil && task.Namespace == namespace
+}
+
+// requireAccess writes an error and returns false unless the caller holds
+// need on taskID. Callers without view access get 404 so agent IDs do not
+// leak.
+func (s *Server) requireAccess(w http.ResponseWriter, r *http.Request, taskID string, need access.Level) bool {
+	level, err := s.accessLevel(r.Context(), taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if level < access.LevelView {
+		writeError(w, http.StatusNotFound, errNotFound("task"))
+		return false
+	}
+	if level < need {
+		writeError(w, http.StatusForbidden, errors.New(need.String()+" access required"))
+		return false
+	}
+	return true
+}
+
+// readOrInteract is the level most endpoints need: view to read, interact to
+// change anything.
+func readOrInteract(r *http.Request) access.Level {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return access.LevelView
+	}
+	return access.LevelInteract
+}
+
+// grantOwner makes the caller the owner of a newly created agent.
+func (s *Server) grantOwner(ctx context.Context, agentID string) error {
+	principal, ok := principalFrom(ctx)
+	if s.Access == nil || !ok {
+		return nil
+	}
+	return s.Access.Grant(ctx, agentID, principal.ID, access.LevelOwner)
+}
+
+// handleAgentACL serves /api/agents/<id>/acl: GET lists grants, POST
+// {principal, level} grants, DELETE /acl/<principal> revokes.
+func (s *Server) handleAgentACL(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Access == nil {
+		writeError(w, http.StatusNotFound, errNotFound("access control"))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && len(rest) == 0:
+		grants, err := s.Access.Grants(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"agent_id": agentID, "grants": grants})
+	case r.Method == http.MethodPost && len(rest) == 0:
+		var payload struct {
+			Principal string `json:"principal"`
+			Level     string `json:"level"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		level, err := access.ParseLevel(payload.Level)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.Access.Grant(r.Context(), agentID, payload.Principal, level); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	case r.Method == http.MethodDelete && len(rest) == 1:
+		if err := s.Access.Revoke(r.Context(), agentID, rest[0]); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
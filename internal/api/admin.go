@@ -0,0 +1,217 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/monitors"
+)
+
+func (s *Server) handleAdminRestart(w http.ResponseWriter, r *http.Request) {
+	if s.Restart == nil {
+		writeError(w, http.StatusNotFound, errNotFound("restart orchestrator"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Restart.Status())
+	case http.MethodPost:
+		status, err := s.Restart.Begin(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "status": status})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, status)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleAdminMonitors lists background monitors with their last and next
+// runs.
+func (s *Server) handleAdminMonitors(w http.ResponseWriter, r *http.Request) {
+	if s.Monitors == nil {
+		writeError(w, http.StatusNotFound, errNotFound("monitor registry"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Monitors.Statuses())
+}
+
+// handleAdminMonitorItem shows one monitor on GET and pauses, resumes or
+// re-times it on PATCH with {"enabled": bool, "interval": "1m"}.
+func (s *Server) handleAdminMonitorItem(w http.ResponseWriter, r *http.Request) {
+	if s.Monitors == nil {
+		writeError(w, http.StatusNotFound, errNotFound("monitor registry"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/monitors/"), "/")
+	status, ok := s.Monitors.Get(name)
+	if name == "" || !ok {
+		writeError(w, http.StatusNotFound, errNotFound("monitor"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, status)
+	case http.MethodPatch:
+		var payload struct {
+			Enabled  *bool   `json:"enabled"`
+			Interval *string `json:"interval"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		update := monitors.Update{Enabled: payload.Enabled}
+		if payload.Interval != nil {
+			interval, err := time.ParseDuration(*payload.Interval)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			update.Interval = &interval
+		}
+		status, err := s.Monitors.Update(name, update)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// authorizeAdmin checks the configured admin token, sent either as a bearer
+// token or in X-Restart-Token. Without a configured token all requests pass.
+func (s *Server) authorizeAdmin(r *http.Request) bool {
+	expected := strings.TrimSpace(s.RestartToken)
+	if expected == "" {
+		return true
+	}
+	got := strings.TrimSpace(r.Header.Get("X-Restart-Token"))
+	if got == "" {
+		got = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
+
+// handleAdminToolValidation reports, per tool, how many calls were made and
+// how many were rejected for arguments that did not match the tool schema.
+func (s *Server) handleAdminToolValidation(w http.ResponseWriter, r *http.Request) {
+	if s.ToolValidation == nil {
+		writeError(w, http.StatusNotFound, errNotFound("tool validation stats"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.ToolValidation.Snapshot())
+}
+
+// handleAdminProviders reports the provider governor's limits, queues and
+// wait times, globally and per provider.
+func (s *Server) handleAdminProviders(w http.ResponseWriter, r *http.Request) {
+	if s.Governor == nil {
+		writeError(w, http.StatusNotFound, errNotFound("provider limits"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Governor.Snapshot())
+}
+
+// handleAdminEgress lists the outbound destinations contacted since start
+// with request, denial and error counts.
+func (s *Server) handleAdminEgress(w http.ResponseWriter, r *http.Request) {
+	if s.Egress == nil {
+		writeError(w, http.StatusNotFound, errNotFound("egress policy"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"destinations": s.Egress.Destinations()})
+}
+
+// handleAdminStorage reports database telemetry: the slowest queries, the
+// longest write lock holds, busy error and retry counts, and the top
+// statements by total time (?top=, default 20). DELETE clears it.
+func (s *Server) handleAdminStorage(w http.ResponseWriter, r *http.Request) {
+	if s.Storage == nil {
+		writeError(w, http.StatusNotFound, errNotFound("storage telemetry"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Storage.Snapshot(parseInt(r.URL.Query().Get("top"), 20)))
+	case http.MethodDelete:
+		s.Storage.Reset()
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleAdminInflight reports the cancellations of running turns and what
+// reconciling them did. POST sweeps orphaned ones right away.
+func (s *Server) handleAdminInflight(w http.ResponseWriter, r *http.Request) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusNotFound, errNotFound("runtime"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Runtime.InflightReport())
+	case http.MethodPost:
+		if _, err := s.Runtime.SweepInflight(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Runtime.InflightReport())
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
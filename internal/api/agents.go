@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// agentListItem is one agent in GET /api/agents.
+type agentListItem struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Title      string    `json:"title,omitempty"`
+	Topics     []string  `json:"topics,omitempty"`
+	Generation int64     `json:"generation,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Loop is set once the agent's loop has crashed.
+	Loop *engine.LoopHealth `json:"loop,omitempty"`
+}
+
+// handleAgents lists the agents the caller can view, with their generated
+// titles and topics when labeling is on.
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Tasks == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"agents": []agentListItem{}})
+		return
+	}
+	agents, err := s.Tasks.List(r.Context(), tasks.ListFilter{Type: "agent", Namespace: requestNamespace(r), Limit: parseInt(r.URL.Query().Get("limit"), 500)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var all map[string]labels.Label
+	if s.Labels != nil {
+		if all, err = s.Labels.All(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	out := make([]agentListItem, 0, len(agents))
+	for _, agent := range agents {
+		level, err := s.accessLevel(r.Context(), agent.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if level < access.LevelView {
+			continue
+		}
+		item := agentListItem{
+			ID:        agent.ID,
+			Status:    string(agent.Status),
+			CreatedAt: agent.CreatedAt,
+			UpdatedAt: agent.UpdatedAt,
+		}
+		if label, ok := all[agent.ID]; ok {
+			item.Title = label.Title
+			item.Topics = label.Topics
+			item.Generation = label.Generation
+		}
+		if s.Runtime != nil {
+			if health, ok := s.Runtime.LoopHealth(agent.ID); ok {
+				item.Loop = &health
+			}
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"agents": out})
+}
+
+func (s *Server) handleAgentItem(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/agents/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		writeError(w, http.StatusNotFound, errNotFound("agent"))
+		return
+	}
+	agentID := segments[0]
+	if len(segments) == 1 {
+		if r.Method != http.MethodPatch {
+			writeMethodNotAllowed(w)
+			return
+		}
+		if !s.agentExists(r, agentID) {
+			writeError(w, http.StatusNotFound, errNotFound("agent"))
+			return
+		}
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		s.handleAgentPatch(w, r, agentID)
+		return
+	}
+	if !s.agentExists(r, agentID) {
+		writeError(w, http.StatusNotFound, errNotFound("agent"))
+		return
+	}
+	need := readOrInteract(r)
+	if segments[1] == "share" || segments[1] == "acl" || segments[1] == "terminate" {
+		need = access.LevelOwner
+	}
+	if !s.requireAccess(w, r, agentID, need) {
+		return
+	}
+
+	switch segments[1] {
+	case "documents":
+		s.handleAgentDocuments(w, r, agentID, segments[2:])
+	case "inbox":
+		s.handleAgentInbox(w, r, agentID, segments[2:])
+	case "history":
+		s.handleAgentHistory(w, r, agentID, segments[2:])
+	case "replay":
+		s.handleAgentReplay(w, r, agentID)
+	case "share":
+		s.handleAgentShare(w, r, agentID)
+	case "subscribe":
+		s.handleAgentSubscribe(w, r, agentID)
+	case "shadow":
+		s.handleAgentShadow(w, r, agentID, segments[2:])
+	case "topics":
+		s.handleAgentTopics(w, r, agentID, segments[2:])
+	case "acl":
+		s.handleAgentACL(w, r, agentID, segments[2:])
+	case "run":
+		s.handleAgentRun(w, r, agentID)
+	case "turns":
+		s.handleAgentTurns(w, r, agentID, segments[2:])
+	case "calendar":
+		s.handleAgentCalendar(w, r, agentID, segments[2:])
+	case "github":
+		s.handleAgentGitHub(w, r, agentID)
+	case "artifacts":
+		s.handleAgentArtifacts(w, r, agentID)
+	case "loop":
+		s.handleAgentLoop(w, r, agentID, segments[2:])
+	case "facts":
+		s.handleAgentFacts(w, r, agentID, segments[2:])
+	case "memories":
+		s.handleAgentMemories(w, r, agentID, segments[2:])
+	case "metrics":
+		s.handleAgentMetrics(w, r, agentID)
+	case "quality":
+		s.handleAgentQuality(w, r, agentID, segments[2:])
+	case "budget":
+		s.handleAgentBudget(w, r, agentID)
+	case "capabilities":
+		s.handleAgentCapabilities(w, r, agentID)
+	case "snooze":
+		s.handleAgentSnooze(w, r, agentID)
+	case "terminate":
+		s.handleAgentTerminate(w, r, agentID)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("agent action"))
+	}
+}
+
+// handleAgentPatch updates an agent's stored settings. {"toolset": {"allow",
+// "deny"}} replaces its tool allow and deny lists from the next turn on; a
+// null toolset offers every tool again. {"preprocessors": [...]} replaces
+// the steps its inbound messages pass through; null removes them.
+// {"budget": {...}} replaces its daily and monthly usage caps; null falls
+// back to the runtime's.
+func (s *Server) handleAgentPatch(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var payload map[string]any
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rawToolset, hasToolset := payload["toolset"]
+	rawSteps, hasSteps := payload["preprocessors"]
+	rawBudget, hasBudget := payload["budget"]
+	if !hasToolset && !hasSteps && !hasBudget {
+		writeError(w, http.StatusBadRequest, errBadRequest("toolset, preprocessors or budget is required"))
+		return
+	}
+	var toolset engine.Toolset
+	var steps []preprocess.Step
+	var agentBudget budget.Budget
+	var err error
+	if hasToolset {
+		if toolset, err = engine.ParseToolset(rawToolset); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if hasSteps {
+		if steps, err = preprocess.ParseSteps(rawSteps); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if hasBudget {
+		if agentBudget, err = budget.Parse(rawBudget); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	resp := map[string]any{"agent_id": agentID}
+	if hasToolset {
+		if toolset, err = s.Runtime.SetAgentToolset(r.Context(), agentID, toolset); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp["toolset"] = toolset
+	}
+	if hasSteps {
+		if steps, err = s.Runtime.SetAgentPreprocessors(r.Context(), agentID, steps); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp["preprocessors"] = steps
+	}
+	if hasBudget {
+		if agentBudget, err = s.Runtime.SetAgentBudget(r.Context(), agentID, agentBudget); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp["budget"] = agentBudget
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) agentExists(r *http.Request, agentID string) bool {
+	if s.Tasks == nil {
+		return false
+	}
+	task, err := s.Tasks.Get(r.Context(), agentID)
+	return err == nil && task.Type == "agent"
+}
+
+// handleAgentSubscribe streams the agent's UI topic: its history entries and
+// progress states, and nothing from other agents.
+func (s *Server) handleAgentSubscribe(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	s.serveEvents(w, r, func(ctx context.Context) <-chan eventbus.Event {
+		return s.Bus.SubscribeTopic(ctx, schema.UITopic(agentID))
+	})
+}
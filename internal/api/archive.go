@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// archiveEventLimit bounds the live events a time-travel query returns
+// alongside archived ones.
+const archiveEventLimit = 1000
+
+// handleAdminArchive serves GET /api/admin/archive, the index of archived
+// objects filtered by ?kind=, ?stream= and ?scope_id=.
+func (s *Server) handleAdminArchive(w http.ResponseWriter, r *http.Request) {
+	if s.Archive == nil {
+		writeError(w, http.StatusNotFound, errNotFound("archive"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	entries, err := s.Archive.Index(r.Context(), archive.Filter{
+		Kind:    query.Get("kind"),
+		Stream:  query.Get("stream"),
+		ScopeID: query.Get("scope_id"),
+		Limit:   parseInt(query.Get("limit"), 100),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleAdminArchiveItem serves POST /api/admin/archive/run,
+// GET /api/admin/archive/events and GET /api/admin/archive/tasks/{id}.
+func (s *Server) handleAdminArchiveItem(w http.ResponseWriter, r *http.Request) {
+	if s.Archive == nil {
+		writeError(w, http.StatusNotFound, errNotFound("archive"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/archive/"), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "run":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		result, err := s.Archive.Run(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case len(parts) == 1 && parts[0] == "events":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		s.handleArchiveEvents(w, r)
+	case len(parts) == 2 && parts[0] == "tasks" && parts[1] != "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		tree, err := s.Archive.TaskTree(r.Context(), parts[1])
+		if errors.Is(err, archive.ErrNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("archived task"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, tree)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("archive action"))
+	}
+}
+
+// handleArchiveEvents answers a time-travel query: a stream's events between
+// ?from= and ?to=, rehydrated from the archive where the database no longer
+// holds them, oldest first. Without ?to= the newest live events are
+// returned.
+func (s *Server) handleArchiveEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := archive.Query{
+		Stream:    strings.TrimSpace(query.Get("stream")),
+		ScopeType: strings.TrimSpace(query.Get("scope_type")),
+		ScopeID:   strings.TrimSpace(query.Get("scope_id")),
+	}
+	if q.Stream == "" {
+		writeError(w, http.StatusBadRequest, errBadRequest("stream is required"))
+		return
+	}
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if raw := strings.TrimSpace(query.Get(param.name)); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, errBadRequest(param.name+" must be RFC 3339"))
+				return
+			}
+			*param.dst = t.UTC()
+		}
+	}
+	archived, err := s.Archive.Events(r.Context(), q)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	var live []eventbus.Event
+	if s.Bus != nil {
+		summaries, err := s.Bus.List(r.Context(), q.Stream, eventbus.ListOptions{
+			ScopeType: q.ScopeType,
+			ScopeID:   q.ScopeID,
+			AllScopes: q.ScopeType == "",
+			Before:    q.To,
+			Order:     "lifo",
+			Limit:     parseInt(query.Get("limit"), archiveEventLimit),
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		ids := make([]string, len(summaries))
+		for i, summary := range summaries {
+			ids[i] = summary.ID
+		}
+		events, err := s.Bus.Read(r.Context(), q.Stream, ids, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, evt := range events {
+			if q.Matches(evt) {
+				live = append(live, evt)
+			}
+		}
+	}
+	events := archive.Merge(archived, live)
+	if events == nil {
+		events = []eventbus.Event{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"stream": q.Stream, "events": events})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+)
+
+// handleArtifactItem serves GET /api/artifacts/<id>: the artifact with its
+// full content as JSON, or only the content as text/plain with ?raw=1.
+// Reading needs view access to the agent the artifact belongs to.
+func (s *Server) handleArtifactItem(w http.ResponseWriter, r *http.Request) {
+	if s.Artifacts == nil {
+		writeError(w, http.StatusNotFound, errNotFound("artifacts"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/artifacts/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, errNotFound("artifact"))
+		return
+	}
+	artifact, err := s.Artifacts.Get(r.Context(), id)
+	if errors.Is(err, artifacts.ErrArtifactNotFound) {
+		writeError(w, http.StatusNotFound, errNotFound("artifact"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !s.requireAccess(w, r, artifact.AgentID, access.LevelView) {
+		return
+	}
+	if r.URL.Query().Get("raw") == "1" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(artifact.Content))
+		return
+	}
+	writeJSON(w, http.StatusOK, artifact)
+}
+
+// handleAgentArtifacts serves GET /api/agents/<id>/artifacts, the agent's
+// artifacts newest first without their content.
+func (s *Server) handleAgentArtifacts(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.Artifacts == nil {
+		writeError(w, http.StatusNotFound, errNotFound("artifacts"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	list, err := s.Artifacts.List(r.Context(), agentID, parseInt(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"artifacts": list})
+}
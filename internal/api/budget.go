@@ -0,0 +1,26 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// handleAgentBudget serves GET /api/agents/<id>/budget: the agent's
+// effective budget, what it used this day and month and, while one is
+// reached, the exhausted cap.
+func (s *Server) handleAgentBudget(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	status, err := s.Runtime.AgentBudgetStatus(r.Context(), agentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
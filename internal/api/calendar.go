@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/calendar"
+)
+
+// handleAgentCalendar serves /api/agents/<id>/calendar. GET returns the
+// account (without credentials) and pending reminders; PUT configures the
+// account and DELETE removes it, both with owner access. GET
+// /calendar/events?from=&to= lists events and DELETE
+// /calendar/reminders/<id> cancels a pending reminder.
+func (s *Server) handleAgentCalendar(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Calendar == nil {
+		writeError(w, http.StatusNotFound, errNotFound("calendar"))
+		return
+	}
+	if len(rest) > 0 && rest[0] != "" {
+		switch rest[0] {
+		case "events":
+			s.handleAgentCalendarEvents(w, r, agentID)
+		case "reminders":
+			s.handleAgentCalendarReminders(w, r, agentID, rest[1:])
+		default:
+			writeError(w, http.StatusNotFound, errNotFound("calendar action"))
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var account *calendar.Account
+		acct, err := s.Calendar.Account(r.Context(), agentID)
+		if err == nil {
+			account = &acct
+		} else if !errors.Is(err, calendar.ErrNotConfigured) {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		reminders, err := s.Calendar.Reminders(r.Context(), agentID, calendar.ReminderPending, parseInt(r.URL.Query().Get("limit"), 50))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"account": account, "reminders": reminders})
+	case http.MethodPut:
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		var payload struct {
+			Provider    string               `json:"provider"`
+			CalendarID  string               `json:"calendar_id"`
+			URL         string               `json:"url"`
+			LeadMinutes int                  `json:"lead_minutes"`
+			Credentials calendar.Credentials `json:"credentials"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		acct, err := s.Calendar.Configure(r.Context(), calendar.Account{
+			AgentID:     agentID,
+			Provider:    payload.Provider,
+			CalendarID:  payload.CalendarID,
+			URL:         payload.URL,
+			LeadMinutes: payload.LeadMinutes,
+		}, payload.Credentials)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, acct)
+	case http.MethodDelete:
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		removed, err := s.Calendar.Remove(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, errNotFound("calendar account"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"removed": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleAgentCalendarEvents(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	from := s.now()
+	if raw := strings.TrimSpace(r.URL.Query().Get("from")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest("from must be RFC 3339"))
+			return
+		}
+		from = t
+	}
+	to := from.AddDate(0, 0, 7)
+	if raw := strings.TrimSpace(r.URL.Query().Get("to")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest("to must be RFC 3339"))
+			return
+		}
+		to = t
+	}
+	events, err := s.Calendar.ListEvents(r.Context(), agentID, from, to)
+	if errors.Is(err, calendar.ErrNotConfigured) {
+		writeError(w, http.StatusNotFound, errNotFound("calendar account"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) handleAgentCalendarReminders(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if len(rest) == 0 || rest[0] == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		list, err := s.Calendar.Reminders(r.Context(), agentID, r.URL.Query().Get("status"), parseInt(r.URL.Query().Get("limit"), 50))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w)
+		return
+	}
+	cancelled, err := s.Calendar.CancelReminder(r.Context(), agentID, rest[0])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !cancelled {
+		writeError(w, http.StatusNotFound, errNotFound("pending reminder"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"cancelled": true})
+}
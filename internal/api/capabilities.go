@@ -0,0 +1,31 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// handleAgentCapabilities serves GET /api/agents/<id>/capabilities so
+// clients can adapt to the agent, for example by hiding image upload for a
+// text-only model.
+func (s *Server) handleAgentCapabilities(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	if !s.agentExists(r, agentID) {
+		writeError(w, http.StatusNotFound, errNotFound("agent"))
+		return
+	}
+	caps, err := s.Runtime.AgentCapabilities(r.Context(), agentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	caps.Inputs.Files = s.Documents != nil
+	writeJSON(w, http.StatusOK, caps)
+}
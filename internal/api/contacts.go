@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/contacts"
+)
+
+// contactsAllowed reports whether the caller may use the contact book. With
+// API keys on it needs admin access, since channels carry handles and
+// webhook URLs.
+func (s *Server) contactsAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if s.Contacts == nil {
+		writeError(w, http.StatusNotFound, errNotFound("contacts"))
+		return false
+	}
+	if principal, ok := principalFrom(r.Context()); s.Access != nil && ok && !principal.Admin {
+		writeError(w, http.StatusForbidden, errors.New("admin access required"))
+		return false
+	}
+	return true
+}
+
+// handleContacts serves GET /api/contacts?q=, which lists or searches the
+// contact book.
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
+	if !s.contactsAllowed(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	list, err := s.Contacts.Search(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"contacts": list})
+}
+
+// handleContactItem serves /api/contacts/<id>: GET returns a contact, PUT
+// creates or replaces it and DELETE removes it.
+func (s *Server) handleContactItem(w http.ResponseWriter, r *http.Request) {
+	if !s.contactsAllowed(w, r) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/contacts/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, errNotFound("contact"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		contact, err := s.Contacts.Get(r.Context(), id)
+		if errors.Is(err, contacts.ErrContactNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("contact"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, contact)
+	case http.MethodPut:
+		var contact contacts.Contact
+		if err := decodeJSON(r.Body, &contact); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if contact.ID != "" && contact.ID != id {
+			writeError(w, http.StatusBadRequest, errBadRequest("id does not match the path"))
+			return
+		}
+		contact.ID = id
+		saved, err := s.Contacts.Put(r.Context(), contact)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		err := s.Contacts.Delete(r.Context(), id)
+		if errors.Is(err, contacts.ErrContactNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("contact"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
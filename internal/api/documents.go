@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/documents"
+)
+
+func (s *Server) handleAgentDocuments(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Documents == nil {
+		writeError(w, http.StatusNotFound, errNotFound("document store"))
+		return
+	}
+	if len(rest) > 0 && rest[0] != "" {
+		if r.Method != http.MethodDelete {
+			writeMethodNotAllowed(w)
+			return
+		}
+		removed, err := s.Documents.Delete(r.Context(), agentID, rest[0])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, errNotFound("document"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		docs, err := s.Documents.List(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, docs)
+	case http.MethodPost:
+		var payload struct {
+			Title       string `json:"title"`
+			Content     string `json:"content"`
+			TokenBudget int    `json:"token_budget"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		doc, err := s.Documents.Add(r.Context(), documents.Input{
+			AgentID:     agentID,
+			Title:       payload.Title,
+			Content:     payload.Content,
+			TokenBudget: payload.TokenBudget,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, doc)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
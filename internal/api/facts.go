@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/facts"
+)
+
+// handleAgentFacts serves /api/agents/<id>/facts. GET lists the agent's
+// pinned facts and POST {"text": "..."} pins one; PUT
+// /facts/<fact_id> {"text": "..."} edits a fact and DELETE removes it.
+func (s *Server) handleAgentFacts(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Facts == nil {
+		writeError(w, http.StatusNotFound, errNotFound("facts"))
+		return
+	}
+	if len(rest) > 0 && rest[0] != "" {
+		if len(rest) > 1 {
+			writeError(w, http.StatusNotFound, errNotFound("fact"))
+			return
+		}
+		s.handleAgentFact(w, r, agentID, rest[0])
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.Facts.List(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"facts": list})
+	case http.MethodPost:
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		fact, added, err := s.Facts.Add(r.Context(), facts.Fact{AgentID: agentID, Text: payload.Text, Source: facts.SourceOperator})
+		if errors.Is(err, facts.ErrFactsFull) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		status := http.StatusOK
+		if added {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, fact)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleAgentFact(w http.ResponseWriter, r *http.Request, agentID, factID string) {
+	switch r.Method {
+	case http.MethodPut:
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		fact, err := s.Facts.Update(r.Context(), agentID, factID, payload.Text, facts.SourceOperator)
+		if errors.Is(err, facts.ErrFactNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("fact"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fact)
+	case http.MethodDelete:
+		err := s.Facts.Delete(r.Context(), agentID, factID)
+		if errors.Is(err, facts.ErrFactNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("fact"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"deleted": factID})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/federation"
+)
+
+// maxFederatedMessageBytes bounds inbound federated message bodies.
+const maxFederatedMessageBytes = 1 << 20
+
+// handleFederationInbound accepts a message from a peer. It skips API key
+// checks because peers sign each request with their shared secret.
+func (s *Server) handleFederationInbound(w http.ResponseWriter, r *http.Request) {
+	if s.Federation == nil {
+		writeError(w, http.StatusNotFound, errNotFound("federation"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFederatedMessageBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(body) > maxFederatedMessageBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("message too large"))
+		return
+	}
+	receipt, err := s.Federation.Receive(r.Context(), r.Header, body)
+	var inbound *federation.InboundError
+	if errors.As(err, &inbound) {
+		writeError(w, inbound.Status, inbound.Err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+// handleFederationMessages lists outbound federated messages and their
+// delivery state, newest first.
+func (s *Server) handleFederationMessages(w http.ResponseWriter, r *http.Request) {
+	if s.Federation == nil {
+		writeError(w, http.StatusNotFound, errNotFound("federation"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	messages, err := s.Federation.Messages(r.Context(), r.URL.Query().Get("status"), parseInt(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":     s.Federation.Name(),
+		"peers":    s.Federation.Peers(),
+		"messages": messages,
+	})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/quality"
+)
+
+// handleFineTune serves GET /api/finetune, the completed turns of the
+// agents in ?agents= (comma separated) as a fine-tuning dataset in JSON
+// lines. ?format= is openai (the default) or anthropic. ?rating=,
+// ?outcome=, ?from= and ?to= narrow the turns. ?validation= is the share of
+// turns held out for validation and ?split= picks train or validation.
+// PII is redacted unless ?redact=none; the configured rules always apply.
+func (s *Server) handleFineTune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	query := r.URL.Query()
+	var agents []string
+	for _, id := range strings.Split(query.Get("agents"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			agents = append(agents, id)
+		}
+	}
+	if len(agents) == 0 {
+		writeError(w, http.StatusBadRequest, errBadRequest("agents is required"))
+		return
+	}
+	format := strings.TrimSpace(query.Get("format"))
+	if format == "" {
+		format = finetune.OpenAI
+	}
+	if !finetune.ValidFormat(format) {
+		writeError(w, http.StatusBadRequest, errBadRequest("format must be openai or anthropic"))
+		return
+	}
+	filter := engine.FineTuneFilter{}
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		t, err := parseUsageTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest(param.name+" must be RFC 3339 or a date"))
+			return
+		}
+		*param.dst = t
+	}
+	if raw := strings.TrimSpace(query.Get("rating")); raw != "" {
+		rating, err := quality.ParseRating(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest(err.Error()))
+			return
+		}
+		filter.Rating = rating
+	}
+	if outcome := strings.TrimSpace(query.Get("outcome")); outcome != "" {
+		if !quality.ValidOutcome(outcome) {
+			writeError(w, http.StatusBadRequest, errBadRequest("unknown outcome "+strconv.Quote(outcome)))
+			return
+		}
+		filter.Outcome = outcome
+	}
+	if (filter.Rating != "" || filter.Outcome != "") && s.Quality == nil {
+		writeError(w, http.StatusNotFound, errNotFound("quality"))
+		return
+	}
+	validation := 0.0
+	if raw := strings.TrimSpace(query.Get("validation")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || v >= 1 {
+			writeError(w, http.StatusBadRequest, errBadRequest("validation must be a fraction from 0 up to 1"))
+			return
+		}
+		validation = v
+	}
+	split := strings.TrimSpace(query.Get("split"))
+	if split != "" && split != finetune.Train && split != finetune.Validation {
+		writeError(w, http.StatusBadRequest, errBadRequest("split must be train or validation"))
+		return
+	}
+	var redactors []finetune.Redactor
+	switch redact := strings.TrimSpace(query.Get("redact")); redact {
+	case "", "pii":
+		redactors = append(redactors, finetune.RedactPII)
+	case "none":
+	default:
+		writeError(w, http.StatusBadRequest, errBadRequest("redact must be pii or none"))
+		return
+	}
+	redactors = append(redactors, s.FineTuneRedactors...)
+
+	var examples []finetune.Example
+	for _, agentID := range agents {
+		if !s.requireAccess(w, r, agentID, access.LevelView) {
+			return
+		}
+		turns, err := s.Runtime.FineTuneExamples(r.Context(), agentID, filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, ex := range turns {
+			if split != "" && finetune.SplitOf(ex.ID, validation) != split {
+				continue
+			}
+			examples = append(examples, finetune.Redact(ex, redactors...))
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	_ = finetune.Encode(w, format, examples)
+}
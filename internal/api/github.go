@@ -0,0 +1,115 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/github"
+)
+
+// maxGitHubWebhookBytes is GitHub's own cap on webhook payloads.
+const maxGitHubWebhookBytes = 25 << 20
+
+// handleGitHubWebhook receives GitHub webhooks. It skips API key checks
+// because each delivery is signed with the webhook secret of the agents
+// watching the repository.
+func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.GitHub == nil {
+		writeError(w, http.StatusNotFound, errNotFound("github"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxGitHubWebhookBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(body) > maxGitHubWebhookBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("webhook too large"))
+		return
+	}
+	result, err := s.GitHub.HandleWebhook(r.Context(), github.Webhook{
+		Event:      r.Header.Get("X-GitHub-Event"),
+		DeliveryID: r.Header.Get("X-GitHub-Delivery"),
+		Signature:  r.Header.Get("X-Hub-Signature-256"),
+		Body:       body,
+	})
+	if errors.Is(err, github.ErrBadSignature) {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil && len(result.Delivered) == 0 {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleAgentGitHub serves /api/agents/<id>/github. GET returns the account
+// without credentials; PUT configures it and DELETE removes it, both with
+// owner access.
+func (s *Server) handleAgentGitHub(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.GitHub == nil {
+		writeError(w, http.StatusNotFound, errNotFound("github"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		acct, err := s.GitHub.Account(r.Context(), agentID)
+		if errors.Is(err, github.ErrNotConfigured) {
+			writeError(w, http.StatusNotFound, errNotFound("github account"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, acct)
+	case http.MethodPut:
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		var payload struct {
+			Repos       []string           `json:"repos"`
+			Events      []string           `json:"events"`
+			Priorities  map[string]string  `json:"priorities"`
+			Credentials github.Credentials `json:"credentials"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		acct, err := s.GitHub.Configure(r.Context(), github.Account{
+			AgentID:    agentID,
+			Repos:      payload.Repos,
+			Events:     payload.Events,
+			Priorities: payload.Priorities,
+		}, payload.Credentials)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, acct)
+	case http.MethodDelete:
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		removed, err := s.GitHub.Remove(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, errNotFound("github account"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"removed": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/health"
+)
+
+// handleHealth is the readiness probe: 200 while ok or degraded, 503 once a
+// critical dependency is failing.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+	report := health.Report{Status: health.StatusOK, Checks: []health.Result{}, CheckedAt: s.now()}
+	if s.Health != nil {
+		report = s.Health.Run(r.Context())
+	}
+	status := http.StatusOK
+	if report.Status == health.StatusFailing {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// handleHealthLive is the liveness probe; it only shows the server answers.
+func (s *Server) handleHealthLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": health.StatusOK})
+}
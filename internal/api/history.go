@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleAgentHistory serves GET /api/agents/{id}/history/verify and
+// POST /api/agents/{id}/history/repair.
+func (s *Server) handleAgentHistory(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("runtime"))
+		return
+	}
+	if len(rest) != 1 {
+		writeError(w, http.StatusNotFound, errNotFound("history action"))
+		return
+	}
+	switch rest[0] {
+	case "verify":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		report, err := s.Runtime.VerifyHistory(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	case "repair":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		report, err := s.Runtime.RepairHistory(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("history action"))
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/engine"
+)
+
+// handleAgentInbox serves POST /api/agents/{id}/inbox/clear.
+func (s *Server) handleAgentInbox(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if len(rest) != 1 || rest[0] != "clear" {
+		writeError(w, http.StatusNotFound, errNotFound("inbox action"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("runtime"))
+		return
+	}
+	var payload struct {
+		Kind      string `json:"kind"`
+		Source    string `json:"source"`
+		OlderThan string `json:"older_than"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	filter := engine.InboxFilter{Kind: payload.Kind, Source: payload.Source}
+	if raw := strings.TrimSpace(payload.OlderThan); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, errBadRequest("older_than must be a duration like 10m"))
+			return
+		}
+		filter.OlderThan = d
+	}
+	result, err := s.Runtime.ClearInbox(r.Context(), agentID, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/engine"
+)
+
+// handleAgentLoop serves GET /api/agents/<id>/loop, the crash history of the
+// agent's loop (state "running" with no crashes when it never crashed), and
+// POST /api/agents/<id>/loop/reset, which clears it and restarts a halted
+// or stopped loop.
+func (s *Server) handleAgentLoop(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	switch {
+	case len(rest) == 0 || rest[0] == "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		health, ok := s.Runtime.LoopHealth(agentID)
+		if !ok {
+			health = engine.LoopHealth{AgentID: agentID, State: engine.LoopRunning}
+		}
+		writeJSON(w, http.StatusOK, health)
+	case len(rest) == 1 && rest[0] == "reset":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		s.Runtime.ResetAgentLoop(agentID)
+		writeJSON(w, http.StatusOK, engine.LoopHealth{AgentID: agentID, State: engine.LoopRunning})
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("loop action"))
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/maintenance"
+)
+
+// handleMaintenance lists and creates maintenance windows. Windows without an
+// agent_id apply to every agent.
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if s.Maintenance == nil {
+		writeError(w, http.StatusNotFound, errNotFound("maintenance store"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		windows, err := s.Maintenance.List(r.Context(), r.URL.Query().Get("all") == "1")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if windows == nil {
+			windows = []maintenance.Window{}
+		}
+		writeJSON(w, http.StatusOK, windows)
+	case http.MethodPost:
+		var payload struct {
+			AgentID         string    `json:"agent_id"`
+			Reason          string    `json:"reason"`
+			StartsAt        time.Time `json:"starts_at"`
+			EndsAt          time.Time `json:"ends_at"`
+			DurationSeconds int       `json:"duration_seconds"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		window, err := s.Maintenance.Create(r.Context(), maintenance.Input{
+			AgentID:  payload.AgentID,
+			Reason:   payload.Reason,
+			StartsAt: payload.StartsAt,
+			EndsAt:   payload.EndsAt,
+			Duration: time.Duration(payload.DurationSeconds) * time.Second,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, window)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleMaintenanceItem ends a window early on DELETE.
+func (s *Server) handleMaintenanceItem(w http.ResponseWriter, r *http.Request) {
+	if s.Maintenance == nil {
+		writeError(w, http.StatusNotFound, errNotFound("maintenance store"))
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/maintenance/"), "/")
+	if id == "" {
+		writeError(w, http.StatusNotFound, errNotFound("maintenance window"))
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w)
+		return
+	}
+	window, ok, err := s.Maintenance.End(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound("maintenance window"))
+		return
+	}
+	writeJSON(w, http.StatusOK, window)
+}
@@ -0,0 +1,15 @@
+package api
+
+import "net/http"
+
+// handleMCP serves /api/mcp, the streamable HTTP endpoint of agentd's MCP
+// server. It exists only when agentd runs with --mcp=sse, and only admins
+// may use it once API keys are configured, since its tools reach every
+// agent.
+func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
+	if s.MCP == nil {
+		writeError(w, http.StatusNotFound, errNotFound("mcp server"))
+		return
+	}
+	s.MCP.ServeHTTP(w, r)
+}
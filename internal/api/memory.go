@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/memory"
+)
+
+// memoryInput is the body of POST /api/agents/<id>/memories.
+type memoryInput struct {
+	Key  string   `json:"key,omitempty"`
+	Text string   `json:"text"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// handleAgentMemories serves /api/agents/<id>/memories. GET lists the
+// agent's memories newest first, or with ?q= recalls the best matches up to
+// ?limit=; ?tag= (repeatable) keeps memories with every tag given. POST
+// {"key", "text", "tags"} saves one. GET and DELETE /memories/<key> show
+// and forget a memory.
+func (s *Server) handleAgentMemories(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Memory == nil {
+		writeError(w, http.StatusNotFound, errNotFound("memory"))
+		return
+	}
+	if len(rest) > 0 && rest[0] != "" {
+		if len(rest) > 1 {
+			writeError(w, http.StatusNotFound, errNotFound("memory"))
+			return
+		}
+		s.handleAgentMemory(w, r, agentID, rest[0])
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		q := memory.Query{Text: strings.TrimSpace(query.Get("q")), Tags: query["tag"]}
+		if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+			limit, err := strconv.Atoi(raw)
+			if err != nil || limit <= 0 {
+				writeError(w, http.StatusBadRequest, errBadRequest("limit must be a positive integer"))
+				return
+			}
+			q.Limit = limit
+		}
+		var list []memory.Memory
+		var err error
+		if q.Text == "" {
+			list, err = s.Memory.List(r.Context(), agentID, q.Tags...)
+		} else {
+			list, err = s.Memory.Recall(r.Context(), agentID, q)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"memories": list})
+	case http.MethodPost:
+		var payload memoryInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		m, created, err := s.Memory.Remember(r.Context(), memory.Memory{AgentID: agentID, Key: payload.Key, Text: payload.Text, Tags: payload.Tags})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, m)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleAgentMemory(w http.ResponseWriter, r *http.Request, agentID, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		m, err := s.Memory.Get(r.Context(), agentID, key)
+		if errors.Is(err, memory.ErrMemoryNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("memory"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	case http.MethodDelete:
+		err := s.Memory.Forget(r.Context(), agentID, key)
+		if errors.Is(err, memory.ErrMemoryNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("memory"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"deleted": key})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// handleAgentMetrics serves GET /api/agents/<id>/metrics, the same snapshot
+// opted-in agents receive as metrics_snapshot signals.
+func (s *Server) handleAgentMetrics(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	snapshot, err := s.Runtime.AgentMetrics(r.Context(), agentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
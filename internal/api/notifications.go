@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// localUser owns devices and preferences when the API runs without keys.
+const localUser = "local"
+
+// notifyUser is the user that notification devices and preferences belong
+// to: the caller's principal, or localUser on an open API.
+func notifyUser(r *http.Request) string {
+	if principal, ok := principalFrom(r.Context()); ok {
+		return principal.ID
+	}
+	return localUser
+}
+
+// handleNotifications describes what can be registered: the configured
+// platforms, the alert kinds and the VAPID key browsers subscribe with.
+func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	if s.Notifications == nil {
+		writeError(w, http.StatusNotFound, errNotFound("notifications"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	platforms := make([]string, 0, len(s.Pushers))
+	for platform := range s.Pushers {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	resp := map[string]any{
+		"platforms": platforms,
+		"kinds":     schema.AlertKinds,
+	}
+	if webPush, ok := s.Pushers[notify.PlatformWebPush].(*notify.WebPush); ok {
+		if key, err := webPush.PublicKey(); err == nil {
+			resp["vapid_public_key"] = key
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleNotificationItem(w http.ResponseWriter, r *http.Request) {
+	if s.Notifications == nil {
+		writeError(w, http.StatusNotFound, errNotFound("notifications"))
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/notifications/"), "/")
+	resource, id, _ := strings.Cut(rest, "/")
+	switch {
+	case resource == "devices" && id == "":
+		s.handleNotificationDevices(w, r)
+	case resource == "devices":
+		if r.Method != http.MethodDelete {
+			writeMethodNotAllowed(w)
+			return
+		}
+		err := s.Notifications.DeleteDevice(r.Context(), notifyUser(r), id)
+		if errors.Is(err, notify.ErrDeviceNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("device"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	case resource == "preferences" && id == "":
+		s.handleNotificationPreferences(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("notification resource"))
+	}
+}
+
+// handleNotificationDevices lists and registers the caller's devices. Web
+// push devices send the browser's PushSubscription as subscription; APNs
+// and FCM devices send their token.
+func (s *Server) handleNotificationDevices(w http.ResponseWriter, r *http.Request) {
+	user := notifyUser(r)
+	switch r.Method {
+	case http.MethodGet:
+		devices, err := s.Notifications.Devices(r.Context(), user)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, devices)
+	case http.MethodPost:
+		var payload struct {
+			Platform     string `json:"platform"`
+			Token        string `json:"token"`
+			Name         string `json:"name"`
+			Subscription *struct {
+				Endpoint string `json:"endpoint"`
+				Keys     struct {
+					P256DH string `json:"p256dh"`
+					Auth   string `json:"auth"`
+				} `json:"keys"`
+			} `json:"subscription"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		device := notify.Device{
+			UserID:   user,
+			Platform: strings.ToLower(strings.TrimSpace(payload.Platform)),
+			Token:    payload.Token,
+			Name:     payload.Name,
+		}
+		if payload.Subscription != nil {
+			if device.Platform == "" {
+				device.Platform = notify.PlatformWebPush
+			}
+			device.Token = payload.Subscription.Endpoint
+			device.P256DH = payload.Subscription.Keys.P256DH
+			device.Auth = payload.Subscription.Keys.Auth
+		}
+		if _, ok := s.Pushers[device.Platform]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("push platform %q is not configured", device.Platform))
+			return
+		}
+		device, err := s.Notifications.RegisterDevice(r.Context(), device)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, device)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleNotificationPreferences reads or replaces the alert kinds the caller
+// is notified about.
+func (s *Server) handleNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user := notifyUser(r)
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := s.Notifications.Preferences(r.Context(), user)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	case http.MethodPut:
+		var payload struct {
+			Kinds    *[]string `json:"kinds"`
+			Disabled bool      `json:"disabled"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		kinds := slices.Clone(schema.AlertKinds)
+		if payload.Kinds != nil {
+			kinds = *payload.Kinds
+		}
+		prefs, err := s.Notifications.SetPreferences(r.Context(), notify.Preferences{
+			UserID:   user,
+			Kinds:    kinds,
+			Disabled: payload.Disabled,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
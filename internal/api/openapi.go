@@ -0,0 +1,809 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/calendar"
+	"github.com/flitsinc/go-agents/internal/contacts"
+	"github.com/flitsinc/go-agents/internal/documents"
+	"github.com/flitsinc/go-agents/internal/egress"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/facts"
+	"github.com/flitsinc/go-agents/internal/federation"
+	"github.com/flitsinc/go-agents/internal/github"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/health"
+	"github.com/flitsinc/go-agents/internal/maintenance"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/replay"
+	"github.com/flitsinc/go-agents/internal/scheduler"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/teams"
+	"github.com/flitsinc/go-agents/internal/templates"
+	"github.com/flitsinc/go-agents/internal/topics"
+)
+
+// operation documents one method on one path of the HTTP API. Bodies and
+// results are sample Go values whose types are turned into JSON schemas, so
+// the document follows the types the handlers encode and decode.
+type operation struct {
+	Method string
+	// Path is relative to the versioned base URL, such as
+	// /tasks/{id}/cancel; {name} segments are path parameters.
+	Path    string
+	Tag     string
+	Summary string
+	// Query lists the optional string query parameters.
+	Query []string
+	// Body is a sample request body, or nil for none.
+	Body any
+	// Result is a sample response body; nil means no JSON body.
+	Result any
+	// Status is the success status; zero means 200.
+	Status int
+	// ContentType is the response media type when it is not JSON.
+	ContentType string
+}
+
+type okResult struct {
+	OK bool `json:"ok"`
+}
+
+type reasonInput struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type textInput struct {
+	Text string `json:"text"`
+}
+
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+// operations lists every operation the server routes. TestOpenAPICoversRoutes
+// checks it against routes.
+var operations = []operation{
+	{Method: "GET", Path: "/versions", Tag: "meta", Summary: "List supported API versions and deprecations",
+		Result: struct {
+			Current          int    `json:"current"`
+			Supported        []int  `json:"supported"`
+			Prefix           string `json:"prefix"`
+			UnversionedPaths struct {
+				Deprecated bool       `json:"deprecated"`
+				Sunset     *time.Time `json:"sunset,omitempty"`
+			} `json:"unversioned_paths"`
+			Deprecations []Deprecation `json:"deprecations"`
+		}{}},
+	{Method: "GET", Path: "/openapi.json", Tag: "meta", Summary: "This OpenAPI document", Result: map[string]any{}},
+	{Method: "GET", Path: "/docs", Tag: "meta", Summary: "Swagger UI for this document", ContentType: "text/html"},
+	{Method: "GET", Path: "/health", Tag: "meta", Summary: "Readiness probe; 503 once a critical dependency fails", Result: health.Report{}},
+	{Method: "GET", Path: "/health/live", Tag: "meta", Summary: "Liveness probe", Result: struct {
+		Status health.Status `json:"status"`
+	}{}},
+
+	{Method: "POST", Path: "/tasks", Tag: "tasks", Summary: "Create a task, or re-apply an existing agent's config when id is taken",
+		Status: http.StatusAccepted,
+		Body: struct {
+			ID       string          `json:"id,omitempty"`
+			Type     string          `json:"type"`
+			Payload  map[string]any  `json:"payload,omitempty"`
+			Source   string          `json:"source,omitempty"`
+			Priority string          `json:"priority,omitempty"`
+			Callback *tasks.Callback `json:"callback,omitempty"`
+		}{},
+		Result: struct {
+			TaskID         string `json:"task_id"`
+			Status         string `json:"status"`
+			Type           string `json:"type"`
+			Created        bool   `json:"created"`
+			CallbackSecret string `json:"callback_secret,omitempty"`
+		}{}},
+	{Method: "GET", Path: "/tasks/queue", Tag: "tasks", Summary: "Claim queued tasks", Query: []string{"type", "limit"}, Result: []tasks.Task{}},
+	{Method: "GET", Path: "/tasks/queue/depths", Tag: "tasks", Summary: "Count queued tasks per priority", Query: []string{"type"},
+		Result: struct {
+			Type   string         `json:"type"`
+			Depths map[string]int `json:"depths"`
+		}{}},
+	{Method: "GET", Path: "/tasks/{id}/updates", Tag: "tasks", Summary: "List a task's updates", Query: []string{"kind", "after_id", "limit"}, Result: []tasks.Update{}},
+	{Method: "POST", Path: "/tasks/{id}/updates", Tag: "tasks", Summary: "Record a task update",
+		Body: struct {
+			Kind    string         `json:"kind"`
+			Payload map[string]any `json:"payload,omitempty"`
+		}{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/complete", Tag: "tasks", Summary: "Complete a task",
+		Body: struct {
+			Result map[string]any `json:"result"`
+		}{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/fail", Tag: "tasks", Summary: "Fail a task, optionally for exceeding a resource limit",
+		Body: struct {
+			Error string         `json:"error"`
+			Limit string         `json:"limit,omitempty"`
+			Usage map[string]any `json:"usage,omitempty"`
+		}{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/send", Tag: "tasks", Summary: "Send a message to an agent or input to a task",
+		Body: struct {
+			agentMessageInput
+			Input map[string]any `json:"input,omitempty"`
+		}{}, Result: messageResult{}},
+	{Method: "POST", Path: "/tasks/{id}/assistant_output", Tag: "tasks", Summary: "Post assistant output on behalf of a task",
+		Body: struct {
+			Text       string         `json:"text"`
+			Source     string         `json:"source,omitempty"`
+			FromTaskID string         `json:"from_task_id,omitempty"`
+			RequestID  string         `json:"request_id,omitempty"`
+			ServiceID  string         `json:"service_id,omitempty"`
+			Context    map[string]any `json:"context,omitempty"`
+			Parts      []schema.Part  `json:"parts,omitempty"`
+		}{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/cancel", Tag: "tasks", Summary: "Cancel a task", Body: reasonInput{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/kill", Tag: "tasks", Summary: "Kill a task and its running work", Body: reasonInput{}, Result: okResult{}},
+	{Method: "POST", Path: "/tasks/{id}/priority", Tag: "tasks", Summary: "Change a queued task's priority and labels",
+		Body: struct {
+			Priority string         `json:"priority,omitempty"`
+			Labels   map[string]any `json:"labels,omitempty"`
+		}{}, Result: tasks.Task{}},
+	{Method: "POST", Path: "/tasks/{id}/compact", Tag: "tasks", Summary: "Compact an agent's context", Status: http.StatusAccepted,
+		Body: reasonInput{}, Result: struct {
+			Status     string `json:"status"`
+			TaskID     string `json:"task_id"`
+			Generation int64  `json:"generation"`
+		}{}},
+	{Method: "GET", Path: "/tasks/{id}/questions", Tag: "tasks", Summary: "List questions the agent asked", Query: []string{"status", "limit"}, Result: []questions.Question{}},
+	{Method: "GET", Path: "/tasks/{id}/questions/{question_id}", Tag: "tasks", Summary: "Get a question", Result: questions.Question{}},
+	{Method: "DELETE", Path: "/tasks/{id}/questions/{question_id}", Tag: "tasks", Summary: "Cancel a pending question", Result: questions.Question{}},
+
+	{Method: "GET", Path: "/agents", Tag: "agents", Summary: "List the agents the caller can view", Query: []string{"limit"},
+		Result: struct {
+			Agents []agentListItem `json:"agents"`
+		}{}},
+	{Method: "PATCH", Path: "/agents/{id}", Tag: "agents", Summary: "Set an agent's tool lists, inbound preprocessors and budget",
+		Body: struct {
+			Toolset       *engine.Toolset   `json:"toolset,omitempty"`
+			Preprocessors []preprocess.Step `json:"preprocessors,omitempty"`
+			Budget        *budget.Budget    `json:"budget,omitempty"`
+		}{}, Result: struct {
+			AgentID       string            `json:"agent_id"`
+			Toolset       *engine.Toolset   `json:"toolset,omitempty"`
+			Preprocessors []preprocess.Step `json:"preprocessors,omitempty"`
+			Budget        *budget.Budget    `json:"budget,omitempty"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/documents", Tag: "agents", Summary: "List an agent's documents", Result: []documents.Document{}},
+	{Method: "POST", Path: "/agents/{id}/documents", Tag: "agents", Summary: "Add a document", Status: http.StatusCreated,
+		Body: struct {
+			Title       string `json:"title"`
+			Content     string `json:"content"`
+			TokenBudget int    `json:"token_budget,omitempty"`
+		}{}, Result: documents.Document{}},
+	{Method: "DELETE", Path: "/agents/{id}/documents/{document_id}", Tag: "agents", Summary: "Remove a document", Result: okResult{}},
+	{Method: "POST", Path: "/agents/{id}/inbox/clear", Tag: "agents", Summary: "Mark matching unread inbox messages read",
+		Body: struct {
+			Kind      string `json:"kind,omitempty"`
+			Source    string `json:"source,omitempty"`
+			OlderThan string `json:"older_than,omitempty"`
+		}{}, Result: engine.InboxClearResult{}},
+	{Method: "GET", Path: "/agents/{id}/history/verify", Tag: "agents", Summary: "Check the agent's history for gaps", Result: engine.HistoryReport{}},
+	{Method: "POST", Path: "/agents/{id}/history/repair", Tag: "agents", Summary: "Repair the agent's history", Result: engine.HistoryReport{}},
+	{Method: "POST", Path: "/agents/{id}/replay", Tag: "agents", Summary: "Replay events against a sandbox copy of the agent",
+		Body: struct {
+			Events []eventbus.Event `json:"events"`
+			System string           `json:"system,omitempty"`
+			Model  string           `json:"model,omitempty"`
+		}{}, Result: replay.Transcript{}},
+	{Method: "POST", Path: "/agents/{id}/share", Tag: "agents", Summary: "Create a signed read-only transcript link", Status: http.StatusCreated,
+		Body: struct {
+			From       string `json:"from,omitempty"`
+			To         string `json:"to,omitempty"`
+			TTLSeconds int    `json:"ttl_seconds,omitempty"`
+		}{}, Result: struct {
+			Token     string    `json:"token"`
+			URL       string    `json:"url"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/subscribe", Tag: "agents", Summary: "Server-sent events for the agent's streams", ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/agents/{id}/shadow", Tag: "agents", Summary: "List shadow trials and the active trial's runs",
+		Result: struct {
+			Trials []shadow.Trial `json:"trials"`
+			Runs   []shadow.Run   `json:"runs"`
+			Active *shadow.Trial  `json:"active"`
+		}{}},
+	{Method: "POST", Path: "/agents/{id}/shadow", Tag: "agents", Summary: "Start a shadow trial of another prompt or model", Status: http.StatusCreated,
+		Body: struct {
+			System          string `json:"system,omitempty"`
+			Model           string `json:"model,omitempty"`
+			DurationSeconds int    `json:"duration_seconds,omitempty"`
+		}{}, Result: shadow.Trial{}},
+	{Method: "DELETE", Path: "/agents/{id}/shadow", Tag: "agents", Summary: "Stop the active shadow trial", Result: shadow.Trial{}},
+	{Method: "POST", Path: "/agents/{id}/shadow/promote", Tag: "agents", Summary: "Adopt the active trial's prompt and model", Result: shadow.Trial{}},
+	{Method: "GET", Path: "/agents/{id}/topics", Tag: "agents", Summary: "List the agent's topic subscriptions", Result: []topics.Subscription{}},
+	{Method: "PUT", Path: "/agents/{id}/topics/{topic}", Tag: "agents", Summary: "Subscribe the agent to a topic", Result: topics.Subscription{}},
+	{Method: "DELETE", Path: "/agents/{id}/topics/{topic}", Tag: "agents", Summary: "Unsubscribe the agent from a topic", Result: okResult{}},
+	{Method: "GET", Path: "/agents/{id}/acl", Tag: "agents", Summary: "List access grants on the agent",
+		Result: struct {
+			AgentID string         `json:"agent_id"`
+			Grants  []access.Grant `json:"grants"`
+		}{}},
+	{Method: "POST", Path: "/agents/{id}/acl", Tag: "agents", Summary: "Grant a principal access to the agent",
+		Body: struct {
+			Principal string `json:"principal"`
+			Level     string `json:"level"`
+		}{}, Result: okResult{}},
+	{Method: "DELETE", Path: "/agents/{id}/acl/{principal}", Tag: "agents", Summary: "Revoke a principal's grant", Result: okResult{}},
+	{Method: "POST", Path: "/agents/{id}/run", Tag: "agents", Summary: "Send a message, optionally with a model override for its turn",
+		Body: struct {
+			agentMessageInput
+			Model    string `json:"model,omitempty"`
+			Provider string `json:"provider,omitempty"`
+		}{}, Result: struct {
+			messageResult
+			ModelOverride *engine.ModelOverride `json:"model_override,omitempty"`
+		}{}},
+	{Method: "POST", Path: "/agents/{id}/turns/{llm_task_id}/undo", Tag: "agents", Summary: "Retract a turn from the conversation",
+		Body: reasonInput{}, Result: engine.TurnUndo{}},
+	{Method: "GET", Path: "/agents/{id}/turns/{llm_task_id}/prompt", Tag: "agents", Summary: "Show the prompt a turn was sent", Result: engine.TurnPrompt{}},
+	{Method: "POST", Path: "/agents/{id}/turns/{llm_task_id}/feedback", Tag: "agents", Summary: "Rate a turn thumbs up or down",
+		Body: feedbackInput{}, Result: quality.Feedback{}},
+	{Method: "GET", Path: "/agents/{id}/turns/diff", Tag: "agents", Summary: "Diff the prompts of two turns", Query: []string{"from", "to"}, Result: engine.PromptDiff{}},
+	{Method: "GET", Path: "/agents/{id}/calendar", Tag: "calendar", Summary: "Show the agent's calendar account and reminders",
+		Result: struct {
+			Account   calendar.Account    `json:"account"`
+			Reminders []calendar.Reminder `json:"reminders"`
+		}{}},
+	{Method: "PUT", Path: "/agents/{id}/calendar", Tag: "calendar", Summary: "Connect a calendar",
+		Body: struct {
+			Provider    string               `json:"provider"`
+			CalendarID  string               `json:"calendar_id,omitempty"`
+			URL         string               `json:"url,omitempty"`
+			LeadMinutes int                  `json:"lead_minutes,omitempty"`
+			Credentials calendar.Credentials `json:"credentials"`
+		}{}, Result: calendar.Account{}},
+	{Method: "DELETE", Path: "/agents/{id}/calendar", Tag: "calendar", Summary: "Disconnect the calendar",
+		Result: struct {
+			Removed bool `json:"removed"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/calendar/events", Tag: "calendar", Summary: "List calendar events", Query: []string{"from", "to"}, Result: []calendar.Event{}},
+	{Method: "GET", Path: "/agents/{id}/calendar/reminders", Tag: "calendar", Summary: "List reminders", Query: []string{"status", "limit"}, Result: []calendar.Reminder{}},
+	{Method: "DELETE", Path: "/agents/{id}/calendar/reminders/{reminder_id}", Tag: "calendar", Summary: "Cancel a reminder",
+		Result: struct {
+			Cancelled bool `json:"cancelled"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/github", Tag: "github", Summary: "Show the agent's GitHub account", Result: github.Account{}},
+	{Method: "PUT", Path: "/agents/{id}/github", Tag: "github", Summary: "Watch GitHub repositories",
+		Body: struct {
+			Repos       []string           `json:"repos"`
+			Events      []string           `json:"events,omitempty"`
+			Priorities  map[string]string  `json:"priorities,omitempty"`
+			Credentials github.Credentials `json:"credentials"`
+		}{}, Result: github.Account{}},
+	{Method: "DELETE", Path: "/agents/{id}/github", Tag: "github", Summary: "Stop watching GitHub",
+		Result: struct {
+			Removed bool `json:"removed"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/artifacts", Tag: "agents", Summary: "List the agent's artifacts", Query: []string{"limit"},
+		Result: struct {
+			Artifacts []artifacts.Artifact `json:"artifacts"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/loop", Tag: "agents", Summary: "Show the agent loop's crash state", Result: engine.LoopHealth{}},
+	{Method: "POST", Path: "/agents/{id}/loop/reset", Tag: "agents", Summary: "Restart a crashed or stopped agent loop", Result: engine.LoopHealth{}},
+	{Method: "POST", Path: "/agents/{id}/terminate", Tag: "agents", Summary: "Stop the agent, its turns and all its descendant tasks",
+		Body: struct {
+			Reason string `json:"reason,omitempty"`
+			DryRun bool   `json:"dry_run,omitempty"`
+		}{}, Result: engine.TerminateReport{}},
+	{Method: "GET", Path: "/agents/{id}/facts", Tag: "agents", Summary: "List pinned facts",
+		Result: struct {
+			Facts []facts.Fact `json:"facts"`
+		}{}},
+	{Method: "POST", Path: "/agents/{id}/facts", Tag: "agents", Summary: "Pin a fact", Body: textInput{}, Result: facts.Fact{}},
+	{Method: "PUT", Path: "/agents/{id}/facts/{fact_id}", Tag: "agents", Summary: "Edit a fact", Body: textInput{}, Result: facts.Fact{}},
+	{Method: "DELETE", Path: "/agents/{id}/facts/{fact_id}", Tag: "agents", Summary: "Unpin a fact",
+		Result: struct {
+			Deleted string `json:"deleted"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/memories", Tag: "agents", Summary: "List memories, or recall the best matches for q",
+		Query: []string{"q", "tag", "limit"}, Result: struct {
+			Memories []memory.Memory `json:"memories"`
+		}{}},
+	{Method: "POST", Path: "/agents/{id}/memories", Tag: "agents", Summary: "Save a memory", Body: memoryInput{}, Status: http.StatusCreated, Result: memory.Memory{}},
+	{Method: "GET", Path: "/agents/{id}/memories/{key}", Tag: "agents", Summary: "Show a memory", Result: memory.Memory{}},
+	{Method: "DELETE", Path: "/agents/{id}/memories/{key}", Tag: "agents", Summary: "Forget a memory",
+		Result: struct {
+			Deleted string `json:"deleted"`
+		}{}},
+	{Method: "GET", Path: "/agents/{id}/metrics", Tag: "agents", Summary: "Usage and latency metrics", Result: engine.MetricsSnapshot{}},
+	{Method: "GET", Path: "/agents/{id}/quality", Tag: "agents", Summary: "Turn outcomes and ratings rolled up", Query: []string{"from", "to"}, Result: quality.Metrics{}},
+	{Method: "GET", Path: "/agents/{id}/quality/export", Tag: "agents", Summary: "Classified turns with their feedback as JSON lines",
+		Query: []string{"from", "to", "outcome", "rating", "limit"}, ContentType: "application/x-ndjson"},
+	{Method: "GET", Path: "/agents/{id}/budget", Tag: "agents", Summary: "Budget and usage this day and month", Result: engine.BudgetStatus{}},
+	{Method: "GET", Path: "/agents/{id}/capabilities", Tag: "agents", Summary: "Tools and models the agent can use", Result: engine.Capabilities{}},
+	{Method: "GET", Path: "/agents/{id}/snooze", Tag: "agents", Summary: "Show the agent's snooze state", Result: engine.SnoozeState{}},
+	{Method: "POST", Path: "/agents/{id}/snooze", Tag: "agents", Summary: "Hold the agent's messages for a duration",
+		Body: struct {
+			Duration string `json:"duration"`
+		}{}, Result: engine.SnoozeState{}},
+	{Method: "DELETE", Path: "/agents/{id}/snooze", Tag: "agents", Summary: "End a snooze and deliver held messages",
+		Result: struct {
+			OK        bool `json:"ok"`
+			Ended     bool `json:"ended"`
+			Delivered int  `json:"delivered"`
+		}{}},
+
+	{Method: "GET", Path: "/admin/restart", Tag: "admin", Summary: "Show restart status", Result: engine.RestartStatus{}},
+	{Method: "POST", Path: "/admin/restart", Tag: "admin", Summary: "Restart the server gracefully", Status: http.StatusAccepted, Result: engine.RestartStatus{}},
+	{Method: "GET", Path: "/admin/monitors", Tag: "admin", Summary: "List background monitors", Result: []monitors.Status{}},
+	{Method: "GET", Path: "/admin/monitors/{name}", Tag: "admin", Summary: "Show a monitor", Result: monitors.Status{}},
+	{Method: "PATCH", Path: "/admin/monitors/{name}", Tag: "admin", Summary: "Enable, disable or reschedule a monitor",
+		Body: struct {
+			Enabled  *bool   `json:"enabled,omitempty"`
+			Interval *string `json:"interval,omitempty"`
+		}{}, Result: monitors.Status{}},
+	{Method: "GET", Path: "/admin/tool-validation", Tag: "admin", Summary: "Tool argument validation counts", Result: []agenttools.ToolValidation{}},
+	{Method: "GET", Path: "/admin/providers", Tag: "admin", Summary: "Provider request limits, queues and waits", Result: governor.Snapshot{}},
+	{Method: "GET", Path: "/admin/egress", Tag: "admin", Summary: "Outbound HTTP destinations",
+		Result: struct {
+			Destinations []egress.Destination `json:"destinations"`
+		}{}},
+	{Method: "GET", Path: "/admin/priorities", Tag: "admin", Summary: "Priorities rejected at the API edge",
+		Result: struct {
+			Rejections []PriorityRejection `json:"rejections"`
+		}{}},
+	{Method: "GET", Path: "/admin/storage", Tag: "admin", Summary: "Query timings and lock contention", Query: []string{"top"}, Result: state.TelemetrySnapshot{}},
+	{Method: "DELETE", Path: "/admin/storage", Tag: "admin", Summary: "Reset storage telemetry", Result: okResult{}},
+	{Method: "GET", Path: "/admin/inflight", Tag: "admin", Summary: "Running turn cancellations and their reconciliation", Result: engine.InflightReport{}},
+	{Method: "POST", Path: "/admin/inflight", Tag: "admin", Summary: "Sweep orphaned turn cancellations now", Result: engine.InflightReport{}},
+	{Method: "GET", Path: "/admin/archive", Tag: "admin", Summary: "List archived objects", Query: []string{"kind", "stream", "scope_id", "limit"}, Result: []archive.Entry{}},
+	{Method: "POST", Path: "/admin/archive/run", Tag: "admin", Summary: "Archive cold data now", Result: archive.Result{}},
+	{Method: "GET", Path: "/admin/archive/events", Tag: "admin", Summary: "A stream's events in a time range, rehydrated from the archive",
+		Query: []string{"stream", "scope_type", "scope_id", "from", "to", "limit"},
+		Result: struct {
+			Stream string           `json:"stream"`
+			Events []eventbus.Event `json:"events"`
+		}{}},
+	{Method: "GET", Path: "/admin/archive/tasks/{id}", Tag: "admin", Summary: "Rehydrate an archived task tree", Result: archive.TaskTree{}},
+
+	{Method: "GET", Path: "/usage", Tag: "agents", Summary: "Tokens and cost rolled up by agent, model or day",
+		Query: []string{"group_by", "agent_id", "model", "from", "to"}, Result: usageReport{}},
+	{Method: "GET", Path: "/finetune", Tag: "agents", Summary: "Agents' turns as a fine-tuning dataset in JSON lines",
+		Query: []string{"agents", "format", "rating", "outcome", "from", "to", "validation", "split", "redact"}, ContentType: "application/x-ndjson"},
+	{Method: "GET", Path: "/threads", Tag: "threads", Summary: "Merge the conversations between agents", Query: []string{"agents", "thread_id"}, Result: engine.Thread{}},
+	{Method: "GET", Path: "/share/{token}", Tag: "threads", Summary: "Shared transcript as HTML, or JSON with format=json", Query: []string{"format"}, ContentType: "text/html"},
+	{Method: "GET", Path: "/maintenance", Tag: "maintenance", Summary: "List maintenance windows", Query: []string{"all"}, Result: []maintenance.Window{}},
+	{Method: "POST", Path: "/maintenance", Tag: "maintenance", Summary: "Schedule a maintenance window", Status: http.StatusCreated,
+		Body: struct {
+			AgentID         string    `json:"agent_id,omitempty"`
+			Reason          string    `json:"reason,omitempty"`
+			StartsAt        time.Time `json:"starts_at,omitempty"`
+			EndsAt          time.Time `json:"ends_at,omitempty"`
+			DurationSeconds int       `json:"duration_seconds,omitempty"`
+		}{}, Result: maintenance.Window{}},
+	{Method: "DELETE", Path: "/maintenance/{id}", Tag: "maintenance", Summary: "End a maintenance window", Result: maintenance.Window{}},
+
+	{Method: "POST", Path: "/federation/inbound", Tag: "federation", Summary: "Receive a signed message from a peer instance", Body: federation.Envelope{}, Result: federation.Receipt{}},
+	{Method: "GET", Path: "/federation/messages", Tag: "federation", Summary: "List federated messages", Query: []string{"status", "limit"},
+		Result: struct {
+			Name     string               `json:"name"`
+			Peers    []string             `json:"peers"`
+			Messages []federation.Message `json:"messages"`
+		}{}},
+	{Method: "POST", Path: "/github/webhook", Tag: "github", Summary: "Receive a signed GitHub webhook", Body: map[string]any{}, Result: github.WebhookResult{}},
+
+	{Method: "GET", Path: "/notifications", Tag: "notifications", Summary: "Push platforms and alert kinds",
+		Result: struct {
+			Platforms      []string `json:"platforms"`
+			Kinds          []string `json:"kinds"`
+			VAPIDPublicKey string   `json:"vapid_public_key,omitempty"`
+		}{}},
+	{Method: "GET", Path: "/notifications/devices", Tag: "notifications", Summary: "List the caller's push devices", Result: []notify.Device{}},
+	{Method: "POST", Path: "/notifications/devices", Tag: "notifications", Summary: "Register a push device", Status: http.StatusCreated,
+		Body: struct {
+			Platform     string `json:"platform"`
+			Token        string `json:"token,omitempty"`
+			Name         string `json:"name,omitempty"`
+			Subscription *struct {
+				Endpoint string `json:"endpoint"`
+				Keys     struct {
+					P256DH string `json:"p256dh"`
+					Auth   string `json:"auth"`
+				} `json:"keys"`
+			} `json:"subscription,omitempty"`
+		}{}, Result: notify.Device{}},
+	{Method: "DELETE", Path: "/notifications/devices/{id}", Tag: "notifications", Summary: "Remove a push device", Result: okResult{}},
+	{Method: "GET", Path: "/notifications/preferences", Tag: "notifications", Summary: "Show alert preferences", Result: notify.Preferences{}},
+	{Method: "PUT", Path: "/notifications/preferences", Tag: "notifications", Summary: "Set alert preferences",
+		Body: struct {
+			Kinds    *[]string `json:"kinds,omitempty"`
+			Disabled bool      `json:"disabled"`
+		}{}, Result: notify.Preferences{}},
+
+	{Method: "GET", Path: "/topics", Tag: "topics", Summary: "List topics", Result: []topics.Topic{}},
+	{Method: "GET", Path: "/topics/{topic}", Tag: "topics", Summary: "List a topic's subscribers",
+		Result: struct {
+			Topic       string                `json:"topic"`
+			Subscribers []topics.Subscription `json:"subscribers"`
+		}{}},
+	{Method: "POST", Path: "/topics/{topic}", Tag: "topics", Summary: "Publish to a topic", Status: http.StatusCreated,
+		Body: struct {
+			Source   string         `json:"source,omitempty"`
+			Subject  string         `json:"subject,omitempty"`
+			Body     string         `json:"body"`
+			Priority string         `json:"priority,omitempty"`
+			Payload  map[string]any `json:"payload,omitempty"`
+		}{}, Result: eventbus.Event{}},
+
+	{Method: "GET", Path: "/templates", Tag: "templates", Summary: "List task templates",
+		Result: struct {
+			Templates []templates.Template `json:"templates"`
+		}{}},
+	{Method: "GET", Path: "/templates/{name}", Tag: "templates", Summary: "Get a template", Result: templates.Template{}},
+	{Method: "PUT", Path: "/templates/{name}", Tag: "templates", Summary: "Create or replace a template", Body: templates.Template{}, Result: templates.Template{}},
+	{Method: "DELETE", Path: "/templates/{name}", Tag: "templates", Summary: "Delete a template", Result: okResult{}},
+	{Method: "POST", Path: "/templates/{name}/spawn", Tag: "templates", Summary: "Spawn a task from a template", Status: http.StatusAccepted,
+		Body: struct {
+			Params map[string]any `json:"params,omitempty"`
+			Source string         `json:"source,omitempty"`
+		}{}, Result: struct {
+			TaskID   string `json:"task_id"`
+			Status   string `json:"status"`
+			Type     string `json:"type"`
+			Template string `json:"template"`
+		}{}},
+
+	{Method: "GET", Path: "/contacts", Tag: "contacts", Summary: "List contacts", Query: []string{"q"},
+		Result: struct {
+			Contacts []contacts.Contact `json:"contacts"`
+		}{}},
+	{Method: "GET", Path: "/contacts/{id}", Tag: "contacts", Summary: "Get a contact", Result: contacts.Contact{}},
+	{Method: "PUT", Path: "/contacts/{id}", Tag: "contacts", Summary: "Create or replace a contact", Body: contacts.Contact{}, Result: contacts.Contact{}},
+	{Method: "DELETE", Path: "/contacts/{id}", Tag: "contacts", Summary: "Delete a contact", Result: okResult{}},
+
+	{Method: "GET", Path: "/schedules", Tag: "schedules", Summary: "List schedules", Query: []string{"task_id"},
+		Result: struct {
+			Schedules []scheduler.Schedule `json:"schedules"`
+		}{}},
+	{Method: "POST", Path: "/schedules", Tag: "schedules", Summary: "Wake a task on a cron schedule", Status: http.StatusCreated,
+		Body: struct {
+			TaskID   string `json:"task_id"`
+			Cron     string `json:"cron"`
+			Timezone string `json:"timezone,omitempty"`
+			Text     string `json:"text,omitempty"`
+			Enabled  *bool  `json:"enabled,omitempty"`
+		}{}, Result: scheduler.Schedule{}},
+	{Method: "GET", Path: "/schedules/{id}", Tag: "schedules", Summary: "Get a schedule", Result: scheduler.Schedule{}},
+	{Method: "PATCH", Path: "/schedules/{id}", Tag: "schedules", Summary: "Update a schedule", Body: scheduler.Patch{}, Result: scheduler.Schedule{}},
+	{Method: "DELETE", Path: "/schedules/{id}", Tag: "schedules", Summary: "Delete a schedule", Result: okResult{}},
+
+	{Method: "GET", Path: "/teams", Tag: "teams", Summary: "List teams",
+		Result: struct {
+			Teams []teams.Team `json:"teams"`
+		}{}},
+	{Method: "POST", Path: "/teams", Tag: "teams", Summary: "Create a supervisor and worker team", Status: http.StatusCreated,
+		Body: struct {
+			SupervisorID   string             `json:"supervisor_id"`
+			Workers        []teams.WorkerSpec `json:"workers"`
+			MaxConcurrency int                `json:"max_concurrency,omitempty"`
+		}{}, Result: teams.Team{}},
+	{Method: "GET", Path: "/teams/{id}", Tag: "teams", Summary: "Get a team and its workers' outputs",
+		Result: struct {
+			Team    teams.Team     `json:"team"`
+			Outputs []teams.Output `json:"outputs"`
+		}{}},
+	{Method: "DELETE", Path: "/teams/{id}", Tag: "teams", Summary: "Disband a team",
+		Result: struct {
+			Disbanded bool `json:"disbanded"`
+		}{}},
+	{Method: "POST", Path: "/teams/{id}/assign", Tag: "teams", Summary: "Assign work to a team worker", Status: http.StatusAccepted,
+		Body: struct {
+			To   string `json:"to,omitempty"`
+			From string `json:"from,omitempty"`
+			Body string `json:"body"`
+		}{}, Result: teams.Assignment{}},
+
+	{Method: "GET", Path: "/state", Tag: "streams", Summary: "Snapshot of tasks and recent stream events",
+		Query: []string{"tasks", "updates", "streams", "history", "stream_names", "deterministic"}, Result: stateResponse{}},
+	{Method: "POST", Path: "/mcp", Tag: "mcp", Summary: "MCP streamable HTTP: send a JSON-RPC message", Body: map[string]any{}, Result: map[string]any{}},
+	{Method: "DELETE", Path: "/mcp", Tag: "mcp", Summary: "MCP streamable HTTP: end a session"},
+	{Method: "GET", Path: "/artifacts/{id}", Tag: "agents", Summary: "Get an artifact, or its raw content with raw=1", Query: []string{"raw"}, Result: artifacts.Artifact{}},
+	{Method: "GET", Path: "/streams/subscribe", Tag: "streams", Summary: "Server-sent events across streams; the activity stream adds typed agent activity frames",
+		Query: []string{"streams", "min_priority", "agent", "kinds", "exclude_kinds"}, ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/streams/ws", Tag: "streams", Summary: "WebSocket subscription across streams", Status: http.StatusSwitchingProtocols},
+	{Method: "GET", Path: "/streams/{stream}", Tag: "streams", Summary: "List a stream's events; metadata.* and payload.* filter",
+		Query: []string{"reader", "limit", "order", "scope_type", "scope_id", "after", "consumer"},
+		Result: struct {
+			Events []eventbus.Event `json:"events"`
+			Next   string           `json:"next"`
+		}{}},
+	{Method: "GET", Path: "/streams/{stream}/cursor", Tag: "streams", Summary: "Get a consumer's cursor", Query: []string{"consumer"}, Result: eventbus.Cursor{}},
+	{Method: "PUT", Path: "/streams/{stream}/cursor", Tag: "streams", Summary: "Move a consumer's cursor", Query: []string{"consumer"},
+		Body: struct {
+			EventID string `json:"event_id"`
+		}{}, Result: eventbus.Cursor{}},
+	{Method: "POST", Path: "/streams/{stream}/ack-all", Tag: "streams", Summary: "Mark matching events read", Body: bulkReadInput{}, Result: eventbus.BulkResult{}},
+	{Method: "POST", Path: "/streams/{stream}/unread", Tag: "streams", Summary: "Mark matching events unread", Body: bulkReadInput{}, Result: eventbus.BulkResult{}},
+}
+
+// messageResult is the response to a message delivered to an agent.
+type messageResult struct {
+	OK        bool   `json:"ok"`
+	RequestID string `json:"request_id"`
+	ServiceID string `json:"service_id,omitempty"`
+}
+
+type bulkReadInput struct {
+	Reader    string            `json:"reader"`
+	IDs       []string          `json:"ids,omitempty"`
+	ScopeType string            `json:"scope_type,omitempty"`
+	ScopeID   string            `json:"scope_id,omitempty"`
+	Filters   map[string]string `json:"filters,omitempty"`
+	Before    string            `json:"before,omitempty"`
+	Limit     int               `json:"limit,omitempty"`
+}
+
+var pathParamRE = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIDocument builds the OpenAPI 3 document for operations.
+func (s *Server) openAPIDocument() map[string]any {
+	b := &schemaBuilder{components: map[string]any{}}
+	paths := map[string]any{}
+	for _, op := range operations {
+		item, _ := paths[op.Path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = s.openAPIOperation(b, op)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "agentd API",
+			"version":     strconv.Itoa(APIVersion),
+			"description": "Every path is also served without the version prefix under /api, deprecated in favour of the versioned path.",
+		},
+		"servers": []any{map[string]any{"url": versionedPath(APIVersion, "/api")}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []any{map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}},
+	}
+}
+
+func (s *Server) openAPIOperation(b *schemaBuilder, op operation) map[string]any {
+	full := "/api" + op.Path
+	out := map[string]any{
+		"operationId": operationID(op),
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+	}
+	var params []any
+	for _, match := range pathParamRE.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]any{"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	for _, name := range op.Query {
+		params = append(params, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	if op.Body != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.Body))}},
+		}
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success["content"] = map[string]any{op.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+	case op.Result != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.Result))}}
+	}
+	out["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeFor[errorResult]())}},
+		},
+	}
+	switch {
+	case hasPathPrefix(full, publicPaths):
+		out["security"] = []any{}
+	case hasPathPrefix(full, adminPaths):
+		out["description"] = "Requires an admin API key once API keys are configured."
+	}
+	for _, d := range s.Deprecations {
+		if full == d.Path || strings.HasPrefix(full, strings.TrimSuffix(d.Path, "/")+"/") {
+			out["deprecated"] = true
+			break
+		}
+	}
+	return out
+}
+
+// operationID names an operation from its method and path, such as
+// postTasksIdCancel.
+func operationID(op operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.Method))
+	for _, word := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return sb.String()
+}
+
+// schemaBuilder turns Go types into JSON schemas. Named struct types become
+// shared components referenced by $ref.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	marshalerType  = reflect.TypeFor[json.Marshaler]()
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.Struct && t.Implements(marshalerType):
+		// A custom encoding could be anything.
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			b.components[name] = map[string]any{}
+			b.components[name] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	b.addFields(t, props)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// addFields adds t's JSON fields, flattening untagged embedded structs the
+// way encoding/json does.
+func (b *schemaBuilder) addFields(t reflect.Type, props map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.addFields(ft, props)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = b.schema(field.Type)
+	}
+}
+
+var typeArgPathRE = regexp.MustCompile(`[\w.-]+/`)
+
+// componentName names a struct's schema after its package and type, such as
+// tasks.Task. Type arguments of generic types keep only their package name.
+func componentName(t reflect.Type) string {
+	name := path.Base(t.PkgPath()) + "." + typeArgPathRE.ReplaceAllString(t.Name(), "")
+	name = strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.TrimSuffix(name, "_")
+}
+
+// handleOpenAPI serves the OpenAPI document.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.openAPIDocument())
+}
+
+// handleAPIDocs serves Swagger UI for the OpenAPI document. The page loads
+// Swagger UI from a CDN.
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// openapi.json is relative so the page works under /api and /api/v1.
+	fmt.Fprint(w, `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>agentd API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`)
+}
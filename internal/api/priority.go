@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// PriorityRejection counts requests to one endpoint rejected for one
+// priority value.
+type PriorityRejection struct {
+	Endpoint string `json:"endpoint"`
+	Value    string `json:"value"`
+	Count    int64  `json:"count"`
+}
+
+// maxPriorityRejections bounds the distinct (endpoint, value) pairs kept;
+// further values are counted as "<other>".
+const maxPriorityRejections = 256
+
+// PriorityStats records priorities rejected at the API edge so misbehaving
+// clients show up. It is safe for concurrent use; the zero value is ready.
+type PriorityStats struct {
+	mu     sync.Mutex
+	counts map[[2]string]int64
+}
+
+func NewPriorityStats() *PriorityStats {
+	return &PriorityStats{}
+}
+
+func (s *PriorityStats) record(endpoint, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[[2]string]int64{}
+	}
+	if len(value) > 32 {
+		value = value[:32]
+	}
+	key := [2]string{endpoint, value}
+	if _, ok := s.counts[key]; !ok && len(s.counts) >= maxPriorityRejections {
+		key[1] = "<other>"
+	}
+	s.counts[key]++
+}
+
+// Snapshot returns the rejection counts sorted by endpoint and value.
+func (s *PriorityStats) Snapshot() []PriorityRejection {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PriorityRejection, 0, len(s.counts))
+	for key, count := range s.counts {
+		out = append(out, PriorityRejection{Endpoint: key[0], Value: key[1], Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Endpoint != out[j].Endpoint {
+			return out[i].Endpoint < out[j].Endpoint
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// validPriority checks raw with schema.ValidatePriority. On failure it
+// counts the rejection under endpoint, writes a 400 and returns false.
+func (s *Server) validPriority(w http.ResponseWriter, endpoint, raw string) (schema.Priority, bool) {
+	p, err := schema.ValidatePriority(raw)
+	if err != nil {
+		s.Priorities.record(endpoint, raw)
+		writeError(w, http.StatusBadRequest, errBadRequest(err.Error()))
+		return "", false
+	}
+	return p, true
+}
+
+// validPayloadPriority checks payload["priority"], which must be a string
+// when present.
+func (s *Server) validPayloadPriority(w http.ResponseWriter, endpoint string, payload map[string]any) bool {
+	raw, ok := payload[schema.MetaPriority]
+	if !ok || raw == nil {
+		return true
+	}
+	str, ok := raw.(string)
+	if !ok {
+		s.Priorities.record(endpoint, "<non-string>")
+		writeError(w, http.StatusBadRequest, errBadRequest("priority must be a string"))
+		return false
+	}
+	_, ok = s.validPriority(w, endpoint, str)
+	return ok
+}
+
+// handleAdminPriorities reports priorities rejected at the API edge.
+func (s *Server) handleAdminPriorities(w http.ResponseWriter, r *http.Request) {
+	if s.Priorities == nil {
+		writeError(w, http.StatusNotFound, errNotFound("priority stats"))
+		return
+	}
+	if !s.authorizeAdmin(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid restart token"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rejections": s.Priorities.Snapshot()})
+}
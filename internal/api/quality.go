@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/quality"
+)
+
+// feedbackInput is the body of POST /api/agents/<id>/turns/<llm_task_id>/feedback.
+type feedbackInput struct {
+	Rating  string `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// handleTurnFeedback serves POST /api/agents/<id>/turns/<llm_task_id>/feedback,
+// which rates a turn {"rating": "up"|"down", "comment": "..."} on behalf of
+// the caller's key.
+func (s *Server) handleTurnFeedback(w http.ResponseWriter, r *http.Request, agentID, llmTaskID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var payload feedbackInput
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := quality.ParseRating(payload.Rating); err != nil {
+		writeError(w, http.StatusBadRequest, errBadRequest(err.Error()))
+		return
+	}
+	fb := quality.Feedback{Rating: payload.Rating, Comment: payload.Comment}
+	if principal, ok := principalFrom(r.Context()); ok {
+		fb.Author = principal.ID
+	}
+	fb, err := s.Runtime.RecordTurnFeedback(r.Context(), agentID, llmTaskID, fb)
+	switch {
+	case errors.Is(err, engine.ErrTurnNotFound):
+		writeError(w, http.StatusNotFound, errNotFound("turn"))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, fb)
+	}
+}
+
+// handleAgentQuality serves GET /api/agents/<id>/quality, the agent's turn
+// outcomes and ratings rolled up, and GET /api/agents/<id>/quality/export,
+// its classified turns with their message, reply and feedback as JSON
+// lines. ?from= and ?to= bound when turns ended; the export also takes
+// ?outcome=, ?rating= and ?limit=.
+func (s *Server) handleAgentQuality(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	export := len(rest) == 1 && rest[0] == "export"
+	if len(rest) > 0 && !export {
+		writeError(w, http.StatusNotFound, errNotFound("quality action"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Quality == nil {
+		writeError(w, http.StatusNotFound, errNotFound("quality"))
+		return
+	}
+	query := r.URL.Query()
+	filter := quality.Filter{AgentID: agentID}
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		t, err := parseUsageTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest(param.name+" must be RFC 3339 or a date"))
+			return
+		}
+		*param.dst = t
+	}
+	if !export {
+		metrics, err := s.Quality.Metrics(r.Context(), filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, metrics)
+		return
+	}
+
+	if outcome := strings.TrimSpace(query.Get("outcome")); outcome != "" {
+		if !quality.ValidOutcome(outcome) {
+			writeError(w, http.StatusBadRequest, errBadRequest("unknown outcome "+strconv.Quote(outcome)))
+			return
+		}
+		filter.Outcome = outcome
+	}
+	if raw := strings.TrimSpace(query.Get("rating")); raw != "" {
+		rating, err := quality.ParseRating(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest(err.Error()))
+			return
+		}
+		filter.Rating = rating
+	}
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, errBadRequest("limit must be a positive integer"))
+			return
+		}
+		filter.Limit = limit
+	}
+	turns, err := s.Quality.Turns(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, turn := range turns {
+		if err := enc.Encode(turn); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/questions"
+)
+
+// handleTaskQuestions serves /api/tasks/<id>/questions: GET lists the
+// questions an agent asked (?status=pending for open ones) and DELETE
+// /api/tasks/<id>/questions/<question_id> withdraws a pending question.
+// Questions are answered by sending the agent a message with question_id.
+func (s *Server) handleTaskQuestions(w http.ResponseWriter, r *http.Request, taskID string, rest []string) {
+	if s.Questions == nil {
+		writeError(w, http.StatusNotFound, errNotFound("question store"))
+		return
+	}
+	if len(rest) == 0 || rest[0] == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		limit := parseInt(r.URL.Query().Get("limit"), 50)
+		list, err := s.Questions.List(r.Context(), taskID, r.URL.Query().Get("status"), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	q, err := s.Questions.Get(r.Context(), rest[0])
+	if errors.Is(err, questions.ErrQuestionNotFound) || (err == nil && q.AgentID != taskID) {
+		writeError(w, http.StatusNotFound, errNotFound("question"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, q)
+	case http.MethodDelete:
+		cancelled, err := s.Questions.Cancel(r.Context(), q.ID)
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cancelled)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/replay"
+)
+
+// handleAgentReplay runs recorded events against a sandbox copy of the agent
+// with dry-run tools and returns the resulting transcript.
+func (s *Server) handleAgentReplay(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil || s.Runtime.LLM == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("llm client"))
+		return
+	}
+	var payload struct {
+		Events []eventbus.Event `json:"events"`
+		System string           `json:"system"`
+		Model  string           `json:"model"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(payload.Events) == 0 {
+		writeError(w, http.StatusBadRequest, errBadRequest("events are required"))
+		return
+	}
+	system, model := s.Runtime.AgentConfig(agentID)
+	if strings.TrimSpace(payload.System) != "" {
+		system = payload.System
+	}
+	if strings.TrimSpace(payload.Model) != "" {
+		model = payload.Model
+	}
+	transcript, err := replay.Run(r.Context(), payload.Events, replay.Options{
+		AgentID: agentID + "-sandbox",
+		System:  system,
+		Model:   model,
+		Client:  s.Runtime.LLM,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transcript)
+}
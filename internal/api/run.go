@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// handleAgentRun serves POST /api/agents/<id>/run: it sends a message like
+// /api/tasks/<id>/send, and may run that one turn on another provider or
+// model. Overrides need owner access and must be allowed by the runtime's
+// model override policy; the agent's configuration is left unchanged.
+func (s *Server) handleAgentRun(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var payload struct {
+		agentMessageInput
+		Model    string `json:"model"`
+		Provider string `json:"provider"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(payload.Message) == "" {
+		writeError(w, http.StatusBadRequest, errBadRequest("message is required"))
+		return
+	}
+	var extra map[string]any
+	var override *engine.ModelOverride
+	if strings.TrimSpace(payload.Model) != "" || strings.TrimSpace(payload.Provider) != "" {
+		if !s.requireAccess(w, r, agentID, access.LevelOwner) {
+			return
+		}
+		resolved, err := s.Runtime.CheckModelOverride(engine.ModelOverride{Provider: payload.Provider, Model: payload.Model})
+		if errors.Is(err, engine.ErrModelOverrideNotAllowed) {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		override = &resolved
+		extra = map[string]any{
+			schema.MetaModelOverride:    resolved.Model,
+			schema.MetaProviderOverride: resolved.Provider,
+		}
+	}
+	resp, ok := s.deliverAgentMessage(w, r, agentID, payload.agentMessageInput, extra)
+	if !ok {
+		return
+	}
+	if override != nil {
+		resp["model_override"] = override
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
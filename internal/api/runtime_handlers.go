@@ -1,38 +1,30 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/flitsinc/go-agents/internal/access"
 	"github.com/flitsinc/go-agents/internal/engine"
 	"github.com/flitsinc/go-agents/internal/idgen"
 	"github.com/flitsinc/go-agents/internal/tasks"
 )
 
-// applyAgentConfig sets system prompt and model on a runtime from the payload.
-func applyAgentConfig(rt *engine.Runtime, taskID string, payload map[string]any) {
-	if rt == nil || payload == nil {
-		return
-	}
-	if system, ok := payload["system"].(string); ok && system != "" {
-		rt.SetAgentSystem(taskID, system)
-	}
-	if model, ok := payload["model"].(string); ok && model != "" {
-		rt.SetAgentModel(taskID, model)
-	}
-}
-
 func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeMethodNotAllowed(w)
 		return
 	}
 	var payload struct {
-		ID      string         `json:"id"`
-		Type    string         `json:"type"`
-		Payload map[string]any `json:"payload"`
-		Source  string         `json:"source"`
+		ID       string          `json:"id"`
+		Type     string          `json:"type"`
+		Payload  map[string]any  `json:"payload"`
+		Source   string          `json:"source"`
+		Priority string          `json:"priority"`
+		Callback *tasks.Callback `json:"callback"`
 	}
 	if err := decodeJSON(r.Body, &payload); err != nil {
 		writeError(w, http.StatusBadRequest, err)
@@ -51,6 +43,35 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	source := strings.TrimSpace(payload.Source)
+	if tz, ok := payload.Payload["timezone"].(string); ok && taskType == "agent" {
+		if _, err := engine.LoadTimezone(tz); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if at, ok := payload.Payload["daily_digest"].(string); ok && taskType == "agent" && strings.TrimSpace(at) != "" {
+		if _, err := engine.ParseDigestTime(at); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if raw, ok := payload.Payload["concurrency"]; ok && taskType == "agent" {
+		n, isNum := raw.(float64)
+		if !isNum || n != float64(int(n)) {
+			writeError(w, http.StatusBadRequest, errBadRequest("concurrency must be a whole number"))
+			return
+		}
+		if err := engine.ValidateAgentConcurrency(int(n)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if raw, ok := payload.Payload["history_budget"]; ok && taskType == "agent" {
+		if _, err := engine.ParseHistoryBudget(raw); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
 
 	if s.Tasks == nil {
 		writeError(w, http.StatusInternalServerError, errNotFound("task manager"))
@@ -61,8 +82,11 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	// re-apply config and ensure the loop is running.
 	if customID != "" {
 		if existing, err := s.Tasks.Get(r.Context(), customID); err == nil && existing.ID != "" {
+			if !s.requireAccess(w, r, existing.ID, access.LevelOwner) {
+				return
+			}
 			if taskType == "agent" && s.Runtime != nil {
-				applyAgentConfig(s.Runtime, existing.ID, payload.Payload)
+				s.Runtime.ApplyAgentConfig(existing.ID, payload.Payload)
 				s.Runtime.EnsureAgentLoop(existing.ID)
 			}
 			writeJSON(w, http.StatusOK, map[string]any{
@@ -76,9 +100,10 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	spec := tasks.Spec{
-		ID:   customID,
-		Type: taskType,
-		Mode: "async",
+		ID:       customID,
+		Type:     taskType,
+		Mode:     "async",
+		Priority: payload.Priority,
 		Metadata: map[string]any{
 			"source": source,
 		},
@@ -86,26 +111,50 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	if payload.Payload != nil {
 		spec.Payload = payload.Payload
 	}
+	callbackSecret := ""
+	if payload.Callback != nil {
+		spec.Callback = payload.Callback
+		if strings.TrimSpace(spec.Callback.Secret) == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("generate callback secret: %w", err))
+				return
+			}
+			callbackSecret = hex.EncodeToString(secret)
+			spec.Callback.Secret = callbackSecret
+		}
+	}
 
 	created, err := s.Tasks.Spawn(r.Context(), spec)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if taskType == "agent" {
+		if err := s.grantOwner(r.Context(), created.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
 
 	// For agent tasks, set up the runtime loop
 	if taskType == "agent" && s.Runtime != nil {
 		_ = s.Tasks.MarkRunning(r.Context(), created.ID)
-		applyAgentConfig(s.Runtime, created.ID, payload.Payload)
+		s.Runtime.ApplyAgentConfig(created.ID, payload.Payload)
 		s.Runtime.EnsureAgentLoop(created.ID)
 	}
 
-	writeJSON(w, http.StatusAccepted, map[string]any{
+	resp := map[string]any{
 		"task_id": created.ID,
 		"status":  string(created.Status),
 		"type":    created.Type,
 		"created": true,
-	})
+	}
+	if callbackSecret != "" {
+		// Returned once so the caller can verify callback signatures.
+		resp["callback_secret"] = callbackSecret
+	}
+	writeJSON(w, http.StatusAccepted, resp)
 }
 
 func (s *Server) handleTaskCompact(w http.ResponseWriter, r *http.Request, taskID string) {
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/scheduler"
+)
+
+// handleSchedules serves /api/schedules. GET lists schedules, optionally
+// for one ?task_id=; listing every task's schedules needs admin access when
+// API keys are on, and a namespaced admin sees only its namespace's. POST {"task_id", "cron", "timezone", "text", "enabled"}
+// creates one; enabled defaults to true.
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	if s.Scheduler == nil {
+		writeError(w, http.StatusNotFound, errNotFound("scheduler"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		taskID := strings.TrimSpace(r.URL.Query().Get("task_id"))
+		if taskID == "" {
+			if principal, ok := principalFrom(r.Context()); s.Access != nil && ok && !principal.Admin {
+				writeError(w, http.StatusForbidden, errors.New("admin access required"))
+				return
+			}
+		} else if !s.requireAccess(w, r, taskID, access.LevelView) {
+			return
+		}
+		list, err := s.Scheduler.List(r.Context(), taskID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if namespace := requestNamespace(r); namespace != "" {
+			list = slices.DeleteFunc(list, func(sc scheduler.Schedule) bool {
+				return !s.inNamespace(r.Context(), sc.TaskID, namespace)
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"schedules": list})
+	case http.MethodPost:
+		var payload struct {
+			TaskID   string `json:"task_id"`
+			Cron     string `json:"cron"`
+			Timezone string `json:"timezone"`
+			Text     string `json:"text"`
+			Enabled  *bool  `json:"enabled"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		taskID := strings.TrimSpace(payload.TaskID)
+		if taskID == "" {
+			writeError(w, http.StatusBadRequest, errBadRequest("task_id is required"))
+			return
+		}
+		if !s.requireAccess(w, r, taskID, access.LevelInteract) {
+			return
+		}
+		created, err := s.Scheduler.Create(r.Context(), scheduler.Schedule{
+			TaskID:   taskID,
+			Cron:     payload.Cron,
+			Timezone: payload.Timezone,
+			Text:     payload.Text,
+			Enabled:  payload.Enabled == nil || *payload.Enabled,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleScheduleItem serves /api/schedules/<id>: GET returns a schedule,
+// PATCH {"cron", "timezone", "text", "enabled"} changes the fields given
+// and DELETE removes it.
+func (s *Server) handleScheduleItem(w http.ResponseWriter, r *http.Request) {
+	if s.Scheduler == nil {
+		writeError(w, http.StatusNotFound, errNotFound("scheduler"))
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, errNotFound("schedule"))
+		return
+	}
+	sched, err := s.Scheduler.Get(r.Context(), id)
+	if errors.Is(err, scheduler.ErrNotFound) {
+		writeError(w, http.StatusNotFound, errNotFound("schedule"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !s.requireAccess(w, r, sched.TaskID, readOrInteract(r)) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sched)
+	case http.MethodPatch:
+		var patch scheduler.Patch
+		if err := decodeJSON(r.Body, &patch); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		updated, err := s.Scheduler.Update(r.Context(), id, patch)
+		if errors.Is(err, scheduler.ErrNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("schedule"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		removed, err := s.Scheduler.Delete(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, errNotFound("schedule"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -1,25 +1,124 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/calendar"
+	"github.com/flitsinc/go-agents/internal/contacts"
+	"github.com/flitsinc/go-agents/internal/documents"
+	"github.com/flitsinc/go-agents/internal/egress"
 	"github.com/flitsinc/go-agents/internal/engine"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/facts"
+	"github.com/flitsinc/go-agents/internal/federation"
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/github"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/health"
 	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/maintenance"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/scheduler"
 	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-agents/internal/share"
+	"github.com/flitsinc/go-agents/internal/state"
 	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/templates"
+	"github.com/flitsinc/go-agents/internal/topics"
+	"github.com/flitsinc/go-agents/internal/usage"
 )
 
 type Server struct {
-	Tasks   *tasks.Manager
-	Bus     *eventbus.Bus
-	Runtime *engine.Runtime
-	NowFn   func() time.Time
+	Tasks       *tasks.Manager
+	Bus         *eventbus.Bus
+	Runtime     *engine.Runtime
+	Documents   *documents.Store
+	Maintenance *maintenance.Store
+	Restart     *engine.RestartOrchestrator
+	Health      *health.Checker
+	Monitors    *monitors.Registry
+	Shares      *share.Signer
+	Topics      *topics.Store
+	Questions   *questions.Store
+	Shadow      *shadow.Store
+	// Labels holds generated conversation titles shown in agent lists.
+	Labels *labels.Store
+	// Notifications stores push devices and alert preferences; Pushers are
+	// the platforms devices may register for.
+	Notifications *notify.Store
+	Pushers       map[string]notify.Pusher
+	// Federation exchanges messages with agents on peer instances.
+	Federation *federation.Node
+	// Calendar holds per-agent calendar accounts and reminders.
+	Calendar *calendar.Service
+	// GitHub routes GitHub webhooks to the agents watching each repository.
+	GitHub *github.Service
+	// Artifacts holds large outputs stored in place of inline text.
+	Artifacts *artifacts.Store
+	// Facts holds each agent's pinned facts.
+	Facts *facts.Store
+	// Memory holds each agent's long-term memories.
+	Memory *memory.Store
+	// ToolValidation holds per-tool argument validation counts.
+	ToolValidation *agenttools.ValidationStats
+	// Templates holds the task spec templates.
+	Templates *templates.Store
+	// Contacts holds the humans agents can message with message_contact.
+	Contacts *contacts.Store
+	// Scheduler holds the cron schedules that wake tasks.
+	Scheduler *scheduler.Service
+	// Priorities counts priorities rejected at the API edge.
+	Priorities *PriorityStats
+	// Storage holds query timings and lock contention for the database.
+	Storage *state.Telemetry
+	// Egress is the outbound HTTP policy, whose destination audit the admin
+	// API reports.
+	Egress *egress.Policy
+	// Governor shapes provider requests; the admin API reports its queues.
+	Governor *governor.Governor
+	// MCP serves agentd's tools to MCP clients at /api/mcp when set.
+	MCP http.Handler
+	// Archive holds cold data moved to object storage. Share links read
+	// through it, and the admin API lists and rehydrates it.
+	Archive *archive.Archiver
+	// Usage holds every turn's tokens and cost for GET /api/usage.
+	Usage *usage.Store
+	// Quality holds every turn's outcome and feedback for the agent quality
+	// endpoints.
+	Quality *quality.Store
+	// FineTuneRedactors rewrite every fine-tuning export after the
+	// built-in PII redaction.
+	FineTuneRedactors []finetune.Redactor
+	// Access enforces API keys and per-agent grants when set; without it
+	// the API is open.
+	Access *access.Store
+	NowFn  func() time.Time
+
+	// RestartToken guards the admin endpoints when set.
+	RestartToken string
+	// Deprecations marks endpoints scheduled for removal; see Deprecation.
+	Deprecations []Deprecation
+	// LegacyAPISunset is when the unversioned /api paths may stop working.
+	// Zero leaves them deprecated without a date.
+	LegacyAPISunset time.Time
 }
 
 func (s *Server) now() time.Time {
@@ -31,14 +130,71 @@ func (s *Server) now() time.Time {
 
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
+	for _, rt := range s.routes() {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
+	return s.withTracing(s.withVersion(s.withAccess(mux)))
+}
 
-	mux.HandleFunc("/api/tasks/queue", s.handleTaskQueue)
-	mux.HandleFunc("/api/tasks/", s.handleTaskItem)
-	mux.HandleFunc("/api/tasks", s.handleCreateTask)
-	mux.HandleFunc("/api/state", s.handleState)
-	mux.HandleFunc("/api/streams/subscribe", s.handleStreamSubscribe)
+// route is one mux pattern and its handler. Every route's operations are
+// described in operations for the OpenAPI document.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
 
-	return mux
+func (s *Server) routes() []route {
+	return []route{
+		{"/api/versions", s.handleVersions},
+		{"/api/health", s.handleHealth},
+		{"/api/health/live", s.handleHealthLive},
+		{"/api/openapi.json", s.handleOpenAPI},
+		{"/api/docs", s.handleAPIDocs},
+		{"/api/tasks/queue", s.handleTaskQueue},
+		{"/api/tasks/queue/depths", s.handleTaskQueueDepths},
+		{"/api/tasks/", s.handleTaskItem},
+		{"/api/tasks", s.handleCreateTask},
+		{"/api/agents", s.handleAgents},
+		{"/api/agents/", s.handleAgentItem},
+		{"/api/admin/restart", s.handleAdminRestart},
+		{"/api/admin/monitors", s.handleAdminMonitors},
+		{"/api/admin/monitors/", s.handleAdminMonitorItem},
+		{"/api/admin/tool-validation", s.handleAdminToolValidation},
+		{"/api/admin/providers", s.handleAdminProviders},
+		{"/api/admin/egress", s.handleAdminEgress},
+		{"/api/admin/priorities", s.handleAdminPriorities},
+		{"/api/admin/storage", s.handleAdminStorage},
+		{"/api/admin/inflight", s.handleAdminInflight},
+		{"/api/admin/archive", s.handleAdminArchive},
+		{"/api/admin/archive/", s.handleAdminArchiveItem},
+		{"/api/threads", s.handleThreads},
+		{"/api/usage", s.handleUsage},
+		{"/api/finetune", s.handleFineTune},
+		{"/api/share/", s.handleShare},
+		{"/api/maintenance", s.handleMaintenance},
+		{"/api/maintenance/", s.handleMaintenanceItem},
+		{federation.InboundPath, s.handleFederationInbound},
+		{"/api/federation/messages", s.handleFederationMessages},
+		{"/api/github/webhook", s.handleGitHubWebhook},
+		{"/api/notifications", s.handleNotifications},
+		{"/api/notifications/", s.handleNotificationItem},
+		{"/api/topics", s.handleTopics},
+		{"/api/topics/", s.handleTopicItem},
+		{"/api/templates", s.handleTemplates},
+		{"/api/templates/", s.handleTemplateItem},
+		{"/api/contacts", s.handleContacts},
+		{"/api/contacts/", s.handleContactItem},
+		{"/api/schedules", s.handleSchedules},
+		{"/api/schedules/", s.handleScheduleItem},
+		{"/api/teams", s.handleTeams},
+		{"/api/teams/", s.handleTeamItem},
+		{"/api/state", s.handleState},
+		{"/api/mcp", s.handleMCP},
+		{"/api/artifacts/", s.handleArtifactItem},
+		{"/api/streams/subscribe", s.handleStreamSubscribe},
+		{"/api/streams/ws", s.handleStreamWS},
+		{"/api/streams/", s.handleStreamItem},
+	}
 }
 
 func (s *Server) handleTaskQueue(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +212,23 @@ func (s *Server) handleTaskQueue(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, items)
 }
 
+func (s *Server) handleTaskQueueDepths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	kind := r.URL.Query().Get("type")
+	depths, err := s.Tasks.QueueDepths(r.Context(), kind)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"type":   kind,
+		"depths": depths,
+	})
+}
+
 func (s *Server) handleTaskItem(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
 	segments := strings.Split(strings.Trim(path, "/"), "/")
@@ -70,6 +243,18 @@ func (s *Server) handleTaskItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	action := segments[1]
+	need := access.LevelInteract
+	switch action {
+	case "updates", "assistant_output":
+		need = access.LevelView
+	case "complete", "fail", "cancel", "kill", "priority":
+		need = access.LevelOwner
+	case "questions":
+		need = readOrInteract(r)
+	}
+	if !s.requireAccess(w, r, taskID, need) {
+		return
+	}
 	switch action {
 	case "updates":
 		s.handleTaskUpdates(w, r, taskID)
@@ -85,8 +270,12 @@ func (s *Server) handleTaskItem(w http.ResponseWriter, r *http.Request) {
 		s.handleTaskCancel(w, r, taskID)
 	case "kill":
 		s.handleTaskKill(w, r, taskID)
+	case "priority":
+		s.handleTaskPriority(w, r, taskID)
 	case "compact":
 		s.handleTaskCompact(w, r, taskID)
+	case "questions":
+		s.handleTaskQuestions(w, r, taskID, segments[2:])
 	default:
 		writeError(w, http.StatusNotFound, errNotFound("task action"))
 	}
@@ -109,11 +298,19 @@ func (s *Server) handleTaskAssistantOutput(w http.ResponseWriter, r *http.Reques
 		RequestID  string         `json:"request_id"`
 		ServiceID  string         `json:"service_id"`
 		Context    map[string]any `json:"context"`
+		// Parts attaches images and files to the text.
+		Parts []schema.Part `json:"parts"`
 	}
 	if err := decodeJSON(r.Body, &payload); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	for _, part := range payload.Parts {
+		if err := part.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
 
 	meta := map[string]any{}
 	if source := strings.TrimSpace(payload.Source); source != "" {
@@ -131,6 +328,9 @@ func (s *Server) handleTaskAssistantOutput(w http.ResponseWriter, r *http.Reques
 	if len(payload.Context) > 0 {
 		meta["context"] = payload.Context
 	}
+	if len(payload.Parts) > 0 {
+		meta[schema.MetaParts] = payload.Parts
+	}
 	if s.Runtime != nil {
 		if err := s.Runtime.EmitAssistantOutput(r.Context(), taskID, payload.Text, meta); err != nil {
 			writeError(w, http.StatusBadRequest, err)
@@ -140,12 +340,25 @@ func (s *Server) handleTaskAssistantOutput(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	text := strings.TrimSpace(payload.Text)
+	body := strings.TrimSpace(payload.Text)
+	text := schema.TextWithAttachments(body, payload.Parts)
 	if text == "" {
 		writeError(w, http.StatusBadRequest, errBadRequest("text is required"))
 		return
 	}
 	updatePayload := map[string]any{"text": text}
+	if schema.HasAttachments(payload.Parts) {
+		var parts []schema.Part
+		if body != "" {
+			parts = append(parts, schema.Part{Type: schema.PartText, Text: body})
+		}
+		for _, part := range payload.Parts {
+			if part.Type != schema.PartText {
+				parts = append(parts, part)
+			}
+		}
+		updatePayload[schema.MetaParts] = parts
+	}
 	if requestID := strings.TrimSpace(payload.RequestID); requestID != "" || len(payload.Context) > 0 {
 		route := map[string]any{}
 		if requestID != "" {
@@ -198,6 +411,9 @@ func (s *Server) handleTaskUpdates(w http.ResponseWriter, r *http.Request, taskI
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		if !s.validPayloadPriority(w, "task_update", payload.Payload) {
+			return
+		}
 		if err := s.Tasks.RecordUpdate(r.Context(), taskID, payload.Kind, payload.Payload); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -234,11 +450,22 @@ func (s *Server) handleTaskFail(w http.ResponseWriter, r *http.Request, taskID s
 	}
 	var payload struct {
 		Error string `json:"error"`
+		// Limit names the resource limit the task exceeded, if any.
+		Limit string         `json:"limit"`
+		Usage map[string]any `json:"usage"`
 	}
 	if err := decodeJSON(r.Body, &payload); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if strings.TrimSpace(payload.Limit) != "" {
+		if err := s.Tasks.ExceedLimit(r.Context(), taskID, payload.Limit, payload.Error, payload.Usage); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+		return
+	}
 	if err := s.Tasks.Fail(r.Context(), taskID, payload.Error); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
@@ -252,13 +479,7 @@ func (s *Server) handleTaskSend(w http.ResponseWriter, r *http.Request, taskID s
 		return
 	}
 	var payload struct {
-		// Agent message fields
-		Message   string         `json:"message"`
-		Source    string         `json:"source"`
-		Priority  string         `json:"priority"`
-		RequestID string         `json:"request_id"`
-		ServiceID string         `json:"service_id"`
-		Context   map[string]any `json:"context"`
+		agentMessageInput
 		// Generic task input
 		Input map[string]any `json:"input"`
 	}
@@ -268,53 +489,10 @@ func (s *Server) handleTaskSend(w http.ResponseWriter, r *http.Request, taskID s
 	}
 
 	// If a message is provided and we have a runtime, deliver it as an agent message.
-	message := strings.TrimSpace(payload.Message)
-	if message != "" && s.Runtime != nil {
-		// Verify the task exists before delivering. No auto-creation.
-		if _, err := s.Tasks.Get(r.Context(), taskID); err != nil {
-			writeError(w, http.StatusNotFound, errNotFound("task"))
-			return
-		}
-		source := strings.TrimSpace(payload.Source)
-		contextData, serviceID, err := normalizeServiceMessageContext(payload.ServiceID, payload.Context)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
-		}
-		if source == "" && serviceID != "" {
-			source = serviceID
-		}
-		s.Runtime.EnsureAgentLoop(taskID)
-		requestID := strings.TrimSpace(payload.RequestID)
-		if requestID == "" {
-			requestID = idgen.New()
-		}
-		meta := map[string]any{
-			"request_id": requestID,
-			"kind":       "message",
+	if strings.TrimSpace(payload.Message) != "" && s.Runtime != nil {
+		if resp, ok := s.deliverAgentMessage(w, r, taskID, payload.agentMessageInput, nil); ok {
+			writeJSON(w, http.StatusOK, resp)
 		}
-		if strings.TrimSpace(payload.Priority) != "" {
-			meta["priority"] = string(schema.ParsePriority(payload.Priority))
-		}
-		if len(contextData) > 0 {
-			meta["context"] = contextData
-		}
-		if serviceID != "" {
-			meta["service_id"] = serviceID
-		}
-		_, err = s.Runtime.SendMessageWithMeta(r.Context(), taskID, message, source, meta)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
-		}
-		resp := map[string]any{
-			"ok":         true,
-			"request_id": requestID,
-		}
-		if serviceID != "" {
-			resp["service_id"] = serviceID
-		}
-		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 
@@ -326,6 +504,99 @@ func (s *Server) handleTaskSend(w http.ResponseWriter, r *http.Request, taskID s
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+// agentMessageInput is the request body fields of a message to an agent.
+type agentMessageInput struct {
+	Message   string         `json:"message"`
+	Source    string         `json:"source"`
+	Priority  string         `json:"priority"`
+	RequestID string         `json:"request_id"`
+	ServiceID string         `json:"service_id"`
+	Context   map[string]any `json:"context"`
+	// QuestionID answers a question the agent asked with ask_user. Without
+	// it, a message answers the latest question sent to its source.
+	QuestionID string `json:"question_id"`
+}
+
+// deliverAgentMessage sends payload to the agent taskID, merging extra into
+// the message metadata, and returns the response body. On failure it writes
+// the error and returns false.
+func (s *Server) deliverAgentMessage(w http.ResponseWriter, r *http.Request, taskID string, payload agentMessageInput, extra map[string]any) (map[string]any, bool) {
+	resp, status, err := s.sendAgentMessage(r.Context(), "agent_message", taskID, payload, extra)
+	if err != nil {
+		writeError(w, status, err)
+		return nil, false
+	}
+	return resp, true
+}
+
+// sendAgentMessage is deliverAgentMessage for callers without an HTTP
+// response. On failure it returns the status code that fits the error.
+func (s *Server) sendAgentMessage(ctx context.Context, endpoint, taskID string, payload agentMessageInput, extra map[string]any) (map[string]any, int, error) {
+	message := strings.TrimSpace(payload.Message)
+	priority, err := schema.ValidatePriority(payload.Priority)
+	if err != nil {
+		s.Priorities.record(endpoint, payload.Priority)
+		return nil, http.StatusBadRequest, errBadRequest(err.Error())
+	}
+	// Verify the task exists before delivering, creating it when a
+	// provisioning rule covers the ID.
+	if _, err := s.Tasks.Get(ctx, taskID); err != nil {
+		if s.Runtime == nil {
+			return nil, http.StatusNotFound, errNotFound("task")
+		}
+		if _, err := s.Runtime.ProvisionAgent(ctx, taskID); errors.Is(err, engine.ErrUnknownAgent) {
+			return nil, http.StatusNotFound, errNotFound("task")
+		} else if err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+	}
+	source := strings.TrimSpace(payload.Source)
+	contextData, serviceID, err := normalizeServiceMessageContext(payload.ServiceID, payload.Context)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if source == "" && serviceID != "" {
+		source = serviceID
+	}
+	s.Runtime.EnsureAgentLoop(taskID)
+	requestID := strings.TrimSpace(payload.RequestID)
+	if requestID == "" {
+		requestID = idgen.New()
+	}
+	meta := map[string]any{
+		"request_id": requestID,
+		"kind":       "message",
+	}
+	if strings.TrimSpace(payload.Priority) != "" {
+		meta["priority"] = string(priority)
+	}
+	if len(contextData) > 0 {
+		meta["context"] = contextData
+	}
+	if serviceID != "" {
+		meta["service_id"] = serviceID
+	}
+	if questionID := strings.TrimSpace(payload.QuestionID); questionID != "" {
+		meta["question_id"] = questionID
+	}
+	for key, value := range extra {
+		meta[key] = value
+	}
+	message, eventPayload := s.Runtime.PreprocessInbound(ctx, taskID, message, meta)
+	s.Runtime.ClassifyInbound(ctx, message, meta)
+	if _, err := s.Runtime.SendMessageWithPayload(ctx, taskID, message, source, meta, eventPayload); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	resp := map[string]any{
+		"ok":         true,
+		"request_id": requestID,
+	}
+	if serviceID != "" {
+		resp["service_id"] = serviceID
+	}
+	return resp, http.StatusOK, nil
+}
+
 func (s *Server) handleTaskCancel(w http.ResponseWriter, r *http.Request, taskID string) {
 	if r.Method != http.MethodPost {
 		writeMethodNotAllowed(w)
@@ -358,6 +629,41 @@ func (s *Server) handleTaskKill(w http.ResponseWriter, r *http.Request, taskID s
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+// handleTaskPriority changes a queued task's priority and labels so it can be
+// expedited without cancelling and respawning it.
+func (s *Server) handleTaskPriority(w http.ResponseWriter, r *http.Request, taskID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	var payload struct {
+		Priority string         `json:"priority"`
+		Labels   map[string]any `json:"labels"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(payload.Priority) == "" && len(payload.Labels) == 0 {
+		writeError(w, http.StatusBadRequest, errBadRequest("priority or labels is required"))
+		return
+	}
+	task, err := s.Tasks.Reprioritize(r.Context(), taskID, payload.Priority, payload.Labels)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, tasks.ErrNotQueued) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// handleStreamSubscribe streams events from ?streams= (history, task_output
+// and errors by default) as server-sent events, filtered server-side by
+// min_priority, agent, kinds and exclude_kinds. The activity stream adds
+// agent activity frames, whose kinds are the frame types.
 func (s *Server) handleStreamSubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeMethodNotAllowed(w)
@@ -368,7 +674,55 @@ func (s *Server) handleStreamSubscribe(w http.ResponseWriter, r *http.Request) {
 		streamsParam = schema.StreamHistory + "," + schema.StreamTaskOutput + "," + schema.StreamErrors
 	}
 	streamList := splitComma(streamsParam)
+	filter, err := parseSubscribeFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.namespace = requestNamespace(r)
+
+	s.serveEvents(w, r, func(ctx context.Context) <-chan eventbus.Event {
+		events := s.Bus.Subscribe(ctx, streamList)
+		if slices.Contains(streamList, schema.StreamActivity) {
+			events = mergeEvents(ctx, events, s.Bus.SubscribeTopic(ctx, schema.StreamActivity))
+		}
+		return filter.apply(ctx, events)
+	})
+}
+
+// mergeEvents forwards the events of a and b until both close.
+func mergeEvents(ctx context.Context, a, b <-chan eventbus.Event) <-chan eventbus.Event {
+	out := make(chan eventbus.Event)
+	go func() {
+		defer close(out)
+		for a != nil || b != nil {
+			var evt eventbus.Event
+			var ok bool
+			select {
+			case evt, ok = <-a:
+				if !ok {
+					a = nil
+					continue
+				}
+			case evt, ok = <-b:
+				if !ok {
+					b = nil
+					continue
+				}
+			}
+			select {
+			case out <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
 
+// serveEvents writes events from subscribe as server-sent events until the
+// client disconnects.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, subscribe func(context.Context) <-chan eventbus.Event) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		writeError(w, http.StatusInternalServerError, errNotFound("streaming support"))
@@ -382,7 +736,7 @@ func (s *Server) handleStreamSubscribe(w http.ResponseWriter, r *http.Request) {
 	flusher.Flush()
 
 	ctx := r.Context()
-	sub := s.Bus.Subscribe(ctx, streamList)
+	sub := subscribe(ctx)
 	heartbeat := time.NewTicker(15 * time.Second)
 	defer heartbeat.Stop()
 
@@ -398,6 +752,12 @@ func (s *Server) handleStreamSubscribe(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			payload, _ := json.Marshal(evt)
+			if evt.Stream == schema.StreamActivity {
+				// Activity frames are sent as themselves, named so clients
+				// can tell them from stream events.
+				payload, _ = json.Marshal(evt.Payload)
+				_, _ = w.Write([]byte("event: activity\n"))
+			}
 			_, _ = w.Write([]byte("data: "))
 			_, _ = w.Write(payload)
 			_, _ = w.Write([]byte("\n\n"))
@@ -7,16 +7,40 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/flitsinc/go-agents/internal/access"
 	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/contacts"
+	"github.com/flitsinc/go-agents/internal/documents"
 	"github.com/flitsinc/go-agents/internal/engine"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/health"
+	"github.com/flitsinc/go-agents/internal/maintenance"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/objectstore"
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/scheduler"
 	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/share"
 	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/teams"
+	"github.com/flitsinc/go-agents/internal/templates"
 	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-agents/internal/usage"
 	"github.com/flitsinc/go-llms/content"
 	"github.com/flitsinc/go-llms/llms"
 	llmtools "github.com/flitsinc/go-llms/tools"
@@ -152,6 +176,141 @@ func TestServerCreateTaskUpsert(t *testing.T) {
 	}
 }
 
+func TestServerCreateAgentConcurrency(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	for _, bad := range []any{0, 2.5, "3", engine.MaxAgentConcurrency + 1} {
+		resp := doJSON(t, client, "POST", "/api/tasks", map[string]any{
+			"type":    "agent",
+			"id":      "responder",
+			"payload": map[string]any{"concurrency": bad},
+		})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for concurrency %v, got %d", bad, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+	resp := doJSON(t, client, "POST", "/api/tasks", map[string]any{
+		"type":    "agent",
+		"id":      "responder",
+		"payload": map[string]any{"concurrency": 4},
+	})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create task status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	if n := rt.AgentConcurrency("responder"); n != 4 {
+		t.Fatalf("expected concurrency 4, got %d", n)
+	}
+}
+
+func TestServerPatchAgentToolset(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "browser", Type: "agent", Owner: "browser"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	resp := doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"toolset": map[string]any{"deny": "exec"}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a malformed toolset to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "PATCH", "/api/agents/nobody", map[string]any{"toolset": nil})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an unknown agent to 404, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"toolset": map[string]any{"deny": []string{"exec"}}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("patch status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	if toolset, _ := rt.AgentToolset(ctx, "browser"); toolset.Allows("exec") || !toolset.Allows("send_task") {
+		t.Fatalf("expected exec denied, got %+v", toolset)
+	}
+
+	resp = doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"toolset": nil})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	if toolset, _ := rt.AgentToolset(ctx, "browser"); !toolset.IsZero() {
+		t.Fatalf("expected the toolset cleared, got %+v", toolset)
+	}
+}
+
+func TestServerPatchAgentBudget(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	rt.Budget = budget.Budget{MonthlyUSD: 50}
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "browser", Type: "agent", Owner: "browser"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	turn, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "browser", ParentID: "browser"})
+	if err != nil {
+		t.Fatalf("spawn turn: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, turn.ID, "llm_usage", map[string]any{"input_tokens": 700, "output_tokens": 400, "cost_microusd": 8100}); err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+
+	resp := doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"budget": map[string]any{"daily_tokens": 1000, "action": "stop"}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown action to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"budget": map[string]any{"daily_tokens": 1000, "action": "fail"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("patch status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "GET", "/api/agents/browser/budget", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("budget status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var status engine.BudgetStatus
+	decodeJSONResponse(t, resp, &status)
+	if status.Budget != (budget.Budget{DailyTokens: 1000, MonthlyUSD: 50, Action: budget.Fail}) {
+		t.Fatalf("expected the agent's budget over the runtime's, got %+v", status.Budget)
+	}
+	if status.Today.CostMicroUSD != 8100 || status.Exhausted == nil || status.Exhausted.Window != "daily" || status.Exhausted.Used != 1100 {
+		t.Fatalf("expected the daily token cap reached, got %+v", status)
+	}
+
+	resp = doJSON(t, client, "PATCH", "/api/agents/browser", map[string]any{"budget": nil})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	if stored, _ := rt.AgentBudget(ctx, "browser"); !stored.IsZero() {
+		t.Fatalf("expected the budget cleared, got %+v", stored)
+	}
+}
+
 func TestServerTaskSendIncludesServiceIDInMessageMetadata(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -246,6 +405,201 @@ func TestServerTaskSendIncludesServiceIDInMessageMetadata(t *testing.T) {
 	}
 }
 
+func TestServerTaskSendProvisionsUnknownAgentsMatchingARule(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	rt.ProvisionRules = []provision.Rule{{Pattern: "support-*", Payload: map[string]any{"system": "Support for {{agent_id}}."}}}
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "POST", "/api/tasks/support-acme/send", map[string]any{"message": "hello"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("send status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	task, err := mgr.Get(ctx, "support-acme")
+	if err != nil {
+		t.Fatalf("expected support-acme provisioned: %v", err)
+	}
+	if task.Type != "agent" || task.Metadata["source"] != "auto_provision" || task.Payload["system"] != "Support for support-acme." {
+		t.Fatalf("unexpected task %+v", task)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/tasks/sales-acme/send", map[string]any{"message": "hello"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unmatched target, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerTaskSendContinuesTheCallersTrace(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "operator", Type: "agent", Owner: "operator"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest("POST", "http://in-process/api/tasks/operator/send", strings.NewReader(`{"message":"hello"}`))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("send status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	summaries, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "operator", Limit: 10})
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("expected one message, got %d, %v", len(summaries), err)
+	}
+	events, err := bus.Read(ctx, schema.StreamTaskInput, []string{summaries[0].ID}, "")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("read message: %v", err)
+	}
+	if events[0].Metadata["trace_id"] != traceID || !strings.Contains(schema.GetMetaString(events[0].Metadata, "traceparent"), traceID) {
+		t.Fatalf("expected the message in the caller's trace, got %+v", events[0].Metadata)
+	}
+}
+
+func TestServerTaskSendPreprocessesInboundMessages(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	rt.Preprocessors = []preprocess.Step{{Type: preprocess.StepTrim}}
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "operator", Type: "agent", Owner: "operator"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	resp := doJSON(t, client, "PATCH", "/api/agents/operator", map[string]any{"preprocessors": []any{map[string]any{"type": "regex", "pattern": "("}}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid pattern to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "PATCH", "/api/agents/operator", map[string]any{"preprocessors": []any{
+		map[string]any{"type": "regex", "pattern": `\bpls\b`, "replace": "please", "service_ids": []string{"telegram-bot"}},
+		map[string]any{"type": "extract_attachments"},
+	}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("patch status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	send := func(message, serviceID string) eventbus.Event {
+		t.Helper()
+		resp := doJSON(t, client, "POST", "/api/tasks/operator/send", map[string]any{"message": message, "service_id": serviceID})
+		var sent map[string]any
+		decodeJSONResponse(t, resp, &sent)
+		summaries, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "operator", Limit: 50})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, summary := range summaries {
+			events, _ := bus.Read(ctx, schema.StreamTaskInput, []string{summary.ID}, "")
+			if len(events) == 1 && events[0].Metadata["request_id"] == sent["request_id"] {
+				return events[0]
+			}
+		}
+		t.Fatalf("no event for %v", sent)
+		return eventbus.Event{}
+	}
+
+	original := "  pls review https://example.com/spec.pdf  \n"
+	evt := send(original, "telegram-bot")
+	if evt.Body != "please review https://example.com/spec.pdf" {
+		t.Fatalf("unexpected body %q", evt.Body)
+	}
+	if evt.Payload["original_message"] != strings.TrimSpace(original) {
+		t.Fatalf("expected the original kept in the payload, got %#v", evt.Payload)
+	}
+	parts := schema.ParseParts(evt.Metadata[schema.MetaParts])
+	if len(parts) != 1 || parts[0].Type != schema.PartFile || parts[0].Name != "spec.pdf" {
+		t.Fatalf("expected the pdf as a file part, got %#v", evt.Metadata[schema.MetaParts])
+	}
+
+	evt = send("pls ship it", "slack-bot")
+	if evt.Body != "pls ship it" || evt.Payload != nil {
+		t.Fatalf("expected the connector's step skipped, got %q %#v", evt.Body, evt.Payload)
+	}
+}
+
+func TestServerAgentRunModelOverride(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	rt.ModelOverrides = []string{"anthropic/claude-opus-4-1"}
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(context.Background(), tasks.Spec{ID: "operator", Type: "agent", Owner: "operator", Mode: "async"}); err != nil {
+		t.Fatalf("spawn operator: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/agents/operator/run", map[string]any{
+		"message":  "hello",
+		"provider": "anthropic",
+		"model":    "claude-3-5-haiku-latest",
+	})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected disallowed override to be forbidden, got %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "POST", "/api/agents/operator/run", map[string]any{
+		"message":    "hello",
+		"request_id": "req-run",
+		"provider":   "anthropic",
+		"model":      "claude-opus-4-1",
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("run status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var runResp map[string]any
+	decodeJSONResponse(t, resp, &runResp)
+	if override, _ := runResp["model_override"].(map[string]any); override["model"] != "claude-opus-4-1" {
+		t.Fatalf("expected model_override in response, got %#v", runResp)
+	}
+
+	summaries, err := bus.List(context.Background(), "task_input", eventbus.ListOptions{ScopeType: "task", ScopeID: "operator", Limit: 20})
+	if err != nil || len(summaries) == 0 {
+		t.Fatalf("expected task_input events, got %d (%v)", len(summaries), err)
+	}
+	events, err := bus.Read(context.Background(), "task_input", []string{summaries[0].ID}, "")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("read task_input: %v", err)
+	}
+	if events[0].Metadata["model_override"] != "claude-opus-4-1" || events[0].Metadata["provider_override"] != "anthropic" {
+		t.Fatalf("expected override in message metadata, got %#v", events[0].Metadata)
+	}
+}
+
 func TestServerTaskSendDoesNotInferServiceIDFromSource(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -579,6 +933,49 @@ func TestServerTaskAssistantOutput(t *testing.T) {
 	}
 }
 
+func TestServerTaskAssistantOutputParts(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(context.Background(), tasks.Spec{ID: "operator", Type: "agent", Owner: "operator"}); err != nil {
+		t.Fatalf("spawn operator: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/tasks/operator/assistant_output", map[string]any{
+		"text":  "chart attached",
+		"parts": []map[string]any{{"type": "video"}},
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid parts to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/tasks/operator/assistant_output", map[string]any{
+		"text":  "chart attached",
+		"parts": []map[string]any{{"type": "image", "url": "https://example.com/chart.png", "name": "chart.png"}},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("assistant_output status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	updates, err := mgr.ListUpdatesSince(context.Background(), "operator", "", "assistant_output", 20)
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("expected one assistant_output update, got %d (%v)", len(updates), err)
+	}
+	payload := updates[0].Payload
+	if got := schema.GetMetaString(payload, "text"); got != "chart attached\n\n[image: chart.png] https://example.com/chart.png" {
+		t.Fatalf("expected text to list the attachment, got %q", got)
+	}
+	parts := schema.ParseParts(payload[schema.MetaParts])
+	if len(parts) != 2 || parts[0].Text != "chart attached" || parts[1].URL != "https://example.com/chart.png" {
+		t.Fatalf("expected text and image parts, got %+v", parts)
+	}
+}
+
 func TestServerStreamSubscribe(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -630,49 +1027,550 @@ func TestServerStreamSubscribe(t *testing.T) {
 	}
 }
 
-func doJSON(t *testing.T, client *http.Client, method, path string, payload any) *http.Response {
-	t.Helper()
-	var body *bytes.Reader
-	if payload != nil {
-		data, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatalf("marshal: %v", err)
+func TestServerStreamSubscribeActivityFrames(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus}
+	mux := server.Handler()
+
+	req := testutil.NewRequest(http.MethodGet, "/api/streams/subscribe?streams=history,activity&kinds=text_delta", nil)
+	rec := testutil.NewStreamRecorder()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() {
+		mux.ServeHTTP(rec, req.WithContext(ctx))
+		_ = rec.Close()
+	}()
+	defer rec.Body.Close()
+
+	frames := make(chan string, 4)
+	go func() {
+		reader := bufio.NewReader(rec.Body)
+		named := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "event: activity\n" {
+				named = true
+			} else if data, ok := strings.CutPrefix(line, "data: "); ok && named {
+				frames <- strings.TrimSpace(data)
+				named = false
+			}
 		}
-		body = bytes.NewReader(data)
-	} else {
-		body = bytes.NewReader(nil)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !bus.HasTopicSubscribers(schema.StreamActivity) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
 	}
-	req, err := http.NewRequest(method, "http://in-process"+path, body)
-	if err != nil {
-		t.Fatalf("new request: %v", err)
+	for _, frame := range []map[string]any{
+		{"type": "tool_start", "tool_name": "noop"},
+		{"type": "text_delta", "text": "hel"},
+	} {
+		bus.Publish(schema.StreamActivity, eventbus.Event{ScopeType: "task", ScopeID: "agent-a", Metadata: map[string]any{"kind": frame["type"]}, Payload: frame})
 	}
-	if payload != nil {
-		req.Header.Set("Content-Type", "application/json")
+
+	select {
+	case data := <-frames:
+		var frame map[string]any
+		if err := json.Unmarshal([]byte(data), &frame); err != nil {
+			t.Fatalf("decode frame %s: %v", data, err)
+		}
+		if frame["type"] != "text_delta" || frame["text"] != "hel" {
+			t.Fatalf("expected only the text delta frame, got %v", frame)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timeout waiting for an activity frame")
 	}
-	resp, err := client.Do(req)
+}
+
+func TestStreamSubscribeFiltersByPriorityAgentAndKind(t *testing.T) {
+	query := url.Values{}
+	query.Set("min_priority", "wake")
+	query.Set("agent", "agent-a")
+	query.Set("exclude_kinds", "task_health")
+	filter, err := parseSubscribeFilter(query)
 	if err != nil {
-		t.Fatalf("do request: %v", err)
+		t.Fatalf("parse: %v", err)
+	}
+	cases := []struct {
+		name string
+		evt  eventbus.Event
+		want bool
+	}{
+		{"wake for agent", eventbus.Event{ScopeType: "task", ScopeID: "agent-a", Metadata: map[string]any{"kind": "wake", "priority": "wake"}}, true},
+		{"normal message wakes", eventbus.Event{ScopeType: "task", ScopeID: "agent-a", Metadata: map[string]any{"kind": "message", "priority": "normal"}}, true},
+		{"low history", eventbus.Event{ScopeType: "task", ScopeID: "agent-a", Metadata: map[string]any{"kind": "history_entry", "priority": "low"}}, false},
+		{"other agent", eventbus.Event{ScopeType: "task", ScopeID: "agent-b", Metadata: map[string]any{"kind": "wake", "priority": "wake"}}, false},
+		{"global naming agent", eventbus.Event{ScopeType: "global", ScopeID: "*", Metadata: map[string]any{"agent_id": "agent-a", "priority": "interrupt"}}, true},
+		{"excluded kind", eventbus.Event{ScopeType: "task", ScopeID: "agent-a", Metadata: map[string]any{"kind": "task_health", "priority": "wake"}}, false},
+	}
+	for _, tc := range cases {
+		if got := filter.matches(tc.evt); got != tc.want {
+			t.Errorf("%s: matches = %v, want %v", tc.name, got, tc.want)
+		}
 	}
-	return resp
-}
 
-func decodeJSONResponse(t *testing.T, resp *http.Response, dest any) {
-	t.Helper()
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(dest); err != nil {
-		t.Fatalf("decode response: %v", err)
+	query = url.Values{}
+	query.Set("kinds", "wake")
+	filter, _ = parseSubscribeFilter(query)
+	if filter.matches(eventbus.Event{Metadata: map[string]any{"kind": "message"}}) {
+		t.Fatalf("expected kinds to select only listed kinds")
+	}
+	if _, err := parseSubscribeFilter(url.Values{"min_priority": {"urgent"}}); err == nil {
+		t.Fatalf("expected an unknown min_priority to be rejected")
 	}
 }
 
-func readBody(t *testing.T, resp *http.Response) string {
-	t.Helper()
-	defer resp.Body.Close()
-	data, _ := io.ReadAll(resp.Body)
-	return string(data)
-}
+func TestServerStreamCursor(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
 
-type apiFakeProvider struct{}
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	first, err := bus.Push(context.Background(), eventbus.EventInput{Stream: "errors", Body: "first"})
+	if err != nil {
+		t.Fatalf("push first: %v", err)
+	}
+	second, err := bus.Push(context.Background(), eventbus.EventInput{Stream: "errors", Body: "second"})
+	if err != nil {
+		t.Fatalf("push second: %v", err)
+	}
+
+	resp := doJSON(t, client, "PUT", "/api/streams/errors/cursor?consumer=poller", map[string]any{"event_id": first.ID})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put cursor status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "GET", "/api/streams/errors/cursor?consumer=poller", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get cursor status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var cursor eventbus.Cursor
+	decodeJSONResponse(t, resp, &cursor)
+	if cursor.EventID != first.ID {
+		t.Fatalf("expected cursor %s, got %#v", first.ID, cursor)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/streams/errors?consumer=poller", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("list status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var listed struct {
+		Events []eventbus.Event `json:"events"`
+		Next   string           `json:"next"`
+	}
+	decodeJSONResponse(t, resp, &listed)
+	if len(listed.Events) != 1 || listed.Events[0].ID != second.ID {
+		t.Fatalf("expected only the event after the cursor, got %#v", listed.Events)
+	}
+	if listed.Next != second.ID {
+		t.Fatalf("expected next %s, got %s", second.ID, listed.Next)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/streams/errors/cursor", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without consumer, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerStreamFieldFilters(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	failed, err := bus.Push(context.Background(), eventbus.EventInput{
+		Stream:   "errors",
+		Body:     "failed",
+		Metadata: map[string]any{"task_id": "exec-1"},
+		Payload:  map[string]any{"result": map[string]any{"status": "failed"}},
+	})
+	if err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if _, err := bus.Push(context.Background(), eventbus.EventInput{
+		Stream:   "errors",
+		Body:     "ok",
+		Metadata: map[string]any{"task_id": "exec-1"},
+		Payload:  map[string]any{"result": map[string]any{"status": "ok"}},
+	}); err != nil {
+		t.Fatalf("push ok: %v", err)
+	}
+
+	resp := doJSON(t, client, "GET", "/api/streams/errors?metadata.task_id=exec-1&payload.result.status=failed", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("list status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var listed struct {
+		Events []eventbus.Event `json:"events"`
+	}
+	decodeJSONResponse(t, resp, &listed)
+	if len(listed.Events) != 1 || listed.Events[0].ID != failed.ID {
+		t.Fatalf("expected only the failed event, got %#v", listed.Events)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/streams/errors?payload.result.status'=x", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid path, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerStreamBulkAckAndUnread(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	var ids []string
+	for _, status := range []string{"failed", "ok"} {
+		evt, err := bus.Push(context.Background(), eventbus.EventInput{
+			Stream:  "errors",
+			Body:    status,
+			Payload: map[string]any{"result": map[string]any{"status": status}},
+		})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		ids = append(ids, evt.ID)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/streams/errors/ack-all", map[string]any{"reader": "ops"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ack-all status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var result eventbus.BulkResult
+	decodeJSONResponse(t, resp, &result)
+	if result.Changed != 2 || result.Truncated {
+		t.Fatalf("expected both events acked, got %+v", result)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/streams/errors/unread", map[string]any{
+		"reader":  "ops",
+		"filters": map[string]string{"payload.result.status": "failed"},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unread status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	decodeJSONResponse(t, resp, &result)
+	if result.Changed != 1 || result.EventIDs[0] != ids[0] {
+		t.Fatalf("expected only the failed event marked unread, got %+v", result)
+	}
+	events, err := bus.Read(context.Background(), "errors", ids, "ops")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	for _, evt := range events {
+		if evt.Read == (evt.ID == ids[0]) {
+			t.Fatalf("unexpected read state for %s: %v", evt.ID, evt.Read)
+		}
+	}
+
+	resp = doJSON(t, client, "POST", "/api/streams/errors/ack-all", map[string]any{"reader": "ops", "ids": ids})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for ids on ack-all, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "POST", "/api/streams/errors/unread", map[string]any{"limit": 5})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without reader, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerMaintenanceWindows(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Maintenance: maintenance.NewStore(db)}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "POST", "/api/maintenance", map[string]any{
+		"reason":           "nightly backup",
+		"duration_seconds": 600,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create window status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var window maintenance.Window
+	decodeJSONResponse(t, resp, &window)
+	if window.ID == "" || window.EndsAt.Sub(window.StartsAt) != 10*time.Minute {
+		t.Fatalf("unexpected window: %#v", window)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/maintenance", nil)
+	var windows []maintenance.Window
+	decodeJSONResponse(t, resp, &windows)
+	if len(windows) != 1 || windows[0].ID != window.ID {
+		t.Fatalf("expected created window in list, got %#v", windows)
+	}
+
+	resp = doJSON(t, client, "DELETE", "/api/maintenance/"+window.ID, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("end window status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "GET", "/api/maintenance", nil)
+	windows = nil
+	decodeJSONResponse(t, resp, &windows)
+	if len(windows) != 0 {
+		t.Fatalf("expected no open windows after ending, got %#v", windows)
+	}
+}
+
+func TestServerAdminMonitors(t *testing.T) {
+	registry := monitors.NewRegistry()
+	if err := registry.Register("task_health", time.Hour, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	server := &Server{Monitors: registry, RestartToken: "tok"}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "GET", "/api/admin/monitors", nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected token to be required, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("PATCH", "http://in-process/api/admin/monitors/task_health", strings.NewReader(`{"enabled":false,"interval":"2m"}`))
+	req.Header.Set("X-Restart-Token", "tok")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	var st monitors.Status
+	decodeJSONResponse(t, resp, &st)
+	if st.Enabled || st.Interval != "2m0s" {
+		t.Fatalf("unexpected status after patch: %#v", st)
+	}
+
+	req, _ = http.NewRequest("GET", "http://in-process/api/admin/monitors", nil)
+	req.Header.Set("X-Restart-Token", "tok")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var list []monitors.Status
+	decodeJSONResponse(t, resp, &list)
+	if len(list) != 1 || list[0].Name != "task_health" || list[0].Enabled {
+		t.Fatalf("unexpected monitors: %#v", list)
+	}
+
+	req, _ = http.NewRequest("GET", "http://in-process/api/admin/monitors/nope", nil)
+	req.Header.Set("X-Restart-Token", "tok")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown monitor, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerThreadsMarkdown(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := rt.SendMessageWithMeta(context.Background(), "agent-b", "ping", "agent-a", nil); err != nil {
+		t.Fatalf("send ping: %v", err)
+	}
+	if _, err := rt.SendMessageWithMeta(context.Background(), "agent-a", "pong", "agent-b", nil); err != nil {
+		t.Fatalf("send pong: %v", err)
+	}
+
+	resp := doJSON(t, client, "GET", "/api/threads?agents=agent-a,agent-b&format=markdown", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("thread status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, "## agent-a → agent-b") || strings.Index(body, "ping") > strings.Index(body, "pong") {
+		t.Fatalf("unexpected markdown transcript:\n%s", body)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/threads?agents=agent-a", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a single agent, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerThreadsIncludeArchivedMessages(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock))
+	mgr := tasks.NewManager(db, bus, tasks.WithClock(clock))
+	rt := engine.NewRuntime(bus, mgr, nil)
+	archiver := archive.New(db, bus, mgr, objectstore.Dir{Root: t.TempDir()}, archive.Config{EventRetentionDays: 30}, archive.WithClock(clock))
+	rt.Archive = archiver
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, Archive: archiver}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := rt.SendMessageWithMeta(ctx, "agent-b", "ping", "agent-a", nil); err != nil {
+		t.Fatalf("send ping: %v", err)
+	}
+	now = now.AddDate(0, 0, 40)
+	if _, err := rt.SendMessageWithMeta(ctx, "agent-a", "pong", "agent-b", nil); err != nil {
+		t.Fatalf("send pong: %v", err)
+	}
+	resp := doJSON(t, client, "POST", "/api/admin/archive/run", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("run status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var result archive.Result
+	decodeJSONResponse(t, resp, &result)
+	if result.Events == 0 {
+		t.Fatalf("expected the old message archived, got %+v", result)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/threads?agents=agent-a,agent-b&format=markdown", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("thread status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	body := readBody(t, resp)
+	if !strings.Contains(body, "ping") || strings.Index(body, "ping") > strings.Index(body, "pong") {
+		t.Fatalf("expected the archived message in the transcript:\n%s", body)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/admin/archive/events?stream=task_input&scope_type=task&to=2026-03-02T00:00:00Z", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("events status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var page struct {
+		Events []eventbus.Event `json:"events"`
+	}
+	decodeJSONResponse(t, resp, &page)
+	if len(page.Events) != 1 || page.Events[0].Body != "ping" {
+		t.Fatalf("expected only the archived ping before the cutoff, got %+v", page.Events)
+	}
+}
+
+func TestServerAgentDocuments(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Documents: documents.NewStore(db)}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(context.Background(), tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/agents/helper/documents", map[string]any{
+		"title":        "Runbook",
+		"content":      "Restart the worker pool when queues back up.",
+		"token_budget": 200,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("add document status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var doc documents.Document
+	decodeJSONResponse(t, resp, &doc)
+	if doc.ID == "" || doc.TokenBudget != 200 {
+		t.Fatalf("unexpected document: %#v", doc)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/helper/documents", nil)
+	var docs []documents.Document
+	decodeJSONResponse(t, resp, &docs)
+	if len(docs) != 1 || docs[0].ID != doc.ID {
+		t.Fatalf("expected listed document, got %#v", docs)
+	}
+
+	resp = doJSON(t, client, "DELETE", "/api/agents/helper/documents/"+doc.ID, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "DELETE", "/api/agents/helper/documents/"+doc.ID, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 on second delete, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "GET", "/api/agents/missing/documents", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown agent, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func doJSON(t *testing.T, client *http.Client, method, path string, payload any) *http.Response {
+	t.Helper()
+	var body *bytes.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, "http://in-process"+path, body)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	return resp
+}
+
+func decodeJSONResponse(t *testing.T, resp *http.Response, dest any) {
+	t.Helper()
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(dest); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return string(data)
+}
+
+type apiFakeProvider struct{}
 
 type apiFakeStream struct{}
 
@@ -698,3 +1596,1278 @@ func (s *apiFakeStream) Iter() func(func(llms.StreamStatus) bool) {
 		yield(llms.StreamStatusText)
 	}
 }
+
+func TestServerAgentHistoryVerifyAndRepair(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(context.Background(), tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	if _, err := bus.Push(context.Background(), eventbus.EventInput{
+		Stream:    "history",
+		ScopeType: "task",
+		ScopeID:   "helper",
+		Body:      "hi",
+		Payload: map[string]any{
+			"agent_id":   "helper",
+			"generation": 1,
+			"type":       "user_message",
+			"role":       "user",
+			"content":    "hi",
+		},
+	}); err != nil {
+		t.Fatalf("push history: %v", err)
+	}
+
+	resp := doJSON(t, client, "GET", "/api/agents/helper/history/verify", nil)
+	var report engine.HistoryReport
+	decodeJSONResponse(t, resp, &report)
+	if len(report.Violations) != 2 {
+		t.Fatalf("expected missing preamble violations, got %#v", report.Violations)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/agents/helper/history/repair", nil)
+	decodeJSONResponse(t, resp, &report)
+	if len(report.Repaired) != 2 {
+		t.Fatalf("expected two repairs, got %#v", report.Repaired)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/helper/history/verify", nil)
+	decodeJSONResponse(t, resp, &report)
+	if !report.OK() {
+		t.Fatalf("expected clean history after repair, got %#v", report.Violations)
+	}
+}
+
+func TestServerAgentInboxClear(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil, engine.WithClock(func() time.Time {
+		return time.Now().UTC().Add(time.Hour)
+	}))
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	push := func(source string) eventbus.Event {
+		evt, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    "task_input",
+			ScopeType: "task",
+			ScopeID:   "helper",
+			Body:      "ping from " + source,
+			Metadata:  map[string]any{"kind": "message", "source": source, "priority": "wake"},
+		})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		return evt
+	}
+	stale := push("alpha")
+	kept := push("beta")
+
+	resp := doJSON(t, client, "POST", "/api/agents/helper/inbox/clear", map[string]any{
+		"kind":       "message",
+		"source":     "alpha",
+		"older_than": "30m",
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var result engine.InboxClearResult
+	decodeJSONResponse(t, resp, &result)
+	if result.Cleared != 1 || len(result.EventIDs["task_input"]) != 1 || result.EventIDs["task_input"][0] != stale.ID {
+		t.Fatalf("expected only the alpha message cleared, got %#v", result)
+	}
+
+	events, err := bus.Read(ctx, "task_input", []string{stale.ID, kept.ID}, "helper")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	for _, evt := range events {
+		if evt.ID == stale.ID && !evt.Read {
+			t.Fatalf("expected cleared event to be acked")
+		}
+		if evt.ID == kept.ID && evt.Read {
+			t.Fatalf("expected unmatched event to stay unread")
+		}
+	}
+
+	summaries, err := bus.List(ctx, "history", eventbus.ListOptions{ScopeType: "task", ScopeID: "helper", Limit: 10})
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Subject != "system:inbox_cleared" {
+		t.Fatalf("expected inbox_cleared history entry, got %#v", summaries)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/agents/helper/inbox/clear", map[string]any{"older_than": "soon"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request for invalid older_than, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerAgentSnooze(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/agents/helper/snooze", map[string]any{"duration": "2h"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("snooze status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var state engine.SnoozeState
+	decodeJSONResponse(t, resp, &state)
+	if !state.Snoozed || state.Until == nil {
+		t.Fatalf("expected the agent snoozed, got %#v", state)
+	}
+	if err := rt.EmitAssistantOutput(ctx, "helper", "All done.", nil); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/helper/snooze", nil)
+	decodeJSONResponse(t, resp, &state)
+	if state.Held != 1 {
+		t.Fatalf("expected one held message, got %#v", state)
+	}
+
+	resp = doJSON(t, client, "DELETE", "/api/agents/helper/snooze", nil)
+	var ended map[string]any
+	decodeJSONResponse(t, resp, &ended)
+	if ended["ended"] != true || ended["delivered"] != float64(1) {
+		t.Fatalf("expected the snooze ended with one delivery, got %#v", ended)
+	}
+	if _, found, err := mgr.LatestUpdate(ctx, "helper", "assistant_output"); err != nil || !found {
+		t.Fatalf("expected the digest delivered, found=%v err=%v", found, err)
+	}
+
+	for _, body := range []map[string]any{{"duration": "soon"}, {"duration": "-1h"}, {"duration": "720h"}} {
+		resp = doJSON(t, client, "POST", "/api/agents/helper/snooze", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected bad request for %v, got %d", body, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestServerAgentTerminate(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	child, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", ParentID: "helper"})
+	if err != nil {
+		t.Fatalf("spawn child: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/agents/helper/terminate", map[string]any{"dry_run": true})
+	var report engine.TerminateReport
+	decodeJSONResponse(t, resp, &report)
+	if !report.DryRun || len(report.Tasks) != 2 || len(report.Killed) != 0 {
+		t.Fatalf("unexpected dry run report %#v", report)
+	}
+	if task, _ := mgr.Get(ctx, child.ID); tasks.IsTerminalStatus(task.Status) {
+		t.Fatalf("expected a dry run to leave the child open, got %s", task.Status)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/agents/helper/terminate", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("terminate status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	decodeJSONResponse(t, resp, &report)
+	if report.DryRun || len(report.Killed) != 2 || report.Reason != "terminated" {
+		t.Fatalf("unexpected report %#v", report)
+	}
+	if task, _ := mgr.Get(ctx, child.ID); task.Status != tasks.StatusCancelled {
+		t.Fatalf("expected the child cancelled, got %s", task.Status)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/helper/loop", nil)
+	var health engine.LoopHealth
+	decodeJSONResponse(t, resp, &health)
+	if health.State != engine.LoopStopped {
+		t.Fatalf("expected the loop stopped, got %#v", health)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/helper/terminate", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected method not allowed, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerTeams(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "lead", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+
+	resp := doJSON(t, client, "POST", "/api/teams", map[string]any{
+		"supervisor_id":   "lead",
+		"workers":         []map[string]any{{"id": "analyst"}, {"id": "editor", "system": "You edit."}},
+		"max_concurrency": 1,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var team teams.Team
+	decodeJSONResponse(t, resp, &team)
+	if team.ID != "lead" || len(team.Workers) != 2 || team.MaxConcurrency != 1 {
+		t.Fatalf("unexpected team %+v", team)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/teams", nil)
+	var list struct {
+		Teams []teams.Team `json:"teams"`
+	}
+	decodeJSONResponse(t, resp, &list)
+	if len(list.Teams) != 1 || list.Teams[0].ID != "lead" {
+		t.Fatalf("expected one team, got %+v", list.Teams)
+	}
+
+	resp = doJSON(t, client, "POST", "/api/teams/lead/assign", map[string]any{"to": "editor", "body": "Tighten the intro."})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("assign status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var assignment teams.Assignment
+	decodeJSONResponse(t, resp, &assignment)
+	if assignment.To != "editor" || assignment.From != "lead" || assignment.StartedAt.IsZero() {
+		t.Fatalf("expected the assignment started on editor, got %+v", assignment)
+	}
+	resp = doJSON(t, client, "POST", "/api/teams/lead/assign", map[string]any{"to": "nobody", "body": "Hi."})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request for an unknown worker, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "GET", "/api/teams/lead", nil)
+	var item struct {
+		Team    teams.Team     `json:"team"`
+		Outputs []teams.Output `json:"outputs"`
+	}
+	decodeJSONResponse(t, resp, &item)
+	if item.Team.ID != "lead" || item.Outputs == nil {
+		t.Fatalf("unexpected team item %+v", item)
+	}
+
+	resp = doJSON(t, client, "DELETE", "/api/teams/lead", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "GET", "/api/teams/lead", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the disbanded team gone, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerQueueDepthsByPriority(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "POST", "/api/tasks", map[string]any{"type": "exec", "priority": "low"})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp = doJSON(t, client, "POST", "/api/tasks", map[string]any{"type": "exec", "priority": "urgent"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid priority to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/tasks/queue/depths?type=exec", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("depths status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var payload struct {
+		Depths map[string]int `json:"depths"`
+	}
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &payload); err != nil {
+		t.Fatalf("decode depths: %v", err)
+	}
+	if payload.Depths["low"] != 1 || payload.Depths["high"] != 0 {
+		t.Fatalf("unexpected depths: %#v", payload.Depths)
+	}
+}
+
+func TestServerTaskPriorityBoost(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus, tasks.WithQueueAging(0))
+	server := &Server{Tasks: mgr, Bus: bus}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	for _, id := range []string{"batch", "stuck"} {
+		if _, err := mgr.Spawn(ctx, tasks.Spec{ID: id, Type: "exec", Priority: tasks.QueuePriorityLow}); err != nil {
+			t.Fatalf("spawn %s: %v", id, err)
+		}
+	}
+
+	resp := doJSON(t, client, "POST", "/api/tasks/stuck/priority", map[string]any{})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an empty change to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "POST", "/api/tasks/stuck/priority", map[string]any{"priority": "urgent"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid priority to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, client, "POST", "/api/tasks/stuck/priority", map[string]any{"priority": "high", "labels": map[string]any{"ticket": "OPS-12"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("priority status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var task tasks.Task
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &task); err != nil {
+		t.Fatalf("decode task: %v", err)
+	}
+	labels, _ := task.Metadata["labels"].(map[string]any)
+	if task.Priority != tasks.QueuePriorityHigh || labels["ticket"] != "OPS-12" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/tasks/queue?type=exec&limit=1", nil)
+	var claimed []tasks.Task
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &claimed); err != nil || len(claimed) != 1 || claimed[0].ID != "stuck" {
+		t.Fatalf("expected the boosted task to be claimed first, got %+v (%v)", claimed, err)
+	}
+	resp = doJSON(t, client, "POST", "/api/tasks/stuck/priority", map[string]any{"priority": "low"})
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected a conflict for a claimed task, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerRejectsUnknownPriorities(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, Priorities: NewPriorityStats()}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "operator", Type: "agent", Owner: "operator", Mode: "async"}); err != nil {
+		t.Fatalf("spawn operator: %v", err)
+	}
+	_ = mgr.MarkRunning(ctx, "operator")
+
+	resp := doJSON(t, client, "POST", "/api/tasks/operator/send", map[string]any{"message": "hi", "priority": "urgent"})
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "must be one of interrupt, wake, normal or low") {
+		t.Fatalf("expected a descriptive 400, got %d %s", resp.StatusCode, body)
+	}
+	resp = doJSON(t, client, "POST", "/api/tasks/operator/send", map[string]any{"message": "hi", "priority": " Wake "})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a known priority in any case to pass, got %d %s", resp.StatusCode, readBody(t, resp))
+	}
+	resp = doJSON(t, client, "POST", "/api/tasks/operator/updates", map[string]any{"kind": "progress", "payload": map[string]any{"priority": 2}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a non-string priority to be rejected, got %d", resp.StatusCode)
+	}
+	resp = doJSON(t, client, "POST", "/api/tasks/operator/send", map[string]any{"message": "hi", "priority": "urgent"})
+	_ = readBody(t, resp)
+
+	resp = doJSON(t, client, "GET", "/api/admin/priorities", nil)
+	var payload struct {
+		Rejections []PriorityRejection `json:"rejections"`
+	}
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []PriorityRejection{
+		{Endpoint: "agent_message", Value: "urgent", Count: 2},
+		{Endpoint: "task_update", Value: "<non-string>", Count: 1},
+	}
+	if !slices.Equal(payload.Rejections, want) {
+		t.Fatalf("unexpected rejections %+v", payload.Rejections)
+	}
+}
+
+func TestServerTaskTemplates(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Templates: templates.NewStore(db)}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "PUT", "/api/templates/fetch", map[string]any{
+		"type":    "exec",
+		"params":  []map[string]any{{"name": "url", "type": "string", "required": true}},
+		"payload": map[string]any{"code": "await fetch({{json url}})"},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp = doJSON(t, client, "POST", "/api/templates/fetch/spawn", map[string]any{"params": map[string]any{}})
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `missing required parameter \"url\"`) {
+		t.Fatalf("expected missing params to be rejected, got %d %s", resp.StatusCode, body)
+	}
+	resp = doJSON(t, client, "POST", "/api/templates/fetch/spawn", map[string]any{"params": map[string]any{"url": "https://example.com"}})
+	var spawned struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &spawned); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("spawn: %d %v", resp.StatusCode, err)
+	}
+	task, err := mgr.Get(context.Background(), spawned.TaskID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if task.Payload["code"] != `await fetch("https://example.com")` || task.Metadata["template"] != "fetch" {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	resp = doJSON(t, client, "POST", "/api/templates/missing/spawn", map[string]any{})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an unknown template to 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerHealthReportsFailingWith503(t *testing.T) {
+	failing := func(context.Context) (health.Status, string) { return health.StatusFailing, "disk full" }
+	server := &Server{Health: health.NewChecker([]health.Check{
+		{Name: "disk", Critical: true, Func: failing},
+	})}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "GET", "/api/health", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var report health.Report
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Status != health.StatusFailing || len(report.Checks) != 1 || report.Checks[0].Detail != "disk full" {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/health/live", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected live probe to pass, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerShareLinkScopesTranscript(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock))
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, NowFn: clock, Shares: share.NewSigner([]byte("test"), share.WithClock(clock))}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(context.Background(), tasks.Spec{ID: "helper", Type: "agent"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	push := func(entryType, role, content string) {
+		t.Helper()
+		if _, err := bus.Push(context.Background(), eventbus.EventInput{
+			Stream:    "history",
+			ScopeType: "task",
+			ScopeID:   "helper",
+			Body:      content,
+			Payload:   map[string]any{"agent_id": "helper", "generation": 1, "type": entryType, "role": role, "content": content},
+		}); err != nil {
+			t.Fatalf("push history: %v", err)
+		}
+	}
+	// More entries than one listing holds precede the range.
+	for range shareListLimit + 1 {
+		push("user_message", "user", "before the range")
+	}
+	now = now.Add(time.Hour)
+	push("system_prompt", "system", "secret prompt")
+	push("user_message", "user", "<b>in range</b>")
+	push("assistant_message", "assistant", "reply")
+
+	resp := doJSON(t, client, "POST", "/api/agents/helper/share", map[string]any{
+		"from":        now.Add(-time.Minute).Format(time.RFC3339),
+		"ttl_seconds": 3600,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("share status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var link struct {
+		URL string `json:"url"`
+	}
+	decodeJSONResponse(t, resp, &link)
+
+	resp = doJSON(t, client, "GET", link.URL+"?format=json", nil)
+	var transcript struct {
+		Entries []engine.AgentHistoryEntry `json:"entries"`
+	}
+	decodeJSONResponse(t, resp, &transcript)
+	if len(transcript.Entries) != 2 || transcript.Entries[0].Content != "<b>in range</b>" {
+		t.Fatalf("unexpected shared entries: %#v", transcript.Entries)
+	}
+
+	resp = doJSON(t, client, "GET", link.URL, nil)
+	body := readBody(t, resp)
+	if !strings.Contains(body, "&lt;b&gt;in range&lt;/b&gt;") || strings.Contains(body, "secret prompt") {
+		t.Fatalf("unexpected share page: %s", body)
+	}
+
+	now = now.Add(2 * time.Hour)
+	resp = doJSON(t, client, "GET", link.URL, nil)
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("expected expired link to return 410, got %d", resp.StatusCode)
+	}
+}
+
+type apiKeyTransport struct {
+	key  string
+	next http.RoundTripper
+}
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.key != "" {
+		req.Header.Set("X-API-Key", t.key)
+	}
+	return t.next.RoundTrip(req)
+}
+
+func TestServerEnforcesAgentOwnershipAndGrants(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Access: access.NewStore(db, []access.Key{
+		{Key: "alice-key", Principal: "alice"},
+		{Key: "bob-key", Principal: "bob"},
+		{Key: "root-key", Principal: "root", Admin: true},
+	})}
+	handler := server.Handler()
+	clientFor := func(key string) *http.Client {
+		return &http.Client{Transport: apiKeyTransport{key: key, next: &testutil.RoundTripHandler{Handler: handler}}}
+	}
+	alice, bob, root, anon := clientFor("alice-key"), clientFor("bob-key"), clientFor("root-key"), clientFor("")
+
+	expect := func(client *http.Client, method, path string, payload any, want int) {
+		t.Helper()
+		resp := doJSON(t, client, method, path, payload)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: expected %d, got %d", method, path, want, resp.StatusCode)
+		}
+	}
+
+	expect(alice, "POST", "/api/tasks", map[string]any{"id": "alice-bot", "type": "agent"}, http.StatusAccepted)
+	expect(anon, "GET", "/api/tasks/alice-bot/updates", nil, http.StatusUnauthorized)
+	expect(bob, "GET", "/api/tasks/alice-bot/updates", nil, http.StatusNotFound)
+	expect(bob, "GET", "/api/state", nil, http.StatusForbidden)
+	expect(alice, "GET", "/api/tasks/alice-bot/updates", nil, http.StatusOK)
+	// Re-creating someone else's agent would reconfigure it.
+	expect(bob, "POST", "/api/tasks", map[string]any{"id": "alice-bot", "type": "agent"}, http.StatusNotFound)
+
+	expect(alice, "POST", "/api/agents/alice-bot/acl", map[string]any{"principal": "bob", "level": "view"}, http.StatusOK)
+	expect(bob, "GET", "/api/tasks/alice-bot/updates", nil, http.StatusOK)
+	expect(bob, "POST", "/api/tasks/alice-bot/send", map[string]any{"input": map[string]any{"x": 1}}, http.StatusForbidden)
+	expect(bob, "POST", "/api/agents/alice-bot/acl", map[string]any{"principal": "bob", "level": "owner"}, http.StatusForbidden)
+
+	expect(alice, "POST", "/api/agents/alice-bot/acl", map[string]any{"principal": "bob", "level": "interact"}, http.StatusOK)
+	expect(bob, "POST", "/api/tasks/alice-bot/send", map[string]any{"input": map[string]any{"x": 1}}, http.StatusOK)
+	expect(bob, "POST", "/api/tasks/alice-bot/cancel", map[string]any{}, http.StatusForbidden)
+
+	// Child tasks inherit the agent's grants.
+	child, err := mgr.Spawn(context.Background(), tasks.Spec{Type: "exec", ParentID: "alice-bot"})
+	if err != nil {
+		t.Fatalf("spawn child: %v", err)
+	}
+	expect(bob, "GET", "/api/tasks/"+child.ID+"/updates", nil, http.StatusOK)
+
+	expect(alice, "DELETE", "/api/agents/alice-bot/acl/bob", nil, http.StatusOK)
+	expect(bob, "GET", "/api/tasks/"+child.ID+"/updates", nil, http.StatusNotFound)
+	expect(root, "GET", "/api/state", nil, http.StatusOK)
+	expect(root, "POST", "/api/tasks/alice-bot/cancel", map[string]any{}, http.StatusOK)
+}
+
+func TestServerScopesNamespacedKeysToTheirNamespace(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Scheduler: scheduler.NewService(db, bus), Access: access.NewStore(db, []access.Key{
+		{Key: "acme-key", Principal: "acme-ops", Admin: true, Namespace: "acme"},
+		{Key: "globex-key", Principal: "globex-ops", Admin: true, Namespace: "globex"},
+		{Key: "root-key", Principal: "root", Admin: true},
+	})}
+	handler := server.Handler()
+	clientFor := func(key string) *http.Client {
+		return &http.Client{Transport: apiKeyTransport{key: key, next: &testutil.RoundTripHandler{Handler: handler}}}
+	}
+	acme, globex, root := clientFor("acme-key"), clientFor("globex-key"), clientFor("root-key")
+	expect := func(client *http.Client, method, path string, payload any, want int) *http.Response {
+		t.Helper()
+		resp := doJSON(t, client, method, path, payload)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: expected %d, got %d: %s", method, path, want, resp.StatusCode, readBody(t, resp))
+		}
+		return resp
+	}
+
+	expect(acme, "POST", "/api/tasks", map[string]any{"id": "acme-bot", "type": "agent"}, http.StatusAccepted).Body.Close()
+	expect(globex, "POST", "/api/tasks", map[string]any{"id": "globex-bot", "type": "agent"}, http.StatusAccepted).Body.Close()
+	child, err := mgr.Spawn(context.Background(), tasks.Spec{Type: "exec", ParentID: "acme-bot"})
+	if err != nil {
+		t.Fatalf("spawn child: %v", err)
+	}
+	if child.Namespace != "acme" {
+		t.Fatalf("expected the child in its parent's namespace, got %q", child.Namespace)
+	}
+
+	expect(globex, "GET", "/api/tasks/acme-bot/updates", nil, http.StatusNotFound).Body.Close()
+	expect(globex, "GET", "/api/tasks/"+child.ID+"/updates", nil, http.StatusNotFound).Body.Close()
+	expect(acme, "GET", "/api/tasks/"+child.ID+"/updates", nil, http.StatusOK).Body.Close()
+	// Instance-wide endpoints need an admin without a namespace.
+	expect(acme, "GET", "/api/admin/tool-validation", nil, http.StatusForbidden).Body.Close()
+	expect(acme, "GET", "/api/templates", nil, http.StatusForbidden).Body.Close()
+	expect(acme, "GET", "/api/contacts", nil, http.StatusForbidden).Body.Close()
+
+	expect(acme, "POST", "/api/schedules", map[string]any{"task_id": "acme-bot", "cron": "0 9 * * *", "text": "standup"}, http.StatusCreated).Body.Close()
+	expect(globex, "POST", "/api/schedules", map[string]any{"task_id": "globex-bot", "cron": "0 9 * * *", "text": "standup"}, http.StatusCreated).Body.Close()
+	var schedules struct {
+		Schedules []scheduler.Schedule `json:"schedules"`
+	}
+	decodeJSONResponse(t, expect(globex, "GET", "/api/schedules", nil, http.StatusOK), &schedules)
+	if len(schedules.Schedules) != 1 || schedules.Schedules[0].TaskID != "globex-bot" {
+		t.Fatalf("expected only globex's schedule, got %+v", schedules.Schedules)
+	}
+	decodeJSONResponse(t, expect(root, "GET", "/api/schedules", nil, http.StatusOK), &schedules)
+	if len(schedules.Schedules) != 2 {
+		t.Fatalf("expected an admin without a namespace to see every schedule, got %+v", schedules.Schedules)
+	}
+	var depths struct {
+		Depths map[string]int `json:"depths"`
+	}
+	decodeJSONResponse(t, expect(globex, "GET", "/api/tasks/queue/depths?type=exec", nil, http.StatusOK), &depths)
+	if depths.Depths["normal"] != 0 {
+		t.Fatalf("expected no queued tasks in globex, got %+v", depths.Depths)
+	}
+	decodeJSONResponse(t, expect(acme, "GET", "/api/tasks/queue/depths?type=exec", nil, http.StatusOK), &depths)
+	if depths.Depths["normal"] != 1 {
+		t.Fatalf("expected acme's queued child counted, got %+v", depths.Depths)
+	}
+
+	var state stateResponse
+	decodeJSONResponse(t, expect(acme, "GET", "/api/state", nil, http.StatusOK), &state)
+	for _, task := range state.Tasks {
+		if task.Namespace != "acme" {
+			t.Fatalf("expected only acme tasks, got %+v", task)
+		}
+	}
+	if len(state.Tasks) != 2 || len(state.Streams[schema.StreamSignals]) == 0 {
+		t.Fatalf("expected acme's tasks and signals, got %+v", state)
+	}
+	var listed struct {
+		Events []eventbus.Event `json:"events"`
+	}
+	decodeJSONResponse(t, expect(globex, "GET", "/api/streams/signals?limit=50", nil, http.StatusOK), &listed)
+	if len(listed.Events) != 1 || listed.Events[0].Metadata["namespace"] != "globex" {
+		t.Fatalf("expected only globex's spawn signal, got %+v", listed.Events)
+	}
+	var agents struct {
+		Agents []agentListItem `json:"agents"`
+	}
+	decodeJSONResponse(t, expect(globex, "GET", "/api/agents", nil, http.StatusOK), &agents)
+	if len(agents.Agents) != 1 || agents.Agents[0].ID != "globex-bot" {
+		t.Fatalf("expected only globex's agent, got %+v", agents.Agents)
+	}
+
+	decodeJSONResponse(t, expect(root, "GET", "/api/state", nil, http.StatusOK), &state)
+	if len(state.Tasks) != 3 {
+		t.Fatalf("expected an admin without a namespace to see every task, got %d", len(state.Tasks))
+	}
+}
+
+func TestServerVersionedPathsAndLegacyShim(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	sunset := time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+	server := &Server{Tasks: mgr, Bus: bus, LegacyAPISunset: sunset, Deprecations: []Deprecation{
+		{Path: "/api/tasks/queue", Since: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Successor: "/api/tasks/queue/depths"},
+	}}
+	handler := server.Handler()
+	client := &http.Client{Transport: &testutil.RoundTripHandler{Handler: handler}}
+
+	resp := doJSON(t, client, "POST", "/api/v1/tasks", map[string]any{"id": "bot", "type": "agent"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get(VersionHeader) != "1" || resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("versioned create: %d version=%q deprecation=%q", resp.StatusCode, resp.Header.Get(VersionHeader), resp.Header.Get("Deprecation"))
+	}
+
+	resp = doJSON(t, client, "GET", "/api/tasks/bot/updates", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "true" {
+		t.Fatalf("legacy get: %d deprecation=%q", resp.StatusCode, resp.Header.Get("Deprecation"))
+	}
+	if got := resp.Header.Get("Link"); got != `</api/v1/tasks/bot/updates>; rel="successor-version"` {
+		t.Fatalf("unexpected successor link %q", got)
+	}
+	if got := resp.Header.Get("Sunset"); got != sunset.Format(http.TimeFormat) {
+		t.Fatalf("unexpected sunset %q", got)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/v1/tasks/queue?type=none", nil)
+	resp.Body.Close()
+	if resp.Header.Get("Deprecation") != "@1788220800" || resp.Header.Get("Link") != `</api/v1/tasks/queue/depths>; rel="successor-version"` {
+		t.Fatalf("expected the deprecated endpoint flagged, got %q %q", resp.Header.Get("Deprecation"), resp.Header.Get("Link"))
+	}
+
+	for _, tc := range []struct {
+		path, header string
+	}{
+		{"/api/v2/tasks/bot", ""},
+		{"/api/tasks/bot", "7"},
+		{"/api/v1/tasks/bot", "2"},
+	} {
+		req, _ := http.NewRequest("GET", "http://in-process"+tc.path, nil)
+		if tc.header != "" {
+			req.Header.Set(VersionHeader, tc.header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(SupportedVersionsHeader) != "1" {
+			t.Fatalf("%s with %q: expected 400 listing versions, got %d", tc.path, tc.header, resp.StatusCode)
+		}
+	}
+
+	resp = doJSON(t, client, "GET", "/api/v1/versions", nil)
+	var versions struct {
+		Current      int           `json:"current"`
+		Supported    []int         `json:"supported"`
+		Deprecations []Deprecation `json:"deprecations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		t.Fatalf("decode versions: %v", err)
+	}
+	resp.Body.Close()
+	if versions.Current != 1 || len(versions.Supported) != 1 || len(versions.Deprecations) != 1 {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	server := &Server{}
+	mux := http.NewServeMux()
+	for _, rt := range server.routes() {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
+	seen := map[string]bool{}
+	for _, op := range operations {
+		key := op.Method + " " + op.Path
+		if seen[key] {
+			t.Fatalf("%s is documented twice", key)
+		}
+		seen[key] = true
+		req := httptest.NewRequest(op.Method, "/api"+pathParamRE.ReplaceAllString(op.Path, "x"), nil)
+		if _, pattern := mux.Handler(req); pattern == "" {
+			t.Fatalf("%s is documented but not routed", key)
+		}
+	}
+	for _, rt := range server.routes() {
+		covered := false
+		for _, op := range operations {
+			full := "/api" + op.Path
+			if full == rt.pattern || strings.HasSuffix(rt.pattern, "/") && strings.HasPrefix(full, rt.pattern) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Fatalf("route %s has no documented operation", rt.pattern)
+		}
+	}
+}
+
+func TestServerServesOpenAPIDocument(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{Tasks: mgr, Bus: bus, Access: access.NewStore(db, []access.Key{{Key: "k", Principal: "alice"}}), Deprecations: []Deprecation{
+		{Path: "/api/tasks/queue", Since: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)},
+	}}
+	client := &http.Client{Transport: &testutil.RoundTripHandler{Handler: server.Handler()}}
+
+	resp := doJSON(t, client, "GET", "/api/v1/openapi.json", nil)
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the document without a key, got %d %s", resp.StatusCode, raw)
+	}
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || len(doc.Paths) == 0 {
+		t.Fatalf("unexpected document %s", raw[:min(len(raw), 200)])
+	}
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Fatalf("unresolved $ref %s", ref[1])
+		}
+	}
+	if _, ok := doc.Components.Schemas["tasks.Task"]; !ok {
+		t.Fatalf("expected a tasks.Task schema")
+	}
+	if doc.Paths["/tasks/queue"]["get"]["deprecated"] != true {
+		t.Fatalf("expected the deprecated endpoint flagged, got %v", doc.Paths["/tasks/queue"]["get"])
+	}
+	if security, ok := doc.Paths["/health"]["get"]["security"].([]any); !ok || len(security) != 0 {
+		t.Fatalf("expected /health to need no key, got %v", doc.Paths["/health"]["get"]["security"])
+	}
+	create := doc.Paths["/tasks"]["post"]
+	if create["operationId"] != "postTasks" || create["requestBody"] == nil {
+		t.Fatalf("unexpected create operation %v", create)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/docs", nil)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "swagger-ui") {
+		t.Fatalf("expected the Swagger UI page, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerArtifactsRequireAgentAccess(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	store := artifacts.NewStore(db)
+	server := &Server{Tasks: mgr, Bus: bus, Artifacts: store, Access: access.NewStore(db, []access.Key{
+		{Key: "alice-key", Principal: "alice"},
+		{Key: "bob-key", Principal: "bob"},
+	})}
+	handler := server.Handler()
+	alice := &http.Client{Transport: apiKeyTransport{key: "alice-key", next: &testutil.RoundTripHandler{Handler: handler}}}
+	bob := &http.Client{Transport: apiKeyTransport{key: "bob-key", next: &testutil.RoundTripHandler{Handler: handler}}}
+
+	resp := doJSON(t, alice, "POST", "/api/tasks", map[string]any{"id": "alice-bot", "type": "agent"})
+	resp.Body.Close()
+	artifact, err := store.Put(context.Background(), artifacts.Artifact{AgentID: "alice-bot", Source: "tool:exec", Summary: "a log", Content: "full log"})
+	if err != nil {
+		t.Fatalf("put artifact: %v", err)
+	}
+
+	resp = doJSON(t, bob, "GET", "/api/artifacts/"+artifact.ID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected another principal to get 404, got %d", resp.StatusCode)
+	}
+	resp = doJSON(t, alice, "GET", "/api/artifacts/"+artifact.ID, nil)
+	var got artifacts.Artifact
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode artifact: %v", err)
+	}
+	resp.Body.Close()
+	if got.Content != "full log" || got.Summary != "a log" {
+		t.Fatalf("unexpected artifact %+v", got)
+	}
+	resp = doJSON(t, alice, "GET", "/api/artifacts/"+artifact.ID+"?raw=1", nil)
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(raw) != "full log" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected raw artifact %q (%s)", raw, resp.Header.Get("Content-Type"))
+	}
+	resp = doJSON(t, alice, "GET", "/api/agents/alice-bot/artifacts", nil)
+	var listed struct {
+		Artifacts []artifacts.Artifact `json:"artifacts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	resp.Body.Close()
+	if len(listed.Artifacts) != 1 || listed.Artifacts[0].ID != artifact.ID || listed.Artifacts[0].Content != "" {
+		t.Fatalf("unexpected listing %+v", listed.Artifacts)
+	}
+}
+
+func TestServerNotificationDevicesArePerPrincipal(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	server := &Server{
+		Tasks:         mgr,
+		Bus:           bus,
+		Notifications: notify.NewStore(db),
+		Pushers:       map[string]notify.Pusher{notify.PlatformAPNs: &notify.APNs{}},
+		Access: access.NewStore(db, []access.Key{
+			{Key: "alice-key", Principal: "alice"},
+			{Key: "bob-key", Principal: "bob"},
+		}),
+	}
+	handler := server.Handler()
+	clientFor := func(key string) *http.Client {
+		return &http.Client{Transport: apiKeyTransport{key: key, next: &testutil.RoundTripHandler{Handler: handler}}}
+	}
+	alice, bob := clientFor("alice-key"), clientFor("bob-key")
+
+	resp := doJSON(t, alice, "POST", "/api/notifications/devices", map[string]any{"platform": "fcm", "token": "t"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unconfigured platform to be rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, alice, "POST", "/api/notifications/devices", map[string]any{"platform": "apns", "token": "alice-phone", "name": "iPhone"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	var device notify.Device
+	decodeJSONResponse(t, resp, &device)
+	if device.UserID != "alice" || device.ID == "" {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	var devices []notify.Device
+	decodeJSONResponse(t, doJSON(t, bob, "GET", "/api/notifications/devices", nil), &devices)
+	if len(devices) != 0 {
+		t.Fatalf("expected bob to see none of alice's devices, got %+v", devices)
+	}
+	resp = doJSON(t, bob, "DELETE", "/api/notifications/devices/"+device.ID, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected bob's delete to miss alice's device, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = doJSON(t, alice, "PUT", "/api/notifications/preferences", map[string]any{"kinds": []string{"budget_exceeded"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("preferences status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp.Body.Close()
+	var prefs notify.Preferences
+	decodeJSONResponse(t, doJSON(t, alice, "GET", "/api/notifications/preferences", nil), &prefs)
+	if len(prefs.Kinds) != 1 || prefs.Kinds[0] != "budget_exceeded" {
+		t.Fatalf("unexpected preferences %+v", prefs)
+	}
+	decodeJSONResponse(t, doJSON(t, bob, "GET", "/api/notifications/preferences", nil), &prefs)
+	if len(prefs.Kinds) != len(schema.AlertKinds) {
+		t.Fatalf("expected bob to default to every kind, got %+v", prefs)
+	}
+
+	resp = doJSON(t, alice, "DELETE", "/api/notifications/devices/"+device.ID, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status: %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerContacts(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	server := &Server{Contacts: contacts.NewStore(db)}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "PUT", "/api/contacts/bob", map[string]any{
+		"name":     "Bob",
+		"timezone": "America/New_York",
+		"channels": []map[string]any{{"name": "slack", "service_id": "slack", "handle": "U123"}},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status: %d body=%s", resp.StatusCode, readBody(t, resp))
+	}
+	resp = doJSON(t, client, "PUT", "/api/contacts/alice", map[string]any{"name": "Alice", "timezone": "Nowhere/Land"})
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "unknown timezone") {
+		t.Fatalf("expected a bad timezone to be rejected, got %d %s", resp.StatusCode, body)
+	}
+	resp = doJSON(t, client, "GET", "/api/contacts?q=u12", nil)
+	var payload struct {
+		Contacts []contacts.Contact `json:"contacts"`
+	}
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &payload); err != nil || len(payload.Contacts) != 1 || payload.Contacts[0].Channels[0].Handle != "U123" {
+		t.Fatalf("expected bob found by handle, got %+v %v", payload, err)
+	}
+	resp = doJSON(t, client, "DELETE", "/api/contacts/bob", nil)
+	_ = readBody(t, resp)
+	resp = doJSON(t, client, "GET", "/api/contacts/bob", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the deleted contact to 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerSchedules(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	server := &Server{Scheduler: scheduler.NewService(db, eventbus.NewBus(db))}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	resp := doJSON(t, client, "POST", "/api/schedules", map[string]any{"task_id": "agent-1", "cron": "0 9 * * mon-fri", "text": "standup"})
+	var created scheduler.Schedule
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &created); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status: %d %v", resp.StatusCode, err)
+	}
+	if !created.Enabled || created.NextRunAt.IsZero() {
+		t.Fatalf("expected an enabled schedule with a next run, got %+v", created)
+	}
+	resp = doJSON(t, client, "POST", "/api/schedules", map[string]any{"task_id": "agent-1", "cron": "every day", "text": "x"})
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "5 fields") {
+		t.Fatalf("expected a bad expression to be rejected, got %d %s", resp.StatusCode, body)
+	}
+	resp = doJSON(t, client, "PATCH", "/api/schedules/"+created.ID, map[string]any{"enabled": false, "text": "weekly sync"})
+	var updated scheduler.Schedule
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &updated); err != nil || updated.Enabled || updated.Text != "weekly sync" || updated.Cron != created.Cron {
+		t.Fatalf("expected a disabled renamed schedule, got %+v %v", updated, err)
+	}
+	resp = doJSON(t, client, "GET", "/api/schedules?task_id=agent-1", nil)
+	var payload struct {
+		Schedules []scheduler.Schedule `json:"schedules"`
+	}
+	if err := json.Unmarshal([]byte(readBody(t, resp)), &payload); err != nil || len(payload.Schedules) != 1 {
+		t.Fatalf("expected one schedule, got %+v %v", payload, err)
+	}
+	resp = doJSON(t, client, "DELETE", "/api/schedules/"+created.ID, nil)
+	_ = readBody(t, resp)
+	resp = doJSON(t, client, "GET", "/api/schedules/"+created.ID, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the deleted schedule to 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerUsageRollsUpVisibleAgents(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	store := usage.NewStore(db)
+	server := &Server{Tasks: mgr, Bus: bus, Usage: store, Access: access.NewStore(db, []access.Key{
+		{Key: "alice-key", Principal: "alice"},
+		{Key: "root-key", Principal: "root", Admin: true},
+	})}
+	handler := server.Handler()
+	alice := &http.Client{Transport: apiKeyTransport{key: "alice-key", next: &testutil.RoundTripHandler{Handler: handler}}}
+	root := &http.Client{Transport: apiKeyTransport{key: "root-key", next: &testutil.RoundTripHandler{Handler: handler}}}
+
+	resp := doJSON(t, alice, "POST", "/api/tasks", map[string]any{"id": "alice-bot", "type": "agent"})
+	resp.Body.Close()
+	resp = doJSON(t, root, "POST", "/api/tasks", map[string]any{"id": "other-bot", "type": "agent"})
+	resp.Body.Close()
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, rec := range []usage.Record{
+		{TaskID: "llm-1", AgentID: "alice-bot", Model: "small", InputTokens: 100, OutputTokens: 10, CostMicroUSD: 3, At: day},
+		{TaskID: "llm-2", AgentID: "alice-bot", Model: "large", InputTokens: 200, OutputTokens: 20, CostMicroUSD: 30, At: day.AddDate(0, 0, 1)},
+		{TaskID: "llm-3", AgentID: "other-bot", Model: "large", InputTokens: 900, OutputTokens: 90, CostMicroUSD: 90, At: day},
+	} {
+		if err := store.Record(ctx, rec); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	var report usageReport
+	decodeJSONResponse(t, doJSON(t, alice, "GET", "/api/usage?group_by=model,day", nil), &report)
+	if len(report.Rows) != 2 || report.Rows[0].Model != "large" || report.Rows[0].Day != "2026-03-02" || report.Rows[0].AgentID != "" {
+		t.Fatalf("expected only alice's turns by model and day, got %+v", report.Rows)
+	}
+	if report.Total.Turns != 2 || report.Total.InputTokens != 300 || report.Total.CostMicroUSD != 33 {
+		t.Fatalf("unexpected total %+v", report.Total)
+	}
+
+	report = usageReport{}
+	decodeJSONResponse(t, doJSON(t, root, "GET", "/api/usage?from=2026-03-01&to=2026-03-02", nil), &report)
+	if len(report.Rows) != 2 || report.Rows[0].AgentID != "alice-bot" || report.Rows[1].AgentID != "other-bot" || report.Total.Turns != 2 {
+		t.Fatalf("expected both agents' first-day turns, got %+v", report)
+	}
+
+	resp = doJSON(t, root, "GET", "/api/usage?group_by=week", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown dimension rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerAgentQualityFeedbackAndExport(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	store := quality.NewStore(db)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	rt.Quality = store
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, Quality: store}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "rated-bot", Type: "agent", Owner: "rated-bot"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	var turnIDs []string
+	for _, outcome := range []string{quality.Answered, quality.Deferred} {
+		turn, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "rated-bot", ParentID: "rated-bot"})
+		if err != nil {
+			t.Fatalf("spawn turn: %v", err)
+		}
+		turnIDs = append(turnIDs, turn.ID)
+		if err := store.RecordOutcome(ctx, quality.Outcome{TaskID: turn.ID, AgentID: "rated-bot", Outcome: outcome, Input: "about " + outcome, Output: "reply"}); err != nil {
+			t.Fatalf("record outcome: %v", err)
+		}
+	}
+
+	var fb quality.Feedback
+	decodeJSONResponse(t, doJSON(t, client, "POST", "/api/agents/rated-bot/turns/"+turnIDs[0]+"/feedback", map[string]any{"rating": "down", "comment": "missed the point"}), &fb)
+	if fb.Rating != quality.Down || fb.TaskID != turnIDs[0] {
+		t.Fatalf("unexpected feedback %+v", fb)
+	}
+	resp := doJSON(t, client, "POST", "/api/agents/rated-bot/turns/"+turnIDs[1]+"/feedback", map[string]any{"rating": "sideways"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown rating rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp = doJSON(t, client, "POST", "/api/agents/rated-bot/turns/not-a-turn/feedback", map[string]any{"rating": "up"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an unknown turn to 404, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	var metrics quality.Metrics
+	decodeJSONResponse(t, doJSON(t, client, "GET", "/api/agents/rated-bot/quality", nil), &metrics)
+	if metrics.Turns != 2 || metrics.Outcomes[quality.Deferred] != 1 || metrics.ThumbsDown != 1 || metrics.Approval != 0 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/agents/rated-bot/quality/export?rating=down", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected export response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(readBody(t, resp)), "\n")
+	var exported quality.Turn
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &exported) != nil {
+		t.Fatalf("expected one exported turn, got %q", lines)
+	}
+	if exported.Input != "about answered" || len(exported.Feedback) != 1 || exported.Feedback[0].Comment != "missed the point" {
+		t.Fatalf("unexpected exported turn %+v", exported)
+	}
+	resp = doJSON(t, client, "GET", "/api/agents/rated-bot/quality/export?outcome=unsure", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown outcome rejected, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestServerFineTuneExportsRedactedTurns(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&apiFakeProvider{})})
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, FineTuneRedactors: []finetune.Redactor{
+		func(s string) string { return strings.ReplaceAll(s, "Project X", "[project]") },
+	}}
+	client := testutil.NewInProcessClient(server.Handler())
+
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "tuned-bot", Type: "agent", Owner: "tuned-bot"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	if _, err := rt.HandleMessage(ctx, "tuned-bot", "user", "mail bob@example.com about Project X", nil); err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+
+	resp := doJSON(t, client, "GET", "/api/finetune?agents=tuned-bot&format=anthropic", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected export response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(readBody(t, resp)), "\n")
+	var example struct {
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &example) != nil || len(example.Messages) != 2 {
+		t.Fatalf("expected one two-message example, got %q", lines)
+	}
+	if got := example.Messages[0].Content[0]["text"]; got != "mail [email] about [project]" {
+		t.Fatalf("expected the message redacted, got %v", got)
+	}
+
+	resp = doJSON(t, client, "GET", "/api/finetune?agents=tuned-bot&split=validation&validation=0", nil)
+	if body := strings.TrimSpace(readBody(t, resp)); resp.StatusCode != http.StatusOK || body != "" {
+		t.Fatalf("expected an empty validation split, got %d %q", resp.StatusCode, body)
+	}
+	for _, query := range []string{"", "agents=tuned-bot&format=llama", "agents=tuned-bot&validation=2", "agents=tuned-bot&rating=up"} {
+		resp = doJSON(t, client, "GET", "/api/finetune?"+query, nil)
+		if resp.StatusCode < 400 {
+			t.Fatalf("expected %q rejected, got %d", query, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+}
@@ -1,67 +1,30 @@
 package api
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
 	"github.com/flitsinc/go-agents/internal/agenttools"
-	"github.com/flitsinc/go-agents/internal/ai"
 	"github.com/flitsinc/go-agents/internal/engine"
 	"github.com/flitsinc/go-agents/internal/eventbus"
 	"github.com/flitsinc/go-agents/internal/tasks"
 	"github.com/flitsinc/go-agents/internal/testutil"
 	"github.com/flitsinc/go-agents/internal/toolresult"
-	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-agents/pkg/agenttest"
 	"github.com/flitsinc/go-llms/llms"
 	llmtools "github.com/flitsinc/go-llms/tools"
 )
 
-type deterministicSource struct {
-	mu     sync.Mutex
-	now    time.Time
-	step   time.Duration
-	nextID int
-}
-
-func newDeterministicSource(start time.Time, step time.Duration) *deterministicSource {
-	if start.IsZero() {
-		start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
-	}
-	if step <= 0 {
-		step = time.Second
-	}
-	return &deterministicSource{now: start.UTC(), step: step}
-}
-
-func (d *deterministicSource) Now() time.Time {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	current := d.now.UTC()
-	d.now = d.now.Add(d.step)
-	return current
-}
-
-func (d *deterministicSource) NewID() string {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.nextID++
-	return fmt.Sprintf("id-%06d", d.nextID)
-}
-
-type ToolFactory func(*tasks.Manager) llmtools.Tool
+type ToolFactory = agenttest.ToolFactory
 
 func ExecToolFactory() ToolFactory {
 	return func(mgr *tasks.Manager) llmtools.Tool {
@@ -119,52 +82,28 @@ type SnapshotFixture struct {
 	Manager *tasks.Manager
 	Runtime *engine.Runtime
 	Client  *http.Client
-	Clock   *deterministicSource
+	Clock   *agenttest.Source
 }
 
 func NewSnapshotFixture(t *testing.T, opts SnapshotFixtureOptions) *SnapshotFixture {
 	t.Helper()
-	if opts.Provider == nil {
-		t.Fatalf("snapshot fixture requires provider")
-	}
-	start := opts.StartTime
-	if start.IsZero() {
-		start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
-	}
-	t.Setenv("GO_AGENTS_PROMPT_DATE_LABEL", start.UTC().Format("Monday, January 2, 2006"))
-	db, closeFn := testutil.OpenTestDB(t)
-	t.Cleanup(closeFn)
-
-	clock := newDeterministicSource(start, opts.Tick)
-	bus := eventbus.NewBus(db,
-		eventbus.WithClock(clock.Now),
-		eventbus.WithIDGenerator(clock.NewID),
-	)
-	mgr := tasks.NewManager(db, bus,
-		tasks.WithClock(clock.Now),
-		tasks.WithIDGenerator(func(string) string { return clock.NewID() }),
-	)
-	tools := make([]llmtools.Tool, 0, len(opts.ToolFactories))
-	for _, factory := range opts.ToolFactories {
-		if factory == nil {
-			continue
-		}
-		tools = append(tools, factory(mgr))
-	}
-	runtimeClient := &ai.Client{LLM: llms.New(opts.Provider, tools...)}
-	rt := engine.NewRuntime(bus, mgr, runtimeClient, engine.WithClock(clock.Now))
-	rt.Context.Home = repoTemplateHome(t)
-
-	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, NowFn: clock.Now}
+	fx := agenttest.NewFixture(t, agenttest.Options{
+		Start:    opts.StartTime,
+		Tick:     opts.Tick,
+		Provider: opts.Provider,
+		Tools:    opts.ToolFactories,
+		Home:     repoTemplateHome(t),
+	})
+	server := &Server{Tasks: fx.Tasks, Bus: fx.Bus, Runtime: fx.Runtime, NowFn: fx.Clock.Now}
 	client := testutil.NewInProcessClient(server.Handler())
 
 	return &SnapshotFixture{
-		DB:      db,
-		Bus:     bus,
-		Manager: mgr,
-		Runtime: rt,
+		DB:      fx.DB,
+		Bus:     fx.Bus,
+		Manager: fx.Tasks,
+		Runtime: fx.Runtime,
 		Client:  client,
-		Clock:   clock,
+		Clock:   fx.Clock,
 	}
 }
 
@@ -197,100 +136,6 @@ func (f *SnapshotFixture) FetchState(t *testing.T) stateResponse {
 	return state
 }
 
-type scriptedProvider struct {
-	mu      sync.Mutex
-	streams []llms.ProviderStream
-	next    int
-}
-
-func newScriptedProvider(streams ...llms.ProviderStream) *scriptedProvider {
-	return &scriptedProvider{streams: append([]llms.ProviderStream(nil), streams...)}
-}
-
-func (p *scriptedProvider) Company() string              { return "test" }
-func (p *scriptedProvider) Model() string                { return "test" }
-func (p *scriptedProvider) SetDebugger(_ llms.Debugger)  {}
-func (p *scriptedProvider) SetHTTPClient(_ *http.Client) {}
-
-func (p *scriptedProvider) Generate(_ context.Context, _ content.Content, _ []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.next >= len(p.streams) {
-		return newScriptedStream(scriptedStreamSpec{Err: fmt.Errorf("unexpected provider call #%d", p.next+1)})
-	}
-	stream := p.streams[p.next]
-	p.next++
-	return stream
-}
-
-type scriptedStreamSpec struct {
-	Message   llms.Message
-	Text      string
-	Thought   content.Thought
-	ToolCall  llms.ToolCall
-	Statuses  []llms.StreamStatus
-	Usage     llms.Usage
-	ImageURL  string
-	ImageMIME string
-	Err       error
-}
-
-type scriptedStream struct {
-	spec scriptedStreamSpec
-}
-
-func newScriptedStream(spec scriptedStreamSpec) *scriptedStream {
-	if spec.Message.Role == "" {
-		spec.Message.Role = "assistant"
-	}
-	if spec.Text != "" {
-		spec.Message.Content = content.FromText(spec.Text)
-	}
-	if spec.ToolCall.ID != "" && len(spec.Message.ToolCalls) == 0 {
-		spec.Message.ToolCalls = []llms.ToolCall{spec.ToolCall}
-	}
-	if len(spec.Statuses) == 0 {
-		var statuses []llms.StreamStatus
-		if strings.TrimSpace(spec.Thought.ID) != "" || strings.TrimSpace(spec.Thought.Text) != "" {
-			statuses = append(statuses, llms.StreamStatusThinking)
-		}
-		if spec.Text != "" {
-			statuses = append(statuses, llms.StreamStatusText)
-		}
-		if len(spec.Message.ToolCalls) > 0 {
-			statuses = append(statuses, llms.StreamStatusToolCallBegin, llms.StreamStatusToolCallReady)
-		}
-		spec.Statuses = statuses
-	}
-	return &scriptedStream{spec: spec}
-}
-
-func (s *scriptedStream) Err() error               { return s.spec.Err }
-func (s *scriptedStream) Message() llms.Message    { return s.spec.Message }
-func (s *scriptedStream) Text() string             { return s.spec.Text }
-func (s *scriptedStream) Image() (string, string)  { return s.spec.ImageURL, s.spec.ImageMIME }
-func (s *scriptedStream) Thought() content.Thought { return s.spec.Thought }
-func (s *scriptedStream) ToolCall() llms.ToolCall {
-	if s.spec.ToolCall.ID != "" {
-		return s.spec.ToolCall
-	}
-	if len(s.spec.Message.ToolCalls) > 0 {
-		return s.spec.Message.ToolCalls[0]
-	}
-	return llms.ToolCall{}
-}
-func (s *scriptedStream) Usage() llms.Usage { return s.spec.Usage }
-func (s *scriptedStream) Iter() func(func(llms.StreamStatus) bool) {
-	statuses := append([]llms.StreamStatus(nil), s.spec.Statuses...)
-	return func(yield func(llms.StreamStatus) bool) {
-		for _, status := range statuses {
-			if !yield(status) {
-				return
-			}
-		}
-	}
-}
-
 func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, error) {
 	var b strings.Builder
 	title = strings.TrimSpace(title)
@@ -304,15 +149,15 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 	agents := append([]agentState(nil), state.Agents...)
 	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
 	b.WriteString("## Agents\n\n")
-	appendJSONBlock(&b, projectAgents(agents))
+	agenttest.AppendJSONBlock(&b, projectAgents(agents))
 
-	sessionIDs := sortedMapKeys(state.Sessions)
+	sessionIDs := agenttest.SortedKeys(state.Sessions)
 	b.WriteString("## Sessions\n\n")
 	for _, agentID := range sessionIDs {
 		b.WriteString("### ")
 		b.WriteString(agentID)
 		b.WriteString("\n\n")
-		appendJSONBlock(&b, projectSession(state.Sessions[agentID]))
+		agenttest.AppendJSONBlock(&b, projectSession(state.Sessions[agentID]))
 	}
 
 	tasksList := append([]tasks.Task(nil), state.Tasks...)
@@ -323,9 +168,9 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 		return tasksList[i].CreatedAt.Before(tasksList[j].CreatedAt)
 	})
 	b.WriteString("## Tasks\n\n")
-	appendJSONBlock(&b, projectTasks(tasksList))
+	agenttest.AppendJSONBlock(&b, projectTasks(tasksList))
 
-	updateTaskIDs := sortedMapKeys(state.Updates)
+	updateTaskIDs := agenttest.SortedKeys(state.Updates)
 	b.WriteString("## Task Updates\n\n")
 	for _, taskID := range updateTaskIDs {
 		updates := append([]tasks.Update(nil), state.Updates[taskID]...)
@@ -335,10 +180,10 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 		b.WriteString("### ")
 		b.WriteString(taskID)
 		b.WriteString("\n\n")
-		appendJSONBlock(&b, projectUpdates(updates))
+		agenttest.AppendJSONBlock(&b, projectUpdates(updates))
 	}
 
-	historyAgentIDs := sortedMapKeys(state.Histories)
+	historyAgentIDs := agenttest.SortedKeys(state.Histories)
 	b.WriteString("## Histories\n\n")
 	for _, agentID := range historyAgentIDs {
 		history := state.Histories[agentID]
@@ -375,7 +220,7 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 				entryMeta["tool_status"] = entry.ToolStatus
 			}
 			if len(entryMeta) > 0 {
-				appendJSONBlock(&b, entryMeta)
+				agenttest.AppendJSONBlock(&b, entryMeta)
 			}
 
 			if entry.Content != "" {
@@ -385,7 +230,7 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 				}
 				renderedContent := entry.Content
 				if fence == "xml" {
-					renderedContent = canonicalizeLLMInputXML(renderedContent)
+					renderedContent = agenttest.CanonicalizeXML(renderedContent)
 				}
 				b.WriteString("```")
 				b.WriteString(fence)
@@ -398,7 +243,7 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 			}
 
 			if data := normalizedHistoryData(entry.Data); len(data) > 0 {
-				appendJSONBlock(&b, data)
+				agenttest.AppendJSONBlock(&b, data)
 			}
 		}
 	}
@@ -406,28 +251,6 @@ func renderSessionSnapshotMarkdown(title string, state stateResponse) ([]byte, e
 	return []byte(b.String()), nil
 }
 
-func appendJSONBlock(b *strings.Builder, v any) {
-	data, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		b.WriteString("```text\n")
-		b.WriteString(err.Error())
-		b.WriteString("\n```\n\n")
-		return
-	}
-	b.WriteString("```json\n")
-	b.Write(data)
-	b.WriteString("\n```\n\n")
-}
-
-func sortedMapKeys[V any](m map[string]V) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	return keys
-}
-
 func historyEntrySortKey(entry engine.AgentHistoryEntry) string {
 	turn := historyTurn(entry.Data)
 	normalizedData := normalizedHistoryData(entry.Data)
@@ -607,71 +430,3 @@ func updateSortKey(update tasks.Update) string {
 	payload, _ := json.Marshal(update.Payload)
 	return strings.Join([]string{update.Kind, string(payload)}, "|")
 }
-
-func canonicalizeLLMInputXML(raw string) string {
-	dec := xml.NewDecoder(strings.NewReader(raw))
-	var out strings.Builder
-	enc := xml.NewEncoder(&out)
-	for {
-		tok, err := dec.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return raw
-		}
-		switch t := tok.(type) {
-		case xml.StartElement:
-			attrs := make([]xml.Attr, 0, len(t.Attr))
-			for _, attr := range t.Attr {
-				val := attr.Value
-				switch attr.Name.Local {
-				case "id":
-					val = "<id>"
-				case "created_at", "generated_at", "previous", "current":
-					val = "<time>"
-				case "elapsed_seconds":
-					val = "<seconds>"
-				}
-				attrs = append(attrs, xml.Attr{Name: attr.Name, Value: val})
-			}
-			sort.Slice(attrs, func(i, j int) bool {
-				li := attrs[i].Name.Space + ":" + attrs[i].Name.Local
-				lj := attrs[j].Name.Space + ":" + attrs[j].Name.Local
-				return li < lj
-			})
-			t.Attr = attrs
-			if err := enc.EncodeToken(t); err != nil {
-				return raw
-			}
-		default:
-			if err := enc.EncodeToken(tok); err != nil {
-				return raw
-			}
-		}
-	}
-	if err := enc.Flush(); err != nil {
-		return raw
-	}
-	return out.String()
-}
-
-func assertSnapshot(t *testing.T, relPath string, got []byte) {
-	t.Helper()
-	path := filepath.Join(".", relPath)
-	if os.Getenv("UPDATE_SNAPSHOTS") == "1" {
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			t.Fatalf("mkdir snapshot dir: %v", err)
-		}
-		if err := os.WriteFile(path, got, 0o644); err != nil {
-			t.Fatalf("write snapshot: %v", err)
-		}
-	}
-	want, err := os.ReadFile(path)
-	if err != nil {
-		t.Fatalf("read snapshot %s: %v", path, err)
-	}
-	if string(got) != string(want) {
-		t.Fatalf("snapshot mismatch for %s\n\n--- got ---\n%s\n--- want ---\n%s", path, string(got), string(want))
-	}
-}
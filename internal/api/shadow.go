@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/shadow"
+)
+
+// handleAgentShadow serves /api/agents/<id>/shadow: GET shows the trials and
+// the latest trial's runs, POST starts a trial, DELETE stops it and POST
+// /shadow/promote adopts the candidate.
+func (s *Server) handleAgentShadow(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Shadow == nil {
+		writeError(w, http.StatusNotFound, errNotFound("shadow store"))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && len(rest) == 0:
+		trials, err := s.Shadow.List(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if trials == nil {
+			trials = []shadow.Trial{}
+		}
+		runs := []shadow.Run{}
+		if len(trials) > 0 {
+			latest, err := s.Shadow.Runs(r.Context(), trials[0].ID, parseInt(r.URL.Query().Get("limit"), 50))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if latest != nil {
+				runs = latest
+			}
+		}
+		active, ok, err := s.Shadow.Active(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp := map[string]any{"trials": trials, "runs": runs, "active": nil}
+		if ok {
+			resp["active"] = active
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodPost && len(rest) == 0:
+		var payload struct {
+			System          string `json:"system"`
+			Model           string `json:"model"`
+			DurationSeconds int    `json:"duration_seconds"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		trial, err := s.Shadow.Start(r.Context(), shadow.TrialInput{
+			AgentID:  agentID,
+			System:   payload.System,
+			Model:    payload.Model,
+			Duration: time.Duration(payload.DurationSeconds) * time.Second,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, trial)
+	case r.Method == http.MethodDelete && len(rest) == 0:
+		trial, err := s.Shadow.End(r.Context(), agentID, shadow.StatusStopped)
+		if err != nil {
+			writeShadowError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, trial)
+	case r.Method == http.MethodPost && len(rest) == 1 && rest[0] == "promote":
+		if s.Runtime == nil {
+			writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+			return
+		}
+		trial, err := s.Runtime.PromoteShadowTrial(r.Context(), agentID)
+		if err != nil {
+			writeShadowError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, trial)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func writeShadowError(w http.ResponseWriter, err error) {
+	if errors.Is(err, shadow.ErrNoActiveTrial) {
+		writeError(w, http.StatusNotFound, errNotFound("active shadow trial"))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/share"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+	// shareListLimit is how many history entries are listed at a time.
+	shareListLimit = 2000
+)
+
+// sharedEntryTypes are the history entries a shared transcript shows. Prompts,
+// reasoning and context bookkeeping stay private.
+var sharedEntryTypes = map[string]bool{
+	"user_message":      true,
+	"assistant_message": true,
+	"tool_call":         true,
+	"tool_result":       true,
+}
+
+type sharedTranscript struct {
+	AgentID   string                     `json:"agent_id"`
+	From      time.Time                  `json:"from,omitzero"`
+	To        time.Time                  `json:"to,omitzero"`
+	ExpiresAt time.Time                  `json:"expires_at"`
+	Entries   []engine.AgentHistoryEntry `json:"entries"`
+}
+
+// handleAgentShare serves POST /api/agents/<id>/share, which mints a
+// read-only link to the agent's transcript.
+func (s *Server) handleAgentShare(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Shares == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("share signer"))
+		return
+	}
+	var payload struct {
+		From       string `json:"from"`
+		To         string `json:"to"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	claims := share.Claims{AgentID: agentID}
+	var err error
+	if claims.From, err = parseShareTime(payload.From); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("from: %w", err))
+		return
+	}
+	if claims.To, err = parseShareTime(payload.To); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("to: %w", err))
+		return
+	}
+	ttl := defaultShareTTL
+	if payload.TTLSeconds > 0 {
+		ttl = min(time.Duration(payload.TTLSeconds)*time.Second, maxShareTTL)
+	}
+	claims.ExpiresAt = s.now().Add(ttl)
+	token, err := s.Shares.Sign(claims)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"token":      token,
+		"url":        "/api/share/" + token,
+		"expires_at": claims.ExpiresAt,
+	})
+}
+
+// handleShare serves GET /api/share/<token> as a minimal HTML transcript, or
+// JSON with format=json. It needs no credentials beyond the token.
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Shares == nil {
+		writeError(w, http.StatusNotFound, errNotFound("share"))
+		return
+	}
+	claims, err := s.Shares.Verify(strings.TrimPrefix(r.URL.Path, "/api/share/"))
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, share.ErrExpiredToken) {
+			status = http.StatusGone
+		}
+		writeError(w, status, err)
+		return
+	}
+	entries, err := readSharedEntries(r.Context(), s.Bus, s.Archive, claims)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	transcript := sharedTranscript{
+		AgentID:   claims.AgentID,
+		From:      claims.From,
+		To:        claims.To,
+		ExpiresAt: claims.ExpiresAt,
+		Entries:   entries,
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, transcript)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = shareTemplate.Execute(w, transcript)
+}
+
+func readSharedEntries(ctx context.Context, bus *eventbus.Bus, archiver *archive.Archiver, claims share.Claims) ([]engine.AgentHistoryEntry, error) {
+	entries := []engine.AgentHistoryEntry{}
+	var events []eventbus.Event
+	if bus != nil {
+		// Page forward through the range so long histories are covered in
+		// full; only entries inside it are read.
+		after := ""
+		for {
+			summaries, err := bus.List(ctx, "history", eventbus.ListOptions{
+				ScopeType: "task",
+				ScopeID:   claims.AgentID,
+				Limit:     shareListLimit,
+				Order:     "fifo",
+				After:     after,
+				Before:    claims.To,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(summaries) == 0 {
+				break
+			}
+			after = summaries[len(summaries)-1].ID
+			ids := make([]string, 0, len(summaries))
+			for _, summary := range summaries {
+				if claims.Contains(summary.CreatedAt) {
+					ids = append(ids, summary.ID)
+				}
+			}
+			if len(ids) > 0 {
+				page, err := bus.Read(ctx, "history", ids, "")
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, page...)
+			}
+			if len(summaries) < shareListLimit {
+				break
+			}
+		}
+	}
+	// Older generations may have been archived; the link still covers them.
+	if archiver != nil {
+		archived, err := archiver.Events(ctx, archive.Query{
+			Stream:    "history",
+			ScopeType: "task",
+			ScopeID:   claims.AgentID,
+			From:      claims.From,
+			To:        claims.To,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(archived, events...)
+	}
+	for _, evt := range archive.Merge(events) {
+		entry, ok := engine.HistoryEntryFromEvent(evt)
+		if !ok || !sharedEntryTypes[entry.Type] || !claims.Contains(entry.CreatedAt) {
+			continue
+		}
+		if entry.Type == "tool_call" && entry.ToolStatus != "start" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseShareTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time: %w", err)
+	}
+	return t.UTC(), nil
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{.AgentID}} transcript</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.entry { margin: 1rem 0; padding: .75rem 1rem; border-radius: 6px; background: #f4f4f5; }
+.user_message { background: #e0ecff; }
+.tool_call, .tool_result { font-size: 13px; color: #555; }
+.meta { font-size: 12px; color: #777; }
+pre { white-space: pre-wrap; margin: .25rem 0 0; font: inherit; }
+</style>
+</head>
+<body>
+<h1>{{.AgentID}}</h1>
+<p class="meta">Read-only transcript{{if not .From.IsZero}} from {{.From.Format "2006-01-02 15:04 MST"}}{{end}}{{if not .To.IsZero}} until {{.To.Format "2006-01-02 15:04 MST"}}{{end}}. Link expires {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}.</p>
+{{range .Entries}}<div class="entry {{.Type}}">
+<div class="meta">{{.Role}}{{if .ToolName}} · {{.ToolName}}{{end}}{{if .ToolStatus}} ({{.ToolStatus}}){{end}} · {{.CreatedAt.Format "15:04:05"}}</div>
+{{if .Content}}<pre>{{.Content}}</pre>{{end}}
+</div>
+{{else}}<p>No messages in this range.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleAgentSnooze serves /api/agents/<id>/snooze. GET reports the snooze,
+// POST {"duration": "2h"} snoozes the agent's messages to the user for that
+// long, and DELETE ends the snooze and delivers what was held back.
+func (s *Server) handleAgentSnooze(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		state, err := s.Runtime.SnoozeState(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	case http.MethodPost:
+		var payload struct {
+			Duration string `json:"duration"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(payload.Duration))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest("duration must be a duration like 30m or 2h"))
+			return
+		}
+		state, err := s.Runtime.Snooze(r.Context(), agentID, d)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	case http.MethodDelete:
+		state, err := s.Runtime.EndSnooze(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "ended": state.Snoozed, "delivered": state.Held})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"sort"
 	"strings"
@@ -20,6 +21,8 @@ type agentState struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	LastError   string    `json:"last_error,omitempty"`
 	Generation  int64     `json:"generation"`
+	Title       string    `json:"title,omitempty"`
+	Topics      []string  `json:"topics,omitempty"`
 }
 
 type stateResponse struct {
@@ -56,9 +59,10 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 		Streams:     map[string][]eventbus.Event{},
 	}
 
+	namespace := requestNamespace(r)
 	agentIDs := map[string]struct{}{}
 	if s.Tasks != nil {
-		tasksList, err := s.Tasks.List(r.Context(), tasks.ListFilter{Limit: taskLimit})
+		tasksList, err := s.Tasks.List(r.Context(), tasks.ListFilter{Namespace: namespace, Limit: taskLimit})
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -90,6 +94,9 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			taskID := strings.TrimSpace(id)
+			if namespace != "" && !s.inNamespace(r.Context(), taskID, namespace) {
+				continue
+			}
 			agentIDs[taskID] = struct{}{}
 			resp.Sessions[taskID] = session
 		}
@@ -117,12 +124,17 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 
 	orderedAgentIDs = filterVisibleAgentIDs(orderedAgentIDs, resp.Tasks, resp.Sessions, resp.Histories)
 	resp.Agents = buildAgentState(orderedAgentIDs, resp.Tasks, resp.Sessions, resp.Histories)
+	if err := s.applyAgentLabels(r.Context(), resp.Agents); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
 
 	if s.Bus != nil {
 		for _, stream := range streamList {
 			summaries, err := s.Bus.List(r.Context(), stream, eventbus.ListOptions{
-				Limit: streamLimit,
-				Order: "lifo",
+				Limit:     streamLimit,
+				Order:     "lifo",
+				Namespace: namespace,
 			})
 			if err != nil {
 				writeError(w, http.StatusBadRequest, err)
@@ -156,6 +168,20 @@ func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 
 	resp.Services = readSupervisorServiceState()
 
+	if r.URL.Query().Get("deterministic") == "1" {
+		snapshot, err := deterministicState(resp)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(snapshot)
+		return
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -216,6 +242,25 @@ func buildAgentState(agentIDs []string, allTasks []tasks.Task, sessions map[stri
 	return out
 }
 
+// applyAgentLabels fills in generated titles and topics. A label from an
+// older generation still describes the agent until it is relabeled.
+func (s *Server) applyAgentLabels(ctx context.Context, agents []agentState) error {
+	if s.Labels == nil || len(agents) == 0 {
+		return nil
+	}
+	all, err := s.Labels.All(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range agents {
+		if label, ok := all[agents[i].ID]; ok {
+			agents[i].Title = label.Title
+			agents[i].Topics = label.Topics
+		}
+	}
+	return nil
+}
+
 func readAgentHistory(ctx context.Context, bus *eventbus.Bus, agentID string, limit int) (engine.AgentHistory, error) {
 	if bus == nil {
 		return engine.AgentHistory{AgentID: agentID, Generation: 1}, nil
@@ -280,6 +325,7 @@ func readAgentHistory(ctx context.Context, bus *eventbus.Bus, agentID string, li
 	for i, j := 0, len(filtered)-1; i < j; i, j = i+1, j-1 {
 		filtered[i], filtered[j] = filtered[j], filtered[i]
 	}
+	engine.MarkRetractedEntries(filtered)
 	return engine.AgentHistory{
 		AgentID:    agentID,
 		Generation: currentGeneration,
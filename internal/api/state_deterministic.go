@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+var (
+	// generatedIDPattern matches the UUIDs that idgen hands out. Sequential
+	// IDs such as "agent-2" and user-chosen ones are already stable.
+	generatedIDPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	timestampPattern   = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// deterministicState renders resp for golden-file comparison, as served by
+// /api/state?deterministic=1:
+//
+//   - agents are ordered by ID, tasks, task updates and stream events
+//     oldest first, and services by name;
+//   - every generated ID, as a value, an object key or inside a string, is
+//     replaced by "<id:N>", numbered in order of first appearance;
+//   - every timestamp is replaced by "<time:N>", where N is its rank among
+//     the snapshot's distinct timestamps, so ordering survives.
+//
+// The result is written indented with sorted object keys, so two runs that
+// do the same things in the same order produce the same bytes.
+func deterministicState(resp stateResponse) (any, error) {
+	sortStateForSnapshot(&resp)
+	raw, err := json.Marshal(normalizeNilSlices(resp))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	p := &snapshotPlaceholders{ids: map[string]string{}, times: map[string]string{}}
+	p.collectTimes(doc)
+	return p.rewrite(doc), nil
+}
+
+func sortStateForSnapshot(resp *stateResponse) {
+	// The live agent order, by last update, can flip between runs when two
+	// agents update within the same instant.
+	sort.SliceStable(resp.Agents, func(i, j int) bool { return resp.Agents[i].ID < resp.Agents[j].ID })
+	slices.SortStableFunc(resp.Tasks, func(a, b tasks.Task) int {
+		return compareCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	for _, updates := range resp.Updates {
+		slices.SortStableFunc(updates, func(a, b tasks.Update) int {
+			return compareCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+		})
+	}
+	for _, events := range resp.Streams {
+		slices.SortStableFunc(events, func(a, b eventbus.Event) int {
+			return compareCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+		})
+	}
+	slices.SortStableFunc(resp.Services, func(a, b serviceRuntimeState) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// compareCreated orders by creation time, falling back to ID. Generated IDs
+// are UUIDv7, which sort by creation too.
+func compareCreated(a, b time.Time, idA, idB string) int {
+	if c := a.Compare(b); c != 0 {
+		return c
+	}
+	return strings.Compare(idA, idB)
+}
+
+type snapshotPlaceholders struct {
+	ids   map[string]string
+	times map[string]string
+}
+
+// collectTimes ranks every distinct timestamp in doc chronologically.
+func (p *snapshotPlaceholders) collectTimes(doc any) {
+	seen := map[string]time.Time{}
+	var walk func(v any)
+	visit := func(s string) {
+		for _, match := range timestampPattern.FindAllString(s, -1) {
+			if t, err := time.Parse(time.RFC3339Nano, match); err == nil {
+				seen[match] = t
+			}
+		}
+	}
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, elem := range v {
+				visit(key)
+				walk(elem)
+			}
+		case []any:
+			for _, elem := range v {
+				walk(elem)
+			}
+		case string:
+			visit(v)
+		}
+	}
+	walk(doc)
+	raw := make([]string, 0, len(seen))
+	for s := range seen {
+		raw = append(raw, s)
+	}
+	slices.SortFunc(raw, func(a, b string) int {
+		if c := seen[a].Compare(seen[b]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	rank := 0
+	var last time.Time
+	for i, s := range raw {
+		// The same instant written two ways shares a placeholder.
+		if i == 0 || !seen[s].Equal(last) {
+			rank++
+			last = seen[s]
+		}
+		p.times[s] = fmt.Sprintf("<time:%d>", rank)
+	}
+}
+
+// rewrite replaces IDs and timestamps, visiting object keys in sorted order
+// so IDs are numbered the same way every time.
+func (p *snapshotPlaceholders) rewrite(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make(map[string]any, len(v))
+		for _, key := range keys {
+			out[p.replace(key)] = p.rewrite(v[key])
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = p.rewrite(elem)
+		}
+		return out
+	case string:
+		return p.replace(v)
+	default:
+		return v
+	}
+}
+
+func (p *snapshotPlaceholders) replace(s string) string {
+	s = timestampPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if placeholder, ok := p.times[match]; ok {
+			return placeholder
+		}
+		return match
+	})
+	return generatedIDPattern.ReplaceAllStringFunc(s, func(match string) string {
+		id := strings.ToLower(match)
+		placeholder, ok := p.ids[id]
+		if !ok {
+			placeholder = fmt.Sprintf("<id:%d>", len(p.ids)+1)
+			p.ids[id] = placeholder
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStateDeterministicSnapshotIsStableAcrossRuns(t *testing.T) {
+	run := func() string {
+		db, closeFn := testutil.OpenTestDB(t)
+		defer closeFn()
+		ctx := context.Background()
+		bus := eventbus.NewBus(db)
+		mgr := tasks.NewManager(db, bus)
+		if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "planner", Type: "agent", Owner: "planner"}); err != nil {
+			t.Fatalf("spawn agent: %v", err)
+		}
+		for _, code := range []string{"first()", "second()"} {
+			task, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "planner", ParentID: "planner", Payload: map[string]any{"code": code}})
+			if err != nil {
+				t.Fatalf("spawn exec: %v", err)
+			}
+			if err := mgr.RecordUpdate(ctx, task.ID, "progress", map[string]any{"note": "started " + task.ID}); err != nil {
+				t.Fatalf("record update: %v", err)
+			}
+			if err := mgr.Complete(ctx, task.ID, map[string]any{"ok": true}); err != nil {
+				t.Fatalf("complete: %v", err)
+			}
+		}
+		if _, err := bus.Push(ctx, eventbus.EventInput{Stream: "signals", ScopeType: "task", ScopeID: "planner", Subject: "build failed", Body: "main is red"}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+
+		server := &Server{Tasks: mgr, Bus: bus, NowFn: time.Now}
+		client := testutil.NewInProcessClient(server.Handler())
+		resp := doJSON(t, client, "GET", "/api/state?deterministic=1", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("state status: %d body=%s", resp.StatusCode, readBody(t, resp))
+		}
+		return readBody(t, resp)
+	}
+
+	first := run()
+	time.Sleep(5 * time.Millisecond)
+	second := run()
+	if first != second {
+		t.Fatalf("expected identical snapshots\n--- first ---\n%s\n--- second ---\n%s", first, second)
+	}
+	if generatedIDPattern.MatchString(first) || timestampPattern.MatchString(first) {
+		t.Fatalf("expected IDs and timestamps replaced, got %s", first)
+	}
+	for _, want := range []string{`"id": "<id:1>"`, `"generated_at": "<time:`, `"id": "planner"`, `"note": "started exec-1"`} {
+		if !strings.Contains(first, want) {
+			t.Fatalf("expected %q in snapshot, got %s", want, first)
+		}
+	}
+	if strings.Index(first, `"code": "first()"`) > strings.Index(first, `"code": "second()"`) {
+		t.Fatalf("expected tasks oldest first, got %s", first)
+	}
+}
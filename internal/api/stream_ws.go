@@ -0,0 +1,336 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	wsBackfillPage    = 200
+	wsReadLimit       = 1 << 20
+	wsWriteTimeout    = 10 * time.Second
+	wsPingInterval    = 30 * time.Second
+	wsMessageEndpoint = "streams_ws"
+)
+
+// wsFrame is a frame sent by a /api/streams/ws client. ID is optional and
+// echoed in the reply so clients can match replies to requests.
+//
+//	{"type": "subscribe", "stream", "after", "consumer", "reader", "scope_type",
+//	 "scope_id", "topics", "agent", "kinds", "exclude_kinds", "min_priority"}
+//	{"type": "unsubscribe", "stream"}
+//	{"type": "cursor", "stream", "event_id", "consumer"}
+//	{"type": "message" or "interrupt", "task_id", "message", "source",
+//	 "priority", "request_id", "service_id", "context", "question_id"}
+type wsFrame struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Stream string `json:"stream"`
+
+	After        string   `json:"after"`
+	Consumer     string   `json:"consumer"`
+	Reader       string   `json:"reader"`
+	ScopeType    string   `json:"scope_type"`
+	ScopeID      string   `json:"scope_id"`
+	Topics       []string `json:"topics"`
+	Agent        string   `json:"agent"`
+	Kinds        string   `json:"kinds"`
+	ExcludeKinds string   `json:"exclude_kinds"`
+	MinPriority  string   `json:"min_priority"`
+
+	EventID string `json:"event_id"`
+
+	TaskID string `json:"task_id"`
+	agentMessageInput
+}
+
+// wsSession is one /api/streams/ws connection and its subscriptions, at
+// most one per stream.
+type wsSession struct {
+	server *Server
+	conn   *websocket.Conn
+	ctx    context.Context
+
+	mu   sync.Mutex
+	subs map[string]*wsSubscription
+}
+
+type wsSubscription struct {
+	cancel   context.CancelFunc
+	consumer string
+}
+
+// handleStreamWS serves /api/streams/ws, a WebSocket that carries the
+// events of several streams and accepts messages for agents. Each
+// subscription can resume from an event ID or a consumer's stored cursor:
+// the events after it are sent first, then live events as they are pushed.
+// Without after, consumer or a scope, a subscription sees every scope like
+// /api/streams/subscribe does.
+func (s *Server) handleStreamWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept has written the error response.
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	c := &wsSession{server: s, conn: conn, ctx: ctx, subs: map[string]*wsSubscription{}}
+	go c.keepalive()
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var frame wsFrame
+		if err := decodeJSON(bytes.NewReader(data), &frame); err != nil {
+			c.sendError("", err)
+			continue
+		}
+		if err := c.handle(frame); err != nil {
+			c.sendError(frame.ID, err)
+		}
+	}
+}
+
+func (c *wsSession) handle(frame wsFrame) error {
+	stream := strings.TrimSpace(frame.Stream)
+	switch frame.Type {
+	case "subscribe":
+		return c.subscribe(stream, frame)
+	case "unsubscribe":
+		if stream == "" {
+			return errBadRequest("stream is required")
+		}
+		c.unsubscribe(stream, nil)
+		return c.send(map[string]any{"type": "unsubscribed", "id": frame.ID, "stream": stream})
+	case "cursor":
+		if stream == "" {
+			return errBadRequest("stream is required")
+		}
+		consumer := strings.TrimSpace(frame.Consumer)
+		if consumer == "" {
+			c.mu.Lock()
+			if sub := c.subs[stream]; sub != nil {
+				consumer = sub.consumer
+			}
+			c.mu.Unlock()
+		}
+		if consumer == "" {
+			return errBadRequest("consumer is required")
+		}
+		cursor, err := c.server.Bus.SetCursor(c.ctx, stream, consumer, frame.EventID)
+		if err != nil {
+			return err
+		}
+		return c.send(map[string]any{"type": "cursor", "id": frame.ID, "cursor": cursor})
+	case "message", "interrupt":
+		if c.server.Runtime == nil {
+			return errNotFound("runtime")
+		}
+		taskID := strings.TrimSpace(frame.TaskID)
+		if taskID == "" {
+			return errBadRequest("task_id is required")
+		}
+		if strings.TrimSpace(frame.Message) == "" {
+			return errBadRequest("message is required")
+		}
+		input := frame.agentMessageInput
+		if frame.Type == "interrupt" {
+			input.Priority = string(schema.PriorityInterrupt)
+		}
+		if level, err := c.server.accessLevel(c.ctx, taskID); err != nil {
+			return err
+		} else if level < access.LevelInteract {
+			return errNotFound("task")
+		}
+		resp, _, err := c.server.sendAgentMessage(c.ctx, wsMessageEndpoint, taskID, input, nil)
+		if err != nil {
+			return err
+		}
+		resp["type"] = "sent"
+		resp["id"] = frame.ID
+		resp["task_id"] = taskID
+		return c.send(resp)
+	default:
+		return errBadRequest("type must be subscribe, unsubscribe, cursor, message or interrupt")
+	}
+}
+
+// subscribe replaces any subscription to stream. The bus subscription is
+// taken before the backfill is listed, so no event falls between the two.
+func (c *wsSession) subscribe(stream string, frame wsFrame) error {
+	if stream == "" {
+		return errBadRequest("stream is required")
+	}
+	filter, err := parseSubscribeFilter(url.Values{
+		"agent":         {frame.Agent},
+		"kinds":         {frame.Kinds},
+		"exclude_kinds": {frame.ExcludeKinds},
+		"min_priority":  {frame.MinPriority},
+	})
+	if err != nil {
+		return err
+	}
+	filter.namespace = agentcontext.NamespaceFromContext(c.ctx)
+	opts := eventbus.ListOptions{
+		Namespace: filter.namespace,
+		Reader:    strings.TrimSpace(frame.Reader),
+		ScopeType: strings.TrimSpace(frame.ScopeType),
+		ScopeID:   strings.TrimSpace(frame.ScopeID),
+		Topics:    frame.Topics,
+		Order:     "fifo",
+		Limit:     wsBackfillPage,
+	}
+	opts.AllScopes = opts.ScopeType == "" && opts.Reader == "" && len(opts.Topics) == 0
+	after := strings.TrimSpace(frame.After)
+	consumer := strings.TrimSpace(frame.Consumer)
+	if after == "" && consumer != "" {
+		cursor, err := c.server.Bus.GetCursor(c.ctx, stream, consumer)
+		if err != nil {
+			return err
+		}
+		after = cursor.EventID
+	}
+
+	c.unsubscribe(stream, nil)
+	ctx, cancel := context.WithCancel(c.ctx)
+	sub := &wsSubscription{cancel: cancel, consumer: consumer}
+	c.mu.Lock()
+	c.subs[stream] = sub
+	c.mu.Unlock()
+	live := c.server.Bus.Subscribe(ctx, []string{stream})
+	go c.forward(ctx, sub, frame.ID, stream, after, opts, filter, live)
+	return nil
+}
+
+// unsubscribe cancels the subscription to stream, or only sub when given.
+func (c *wsSession) unsubscribe(stream string, sub *wsSubscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := c.subs[stream]
+	if current == nil || (sub != nil && current != sub) {
+		return
+	}
+	current.cancel()
+	delete(c.subs, stream)
+}
+
+// forward sends the events after the cursor, confirms the subscription and
+// then sends live events until the subscription ends.
+func (c *wsSession) forward(ctx context.Context, sub *wsSubscription, frameID, stream, after string, opts eventbus.ListOptions, filter subscribeFilter, live <-chan eventbus.Event) {
+	sent := map[string]bool{}
+	backfilled := 0
+	cursor := after
+	for cursor != "" {
+		opts.After = cursor
+		summaries, err := c.server.Bus.List(ctx, stream, opts)
+		if err == nil && len(summaries) == 0 {
+			break
+		}
+		var events []eventbus.Event
+		ids := make([]string, 0, len(summaries))
+		if err == nil {
+			for _, summary := range summaries {
+				ids = append(ids, summary.ID)
+			}
+			events, err = c.server.Bus.Read(ctx, stream, ids, opts.Reader)
+		}
+		if err != nil {
+			c.unsubscribe(stream, sub)
+			c.sendError(frameID, err)
+			return
+		}
+		byID := make(map[string]eventbus.Event, len(events))
+		for _, evt := range events {
+			byID[evt.ID] = evt
+		}
+		for _, id := range ids {
+			cursor = id
+			sent[id] = true
+			evt, ok := byID[id]
+			if !ok || !filter.matches(evt) {
+				continue
+			}
+			if c.send(map[string]any{"type": "event", "stream": stream, "event": evt}) != nil {
+				return
+			}
+			backfilled++
+		}
+		if len(summaries) < opts.Limit {
+			break
+		}
+	}
+	if c.send(map[string]any{"type": "subscribed", "id": frameID, "stream": stream, "backfilled": backfilled, "cursor": cursor}) != nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-live:
+			if !ok {
+				return
+			}
+			if sent[evt.ID] || !opts.MatchesScope(evt) || !filter.matches(evt) {
+				continue
+			}
+			if c.send(map[string]any{"type": "event", "stream": stream, "event": evt}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes v as a JSON text frame. Failing writes close the connection.
+func (c *wsSession) send(v any) error {
+	ctx, cancel := context.WithTimeout(c.ctx, wsWriteTimeout)
+	defer cancel()
+	if err := wsjson.Write(ctx, c.conn, normalizeNilSlices(v)); err != nil {
+		c.conn.CloseNow()
+		return err
+	}
+	return nil
+}
+
+func (c *wsSession) sendError(id string, err error) {
+	_ = c.send(map[string]any{"type": "error", "id": id, "error": err.Error()})
+}
+
+func (c *wsSession) keepalive() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(c.ctx, wsWriteTimeout)
+			err := c.conn.Ping(ctx)
+			cancel()
+			if err != nil {
+				c.conn.CloseNow()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestServerStreamWS(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := engine.NewRuntime(bus, mgr, nil)
+	server := &Server{Tasks: mgr, Bus: bus, Runtime: rt, Priorities: NewPriorityStats()}
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "operator", Type: "agent", Owner: "operator", Mode: "async"}); err != nil {
+		t.Fatalf("spawn operator: %v", err)
+	}
+	seen, err := bus.Push(ctx, eventbus.EventInput{Stream: "task_output", Body: "seen"})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	missed, err := bus.Push(ctx, eventbus.EventInput{Stream: "task_output", ScopeType: "task", ScopeID: "agent-a", Body: "missed"})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if _, err := bus.SetCursor(ctx, "task_output", "ui", seen.ID); err != nil {
+		t.Fatalf("set cursor: %v", err)
+	}
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/streams/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+	send := func(frame map[string]any) {
+		t.Helper()
+		if err := wsjson.Write(ctx, conn, frame); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	read := func() map[string]any {
+		t.Helper()
+		var frame map[string]any
+		if err := wsjson.Read(ctx, conn, &frame); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return frame
+	}
+	// readReply skips events, such as task updates from the interrupted
+	// turn, that may arrive ahead of a reply.
+	readReply := func() map[string]any {
+		t.Helper()
+		for {
+			if frame := read(); frame["type"] != "event" {
+				return frame
+			}
+		}
+	}
+	eventID := func(frame map[string]any) string {
+		evt, _ := frame["event"].(map[string]any)
+		id, _ := evt["id"].(string)
+		return id
+	}
+
+	// The consumer's cursor resumes the stream with the event it missed.
+	send(map[string]any{"type": "subscribe", "id": "s1", "stream": "task_output", "consumer": "ui"})
+	if frame := read(); frame["type"] != "event" || eventID(frame) != missed.ID {
+		t.Fatalf("expected the missed event as backfill, got %v", frame)
+	}
+	if frame := read(); frame["type"] != "subscribed" || frame["id"] != "s1" || frame["backfilled"] != float64(1) || frame["cursor"] != missed.ID {
+		t.Fatalf("expected subscribed after one backfilled event, got %v", frame)
+	}
+	send(map[string]any{"type": "subscribe", "id": "s2", "stream": "task_input", "kinds": "message"})
+	if frame := read(); frame["type"] != "subscribed" || frame["stream"] != "task_input" {
+		t.Fatalf("expected task_input subscription, got %v", frame)
+	}
+
+	live, err := bus.Push(ctx, eventbus.EventInput{Stream: "task_output", Body: "live"})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if frame := read(); frame["type"] != "event" || eventID(frame) != live.ID {
+		t.Fatalf("expected the live event, got %v", frame)
+	}
+	send(map[string]any{"type": "cursor", "id": "c1", "stream": "task_output", "event_id": live.ID})
+	if frame := read(); frame["type"] != "cursor" || frame["id"] != "c1" {
+		t.Fatalf("expected cursor reply, got %v", frame)
+	}
+	if cursor, _ := bus.GetCursor(ctx, "task_output", "ui"); cursor.EventID != live.ID {
+		t.Fatalf("expected the cursor to advance, got %+v", cursor)
+	}
+
+	// Interrupts go out over the same connection and come back as events.
+	send(map[string]any{"type": "interrupt", "id": "m1", "task_id": "operator", "message": "stop"})
+	var sent, echoed bool
+	for !sent || !echoed {
+		frame := read()
+		switch frame["type"] {
+		case "sent":
+			sent = frame["id"] == "m1" && frame["task_id"] == "operator"
+		case "event":
+			evt, _ := frame["event"].(map[string]any)
+			meta, _ := evt["metadata"].(map[string]any)
+			echoed = frame["stream"] == "task_input" && meta["priority"] == "interrupt"
+		default:
+			t.Fatalf("unexpected frame %v", frame)
+		}
+	}
+
+	send(map[string]any{"type": "subscribe", "id": "s3", "stream": "errors", "after": "nope"})
+	if frame := readReply(); frame["type"] != "error" || frame["id"] != "s3" {
+		t.Fatalf("expected an unknown after to fail, got %v", frame)
+	}
+	send(map[string]any{"type": "bogus"})
+	if frame := readReply(); frame["type"] != "error" {
+		t.Fatalf("expected an unknown type to fail, got %v", frame)
+	}
+}
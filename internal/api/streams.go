@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+func (s *Server) handleStreamItem(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/streams/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		writeError(w, http.StatusNotFound, errNotFound("stream"))
+		return
+	}
+	stream := segments[0]
+	if len(segments) == 1 {
+		s.handleStreamList(w, r, stream)
+		return
+	}
+	switch segments[1] {
+	case "cursor":
+		s.handleStreamCursor(w, r, stream)
+	case "ack-all":
+		s.handleStreamBulk(w, r, stream, false)
+	case "unread":
+		s.handleStreamBulk(w, r, stream, true)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound("stream action"))
+	}
+}
+
+// handleStreamList lists events in a stream. When consumer is given and no
+// explicit after is set, listing resumes from the consumer's stored cursor.
+// Query keys of the form metadata.<path> or payload.<path> filter on JSON
+// fields, e.g. payload.result.status=failed.
+func (s *Server) handleStreamList(w http.ResponseWriter, r *http.Request, stream string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	opts := eventbus.ListOptions{
+		Reader:    query.Get("reader"),
+		Limit:     parseInt(query.Get("limit"), 50),
+		Order:     query.Get("order"),
+		ScopeType: query.Get("scope_type"),
+		ScopeID:   query.Get("scope_id"),
+		After:     strings.TrimSpace(query.Get("after")),
+		Namespace: requestNamespace(r),
+	}
+	for key, values := range query {
+		filter, ok, err := eventbus.ParseFieldFilter(key, values)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if ok {
+			opts.Fields = append(opts.Fields, filter)
+		}
+	}
+	if consumer := strings.TrimSpace(query.Get("consumer")); consumer != "" && opts.After == "" {
+		cursor, err := s.Bus.GetCursor(r.Context(), stream, consumer)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts.After = cursor.EventID
+	}
+	summaries, err := s.Bus.List(r.Context(), stream, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	events, err := s.Bus.Read(r.Context(), stream, ids, opts.Reader)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	byID := make(map[string]eventbus.Event, len(events))
+	for _, evt := range events {
+		byID[evt.ID] = evt
+	}
+	ordered := make([]eventbus.Event, 0, len(ids))
+	for _, id := range ids {
+		if evt, ok := byID[id]; ok {
+			ordered = append(ordered, evt)
+		}
+	}
+	// next is the newest event returned, suitable for storing as the cursor.
+	next := opts.After
+	var newest eventbus.Event
+	for _, evt := range ordered {
+		if next == opts.After || evt.CreatedAt.After(newest.CreatedAt) || (evt.CreatedAt.Equal(newest.CreatedAt) && evt.ID > newest.ID) {
+			newest = evt
+			next = evt.ID
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"events": ordered,
+		"next":   next,
+	})
+}
+
+func (s *Server) handleStreamCursor(w http.ResponseWriter, r *http.Request, stream string) {
+	consumer := strings.TrimSpace(r.URL.Query().Get("consumer"))
+	if consumer == "" {
+		writeError(w, http.StatusBadRequest, errBadRequest("consumer is required"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		cursor, err := s.Bus.GetCursor(r.Context(), stream, consumer)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cursor)
+	case http.MethodPut:
+		var payload struct {
+			EventID string `json:"event_id"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		cursor, err := s.Bus.SetCursor(r.Context(), stream, consumer, payload.EventID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cursor)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleStreamBulk marks events read (ack-all) or unread for a reader.
+// Events are chosen by explicit ids (unread only) or by the same scope and
+// field filters as listing, oldest first and at most limit per call.
+func (s *Server) handleStreamBulk(w http.ResponseWriter, r *http.Request, stream string, unread bool) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	var payload struct {
+		Reader    string            `json:"reader"`
+		IDs       []string          `json:"ids"`
+		ScopeType string            `json:"scope_type"`
+		ScopeID   string            `json:"scope_id"`
+		Filters   map[string]string `json:"filters"`
+		Before    string            `json:"before"`
+		Limit     int               `json:"limit"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	reader := strings.TrimSpace(payload.Reader)
+	if reader == "" {
+		writeError(w, http.StatusBadRequest, errBadRequest("reader is required"))
+		return
+	}
+	if len(payload.IDs) > 0 {
+		if !unread {
+			writeError(w, http.StatusBadRequest, errBadRequest("ids are only accepted by unread"))
+			return
+		}
+		if len(payload.IDs) > eventbus.MaxBulkLimit {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("at most %d ids per call", eventbus.MaxBulkLimit))
+			return
+		}
+		if err := s.Bus.MarkUnread(r.Context(), stream, payload.IDs, reader); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, eventbus.BulkResult{Changed: len(payload.IDs), EventIDs: payload.IDs})
+		return
+	}
+	filter := eventbus.BulkFilter{
+		ScopeType: payload.ScopeType,
+		ScopeID:   payload.ScopeID,
+		Namespace: requestNamespace(r),
+		Limit:     payload.Limit,
+	}
+	for key, value := range payload.Filters {
+		field, ok, err := eventbus.ParseFieldFilter(key, []string{value})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("filter %q must start with metadata. or payload.", key))
+			return
+		}
+		filter.Fields = append(filter.Fields, field)
+	}
+	if before := strings.TrimSpace(payload.Before); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest("before must be an RFC3339 time"))
+			return
+		}
+		filter.Before = parsed
+	}
+	var (
+		result eventbus.BulkResult
+		err    error
+	)
+	if unread {
+		result, err = s.Bus.MarkUnreadAll(r.Context(), stream, reader, filter)
+	} else {
+		result, err = s.Bus.AckAll(r.Context(), stream, reader, filter)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// subscribeFilter selects which subscribed events reach an SSE client.
+// Empty fields match everything.
+type subscribeFilter struct {
+	// minPriority drops events less urgent than it, judged the way the
+	// runtime does (a normal message wakes its agent).
+	minPriority  schema.Priority
+	agents       map[string]bool
+	kinds        map[string]bool
+	excludeKinds map[string]bool
+	// namespace drops events of other namespaces.
+	namespace string
+}
+
+func parseSubscribeFilter(query url.Values) (subscribeFilter, error) {
+	var f subscribeFilter
+	if raw := strings.TrimSpace(query.Get("min_priority")); raw != "" {
+		f.minPriority = schema.ParsePriority(raw)
+		if !strings.EqualFold(raw, string(f.minPriority)) {
+			return f, fmt.Errorf("min_priority must be low, normal, wake or interrupt")
+		}
+	}
+	f.agents = commaSet(query.Get("agent"))
+	f.kinds = commaSet(query.Get("kinds"))
+	f.excludeKinds = commaSet(query.Get("exclude_kinds"))
+	return f, nil
+}
+
+func commaSet(value string) map[string]bool {
+	parts := splitComma(value)
+	if len(parts) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(parts))
+	for _, p := range parts {
+		set[p] = true
+	}
+	return set
+}
+
+// matches reports whether evt passes the filter. An agent matches events
+// scoped to its task and events whose metadata names it as agent_id or
+// target.
+func (f subscribeFilter) matches(evt eventbus.Event) bool {
+	if !eventbus.InNamespace(evt, f.namespace) {
+		return false
+	}
+	if f.minPriority != "" && engine.EventPriority(evt).Rank() > f.minPriority.Rank() {
+		return false
+	}
+	if f.agents != nil {
+		scoped := evt.ScopeType == "task" && f.agents[evt.ScopeID]
+		if !scoped && !f.agents[schema.GetMetaString(evt.Metadata, "agent_id")] &&
+			!f.agents[schema.GetMetaString(evt.Metadata, "target")] {
+			return false
+		}
+	}
+	kind := schema.GetMetaString(evt.Metadata, "kind")
+	if f.kinds != nil && !f.kinds[kind] {
+		return false
+	}
+	return !f.excludeKinds[kind]
+}
+
+func (f subscribeFilter) empty() bool {
+	return f.minPriority == "" && f.agents == nil && f.kinds == nil && f.excludeKinds == nil && f.namespace == ""
+}
+
+// apply forwards the events from in that match the filter.
+func (f subscribeFilter) apply(ctx context.Context, in <-chan eventbus.Event) <-chan eventbus.Event {
+	if f.empty() {
+		return in
+	}
+	out := make(chan eventbus.Event)
+	go func() {
+		defer close(out)
+		for evt := range in {
+			if !f.matches(evt) {
+				continue
+			}
+			select {
+			case out <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/teams"
+)
+
+// teamOutputLimit caps the worker outputs returned with a team.
+const teamOutputLimit = 50
+
+// handleTeams serves /api/teams. GET lists the teams whose supervisor the
+// caller can view. POST {"supervisor_id", "workers", "max_concurrency"}
+// creates a team and needs owner access to the supervisor.
+func (s *Server) handleTeams(w http.ResponseWriter, r *http.Request) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.Runtime.Teams(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out := make([]teams.Team, 0, len(list))
+		for _, team := range list {
+			level, err := s.accessLevel(r.Context(), team.ID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if level >= access.LevelView {
+				out = append(out, team)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"teams": out})
+	case http.MethodPost:
+		var payload struct {
+			SupervisorID   string             `json:"supervisor_id"`
+			Workers        []teams.WorkerSpec `json:"workers"`
+			MaxConcurrency int                `json:"max_concurrency"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		supervisorID := strings.TrimSpace(payload.SupervisorID)
+		if supervisorID == "" {
+			writeError(w, http.StatusBadRequest, errBadRequest("supervisor_id is required"))
+			return
+		}
+		if !s.requireAccess(w, r, supervisorID, access.LevelOwner) {
+			return
+		}
+		team, err := s.Runtime.CreateTeam(r.Context(), supervisorID, payload.Workers, payload.MaxConcurrency)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, team)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleTeamItem serves /api/teams/<id>, where id is the supervisor's task
+// ID. GET returns the team with its workers' latest outputs, DELETE
+// disbands it, and POST .../assign {"to", "from", "body"} queues an
+// assignment; to defaults to the first free worker and from to the
+// supervisor.
+func (s *Server) handleTeamItem(w http.ResponseWriter, r *http.Request) {
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/teams/"), "/"), "/")
+	id := segments[0]
+	if id == "" || len(segments) > 2 || (len(segments) == 2 && segments[1] != "assign") {
+		writeError(w, http.StatusNotFound, errNotFound("team"))
+		return
+	}
+	if !s.requireAccess(w, r, id, readOrInteract(r)) {
+		return
+	}
+	team, err := s.Runtime.Team(r.Context(), id)
+	if errors.Is(err, teams.ErrNoTeam) {
+		writeError(w, http.StatusNotFound, errNotFound("team"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(segments) == 2 {
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		var payload struct {
+			To   string `json:"to"`
+			From string `json:"from"`
+			Body string `json:"body"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		assignment, err := s.Runtime.AssignToTeam(r.Context(), team.ID, payload.From, payload.To, payload.Body)
+		if errors.Is(err, teams.ErrQueueFull) {
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, assignment)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		outputs, err := s.Runtime.TeamOutputs(r.Context(), team.ID, teamOutputLimit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"team": team, "outputs": outputs})
+	case http.MethodDelete:
+		if !s.requireAccess(w, r, id, access.LevelOwner) {
+			return
+		}
+		if err := s.Runtime.DisbandTeam(r.Context(), team.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"disbanded": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/templates"
+)
+
+// handleTemplates serves GET /api/templates, which lists the task
+// templates.
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if s.Templates == nil {
+		writeError(w, http.StatusNotFound, errNotFound("task templates"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	list, err := s.Templates.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"templates": list})
+}
+
+// handleTemplateItem serves /api/templates/<name>: GET returns a template,
+// PUT creates or replaces it and DELETE removes it; the last two need admin
+// access when API keys are on. POST /api/templates/<name>/spawn
+// {"params": {...}, "source": "..."} spawns a task from it.
+func (s *Server) handleTemplateItem(w http.ResponseWriter, r *http.Request) {
+	if s.Templates == nil {
+		writeError(w, http.StatusNotFound, errNotFound("task templates"))
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/templates/"), "/"), "/")
+	name := parts[0]
+	if name == "" || len(parts) > 2 || len(parts) == 2 && parts[1] != "spawn" {
+		writeError(w, http.StatusNotFound, errNotFound("template"))
+		return
+	}
+	if len(parts) == 2 {
+		s.handleTemplateSpawn(w, r, name)
+		return
+	}
+	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+		if principal, ok := principalFrom(r.Context()); s.Access != nil && ok && !principal.Admin {
+			writeError(w, http.StatusForbidden, errors.New("admin access required"))
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		tmpl, err := s.Templates.Get(r.Context(), name)
+		if errors.Is(err, templates.ErrTemplateNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("template"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, tmpl)
+	case http.MethodPut:
+		var tmpl templates.Template
+		if err := decodeJSON(r.Body, &tmpl); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if tmpl.Name != "" && tmpl.Name != name {
+			writeError(w, http.StatusBadRequest, errBadRequest("name does not match the path"))
+			return
+		}
+		tmpl.Name = name
+		saved, err := s.Templates.Put(r.Context(), tmpl)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		err := s.Templates.Delete(r.Context(), name)
+		if errors.Is(err, templates.ErrTemplateNotFound) {
+			writeError(w, http.StatusNotFound, errNotFound("template"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleTemplateSpawn(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Tasks == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("task manager"))
+		return
+	}
+	var payload struct {
+		Params map[string]any `json:"params"`
+		Source string         `json:"source"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tmpl, err := s.Templates.Get(r.Context(), name)
+	if errors.Is(err, templates.ErrTemplateNotFound) {
+		writeError(w, http.StatusNotFound, errNotFound("template"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	spec, err := templates.Instantiate(tmpl, payload.Params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if spec.Mode == "" {
+		spec.Mode = "async"
+	}
+	spec.Metadata["source"] = strings.TrimSpace(payload.Source)
+	created, err := s.Tasks.Spawn(r.Context(), spec)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"task_id":  created.ID,
+		"status":   string(created.Status),
+		"type":     created.Type,
+		"template": tmpl.Name,
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// handleAgentTerminate serves POST /api/agents/<id>/terminate, which stops
+// the agent's loop, interrupts its in-flight turns and kills its task and
+// all open descendant tasks in one step. {"dry_run": true} only reports what
+// would be stopped. The body is optional.
+func (s *Server) handleAgentTerminate(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var payload struct {
+		Reason string `json:"reason"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := decodeJSON(r.Body, &payload); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	report, err := s.Runtime.TerminateAgent(r.Context(), agentID, payload.Reason, payload.DryRun)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
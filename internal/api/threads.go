@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/engine"
+)
+
+// handleThreads serves GET /api/threads?agents=a,b[,c][&thread_id=...]. Pass
+// format=markdown for a Markdown transcript instead of JSON.
+func (s *Server) handleThreads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusInternalServerError, errNotFound("runtime"))
+		return
+	}
+	query := r.URL.Query()
+	agentIDs := strings.Split(query.Get("agents"), ",")
+	for _, agentID := range agentIDs {
+		if agentID = strings.TrimSpace(agentID); agentID != "" && !s.requireAccess(w, r, agentID, access.LevelView) {
+			return
+		}
+	}
+	thread, err := s.Runtime.Thread(r.Context(), agentIDs, query.Get("thread_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(engine.RenderThreadMarkdown(thread)))
+		return
+	}
+	writeJSON(w, http.StatusOK, thread)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/topics"
+)
+
+// handleTopics lists topics that have subscribers.
+func (s *Server) handleTopics(w http.ResponseWriter, r *http.Request) {
+	if s.Topics == nil {
+		writeError(w, http.StatusNotFound, errNotFound("topic store"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	list, err := s.Topics.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if list == nil {
+		list = []topics.Topic{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleTopicItem serves /api/topics/<topic>: GET lists subscribers, POST
+// publishes an event to them.
+func (s *Server) handleTopicItem(w http.ResponseWriter, r *http.Request) {
+	if s.Topics == nil {
+		writeError(w, http.StatusNotFound, errNotFound("topic store"))
+		return
+	}
+	topic := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/topics/"), "/")
+	if topic == "" {
+		writeError(w, http.StatusNotFound, errNotFound("topic"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := s.Topics.Subscribers(r.Context(), topic)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"topic": topic, "subscribers": subs})
+	case http.MethodPost:
+		var payload struct {
+			Source   string         `json:"source"`
+			Subject  string         `json:"subject"`
+			Body     string         `json:"body"`
+			Priority string         `json:"priority"`
+			Payload  map[string]any `json:"payload"`
+		}
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, ok := s.validPriority(w, "topic_publish", payload.Priority); !ok {
+			return
+		}
+		evt, err := s.Topics.Publish(r.Context(), topics.PublishInput{
+			Topic:    topic,
+			Source:   payload.Source,
+			Subject:  payload.Subject,
+			Body:     payload.Body,
+			Priority: payload.Priority,
+			Payload:  payload.Payload,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if s.Runtime != nil {
+			if subs, err := s.Topics.Subscribers(r.Context(), evt.ScopeID); err == nil {
+				for _, sub := range subs {
+					s.Runtime.EnsureAgentLoop(sub.AgentID)
+				}
+			}
+		}
+		writeJSON(w, http.StatusCreated, evt)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleAgentTopics serves /api/agents/<id>/topics: GET lists the agent's
+// subscriptions, PUT /topics/<topic> subscribes and DELETE unsubscribes.
+func (s *Server) handleAgentTopics(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if s.Topics == nil {
+		writeError(w, http.StatusNotFound, errNotFound("topic store"))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && len(rest) == 0:
+		subs, err := s.Topics.ForAgent(r.Context(), agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if subs == nil {
+			subs = []topics.Subscription{}
+		}
+		writeJSON(w, http.StatusOK, subs)
+	case r.Method == http.MethodPut && len(rest) == 1:
+		sub, err := s.Topics.Subscribe(r.Context(), agentID, rest[0])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, sub)
+	case r.Method == http.MethodDelete && len(rest) == 1:
+		removed, err := s.Topics.Unsubscribe(r.Context(), agentID, rest[0])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, errNotFound("topic subscription"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
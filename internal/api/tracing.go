@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// withTracing runs each request in a span that continues the caller's trace
+// when the request has a W3C traceparent header. Messages sent and tasks
+// spawned by the request carry that trace on to the turns that handle them.
+func (s *Server) withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(tracing.FromHeader(r.Context(), r.Header), "HTTP "+r.Method,
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path))
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/engine"
+)
+
+// handleAgentTurns serves POST /api/agents/<id>/turns/<llm_task_id>/undo,
+// which retracts a turn: the tasks it spawned are cancelled and later turns
+// are told to disregard it. The body may carry {"reason": "..."}.
+//
+// GET /api/agents/<id>/turns/<llm_task_id>/prompt returns the prompt a turn
+// was given, and GET /api/agents/<id>/turns/diff?from=<llm_task_id>&to=<llm_task_id>
+// compares the prompts of two turns. POST .../turns/<llm_task_id>/feedback
+// rates a turn.
+func (s *Server) handleAgentTurns(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if len(rest) == 2 && rest[0] != "" && rest[1] == "feedback" {
+		s.handleTurnFeedback(w, r, agentID, rest[0])
+		return
+	}
+	if len(rest) == 1 && rest[0] == "diff" || len(rest) == 2 && rest[0] != "" && rest[1] == "prompt" {
+		s.handleTurnPrompt(w, r, agentID, rest)
+		return
+	}
+	if len(rest) != 2 || rest[0] == "" || rest[1] != "undo" {
+		writeError(w, http.StatusNotFound, errNotFound("turn action"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	undo, err := s.Runtime.UndoTurn(r.Context(), agentID, rest[0], payload.Reason)
+	switch {
+	case errors.Is(err, engine.ErrTurnNotFound):
+		writeError(w, http.StatusNotFound, errNotFound("turn"))
+	case errors.Is(err, engine.ErrTurnAlreadyRetracted):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, undo)
+	}
+}
+
+func (s *Server) handleTurnPrompt(w http.ResponseWriter, r *http.Request, agentID string, rest []string) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Runtime == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("runtime unavailable"))
+		return
+	}
+	var (
+		out any
+		err error
+	)
+	if rest[0] == "diff" {
+		query := r.URL.Query()
+		from, to := strings.TrimSpace(query.Get("from")), strings.TrimSpace(query.Get("to"))
+		if from == "" || to == "" {
+			writeError(w, http.StatusBadRequest, errBadRequest("from and to are required"))
+			return
+		}
+		out, err = s.Runtime.DiffTurnPrompts(r.Context(), agentID, from, to)
+	} else {
+		out, err = s.Runtime.TurnPrompt(r.Context(), agentID, rest[0])
+	}
+	switch {
+	case errors.Is(err, engine.ErrTurnNotFound):
+		writeError(w, http.StatusNotFound, errNotFound("turn"))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, out)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/usage"
+)
+
+// usageReport is the body of GET /api/usage.
+type usageReport struct {
+	GroupBy []string     `json:"group_by"`
+	From    *time.Time   `json:"from,omitempty"`
+	To      *time.Time   `json:"to,omitempty"`
+	Rows    []usage.Row  `json:"rows"`
+	Total   usage.Totals `json:"total"`
+}
+
+// handleUsage serves GET /api/usage: the tokens and estimated cost of the
+// turns of the agents the caller can view, rolled up by ?group_by= (a comma
+// separated list of agent, model and day; agent by default). ?agent_id=,
+// ?model=, ?from= and ?to= narrow the turns; times are RFC 3339 or a UTC
+// date, and to is exclusive.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.Usage == nil {
+		writeError(w, http.StatusNotFound, errNotFound("usage"))
+		return
+	}
+	query := r.URL.Query()
+	groupBy := []string{usage.ByAgent}
+	if raw := strings.TrimSpace(query.Get("group_by")); raw != "" {
+		groupBy = nil
+		for _, dim := range strings.Split(raw, ",") {
+			if dim = strings.TrimSpace(dim); dim != "" {
+				groupBy = append(groupBy, dim)
+			}
+		}
+	}
+	if err := usage.ValidateGroupBy(groupBy); err != nil {
+		writeError(w, http.StatusBadRequest, errBadRequest(err.Error()))
+		return
+	}
+	filter := usage.Filter{
+		AgentID: strings.TrimSpace(query.Get("agent_id")),
+		Model:   strings.TrimSpace(query.Get("model")),
+	}
+	report := usageReport{GroupBy: groupBy}
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+		out  **time.Time
+	}{{"from", &filter.From, &report.From}, {"to", &filter.To, &report.To}} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		t, err := parseUsageTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest(param.name+" must be RFC 3339 or a date"))
+			return
+		}
+		*param.dst = t
+		*param.out = &t
+	}
+	rows, err := s.Usage.Rollup(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if rows, err = s.visibleUsage(r.Context(), rows); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	report.Rows = usage.Group(rows, groupBy...)
+	if report.Rows == nil {
+		report.Rows = []usage.Row{}
+	}
+	report.Total = usage.Total(rows)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// visibleUsage drops the rows of agents the caller cannot view.
+func (s *Server) visibleUsage(ctx context.Context, rows []usage.Row) ([]usage.Row, error) {
+	levels := map[string]access.Level{}
+	out := rows[:0]
+	for _, row := range rows {
+		level, ok := levels[row.AgentID]
+		if !ok {
+			var err error
+			if level, err = s.accessLevel(ctx, row.AgentID); err != nil {
+				return nil, err
+			}
+			levels[row.AgentID] = level
+		}
+		if level >= access.LevelView {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func parseUsageTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, raw)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// APIVersion is the newest API version, served under /api/v1.
+	APIVersion = 1
+	// VersionHeader carries the API version: clients may send it to pin a
+	// version, and every response names the version that served it.
+	VersionHeader = "X-API-Version"
+	// SupportedVersionsHeader lists every version the server accepts.
+	SupportedVersionsHeader = "X-API-Supported-Versions"
+)
+
+// supportedVersions lists the versions this server serves, oldest first.
+var supportedVersions = []int{1}
+
+// Deprecation marks an endpoint as scheduled for removal. Responses from
+// matching paths carry Deprecation, Sunset and successor Link headers, and
+// GET /api/versions lists every deprecation so clients can find them ahead
+// of time.
+type Deprecation struct {
+	// Path is the endpoint's path without the version prefix, such as
+	// "/api/tasks/queue"; it matches the path and anything beneath it.
+	Path string `json:"path"`
+	// Since is when the endpoint was deprecated.
+	Since time.Time `json:"since"`
+	// Sunset is when it may be removed; zero means no date is set yet.
+	Sunset time.Time `json:"sunset,omitempty"`
+	// Successor optionally names the endpoint that replaces it.
+	Successor string `json:"successor,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// withVersion negotiates the API version. Versioned paths (/api/v1/...) are
+// served by the same handlers as the unversioned paths they map to. The
+// unversioned paths remain as a compatibility shim for clients written
+// before versioning: they serve the version asked for in X-API-Version, or
+// v1, and are marked deprecated with a link to their versioned successor.
+func (s *Server) withVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set(SupportedVersionsHeader, versionList())
+		version, path, versioned, err := negotiateVersion(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		header.Set(VersionHeader, strconv.Itoa(version))
+		if !versioned {
+			setDeprecationHeaders(header, Deprecation{
+				Successor: versionedPath(version, path),
+				Sunset:    s.LegacyAPISunset,
+			})
+		}
+		for _, d := range s.Deprecations {
+			if path == d.Path || strings.HasPrefix(path, strings.TrimSuffix(d.Path, "/")+"/") {
+				if d.Successor != "" {
+					d.Successor = versionedPath(version, d.Successor)
+				}
+				setDeprecationHeaders(header, d)
+				break
+			}
+		}
+		if versioned {
+			r2 := new(http.Request)
+			*r2 = *r
+			u := *r.URL
+			u.Path = path
+			u.RawPath = ""
+			r2.URL = &u
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// negotiateVersion returns the version to serve and the request path with
+// any version prefix removed. A version in the path must agree with
+// X-API-Version when both are given.
+func negotiateVersion(r *http.Request) (int, string, bool, error) {
+	path := r.URL.Path
+	version, versioned := 0, false
+	if rest, ok := strings.CutPrefix(path, "/api/v"); ok {
+		segment, tail, _ := strings.Cut(rest, "/")
+		n, err := strconv.Atoi(segment)
+		if err == nil && n > 0 {
+			if !isSupportedVersion(n) {
+				return 0, "", false, fmt.Errorf("unsupported API version %d; supported: %s", n, versionList())
+			}
+			version, versioned = n, true
+			path = "/api/" + tail
+		}
+	}
+	if raw := strings.TrimPrefix(strings.TrimSpace(r.Header.Get(VersionHeader)), "v"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || !isSupportedVersion(n) {
+			return 0, "", false, fmt.Errorf("unsupported API version %q; supported: %s", raw, versionList())
+		}
+		if versioned && n != version {
+			return 0, "", false, fmt.Errorf("%s %d conflicts with path version %d", VersionHeader, n, version)
+		}
+		version = n
+	}
+	if version == 0 {
+		version = APIVersion
+	}
+	return version, path, versioned, nil
+}
+
+// setDeprecationHeaders writes the headers of RFC 9745 (Deprecation), RFC
+// 8594 (Sunset) and a successor-version link.
+func setDeprecationHeaders(header http.Header, d Deprecation) {
+	if d.Since.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		header.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Successor))
+	}
+}
+
+func versionedPath(version int, path string) string {
+	return fmt.Sprintf("/api/v%d/%s", version, strings.TrimPrefix(path, "/api/"))
+}
+
+func isSupportedVersion(version int) bool {
+	for _, v := range supportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func versionList() string {
+	parts := make([]string, len(supportedVersions))
+	for i, v := range supportedVersions {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// handleVersions serves GET /api/versions: the current and supported
+// versions, the status of the unversioned paths, and every deprecation.
+func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	deprecations := append([]Deprecation(nil), s.Deprecations...)
+	sort.SliceStable(deprecations, func(i, j int) bool { return deprecations[i].Path < deprecations[j].Path })
+	legacy := map[string]any{"deprecated": true}
+	if !s.LegacyAPISunset.IsZero() {
+		legacy["sunset"] = s.LegacyAPISunset.UTC()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"current":           APIVersion,
+		"supported":         supportedVersions,
+		"prefix":            versionedPath(APIVersion, "/api/"),
+		"unversioned_paths": legacy,
+		"deprecations":      deprecations,
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/pkg/agenttest"
 	"github.com/flitsinc/go-llms/content"
 	"github.com/flitsinc/go-llms/llms"
 )
@@ -23,8 +24,8 @@ globalThis.result = {
 };`,
 		"wait_seconds": 5,
 	})
-	provider := newScriptedProvider(
-		newScriptedStream(scriptedStreamSpec{
+	provider := agenttest.NewScriptedProvider(
+		agenttest.NewStream(agenttest.StreamSpec{
 			Message: llms.Message{
 				Role: "assistant",
 				Content: content.FromText(
@@ -49,7 +50,7 @@ globalThis.result = {
 				llms.StreamStatusToolCallReady,
 			},
 		}),
-		newScriptedStream(scriptedStreamSpec{
+		agenttest.NewStream(agenttest.StreamSpec{
 			Message: llms.Message{Role: "assistant", Content: content.FromText(weatherFinalText)},
 			Text:    weatherFinalText,
 			Statuses: []llms.StreamStatus{
@@ -103,7 +104,7 @@ globalThis.result = {
 	if err != nil {
 		t.Fatalf("render snapshot markdown: %v", err)
 	}
-	assertSnapshot(t, filepath.Join("testdata", "weather_session_snapshot.md"), got)
+	agenttest.AssertSnapshot(t, filepath.Join("testdata", "weather_session_snapshot.md"), got)
 }
 
 const weatherFinalText = `Perfect! Here's the current weather in Amsterdam:
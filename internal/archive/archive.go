@@ -0,0 +1,589 @@
+// Package archive moves cold data to object storage: events past their
+// retention, the history of an agent's older generations, and finished task
+// trees. Every archived object is recorded in a local index, so reads that
+// reach past what the database holds can rehydrate it without listing the
+// bucket.
+//
+// Objects are written before the rows they hold are deleted. A pass that
+// fails in between writes the same object again on the next run, so an
+// archived item may be stored twice but is never lost.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/objectstore"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+const (
+	DefaultPollInterval = time.Hour
+	DefaultBatchSize    = 500
+	// maxBatches bounds how many objects one pass writes per stream or
+	// agent, so a large backlog is worked off over several passes.
+	maxBatches = 20
+	// maxAgents bounds how many agents a pass looks at for old generations.
+	maxAgents = 1000
+	// maxUpdates bounds the updates kept per archived task.
+	maxUpdates = 10000
+	// maxRehydrated bounds how many archived events one read returns.
+	maxRehydrated = 5000
+)
+
+// Kinds of archived objects.
+const (
+	KindEvents     = "events"
+	KindGeneration = "generation"
+	KindTaskTree   = "task_tree"
+)
+
+var ErrNotFound = errors.New("archived item not found")
+
+// eventStreams are archived by age. History is archived by generation
+// instead, since an agent's current conversation may be older than any
+// retention.
+var eventStreams = append(slices.Clone(schema.AgentStreams),
+	schema.StreamProgress,
+	schema.StreamReports,
+	schema.StreamAlerts,
+	schema.StreamDeadLetter,
+)
+
+// Entry is one archived object in the local index. Batches of events from
+// several scopes leave ScopeType and ScopeID empty.
+type Entry struct {
+	Key        string    `json:"key"`
+	Kind       string    `json:"kind"`
+	Stream     string    `json:"stream,omitempty"`
+	ScopeType  string    `json:"scope_type,omitempty"`
+	ScopeID    string    `json:"scope_id,omitempty"`
+	Generation int64     `json:"generation,omitempty"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+	Items      int       `json:"items"`
+	Bytes      int       `json:"bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TaskTree is an archived top-level task with its descendants, parents
+// before children, and each task's updates.
+type TaskTree struct {
+	Tasks   []tasks.Task              `json:"tasks"`
+	Updates map[string][]tasks.Update `json:"updates"`
+}
+
+// Result counts what one pass archived.
+type Result struct {
+	Events  int `json:"events"`
+	History int `json:"history"`
+	Tasks   int `json:"tasks"`
+}
+
+// Filter narrows the index. Empty fields match everything.
+type Filter struct {
+	Kind    string
+	Stream  string
+	ScopeID string
+	Limit   int
+}
+
+// Query selects archived events of one stream. Zero times leave that end of
+// the range open; To is exclusive.
+type Query struct {
+	Stream    string
+	ScopeType string
+	ScopeID   string
+	From      time.Time
+	To        time.Time
+}
+
+type Archiver struct {
+	db    *sql.DB
+	bus   *eventbus.Bus
+	tasks *tasks.Manager
+	store objectstore.Store
+	cfg   Config
+
+	batchSize int
+	nowFn     func() time.Time
+}
+
+type Option func(*Archiver)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(a *Archiver) {
+		if nowFn != nil {
+			a.nowFn = nowFn
+		}
+	}
+}
+
+// WithBatchSize sets how many events or task trees go into one object.
+func WithBatchSize(n int) Option {
+	return func(a *Archiver) {
+		if n > 0 {
+			a.batchSize = n
+		}
+	}
+}
+
+func New(db *sql.DB, bus *eventbus.Bus, manager *tasks.Manager, store objectstore.Store, cfg Config, opts ...Option) *Archiver {
+	a := &Archiver{
+		db:        db,
+		bus:       bus,
+		tasks:     manager,
+		store:     store,
+		cfg:       cfg,
+		batchSize: DefaultBatchSize,
+		nowFn:     func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+	return a
+}
+
+func (a *Archiver) now() time.Time {
+	if a.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return a.nowFn().UTC()
+}
+
+// Run archives whatever is past the configured retention. Kinds of data
+// with no retention configured are left alone.
+func (a *Archiver) Run(ctx context.Context) (Result, error) {
+	var res Result
+	var err error
+	if a.cfg.EventRetentionDays > 0 {
+		if res.Events, err = a.archiveEvents(ctx); err != nil {
+			return res, err
+		}
+	}
+	if a.cfg.KeepGenerations > 0 && a.tasks != nil {
+		if res.History, err = a.archiveGenerations(ctx); err != nil {
+			return res, err
+		}
+	}
+	if a.cfg.TaskRetentionDays > 0 && a.tasks != nil {
+		if res.Tasks, err = a.archiveTasks(ctx); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (a *Archiver) archiveEvents(ctx context.Context) (int, error) {
+	cutoff := a.now().AddDate(0, 0, -a.cfg.EventRetentionDays)
+	total := 0
+	for _, stream := range eventStreams {
+		for range maxBatches {
+			events, err := a.listEvents(ctx, stream, eventbus.ListOptions{AllScopes: true, Before: cutoff})
+			if err != nil {
+				return total, err
+			}
+			if len(events) == 0 {
+				break
+			}
+			first, last := events[0], events[len(events)-1]
+			key := fmt.Sprintf("%sevents/%s/%s/%s_%s.jsonl", a.cfg.Prefix, stream, first.CreatedAt.UTC().Format("2006/01/02"), first.ID, last.ID)
+			n, err := a.moveEvents(ctx, Entry{Key: key, Kind: KindEvents, Stream: stream}, events)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if len(events) < a.batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// archiveGenerations archives the history of every agent's generations
+// older than the newest KeepGenerations, one object per generation and
+// batch.
+func (a *Archiver) archiveGenerations(ctx context.Context) (int, error) {
+	agents, err := a.tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: maxAgents})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, agent := range agents {
+		latest, err := a.latestGeneration(ctx, agent.ID)
+		if err != nil {
+			return total, err
+		}
+		cutoff := latest - int64(a.cfg.KeepGenerations)
+		if cutoff < 1 {
+			continue
+		}
+		for range maxBatches {
+			events, err := a.listEvents(ctx, schema.StreamHistory, eventbus.ListOptions{ScopeType: "task", ScopeID: agent.ID})
+			if err != nil {
+				return total, err
+			}
+			// History is appended in generation order, so the old
+			// generations are a prefix of the oldest events.
+			end := 0
+			for end < len(events) && eventGeneration(events[end]) <= cutoff {
+				end++
+			}
+			if end == 0 {
+				break
+			}
+			for start := 0; start < end; {
+				gen := eventGeneration(events[start])
+				stop := start
+				for stop < end && eventGeneration(events[stop]) == gen {
+					stop++
+				}
+				batch := events[start:stop]
+				key := fmt.Sprintf("%shistory/%s/%d/%s_%s.jsonl", a.cfg.Prefix, agent.ID, gen, batch[0].ID, batch[len(batch)-1].ID)
+				n, err := a.moveEvents(ctx, Entry{
+					Key:        key,
+					Kind:       KindGeneration,
+					Stream:     schema.StreamHistory,
+					ScopeType:  "task",
+					ScopeID:    agent.ID,
+					Generation: gen,
+				}, batch)
+				total += n
+				if err != nil {
+					return total, err
+				}
+				start = stop
+			}
+			if end < len(events) || len(events) < a.batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+func (a *Archiver) latestGeneration(ctx context.Context, agentID string) (int64, error) {
+	summaries, err := a.bus.List(ctx, schema.StreamHistory, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Limit:     1,
+		Order:     "lifo",
+	})
+	if err != nil || len(summaries) == 0 {
+		return 0, err
+	}
+	events, err := a.bus.Read(ctx, schema.StreamHistory, []string{summaries[0].ID}, "")
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	return eventGeneration(events[0]), nil
+}
+
+func eventGeneration(evt eventbus.Event) int64 {
+	for _, m := range []map[string]any{evt.Metadata, evt.Payload} {
+		switch v := m["generation"].(type) {
+		case float64:
+			return int64(v)
+		case int64:
+			return v
+		case int:
+			return int64(v)
+		case json.Number:
+			n, _ := v.Int64()
+			return n
+		}
+	}
+	return 0
+}
+
+func (a *Archiver) archiveTasks(ctx context.Context) (int, error) {
+	cutoff := a.now().AddDate(0, 0, -a.cfg.TaskRetentionDays)
+	trees, err := a.tasks.FinishedTrees(ctx, cutoff, a.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, list := range trees {
+		tree := TaskTree{Tasks: list, Updates: map[string][]tasks.Update{}}
+		ids := make([]string, 0, len(list))
+		entry := Entry{Kind: KindTaskTree, ScopeType: "task", ScopeID: list[0].ID, Items: len(list)}
+		for _, task := range list {
+			updates, err := a.tasks.ListUpdates(ctx, task.ID, maxUpdates)
+			if err != nil {
+				return total, err
+			}
+			if len(updates) > 0 {
+				tree.Updates[task.ID] = updates
+			}
+			ids = append(ids, task.ID)
+			if entry.FirstAt.IsZero() || task.CreatedAt.Before(entry.FirstAt) {
+				entry.FirstAt = task.CreatedAt
+			}
+			if task.UpdatedAt.After(entry.LastAt) {
+				entry.LastAt = task.UpdatedAt
+			}
+		}
+		body, err := json.Marshal(tree)
+		if err != nil {
+			return total, fmt.Errorf("encode task tree %s: %w", entry.ScopeID, err)
+		}
+		entry.Key = fmt.Sprintf("%stasks/%s/%s.json", a.cfg.Prefix, entry.LastAt.UTC().Format("2006/01/02"), entry.ScopeID)
+		if err := a.put(ctx, entry, body, "application/json"); err != nil {
+			return total, err
+		}
+		if err := a.tasks.Remove(ctx, ids); err != nil {
+			return total, err
+		}
+		total += len(ids)
+	}
+	return total, nil
+}
+
+// listEvents reads the oldest batch of stream's events matching opts.
+func (a *Archiver) listEvents(ctx context.Context, stream string, opts eventbus.ListOptions) ([]eventbus.Event, error) {
+	opts.Order = "fifo"
+	opts.Limit = a.batchSize
+	summaries, err := a.bus.List(ctx, stream, opts)
+	if err != nil || len(summaries) == 0 {
+		return nil, err
+	}
+	ids := make([]string, len(summaries))
+	for i, summary := range summaries {
+		ids[i] = summary.ID
+	}
+	events, err := a.bus.Read(ctx, stream, ids, "")
+	if err != nil {
+		return nil, err
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// moveEvents writes events to the object entry describes, then deletes
+// them from the bus.
+func (a *Archiver) moveEvents(ctx context.Context, entry Entry, events []eventbus.Event) (int, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	ids := make([]string, len(events))
+	for i, evt := range events {
+		if err := enc.Encode(evt); err != nil {
+			return 0, fmt.Errorf("encode event %s: %w", evt.ID, err)
+		}
+		ids[i] = evt.ID
+	}
+	entry.FirstAt = events[0].CreatedAt
+	entry.LastAt = events[len(events)-1].CreatedAt
+	entry.Items = len(events)
+	if err := a.put(ctx, entry, buf.Bytes(), "application/x-ndjson"); err != nil {
+		return 0, err
+	}
+	return a.bus.Delete(ctx, entry.Stream, ids)
+}
+
+// put stores body and records it in the index.
+func (a *Archiver) put(ctx context.Context, entry Entry, body []byte, contentType string) error {
+	if err := a.store.Put(ctx, entry.Key, body, contentType); err != nil {
+		return err
+	}
+	entry.Bytes = len(body)
+	entry.CreatedAt = a.now()
+	if _, err := a.db.ExecContext(ctx, `
+		INSERT INTO archive_index (key, kind, stream, scope_type, scope_id, generation, first_at, last_at, items, bytes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			items = excluded.items,
+			bytes = excluded.bytes,
+			created_at = excluded.created_at
+	`, entry.Key, entry.Kind, entry.Stream, entry.ScopeType, entry.ScopeID, entry.Generation,
+		entry.FirstAt.UTC().Format(state.TimeLayout), entry.LastAt.UTC().Format(state.TimeLayout),
+		entry.Items, entry.Bytes, entry.CreatedAt.Format(state.TimeLayout)); err != nil {
+		return fmt.Errorf("index archive object %s: %w", entry.Key, err)
+	}
+	return nil
+}
+
+// Index lists archived objects, oldest first.
+func (a *Archiver) Index(ctx context.Context, filter Filter) ([]Entry, error) {
+	query := `SELECT key, kind, stream, scope_type, scope_id, generation, first_at, last_at, items, bytes, created_at FROM archive_index`
+	var clauses []string
+	var args []any
+	if filter.Kind != "" {
+		clauses = append(clauses, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.Stream != "" {
+		clauses = append(clauses, "stream = ?")
+		args = append(args, filter.Stream)
+	}
+	if filter.ScopeID != "" {
+		clauses = append(clauses, "scope_id = ?")
+		args = append(args, filter.ScopeID)
+	}
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY first_at ASC, key ASC LIMIT ?"
+	args = append(args, limit)
+	return a.queryIndex(ctx, query, args...)
+}
+
+// Events rehydrates the archived events matching q, oldest first. Callers
+// merge them with live events using Merge.
+func (a *Archiver) Events(ctx context.Context, q Query) ([]eventbus.Event, error) {
+	if strings.TrimSpace(q.Stream) == "" {
+		return nil, fmt.Errorf("stream is required")
+	}
+	query := `SELECT key, kind, stream, scope_type, scope_id, generation, first_at, last_at, items, bytes, created_at
+		FROM archive_index WHERE kind IN (?, ?) AND stream = ?`
+	args := []any{KindEvents, KindGeneration, q.Stream}
+	if q.ScopeID != "" {
+		query += " AND (scope_id = '' OR scope_id = ?)"
+		args = append(args, q.ScopeID)
+	}
+	if !q.From.IsZero() {
+		query += " AND last_at >= ?"
+		args = append(args, q.From.UTC().Format(state.TimeLayout))
+	}
+	if !q.To.IsZero() {
+		query += " AND first_at < ?"
+		args = append(args, q.To.UTC().Format(state.TimeLayout))
+	}
+	query += " ORDER BY first_at ASC, key ASC"
+	entries, err := a.queryIndex(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []eventbus.Event
+	for _, entry := range entries {
+		body, err := a.store.Get(ctx, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		for dec.More() {
+			var evt eventbus.Event
+			if err := dec.Decode(&evt); err != nil {
+				return nil, fmt.Errorf("decode archive object %s: %w", entry.Key, err)
+			}
+			if !q.Matches(evt) {
+				continue
+			}
+			out = append(out, evt)
+		}
+		if len(out) >= maxRehydrated {
+			break
+		}
+	}
+	out = Merge(out)
+	if len(out) > maxRehydrated {
+		out = out[:maxRehydrated]
+	}
+	return out, nil
+}
+
+// Matches reports whether evt falls within q's scope and time range.
+func (q Query) Matches(evt eventbus.Event) bool {
+	if q.ScopeType != "" && evt.ScopeType != q.ScopeType {
+		return false
+	}
+	if q.ScopeID != "" && evt.ScopeID != q.ScopeID {
+		return false
+	}
+	if !q.From.IsZero() && evt.CreatedAt.Before(q.From) {
+		return false
+	}
+	return q.To.IsZero() || evt.CreatedAt.Before(q.To)
+}
+
+// TaskTree rehydrates the archived tree whose top-level task is rootID.
+func (a *Archiver) TaskTree(ctx context.Context, rootID string) (TaskTree, error) {
+	var key string
+	err := a.db.QueryRowContext(ctx, `SELECT key FROM archive_index WHERE kind = ? AND scope_id = ? ORDER BY created_at DESC LIMIT 1`,
+		KindTaskTree, strings.TrimSpace(rootID)).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TaskTree{}, ErrNotFound
+	}
+	if err != nil {
+		return TaskTree{}, fmt.Errorf("find archived task tree: %w", err)
+	}
+	body, err := a.store.Get(ctx, key)
+	if err != nil {
+		return TaskTree{}, err
+	}
+	var tree TaskTree
+	if err := json.Unmarshal(body, &tree); err != nil {
+		return TaskTree{}, fmt.Errorf("decode archive object %s: %w", key, err)
+	}
+	if tree.Updates == nil {
+		tree.Updates = map[string][]tasks.Update{}
+	}
+	return tree, nil
+}
+
+func (a *Archiver) queryIndex(ctx context.Context, query string, args ...any) ([]Entry, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query archive index: %w", err)
+	}
+	defer rows.Close()
+	out := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var firstAt, lastAt, createdAt string
+		if err := rows.Scan(&e.Key, &e.Kind, &e.Stream, &e.ScopeType, &e.ScopeID, &e.Generation, &firstAt, &lastAt, &e.Items, &e.Bytes, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan archive index: %w", err)
+		}
+		e.FirstAt, _ = time.Parse(state.TimeLayout, firstAt)
+		e.LastAt, _ = time.Parse(state.TimeLayout, lastAt)
+		e.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate archive index: %w", err)
+	}
+	return out, nil
+}
+
+// Merge combines event lists, dropping repeated IDs, oldest first.
+func Merge(lists ...[]eventbus.Event) []eventbus.Event {
+	seen := map[string]bool{}
+	var out []eventbus.Event
+	for _, list := range lists {
+		for _, evt := range list {
+			if seen[evt.ID] {
+				continue
+			}
+			seen[evt.ID] = true
+			out = append(out, evt)
+		}
+	}
+	sortEvents(out)
+	return out
+}
+
+func sortEvents(events []eventbus.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/objectstore"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestRunArchivesColdDataAndRehydratesIt(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock))
+	mgr := tasks.NewManager(db, bus, tasks.WithClock(clock))
+	push := func(input eventbus.EventInput) eventbus.Event {
+		t.Helper()
+		evt, err := bus.Push(ctx, input)
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		now = now.Add(time.Minute)
+		return evt
+	}
+	history := func(gen int, body string) eventbus.Event {
+		return push(eventbus.EventInput{
+			Stream:    schema.StreamHistory,
+			ScopeType: "task",
+			ScopeID:   "agent-1",
+			Body:      body,
+			Metadata:  map[string]any{"kind": "history_entry", "generation": gen},
+			Payload:   map[string]any{"generation": gen, "type": "user_message", "content": body},
+		})
+	}
+
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "agent-1", Type: "agent", Owner: "agent-1"}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	oldMessage := push(eventbus.EventInput{Stream: schema.StreamTaskInput, ScopeType: "task", ScopeID: "agent-1", Body: "old"})
+	history(1, "first")
+	history(1, "second")
+	history(2, "third")
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "job", Type: "exec", Owner: "agent-1"}); err != nil {
+		t.Fatalf("spawn job: %v", err)
+	}
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: "job-child", Type: "exec", Owner: "agent-1", ParentID: "job"}); err != nil {
+		t.Fatalf("spawn child: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, "job-child", "stdout", map[string]any{"text": "hi"}); err != nil {
+		t.Fatalf("record update: %v", err)
+	}
+	for _, id := range []string{"job-child", "job"} {
+		if err := mgr.Complete(ctx, id, map[string]any{"ok": true}); err != nil {
+			t.Fatalf("complete %s: %v", id, err)
+		}
+	}
+
+	now = now.AddDate(0, 0, 40)
+	history(3, "current")
+	freshMessage := push(eventbus.EventInput{Stream: schema.StreamTaskInput, ScopeType: "task", ScopeID: "agent-1", Body: "fresh"})
+
+	archiver := New(db, bus, mgr, objectstore.Dir{Root: t.TempDir()}, Config{
+		Prefix:             "test/",
+		EventRetentionDays: 30,
+		KeepGenerations:    1,
+		TaskRetentionDays:  7,
+	}, WithClock(clock), WithBatchSize(2))
+
+	res, err := archiver.Run(ctx)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.History != 3 || res.Tasks != 2 || res.Events == 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res, err := archiver.Run(ctx); err != nil || res != (Result{}) {
+		t.Fatalf("expected nothing left to archive, got %+v, %v", res, err)
+	}
+
+	live, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{AllScopes: true, Order: "fifo"})
+	if err != nil {
+		t.Fatalf("list task input: %v", err)
+	}
+	if len(live) != 1 || live[0].ID != freshMessage.ID {
+		t.Fatalf("expected only the fresh message left, got %+v", live)
+	}
+	archived, err := archiver.Events(ctx, Query{Stream: schema.StreamTaskInput, ScopeType: "task", ScopeID: "agent-1"})
+	if err != nil {
+		t.Fatalf("rehydrate task input: %v", err)
+	}
+	if len(archived) != 1 || archived[0].ID != oldMessage.ID || archived[0].Body != "old" {
+		t.Fatalf("expected the old message rehydrated, got %+v", archived)
+	}
+
+	kept, err := bus.List(ctx, schema.StreamHistory, eventbus.ListOptions{ScopeType: "task", ScopeID: "agent-1"})
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	if len(kept) != 1 {
+		t.Fatalf("expected only the latest generation kept, got %+v", kept)
+	}
+	old, err := archiver.Events(ctx, Query{Stream: schema.StreamHistory, ScopeType: "task", ScopeID: "agent-1"})
+	if err != nil {
+		t.Fatalf("rehydrate history: %v", err)
+	}
+	if len(old) != 3 || old[0].Body != "first" || old[2].Body != "third" {
+		t.Fatalf("expected generations 1 and 2 rehydrated in order, got %+v", old)
+	}
+	ranged, err := archiver.Events(ctx, Query{Stream: schema.StreamHistory, ScopeID: "agent-1", From: old[1].CreatedAt, To: old[2].CreatedAt})
+	if err != nil || len(ranged) != 1 || ranged[0].ID != old[1].ID {
+		t.Fatalf("expected one entry in range, got %+v, %v", ranged, err)
+	}
+	entries, err := archiver.Index(ctx, Filter{Kind: KindGeneration, ScopeID: "agent-1"})
+	if err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Generation != 1 || entries[0].Items != 2 || entries[1].Generation != 2 {
+		t.Fatalf("expected one index entry per generation, got %+v", entries)
+	}
+
+	if _, err := mgr.Get(ctx, "job-child"); err == nil {
+		t.Fatalf("expected the archived task removed")
+	}
+	tree, err := archiver.TaskTree(ctx, "job")
+	if err != nil {
+		t.Fatalf("task tree: %v", err)
+	}
+	if len(tree.Tasks) != 2 || tree.Tasks[0].ID != "job" || tree.Tasks[1].ID != "job-child" || len(tree.Updates["job-child"]) == 0 {
+		t.Fatalf("unexpected task tree %+v", tree)
+	}
+	if _, err := archiver.TaskTree(ctx, "agent-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the running agent not archived, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/objectstore"
+)
+
+// Config is the config file's archive section. A retention of zero leaves
+// that kind of data in the database.
+type Config struct {
+	// Driver is "s3", "gcs" or "dir".
+	Driver string `json:"driver"`
+	Bucket string `json:"bucket"`
+	Region string `json:"region"`
+	// Prefix is prepended to object keys, e.g. "go-agents/".
+	Prefix string `json:"prefix"`
+	// Endpoint overrides the S3 endpoint for S3-compatible stores.
+	Endpoint string `json:"endpoint"`
+	// Dir is the directory the dir driver writes to.
+	Dir string `json:"dir"`
+
+	// EventRetentionDays archives events, other than history, older than
+	// this many days.
+	EventRetentionDays int `json:"event_retention_days"`
+	// KeepGenerations archives the history of all but an agent's latest
+	// generations.
+	KeepGenerations int `json:"keep_generations"`
+	// TaskRetentionDays archives top-level tasks whose whole tree finished
+	// more than this many days ago.
+	TaskRetentionDays int `json:"task_retention_days"`
+}
+
+// Store builds the object store described by c. S3 credentials come from
+// the standard AWS environment variables and GCS credentials from the HMAC
+// key in GCS_HMAC_ACCESS_KEY_ID and GCS_HMAC_SECRET.
+func (c Config) Store() (objectstore.Store, error) {
+	switch strings.ToLower(strings.TrimSpace(c.Driver)) {
+	case "s3":
+		if c.Bucket == "" {
+			return nil, fmt.Errorf("s3 archive requires bucket")
+		}
+		region := c.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return nil, fmt.Errorf("s3 archive requires region")
+		}
+		return &objectstore.S3{
+			Bucket:          c.Bucket,
+			Region:          region,
+			Endpoint:        c.Endpoint,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	case "gcs":
+		if c.Bucket == "" {
+			return nil, fmt.Errorf("gcs archive requires bucket")
+		}
+		endpoint := c.Endpoint
+		if endpoint == "" {
+			endpoint = objectstore.GCSEndpoint
+		}
+		return &objectstore.S3{
+			Bucket:          c.Bucket,
+			Region:          "auto",
+			Endpoint:        endpoint,
+			AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+		}, nil
+	case "dir":
+		if strings.TrimSpace(c.Dir) == "" {
+			return nil, fmt.Errorf("dir archive requires dir")
+		}
+		return objectstore.Dir{Root: c.Dir}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive driver: %q", c.Driver)
+	}
+}
@@ -0,0 +1,85 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type stubSummarizer struct {
+	summary string
+	err     error
+	calls   int
+}
+
+func (s *stubSummarizer) Summarize(_ context.Context, _ string) (string, error) {
+	s.calls++
+	return s.summary, s.err
+}
+
+func TestOffloaderStoresOnlyOversizedText(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	summarizer := &stubSummarizer{summary: "A build log ending in 3 failures."}
+	off := &Offloader{Store: NewStore(db), Summarizer: summarizer, Threshold: 100}
+
+	if _, ok, err := off.Offload(ctx, "agent-a", "", "tool:exec", strings.Repeat("x", 100)); ok || err != nil {
+		t.Fatalf("expected text at the threshold to stay inline, got %v %v", ok, err)
+	}
+	if summarizer.calls != 0 {
+		t.Fatalf("expected no summary for inline text")
+	}
+
+	long := strings.Repeat("line\n", 50)
+	a, ok, err := off.Offload(ctx, "agent-a", "llm-1", "tool:exec", long)
+	if err != nil || !ok {
+		t.Fatalf("offload: %v %v", ok, err)
+	}
+	if a.Summary != summarizer.summary || a.Chars != len(long) || !strings.HasPrefix(a.ID, "art-") {
+		t.Fatalf("unexpected artifact %+v", a)
+	}
+	if ref := Reference(a); !strings.Contains(ref, a.ID) || !strings.Contains(ref, summarizer.summary) {
+		t.Fatalf("unexpected reference %q", ref)
+	}
+
+	got, err := off.Store.Get(ctx, a.ID)
+	if err != nil || got.Content != long || got.TaskID != "llm-1" {
+		t.Fatalf("unexpected stored artifact %+v %v", got, err)
+	}
+	list, err := off.Store.List(ctx, "agent-a", 10)
+	if err != nil || len(list) != 1 || list[0].Content != "" {
+		t.Fatalf("expected one listed artifact without content, got %+v %v", list, err)
+	}
+	if _, err := off.Store.Get(ctx, "art-missing"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	off.Threshold = -1
+	if _, ok, _ := off.Offload(ctx, "agent-a", "", "assistant", long); ok {
+		t.Fatalf("expected a negative threshold to disable offloading")
+	}
+}
+
+func TestOffloaderFallsBackToExcerpt(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	off := &Offloader{
+		Store:      NewStore(db),
+		Summarizer: &stubSummarizer{err: errors.New("model unavailable")},
+		Threshold:  10,
+	}
+	text := "BEGIN " + strings.Repeat("middle ", 500) + " END"
+	a, ok, err := off.Offload(context.Background(), "agent-a", "", "assistant", text)
+	if err != nil || !ok {
+		t.Fatalf("offload: %v %v", ok, err)
+	}
+	if !strings.HasPrefix(a.Summary, "BEGIN") || !strings.HasSuffix(a.Summary, "END") || !strings.Contains(a.Summary, "chars omitted") {
+		t.Fatalf("expected a head and tail excerpt, got %q", a.Summary)
+	}
+}
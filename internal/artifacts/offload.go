@@ -0,0 +1,126 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const (
+	// DefaultThreshold is the size in characters above which output is
+	// stored as an artifact.
+	DefaultThreshold = 16000
+	maxSummaryChars  = 1200
+	summaryTimeout   = 30 * time.Second
+	// summaryInputChars caps how much of the content the summarizer reads.
+	summaryInputChars = 60000
+)
+
+const summaryPrompt = `You summarize long outputs produced by an AI agent or its tools. The full text is stored separately and the summary replaces it in the conversation.
+Write at most six short sentences or bullets. Keep the facts a reader needs to decide whether to open the full text: what it is, key results, numbers, identifiers, errors, and file paths. Do not add commentary.`
+
+// Summarizer writes the short summary that stands in for an artifact.
+type Summarizer interface {
+	Summarize(ctx context.Context, text string) (string, error)
+}
+
+// LLMSummarizer asks a model for the summary. NewSession should return a
+// session on a cheap model without tools.
+type LLMSummarizer struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (s LLMSummarizer) Summarize(ctx context.Context, text string) (string, error) {
+	if s.NewSession == nil {
+		return "", fmt.Errorf("summarizer has no llm")
+	}
+	llm, err := s.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("create summary session: %w", err)
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(summaryPrompt) }
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, clip(text, summaryInputChars)) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return "", fmt.Errorf("summarize artifact: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Offloader stores outputs over Threshold characters as artifacts.
+type Offloader struct {
+	Store *Store
+	// Summarizer writes the inline summary; without one, or when it fails,
+	// the start and end of the content stand in for it.
+	Summarizer Summarizer
+	// Threshold defaults to DefaultThreshold; a negative value disables
+	// offloading.
+	Threshold int
+}
+
+func (o *Offloader) threshold() int {
+	if o.Threshold == 0 {
+		return DefaultThreshold
+	}
+	return o.Threshold
+}
+
+// Offload stores text as an artifact when it is over the threshold and
+// reports whether it did.
+func (o *Offloader) Offload(ctx context.Context, agentID, taskID, source, text string) (Artifact, bool, error) {
+	if o == nil || o.Store == nil || o.threshold() < 0 || len(text) <= o.threshold() {
+		return Artifact{}, false, nil
+	}
+	summary := ""
+	if o.Summarizer != nil {
+		sumCtx, cancel := context.WithTimeout(ctx, summaryTimeout)
+		summary, _ = o.Summarizer.Summarize(sumCtx, text)
+		cancel()
+	}
+	if summary = strings.TrimSpace(summary); summary == "" {
+		summary = Excerpt(text, 600)
+	}
+	a, err := o.Store.Put(ctx, Artifact{
+		AgentID: agentID,
+		TaskID:  taskID,
+		Source:  source,
+		Summary: clip(summary, maxSummaryChars),
+		Content: text,
+	})
+	if err != nil {
+		return Artifact{}, false, err
+	}
+	return a, true, nil
+}
+
+// Reference is the inline text that replaces an artifact's content.
+func Reference(a Artifact) string {
+	return fmt.Sprintf("[Stored as artifact %s: %d chars from %s, shown here as a summary. Read it in full with read_artifact or GET /api/artifacts/%s.]\n%s",
+		a.ID, a.Chars, a.Source, a.ID, a.Summary)
+}
+
+// Excerpt keeps about limit characters from the start and end of text.
+func Excerpt(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= limit {
+		return text
+	}
+	head := strings.ToValidUTF8(text[:limit*2/3], "")
+	tail := strings.ToValidUTF8(text[len(text)-limit/3:], "")
+	return fmt.Sprintf("%s\n…(%d chars omitted)…\n%s", head, len(text)-len(head)-len(tail), tail)
+}
+
+func clip(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit], "") + "…"
+}
@@ -0,0 +1,134 @@
+// Package artifacts keeps large text outputs out of prompts and events. An
+// assistant reply or tool result over the size threshold is stored in full
+// as an artifact and replaced inline by a short summary and a reference the
+// agent or an operator can expand.
+package artifacts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+)
+
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact is one stored output. Content is omitted from listings.
+type Artifact struct {
+	ID      string `json:"id"`
+	AgentID string `json:"agent_id"`
+	TaskID  string `json:"task_id,omitempty"`
+	// Source is what produced the content: "assistant" or "tool:<name>".
+	Source    string    `json:"source"`
+	Chars     int       `json:"chars"`
+	Summary   string    `json:"summary"`
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Put stores a new artifact and returns it with its ID set.
+func (s *Store) Put(ctx context.Context, a Artifact) (Artifact, error) {
+	a.AgentID = strings.TrimSpace(a.AgentID)
+	if a.AgentID == "" {
+		return Artifact{}, fmt.Errorf("agent_id is required")
+	}
+	a.ID = "art-" + s.newIDFn()
+	a.Chars = len(a.Content)
+	a.CreatedAt = s.now()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO artifacts (id, agent_id, task_id, source, chars, summary, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.AgentID, strings.TrimSpace(a.TaskID), strings.TrimSpace(a.Source), a.Chars, a.Summary, a.Content,
+		a.CreatedAt.Format(time.RFC3339Nano)); err != nil {
+		return Artifact{}, fmt.Errorf("insert artifact: %w", err)
+	}
+	return a, nil
+}
+
+// Get returns an artifact with its content.
+func (s *Store) Get(ctx context.Context, id string) (Artifact, error) {
+	var a Artifact
+	var createdAt string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, agent_id, task_id, source, chars, summary, content, created_at FROM artifacts WHERE id = ?
+	`, strings.TrimSpace(id)).Scan(&a.ID, &a.AgentID, &a.TaskID, &a.Source, &a.Chars, &a.Summary, &a.Content, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Artifact{}, ErrArtifactNotFound
+	}
+	if err != nil {
+		return Artifact{}, fmt.Errorf("read artifact: %w", err)
+	}
+	a.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	return a, nil
+}
+
+// List returns agentID's artifacts newest first, without content.
+func (s *Store) List(ctx context.Context, agentID string, limit int) ([]Artifact, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, agent_id, task_id, source, chars, summary, created_at FROM artifacts
+		WHERE agent_id = ? ORDER BY created_at DESC, id DESC LIMIT ?
+	`, strings.TrimSpace(agentID), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query artifacts: %w", err)
+	}
+	defer rows.Close()
+	out := []Artifact{}
+	for rows.Next() {
+		var a Artifact
+		var createdAt string
+		if err := rows.Scan(&a.ID, &a.AgentID, &a.TaskID, &a.Source, &a.Chars, &a.Summary, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan artifact: %w", err)
+		}
+		a.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate artifacts: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,174 @@
+// Package budget caps what an agent's turns may consume. A Budget sets
+// daily and monthly limits on tokens and on the cost estimated from
+// per-model prices; Check compares it with the usage recorded so far.
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// Actions: what happens to a turn once its agent's budget is exhausted.
+const (
+	// Pause leaves the message unread; the turn runs once the window that
+	// ran out resets.
+	Pause = "pause"
+	// Fail fails the turn as limit_exceeded and answers the sender with an
+	// error.
+	Fail = "fail"
+)
+
+// ModelPrice is what a model charges, in US dollars per million tokens.
+type ModelPrice struct {
+	Input         float64 `json:"input"`
+	Output        float64 `json:"output"`
+	CachedInput   float64 `json:"cached_input,omitempty"`
+	CacheCreation float64 `json:"cache_creation,omitempty"`
+}
+
+// Cost estimates what usage cost in millionths of a dollar, which at a
+// price per million tokens is tokens times price.
+func (p ModelPrice) Cost(usage llms.Usage) int64 {
+	return int64(math.Round(float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CachedInputTokens)*p.CachedInput +
+		float64(usage.CacheCreationInputTokens)*p.CacheCreation))
+}
+
+// Budget caps what an agent's turns consume per calendar day and month.
+// Tokens count input and output tokens; USD caps the estimated cost. Zero
+// fields take the fallback budget's value (see Or) and negative fields
+// disable that cap. Action is Pause (the default) or Fail.
+type Budget struct {
+	DailyTokens   int64   `json:"daily_tokens,omitempty"`
+	MonthlyTokens int64   `json:"monthly_tokens,omitempty"`
+	DailyUSD      float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd,omitempty"`
+	Action        string  `json:"action,omitempty"`
+}
+
+// IsZero reports whether the budget sets nothing.
+func (b Budget) IsZero() bool {
+	return b == Budget{}
+}
+
+func (b Budget) Validate() error {
+	switch b.Action {
+	case "", Pause, Fail:
+		return nil
+	default:
+		return fmt.Errorf("budget action must be %q or %q", Pause, Fail)
+	}
+}
+
+// Or fills the fields b leaves zero from fallback.
+func (b Budget) Or(fallback Budget) Budget {
+	if b.DailyTokens == 0 {
+		b.DailyTokens = fallback.DailyTokens
+	}
+	if b.MonthlyTokens == 0 {
+		b.MonthlyTokens = fallback.MonthlyTokens
+	}
+	if b.DailyUSD == 0 {
+		b.DailyUSD = fallback.DailyUSD
+	}
+	if b.MonthlyUSD == 0 {
+		b.MonthlyUSD = fallback.MonthlyUSD
+	}
+	if b.Action == "" {
+		b.Action = fallback.Action
+	}
+	return b
+}
+
+// Capped reports whether any cap is set.
+func (b Budget) Capped() bool {
+	return b.DailyTokens > 0 || b.MonthlyTokens > 0 || b.DailyUSD > 0 || b.MonthlyUSD > 0
+}
+
+// Parse reads a budget from decoded JSON. A nil value is the zero budget.
+func Parse(raw any) (Budget, error) {
+	if raw == nil {
+		return Budget{}, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return Budget{}, fmt.Errorf("budget must be an object")
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	var b Budget
+	if err := dec.Decode(&b); err != nil {
+		return Budget{}, fmt.Errorf("budget must be an object: %w", err)
+	}
+	if err := b.Validate(); err != nil {
+		return Budget{}, err
+	}
+	return b, nil
+}
+
+// Exhaustion names the cap a budget reached.
+type Exhaustion struct {
+	// Window is "daily" or "monthly" and Unit "tokens" or "usd".
+	Window   string    `json:"window"`
+	Unit     string    `json:"unit"`
+	Used     float64   `json:"used"`
+	Limit    float64   `json:"limit"`
+	Action   string    `json:"action"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+func (e Exhaustion) String() string {
+	if e.Unit == "usd" {
+		return fmt.Sprintf("%s spending budget: $%.2f of $%.2f used, resets %s", e.Window, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s token budget: %.0f of %.0f tokens used, resets %s", e.Window, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+// Windows returns the starts of the day and month that contain now, in
+// now's location.
+func Windows(now time.Time) (day, month time.Time) {
+	loc := now.Location()
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	return day, month
+}
+
+// Check compares b with the usage of the day and month starting at day and
+// month and returns the cap that is reached, or nil. Monthly caps are
+// checked first: when both are reached they hold the agent longest.
+func Check(b Budget, today, thisMonth tasks.TokenUsage, day, month time.Time) *Exhaustion {
+	action := b.Action
+	if action == "" {
+		action = Pause
+	}
+	caps := []struct {
+		window, unit string
+		used, limit  float64
+		resetsAt     time.Time
+	}{
+		{"monthly", "tokens", float64(thisMonth.InputTokens + thisMonth.OutputTokens), float64(b.MonthlyTokens), month.AddDate(0, 1, 0)},
+		{"monthly", "usd", float64(thisMonth.CostMicroUSD) / 1e6, b.MonthlyUSD, month.AddDate(0, 1, 0)},
+		{"daily", "tokens", float64(today.InputTokens + today.OutputTokens), float64(b.DailyTokens), day.AddDate(0, 0, 1)},
+		{"daily", "usd", float64(today.CostMicroUSD) / 1e6, b.DailyUSD, day.AddDate(0, 0, 1)},
+	}
+	for _, c := range caps {
+		if c.limit > 0 && c.used >= c.limit {
+			return &Exhaustion{
+				Window:   c.window,
+				Unit:     c.unit,
+				Used:     c.used,
+				Limit:    c.limit,
+				Action:   action,
+				ResetsAt: c.resetsAt,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestCheckPrefersMonthlyCaps(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	day, month := Windows(time.Date(2026, time.October, 17, 15, 4, 0, 0, loc))
+	if !day.Equal(time.Date(2026, time.October, 17, 0, 0, 0, 0, loc)) || !month.Equal(time.Date(2026, time.October, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected windows %s %s", day, month)
+	}
+	today := tasks.TokenUsage{InputTokens: 800, OutputTokens: 300, CostMicroUSD: 1_500_000}
+	thisMonth := tasks.TokenUsage{InputTokens: 9000, OutputTokens: 1000, CostMicroUSD: 20_000_000}
+
+	if got := Check(Budget{DailyTokens: 2000, MonthlyUSD: 25}, today, thisMonth, day, month); got != nil {
+		t.Fatalf("expected no cap reached, got %+v", got)
+	}
+	got := Check(Budget{DailyTokens: 1000, MonthlyUSD: 20, Action: Fail}, today, thisMonth, day, month)
+	if got == nil || got.Window != "monthly" || got.Unit != "usd" || got.Used != 20 || got.Action != Fail ||
+		!got.ResetsAt.Equal(time.Date(2026, time.November, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("expected the monthly spending cap, got %+v", got)
+	}
+	got = Check(Budget{DailyTokens: 1000, MonthlyTokens: -1}, today, thisMonth, day, month)
+	if got == nil || got.Window != "daily" || got.Unit != "tokens" || got.Used != 1100 || got.Action != Pause ||
+		!got.ResetsAt.Equal(time.Date(2026, time.October, 18, 0, 0, 0, 0, loc)) {
+		t.Fatalf("expected the daily token cap, got %+v", got)
+	}
+}
+
+func TestBudgetOrAndParse(t *testing.T) {
+	b := Budget{DailyTokens: -1, MonthlyUSD: 5}.Or(Budget{DailyTokens: 1000, DailyUSD: 1, MonthlyUSD: 50, Action: Fail})
+	if b != (Budget{DailyTokens: -1, DailyUSD: 1, MonthlyUSD: 5, Action: Fail}) {
+		t.Fatalf("unexpected merged budget %+v", b)
+	}
+	if b.Capped() != true || (Budget{DailyTokens: -1}).Capped() {
+		t.Fatalf("unexpected Capped")
+	}
+
+	parsed, err := Parse(map[string]any{"daily_tokens": float64(5000), "action": "fail"})
+	if err != nil || parsed != (Budget{DailyTokens: 5000, Action: Fail}) {
+		t.Fatalf("parse: %+v %v", parsed, err)
+	}
+	for _, raw := range []any{"lots", map[string]any{"daily": 1}, map[string]any{"action": "stop"}} {
+		if _, err := Parse(raw); err == nil {
+			t.Fatalf("expected %v to be rejected", raw)
+		}
+	}
+
+	price := ModelPrice{Input: 3, Output: 15, CachedInput: 0.3}
+	if got := price.Cost(llms.Usage{InputTokens: 1000, OutputTokens: 100, CachedInputTokens: 500}); got != 4650 {
+		t.Fatalf("expected 4650 micro-dollars, got %d", got)
+	}
+}
@@ -0,0 +1,305 @@
+package calendar
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// icalUTC is the iCalendar UTC date-time form, e.g. 20260102T150405Z.
+const icalUTC = "20060102T150405Z"
+
+// caldavCalendar talks to one CalDAV calendar collection with basic auth.
+type caldavCalendar struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+	newIDFn  func() string
+	nowFn    func() time.Time
+}
+
+func (c *caldavCalendar) ListEvents(ctx context.Context, from, to time.Time) ([]Event, error) {
+	// The server expands recurring events into the instances in range.
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data><C:expand start="%[1]s" end="%[2]s"/></C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT"><C:time-range start="%[1]s" end="%[2]s"/></C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`, from.UTC().Format(icalUTC), to.UTC().Format(icalUTC))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", c.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var status struct {
+		Responses []struct {
+			Propstats []struct {
+				CalendarData string `xml:"prop>calendar-data"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("decode caldav response: %w", err)
+	}
+	var out []Event
+	for _, r := range status.Responses {
+		for _, ps := range r.Propstats {
+			if strings.TrimSpace(ps.CalendarData) == "" {
+				continue
+			}
+			events, err := parseICalEvents(ps.CalendarData)
+			if err != nil {
+				return nil, err
+			}
+			for _, event := range events {
+				if event.Start.Before(to) && event.End.After(from) {
+					out = append(out, event)
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+func (c *caldavCalendar) CreateEvent(ctx context.Context, event Event) (Event, error) {
+	event.ID = c.newIDFn()
+	target := strings.TrimRight(c.url, "/") + "/" + event.ID + ".ics"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, strings.NewReader(formatICalEvent(event, c.nowFn())))
+	if err != nil {
+		return Event{}, err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	req.Header.Set("If-None-Match", "*")
+	resp, err := c.send(req)
+	if err != nil {
+		return Event{}, err
+	}
+	resp.Body.Close()
+	if event.AllDay {
+		event.Start = truncateDay(event.Start)
+		event.End = truncateDay(event.End)
+	}
+	return event, nil
+}
+
+func (c *caldavCalendar) send(req *http.Request) (*http.Response, error) {
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("caldav %s: %s: %s", req.Method, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+// parseICalEvents reads the VEVENTs of an iCalendar object. Expanded
+// recurrence instances get IDs of the form "<uid>/<recurrence-id>".
+func parseICalEvents(data string) ([]Event, error) {
+	var out []Event
+	var current *Event
+	var duration time.Duration
+	var recurrence string
+	// nested counts open components inside the event, such as VALARM,
+	// whose properties are not the event's.
+	nested := 0
+	for _, line := range unfoldICal(data) {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &Event{}
+			duration, recurrence, nested = 0, "", 0
+		case current == nil:
+		case name == "BEGIN":
+			nested++
+		case name == "END" && nested > 0:
+			nested--
+		case nested > 0:
+		case name == "END" && value == "VEVENT":
+			if recurrence != "" {
+				current.ID += "/" + recurrence
+			}
+			if current.End.IsZero() {
+				switch {
+				case duration > 0:
+					current.End = current.Start.Add(duration)
+				case current.AllDay:
+					current.End = current.Start.AddDate(0, 0, 1)
+				default:
+					current.End = current.Start
+				}
+			}
+			out = append(out, *current)
+			current = nil
+		case name == "UID":
+			current.ID = value
+		case name == "SUMMARY":
+			current.Title = unescapeICalText(value)
+		case name == "DESCRIPTION":
+			current.Description = unescapeICalText(value)
+		case name == "LOCATION":
+			current.Location = unescapeICalText(value)
+		case name == "URL":
+			current.URL = value
+		case name == "RECURRENCE-ID":
+			recurrence = value
+		case name == "DTSTART":
+			at, allDay, err := parseICalTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("event %s DTSTART: %w", current.ID, err)
+			}
+			current.Start, current.AllDay = at, allDay
+		case name == "DTEND":
+			at, _, err := parseICalTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("event %s DTEND: %w", current.ID, err)
+			}
+			current.End = at
+		case name == "DURATION":
+			duration = parseICalDuration(value)
+		}
+	}
+	return out, nil
+}
+
+func unfoldICal(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICalLine splits "NAME;PARAM=x:value" into its parts.
+func splitICalLine(line string) (string, map[string]string, string) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, ""
+	}
+	parts := strings.Split(head, ";")
+	params := map[string]string{}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+func parseICalTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		at, err := time.Parse("20060102", value)
+		return at, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		at, err := time.Parse(icalUTC, value)
+		return at, false, err
+	}
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	at, err := time.ParseInLocation("20060102T150405", value, loc)
+	return at.UTC(), false, err
+}
+
+// parseICalDuration reads the day, hour, minute and second parts of an
+// iCalendar duration such as PT1H30M or P1D.
+func parseICalDuration(value string) time.Duration {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var total time.Duration
+	n := 0
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+		case r == 'W':
+			total += time.Duration(n) * 7 * 24 * time.Hour
+			n = 0
+		case r == 'D':
+			total += time.Duration(n) * 24 * time.Hour
+			n = 0
+		case r == 'H':
+			total += time.Duration(n) * time.Hour
+			n = 0
+		case r == 'M':
+			total += time.Duration(n) * time.Minute
+			n = 0
+		case r == 'S':
+			total += time.Duration(n) * time.Second
+			n = 0
+		}
+	}
+	return total
+}
+
+var icalTextUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+func unescapeICalText(value string) string {
+	return icalTextUnescaper.Replace(value)
+}
+
+func formatICalEvent(event Event, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//go-agents//calendar//EN",
+		"BEGIN:VEVENT",
+		"UID:" + event.ID,
+		"DTSTAMP:" + now.UTC().Format(icalUTC),
+	}
+	if event.AllDay {
+		lines = append(lines,
+			"DTSTART;VALUE=DATE:"+event.Start.UTC().Format("20060102"),
+			"DTEND;VALUE=DATE:"+event.End.UTC().Format("20060102"))
+	} else {
+		lines = append(lines,
+			"DTSTART:"+event.Start.UTC().Format(icalUTC),
+			"DTEND:"+event.End.UTC().Format(icalUTC))
+	}
+	lines = append(lines, "SUMMARY:"+icalTextEscaper.Replace(event.Title))
+	if event.Location != "" {
+		lines = append(lines, "LOCATION:"+icalTextEscaper.Replace(event.Location))
+	}
+	if event.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+icalTextEscaper.Replace(event.Description))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,637 @@
+// Package calendar connects agents to Google Calendar or CalDAV calendars.
+// Each agent has at most one account, whose credentials live in the secrets
+// store. The service lists and creates events for calendar tools, keeps
+// reminders, and wakes the owning agent ahead of upcoming events.
+package calendar
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/secrets"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	ProviderGoogle = "google"
+	ProviderCalDAV = "caldav"
+
+	DefaultPollInterval = time.Minute
+	DefaultLeadMinutes  = 10
+	maxLeadMinutes      = 24 * 60
+	dueBatch            = 50
+	// secretName is the secrets store entry holding an agent's credentials.
+	secretName = "calendar"
+)
+
+const (
+	ReminderPending = "pending"
+	ReminderSent    = "sent"
+)
+
+var ErrNotConfigured = errors.New("calendar not configured")
+
+// Event is one calendar event. All-day events start and end at midnight UTC
+// of their dates.
+type Event struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Description string    `json:"description,omitempty"`
+	URL         string    `json:"url,omitempty"`
+}
+
+// Provider is a calendar backend.
+type Provider interface {
+	// ListEvents returns events overlapping [from, to), ordered by start,
+	// with recurring events expanded into instances.
+	ListEvents(ctx context.Context, from, to time.Time) ([]Event, error)
+	CreateEvent(ctx context.Context, event Event) (Event, error)
+}
+
+// Account is an agent's calendar configuration. Credentials are kept apart
+// in the secrets store and never returned.
+type Account struct {
+	AgentID  string `json:"agent_id"`
+	Provider string `json:"provider"`
+	// CalendarID is the Google calendar to use; it defaults to "primary".
+	CalendarID string `json:"calendar_id,omitempty"`
+	// URL is the CalDAV calendar collection.
+	URL string `json:"url,omitempty"`
+	// LeadMinutes is how long before an event the agent is woken.
+	LeadMinutes int       `json:"lead_minutes"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Credentials authenticate an account. Google accounts use an OAuth client
+// and refresh token (or a bare access token); CalDAV accounts use basic
+// auth.
+type Credentials struct {
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+// Reminder wakes AgentID with Text at RemindAt.
+type Reminder struct {
+	ID       string    `json:"id"`
+	AgentID  string    `json:"agent_id"`
+	Text     string    `json:"text"`
+	EventID  string    `json:"event_id,omitempty"`
+	RemindAt time.Time `json:"remind_at"`
+	Status   string    `json:"status"`
+	// CreatedAt is when the reminder was set.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeliverFunc hands a wake to an agent.
+type DeliverFunc func(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error)
+
+type Service struct {
+	db      *sql.DB
+	bus     *eventbus.Bus
+	secrets *secrets.Store
+
+	client      *http.Client
+	deliverFn   DeliverFunc
+	newProvider func(acct Account, creds Credentials) (Provider, error)
+	google      googleEndpoints
+	tokens      *tokenCache
+	nowFn       func() time.Time
+	newIDFn     func() string
+}
+
+type Option func(*Service)
+
+// WithDeliverer replaces how wakes reach agents; the runtime uses it to
+// start the agent's loop. By default they are pushed to the agent's
+// task_input.
+func WithDeliverer(fn DeliverFunc) Option {
+	return func(s *Service) {
+		if fn != nil {
+			s.deliverFn = fn
+		}
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Service) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewService(db *sql.DB, bus *eventbus.Bus, store *secrets.Store, opts ...Option) *Service {
+	s := &Service{
+		db:      db,
+		bus:     bus,
+		secrets: store,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		google:  defaultGoogleEndpoints,
+		tokens:  &tokenCache{tokens: map[string]cachedToken{}},
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	s.deliverFn = s.pushWake
+	s.newProvider = s.provider
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Service) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Configure sets agentID's calendar account and stores its credentials,
+// replacing any previous account.
+func (s *Service) Configure(ctx context.Context, acct Account, creds Credentials) (Account, error) {
+	if s.secrets == nil {
+		return Account{}, fmt.Errorf("secrets store unavailable")
+	}
+	acct.AgentID = strings.TrimSpace(acct.AgentID)
+	if acct.AgentID == "" {
+		return Account{}, fmt.Errorf("agent_id is required")
+	}
+	acct.Provider = strings.ToLower(strings.TrimSpace(acct.Provider))
+	acct.CalendarID = strings.TrimSpace(acct.CalendarID)
+	acct.URL = strings.TrimSpace(acct.URL)
+	switch acct.Provider {
+	case ProviderGoogle:
+		if acct.CalendarID == "" {
+			acct.CalendarID = "primary"
+		}
+		hasRefresh := creds.RefreshToken != "" && creds.ClientID != "" && creds.ClientSecret != ""
+		if !hasRefresh && creds.AccessToken == "" {
+			return Account{}, fmt.Errorf("google calendar needs client_id, client_secret and refresh_token, or an access_token")
+		}
+		acct.URL = ""
+	case ProviderCalDAV:
+		u, err := url.Parse(acct.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Account{}, fmt.Errorf("caldav calendar needs an http(s) url")
+		}
+		acct.CalendarID = ""
+	default:
+		return Account{}, fmt.Errorf("unknown calendar provider %q (want google or caldav)", acct.Provider)
+	}
+	if acct.LeadMinutes == 0 {
+		acct.LeadMinutes = DefaultLeadMinutes
+	}
+	if acct.LeadMinutes < 0 || acct.LeadMinutes > maxLeadMinutes {
+		return Account{}, fmt.Errorf("lead_minutes must be between 1 and %d", maxLeadMinutes)
+	}
+
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return Account{}, fmt.Errorf("encode credentials: %w", err)
+	}
+	if err := s.secrets.Put(ctx, acct.AgentID, secretName, data); err != nil {
+		return Account{}, err
+	}
+	s.tokens.drop(acct.AgentID)
+	now := s.now()
+	acct.CreatedAt, acct.UpdatedAt = now, now
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_accounts (agent_id, provider, calendar_id, url, lead_minutes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id) DO UPDATE SET provider = excluded.provider, calendar_id = excluded.calendar_id,
+			url = excluded.url, lead_minutes = excluded.lead_minutes, updated_at = excluded.updated_at
+	`, acct.AgentID, acct.Provider, acct.CalendarID, acct.URL, acct.LeadMinutes,
+		now.Format(state.TimeLayout), now.Format(state.TimeLayout)); err != nil {
+		return Account{}, fmt.Errorf("store calendar account: %w", err)
+	}
+	return s.Account(ctx, acct.AgentID)
+}
+
+// Account returns agentID's calendar account, or ErrNotConfigured.
+func (s *Service) Account(ctx context.Context, agentID string) (Account, error) {
+	list, err := s.accounts(ctx, `WHERE agent_id = ?`, strings.TrimSpace(agentID))
+	if err != nil {
+		return Account{}, err
+	}
+	if len(list) == 0 {
+		return Account{}, ErrNotConfigured
+	}
+	return list[0], nil
+}
+
+// Remove deletes agentID's account and credentials and reports whether an
+// account existed. Reminders are kept; they do not need a calendar.
+func (s *Service) Remove(ctx context.Context, agentID string) (bool, error) {
+	agentID = strings.TrimSpace(agentID)
+	res, err := s.db.ExecContext(ctx, `DELETE FROM calendar_accounts WHERE agent_id = ?`, agentID)
+	if err != nil {
+		return false, fmt.Errorf("delete calendar account: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete calendar account rows affected: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM calendar_notified WHERE agent_id = ?`, agentID); err != nil {
+		return false, fmt.Errorf("delete calendar notifications: %w", err)
+	}
+	if s.secrets != nil {
+		if _, err := s.secrets.Delete(ctx, agentID, secretName); err != nil {
+			return false, err
+		}
+	}
+	s.tokens.drop(agentID)
+	return n > 0, nil
+}
+
+// ListEvents returns agentID's events overlapping [from, to).
+func (s *Service) ListEvents(ctx context.Context, agentID string, from, to time.Time) ([]Event, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("end of range must be after its start")
+	}
+	provider, err := s.agentProvider(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListEvents(ctx, from.UTC(), to.UTC())
+}
+
+// CreateEvent adds event to agentID's calendar.
+func (s *Service) CreateEvent(ctx context.Context, agentID string, event Event) (Event, error) {
+	event.Title = strings.TrimSpace(event.Title)
+	if event.Title == "" {
+		return Event{}, fmt.Errorf("title is required")
+	}
+	if event.Start.IsZero() {
+		return Event{}, fmt.Errorf("start is required")
+	}
+	if event.End.IsZero() {
+		if event.AllDay {
+			event.End = event.Start.AddDate(0, 0, 1)
+		} else {
+			event.End = event.Start.Add(time.Hour)
+		}
+	}
+	if !event.End.After(event.Start) {
+		return Event{}, fmt.Errorf("end must be after start")
+	}
+	provider, err := s.agentProvider(ctx, agentID)
+	if err != nil {
+		return Event{}, err
+	}
+	return provider.CreateEvent(ctx, event)
+}
+
+// SetReminder schedules a wake for the reminder's agent at RemindAt.
+// Reminders work without a calendar account.
+func (s *Service) SetReminder(ctx context.Context, reminder Reminder) (Reminder, error) {
+	reminder.AgentID = strings.TrimSpace(reminder.AgentID)
+	if reminder.AgentID == "" {
+		return Reminder{}, fmt.Errorf("agent_id is required")
+	}
+	reminder.Text = strings.TrimSpace(reminder.Text)
+	if reminder.Text == "" {
+		return Reminder{}, fmt.Errorf("text is required")
+	}
+	now := s.now()
+	if reminder.RemindAt.IsZero() || !reminder.RemindAt.After(now) {
+		return Reminder{}, fmt.Errorf("remind_at must be in the future")
+	}
+	reminder.ID = "rem-" + s.newIDFn()
+	reminder.EventID = strings.TrimSpace(reminder.EventID)
+	reminder.RemindAt = reminder.RemindAt.UTC()
+	reminder.Status = ReminderPending
+	reminder.CreatedAt = now
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_reminders (id, agent_id, text, event_id, remind_at, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, reminder.ID, reminder.AgentID, reminder.Text, reminder.EventID, reminder.RemindAt.Format(state.TimeLayout),
+		reminder.Status, now.Format(state.TimeLayout)); err != nil {
+		return Reminder{}, fmt.Errorf("insert reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// Reminders returns agentID's reminders in firing order, optionally with one
+// status.
+func (s *Service) Reminders(ctx context.Context, agentID, status string, limit int) ([]Reminder, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `WHERE agent_id = ?`
+	args := []any{strings.TrimSpace(agentID)}
+	if status = strings.TrimSpace(status); status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	return s.reminders(ctx, query+` ORDER BY remind_at, id LIMIT ?`, args...)
+}
+
+// CancelReminder deletes one of agentID's pending reminders and reports
+// whether it existed.
+func (s *Service) CancelReminder(ctx context.Context, agentID, id string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM calendar_reminders WHERE id = ? AND agent_id = ? AND status = ?`,
+		strings.TrimSpace(id), strings.TrimSpace(agentID), ReminderPending)
+	if err != nil {
+		return false, fmt.Errorf("delete reminder: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete reminder rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Poll fires due reminders and wakes agents whose events start within their
+// account's lead time. Each event instance wakes its agent once. All-day
+// events do not wake agents. It returns how many wakes were sent.
+func (s *Service) Poll(ctx context.Context) (int, error) {
+	now := s.now()
+	sent, err := s.fireReminders(ctx, now)
+	if err != nil {
+		return sent, err
+	}
+	accounts, err := s.accounts(ctx, ``)
+	if err != nil {
+		return sent, err
+	}
+	var errs []error
+	for _, acct := range accounts {
+		n, err := s.wakeForEvents(ctx, acct, now)
+		sent += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("calendar %s: %w", acct.AgentID, err))
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM calendar_notified WHERE starts_at < ?`,
+		now.Add(-48*time.Hour).Format(state.TimeLayout)); err != nil {
+		errs = append(errs, fmt.Errorf("prune calendar notifications: %w", err))
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (s *Service) fireReminders(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.reminders(ctx, `WHERE status = ? AND remind_at <= ? ORDER BY remind_at, id LIMIT ?`,
+		ReminderPending, now.Format(state.TimeLayout), dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, reminder := range due {
+		meta := map[string]any{
+			"reason":      "reminder",
+			"reminder_id": reminder.ID,
+		}
+		if reminder.EventID != "" {
+			meta["calendar_event_id"] = reminder.EventID
+		}
+		if err := s.wake(ctx, reminder.AgentID, "Reminder: "+reminder.Text, meta); err != nil {
+			return sent, err
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE calendar_reminders SET status = ? WHERE id = ?`,
+			ReminderSent, reminder.ID); err != nil {
+			return sent, fmt.Errorf("mark reminder sent: %w", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *Service) wakeForEvents(ctx context.Context, acct Account, now time.Time) (int, error) {
+	provider, err := s.agentProvider(ctx, acct.AgentID)
+	if err != nil {
+		return 0, err
+	}
+	events, err := provider.ListEvents(ctx, now, now.Add(time.Duration(acct.LeadMinutes)*time.Minute))
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, event := range events {
+		if event.AllDay || event.Start.Before(now) {
+			continue
+		}
+		res, err := s.db.ExecContext(ctx, `
+			INSERT INTO calendar_notified (agent_id, event_id, starts_at, notified_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(agent_id, event_id, starts_at) DO NOTHING
+		`, acct.AgentID, event.ID, event.Start.UTC().Format(state.TimeLayout), now.Format(state.TimeLayout))
+		if err != nil {
+			return sent, fmt.Errorf("record calendar notification: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		meta := map[string]any{
+			"reason":            "calendar_event",
+			"calendar_event_id": event.ID,
+			"starts_at":         event.Start.UTC().Format(time.RFC3339),
+		}
+		if err := s.wake(ctx, acct.AgentID, upcomingText(event, now), meta); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func upcomingText(event Event, now time.Time) string {
+	minutes := int(event.Start.Sub(now).Round(time.Minute) / time.Minute)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Upcoming event in %d min: %s (%s to %s UTC)", minutes, event.Title,
+		event.Start.UTC().Format("15:04"), event.End.UTC().Format("15:04"))
+	if event.Location != "" {
+		fmt.Fprintf(&b, " at %s", event.Location)
+	}
+	if event.URL != "" {
+		fmt.Fprintf(&b, "\n%s", event.URL)
+	}
+	return b.String()
+}
+
+func (s *Service) wake(ctx context.Context, agentID, body string, meta map[string]any) error {
+	meta["kind"] = "wake"
+	meta["priority"] = "wake"
+	if _, err := s.deliverFn(ctx, agentID, body, "calendar", meta); err != nil {
+		return fmt.Errorf("wake %s: %w", agentID, err)
+	}
+	return nil
+}
+
+func (s *Service) pushWake(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error) {
+	if s.bus == nil {
+		return eventbus.Event{}, fmt.Errorf("event bus unavailable")
+	}
+	metadata := map[string]any{"source": source, "target": target}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	return s.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   target,
+		Subject:   fmt.Sprintf("wake: %s", schema.GetMetaString(meta, "reason")),
+		Body:      body,
+		Metadata:  metadata,
+	})
+}
+
+func (s *Service) agentProvider(ctx context.Context, agentID string) (Provider, error) {
+	acct, err := s.Account(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if s.secrets == nil {
+		return nil, fmt.Errorf("secrets store unavailable")
+	}
+	data, err := s.secrets.Get(ctx, acct.AgentID, secretName)
+	if err != nil {
+		return nil, fmt.Errorf("calendar credentials: %w", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("decode calendar credentials: %w", err)
+	}
+	return s.newProvider(acct, creds)
+}
+
+func (s *Service) provider(acct Account, creds Credentials) (Provider, error) {
+	switch acct.Provider {
+	case ProviderGoogle:
+		return &googleCalendar{
+			client:     s.client,
+			endpoints:  s.google,
+			calendarID: acct.CalendarID,
+			creds:      creds,
+			tokens:     s.tokens,
+			cacheKey:   acct.AgentID,
+			nowFn:      s.now,
+		}, nil
+	case ProviderCalDAV:
+		return &caldavCalendar{
+			client:   s.client,
+			url:      acct.URL,
+			username: creds.Username,
+			password: creds.Password,
+			newIDFn:  s.newIDFn,
+			nowFn:    s.now,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown calendar provider %q", acct.Provider)
+	}
+}
+
+const accountColumns = `agent_id, provider, calendar_id, url, lead_minutes, created_at, updated_at`
+
+func (s *Service) accounts(ctx context.Context, where string, args ...any) ([]Account, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accountColumns+` FROM calendar_accounts `+where+` ORDER BY agent_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query calendar accounts: %w", err)
+	}
+	defer rows.Close()
+	out := []Account{}
+	for rows.Next() {
+		var acct Account
+		var createdAt, updatedAt string
+		if err := rows.Scan(&acct.AgentID, &acct.Provider, &acct.CalendarID, &acct.URL, &acct.LeadMinutes,
+			&createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan calendar account: %w", err)
+		}
+		acct.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		acct.UpdatedAt, _ = time.Parse(state.TimeLayout, updatedAt)
+		out = append(out, acct)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate calendar accounts: %w", err)
+	}
+	return out, nil
+}
+
+const reminderColumns = `id, agent_id, text, event_id, remind_at, status, created_at`
+
+func (s *Service) reminders(ctx context.Context, where string, args ...any) ([]Reminder, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+reminderColumns+` FROM calendar_reminders `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query reminders: %w", err)
+	}
+	defer rows.Close()
+	out := []Reminder{}
+	for rows.Next() {
+		var r Reminder
+		var remindAt, createdAt string
+		if err := rows.Scan(&r.ID, &r.AgentID, &r.Text, &r.EventID, &remindAt, &r.Status, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan reminder: %w", err)
+		}
+		r.RemindAt, _ = time.Parse(state.TimeLayout, remindAt)
+		r.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reminders: %w", err)
+	}
+	return out, nil
+}
+
+// tokenCache keeps Google access tokens per agent until shortly before they
+// expire.
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+func (c *tokenCache) get(key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[key]
+	if !ok || !now.Before(t.expires) {
+		return "", false
+	}
+	return t.token, true
+}
+
+func (c *tokenCache) put(key, token string, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = cachedToken{token: token, expires: expires}
+}
+
+func (c *tokenCache) drop(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+}
@@ -0,0 +1,183 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/secrets"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type fakeProvider struct {
+	events []Event
+}
+
+func (f *fakeProvider) ListEvents(_ context.Context, from, to time.Time) ([]Event, error) {
+	var out []Event
+	for _, e := range f.events {
+		if e.Start.Before(to) && e.End.After(from) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeProvider) CreateEvent(_ context.Context, event Event) (Event, error) {
+	event.ID = "created"
+	f.events = append(f.events, event)
+	return event, nil
+}
+
+func TestPollWakesOnceBeforeEventsAndFiresReminders(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	store, err := secrets.NewStore(db, bytes.Repeat([]byte{1}, secrets.KeySize))
+	if err != nil {
+		t.Fatalf("secrets: %v", err)
+	}
+	type wake struct {
+		target, body string
+		meta         map[string]any
+	}
+	var wakes []wake
+	svc := NewService(db, eventbus.NewBus(db), store,
+		WithClock(func() time.Time { return now }),
+		WithDeliverer(func(_ context.Context, target, body, _ string, meta map[string]any) (eventbus.Event, error) {
+			wakes = append(wakes, wake{target, body, meta})
+			return eventbus.Event{}, nil
+		}))
+	provider := &fakeProvider{events: []Event{
+		{ID: "standup", Title: "Standup", Start: now.Add(8 * time.Minute), End: now.Add(23 * time.Minute)},
+		{ID: "holiday", Title: "Holiday", Start: now.Truncate(24 * time.Hour), End: now.Truncate(24*time.Hour).AddDate(0, 0, 1), AllDay: true},
+		{ID: "review", Title: "Review", Start: now.Add(2 * time.Hour), End: now.Add(3 * time.Hour)},
+	}}
+	var gotCreds Credentials
+	svc.newProvider = func(_ Account, creds Credentials) (Provider, error) {
+		gotCreds = creds
+		return provider, nil
+	}
+
+	if _, err := svc.Configure(ctx, Account{AgentID: "agent-a", Provider: "google"}, Credentials{}); err == nil {
+		t.Fatalf("expected google without credentials to fail")
+	}
+	acct, err := svc.Configure(ctx, Account{AgentID: "agent-a", Provider: "google"},
+		Credentials{ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh"})
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if acct.CalendarID != "primary" || acct.LeadMinutes != DefaultLeadMinutes {
+		t.Fatalf("unexpected account defaults %+v", acct)
+	}
+	if _, err := svc.SetReminder(ctx, Reminder{AgentID: "agent-a", Text: "call Sam", RemindAt: now.Add(30 * time.Second)}); err != nil {
+		t.Fatalf("set reminder: %v", err)
+	}
+
+	if sent, err := svc.Poll(ctx); err != nil || sent != 1 {
+		t.Fatalf("expected the standup wake only, got %d %v", sent, err)
+	}
+	if gotCreds.RefreshToken != "refresh" {
+		t.Fatalf("expected credentials from the secrets store, got %+v", gotCreds)
+	}
+	if wakes[0].target != "agent-a" || !strings.Contains(wakes[0].body, "in 8 min: Standup") ||
+		wakes[0].meta["kind"] != "wake" || wakes[0].meta["calendar_event_id"] != "standup" {
+		t.Fatalf("unexpected event wake %+v", wakes[0])
+	}
+	if sent, _ := svc.Poll(ctx); sent != 0 {
+		t.Fatalf("expected no repeated wake, got %d", sent)
+	}
+
+	now = now.Add(time.Minute)
+	if sent, err := svc.Poll(ctx); err != nil || sent != 1 {
+		t.Fatalf("expected the reminder to fire, got %d %v", sent, err)
+	}
+	if wakes[1].body != "Reminder: call Sam" || wakes[1].meta["reason"] != "reminder" {
+		t.Fatalf("unexpected reminder wake %+v", wakes[1])
+	}
+	if pending, _ := svc.Reminders(ctx, "agent-a", ReminderPending, 10); len(pending) != 0 {
+		t.Fatalf("expected no pending reminders, got %+v", pending)
+	}
+
+	if removed, err := svc.Remove(ctx, "agent-a"); err != nil || !removed {
+		t.Fatalf("remove: %v %v", removed, err)
+	}
+	if _, err := svc.ListEvents(ctx, "agent-a", now, now.Add(time.Hour)); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected removed calendar to be unconfigured, got %v", err)
+	}
+}
+
+func TestCalDAVListsExpandedEvents(t *testing.T) {
+	const multistatus = `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response><d:href>/cal/a.ics</d:href><d:propstat><d:prop><cal:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:weekly-1
+RECURRENCE-ID:20260302T100000Z
+DTSTART;TZID=Europe/Stockholm:20260302T110000
+DURATION:PT30M
+SUMMARY:Planning\, weekly
+DESCRIPTION:Bring the
+  roadmap
+BEGIN:VALARM
+DESCRIPTION:alarm text
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data></d:prop></d:propstat></d:response>
+</d:multistatus>`
+	var method, depth, user string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, depth = r.Method, r.Header.Get("Depth")
+		user, _, _ = r.BasicAuth()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = io.WriteString(w, multistatus)
+	}))
+	defer srv.Close()
+
+	cal := &caldavCalendar{client: srv.Client(), url: srv.URL + "/cal/", username: "me", password: "pw",
+		newIDFn: func() string { return "new-1" }, nowFn: time.Now}
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	events, err := cal.ListEvents(context.Background(), from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if method != "REPORT" || depth != "1" || user != "me" || !strings.Contains(body, `<C:expand start="20260302T000000Z"`) {
+		t.Fatalf("unexpected request %s depth=%s user=%s body=%s", method, depth, user, body)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %+v", events)
+	}
+	e := events[0]
+	if e.ID != "weekly-1/20260302T100000Z" || e.Title != "Planning, weekly" || e.Description != "Bring the roadmap" {
+		t.Fatalf("unexpected event fields %+v", e)
+	}
+	if !e.Start.Equal(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)) || e.End.Sub(e.Start) != 30*time.Minute {
+		t.Fatalf("unexpected event times %s - %s", e.Start, e.End)
+	}
+
+	created, err := cal.CreateEvent(context.Background(), Event{Title: "Lunch; team", Start: from.Add(12 * time.Hour), End: from.Add(13 * time.Hour)})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID != "new-1" || method != http.MethodPut || !strings.Contains(body, `SUMMARY:Lunch\; team`) ||
+		!strings.Contains(body, "DTSTART:20260302T120000Z") {
+		t.Fatalf("unexpected create %+v body=%s", created, body)
+	}
+}
@@ -0,0 +1,226 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type googleEndpoints struct {
+	apiBase  string
+	tokenURL string
+}
+
+var defaultGoogleEndpoints = googleEndpoints{
+	apiBase:  "https://www.googleapis.com/calendar/v3",
+	tokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// googleCalendar talks to the Google Calendar v3 API, refreshing OAuth
+// access tokens from the account's refresh token.
+type googleCalendar struct {
+	client     *http.Client
+	endpoints  googleEndpoints
+	calendarID string
+	creds      Credentials
+	tokens     *tokenCache
+	cacheKey   string
+	nowFn      func() time.Time
+}
+
+type googleTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type googleEvent struct {
+	ID          string     `json:"id,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	HTMLLink    string     `json:"htmlLink,omitempty"`
+	Start       googleTime `json:"start"`
+	End         googleTime `json:"end"`
+}
+
+func (g *googleCalendar) ListEvents(ctx context.Context, from, to time.Time) ([]Event, error) {
+	query := url.Values{}
+	query.Set("timeMin", from.Format(time.RFC3339))
+	query.Set("timeMax", to.Format(time.RFC3339))
+	query.Set("singleEvents", "true")
+	query.Set("orderBy", "startTime")
+	query.Set("maxResults", "250")
+	var out []Event
+	for page := ""; ; {
+		if page != "" {
+			query.Set("pageToken", page)
+		}
+		var resp struct {
+			Items         []googleEvent `json:"items"`
+			NextPageToken string        `json:"nextPageToken"`
+		}
+		if err := g.do(ctx, http.MethodGet, g.eventsURL()+"?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			if item.Status == "cancelled" {
+				continue
+			}
+			event, err := item.event()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, event)
+		}
+		if page = resp.NextPageToken; page == "" {
+			break
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+func (g *googleCalendar) CreateEvent(ctx context.Context, event Event) (Event, error) {
+	body := googleEvent{
+		Summary:     event.Title,
+		Description: event.Description,
+		Location:    event.Location,
+	}
+	if event.AllDay {
+		body.Start.Date = event.Start.UTC().Format(time.DateOnly)
+		body.End.Date = event.End.UTC().Format(time.DateOnly)
+	} else {
+		body.Start.DateTime = event.Start.Format(time.RFC3339)
+		body.End.DateTime = event.End.Format(time.RFC3339)
+	}
+	var created googleEvent
+	if err := g.do(ctx, http.MethodPost, g.eventsURL(), body, &created); err != nil {
+		return Event{}, err
+	}
+	return created.event()
+}
+
+func (g *googleCalendar) eventsURL() string {
+	return g.endpoints.apiBase + "/calendars/" + url.PathEscape(g.calendarID) + "/events"
+}
+
+func (e googleEvent) event() (Event, error) {
+	start, allDay, err := e.Start.parse()
+	if err != nil {
+		return Event{}, fmt.Errorf("event %s start: %w", e.ID, err)
+	}
+	end, _, err := e.End.parse()
+	if err != nil {
+		return Event{}, fmt.Errorf("event %s end: %w", e.ID, err)
+	}
+	return Event{
+		ID:          e.ID,
+		Title:       e.Summary,
+		Start:       start,
+		End:         end,
+		AllDay:      allDay,
+		Location:    e.Location,
+		Description: e.Description,
+		URL:         e.HTMLLink,
+	}, nil
+}
+
+func (t googleTime) parse() (time.Time, bool, error) {
+	if t.DateTime != "" {
+		at, err := time.Parse(time.RFC3339, t.DateTime)
+		return at.UTC(), false, err
+	}
+	at, err := time.Parse(time.DateOnly, t.Date)
+	return at, true, err
+}
+
+func (g *googleCalendar) do(ctx context.Context, method, target string, body, dest any) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode google request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("google calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		g.tokens.drop(g.cacheKey)
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("google calendar: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("decode google response: %w", err)
+	}
+	return nil
+}
+
+// accessToken returns a cached access token or exchanges the refresh token
+// for a new one. Accounts configured with only an access token use it as is.
+func (g *googleCalendar) accessToken(ctx context.Context) (string, error) {
+	if g.creds.RefreshToken == "" {
+		return g.creds.AccessToken, nil
+	}
+	now := g.nowFn()
+	if token, ok := g.tokens.get(g.cacheKey, now); ok {
+		return token, nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", g.creds.RefreshToken)
+	form.Set("client_id", g.creds.ClientID)
+	form.Set("client_secret", g.creds.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoints.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("refresh google token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("refresh google token: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decode google token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("refresh google token: no access_token in response")
+	}
+	// Refresh a minute early so requests never carry an expiring token.
+	g.tokens.put(g.cacheKey, token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second-time.Minute))
+	return token.AccessToken, nil
+}
@@ -0,0 +1,200 @@
+// Package callbacks delivers task completion callbacks. When a task with a
+// callback reaches a terminal status, the dispatcher POSTs the task as JSON,
+// signed with the callback's secret, and retries with backoff until the
+// receiver answers 2xx or attempts run out.
+//
+// Receivers verify X-Go-Agents-Signature, which is "sha256=" followed by the
+// hex HMAC-SHA256 of "<X-Go-Agents-Timestamp>.<body>".
+package callbacks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+const (
+	DefaultMaxAttempts  = 8
+	DefaultPollInterval = time.Second
+	DefaultTimeout      = 10 * time.Second
+	initialBackoff      = 10 * time.Second
+	maxBackoff          = time.Hour
+)
+
+// Payload is the JSON body of a callback.
+type Payload struct {
+	Event       string     `json:"event"`
+	Task        tasks.Task `json:"task"`
+	Attempt     int        `json:"attempt"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+}
+
+type Dispatcher struct {
+	tasks  *tasks.Manager
+	client *http.Client
+
+	maxAttempts  int
+	pollInterval time.Duration
+	nowFn        func() time.Time
+}
+
+type Option func(*Dispatcher)
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		if client != nil {
+			d.client = client
+		}
+	}
+}
+
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(d *Dispatcher) {
+		if nowFn != nil {
+			d.nowFn = nowFn
+		}
+	}
+}
+
+func NewDispatcher(manager *tasks.Manager, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		tasks:        manager,
+		client:       &http.Client{Timeout: DefaultTimeout},
+		maxAttempts:  DefaultMaxAttempts,
+		pollInterval: DefaultPollInterval,
+		nowFn:        func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d)
+		}
+	}
+	return d
+}
+
+func (d *Dispatcher) now() time.Time {
+	return d.nowFn().UTC()
+}
+
+// Run delivers due callbacks until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		_, _ = d.DeliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts every due callback once and returns how many were
+// delivered.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	due, err := d.tasks.DueCallbacks(ctx, 20)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, cb := range due {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		deliveryErr := d.deliver(ctx, cb)
+		var retryAt time.Time
+		if deliveryErr != nil && cb.Attempts+1 < d.maxAttempts {
+			retryAt = d.now().Add(backoff(cb.Attempts))
+		}
+		if err := d.tasks.RecordCallbackAttempt(ctx, cb.TaskID, deliveryErr, retryAt); err != nil {
+			return delivered, err
+		}
+		if deliveryErr == nil {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, cb tasks.CallbackDelivery) error {
+	task, err := d.tasks.Get(ctx, cb.TaskID)
+	if err != nil {
+		return fmt.Errorf("load task: %w", err)
+	}
+	sentAt := d.now()
+	body, err := json.Marshal(Payload{
+		Event:       "task." + string(task.Status),
+		Task:        task,
+		Attempt:     cb.Attempts + 1,
+		DeliveredAt: sentAt,
+	})
+	if err != nil {
+		return fmt.Errorf("encode callback: %w", err)
+	}
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Go-Agents-Task-ID", task.ID)
+	req.Header.Set("X-Go-Agents-Timestamp", timestamp)
+	req.Header.Set("X-Go-Agents-Signature", Sign(cb.Secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post callback: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post callback: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the X-Go-Agents-Signature value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func backoff(attempts int) time.Duration {
+	wait := initialBackoff
+	for range attempts {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,114 @@
+package callbacks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestDispatcherDeliversSignedCallbackAfterRetry(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	mgr := tasks.NewManager(db, eventbus.NewBus(db), tasks.WithClock(clock))
+
+	var mu sync.Mutex
+	var received []Payload
+	fail := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Go-Agents-Signature") != Sign("s3cret", r.Header.Get("X-Go-Agents-Timestamp"), body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		var p Payload
+		_ = json.Unmarshal(body, &p)
+		received = append(received, p)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Callback: &tasks.Callback{URL: "ftp://nope", Secret: "x"}}); err == nil {
+		t.Fatalf("expected invalid callback url to be rejected")
+	}
+	task, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Callback: &tasks.Callback{URL: receiver.URL, Secret: "s3cret"}})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	d := NewDispatcher(mgr, WithClock(clock))
+	if n, err := d.DeliverDue(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing due before completion, got %d (%v)", n, err)
+	}
+	if err := mgr.Complete(ctx, task.ID, map[string]any{"ok": true}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if n, _ := d.DeliverDue(ctx); n != 0 {
+		t.Fatalf("expected first attempt to fail")
+	}
+	if status, attempts, lastErr, _ := mgr.CallbackStatus(ctx, task.ID); status != tasks.CallbackPending || attempts != 1 || lastErr == "" {
+		t.Fatalf("expected pending retry, got %s after %d attempts (%s)", status, attempts, lastErr)
+	}
+	if n, _ := d.DeliverDue(ctx); n != 0 {
+		t.Fatalf("expected retry to wait for backoff")
+	}
+
+	now = now.Add(initialBackoff)
+	if n, err := d.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("expected retry to deliver, got %d (%v)", n, err)
+	}
+	if status, attempts, _, _ := mgr.CallbackStatus(ctx, task.ID); status != tasks.CallbackDelivered || attempts != 2 {
+		t.Fatalf("expected delivered after 2 attempts, got %s/%d", status, attempts)
+	}
+	if len(received) != 1 || received[0].Event != "task.completed" || received[0].Task.ID != task.ID || received[0].Attempt != 2 {
+		t.Fatalf("unexpected callback payload: %+v", received)
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	mgr := tasks.NewManager(db, eventbus.NewBus(db), tasks.WithClock(clock))
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	task, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Callback: &tasks.Callback{URL: receiver.URL, Secret: "s"}})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := mgr.Fail(ctx, task.ID, "boom"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	d := NewDispatcher(mgr, WithClock(clock), WithMaxAttempts(2))
+	for range 3 {
+		_, _ = d.DeliverDue(ctx)
+		now = now.Add(maxBackoff)
+	}
+	if status, attempts, _, _ := mgr.CallbackStatus(ctx, task.ID); status != tasks.CallbackFailed || attempts != 2 {
+		t.Fatalf("expected failed after 2 attempts, got %s/%d", status, attempts)
+	}
+}
@@ -6,6 +6,24 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/access"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/egress"
+	"github.com/flitsinc/go-agents/internal/eventsink"
+	"github.com/flitsinc/go-agents/internal/federation"
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/mcp"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/notify"
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/reports"
+	"github.com/flitsinc/go-agents/internal/tracing"
 )
 
 type Config struct {
@@ -13,20 +31,140 @@ type Config struct {
 	DataDir     string
 	DBPath      string
 	LLMDebugDir string
+	// DBDriver is "sqlite" (the default) or "postgres". DBDSN is the
+	// Postgres connection string; for SQLite it defaults to DBPath. The
+	// GO_AGENTS_DB_DSN environment variable overrides it.
+	DBDriver string
+	DBDSN    string
 
 	LLMProvider  string
 	LLMModel     string
 	LLMAPIKey    string
 	RestartToken string
+	// LLMAPIKeys holds every configured provider's key, for turns that
+	// override the provider.
+	LLMAPIKeys map[string]string
+	// ModelOverrides lists the "provider/model" pairs a single run may
+	// request instead of the agent's own; "provider/*" allows any model of
+	// that provider. Empty disables overrides.
+	ModelOverrides []string
+
+	// ClassifyUrgency enables the urgency classifier for inbound messages.
+	ClassifyUrgency bool
+	// InboundPreprocessors rewrite every inbound external message before
+	// the agent's own preprocessors do.
+	InboundPreprocessors []preprocess.Step
+	// AgentProvisioning creates agents on demand when a message arrives for
+	// an unknown ID matching one of its rules.
+	AgentProvisioning []provision.Rule
+	// InterruptCancelTools lists tools whose spawned tasks are cancelled when
+	// the turn that spawned them is interrupted. Other tools' tasks are
+	// adopted by the agent as background work.
+	InterruptCancelTools []string
+	// ProviderTools enables provider-native tools (web_search,
+	// code_interpreter, image_generation) for every agent by default.
+	ProviderTools []string
+	// ToolSummaryBudgets sets, per tool name, how many characters of each
+	// tool result item are kept in task updates and history.
+	ToolSummaryBudgets map[string]int
+	// ContextBudgets caps, per model name, the estimated tokens of an
+	// agent's context before it is compacted; "*" covers other models.
+	ContextBudgets map[string]int
+	// ModelPrices prices tokens per model name, in US dollars per million
+	// tokens; "*" covers other models. Budget caps every agent's daily and
+	// monthly tokens and estimated cost.
+	ModelPrices map[string]budget.ModelPrice
+	Budget      budget.Budget
+	// EventSinks mirror selected streams to external systems.
+	EventSinks []eventsink.Config
+	// Archive moves cold events, old generations and finished task trees
+	// to object storage.
+	Archive *archive.Config
+	// APIKeys enables authentication and per-agent access control. Without
+	// keys the API is open.
+	APIKeys []access.Key
+	// ActivityReports enables periodic per-agent activity reports.
+	ActivityReports *reports.Config
+	// Monitors overrides background monitor intervals or disables them,
+	// keyed by monitor name.
+	Monitors map[string]monitors.Config
+	// Notifications enables push notifications for operator alerts.
+	Notifications *notify.Config
+	// Federation connects this instance to peer agentd instances.
+	Federation *federation.Config
+	// InterruptAlertAfter is how long an interrupt may go unread before an
+	// alert is raised; zero uses the runtime default.
+	InterruptAlertAfter time.Duration
+	// CheckpointEvery is how many tool rounds a turn runs between
+	// checkpoints; zero uses the runtime default and a negative value
+	// disables them.
+	CheckpointEvery int
+	// ArtifactThreshold is the size in characters above which replies and
+	// tool results are stored as artifacts; zero uses the default and a
+	// negative value disables it.
+	ArtifactThreshold int
+	// LegacyAPISunset is announced as the removal date of the unversioned
+	// /api paths; zero announces none.
+	LegacyAPISunset time.Time
+	// StorageMode is "single" (the default) for one database file, or
+	// "sharded" to keep each agent's task-scoped events in a file of its
+	// own under ShardDir.
+	StorageMode string
+	ShardDir    string
+	// SlowQueryThreshold and WriteHoldThreshold are how long a query or a
+	// held write lock may take before a warning is logged; zero uses the
+	// storage defaults.
+	SlowQueryThreshold time.Duration
+	WriteHoldThreshold time.Duration
+	// Egress sets the proxy, destination allow/deny lists and TLS roots
+	// for every outbound HTTP request.
+	Egress *egress.Config
+	// MCPServers are external MCP servers whose tools agents can call.
+	MCPServers []mcp.ServerConfig
+	// Tracing exports OpenTelemetry spans of turns, tool calls, tasks and
+	// events over OTLP.
+	Tracing *tracing.Config
+	// ProviderLimits caps concurrent provider requests and their rate,
+	// globally and per provider; nil leaves them unlimited.
+	ProviderLimits *governor.Config
+	// FineTune adds redaction rules to fine-tuning exports.
+	FineTune *finetune.Config
+	// MemoryPromptLimit is how many of an agent's memories relevant to a
+	// message are added to its system prompt; zero adds none.
+	MemoryPromptLimit int
+	// Embeddings picks the provider and model that embed memories and
+	// messages for semantic search; nil turns semantic search off.
+	Embeddings *ai.EmbeddingConfig
 }
 
+// Database drivers.
+const (
+	DBDriverSQLite   = "sqlite"
+	DBDriverPostgres = "postgres"
+)
+
+// Storage modes.
+const (
+	StorageSingle  = "single"
+	StorageSharded = "sharded"
+)
+
 func Load() Config {
 	loadDotEnv(".env")
 	cfg := defaultConfig()
 	fileCfg, _ := loadFileConfig([]string{"config.json", filepath.Join(cfg.DataDir, "config.json")})
 	cfg = mergeConfig(cfg, fileCfg)
+	if dsn := strings.TrimSpace(os.Getenv("GO_AGENTS_DB_DSN")); dsn != "" {
+		cfg.DBDSN = dsn
+	}
 	cfg = applyDefaults(cfg)
 	cfg.LLMAPIKey = strings.TrimSpace(providerAPIKey(cfg.LLMProvider))
+	cfg.LLMAPIKeys = map[string]string{}
+	for _, provider := range []string{"anthropic", "openai-responses", "openai-chat", "google"} {
+		if key := strings.TrimSpace(providerAPIKey(provider)); key != "" {
+			cfg.LLMAPIKeys[provider] = key
+		}
+	}
 	return cfg
 }
 
@@ -34,10 +172,45 @@ type fileConfig struct {
 	HTTPAddr     string `json:"http_addr"`
 	DataDir      string `json:"data_dir"`
 	DBPath       string `json:"db_path"`
+	DBDriver     string `json:"db_driver"`
+	DBDSN        string `json:"db_dsn"`
 	LLMDebugDir  string `json:"llm_debug_dir"`
 	LLMProvider  string `json:"llm_provider"`
 	LLMModel     string `json:"llm_model"`
 	RestartToken string `json:"restart_token"`
+
+	ClassifyUrgency      bool                         `json:"classify_urgency"`
+	InboundPreprocessors []preprocess.Step            `json:"inbound_preprocessors"`
+	AgentProvisioning    []provision.Rule             `json:"agent_provisioning"`
+	InterruptCancelTools []string                     `json:"interrupt_cancel_tools"`
+	ProviderTools        []string                     `json:"provider_tools"`
+	ToolSummaryBudgets   map[string]int               `json:"tool_summary_budgets"`
+	ContextBudgets       map[string]int               `json:"context_budgets"`
+	ModelPrices          map[string]budget.ModelPrice `json:"model_prices"`
+	Budget               *budget.Budget               `json:"budget"`
+	ModelOverrides       []string                     `json:"model_overrides"`
+	EventSinks           []eventsink.Config           `json:"event_sinks"`
+	Archive              *archive.Config              `json:"archive"`
+	APIKeys              []access.Key                 `json:"api_keys"`
+	ActivityReports      *reports.Config              `json:"activity_reports"`
+	Monitors             map[string]monitors.Config   `json:"monitors"`
+	Notifications        *notify.Config               `json:"notifications"`
+	Federation           *federation.Config           `json:"federation"`
+	InterruptAlertAfterS int                          `json:"interrupt_alert_after_seconds"`
+	CheckpointEvery      int                          `json:"checkpoint_every_tool_rounds"`
+	ArtifactThreshold    int                          `json:"artifact_threshold_chars"`
+	LegacyAPISunset      string                       `json:"legacy_api_sunset"`
+	StorageMode          string                       `json:"storage_mode"`
+	ShardDir             string                       `json:"shard_dir"`
+	SlowQueryMs          int                          `json:"slow_query_ms"`
+	WriteHoldWarnMs      int                          `json:"write_hold_warn_ms"`
+	Egress               *egress.Config               `json:"egress"`
+	MCPServers           []mcp.ServerConfig           `json:"mcp_servers"`
+	Tracing              *tracing.Config              `json:"tracing"`
+	ProviderLimits       *governor.Config             `json:"provider_limits"`
+	FineTune             *finetune.Config             `json:"finetune"`
+	MemoryPromptLimit    int                          `json:"memory_prompt_limit"`
+	Embeddings           *ai.EmbeddingConfig          `json:"embeddings"`
 }
 
 func defaultConfig() Config {
@@ -56,9 +229,21 @@ func applyDefaults(cfg Config) Config {
 	if cfg.DBPath == "" {
 		cfg.DBPath = filepath.Join(cfg.DataDir, "go-agents.db")
 	}
+	if cfg.DBDriver == "" {
+		cfg.DBDriver = DBDriverSQLite
+	}
+	if cfg.DBDSN == "" && cfg.DBDriver == DBDriverSQLite {
+		cfg.DBDSN = cfg.DBPath
+	}
 	if cfg.LLMDebugDir == "" {
 		cfg.LLMDebugDir = filepath.Join(cfg.DataDir, "llm-debug")
 	}
+	if cfg.StorageMode == "" {
+		cfg.StorageMode = StorageSingle
+	}
+	if cfg.ShardDir == "" {
+		cfg.ShardDir = filepath.Join(cfg.DataDir, "shards")
+	}
 	return cfg
 }
 
@@ -72,6 +257,12 @@ func mergeConfig(base Config, fileCfg fileConfig) Config {
 	if fileCfg.DBPath != "" {
 		base.DBPath = fileCfg.DBPath
 	}
+	if driver := strings.ToLower(strings.TrimSpace(fileCfg.DBDriver)); driver != "" {
+		base.DBDriver = driver
+	}
+	if fileCfg.DBDSN != "" {
+		base.DBDSN = fileCfg.DBDSN
+	}
 	if fileCfg.LLMDebugDir != "" {
 		base.LLMDebugDir = fileCfg.LLMDebugDir
 	}
@@ -84,6 +275,102 @@ func mergeConfig(base Config, fileCfg fileConfig) Config {
 	if fileCfg.RestartToken != "" {
 		base.RestartToken = fileCfg.RestartToken
 	}
+	if fileCfg.ClassifyUrgency {
+		base.ClassifyUrgency = true
+	}
+	if len(fileCfg.InboundPreprocessors) > 0 {
+		base.InboundPreprocessors = fileCfg.InboundPreprocessors
+	}
+	if len(fileCfg.AgentProvisioning) > 0 {
+		base.AgentProvisioning = fileCfg.AgentProvisioning
+	}
+	if len(fileCfg.InterruptCancelTools) > 0 {
+		base.InterruptCancelTools = fileCfg.InterruptCancelTools
+	}
+	if len(fileCfg.ProviderTools) > 0 {
+		base.ProviderTools = fileCfg.ProviderTools
+	}
+	if len(fileCfg.ToolSummaryBudgets) > 0 {
+		base.ToolSummaryBudgets = fileCfg.ToolSummaryBudgets
+	}
+	if len(fileCfg.ContextBudgets) > 0 {
+		base.ContextBudgets = fileCfg.ContextBudgets
+	}
+	if len(fileCfg.ModelPrices) > 0 {
+		base.ModelPrices = fileCfg.ModelPrices
+	}
+	if fileCfg.Budget != nil {
+		base.Budget = *fileCfg.Budget
+	}
+	if len(fileCfg.ModelOverrides) > 0 {
+		base.ModelOverrides = fileCfg.ModelOverrides
+	}
+	if len(fileCfg.EventSinks) > 0 {
+		base.EventSinks = fileCfg.EventSinks
+	}
+	if fileCfg.Archive != nil {
+		base.Archive = fileCfg.Archive
+	}
+	if len(fileCfg.APIKeys) > 0 {
+		base.APIKeys = fileCfg.APIKeys
+	}
+	if fileCfg.ActivityReports != nil {
+		base.ActivityReports = fileCfg.ActivityReports
+	}
+	if len(fileCfg.Monitors) > 0 {
+		base.Monitors = fileCfg.Monitors
+	}
+	if fileCfg.Notifications != nil {
+		base.Notifications = fileCfg.Notifications
+	}
+	if fileCfg.Federation != nil {
+		base.Federation = fileCfg.Federation
+	}
+	if fileCfg.InterruptAlertAfterS > 0 {
+		base.InterruptAlertAfter = time.Duration(fileCfg.InterruptAlertAfterS) * time.Second
+	}
+	if fileCfg.CheckpointEvery != 0 {
+		base.CheckpointEvery = fileCfg.CheckpointEvery
+	}
+	if fileCfg.ArtifactThreshold != 0 {
+		base.ArtifactThreshold = fileCfg.ArtifactThreshold
+	}
+	if t, err := time.Parse(time.DateOnly, strings.TrimSpace(fileCfg.LegacyAPISunset)); err == nil {
+		base.LegacyAPISunset = t
+	}
+	if mode := strings.TrimSpace(fileCfg.StorageMode); mode != "" {
+		base.StorageMode = mode
+	}
+	if fileCfg.ShardDir != "" {
+		base.ShardDir = fileCfg.ShardDir
+	}
+	if fileCfg.SlowQueryMs > 0 {
+		base.SlowQueryThreshold = time.Duration(fileCfg.SlowQueryMs) * time.Millisecond
+	}
+	if fileCfg.WriteHoldWarnMs > 0 {
+		base.WriteHoldThreshold = time.Duration(fileCfg.WriteHoldWarnMs) * time.Millisecond
+	}
+	if fileCfg.Egress != nil {
+		base.Egress = fileCfg.Egress
+	}
+	if len(fileCfg.MCPServers) > 0 {
+		base.MCPServers = fileCfg.MCPServers
+	}
+	if fileCfg.Tracing != nil {
+		base.Tracing = fileCfg.Tracing
+	}
+	if fileCfg.ProviderLimits != nil {
+		base.ProviderLimits = fileCfg.ProviderLimits
+	}
+	if fileCfg.FineTune != nil {
+		base.FineTune = fileCfg.FineTune
+	}
+	if fileCfg.MemoryPromptLimit > 0 {
+		base.MemoryPromptLimit = fileCfg.MemoryPromptLimit
+	}
+	if fileCfg.Embeddings != nil {
+		base.Embeddings = fileCfg.Embeddings
+	}
 	return base
 }
 
@@ -0,0 +1,180 @@
+package contacts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func bob() Contact {
+	return Contact{
+		ID:       "bob",
+		Name:     "Bob Smith",
+		Aliases:  []string{"Bobby"},
+		Timezone: "Europe/Stockholm",
+		Channels: []Channel{
+			{Name: "telegram", ServiceID: "telegram-bot", Handle: "12345"},
+			{Name: "Pager", WebhookURL: "https://example.com/hook"},
+		},
+		Preferences: Preferences{Channel: "telegram", QuietHours: "22:00-07:00"},
+	}
+}
+
+func TestStorePutSearchResolveDelete(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	store := NewStore(db)
+
+	saved, err := store.Put(ctx, bob())
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if saved.Channels[1].Name != "pager" || saved.CreatedAt.IsZero() {
+		t.Fatalf("unexpected saved contact %+v", saved)
+	}
+	if _, err := store.Put(ctx, Contact{ID: "bobcat", Name: "Roberta", Aliases: []string{"bobby"}}); err != nil {
+		t.Fatalf("put second: %v", err)
+	}
+
+	found, err := store.Search(ctx, "bob")
+	if err != nil || len(found) != 2 || found[0].ID != "bob" {
+		t.Fatalf("expected the exact ID match first, got %+v %v", found, err)
+	}
+	if found, _ := store.Search(ctx, "1234"); len(found) != 1 || found[0].ID != "bob" {
+		t.Fatalf("expected a handle match, got %+v", found)
+	}
+	if c, err := store.Resolve(ctx, "bob smith"); err != nil || c.ID != "bob" {
+		t.Fatalf("expected to resolve by name, got %+v %v", c, err)
+	}
+	if _, err := store.Resolve(ctx, "Bobby"); err == nil || !strings.Contains(err.Error(), "several contacts (bob, bobcat)") {
+		t.Fatalf("expected an ambiguous alias, got %v", err)
+	}
+	if _, err := store.Resolve(ctx, "alice"); !errors.Is(err, ErrContactNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "bobcat"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "bobcat"); !errors.Is(err, ErrContactNotFound) {
+		t.Fatalf("expected the contact gone, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		edit func(*Contact)
+		want string
+	}{
+		{func(c *Contact) { c.ID = "Bob" }, "contact id"},
+		{func(c *Contact) { c.Timezone = "Mars/Olympus" }, "unknown timezone"},
+		{func(c *Contact) { c.Channels[1].Name = "telegram" }, "listed twice"},
+		{func(c *Contact) { c.Channels[0].Handle = "" }, "needs a handle or context"},
+		{func(c *Contact) { c.Channels[1].WebhookURL = "" }, "needs a service_id or webhook_url"},
+		{func(c *Contact) { c.Preferences.Channel = "email" }, "preferred channel"},
+		{func(c *Contact) { c.Preferences.QuietHours = "25:00-07:00" }, "quiet_hours"},
+	} {
+		c := bob()
+		tc.edit(&c)
+		if _, err := store.Put(ctx, c); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	c := bob()
+	stockholm, _ := time.LoadLocation("Europe/Stockholm")
+	for _, tc := range []struct {
+		local string
+		quiet bool
+		until string
+	}{
+		{"2026-03-10 21:59", false, ""},
+		{"2026-03-10 23:30", true, "2026-03-11 07:00"},
+		{"2026-03-11 06:59", true, "2026-03-11 07:00"},
+		{"2026-03-11 07:00", false, ""},
+	} {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", tc.local, stockholm)
+		until, quiet := c.QuietUntil(now.UTC())
+		if quiet != tc.quiet || (quiet && until.Format("2006-01-02 15:04") != tc.until) {
+			t.Fatalf("at %s: expected quiet=%t until %s, got %t %s", tc.local, tc.quiet, tc.until, quiet, until)
+		}
+	}
+}
+
+type recordingEmitter struct {
+	taskID   string
+	text     string
+	metadata map[string]any
+}
+
+func (e *recordingEmitter) EmitAssistantOutput(_ context.Context, taskID, text string, metadata map[string]any) error {
+	e.taskID, e.text, e.metadata = taskID, text, metadata
+	return nil
+}
+
+func TestDelivererRoutesToServiceAndWebhook(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	store := NewStore(db)
+
+	var got WebhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	c := bob()
+	c.Channels[0].Context = map[string]any{"thread": "ops"}
+	c.Channels[1].WebhookURL = hook.URL
+	if _, err := store.Put(ctx, c); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	noon := time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC)
+	emitter := &recordingEmitter{}
+	deliverer := NewDeliverer(store, emitter, WithHTTPClient(hook.Client()), WithDeliveryClock(func() time.Time { return noon }))
+
+	delivery, err := deliverer.Send(ctx, Message{AgentID: "agent-1", Contact: "Bobby", Text: " Deploy is done. "})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if delivery.Via != ViaService || delivery.ServiceID != "telegram-bot" || delivery.Channel != "telegram" {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+	routeContext := emitter.metadata["context"].(map[string]any)
+	if emitter.taskID != "agent-1" || emitter.text != "Deploy is done." || emitter.metadata["service_id"] != "telegram-bot" ||
+		routeContext["handle"] != "12345" || routeContext["contact_id"] != "bob" || routeContext["thread"] != "ops" {
+		t.Fatalf("unexpected service output %+v", emitter)
+	}
+
+	delivery, err = deliverer.Send(ctx, Message{AgentID: "agent-1", Contact: "bob", Channel: "pager", Text: "Disk full"})
+	if err != nil {
+		t.Fatalf("send webhook: %v", err)
+	}
+	if delivery.Via != ViaWebhook || delivery.Status != http.StatusNoContent || got.Text != "Disk full" || got.ContactID != "bob" || got.AgentID != "agent-1" {
+		t.Fatalf("unexpected webhook delivery %+v %+v", delivery, got)
+	}
+
+	if _, err := deliverer.Send(ctx, Message{AgentID: "agent-1", Contact: "bob", Channel: "email", Text: "hi"}); !errors.Is(err, ErrChannelNotFound) {
+		t.Fatalf("expected an unknown channel, got %v", err)
+	}
+
+	night := time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC)
+	deliverer = NewDeliverer(store, emitter, WithDeliveryClock(func() time.Time { return night }))
+	var quiet *QuietHoursError
+	if _, err := deliverer.Send(ctx, Message{AgentID: "agent-1", Contact: "bob", Text: "fyi"}); !errors.As(err, &quiet) || quiet.Until.Hour() != 7 {
+		t.Fatalf("expected quiet hours until 07:00, got %v", err)
+	}
+	if _, err := deliverer.Send(ctx, Message{AgentID: "agent-1", Contact: "bob", Text: "site down", Urgent: true}); err != nil {
+		t.Fatalf("expected urgent messages through quiet hours, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package contacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Delivery methods.
+const (
+	ViaService = "service"
+	ViaWebhook = "webhook"
+)
+
+// OutputEmitter sends an agent's message along a service route.
+// engine.Runtime implements it.
+type OutputEmitter interface {
+	EmitAssistantOutput(ctx context.Context, taskID, text string, metadata map[string]any) error
+}
+
+// Message is what an agent asks to send to a contact.
+type Message struct {
+	AgentID string
+	// Contact is the contact's ID, name or alias.
+	Contact string
+	// Channel picks one of the contact's channels; empty uses the
+	// preferred one.
+	Channel string
+	Text    string
+	// Urgent sends the message even during the contact's quiet hours.
+	Urgent bool
+}
+
+// Delivery reports where a message went.
+type Delivery struct {
+	ContactID string `json:"contact_id"`
+	Name      string `json:"name"`
+	Channel   string `json:"channel"`
+	Via       string `json:"via"`
+	ServiceID string `json:"service_id,omitempty"`
+	Status    int    `json:"status,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook channel.
+type WebhookPayload struct {
+	ContactID string    `json:"contact_id"`
+	Name      string    `json:"name"`
+	Channel   string    `json:"channel"`
+	Handle    string    `json:"handle,omitempty"`
+	AgentID   string    `json:"agent_id"`
+	Text      string    `json:"text"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// QuietHoursError is returned for a message that is not urgent while the
+// contact has quiet hours.
+type QuietHoursError struct {
+	Name  string
+	Until time.Time
+}
+
+func (e *QuietHoursError) Error() string {
+	return fmt.Sprintf("%s is in quiet hours until %s their time; send later or mark the message urgent", e.Name, e.Until.Format("15:04 MST"))
+}
+
+// Deliverer resolves contacts to channels and delivers messages on them.
+type Deliverer struct {
+	store  *Store
+	output OutputEmitter
+	client *http.Client
+	nowFn  func() time.Time
+}
+
+type DelivererOption func(*Deliverer)
+
+func WithHTTPClient(client *http.Client) DelivererOption {
+	return func(d *Deliverer) {
+		if client != nil {
+			d.client = client
+		}
+	}
+}
+
+func WithDeliveryClock(nowFn func() time.Time) DelivererOption {
+	return func(d *Deliverer) {
+		if nowFn != nil {
+			d.nowFn = nowFn
+		}
+	}
+}
+
+func NewDeliverer(store *Store, output OutputEmitter, opts ...DelivererOption) *Deliverer {
+	d := &Deliverer{
+		store:  store,
+		output: output,
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+		nowFn:  func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d)
+		}
+	}
+	return d
+}
+
+// Store returns the contact book the deliverer resolves against.
+func (d *Deliverer) Store() *Store {
+	return d.store
+}
+
+// Send resolves msg's contact and channel and delivers the text. A service
+// channel becomes an assistant_output of the sending agent with the
+// channel's service_id and a context carrying the contact ID and handle;
+// a webhook channel is POSTed a WebhookPayload.
+func (d *Deliverer) Send(ctx context.Context, msg Message) (Delivery, error) {
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		return Delivery{}, fmt.Errorf("text is required")
+	}
+	contact, err := d.store.Resolve(ctx, msg.Contact)
+	if err != nil {
+		return Delivery{}, err
+	}
+	ch, err := contact.Channel(msg.Channel)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !msg.Urgent {
+		if until, quiet := contact.QuietUntil(d.nowFn()); quiet {
+			return Delivery{}, &QuietHoursError{Name: contact.Name, Until: until}
+		}
+	}
+	delivery := Delivery{ContactID: contact.ID, Name: contact.Name, Channel: ch.Name}
+	if ch.ServiceID != "" {
+		if d.output == nil {
+			return Delivery{}, fmt.Errorf("service delivery unavailable")
+		}
+		routeContext := map[string]any{}
+		for k, v := range ch.Context {
+			routeContext[k] = v
+		}
+		routeContext["contact_id"] = contact.ID
+		if ch.Handle != "" {
+			routeContext["handle"] = ch.Handle
+		}
+		if err := d.output.EmitAssistantOutput(ctx, msg.AgentID, text, map[string]any{
+			"source":     "contact",
+			"service_id": ch.ServiceID,
+			"context":    routeContext,
+		}); err != nil {
+			return Delivery{}, fmt.Errorf("deliver to %s via %s: %w", contact.ID, ch.ServiceID, err)
+		}
+		delivery.Via, delivery.ServiceID = ViaService, ch.ServiceID
+		return delivery, nil
+	}
+	status, err := d.postWebhook(ctx, ch.WebhookURL, WebhookPayload{
+		ContactID: contact.ID,
+		Name:      contact.Name,
+		Channel:   ch.Name,
+		Handle:    ch.Handle,
+		AgentID:   msg.AgentID,
+		Text:      text,
+		SentAt:    d.nowFn().UTC(),
+	})
+	if err != nil {
+		return Delivery{}, fmt.Errorf("deliver to %s via webhook: %w", contact.ID, err)
+	}
+	delivery.Via, delivery.Status = ViaWebhook, status
+	return delivery, nil
+}
+
+func (d *Deliverer) postWebhook(ctx context.Context, url string, payload WebhookPayload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
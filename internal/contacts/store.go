@@ -0,0 +1,418 @@
+// Package contacts keeps a book of the humans agents may reach outside a
+// conversation: their names, channel handles, timezone and delivery
+// preferences. Agents look contacts up with lookup_contact and write to them
+// with message_contact; a Deliverer resolves the contact's channel to a
+// service route or webhook, so prompts never carry addresses.
+package contacts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+	ErrChannelNotFound = errors.New("channel not found")
+)
+
+var (
+	idPattern          = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+	channelNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+	quietHoursPattern  = regexp.MustCompile(`^(\d{2}):(\d{2})-(\d{2}):(\d{2})$`)
+)
+
+// Contact is one human agents can address.
+type Contact struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Aliases     []string    `json:"aliases,omitempty"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Channels    []Channel   `json:"channels"`
+	Preferences Preferences `json:"preferences"`
+	Notes       string      `json:"notes,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Channel is one way to reach a contact: through a service, which receives
+// an assistant_output carrying ServiceID and a context with the handle, or
+// by POSTing to WebhookURL.
+type Channel struct {
+	Name       string         `json:"name"`
+	ServiceID  string         `json:"service_id,omitempty"`
+	Handle     string         `json:"handle,omitempty"`
+	Context    map[string]any `json:"context,omitempty"`
+	WebhookURL string         `json:"webhook_url,omitempty"`
+}
+
+// Preferences shape delivery. Channel names the channel used when the
+// sender does not pick one; QuietHours ("22:00-07:00", in the contact's
+// timezone) holds back messages not marked urgent.
+type Preferences struct {
+	Channel    string `json:"channel,omitempty"`
+	QuietHours string `json:"quiet_hours,omitempty"`
+}
+
+// contactData is the JSON stored in the data column.
+type contactData struct {
+	Aliases     []string    `json:"aliases,omitempty"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Channels    []Channel   `json:"channels"`
+	Preferences Preferences `json:"preferences"`
+	Notes       string      `json:"notes,omitempty"`
+}
+
+type Store struct {
+	db    *sql.DB
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{db: db, nowFn: func() time.Time { return time.Now().UTC() }}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Put creates or replaces the contact c.ID after validating it. A replaced
+// contact keeps its creation time.
+func (s *Store) Put(ctx context.Context, c Contact) (Contact, error) {
+	if err := c.normalize(); err != nil {
+		return Contact{}, err
+	}
+	data, err := json.Marshal(contactData{Aliases: c.Aliases, Timezone: c.Timezone, Channels: c.Channels, Preferences: c.Preferences, Notes: c.Notes})
+	if err != nil {
+		return Contact{}, fmt.Errorf("encode contact: %w", err)
+	}
+	now := s.now().Format(time.RFC3339Nano)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO contacts (id, name, data, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, data = excluded.data, updated_at = excluded.updated_at
+	`, c.ID, c.Name, string(data), now, now); err != nil {
+		return Contact{}, fmt.Errorf("save contact: %w", err)
+	}
+	return s.Get(ctx, c.ID)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (Contact, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, name, data, created_at, updated_at FROM contacts WHERE id = ?
+	`, strings.TrimSpace(id))
+	c, err := scanContact(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Contact{}, ErrContactNotFound
+	}
+	return c, err
+}
+
+// List returns every contact by name.
+func (s *Store) List(ctx context.Context) ([]Contact, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, data, created_at, updated_at FROM contacts ORDER BY LOWER(name), id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query contacts: %w", err)
+	}
+	defer rows.Close()
+	out := []Contact{}
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contacts: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM contacts WHERE id = ?`, strings.TrimSpace(id))
+	if err != nil {
+		return fmt.Errorf("delete contact: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// Search returns the contacts whose ID, name, aliases, handles or notes
+// contain query, ignoring case; exact ID, name and alias matches come
+// first. An empty query returns every contact.
+func (s *Store) Search(ctx context.Context, query string) ([]Contact, error) {
+	all, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return all, nil
+	}
+	var exact, partial []Contact
+	for _, c := range all {
+		switch {
+		case c.matchesExactly(query):
+			exact = append(exact, c)
+		case c.contains(query):
+			partial = append(partial, c)
+		}
+	}
+	return append(exact, partial...), nil
+}
+
+// Resolve finds the one contact ref names by ID, name or alias, ignoring
+// case. It fails when none or several match.
+func (s *Store) Resolve(ctx context.Context, ref string) (Contact, error) {
+	ref = strings.TrimSpace(ref)
+	if c, err := s.Get(ctx, ref); err == nil {
+		return c, nil
+	} else if !errors.Is(err, ErrContactNotFound) {
+		return Contact{}, err
+	}
+	all, err := s.List(ctx)
+	if err != nil {
+		return Contact{}, err
+	}
+	var matches []Contact
+	for _, c := range all {
+		if c.matchesExactly(strings.ToLower(ref)) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Contact{}, fmt.Errorf("%w: %q", ErrContactNotFound, ref)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, c := range matches {
+		ids[i] = c.ID
+	}
+	return Contact{}, fmt.Errorf("%q matches several contacts (%s); use the contact ID", ref, strings.Join(ids, ", "))
+}
+
+// Channel returns the named channel, or the preferred channel, or the first
+// one when name is empty.
+func (c Contact) Channel(name string) (Channel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = c.Preferences.Channel
+	}
+	if name == "" && len(c.Channels) > 0 {
+		return c.Channels[0], nil
+	}
+	for _, ch := range c.Channels {
+		if ch.Name == name {
+			return ch, nil
+		}
+	}
+	return Channel{}, fmt.Errorf("%w: %s has no channel %q", ErrChannelNotFound, c.Name, name)
+}
+
+// Location returns the contact's timezone, UTC when unset.
+func (c Contact) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// QuietUntil reports whether now falls in the contact's quiet hours and, if
+// so, when they end.
+func (c Contact) QuietUntil(now time.Time) (time.Time, bool) {
+	start, end, ok := parseQuietHours(c.Preferences.QuietHours)
+	if !ok {
+		return time.Time{}, false
+	}
+	local := now.In(c.Location())
+	current := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if start <= end {
+		quiet = current >= start && current < end
+	} else {
+		quiet = current >= start || current < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// parseQuietHours returns the start and end of "HH:MM-HH:MM" in minutes
+// after midnight.
+func parseQuietHours(q string) (start, end int, ok bool) {
+	m := quietHoursPattern.FindStringSubmatch(q)
+	if m == nil {
+		return 0, 0, false
+	}
+	var v [4]int
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	if v[0] > 23 || v[2] > 23 || v[1] > 59 || v[3] > 59 {
+		return 0, 0, false
+	}
+	start, end = v[0]*60+v[1], v[2]*60+v[3]
+	return start, end, start != end
+}
+
+func (c Contact) matchesExactly(query string) bool {
+	if c.ID == query || strings.ToLower(c.Name) == query {
+		return true
+	}
+	for _, alias := range c.Aliases {
+		if strings.ToLower(alias) == query {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Contact) contains(query string) bool {
+	fields := append([]string{c.ID, c.Name, c.Notes}, c.Aliases...)
+	for _, ch := range c.Channels {
+		fields = append(fields, ch.Handle)
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContact(row scanner) (Contact, error) {
+	var c Contact
+	var dataJSON, createdAt, updatedAt string
+	if err := row.Scan(&c.ID, &c.Name, &dataJSON, &createdAt, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Contact{}, err
+		}
+		return Contact{}, fmt.Errorf("scan contact: %w", err)
+	}
+	var data contactData
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return Contact{}, fmt.Errorf("decode contact %s: %w", c.ID, err)
+	}
+	c.Aliases, c.Timezone, c.Channels, c.Preferences, c.Notes = data.Aliases, data.Timezone, data.Channels, data.Preferences, data.Notes
+	if c.Channels == nil {
+		c.Channels = []Channel{}
+	}
+	c.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	c.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return c, nil
+}
+
+// normalize trims c and checks its ID, timezone, channels and preferences.
+func (c *Contact) normalize() error {
+	c.ID = strings.TrimSpace(c.ID)
+	c.Name = strings.TrimSpace(c.Name)
+	c.Timezone = strings.TrimSpace(c.Timezone)
+	c.Notes = strings.TrimSpace(c.Notes)
+	if !idPattern.MatchString(c.ID) {
+		return fmt.Errorf("contact id %q must be lowercase letters, digits, _ or -, starting with a letter", c.ID)
+	}
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", c.Timezone)
+		}
+	}
+	aliases := c.Aliases[:0]
+	for _, alias := range c.Aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	c.Aliases = aliases
+	if c.Channels == nil {
+		c.Channels = []Channel{}
+	}
+	seen := map[string]bool{}
+	for i := range c.Channels {
+		ch := &c.Channels[i]
+		ch.Name = strings.ToLower(strings.TrimSpace(ch.Name))
+		ch.ServiceID = strings.TrimSpace(ch.ServiceID)
+		ch.Handle = strings.TrimSpace(ch.Handle)
+		ch.WebhookURL = strings.TrimSpace(ch.WebhookURL)
+		if !channelNamePattern.MatchString(ch.Name) {
+			return fmt.Errorf("channel name %q must be lowercase letters, digits, _ or -, starting with a letter", ch.Name)
+		}
+		if seen[ch.Name] {
+			return fmt.Errorf("channel %q is listed twice", ch.Name)
+		}
+		seen[ch.Name] = true
+		switch {
+		case ch.ServiceID != "" && ch.WebhookURL != "":
+			return fmt.Errorf("channel %q must set service_id or webhook_url, not both", ch.Name)
+		case ch.ServiceID != "":
+			if ch.Handle == "" && len(ch.Context) == 0 {
+				return fmt.Errorf("channel %q needs a handle or context to address the contact on %s", ch.Name, ch.ServiceID)
+			}
+		case ch.WebhookURL != "":
+			if !strings.HasPrefix(ch.WebhookURL, "https://") && !strings.HasPrefix(ch.WebhookURL, "http://") {
+				return fmt.Errorf("channel %q webhook_url must be an http(s) URL", ch.Name)
+			}
+		default:
+			return fmt.Errorf("channel %q needs a service_id or webhook_url", ch.Name)
+		}
+	}
+	c.Preferences.Channel = strings.ToLower(strings.TrimSpace(c.Preferences.Channel))
+	if c.Preferences.Channel != "" && !seen[c.Preferences.Channel] {
+		return fmt.Errorf("preferred channel %q is not one of the contact's channels", c.Preferences.Channel)
+	}
+	c.Preferences.QuietHours = strings.ReplaceAll(strings.TrimSpace(c.Preferences.QuietHours), " ", "")
+	if q := c.Preferences.QuietHours; q != "" {
+		if _, _, ok := parseQuietHours(q); !ok {
+			return fmt.Errorf("quiet_hours %q must look like 22:00-07:00", q)
+		}
+	}
+	sort.Strings(c.Aliases)
+	return nil
+}
@@ -0,0 +1,179 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const defaultChunkChars = 1200
+
+// Match is a document chunk selected for a turn.
+type Match struct {
+	DocumentID string  `json:"document_id"`
+	Title      string  `json:"title"`
+	Seq        int     `json:"seq"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
+// Retrieve returns the chunks of an agent's documents that best match query,
+// using keyword overlap. Each document contributes at most its token budget
+// and the total is capped at maxTokens.
+func (s *Store) Retrieve(ctx context.Context, agentID, query string, maxTokens int) ([]Match, error) {
+	terms := termSet(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.title, d.token_budget, c.seq, c.content
+		FROM agent_document_chunks c
+		JOIN agent_documents d ON d.id = c.document_id
+		WHERE d.agent_id = ?
+	`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("load document chunks: %w", err)
+	}
+	defer rows.Close()
+
+	type candidate struct {
+		Match
+		budget int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.DocumentID, &c.Title, &c.budget, &c.Seq, &c.Content); err != nil {
+			return nil, fmt.Errorf("scan document chunk: %w", err)
+		}
+		c.Score = score(terms, c.Title+"\n"+c.Content)
+		if c.Score <= 0 {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate document chunks: %w", err)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].DocumentID != candidates[j].DocumentID {
+			return candidates[i].DocumentID < candidates[j].DocumentID
+		}
+		return candidates[i].Seq < candidates[j].Seq
+	})
+
+	usedByDoc := map[string]int{}
+	used := 0
+	var out []Match
+	for _, c := range candidates {
+		tokens := EstimateTokens(c.Content)
+		if usedByDoc[c.DocumentID]+tokens > c.budget {
+			continue
+		}
+		if maxTokens > 0 && used+tokens > maxTokens {
+			continue
+		}
+		usedByDoc[c.DocumentID] += tokens
+		used += tokens
+		out = append(out, c.Match)
+	}
+	return out, nil
+}
+
+// EstimateTokens approximates the token count of text at four characters
+// per token.
+func EstimateTokens(text string) int {
+	n := len(text)
+	if n == 0 {
+		return 0
+	}
+	return (n + 3) / 4
+}
+
+// Split breaks text into chunks of roughly maxChars, preferring paragraph
+// boundaries and falling back to hard splits for oversized paragraphs.
+func Split(text string, maxChars int) []string {
+	if maxChars <= 0 {
+		maxChars = defaultChunkChars
+	}
+	var out []string
+	var current strings.Builder
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			out = append(out, chunk)
+		}
+		current.Reset()
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(para)+2 > maxChars {
+			flush()
+		}
+		for len(para) > maxChars {
+			cut := strings.LastIndexAny(para[:maxChars], " \n")
+			if cut <= 0 {
+				cut = maxChars
+			}
+			current.WriteString(para[:cut])
+			flush()
+			para = strings.TrimSpace(para[cut:])
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(para)
+	}
+	flush()
+	return out
+}
+
+func termSet(text string) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, term := range tokenize(text) {
+		if len(term) < 3 || stopWords[term] {
+			continue
+		}
+		out[term] = struct{}{}
+	}
+	return out
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// score counts how many distinct query terms appear in text, with a small
+// bonus for repeated occurrences.
+func score(terms map[string]struct{}, text string) float64 {
+	counts := map[string]int{}
+	for _, term := range tokenize(text) {
+		if _, ok := terms[term]; ok {
+			counts[term]++
+		}
+	}
+	var total float64
+	for _, n := range counts {
+		total += 1 + 0.1*float64(n-1)
+	}
+	return total
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "any": true, "can": true, "was": true, "our": true,
+	"has": true, "have": true, "this": true, "that": true, "with": true, "from": true,
+	"what": true, "when": true, "how": true, "why": true, "who": true, "which": true,
+	"will": true, "would": true, "should": true, "could": true, "into": true, "about": true,
+	"your": true, "they": true, "them": true, "their": true, "there": true, "then": true,
+}
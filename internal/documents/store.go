@@ -0,0 +1,198 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+)
+
+// DefaultTokenBudget caps how many tokens of a single document can be
+// injected into one turn when the document does not set its own budget.
+const DefaultTokenBudget = 800
+
+type Document struct {
+	ID          string    `json:"id"`
+	AgentID     string    `json:"agent_id"`
+	Title       string    `json:"title"`
+	TokenBudget int       `json:"token_budget"`
+	Chunks      int       `json:"chunks"`
+	Chars       int       `json:"chars"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Input struct {
+	AgentID     string
+	Title       string
+	Content     string
+	TokenBudget int
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func WithIDGenerator(newIDFn func() string) Option {
+	return func(s *Store) {
+		if newIDFn != nil {
+			s.newIDFn = newIDFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+func (s *Store) newID() string {
+	if s.newIDFn == nil {
+		return idgen.New()
+	}
+	return s.newIDFn()
+}
+
+// Add stores a document for an agent and splits it into retrievable chunks.
+func (s *Store) Add(ctx context.Context, input Input) (Document, error) {
+	agentID := strings.TrimSpace(input.AgentID)
+	if agentID == "" {
+		return Document{}, fmt.Errorf("agent_id is required")
+	}
+	text := strings.TrimSpace(input.Content)
+	if text == "" {
+		return Document{}, fmt.Errorf("content is required")
+	}
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		title = "untitled"
+	}
+	budget := input.TokenBudget
+	if budget <= 0 {
+		budget = DefaultTokenBudget
+	}
+	chunks := Split(text, defaultChunkChars)
+
+	doc := Document{
+		ID:          s.newID(),
+		AgentID:     agentID,
+		Title:       title,
+		TokenBudget: budget,
+		Chunks:      len(chunks),
+		Chars:       len(text),
+		CreatedAt:   s.now(),
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Document{}, fmt.Errorf("begin document tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO agent_documents (id, agent_id, title, content, token_budget, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, doc.ID, doc.AgentID, doc.Title, text, doc.TokenBudget, doc.CreatedAt.Format(time.RFC3339Nano)); err != nil {
+		return Document{}, fmt.Errorf("insert document: %w", err)
+	}
+	for i, chunk := range chunks {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO agent_document_chunks (document_id, seq, content) VALUES (?, ?, ?)`, doc.ID, i, chunk); err != nil {
+			return Document{}, fmt.Errorf("insert document chunk: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Document{}, fmt.Errorf("commit document: %w", err)
+	}
+	return doc, nil
+}
+
+func (s *Store) List(ctx context.Context, agentID string) ([]Document, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.agent_id, d.title, d.token_budget, length(d.content), d.created_at,
+			(SELECT COUNT(*) FROM agent_document_chunks c WHERE c.document_id = d.id)
+		FROM agent_documents d
+		WHERE d.agent_id = ?
+		ORDER BY d.created_at ASC
+	`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Document
+	for rows.Next() {
+		var doc Document
+		var createdAtStr string
+		if err := rows.Scan(&doc.ID, &doc.AgentID, &doc.Title, &doc.TokenBudget, &doc.Chars, &createdAtStr, &doc.Chunks); err != nil {
+			return nil, fmt.Errorf("scan document: %w", err)
+		}
+		doc.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
+		out = append(out, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate documents: %w", err)
+	}
+	return out, nil
+}
+
+// Delete removes a document and its chunks. It reports whether a document
+// was removed.
+func (s *Store) Delete(ctx context.Context, agentID, documentID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin document tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM agent_documents WHERE id = ? AND agent_id = ?`, documentID, agentID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("load document: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM agent_document_chunks WHERE document_id = ?`, id); err != nil {
+		return false, fmt.Errorf("delete document chunks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM agent_documents WHERE id = ?`, id); err != nil {
+		return false, fmt.Errorf("delete document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit document delete: %w", err)
+	}
+	return true, nil
+}
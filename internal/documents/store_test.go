@@ -0,0 +1,98 @@
+package documents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreAddRetrieveDelete(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	store := NewStore(db)
+	ctx := context.Background()
+
+	runbook, err := store.Add(ctx, Input{
+		AgentID: "agent-1",
+		Title:   "Deploy runbook",
+		Content: "To roll back a deploy, run the rollback script.\n\nDatabase migrations must be reviewed first.",
+	})
+	if err != nil {
+		t.Fatalf("add runbook: %v", err)
+	}
+	if _, err := store.Add(ctx, Input{AgentID: "agent-1", Title: "Pricing", Content: "Plans are billed monthly."}); err != nil {
+		t.Fatalf("add pricing: %v", err)
+	}
+	if _, err := store.Add(ctx, Input{AgentID: "agent-2", Title: "Other", Content: "Rollback procedures for another agent."}); err != nil {
+		t.Fatalf("add other: %v", err)
+	}
+
+	docs, err := store.List(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+	if docs[0].TokenBudget != DefaultTokenBudget {
+		t.Fatalf("expected default budget, got %d", docs[0].TokenBudget)
+	}
+
+	matches, err := store.Retrieve(ctx, "agent-1", "how do I rollback the deploy?", 0)
+	if err != nil {
+		t.Fatalf("retrieve: %v", err)
+	}
+	if len(matches) == 0 || matches[0].DocumentID != runbook.ID {
+		t.Fatalf("expected runbook chunk first, got %#v", matches)
+	}
+
+	removed, err := store.Delete(ctx, "agent-1", runbook.ID)
+	if err != nil || !removed {
+		t.Fatalf("delete: removed=%v err=%v", removed, err)
+	}
+	matches, err = store.Retrieve(ctx, "agent-1", "rollback deploy", 0)
+	if err != nil {
+		t.Fatalf("retrieve after delete: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("expected no matches after delete, got %#v", matches)
+	}
+}
+
+func TestRetrieveRespectsDocumentBudget(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	store := NewStore(db)
+	ctx := context.Background()
+
+	paragraph := strings.Repeat("incident response escalation ", 40)
+	content := paragraph + "\n\n" + paragraph + "\n\n" + paragraph
+	if _, err := store.Add(ctx, Input{AgentID: "agent-1", Title: "Incidents", Content: content, TokenBudget: 400}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	matches, err := store.Retrieve(ctx, "agent-1", "incident escalation", 0)
+	if err != nil {
+		t.Fatalf("retrieve: %v", err)
+	}
+	total := 0
+	for _, m := range matches {
+		total += EstimateTokens(m.Content)
+	}
+	if len(matches) == 0 || total > 400 {
+		t.Fatalf("expected matches within budget, got %d chunks totalling %d tokens", len(matches), total)
+	}
+}
+
+func TestSplitPrefersParagraphs(t *testing.T) {
+	chunks := Split("alpha\n\nbeta\n\n"+strings.Repeat("x", 30), 20)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %#v", chunks)
+	}
+	if chunks[0] != "alpha\n\nbeta" {
+		t.Fatalf("expected short paragraphs merged, got %q", chunks[0])
+	}
+}
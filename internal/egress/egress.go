@@ -0,0 +1,351 @@
+// Package egress applies one outbound policy to every HTTP request agentd
+// makes: provider calls, webhooks, push notifications, calendars, event
+// sinks, federation and agent-requested fetches. A policy sets the proxy,
+// which destinations may be reached, extra TLS roots, and keeps an audit of
+// the destinations contacted.
+package egress
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTransport is captured before Install replaces
+// http.DefaultTransport.
+var defaultTransport = http.DefaultTransport.(*http.Transport)
+
+// ErrDenied is returned for requests to destinations the policy blocks.
+var ErrDenied = errors.New("egress denied")
+
+// Config is the "egress" section of the config file.
+type Config struct {
+	// Proxy is the HTTP(S) proxy URL for outbound requests. Empty uses the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+	Proxy string `json:"proxy"`
+	// NoProxy lists destinations reached directly when Proxy is set.
+	NoProxy []string `json:"no_proxy"`
+	// Allow, when not empty, is the only set of destinations that may be
+	// reached. Deny always wins over Allow. Entries are host names
+	// ("api.openai.com"), subdomain wildcards ("*.googleapis.com"), IP
+	// ranges ("10.0.0.0/8") or "*".
+	Allow []string  `json:"allow"`
+	Deny  []string  `json:"deny"`
+	TLS   TLSConfig `json:"tls"`
+	// Audit logs every outbound request's method, host and outcome.
+	Audit bool `json:"audit"`
+}
+
+type TLSConfig struct {
+	// CAFile adds PEM root certificates, such as a corporate proxy's CA,
+	// to the system pool.
+	CAFile string `json:"ca_file"`
+	// MinVersion is "1.2" or "1.3"; empty keeps Go's default.
+	MinVersion         string `json:"min_version"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// Destination is the audit record for one host.
+type Destination struct {
+	Host       string    `json:"host"`
+	Requests   int64     `json:"requests"`
+	Denied     int64     `json:"denied"`
+	Errors     int64     `json:"errors"`
+	LastStatus int       `json:"last_status,omitempty"`
+	LastAt     time.Time `json:"last_at"`
+}
+
+// Policy is an outbound HTTP policy. A nil Policy allows everything.
+type Policy struct {
+	allow   []matcher
+	deny    []matcher
+	noProxy []matcher
+	audit   bool
+	base    *http.Transport
+	logf    func(format string, args ...any)
+	nowFn   func() time.Time
+
+	mu           sync.Mutex
+	destinations map[string]*Destination
+}
+
+type Option func(*Policy)
+
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(p *Policy) {
+		if logf != nil {
+			p.logf = logf
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(p *Policy) {
+		if nowFn != nil {
+			p.nowFn = nowFn
+		}
+	}
+}
+
+// New builds a policy from cfg.
+func New(cfg Config, opts ...Option) (*Policy, error) {
+	p := &Policy{
+		audit:        cfg.Audit,
+		logf:         func(string, ...any) {},
+		nowFn:        func() time.Time { return time.Now().UTC() },
+		destinations: map[string]*Destination{},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	var err error
+	if p.allow, err = parseMatchers(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("egress allow: %w", err)
+	}
+	if p.deny, err = parseMatchers(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("egress deny: %w", err)
+	}
+	if p.noProxy, err = parseMatchers(cfg.NoProxy); err != nil {
+		return nil, fmt.Errorf("egress no_proxy: %w", err)
+	}
+
+	base := defaultTransport.Clone()
+	if proxy := strings.TrimSpace(cfg.Proxy); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("egress proxy %q is not a URL", proxy)
+		}
+		base.Proxy = func(req *http.Request) (*url.URL, error) {
+			if matchAny(p.noProxy, req.URL.Hostname()) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+	tlsConfig, err := cfg.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		base.TLSClientConfig = tlsConfig
+	}
+	p.base = base
+	return p, nil
+}
+
+func (c TLSConfig) build() (*tls.Config, error) {
+	if c.CAFile == "" && c.MinVersion == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	out := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify} // #nosec G402 -- operator opt-in for test environments
+	switch strings.TrimSpace(c.MinVersion) {
+	case "":
+	case "1.2":
+		out.MinVersion = tls.VersionTLS12
+	case "1.3":
+		out.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("egress tls min_version %q: want 1.2 or 1.3", c.MinVersion)
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read egress ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("egress ca_file %s has no certificates", c.CAFile)
+		}
+		out.RootCAs = pool
+	}
+	return out, nil
+}
+
+// Check reports whether host may be reached.
+func (p *Policy) Check(host string) error {
+	if p == nil {
+		return nil
+	}
+	host = normalizeHost(host)
+	if matchAny(p.deny, host) {
+		return fmt.Errorf("%w: %s is on the deny list", ErrDenied, host)
+	}
+	if len(p.allow) > 0 && !matchAny(p.allow, host) {
+		return fmt.Errorf("%w: %s is not on the allow list", ErrDenied, host)
+	}
+	return nil
+}
+
+// Transport returns a round tripper that enforces the policy. It is
+// http.DefaultTransport for a nil policy.
+func (p *Policy) Transport() http.RoundTripper {
+	if p == nil {
+		return http.DefaultTransport
+	}
+	return &transport{policy: p}
+}
+
+// Client returns an HTTP client with the given timeout that goes through
+// the policy.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: p.Transport()}
+}
+
+// Install routes http.DefaultTransport, and so http.DefaultClient and every
+// client without its own transport, through the policy.
+func (p *Policy) Install() {
+	if p == nil {
+		return
+	}
+	http.DefaultTransport = p.Transport()
+}
+
+// Destinations returns the audit records, most requested first.
+func (p *Policy) Destinations() []Destination {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	out := make([]Destination, 0, len(p.destinations))
+	for _, d := range p.destinations {
+		out = append(out, *d)
+	}
+	p.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Host < out[j].Host
+	})
+	return out
+}
+
+func (p *Policy) record(method, host string, status int, denied bool, err error) {
+	p.mu.Lock()
+	d, ok := p.destinations[host]
+	if !ok {
+		d = &Destination{Host: host}
+		p.destinations[host] = d
+	}
+	d.Requests++
+	d.LastAt = p.nowFn()
+	switch {
+	case denied:
+		d.Denied++
+	case err != nil:
+		d.Errors++
+	default:
+		d.LastStatus = status
+	}
+	p.mu.Unlock()
+	if !p.audit {
+		return
+	}
+	// Only the method and host are logged; paths and queries can carry
+	// tokens.
+	switch {
+	case denied:
+		p.logf("egress: %s %s denied", method, host)
+	case err != nil:
+		p.logf("egress: %s %s failed: %v", method, host, err)
+	default:
+		p.logf("egress: %s %s -> %d", method, host, status)
+	}
+}
+
+type transport struct {
+	policy *Policy
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := normalizeHost(req.URL.Hostname())
+	if err := t.policy.Check(host); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		t.policy.record(req.Method, host, 0, true, nil)
+		return nil, err
+	}
+	resp, err := t.policy.base.RoundTrip(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	t.policy.record(req.Method, host, status, false, err)
+	return resp, err
+}
+
+// matcher matches a host name or IP address.
+type matcher struct {
+	any    bool
+	host   string
+	suffix string
+	ipNet  *net.IPNet
+}
+
+func parseMatchers(entries []string) ([]matcher, error) {
+	var out []matcher
+	for _, raw := range entries {
+		entry := normalizeHost(raw)
+		switch {
+		case entry == "":
+		case entry == "*":
+			out = append(out, matcher{any: true})
+		case strings.HasPrefix(entry, "*."):
+			out = append(out, matcher{suffix: entry[1:]})
+		case strings.Contains(entry, "/"):
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("bad range %q: %w", raw, err)
+			}
+			out = append(out, matcher{ipNet: ipNet})
+		default:
+			out = append(out, matcher{host: entry})
+		}
+	}
+	return out, nil
+}
+
+func (m matcher) match(host string) bool {
+	switch {
+	case m.any:
+		return true
+	case m.ipNet != nil:
+		ip := net.ParseIP(host)
+		return ip != nil && m.ipNet.Contains(ip)
+	case m.suffix != "":
+		return strings.HasSuffix(host, m.suffix)
+	default:
+		return host == m.host
+	}
+}
+
+func matchAny(matchers []matcher, host string) bool {
+	host = normalizeHost(host)
+	for _, m := range matchers {
+		if m.match(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimSuffix(host, ".")
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,104 @@
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPolicyAllowDenyLists(t *testing.T) {
+	p, err := New(Config{
+		Allow: []string{"api.openai.com", "*.googleapis.com", "10.0.0.0/8"},
+		Deny:  []string{"blocked.googleapis.com"},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	cases := map[string]bool{
+		"api.openai.com":                    true,
+		"API.OpenAI.com.":                   true,
+		"generativelanguage.googleapis.com": true,
+		"blocked.googleapis.com":            false,
+		"googleapis.com":                    false,
+		"10.1.2.3":                          true,
+		"11.1.2.3":                          false,
+		"example.com":                       false,
+	}
+	for host, want := range cases {
+		if got := p.Check(host) == nil; got != want {
+			t.Errorf("Check(%q) allowed=%v, want %v", host, got, want)
+		}
+	}
+	if err := p.Check("example.com"); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected ErrDenied, got %v", err)
+	}
+	if _, err := New(Config{Deny: []string{"10.0.0.0/99"}}); err == nil {
+		t.Fatalf("expected a bad range to be rejected")
+	}
+}
+
+func TestTransportEnforcesPolicyAndAudits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	denied, err := New(Config{Allow: []string{"api.example.com"}, Audit: true}, WithLogger(logf))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := denied.Client(0).Get(srv.URL + "/secret?token=x"); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected the request to be denied, got %v", err)
+	}
+
+	allowed, err := New(Config{Allow: []string{"127.0.0.0/8"}, Audit: true}, WithLogger(logf))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	resp, err := allowed.Client(0).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+
+	dests := allowed.Destinations()
+	if len(dests) != 1 || dests[0].Host != "127.0.0.1" || dests[0].Requests != 1 || dests[0].LastStatus != http.StatusNoContent {
+		t.Fatalf("unexpected audit %+v", dests)
+	}
+	if d := denied.Destinations(); len(d) != 1 || d[0].Denied != 1 {
+		t.Fatalf("expected the denial audited, got %+v", d)
+	}
+	if len(logged) != 2 || !strings.Contains(logged[0], "denied") || strings.Contains(strings.Join(logged, " "), "token") {
+		t.Fatalf("unexpected audit log %q", logged)
+	}
+}
+
+func TestTransportUsesConfiguredProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "via proxy to "+r.URL.Host)
+	}))
+	defer proxy.Close()
+
+	p, err := New(Config{Proxy: proxy.URL, NoProxy: []string{"direct.invalid"}})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	resp, err := p.Client(0).Get("http://upstream.invalid/path")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy to upstream.invalid" {
+		t.Fatalf("expected the request to go through the proxy, got %q", body)
+	}
+	if _, err := p.Client(0).Get("http://direct.invalid/"); err == nil {
+		t.Fatalf("expected no_proxy host to be dialed directly and fail")
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// Activity frame types published on schema.StreamActivity.
+const (
+	ActivityTextDelta    = "text_delta"
+	ActivityThinking     = "thinking"
+	ActivityToolStart    = "tool_start"
+	ActivityToolDelta    = "tool_delta"
+	ActivityToolDone     = "tool_done"
+	ActivityTurnComplete = "turn_complete"
+)
+
+// activityFrames maps the LLM updates recorded for a turn to the activity
+// frame they are published as, and the payload fields the frame keeps.
+var activityFrames = map[string]struct {
+	frameType string
+	fields    []string
+}{
+	"llm_text":          {ActivityTextDelta, []string{"text"}},
+	"llm_thinking":      {ActivityThinking, []string{"id", "text", "summary"}},
+	"llm_thinking_done": {ActivityThinking, []string{"done"}},
+	"llm_tool_start":    {ActivityToolStart, []string{"tool_call_id", "tool_name", "tool_label"}},
+	"llm_tool_delta":    {ActivityToolDelta, []string{"tool_call_id", "delta"}},
+	"llm_tool_done":     {ActivityToolDone, []string{"tool_call_id", "tool_name", "args", "result"}},
+}
+
+// publishActivity publishes the activity frame for an LLM update of the
+// turn llmTaskID, if the update has one and anyone is listening. The agent
+// is taken from ctx.
+func (r *Runtime) publishActivity(ctx context.Context, llmTaskID, kind string, payload map[string]any) {
+	spec, ok := activityFrames[kind]
+	if !ok {
+		return
+	}
+	frame := make(map[string]any, len(spec.fields))
+	for _, field := range spec.fields {
+		if value, present := payload[field]; present {
+			frame[field] = value
+		}
+	}
+	r.emitActivity(agentcontext.TaskIDFromContext(ctx), llmTaskID, spec.frameType, frame)
+}
+
+// publishTurnComplete publishes the turn_complete frame ending a turn's
+// activity, with status completed, cancelled or failed.
+func (r *Runtime) publishTurnComplete(agentID, llmTaskID string, err error) {
+	frame := map[string]any{"status": "completed"}
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		frame["status"] = "cancelled"
+	case err != nil:
+		frame["status"] = "failed"
+		frame["error"] = err.Error()
+	}
+	r.emitActivity(agentID, llmTaskID, ActivityTurnComplete, frame)
+}
+
+func (r *Runtime) emitActivity(agentID, llmTaskID, frameType string, frame map[string]any) {
+	agentID = strings.TrimSpace(agentID)
+	if r.Bus == nil || agentID == "" || !r.Bus.HasTopicSubscribers(schema.StreamActivity) {
+		return
+	}
+	now := time.Now().UTC()
+	frame["type"] = frameType
+	frame["agent_id"] = agentID
+	frame["llm_task_id"] = llmTaskID
+	frame["created_at"] = now
+	r.Bus.Publish(schema.StreamActivity, eventbus.Event{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Subject:   frameType,
+		Metadata:  map[string]any{"kind": frameType, "agent_id": agentID, "llm_task_id": llmTaskID},
+		Payload:   frame,
+		CreatedAt: now,
+	})
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestTurnPublishesActivityFrames(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := newMultiExecTurnProvider([]llms.ToolCall{
+		{ID: "call-1", Name: "noop", Arguments: []byte(`{"comment":"hi"}`)},
+	}, "all done")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider, agenttools.NoopTool())})
+	createTestAgent(t, mgr, "agent-a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := bus.SubscribeTopic(ctx, schema.StreamActivity)
+	session, err := rt.HandleMessage(ctx, "agent-a", "user", "go", nil)
+	if err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+
+	var frames []map[string]any
+	for len(frames) == 0 || frames[len(frames)-1]["type"] != ActivityTurnComplete {
+		select {
+		case evt := <-sub:
+			if evt.ScopeID != "agent-a" || evt.Payload["llm_task_id"] != session.LLMTaskID {
+				t.Fatalf("expected frames for the agent's turn, got %+v", evt)
+			}
+			frames = append(frames, evt.Payload)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out after frames %v", frames)
+		}
+	}
+	var types []string
+	for _, frame := range frames {
+		types = append(types, frame["type"].(string))
+	}
+	want := []string{ActivityToolStart, ActivityToolDelta, ActivityToolDone, ActivityTextDelta, ActivityTurnComplete}
+	if len(types) != len(want) {
+		t.Fatalf("expected frames %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected frames %v, got %v", want, types)
+		}
+	}
+	if frames[0]["tool_name"] != "noop" || frames[1]["delta"] != `{"comment":"hi"}` || frames[2]["tool_call_id"] != "call-1" || frames[2]["result"] == nil {
+		t.Fatalf("unexpected tool frames %v", frames[:3])
+	}
+	if frames[3]["text"] != "all done" || frames[4]["status"] != "completed" {
+		t.Fatalf("unexpected text and completion frames %v %v", frames[3], frames[4])
+	}
+	if _, ok := frames[0]["tool_desc"]; ok {
+		t.Fatalf("expected frames to keep only their fields, got %v", frames[0])
+	}
+}
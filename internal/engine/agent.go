@@ -5,21 +5,45 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/flitsinc/go-agents/internal/agentcontext"
 	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/documents"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/facts"
 	"github.com/flitsinc/go-agents/internal/goagents"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/maintenance"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/monitors"
+	"github.com/flitsinc/go-agents/internal/preprocess"
 	agentctx "github.com/flitsinc/go-agents/internal/prompt"
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/questions"
 	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-agents/internal/state"
 	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/topics"
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"github.com/flitsinc/go-agents/internal/urgency"
+	"github.com/flitsinc/go-agents/internal/usage"
 	"github.com/flitsinc/go-llms/content"
 	"github.com/flitsinc/go-llms/llms"
 	llmtools "github.com/flitsinc/go-llms/tools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Session struct {
@@ -33,9 +57,21 @@ type Session struct {
 }
 
 type taskConfig struct {
-	System string
-	Model  string
-	mu     sync.Mutex
+	System        string
+	Model         string
+	ProviderTools []string
+	Location      *time.Location
+	// MetricsSnapshots opts the agent into periodic metrics_snapshot
+	// signals; TokenBudget is its daily token allowance, zero for none.
+	MetricsSnapshots bool
+	TokenBudget      int64
+	// DigestAt is the local "HH:MM" of the agent's daily digest turn.
+	DigestAt string
+	// Concurrency is how many turns may run in parallel; zero means one.
+	Concurrency int
+	// HistoryBudget overrides the runtime's per-turn history limits.
+	HistoryBudget *HistoryBudget
+	mu            sync.Mutex
 }
 
 type TurnContext struct {
@@ -44,6 +80,8 @@ type TurnContext struct {
 	TimePassed  bool
 	Elapsed     time.Duration
 	DateChanged bool
+	// Location is the agent's timezone for rendering times; nil means UTC.
+	Location *time.Location
 }
 
 type ContextUpdateFrame struct {
@@ -53,6 +91,9 @@ type ContextUpdateFrame struct {
 	Scanned     int
 	Emitted     int
 	Superseded  int
+	MessageGaps []MessageGap
+	// Refs maps event IDs to the short refs the model cites them by.
+	Refs map[string]string
 }
 
 type Runtime struct {
@@ -62,19 +103,127 @@ type Runtime struct {
 	Context     *agentctx.Manager
 	LLMFactory  func() (*llms.LLM, error)
 	LLMDebugDir string
+	Documents   *documents.Store
+	Urgency     urgency.Classifier
+	// InterruptedTaskPolicies maps tool names to what happens to tasks they
+	// spawned when the turn is interrupted. Unlisted tools adopt.
+	InterruptedTaskPolicies map[string]InterruptedTaskPolicy
+	Maintenance             *maintenance.Store
+	// ToolLoopLimit is how many identical tool calls a turn may make before
+	// the model is nudged and then stopped. Zero uses the default; negative
+	// disables the watchdog.
+	ToolLoopLimit int
+	// HistoryBudget limits the history entries each turn writes, for agents
+	// without their own. Zero fields use the defaults.
+	HistoryBudget HistoryBudget
+	// Monitors runs the runtime's background jobs. Start creates one when
+	// nil.
+	Monitors *monitors.Registry
+	// Topics holds agents' shared topic subscriptions. Without it agents
+	// receive no topic publications.
+	Topics *topics.Store
+	// Questions tracks questions agents ask with ask_user. Without it the
+	// tool is unavailable and replies are not correlated.
+	Questions *questions.Store
+	// ToolSummaryBudgets overrides, per tool name, how many characters of
+	// each result content item are kept in task updates and history.
+	ToolSummaryBudgets map[string]int
+	// ContextBudgets caps, per model name, the estimated tokens of the system
+	// prompt and prior conversation a turn sends; "*" covers unlisted
+	// models. A turn over its budget first compacts the agent's context and
+	// carries a summary by ContextSummarizer into the new generation.
+	ContextBudgets    map[string]int
+	ContextSummarizer ContextSummarizer
+	// Shadow holds A/B trials; turns of an agent with an active trial are
+	// mirrored to the trial's candidate prompt and model.
+	Shadow *shadow.Store
+	// ShadowLLMFactory overrides how candidate sessions are built, mainly
+	// for tests. It receives the trial's model.
+	ShadowLLMFactory func(model string) (*llms.LLM, error)
+	// ModelOverrides lists the "provider/model" pairs a message may request
+	// for its own turn; see CheckModelOverride.
+	ModelOverrides []string
+	// OverrideLLMFactory overrides how sessions for per-turn model overrides
+	// are built, mainly for tests.
+	OverrideLLMFactory func(override ModelOverride) (*llms.LLM, error)
+	// Labels stores a title and topic tags per agent, written by the labels
+	// monitor using Titler. Labeling is off unless both are set.
+	Labels *labels.Store
+	Titler labels.Titler
+	// InterruptAlertAfter is how long an interrupt may sit unread before an
+	// alert is raised. Zero uses DefaultInterruptAlertAfter.
+	InterruptAlertAfter time.Duration
+	// Artifacts stores assistant replies over its threshold in full; history,
+	// task output and replies carry a summary and reference instead.
+	Artifacts *artifacts.Offloader
+	// CrashLoopThreshold crashes of an agent's loop within CrashLoopWindow
+	// make a crash loop; zero uses the defaults.
+	CrashLoopThreshold int
+	CrashLoopWindow    time.Duration
+	// Facts holds each agent's pinned facts, shown on every turn.
+	// FactExtractor, when set, adds the durable facts it finds in each
+	// finished turn.
+	Facts         *facts.Store
+	FactExtractor facts.Extractor
+	// Preprocessors rewrite every inbound external message before the
+	// agent's own preprocessors do. Translator serves their translate
+	// steps.
+	Preprocessors []preprocess.Step
+	Translator    preprocess.Translator
+	// ModelPrices prices tokens per model name, "*" covering unlisted
+	// models, so recorded usage carries an estimated cost. Budget caps every
+	// agent's daily and monthly tokens and cost; an agent's own budget
+	// overrides it field by field.
+	ModelPrices map[string]budget.ModelPrice
+	Budget      budget.Budget
+	// Archive rehydrates events moved to object storage for exports that
+	// reach past what the database still holds.
+	Archive *archive.Archiver
+	// ProvisionRules let messages for unknown agents create them on
+	// demand.
+	ProvisionRules []provision.Rule
+	// CheckpointEvery is how many tool rounds a turn runs between
+	// checkpoints of its progress; zero uses DefaultCheckpointEvery and a
+	// negative value turns checkpoints off.
+	CheckpointEvery int
+	// Usage keeps every turn's tokens and cost for roll-ups by agent, model
+	// and day.
+	Usage *usage.Store
+	// Quality keeps every turn's outcome and the feedback left on it for
+	// per-agent quality metrics.
+	Quality *quality.Store
+	// Memories holds agents' long-term memories. Embedder, when set, embeds
+	// them and agents' messages in the background for SearchMemory.
+	Memories *memory.Store
+	Embedder memory.Embedder
 
 	baseCtx context.Context
 	loopMu  sync.Mutex
 	loops   map[string]context.CancelFunc
 
+	loopHealthMu sync.Mutex
+	loopHealth   map[string]*loopTracker
+
+	// factsWG tracks background fact extraction.
+	factsWG sync.WaitGroup
+
 	mu       sync.RWMutex
 	sessions map[string]Session
 
 	configMu    sync.RWMutex
 	taskConfigs map[string]*taskConfig
 
-	inflightMu sync.Mutex
-	inflight   map[string]context.CancelFunc
+	// inflightMu guards inflight, the cancellations of running turns by
+	// llm task, and inflightStats, what reconciling them did.
+	inflightMu    sync.Mutex
+	inflight      map[string]context.CancelFunc
+	inflightStats InflightReport
+
+	// embedMu guards embedCursors, the last history entry the embedding
+	// monitor saw per agent, and semanticIndexes, the cached indexes.
+	embedMu         sync.Mutex
+	embedCursors    map[string]string
+	semanticIndexes map[string]semanticIndex
 
 	wakeMu   sync.Mutex
 	lastWake map[string]time.Time
@@ -89,6 +238,41 @@ type Runtime struct {
 	historyGenerationByTask map[string]int64
 	historyPreambleByTask   map[string]int64
 
+	messageSeqMu sync.Mutex
+	messageSeqs  map[string]int64
+
+	contextRefMu sync.Mutex
+	contextRefs  map[string]*contextRefs
+
+	conversationMu sync.Mutex
+	conversations  map[string]*conversationBuilder
+
+	maintenanceMu sync.Mutex
+	deferredWakes map[string]map[string]struct{}
+
+	// budgetHolds remembers, per agent, the exhausted budget until its
+	// window resets.
+	budgetMu    sync.Mutex
+	budgetHolds map[string]budget.Exhaustion
+
+	lanesMu sync.Mutex
+	lanes   map[string]*turnLanes
+
+	// teamMu serializes changes to the assignment state of teams.
+	teamMu sync.Mutex
+
+	// asksMu guards asks, the number of Ask calls each agent is waiting on,
+	// by the agent asked.
+	asksMu sync.Mutex
+	asks   map[string]map[string]int
+
+	// promptToolsMu guards Context.ToolNames, which MCP refreshes replace
+	// while turns run.
+	promptToolsMu sync.RWMutex
+
+	draining    atomic.Bool
+	activeTurns atomic.Int64
+
 	nowFn func() time.Time
 }
 
@@ -123,6 +307,7 @@ var agentContextDefaultVisibilityByStream = map[string]bool{
 	schema.StreamErrors:     true,
 	schema.StreamExternal:   true,
 	schema.StreamTaskInput:  true,
+	schema.StreamShared:     true,
 }
 
 type Option func(*Runtime)
@@ -156,6 +341,7 @@ func NewRuntime(bus *eventbus.Bus, tasksMgr *tasks.Manager, client *ai.Client, o
 		lastContextCursorByTask: map[string]string{},
 		historyGenerationByTask: map[string]int64{},
 		historyPreambleByTask:   map[string]int64{},
+		messageSeqs:             map[string]int64{},
 		nowFn:                   func() time.Time { return time.Now().UTC() },
 	}
 	for _, opt := range opts {
@@ -223,7 +409,22 @@ func (r *Runtime) Start(ctx context.Context) {
 		r.recoverStaleTasks(ctx)
 	}
 	if r.Tasks != nil && r.Bus != nil {
-		go r.monitorTaskHealth(ctx)
+		if r.Monitors == nil {
+			r.Monitors = monitors.NewRegistry()
+		}
+		_ = r.Monitors.Register(TaskHealthMonitor, taskHealthInterval, r.emitTaskHealth)
+		if r.Labels != nil && r.Titler != nil {
+			_ = r.Monitors.Register(LabelMonitor, labelInterval, r.refreshLabels)
+		}
+		_ = r.Monitors.Register(InterruptAlertMonitor, interruptAlertInterval, r.alertUnacknowledgedInterrupts)
+		_ = r.Monitors.Register(MetricsSnapshotMonitor, metricsSnapshotInterval, r.emitMetricsSnapshots)
+		_ = r.Monitors.Register(DailyDigestMonitor, dailyDigestInterval, r.emitDailyDigests)
+		_ = r.Monitors.Register(SnoozeMonitor, snoozeInterval, r.deliverEndedSnoozes)
+		_ = r.Monitors.Register(InflightMonitor, inflightInterval, r.sweepInflight)
+		if r.Memories != nil && r.Embedder != nil {
+			_ = r.Monitors.Register(EmbeddingMonitor, embeddingInterval, r.embedPending)
+		}
+		r.Monitors.Start(ctx)
 	}
 }
 
@@ -246,32 +447,27 @@ func (r *Runtime) recoverStaleTasks(ctx context.Context) {
 	}
 }
 
-func (r *Runtime) monitorTaskHealth(ctx context.Context) {
-	const taskHealthInterval = 30 * time.Second
-	ticker := time.NewTicker(taskHealthInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			r.emitTaskHealth(ctx)
-		}
-	}
-}
+// TaskHealthMonitor is the monitor that snapshots running tasks and wakes
+// agents whose exec tasks have gone stale.
+const TaskHealthMonitor = "task_health"
 
-func (r *Runtime) emitTaskHealth(ctx context.Context) {
+const taskHealthInterval = 30 * time.Second
+
+func (r *Runtime) emitTaskHealth(ctx context.Context) error {
 	const taskHealthStale = 30 * time.Second
 	const taskHealthWakeCooldown = 30 * time.Second
 	if r.Tasks == nil || r.Bus == nil {
-		return
+		return nil
 	}
 	tasksList, err := r.Tasks.List(ctx, tasks.ListFilter{
 		Status: tasks.StatusRunning,
 		Limit:  200,
 	})
-	if err != nil || len(tasksList) == 0 {
-		return
+	if err != nil {
+		return fmt.Errorf("list running tasks: %w", err)
+	}
+	if len(tasksList) == 0 {
+		return nil
 	}
 
 	now := r.now()
@@ -307,6 +503,7 @@ func (r *Runtime) emitTaskHealth(ctx context.Context) {
 		}
 	}
 
+	var pushErrs []error
 	for target, list := range byTarget {
 		scopeType := "task"
 		scopeID := target
@@ -314,7 +511,7 @@ func (r *Runtime) emitTaskHealth(ctx context.Context) {
 			scopeType = "global"
 			scopeID = "*"
 		}
-		_, _ = r.Bus.Push(ctx, eventbus.EventInput{
+		_, err := r.Bus.Push(ctx, eventbus.EventInput{
 			Stream:    "signals",
 			Subject:   "task_health",
 			Body:      "task health snapshot",
@@ -330,6 +527,9 @@ func (r *Runtime) emitTaskHealth(ctx context.Context) {
 				"priority": "low",
 			},
 		})
+		if err != nil {
+			pushErrs = append(pushErrs, err)
+		}
 	}
 
 	for target, list := range staleByTarget {
@@ -351,7 +551,7 @@ func (r *Runtime) emitTaskHealth(ctx context.Context) {
 			continue
 		}
 		body := fmt.Sprintf("task_health: stale tasks detected (%d). See signals/task_health. ids=%s", len(wakeIDs), strings.Join(wakeIDs, ","))
-		_, _ = r.Bus.Push(ctx, eventbus.EventInput{
+		_, err := r.Bus.Push(ctx, eventbus.EventInput{
 			Stream:    schema.StreamTaskInput,
 			ScopeType: "task",
 			ScopeID:   target,
@@ -365,7 +565,11 @@ func (r *Runtime) emitTaskHealth(ctx context.Context) {
 				"source":   "runtime",
 			},
 		})
+		if err != nil {
+			pushErrs = append(pushErrs, err)
+		}
 	}
+	return errors.Join(pushErrs...)
 }
 
 func (r *Runtime) shouldWakeTask(taskID string, now time.Time, cooldown time.Duration) bool {
@@ -384,7 +588,7 @@ func (r *Runtime) EnsureAgentLoop(taskID string) {
 		return
 	}
 	r.loopMu.Lock()
-	if _, ok := r.loops[taskID]; ok {
+	if _, ok := r.loops[taskID]; ok || r.loopHalted(taskID) {
 		r.loopMu.Unlock()
 		return
 	}
@@ -397,7 +601,7 @@ func (r *Runtime) EnsureAgentLoop(taskID string) {
 	r.loopMu.Unlock()
 
 	go func() {
-		_ = r.Run(loopCtx, taskID)
+		r.superviseAgentLoop(loopCtx, taskID)
 		r.loopMu.Lock()
 		delete(r.loops, taskID)
 		r.loopMu.Unlock()
@@ -418,13 +622,24 @@ func (r *Runtime) ensureTaskConfig(taskID string) *taskConfig {
 	return cfg
 }
 
-func (r *Runtime) ensureAgentLLM(cfg *taskConfig) (*llms.LLM, error) {
+func (r *Runtime) ensureAgentLLM(cfg *taskConfig, toolset Toolset) (*llms.LLM, error) {
 	if r.LLMFactory != nil {
 		return r.LLMFactory()
 	}
 	if r.LLM != nil {
-		if cfg != nil && cfg.Model != "" {
-			if llm, err := r.LLM.NewSessionWithModel(cfg.Model); err == nil {
+		opts := ai.SessionOptions{ToolFilter: toolset.toolFilter()}
+		if cfg != nil {
+			cfg.mu.Lock()
+			opts.Model, opts.ProviderTools = cfg.Model, cfg.ProviderTools
+			cfg.mu.Unlock()
+		}
+		if opts.ToolFilter != nil {
+			// Falling back to a session with every tool would bypass the
+			// agent's toolset.
+			return r.LLM.NewSessionWithOptions(opts)
+		}
+		if opts.Model != "" || opts.ProviderTools != nil {
+			if llm, err := r.LLM.NewSessionWithOptions(opts); err == nil {
 				return llm, nil
 			}
 		}
@@ -442,7 +657,9 @@ func (r *Runtime) ensureAgentLLM(cfg *taskConfig) (*llms.LLM, error) {
 func (r *Runtime) SetPromptTools(toolNames []string) {
 	normalized := normalizePromptToolNames(toolNames)
 	if r.Context != nil {
+		r.promptToolsMu.Lock()
 		r.Context.ToolNames = append([]string{}, normalized...)
+		r.promptToolsMu.Unlock()
 	}
 }
 
@@ -493,6 +710,35 @@ func (r *Runtime) SetAgentModel(taskID, model string) {
 	cfg.mu.Unlock()
 }
 
+// SetAgentProviderTools enables provider-native tools (e.g. web_search) for
+// an agent, replacing the client default.
+func (r *Runtime) SetAgentProviderTools(taskID string, names []string) {
+	if taskID == "" {
+		return
+	}
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return
+	}
+	cfg.mu.Lock()
+	cfg.ProviderTools = append([]string{}, names...)
+	cfg.mu.Unlock()
+}
+
+// AgentConfig returns the system prompt addition and model override set for
+// an agent.
+func (r *Runtime) AgentConfig(taskID string) (system, model string) {
+	r.configMu.RLock()
+	cfg, ok := r.taskConfigs[taskID]
+	r.configMu.RUnlock()
+	if !ok || cfg == nil {
+		return "", ""
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.System, cfg.Model
+}
+
 func (r *Runtime) EnsureRootTask(ctx context.Context, taskID string) (tasks.Task, error) {
 	if r.Tasks == nil {
 		return tasks.Task{}, fmt.Errorf("task manager unavailable")
@@ -523,17 +769,61 @@ func (r *Runtime) ensureRootTask(ctx context.Context, taskID string) (tasks.Task
 	return tasks.Task{}, fmt.Errorf("agent task %q not found — create it first", taskID)
 }
 
-func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message string, messageMeta map[string]any) (Session, error) {
+func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message string, messageMeta map[string]any) (session Session, err error) {
+	// A message sent within a trace continues it.
+	ctx, span := tracing.Start(tracing.FromMetadata(ctx, messageMeta), "agent.HandleMessage",
+		attribute.String("agent.id", agentID),
+		attribute.String("message.source", source),
+		attribute.String("event.id", schema.GetMetaString(messageMeta, "event_id")))
+	defer func() {
+		span.SetAttributes(attribute.String("llm_task.id", session.LLMTaskID))
+		tracing.End(span, err)
+	}()
+	// A panicking turn fails its llm task and drops its cancellation, then
+	// panics on so the agent loop's supervisor counts the crash.
+	guard := &turnGuard{}
+	ctx = withTurnGuard(ctx, guard)
+	defer func() {
+		if p := recover(); p != nil {
+			lp := asLoopPanic(p)
+			err = lp
+			r.recordTurnPanic(agentID, guard.llmTaskID, lp)
+			panic(lp)
+		}
+	}()
+	session, err = r.handleMessage(ctx, agentID, source, message, messageMeta)
+	if errors.Is(err, ErrBudgetExhausted) {
+		// The paused message runs again once the budget allows.
+		return session, err
+	}
+	// The turn that handled a team assignment finishes it, freeing the
+	// worker for the next one.
+	r.finishTeamAssignment(agentcontext.WithTaskID(context.Background(), agentID), agentID, messageMeta, session, err)
+	return session, err
+}
+
+func (r *Runtime) handleMessage(ctx context.Context, agentID, source, message string, messageMeta map[string]any) (Session, error) {
 	if agentID == "" {
 		return Session{}, fmt.Errorf("task_id is required")
 	}
-	ctx = agentcontext.WithTaskID(ctx, agentID)
+	r.activeTurns.Add(1)
+	defer r.activeTurns.Add(-1)
+	ctx = r.withAgentNamespace(agentcontext.WithTaskID(ctx, agentID), agentID)
 	bgCtx := agentcontext.WithTaskID(context.Background(), agentID)
+	bgCtx = agentcontext.WithNamespace(bgCtx, agentcontext.NamespaceFromContext(ctx))
+	if lane := turnLaneFromContext(ctx); lane > 0 {
+		bgCtx = withTurnLane(bgCtx, lane)
+	}
+	bgCtx = trace.ContextWithSpan(bgCtx, trace.SpanFromContext(ctx))
 	cfg := r.ensureTaskConfig(agentID)
+	exhausted := r.checkBudget(ctx, agentID)
+	if exhausted != nil && exhausted.Action != budget.Fail {
+		return Session{}, fmt.Errorf("%w: %s", ErrBudgetExhausted, exhausted)
+	}
 	currentGeneration := r.historyGeneration(ctx, agentID)
 
 	var promptContent content.Content
-	var promptText string
+	var promptText, basePromptText string
 	if r.Context != nil {
 		prompt, text, err := r.Context.BuildSystemPrompt(ctx, r.Bus)
 		if err != nil {
@@ -541,6 +831,7 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		}
 		promptContent = prompt
 		promptText = text
+		basePromptText = text
 	} else {
 		return Session{}, fmt.Errorf("prompt unavailable")
 	}
@@ -553,11 +844,40 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 	// this generation. Using the stored prompt across all turns of a
 	// generation keeps the provider's prompt-cache key stable.
 	storedPrompt, priorMessages, _ := r.loadConversationMessages(ctx, agentID, currentGeneration)
+	override := turnModelOverride(messageMeta)
+	budgetPrompt := storedPrompt
+	if budgetPrompt == "" {
+		budgetPrompt = promptText
+	}
+	if next, ok := r.enforceContextBudget(ctx, agentID, currentGeneration, r.turnModel(cfg, override), budgetPrompt, priorMessages); ok {
+		currentGeneration = next
+		storedPrompt, priorMessages, _ = r.loadConversationMessages(ctx, agentID, currentGeneration)
+	}
 	if storedPrompt != "" {
 		promptText = storedPrompt
 		promptContent = content.FromText(storedPrompt)
+	} else if summary := r.conversationSummary(agentID, currentGeneration); summary != "" {
+		// The first turn after a compaction records the summary as part of
+		// the generation's stored prompt.
+		promptText = withContextSummary(promptText, summary)
+		promptContent = content.FromText(promptText)
+	}
+	// Memories relevant to the message follow the stored prompt, so they
+	// change with each message without changing what the generation stores.
+	if section := r.Context.MemoryPrompt(ctx, agentID, message); section != "" {
+		promptContent = content.FromText(promptText + "\n\n" + section)
+	}
+	// A turn that checkpointed before the agent went down resumes from its
+	// last checkpoint when its message is delivered again.
+	checkpoints := &turnCheckpoints{every: r.checkpointEvery(), eventID: schema.GetMetaString(messageMeta, "event_id")}
+	resume, resuming := r.resumeTurn(ctx, agentID, currentGeneration, checkpoints.eventID)
+	var resumedMessages []llms.Message
+	if resuming {
+		resumedMessages = resume.Messages
+		priorMessages = append(resume.Prior, resume.Messages...)
+		checkpoints.saved = len(resume.Messages)
+		checkpoints.rounds = resume.Rounds
 	}
-
 	var rootTask tasks.Task
 	var llmTask tasks.Task
 	taskID := agentID
@@ -567,26 +887,44 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 			taskID = rootTask.ID
 		}
 
+		llmMeta := map[string]any{
+			"input_target":       taskID,
+			"notify_target":      taskID,
+			"source":             source,
+			"priority":           eventPriority(messageMeta),
+			"request_id":         schema.GetMetaString(messageMeta, "request_id"),
+			"service_id":         schema.GetMetaString(messageMeta, "service_id"),
+			"event_id":           schema.GetMetaString(messageMeta, "event_id"),
+			"history_generation": currentGeneration,
+		}
+		if lane := turnLaneFromContext(ctx); lane > 0 {
+			llmMeta["lane"] = lane
+		}
+		if resuming {
+			llmMeta["resumed_from"] = resume.TaskID
+		}
+		if override.Model != "" {
+			llmMeta[schema.MetaModelOverride] = override.Model
+			if override.Provider != "" {
+				llmMeta[schema.MetaProviderOverride] = override.Provider
+			}
+		}
 		llmTask, _ = r.Tasks.Spawn(ctx, tasks.Spec{
 			Type:     "llm",
 			Owner:    taskID,
 			ParentID: rootTask.ID,
 			Mode:     "sync",
-			Metadata: map[string]any{
-				"input_target":       taskID,
-				"notify_target":      taskID,
-				"source":             source,
-				"priority":           eventPriority(messageMeta),
-				"request_id":         schema.GetMetaString(messageMeta, "request_id"),
-				"service_id":         schema.GetMetaString(messageMeta, "service_id"),
-				"event_id":           schema.GetMetaString(messageMeta, "event_id"),
-				"history_generation": currentGeneration,
-			},
+			Metadata: llmMeta,
 		})
 		_ = r.Tasks.MarkRunning(ctx, llmTask.ID)
 		_ = r.Tasks.Send(ctx, llmTask.ID, map[string]any{"message": message})
+		if guard := turnGuardFromContext(ctx); guard != nil {
+			guard.llmTaskID = llmTask.ID
+		}
 	}
 
+	// The agent's toolset applies to this turn's session and tools snapshot.
+	toolset := ToolsetFromMetadata(rootTask.Metadata)
 	session := Session{
 		TaskID:    taskID,
 		LLMTaskID: llmTask.ID,
@@ -594,9 +932,14 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		LastInput: message,
 		UpdatedAt: r.now(),
 	}
+	historyBudget := newHistoryTurnBudget(agentID, r.AgentHistoryBudget(agentID))
+	ctx = withHistoryBudget(ctx, historyBudget)
+	defer func() { r.flushHistoryBudget(ctx, historyBudget, llmTask.ID, currentGeneration) }()
 	turnCtx := r.nextTurnContext(agentID, session.UpdatedAt)
 	rawContextEvents, _ := r.collectUnreadContextEvents(ctx, agentID, maxContextEventsPerTurn*2)
 	turnRouting := buildTurnRouting(source, messageMeta, rawContextEvents)
+	messageMeta = r.answerQuestion(ctx, agentID, source, message, messageMeta)
+	ctx = withTurnRoute(ctx, turnRoute{source: source, messageMeta: messageMeta, routing: turnRouting})
 	contextEvents, initialSuperseded := projectContextEventsForPrompt(rawContextEvents, maxContextEventsPerTurn)
 	currentContextCursor := r.contextCursor(agentID)
 	initialFrame := ContextUpdateFrame{
@@ -606,13 +949,17 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		Scanned:     len(rawContextEvents),
 		Emitted:     len(contextEvents),
 		Superseded:  initialSuperseded,
+		MessageGaps: r.observeMessageSequences(agentID, contextEvents),
+		Refs:        r.assignContextRefs(ctx, agentID, contextEvents),
 	}
 	if initialFrame.ToEventID != "" {
 		currentContextCursor = initialFrame.ToEventID
 	}
 	toolsSnapshot := []string{}
-	if r.Context != nil && len(r.Context.ToolNames) > 0 {
-		toolsSnapshot = append(toolsSnapshot, r.Context.ToolNames...)
+	if r.Context != nil {
+		r.promptToolsMu.RLock()
+		toolsSnapshot = append(toolsSnapshot, toolset.filter(r.Context.ToolNames)...)
+		r.promptToolsMu.RUnlock()
 		sort.Strings(toolsSnapshot)
 	}
 	if r.shouldAppendGenerationPreamble(ctx, agentID, currentGeneration) {
@@ -622,13 +969,17 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		r.appendHistory(ctx, agentID, "system_prompt", "system", promptText, llmTask.ID, currentGeneration, nil)
 	}
 	if strings.TrimSpace(message) != "" {
-		r.appendHistory(ctx, agentID, "user_message", "user", message, llmTask.ID, currentGeneration, map[string]any{
+		data := map[string]any{
 			"source":     source,
 			"priority":   eventPriority(messageMeta),
 			"request_id": schema.GetMetaString(messageMeta, "request_id"),
 			"service_id": schema.GetMetaString(messageMeta, "service_id"),
 			"event_id":   schema.GetMetaString(messageMeta, "event_id"),
-		})
+		}
+		if q, ok := messageMeta[metaAnsweredQuestion].(questions.Question); ok {
+			data["question_id"] = q.ID
+		}
+		r.appendHistory(ctx, agentID, "user_message", "user", message, llmTask.ID, currentGeneration, data)
 	} else if strings.EqualFold(schema.GetMetaString(messageMeta, "kind"), "wake") {
 		r.appendHistory(ctx, agentID, "wake", "system", "wake turn", llmTask.ID, currentGeneration, map[string]any{
 			"stream":   schema.GetMetaString(messageMeta, "stream"),
@@ -636,7 +987,11 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 			"priority": eventPriority(messageMeta),
 		})
 	}
-	r.appendContextUpdateHistory(ctx, agentID, llmTask.ID, currentGeneration, turnCtx, contextEvents)
+	r.appendContextUpdateHistory(ctx, agentID, llmTask.ID, currentGeneration, turnCtx, initialFrame)
+
+	progress := r.newProgressReporter(agentID, llmTask.ID)
+	progress.report(ctx, ProgressTurnStarted)
+	defer progress.report(bgCtx, ProgressTurnFinished)
 
 	if r.Bus != nil {
 		_, _ = r.Bus.Push(ctx, eventbus.EventInput{
@@ -652,9 +1007,18 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		})
 	}
 
-	llmClient, err := r.ensureAgentLLM(cfg)
-	if err != nil || llmClient == nil {
+	var llmClient *llms.LLM
+	var err error
+	if exhausted == nil {
+		llmClient, err = r.turnLLM(cfg, override, toolset)
+	}
+	if exhausted != nil || err != nil || llmClient == nil {
 		session.LastError = "LLM not configured. Set the provider API key (e.g. GO_AGENTS_ANTHROPIC_API_KEY) and configure llm_provider/llm_model in config.json."
+		if exhausted != nil {
+			session.LastError = "Budget exhausted: " + exhausted.String() + "."
+		} else if override.Model != "" && err != nil {
+			session.LastError = fmt.Sprintf("Model override %s failed: %v", override, err)
+		}
 		session.LastOutput = session.LastError
 		r.SetSession(session)
 		r.appendHistory(ctx, agentID, "assistant_message", "assistant", session.LastOutput, llmTask.ID, currentGeneration, map[string]any{
@@ -669,22 +1033,33 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 				assistantOutputUpdateOptions(source, turnRouting),
 			)
 		}
-		if r.Tasks != nil {
-			if llmTask.ID != "" {
+		if r.Tasks != nil && llmTask.ID != "" {
+			if exhausted != nil {
+				_ = r.Tasks.ExceedLimit(bgCtx, llmTask.ID, tasks.LimitBudget, session.LastError, map[string]any{
+					"window": exhausted.Window,
+					"unit":   exhausted.Unit,
+					"used":   exhausted.Used,
+					"limit":  exhausted.Limit,
+				})
+			} else {
 				_ = r.Tasks.Fail(bgCtx, llmTask.ID, session.LastError)
 			}
 		}
 		replyTarget := responseRoutingTarget(source, agentID)
 		if replyTarget != "" {
-			replyMeta := responseRoutingMetadata(turnRouting)
+			replyMeta := withThreadReply(responseRoutingMetadata(turnRouting), messageMeta)
 			_, _ = r.SendMessageWithMeta(ctx, replyTarget, session.LastOutput, agentID, replyMeta)
 		}
 		r.ackContextEvents(bgCtx, agentID, rawContextEvents)
 		return session, nil
 	}
-	r.attachDebugger(llmClient, agentID, llmTask.ID)
+	reproDebug := r.attachDebugger(llmClient, agentID, llmTask.ID)
+	usageBefore := llmClient.TotalUsage
 
-	var output string
+	var output, delivered string
+	// turnImages are the images the model generated this turn; attachments
+	// are the non-text parts of the reply.
+	var turnImages, attachments []schema.Part
 	trackedContextEvents := make([]eventbus.Event, 0, len(rawContextEvents))
 	trackedContextEventKeys := map[string]struct{}{}
 	contextEventKey := func(evt eventbus.Event) string {
@@ -730,6 +1105,10 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 	{
 		llmCtx := tasks.WithParentTaskID(ctx, llmTask.ID)
 		llmCtx = tasks.WithIgnoredWakeEventIDs(llmCtx, ignoredWakeEventIDsForTurn(messageMeta, rawContextEvents))
+		llmCtx = tasks.WithInheritedPriority(llmCtx, inheritedTurnPriority(messageMeta))
+		waits := &providerWaits{}
+		outcome := &turnOutcome{}
+		llmCtx = governor.WithWaitObserver(llmCtx, waits.observe)
 		llmCtx, cancel := context.WithCancel(llmCtx)
 		r.registerInflight(llmTask.ID, cancel)
 		defer func() {
@@ -740,8 +1119,12 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		if r.Bus != nil {
 			interruptCtx, interruptCancel := context.WithCancel(ctx)
 			defer interruptCancel()
-			go r.watchInterrupts(interruptCtx, agentID, llmTask.ID, cancel)
-			go r.watchTaskCommands(interruptCtx, llmTask.ID, cancel)
+			// Subscribe before the LLM call starts so no interrupt slips
+			// between the call and the watchers coming up.
+			interruptSub := r.Bus.Subscribe(interruptCtx, schema.AgentStreams)
+			commandSub := r.Bus.Subscribe(interruptCtx, []string{schema.StreamSignals})
+			go r.watchInterrupts(interruptCtx, interruptSub, agentID, llmTask.ID, cancel)
+			go r.watchTaskCommands(interruptCtx, commandSub, llmTask.ID, cancel)
 		}
 
 		prev := llmClient.SystemPrompt
@@ -751,6 +1134,27 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		}()
 
 		input := buildInputWithHistory(source, message, messageMeta, turnCtx, initialFrame)
+		input = withPinnedFacts(input, r.pinnedFacts(ctx, agentID))
+		input = withReferenceDocuments(input, r.retrieveReferenceDocuments(ctx, agentID, message))
+		if resuming {
+			// The original input opens the saved messages.
+			input = resumeNote
+			r.appendHistory(ctx, agentID, "turn_resumed", "system", fmt.Sprintf("resumed after %d tool rounds", resume.Rounds), llmTask.ID, currentGeneration, map[string]any{
+				"event_id":     checkpoints.eventID,
+				"rounds":       resume.Rounds,
+				"resumed_from": resume.TaskID,
+			})
+			r.recordTaskUpdate(ctx, llmTask.ID, "turn_resumed", map[string]any{
+				"event_id":     checkpoints.eventID,
+				"rounds":       resume.Rounds,
+				"messages":     len(resume.Messages),
+				"resumed_from": resume.TaskID,
+			}, tasks.UpdateOptions{})
+		}
+		shadowGen := r.startShadowGeneration(ctx, agentID, llmTask.ID, basePromptText, promptText, cfg, priorMessages, input)
+		defer func() {
+			shadowGen.recordPrimary(bgCtx, session.LastOutput, session.LastError)
+		}()
 		runSource := source
 		if strings.TrimSpace(runSource) == "" {
 			runSource = "external"
@@ -760,8 +1164,12 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		lastLLMTurn := 1
 		publishedAssistantTurns := map[int]struct{}{}
 		publishedAssistantPrefix := ""
+		turnArtifacts := r.newTurnArtifacts(agentID, llmTask.ID)
+		// unpublishedImages are generated images not yet attached to a
+		// history entry.
+		var unpublishedImages []schema.Part
 		publishAssistantTurn := func(turn int, text string, partial bool) {
-			if turn <= 0 || strings.TrimSpace(text) == "" {
+			if turn <= 0 || (strings.TrimSpace(text) == "" && len(unpublishedImages) == 0) {
 				return
 			}
 			if _, ok := publishedAssistantTurns[turn]; ok {
@@ -773,10 +1181,31 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 			if partial {
 				data["partial"] = true
 			}
-			r.appendHistory(llmCtx, agentID, "assistant_message", "assistant", text, llmTask.ID, currentGeneration, data)
+			if len(unpublishedImages) > 0 {
+				data[schema.MetaParts] = unpublishedImages
+				unpublishedImages = nil
+			}
+			if citations := r.resolveCitations(llmCtx, agentID, text); len(citations) > 0 {
+				data["citations"] = citations
+			}
+			inline, artifact := turnArtifacts.offload(llmCtx, text)
+			if artifact != nil {
+				data["artifact_id"] = artifact.ID
+				data["original_chars"] = artifact.Chars
+			}
+			r.appendHistory(llmCtx, agentID, "assistant_message", "assistant", inline, llmTask.ID, currentGeneration, data)
 			publishedAssistantTurns[turn] = struct{}{}
 			publishedAssistantPrefix += text
 		}
+		loopWatchdog := newToolLoopWatchdog(r.ToolLoopLimit)
+		// Each provider request of the turn gets a span, ended when the
+		// next one starts or the turn ends.
+		var requestSpan trace.Span
+		defer func() {
+			if requestSpan != nil {
+				tracing.End(requestSpan, llmClient.Err())
+			}
+		}()
 		prevBeforeResponse := llmClient.BeforeResponse
 		llmClient.BeforeResponse = func(hookCtx context.Context, before llms.BeforeResponseState) error {
 			if prevBeforeResponse != nil {
@@ -790,6 +1219,10 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 				turnNumber = 1
 			}
 			lastLLMTurn = turnNumber
+			if requestSpan != nil {
+				requestSpan.End()
+			}
+			_, requestSpan = tracing.Start(llmCtx, "llm.request", attribute.Int("llm.turn", turnNumber))
 			if turnNumber > 1 {
 				// Skip prior conversation history so we only capture
 				// assistant text from the current HandleMessage call.
@@ -798,6 +1231,18 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 					currentMsgs = currentMsgs[n:]
 				}
 				publishAssistantTurn(turnNumber-1, latestAssistantText(currentMsgs), false)
+				checkpoints.base = max(len(priorMessages)-len(resumedMessages), 0)
+				checkpoints.checkpoint(hookCtx, r, agentID, llmTask.ID, currentGeneration, turnNumber-1, before.Messages())
+				if loopWatchdog != nil {
+					loopWatchdog.waitFor(hookCtx, countToolMessages(currentMsgs))
+					if verdict, ok := loopWatchdog.check(); ok {
+						r.recordToolLoop(hookCtx, agentID, llmTask.ID, currentGeneration, verdict)
+						if verdict.action == "stop" {
+							return fmt.Errorf("%w: %s kept repeating after a nudge", errToolLoop, verdict.tool)
+						}
+						before.Append(llms.Message{Role: "user", Content: content.FromText(verdict.nudge())})
+					}
+				}
 			}
 
 			turnInput := ""
@@ -820,11 +1265,12 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 				snapshot := TurnContext{
 					Now:      now,
 					Previous: lastContextSnapshotAt,
+					Location: r.agentLocation(agentID),
 				}
 				if !lastContextSnapshotAt.IsZero() {
 					snapshot.Elapsed = now.Sub(lastContextSnapshotAt)
 					snapshot.TimePassed = snapshot.Elapsed >= minTimePassedDelta
-					snapshot.DateChanged = lastContextSnapshotAt.UTC().Format("2006-01-02") != now.Format("2006-01-02")
+					snapshot.DateChanged = dateChanged(lastContextSnapshotAt, now, snapshot.Location)
 				}
 				lastContextSnapshotAt = now
 
@@ -841,6 +1287,8 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 					Scanned:     len(freshRaw),
 					Emitted:     len(fresh),
 					Superseded:  superseded,
+					MessageGaps: r.observeMessageSequences(agentID, fresh),
+					Refs:        r.assignContextRefs(hookCtx, agentID, fresh),
 				}
 				if len(freshRaw) > 0 {
 					markTrackedContextEvents(freshRaw)
@@ -849,7 +1297,7 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 					currentContextCursor = frame.ToEventID
 				}
 				if len(fresh) > 0 || snapshot.DateChanged {
-					r.appendContextUpdateHistory(hookCtx, agentID, llmTask.ID, currentGeneration, snapshot, fresh)
+					r.appendContextUpdateHistory(hookCtx, agentID, llmTask.ID, currentGeneration, snapshot, frame)
 					turnInput = buildInputWithHistory("runtime", "", map[string]any{"priority": "normal"}, snapshot, frame)
 					before.Append(llms.Message{Role: "user", Content: content.FromText(turnInput)})
 					turnSource = "runtime"
@@ -891,23 +1339,22 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 			llmClient.BeforeResponse = prevBeforeResponse
 		}()
 
-		allMessages := make([]llms.Message, 0, len(priorMessages)+1)
-		allMessages = append(allMessages, priorMessages...)
-		allMessages = append(allMessages, llms.Message{
+		updates := r.chatWithContextRetry(llmCtx, llmClient, agentID, llmTask.ID, currentGeneration, &priorMessages, llms.Message{
 			Role:    "user",
 			Content: content.FromText(input),
 		})
-		updates := llmClient.ChatUsingMessages(llmCtx, allMessages)
 		toolInputRaw := map[string]string{}
 		toolStreamingMarked := map[string]bool{}
 		for update := range updates {
 			switch u := update.(type) {
 			case llms.TextUpdate:
+				progress.report(llmCtx, ProgressResponding)
 				output += u.Text
 				r.recordLLMUpdate(llmCtx, llmTask.ID, "llm_text", map[string]any{"text": u.Text})
 			case llms.MessageStartUpdate:
 				r.recordLLMUpdate(llmCtx, llmTask.ID, "llm_message_start", map[string]any{"message_id": u.MessageID})
 			case llms.ThinkingUpdate:
+				progress.report(llmCtx, ProgressThinking)
 				r.recordLLMUpdate(llmCtx, llmTask.ID, "llm_thinking", map[string]any{
 					"id":      u.ID,
 					"text":    u.Text,
@@ -927,6 +1374,7 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 				if pendingText := strings.TrimPrefix(output, publishedAssistantPrefix); strings.TrimSpace(pendingText) != "" {
 					publishAssistantTurn(lastLLMTurn, pendingText, true)
 				}
+				progress.reportTool(llmCtx, ProgressRunningTool, u.Tool.FuncName())
 				r.recordLLMUpdate(llmCtx, llmTask.ID, "llm_tool_start", map[string]any{
 					"tool_call_id": u.ToolCallID,
 					"tool_name":    u.Tool.FuncName(),
@@ -970,7 +1418,7 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 					}
 				}
 				if u.Result != nil {
-					payload["result"] = summarizeToolResult(u.Result)
+					payload["result"] = summarizeToolResult(u.Result, r.toolSummaryBudget(u.Tool.FuncName()))
 				}
 				if u.Metadata != nil {
 					payload["metadata"] = u.Metadata
@@ -981,13 +1429,25 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 					toolStatus = "failed"
 				}
 				r.appendToolHistory(llmCtx, agentID, llmTask.ID, "tool_result", u.ToolCallID, u.Tool.FuncName(), toolStatus, "", payload)
+				loopWatchdog.observe(u.Tool.FuncName(), toolInputRaw[u.ToolCallID], toolStatus == "failed")
+				outcome.observe(u.Tool.FuncName(), toolStatus == "failed")
+				// The model reads the tool result before it produces more output.
+				progress.report(llmCtx, ProgressThinking)
 			case llms.ImageUpdate:
 				r.recordLLMUpdate(llmCtx, llmTask.ID, "llm_image", map[string]any{
 					"url":       u.URL,
 					"mime_type": u.MimeType,
 				})
+				image := schema.Part{Type: schema.PartImage, URL: u.URL, MimeType: u.MimeType, Name: fmt.Sprintf("image-%d", len(turnImages)+1)}
+				turnImages = append(turnImages, image)
+				unpublishedImages = append(unpublishedImages, image)
 			}
 		}
+		r.recordRepro(bgCtx, agentID, llmTask.ID, currentGeneration, reproDebug)
+		r.recordUsage(bgCtx, agentID, llmTask.ID, r.turnModel(cfg, override), usageBefore, llmClient.TotalUsage)
+		r.recordProviderWaits(bgCtx, llmTask.ID, waits)
+		r.recordTurnOutcome(bgCtx, agentID, llmTask.ID, r.turnModel(cfg, override), message, output, llmClient.Err(), outcome)
+		r.publishTurnComplete(agentID, llmTask.ID, llmClient.Err())
 		if err := llmClient.Err(); err != nil {
 			session.LastError = err.Error()
 			session.LastOutput = output
@@ -999,6 +1459,7 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 			if r.Tasks != nil {
 				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 					if llmTask.ID != "" {
+						r.settleInterruptedTurnTasks(bgCtx, agentID, llmTask.ID)
 						_ = r.Tasks.Cancel(bgCtx, llmTask.ID, "interrupted")
 					}
 				} else {
@@ -1031,15 +1492,15 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 				} else {
 					reply = fmt.Sprintf("%s\n\n[error] %s", reply, session.LastError)
 				}
-				replyMeta := responseRoutingMetadata(turnRouting)
-				_, _ = r.SendMessageWithMeta(ctx, replyTarget, reply, agentID, replyMeta)
+				replyMeta := withThreadReply(responseRoutingMetadata(turnRouting), messageMeta)
+				_, _ = r.SendMessageWithMeta(ctx, replyTarget, schema.TextWithAttachments(reply, turnImages), agentID, withReplyParts(replyMeta, reply, turnImages))
 			}
-			if rootTask.ID != "" && strings.TrimSpace(output) != "" {
+			if rootTask.ID != "" && (strings.TrimSpace(output) != "" || len(turnImages) > 0) {
 				r.recordTaskUpdate(
 					ctx,
 					rootTask.ID,
 					"assistant_output",
-					assistantOutputPayload(output, turnRouting),
+					withOutputParts(assistantOutputPayload(schema.TextWithAttachments(output, turnImages), turnRouting), output, turnImages),
 					assistantOutputUpdateOptions(source, turnRouting),
 				)
 			}
@@ -1048,29 +1509,38 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		remainder := output
 		remainder = strings.TrimPrefix(remainder, publishedAssistantPrefix)
 		publishAssistantTurn(lastLLMTurn, remainder, false)
+		// Oversized output leaves the turn as a summary and reference.
+		var artifact *artifacts.Artifact
+		delivered, artifact = turnArtifacts.offload(bgCtx, output)
+		// The artifact reference is already part of delivered, so only the
+		// images need a text rendering.
+		attachments = turnImages
+		if artifact != nil {
+			attachments = append(slices.Clone(turnImages), schema.Part{Type: schema.PartFile, ArtifactID: artifact.ID, Name: "reply.md", MimeType: "text/markdown"})
+		}
 	}
 
 	r.ackContextEvents(context.Background(), agentID, trackedContextEvents)
 	session.LastOutput = output
 	r.SetSession(session)
-	if rootTask.ID != "" && strings.TrimSpace(output) != "" {
+	if rootTask.ID != "" && (strings.TrimSpace(delivered) != "" || len(attachments) > 0) {
 		r.recordTaskUpdate(
 			ctx,
 			rootTask.ID,
 			"assistant_output",
-			assistantOutputPayload(output, turnRouting),
+			withOutputParts(assistantOutputPayload(schema.TextWithAttachments(delivered, turnImages), turnRouting), delivered, attachments),
 			assistantOutputUpdateOptions(source, turnRouting),
 		)
 	}
 	if r.Tasks != nil {
 		if llmTask.ID != "" {
-			_ = r.Tasks.Complete(bgCtx, llmTask.ID, map[string]any{"output": output})
+			_ = r.Tasks.Complete(bgCtx, llmTask.ID, map[string]any{"output": delivered})
 		}
 		// Complete the root agent task so that await_task callers see
 		// a terminal status and receive the output.  For long-running
 		// agents this is a no-op after the first turn (already completed).
 		if rootTask.ID != "" {
-			_ = r.Tasks.Complete(bgCtx, rootTask.ID, map[string]any{"output": output})
+			_ = r.Tasks.Complete(bgCtx, rootTask.ID, map[string]any{"output": delivered})
 		}
 	}
 	if r.Bus != nil {
@@ -1087,10 +1557,11 @@ func (r *Runtime) HandleMessage(ctx context.Context, agentID, source, message st
 		})
 	}
 	replyTarget := responseRoutingTarget(source, agentID)
-	if replyTarget != "" && strings.TrimSpace(output) != "" {
-		replyMeta := responseRoutingMetadata(turnRouting)
-		_, _ = r.SendMessageWithMeta(ctx, replyTarget, output, agentID, replyMeta)
+	if replyTarget != "" && (strings.TrimSpace(delivered) != "" || len(attachments) > 0) {
+		replyMeta := withThreadReply(responseRoutingMetadata(turnRouting), messageMeta)
+		_, _ = r.SendMessageWithMeta(ctx, replyTarget, schema.TextWithAttachments(delivered, turnImages), agentID, withReplyParts(replyMeta, delivered, attachments))
 	}
+	r.extractFacts(agentID, llmTask.ID, message, output)
 	return session, nil
 }
 
@@ -1237,6 +1708,38 @@ func assistantOutputPayload(text string, routing turnRouting) map[string]any {
 	return payload
 }
 
+// withOutputParts adds the parts of a reply with attachments to an
+// assistant_output payload, whose "text" already renders the attachments
+// for text-only channels. Text-only replies keep just "text".
+func withOutputParts(payload map[string]any, text string, attachments []schema.Part) map[string]any {
+	if len(attachments) == 0 {
+		return payload
+	}
+	payload[schema.MetaParts] = replyParts(text, attachments)
+	return payload
+}
+
+// withReplyParts adds the parts of a reply with attachments to the metadata
+// of the message that delivers it to another agent.
+func withReplyParts(meta map[string]any, text string, attachments []schema.Part) map[string]any {
+	if len(attachments) == 0 {
+		return meta
+	}
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta[schema.MetaParts] = replyParts(text, attachments)
+	return meta
+}
+
+func replyParts(text string, attachments []schema.Part) []schema.Part {
+	parts := make([]schema.Part, 0, len(attachments)+1)
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, schema.Part{Type: schema.PartText, Text: text})
+	}
+	return append(parts, attachments...)
+}
+
 func routingRoutesPayload(routes []routingCandidate) []map[string]any {
 	if len(routes) == 0 {
 		return nil
@@ -1307,6 +1810,7 @@ func assistantOutputUpdateOptions(source string, routing turnRouting) tasks.Upda
 }
 
 func (r *Runtime) recordLLMUpdate(ctx context.Context, taskID, kind string, payload map[string]any) {
+	r.publishActivity(ctx, taskID, kind, payload)
 	r.recordTaskUpdate(ctx, taskID, kind, payload, tasks.UpdateOptions{})
 }
 
@@ -1314,6 +1818,9 @@ func (r *Runtime) recordTaskUpdate(ctx context.Context, taskID, kind string, pay
 	if r.Tasks == nil || taskID == "" {
 		return
 	}
+	if kind == "assistant_output" && r.holdForSnooze(ctx, taskID, payload, opts) {
+		return
+	}
 	var err error
 	for attempt := 0; attempt < 3; attempt++ {
 		if len(opts.EventMetadata) == 0 {
@@ -1325,6 +1832,7 @@ func (r *Runtime) recordTaskUpdate(ctx context.Context, taskID, kind string, pay
 			return
 		}
 		if strings.Contains(err.Error(), "database is locked") {
+			state.DefaultTelemetry.RecordBusyRetry()
 			time.Sleep(25 * time.Millisecond)
 			continue
 		}
@@ -1346,7 +1854,7 @@ func (r *Runtime) recordTaskUpdate(ctx context.Context, taskID, kind string, pay
 	}
 }
 
-func summarizeToolResult(result llmtools.Result) map[string]any {
+func summarizeToolResult(result llmtools.Result, budget int) map[string]any {
 	if result == nil {
 		return nil
 	}
@@ -1356,33 +1864,59 @@ func summarizeToolResult(result llmtools.Result) map[string]any {
 	if err := result.Error(); err != nil {
 		out["error"] = err.Error()
 	}
-	out["content"] = summarizeContent(result.Content())
+	out["content"] = summarizeContent(result.Content(), budget)
 	return out
 }
 
-func summarizeContent(items content.Content) []map[string]any {
+// summarizeContent keeps up to budget characters of each content item. JSON,
+// including JSON inside a tool result envelope, is shortened structurally so
+// it stays parseable, and its key fields are lifted out.
+func summarizeContent(items content.Content, budget int) []map[string]any {
 	if len(items) == 0 {
 		return nil
 	}
+	if budget <= 0 {
+		budget = maxToolContentChars
+	}
 	out := make([]map[string]any, 0, len(items))
 	for _, item := range items {
 		switch v := item.(type) {
 		case *content.Text:
 			text := v.Text
-			truncated := len(text) > maxToolContentChars
-			out = append(out, map[string]any{
+			truncated := len(text) > budget
+			entry := map[string]any{
 				"type":      "text",
-				"text":      clipText(text, maxToolContentChars),
+				"text":      clipText(text, budget),
 				"truncated": truncated,
-			})
+			}
+			if summary, keys, ok := summarizeJSONText(text, budget); ok {
+				entry["text"] = summary
+				if keys != nil {
+					entry["key_fields"] = keys
+				}
+			}
+			if truncated {
+				entry["original_chars"] = len(text)
+			}
+			out = append(out, entry)
 		case *content.JSON:
 			data := string(v.Data)
-			truncated := len(data) > maxToolContentChars
-			out = append(out, map[string]any{
+			truncated := len(data) > budget
+			entry := map[string]any{
 				"type":      "json",
-				"data":      clipText(data, maxToolContentChars),
+				"data":      clipText(data, budget),
 				"truncated": truncated,
-			})
+			}
+			if summary, keys, ok := summarizeJSONText(data, budget); ok {
+				entry["data"] = summary
+				if keys != nil {
+					entry["key_fields"] = keys
+				}
+			}
+			if truncated {
+				entry["original_chars"] = len(data)
+			}
+			out = append(out, entry)
 		case *content.ImageURL:
 			urlValue := strings.TrimSpace(v.URL)
 			display := urlValue
@@ -1393,20 +1927,20 @@ func summarizeContent(items content.Content) []map[string]any {
 					display = "data:<omitted>"
 				}
 			}
-			truncated := len(display) > maxToolContentChars || display != urlValue
+			truncated := len(display) > budget || display != urlValue
 			out = append(out, map[string]any{
 				"type":      "image",
-				"url":       clipText(display, maxToolContentChars),
+				"url":       clipText(display, budget),
 				"mime_type": v.MimeType,
 				"truncated": truncated,
 			})
 		case *content.Thought:
 			text := v.Text
-			truncated := len(text) > maxToolContentChars
+			truncated := len(text) > budget
 			out = append(out, map[string]any{
 				"type":      "thought",
 				"id":        v.ID,
-				"text":      clipText(text, maxToolContentChars),
+				"text":      clipText(text, budget),
 				"summary":   v.Summary,
 				"truncated": truncated,
 			})
@@ -1464,6 +1998,11 @@ func (r *Runtime) clearInflight(taskID string) {
 }
 
 func (r *Runtime) SendMessageWithMeta(ctx context.Context, target, body, source string, metadata map[string]any) (eventbus.Event, error) {
+	return r.SendMessageWithPayload(ctx, target, body, source, metadata, nil)
+}
+
+// SendMessageWithPayload is SendMessageWithMeta with an event payload.
+func (r *Runtime) SendMessageWithPayload(ctx context.Context, target, body, source string, metadata, payload map[string]any) (eventbus.Event, error) {
 	if r.Bus == nil {
 		return eventbus.Event{}, fmt.Errorf("event bus unavailable")
 	}
@@ -1489,6 +2028,15 @@ func (r *Runtime) SendMessageWithMeta(ctx context.Context, target, body, source
 	if _, ok := meta["priority"]; !ok {
 		meta["priority"] = "wake"
 	}
+	// The recipient's turn continues the sender's trace.
+	meta = tracing.Inject(ctx, meta)
+	if _, ok := meta["seq"]; !ok {
+		seq, err := r.Bus.NextSequence(ctx, eventbus.MessageSequenceKey(source, target))
+		if err != nil {
+			return eventbus.Event{}, err
+		}
+		meta["seq"] = seq
+	}
 	return r.Bus.Push(ctx, eventbus.EventInput{
 		Stream:    schema.StreamTaskInput,
 		ScopeType: "task",
@@ -1496,14 +2044,11 @@ func (r *Runtime) SendMessageWithMeta(ctx context.Context, target, body, source
 		Subject:   fmt.Sprintf("Message from %s", source),
 		Body:      message,
 		Metadata:  meta,
+		Payload:   payload,
 	})
 }
 
-func (r *Runtime) watchInterrupts(ctx context.Context, agentID, taskID string, cancel context.CancelFunc) {
-	if r.Bus == nil {
-		return
-	}
-	sub := r.Bus.Subscribe(ctx, schema.AgentStreams)
+func (r *Runtime) watchInterrupts(ctx context.Context, sub <-chan eventbus.Event, agentID, taskID string, cancel context.CancelFunc) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -1519,9 +2064,22 @@ func (r *Runtime) watchInterrupts(ctx context.Context, agentID, taskID string, c
 				continue
 			}
 			if evt.Metadata != nil {
+				// Results of tasks this turn spawned inherit its priority and
+				// must not interrupt the turn itself.
+				if schema.GetMetaString(evt.Metadata, "kind") == "task_update" && schema.GetMetaString(evt.Metadata, "parent_id") == taskID {
+					continue
+				}
 				if priority, ok := evt.Metadata["priority"].(string); ok && strings.EqualFold(priority, "interrupt") {
+					// A parallel turn only yields when no lane is free to
+					// take the interrupt.
+					if turnLaneFromContext(ctx) > 0 && r.busyLanes(agentID) < r.AgentConcurrency(agentID) {
+						continue
+					}
 					cancel()
 					if r.Tasks != nil && taskID != "" {
+						// Settle spawned tasks first, since killing the LLM task
+						// cascades to whatever is still parented to it.
+						r.settleInterruptedTurnTasks(agentcontext.WithTaskID(context.Background(), agentID), agentID, taskID)
 						_ = r.Tasks.Kill(context.Background(), taskID, "interrupt")
 					}
 					return
@@ -1531,11 +2089,10 @@ func (r *Runtime) watchInterrupts(ctx context.Context, agentID, taskID string, c
 	}
 }
 
-func (r *Runtime) watchTaskCommands(ctx context.Context, taskID string, cancel context.CancelFunc) {
-	if r.Bus == nil || taskID == "" {
+func (r *Runtime) watchTaskCommands(ctx context.Context, sub <-chan eventbus.Event, taskID string, cancel context.CancelFunc) {
+	if taskID == "" {
 		return
 	}
-	sub := r.Bus.Subscribe(ctx, []string{schema.StreamSignals})
 	for {
 		select {
 		case <-ctx.Done():
@@ -1614,6 +2171,7 @@ func (r *Runtime) Run(ctx context.Context, agentID string) error {
 	sub := r.Bus.Subscribe(ctx, schema.AgentStreams)
 	replayTicker := time.NewTicker(500 * time.Millisecond)
 	defer replayTicker.Stop()
+	lanes := r.agentLanes(agentID, true)
 
 	for {
 		replayedWake, err := r.replayUnreadWakeEvents(ctx, agentID, maxContextEventsPerTurn*2)
@@ -1629,11 +2187,15 @@ func (r *Runtime) Run(ctx context.Context, agentID string) error {
 			return ctx.Err()
 		case <-replayTicker.C:
 			continue
+		case <-lanes.done:
+			continue
+		case p := <-lanes.crashed:
+			return p
 		case evt, ok := <-sub:
 			if !ok {
 				return ctx.Err()
 			}
-			if !eventTargetsTask(evt, agentID) {
+			if !eventTargetsTask(evt, agentID) && evt.ScopeType != topics.ScopeType {
 				continue
 			}
 			if _, err := r.replayUnreadWakeEvents(ctx, agentID, maxContextEventsPerTurn*2); err != nil && ctx.Err() != nil {
@@ -1644,7 +2206,7 @@ func (r *Runtime) Run(ctx context.Context, agentID string) error {
 }
 
 func (r *Runtime) replayUnreadWakeEvents(ctx context.Context, agentID string, limit int) (int, error) {
-	if r.Bus == nil {
+	if r.Bus == nil || r.draining.Load() {
 		return 0, nil
 	}
 	events, err := r.collectUnreadContextEvents(ctx, agentID, limit)
@@ -1665,27 +2227,29 @@ func (r *Runtime) replayUnreadWakeEvents(ctx context.Context, agentID string, li
 		}
 		return events[i].CreatedAt.Before(events[j].CreatedAt)
 	})
+	orderMessagesBySenderSeq(events)
+	silenced := r.inMaintenance(ctx, agentID)
+	if !silenced && r.deliverMaintenanceDigest(ctx, agentID, events) {
+		return 1, nil
+	}
+	// Lanes still busy after the setting drops back to one drain first.
+	if limit := r.AgentConcurrency(agentID); limit > 1 || r.busyLanes(agentID) > 0 {
+		return r.dispatchTurnLanes(ctx, agentID, events, limit, silenced), nil
+	}
 	for _, evt := range events {
 		priority := eventPriorityForEvent(evt)
 		if priority != "wake" && priority != "interrupt" {
 			continue
 		}
-		meta := map[string]any{
-			"priority": priority,
-			"stream":   evt.Stream,
-			"event_id": evt.ID,
-		}
-		for k, v := range evt.Metadata {
-			meta[k] = v
-		}
-		if _, ok := meta["kind"]; !ok {
-			meta["kind"] = "event"
-		}
-		source := schema.GetMetaString(evt.Metadata, "source")
-		if source == "" {
-			source = "runtime"
+		if silenced && priority == "wake" {
+			r.deferWake(agentID, evt.ID)
+			continue
 		}
-		if _, err := r.HandleMessage(ctx, agentID, source, evt.Body, meta); err != nil {
+		source, meta := wakeTurnMessage(evt)
+		r.setHandlingEvent(agentID, &evt)
+		_, err := r.HandleMessage(ctx, agentID, source, evt.Body, meta)
+		r.setHandlingEvent(agentID, nil)
+		if err != nil {
 			return 0, nil
 		}
 		return 1, nil
@@ -1693,6 +2257,27 @@ func (r *Runtime) replayUnreadWakeEvents(ctx context.Context, agentID string, li
 	return 0, nil
 }
 
+// wakeTurnMessage returns the source and message metadata of the turn that
+// handles the wake or interrupt evt.
+func wakeTurnMessage(evt eventbus.Event) (string, map[string]any) {
+	meta := map[string]any{
+		"priority": eventPriorityForEvent(evt),
+		"stream":   evt.Stream,
+		"event_id": evt.ID,
+	}
+	for k, v := range evt.Metadata {
+		meta[k] = v
+	}
+	if _, ok := meta["kind"]; !ok {
+		meta["kind"] = "event"
+	}
+	source := schema.GetMetaString(evt.Metadata, "source")
+	if source == "" {
+		source = "runtime"
+	}
+	return source, meta
+}
+
 func eventPriority(metadata map[string]any) string {
 	return string(schema.ParsePriority(schema.GetMetaString(metadata, "priority")))
 }
@@ -1710,6 +2295,22 @@ func eventPriorityForEvent(evt eventbus.Event) string {
 	return priority
 }
 
+// EventPriority is the priority the runtime treats evt with: messages are
+// one level more urgent than their stated priority.
+func EventPriority(evt eventbus.Event) schema.Priority {
+	return schema.ParsePriority(eventPriorityForEvent(evt))
+}
+
+// inheritedTurnPriority is the priority tasks spawned during a turn inherit.
+// Only waking priorities are inherited; results already default to wake.
+func inheritedTurnPriority(messageMeta map[string]any) schema.Priority {
+	priority := schema.ParsePriority(eventPriorityForEvent(eventbus.Event{Metadata: messageMeta}))
+	if !priority.Wakes() {
+		return ""
+	}
+	return priority
+}
+
 func (r *Runtime) nextTurnContext(agentID string, now time.Time) TurnContext {
 	if now.IsZero() {
 		now = r.now()
@@ -1723,11 +2324,12 @@ func (r *Runtime) nextTurnContext(agentID string, now time.Time) TurnContext {
 	ctx := TurnContext{
 		Now:      now,
 		Previous: previous,
+		Location: r.agentLocation(agentID),
 	}
 	if !previous.IsZero() {
 		ctx.Elapsed = now.Sub(previous)
 		ctx.TimePassed = ctx.Elapsed >= minTimePassedDelta
-		ctx.DateChanged = previous.UTC().Format("2006-01-02") != now.Format("2006-01-02")
+		ctx.DateChanged = dateChanged(previous, now, ctx.Location)
 	}
 	return ctx
 }
@@ -1737,7 +2339,7 @@ func (r *Runtime) appendContextUpdateHistory(
 	agentID, taskID string,
 	generation int64,
 	turnCtx TurnContext,
-	events []eventbus.Event,
+	frame ContextUpdateFrame,
 ) {
 	if strings.TrimSpace(agentID) == "" {
 		return
@@ -1747,17 +2349,20 @@ func (r *Runtime) appendContextUpdateHistory(
 			"kind":            "time_passed",
 			"previous":        turnCtx.Previous.UTC().Format(time.RFC3339),
 			"current":         turnCtx.Now.UTC().Format(time.RFC3339),
+			"current_local":   formatPromptTime(turnCtx.Now, turnCtx.location()),
+			"timezone":        turnCtx.location().String(),
 			"elapsed_seconds": int64(turnCtx.Elapsed.Seconds()),
 		})
 	}
 	if turnCtx.DateChanged {
 		r.appendHistory(ctx, agentID, "system_update", "system", "date changed", taskID, generation, map[string]any{
 			"kind":          "date_changed",
-			"previous_date": turnCtx.Previous.UTC().Format("Monday, 2006-01-02"),
-			"current_date":  turnCtx.Now.UTC().Format("Monday, 2006-01-02"),
+			"previous_date": formatPromptDate(turnCtx.Previous, turnCtx.location()),
+			"current_date":  formatPromptDate(turnCtx.Now, turnCtx.location()),
+			"timezone":      turnCtx.location().String(),
 		})
 	}
-	for _, evt := range events {
+	for _, evt := range frame.Events {
 		priority := eventPriorityForEvent(evt)
 		subject := clipText(strings.TrimSpace(evt.Subject), contextEventBodyLimit(priority))
 		body, _ := buildContextEventBody(evt, priority)
@@ -1777,6 +2382,9 @@ func (r *Runtime) appendContextUpdateHistory(
 			"body":       body,
 			"created_at": evt.CreatedAt.UTC().Format(time.RFC3339Nano),
 		}
+		if ref := frame.Refs[evt.ID]; ref != "" {
+			data["ref"] = ref
+		}
 		if metadata := previewJSON(evt.Metadata, maxContextEventData); metadata != "" {
 			data["metadata"] = metadata
 		}
@@ -1794,13 +2402,18 @@ func (r *Runtime) collectUnreadContextEvents(ctx context.Context, agentID string
 	if limit <= 0 {
 		limit = maxContextEventsPerTurn * 2
 	}
+	if turnLaneFromContext(ctx) == 0 {
+		r.pruneReleasedClaims(agentID)
+	}
 
+	subscribed := r.subscribedTopics(ctx, agentID)
 	idsByStream := map[string][]string{}
 	for _, stream := range schema.AgentStreams {
 		summaries, err := r.Bus.List(ctx, stream, eventbus.ListOptions{
 			Reader: agentID,
 			Limit:  limit,
 			Order:  "lifo",
+			Topics: subscribed.names(),
 		})
 		if err != nil {
 			return nil, err
@@ -1825,7 +2438,12 @@ func (r *Runtime) collectUnreadContextEvents(ctx context.Context, agentID string
 			if evt.Read {
 				continue
 			}
-			if !eventTargetsTask(evt, agentID) {
+			if !eventReachesAgent(evt, agentID, subscribed) {
+				// Publications from before the agent subscribed are
+				// marked read so they stop occupying the scan window.
+				if evt.ScopeType == topics.ScopeType && evt.ID != "" {
+					suppressedByStream[evt.Stream] = append(suppressedByStream[evt.Stream], evt.ID)
+				}
 				continue
 			}
 			if !eventVisibleToAgentContext(evt) {
@@ -1848,7 +2466,7 @@ func (r *Runtime) collectUnreadContextEvents(ctx context.Context, agentID string
 		}
 		_ = r.Bus.Ack(ctx, stream, uniqueStrings(ids), agentID)
 	}
-	return out, nil
+	return r.claimContextEvents(ctx, agentID, out), nil
 }
 
 func selectContextEventsForPrompt(events []eventbus.Event, limit int) []eventbus.Event {
@@ -1880,6 +2498,7 @@ func selectContextEventsForPrompt(events []eventbus.Event, limit int) []eventbus
 	if limit <= 0 || len(events) <= limit {
 		out := append([]eventbus.Event{}, events...)
 		sort.SliceStable(out, func(i, j int) bool { return orderForPrompt(out[i], out[j]) })
+		orderMessagesBySenderSeq(out)
 		return out
 	}
 
@@ -1887,6 +2506,7 @@ func selectContextEventsForPrompt(events []eventbus.Event, limit int) []eventbus
 	sort.SliceStable(scored, func(i, j int) bool { return orderForSelection(scored[i], scored[j]) })
 	scored = scored[:limit]
 	sort.SliceStable(scored, func(i, j int) bool { return orderForPrompt(scored[i], scored[j]) })
+	orderMessagesBySenderSeq(scored)
 	return scored
 }
 
@@ -1925,7 +2545,7 @@ func isActionablePromptEvent(evt eventbus.Event) bool {
 	}
 	if evt.Stream == "task_output" {
 		switch strings.ToLower(strings.TrimSpace(schema.GetMetaString(evt.Metadata, "task_kind"))) {
-		case "completed", "failed", "cancelled", "killed":
+		case "completed", "failed", "cancelled", "killed", "limit_exceeded":
 			return true
 		}
 	}
@@ -2074,6 +2694,7 @@ func buildInputWithHistory(source, message string, metadata map[string]any, turn
 		b.WriteString(xmlEscape(message))
 		b.WriteString("</message>\n")
 	}
+	b.WriteString(renderAnsweredQuestionXML(metadata))
 	if ctx, ok := metadata["context"]; ok {
 		if ctxJSON := previewJSON(ctx, maxContextEventBodyWake); ctxJSON != "" {
 			b.WriteString("  <context>")
@@ -2101,25 +2722,34 @@ func indentXML(raw, prefix string) string {
 
 func renderContextUpdatesXML(turnCtx TurnContext, frame ContextUpdateFrame) string {
 	var b strings.Builder
-	b.WriteString("<context_updates>\n")
+	loc := turnCtx.location()
+	b.WriteString("<context_updates timezone=\"")
+	b.WriteString(xmlEscape(loc.String()))
+	b.WriteString("\">\n")
 
 	if !turnCtx.Previous.IsZero() && turnCtx.TimePassed {
 		b.WriteString("  <system_update kind=\"time_passed\" previous=\"")
-		b.WriteString(turnCtx.Previous.UTC().Format(time.RFC3339))
+		b.WriteString(formatPromptTime(turnCtx.Previous, loc))
 		b.WriteString("\" current=\"")
-		b.WriteString(turnCtx.Now.UTC().Format(time.RFC3339))
+		b.WriteString(formatPromptTime(turnCtx.Now, loc))
 		b.WriteString("\" elapsed_seconds=\"")
 		b.WriteString(fmt.Sprintf("%d", int64(turnCtx.Elapsed.Seconds())))
 		b.WriteString("\" />\n")
 	}
 	if turnCtx.DateChanged {
 		b.WriteString("  <system_update kind=\"date_changed\" previous_date=\"")
-		b.WriteString(turnCtx.Previous.UTC().Format("Monday, 2006-01-02"))
+		b.WriteString(formatPromptDate(turnCtx.Previous, loc))
 		b.WriteString("\" current_date=\"")
-		b.WriteString(turnCtx.Now.UTC().Format("Monday, 2006-01-02"))
+		b.WriteString(formatPromptDate(turnCtx.Now, loc))
 		b.WriteString("\" />\n")
 	}
 
+	for _, gap := range frame.MessageGaps {
+		b.WriteString("  <system_update kind=\"message_gap\" source=\"")
+		b.WriteString(xmlEscape(gap.Source))
+		b.WriteString(fmt.Sprintf("\" expected_seq=\"%d\" received_seq=\"%d\" />\n", gap.Expected, gap.Received))
+	}
+
 	for _, evt := range frame.Events {
 		priority := eventPriorityForEvent(evt)
 		taskID := schema.GetMetaString(evt.Metadata, "task_id")
@@ -2134,7 +2764,13 @@ func renderContextUpdatesXML(turnCtx TurnContext, frame ContextUpdateFrame) stri
 			body = ""
 			bodyTruncated = false
 		}
-		b.WriteString("  <event stream=\"")
+		b.WriteString("  <event")
+		if ref := frame.Refs[evt.ID]; ref != "" {
+			b.WriteString(" ref=\"")
+			b.WriteString(xmlEscape(ref))
+			b.WriteString("\"")
+		}
+		b.WriteString(" stream=\"")
 		b.WriteString(xmlEscape(evt.Stream))
 		b.WriteString("\"")
 		if priority != "" && priority != "normal" {
@@ -2157,8 +2793,11 @@ func renderContextUpdatesXML(turnCtx TurnContext, frame ContextUpdateFrame) stri
 			b.WriteString(xmlEscape(serviceID))
 			b.WriteString("\"")
 		}
+		if _, seq, ok := messageSequence(evt); ok {
+			b.WriteString(fmt.Sprintf(" seq=\"%d\"", seq))
+		}
 		b.WriteString(" created_at=\"")
-		b.WriteString(evt.CreatedAt.UTC().Format(time.RFC3339))
+		b.WriteString(formatPromptTime(evt.CreatedAt, loc))
 		b.WriteString("\">\n")
 		if subject != "" {
 			b.WriteString("    <subject>")
@@ -2236,11 +2875,11 @@ func shouldAppendPayloadToContextBody(evt eventbus.Event, priority, body string)
 	}
 	taskKind := strings.ToLower(strings.TrimSpace(schema.GetMetaString(evt.Metadata, "task_kind")))
 	switch taskKind {
-	case "completed", "failed", "cancelled", "killed":
+	case "completed", "failed", "cancelled", "killed", "limit_exceeded":
 		return true
 	}
 	switch strings.ToLower(strings.TrimSpace(body)) {
-	case "summary", "completed", "failed", "cancelled", "killed":
+	case "summary", "completed", "failed", "cancelled", "killed", "limit_exceeded":
 		return true
 	default:
 		return false
@@ -2291,6 +2930,9 @@ func compactEventMetadataForPrompt(metadata map[string]any, stream, priority, ta
 	delete(clean, "agent_id")
 	delete(clean, "task_type")
 	delete(clean, "supersedes_count")
+	if kind == "message" {
+		delete(clean, "seq")
+	}
 	if len(clean) == 0 {
 		return ""
 	}
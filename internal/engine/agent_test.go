@@ -12,10 +12,13 @@ import (
 
 	"github.com/flitsinc/go-agents/internal/agentcontext"
 	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/documents"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/maintenance"
 	"github.com/flitsinc/go-agents/internal/schema"
 	"github.com/flitsinc/go-agents/internal/tasks"
 	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-agents/internal/urgency"
 	"github.com/flitsinc/go-llms/content"
 	"github.com/flitsinc/go-llms/llms"
 	llmtools "github.com/flitsinc/go-llms/tools"
@@ -511,6 +514,77 @@ func TestRenderContextUpdatesXMLUsesBodyAndTruncatesNormalPriority(t *testing.T)
 	}
 }
 
+func TestClassifyInboundSetsPriorityOnlyWhenUnset(t *testing.T) {
+	rt := &Runtime{Urgency: urgency.KeywordClassifier{}}
+
+	meta := map[string]any{"kind": "message"}
+	rt.ClassifyInbound(context.Background(), "thanks, got it", meta)
+	if meta["priority"] != "normal" || meta["urgency"] != "low" {
+		t.Fatalf("expected low-urgency chatter to map to normal priority, got %#v", meta)
+	}
+
+	meta = map[string]any{"kind": "message", "priority": "wake"}
+	rt.ClassifyInbound(context.Background(), "production down, fix ASAP", meta)
+	if meta["priority"] != "wake" {
+		t.Fatalf("expected caller priority to be kept, got %#v", meta["priority"])
+	}
+	if meta["urgency"] != "high" {
+		t.Fatalf("expected urgency tag, got %#v", meta["urgency"])
+	}
+}
+
+func TestMessagesOrderedBySenderSequenceWithGapDetection(t *testing.T) {
+	now := time.Now().UTC()
+	message := func(id, source string, seq int64, at time.Time) eventbus.Event {
+		return eventbus.Event{
+			ID:        id,
+			Stream:    "task_input",
+			Body:      id,
+			CreatedAt: at,
+			Metadata: map[string]any{
+				"kind":     "message",
+				"source":   source,
+				"priority": "wake",
+				"seq":      seq,
+			},
+		}
+	}
+	events := []eventbus.Event{
+		message("a2", "agent-a", 2, now),
+		message("b1", "agent-b", 1, now.Add(time.Millisecond)),
+		message("a1", "agent-a", 1, now.Add(2*time.Millisecond)),
+		message("a4", "agent-a", 4, now.Add(3*time.Millisecond)),
+	}
+	ordered := selectContextEventsForPrompt(events, 0)
+	var ids []string
+	for _, evt := range ordered {
+		ids = append(ids, evt.ID)
+	}
+	if got := strings.Join(ids, ","); got != "a1,b1,a2,a4" {
+		t.Fatalf("expected per-sender sequence order, got %s", got)
+	}
+
+	rt := &Runtime{}
+	if gaps := rt.observeMessageSequences("operator", ordered[:1]); len(gaps) != 0 {
+		t.Fatalf("expected no gap on first message, got %+v", gaps)
+	}
+	gaps := rt.observeMessageSequences("operator", ordered[1:])
+	if len(gaps) != 1 || gaps[0].Source != "agent-a" || gaps[0].Expected != 3 || gaps[0].Received != 4 {
+		t.Fatalf("expected gap before agent-a seq 4, got %+v", gaps)
+	}
+
+	xml := renderContextUpdatesXML(TurnContext{Now: now}, ContextUpdateFrame{
+		Events:      ordered,
+		MessageGaps: gaps,
+	})
+	if !strings.Contains(xml, `<system_update kind="message_gap" source="agent-a" expected_seq="3" received_seq="4" />`) {
+		t.Fatalf("expected message gap update, got: %q", xml)
+	}
+	if !strings.Contains(xml, `seq="4"`) || strings.Contains(xml, "&quot;seq&quot;") {
+		t.Fatalf("expected seq rendered as attribute only, got: %q", xml)
+	}
+}
+
 func TestRenderContextUpdatesXMLAppendsTerminalPayloadToBody(t *testing.T) {
 	evt := eventbus.Event{
 		ID:        "evt-1",
@@ -1159,3 +1233,282 @@ func TestConversationHistoryUsesStoredSystemPrompt(t *testing.T) {
 		t.Fatalf("system prompt changed between turns:\n  turn 1: %q\n  turn 2: %q", text1[:min(len(text1), 200)], text2[:min(len(text2), 200)])
 	}
 }
+
+func TestWithReferenceDocumentsInsideSystemUpdates(t *testing.T) {
+	input := buildInputWithHistory("external", "how do I roll back?", nil, TurnContext{Now: time.Now().UTC()}, ContextUpdateFrame{})
+	out := withReferenceDocuments(input, []documents.Match{{DocumentID: "doc-1", Title: "Runbook", Seq: 0, Content: "Run rollback.sh"}})
+	if !strings.Contains(out, `<chunk document_id="doc-1" title="Runbook" seq="0">Run rollback.sh</chunk>`) {
+		t.Fatalf("expected chunk in input, got %s", out)
+	}
+	if !strings.HasSuffix(out, "</system_updates>") {
+		t.Fatalf("expected envelope to stay closed last, got %s", out)
+	}
+}
+
+func TestInterruptSettlesTasksSpawnedByTurn(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	rt.InterruptedTaskPolicies = map[string]InterruptedTaskPolicy{"exec": InterruptedTaskCancel}
+	ctx := context.Background()
+	createTestAgent(t, mgr, "agent-a")
+
+	llmTask, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "agent-a", ParentID: "agent-a"})
+	if err != nil {
+		t.Fatalf("spawn llm task: %v", err)
+	}
+	spawn := func(toolName string) tasks.Task {
+		t.Helper()
+		task, err := mgr.Spawn(ctx, tasks.Spec{
+			Type:     "exec",
+			Owner:    "agent-a",
+			ParentID: llmTask.ID,
+			Metadata: map[string]any{"tool_name": toolName, "notify_target": "agent-a"},
+		})
+		if err != nil {
+			t.Fatalf("spawn %s task: %v", toolName, err)
+		}
+		return task
+	}
+	cancelled := spawn("exec")
+	adopted := spawn("fetch")
+
+	watchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	turnCtx, cancelTurn := context.WithCancel(ctx)
+	defer cancelTurn()
+	sub := bus.Subscribe(watchCtx, schema.AgentStreams)
+	done := make(chan struct{})
+	go func() {
+		rt.watchInterrupts(watchCtx, sub, "agent-a", llmTask.ID, cancelTurn)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := bus.Push(ctx, eventbus.EventInput{
+		Stream:    "task_input",
+		ScopeType: "task",
+		ScopeID:   "agent-a",
+		Body:      "stop",
+		Metadata:  map[string]any{"kind": "message", "source": "human", "priority": "interrupt"},
+	}); err != nil {
+		t.Fatalf("push interrupt: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("interrupt was not observed")
+	}
+	if turnCtx.Err() == nil {
+		t.Fatalf("expected turn context to be cancelled")
+	}
+
+	got, err := mgr.Get(ctx, cancelled.ID)
+	if err != nil {
+		t.Fatalf("get cancelled task: %v", err)
+	}
+	if got.Status != tasks.StatusCancelled {
+		t.Fatalf("expected exec task to be cancelled, got %s", got.Status)
+	}
+	got, err = mgr.Get(ctx, adopted.ID)
+	if err != nil {
+		t.Fatalf("get adopted task: %v", err)
+	}
+	if got.Status != tasks.StatusQueued {
+		t.Fatalf("expected adopted task to keep running, got %s", got.Status)
+	}
+	if got.ParentID != "agent-a" || got.Metadata["background"] != true || got.Metadata["adopted_from_llm"] != llmTask.ID {
+		t.Fatalf("expected task to be adopted by the agent, got parent=%q metadata=%#v", got.ParentID, got.Metadata)
+	}
+}
+
+func TestMaintenanceWindowDefersWakesIntoOneDigestTurn(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &captureProvider{}
+	client := &ai.Client{LLM: llms.New(provider)}
+	rt := NewRuntime(bus, mgr, client)
+	now := time.Now().UTC()
+	rt.Maintenance = maintenance.NewStore(db, maintenance.WithClock(func() time.Time { return now }))
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	if _, err := rt.Maintenance.Create(ctx, maintenance.Input{AgentID: "agent-a", Duration: time.Hour, Reason: "db upgrade"}); err != nil {
+		t.Fatalf("create window: %v", err)
+	}
+	for _, subject := range []string{"disk alert", "cron finished"} {
+		if _, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    "external",
+			ScopeType: "task",
+			ScopeID:   "agent-a",
+			Subject:   subject,
+			Body:      subject,
+			Metadata:  map[string]any{"kind": "event", "priority": "wake"},
+		}); err != nil {
+			t.Fatalf("push %s: %v", subject, err)
+		}
+	}
+
+	replayed, err := rt.replayUnreadWakeEvents(ctx, "agent-a", 50)
+	if err != nil {
+		t.Fatalf("replay during window: %v", err)
+	}
+	if replayed != 0 || provider.LastInput() != "" {
+		t.Fatalf("expected wakes to be deferred, replayed=%d input=%q", replayed, provider.LastInput())
+	}
+
+	now = now.Add(2 * time.Hour)
+	replayed, err = rt.replayUnreadWakeEvents(ctx, "agent-a", 50)
+	if err != nil {
+		t.Fatalf("replay after window: %v", err)
+	}
+	if replayed != 1 {
+		t.Fatalf("expected one digest turn, got %d", replayed)
+	}
+	input := provider.LastInput()
+	if !strings.Contains(input, "2 wake event(s) were deferred") || !strings.Contains(input, "disk alert") || !strings.Contains(input, "cron finished") {
+		t.Fatalf("expected digest covering both deferred events, got %q", input)
+	}
+
+	replayed, err = rt.replayUnreadWakeEvents(ctx, "agent-a", 50)
+	if err != nil {
+		t.Fatalf("replay after digest: %v", err)
+	}
+	if replayed != 0 {
+		t.Fatalf("expected deferred events to be consumed by the digest turn, got %d more turns", replayed)
+	}
+}
+
+func TestThreadStitchesRepliesBetweenAgents(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	client := &ai.Client{LLM: llms.New(&fakeProvider{})}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "agent-a")
+	createTestAgent(t, mgr, "agent-b")
+	ctx := context.Background()
+
+	question, err := rt.SendMessageWithMeta(ctx, "agent-b", "can you check the build?", "agent-a", nil)
+	if err != nil {
+		t.Fatalf("send question: %v", err)
+	}
+	if _, err := rt.SendMessageWithMeta(ctx, "agent-b", "unrelated", "human", nil); err != nil {
+		t.Fatalf("send unrelated: %v", err)
+	}
+	if replayed, err := rt.replayUnreadWakeEvents(ctx, "agent-b", 50); err != nil || replayed != 1 {
+		t.Fatalf("replay agent-b: replayed=%d err=%v", replayed, err)
+	}
+
+	thread, err := rt.Thread(ctx, []string{"agent-a", "agent-b"}, "")
+	if err != nil {
+		t.Fatalf("thread: %v", err)
+	}
+	if len(thread.Messages) != 2 {
+		t.Fatalf("expected question and reply, got %+v", thread.Messages)
+	}
+	first, reply := thread.Messages[0], thread.Messages[1]
+	if first.ID != question.ID || first.From != "agent-a" || first.To != "agent-b" || first.ThreadID != question.ID {
+		t.Fatalf("unexpected first message: %+v", first)
+	}
+	if reply.From != "agent-b" || reply.To != "agent-a" || reply.ReplyTo != question.ID || reply.ThreadID != question.ID {
+		t.Fatalf("expected reply threaded under the question, got %+v", reply)
+	}
+
+	narrowed, err := rt.Thread(ctx, []string{"agent-a", "agent-b"}, "other-thread")
+	if err != nil {
+		t.Fatalf("thread by id: %v", err)
+	}
+	if len(narrowed.Messages) != 0 {
+		t.Fatalf("expected no messages for unknown thread, got %+v", narrowed.Messages)
+	}
+
+	md := RenderThreadMarkdown(thread)
+	if !strings.Contains(md, "## agent-a → agent-b") || !strings.Contains(md, "## agent-b → agent-a") || !strings.Contains(md, "in reply to `"+question.ID+"`") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+	if _, err := rt.Thread(ctx, []string{"agent-a"}, ""); err == nil {
+		t.Fatalf("expected error for a single agent")
+	}
+}
+
+func TestInheritedTurnPriority(t *testing.T) {
+	cases := []struct {
+		meta map[string]any
+		want schema.Priority
+	}{
+		{nil, ""},
+		{map[string]any{"kind": "message", "priority": "normal"}, schema.PriorityWake},
+		{map[string]any{"kind": "message", "priority": "wake"}, schema.PriorityInterrupt},
+		{map[string]any{"kind": "task_update", "priority": "wake"}, schema.PriorityWake},
+		{map[string]any{"kind": "event", "priority": "low"}, ""},
+	}
+	for _, tc := range cases {
+		if got := inheritedTurnPriority(tc.meta); got != tc.want {
+			t.Fatalf("inheritedTurnPriority(%v) = %q, want %q", tc.meta, got, tc.want)
+		}
+	}
+}
+
+func TestRenderContextUpdatesXMLUsesAgentTimezoneAcrossDST(t *testing.T) {
+	rt := NewRuntime(nil, nil, nil)
+	if err := rt.SetAgentTimezone("agent-tz", "Mars/Olympus"); err == nil {
+		t.Fatalf("expected unknown timezone to be rejected")
+	}
+	if err := rt.SetAgentTimezone("agent-tz", "America/New_York"); err != nil {
+		t.Fatalf("set timezone: %v", err)
+	}
+
+	// US clocks spring forward at 2026-03-08 07:00 UTC (02:00 EST -> 03:00 EDT).
+	before := time.Date(2026, 3, 8, 6, 30, 0, 0, time.UTC)
+	after := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)
+	rt.nextTurnContext("agent-tz", before)
+	turnCtx := rt.nextTurnContext("agent-tz", after)
+	if turnCtx.DateChanged {
+		t.Fatalf("did not expect a date change within one local day")
+	}
+
+	xml := renderContextUpdatesXML(turnCtx, ContextUpdateFrame{
+		Events: []eventbus.Event{{ID: "evt-1", Stream: "messages_in", Body: "hi", CreatedAt: after}},
+	})
+	for _, want := range []string{
+		`<context_updates timezone="America/New_York">`,
+		`previous="Sunday 2026-03-08T01:30:00-05:00"`,
+		`current="Sunday 2026-03-08T03:30:00-04:00"`,
+		`created_at="Sunday 2026-03-08T03:30:00-04:00"`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Fatalf("expected %s in context updates, got: %q", want, xml)
+		}
+	}
+
+	// Clocks fall back at 2026-11-01 06:00 UTC; local midnight is 04:00 UTC.
+	rt.nextTurnContext("agent-tz", time.Date(2026, 11, 1, 3, 30, 0, 0, time.UTC))
+	turnCtx = rt.nextTurnContext("agent-tz", time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC))
+	if !turnCtx.DateChanged {
+		t.Fatalf("expected local midnight to count as a date change")
+	}
+	xml = renderContextUpdatesXML(turnCtx, ContextUpdateFrame{})
+	if !strings.Contains(xml, `previous_date="Saturday, 2026-10-31" current_date="Sunday, 2026-11-01"`) ||
+		!strings.Contains(xml, `current="Sunday 2026-11-01T01:30:00-05:00"`) {
+		t.Fatalf("expected local dates across fall back, got: %q", xml)
+	}
+}
+
+func TestRenderContextUpdatesXMLDefaultsToUTC(t *testing.T) {
+	now := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)
+	xml := renderContextUpdatesXML(TurnContext{Now: now}, ContextUpdateFrame{
+		Events: []eventbus.Event{{ID: "evt-1", Stream: "messages_in", Body: "hi", CreatedAt: now}},
+	})
+	if !strings.Contains(xml, `<context_updates timezone="UTC">`) || !strings.Contains(xml, `created_at="Sunday 2026-03-08T07:30:00Z"`) {
+		t.Fatalf("expected UTC rendering, got: %q", xml)
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// InterruptAlertMonitor raises alerts for interrupts agents have not read.
+const InterruptAlertMonitor = "interrupt_alerts"
+
+// DefaultInterruptAlertAfter is how long an interrupt may go unread before
+// operators are alerted.
+const DefaultInterruptAlertAfter = 5 * time.Minute
+
+const (
+	interruptAlertInterval = time.Minute
+	interruptAlertScan     = 50
+)
+
+// PushAlert raises an operator alert of kind about agentID on the alerts
+// stream, where the notification dispatcher picks it up.
+func (r *Runtime) PushAlert(ctx context.Context, agentID, kind, subject, body string, meta map[string]any) (eventbus.Event, error) {
+	if r.Bus == nil {
+		return eventbus.Event{}, fmt.Errorf("event bus not configured")
+	}
+	if body == "" {
+		body = subject
+	}
+	metadata := map[string]any{}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	metadata[schema.MetaKind] = kind
+	if agentID != "" {
+		metadata[schema.MetaAgentID] = agentID
+	}
+	return r.Bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamAlerts,
+		ScopeType: "global",
+		ScopeID:   "*",
+		Subject:   subject,
+		Body:      body,
+		Metadata:  metadata,
+	})
+}
+
+// alertUnacknowledgedInterrupts raises one alert for each interrupt-priority
+// message an agent has left unread for longer than InterruptAlertAfter.
+func (r *Runtime) alertUnacknowledgedInterrupts(ctx context.Context) error {
+	if r.Bus == nil || r.Tasks == nil {
+		return nil
+	}
+	after := r.InterruptAlertAfter
+	if after <= 0 {
+		after = DefaultInterruptAlertAfter
+	}
+	agents, err := r.Tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return err
+	}
+	cutoff := r.now().Add(-after)
+	var errs []error
+	for _, agent := range agents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.alertAgentInterrupts(ctx, agent.ID, cutoff); err != nil {
+			errs = append(errs, fmt.Errorf("interrupt alerts for %s: %w", agent.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runtime) alertAgentInterrupts(ctx context.Context, agentID string, cutoff time.Time) error {
+	pending, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+		Reader:    agentID,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Order:     "lifo",
+		Limit:     interruptAlertScan,
+		Fields: []eventbus.FieldFilter{
+			{Column: "metadata", Path: []string{schema.MetaPriority}, Values: []string{string(schema.PriorityInterrupt)}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, evt := range pending {
+		if evt.Read || evt.CreatedAt.After(cutoff) {
+			continue
+		}
+		alerted, err := r.Bus.Count(ctx, schema.StreamAlerts, eventbus.ListOptions{
+			Fields: []eventbus.FieldFilter{
+				{Column: "metadata", Path: []string{schema.MetaEventID}, Values: []string{evt.ID}},
+			},
+		}, time.Time{}, time.Time{})
+		if err != nil {
+			return err
+		}
+		if alerted > 0 {
+			continue
+		}
+		waited := r.now().Sub(evt.CreatedAt).Round(time.Minute)
+		subject := fmt.Sprintf("Interrupt to %s unanswered for %s", agentID, waited)
+		if _, err := r.PushAlert(ctx, agentID, schema.AlertInterruptUnacknowledged, subject, evt.Subject, map[string]any{
+			schema.MetaEventID: evt.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestAlertUnacknowledgedInterruptsAlertsOncePerMessage(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Now().UTC()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil, WithClock(func() time.Time { return now }))
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	pushInput := func(priority schema.Priority) eventbus.Event {
+		t.Helper()
+		evt, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    schema.StreamTaskInput,
+			ScopeType: "task",
+			ScopeID:   "agent-a",
+			Subject:   "Stop the deploy",
+			Body:      "Stop the deploy now",
+			Metadata:  map[string]any{schema.MetaPriority: string(priority)},
+		})
+		if err != nil {
+			t.Fatalf("push input: %v", err)
+		}
+		return evt
+	}
+	interrupt := pushInput(schema.PriorityInterrupt)
+	pushInput(schema.PriorityNormal)
+	read := pushInput(schema.PriorityInterrupt)
+	if err := bus.Ack(ctx, schema.StreamTaskInput, []string{read.ID}, "agent-a"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	countAlerts := func() int {
+		t.Helper()
+		n, err := bus.Count(ctx, schema.StreamAlerts, eventbus.ListOptions{}, time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("count alerts: %v", err)
+		}
+		return n
+	}
+	if err := rt.alertUnacknowledgedInterrupts(ctx); err != nil {
+		t.Fatalf("alert: %v", err)
+	}
+	if n := countAlerts(); n != 0 {
+		t.Fatalf("expected no alert before the threshold, got %d", n)
+	}
+
+	now = now.Add(DefaultInterruptAlertAfter + time.Minute)
+	for range 2 {
+		if err := rt.alertUnacknowledgedInterrupts(ctx); err != nil {
+			t.Fatalf("alert: %v", err)
+		}
+	}
+	if n := countAlerts(); n != 1 {
+		t.Fatalf("expected one alert for the unread interrupt, got %d", n)
+	}
+	list, _ := bus.List(ctx, schema.StreamAlerts, eventbus.ListOptions{Limit: 1})
+	events, _ := bus.Read(ctx, schema.StreamAlerts, []string{list[0].ID}, "")
+	meta := events[0].Metadata
+	if schema.GetMetaString(meta, schema.MetaKind) != schema.AlertInterruptUnacknowledged ||
+		schema.GetMetaString(meta, schema.MetaAgentID) != "agent-a" ||
+		schema.GetMetaString(meta, schema.MetaEventID) != interrupt.ID {
+		t.Fatalf("unexpected alert metadata %v", meta)
+	}
+}
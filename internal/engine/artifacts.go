@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/artifacts"
+)
+
+// turnArtifacts offloads a turn's oversized assistant text, storing each
+// distinct text once so the history entry and the final output share an
+// artifact.
+type turnArtifacts struct {
+	offloader *artifacts.Offloader
+	agentID   string
+	taskID    string
+	stored    map[string]artifacts.Artifact
+}
+
+func (r *Runtime) newTurnArtifacts(agentID, taskID string) *turnArtifacts {
+	return &turnArtifacts{
+		offloader: r.Artifacts,
+		agentID:   agentID,
+		taskID:    taskID,
+		stored:    map[string]artifacts.Artifact{},
+	}
+}
+
+// offload returns the text to show inline in place of text, and the
+// artifact holding the original when it was offloaded.
+func (t *turnArtifacts) offload(ctx context.Context, text string) (string, *artifacts.Artifact) {
+	if t == nil || t.offloader == nil || strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+	if a, ok := t.stored[text]; ok {
+		return artifacts.Reference(a), &a
+	}
+	a, ok, err := t.offloader.Offload(ctx, t.agentID, t.taskID, "assistant", text)
+	if err != nil || !ok {
+		return text, nil
+	}
+	t.stored[text] = a
+	return artifacts.Reference(a), &a
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/artifacts"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestOversizedReplyIsStoredAsArtifact(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	store := artifacts.NewStore(db)
+	// The fake model always answers "ok", so any threshold below two
+	// characters offloads it.
+	rt.Artifacts = &artifacts.Offloader{Store: store, Threshold: 1}
+	createTestAgent(t, mgr, "operator")
+
+	session, err := rt.RunOnce(ctx, "operator", "hello")
+	if err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if session.LastOutput != "ok" {
+		t.Fatalf("expected the session to keep the full output, got %q", session.LastOutput)
+	}
+
+	list, err := store.List(ctx, "operator", 10)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one artifact, got %v %v", list, err)
+	}
+	artifact, err := store.Get(ctx, list[0].ID)
+	if err != nil || artifact.Content != "ok" || artifact.Source != "assistant" {
+		t.Fatalf("unexpected artifact %+v %v", artifact, err)
+	}
+
+	entries, err := rt.readHistoryEntries(ctx, "operator")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	var assistant *AgentHistoryEntry
+	for i := range entries {
+		if entries[i].Type == "assistant_message" {
+			assistant = &entries[i]
+		}
+	}
+	if assistant == nil || assistant.Data["artifact_id"] != artifact.ID || !strings.Contains(assistant.Content, artifact.ID) {
+		t.Fatalf("expected the history entry to reference %s, got %+v", artifact.ID, assistant)
+	}
+
+	turns, err := mgr.List(ctx, tasks.ListFilter{Type: "llm", Limit: 10})
+	if err != nil || len(turns) != 1 {
+		t.Fatalf("expected one turn, got %v %v", turns, err)
+	}
+	if output, _ := turns[0].Result["output"].(string); !strings.HasPrefix(output, "[Stored as artifact "+artifact.ID) {
+		t.Fatalf("expected the turn output to be the reference, got %q", output)
+	}
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	// DefaultAskTimeout is how long Ask waits without a timeout of its own.
+	DefaultAskTimeout = 2 * time.Minute
+	// MaxAskTimeout caps how long Ask waits.
+	MaxAskTimeout = 10 * time.Minute
+)
+
+// askCorrelationMeta is the metadata key that ties a reply to the message
+// Ask sent.
+const askCorrelationMeta = "correlation_id"
+
+var (
+	// ErrAskTimeout is returned when the agent asked did not reply in time.
+	// Its reply, if it comes, arrives later as an ordinary message.
+	ErrAskTimeout = errors.New("no reply before the timeout; a late reply arrives as an ordinary message")
+	// ErrAskCycle is returned when the agent asked is itself waiting, directly
+	// or through others, on the agent asking, so neither could go on.
+	ErrAskCycle = errors.New("the agent asked is waiting on a reply from you")
+)
+
+// Ask sends message from source to the agent target with a correlation ID
+// and waits up to timeout for target's reply, which it returns and marks
+// read for source so it does not start another turn. A zero timeout is
+// DefaultAskTimeout. Unknown agents are created when a provisioning rule
+// covers them.
+func (r *Runtime) Ask(ctx context.Context, source, target, message string, timeout time.Duration) (agenttools.AgentReply, error) {
+	if r.Bus == nil || r.Tasks == nil {
+		return agenttools.AgentReply{}, fmt.Errorf("runtime unavailable")
+	}
+	source = strings.TrimSpace(source)
+	target = strings.TrimSpace(target)
+	message = strings.TrimSpace(message)
+	switch {
+	case source == "":
+		return agenttools.AgentReply{}, fmt.Errorf("source is required")
+	case target == "":
+		return agenttools.AgentReply{}, fmt.Errorf("target agent is required")
+	case target == source:
+		return agenttools.AgentReply{}, fmt.Errorf("an agent cannot ask itself")
+	case message == "":
+		return agenttools.AgentReply{}, fmt.Errorf("message is required")
+	}
+	if timeout <= 0 {
+		timeout = DefaultAskTimeout
+	}
+	timeout = min(timeout, MaxAskTimeout)
+	if task, err := r.Tasks.Get(ctx, target); err == nil {
+		if task.Type != "agent" {
+			return agenttools.AgentReply{}, fmt.Errorf("%s is a %s task, not an agent", target, task.Type)
+		}
+	} else if _, err := r.ProvisionAgent(ctx, target); errors.Is(err, ErrUnknownAgent) {
+		return agenttools.AgentReply{}, fmt.Errorf("unknown agent %s", target)
+	} else if err != nil {
+		return agenttools.AgentReply{}, err
+	}
+	if !r.beginAsk(source, target) {
+		return agenttools.AgentReply{}, ErrAskCycle
+	}
+	defer r.endAsk(source, target)
+
+	// Subscribe before sending so a quick reply is not missed.
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	sub := r.Bus.Subscribe(waitCtx, []string{schema.StreamTaskInput})
+	correlationID := idgen.New()
+	start := time.Now()
+	r.EnsureAgentLoop(target)
+	sent, err := r.SendMessageWithMeta(ctx, target, message, source, map[string]any{askCorrelationMeta: correlationID})
+	if err != nil {
+		return agenttools.AgentReply{}, err
+	}
+	for {
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return agenttools.AgentReply{}, ctx.Err()
+			}
+			return agenttools.AgentReply{}, ErrAskTimeout
+		case evt, ok := <-sub:
+			if !ok {
+				continue
+			}
+			if evt.ScopeID != source || schema.GetMetaString(evt.Metadata, askCorrelationMeta) != correlationID ||
+				schema.GetMetaString(evt.Metadata, "source") != target {
+				continue
+			}
+			_ = r.Bus.Ack(context.WithoutCancel(ctx), evt.Stream, []string{evt.ID}, source)
+			return agenttools.AgentReply{
+				AgentID:       target,
+				CorrelationID: correlationID,
+				MessageID:     sent.ID,
+				ReplyID:       evt.ID,
+				Text:          evt.Body,
+				QuestionID:    schema.GetMetaString(evt.Metadata, "question_id"),
+				ElapsedMS:     time.Since(start).Milliseconds(),
+			}, nil
+		}
+	}
+}
+
+// beginAsk records that source waits on target, unless target already
+// waits on source through a chain of asks.
+func (r *Runtime) beginAsk(source, target string) bool {
+	r.asksMu.Lock()
+	defer r.asksMu.Unlock()
+	seen := map[string]bool{}
+	pending := []string{target}
+	for len(pending) > 0 {
+		agent := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if agent == source {
+			return false
+		}
+		if seen[agent] {
+			continue
+		}
+		seen[agent] = true
+		for next := range r.asks[agent] {
+			pending = append(pending, next)
+		}
+	}
+	if r.asks == nil {
+		r.asks = map[string]map[string]int{}
+	}
+	if r.asks[source] == nil {
+		r.asks[source] = map[string]int{}
+	}
+	r.asks[source][target]++
+	return true
+}
+
+func (r *Runtime) endAsk(source, target string) {
+	r.asksMu.Lock()
+	defer r.asksMu.Unlock()
+	if r.asks[source][target]--; r.asks[source][target] <= 0 {
+		delete(r.asks[source], target)
+	}
+	if len(r.asks[source]) == 0 {
+		delete(r.asks, source)
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestAskWaitsForTheAgentsCorrelatedReply(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	rt.baseCtx = ctx
+	createTestAgent(t, mgr, "asker")
+	createTestAgent(t, mgr, "expert")
+
+	reply, err := rt.Ask(ctx, "asker", "expert", "what is the capital of France?", 10*time.Second)
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	if reply.AgentID != "expert" || reply.Text != "ok" || reply.CorrelationID == "" || reply.MessageID == "" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+	events, err := bus.Read(ctx, schema.StreamTaskInput, []string{reply.ReplyID}, "asker")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("read reply: %d events, %v", len(events), err)
+	}
+	if !events[0].Read || schema.GetMetaString(events[0].Metadata, "reply_to") != reply.MessageID {
+		t.Fatalf("expected the reply threaded and read for the asker, got %+v", events[0])
+	}
+
+	// expert waits on asker, so asker asking expert could never finish.
+	if !rt.beginAsk("expert", "asker") {
+		t.Fatalf("expected the first ask to be allowed")
+	}
+	if _, err := rt.Ask(ctx, "asker", "expert", "and Spain?", time.Second); !errors.Is(err, ErrAskCycle) {
+		t.Fatalf("expected a cycle refused, got %v", err)
+	}
+	rt.endAsk("expert", "asker")
+	if len(rt.asks) != 0 {
+		t.Fatalf("expected no asks left, got %+v", rt.asks)
+	}
+
+	if _, err := rt.Ask(ctx, "asker", "nobody", "hello?", time.Second); err == nil {
+		t.Fatalf("expected an unknown agent refused")
+	}
+	if _, err := rt.Ask(ctx, "asker", "asker", "hello?", time.Second); err == nil {
+		t.Fatalf("expected asking oneself refused")
+	}
+}
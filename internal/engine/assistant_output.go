@@ -9,7 +9,9 @@ import (
 )
 
 // EmitAssistantOutput records an assistant_output update and appends a matching
-// assistant_message history entry for the given agent task.
+// assistant_message history entry for the given agent task. Images and files
+// in metadata["parts"] travel with the text as parts, and the payload's text
+// lists them for channels that only show text.
 func (r *Runtime) EmitAssistantOutput(ctx context.Context, taskID, text string, metadata map[string]any) error {
 	if r == nil || r.Tasks == nil {
 		return fmt.Errorf("runtime unavailable")
@@ -19,6 +21,14 @@ func (r *Runtime) EmitAssistantOutput(ctx context.Context, taskID, text string,
 		return fmt.Errorf("task_id is required")
 	}
 	text = strings.TrimSpace(text)
+	var attachments []schema.Part
+	for _, part := range schema.ParseParts(metadata[schema.MetaParts]) {
+		if part.Type != schema.PartText {
+			attachments = append(attachments, part)
+		}
+	}
+	body := text
+	text = schema.TextWithAttachments(body, attachments)
 	if text == "" {
 		return fmt.Errorf("text is required")
 	}
@@ -50,10 +60,12 @@ func (r *Runtime) EmitAssistantOutput(ctx context.Context, taskID, text string,
 		}
 	}
 
-	payload := assistantOutputPayload(text, routing)
+	payload := withOutputParts(assistantOutputPayload(text, routing), body, attachments)
 	opts := assistantOutputUpdateOptions(source, routing)
-	if err := r.Tasks.RecordUpdateWithOptions(ctx, taskID, "assistant_output", payload, opts); err != nil {
-		return err
+	if !r.holdForSnooze(ctx, taskID, payload, opts) {
+		if err := r.Tasks.RecordUpdateWithOptions(ctx, taskID, "assistant_output", payload, opts); err != nil {
+			return err
+		}
 	}
 
 	historyData := map[string]any{
@@ -74,6 +86,9 @@ func (r *Runtime) EmitAssistantOutput(ctx context.Context, taskID, text string,
 	if len(routeContext) > 0 {
 		historyData["context"] = cloneAnyMap(routeContext)
 	}
+	if len(attachments) > 0 {
+		historyData[schema.MetaParts] = attachments
+	}
 	r.appendHistory(ctx, taskID, "assistant_message", "assistant", text, "", 0, historyData)
 
 	session, ok := r.GetSession(taskID)
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// ErrBudgetExhausted is returned for a turn that was paused because its
+// agent's budget is exhausted.
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// modelPrice returns the price of model. ModelPrices["*"] applies to
+// unlisted models.
+func (r *Runtime) modelPrice(model string) (budget.ModelPrice, bool) {
+	if price, ok := r.ModelPrices[strings.TrimSpace(model)]; ok {
+		return price, true
+	}
+	price, ok := r.ModelPrices["*"]
+	return price, ok
+}
+
+// BudgetFromMetadata reads the budget stored in an agent task's metadata. A
+// malformed entry is treated as none.
+func BudgetFromMetadata(metadata map[string]any) budget.Budget {
+	b, _ := budget.Parse(metadata[schema.MetaBudget])
+	return b
+}
+
+// AgentBudget returns the budget stored on the agent's task, without the
+// runtime's.
+func (r *Runtime) AgentBudget(ctx context.Context, agentID string) (budget.Budget, error) {
+	if r.Tasks == nil {
+		return budget.Budget{}, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return budget.Budget{}, err
+	}
+	return BudgetFromMetadata(task.Metadata), nil
+}
+
+// SetAgentBudget stores the agent's budget in its task metadata; the zero
+// budget removes it. It applies from the agent's next turn, including a
+// turn the old budget paused.
+func (r *Runtime) SetAgentBudget(ctx context.Context, agentID string, b budget.Budget) (budget.Budget, error) {
+	if r.Tasks == nil {
+		return budget.Budget{}, fmt.Errorf("task manager unavailable")
+	}
+	if err := b.Validate(); err != nil {
+		return budget.Budget{}, err
+	}
+	var value any
+	if !b.IsZero() {
+		value = b
+	}
+	if _, err := r.Tasks.MergeMetadata(ctx, agentID, map[string]any{schema.MetaBudget: value}); err != nil {
+		return budget.Budget{}, err
+	}
+	r.budgetMu.Lock()
+	delete(r.budgetHolds, agentID)
+	r.budgetMu.Unlock()
+	return b, nil
+}
+
+// BudgetStatus is an agent's budget with what it used so far.
+type BudgetStatus struct {
+	AgentID string `json:"agent_id"`
+	// Budget is the agent's budget with the runtime's filled in.
+	Budget budget.Budget    `json:"budget"`
+	Today  tasks.TokenUsage `json:"today"`
+	Month  tasks.TokenUsage `json:"month"`
+	// Exhausted is set while a cap is reached.
+	Exhausted *budget.Exhaustion `json:"exhausted,omitempty"`
+}
+
+// AgentBudgetStatus reports the agent's budget and its usage in the current
+// day and month of its timezone.
+func (r *Runtime) AgentBudgetStatus(ctx context.Context, agentID string) (BudgetStatus, error) {
+	agentBudget, err := r.AgentBudget(ctx, agentID)
+	if err != nil {
+		return BudgetStatus{}, err
+	}
+	out := BudgetStatus{AgentID: agentID, Budget: agentBudget.Or(r.Budget)}
+	if out.Budget.Action == "" {
+		out.Budget.Action = budget.Pause
+	}
+	now := r.now().In(r.agentLocation(agentID))
+	day, month := budget.Windows(now)
+	if out.Today, err = r.Tasks.Usage(ctx, agentID, day, day.AddDate(0, 0, 1)); err != nil {
+		return BudgetStatus{}, err
+	}
+	if out.Month, err = r.Tasks.Usage(ctx, agentID, month, month.AddDate(0, 1, 0)); err != nil {
+		return BudgetStatus{}, err
+	}
+	out.Exhausted = budget.Check(out.Budget, out.Today, out.Month, day, month)
+	return out, nil
+}
+
+// checkBudget returns the cap that keeps the agent from starting a turn, or
+// nil. An exhausted budget is remembered until its window resets, so paused
+// turns the loop retries do not query usage each time, and is announced
+// once with a budget_exceeded signal.
+func (r *Runtime) checkBudget(ctx context.Context, agentID string) *budget.Exhaustion {
+	if r.Tasks == nil {
+		return nil
+	}
+	now := r.now()
+	r.budgetMu.Lock()
+	if held, ok := r.budgetHolds[agentID]; ok {
+		if now.Before(held.ResetsAt) {
+			r.budgetMu.Unlock()
+			return &held
+		}
+		delete(r.budgetHolds, agentID)
+	}
+	r.budgetMu.Unlock()
+
+	agentBudget, err := r.AgentBudget(ctx, agentID)
+	if err != nil || !agentBudget.Or(r.Budget).Capped() {
+		return nil
+	}
+	status, err := r.AgentBudgetStatus(ctx, agentID)
+	if err != nil || status.Exhausted == nil {
+		// A failed usage query lets the turn run.
+		return nil
+	}
+	exhausted := *status.Exhausted
+	r.budgetMu.Lock()
+	if r.budgetHolds == nil {
+		r.budgetHolds = map[string]budget.Exhaustion{}
+	}
+	r.budgetHolds[agentID] = exhausted
+	r.budgetMu.Unlock()
+	r.signalBudgetExceeded(ctx, agentID, exhausted)
+	return &exhausted
+}
+
+// signalBudgetExceeded pushes a budget_exceeded signal for the agent and
+// raises the matching operator alert. The signal is low priority: the agent
+// sees it on its next turn but is not woken for a turn its budget would not
+// allow.
+func (r *Runtime) signalBudgetExceeded(ctx context.Context, agentID string, exhausted budget.Exhaustion) {
+	if r.Bus == nil {
+		return
+	}
+	outcome := "paused"
+	if exhausted.Action == budget.Fail {
+		outcome = "failed"
+	}
+	_, _ = r.PushAlert(ctx, agentID, schema.AlertBudgetExceeded,
+		fmt.Sprintf("Agent %s exhausted its %s budget", agentID, exhausted.Window),
+		fmt.Sprintf("Budget exhausted: %s. Its turns are %s until then.", exhausted, outcome), nil)
+	_, _ = r.Bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamSignals,
+		Subject:   "budget_exceeded",
+		Body:      "Budget exhausted: " + exhausted.String(),
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Payload: map[string]any{
+			"agent_id": agentID,
+			"budget":   exhausted,
+		},
+		Metadata: map[string]any{
+			"kind":     "budget_exceeded",
+			"agent_id": agentID,
+			"priority": "low",
+			"source":   "runtime",
+		},
+		SourceID: agentID,
+	})
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/budget"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestHandleMessageEnforcesBudget(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &historyCapture{}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider)})
+	rt.ModelPrices = map[string]budget.ModelPrice{"*": {Input: 3, Output: 15}}
+	rt.Budget = budget.Budget{DailyTokens: 1000}
+	ctx := context.Background()
+	agentID := "agent-budget"
+	createTestAgent(t, mgr, agentID)
+
+	// An earlier turn used 900 input and 200 output tokens.
+	earlier, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: agentID, ParentID: agentID})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	rt.recordUsage(ctx, agentID, earlier.ID, "capture", llms.Usage{}, llms.Usage{InputTokens: 900, OutputTokens: 200})
+
+	status, err := rt.AgentBudgetStatus(ctx, agentID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	// 900 × $3 + 200 × $15 per million tokens.
+	if status.Today.CostMicroUSD != 5700 || status.Exhausted == nil || status.Exhausted.Window != "daily" || status.Exhausted.Unit != "tokens" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	budgetSignals := func() int {
+		t.Helper()
+		list, err := bus.List(ctx, schema.StreamSignals, eventbus.ListOptions{ScopeType: "task", ScopeID: agentID, Limit: 50})
+		if err != nil {
+			t.Fatalf("list signals: %v", err)
+		}
+		n := 0
+		for _, summary := range list {
+			if summary.Subject == "budget_exceeded" {
+				n++
+			}
+		}
+		return n
+	}
+
+	for range 2 {
+		if _, err := rt.HandleMessage(ctx, agentID, "user", "hello", nil); !errors.Is(err, ErrBudgetExhausted) {
+			t.Fatalf("expected the turn to pause, got %v", err)
+		}
+	}
+	if provider.NumCalls() != 0 {
+		t.Fatalf("expected no model call while paused, got %d", provider.NumCalls())
+	}
+	if got := budgetSignals(); got != 1 {
+		t.Fatalf("expected one budget_exceeded signal, got %d", got)
+	}
+	alerts, err := bus.Count(ctx, schema.StreamAlerts, eventbus.ListOptions{}, time.Time{}, time.Time{})
+	if err != nil || alerts != 1 {
+		t.Fatalf("expected one budget alert, got %d %v", alerts, err)
+	}
+
+	if _, err := rt.SetAgentBudget(ctx, agentID, budget.Budget{Action: budget.Fail}); err != nil {
+		t.Fatalf("set budget: %v", err)
+	}
+	sess, err := rt.HandleMessage(ctx, agentID, "user", "hello", nil)
+	if err != nil || !strings.Contains(sess.LastError, "daily token budget") {
+		t.Fatalf("expected the turn to fail on its budget, got err=%v session=%+v", err, sess)
+	}
+	turn, err := mgr.Get(ctx, sess.LLMTaskID)
+	if err != nil {
+		t.Fatalf("get turn: %v", err)
+	}
+	if turn.Status != tasks.StatusLimitExceeded {
+		t.Fatalf("expected the turn to be limit_exceeded, got %s", turn.Status)
+	}
+	if provider.NumCalls() != 0 {
+		t.Fatalf("expected no model call for a failed turn, got %d", provider.NumCalls())
+	}
+
+	// A negative cap lifts the runtime's.
+	if _, err := rt.SetAgentBudget(ctx, agentID, budget.Budget{DailyTokens: -1}); err != nil {
+		t.Fatalf("set budget: %v", err)
+	}
+	if sess, err := rt.HandleMessage(ctx, agentID, "user", "hello", nil); err != nil || sess.LastError != "" {
+		t.Fatalf("expected the turn to run, got err=%v session=%+v", err, sess)
+	}
+	if provider.NumCalls() != 1 {
+		t.Fatalf("expected one model call, got %d", provider.NumCalls())
+	}
+}
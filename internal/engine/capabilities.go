@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// Capabilities is what a client needs to know to talk to an agent: the
+// tools it can call, the model behind it and what that model accepts, and
+// its limits.
+type Capabilities struct {
+	AgentID       string           `json:"agent_id"`
+	Provider      string           `json:"provider,omitempty"`
+	Model         string           `json:"model,omitempty"`
+	ModelInfo     ai.ModelInfo     `json:"model_info"`
+	Tools         []ToolCapability `json:"tools"`
+	ProviderTools []string         `json:"provider_tools,omitempty"`
+	Inputs        InputTypes       `json:"inputs"`
+	RateLimits    RateLimits       `json:"rate_limits"`
+}
+
+type ToolCapability struct {
+	Name        string `json:"name"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the arguments, for JSON tools.
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Grammar is "json", "text", "lark" or "regex".
+	Grammar string `json:"grammar"`
+}
+
+// InputTypes says which kinds of input reach the model. Images are
+// accepted when the model has vision and the agent can load them with
+// view_image; Files is set by the API when the document store is on.
+type InputTypes struct {
+	Text  bool `json:"text"`
+	Image bool `json:"image"`
+	Files bool `json:"files"`
+}
+
+// RateLimits reports the agent's daily token allowance, if it has one.
+type RateLimits struct {
+	TokensPerDay    int64  `json:"tokens_per_day,omitempty"`
+	TokensRemaining *int64 `json:"tokens_remaining,omitempty"`
+}
+
+// AgentCapabilities describes agentID as it would run its next turn.
+func (r *Runtime) AgentCapabilities(ctx context.Context, agentID string) (Capabilities, error) {
+	out := Capabilities{AgentID: agentID, Tools: []ToolCapability{}, Inputs: InputTypes{Text: true}}
+	if r.LLM != nil {
+		out.Provider = r.LLM.Provider()
+		out.Model = r.LLM.Model()
+		out.ProviderTools = r.LLM.ProviderTools()
+		var toolset Toolset
+		if r.Tasks != nil {
+			toolset, _ = r.AgentToolset(ctx, agentID)
+		}
+		for _, tool := range r.LLM.Tools() {
+			if toolset.Allows(tool.FuncName()) {
+				out.Tools = append(out.Tools, toolCapability(tool))
+			}
+		}
+		sort.Slice(out.Tools, func(i, j int) bool { return out.Tools[i].Name < out.Tools[j].Name })
+	}
+	r.configMu.RLock()
+	cfg := r.taskConfigs[agentID]
+	r.configMu.RUnlock()
+	if cfg != nil {
+		cfg.mu.Lock()
+		if cfg.Model != "" {
+			out.Model = cfg.Model
+		}
+		if cfg.ProviderTools != nil {
+			out.ProviderTools = append([]string(nil), cfg.ProviderTools...)
+		}
+		cfg.mu.Unlock()
+	}
+	out.ModelInfo = ai.LookupModel(out.Provider, out.Model)
+	for _, tool := range out.Tools {
+		if tool.Name == "view_image" {
+			out.Inputs.Image = out.ModelInfo.Vision
+		}
+	}
+
+	if budget := r.agentTokenBudget(agentID); budget > 0 && r.Tasks != nil {
+		metrics, err := r.AgentMetrics(ctx, agentID)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		out.RateLimits.TokensPerDay = metrics.TokenBudget
+		out.RateLimits.TokensRemaining = metrics.BudgetRemaining
+	}
+	return out, nil
+}
+
+func toolCapability(tool llmtools.Tool) ToolCapability {
+	out := ToolCapability{Name: tool.FuncName(), Label: tool.Label(), Description: tool.Description()}
+	switch grammar := tool.Grammar().(type) {
+	case llmtools.JSONGrammar:
+		out.Grammar = "json"
+		if schema := grammar.Schema(); schema != nil {
+			if data, err := json.Marshal(schema.Parameters); err == nil {
+				out.Parameters = data
+			}
+		}
+	case llmtools.LarkGrammar:
+		out.Grammar = "lark"
+	case llmtools.RegexGrammar:
+		out.Grammar = "regex"
+	default:
+		out.Grammar = "text"
+	}
+	return out
+}
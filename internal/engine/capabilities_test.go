@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestAgentCapabilities(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	client, err := ai.NewClient(ai.Config{Provider: "anthropic", Model: "claude-sonnet-4-5", APIKey: "test"},
+		agenttools.NoopTool(), agenttools.ViewImageTool())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	caps, err := rt.AgentCapabilities(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+	if caps.Provider != "anthropic" || caps.Model != "claude-sonnet-4-5" || caps.ModelInfo.ContextTokens != 200000 {
+		t.Fatalf("unexpected model %+v", caps)
+	}
+	if !caps.Inputs.Text || !caps.Inputs.Image {
+		t.Fatalf("expected text and image input, got %+v", caps.Inputs)
+	}
+	if len(caps.Tools) != 2 || caps.Tools[1].Name != "view_image" || caps.Tools[1].Grammar != "json" ||
+		!strings.Contains(string(caps.Tools[1].Parameters), `"fidelity"`) {
+		t.Fatalf("unexpected tools %+v", caps.Tools)
+	}
+	if caps.RateLimits.TokensPerDay != 0 {
+		t.Fatalf("expected no token budget, got %+v", caps.RateLimits)
+	}
+
+	rt.SetAgentModel("agent-a", "gpt-3.5-turbo")
+	rt.SetAgentTokenBudget("agent-a", 5000)
+	caps, err = rt.AgentCapabilities(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+	if caps.Model != "gpt-3.5-turbo" || caps.ModelInfo.Known || caps.Inputs.Image {
+		t.Fatalf("expected an unknown text-only model under anthropic, got %+v", caps)
+	}
+	if caps.RateLimits.TokensPerDay != 5000 || caps.RateLimits.TokensRemaining == nil || *caps.RateLimits.TokensRemaining != 5000 {
+		t.Fatalf("unexpected rate limits %+v", caps.RateLimits)
+	}
+}
+
+func TestAgentToolsetLimitsTools(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	client, err := ai.NewClient(ai.Config{Provider: "anthropic", Model: "claude-sonnet-4-5", APIKey: "test"},
+		agenttools.NoopTool(), agenttools.ViewImageTool())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "browser")
+	ctx := context.Background()
+
+	if _, err := ParseToolset(map[string]any{"allow": []any{"noop", 3}}); err == nil {
+		t.Fatalf("expected non-string tool names to be rejected")
+	}
+	if _, err := ParseToolset(map[string]any{"only": []any{"noop"}}); err == nil {
+		t.Fatalf("expected unknown fields to be rejected")
+	}
+	toolset, err := ParseToolset(map[string]any{"deny": []any{"view_image", " view_image "}})
+	if err != nil {
+		t.Fatalf("parse toolset: %v", err)
+	}
+	if _, err := rt.SetAgentToolset(ctx, "browser", toolset); err != nil {
+		t.Fatalf("set toolset: %v", err)
+	}
+	stored, err := rt.AgentToolset(ctx, "browser")
+	if err != nil || len(stored.Deny) != 1 || stored.Deny[0] != "view_image" {
+		t.Fatalf("expected the toolset in task metadata, got %+v (%v)", stored, err)
+	}
+	caps, err := rt.AgentCapabilities(ctx, "browser")
+	if err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+	if len(caps.Tools) != 1 || caps.Tools[0].Name != "noop" || caps.Inputs.Image {
+		t.Fatalf("expected view_image denied, got %+v", caps)
+	}
+
+	if _, err := rt.SetAgentToolset(ctx, "browser", Toolset{Allow: []string{"view_image"}}); err != nil {
+		t.Fatalf("set toolset: %v", err)
+	}
+	if caps, _ = rt.AgentCapabilities(ctx, "browser"); len(caps.Tools) != 1 || caps.Tools[0].Name != "view_image" {
+		t.Fatalf("expected only allowed tools, got %+v", caps.Tools)
+	}
+
+	if _, err := rt.SetAgentToolset(ctx, "browser", Toolset{}); err != nil {
+		t.Fatalf("clear toolset: %v", err)
+	}
+	if task, _ := mgr.Get(ctx, "browser"); task.Metadata["toolset"] != nil {
+		t.Fatalf("expected the toolset removed, got %+v", task.Metadata)
+	}
+	if caps, _ = rt.AgentCapabilities(ctx, "browser"); len(caps.Tools) != 2 {
+		t.Fatalf("expected every tool again, got %+v", caps.Tools)
+	}
+}
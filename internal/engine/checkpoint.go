@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// DefaultCheckpointEvery is how many tool rounds a turn runs between
+// checkpoints when the runtime does not set CheckpointEvery.
+const DefaultCheckpointEvery = 10
+
+// resumeNote is the message a resumed turn continues with.
+const resumeNote = "[runtime] The agent restarted in the middle of this turn. The conversation above is the progress saved at the last checkpoint; continue from there without repeating finished work."
+
+// turnCheckpoints tracks the checkpoints of one turn attempt. A checkpoint
+// stores the messages the turn added since the previous one, so a turn
+// resumed from an earlier attempt starts with those messages saved.
+type turnCheckpoints struct {
+	every   int
+	eventID string
+	// base is how many messages precede the turn's own, saved those of
+	// the turn already persisted and rounds the tool rounds they cover.
+	base   int
+	saved  int
+	rounds int
+}
+
+// checkpointEvery returns how many tool rounds pass between checkpoints,
+// or zero when checkpoints are off.
+func (r *Runtime) checkpointEvery() int {
+	switch {
+	case r.CheckpointEvery < 0:
+		return 0
+	case r.CheckpointEvery == 0:
+		return DefaultCheckpointEvery
+	default:
+		return r.CheckpointEvery
+	}
+}
+
+func checkpointEventID(entry AgentHistoryEntry) string {
+	id, _ := entry.Data["event_id"].(string)
+	return strings.TrimSpace(id)
+}
+
+// turnResume is what an earlier attempt at a turn saved.
+type turnResume struct {
+	// Prior is the conversation before the turn, without the entries of
+	// earlier attempts.
+	Prior []llms.Message
+	// Messages are the turn's messages saved at its checkpoints.
+	Messages []llms.Message
+	Rounds   int
+	// TaskID is the LLM task of the attempt that saved the last checkpoint.
+	TaskID string
+}
+
+// resumeTurn returns what earlier attempts at the turn for eventID saved in
+// checkpoints of the generation loadConversationMessages last read.
+func (r *Runtime) resumeTurn(ctx context.Context, agentID string, generation int64, eventID string) (turnResume, bool) {
+	eventID = strings.TrimSpace(eventID)
+	if eventID == "" || r.checkpointEvery() == 0 {
+		return turnResume{}, false
+	}
+	b := r.cachedConversation(agentID, generation)
+	if len(b.checkpoints) == 0 || checkpointEventID(b.checkpoints[0]) != eventID {
+		return turnResume{}, false
+	}
+	var resume turnResume
+	attempts := maps.Clone(b.retracted)
+	if attempts == nil {
+		attempts = map[string]bool{}
+	}
+	for _, entry := range b.checkpoints {
+		if entry.TaskID != "" {
+			attempts[entry.TaskID] = true
+		}
+		if entry.Type != "turn_checkpoint" {
+			continue
+		}
+		messages, err := decodeCheckpointMessages(entry.Data["messages"])
+		if err != nil {
+			return turnResume{}, false
+		}
+		resume.Messages = append(resume.Messages, messages...)
+		resume.Rounds = int(anyToInt64(entry.Data["rounds"]))
+		resume.TaskID = entry.TaskID
+	}
+	if len(resume.Messages) == 0 {
+		return turnResume{}, false
+	}
+	fresh, err := r.extendConversation(ctx, agentID, &conversationBuilder{generation: generation, retracted: attempts})
+	if err != nil {
+		return turnResume{}, false
+	}
+	_, resume.Prior = fresh.result()
+	return resume, true
+}
+
+// checkpoint saves the turn's messages since its last checkpoint once
+// another every tool rounds have finished. all is the whole conversation
+// the next request sends and rounds the tool rounds this attempt ran.
+func (c *turnCheckpoints) checkpoint(ctx context.Context, r *Runtime, agentID, llmTaskID string, generation int64, rounds int, all []llms.Message) {
+	if c == nil || c.every <= 0 || rounds <= 0 || rounds%c.every != 0 {
+		return
+	}
+	start := min(c.base+c.saved, len(all))
+	messages := all[start:]
+	if len(messages) == 0 {
+		return
+	}
+	total := c.rounds + rounds
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		return
+	}
+	var encoded []any
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return
+	}
+	r.appendHistory(ctx, agentID, "turn_checkpoint", "system", fmt.Sprintf("checkpoint after %d tool rounds", total), llmTaskID, generation, map[string]any{
+		"event_id": c.eventID,
+		"rounds":   total,
+		"messages": encoded,
+	})
+	r.recordTaskUpdate(ctx, llmTaskID, "turn_checkpoint", map[string]any{
+		"event_id": c.eventID,
+		"rounds":   total,
+		"messages": len(messages),
+		"bytes":    len(raw),
+	}, tasks.UpdateOptions{})
+	c.saved = len(all) - c.base
+}
+
+func decodeCheckpointMessages(raw any) ([]llms.Message, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var messages []llms.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
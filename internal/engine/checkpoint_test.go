@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// checkpointProvider calls noop with distinct arguments for its first
+// rounds, then fails the turn when crash is set or replies otherwise.
+type checkpointProvider struct {
+	mu     sync.Mutex
+	rounds int
+	crash  bool
+	calls  [][]llms.Message
+}
+
+func (p *checkpointProvider) Company() string              { return "fake" }
+func (p *checkpointProvider) Model() string                { return "fake" }
+func (p *checkpointProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *checkpointProvider) SetHTTPClient(_ *http.Client) {}
+func (p *checkpointProvider) Generate(_ context.Context, _ content.Content, messages []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, append([]llms.Message(nil), messages...))
+	n := len(p.calls)
+	switch {
+	case n <= p.rounds:
+		return newToolCallsOnlyStream([]llms.ToolCall{
+			{ID: fmt.Sprintf("step-%d", n), Name: "noop", Arguments: fmt.Appendf(nil, `{"comment":"step %d"}`, n)},
+		})
+	case p.crash:
+		return &failingLoopStream{}
+	default:
+		return newTextOnlyStream("all steps done")
+	}
+}
+
+func TestHandleMessageResumesFromLastCheckpoint(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-long")
+	meta := map[string]any{"event_id": "evt-long"}
+
+	first := &checkpointProvider{rounds: 5, crash: true}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(first, agenttools.NoopTool())})
+	rt.CheckpointEvery = 2
+	if _, err := rt.HandleMessage(ctx, "agent-long", "user", "run the migration", meta); err == nil {
+		t.Fatalf("expected the first attempt to fail")
+	}
+	if len(first.calls) != 6 {
+		t.Fatalf("expected six requests before the crash, got %d", len(first.calls))
+	}
+
+	// A fresh runtime stands in for the restarted process.
+	second := &checkpointProvider{}
+	rt = NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(second, agenttools.NoopTool())})
+	rt.CheckpointEvery = 2
+	session, err := rt.HandleMessage(ctx, "agent-long", "user", "run the migration", meta)
+	if err != nil {
+		t.Fatalf("resumed attempt: %v", err)
+	}
+	if session.LastOutput != "all steps done" {
+		t.Fatalf("unexpected output %q", session.LastOutput)
+	}
+	if len(second.calls) != 1 {
+		t.Fatalf("expected the resumed turn to finish in one request, got %d", len(second.calls))
+	}
+	resumed := second.calls[0]
+	var toolCalls []string
+	users := 0
+	for _, msg := range resumed {
+		for _, call := range msg.ToolCalls {
+			toolCalls = append(toolCalls, call.ID)
+		}
+		if msg.Role == "user" && strings.Contains(messageText(msg), "run the migration") {
+			users++
+		}
+	}
+	if strings.Join(toolCalls, ",") != "step-1,step-2,step-3,step-4" {
+		t.Fatalf("expected the four checkpointed rounds replayed, got %v", toolCalls)
+	}
+	if users != 1 {
+		t.Fatalf("expected the original message once, got %d in %+v", users, resumed)
+	}
+	if last := resumed[len(resumed)-1]; last.Role != "user" || messageText(last) != resumeNote {
+		t.Fatalf("expected the resume note last, got %+v", last)
+	}
+
+	llmTasks, err := mgr.List(ctx, tasks.ListFilter{Type: "llm", Limit: 10})
+	if err != nil || len(llmTasks) != 2 {
+		t.Fatalf("expected two llm tasks, got %d, %v", len(llmTasks), err)
+	}
+	kinds := map[string][]map[string]any{}
+	for _, task := range llmTasks {
+		updates, err := mgr.ListUpdates(ctx, task.ID, 100)
+		if err != nil {
+			t.Fatalf("list updates: %v", err)
+		}
+		for _, update := range updates {
+			if update.Kind == "turn_checkpoint" || update.Kind == "turn_resumed" {
+				kinds[update.Kind] = append(kinds[update.Kind], update.Payload)
+			}
+		}
+	}
+	var rounds []int64
+	for _, payload := range kinds["turn_checkpoint"] {
+		rounds = append(rounds, anyToInt64(payload["rounds"]))
+	}
+	slices.Sort(rounds)
+	if !slices.Equal(rounds, []int64{2, 4}) {
+		t.Fatalf("expected checkpoints after rounds 2 and 4, got %+v", kinds["turn_checkpoint"])
+	}
+	if len(kinds["turn_resumed"]) != 1 || anyToInt64(kinds["turn_resumed"][0]["rounds"]) != 4 {
+		t.Fatalf("expected one resume from round 4, got %+v", kinds["turn_resumed"])
+	}
+}
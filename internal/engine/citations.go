@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// maxContextRefSeed bounds how many context_event history entries are read
+// to restore an agent's refs after a restart.
+const maxContextRefSeed = 500
+
+// citationPattern matches inline citations such as [e12] or [e3, e7].
+var citationPattern = regexp.MustCompile(`\[(e\d+(?:\s*,\s*e\d+)*)\]`)
+
+// Citation links a ref cited by the model to the context event it names.
+type Citation struct {
+	Ref     string `json:"ref"`
+	EventID string `json:"event_id"`
+}
+
+// contextRefs gives an agent's context events short stable refs (e1, e2, …)
+// the model can cite instead of full event IDs.
+type contextRefs struct {
+	next    int
+	byEvent map[string]string
+	byRef   map[string]string
+}
+
+// assignContextRefs returns the ref for each event, assigning new refs in
+// order to events the agent has not seen before.
+func (r *Runtime) assignContextRefs(ctx context.Context, agentID string, events []eventbus.Event) map[string]string {
+	if strings.TrimSpace(agentID) == "" || len(events) == 0 {
+		return nil
+	}
+	table := r.loadContextRefs(ctx, agentID)
+	r.contextRefMu.Lock()
+	defer r.contextRefMu.Unlock()
+	out := make(map[string]string, len(events))
+	for _, evt := range events {
+		if evt.ID == "" {
+			continue
+		}
+		ref, ok := table.byEvent[evt.ID]
+		if !ok {
+			table.next++
+			ref = "e" + strconv.Itoa(table.next)
+			table.byEvent[evt.ID] = ref
+			table.byRef[ref] = evt.ID
+		}
+		out[evt.ID] = ref
+	}
+	return out
+}
+
+// resolveCitations maps the refs cited in text back to event IDs, in order of
+// first citation. Unknown refs are dropped.
+func (r *Runtime) resolveCitations(ctx context.Context, agentID, text string) []Citation {
+	matches := citationPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 || strings.TrimSpace(agentID) == "" {
+		return nil
+	}
+	table := r.loadContextRefs(ctx, agentID)
+	r.contextRefMu.Lock()
+	defer r.contextRefMu.Unlock()
+	seen := map[string]struct{}{}
+	var out []Citation
+	for _, m := range matches {
+		for _, ref := range strings.Split(m[1], ",") {
+			ref = strings.TrimSpace(ref)
+			if _, ok := seen[ref]; ok {
+				continue
+			}
+			seen[ref] = struct{}{}
+			if eventID, ok := table.byRef[ref]; ok {
+				out = append(out, Citation{Ref: ref, EventID: eventID})
+			}
+		}
+	}
+	return out
+}
+
+// loadContextRefs returns the agent's ref table, restoring it from recent
+// context_event history on first use.
+func (r *Runtime) loadContextRefs(ctx context.Context, agentID string) *contextRefs {
+	r.contextRefMu.Lock()
+	if table, ok := r.contextRefs[agentID]; ok {
+		r.contextRefMu.Unlock()
+		return table
+	}
+	r.contextRefMu.Unlock()
+
+	table := &contextRefs{byEvent: map[string]string{}, byRef: map[string]string{}}
+	for _, entry := range r.recentContextEventEntries(ctx, agentID) {
+		ref, eventID := mapString(entry.Data, "ref"), mapString(entry.Data, "event_id")
+		n, err := strconv.Atoi(strings.TrimPrefix(ref, "e"))
+		if err != nil || !strings.HasPrefix(ref, "e") || eventID == "" {
+			continue
+		}
+		table.byEvent[eventID] = ref
+		table.byRef[ref] = eventID
+		table.next = max(table.next, n)
+	}
+
+	r.contextRefMu.Lock()
+	defer r.contextRefMu.Unlock()
+	if r.contextRefs == nil {
+		r.contextRefs = map[string]*contextRefs{}
+	}
+	if existing, ok := r.contextRefs[agentID]; ok {
+		return existing
+	}
+	r.contextRefs[agentID] = table
+	return table
+}
+
+func (r *Runtime) recentContextEventEntries(ctx context.Context, agentID string) []AgentHistoryEntry {
+	if r.Bus == nil {
+		return nil
+	}
+	summaries, err := r.Bus.List(ctx, "history", eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Limit:     maxContextRefSeed,
+		Order:     "lifo",
+		Fields:    []eventbus.FieldFilter{{Column: "payload", Path: []string{"type"}, Values: []string{"context_event"}}},
+	})
+	if err != nil || len(summaries) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, item := range summaries {
+		ids = append(ids, item.ID)
+	}
+	events, err := r.Bus.Read(ctx, "history", ids, "")
+	if err != nil {
+		return nil
+	}
+	out := make([]AgentHistoryEntry, 0, len(events))
+	for _, evt := range events {
+		if entry, ok := HistoryEntryFromEvent(evt); ok {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
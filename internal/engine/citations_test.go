@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestContextRefsAreStableAndResolveCitations(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, nil, nil)
+	now := time.Now().UTC()
+	evtA := eventbus.Event{ID: "evt-a", Stream: "messages_in", Body: "deploy at 5", CreatedAt: now}
+	evtB := eventbus.Event{ID: "evt-b", Stream: "task_output", Body: "build failed", CreatedAt: now}
+	evtC := eventbus.Event{ID: "evt-c", Stream: "task_output", Body: "build fixed", CreatedAt: now}
+
+	first := ContextUpdateFrame{Events: []eventbus.Event{evtA, evtB}}
+	first.Refs = rt.assignContextRefs(ctx, "agent-a", first.Events)
+	if first.Refs["evt-a"] != "e1" || first.Refs["evt-b"] != "e2" {
+		t.Fatalf("unexpected refs: %+v", first.Refs)
+	}
+	rt.appendContextUpdateHistory(ctx, "agent-a", "llm-1", 1, TurnContext{Now: now}, first)
+
+	xml := renderContextUpdatesXML(TurnContext{Now: now}, first)
+	if !strings.Contains(xml, `<event ref="e2" stream="task_output"`) {
+		t.Fatalf("expected ref attribute in context updates, got: %q", xml)
+	}
+
+	// A fresh runtime restores refs from history, so earlier citations still
+	// resolve and new events continue the sequence.
+	restarted := NewRuntime(bus, nil, nil)
+	refs := restarted.assignContextRefs(ctx, "agent-a", []eventbus.Event{evtB, evtC})
+	if refs["evt-b"] != "e2" || refs["evt-c"] != "e3" {
+		t.Fatalf("expected refs to survive restart, got %+v", refs)
+	}
+
+	citations := restarted.resolveCitations(ctx, "agent-a", "The build broke [e2] after the request [e1, e3]; see also [e9] and [e2].")
+	want := []Citation{{Ref: "e2", EventID: "evt-b"}, {Ref: "e1", EventID: "evt-a"}, {Ref: "e3", EventID: "evt-c"}}
+	if len(citations) != len(want) {
+		t.Fatalf("expected %d citations, got %+v", len(want), citations)
+	}
+	for i := range want {
+		if citations[i] != want[i] {
+			t.Fatalf("citation %d: expected %+v, got %+v", i, want[i], citations[i])
+		}
+	}
+	if got := restarted.resolveCitations(ctx, "agent-b", "[e1]"); len(got) != 0 {
+		t.Fatalf("expected refs to be scoped per agent, got %+v", got)
+	}
+}
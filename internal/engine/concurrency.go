@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// MaxAgentConcurrency is the most turns an agent may run in parallel.
+const MaxAgentConcurrency = 8
+
+// releasedLane owns the claims of finished turns until the dispatcher next
+// reads the agent's events, by when they are acked.
+const releasedLane = -1
+
+// turnLanes tracks the parallel turns of an agent whose concurrency is
+// above one. Each turn runs in a numbered lane from 1; the events a lane's
+// turn has taken are claimed so no other lane reads them.
+type turnLanes struct {
+	busy   map[int]string
+	claims map[string]int
+	// done is signaled when a lane frees up; crashed receives the panic
+	// of a lane's turn so the agent's loop can crash with it.
+	done    chan struct{}
+	crashed chan loopPanic
+}
+
+type turnLaneKey struct{}
+
+func withTurnLane(ctx context.Context, lane int) context.Context {
+	return context.WithValue(ctx, turnLaneKey{}, lane)
+}
+
+// turnLaneFromContext returns the lane of the turn ctx belongs to, or zero
+// outside a parallel turn.
+func turnLaneFromContext(ctx context.Context) int {
+	lane, _ := ctx.Value(turnLaneKey{}).(int)
+	return lane
+}
+
+// ValidateAgentConcurrency checks a concurrency setting.
+func ValidateAgentConcurrency(n int) error {
+	if n < 1 || n > MaxAgentConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", MaxAgentConcurrency)
+	}
+	return nil
+}
+
+// SetAgentConcurrency sets how many wake events the agent may handle in
+// parallel turns. One, the default, serializes turns; only agents that keep
+// no state across turns should run more.
+func (r *Runtime) SetAgentConcurrency(taskID string, n int) error {
+	if err := ValidateAgentConcurrency(n); err != nil {
+		return err
+	}
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return nil
+	}
+	cfg.mu.Lock()
+	cfg.Concurrency = n
+	cfg.mu.Unlock()
+	return nil
+}
+
+// AgentConcurrency returns the agent's concurrency setting.
+func (r *Runtime) AgentConcurrency(taskID string) int {
+	r.configMu.RLock()
+	cfg := r.taskConfigs[taskID]
+	r.configMu.RUnlock()
+	if cfg == nil {
+		return 1
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return max(cfg.Concurrency, 1)
+}
+
+// agentLanes returns the agent's lane state, creating it when create is set.
+func (r *Runtime) agentLanes(agentID string, create bool) *turnLanes {
+	r.lanesMu.Lock()
+	defer r.lanesMu.Unlock()
+	lanes, ok := r.lanes[agentID]
+	if !ok && create {
+		if r.lanes == nil {
+			r.lanes = map[string]*turnLanes{}
+		}
+		lanes = &turnLanes{
+			busy:    map[int]string{},
+			claims:  map[string]int{},
+			done:    make(chan struct{}, 1),
+			crashed: make(chan loopPanic, 1),
+		}
+		r.lanes[agentID] = lanes
+	}
+	return lanes
+}
+
+// busyLanes returns how many of the agent's parallel turns are running.
+func (r *Runtime) busyLanes(agentID string) int {
+	r.lanesMu.Lock()
+	defer r.lanesMu.Unlock()
+	if lanes, ok := r.lanes[agentID]; ok {
+		return len(lanes.busy)
+	}
+	return 0
+}
+
+// pruneReleasedClaims forgets the claims of finished turns. Only the
+// dispatcher calls it, before reading events, so an event a turn acked is
+// never seen unread and unclaimed.
+func (r *Runtime) pruneReleasedClaims(agentID string) {
+	r.lanesMu.Lock()
+	defer r.lanesMu.Unlock()
+	lanes, ok := r.lanes[agentID]
+	if !ok {
+		return
+	}
+	for key, owner := range lanes.claims {
+		if owner == releasedLane {
+			delete(lanes.claims, key)
+		}
+	}
+}
+
+// claimContextEvents drops the events other lanes have claimed and claims
+// the rest for ctx's lane. A parallel turn leaves unclaimed wake and
+// interrupt events alone, since each of those gets a turn of its own.
+// Outside a parallel turn every claimed event is dropped.
+func (r *Runtime) claimContextEvents(ctx context.Context, agentID string, events []eventbus.Event) []eventbus.Event {
+	lane := turnLaneFromContext(ctx)
+	r.lanesMu.Lock()
+	defer r.lanesMu.Unlock()
+	lanes, ok := r.lanes[agentID]
+	if !ok || (lane == 0 && len(lanes.claims) == 0) {
+		return events
+	}
+	out := events[:0]
+	for _, evt := range events {
+		key := evt.Stream + ":" + evt.ID
+		owner, claimed := lanes.claims[key]
+		switch {
+		case claimed && owner != lane:
+			continue
+		case claimed || lane == 0:
+		case schema.ParsePriority(eventPriorityForEvent(evt)).Wakes():
+			continue
+		default:
+			lanes.claims[key] = lane
+		}
+		out = append(out, evt)
+	}
+	return out
+}
+
+// dispatchTurnLanes starts a parallel turn for each wake or interrupt in
+// events while the agent has free lanes, and returns how many started.
+func (r *Runtime) dispatchTurnLanes(ctx context.Context, agentID string, events []eventbus.Event, limit int, silenced bool) int {
+	lanes := r.agentLanes(agentID, true)
+	type start struct {
+		lane int
+		evt  eventbus.Event
+	}
+	var starts []start
+	var deferred []string
+	r.lanesMu.Lock()
+	for _, evt := range events {
+		priority := eventPriorityForEvent(evt)
+		if priority != "wake" && priority != "interrupt" {
+			continue
+		}
+		key := evt.Stream + ":" + evt.ID
+		if _, ok := lanes.claims[key]; ok {
+			continue
+		}
+		if silenced && priority == "wake" {
+			deferred = append(deferred, evt.ID)
+			continue
+		}
+		if len(lanes.busy) >= limit {
+			continue
+		}
+		lane := 1
+		for ; lanes.busy[lane] != ""; lane++ {
+		}
+		lanes.busy[lane] = key
+		lanes.claims[key] = lane
+		starts = append(starts, start{lane: lane, evt: evt})
+	}
+	r.lanesMu.Unlock()
+	for _, id := range deferred {
+		r.deferWake(agentID, id)
+	}
+	for _, s := range starts {
+		go r.runTurnLane(ctx, agentID, lanes, s.lane, s.evt)
+	}
+	return len(starts)
+}
+
+// runTurnLane handles evt in lane, then releases the lane and its claims.
+func (r *Runtime) runTurnLane(ctx context.Context, agentID string, lanes *turnLanes, lane int, evt eventbus.Event) {
+	failed := false
+	defer func() {
+		if p := recover(); p != nil {
+			failed = true
+			r.setHandlingEvent(agentID, &evt)
+			select {
+			case lanes.crashed <- asLoopPanic(p):
+			default:
+			}
+		}
+		r.lanesMu.Lock()
+		delete(lanes.busy, lane)
+		for key, owner := range lanes.claims {
+			if owner == lane {
+				lanes.claims[key] = releasedLane
+			}
+		}
+		r.lanesMu.Unlock()
+		if failed {
+			return
+		}
+		select {
+		case lanes.done <- struct{}{}:
+		default:
+		}
+	}()
+	source, meta := wakeTurnMessage(evt)
+	// A failed turn leaves its event unread; the replay ticker retries it
+	// rather than the loop spinning on it.
+	_, err := r.HandleMessage(withTurnLane(ctx, lane), agentID, source, evt.Body, meta)
+	failed = err != nil
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// gatedProvider holds every generation until release is closed and records
+// how many ran at once.
+type gatedProvider struct {
+	release chan struct{}
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (p *gatedProvider) Company() string              { return "gated" }
+func (p *gatedProvider) Model() string                { return "gated" }
+func (p *gatedProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *gatedProvider) SetHTTPClient(_ *http.Client) {}
+func (p *gatedProvider) Generate(ctx context.Context, _ content.Content, _ []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	return &gatedStream{ctx: ctx, p: p}
+}
+
+func (p *gatedProvider) counts() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running, p.peak
+}
+
+type gatedStream struct {
+	ctx context.Context
+	p   *gatedProvider
+}
+
+func (s *gatedStream) Err() error { return s.ctx.Err() }
+func (s *gatedStream) Message() llms.Message {
+	return llms.Message{Role: "assistant", Content: content.FromText("ok")}
+}
+func (s *gatedStream) Text() string             { return "ok" }
+func (s *gatedStream) Image() (string, string)  { return "", "" }
+func (s *gatedStream) Thought() content.Thought { return content.Thought{} }
+func (s *gatedStream) ToolCall() llms.ToolCall  { return llms.ToolCall{} }
+func (s *gatedStream) Usage() llms.Usage        { return llms.Usage{} }
+func (s *gatedStream) Iter() func(func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		s.p.mu.Lock()
+		s.p.running++
+		s.p.peak = max(s.p.peak, s.p.running)
+		s.p.mu.Unlock()
+		defer func() {
+			s.p.mu.Lock()
+			s.p.running--
+			s.p.mu.Unlock()
+		}()
+		select {
+		case <-s.p.release:
+			yield(llms.StreamStatusText)
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAgentConcurrencyRunsTurnsInLanes(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &gatedProvider{release: make(chan struct{})}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider)})
+	rt.baseCtx = ctx
+	rt.LLMFactory = func() (*llms.LLM, error) { return llms.New(provider), nil }
+	createTestAgent(t, mgr, "responder")
+	if err := rt.SetAgentConcurrency("responder", MaxAgentConcurrency+1); err == nil {
+		t.Fatalf("expected a concurrency above the maximum to be rejected")
+	}
+	if err := rt.SetAgentConcurrency("responder", 2); err != nil {
+		t.Fatalf("set concurrency: %v", err)
+	}
+
+	var ids []string
+	for _, body := range []string{"one", "two", "three"} {
+		evt, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    schema.StreamTaskInput,
+			ScopeType: "task",
+			ScopeID:   "responder",
+			Body:      body,
+			Metadata:  map[string]any{"source": "external", "target": "responder", "kind": "message"},
+		})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		ids = append(ids, evt.ID)
+	}
+	rt.EnsureAgentLoop("responder")
+
+	waitFor(t, "two parallel turns", func() bool {
+		running, _ := provider.counts()
+		return running == 2
+	})
+	time.Sleep(600 * time.Millisecond)
+	if _, peak := provider.counts(); peak != 2 {
+		t.Fatalf("expected at most two turns at once, got %d", peak)
+	}
+	close(provider.release)
+
+	waitFor(t, "all messages read", func() bool {
+		events, err := bus.Read(ctx, schema.StreamTaskInput, ids, "responder")
+		if err != nil {
+			return false
+		}
+		for _, evt := range events {
+			if !evt.Read {
+				return false
+			}
+		}
+		return true
+	})
+	var entries []AgentHistoryEntry
+	waitFor(t, "three replies", func() bool {
+		entries, _ = rt.readHistoryEntries(ctx, "responder")
+		replies := 0
+		for _, entry := range entries {
+			if entry.Type == "assistant_message" {
+				replies++
+			}
+		}
+		return replies == 3
+	})
+	lanes := map[string]float64{}
+	for _, entry := range entries {
+		if entry.Type != "user_message" {
+			continue
+		}
+		lane, _ := entry.Data["lane"].(float64)
+		if lane < 1 || lane > 2 {
+			t.Fatalf("expected %q in lane 1 or 2, got %v", entry.Content, entry.Data["lane"])
+		}
+		lanes[entry.Content] = lane
+	}
+	if len(lanes) != 3 || lanes["one"] == lanes["two"] {
+		t.Fatalf("expected the first two messages in separate lanes, got %v", lanes)
+	}
+}
+
+func TestAgentConcurrencyDefaultsToSerialTurns(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &gatedProvider{release: make(chan struct{})}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider)})
+	rt.baseCtx = ctx
+	rt.LLMFactory = func() (*llms.LLM, error) { return llms.New(provider), nil }
+	createTestAgent(t, mgr, "stateful")
+	if n := rt.AgentConcurrency("stateful"); n != 1 {
+		t.Fatalf("expected a default concurrency of 1, got %d", n)
+	}
+	for _, body := range []string{"one", "two"} {
+		if _, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    schema.StreamTaskInput,
+			ScopeType: "task",
+			ScopeID:   "stateful",
+			Body:      body,
+			Metadata:  map[string]any{"source": "external", "target": "stateful", "kind": "message"},
+		}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	rt.EnsureAgentLoop("stateful")
+
+	waitFor(t, "a turn", func() bool {
+		running, _ := provider.counts()
+		return running == 1
+	})
+	time.Sleep(600 * time.Millisecond)
+	if _, peak := provider.counts(); peak != 1 {
+		t.Fatalf("expected serialized turns, got %d at once", peak)
+	}
+	close(provider.release)
+	entries, _ := rt.readHistoryEntries(ctx, "stateful")
+	for _, entry := range entries {
+		if _, ok := entry.Data["lane"]; ok {
+			t.Fatalf("expected no lane on serialized turns, got %+v", entry)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flitsinc/go-agents/internal/documents"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const (
+	// contextSummaryInputChars caps the transcript a summarizer reads; the
+	// most recent part is kept.
+	contextSummaryInputChars = 60000
+	// contextSummaryFallbackChars is how much of the transcript's end stands
+	// in for a summary when there is no summarizer or it fails.
+	contextSummaryFallbackChars = 4000
+)
+
+const contextSummaryPrompt = `You condense the conversation of an AI agent whose context window is full. Your summary replaces the conversation, so the agent continues from it alone.
+Keep what the agent needs to carry on: who it is talking to, open requests and promises, decisions made, work in progress with task IDs, identifiers, file paths, numbers and errors. Drop greetings and finished chatter.
+Write plain prose or bullets, at most 400 words, in the second person ("You were asked to...").`
+
+// ContextSummarizer condenses a conversation that outgrew its budget into the
+// summary that opens the agent's next generation.
+type ContextSummarizer interface {
+	SummarizeContext(ctx context.Context, transcript string) (string, error)
+}
+
+// LLMContextSummarizer asks a model for the summary. NewSession should return
+// a session without tools.
+type LLMContextSummarizer struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (s LLMContextSummarizer) SummarizeContext(ctx context.Context, transcript string) (string, error) {
+	if s.NewSession == nil {
+		return "", fmt.Errorf("summarizer has no llm")
+	}
+	llm, err := s.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("create summary session: %w", err)
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(contextSummaryPrompt) }
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, transcript) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return "", fmt.Errorf("summarize context: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// contextBudget returns the token budget for a turn on model, or zero when
+// the model has none. ContextBudgets["*"] applies to unlisted models.
+func (r *Runtime) contextBudget(model string) int {
+	if budget, ok := r.ContextBudgets[strings.TrimSpace(model)]; ok {
+		return budget
+	}
+	return r.ContextBudgets["*"]
+}
+
+// turnModel names the model a turn runs on: the message's override, then
+// the agent's model, then the client default.
+func (r *Runtime) turnModel(cfg *taskConfig, override ModelOverride) string {
+	if override.Model != "" {
+		return override.Model
+	}
+	if cfg != nil {
+		cfg.mu.Lock()
+		model := cfg.Model
+		cfg.mu.Unlock()
+		if model != "" {
+			return model
+		}
+	}
+	return r.LLM.Model()
+}
+
+// estimateContextTokens approximates the tokens of a system prompt and the
+// prior conversation sent with it.
+func estimateContextTokens(prompt string, messages []llms.Message) int {
+	tokens := documents.EstimateTokens(prompt)
+	for _, msg := range messages {
+		tokens += documents.EstimateTokens(textFromContent(msg.Content))
+	}
+	return tokens
+}
+
+// enforceContextBudget compacts agentID's context when the prompt and prior
+// messages of the coming turn exceed the model's budget. A summary of the
+// conversation is recorded as the new generation's first entry, so it is
+// carried into the system prompt of every turn that follows. It returns the
+// generation the turn runs in and whether it compacted.
+func (r *Runtime) enforceContextBudget(ctx context.Context, agentID string, generation int64, model, prompt string, messages []llms.Message) (int64, bool) {
+	budget := r.contextBudget(model)
+	if budget <= 0 || len(messages) < 2 {
+		return generation, false
+	}
+	estimated := estimateContextTokens(prompt, messages)
+	if estimated <= budget {
+		return generation, false
+	}
+	transcript := conversationTranscript(messages)
+	summary, source := "", "summarizer"
+	if r.ContextSummarizer != nil {
+		if text, err := r.ContextSummarizer.SummarizeContext(ctx, tailText(transcript, contextSummaryInputChars)); err == nil {
+			summary = text
+		}
+	}
+	if strings.TrimSpace(summary) == "" {
+		// The end of the conversation stands in for the summary.
+		summary, source = tailText(transcript, contextSummaryFallbackChars), "transcript_tail"
+	}
+	reason := fmt.Sprintf("context budget exceeded: about %d tokens for a budget of %d on %s", estimated, budget, model)
+	next, err := r.CompactAgentContext(ctx, agentID, reason)
+	if err != nil {
+		return generation, false
+	}
+	r.appendHistory(ctx, agentID, "context_summary", "system", summary, "", next, map[string]any{
+		"previous_gen":     generation,
+		"estimated_tokens": estimated,
+		"budget":           budget,
+		"model":            model,
+		"summary_source":   source,
+	})
+	return next, true
+}
+
+// conversationTranscript renders messages as plain text for a summarizer.
+func conversationTranscript(messages []llms.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		text := strings.TrimSpace(textFromContent(msg.Content))
+		if text == "" {
+			continue
+		}
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(role + ": " + text)
+	}
+	return b.String()
+}
+
+// tailText keeps the last limit bytes of text, starting at a line break
+// where one is near.
+func tailText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	start := len(text) - limit
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	tail := text[start:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < limit/4 {
+		tail = tail[i+1:]
+	}
+	return "… " + strings.TrimSpace(tail)
+}
+
+// withContextSummary adds the summary a compaction carried over to the
+// system prompt.
+func withContextSummary(prompt, summary string) string {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return prompt
+	}
+	return prompt + "\n\n<context_summary>\nYour earlier conversation was compacted to fit the context window. This is what it covered:\n" + summary + "\n</context_summary>"
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/documents"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+type stubContextSummarizer struct {
+	transcripts []string
+}
+
+func (s *stubContextSummarizer) SummarizeContext(_ context.Context, transcript string) (string, error) {
+	s.transcripts = append(s.transcripts, transcript)
+	return "You were asked for the llama census and promised it by Friday.", nil
+}
+
+func TestHandleMessageCompactsOverContextBudget(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &historyCapture{}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider)})
+	summarizer := &stubContextSummarizer{}
+	rt.ContextSummarizer = summarizer
+
+	ctx := context.Background()
+	agentID := "agent-budget"
+	createTestAgent(t, mgr, agentID)
+	_, prompt, err := rt.Context.BuildSystemPrompt(ctx, bus)
+	if err != nil {
+		t.Fatalf("build prompt: %v", err)
+	}
+	// Room for the prompt and one short exchange, not a long one.
+	rt.ContextBudgets = map[string]int{"*": documents.EstimateTokens(prompt) + 500}
+	systemText := func(i int) string {
+		return textFromContent(content.Content(provider.SystemPrompt(i)))
+	}
+
+	long := "Count the llamas. " + strings.Repeat("llama ", 800)
+	for _, text := range []string{long, "How is it going?", "Thanks."} {
+		if sess, err := rt.HandleMessage(ctx, agentID, "user", text, nil); err != nil || sess.LastError != "" {
+			t.Fatalf("HandleMessage(%.20q): err=%v session=%+v", text, err, sess)
+		}
+	}
+
+	if len(summarizer.transcripts) != 1 || !strings.Contains(summarizer.transcripts[0], "Count the llamas.") {
+		t.Fatalf("expected one summary of the long turn, got %d", len(summarizer.transcripts))
+	}
+	if got := len(provider.Call(1)); got != 1 {
+		t.Fatalf("expected the compacted turn to send only its input, got %d messages", got)
+	}
+	for _, i := range []int{1, 2} {
+		if got := systemText(i); !strings.Contains(got, "<context_summary>") || !strings.Contains(got, "llama census") {
+			t.Fatalf("expected call %d to carry the summary in its system prompt, got %q", i, got)
+		}
+	}
+	if strings.Contains(systemText(0), "<context_summary>") {
+		t.Fatalf("expected no summary before the compaction")
+	}
+	if got := len(provider.Call(2)); got != 3 {
+		t.Fatalf("expected the next turn to continue the new generation, got %d messages", got)
+	}
+	if gen := rt.historyGeneration(ctx, agentID); gen != 2 {
+		t.Fatalf("expected generation 2, got %d", gen)
+	}
+
+	entries, err := rt.readHistoryEntries(ctx, agentID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	var summary *AgentHistoryEntry
+	for i := range entries {
+		if entries[i].Type == "context_summary" && entries[i].Generation == 2 {
+			summary = &entries[i]
+		}
+	}
+	if summary == nil || summary.Data["summary_source"] != "summarizer" || anyToInt64(summary.Data["previous_gen"]) != 1 {
+		t.Fatalf("expected a context_summary entry in generation 2, got %+v", summary)
+	}
+}
+
+func TestEnforceContextBudgetFallsBackToTranscriptTail(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, tasks.NewManager(db, bus), nil)
+	rt.ContextBudgets = map[string]int{"small": 5}
+	ctx := context.Background()
+	messages := []llms.Message{
+		{Role: "user", Content: content.FromText("Please file the report.")},
+		{Role: "assistant", Content: content.FromText("Filed as REP-7.")},
+	}
+
+	if gen, ok := rt.enforceContextBudget(ctx, "agent-a", 1, "large", "", messages); ok || gen != 1 {
+		t.Fatalf("expected models without a budget to be left alone")
+	}
+	gen, ok := rt.enforceContextBudget(ctx, "agent-a", 1, "small", "", messages)
+	if !ok || gen != 2 {
+		t.Fatalf("expected compaction to generation 2, got %d %v", gen, ok)
+	}
+	rt.forgetConversation("agent-a")
+	if _, _, err := rt.loadConversationMessages(ctx, "agent-a", 2); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := rt.conversationSummary("agent-a", 2); !strings.Contains(got, "Assistant: Filed as REP-7.") {
+		t.Fatalf("expected the transcript to stand in for the summary, got %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// contextLengthMarkers are substrings providers use when a request exceeds the
+// model's context window.
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context window",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+	"exceeds the maximum number of tokens",
+	"input token count",
+}
+
+func isContextLengthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneConversation drops the older half of messages. The kept tail always
+// starts with a user message, as providers require.
+func pruneConversation(messages []llms.Message) (kept []llms.Message, dropped int) {
+	cut := len(messages) / 2
+	for cut < len(messages) && messages[cut].Role != "user" {
+		cut++
+	}
+	return messages[cut:], cut
+}
+
+// recordContextPruned notes in history that the first dropped messages of the
+// generation no longer go to the provider. loadConversationMessages applies
+// the same cut on later turns.
+func (r *Runtime) recordContextPruned(ctx context.Context, agentID, llmTaskID string, generation int64, dropped []llms.Message, kept int, cause error) {
+	droppedChars := 0
+	for _, msg := range dropped {
+		droppedChars += len(textFromContent(msg.Content))
+	}
+	data := map[string]any{
+		"dropped_messages": len(dropped),
+		"dropped_chars":    droppedChars,
+		"kept_messages":    kept,
+		"error":            cause.Error(),
+	}
+	r.recordLLMUpdate(ctx, llmTaskID, "llm_context_pruned", data)
+	note := fmt.Sprintf("Provider rejected the request for exceeding the context window. Dropped %d earlier message(s) (%d chars) and retried.", len(dropped), droppedChars)
+	r.appendHistory(ctx, agentID, "context_pruned", "system", note, llmTaskID, generation, data)
+}
+
+// chatWithContextRetry streams a chat turn. If the provider rejects the
+// request for its length before producing anything, the prior messages are
+// pruned once, *prior is updated to the kept tail, and the turn is retried on
+// the same stream. llm.Err reports the outcome of the last attempt once the
+// returned channel closes.
+func (r *Runtime) chatWithContextRetry(ctx context.Context, llm *llms.LLM, agentID, llmTaskID string, generation int64, prior *[]llms.Message, input llms.Message) <-chan llms.Update {
+	out := make(chan llms.Update)
+	go func() {
+		defer close(out)
+		for attempt := 0; ; attempt++ {
+			messages := make([]llms.Message, 0, len(*prior)+1)
+			messages = append(messages, *prior...)
+			messages = append(messages, input)
+			received := false
+			for update := range llm.ChatUsingMessages(ctx, messages) {
+				received = true
+				out <- update
+			}
+			err := llm.Err()
+			if attempt > 0 || received || len(*prior) == 0 || !isContextLengthError(err) {
+				return
+			}
+			kept, dropped := pruneConversation(*prior)
+			if dropped == 0 {
+				return
+			}
+			r.recordContextPruned(ctx, agentID, llmTaskID, generation, (*prior)[:dropped], len(kept), err)
+			*prior = kept
+		}
+	}()
+	return out
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// contextLimitProvider rejects requests with more than limit messages.
+type contextLimitProvider struct {
+	limit int
+	mu    sync.Mutex
+	calls [][]llms.Message
+}
+
+func (p *contextLimitProvider) Company() string              { return "limit" }
+func (p *contextLimitProvider) Model() string                { return "limit" }
+func (p *contextLimitProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *contextLimitProvider) SetHTTPClient(_ *http.Client) {}
+func (p *contextLimitProvider) Generate(_ context.Context, _ content.Content, messages []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	clone := make([]llms.Message, len(messages))
+	copy(clone, messages)
+	p.mu.Lock()
+	p.calls = append(p.calls, clone)
+	p.mu.Unlock()
+	if len(messages) > p.limit {
+		return &errorStream{err: errors.New("context_length_exceeded: maximum context length is 4 messages")}
+	}
+	return &captureStream{}
+}
+
+func (p *contextLimitProvider) Calls() [][]llms.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]llms.Message(nil), p.calls...)
+}
+
+type errorStream struct {
+	err error
+}
+
+func (s *errorStream) Err() error               { return s.err }
+func (s *errorStream) Message() llms.Message    { return llms.Message{Role: "assistant"} }
+func (s *errorStream) Text() string             { return "" }
+func (s *errorStream) Image() (string, string)  { return "", "" }
+func (s *errorStream) Thought() content.Thought { return content.Thought{} }
+func (s *errorStream) ToolCall() llms.ToolCall  { return llms.ToolCall{} }
+func (s *errorStream) Usage() llms.Usage        { return llms.Usage{} }
+func (s *errorStream) Iter() func(func(llms.StreamStatus) bool) {
+	return func(func(llms.StreamStatus) bool) {}
+}
+
+func TestPruneConversationKeepsUserFirst(t *testing.T) {
+	msgs := []llms.Message{
+		{Role: "user", Content: content.FromText("a")},
+		{Role: "assistant", Content: content.FromText("b")},
+		{Role: "assistant", Content: content.FromText("c")},
+		{Role: "user", Content: content.FromText("d")},
+		{Role: "assistant", Content: content.FromText("e")},
+	}
+	kept, dropped := pruneConversation(msgs)
+	if dropped != 3 || len(kept) != 2 || kept[0].Role != "user" {
+		t.Fatalf("unexpected prune: dropped=%d kept=%d", dropped, len(kept))
+	}
+	if isContextLengthError(errors.New("rate limited")) {
+		t.Fatalf("rate limit should not count as a context length error")
+	}
+	if !isContextLengthError(errors.New("prompt is too long: 210000 tokens > 200000 maximum")) {
+		t.Fatalf("expected anthropic context error to be detected")
+	}
+}
+
+func TestHandleMessagePrunesAndRetriesOnContextLength(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &contextLimitProvider{limit: 4}
+	client := &ai.Client{LLM: llms.New(provider)}
+	rt := NewRuntime(bus, mgr, client)
+
+	ctx := context.Background()
+	agentID := "agent-prune"
+	createTestAgent(t, mgr, agentID)
+
+	for _, text := range []string{"first", "second", "third"} {
+		sess, err := rt.HandleMessage(ctx, agentID, "user", text, nil)
+		if err != nil {
+			t.Fatalf("HandleMessage(%q): %v", text, err)
+		}
+		if sess.LastError != "" || sess.LastOutput != "ok" {
+			t.Fatalf("turn %q: output=%q error=%q", text, sess.LastOutput, sess.LastError)
+		}
+	}
+
+	calls := provider.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 provider calls (one retry), got %d", len(calls))
+	}
+	if len(calls[2]) != 5 || len(calls[3]) != 3 {
+		t.Fatalf("expected retry to shrink 5 messages to 3, got %d then %d", len(calls[2]), len(calls[3]))
+	}
+	if !strings.Contains(messageText(calls[3][0]), "second") {
+		t.Fatalf("expected retry to start at the second turn, got %q", messageText(calls[3][0]))
+	}
+
+	summaries, err := bus.List(ctx, "history", eventbus.ListOptions{ScopeType: "task", ScopeID: agentID, Limit: 500})
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	ids := make([]string, len(summaries))
+	for i, s := range summaries {
+		ids[i] = s.ID
+	}
+	events, err := bus.Read(ctx, "history", ids, "")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	var pruned *AgentHistoryEntry
+	for _, evt := range events {
+		entry, ok := HistoryEntryFromEvent(evt)
+		if ok && entry.Type == "context_pruned" {
+			pruned = &entry
+		}
+	}
+	if pruned == nil {
+		t.Fatalf("expected context_pruned history entry")
+	}
+	if got := anyToInt64(pruned.Data["dropped_messages"]); got != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", got)
+	}
+
+	updates, err := mgr.ListUpdates(ctx, pruned.TaskID, 200)
+	if err != nil {
+		t.Fatalf("list updates: %v", err)
+	}
+	found := false
+	for _, u := range updates {
+		if u.Kind == "llm_context_pruned" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected llm_context_pruned update on %s", pruned.TaskID)
+	}
+
+	// The next turn starts from the pruned history rather than the full one.
+	if _, err := rt.HandleMessage(ctx, agentID, "user", "fourth", nil); err != nil {
+		t.Fatalf("fourth HandleMessage: %v", err)
+	}
+	calls = provider.Calls()
+	if got := messageText(calls[4][0]); strings.Contains(got, "first") {
+		t.Fatalf("expected pruned turn to stay dropped, got %q", got)
+	}
+}
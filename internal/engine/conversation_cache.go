@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// conversationPageSize is how many history entries are read per query when
+// rebuilding or extending a cached conversation.
+const conversationPageSize = 2000
+
+// conversationBuilder folds history entries of one generation into provider
+// messages. It remembers the last history entry it has seen so a cached
+// builder can be extended with only the entries appended since.
+type conversationBuilder struct {
+	generation   int64
+	lastID       string
+	storedPrompt string
+	messages     []llms.Message
+	pendingRole  string
+	pendingText  string
+	// summary is the context_summary a compaction opened the generation
+	// with, if any.
+	summary string
+	// retracted holds LLM task IDs of undone turns, whose entries are
+	// skipped. It is replaced, never mutated, so cached copies can share it.
+	retracted map[string]bool
+	// checkpoints are the turn_checkpoint and turn_resumed entries of the
+	// latest event a turn checkpointed, oldest first. Like retracted it is
+	// replaced, never mutated.
+	checkpoints []AgentHistoryEntry
+}
+
+// retracts reports whether entry retracts a turn the builder has not yet
+// excluded; the builder must then be rebuilt without that turn.
+func (b *conversationBuilder) retracts(entry AgentHistoryEntry) (string, bool) {
+	id := retractedTaskID(entry)
+	return id, id != "" && !b.retracted[id]
+}
+
+func (b *conversationBuilder) apply(entry AgentHistoryEntry) {
+	if entry.TaskID != "" && b.retracted[entry.TaskID] {
+		return
+	}
+	switch entry.Type {
+	case "system_prompt":
+		if b.storedPrompt == "" {
+			b.storedPrompt = entry.Content
+		}
+	case "context_summary":
+		if b.summary == "" {
+			b.summary = strings.TrimSpace(entry.Content)
+		}
+	case "user_message":
+		b.add("user", entry.Content)
+	case "assistant_message":
+		b.add("assistant", entry.Content)
+	case "turn_retracted":
+		// The note rides on the assistant side so user and assistant
+		// messages keep alternating; with nothing before it there is
+		// nothing to disregard.
+		if len(b.messages) > 0 || b.pendingRole != "" {
+			b.add("assistant", entry.Content)
+		}
+	case "turn_checkpoint", "turn_resumed":
+		eventID := checkpointEventID(entry)
+		if eventID == "" {
+			return
+		}
+		if len(b.checkpoints) > 0 && checkpointEventID(b.checkpoints[0]) != eventID {
+			b.checkpoints = nil
+		}
+		b.checkpoints = append(slices.Clip(b.checkpoints), entry)
+	case "context_pruned":
+		// Keep the cut made when the provider rejected the context length.
+		b.flush()
+		n := min(int(anyToInt64(entry.Data["dropped_messages"])), len(b.messages))
+		if n > 0 {
+			b.messages = slices.Clone(b.messages[n:])
+		}
+	}
+}
+
+// add merges consecutive entries with the same role into one message.
+func (b *conversationBuilder) add(role, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if b.pendingRole == role {
+		b.pendingText += "\n\n" + text
+		return
+	}
+	b.flush()
+	b.pendingRole, b.pendingText = role, text
+}
+
+func (b *conversationBuilder) flush() {
+	if b.pendingRole != "" && strings.TrimSpace(b.pendingText) != "" {
+		b.messages = append(b.messages, llms.Message{
+			Role:    b.pendingRole,
+			Content: content.FromText(b.pendingText),
+		})
+	}
+	b.pendingRole, b.pendingText = "", ""
+}
+
+// result returns the conversation without changing the builder. A trailing
+// user message is dropped since it marks a failed turn with no response.
+func (b *conversationBuilder) result() (string, []llms.Message) {
+	messages := slices.Clone(b.messages)
+	if b.pendingRole != "" && strings.TrimSpace(b.pendingText) != "" {
+		messages = append(messages, llms.Message{
+			Role:    b.pendingRole,
+			Content: content.FromText(b.pendingText),
+		})
+	}
+	if len(messages) > 0 && messages[len(messages)-1].Role == "user" {
+		messages = messages[:len(messages)-1]
+	}
+	return b.storedPrompt, messages
+}
+
+// cachedConversation returns a copy of the agent's cached builder for
+// generation, or an empty builder when there is none.
+func (r *Runtime) cachedConversation(agentID string, generation int64) *conversationBuilder {
+	r.conversationMu.Lock()
+	defer r.conversationMu.Unlock()
+	cached, ok := r.conversations[agentID]
+	if !ok || cached.generation != generation {
+		return &conversationBuilder{generation: generation}
+	}
+	b := *cached
+	b.messages = slices.Clone(cached.messages)
+	return &b
+}
+
+// conversationSummary returns the context summary of the agent's cached
+// conversation for generation. loadConversationMessages must have run first.
+func (r *Runtime) conversationSummary(agentID string, generation int64) string {
+	r.conversationMu.Lock()
+	defer r.conversationMu.Unlock()
+	cached, ok := r.conversations[agentID]
+	if !ok || cached.generation != generation {
+		return ""
+	}
+	return cached.summary
+}
+
+func (r *Runtime) storeConversation(agentID string, b *conversationBuilder) {
+	r.conversationMu.Lock()
+	defer r.conversationMu.Unlock()
+	if r.conversations == nil {
+		r.conversations = map[string]*conversationBuilder{}
+	}
+	r.conversations[agentID] = b
+}
+
+func (r *Runtime) forgetConversation(agentID string) {
+	r.conversationMu.Lock()
+	defer r.conversationMu.Unlock()
+	delete(r.conversations, agentID)
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestLoadConversationMessagesExtendsCachedConversation(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, nil, nil)
+	rt.appendHistory(ctx, "agent-a", "system_prompt", "system", "be brief", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "hi", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "hello", "", 1, nil)
+
+	prompt, messages, err := rt.loadConversationMessages(ctx, "agent-a", 1)
+	if err != nil || prompt != "be brief" || len(messages) != 2 {
+		t.Fatalf("unexpected first load: %q %d %v", prompt, len(messages), err)
+	}
+	firstCursor := rt.conversations["agent-a"].lastID
+
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "weather?", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "sunny", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "and warm", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "unanswered", "", 1, nil)
+
+	_, messages, err = rt.loadConversationMessages(ctx, "agent-a", 1)
+	if err != nil {
+		t.Fatalf("second load: %v", err)
+	}
+	if rt.conversations["agent-a"].lastID == firstCursor {
+		t.Fatalf("expected cache cursor to advance")
+	}
+	_, rebuilt, _ := NewRuntime(bus, nil, nil).loadConversationMessages(ctx, "agent-a", 1)
+	if got, want := conversationTexts(messages), conversationTexts(rebuilt); len(got) != 4 || !slices.Equal(got, want) {
+		t.Fatalf("incremental load %q does not match rebuild %q", got, want)
+	}
+	if got := conversationTexts(messages)[3]; got != "assistant: sunny\n\nand warm" {
+		t.Fatalf("expected merged assistant turn, got %q", got)
+	}
+
+	if _, err := rt.CompactAgentContext(ctx, "agent-a", "test"); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if _, ok := rt.conversations["agent-a"]; ok {
+		t.Fatalf("expected compaction to drop the cached conversation")
+	}
+	if _, messages, _ := rt.loadConversationMessages(ctx, "agent-a", 2); len(messages) != 0 {
+		t.Fatalf("expected empty conversation after compaction, got %d messages", len(messages))
+	}
+}
+
+func conversationTexts(messages []llms.Message) []string {
+	out := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, msg.Role+": "+textFromContent(msg.Content))
+	}
+	return out
+}
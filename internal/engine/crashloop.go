@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	// DefaultCrashLoopThreshold and DefaultCrashLoopWindow define a crash
+	// loop: this many loop crashes within the window.
+	DefaultCrashLoopThreshold = 5
+	DefaultCrashLoopWindow    = 10 * time.Minute
+
+	loopBackoffBase   = time.Second
+	loopBackoffMax    = time.Minute
+	maxQuarantineKept = 20
+	maxLoopStackChars = 4000
+)
+
+// LoopState is the state of an agent's Run goroutine.
+type LoopState string
+
+const (
+	LoopRunning LoopState = "running"
+	// LoopBackoff means the loop crashed and waits to restart.
+	LoopBackoff LoopState = "backoff"
+	// LoopHalted means the loop kept crashing with no event to blame and
+	// stays stopped until ResetAgentLoop.
+	LoopHalted LoopState = "halted"
+	// LoopStopped means the agent was terminated and its loop stays
+	// stopped until ResetAgentLoop.
+	LoopStopped LoopState = "stopped"
+)
+
+// QuarantinedEvent records an event moved to the dead-letter stream after
+// the agent's loop crashed repeatedly while handling it.
+type QuarantinedEvent struct {
+	EventID      string    `json:"event_id"`
+	Stream       string    `json:"stream"`
+	DeadLetterID string    `json:"dead_letter_id,omitempty"`
+	Error        string    `json:"error"`
+	At           time.Time `json:"at"`
+}
+
+// LoopHealth reports an agent loop's crashes. Agents whose loop never
+// crashed have none.
+type LoopHealth struct {
+	AgentID       string             `json:"agent_id"`
+	State         LoopState          `json:"state"`
+	Crashes       int                `json:"crashes"`
+	RecentCrashes int                `json:"recent_crashes"`
+	LastError     string             `json:"last_error,omitempty"`
+	LastStack     string             `json:"last_stack,omitempty"`
+	LastCrashAt   time.Time          `json:"last_crash_at"`
+	NextRestartAt *time.Time         `json:"next_restart_at,omitempty"`
+	Quarantined   []QuarantinedEvent `json:"quarantined,omitempty"`
+}
+
+// loopTracker is the crash bookkeeping for one agent's loop.
+type loopTracker struct {
+	health LoopHealth
+	// recent holds crash times within the crash loop window.
+	recent []time.Time
+	// handling is the event the loop is handling, the suspect when it
+	// crashes.
+	handling *eventbus.Event
+}
+
+// loopPanic is the error a recovered panic in the loop becomes.
+type loopPanic struct {
+	value any
+	stack string
+}
+
+func (p loopPanic) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
+
+// runAgentLoop runs Run, turning a panic into an error.
+func (r *Runtime) runAgentLoop(ctx context.Context, agentID string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = asLoopPanic(p)
+		}
+	}()
+	return r.Run(ctx, agentID)
+}
+
+// superviseAgentLoop restarts an agent's loop after it crashes, backing off
+// exponentially. A crash loop quarantines the event being handled when the
+// loop crashed, or halts the loop when there is none.
+func (r *Runtime) superviseAgentLoop(ctx context.Context, agentID string) {
+	for {
+		err := r.runAgentLoop(ctx, agentID)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		delay, halt := r.recordLoopCrash(ctx, agentID, err)
+		if halt {
+			return
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		r.setLoopState(agentID, LoopRunning)
+	}
+}
+
+func (r *Runtime) loopTrackerLocked(agentID string) *loopTracker {
+	if r.loopHealth == nil {
+		r.loopHealth = map[string]*loopTracker{}
+	}
+	t, ok := r.loopHealth[agentID]
+	if !ok {
+		t = &loopTracker{health: LoopHealth{AgentID: agentID, State: LoopRunning}}
+		r.loopHealth[agentID] = t
+	}
+	return t
+}
+
+// setHandlingEvent records the event the loop is about to handle; nil
+// clears it once handled.
+func (r *Runtime) setHandlingEvent(agentID string, evt *eventbus.Event) {
+	r.loopHealthMu.Lock()
+	defer r.loopHealthMu.Unlock()
+	if evt == nil {
+		if t, ok := r.loopHealth[agentID]; ok {
+			t.handling = nil
+		}
+		return
+	}
+	r.loopTrackerLocked(agentID).handling = evt
+}
+
+func (r *Runtime) setLoopState(agentID string, state LoopState) {
+	r.loopHealthMu.Lock()
+	defer r.loopHealthMu.Unlock()
+	t := r.loopTrackerLocked(agentID)
+	t.health.State = state
+	if state != LoopBackoff {
+		t.health.NextRestartAt = nil
+	}
+}
+
+// recordLoopCrash records a crash and returns how long to wait before
+// restarting, or true when the loop should stay stopped.
+func (r *Runtime) recordLoopCrash(ctx context.Context, agentID string, err error) (time.Duration, bool) {
+	threshold := r.CrashLoopThreshold
+	if threshold <= 0 {
+		threshold = DefaultCrashLoopThreshold
+	}
+	window := r.CrashLoopWindow
+	if window <= 0 {
+		window = DefaultCrashLoopWindow
+	}
+	now := r.now()
+
+	r.loopHealthMu.Lock()
+	t := r.loopTrackerLocked(agentID)
+	recent := t.recent[:0]
+	for _, at := range t.recent {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	t.recent = append(recent, now)
+	t.health.Crashes++
+	t.health.RecentCrashes = len(t.recent)
+	t.health.LastError = err.Error()
+	t.health.LastStack = ""
+	if p, ok := err.(loopPanic); ok {
+		t.health.LastStack = clipText(p.stack, maxLoopStackChars)
+	}
+	t.health.LastCrashAt = now
+	suspect := t.handling
+	t.handling = nil
+	crashLoop := len(t.recent) >= threshold
+	if crashLoop && suspect == nil {
+		t.health.State = LoopHalted
+		t.health.NextRestartAt = nil
+		r.loopHealthMu.Unlock()
+		_, _ = r.PushAlert(ctx, agentID, schema.AlertAgentCrashLoop,
+			fmt.Sprintf("Agent %s halted after %d crashes", agentID, threshold),
+			fmt.Sprintf("The agent's loop crashed %d times within %s and was stopped: %s", threshold, window, err), nil)
+		return 0, true
+	}
+	if crashLoop {
+		// The suspect is gone after quarantine, so start counting afresh.
+		t.recent = nil
+		t.health.RecentCrashes = 0
+	}
+	delay := loopBackoffBase << max(len(t.recent)-1, 0)
+	delay = min(delay, loopBackoffMax)
+	next := now.Add(delay)
+	t.health.State = LoopBackoff
+	t.health.NextRestartAt = &next
+	r.loopHealthMu.Unlock()
+
+	if crashLoop {
+		q := r.quarantineEvent(ctx, agentID, *suspect, err)
+		r.loopHealthMu.Lock()
+		t.health.Quarantined = append(t.health.Quarantined, q)
+		if n := len(t.health.Quarantined); n > maxQuarantineKept {
+			t.health.Quarantined = t.health.Quarantined[n-maxQuarantineKept:]
+		}
+		r.loopHealthMu.Unlock()
+		_, _ = r.PushAlert(ctx, agentID, schema.AlertAgentCrashLoop,
+			fmt.Sprintf("Agent %s quarantined event %s", agentID, suspect.ID),
+			fmt.Sprintf("The agent's loop crashed %d times within %s handling event %s, which was moved to the %s stream: %s",
+				threshold, window, suspect.ID, schema.StreamDeadLetter, err),
+			map[string]any{"event_id": suspect.ID, "dead_letter_id": q.DeadLetterID})
+	}
+	return delay, false
+}
+
+// quarantineEvent marks evt read for agentID so the loop no longer replays
+// it, and copies it to the dead-letter stream with the crash that put it
+// there.
+func (r *Runtime) quarantineEvent(ctx context.Context, agentID string, evt eventbus.Event, cause error) QuarantinedEvent {
+	q := QuarantinedEvent{EventID: evt.ID, Stream: evt.Stream, Error: cause.Error(), At: r.now()}
+	if r.Bus == nil {
+		return q
+	}
+	r.ackContextEvents(ctx, agentID, []eventbus.Event{evt})
+	dead, err := r.Bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamDeadLetter,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Subject:   evt.Subject,
+		Body:      evt.Body,
+		Metadata: map[string]any{
+			schema.MetaKind:     "dead_letter",
+			schema.MetaAgentID:  agentID,
+			"original_stream":   evt.Stream,
+			"original_event_id": evt.ID,
+			"original_metadata": evt.Metadata,
+			"error":             cause.Error(),
+		},
+		Payload:  evt.Payload,
+		SourceID: agentID,
+	})
+	if err == nil {
+		q.DeadLetterID = dead.ID
+	}
+	return q
+}
+
+// LoopHealth reports agentID's loop crashes; false when it never crashed
+// and was not stopped.
+func (r *Runtime) LoopHealth(agentID string) (LoopHealth, bool) {
+	r.loopHealthMu.Lock()
+	defer r.loopHealthMu.Unlock()
+	t, ok := r.loopHealth[agentID]
+	if !ok || (t.health.Crashes == 0 && t.health.State != LoopStopped) {
+		return LoopHealth{}, false
+	}
+	out := t.health
+	out.Quarantined = append([]QuarantinedEvent(nil), t.health.Quarantined...)
+	return out, true
+}
+
+// ResetAgentLoop clears agentID's crash history and starts its loop again if
+// it was halted or stopped.
+func (r *Runtime) ResetAgentLoop(agentID string) {
+	r.loopHealthMu.Lock()
+	delete(r.loopHealth, agentID)
+	r.loopHealthMu.Unlock()
+	r.EnsureAgentLoop(agentID)
+}
+
+func (r *Runtime) loopHalted(agentID string) bool {
+	r.loopHealthMu.Lock()
+	defer r.loopHealthMu.Unlock()
+	t, ok := r.loopHealth[agentID]
+	return ok && (t.health.State == LoopHalted || t.health.State == LoopStopped)
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestCrashLoopQuarantinesPoisonedEvent(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&loopProvider{})})
+	rt.baseCtx = ctx
+	rt.CrashLoopThreshold = 2
+	var poisoned atomic.Bool
+	poisoned.Store(true)
+	rt.LLMFactory = func() (*llms.LLM, error) {
+		if poisoned.Load() {
+			panic("poisoned")
+		}
+		return llms.New(&loopProvider{}), nil
+	}
+	createTestAgent(t, mgr, "operator")
+
+	evt, err := bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   "operator",
+		Body:      "crash me",
+		Metadata:  map[string]any{"source": "external", "target": "operator", "kind": "message"},
+	})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	rt.EnsureAgentLoop("operator")
+
+	deadline := time.Now().Add(5 * time.Second)
+	var health LoopHealth
+	for {
+		var ok bool
+		if health, ok = rt.LoopHealth("operator"); ok && len(health.Quarantined) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for quarantine, health %+v", health)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if health.Crashes != 2 || health.Quarantined[0].EventID != evt.ID || health.LastStack == "" {
+		t.Fatalf("unexpected health %+v", health)
+	}
+	dead, err := bus.Read(ctx, schema.StreamDeadLetter, []string{health.Quarantined[0].DeadLetterID}, "")
+	if err != nil || len(dead) != 1 || dead[0].Body != "crash me" || dead[0].Metadata["original_event_id"] != evt.ID {
+		t.Fatalf("expected the event in the dead-letter stream, got %+v %v", dead, err)
+	}
+	alerts, err := bus.Count(ctx, schema.StreamAlerts, eventbus.ListOptions{}, time.Time{}, time.Time{})
+	if err != nil || alerts != 1 {
+		t.Fatalf("expected one alert, got %d %v", alerts, err)
+	}
+
+	// With the event gone the restarted loop has nothing to crash on.
+	poisoned.Store(false)
+	time.Sleep(1500 * time.Millisecond)
+	if health, _ := rt.LoopHealth("operator"); health.State != LoopRunning || health.Crashes != 2 {
+		t.Fatalf("expected the loop running again, got %+v", health)
+	}
+}
+
+func TestCrashLoopWithoutSuspectHaltsLoop(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, tasks.NewManager(db, bus), nil)
+	rt.CrashLoopThreshold = 3
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		delay, halt := rt.recordLoopCrash(ctx, "operator", errors.New("boom"))
+		if halt || delay != want {
+			t.Fatalf("crash %d: expected backoff %s, got %s halt=%v", i+1, want, delay, halt)
+		}
+	}
+	if _, halt := rt.recordLoopCrash(ctx, "operator", errors.New("boom")); !halt {
+		t.Fatalf("expected the third crash to halt the loop")
+	}
+	if health, _ := rt.LoopHealth("operator"); health.State != LoopHalted || health.LastError != "boom" {
+		t.Fatalf("unexpected health %+v", health)
+	}
+	rt.EnsureAgentLoop("operator")
+	rt.loopMu.Lock()
+	_, running := rt.loops["operator"]
+	rt.loopMu.Unlock()
+	if running {
+		t.Fatalf("expected a halted loop to stay stopped")
+	}
+	rt.ResetAgentLoop("operator")
+	if _, ok := rt.LoopHealth("operator"); ok {
+		t.Fatalf("expected reset to clear the crash history")
+	}
+}
@@ -218,9 +218,18 @@ func (d *busDebugger) RawEvent(data []byte) {
 	d.record("event", payload)
 }
 
-func (r *Runtime) attachDebugger(llm *llms.LLM, agentID, taskID string) {
+// attachDebugger records raw provider traffic for the llm task and returns
+// the debugger collecting its reproducibility metadata.
+func (r *Runtime) attachDebugger(llm *llms.LLM, agentID, taskID string) *reproDebugger {
 	if llm == nil || r.Bus == nil {
-		return
+		return nil
 	}
-	llm.WithDebugger(newBusDebugger(r.Bus, agentID, taskID, r.LLMDebugDir))
+	repro := &reproDebugger{Debugger: newBusDebugger(r.Bus, agentID, taskID, r.LLMDebugDir)}
+	llm.WithDebugger(&providerToolDebugger{
+		Debugger: repro,
+		handle: func(evt providerToolEvent) {
+			r.handleProviderToolEvent(context.Background(), agentID, taskID, evt)
+		},
+	})
+	return repro
 }
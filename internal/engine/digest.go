@@ -0,0 +1,335 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// DailyDigestMonitor is the monitor that starts end-of-day digest turns for
+// agents that opted in.
+const DailyDigestMonitor = "daily_digest"
+
+const (
+	dailyDigestInterval = time.Minute
+	// digestListLimit caps each list in a digest; the rest are counted.
+	digestListLimit = 20
+	// maxDigestScan caps how many events and tasks are read per list.
+	maxDigestScan = 500
+)
+
+// DailyDigest is what an agent is given to summarize its day: the inputs
+// it handled, the tasks that finished, and what is still open, all since
+// local midnight.
+type DailyDigest struct {
+	AgentID string    `json:"agent_id"`
+	Date    string    `json:"date"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Handled counts the inputs read today; HandledItems lists the newest.
+	Handled      int          `json:"handled"`
+	HandledItems []DigestItem `json:"handled_items"`
+	Completed    []DigestItem `json:"completed"`
+	Failed       []DigestItem `json:"failed"`
+	// Open lists unfinished tasks; UnreadInputs counts inputs not yet read.
+	Open         []DigestItem `json:"open"`
+	UnreadInputs int          `json:"unread_inputs"`
+}
+
+type DigestItem struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Summary string    `json:"summary"`
+	At      time.Time `json:"at"`
+}
+
+// ParseDigestTime parses a local "HH:MM" digest time.
+func ParseDigestTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("digest time %q must be HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// SetAgentDailyDigest schedules a daily digest turn at "HH:MM" in the
+// agent's timezone. An empty value turns digests off.
+func (r *Runtime) SetAgentDailyDigest(taskID, at string) error {
+	at = strings.TrimSpace(at)
+	if at != "" {
+		if _, err := ParseDigestTime(at); err != nil {
+			return err
+		}
+	}
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return nil
+	}
+	cfg.mu.Lock()
+	cfg.DigestAt = at
+	cfg.mu.Unlock()
+	return nil
+}
+
+// AgentDailyDigest collects the digest of agentID's current local day.
+func (r *Runtime) AgentDailyDigest(ctx context.Context, agentID string) (DailyDigest, error) {
+	if r.Tasks == nil || r.Bus == nil {
+		return DailyDigest{}, fmt.Errorf("task manager unavailable")
+	}
+	now := r.now()
+	loc := r.agentLocation(agentID)
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	out := DailyDigest{
+		AgentID:      agentID,
+		Date:         local.Format(time.DateOnly),
+		From:         midnight.UTC(),
+		To:           now,
+		HandledItems: []DigestItem{},
+		Completed:    []DigestItem{},
+		Failed:       []DigestItem{},
+		Open:         []DigestItem{},
+	}
+
+	summaries, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+		Reader:    agentID,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Limit:     maxDigestScan,
+	})
+	if err != nil {
+		return DailyDigest{}, fmt.Errorf("list inputs: %w", err)
+	}
+	var handledIDs []string
+	for _, summary := range summaries {
+		if !summary.Read {
+			out.UnreadInputs++
+			continue
+		}
+		if summary.CreatedAt.Before(midnight) {
+			continue
+		}
+		out.Handled++
+		if len(handledIDs) < digestListLimit {
+			handledIDs = append(handledIDs, summary.ID)
+		}
+	}
+	if len(handledIDs) > 0 {
+		events, err := r.Bus.Read(ctx, schema.StreamTaskInput, handledIDs, "")
+		if err != nil {
+			return DailyDigest{}, fmt.Errorf("read inputs: %w", err)
+		}
+		for _, evt := range events {
+			if schema.GetMetaString(evt.Metadata, "digest") != "" {
+				continue
+			}
+			// A message's subject only names its sender.
+			kind := schema.GetMetaString(evt.Metadata, "kind")
+			summary := strings.TrimSpace(evt.Subject)
+			if summary == "" || kind == "message" {
+				summary = strings.TrimSpace(evt.Body)
+			}
+			if source := schema.GetMetaString(evt.Metadata, "source"); source != "" {
+				kind += " from " + source
+			}
+			out.HandledItems = append(out.HandledItems, DigestItem{ID: evt.ID, Kind: kind, Summary: clipText(summary, 160), At: evt.CreatedAt})
+		}
+	}
+
+	for _, status := range []tasks.Status{tasks.StatusCompleted, tasks.StatusFailed, tasks.StatusQueued, tasks.StatusRunning} {
+		list, err := r.Tasks.List(ctx, tasks.ListFilter{Owner: agentID, Status: status, Limit: maxDigestScan})
+		if err != nil {
+			return DailyDigest{}, fmt.Errorf("list %s tasks: %w", status, err)
+		}
+		for _, task := range list {
+			if task.Type == "agent" || task.Type == "llm" {
+				continue
+			}
+			item := DigestItem{ID: task.ID, Kind: task.Type, Summary: digestTaskSummary(task), At: task.UpdatedAt}
+			switch status {
+			case tasks.StatusCompleted:
+				if !task.UpdatedAt.Before(midnight) {
+					out.Completed = append(out.Completed, item)
+				}
+			case tasks.StatusFailed:
+				if !task.UpdatedAt.Before(midnight) {
+					out.Failed = append(out.Failed, item)
+				}
+			default:
+				out.Open = append(out.Open, item)
+			}
+		}
+	}
+	return out, nil
+}
+
+// emitDailyDigests starts a digest turn for each opted-in agent whose digest
+// time has passed today and that has not had today's digest yet.
+func (r *Runtime) emitDailyDigests(ctx context.Context) error {
+	if r.Tasks == nil || r.Bus == nil {
+		return nil
+	}
+	now := r.now()
+	var errs []error
+	for agentID, at := range r.dailyDigestAgents() {
+		offset, err := ParseDigestTime(at)
+		if err != nil {
+			continue
+		}
+		local := now.In(r.agentLocation(agentID))
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+		if local.Before(midnight.Add(offset)) {
+			continue
+		}
+		if err := r.sendDailyDigest(ctx, agentID, local.Format(time.DateOnly)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", agentID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runtime) sendDailyDigest(ctx context.Context, agentID, date string) error {
+	// The digest message itself records that the day's digest was sent, so
+	// a restart does not send it twice.
+	sent, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Limit:     1,
+		Fields:    []eventbus.FieldFilter{{Column: "metadata", Path: []string{"digest_date"}, Values: []string{date}}},
+	})
+	if err != nil {
+		return fmt.Errorf("check digest: %w", err)
+	}
+	if len(sent) > 0 {
+		return nil
+	}
+	digest, err := r.AgentDailyDigest(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	meta := map[string]any{
+		"digest":      "daily",
+		"digest_date": digest.Date,
+		"priority":    "wake",
+	}
+	// Reply on the channel the user last wrote from.
+	for key, value := range r.lastUserRoute(ctx, agentID) {
+		meta[key] = value
+	}
+	_, err = r.SendMessageWithMeta(ctx, agentID, dailyDigestBody(digest), "runtime", meta)
+	return err
+}
+
+// lastUserRoute returns the service_id and context of the newest message
+// to agentID that did not come from the runtime.
+func (r *Runtime) lastUserRoute(ctx context.Context, agentID string) map[string]any {
+	summaries, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Limit:     50,
+		Fields:    []eventbus.FieldFilter{{Column: "metadata", Path: []string{"kind"}, Values: []string{"message"}}},
+	})
+	if err != nil || len(summaries) == 0 {
+		return nil
+	}
+	ids := make([]string, len(summaries))
+	for i, s := range summaries {
+		ids[i] = s.ID
+	}
+	events, err := r.Bus.Read(ctx, schema.StreamTaskInput, ids, "")
+	if err != nil {
+		return nil
+	}
+	var newest *eventbus.Event
+	for i, evt := range events {
+		if strings.EqualFold(schema.GetMetaString(evt.Metadata, "source"), "runtime") {
+			continue
+		}
+		if newest == nil || evt.CreatedAt.After(newest.CreatedAt) {
+			newest = &events[i]
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+	route := map[string]any{}
+	if serviceID := schema.GetMetaString(newest.Metadata, "service_id"); serviceID != "" {
+		route["service_id"] = serviceID
+	}
+	if routeCtx, ok := newest.Metadata["context"].(map[string]any); ok && len(routeCtx) > 0 {
+		route["context"] = routeCtx
+	}
+	return route
+}
+
+func (r *Runtime) dailyDigestAgents() map[string]string {
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+	out := map[string]string{}
+	for id, cfg := range r.taskConfigs {
+		if cfg == nil {
+			continue
+		}
+		cfg.mu.Lock()
+		at := cfg.DigestAt
+		cfg.mu.Unlock()
+		if at != "" {
+			out[id] = at
+		}
+	}
+	return out
+}
+
+func digestTaskSummary(task tasks.Task) string {
+	if task.Error != "" {
+		return clipText(task.Error, 160)
+	}
+	for _, key := range []string{"name", "description", "title"} {
+		if s := schema.GetMetaString(task.Metadata, key); s != "" {
+			return clipText(s, 160)
+		}
+	}
+	if code, ok := task.Payload["code"].(string); ok {
+		return clipText(strings.Join(strings.Fields(code), " "), 160)
+	}
+	return ""
+}
+
+func dailyDigestBody(d DailyDigest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Daily digest for %s. Write the user a concise end-of-day summary: what was handled, what finished or failed, and what is still open and needs attention. Keep it short and skip sections with nothing in them.\n", d.Date)
+	fmt.Fprintf(&b, "\nHandled today: %d input(s).", d.Handled)
+	writeDigestItems(&b, d.HandledItems, d.Handled, false)
+	fmt.Fprintf(&b, "\n\nCompleted tasks: %d.", len(d.Completed))
+	writeDigestItems(&b, d.Completed, len(d.Completed), true)
+	fmt.Fprintf(&b, "\n\nFailed tasks: %d.", len(d.Failed))
+	writeDigestItems(&b, d.Failed, len(d.Failed), true)
+	fmt.Fprintf(&b, "\n\nOpen: %d task(s), %d unread input(s).", len(d.Open), d.UnreadInputs)
+	writeDigestItems(&b, d.Open, len(d.Open), true)
+	return b.String()
+}
+
+func writeDigestItems(b *strings.Builder, items []DigestItem, total int, withID bool) {
+	for i, item := range items {
+		if i >= digestListLimit {
+			break
+		}
+		switch {
+		case !withID && item.Summary != "":
+			fmt.Fprintf(b, "\n- [%s] %s", item.Kind, item.Summary)
+		case item.Summary != "":
+			fmt.Fprintf(b, "\n- [%s] %s: %s", item.Kind, item.ID, item.Summary)
+		default:
+			fmt.Fprintf(b, "\n- [%s] %s", item.Kind, item.ID)
+		}
+	}
+	if extra := total - min(len(items), digestListLimit); extra > 0 {
+		fmt.Fprintf(b, "\n- ... and %d more", extra)
+	}
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestDailyDigestRunsOncePerDayOnTheUsersChannel(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	done, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a", Payload: map[string]any{"code": "await fetchLogs()"}})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := mgr.Complete(ctx, done.ID, nil); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	open, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a", Payload: map[string]any{"code": "await runMigrations()"}})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	msg, err := rt.SendMessageWithMeta(ctx, "agent-a", "check the logs", "telegram-bot", map[string]any{
+		"service_id": "telegram-bot",
+		"context":    map[string]any{"chat_id": "42"},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := bus.Ack(ctx, msg.Stream, []string{msg.ID}, "agent-a"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	now := time.Now().UTC()
+	rt.nowFn = func() time.Time { return now }
+	digests := func() []eventbus.Event {
+		t.Helper()
+		list, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+			ScopeType: "task",
+			ScopeID:   "agent-a",
+			Fields:    []eventbus.FieldFilter{{Column: "metadata", Path: []string{"digest"}, Values: []string{"daily"}}},
+		})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		ids := make([]string, len(list))
+		for i, s := range list {
+			ids[i] = s.ID
+		}
+		events, err := bus.Read(ctx, schema.StreamTaskInput, ids, "")
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return events
+	}
+
+	if err := rt.emitDailyDigests(ctx); err != nil {
+		t.Fatalf("emit without opt-in: %v", err)
+	}
+	if got := digests(); len(got) != 0 {
+		t.Fatalf("expected no digest without opt-in, got %d", len(got))
+	}
+
+	if err := rt.SetAgentDailyDigest("agent-a", "25:00"); err == nil {
+		t.Fatalf("expected an invalid digest time to be rejected")
+	}
+	if err := rt.SetAgentDailyDigest("agent-a", "00:00"); err != nil {
+		t.Fatalf("set digest: %v", err)
+	}
+	for range 2 {
+		if err := rt.emitDailyDigests(ctx); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+	got := digests()
+	if len(got) != 1 {
+		t.Fatalf("expected exactly one digest, got %d", len(got))
+	}
+	digest := got[0]
+	if schema.GetMetaString(digest.Metadata, "digest_date") != now.Format(time.DateOnly) || schema.GetMetaString(digest.Metadata, "service_id") != "telegram-bot" {
+		t.Fatalf("expected the digest dated today and routed to the user's service, got %v", digest.Metadata)
+	}
+	for _, want := range []string{"Handled today: 1", "check the logs", "Completed tasks: 1", done.ID, "Open: 1 task(s)", open.ID, "runMigrations"} {
+		if !strings.Contains(digest.Body, want) {
+			t.Fatalf("expected %q in the digest:\n%s", want, digest.Body)
+		}
+	}
+}
+
+func TestDailyDigestWaitsForTheLocalTime(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil, WithClock(func() time.Time {
+		return time.Date(2026, 3, 2, 16, 30, 0, 0, time.UTC)
+	}))
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	// 16:30 UTC is 17:30 in Stockholm, before an 18:00 digest.
+	if err := rt.SetAgentTimezone("agent-a", "Europe/Stockholm"); err != nil {
+		t.Fatalf("timezone: %v", err)
+	}
+	if err := rt.SetAgentDailyDigest("agent-a", "18:00"); err != nil {
+		t.Fatalf("set digest: %v", err)
+	}
+	if err := rt.emitDailyDigests(ctx); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	list, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "agent-a"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no digest before the local digest time, got %d events", len(list))
+	}
+}
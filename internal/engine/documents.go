@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/documents"
+)
+
+const maxReferenceTokensPerTurn = 2000
+
+func (r *Runtime) retrieveReferenceDocuments(ctx context.Context, agentID, message string) []documents.Match {
+	if r.Documents == nil || strings.TrimSpace(message) == "" {
+		return nil
+	}
+	matches, err := r.Documents.Retrieve(ctx, agentID, message, maxReferenceTokensPerTurn)
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+// withReferenceDocuments places retrieved document chunks inside the
+// system_updates envelope produced by buildInputWithHistory.
+func withReferenceDocuments(input string, matches []documents.Match) string {
+	if len(matches) == 0 {
+		return input
+	}
+	var b strings.Builder
+	b.WriteString("  <reference_documents>\n")
+	for _, m := range matches {
+		b.WriteString(fmt.Sprintf("    <chunk document_id=\"%s\" title=\"%s\" seq=\"%d\">", xmlEscape(m.DocumentID), xmlEscape(m.Title), m.Seq))
+		b.WriteString(xmlEscape(m.Content))
+		b.WriteString("</chunk>\n")
+	}
+	b.WriteString("  </reference_documents>\n")
+
+	const closing = "</system_updates>"
+	idx := strings.LastIndex(input, closing)
+	if idx < 0 {
+		return input + "\n" + b.String()
+	}
+	return input[:idx] + b.String() + input[idx:]
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Handoff is the runtime state an old process passes to its successor
+// during a restart.
+type Handoff struct {
+	Agents         []string          `json:"agents"`
+	ContextCursors map[string]string `json:"context_cursors,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// Drain stops agent loops from starting new turns and waits for active turns
+// to finish. Turns still running when ctx ends are cancelled.
+func (r *Runtime) Drain(ctx context.Context) error {
+	r.draining.Store(true)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for r.activeTurns.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			r.inflightMu.Lock()
+			for _, cancel := range r.inflight {
+				cancel()
+			}
+			r.inflightMu.Unlock()
+			return fmt.Errorf("drain: %d turns still active: %w", r.activeTurns.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Draining reports whether Drain has been called.
+func (r *Runtime) Draining() bool {
+	return r.draining.Load()
+}
+
+// ActiveTurns returns the number of turns currently being handled.
+func (r *Runtime) ActiveTurns() int {
+	return int(r.activeTurns.Load())
+}
+
+func (r *Runtime) ExportHandoff() Handoff {
+	h := Handoff{ContextCursors: map[string]string{}, CreatedAt: r.now()}
+	r.loopMu.Lock()
+	for agentID := range r.loops {
+		h.Agents = append(h.Agents, agentID)
+	}
+	r.loopMu.Unlock()
+	sort.Strings(h.Agents)
+	r.contextCursorMu.Lock()
+	for agentID, cursor := range r.lastContextCursorByTask {
+		h.ContextCursors[agentID] = cursor
+	}
+	r.contextCursorMu.Unlock()
+	return h
+}
+
+// ImportHandoff restores cursors and restarts the agent loops that were
+// running in the previous process.
+func (r *Runtime) ImportHandoff(h Handoff) {
+	r.contextCursorMu.Lock()
+	for agentID, cursor := range h.ContextCursors {
+		if existing := r.lastContextCursorByTask[agentID]; existing < cursor {
+			r.lastContextCursorByTask[agentID] = cursor
+		}
+	}
+	r.contextCursorMu.Unlock()
+	for _, agentID := range h.Agents {
+		r.EnsureAgentLoop(agentID)
+	}
+}
+
+func WriteHandoff(path string, h Handoff) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("encode handoff: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write handoff: %w", err)
+	}
+	return nil
+}
+
+// ReadHandoff loads and removes a handoff file. A missing file yields a zero
+// Handoff and no error.
+func ReadHandoff(path string) (Handoff, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Handoff{}, nil
+		}
+		return Handoff{}, fmt.Errorf("read handoff: %w", err)
+	}
+	_ = os.Remove(path)
+	var h Handoff
+	if err := json.Unmarshal(data, &h); err != nil {
+		return Handoff{}, fmt.Errorf("decode handoff: %w", err)
+	}
+	return h, nil
+}
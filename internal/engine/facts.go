@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/facts"
+)
+
+const (
+	factExtractTimeout = time.Minute
+	// factExchangeChars caps each side of the exchange the extractor reads.
+	factExchangeChars = 4000
+)
+
+// pinnedFacts returns agentID's facts, or nil when facts are off.
+func (r *Runtime) pinnedFacts(ctx context.Context, agentID string) []facts.Fact {
+	if r.Facts == nil {
+		return nil
+	}
+	list, err := r.Facts.List(ctx, agentID)
+	if err != nil {
+		return nil
+	}
+	return list
+}
+
+// withPinnedFacts places the agent's pinned facts inside the system_updates
+// envelope, so they reach every turn whatever compaction dropped.
+func withPinnedFacts(input string, list []facts.Fact) string {
+	if len(list) == 0 {
+		return input
+	}
+	var b strings.Builder
+	b.WriteString("  <pinned_facts>\n")
+	for _, f := range list {
+		b.WriteString(fmt.Sprintf("    <fact id=\"%s\">%s</fact>\n", xmlEscape(f.ID), xmlEscape(f.Text)))
+	}
+	b.WriteString("  </pinned_facts>\n")
+
+	const closing = "</system_updates>"
+	idx := strings.LastIndex(input, closing)
+	if idx < 0 {
+		return input + "\n" + b.String()
+	}
+	return input[:idx] + b.String() + input[idx:]
+}
+
+// extractFacts runs the fact extractor over a finished turn in the
+// background and pins what it finds.
+func (r *Runtime) extractFacts(agentID, llmTaskID, message, output string) {
+	if r.Facts == nil || r.FactExtractor == nil {
+		return
+	}
+	if strings.TrimSpace(message) == "" && strings.TrimSpace(output) == "" {
+		return
+	}
+	exchange := fmt.Sprintf("Message: %s\nAgent: %s",
+		clipText(strings.TrimSpace(message), factExchangeChars), clipText(strings.TrimSpace(output), factExchangeChars))
+	r.factsWG.Add(1)
+	go func() {
+		defer r.factsWG.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), factExtractTimeout)
+		defer cancel()
+		_ = r.pinExtractedFacts(ctx, agentID, llmTaskID, exchange)
+	}()
+}
+
+func (r *Runtime) pinExtractedFacts(ctx context.Context, agentID, llmTaskID, exchange string) error {
+	known, err := r.Facts.List(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	found, err := r.FactExtractor.Extract(ctx, known, exchange)
+	if err != nil {
+		return err
+	}
+	for _, f := range found {
+		if f.Replaces != "" {
+			// Corrections keep the fact's place in the list.
+			if _, err := r.Facts.Update(ctx, agentID, f.Replaces, f.Text, facts.SourceExtracted); err == nil {
+				continue
+			}
+		}
+		if _, _, err := r.Facts.Add(ctx, facts.Fact{
+			AgentID: agentID,
+			Text:    f.Text,
+			Source:  facts.SourceExtracted,
+			TaskID:  llmTaskID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
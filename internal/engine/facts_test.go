@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/facts"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+type stubFactExtractor struct {
+	found []facts.Extracted
+	known [][]facts.Fact
+}
+
+func (e *stubFactExtractor) Extract(_ context.Context, known []facts.Fact, _ string) ([]facts.Extracted, error) {
+	e.known = append(e.known, known)
+	return e.found, nil
+}
+
+func TestExtractedFactsArePinnedIntoLaterTurns(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	cp := &captureProvider{}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(cp)})
+	store := facts.NewStore(db)
+	extractor := &stubFactExtractor{found: []facts.Extracted{{Text: "The user's name is Sam."}}}
+	rt.Facts = store
+	rt.FactExtractor = extractor
+	createTestAgent(t, mgr, "operator")
+
+	if _, err := rt.RunOnce(ctx, "operator", "hi, I'm Sam"); err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	rt.factsWG.Wait()
+	list, err := store.List(ctx, "operator")
+	if err != nil || len(list) != 1 || list[0].Source != facts.SourceExtracted || list[0].TaskID == "" {
+		t.Fatalf("expected one extracted fact, got %+v %v", list, err)
+	}
+
+	// The next turn sees the fact, and the extractor is told it is known,
+	// so correcting it replaces it in place.
+	extractor.found = []facts.Extracted{{Text: "The user's name is Samantha.", Replaces: list[0].ID}}
+	if _, err := rt.RunOnce(ctx, "operator", "actually call me Samantha"); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	rt.factsWG.Wait()
+	if input := cp.LastInput(); !strings.Contains(input, "<pinned_facts>") || !strings.Contains(input, "The user&apos;s name is Sam.") {
+		t.Fatalf("expected the pinned fact in the turn input, got %q", input)
+	}
+	if len(extractor.known) != 2 || len(extractor.known[1]) != 1 {
+		t.Fatalf("expected the extractor to see the known fact, got %+v", extractor.known)
+	}
+	list, _ = store.List(ctx, "operator")
+	if len(list) != 1 || list[0].Text != "The user's name is Samantha." {
+		t.Fatalf("expected the fact corrected in place, got %+v", list)
+	}
+}
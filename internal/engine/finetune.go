@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/finetune"
+	"github.com/flitsinc/go-agents/internal/quality"
+)
+
+// FineTuneFilter selects the turns exported as fine-tuning examples. From
+// and To bound when a turn started, To exclusive. Rating and Outcome keep
+// only turns rated or classified so, and need the quality store.
+type FineTuneFilter struct {
+	From    time.Time
+	To      time.Time
+	Rating  string
+	Outcome string
+}
+
+// FineTuneExamples turns agentID's history into one fine-tuning example per
+// completed turn: the system prompt it ran with, the message it handled,
+// its tool calls with their summarized results, and its reply. Turns that
+// failed, produced no reply or were retracted are left out.
+func (r *Runtime) FineTuneExamples(ctx context.Context, agentID string, filter FineTuneFilter) ([]finetune.Example, error) {
+	var keep map[string]bool
+	if filter.Rating != "" || filter.Outcome != "" {
+		if r.Quality == nil {
+			return nil, fmt.Errorf("turn quality unavailable")
+		}
+		turns, err := r.Quality.Turns(ctx, quality.Filter{AgentID: agentID, Rating: filter.Rating, Outcome: filter.Outcome, Limit: historyVerifyLimit})
+		if err != nil {
+			return nil, err
+		}
+		keep = make(map[string]bool, len(turns))
+		for _, turn := range turns {
+			keep[turn.TaskID] = true
+		}
+	}
+	entries, err := r.readHistoryEntries(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	retracted := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Type == "turn_retracted" {
+			if id, ok := entry.Data["retracted_task_id"].(string); ok {
+				retracted[id] = true
+			}
+		}
+	}
+	var order []string
+	turns := map[string]*fineTuneTurn{}
+	system := ""
+	for _, entry := range entries {
+		if entry.Type == "system_prompt" {
+			system = entry.Content
+		}
+		if entry.TaskID == "" || retracted[entry.TaskID] || (keep != nil && !keep[entry.TaskID]) {
+			continue
+		}
+		turn, ok := turns[entry.TaskID]
+		if !ok {
+			turn = &fineTuneTurn{example: finetune.Example{
+				ID:        entry.TaskID,
+				AgentID:   agentID,
+				System:    system,
+				CreatedAt: entry.CreatedAt,
+			}}
+			turns[entry.TaskID] = turn
+			order = append(order, entry.TaskID)
+		}
+		turn.add(entry)
+	}
+
+	var out []finetune.Example
+	for _, id := range order {
+		turn := turns[id]
+		created := turn.example.CreatedAt
+		if (!filter.From.IsZero() && created.Before(filter.From)) || (!filter.To.IsZero() && !created.Before(filter.To)) {
+			continue
+		}
+		if ex, ok := turn.complete(); ok {
+			out = append(out, ex)
+		}
+	}
+	return out, nil
+}
+
+// fineTuneTurn assembles one turn's history entries into messages.
+type fineTuneTurn struct {
+	example finetune.Example
+	// calls indexes the tool calls still waiting on a result.
+	calls  map[string]*finetune.ToolCall
+	failed bool
+}
+
+func (t *fineTuneTurn) last() *finetune.Message {
+	if n := len(t.example.Messages); n > 0 {
+		return &t.example.Messages[n-1]
+	}
+	return nil
+}
+
+func (t *fineTuneTurn) add(entry AgentHistoryEntry) {
+	switch entry.Type {
+	case "user_message":
+		t.example.Messages = append(t.example.Messages, finetune.Message{Role: "user", Content: entry.Content})
+	case "assistant_message":
+		if failed, _ := entry.Data["error"].(bool); failed {
+			t.failed = true
+			return
+		}
+		text := strings.TrimSpace(entry.Content)
+		if text == "" {
+			return
+		}
+		if last := t.last(); last != nil && last.Role == "assistant" {
+			last.Content = strings.TrimSpace(last.Content + "\n\n" + text)
+			return
+		}
+		t.example.Messages = append(t.example.Messages, finetune.Message{Role: "assistant", Content: text})
+	case "tool_call":
+		id := historyString(entry.Data, "tool_call_id")
+		if id == "" {
+			return
+		}
+		last := t.last()
+		if last == nil || last.Role != "assistant" {
+			t.example.Messages = append(t.example.Messages, finetune.Message{Role: "assistant"})
+			last = t.last()
+		}
+		last.ToolCalls = append(last.ToolCalls, finetune.ToolCall{ID: id, Name: historyString(entry.Data, "tool_name")})
+		if t.calls == nil {
+			t.calls = map[string]*finetune.ToolCall{}
+		}
+		t.calls[id] = nil
+	case "tool_result":
+		id := historyString(entry.Data, "tool_call_id")
+		if _, ok := t.calls[id]; !ok {
+			return
+		}
+		delete(t.calls, id)
+		// The call's arguments are only known once it is done.
+		args := historyString(entry.Data, "args_raw")
+		for i := range t.example.Messages {
+			for j := range t.example.Messages[i].ToolCalls {
+				if call := &t.example.Messages[i].ToolCalls[j]; call.ID == id {
+					call.Arguments = args
+				}
+			}
+		}
+		t.example.Messages = append(t.example.Messages, finetune.Message{Role: "tool", ToolCallID: id, Content: toolResultText(entry.Data["result"])})
+	case "error":
+		t.failed = true
+	}
+}
+
+// complete returns the example when the turn handled a message and ended
+// on a reply with every tool call answered.
+func (t *fineTuneTurn) complete() (finetune.Example, bool) {
+	msgs := t.example.Messages
+	if t.failed || len(t.calls) > 0 || len(msgs) < 2 || msgs[0].Role != "user" {
+		return finetune.Example{}, false
+	}
+	if last := msgs[len(msgs)-1]; last.Role != "assistant" || last.Content == "" || len(last.ToolCalls) > 0 {
+		return finetune.Example{}, false
+	}
+	return t.example, true
+}
+
+func historyString(data map[string]any, key string) string {
+	s, _ := data[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// toolResultText renders a summarized tool result as the tool message: its
+// error when it failed, else its content.
+func toolResultText(result any) string {
+	m, ok := result.(map[string]any)
+	if !ok {
+		return ""
+	}
+	if msg, ok := m["error"].(string); ok && msg != "" {
+		return "error: " + msg
+	}
+	switch content := m["content"].(type) {
+	case nil:
+		return ""
+	case string:
+		return content
+	default:
+		raw, err := json.Marshal(content)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	}
+}
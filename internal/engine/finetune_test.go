@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestFineTuneExamplesAssembleTurnsFromHistory(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-tuned")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	rt.Quality = quality.NewStore(db)
+
+	for _, msg := range []string{"hello", "how are you?"} {
+		if _, err := rt.HandleMessage(ctx, "agent-tuned", "user", msg, nil); err != nil {
+			t.Fatalf("handle message: %v", err)
+		}
+	}
+	rt.appendHistory(ctx, "agent-tuned", "user_message", "user", "what is 6*7?", "llm-tool", 0, nil)
+	rt.appendToolHistory(ctx, "agent-tuned", "llm-tool", "tool_call", "call-1", "calc", "start", "", nil)
+	rt.appendToolHistory(ctx, "agent-tuned", "llm-tool", "tool_result", "call-1", "calc", "done", "", map[string]any{
+		"args_raw": `{"expr":"6*7"}`,
+		"result":   map[string]any{"label": "calc", "content": "42"},
+	})
+	rt.appendHistory(ctx, "agent-tuned", "assistant_message", "assistant", "It is 42.", "llm-tool", 0, nil)
+	// A turn without a reply is left out.
+	rt.appendHistory(ctx, "agent-tuned", "user_message", "user", "still there?", "llm-cut", 0, nil)
+
+	examples, err := rt.FineTuneExamples(ctx, "agent-tuned", FineTuneFilter{})
+	if err != nil {
+		t.Fatalf("examples: %v", err)
+	}
+	if len(examples) != 3 {
+		t.Fatalf("expected three examples, got %+v", examples)
+	}
+	first := examples[0]
+	if first.Messages[0].Content != "hello" || first.Messages[1].Content != "ok" || len(first.Messages) != 2 {
+		t.Fatalf("unexpected first example %+v", first)
+	}
+	tool := examples[2]
+	if len(tool.Messages) != 4 || tool.Messages[1].ToolCalls[0].Arguments != `{"expr":"6*7"}` ||
+		tool.Messages[2].Role != "tool" || tool.Messages[2].Content != "42" || tool.Messages[3].Content != "It is 42." {
+		t.Fatalf("unexpected tool example %+v", tool)
+	}
+
+	if _, err := rt.RecordTurnFeedback(ctx, "agent-tuned", examples[1].ID, quality.Feedback{Rating: "up", Author: "alice"}); err != nil {
+		t.Fatalf("feedback: %v", err)
+	}
+	rated, err := rt.FineTuneExamples(ctx, "agent-tuned", FineTuneFilter{Rating: quality.Up})
+	if err != nil || len(rated) != 1 || rated[0].Messages[0].Content != "how are you?" {
+		t.Fatalf("expected only the thumbs-up turn, got %+v, %v", rated, err)
+	}
+	if _, err := rt.UndoTurn(ctx, "agent-tuned", examples[0].ID, "wrong"); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if left, err := rt.FineTuneExamples(ctx, "agent-tuned", FineTuneFilter{}); err != nil || len(left) != 2 {
+		t.Fatalf("expected the retracted turn left out, got %+v, %v", left, err)
+	}
+}
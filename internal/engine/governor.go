@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// providerWaits sums how long a turn's provider requests were queued by the
+// provider governor.
+type providerWaits struct {
+	mu       sync.Mutex
+	requests int
+	total    time.Duration
+	longest  time.Duration
+}
+
+func (w *providerWaits) observe(wait time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requests++
+	w.total += wait
+	w.longest = max(w.longest, wait)
+}
+
+// recordProviderWaits stores how long the turn waited for provider capacity
+// in its llm task's metadata. Turns whose requests bypass the governor
+// record nothing.
+func (r *Runtime) recordProviderWaits(ctx context.Context, llmTaskID string, w *providerWaits) {
+	if r.Tasks == nil || llmTaskID == "" {
+		return
+	}
+	w.mu.Lock()
+	requests, total, longest := w.requests, w.total, w.longest
+	w.mu.Unlock()
+	if requests == 0 {
+		return
+	}
+	_, _ = r.Tasks.MergeMetadata(ctx, llmTaskID, map[string]any{
+		"provider_requests":     requests,
+		"provider_queue_ms":     total.Milliseconds(),
+		"provider_queue_max_ms": longest.Milliseconds(),
+	})
+}
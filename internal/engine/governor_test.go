@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/governor"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestHandleMessageRecordsProviderQueueTime(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-governed")
+	provider := newMultiExecTurnProvider([]llms.ToolCall{
+		makeExecToolCall(t, "exec-call-1", 0, "globalThis.result = 1"),
+	}, "done")
+	gov := governor.New(governor.Config{MaxConcurrent: 1})
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(gov.Wrap("test", provider), agenttools.ExecTool(mgr))})
+
+	// Another caller holds the only slot when the turn starts.
+	held, err := gov.Acquire(ctx, "test", "someone-else")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	go func() {
+		for gov.Snapshot().Global.Queued == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		held.Release()
+	}()
+
+	if _, err := rt.HandleMessage(ctx, "agent-governed", "user", "run it", nil); err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	list, err := mgr.List(ctx, tasks.ListFilter{Type: "llm", Limit: 10})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one llm task, got %d, %v", len(list), err)
+	}
+	meta := list[0].Metadata
+	if requests, _ := meta["provider_requests"].(float64); requests != 2 {
+		t.Fatalf("expected both provider requests counted, got %+v", meta)
+	}
+	if waited, _ := meta["provider_queue_ms"].(float64); waited < 40 {
+		t.Fatalf("expected the queue time recorded, got %+v", meta)
+	}
+	if s := gov.Snapshot(); s.Global.Active != 0 || s.Global.Requests != 3 || s.Global.Waited != 1 {
+		t.Fatalf("expected every permit released, got %+v", s.Global)
+	}
+}
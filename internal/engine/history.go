@@ -3,12 +3,12 @@ package engine
 import (
 	"context"
 	"fmt"
+	"maps"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/flitsinc/go-agents/internal/eventbus"
-	"github.com/flitsinc/go-llms/content"
 	"github.com/flitsinc/go-llms/llms"
 )
 
@@ -66,13 +66,19 @@ func (r *Runtime) appendHistory(ctx context.Context, taskID, entryType, role, co
 		"task_id":    strings.TrimSpace(llmTaskID),
 		"created_at": r.now().Format(time.RFC3339Nano),
 	}
+	if lane := turnLaneFromContext(ctx); lane > 0 {
+		payload["lane"] = lane
+	}
 	for k, v := range data {
 		if v == nil {
 			continue
 		}
 		payload[k] = v
 	}
-	_, _ = r.Bus.Push(ctx, eventbus.EventInput{
+	if budget := historyBudgetFromContext(ctx); budget != nil && budget.agentID == taskID && !budget.admit(entryType, payload) {
+		return
+	}
+	evt, err := r.Bus.Push(ctx, eventbus.EventInput{
 		Stream:    "history",
 		ScopeType: "task",
 		ScopeID:   taskID,
@@ -88,6 +94,9 @@ func (r *Runtime) appendHistory(ctx context.Context, taskID, entryType, role, co
 		},
 		Payload: payload,
 	})
+	if err == nil {
+		r.publishUI(taskID, UIHistory, evt)
+	}
 }
 
 func (r *Runtime) appendToolHistory(ctx context.Context, taskID, llmTaskID, entryType, toolCallID, toolName, toolStatus, content string, data map[string]any) {
@@ -228,6 +237,7 @@ func (r *Runtime) CompactAgentContext(ctx context.Context, taskID, reason string
 	r.historyMu.Lock()
 	r.historyGenerationByTask[taskID] = next
 	r.historyMu.Unlock()
+	r.forgetConversation(taskID)
 
 	reason = strings.TrimSpace(reason)
 	if reason == "" {
@@ -238,13 +248,18 @@ func (r *Runtime) CompactAgentContext(ctx context.Context, taskID, reason string
 		"previous_gen": current,
 		"next_gen":     next,
 	})
+	if r.Tasks != nil {
+		// Agents awaiting this one learn that its context was reset.
+		_ = r.Tasks.RecordGenerationChange(ctx, taskID, current, next, reason)
+	}
 	return next, nil
 }
 
 // loadConversationMessages reads history entries for the given agent and
 // generation, returning the stored system prompt text and a reconstructed
 // []llms.Message conversation suitable for passing to ChatUsingMessages.
-// Consecutive messages with the same role are merged.
+// Consecutive messages with the same role are merged. The reconstruction is
+// cached per agent so later turns only read entries appended since.
 func (r *Runtime) loadConversationMessages(ctx context.Context, agentID string, generation int64) (storedPrompt string, messages []llms.Message, err error) {
 	if r.Bus == nil {
 		return "", nil, nil
@@ -254,81 +269,82 @@ func (r *Runtime) loadConversationMessages(ctx context.Context, agentID string,
 		return "", nil, nil
 	}
 
-	summaries, err := r.Bus.List(ctx, "history", eventbus.ListOptions{
-		ScopeType: "task",
-		ScopeID:   agentID,
-		Limit:     2000,
-		Order:     "fifo",
-	})
-	if err != nil || len(summaries) == 0 {
-		return "", nil, err
-	}
-
-	ids := make([]string, len(summaries))
-	for i, s := range summaries {
-		ids[i] = s.ID
-	}
-	events, err := r.Bus.Read(ctx, "history", ids, "")
+	b, err := r.extendConversation(ctx, agentID, r.cachedConversation(agentID, generation))
 	if err != nil {
 		return "", nil, err
 	}
+	r.storeConversation(agentID, b)
 
-	// Accumulate user/assistant messages, merging consecutive same-role entries.
-	var lastRole string
-	var lastText string
-	flush := func() {
-		if lastRole == "" || strings.TrimSpace(lastText) == "" {
-			return
-		}
-		messages = append(messages, llms.Message{
-			Role:    lastRole,
-			Content: content.FromText(lastText),
+	storedPrompt, messages = b.result()
+	return storedPrompt, messages, nil
+}
+
+// extendConversation folds the history entries appended since b's last
+// entry into b, rebuilding it when a turn was retracted.
+func (r *Runtime) extendConversation(ctx context.Context, agentID string, b *conversationBuilder) (*conversationBuilder, error) {
+	generation := b.generation
+	for {
+		summaries, err := r.Bus.List(ctx, "history", eventbus.ListOptions{
+			ScopeType: "task",
+			ScopeID:   agentID,
+			Limit:     conversationPageSize,
+			Order:     "fifo",
+			After:     b.lastID,
 		})
-	}
-	for _, evt := range events {
-		entry, ok := HistoryEntryFromEvent(evt)
-		if !ok || entry.Generation != generation {
+		if err != nil && b.lastID != "" {
+			// The cursor entry is gone; rebuild from scratch.
+			b = &conversationBuilder{generation: generation, retracted: b.retracted}
 			continue
 		}
-		switch entry.Type {
-		case "system_prompt":
-			if storedPrompt == "" {
-				storedPrompt = entry.Content
-			}
-		case "user_message":
-			text := strings.TrimSpace(entry.Content)
-			if text == "" {
-				continue
-			}
-			if lastRole == "user" {
-				lastText += "\n\n" + text
-			} else {
-				flush()
-				lastRole = "user"
-				lastText = text
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) == 0 {
+			break
+		}
+		ids := make([]string, len(summaries))
+		for i, s := range summaries {
+			ids[i] = s.ID
+		}
+		events, err := r.Bus.Read(ctx, "history", ids, "")
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]eventbus.Event, len(events))
+		for _, evt := range events {
+			byID[evt.ID] = evt
+		}
+		restart := false
+		for _, id := range ids {
+			entry, ok := HistoryEntryFromEvent(byID[id])
+			if ok && entry.Generation == generation {
+				if taskID, ok := b.retracts(entry); ok {
+					// Entries of the retracted turn are already folded in;
+					// start over without them.
+					retracted := maps.Clone(b.retracted)
+					if retracted == nil {
+						retracted = map[string]bool{}
+					}
+					retracted[taskID] = true
+					b = &conversationBuilder{generation: generation, retracted: retracted}
+					restart = true
+					break
+				}
 			}
-		case "assistant_message":
-			text := strings.TrimSpace(entry.Content)
-			if text == "" {
+			b.lastID = id
+			if !ok || entry.Generation != generation {
 				continue
 			}
-			if lastRole == "assistant" {
-				lastText += "\n\n" + text
-			} else {
-				flush()
-				lastRole = "assistant"
-				lastText = text
-			}
+			b.apply(entry)
+		}
+		if restart {
+			continue
+		}
+		if len(summaries) < conversationPageSize {
+			break
 		}
 	}
-	flush()
-
-	// Drop trailing user message (indicates a failed turn with no response).
-	if len(messages) > 0 && messages[len(messages)-1].Role == "user" {
-		messages = messages[:len(messages)-1]
-	}
-
-	return storedPrompt, messages, nil
+	return b, nil
 }
 
 func HistoryEntryFromEvent(evt eventbus.Event) (AgentHistoryEntry, bool) {
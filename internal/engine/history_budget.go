@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// Default per-turn history write limits.
+const (
+	DefaultHistoryTurnEntries = 400
+	DefaultHistoryTurnBytes   = 4 << 20
+)
+
+// coalescedHistoryTypes are the progress entries a turn stops writing one by
+// one once it is over its history budget. Everything else is needed to
+// rebuild or verify the conversation and is always written.
+var coalescedHistoryTypes = map[string]bool{
+	"tool_status": true,
+	"reasoning":   true,
+}
+
+// HistoryBudget bounds how many history entries, and how many bytes of
+// them, one turn writes. Once a turn is over either limit its tool_status
+// and reasoning entries are skipped and the turn ends with one
+// history_coalesced entry counting them. Zero fields use the defaults and
+// negative fields disable that limit.
+type HistoryBudget struct {
+	MaxEntries int `json:"max_entries,omitempty"`
+	MaxBytes   int `json:"max_bytes,omitempty"`
+}
+
+func (b HistoryBudget) resolved() HistoryBudget {
+	if b.MaxEntries == 0 {
+		b.MaxEntries = DefaultHistoryTurnEntries
+	}
+	if b.MaxBytes == 0 {
+		b.MaxBytes = DefaultHistoryTurnBytes
+	}
+	return b
+}
+
+// ParseHistoryBudget reads a history_budget payload object with max_entries
+// and max_bytes.
+func ParseHistoryBudget(raw any) (HistoryBudget, error) {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return HistoryBudget{}, fmt.Errorf("history_budget must be an object")
+	}
+	var budget HistoryBudget
+	fields := []struct {
+		key string
+		dst *int
+	}{{"max_entries", &budget.MaxEntries}, {"max_bytes", &budget.MaxBytes}}
+	for _, field := range fields {
+		key, dst := field.key, field.dst
+		v, present := obj[key]
+		if !present || v == nil {
+			continue
+		}
+		n, isNum := v.(float64)
+		if !isNum || n != float64(int(n)) {
+			return HistoryBudget{}, fmt.Errorf("history_budget.%s must be a whole number", key)
+		}
+		*dst = int(n)
+	}
+	return budget, nil
+}
+
+// SetAgentHistoryBudget sets the agent's per-turn history limits, replacing
+// the runtime's HistoryBudget for its turns.
+func (r *Runtime) SetAgentHistoryBudget(taskID string, budget HistoryBudget) {
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return
+	}
+	cfg.mu.Lock()
+	cfg.HistoryBudget = &budget
+	cfg.mu.Unlock()
+}
+
+// AgentHistoryBudget returns the per-turn history limits for the agent's
+// turns, with defaults filled in.
+func (r *Runtime) AgentHistoryBudget(taskID string) HistoryBudget {
+	budget := r.HistoryBudget
+	r.configMu.RLock()
+	cfg := r.taskConfigs[taskID]
+	r.configMu.RUnlock()
+	if cfg != nil {
+		cfg.mu.Lock()
+		if cfg.HistoryBudget != nil {
+			budget = *cfg.HistoryBudget
+		}
+		cfg.mu.Unlock()
+	}
+	return budget.resolved()
+}
+
+// historyTurnBudget tracks what one turn has written to an agent's history.
+type historyTurnBudget struct {
+	agentID string
+	limits  HistoryBudget
+
+	mu           sync.Mutex
+	entries      int
+	bytes        int
+	skipped      map[string]int
+	skippedBytes int
+	// lastToolStatus keeps the final skipped status of each tool call.
+	lastToolStatus map[string]string
+}
+
+type historyBudgetKey struct{}
+
+func withHistoryBudget(ctx context.Context, budget *historyTurnBudget) context.Context {
+	return context.WithValue(ctx, historyBudgetKey{}, budget)
+}
+
+func historyBudgetFromContext(ctx context.Context) *historyTurnBudget {
+	budget, _ := ctx.Value(historyBudgetKey{}).(*historyTurnBudget)
+	return budget
+}
+
+func newHistoryTurnBudget(agentID string, limits HistoryBudget) *historyTurnBudget {
+	return &historyTurnBudget{agentID: agentID, limits: limits.resolved()}
+}
+
+// admit reports whether an entry of the given type and payload may be
+// written. Written entries count toward the budget; skipped ones are
+// tallied for the history_coalesced entry.
+func (b *historyTurnBudget) admit(entryType string, payload map[string]any) bool {
+	size := 0
+	if data, err := json.Marshal(payload); err == nil {
+		size = len(data)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	over := (b.limits.MaxEntries > 0 && b.entries >= b.limits.MaxEntries) ||
+		(b.limits.MaxBytes > 0 && b.bytes+size > b.limits.MaxBytes)
+	if over && coalescedHistoryTypes[entryType] {
+		if b.skipped == nil {
+			b.skipped = map[string]int{}
+		}
+		b.skipped[entryType]++
+		b.skippedBytes += size
+		if entryType == "tool_status" {
+			if callID, _ := payload["tool_call_id"].(string); callID != "" {
+				if b.lastToolStatus == nil {
+					b.lastToolStatus = map[string]string{}
+				}
+				status, _ := payload["tool_status"].(string)
+				b.lastToolStatus[callID] = status
+			}
+		}
+		return false
+	}
+	b.entries++
+	b.bytes += size
+	return true
+}
+
+// flushHistoryBudget writes one history_coalesced entry for the entries
+// the turn skipped, if any.
+func (r *Runtime) flushHistoryBudget(ctx context.Context, budget *historyTurnBudget, llmTaskID string, generation int64) {
+	if budget == nil {
+		return
+	}
+	budget.mu.Lock()
+	skipped := 0
+	for _, n := range budget.skipped {
+		skipped += n
+	}
+	data := map[string]any{
+		"skipped":       maps.Clone(budget.skipped),
+		"skipped_bytes": budget.skippedBytes,
+		"max_entries":   budget.limits.MaxEntries,
+		"max_bytes":     budget.limits.MaxBytes,
+	}
+	if len(budget.lastToolStatus) > 0 {
+		data["tool_statuses"] = maps.Clone(budget.lastToolStatus)
+	}
+	budget.mu.Unlock()
+	if skipped == 0 {
+		return
+	}
+	note := fmt.Sprintf("skipped %d progress entries over the turn's history budget", skipped)
+	// An interrupted turn still records what it skipped.
+	r.appendHistory(context.WithoutCancel(ctx), budget.agentID, "history_coalesced", "system", note, llmTaskID, generation, data)
+}
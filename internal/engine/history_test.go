@@ -1,10 +1,14 @@
 package engine
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
 )
 
 func TestHistoryEntryFromEventPreservesContentWhitespace(t *testing.T) {
@@ -31,3 +35,154 @@ func TestHistoryEntryFromEventPreservesContentWhitespace(t *testing.T) {
 		t.Fatalf("expected content whitespace preserved, got %q", entry.Content)
 	}
 }
+
+func TestVerifyHistoryReportsAndRepairsViolations(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "operator")
+	ctx := context.Background()
+
+	rt.appendHistory(ctx, "operator", "system_prompt", "system", "prompt", "", 1, nil)
+	rt.appendHistory(ctx, "operator", "system_prompt", "system", "prompt again", "", 1, nil)
+	rt.appendHistory(ctx, "operator", "user_message", "user", "first", "", 1, nil)
+	rt.appendHistory(ctx, "operator", "user_message", "user", "second", "llm-missing", 1, nil)
+	rt.appendHistory(ctx, "operator", "assistant_message", "assistant", "reply", "llm-missing", 1, nil)
+
+	report, err := rt.VerifyHistory(ctx, "operator")
+	if err != nil {
+		t.Fatalf("verify history: %v", err)
+	}
+	kinds := map[string]int{}
+	for _, v := range report.Violations {
+		kinds[v.Kind]++
+	}
+	for _, want := range []string{"duplicate_system_prompt", "missing_tools_config", "unanswered_turn", "missing_task"} {
+		if kinds[want] != 1 {
+			t.Fatalf("expected one %s violation, got %+v", want, report.Violations)
+		}
+	}
+
+	repaired, err := rt.RepairHistory(ctx, "operator")
+	if err != nil {
+		t.Fatalf("repair history: %v", err)
+	}
+	if len(repaired.Repaired) != len(report.Violations) {
+		t.Fatalf("expected %d repairs, got %v", len(report.Violations), repaired.Repaired)
+	}
+
+	after, err := rt.VerifyHistory(ctx, "operator")
+	if err != nil {
+		t.Fatalf("verify history after repair: %v", err)
+	}
+	if !after.OK() {
+		t.Fatalf("expected repaired history to verify, got %+v", after.Violations)
+	}
+	if after.Entries != report.Entries+len(report.Violations) {
+		t.Fatalf("expected repair markers appended, got %d entries", after.Entries)
+	}
+}
+
+func TestHistoryBudgetCoalescesProgressEntries(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "operator")
+	rt.SetAgentHistoryBudget("operator", HistoryBudget{MaxEntries: 4, MaxBytes: -1})
+	if got := rt.AgentHistoryBudget("operator"); got.MaxEntries != 4 || got.MaxBytes != -1 {
+		t.Fatalf("unexpected agent budget %+v", got)
+	}
+	if got := rt.AgentHistoryBudget("other"); got.MaxEntries != DefaultHistoryTurnEntries || got.MaxBytes != DefaultHistoryTurnBytes {
+		t.Fatalf("expected default budget, got %+v", got)
+	}
+
+	budget := newHistoryTurnBudget("operator", rt.AgentHistoryBudget("operator"))
+	ctx := withHistoryBudget(context.Background(), budget)
+	rt.appendHistory(ctx, "operator", "user_message", "user", "go", "", 1, nil)
+	for i := range 3 {
+		callID := fmt.Sprintf("call-%d", i)
+		rt.appendToolHistory(ctx, "operator", "", "tool_call", callID, "exec", "start", "", nil)
+		for _, status := range []string{"streaming", "running", "still running"} {
+			rt.appendToolHistory(ctx, "operator", "", "tool_status", callID, "exec", status, "", nil)
+		}
+		rt.appendToolHistory(ctx, "operator", "", "tool_result", callID, "exec", "done", "", nil)
+	}
+	rt.appendHistory(ctx, "operator", "assistant_message", "assistant", "done", "", 1, nil)
+	rt.flushHistoryBudget(ctx, budget, "", 1)
+
+	entries, err := rt.readHistoryEntries(context.Background(), "operator")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	counts := map[string]int{}
+	var coalesced AgentHistoryEntry
+	for _, entry := range entries {
+		counts[entry.Type]++
+		if entry.Type == "history_coalesced" {
+			coalesced = entry
+		}
+	}
+	// The user message, the first call and two of its statuses fill the
+	// budget; later statuses are skipped while calls, results and replies
+	// are still written.
+	if counts["tool_call"] != 3 || counts["tool_result"] != 3 || counts["assistant_message"] != 1 || counts["tool_status"] != 2 {
+		t.Fatalf("unexpected entry counts %v", counts)
+	}
+	if counts["history_coalesced"] != 1 {
+		t.Fatalf("expected one coalesced entry, got %v", counts)
+	}
+	skipped, _ := coalesced.Data["skipped"].(map[string]any)
+	statuses, _ := coalesced.Data["tool_statuses"].(map[string]any)
+	if anyToInt64(skipped["tool_status"]) != 7 || statuses["call-2"] != "still running" {
+		t.Fatalf("unexpected coalesced data %+v", coalesced.Data)
+	}
+
+	quiet := newHistoryTurnBudget("operator", HistoryBudget{})
+	rt.flushHistoryBudget(withHistoryBudget(context.Background(), quiet), quiet, "", 1)
+	if after, _ := rt.readHistoryEntries(context.Background(), "operator"); len(after) != len(entries) {
+		t.Fatalf("expected no coalesced entry for a turn within budget")
+	}
+
+	if _, err := ParseHistoryBudget(map[string]any{"max_entries": 1.5}); err == nil {
+		t.Fatalf("expected fractional limits to be rejected")
+	}
+	if parsed, err := ParseHistoryBudget(map[string]any{"max_bytes": float64(4096)}); err != nil || parsed.MaxBytes != 4096 || parsed.MaxEntries != 0 {
+		t.Fatalf("unexpected parsed budget %+v (%v)", parsed, err)
+	}
+}
+
+func TestVerifyHistoryReadsPastTheFirstPage(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "operator")
+	ctx := context.Background()
+
+	rt.appendHistory(ctx, "operator", "system_prompt", "system", "prompt", "", 1, nil)
+	rt.appendHistory(ctx, "operator", "tools_config", "system", "tools", "", 1, nil)
+	for i := range historyVerifyLimit {
+		rt.appendHistory(ctx, "operator", "reasoning", "assistant", fmt.Sprintf("thought %d", i), "", 1, nil)
+	}
+	rt.appendHistory(ctx, "operator", "user_message", "user", "first", "", 1, nil)
+	rt.appendHistory(ctx, "operator", "user_message", "user", "second", "", 1, nil)
+
+	report, err := rt.VerifyHistory(ctx, "operator")
+	if err != nil {
+		t.Fatalf("verify history: %v", err)
+	}
+	if report.Entries != historyVerifyLimit+4 {
+		t.Fatalf("expected every entry read, got %d", report.Entries)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].Kind != "unanswered_turn" {
+		t.Fatalf("expected the unanswered turn on the second page, got %+v", report.Violations)
+	}
+}
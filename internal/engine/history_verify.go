@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// historyVerifyLimit is how many history entries are read at a time.
+const historyVerifyLimit = 5000
+
+// HistoryViolation describes a broken invariant in an agent's history.
+type HistoryViolation struct {
+	Generation int64  `json:"generation"`
+	Kind       string `json:"kind"`
+	EntryID    string `json:"entry_id,omitempty"`
+	TaskID     string `json:"task_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+type HistoryReport struct {
+	AgentID     string             `json:"agent_id"`
+	Entries     int                `json:"entries"`
+	Generations []int64            `json:"generations"`
+	Violations  []HistoryViolation `json:"violations"`
+	Repaired    []string           `json:"repaired,omitempty"`
+}
+
+func (r HistoryReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// VerifyHistory checks per-generation invariants of an agent's history:
+// exactly one system_prompt and tools_config, user turns answered before the
+// next one starts, and referenced LLM tasks still present. Violations already
+// covered by a history_repair marker are not reported again.
+func (r *Runtime) VerifyHistory(ctx context.Context, agentID string) (HistoryReport, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return HistoryReport{}, fmt.Errorf("agent_id is required")
+	}
+	entries, err := r.readHistoryEntries(ctx, agentID)
+	if err != nil {
+		return HistoryReport{}, err
+	}
+	report := HistoryReport{
+		AgentID:    agentID,
+		Entries:    len(entries),
+		Violations: []HistoryViolation{},
+	}
+
+	byGen := map[int64][]AgentHistoryEntry{}
+	for _, entry := range entries {
+		if _, ok := byGen[entry.Generation]; !ok {
+			report.Generations = append(report.Generations, entry.Generation)
+		}
+		byGen[entry.Generation] = append(byGen[entry.Generation], entry)
+	}
+	sort.Slice(report.Generations, func(i, j int) bool { return report.Generations[i] < report.Generations[j] })
+
+	repaired := map[string]struct{}{}
+	for _, entry := range entries {
+		if entry.Type == "history_repair" {
+			repaired[repairKey(entry.Generation, mapString(entry.Data, "violation"), mapString(entry.Data, "entry_id"))] = struct{}{}
+		}
+	}
+	taskExists := map[string]bool{}
+	for _, gen := range report.Generations {
+		for _, v := range r.verifyGeneration(ctx, gen, byGen[gen], taskExists) {
+			if _, ok := repaired[repairKey(v.Generation, v.Kind, v.EntryID)]; ok {
+				continue
+			}
+			report.Violations = append(report.Violations, v)
+		}
+	}
+	return report, nil
+}
+
+func (r *Runtime) verifyGeneration(ctx context.Context, gen int64, entries []AgentHistoryEntry, taskExists map[string]bool) []HistoryViolation {
+	var out []HistoryViolation
+	add := func(kind string, entry AgentHistoryEntry, format string, args ...any) {
+		out = append(out, HistoryViolation{
+			Generation: gen,
+			Kind:       kind,
+			EntryID:    entry.ID,
+			TaskID:     entry.TaskID,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	preamble := map[string]int{}
+	conversationStarted := false
+	awaitingReply := false
+	var lastTurn AgentHistoryEntry
+	for _, entry := range entries {
+		switch entry.Type {
+		case "system_prompt", "tools_config":
+			preamble[entry.Type]++
+			if preamble[entry.Type] > 1 {
+				add("duplicate_"+entry.Type, entry, "generation %d has more than one %s entry", gen, entry.Type)
+			}
+		case "user_message", "wake":
+			conversationStarted = true
+			if awaitingReply && lastTurn.Type == "user_message" && entry.Type == "user_message" {
+				add("unanswered_turn", lastTurn, "user message has no assistant reply before the next user message")
+			}
+			awaitingReply = true
+			lastTurn = entry
+		case "assistant_message":
+			if !conversationStarted {
+				add("orphan_assistant_message", entry, "assistant message recorded before any user message or wake")
+			}
+			conversationStarted = true
+			awaitingReply = false
+		}
+
+		if entry.TaskID == "" || r.Tasks == nil {
+			continue
+		}
+		if _, checked := taskExists[entry.TaskID]; checked {
+			continue
+		}
+		_, err := r.Tasks.Get(ctx, entry.TaskID)
+		taskExists[entry.TaskID] = err == nil
+		if err != nil {
+			add("missing_task", entry, "referenced task %s does not exist", entry.TaskID)
+		}
+	}
+
+	if conversationStarted {
+		for _, kind := range []string{"system_prompt", "tools_config"} {
+			if preamble[kind] == 0 {
+				out = append(out, HistoryViolation{
+					Generation: gen,
+					Kind:       "missing_" + kind,
+					Message:    fmt.Sprintf("generation %d has no %s entry", gen, kind),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// RepairHistory verifies the history and appends a history_repair marker for
+// each violation so readers can tell which entries to distrust. Missing
+// preambles are re-recorded on the agent's next turn.
+func (r *Runtime) RepairHistory(ctx context.Context, agentID string) (HistoryReport, error) {
+	report, err := r.VerifyHistory(ctx, agentID)
+	if err != nil {
+		return report, err
+	}
+	if report.OK() {
+		return report, nil
+	}
+	if r.Bus == nil {
+		return report, fmt.Errorf("event bus unavailable")
+	}
+	for _, v := range report.Violations {
+		data := map[string]any{
+			"violation": v.Kind,
+		}
+		if v.EntryID != "" {
+			data["entry_id"] = v.EntryID
+		}
+		if v.TaskID != "" {
+			data["referenced_task_id"] = v.TaskID
+		}
+		switch v.Kind {
+		case "duplicate_system_prompt", "duplicate_tools_config":
+			data["action"] = "superseded"
+		case "missing_system_prompt", "missing_tools_config":
+			data["action"] = "rerecord_preamble"
+			r.historyMu.Lock()
+			delete(r.historyPreambleByTask, report.AgentID)
+			r.historyMu.Unlock()
+		default:
+			data["action"] = "noted"
+		}
+		r.appendHistory(ctx, report.AgentID, "history_repair", "system", v.Message, "", v.Generation, data)
+		report.Repaired = append(report.Repaired, v.Kind)
+	}
+	return report, nil
+}
+
+func repairKey(gen int64, kind, entryID string) string {
+	return fmt.Sprintf("%d:%s:%s", gen, kind, entryID)
+}
+
+// readHistoryEntries reads agentID's whole history, oldest first.
+func (r *Runtime) readHistoryEntries(ctx context.Context, agentID string) ([]AgentHistoryEntry, error) {
+	if r.Bus == nil {
+		return nil, nil
+	}
+	var entries []AgentHistoryEntry
+	after := ""
+	for {
+		summaries, err := r.Bus.List(ctx, "history", eventbus.ListOptions{
+			ScopeType: "task",
+			ScopeID:   agentID,
+			After:     after,
+			Limit:     historyVerifyLimit,
+			Order:     "fifo",
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) == 0 {
+			return entries, nil
+		}
+		ids := make([]string, len(summaries))
+		for i, s := range summaries {
+			ids[i] = s.ID
+		}
+		events, err := r.Bus.Read(ctx, "history", ids, "")
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]eventbus.Event, len(events))
+		for _, evt := range events {
+			byID[evt.ID] = evt
+		}
+		for _, id := range ids {
+			evt, ok := byID[id]
+			if !ok {
+				continue
+			}
+			if entry, ok := HistoryEntryFromEvent(evt); ok {
+				entries = append(entries, entry)
+			}
+		}
+		if len(summaries) < historyVerifyLimit {
+			return entries, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// inboxClearPage is how many events ClearInbox lists at a time.
+const inboxClearPage = 1000
+
+// InboxFilter selects unread events to clear. Empty fields match everything.
+type InboxFilter struct {
+	Kind      string        `json:"kind,omitempty"`
+	Source    string        `json:"source,omitempty"`
+	OlderThan time.Duration `json:"-"`
+}
+
+type InboxClearResult struct {
+	Cleared  int                 `json:"cleared"`
+	EventIDs map[string][]string `json:"event_ids"`
+}
+
+// ClearInbox acks the agent's unread events that match filter so they never
+// start a turn, and records an inbox_cleared history entry.
+func (r *Runtime) ClearInbox(ctx context.Context, agentID string, filter InboxFilter) (InboxClearResult, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return InboxClearResult{}, fmt.Errorf("agent_id is required")
+	}
+	if r.Bus == nil {
+		return InboxClearResult{}, fmt.Errorf("event bus unavailable")
+	}
+	kind := strings.TrimSpace(filter.Kind)
+	source := strings.TrimSpace(filter.Source)
+	var cutoff time.Time
+	if filter.OlderThan > 0 {
+		cutoff = r.now().Add(-filter.OlderThan)
+	}
+
+	subscribed := r.subscribedTopics(ctx, agentID)
+	result := InboxClearResult{EventIDs: map[string][]string{}}
+	for _, stream := range schema.AgentStreams {
+		// Page forward through the whole stream; the unread events are
+		// usually the newest, behind any number of read ones.
+		after := ""
+		for {
+			summaries, err := r.Bus.List(ctx, stream, eventbus.ListOptions{
+				Reader: agentID,
+				Limit:  inboxClearPage,
+				Order:  "fifo",
+				After:  after,
+				Topics: subscribed.names(),
+			})
+			if err != nil {
+				return result, fmt.Errorf("list %s: %w", stream, err)
+			}
+			if len(summaries) == 0 {
+				break
+			}
+			after = summaries[len(summaries)-1].ID
+			ids := make([]string, 0, len(summaries))
+			for _, summary := range summaries {
+				if !summary.Read {
+					ids = append(ids, summary.ID)
+				}
+			}
+			if len(ids) > 0 {
+				matched, err := r.clearInboxEvents(ctx, stream, agentID, ids, func(evt eventbus.Event) bool {
+					if !eventReachesAgent(evt, agentID, subscribed) {
+						return false
+					}
+					if kind != "" && schema.GetMetaString(evt.Metadata, "kind") != kind {
+						return false
+					}
+					if source != "" && schema.GetMetaString(evt.Metadata, "source") != source {
+						return false
+					}
+					return cutoff.IsZero() || evt.CreatedAt.Before(cutoff)
+				})
+				if err != nil {
+					return result, err
+				}
+				if len(matched) > 0 {
+					result.EventIDs[stream] = append(result.EventIDs[stream], matched...)
+					result.Cleared += len(matched)
+				}
+			}
+			if len(summaries) < inboxClearPage {
+				break
+			}
+		}
+	}
+
+	data := map[string]any{
+		"cleared":   result.Cleared,
+		"event_ids": result.EventIDs,
+	}
+	if kind != "" {
+		data["filter_kind"] = kind
+	}
+	if source != "" {
+		data["filter_source"] = source
+	}
+	if filter.OlderThan > 0 {
+		data["filter_older_than"] = filter.OlderThan.String()
+	}
+	r.appendHistory(ctx, agentID, "inbox_cleared", "system", fmt.Sprintf("cleared %d pending events", result.Cleared), "", 0, data)
+	return result, nil
+}
+
+// clearInboxEvents acks the events of ids on stream that match and returns
+// their IDs.
+func (r *Runtime) clearInboxEvents(ctx context.Context, stream, agentID string, ids []string, match func(eventbus.Event) bool) ([]string, error) {
+	events, err := r.Bus.Read(ctx, stream, ids, "")
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", stream, err)
+	}
+	var matched []string
+	for _, evt := range events {
+		if match(evt) {
+			matched = append(matched, evt.ID)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	if err := r.Bus.Ack(ctx, stream, matched, agentID); err != nil {
+		return nil, fmt.Errorf("ack %s: %w", stream, err)
+	}
+	return matched, nil
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestClearInboxReachesUnreadEventsBehindALongReadHistory(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-inbox")
+	rt := NewRuntime(bus, mgr, nil)
+
+	push := func(body string) string {
+		evt, err := bus.Push(ctx, eventbus.EventInput{
+			Stream:    "task_input",
+			ScopeType: "task",
+			ScopeID:   "agent-inbox",
+			Body:      body,
+			Metadata:  map[string]any{"kind": "message", "source": "user"},
+		})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		return evt.ID
+	}
+	read := make([]string, 0, inboxClearPage+1)
+	for range inboxClearPage + 1 {
+		read = append(read, push("handled"))
+	}
+	if err := bus.Ack(ctx, "task_input", read, "agent-inbox"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	unread := []string{push("pending 1"), push("pending 2")}
+
+	result, err := rt.ClearInbox(ctx, "agent-inbox", InboxFilter{})
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	got := result.EventIDs["task_input"]
+	if len(got) != 2 || got[0] != unread[0] || got[1] != unread[1] {
+		t.Fatalf("expected the two newest messages cleared, got %+v", result)
+	}
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// InflightMonitor is the monitor that reconciles the cancellations of
+// running turns with their llm tasks.
+const InflightMonitor = "inflight"
+
+const (
+	inflightInterval = time.Minute
+	// inflightGrace lets a turn that just finished its llm task clear its
+	// own cancellation before the sweeper counts it as orphaned.
+	inflightGrace      = 30 * time.Second
+	maxInflightActions = 50
+)
+
+const (
+	// InflightOrphanCancelled means the sweeper cancelled and dropped the
+	// cancellation of an llm task that had ended or no longer existed.
+	InflightOrphanCancelled = "orphan_cancelled"
+	// InflightPanicRecovered means a turn panicked and its llm task was
+	// failed and its cancellation dropped.
+	InflightPanicRecovered = "panic_recovered"
+)
+
+// InflightAction is one reconciliation of a turn's cancellation.
+type InflightAction struct {
+	Action  string    `json:"action"`
+	TaskID  string    `json:"task_id,omitempty"`
+	AgentID string    `json:"agent_id,omitempty"`
+	Reason  string    `json:"reason"`
+	At      time.Time `json:"at"`
+}
+
+// InflightReport is the diagnostics view of running turns' cancellations:
+// how many are registered, how often they were swept, and the latest
+// actions, newest first.
+type InflightReport struct {
+	Inflight    int              `json:"inflight"`
+	Sweeps      int              `json:"sweeps"`
+	LastSweepAt *time.Time       `json:"last_sweep_at,omitempty"`
+	Orphans     int              `json:"orphans"`
+	Panics      int              `json:"panics"`
+	Actions     []InflightAction `json:"actions"`
+}
+
+// InflightReport reports the registered turn cancellations and what the
+// sweeper and panic recovery did with them.
+func (r *Runtime) InflightReport() InflightReport {
+	r.inflightMu.Lock()
+	defer r.inflightMu.Unlock()
+	report := r.inflightStats
+	report.Inflight = len(r.inflight)
+	report.Actions = append([]InflightAction{}, r.inflightStats.Actions...)
+	return report
+}
+
+// SweepInflight cancels and drops the cancellations of llm tasks that ended
+// or no longer exist, which a turn that panicked before clearing its own
+// leaves behind. It returns how many it dropped.
+func (r *Runtime) SweepInflight(ctx context.Context) (int, error) {
+	if r.Tasks == nil {
+		return 0, nil
+	}
+	r.inflightMu.Lock()
+	ids := make([]string, 0, len(r.inflight))
+	for id := range r.inflight {
+		ids = append(ids, id)
+	}
+	r.inflightMu.Unlock()
+
+	now := r.now()
+	swept := 0
+	var errs []error
+	for _, id := range ids {
+		reason := ""
+		agentID := ""
+		task, err := r.Tasks.Get(ctx, id)
+		switch {
+		case err != nil && strings.Contains(err.Error(), "not found"):
+			reason = "llm task no longer exists"
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		case tasks.IsTerminalStatus(task.Status) && now.Sub(task.UpdatedAt) >= inflightGrace:
+			reason = fmt.Sprintf("llm task is %s", task.Status)
+			agentID = task.Owner
+		default:
+			continue
+		}
+		if r.dropInflight(id) {
+			swept++
+			r.recordInflightAction(InflightAction{Action: InflightOrphanCancelled, TaskID: id, AgentID: agentID, Reason: reason, At: now})
+		}
+	}
+	r.inflightMu.Lock()
+	r.inflightStats.Sweeps++
+	r.inflightStats.LastSweepAt = &now
+	r.inflightMu.Unlock()
+	return swept, errors.Join(errs...)
+}
+
+func (r *Runtime) sweepInflight(ctx context.Context) error {
+	_, err := r.SweepInflight(ctx)
+	return err
+}
+
+// dropInflight cancels and removes taskID's cancellation; false when it had
+// none.
+func (r *Runtime) dropInflight(taskID string) bool {
+	r.inflightMu.Lock()
+	cancel, ok := r.inflight[taskID]
+	delete(r.inflight, taskID)
+	r.inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (r *Runtime) recordInflightAction(action InflightAction) {
+	r.inflightMu.Lock()
+	defer r.inflightMu.Unlock()
+	switch action.Action {
+	case InflightOrphanCancelled:
+		r.inflightStats.Orphans++
+	case InflightPanicRecovered:
+		r.inflightStats.Panics++
+	}
+	actions := append([]InflightAction{action}, r.inflightStats.Actions...)
+	if len(actions) > maxInflightActions {
+		actions = actions[:maxInflightActions]
+	}
+	r.inflightStats.Actions = actions
+}
+
+// turnGuard holds the llm task of the turn in progress so a panic can be
+// recorded on it.
+type turnGuard struct {
+	llmTaskID string
+}
+
+type turnGuardKey struct{}
+
+func withTurnGuard(ctx context.Context, guard *turnGuard) context.Context {
+	return context.WithValue(ctx, turnGuardKey{}, guard)
+}
+
+func turnGuardFromContext(ctx context.Context) *turnGuard {
+	guard, _ := ctx.Value(turnGuardKey{}).(*turnGuard)
+	return guard
+}
+
+// asLoopPanic turns a recovered value into a loopPanic, keeping the stack of
+// one that was already recovered further down.
+func asLoopPanic(p any) loopPanic {
+	if lp, ok := p.(loopPanic); ok {
+		return lp
+	}
+	return loopPanic{value: p, stack: string(debug.Stack())}
+}
+
+// recordTurnPanic fails the llm task of a turn that panicked, with the panic
+// and its stack as an update, and drops its cancellation.
+func (r *Runtime) recordTurnPanic(agentID, llmTaskID string, p loopPanic) {
+	action := InflightAction{Action: InflightPanicRecovered, TaskID: llmTaskID, AgentID: agentID, Reason: p.Error(), At: r.now()}
+	r.recordInflightAction(action)
+	if llmTaskID == "" {
+		return
+	}
+	r.dropInflight(llmTaskID)
+	if r.Tasks == nil {
+		return
+	}
+	ctx := agentcontext.WithTaskID(context.Background(), agentID)
+	_ = r.Tasks.RecordUpdate(ctx, llmTaskID, "turn_panic", map[string]any{
+		"error": p.Error(),
+		"stack": clipText(p.stack, maxLoopStackChars),
+	})
+	if task, err := r.Tasks.Get(ctx, llmTaskID); err == nil && !tasks.IsTerminalStatus(task.Status) {
+		_ = r.Tasks.Fail(ctx, llmTaskID, p.Error())
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestSweepInflightCancelsOrphansAndPanicsFailTheTurn(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-inflight")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	now := time.Now()
+	rt.nowFn = func() time.Time { return now }
+
+	spawn := func() tasks.Task {
+		task, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "agent-inflight", Mode: "sync"})
+		if err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		_ = mgr.MarkRunning(ctx, task.ID)
+		return task
+	}
+	done, running := spawn(), spawn()
+	if err := mgr.Complete(ctx, done.ID, nil); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	doneCtx, doneCancel := context.WithCancel(ctx)
+	defer doneCancel()
+	rt.registerInflight(done.ID, doneCancel)
+	rt.registerInflight(running.ID, func() {})
+	rt.registerInflight("gone", func() {})
+
+	// A task that just ended is left to clear its own cancellation.
+	if n, err := rt.SweepInflight(ctx); err != nil || n != 1 {
+		t.Fatalf("expected only the missing task swept, got %d, %v", n, err)
+	}
+	now = now.Add(time.Minute)
+	if n, err := rt.SweepInflight(ctx); err != nil || n != 1 || doneCtx.Err() == nil {
+		t.Fatalf("expected the ended turn cancelled, got %d, %v", n, err)
+	}
+	report := rt.InflightReport()
+	if report.Inflight != 1 || report.Sweeps != 2 || report.Orphans != 2 || report.Actions[0].TaskID != done.ID || report.Actions[0].AgentID != "agent-inflight" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// The session is built after the turn's llm task is spawned.
+	rt.LLMFactory = func() (*llms.LLM, error) { panic("factory exploded") }
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		_, _ = rt.HandleMessage(ctx, "agent-inflight", "user", "hello", nil)
+	}()
+	lp, ok := recovered.(loopPanic)
+	if !ok || lp.stack == "" {
+		t.Fatalf("expected the panic to reach the caller as a loopPanic, got %#v", recovered)
+	}
+	report = rt.InflightReport()
+	if report.Panics != 1 || report.Inflight != 1 || report.Actions[0].Action != InflightPanicRecovered {
+		t.Fatalf("unexpected report after the panic %+v", report)
+	}
+	turn, err := mgr.Get(ctx, report.Actions[0].TaskID)
+	if err != nil || turn.Status != tasks.StatusFailed {
+		t.Fatalf("expected the panicked turn failed, got %+v, %v", turn, err)
+	}
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// LabelMonitor is the monitor that titles agents' current conversations.
+const LabelMonitor = "labels"
+
+const labelInterval = 5 * time.Minute
+
+const (
+	// labelRefreshMessages is how many new messages a labeled generation
+	// needs before it is titled again.
+	labelRefreshMessages = 6
+	labelTranscriptChars = 6000
+	labelMessageChars    = 500
+)
+
+// refreshLabels titles every agent whose current generation is unlabeled or
+// has moved on since it was last labeled.
+func (r *Runtime) refreshLabels(ctx context.Context) error {
+	if r.Labels == nil || r.Titler == nil || r.Tasks == nil {
+		return nil
+	}
+	agents, err := r.Tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return err
+	}
+	existing, err := r.Labels.All(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, agent := range agents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prev, ok := existing[agent.ID]
+		if err := r.labelAgent(ctx, agent.ID, prev, ok); err != nil {
+			errs = append(errs, fmt.Errorf("label %s: %w", agent.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runtime) labelAgent(ctx context.Context, agentID string, prev labels.Label, labeled bool) error {
+	generation := r.historyGeneration(ctx, agentID)
+	entries, err := r.readHistoryEntries(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	transcript, messages := labelTranscript(entries, generation)
+	if messages == 0 {
+		return nil
+	}
+	if labeled && prev.Generation == generation && messages < prev.Messages+labelRefreshMessages {
+		return nil
+	}
+	title, topics, err := r.Titler.Title(ctx, transcript)
+	if err != nil {
+		return err
+	}
+	_, err = r.Labels.Put(ctx, labels.Label{
+		AgentID:    agentID,
+		Generation: generation,
+		Title:      title,
+		Topics:     topics,
+		Messages:   messages,
+	})
+	return err
+}
+
+// labelTranscript renders a generation's user and assistant messages for the
+// titler. When it is too long the opening message is kept along with as many
+// of the latest messages as fit, since both say what the agent is doing.
+func labelTranscript(entries []AgentHistoryEntry, generation int64) (string, int) {
+	var lines []string
+	for _, entry := range entries {
+		if entry.Generation != generation {
+			continue
+		}
+		var speaker string
+		switch entry.Type {
+		case "user_message":
+			speaker = "User"
+		case "assistant_message":
+			speaker = "Agent"
+		default:
+			continue
+		}
+		text := strings.Join(strings.Fields(entry.Content), " ")
+		if text == "" {
+			continue
+		}
+		lines = append(lines, speaker+": "+clipText(text, labelMessageChars))
+	}
+	if len(lines) == 0 {
+		return "", 0
+	}
+	total := 0
+	for _, line := range lines {
+		total += len(line) + 1
+	}
+	if total <= labelTranscriptChars {
+		return strings.Join(lines, "\n"), len(lines)
+	}
+	budget := labelTranscriptChars - len(lines[0]) - 1
+	start := len(lines)
+	for start > 1 && budget-len(lines[start-1])-1 >= 0 {
+		budget -= len(lines[start-1]) + 1
+		start--
+	}
+	kept := append([]string{lines[0], "…"}, lines[start:]...)
+	return strings.Join(kept, "\n"), len(lines)
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/labels"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type fakeTitler struct {
+	transcripts []string
+}
+
+func (f *fakeTitler) Title(_ context.Context, transcript string) (string, []string, error) {
+	f.transcripts = append(f.transcripts, transcript)
+	return fmt.Sprintf("Title %d", len(f.transcripts)), []string{"deploys"}, nil
+}
+
+func TestRefreshLabelsTitlesChangedGenerations(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	rt.Labels = labels.NewStore(db)
+	titler := &fakeTitler{}
+	rt.Titler = titler
+	createTestAgent(t, mgr, "agent-a")
+	createTestAgent(t, mgr, "agent-idle")
+	ctx := context.Background()
+
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "The api-service deploy failed", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "Looking at the logs now.", "", 1, nil)
+	if err := rt.refreshLabels(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if len(titler.transcripts) != 1 || titler.transcripts[0] != "User: The api-service deploy failed\nAgent: Looking at the logs now." {
+		t.Fatalf("expected one transcript for the active agent, got %q", titler.transcripts)
+	}
+	label, ok, err := rt.Labels.Get(ctx, "agent-a")
+	if err != nil || !ok || label.Title != "Title 1" || label.Generation != 1 || label.Messages != 2 {
+		t.Fatalf("unexpected label %+v ok=%v err=%v", label, ok, err)
+	}
+
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "any luck?", "", 1, nil)
+	if err := rt.refreshLabels(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if len(titler.transcripts) != 1 {
+		t.Fatalf("expected no relabel after one new message")
+	}
+
+	if _, err := rt.CompactAgentContext(ctx, "agent-a", "test"); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "Now rotate the billing keys", "", 2, nil)
+	if err := rt.refreshLabels(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if len(titler.transcripts) != 2 || strings.Contains(titler.transcripts[1], "deploy") {
+		t.Fatalf("expected the new generation alone to be labeled, got %q", titler.transcripts)
+	}
+	if label, _, _ := rt.Labels.Get(ctx, "agent-a"); label.Generation != 2 || label.Title != "Title 2" {
+		t.Fatalf("expected relabeled generation, got %+v", label)
+	}
+}
+
+func TestCompactAgentContextNotifiesAwaitingAgent(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+	if _, err := mgr.Spawn(ctx, tasks.Spec{
+		ID:       "agent-b",
+		Type:     "agent",
+		Owner:    "agent-a",
+		Metadata: map[string]any{"notify_target": "agent-a"},
+	}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	for _, id := range []string{"agent-a", "agent-b"} {
+		if _, err := rt.CompactAgentContext(ctx, id, "test"); err != nil {
+			t.Fatalf("compact %s: %v", id, err)
+		}
+		task, err := mgr.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if got := tasks.Generation(task); got != 2 {
+			t.Fatalf("expected %s on generation 2, got %d", id, got)
+		}
+	}
+	for id, want := range map[string]bool{"agent-a": false, "agent-b": true} {
+		_, found, err := mgr.LatestUpdate(ctx, id, tasks.GenerationChangedKind)
+		if err != nil || found != want {
+			t.Fatalf("expected generation_changed update for %s = %v, got %v (err %v)", id, want, found, err)
+		}
+	}
+}
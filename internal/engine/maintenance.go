@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+const maintenanceDigestListLimit = 20
+
+// inMaintenance reports whether a maintenance window currently covers
+// agentID. Lookup errors fail open so wakes are never lost.
+func (r *Runtime) inMaintenance(ctx context.Context, agentID string) bool {
+	if r.Maintenance == nil {
+		return false
+	}
+	windows, err := r.Maintenance.Active(ctx, agentID)
+	return err == nil && len(windows) > 0
+}
+
+func (r *Runtime) deferWake(agentID, eventID string) {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+	if r.deferredWakes == nil {
+		r.deferredWakes = map[string]map[string]struct{}{}
+	}
+	ids := r.deferredWakes[agentID]
+	if ids == nil {
+		ids = map[string]struct{}{}
+		r.deferredWakes[agentID] = ids
+	}
+	ids[eventID] = struct{}{}
+}
+
+func (r *Runtime) takeDeferredWakes(agentID string) map[string]struct{} {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+	ids := r.deferredWakes[agentID]
+	delete(r.deferredWakes, agentID)
+	return ids
+}
+
+// deliverMaintenanceDigest runs one turn covering every wake deferred during
+// maintenance that is still unread. The deferred events themselves reach the
+// model as context updates of that turn.
+func (r *Runtime) deliverMaintenanceDigest(ctx context.Context, agentID string, events []eventbus.Event) bool {
+	deferred := r.takeDeferredWakes(agentID)
+	if len(deferred) == 0 {
+		return false
+	}
+	var pending []eventbus.Event
+	for _, evt := range events {
+		if _, ok := deferred[evt.ID]; ok {
+			pending = append(pending, evt)
+		}
+	}
+	if len(pending) == 0 {
+		return false
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+
+	ids := make([]string, 0, len(pending))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Maintenance window ended. %d wake event(s) were deferred:", len(pending))
+	for i, evt := range pending {
+		ids = append(ids, evt.ID)
+		if i >= maintenanceDigestListLimit {
+			continue
+		}
+		summary := strings.TrimSpace(evt.Subject)
+		if summary == "" {
+			summary = strings.TrimSpace(evt.Body)
+		}
+		if len(summary) > 120 {
+			summary = summary[:120] + "..."
+		}
+		fmt.Fprintf(&b, "\n- [%s] %s", evt.Stream, summary)
+	}
+	if extra := len(pending) - maintenanceDigestListLimit; extra > 0 {
+		fmt.Fprintf(&b, "\n- ... and %d more", extra)
+	}
+	meta := map[string]any{
+		"kind":               "maintenance_digest",
+		"priority":           "wake",
+		"deferred_count":     len(pending),
+		"deferred_event_ids": ids,
+	}
+	if _, err := r.HandleMessage(ctx, agentID, "runtime", b.String(), meta); err != nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// EmbeddingMonitor is the monitor that embeds new memories and messages for
+// semantic search.
+const EmbeddingMonitor = "embeddings"
+
+const (
+	embeddingInterval = time.Minute
+	// maxEmbedPerSweep bounds the texts embedded, and the history entries
+	// scanned per agent, in one sweep; a backlog is worked off over several.
+	maxEmbedPerSweep = 256
+	// maxPassageChars clips the message text embedded as a passage.
+	maxPassageChars = 4000
+)
+
+// ErrSemanticSearchUnavailable means no embedding provider is configured.
+var ErrSemanticSearchUnavailable = errors.New("semantic search unavailable: no embedding provider configured")
+
+// semanticIndex is an agent's cached index and the store version it was
+// built from.
+type semanticIndex struct {
+	version string
+	index   *memory.Index
+}
+
+// SearchMemory returns agentID's memories and past messages closest in
+// meaning to q.Text, best first.
+func (r *Runtime) SearchMemory(ctx context.Context, agentID string, q memory.SearchQuery) ([]memory.Hit, error) {
+	if r.Memories == nil || r.Embedder == nil {
+		return nil, ErrSemanticSearchUnavailable
+	}
+	agentID = strings.TrimSpace(agentID)
+	text := strings.TrimSpace(q.Text)
+	if text == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	for _, source := range q.Sources {
+		if source != memory.SourceMemory && source != memory.SourceHistory {
+			return nil, fmt.Errorf("unknown source %q", source)
+		}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = memory.DefaultSearchLimit
+	}
+	limit = min(limit, memory.MaxSearchLimit)
+	ix, err := r.semanticIndex(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if ix.Len() == 0 {
+		return []memory.Hit{}, nil
+	}
+	vectors, err := r.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	hits := ix.Search(vectors[0], limit, q.Sources...)
+	if hits == nil {
+		hits = []memory.Hit{}
+	}
+	return hits, nil
+}
+
+// semanticIndex returns agentID's index, rebuilding it when its memories or
+// passages changed since it was built.
+func (r *Runtime) semanticIndex(ctx context.Context, agentID string) (*memory.Index, error) {
+	model := r.Embedder.Model()
+	version, err := r.Memories.Version(ctx, agentID, model)
+	if err != nil {
+		return nil, err
+	}
+	r.embedMu.Lock()
+	cached, ok := r.semanticIndexes[agentID]
+	r.embedMu.Unlock()
+	if ok && cached.version == version && cached.index.Model() == model {
+		return cached.index, nil
+	}
+	ix, err := r.Memories.BuildIndex(ctx, agentID, model)
+	if err != nil {
+		return nil, err
+	}
+	r.embedMu.Lock()
+	if r.semanticIndexes == nil {
+		r.semanticIndexes = map[string]semanticIndex{}
+	}
+	r.semanticIndexes[agentID] = semanticIndex{version: version, index: ix}
+	r.embedMu.Unlock()
+	return ix, nil
+}
+
+// embed embeds texts, failing unless there is a vector for each.
+func (r *Runtime) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := r.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embed: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+func (r *Runtime) embedPending(ctx context.Context) error {
+	_, err := r.EmbedPending(ctx)
+	return err
+}
+
+// EmbedPending embeds memories without a vector by the current model, then
+// the messages added to agents' history since the last sweep, and returns
+// how many texts it embedded. It does nothing without an Embedder.
+func (r *Runtime) EmbedPending(ctx context.Context) (int, error) {
+	if r.Memories == nil || r.Embedder == nil {
+		return 0, nil
+	}
+	model := r.Embedder.Model()
+	pending, err := r.Memories.Unembedded(ctx, model, maxEmbedPerSweep)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	if len(pending) > 0 {
+		texts := make([]string, len(pending))
+		for i, m := range pending {
+			texts[i] = memoryEmbeddingText(m)
+		}
+		vectors, err := r.embed(ctx, texts)
+		if err != nil {
+			return 0, err
+		}
+		for i, m := range pending {
+			if err := r.Memories.SetEmbedding(ctx, m, model, vectors[i]); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if r.Tasks == nil || r.Bus == nil {
+		return n, nil
+	}
+	agents, err := r.Tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return n, err
+	}
+	var errs []error
+	for _, agent := range agents {
+		if n >= maxEmbedPerSweep {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		added, err := r.embedHistory(ctx, agent.ID, model, maxEmbedPerSweep-n)
+		n += added
+		if err != nil {
+			errs = append(errs, fmt.Errorf("embed history of %s: %w", agent.ID, err))
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// embedHistory embeds up to budget of agentID's messages after its cursor
+// as passages, and forgets the passages of turns retracted since.
+func (r *Runtime) embedHistory(ctx context.Context, agentID, model string, budget int) (int, error) {
+	after, err := r.embedCursor(ctx, agentID, model)
+	if err != nil {
+		return 0, err
+	}
+	summaries, err := r.Bus.List(ctx, "history", eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		After:     after,
+		Limit:     budget,
+		Order:     "fifo",
+	})
+	if err != nil {
+		if after != "" {
+			// The cursor's entry is gone, archived perhaps; rescan from
+			// the start, skipping what is already embedded.
+			r.setEmbedCursor(agentID, "")
+		}
+		return 0, err
+	}
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(summaries))
+	for i, s := range summaries {
+		ids[i] = s.ID
+	}
+	events, err := r.Bus.Read(ctx, "history", ids, "")
+	if err != nil {
+		return 0, err
+	}
+	embedded, err := r.Memories.EmbeddedPassages(ctx, model, ids)
+	if err != nil {
+		return 0, err
+	}
+	var passages []memory.Passage
+	var retracted []string
+	for _, evt := range events {
+		entry, ok := HistoryEntryFromEvent(evt)
+		if !ok {
+			continue
+		}
+		if id := retractedTaskID(entry); id != "" {
+			retracted = append(retracted, id)
+			continue
+		}
+		text := passageText(entry)
+		if text == "" || embedded[entry.ID] {
+			continue
+		}
+		passages = append(passages, memory.Passage{
+			ID:             entry.ID,
+			AgentID:        agentID,
+			TaskID:         entry.TaskID,
+			Role:           entry.Role,
+			Text:           text,
+			EmbeddingModel: model,
+			CreatedAt:      entry.CreatedAt,
+		})
+	}
+	for _, id := range retracted {
+		passages = deletePassagesOfTurn(passages, id)
+		if err := r.Memories.ForgetTurn(ctx, agentID, id); err != nil {
+			return 0, err
+		}
+	}
+	if len(passages) > 0 {
+		texts := make([]string, len(passages))
+		for i, p := range passages {
+			texts[i] = p.Text
+		}
+		vectors, err := r.embed(ctx, texts)
+		if err != nil {
+			return 0, err
+		}
+		for i := range passages {
+			passages[i].Embedding = vectors[i]
+		}
+		if err := r.Memories.SavePassages(ctx, passages); err != nil {
+			return 0, err
+		}
+	}
+	r.setEmbedCursor(agentID, ids[len(ids)-1])
+	return len(passages), nil
+}
+
+// embedCursor is the last history entry of agentID the monitor has seen. A
+// fresh runtime resumes after the newest passage embedded by model.
+func (r *Runtime) embedCursor(ctx context.Context, agentID, model string) (string, error) {
+	r.embedMu.Lock()
+	after, ok := r.embedCursors[agentID]
+	r.embedMu.Unlock()
+	if ok {
+		return after, nil
+	}
+	latest, found, err := r.Memories.LatestPassage(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+	if found && latest.EmbeddingModel == model {
+		after = latest.ID
+	}
+	r.setEmbedCursor(agentID, after)
+	return after, nil
+}
+
+func (r *Runtime) setEmbedCursor(agentID, after string) {
+	r.embedMu.Lock()
+	defer r.embedMu.Unlock()
+	if r.embedCursors == nil {
+		r.embedCursors = map[string]string{}
+	}
+	r.embedCursors[agentID] = after
+}
+
+// passageText is the text of a user or assistant message worth embedding,
+// or "" for any other entry.
+func passageText(entry AgentHistoryEntry) string {
+	switch entry.Type {
+	case "user_message":
+	case "assistant_message":
+		if failed, _ := entry.Data["error"].(bool); failed {
+			return ""
+		}
+	default:
+		return ""
+	}
+	text := strings.TrimSpace(entry.Content)
+	if runes := []rune(text); len(runes) > maxPassageChars {
+		text = string(runes[:maxPassageChars])
+	}
+	return text
+}
+
+// memoryEmbeddingText is what is embedded for a memory: its text, after its
+// key when the key was given rather than generated.
+func memoryEmbeddingText(m memory.Memory) string {
+	if m.Key == "" || m.Key == m.ID {
+		return m.Text
+	}
+	return m.Key + ": " + m.Text
+}
+
+func deletePassagesOfTurn(passages []memory.Passage, taskID string) []memory.Passage {
+	out := passages[:0]
+	for _, p := range passages {
+		if p.TaskID != taskID {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// conceptEmbedder maps words to a few concepts, so texts about the same
+// thing are close without sharing words.
+type conceptEmbedder struct{}
+
+var testConcepts = [][]string{
+	{"deploy", "deploys", "release", "ship", "rollout"},
+	{"invoice", "invoices", "billing", "payment", "paid"},
+	{"lunch", "food", "pizza", "hungry"},
+}
+
+func (e *conceptEmbedder) Model() string { return "test/concepts" }
+
+func (e *conceptEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(testConcepts)+1)
+		v[len(testConcepts)] = 0.1
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			word = strings.Trim(word, ".,?!:")
+			for c, words := range testConcepts {
+				for _, w := range words {
+					if w == word {
+						v[c]++
+					}
+				}
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestSearchMemoryFindsMemoriesAndMessagesByMeaning(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-semantic")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	rt.Memories = memory.NewStore(db)
+	if _, err := rt.SearchMemory(ctx, "agent-semantic", memory.SearchQuery{Text: "x"}); !errors.Is(err, ErrSemanticSearchUnavailable) {
+		t.Fatalf("expected search unavailable without an embedder, got %v", err)
+	}
+	rt.Embedder = &conceptEmbedder{}
+
+	if _, _, err := rt.Memories.Remember(ctx, memory.Memory{AgentID: "agent-semantic", Key: "fridays", Text: "Rollout happens on Fridays from main"}); err != nil {
+		t.Fatalf("remember: %v", err)
+	}
+	rt.appendHistory(ctx, "agent-semantic", "user_message", "user", "Was the ACME invoice paid?", "llm-1", 0, nil)
+	rt.appendHistory(ctx, "agent-semantic", "assistant_message", "assistant", "Yes, the payment arrived Monday.", "llm-1", 0, nil)
+	rt.appendHistory(ctx, "agent-semantic", "user_message", "user", "Order pizza for the team", "llm-2", 0, nil)
+
+	n, err := rt.EmbedPending(ctx)
+	if err != nil || n != 4 {
+		t.Fatalf("expected a memory and three messages embedded, got %d, %v", n, err)
+	}
+	if n, err := rt.EmbedPending(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing left to embed, got %d, %v", n, err)
+	}
+
+	hits, err := rt.SearchMemory(ctx, "agent-semantic", memory.SearchQuery{Text: "when do we ship?", Limit: 1})
+	if err != nil || len(hits) != 1 || hits[0].Source != memory.SourceMemory || hits[0].Key != "fridays" {
+		t.Fatalf("expected the rollout memory, got %+v, %v", hits, err)
+	}
+	hits, err = rt.SearchMemory(ctx, "agent-semantic", memory.SearchQuery{Text: "billing question", Limit: 2, Sources: []string{memory.SourceHistory}})
+	if err != nil || len(hits) != 2 || hits[0].TaskID != "llm-1" || hits[1].TaskID != "llm-1" || hits[0].Score < 0.9 {
+		t.Fatalf("expected both billing messages, got %+v, %v", hits, err)
+	}
+
+	// A changed memory is embedded again and the index rebuilt.
+	if _, _, err := rt.Memories.Remember(ctx, memory.Memory{AgentID: "agent-semantic", Key: "fridays", Text: "Lunch is on Fridays"}); err != nil {
+		t.Fatalf("remember: %v", err)
+	}
+	if n, err := rt.EmbedPending(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the changed memory embedded, got %d, %v", n, err)
+	}
+	hits, err = rt.SearchMemory(ctx, "agent-semantic", memory.SearchQuery{Text: "I'm hungry", Limit: 2})
+	if err != nil || len(hits) != 2 || hits[0].Score < 0.9 || hits[1].Score < 0.9 {
+		t.Fatalf("expected the lunch memory and pizza message, got %+v, %v", hits, err)
+	}
+
+	// A retracted turn's messages are no longer found.
+	rt.appendHistory(ctx, "agent-semantic", "turn_retracted", "system", "Turn retracted.", "", 0, map[string]any{"retracted_task_id": "llm-1"})
+	if _, err := rt.EmbedPending(ctx); err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	hits, err = rt.SearchMemory(ctx, "agent-semantic", memory.SearchQuery{Text: "invoice", Sources: []string{memory.SourceHistory}})
+	if err != nil || len(hits) != 1 || hits[0].TaskID != "llm-2" {
+		t.Fatalf("expected the retracted turn forgotten, got %+v, %v", hits, err)
+	}
+}
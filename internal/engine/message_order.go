@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// MessageGap reports messages from Source that were skipped: the agent last
+// saw Expected-1 but the next message carried Received.
+type MessageGap struct {
+	Source   string
+	Expected int64
+	Received int64
+}
+
+func messageSequence(evt eventbus.Event) (string, int64, bool) {
+	if schema.GetMetaString(evt.Metadata, "kind") != "message" {
+		return "", 0, false
+	}
+	source := schema.GetMetaString(evt.Metadata, "source")
+	if source == "" || evt.Metadata == nil {
+		return "", 0, false
+	}
+	seq := anyToInt64(evt.Metadata["seq"])
+	if seq <= 0 {
+		return "", 0, false
+	}
+	return source, seq, true
+}
+
+// orderMessagesBySenderSeq reorders message events in place so that each
+// sender's messages appear in sequence order. Non-message events and the
+// slots used by each sender keep their positions.
+func orderMessagesBySenderSeq(events []eventbus.Event) {
+	slots := map[string][]int{}
+	for i, evt := range events {
+		if source, _, ok := messageSequence(evt); ok {
+			slots[source] = append(slots[source], i)
+		}
+	}
+	for _, idx := range slots {
+		if len(idx) < 2 {
+			continue
+		}
+		msgs := make([]eventbus.Event, len(idx))
+		for i, pos := range idx {
+			msgs[i] = events[pos]
+		}
+		sort.SliceStable(msgs, func(i, j int) bool {
+			_, si, _ := messageSequence(msgs[i])
+			_, sj, _ := messageSequence(msgs[j])
+			return si < sj
+		})
+		for i, pos := range idx {
+			events[pos] = msgs[i]
+		}
+	}
+}
+
+// observeMessageSequences records the highest sequence seen per sender for
+// agentID and returns any gaps. Events must already be in sender order.
+func (r *Runtime) observeMessageSequences(agentID string, events []eventbus.Event) []MessageGap {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return nil
+	}
+	var gaps []MessageGap
+	r.messageSeqMu.Lock()
+	defer r.messageSeqMu.Unlock()
+	if r.messageSeqs == nil {
+		r.messageSeqs = map[string]int64{}
+	}
+	for _, evt := range events {
+		source, seq, ok := messageSequence(evt)
+		if !ok {
+			continue
+		}
+		key := agentID + "\x00" + source
+		last, seen := r.messageSeqs[key]
+		if seen && seq > last+1 {
+			gaps = append(gaps, MessageGap{Source: source, Expected: last + 1, Received: seq})
+		}
+		if seq > last {
+			r.messageSeqs[key] = seq
+		}
+	}
+	return gaps
+}
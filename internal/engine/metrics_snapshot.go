@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// MetricsSnapshotMonitor is the monitor that pushes metrics_snapshot signals
+// to agents that opted in.
+const MetricsSnapshotMonitor = "metrics_snapshot"
+
+const (
+	metricsSnapshotInterval = 5 * time.Minute
+	// metricsErrorWindow is how far back the error rate looks.
+	metricsErrorWindow = time.Hour
+	// maxMetricsUnreadScan caps how many unread inputs are counted.
+	maxMetricsUnreadScan = 200
+)
+
+// MetricsSnapshot is what an agent is told about its own runtime state, so
+// it can throttle itself or tell the operator it is degraded.
+type MetricsSnapshot struct {
+	AgentID     string    `json:"agent_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// QueuedTasks and RunningTasks count unfinished tasks the agent owns;
+	// UnreadInputs counts task_input events it has not handled yet.
+	QueuedTasks  int `json:"queued_tasks"`
+	RunningTasks int `json:"running_tasks"`
+	UnreadInputs int `json:"unread_inputs"`
+	// The error rate covers non-llm tasks that finished in the window.
+	WindowSeconds int64   `json:"window_seconds"`
+	Turns         int     `json:"turns"`
+	Completed     int     `json:"completed"`
+	Failed        int     `json:"failed"`
+	ErrorRate     float64 `json:"error_rate"`
+	// TokensToday counts input and output tokens since midnight in the
+	// agent's timezone. BudgetRemaining is set only when the agent has a
+	// token budget.
+	TokensToday     int64  `json:"tokens_today"`
+	TokenBudget     int64  `json:"token_budget,omitempty"`
+	BudgetRemaining *int64 `json:"budget_remaining,omitempty"`
+}
+
+// SetAgentMetricsSnapshots turns periodic metrics_snapshot signals for an
+// agent on or off.
+func (r *Runtime) SetAgentMetricsSnapshots(taskID string, enabled bool) {
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return
+	}
+	cfg.mu.Lock()
+	cfg.MetricsSnapshots = enabled
+	cfg.mu.Unlock()
+}
+
+// SetAgentTokenBudget sets the daily token allowance reported in an agent's
+// metrics snapshots. Zero or less clears it.
+func (r *Runtime) SetAgentTokenBudget(taskID string, tokens int64) {
+	cfg := r.ensureTaskConfig(taskID)
+	if cfg == nil {
+		return
+	}
+	if tokens < 0 {
+		tokens = 0
+	}
+	cfg.mu.Lock()
+	cfg.TokenBudget = tokens
+	cfg.mu.Unlock()
+}
+
+// AgentMetrics computes an agent's current metrics snapshot.
+func (r *Runtime) AgentMetrics(ctx context.Context, agentID string) (MetricsSnapshot, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return MetricsSnapshot{}, fmt.Errorf("agent_id is required")
+	}
+	if r.Tasks == nil {
+		return MetricsSnapshot{}, fmt.Errorf("task manager unavailable")
+	}
+	now := r.now()
+	out := MetricsSnapshot{
+		AgentID:       agentID,
+		GeneratedAt:   now,
+		WindowSeconds: int64(metricsErrorWindow / time.Second),
+	}
+	for _, status := range []tasks.Status{tasks.StatusQueued, tasks.StatusRunning} {
+		list, err := r.Tasks.List(ctx, tasks.ListFilter{Owner: agentID, Status: status, Limit: 500})
+		if err != nil {
+			return MetricsSnapshot{}, fmt.Errorf("list %s tasks: %w", status, err)
+		}
+		n := 0
+		for _, task := range list {
+			if task.Type != "agent" {
+				n++
+			}
+		}
+		if status == tasks.StatusQueued {
+			out.QueuedTasks = n
+		} else {
+			out.RunningTasks = n
+		}
+	}
+	if r.Bus != nil {
+		summaries, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+			Reader:    agentID,
+			ScopeType: "task",
+			ScopeID:   agentID,
+			Limit:     maxMetricsUnreadScan,
+			Order:     "fifo",
+		})
+		if err != nil {
+			return MetricsSnapshot{}, fmt.Errorf("list unread inputs: %w", err)
+		}
+		for _, summary := range summaries {
+			if !summary.Read {
+				out.UnreadInputs++
+			}
+		}
+	}
+
+	recent, err := r.Tasks.Activity(ctx, agentID, now.Add(-metricsErrorWindow), now)
+	if err != nil {
+		return MetricsSnapshot{}, err
+	}
+	out.Turns = recent.Turns
+	out.Completed = recent.Completed
+	out.Failed = recent.Failed
+	if finished := recent.Completed + recent.Failed + recent.Cancelled; finished > 0 {
+		out.ErrorRate = float64(recent.Failed) / float64(finished)
+	}
+
+	loc := r.agentLocation(agentID)
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	today, err := r.Tasks.Activity(ctx, agentID, midnight, now.Add(time.Nanosecond))
+	if err != nil {
+		return MetricsSnapshot{}, err
+	}
+	out.TokensToday = today.Usage.InputTokens + today.Usage.OutputTokens
+	if budget := r.agentTokenBudget(agentID); budget > 0 {
+		remaining := max(budget-out.TokensToday, 0)
+		out.TokenBudget = budget
+		out.BudgetRemaining = &remaining
+	}
+	return out, nil
+}
+
+// emitMetricsSnapshots pushes a low-priority metrics_snapshot signal to every
+// agent that opted in. The signal is context for the agent's next turn; it
+// does not wake the agent.
+func (r *Runtime) emitMetricsSnapshots(ctx context.Context) error {
+	if r.Tasks == nil || r.Bus == nil {
+		return nil
+	}
+	var pushErrs []error
+	for _, agentID := range r.metricsSnapshotAgents() {
+		snapshot, err := r.AgentMetrics(ctx, agentID)
+		if err != nil {
+			pushErrs = append(pushErrs, fmt.Errorf("%s: %w", agentID, err))
+			continue
+		}
+		if _, err := r.Bus.Push(ctx, eventbus.EventInput{
+			Stream:    schema.StreamSignals,
+			ScopeType: "task",
+			ScopeID:   agentID,
+			Subject:   "metrics_snapshot",
+			Body:      metricsSnapshotBody(snapshot),
+			Payload:   map[string]any{"metrics": snapshot},
+			Metadata: map[string]any{
+				"kind":     "metrics_snapshot",
+				"priority": "low",
+				"source":   "runtime",
+			},
+		}); err != nil {
+			pushErrs = append(pushErrs, err)
+		}
+	}
+	return errors.Join(pushErrs...)
+}
+
+func (r *Runtime) metricsSnapshotAgents() []string {
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+	var out []string
+	for id, cfg := range r.taskConfigs {
+		if cfg == nil {
+			continue
+		}
+		cfg.mu.Lock()
+		enabled := cfg.MetricsSnapshots
+		cfg.mu.Unlock()
+		if enabled {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (r *Runtime) agentTokenBudget(agentID string) int64 {
+	r.configMu.RLock()
+	cfg, ok := r.taskConfigs[agentID]
+	r.configMu.RUnlock()
+	if !ok || cfg == nil {
+		return 0
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.TokenBudget
+}
+
+func metricsSnapshotBody(s MetricsSnapshot) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "metrics: %d queued, %d running tasks, %d unread inputs; error rate %.0f%% over the last hour (%d failed, %d completed)",
+		s.QueuedTasks, s.RunningTasks, s.UnreadInputs, s.ErrorRate*100, s.Failed, s.Completed)
+	if s.BudgetRemaining != nil {
+		fmt.Fprintf(&b, "; %d of %d tokens left today", *s.BudgetRemaining, s.TokenBudget)
+	} else {
+		fmt.Fprintf(&b, "; %d tokens used today", s.TokensToday)
+	}
+	return b.String()
+}
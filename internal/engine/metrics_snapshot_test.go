@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestEmitMetricsSnapshotsReachesOptedInAgents(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+	createTestAgent(t, mgr, "agent-b")
+	ctx := context.Background()
+
+	spawn := func(taskType string) tasks.Task {
+		t.Helper()
+		task, err := mgr.Spawn(ctx, tasks.Spec{Type: taskType, Owner: "agent-a"})
+		if err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		return task
+	}
+	spawn("exec")
+	if err := mgr.Fail(ctx, spawn("exec").ID, "boom"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	for range 3 {
+		if err := mgr.Complete(ctx, spawn("exec").ID, nil); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	turn := spawn("llm")
+	if err := mgr.Complete(ctx, turn.ID, nil); err != nil {
+		t.Fatalf("complete turn: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, turn.ID, "llm_usage", map[string]any{"input_tokens": 250, "output_tokens": 50}); err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+	if _, err := bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   "agent-a",
+		Subject:   "hello",
+		Body:      "hello",
+	}); err != nil {
+		t.Fatalf("push input: %v", err)
+	}
+
+	rt.SetAgentMetricsSnapshots("agent-a", true)
+	rt.SetAgentTokenBudget("agent-a", 1000)
+	rt.SetAgentModel("agent-b", "fast")
+	if err := rt.emitMetricsSnapshots(ctx); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+
+	snapshots := func(agentID string) []string {
+		t.Helper()
+		list, err := bus.List(ctx, schema.StreamSignals, eventbus.ListOptions{ScopeType: "task", ScopeID: agentID, Limit: 50})
+		if err != nil {
+			t.Fatalf("list signals: %v", err)
+		}
+		var ids []string
+		for _, summary := range list {
+			if summary.Subject == "metrics_snapshot" {
+				ids = append(ids, summary.ID)
+			}
+		}
+		return ids
+	}
+	if ids := snapshots("agent-b"); len(ids) != 0 {
+		t.Fatalf("expected no snapshot for agent-b, got %d", len(ids))
+	}
+	ids := snapshots("agent-a")
+	if len(ids) != 1 {
+		t.Fatalf("expected one snapshot for the opted-in agent, got %d", len(ids))
+	}
+	events, _ := bus.Read(ctx, schema.StreamSignals, ids, "")
+	evt := events[0]
+	if evt.ScopeID != "agent-a" || schema.GetMetaString(evt.Metadata, schema.MetaKind) != "metrics_snapshot" ||
+		schema.GetMetaString(evt.Metadata, schema.MetaPriority) != "low" {
+		t.Fatalf("unexpected snapshot event %+v", evt)
+	}
+
+	snapshot, err := rt.AgentMetrics(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	if snapshot.QueuedTasks != 1 || snapshot.UnreadInputs != 1 || snapshot.Failed != 1 || snapshot.Completed != 3 {
+		t.Fatalf("unexpected counts %+v", snapshot)
+	}
+	if snapshot.ErrorRate != 0.25 {
+		t.Fatalf("expected error rate 0.25, got %v", snapshot.ErrorRate)
+	}
+	if snapshot.TokensToday != 300 || snapshot.BudgetRemaining == nil || *snapshot.BudgetRemaining != 700 {
+		t.Fatalf("unexpected budget %+v", snapshot)
+	}
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// ErrModelOverrideNotAllowed is returned for overrides outside the
+// runtime's ModelOverrides policy.
+var ErrModelOverrideNotAllowed = errors.New("model override not allowed")
+
+// ModelOverride is a provider and model used for one turn instead of the
+// agent's own. An empty Provider means the runtime's default provider.
+type ModelOverride struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model"`
+}
+
+func (o ModelOverride) String() string {
+	if o.Provider == "" {
+		return o.Model
+	}
+	return o.Provider + "/" + o.Model
+}
+
+// CheckModelOverride resolves o's provider and reports whether the policy
+// allows it. Entries in ModelOverrides are "provider/model" or
+// "provider/*"; with none configured, every override is refused.
+func (r *Runtime) CheckModelOverride(o ModelOverride) (ModelOverride, error) {
+	o.Provider = strings.TrimSpace(o.Provider)
+	o.Model = strings.TrimSpace(o.Model)
+	if o.Model == "" {
+		return o, fmt.Errorf("model is required")
+	}
+	if o.Provider == "" {
+		o.Provider = r.LLM.Provider()
+	}
+	for _, entry := range r.ModelOverrides {
+		provider, model, ok := strings.Cut(strings.TrimSpace(entry), "/")
+		if !ok || provider != o.Provider {
+			continue
+		}
+		if model == "*" || model == o.Model {
+			return o, nil
+		}
+	}
+	return o, fmt.Errorf("%w: %s", ErrModelOverrideNotAllowed, o)
+}
+
+// turnModelOverride reads the override a message requested, if any.
+func turnModelOverride(meta map[string]any) ModelOverride {
+	return ModelOverride{
+		Provider: strings.TrimSpace(schema.GetMetaString(meta, schema.MetaProviderOverride)),
+		Model:    strings.TrimSpace(schema.GetMetaString(meta, schema.MetaModelOverride)),
+	}
+}
+
+// turnLLM builds the session for a turn: the agent's own unless the turn
+// carries an override, which is checked against policy again here since
+// messages can arrive by paths other than the API. Either way the session
+// only gets the tools toolset allows.
+func (r *Runtime) turnLLM(cfg *taskConfig, override ModelOverride, toolset Toolset) (*llms.LLM, error) {
+	if override.Model == "" {
+		return r.ensureAgentLLM(cfg, toolset)
+	}
+	override, err := r.CheckModelOverride(override)
+	if err != nil {
+		return nil, err
+	}
+	if r.OverrideLLMFactory != nil {
+		return r.OverrideLLMFactory(override)
+	}
+	if r.LLM == nil {
+		return nil, fmt.Errorf("LLM not configured")
+	}
+	opts := ai.SessionOptions{Provider: override.Provider, Model: override.Model, ToolFilter: toolset.toolFilter()}
+	if cfg != nil && override.Provider == r.LLM.Provider() {
+		cfg.mu.Lock()
+		opts.ProviderTools = cfg.ProviderTools
+		cfg.mu.Unlock()
+	}
+	return r.LLM.NewSessionWithOptions(opts)
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestCheckModelOverrideFollowsPolicy(t *testing.T) {
+	rt := &Runtime{ModelOverrides: []string{"anthropic/claude-opus-4-1", "openai-responses/*"}}
+	cases := []struct {
+		override ModelOverride
+		allowed  bool
+	}{
+		{ModelOverride{Provider: "anthropic", Model: "claude-opus-4-1"}, true},
+		{ModelOverride{Provider: "anthropic", Model: "claude-3-5-haiku-latest"}, false},
+		{ModelOverride{Provider: "openai-responses", Model: "o3"}, true},
+		{ModelOverride{Provider: "google", Model: "gemini-2.5-pro"}, false},
+	}
+	for _, tc := range cases {
+		_, err := rt.CheckModelOverride(tc.override)
+		if allowed := err == nil; allowed != tc.allowed {
+			t.Fatalf("%s: expected allowed=%v, got %v", tc.override, tc.allowed, err)
+		}
+		if err != nil && !errors.Is(err, ErrModelOverrideNotAllowed) {
+			t.Fatalf("%s: expected policy error, got %v", tc.override, err)
+		}
+	}
+	if _, err := rt.CheckModelOverride(ModelOverride{Provider: "anthropic"}); err == nil || errors.Is(err, ErrModelOverrideNotAllowed) {
+		t.Fatalf("expected missing model to be a request error, got %v", err)
+	}
+	if _, err := (&Runtime{}).CheckModelOverride(ModelOverride{Provider: "anthropic", Model: "claude-opus-4-1"}); !errors.Is(err, ErrModelOverrideNotAllowed) {
+		t.Fatalf("expected overrides to be refused without a policy, got %v", err)
+	}
+}
+
+func TestHandleMessageUsesModelOverrideForOneTurn(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	own := &historyCapture{}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(own)})
+	rt.ModelOverrides = []string{"anthropic/claude-opus-4-1"}
+	overridden := &historyCapture{}
+	var got ModelOverride
+	rt.OverrideLLMFactory = func(o ModelOverride) (*llms.LLM, error) {
+		got = o
+		return llms.New(overridden), nil
+	}
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+
+	session, err := rt.HandleMessage(ctx, "agent-a", "user", "hello", map[string]any{
+		"model_override":    "claude-opus-4-1",
+		"provider_override": "anthropic",
+	})
+	if err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	if got.Model != "claude-opus-4-1" || overridden.NumCalls() != 1 || own.NumCalls() != 0 {
+		t.Fatalf("expected the turn on the override model, got %+v (override calls %d, own calls %d)", got, overridden.NumCalls(), own.NumCalls())
+	}
+	llmTask, err := mgr.Get(ctx, session.LLMTaskID)
+	if err != nil {
+		t.Fatalf("get llm task: %v", err)
+	}
+	if llmTask.Metadata["model_override"] != "claude-opus-4-1" || llmTask.Metadata["provider_override"] != "anthropic" {
+		t.Fatalf("expected override in llm task metadata, got %v", llmTask.Metadata)
+	}
+
+	if _, err := rt.HandleMessage(ctx, "agent-a", "user", "again", nil); err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	if own.NumCalls() != 1 || overridden.NumCalls() != 1 {
+		t.Fatalf("expected the next turn on the agent's own model, got own=%d override=%d", own.NumCalls(), overridden.NumCalls())
+	}
+
+	session, err = rt.HandleMessage(ctx, "agent-a", "user", "sneaky", map[string]any{"model_override": "gpt-5", "provider_override": "openai-responses"})
+	if err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	if overridden.NumCalls() != 1 || session.LastError == "" {
+		t.Fatalf("expected a disallowed override to fail the turn, got %q", session.LastError)
+	}
+}
@@ -0,0 +1,20 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+)
+
+// withAgentNamespace scopes ctx to the agent's namespace, so the tasks and
+// events its turn creates stay in it.
+func (r *Runtime) withAgentNamespace(ctx context.Context, agentID string) context.Context {
+	if r.Tasks == nil {
+		return ctx
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return ctx
+	}
+	return agentcontext.WithNamespace(ctx, task.Namespace)
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/preprocess"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// PreprocessorsFromMetadata reads the preprocessor steps stored in an agent
+// task's metadata. A malformed entry is treated as none.
+func PreprocessorsFromMetadata(metadata map[string]any) []preprocess.Step {
+	steps, _ := preprocess.ParseSteps(metadata[schema.MetaPreprocessors])
+	return steps
+}
+
+// AgentPreprocessors returns the preprocessor steps stored on the agent's
+// task.
+func (r *Runtime) AgentPreprocessors(ctx context.Context, agentID string) ([]preprocess.Step, error) {
+	if r.Tasks == nil {
+		return nil, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return PreprocessorsFromMetadata(task.Metadata), nil
+}
+
+// SetAgentPreprocessors stores the steps the agent's inbound messages pass
+// through after the runtime's own; an empty list removes them.
+func (r *Runtime) SetAgentPreprocessors(ctx context.Context, agentID string, steps []preprocess.Step) ([]preprocess.Step, error) {
+	if r.Tasks == nil {
+		return nil, fmt.Errorf("task manager unavailable")
+	}
+	if err := preprocess.Validate(steps); err != nil {
+		return nil, err
+	}
+	var value any
+	if len(steps) > 0 {
+		value = steps
+	}
+	if _, err := r.Tasks.MergeMetadata(ctx, agentID, map[string]any{schema.MetaPreprocessors: value}); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// PreprocessInbound runs an inbound external message for agentID through the
+// runtime's preprocessors and then the agent's, limited to those that apply
+// to the message's service_id. It returns the body to deliver and, when a
+// step changed the message or failed, the event payload that keeps the
+// original for audit. Extracted attachments are added to meta as parts.
+func (r *Runtime) PreprocessInbound(ctx context.Context, agentID, body string, meta map[string]any) (string, map[string]any) {
+	steps := r.Preprocessors
+	if agentSteps, err := r.AgentPreprocessors(ctx, agentID); err == nil {
+		steps = append(steps[:len(steps):len(steps)], agentSteps...)
+	}
+	if len(steps) == 0 {
+		return body, nil
+	}
+	res := preprocess.Run(ctx, steps, body, schema.GetMetaString(meta, "service_id"), r.Translator)
+	if strings.TrimSpace(res.Text) == "" {
+		// A message is never preprocessed away.
+		res.Text = body
+		res.Errors = append(res.Errors, "preprocessors left the message empty")
+	}
+	if len(res.Applied) == 0 && len(res.Errors) == 0 {
+		return body, nil
+	}
+	if len(res.Parts) > 0 && meta != nil {
+		meta[schema.MetaParts] = append(schema.ParseParts(meta[schema.MetaParts]), res.Parts...)
+	}
+	payload := map[string]any{
+		"original_message": body,
+		"preprocessors":    res.Applied,
+	}
+	if len(res.Errors) > 0 {
+		payload["preprocess_errors"] = res.Errors
+	}
+	return res.Text, payload
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// Progress states published on the progress stream while a turn executes.
+// Tool activity is published as "running_tool:<name>".
+const (
+	ProgressTurnStarted  = "turn_started"
+	ProgressThinking     = "thinking"
+	ProgressRunningTool  = "running_tool"
+	ProgressResponding   = "responding"
+	ProgressTurnFinished = "turn_finished"
+)
+
+// progressReporter publishes a turn's activity states, skipping repeats so
+// streamed deltas produce one event per state change.
+type progressReporter struct {
+	r         *Runtime
+	agentID   string
+	llmTaskID string
+	last      string
+}
+
+func (r *Runtime) newProgressReporter(agentID, llmTaskID string) *progressReporter {
+	return &progressReporter{r: r, agentID: agentID, llmTaskID: llmTaskID}
+}
+
+func (p *progressReporter) report(ctx context.Context, state string) {
+	p.reportTool(ctx, state, "")
+}
+
+func (p *progressReporter) reportTool(ctx context.Context, state, toolName string) {
+	if p == nil || p.r == nil || p.r.Bus == nil {
+		return
+	}
+	toolName = strings.TrimSpace(toolName)
+	subject := state
+	if state == ProgressRunningTool && toolName != "" {
+		subject = state + ":" + toolName
+	}
+	if subject == p.last {
+		return
+	}
+	p.last = subject
+	meta := map[string]any{
+		"agent_id": p.agentID,
+		"state":    state,
+		"task_id":  p.llmTaskID,
+	}
+	if toolName != "" {
+		meta["tool_name"] = toolName
+	}
+	evt, err := p.r.Bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamProgress,
+		ScopeType: "task",
+		ScopeID:   p.agentID,
+		Subject:   subject,
+		Body:      subject,
+		Metadata:  meta,
+		SourceID:  p.agentID,
+	})
+	if err == nil {
+		p.r.publishUI(p.agentID, UIProgress, evt)
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestHandleMessagePublishesProgressStates(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := newMultiExecTurnProvider([]llms.ToolCall{
+		{ID: "noop-1", Name: "noop", Arguments: []byte(`{"comment":"waiting"}`)},
+	}, "done")
+	client := &ai.Client{LLM: llms.New(provider, agenttools.NoopTool())}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "agent-progress")
+
+	ctx := context.Background()
+	if _, err := rt.HandleMessage(ctx, "agent-progress", "user", "hi", nil); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+
+	summaries, err := bus.List(ctx, schema.StreamProgress, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   "agent-progress",
+		Order:     "fifo",
+		Limit:     50,
+	})
+	if err != nil {
+		t.Fatalf("list progress: %v", err)
+	}
+	var states []string
+	for _, s := range summaries {
+		states = append(states, s.Subject)
+	}
+	want := []string{
+		ProgressTurnStarted,
+		ProgressRunningTool + ":noop",
+		ProgressThinking,
+		ProgressResponding,
+		ProgressTurnFinished,
+	}
+	if len(states) != len(want) {
+		t.Fatalf("expected progress %v, got %v", want, states)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("expected progress %v, got %v", want, states)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// maxDiffLines bounds the line diff of one section; longer texts are
+// reported as wholly replaced.
+const maxDiffLines = 4000
+
+// TurnPrompt is what the model was given on one turn, rebuilt from the
+// agent's history: the system prompt of the turn's generation, the earlier
+// conversation, and the turn's own input and context events.
+type TurnPrompt struct {
+	AgentID       string               `json:"agent_id"`
+	LLMTaskID     string               `json:"llm_task_id"`
+	Generation    int64                `json:"generation"`
+	Model         string               `json:"model,omitempty"`
+	Tools         []string             `json:"tools"`
+	SystemPrompt  string               `json:"system_prompt"`
+	Messages      []PromptMessage      `json:"messages"`
+	Inputs        []string             `json:"inputs"`
+	ContextEvents []PromptContextEvent `json:"context_events"`
+}
+
+type PromptMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type PromptContextEvent struct {
+	Stream   string `json:"stream"`
+	EventID  string `json:"event_id"`
+	Priority string `json:"priority,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Body     string `json:"body,omitempty"`
+}
+
+func (e PromptContextEvent) key() string {
+	return e.Stream + "\x00" + e.Subject + "\x00" + e.Body
+}
+
+// PromptDiff compares the prompts of two turns. Sections that did not
+// change are left empty; Changed names the ones that did.
+type PromptDiff struct {
+	AgentID       string                          `json:"agent_id"`
+	From          string                          `json:"from"`
+	To            string                          `json:"to"`
+	Changed       []string                        `json:"changed"`
+	Model         *ValueChange                    `json:"model,omitempty"`
+	Tools         *ListChange[string]             `json:"tools,omitempty"`
+	SystemPrompt  []DiffLine                      `json:"system_prompt,omitempty"`
+	Messages      *MessageDiff                    `json:"messages,omitempty"`
+	Input         []DiffLine                      `json:"input,omitempty"`
+	ContextEvents *ListChange[PromptContextEvent] `json:"context_events,omitempty"`
+}
+
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ListChange[T any] struct {
+	Removed []T `json:"removed"`
+	Added   []T `json:"added"`
+}
+
+// MessageDiff compares two conversations. Both share their first Common
+// messages; the rest of each is listed.
+type MessageDiff struct {
+	Common  int             `json:"common"`
+	Removed []PromptMessage `json:"removed"`
+	Added   []PromptMessage `json:"added"`
+}
+
+// DiffLine is one line of a line diff. Op is "=" for an unchanged line,
+// "-" for a line only in the first text and "+" for one only in the
+// second. Long unchanged runs are folded into one "=" line.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// TurnPrompt rebuilds the prompt of the turn run by llmTaskID.
+func (r *Runtime) TurnPrompt(ctx context.Context, agentID, llmTaskID string) (TurnPrompt, error) {
+	if r.Tasks == nil {
+		return TurnPrompt{}, fmt.Errorf("task manager unavailable")
+	}
+	agentID = strings.TrimSpace(agentID)
+	llmTaskID = strings.TrimSpace(llmTaskID)
+	turn, err := r.Tasks.Get(ctx, llmTaskID)
+	if err != nil || turn.Type != "llm" || turn.Owner != agentID {
+		return TurnPrompt{}, ErrTurnNotFound
+	}
+	entries, err := r.readHistoryEntries(ctx, agentID)
+	if err != nil {
+		return TurnPrompt{}, err
+	}
+	start := slices.IndexFunc(entries, func(e AgentHistoryEntry) bool { return e.TaskID == llmTaskID })
+	if start < 0 {
+		return TurnPrompt{}, fmt.Errorf("%w: no history recorded for %s", ErrTurnNotFound, llmTaskID)
+	}
+	out := TurnPrompt{
+		AgentID:       agentID,
+		LLMTaskID:     llmTaskID,
+		Generation:    entries[start].Generation,
+		Model:         strings.TrimSpace(schema.GetMetaString(turn.Metadata, schema.MetaModelOverride)),
+		Tools:         []string{},
+		Messages:      []PromptMessage{},
+		Inputs:        []string{},
+		ContextEvents: []PromptContextEvent{},
+	}
+
+	// Fold the earlier entries of the generation the way the runtime
+	// loaded them for this turn, leaving out turns retracted by then.
+	b := &conversationBuilder{generation: out.Generation, retracted: map[string]bool{}}
+	for _, entry := range entries[:start] {
+		if id := retractedTaskID(entry); id != "" && entry.Generation == out.Generation {
+			b.retracted[id] = true
+		}
+	}
+	for _, entry := range entries[:start] {
+		if entry.Generation != out.Generation {
+			continue
+		}
+		if entry.Type == "tools_config" && len(out.Tools) == 0 {
+			out.Tools = historyTools(entry)
+		}
+		b.apply(entry)
+	}
+	storedPrompt, messages := b.result()
+	out.SystemPrompt = storedPrompt
+	out.Messages = promptMessages(messages)
+
+	for _, entry := range entries[start:] {
+		if entry.TaskID != llmTaskID {
+			continue
+		}
+		switch entry.Type {
+		case "system_prompt":
+			if out.SystemPrompt == "" {
+				out.SystemPrompt = entry.Content
+			}
+		case "tools_config":
+			if len(out.Tools) == 0 {
+				out.Tools = historyTools(entry)
+			}
+		case "llm_input":
+			out.Inputs = append(out.Inputs, entry.Content)
+		case "context_event":
+			out.ContextEvents = append(out.ContextEvents, PromptContextEvent{
+				Stream:   dataString(entry.Data, "stream"),
+				EventID:  dataString(entry.Data, "event_id"),
+				Priority: dataString(entry.Data, "priority"),
+				Subject:  dataString(entry.Data, "subject"),
+				Body:     dataString(entry.Data, "body"),
+			})
+		case "llm_repro":
+			if rp, ok := LLMReproFromEntry(entry); ok && out.Model == "" {
+				out.Model = firstNonEmpty(rp.ModelVersion, rp.Model)
+			}
+		}
+	}
+	return out, nil
+}
+
+// DiffTurnPrompts compares the prompts of two turns of the same agent.
+func (r *Runtime) DiffTurnPrompts(ctx context.Context, agentID, fromTaskID, toTaskID string) (PromptDiff, error) {
+	from, err := r.TurnPrompt(ctx, agentID, fromTaskID)
+	if err != nil {
+		return PromptDiff{}, err
+	}
+	to, err := r.TurnPrompt(ctx, agentID, toTaskID)
+	if err != nil {
+		return PromptDiff{}, err
+	}
+	return DiffPrompts(from, to), nil
+}
+
+// DiffPrompts compares two rebuilt turn prompts.
+func DiffPrompts(from, to TurnPrompt) PromptDiff {
+	out := PromptDiff{AgentID: to.AgentID, From: from.LLMTaskID, To: to.LLMTaskID, Changed: []string{}}
+	if from.Generation != to.Generation {
+		out.Changed = append(out.Changed, "generation")
+	}
+	if from.Model != to.Model {
+		out.Model = &ValueChange{From: from.Model, To: to.Model}
+		out.Changed = append(out.Changed, "model")
+	}
+	if tools := diffSets(from.Tools, to.Tools, func(s string) string { return s }); tools != nil {
+		out.Tools = tools
+		out.Changed = append(out.Changed, "tools")
+	}
+	if from.SystemPrompt != to.SystemPrompt {
+		out.SystemPrompt = diffLines(from.SystemPrompt, to.SystemPrompt)
+		out.Changed = append(out.Changed, "system_prompt")
+	}
+	if !slices.Equal(from.Messages, to.Messages) {
+		common := 0
+		for common < len(from.Messages) && common < len(to.Messages) && from.Messages[common] == to.Messages[common] {
+			common++
+		}
+		out.Messages = &MessageDiff{
+			Common:  common,
+			Removed: append([]PromptMessage{}, from.Messages[common:]...),
+			Added:   append([]PromptMessage{}, to.Messages[common:]...),
+		}
+		out.Changed = append(out.Changed, "messages")
+	}
+	fromInput := strings.Join(from.Inputs, "\n")
+	toInput := strings.Join(to.Inputs, "\n")
+	if fromInput != toInput {
+		out.Input = diffLines(fromInput, toInput)
+		out.Changed = append(out.Changed, "input")
+	}
+	if events := diffSets(from.ContextEvents, to.ContextEvents, PromptContextEvent.key); events != nil {
+		out.ContextEvents = events
+		out.Changed = append(out.Changed, "context_events")
+	}
+	return out
+}
+
+// diffSets returns the items only in a and only in b, compared by key, or
+// nil when both hold the same items.
+func diffSets[T any](a, b []T, key func(T) string) *ListChange[T] {
+	inA := map[string]bool{}
+	for _, item := range a {
+		inA[key(item)] = true
+	}
+	inB := map[string]bool{}
+	for _, item := range b {
+		inB[key(item)] = true
+	}
+	change := &ListChange[T]{Removed: []T{}, Added: []T{}}
+	for _, item := range a {
+		if !inB[key(item)] {
+			change.Removed = append(change.Removed, item)
+		}
+	}
+	for _, item := range b {
+		if !inA[key(item)] {
+			change.Added = append(change.Added, item)
+		}
+	}
+	if len(change.Removed) == 0 && len(change.Added) == 0 {
+		return nil
+	}
+	return change
+}
+
+// diffLines diffs a and b line by line through their longest common
+// subsequence. Runs of more than six unchanged lines keep three lines of
+// context on each side.
+func diffLines(a, b string) []DiffLine {
+	la := strings.Split(a, "\n")
+	lb := strings.Split(b, "\n")
+	if len(la) > maxDiffLines || len(lb) > maxDiffLines {
+		out := make([]DiffLine, 0, len(la)+len(lb))
+		for _, line := range la {
+			out = append(out, DiffLine{Op: "-", Text: line})
+		}
+		for _, line := range lb {
+			out = append(out, DiffLine{Op: "+", Text: line})
+		}
+		return out
+	}
+	// lcs[i][j] is the common subsequence length of la[i:] and lb[j:].
+	lcs := make([][]int, len(la)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lb)+1)
+	}
+	for i := len(la) - 1; i >= 0; i-- {
+		for j := len(lb) - 1; j >= 0; j-- {
+			if la[i] == lb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []DiffLine
+	i, j := 0, 0
+	for i < len(la) || j < len(lb) {
+		switch {
+		case i < len(la) && j < len(lb) && la[i] == lb[j]:
+			ops = append(ops, DiffLine{Op: "=", Text: la[i]})
+			i++
+			j++
+		case i < len(la) && (j == len(lb) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, DiffLine{Op: "-", Text: la[i]})
+			i++
+		default:
+			ops = append(ops, DiffLine{Op: "+", Text: lb[j]})
+			j++
+		}
+	}
+	return foldUnchanged(ops, 3)
+}
+
+func foldUnchanged(ops []DiffLine, context int) []DiffLine {
+	out := make([]DiffLine, 0, len(ops))
+	for start := 0; start < len(ops); {
+		if ops[start].Op != "=" {
+			out = append(out, ops[start])
+			start++
+			continue
+		}
+		end := start
+		for end < len(ops) && ops[end].Op == "=" {
+			end++
+		}
+		keepHead, keepTail := context, context
+		if start == 0 {
+			keepHead = 0
+		}
+		if end == len(ops) {
+			keepTail = 0
+		}
+		if end-start <= keepHead+keepTail+1 {
+			out = append(out, ops[start:end]...)
+		} else {
+			out = append(out, ops[start:start+keepHead]...)
+			out = append(out, DiffLine{Op: "=", Text: fmt.Sprintf("... %d unchanged lines", end-start-keepHead-keepTail)})
+			out = append(out, ops[end-keepTail:end]...)
+		}
+		start = end
+	}
+	return out
+}
+
+func promptMessages(messages []llms.Message) []PromptMessage {
+	out := make([]PromptMessage, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, PromptMessage{Role: msg.Role, Content: textFromContent(msg.Content)})
+	}
+	return out
+}
+
+func historyTools(entry AgentHistoryEntry) []string {
+	if tools, ok := entry.Data["tools"].([]string); ok {
+		return slices.Clone(tools)
+	}
+	raw, _ := entry.Data["tools"].([]any)
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func dataString(data map[string]any, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
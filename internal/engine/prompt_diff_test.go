@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestDiffTurnPromptsShowsWhatChangedBetweenTurns(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+
+	spawnTurn := func() string {
+		t.Helper()
+		turn, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "agent-a", ParentID: "agent-a"})
+		if err != nil {
+			t.Fatalf("spawn turn: %v", err)
+		}
+		return turn.ID
+	}
+	first := spawnTurn()
+	rt.appendHistory(ctx, "agent-a", "tools_config", "system", "exec", first, 1, map[string]any{"tools": []string{"exec"}})
+	rt.appendHistory(ctx, "agent-a", "system_prompt", "system", "You are helpful.\nBe brief.", first, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "status?", first, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "llm_input", "system", "status?", first, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "context_event", "system", "deploy started", first, 1, map[string]any{"stream": "signals", "event_id": "e1", "subject": "deploy started"})
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "Deploying.", first, 1, nil)
+
+	second := spawnTurn()
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "and now?", second, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "llm_input", "system", "and now?", second, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "context_event", "system", "deploy finished", second, 1, map[string]any{"stream": "signals", "event_id": "e2", "subject": "deploy finished"})
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "Done.", second, 1, nil)
+
+	prompt, err := rt.TurnPrompt(ctx, "agent-a", second)
+	if err != nil {
+		t.Fatalf("turn prompt: %v", err)
+	}
+	if prompt.SystemPrompt != "You are helpful.\nBe brief." || !slices.Equal(prompt.Tools, []string{"exec"}) {
+		t.Fatalf("expected the generation's prompt and tools, got %+v", prompt)
+	}
+	if len(prompt.Messages) != 2 || prompt.Messages[0].Content != "status?" || prompt.Messages[1].Content != "Deploying." {
+		t.Fatalf("expected only the earlier turn in the conversation, got %+v", prompt.Messages)
+	}
+
+	diff, err := rt.DiffTurnPrompts(ctx, "agent-a", first, second)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if !slices.Equal(diff.Changed, []string{"messages", "input", "context_events"}) {
+		t.Fatalf("unexpected changed sections %v", diff.Changed)
+	}
+	if diff.Messages.Common != 0 || len(diff.Messages.Added) != 2 || len(diff.Messages.Removed) != 0 {
+		t.Fatalf("unexpected message diff %+v", diff.Messages)
+	}
+	if len(diff.Input) != 2 || diff.Input[0] != (DiffLine{Op: "-", Text: "status?"}) || diff.Input[1] != (DiffLine{Op: "+", Text: "and now?"}) {
+		t.Fatalf("unexpected input diff %+v", diff.Input)
+	}
+	if len(diff.ContextEvents.Removed) != 1 || diff.ContextEvents.Added[0].EventID != "e2" {
+		t.Fatalf("unexpected context event diff %+v", diff.ContextEvents)
+	}
+
+	if _, err := rt.DiffTurnPrompts(ctx, "agent-b", first, second); !errors.Is(err, ErrTurnNotFound) {
+		t.Fatalf("expected another agent's turns to be rejected, got %v", err)
+	}
+}
+
+func TestDiffLinesFoldsUnchangedRuns(t *testing.T) {
+	a := "0\n1\na\nb\nc\nd\ne\nf\ng\nh\ni\nj"
+	b := "0\n1\na\nb\nc\nd\nE\nf\ng\nh\ni\nj"
+	got := diffLines(a, b)
+	want := []DiffLine{
+		{Op: "=", Text: "... 3 unchanged lines"},
+		{Op: "=", Text: "b"}, {Op: "=", Text: "c"}, {Op: "=", Text: "d"},
+		{Op: "-", Text: "e"}, {Op: "+", Text: "E"},
+		{Op: "=", Text: "f"}, {Op: "=", Text: "g"}, {Op: "=", Text: "h"},
+		{Op: "=", Text: "... 2 unchanged lines"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected diff %+v", got)
+	}
+}
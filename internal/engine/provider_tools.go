@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// providerToolEvent is provider-native tool activity (web search, code
+// interpreter, image generation) or a citation, parsed from the raw stream.
+type providerToolEvent struct {
+	Kind       string // "start", "status", "done" or "citation"
+	ToolCallID string
+	ToolName   string
+	Status     string
+	Data       map[string]any
+}
+
+// providerToolDebugger forwards raw stream events to the wrapped debugger and
+// reports provider-native tool activity that the LLM client does not surface
+// as updates.
+type providerToolDebugger struct {
+	llms.Debugger
+	handle func(providerToolEvent)
+}
+
+func (d *providerToolDebugger) RawEvent(data []byte) {
+	if d.Debugger != nil {
+		d.Debugger.RawEvent(data)
+	}
+	if d.handle == nil {
+		return
+	}
+	for _, evt := range parseProviderToolEvents(data) {
+		d.handle(evt)
+	}
+}
+
+// parseProviderToolEvents extracts provider tool events from one OpenAI
+// Responses stream event.
+func parseProviderToolEvents(data []byte) []providerToolEvent {
+	var raw struct {
+		Type       string         `json:"type"`
+		ItemID     string         `json:"item_id"`
+		Item       map[string]any `json:"item"`
+		Annotation map[string]any `json:"annotation"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || raw.Type == "" {
+		return nil
+	}
+	switch raw.Type {
+	case "response.output_item.added", "response.output_item.done":
+		itemType, _ := raw.Item["type"].(string)
+		name, ok := providerToolName(itemType)
+		if !ok {
+			return nil
+		}
+		id, _ := raw.Item["id"].(string)
+		status, _ := raw.Item["status"].(string)
+		if raw.Type == "response.output_item.added" {
+			return []providerToolEvent{{Kind: "start", ToolCallID: id, ToolName: name, Status: "start"}}
+		}
+		if status == "" {
+			status = "completed"
+		}
+		return []providerToolEvent{{Kind: "done", ToolCallID: id, ToolName: name, Status: status, Data: providerToolResult(raw.Item)}}
+	case "response.output_text.annotation.added":
+		if len(raw.Annotation) == 0 {
+			return nil
+		}
+		return []providerToolEvent{{Kind: "citation", Data: raw.Annotation}}
+	}
+	// Intermediate progress, e.g. response.web_search_call.searching.
+	rest, ok := strings.CutPrefix(raw.Type, "response.")
+	if !ok {
+		return nil
+	}
+	itemType, status, ok := strings.Cut(rest, ".")
+	if !ok || strings.Contains(status, "delta") || strings.Contains(status, "partial") {
+		return nil
+	}
+	name, ok := providerToolName(itemType)
+	if !ok {
+		return nil
+	}
+	return []providerToolEvent{{Kind: "status", ToolCallID: raw.ItemID, ToolName: name, Status: status}}
+}
+
+func providerToolName(itemType string) (string, bool) {
+	switch itemType {
+	case "web_search_call", "code_interpreter_call", "image_generation_call", "file_search_call":
+		return strings.TrimSuffix(itemType, "_call"), true
+	}
+	return "", false
+}
+
+// providerToolResult keeps the parts of a finished provider tool item worth
+// recording. Generated image data is dropped; it arrives as an image update.
+func providerToolResult(item map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, key := range []string{"action", "code", "outputs", "queries", "results", "revised_prompt", "container_id"} {
+		if v, ok := item[key]; ok && v != nil {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+func (r *Runtime) handleProviderToolEvent(ctx context.Context, agentID, llmTaskID string, evt providerToolEvent) {
+	if evt.Kind == "citation" {
+		r.recordLLMUpdate(ctx, llmTaskID, "llm_citation", evt.Data)
+		// History payloads are flat, so the annotation's own "type" is renamed.
+		data := map[string]any{}
+		for k, v := range evt.Data {
+			if k == "type" {
+				k = "citation_type"
+			}
+			data[k] = v
+		}
+		title, _ := evt.Data["title"].(string)
+		url, _ := evt.Data["url"].(string)
+		content := strings.TrimSpace(title + " " + url)
+		r.appendHistory(ctx, agentID, "citation", "assistant", content, llmTaskID, 0, data)
+		return
+	}
+	payload := map[string]any{
+		"tool_call_id":  evt.ToolCallID,
+		"tool_name":     evt.ToolName,
+		"status":        evt.Status,
+		"provider_tool": true,
+	}
+	if len(evt.Data) > 0 {
+		payload["result"] = evt.Data
+	}
+	r.recordLLMUpdate(ctx, llmTaskID, "llm_provider_tool", payload)
+	switch evt.Kind {
+	case "start":
+		r.appendToolHistory(ctx, agentID, llmTaskID, "tool_call", evt.ToolCallID, evt.ToolName, "start", "", map[string]any{
+			"provider_tool": true,
+		})
+	case "done":
+		status := "done"
+		if evt.Status == "failed" || evt.Status == "incomplete" {
+			status = "failed"
+		}
+		r.appendToolHistory(ctx, agentID, llmTaskID, "tool_result", evt.ToolCallID, evt.ToolName, status, "", payload)
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// rawEventProvider replays raw OpenAI Responses events through the debugger
+// before streaming a plain text reply.
+type rawEventProvider struct {
+	debugger llms.Debugger
+	events   []string
+}
+
+func (p *rawEventProvider) Company() string              { return "raw" }
+func (p *rawEventProvider) Model() string                { return "raw" }
+func (p *rawEventProvider) SetDebugger(d llms.Debugger)  { p.debugger = d }
+func (p *rawEventProvider) SetHTTPClient(_ *http.Client) {}
+func (p *rawEventProvider) Generate(_ context.Context, _ content.Content, _ []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	for _, evt := range p.events {
+		if p.debugger != nil {
+			p.debugger.RawEvent([]byte(evt))
+		}
+	}
+	return &fakeStream{}
+}
+
+func TestProviderToolEventsRecordedInHistoryAndTaskUpdates(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &rawEventProvider{events: []string{
+		`{"type":"response.output_item.added","item":{"type":"web_search_call","id":"ws_1","status":"in_progress"}}`,
+		`{"type":"response.web_search_call.searching","item_id":"ws_1"}`,
+		`{"type":"response.output_item.done","item":{"type":"web_search_call","id":"ws_1","status":"completed","action":{"type":"search","query":"weather stockholm"}}}`,
+		`{"type":"response.output_text.delta","delta":"ok"}`,
+		`{"type":"response.output_text.annotation.added","annotation":{"type":"url_citation","url":"https://example.com/weather","title":"Weather","start_index":0,"end_index":2}}`,
+	}}
+	client := &ai.Client{LLM: llms.New(provider)}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "agent-a")
+
+	ctx := context.Background()
+	if _, err := rt.RunOnce(ctx, "agent-a", "what's the weather?"); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+
+	summaries, err := bus.List(ctx, "history", eventbus.ListOptions{ScopeType: "task", ScopeID: "agent-a", Limit: 100, Order: "asc"})
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	history, err := bus.Read(ctx, "history", ids, "")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	var sawCall, sawResult, sawCitation bool
+	for _, evt := range history {
+		switch evt.Payload["type"] {
+		case "tool_call":
+			if evt.Payload["tool_name"] == "web_search" && evt.Payload["provider_tool"] == true {
+				sawCall = true
+			}
+		case "tool_result":
+			if evt.Payload["tool_call_id"] == "ws_1" && evt.Payload["tool_status"] == "done" {
+				sawResult = true
+			}
+		case "citation":
+			sawCitation = evt.Payload["url"] == "https://example.com/weather"
+		}
+	}
+	if !sawCall || !sawResult || !sawCitation {
+		t.Fatalf("expected provider tool call, result and citation in history: call=%v result=%v citation=%v", sawCall, sawResult, sawCitation)
+	}
+
+	llmTasks, err := mgr.List(ctx, tasks.ListFilter{Type: "llm", Limit: 10})
+	if err != nil || len(llmTasks) == 0 {
+		t.Fatalf("list llm tasks: %v (%d)", err, len(llmTasks))
+	}
+	updates, err := mgr.ListUpdates(ctx, llmTasks[0].ID, 100)
+	if err != nil {
+		t.Fatalf("list updates: %v", err)
+	}
+	statuses := []string{}
+	citations := 0
+	for _, update := range updates {
+		switch update.Kind {
+		case "llm_provider_tool":
+			status, _ := update.Payload["status"].(string)
+			statuses = append(statuses, status)
+		case "llm_citation":
+			citations++
+		}
+	}
+	if len(statuses) != 3 || statuses[0] != "start" || statuses[1] != "searching" || statuses[2] != "completed" {
+		t.Fatalf("unexpected provider tool statuses: %v", statuses)
+	}
+	if citations != 1 {
+		t.Fatalf("expected one citation update, got %d", citations)
+	}
+}
+
+func TestParseProviderToolEventsIgnoresDeltas(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"response.output_text.delta","delta":"hi"}`,
+		`{"type":"response.code_interpreter_call_code.delta","delta":"print(1)"}`,
+		`{"type":"response.image_generation_call.partial_image","item_id":"ig_1"}`,
+		`{"type":"response.output_item.added","item":{"type":"function_call","id":"fc_1"}}`,
+		`not json`,
+	} {
+		if got := parseProviderToolEvents([]byte(raw)); len(got) != 0 {
+			t.Fatalf("expected no events for %s, got %+v", raw, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// ErrUnknownAgent is returned by ProvisionAgent for an agent that does not
+// exist and that no provisioning rule covers.
+var ErrUnknownAgent = errors.New("unknown agent")
+
+// ApplyAgentConfig sets system prompt, model, provider tools, timezone,
+// metrics snapshot, token budget, daily digest, concurrency and history
+// budget settings on an agent from its payload. Invalid values are skipped;
+// callers that need to reject them validate first.
+func (r *Runtime) ApplyAgentConfig(agentID string, payload map[string]any) {
+	if payload == nil {
+		return
+	}
+	if system, ok := payload["system"].(string); ok && system != "" {
+		r.SetAgentSystem(agentID, system)
+	}
+	if model, ok := payload["model"].(string); ok && model != "" {
+		r.SetAgentModel(agentID, model)
+	}
+	if raw, ok := payload["provider_tools"].([]any); ok {
+		names := make([]string, 0, len(raw))
+		for _, item := range raw {
+			if name, ok := item.(string); ok && strings.TrimSpace(name) != "" {
+				names = append(names, strings.TrimSpace(name))
+			}
+		}
+		r.SetAgentProviderTools(agentID, names)
+	}
+	if tz, ok := payload["timezone"].(string); ok && tz != "" {
+		_ = r.SetAgentTimezone(agentID, tz)
+	}
+	if enabled, ok := payload["metrics_snapshots"].(bool); ok {
+		r.SetAgentMetricsSnapshots(agentID, enabled)
+	}
+	if budget, ok := payload["token_budget"].(float64); ok {
+		r.SetAgentTokenBudget(agentID, int64(budget))
+	}
+	if at, ok := payload["daily_digest"].(string); ok {
+		_ = r.SetAgentDailyDigest(agentID, at)
+	}
+	if n, ok := payload["concurrency"].(float64); ok {
+		_ = r.SetAgentConcurrency(agentID, int(n))
+	}
+	if raw, ok := payload["history_budget"]; ok {
+		if budget, err := ParseHistoryBudget(raw); err == nil {
+			r.SetAgentHistoryBudget(agentID, budget)
+		}
+	}
+}
+
+// ProvisionAgent makes sure the agent agentID exists before a message is
+// delivered to it. An existing agent is left alone. An unknown one is
+// created from the first of ProvisionRules matching its ID, with its
+// config applied and its loop started, and created is true; without a
+// matching rule ErrUnknownAgent is returned.
+func (r *Runtime) ProvisionAgent(ctx context.Context, agentID string) (created bool, err error) {
+	if r.Tasks == nil {
+		return false, fmt.Errorf("task manager unavailable")
+	}
+	if _, err := r.Tasks.Get(ctx, agentID); err == nil {
+		return false, nil
+	}
+	rule, ok := provision.Match(r.ProvisionRules, agentID)
+	if !ok {
+		return false, ErrUnknownAgent
+	}
+	task, err := r.Tasks.Spawn(ctx, tasks.Spec{
+		ID:      agentID,
+		Type:    "agent",
+		Mode:    "async",
+		Payload: rule.PayloadFor(agentID),
+		Metadata: map[string]any{
+			"source":            "auto_provision",
+			"provision_pattern": rule.Pattern,
+		},
+	})
+	if err != nil {
+		// Another message for the same agent may have created it first.
+		if _, getErr := r.Tasks.Get(ctx, agentID); getErr == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("provision %s: %w", agentID, err)
+	}
+	_ = r.Tasks.MarkRunning(ctx, task.ID)
+	r.ApplyAgentConfig(task.ID, task.Payload)
+	r.EnsureAgentLoop(task.ID)
+	return true, nil
+}
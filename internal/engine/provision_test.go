@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/provision"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestProvisionAgentCreatesAgentsMatchingARule(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	rt.ProvisionRules = []provision.Rule{
+		{Pattern: "support-*", Payload: map[string]any{"system": "You help the customer {{agent_id}}.", "model": "support-model"}},
+		{Pattern: "channel-*"},
+	}
+
+	created, err := rt.ProvisionAgent(ctx, "support-acme")
+	if err != nil || !created {
+		t.Fatalf("expected support-acme created, got %v, %v", created, err)
+	}
+	task, err := mgr.Get(ctx, "support-acme")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if task.Type != "agent" || task.Status != tasks.StatusRunning {
+		t.Fatalf("expected a running agent, got %+v", task)
+	}
+	if task.Metadata["source"] != "auto_provision" || task.Metadata["provision_pattern"] != "support-*" {
+		t.Fatalf("unexpected metadata %+v", task.Metadata)
+	}
+	if got := task.Payload["system"]; got != "You help the customer support-acme." {
+		t.Fatalf("unexpected system prompt %q", got)
+	}
+	cfg := rt.ensureTaskConfig("support-acme")
+	cfg.mu.Lock()
+	model := cfg.Model
+	cfg.mu.Unlock()
+	if model != "support-model" {
+		t.Fatalf("expected the rule's model applied, got %q", model)
+	}
+
+	if created, err := rt.ProvisionAgent(ctx, "support-acme"); err != nil || created {
+		t.Fatalf("expected the existing agent left alone, got %v, %v", created, err)
+	}
+	if created, err := rt.ProvisionAgent(ctx, "channel-news"); err != nil || !created {
+		t.Fatalf("expected channel-news created as a bare agent, got %v, %v", created, err)
+	}
+	if _, err := rt.ProvisionAgent(ctx, "sales-acme"); !errors.Is(err, ErrUnknownAgent) {
+		t.Fatalf("expected ErrUnknownAgent, got %v", err)
+	}
+	if _, err := mgr.Get(ctx, "sales-acme"); err == nil {
+		t.Fatalf("expected no agent created without a matching rule")
+	}
+}
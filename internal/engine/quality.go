@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/quality"
+)
+
+// deferringTools hand a turn's answer to a later time.
+var deferringTools = map[string]bool{
+	"schedule_task": true,
+	"set_reminder":  true,
+}
+
+// turnOutcome watches the tools a turn calls to classify how it ended.
+type turnOutcome struct {
+	mu        sync.Mutex
+	askedUser bool
+	deferBy   string
+}
+
+func (o *turnOutcome) observe(toolName string, failed bool) {
+	if failed {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch {
+	case toolName == "ask_user":
+		o.askedUser = true
+	case deferringTools[toolName] && o.deferBy == "":
+		o.deferBy = toolName
+	}
+}
+
+// classifyTurn decides how a turn ended: failed on an error, needed_human
+// when it asked the sender a question, deferred when it scheduled the work,
+// left spawned tasks running or gave no reply, and answered otherwise.
+func (r *Runtime) classifyTurn(ctx context.Context, agentID, llmTaskID, output string, turnErr error, o *turnOutcome) (string, string) {
+	if turnErr != nil {
+		if errors.Is(turnErr, context.Canceled) || errors.Is(turnErr, context.DeadlineExceeded) {
+			return quality.Failed, "interrupted"
+		}
+		return quality.Failed, turnErr.Error()
+	}
+	o.mu.Lock()
+	askedUser, deferBy := o.askedUser, o.deferBy
+	o.mu.Unlock()
+	if askedUser {
+		return quality.NeededHuman, "ask_user"
+	}
+	if deferBy != "" {
+		return quality.Deferred, deferBy
+	}
+	if r.Tasks != nil {
+		if pending, err := r.turnSpawnedTasks(ctx, agentID, llmTaskID); err == nil && len(pending) > 0 {
+			return quality.Deferred, fmt.Sprintf("%d spawned tasks still running", len(pending))
+		}
+	}
+	if strings.TrimSpace(output) == "" {
+		return quality.Deferred, "no reply"
+	}
+	return quality.Answered, ""
+}
+
+// recordTurnOutcome classifies the turn run by llmTaskID, stores the
+// outcome in its llm task's metadata and, with a quality store, keeps it
+// with the turn's message and reply for metrics and export.
+func (r *Runtime) recordTurnOutcome(ctx context.Context, agentID, llmTaskID, model, message, output string, turnErr error, o *turnOutcome) {
+	if llmTaskID == "" {
+		return
+	}
+	outcome, reason := r.classifyTurn(ctx, agentID, llmTaskID, output, turnErr, o)
+	if r.Tasks != nil {
+		_, _ = r.Tasks.MergeMetadata(ctx, llmTaskID, map[string]any{
+			"outcome":        outcome,
+			"outcome_reason": reason,
+		})
+	}
+	if r.Quality == nil {
+		return
+	}
+	_ = r.Quality.RecordOutcome(ctx, quality.Outcome{
+		TaskID:  llmTaskID,
+		AgentID: agentID,
+		Outcome: outcome,
+		Reason:  reason,
+		Model:   model,
+		Input:   message,
+		Output:  output,
+	})
+}
+
+// RecordTurnFeedback attaches a thumbs up or down, with an optional
+// comment, to the turn run by llmTaskID. It is written to the agent's
+// history as a turn_feedback entry and, with a quality store, kept for
+// metrics; an author rating the same turn again replaces their rating.
+func (r *Runtime) RecordTurnFeedback(ctx context.Context, agentID, llmTaskID string, fb quality.Feedback) (quality.Feedback, error) {
+	if r.Tasks == nil {
+		return quality.Feedback{}, fmt.Errorf("task manager unavailable")
+	}
+	agentID = strings.TrimSpace(agentID)
+	llmTaskID = strings.TrimSpace(llmTaskID)
+	turn, err := r.Tasks.Get(ctx, llmTaskID)
+	if err != nil || turn.Type != "llm" || turn.Owner != agentID {
+		return quality.Feedback{}, ErrTurnNotFound
+	}
+	rating, err := quality.ParseRating(fb.Rating)
+	if err != nil {
+		return quality.Feedback{}, err
+	}
+	fb.TaskID = llmTaskID
+	fb.AgentID = agentID
+	fb.Rating = rating
+	fb.Author = strings.TrimSpace(fb.Author)
+	fb.Comment = strings.TrimSpace(fb.Comment)
+	if fb.At.IsZero() {
+		fb.At = r.now()
+	}
+	if r.Quality != nil {
+		if fb, err = r.Quality.RecordFeedback(ctx, fb); err != nil {
+			return quality.Feedback{}, err
+		}
+	}
+	r.appendHistory(ctx, agentID, "turn_feedback", "user", fb.Comment, "", 0, map[string]any{
+		"rated_task_id": llmTaskID,
+		"rating":        fb.Rating,
+		"author":        fb.Author,
+	})
+	return fb, nil
+}
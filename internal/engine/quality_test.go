@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/quality"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestHandleMessageClassifiesTurnsAndRecordsFeedback(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-rated")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(&fakeProvider{})})
+	rt.Quality = quality.NewStore(db)
+
+	if _, err := rt.HandleMessage(ctx, "agent-rated", "user", "hello", nil); err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+	list, err := mgr.List(ctx, tasks.ListFilter{Type: "llm", Limit: 10})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one llm task, got %d, %v", len(list), err)
+	}
+	turn := list[0]
+	if turn.Metadata["outcome"] != quality.Answered {
+		t.Fatalf("expected the turn classified as answered, got %+v", turn.Metadata)
+	}
+
+	fb, err := rt.RecordTurnFeedback(ctx, "agent-rated", turn.ID, quality.Feedback{Rating: "thumbs_down", Comment: " too terse ", Author: "alice"})
+	if err != nil || fb.Rating != quality.Down || fb.Comment != "too terse" {
+		t.Fatalf("unexpected feedback %+v, %v", fb, err)
+	}
+	if _, err := rt.RecordTurnFeedback(ctx, "someone-else", turn.ID, quality.Feedback{Rating: "up"}); !errors.Is(err, ErrTurnNotFound) {
+		t.Fatalf("expected another agent's turn not found, got %v", err)
+	}
+	if _, err := rt.RecordTurnFeedback(ctx, "agent-rated", turn.ID, quality.Feedback{Rating: "meh"}); err == nil {
+		t.Fatalf("expected an unknown rating rejected")
+	}
+	entries, err := rt.readHistoryEntries(ctx, "agent-rated")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	last := entries[len(entries)-1]
+	if last.Type != "turn_feedback" || last.Content != "too terse" || last.Data["rated_task_id"] != turn.ID || last.Data["rating"] != quality.Down {
+		t.Fatalf("expected a turn_feedback history entry, got %+v", last)
+	}
+
+	turns, err := rt.Quality.Turns(ctx, quality.Filter{AgentID: "agent-rated"})
+	if err != nil || len(turns) != 1 {
+		t.Fatalf("expected one stored turn, got %+v, %v", turns, err)
+	}
+	if turns[0].Input != "hello" || turns[0].Output != "ok" || len(turns[0].Feedback) != 1 || turns[0].Feedback[0].Author != "alice" {
+		t.Fatalf("unexpected stored turn %+v", turns[0])
+	}
+
+	for _, tc := range []struct {
+		tool string
+		err  error
+		want string
+	}{
+		{tool: "ask_user", want: quality.NeededHuman},
+		{tool: "schedule_task", want: quality.Deferred},
+		{err: context.Canceled, want: quality.Failed},
+	} {
+		o := &turnOutcome{}
+		o.observe(tc.tool, false)
+		if got, _ := rt.classifyTurn(ctx, "agent-rated", turn.ID, "reply", tc.err, o); got != tc.want {
+			t.Fatalf("expected %s for %q/%v, got %s", tc.want, tc.tool, tc.err, got)
+		}
+	}
+}
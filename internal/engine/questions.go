@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// metaAnsweredQuestion carries the question a message answers from
+// HandleMessage into the turn input.
+const metaAnsweredQuestion = "answered_question"
+
+// turnRoute is where the current turn's message came from, so tools can
+// address the same source mid-turn.
+type turnRoute struct {
+	source      string
+	messageMeta map[string]any
+	routing     turnRouting
+}
+
+type turnRouteKey struct{}
+
+func withTurnRoute(ctx context.Context, route turnRoute) context.Context {
+	return context.WithValue(ctx, turnRouteKey{}, route)
+}
+
+func turnRouteFromContext(ctx context.Context) (turnRoute, bool) {
+	route, ok := ctx.Value(turnRouteKey{}).(turnRoute)
+	return route, ok
+}
+
+// AskUser sends question to the source of the current turn's message and
+// records it as pending. The next message from that source, or any message
+// naming the question's ID, is delivered as its answer.
+func (r *Runtime) AskUser(ctx context.Context, question string, options []string) (questions.Question, error) {
+	if r.Questions == nil {
+		return questions.Question{}, fmt.Errorf("questions unavailable")
+	}
+	agentID := strings.TrimSpace(agentcontext.TaskIDFromContext(ctx))
+	if agentID == "" {
+		return questions.Question{}, fmt.Errorf("calling agent unknown")
+	}
+	route, ok := turnRouteFromContext(ctx)
+	if !ok {
+		return questions.Question{}, fmt.Errorf("ask_user is only available while handling a message")
+	}
+	source := strings.TrimSpace(route.source)
+	if route.routing.HasPrimary && strings.TrimSpace(route.routing.Primary.Source) != "" {
+		source = strings.TrimSpace(route.routing.Primary.Source)
+	}
+	q, err := r.Questions.Ask(ctx, questions.Question{
+		AgentID:   agentID,
+		Question:  question,
+		Options:   options,
+		Source:    source,
+		ServiceID: route.routing.Primary.ServiceID,
+		RequestID: route.routing.Primary.RequestID,
+		Context:   route.routing.Primary.Context,
+	})
+	if err != nil {
+		return questions.Question{}, err
+	}
+
+	text := questionText(q)
+	payload := assistantOutputPayload(text, route.routing)
+	payload["question_id"] = q.ID
+	if len(q.Options) > 0 {
+		payload["options"] = q.Options
+	}
+	opts := assistantOutputUpdateOptions(route.source, route.routing)
+	opts.EventMetadata["question_id"] = q.ID
+	r.recordTaskUpdate(ctx, agentID, "assistant_output", payload, opts)
+	r.appendHistory(ctx, agentID, "assistant_message", "assistant", text, "", 0, map[string]any{
+		"origin":      "ask_user",
+		"question_id": q.ID,
+		"source":      source,
+	})
+	if target := responseRoutingTarget(route.source, agentID); target != "" {
+		meta := withThreadReply(responseRoutingMetadata(route.routing), route.messageMeta)
+		if meta == nil {
+			meta = map[string]any{}
+		}
+		meta["question_id"] = q.ID
+		if _, err := r.SendMessageWithMeta(ctx, target, text, agentID, meta); err != nil {
+			return q, fmt.Errorf("send question: %w", err)
+		}
+	}
+	return q, nil
+}
+
+func questionText(q questions.Question) string {
+	if len(q.Options) == 0 {
+		return q.Question
+	}
+	return q.Question + "\nOptions: " + strings.Join(q.Options, " / ")
+}
+
+// answerQuestion marks the pending question message answers, if any, and
+// returns messageMeta extended with that question for the turn input.
+// Lookup errors leave the message unmatched rather than failing the turn.
+func (r *Runtime) answerQuestion(ctx context.Context, agentID, source, message string, messageMeta map[string]any) map[string]any {
+	if r.Questions == nil || strings.TrimSpace(message) == "" {
+		return messageMeta
+	}
+	q, ok, err := r.Questions.Match(ctx, agentID, source,
+		schema.GetMetaString(messageMeta, "service_id"), schema.GetMetaString(messageMeta, "question_id"))
+	if err != nil || !ok {
+		return messageMeta
+	}
+	answered, err := r.Questions.Answer(ctx, q.ID, message, schema.GetMetaString(messageMeta, "event_id"))
+	if err != nil {
+		return messageMeta
+	}
+	out := make(map[string]any, len(messageMeta)+1)
+	for k, v := range messageMeta {
+		out[k] = v
+	}
+	out[metaAnsweredQuestion] = answered
+	return out
+}
+
+// renderAnsweredQuestionXML shows the model which of its questions a
+// message answers.
+func renderAnsweredQuestionXML(metadata map[string]any) string {
+	q, ok := metadata[metaAnsweredQuestion].(questions.Question)
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("  <in_reply_to question_id=\"")
+	b.WriteString(xmlEscape(q.ID))
+	b.WriteString("\" asked_at=\"")
+	b.WriteString(xmlEscape(q.CreatedAt.Format(time.RFC3339)))
+	b.WriteString("\">")
+	b.WriteString(xmlEscape(questionText(q)))
+	b.WriteString("</in_reply_to>\n")
+	return b.String()
+}
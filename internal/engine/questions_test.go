@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/questions"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestAskUserRoutesQuestionAndCorrelatesReply(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	rt.Questions = questions.NewStore(db)
+	createTestAgent(t, mgr, "agent-a")
+	ctx := agentcontext.WithTaskID(context.Background(), "agent-a")
+
+	if _, err := rt.AskUser(ctx, "Deploy now?", nil); err == nil {
+		t.Fatalf("expected ask_user outside a turn to fail")
+	}
+
+	messageMeta := map[string]any{"service_id": "telegram", "request_id": "req-1", "context": map[string]any{"chat_id": "42"}}
+	routing := buildTurnRouting("telegram", messageMeta, nil)
+	turnCtx := withTurnRoute(ctx, turnRoute{source: "telegram", messageMeta: messageMeta, routing: routing})
+	q, err := rt.AskUser(turnCtx, "Deploy now?", []string{"yes", "no"})
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	if q.Source != "telegram" || q.ServiceID != "telegram" || q.Context["chat_id"] != "42" {
+		t.Fatalf("expected the question to record the turn's route, got %+v", q)
+	}
+	updates, err := mgr.ListUpdatesSince(ctx, "agent-a", "", "assistant_output", 10)
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("expected one assistant output, got %d err=%v", len(updates), err)
+	}
+	if updates[0].Payload["question_id"] != q.ID || updates[0].Payload["text"] != "Deploy now?\nOptions: yes / no" {
+		t.Fatalf("unexpected question output %+v", updates[0].Payload)
+	}
+
+	// A message from another source leaves the question pending.
+	other := rt.answerQuestion(ctx, "agent-a", "web", "hello", map[string]any{})
+	if _, ok := other[metaAnsweredQuestion]; ok {
+		t.Fatalf("expected a message from another source not to answer the question")
+	}
+	replyMeta := rt.answerQuestion(ctx, "agent-a", "telegram", "yes, go", map[string]any{"service_id": "telegram", "event_id": "evt-9"})
+	input := buildInputWithHistory("telegram", "yes, go", replyMeta, TurnContext{Now: time.Now().UTC()}, ContextUpdateFrame{})
+	if !strings.Contains(input, `<in_reply_to question_id="`+q.ID+`"`) || !strings.Contains(input, "Deploy now?") {
+		t.Fatalf("expected the reply to carry its question, got %s", input)
+	}
+	stored, _ := rt.Questions.Get(ctx, q.ID)
+	if stored.Status != questions.StatusAnswered || stored.Answer != "yes, go" || stored.AnswerEventID != "evt-9" {
+		t.Fatalf("unexpected stored question %+v", stored)
+	}
+}
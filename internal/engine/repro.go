@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// LLMRepro is what an llm task needs to be re-run as closely as its provider
+// allows: the requested model, the snapshot that actually served it, sampling
+// parameters and any seed or system fingerprint the provider reports.
+type LLMRepro struct {
+	Model              string   `json:"model,omitempty"`
+	ModelVersion       string   `json:"model_version,omitempty"`
+	Seed               *int64   `json:"seed,omitempty"`
+	Temperature        *float64 `json:"temperature,omitempty"`
+	TopP               *float64 `json:"top_p,omitempty"`
+	SystemFingerprints []string `json:"system_fingerprints,omitempty"`
+	ResponseIDs        []string `json:"response_ids,omitempty"`
+	Requests           int      `json:"requests"`
+}
+
+func (rp LLMRepro) empty() bool {
+	return rp.Requests == 0 && rp.Model == "" && rp.ModelVersion == ""
+}
+
+func (rp LLMRepro) summary() string {
+	parts := []string{}
+	if rp.Model != "" {
+		parts = append(parts, "model "+rp.Model)
+	}
+	if rp.ModelVersion != "" && rp.ModelVersion != rp.Model {
+		parts = append(parts, "snapshot "+rp.ModelVersion)
+	}
+	if rp.Seed != nil {
+		parts = append(parts, fmt.Sprintf("seed %d", *rp.Seed))
+	}
+	if len(rp.SystemFingerprints) > 0 {
+		parts = append(parts, "fingerprint "+strings.Join(rp.SystemFingerprints, ","))
+	}
+	if len(parts) == 0 {
+		return "llm reproducibility metadata"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LLMReproFromEntry decodes an llm_repro history entry.
+func LLMReproFromEntry(entry AgentHistoryEntry) (LLMRepro, bool) {
+	if entry.Type != "llm_repro" || entry.Data == nil {
+		return LLMRepro{}, false
+	}
+	raw, err := json.Marshal(entry.Data)
+	if err != nil {
+		return LLMRepro{}, false
+	}
+	var rp LLMRepro
+	if err := json.Unmarshal(raw, &rp); err != nil {
+		return LLMRepro{}, false
+	}
+	return rp, true
+}
+
+var googleModelPath = regexp.MustCompile(`/models/([^/:?]+)`)
+
+// reproDebugger reads reproducibility metadata out of raw provider requests
+// and stream events, forwarding both to the wrapped debugger.
+type reproDebugger struct {
+	llms.Debugger
+
+	mu    sync.Mutex
+	repro LLMRepro
+}
+
+func (d *reproDebugger) RawRequest(endpoint string, data []byte) {
+	if d.Debugger != nil {
+		d.Debugger.RawRequest(endpoint, data)
+	}
+	var req struct {
+		Model            string   `json:"model"`
+		Seed             *int64   `json:"seed"`
+		Temperature      *float64 `json:"temperature"`
+		TopP             *float64 `json:"top_p"`
+		GenerationConfig struct {
+			Seed        *int64   `json:"seed"`
+			Temperature *float64 `json:"temperature"`
+			TopP        *float64 `json:"topP"`
+		} `json:"generationConfig"`
+	}
+	_ = json.Unmarshal(data, &req)
+	if req.Model == "" {
+		if m := googleModelPath.FindStringSubmatch(endpoint); m != nil {
+			req.Model = m[1]
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.repro.Requests++
+	if req.Model != "" {
+		d.repro.Model = req.Model
+	}
+	d.repro.Seed = firstNonNil(req.Seed, req.GenerationConfig.Seed, d.repro.Seed)
+	d.repro.Temperature = firstNonNil(req.Temperature, req.GenerationConfig.Temperature, d.repro.Temperature)
+	d.repro.TopP = firstNonNil(req.TopP, req.GenerationConfig.TopP, d.repro.TopP)
+}
+
+func (d *reproDebugger) RawEvent(data []byte) {
+	if d.Debugger != nil {
+		d.Debugger.RawEvent(data)
+	}
+	line := strings.TrimSpace(string(data))
+	line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if !strings.HasPrefix(line, "{") {
+		return
+	}
+	// OpenAI chat chunks and Gemini responses carry these at the top level;
+	// Responses API and Anthropic nest them under response and message.
+	type ids struct {
+		ID           string `json:"id"`
+		Model        string `json:"model"`
+		ResponseID   string `json:"responseId"`
+		ModelVersion string `json:"modelVersion"`
+	}
+	var evt struct {
+		ids
+		SystemFingerprint string `json:"system_fingerprint"`
+		Response          *ids   `json:"response"`
+		Message           *ids   `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(line), &evt); err != nil {
+		return
+	}
+	found := []ids{evt.ids}
+	if evt.Response != nil {
+		found = append(found, *evt.Response)
+	}
+	if evt.Message != nil {
+		found = append(found, *evt.Message)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range found {
+		if v := firstNonEmpty(f.ModelVersion, f.Model); v != "" {
+			d.repro.ModelVersion = v
+		}
+		if id := firstNonEmpty(f.ResponseID, f.ID); id != "" && !slices.Contains(d.repro.ResponseIDs, id) {
+			d.repro.ResponseIDs = append(d.repro.ResponseIDs, id)
+		}
+	}
+	if fp := evt.SystemFingerprint; fp != "" && !slices.Contains(d.repro.SystemFingerprints, fp) {
+		d.repro.SystemFingerprints = append(d.repro.SystemFingerprints, fp)
+	}
+}
+
+func (d *reproDebugger) snapshot() LLMRepro {
+	if d == nil {
+		return LLMRepro{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rp := d.repro
+	rp.SystemFingerprints = slices.Clone(rp.SystemFingerprints)
+	rp.ResponseIDs = slices.Clone(rp.ResponseIDs)
+	return rp
+}
+
+// recordRepro stores the llm task's reproducibility metadata as a task update
+// and an llm_repro history entry.
+func (r *Runtime) recordRepro(ctx context.Context, agentID, llmTaskID string, generation int64, d *reproDebugger) {
+	rp := d.snapshot()
+	if rp.empty() {
+		return
+	}
+	raw, err := json.Marshal(rp)
+	if err != nil {
+		return
+	}
+	var data map[string]any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return
+	}
+	r.recordLLMUpdate(ctx, llmTaskID, "llm_repro", data)
+	r.appendHistory(ctx, agentID, "llm_repro", "system", rp.summary(), llmTaskID, generation, data)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonNil[T any](values ...*T) *T {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestReproDebuggerReadsProviderMetadata(t *testing.T) {
+	openai := &reproDebugger{}
+	openai.RawRequest("https://api.openai.com/v1/chat/completions", []byte(`{"model":"gpt-4o","seed":42,"temperature":0.2,"messages":[]}`))
+	openai.RawEvent([]byte(`data: {"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","system_fingerprint":"fp_a"}`))
+	openai.RawEvent([]byte(`data: {"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","system_fingerprint":"fp_a"}`))
+	openai.RawEvent([]byte(`data: [DONE]`))
+	rp := openai.snapshot()
+	if rp.Model != "gpt-4o" || rp.ModelVersion != "gpt-4o-2024-08-06" || rp.Seed == nil || *rp.Seed != 42 {
+		t.Fatalf("unexpected openai repro: %#v", rp)
+	}
+	if rp.Temperature == nil || *rp.Temperature != 0.2 || len(rp.SystemFingerprints) != 1 || len(rp.ResponseIDs) != 1 {
+		t.Fatalf("unexpected openai repro: %#v", rp)
+	}
+
+	anthropic := &reproDebugger{}
+	anthropic.RawRequest("https://api.anthropic.com/v1/messages", []byte(`{"model":"claude-sonnet-4-5"}`))
+	anthropic.RawEvent([]byte(`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5-20250929"}}`))
+	if rp := anthropic.snapshot(); rp.ModelVersion != "claude-sonnet-4-5-20250929" || rp.Seed != nil || rp.ResponseIDs[0] != "msg_1" {
+		t.Fatalf("unexpected anthropic repro: %#v", rp)
+	}
+
+	google := &reproDebugger{}
+	google.RawRequest("https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", []byte(`{"generationConfig":{"seed":9,"topP":0.9}}`))
+	google.RawEvent([]byte(`data: {"modelVersion":"gemini-2.5-pro-002","responseId":"r1"}`))
+	rp = google.snapshot()
+	if rp.Model != "gemini-2.5-pro" || rp.ModelVersion != "gemini-2.5-pro-002" || rp.Seed == nil || *rp.Seed != 9 || rp.TopP == nil {
+		t.Fatalf("unexpected google repro: %#v", rp)
+	}
+
+	entry := AgentHistoryEntry{Type: "llm_repro", Data: map[string]any{"model": "gpt-4o", "seed": float64(42), "requests": float64(1)}}
+	decoded, ok := LLMReproFromEntry(entry)
+	if !ok || decoded.Model != "gpt-4o" || decoded.Seed == nil || *decoded.Seed != 42 {
+		t.Fatalf("unexpected decoded repro: %#v ok=%v", decoded, ok)
+	}
+}
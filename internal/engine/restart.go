@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -13,33 +15,121 @@ type Restarter struct {
 	Listener net.Listener
 	Args     []string
 	Env      []string
+	// ExtraArgs are appended to the successor's arguments.
+	ExtraArgs []string
 }
 
 func (r *Restarter) Restart() error {
+	_, err := r.Start()
+	return err
+}
+
+// Start launches the successor process with the listener on fd 3 and a
+// readiness pipe on fd 4. The returned channel receives nil once the
+// successor calls SignalReady, or an error if it exits or closes the pipe
+// without signalling.
+func (r *Restarter) Start() (<-chan error, error) {
 	if r.Listener == nil {
-		return fmt.Errorf("listener not set")
+		return nil, fmt.Errorf("listener not set")
 	}
 	if len(r.Args) == 0 {
-		return fmt.Errorf("args not set")
+		return nil, fmt.Errorf("args not set")
 	}
 	file, err := listenerFile(r.Listener)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer file.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("ready pipe: %w", err)
 	}
+	defer readyW.Close()
 
 	args := withInheritFDArgs(r.Args[1:], 3)
+	args = withoutFlag(args, readyFDFlag)
+	args = append(args, fmt.Sprintf("%s=%d", readyFDFlag, 4))
+	args = append(args, r.ExtraArgs...)
 	cmd := exec.Command(r.Args[0], args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Env = append([]string{}, r.Env...)
-	cmd.ExtraFiles = []*os.File{file}
+	cmd.ExtraFiles = []*os.File{file, readyW}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start new process: %w", err)
+		_ = readyR.Close()
+		return nil, fmt.Errorf("start new process: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		defer readyR.Close()
+		line, err := bufio.NewReader(readyR).ReadString('\n')
+		if strings.TrimSpace(line) == "ready" {
+			ready <- nil
+			return
+		}
+		if err == nil || err == io.EOF {
+			err = fmt.Errorf("successor exited before signalling ready")
+		}
+		ready <- err
+	}()
+	return ready, nil
+}
+
+const readyFDFlag = "--ready-fd"
+
+// SignalReady tells the parent process, if any, that this process has taken
+// over the listener and is serving.
+func SignalReady(args []string) error {
+	value, ok := flagValue(args, readyFDFlag)
+	if !ok {
+		return nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid ready fd: %w", err)
+	}
+	file := os.NewFile(uintptr(fd), "ready")
+	if file == nil {
+		return fmt.Errorf("failed to open ready fd")
+	}
+	defer file.Close()
+	if _, err := file.WriteString("ready\n"); err != nil {
+		return fmt.Errorf("signal ready: %w", err)
 	}
 	return nil
 }
 
+func flagValue(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"="), true
+		}
+		if arg == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+func withoutFlag(args []string, name string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, name+"=") {
+			continue
+		}
+		if arg == name {
+			i++
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
 func listenerFile(listener net.Listener) (*os.File, error) {
 	switch ln := listener.(type) {
 	case *net.TCPListener:
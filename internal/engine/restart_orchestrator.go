@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	handoffFlag = "--handoff"
+
+	defaultDrainTimeout = 30 * time.Second
+	defaultReadyTimeout = 30 * time.Second
+)
+
+type RestartPhase string
+
+const (
+	RestartIdle      RestartPhase = "idle"
+	RestartDraining  RestartPhase = "draining"
+	RestartHandoff   RestartPhase = "handoff"
+	RestartStarting  RestartPhase = "starting_successor"
+	RestartStopping  RestartPhase = "stopping"
+	RestartCompleted RestartPhase = "completed"
+	RestartFailed    RestartPhase = "failed"
+)
+
+type RestartStep struct {
+	Phase   RestartPhase `json:"phase"`
+	Message string       `json:"message"`
+	At      time.Time    `json:"at"`
+}
+
+type RestartStatus struct {
+	Phase      RestartPhase  `json:"phase"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at,omitempty"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+	Steps      []RestartStep `json:"steps"`
+}
+
+// RestartOrchestrator coordinates a zero-downtime restart: it drains active
+// turns, writes a handoff file, starts the successor on the shared listener,
+// waits for it to signal readiness, then shuts this process down.
+type RestartOrchestrator struct {
+	Runtime     *Runtime
+	Restarter   *Restarter
+	HandoffPath string
+	// Shutdown stops accepting HTTP requests and waits for open ones.
+	Shutdown func(ctx context.Context) error
+	// Exit is called once the successor is serving and this process has
+	// stopped accepting requests.
+	Exit func()
+
+	DrainTimeout time.Duration
+	ReadyTimeout time.Duration
+
+	mu     sync.Mutex
+	status RestartStatus
+}
+
+// Begin starts a restart in the background. It fails if one is already in
+// progress or has completed.
+func (o *RestartOrchestrator) Begin(ctx context.Context) (RestartStatus, error) {
+	if o.Runtime == nil || o.Restarter == nil {
+		return RestartStatus{}, fmt.Errorf("restart is not configured")
+	}
+	o.mu.Lock()
+	switch o.status.Phase {
+	case "", RestartIdle, RestartFailed:
+	default:
+		status := o.snapshotLocked()
+		o.mu.Unlock()
+		return status, fmt.Errorf("restart already %s", status.Phase)
+	}
+	o.status = RestartStatus{Phase: RestartDraining, StartedAt: o.Runtime.now()}
+	o.mu.Unlock()
+
+	go o.run(context.WithoutCancel(ctx))
+	return o.Status(), nil
+}
+
+func (o *RestartOrchestrator) Status() RestartStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.snapshotLocked()
+}
+
+func (o *RestartOrchestrator) snapshotLocked() RestartStatus {
+	out := o.status
+	if out.Phase == "" {
+		out.Phase = RestartIdle
+	}
+	out.Steps = append([]RestartStep(nil), o.status.Steps...)
+	return out
+}
+
+func (o *RestartOrchestrator) run(ctx context.Context) {
+	drainTimeout := o.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	readyTimeout := o.ReadyTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = defaultReadyTimeout
+	}
+
+	o.step(ctx, RestartDraining, fmt.Sprintf("draining %d active turns", o.Runtime.ActiveTurns()))
+	drainCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+	err := o.Runtime.Drain(drainCtx)
+	cancel()
+	if err != nil {
+		o.step(ctx, RestartDraining, err.Error())
+	}
+
+	restarter := *o.Restarter
+	if o.HandoffPath != "" {
+		handoff := o.Runtime.ExportHandoff()
+		if err := WriteHandoff(o.HandoffPath, handoff); err != nil {
+			o.fail(ctx, err)
+			return
+		}
+		restarter.ExtraArgs = append(withoutFlag(restarter.ExtraArgs, handoffFlag), fmt.Sprintf("%s=%s", handoffFlag, o.HandoffPath))
+		o.step(ctx, RestartHandoff, fmt.Sprintf("handing off %d agents", len(handoff.Agents)))
+	}
+
+	o.step(ctx, RestartStarting, "starting successor process")
+	ready, err := restarter.Start()
+	if err != nil {
+		o.fail(ctx, err)
+		return
+	}
+	select {
+	case err := <-ready:
+		if err != nil {
+			o.fail(ctx, err)
+			return
+		}
+	case <-time.After(readyTimeout):
+		o.fail(ctx, fmt.Errorf("successor not ready after %s", readyTimeout))
+		return
+	}
+
+	o.step(ctx, RestartStopping, "successor ready; closing listener")
+	if o.Shutdown != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+		err := o.Shutdown(shutdownCtx)
+		cancel()
+		if err != nil {
+			o.step(ctx, RestartStopping, fmt.Sprintf("shutdown: %v", err))
+		}
+	}
+	o.mu.Lock()
+	o.status.FinishedAt = o.Runtime.now()
+	o.mu.Unlock()
+	o.step(ctx, RestartCompleted, "restart complete")
+	if o.Exit != nil {
+		o.Exit()
+	}
+}
+
+func (o *RestartOrchestrator) fail(ctx context.Context, err error) {
+	o.mu.Lock()
+	o.status.Error = err.Error()
+	o.status.FinishedAt = o.Runtime.now()
+	o.mu.Unlock()
+	// Resume normal operation; the old process keeps serving.
+	o.Runtime.draining.Store(false)
+	o.step(ctx, RestartFailed, err.Error())
+}
+
+func (o *RestartOrchestrator) step(ctx context.Context, phase RestartPhase, message string) {
+	now := o.Runtime.now()
+	o.mu.Lock()
+	o.status.Phase = phase
+	o.status.Steps = append(o.status.Steps, RestartStep{Phase: phase, Message: message, At: now})
+	o.mu.Unlock()
+	if o.Runtime.Bus != nil {
+		_, _ = o.Runtime.Bus.Push(ctx, eventbus.EventInput{
+			Stream:  schema.StreamSignals,
+			Subject: "restart_progress",
+			Body:    message,
+			Metadata: map[string]any{
+				"kind":                     "restart_progress",
+				"phase":                    string(phase),
+				"priority":                 "low",
+				schema.MetaDeliveryExclude: []string{schema.ConsumerAgentContext},
+			},
+		})
+	}
+}
+
+// HandoffFromArgs loads the handoff file named by --handoff, if present.
+func HandoffFromArgs(args []string) (Handoff, error) {
+	path, ok := flagValue(args, handoffFlag)
+	if !ok || path == "" {
+		return Handoff{}, nil
+	}
+	return ReadHandoff(path)
+}
@@ -1,9 +1,16 @@
 package engine
 
 import (
+	"context"
 	"net"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
 )
 
 func TestListenerFromArgs(t *testing.T) {
@@ -32,3 +39,103 @@ func TestListenerFromArgs(t *testing.T) {
 	}
 	_ = got.Close()
 }
+
+func TestWithInheritFDArgsReplacesExistingFlags(t *testing.T) {
+	args := withInheritFDArgs([]string{"--inherit-fd", "7", "--verbose", "--inherit-fd=9"}, 3)
+	args = withoutFlag(args, readyFDFlag)
+	want := []string{"--verbose", "--inherit-fd=3"}
+	if len(args) != len(want) {
+		t.Fatalf("expected %v, got %v", want, args)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, args)
+		}
+	}
+}
+
+func TestRestartOrchestratorHandsOffToSuccessor(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, tasks.NewManager(db, bus), nil)
+	rt.lastContextCursorByTask["agent-1"] = "evt-9"
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	exited := make(chan struct{})
+	handoffPath := filepath.Join(t.TempDir(), "handoff.json")
+	orch := &RestartOrchestrator{
+		Runtime: rt,
+		Restarter: &Restarter{
+			Listener: ln,
+			// The successor only acknowledges readiness on fd 4.
+			Args: []string{"/bin/sh", "-c", "echo ready >&4"},
+		},
+		HandoffPath:  handoffPath,
+		ReadyTimeout: 5 * time.Second,
+		Exit:         func() { close(exited) },
+	}
+	if _, err := orch.Begin(context.Background()); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for restart, status=%#v", orch.Status())
+	}
+
+	status := orch.Status()
+	if status.Phase != RestartCompleted {
+		t.Fatalf("expected completed, got %#v", status)
+	}
+	if !rt.Draining() {
+		t.Fatalf("expected runtime to stay drained after handoff")
+	}
+	handoff, err := HandoffFromArgs([]string{"agentd", "--handoff=" + handoffPath})
+	if err != nil {
+		t.Fatalf("read handoff: %v", err)
+	}
+	if handoff.ContextCursors["agent-1"] != "evt-9" {
+		t.Fatalf("expected cursor in handoff, got %#v", handoff)
+	}
+	if _, err := orch.Begin(context.Background()); err == nil {
+		t.Fatalf("expected second restart to be rejected")
+	}
+}
+
+func TestRestartOrchestratorResumesWhenSuccessorFails(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, tasks.NewManager(db, bus), nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	orch := &RestartOrchestrator{
+		Runtime:   rt,
+		Restarter: &Restarter{Listener: ln, Args: []string{"/bin/sh", "-c", "exit 1"}},
+	}
+	if _, err := orch.Begin(context.Background()); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for orch.Status().Phase != RestartFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for failure, status=%#v", orch.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if rt.Draining() {
+		t.Fatalf("expected runtime to resume after failed restart")
+	}
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// shadowGenerationTimeout bounds a candidate generation; it never holds up
+// the live turn.
+const shadowGenerationTimeout = 10 * time.Minute
+
+// shadowRun is a candidate generation mirroring one live turn.
+type shadowRun struct {
+	store *shadow.Store
+	runID string
+	done  chan struct{}
+}
+
+// recordPrimary stores the live turn's outcome next to the candidate's.
+func (s *shadowRun) recordPrimary(ctx context.Context, output, errText string) {
+	if s == nil {
+		return
+	}
+	_ = s.store.RecordPrimary(ctx, s.runID, output, errText)
+}
+
+// startShadowGeneration mirrors a turn to the agent's active shadow trial, if
+// any. The candidate sees the same conversation and input as the live turn,
+// with the trial's prompt and model and dry-run tools; its output is only
+// recorded. basePrompt is the system prompt without the agent's own addition.
+func (r *Runtime) startShadowGeneration(ctx context.Context, agentID, llmTaskID, basePrompt, livePrompt string, cfg *taskConfig, prior []llms.Message, input string) *shadowRun {
+	if r.Shadow == nil {
+		return nil
+	}
+	trial, ok, err := r.Shadow.Active(ctx, agentID)
+	if err != nil || !ok {
+		return nil
+	}
+	run, err := r.Shadow.StartRun(ctx, trial, llmTaskID, input)
+	if err != nil {
+		return nil
+	}
+	prompt := livePrompt
+	if trial.System != "" {
+		prompt = strings.TrimSpace(basePrompt + "\n\n" + trial.System)
+	}
+	model := trial.Model
+	var providerTools []string
+	if cfg != nil {
+		cfg.mu.Lock()
+		if model == "" {
+			model = cfg.Model
+		}
+		providerTools = cfg.ProviderTools
+		cfg.mu.Unlock()
+	}
+	messages := make([]llms.Message, 0, len(prior)+1)
+	messages = append(messages, prior...)
+	messages = append(messages, llms.Message{Role: "user", Content: content.FromText(input)})
+
+	out := &shadowRun{store: r.Shadow, runID: run.ID, done: make(chan struct{})}
+	go func() {
+		defer close(out.done)
+		shadowCtx, cancel := context.WithTimeout(agentcontext.WithTaskID(context.Background(), agentID), shadowGenerationTimeout)
+		defer cancel()
+		started := time.Now()
+		output, tools, err := r.runShadowGeneration(shadowCtx, model, providerTools, prompt, messages)
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		_ = r.Shadow.RecordShadow(context.Background(), run.ID, output, errText, tools, time.Since(started))
+	}()
+	return out
+}
+
+func (r *Runtime) runShadowGeneration(ctx context.Context, model string, providerTools []string, prompt string, messages []llms.Message) (string, []string, error) {
+	llm, err := r.shadowLLM(model, providerTools)
+	if err != nil {
+		return "", nil, err
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(prompt) }
+	var output string
+	var tools []string
+	for update := range llm.ChatUsingMessages(ctx, messages) {
+		switch u := update.(type) {
+		case llms.TextUpdate:
+			output += u.Text
+		case llms.ToolStartUpdate:
+			tools = append(tools, u.Tool.FuncName())
+		}
+	}
+	return output, tools, llm.Err()
+}
+
+// shadowLLM builds a session for a candidate generation. Tools keep their
+// schemas but only report what they would have run.
+func (r *Runtime) shadowLLM(model string, providerTools []string) (*llms.LLM, error) {
+	if r.ShadowLLMFactory != nil {
+		return r.ShadowLLMFactory(model)
+	}
+	if r.LLM == nil {
+		return nil, fmt.Errorf("LLM not configured")
+	}
+	client, err := r.LLM.WithTools(agenttools.DryRun(r.LLM.Tools()...)...)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewSessionWithOptions(ai.SessionOptions{Model: model, ProviderTools: providerTools})
+}
+
+// PromoteShadowTrial ends agentID's active trial and makes its prompt and
+// model the agent's own.
+func (r *Runtime) PromoteShadowTrial(ctx context.Context, agentID string) (shadow.Trial, error) {
+	if r.Shadow == nil {
+		return shadow.Trial{}, errors.New("shadow trials unavailable")
+	}
+	trial, err := r.Shadow.End(ctx, agentID, shadow.StatusPromoted)
+	if err != nil {
+		return shadow.Trial{}, err
+	}
+	if trial.System != "" {
+		r.SetAgentSystem(agentID, trial.System)
+	}
+	if trial.Model != "" {
+		r.SetAgentModel(agentID, trial.Model)
+	}
+	return trial, nil
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/shadow"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestShadowGenerationRecordsCandidateWithoutDelivering(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	rt.Shadow = shadow.NewStore(db)
+	provider := &historyCapture{}
+	var gotModel string
+	rt.ShadowLLMFactory = func(model string) (*llms.LLM, error) {
+		gotModel = model
+		return llms.New(provider), nil
+	}
+	createTestAgent(t, mgr, "agent-a")
+	rt.SetAgentSystem("agent-a", "Be terse.")
+	ctx := context.Background()
+
+	if run := rt.startShadowGeneration(ctx, "agent-a", "llm-1", "base", "base\n\nBe terse.", rt.ensureTaskConfig("agent-a"), nil, "hello"); run != nil {
+		t.Fatalf("expected no shadow run without a trial")
+	}
+
+	trial, err := rt.Shadow.Start(ctx, shadow.TrialInput{AgentID: "agent-a", System: "Be thorough.", Model: "candidate-model", Duration: time.Hour})
+	if err != nil {
+		t.Fatalf("start trial: %v", err)
+	}
+	prior := []llms.Message{{Role: "user", Content: content.FromText("earlier")}}
+	run := rt.startShadowGeneration(ctx, "agent-a", "llm-2", "base", "base\n\nBe terse.", rt.ensureTaskConfig("agent-a"), prior, "hello")
+	if run == nil {
+		t.Fatalf("expected a shadow run for the active trial")
+	}
+	<-run.done
+	run.recordPrimary(ctx, "live answer", "")
+
+	if gotModel != "candidate-model" {
+		t.Fatalf("expected candidate model, got %q", gotModel)
+	}
+	if system := messageText(llms.Message{Content: provider.SystemPrompt(0)}); !strings.Contains(system, "Be thorough.") || strings.Contains(system, "Be terse.") {
+		t.Fatalf("expected candidate prompt instead of the agent's, got %q", system)
+	}
+	if msgs := provider.Call(0); len(msgs) != 2 || messageText(msgs[1]) != "hello" {
+		t.Fatalf("expected prior conversation plus input, got %+v", msgs)
+	}
+
+	runs, err := rt.Shadow.Runs(ctx, trial.ID, 10)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one recorded run, got %d (%v)", len(runs), err)
+	}
+	if runs[0].ShadowOutput != "ok" || runs[0].PrimaryOutput != "live answer" || runs[0].ShadowDoneAt == nil {
+		t.Fatalf("unexpected run: %+v", runs[0])
+	}
+	promoted, err := rt.PromoteShadowTrial(ctx, "agent-a")
+	if err != nil || promoted.Status != shadow.StatusPromoted {
+		t.Fatalf("promote: %+v %v", promoted, err)
+	}
+	if system, model := rt.AgentConfig("agent-a"); system != "Be thorough." || model != "candidate-model" {
+		t.Fatalf("expected promoted config, got system=%q model=%q", system, model)
+	}
+	if _, ok, _ := rt.Shadow.Active(ctx, "agent-a"); ok {
+		t.Fatalf("expected no active trial after promotion")
+	}
+}
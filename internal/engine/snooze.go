@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// SnoozeMonitor is the monitor that delivers held messages once an agent's
+// snooze ends.
+const SnoozeMonitor = "snooze"
+
+const (
+	snoozeInterval = 30 * time.Second
+	// MaxSnooze is the longest an agent can be snoozed at once.
+	MaxSnooze = 7 * 24 * time.Hour
+	// snoozedOutputKind is the update kind of a message held back while
+	// its agent is snoozed.
+	snoozedOutputKind = "snoozed_output"
+	// snoozeDigestLimit caps how many held messages a digest quotes; older
+	// ones are counted.
+	snoozeDigestLimit = 50
+)
+
+// SnoozeState describes an agent's snooze. Held counts the messages kept
+// back so far.
+type SnoozeState struct {
+	AgentID string     `json:"agent_id"`
+	Snoozed bool       `json:"snoozed"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+	Held    int        `json:"held"`
+}
+
+// snooze is the snooze recorded in an agent task's metadata. baseline is
+// the agent's newest update when the snooze began, so held messages are
+// the snoozed_output updates after it.
+type snooze struct {
+	since, until time.Time
+	baseline     string
+}
+
+func snoozeOf(task tasks.Task) (snooze, bool) {
+	since, err := time.Parse(time.RFC3339Nano, schema.GetMetaString(task.Metadata, "snoozed_since"))
+	if err != nil {
+		return snooze{}, false
+	}
+	until, err := time.Parse(time.RFC3339Nano, schema.GetMetaString(task.Metadata, "snoozed_until"))
+	if err != nil {
+		return snooze{}, false
+	}
+	return snooze{since: since, until: until, baseline: schema.GetMetaString(task.Metadata, "snooze_baseline")}, true
+}
+
+// Snooze holds back the agent's messages to the user for d. The agent keeps
+// running turns and tasks; what it says is delivered as one digest when the
+// snooze ends. Snoozing a snoozed agent moves the end and keeps what was
+// already held.
+func (r *Runtime) Snooze(ctx context.Context, agentID string, d time.Duration) (SnoozeState, error) {
+	if r.Tasks == nil {
+		return SnoozeState{}, fmt.Errorf("task manager unavailable")
+	}
+	if d <= 0 {
+		return SnoozeState{}, fmt.Errorf("snooze duration must be positive")
+	}
+	if d > MaxSnooze {
+		return SnoozeState{}, fmt.Errorf("snooze duration must be at most %s", MaxSnooze)
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return SnoozeState{}, err
+	}
+	now := r.now()
+	current, ok := snoozeOf(task)
+	if !ok {
+		current = snooze{since: now}
+		if latest, found, err := r.Tasks.LatestUpdate(ctx, agentID, ""); err != nil {
+			return SnoozeState{}, err
+		} else if found {
+			current.baseline = latest.ID
+		}
+	}
+	if _, err := r.Tasks.MergeMetadata(ctx, agentID, map[string]any{
+		"snoozed_since":   current.since.Format(time.RFC3339Nano),
+		"snoozed_until":   now.Add(d).Format(time.RFC3339Nano),
+		"snooze_baseline": current.baseline,
+	}); err != nil {
+		return SnoozeState{}, err
+	}
+	return r.SnoozeState(ctx, agentID)
+}
+
+// SnoozeState reports whether agentID is snoozed and how many messages it
+// has held back.
+func (r *Runtime) SnoozeState(ctx context.Context, agentID string) (SnoozeState, error) {
+	if r.Tasks == nil {
+		return SnoozeState{}, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return SnoozeState{}, err
+	}
+	out := SnoozeState{AgentID: agentID}
+	current, ok := snoozeOf(task)
+	if !ok {
+		return out, nil
+	}
+	held, err := r.heldOutputs(ctx, agentID, current.baseline)
+	if err != nil {
+		return SnoozeState{}, err
+	}
+	out.Snoozed = true
+	out.Since = &current.since
+	out.Until = &current.until
+	out.Held = len(held)
+	return out, nil
+}
+
+// EndSnooze ends agentID's snooze now and delivers what it held back. The
+// returned state is the snooze that ended, with Held the number of messages
+// delivered.
+func (r *Runtime) EndSnooze(ctx context.Context, agentID string) (SnoozeState, error) {
+	if r.Tasks == nil {
+		return SnoozeState{}, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return SnoozeState{}, err
+	}
+	out := SnoozeState{AgentID: agentID}
+	current, ok := snoozeOf(task)
+	if !ok {
+		return out, nil
+	}
+	held, err := r.heldOutputs(ctx, agentID, current.baseline)
+	if err != nil {
+		return SnoozeState{}, err
+	}
+	// Clear the snooze first so the digest itself is not held.
+	if _, err := r.Tasks.MergeMetadata(ctx, agentID, map[string]any{
+		"snoozed_since":   nil,
+		"snoozed_until":   nil,
+		"snooze_baseline": nil,
+	}); err != nil {
+		return SnoozeState{}, err
+	}
+	out.Snoozed = true
+	out.Since = &current.since
+	out.Until = &current.until
+	out.Held = len(held)
+	if len(held) > 0 {
+		payload, opts := r.snoozeDigest(agentID, held)
+		r.recordTaskUpdate(ctx, agentID, "assistant_output", payload, opts)
+	}
+	return out, nil
+}
+
+func (r *Runtime) heldOutputs(ctx context.Context, agentID, baseline string) ([]tasks.Update, error) {
+	var out []tasks.Update
+	after := baseline
+	for {
+		page, err := r.Tasks.ListUpdatesSince(ctx, agentID, after, snoozedOutputKind, 200)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page...)
+		if len(page) < 200 {
+			return out, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// holdForSnooze keeps an assistant_output of a snoozed agent back as a
+// snoozed_output update and reports whether it did. Questions are never
+// held, since the agent's work waits on the answer. Once the snooze has
+// run out, the held messages are delivered first.
+func (r *Runtime) holdForSnooze(ctx context.Context, agentID string, payload map[string]any, opts tasks.UpdateOptions) bool {
+	if r.Tasks == nil || payload["question_id"] != nil {
+		return false
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return false
+	}
+	current, ok := snoozeOf(task)
+	if !ok {
+		return false
+	}
+	if !r.now().Before(current.until) {
+		_, _ = r.EndSnooze(ctx, agentID)
+		return false
+	}
+	held := cloneAnyMap(payload)
+	if held == nil {
+		held = map[string]any{}
+	}
+	if len(opts.EventMetadata) > 0 {
+		held["event_metadata"] = cloneAnyMap(opts.EventMetadata)
+	}
+	err = r.Tasks.RecordUpdateWithOptions(ctx, agentID, snoozedOutputKind, held, tasks.UpdateOptions{
+		EventMetadata: map[string]any{
+			schema.MetaDeliveryExclude: []string{schema.ConsumerAgentContext},
+		},
+	})
+	return err == nil
+}
+
+// snoozeDigest builds the assistant_output that delivers held messages. It
+// is routed like the newest of them.
+func (r *Runtime) snoozeDigest(agentID string, held []tasks.Update) (map[string]any, tasks.UpdateOptions) {
+	loc := r.agentLocation(agentID)
+	var b strings.Builder
+	if len(held) == 1 {
+		b.WriteString("While this agent was snoozed, it held back 1 message:")
+	} else {
+		fmt.Fprintf(&b, "While this agent was snoozed, it held back %d messages:", len(held))
+	}
+	quoted := held
+	if len(quoted) > snoozeDigestLimit {
+		fmt.Fprintf(&b, "\n\n(%d earlier messages are not shown.)", len(quoted)-snoozeDigestLimit)
+		quoted = quoted[len(quoted)-snoozeDigestLimit:]
+	}
+	var attachments []schema.Part
+	for _, upd := range quoted {
+		text, _ := upd.Payload["text"].(string)
+		fmt.Fprintf(&b, "\n\n[%s] %s", upd.CreatedAt.In(loc).Format("Jan 2 15:04"), strings.TrimSpace(text))
+		for _, part := range schema.ParseParts(upd.Payload[schema.MetaParts]) {
+			if part.Type != schema.PartText {
+				attachments = append(attachments, part)
+			}
+		}
+	}
+
+	last := held[len(held)-1].Payload
+	payload := withOutputParts(map[string]any{
+		"text":          b.String(),
+		"snooze_digest": len(held),
+	}, b.String(), attachments)
+	if routes, ok := last["routes"]; ok {
+		payload["routes"] = routes
+	}
+	metadata := map[string]any{
+		schema.MetaDeliveryExclude: []string{schema.ConsumerAgentContext},
+	}
+	if raw, ok := last["event_metadata"].(map[string]any); ok {
+		for key, value := range raw {
+			metadata[key] = value
+		}
+	}
+	metadata["snooze_digest"] = true
+	return payload, tasks.UpdateOptions{EventMetadata: metadata}
+}
+
+// deliverEndedSnoozes delivers the held messages of every agent whose
+// snooze has run out.
+func (r *Runtime) deliverEndedSnoozes(ctx context.Context) error {
+	if r.Tasks == nil {
+		return nil
+	}
+	agents, err := r.Tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return err
+	}
+	now := r.now()
+	var errs []error
+	for _, agent := range agents {
+		current, ok := snoozeOf(agent)
+		if !ok || now.Before(current.until) {
+			continue
+		}
+		if _, err := r.EndSnooze(ctx, agent.ID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", agent.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestSnoozeHoldsMessagesAndDeliversADigest(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+	ctx := context.Background()
+	now := time.Now().UTC()
+	rt.nowFn = func() time.Time { return now }
+	outputs := func() []tasks.Update {
+		t.Helper()
+		list, err := mgr.ListUpdatesSince(ctx, "agent-a", "", "assistant_output", 50)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		return list
+	}
+
+	if _, err := rt.Snooze(ctx, "agent-a", 0); err == nil {
+		t.Fatalf("expected a zero duration to be rejected")
+	}
+	state, err := rt.Snooze(ctx, "agent-a", time.Hour)
+	if err != nil || !state.Snoozed || !state.Until.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected snooze %+v err=%v", state, err)
+	}
+	meta := map[string]any{"source": "telegram-bot", "request_id": "req-1", "context": map[string]any{"chat_id": "42"}}
+	for _, text := range []string{"Build 41 passed.", "Build 42 passed."} {
+		if err := rt.EmitAssistantOutput(ctx, "agent-a", text, meta); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+	rt.recordTaskUpdate(ctx, "agent-a", "assistant_output", map[string]any{"text": "Deploy now?", "question_id": "q-1"}, tasks.UpdateOptions{})
+	if got := outputs(); len(got) != 1 || got[0].Payload["question_id"] == nil {
+		t.Fatalf("expected only the question to go out while snoozed, got %+v", got)
+	}
+	if state, _ := rt.SnoozeState(ctx, "agent-a"); state.Held != 2 {
+		t.Fatalf("expected two held messages, got %+v", state)
+	}
+
+	// A longer snooze keeps what was held.
+	if state, _ := rt.Snooze(ctx, "agent-a", 2*time.Hour); state.Held != 2 || !state.Since.Equal(now) {
+		t.Fatalf("expected the snooze extended, got %+v", state)
+	}
+	if err := rt.deliverEndedSnoozes(ctx); err != nil || len(outputs()) != 1 {
+		t.Fatalf("expected nothing delivered before the snooze ends, err=%v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := rt.deliverEndedSnoozes(ctx); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	got := outputs()
+	if len(got) != 2 {
+		t.Fatalf("expected one digest after the question, got %+v", got)
+	}
+	text, _ := got[1].Payload["text"].(string)
+	if !strings.Contains(text, "held back 2 messages") || !strings.Contains(text, "Build 41 passed.") || strings.Index(text, "Build 41") > strings.Index(text, "Build 42") {
+		t.Fatalf("unexpected digest %q", text)
+	}
+	if routes, _ := got[1].Payload["routes"].([]any); len(routes) != 1 {
+		t.Fatalf("expected the digest routed like the held messages, got %+v", got[1].Payload)
+	}
+	if state, _ := rt.SnoozeState(ctx, "agent-a"); state.Snoozed || state.Held != 0 {
+		t.Fatalf("expected the snooze cleared, got %+v", state)
+	}
+	if err := rt.EmitAssistantOutput(ctx, "agent-a", "Build 43 passed.", meta); err != nil || len(outputs()) != 3 {
+		t.Fatalf("expected messages to flow again, err=%v", err)
+	}
+}
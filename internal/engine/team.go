@@ -0,0 +1,484 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/teams"
+)
+
+const (
+	// maxTeamQueue caps the assignments waiting for a free worker.
+	maxTeamQueue = 100
+	// teamOutputKind is the update kind recorded on a supervisor for each
+	// finished assignment.
+	teamOutputKind = "team_output"
+	// teamAssignmentMeta carries an assignment's ID on the message that
+	// hands it to a worker, so the end of the worker's turn finishes it.
+	teamAssignmentMeta = "team_assignment"
+)
+
+// teamState is a team as kept in its supervisor's metadata.
+type teamState struct {
+	id             string
+	maxConcurrency int
+	workers        []string
+	active         map[string]teams.Assignment
+	queue          []teams.Assignment
+	createdAt      time.Time
+}
+
+func teamStateOf(task tasks.Task) (teamState, bool) {
+	var workers []string
+	if !decodeTeamMeta(task.Metadata["team_workers"], &workers) || len(workers) == 0 {
+		return teamState{}, false
+	}
+	st := teamState{id: task.ID, workers: workers, active: map[string]teams.Assignment{}}
+	st.maxConcurrency = int(anyToInt64(task.Metadata["team_max_concurrency"]))
+	decodeTeamMeta(task.Metadata["team_active"], &st.active)
+	decodeTeamMeta(task.Metadata["team_queue"], &st.queue)
+	st.createdAt, _ = time.Parse(time.RFC3339Nano, schema.GetMetaString(task.Metadata, "team_created_at"))
+	return st, true
+}
+
+// decodeTeamMeta converts a metadata value read back from storage into out.
+func decodeTeamMeta(raw, out any) bool {
+	if raw == nil {
+		return false
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, out) == nil
+}
+
+func (st teamState) team() teams.Team {
+	team := teams.Team{
+		ID:             st.id,
+		MaxConcurrency: st.maxConcurrency,
+		Workers:        make([]teams.Worker, 0, len(st.workers)),
+		Queued:         append([]teams.Assignment{}, st.queue...),
+		CreatedAt:      st.createdAt,
+	}
+	for _, id := range st.workers {
+		worker := teams.Worker{ID: id}
+		if a, ok := st.active[id]; ok {
+			worker.Assignment = &a
+		}
+		team.Workers = append(team.Workers, worker)
+	}
+	return team
+}
+
+func (st teamState) hasMember(id string) bool {
+	return id == st.id || slices.Contains(st.workers, id)
+}
+
+// CreateTeam makes supervisorID the supervisor of a new team of workers,
+// of which at most maxConcurrency hold an assignment at once. Zero lets
+// every worker work at once.
+func (r *Runtime) CreateTeam(ctx context.Context, supervisorID string, workers []teams.WorkerSpec, maxConcurrency int) (teams.Team, error) {
+	if r.Tasks == nil {
+		return teams.Team{}, fmt.Errorf("task manager unavailable")
+	}
+	supervisorID = strings.TrimSpace(supervisorID)
+	supervisor, err := r.Tasks.Get(ctx, supervisorID)
+	if err != nil {
+		return teams.Team{}, err
+	}
+	if supervisor.Type != "agent" {
+		return teams.Team{}, fmt.Errorf("supervisor %s is not an agent", supervisorID)
+	}
+	if _, ok := teamStateOf(supervisor); ok {
+		return teams.Team{}, fmt.Errorf("%s already supervises a team", supervisorID)
+	}
+	if team := schema.GetMetaString(supervisor.Metadata, "team_id"); team != "" {
+		return teams.Team{}, fmt.Errorf("%s is a worker in team %s", supervisorID, team)
+	}
+	if len(workers) == 0 || len(workers) > teams.MaxWorkers {
+		return teams.Team{}, fmt.Errorf("a team needs between 1 and %d workers", teams.MaxWorkers)
+	}
+	if maxConcurrency == 0 {
+		maxConcurrency = len(workers)
+	}
+	if maxConcurrency < 1 || maxConcurrency > len(workers) {
+		return teams.Team{}, fmt.Errorf("max_concurrency must be between 1 and the number of workers")
+	}
+
+	// Check every worker before changing anything.
+	adopt := map[string]bool{}
+	seen := map[string]bool{}
+	for i := range workers {
+		id := strings.TrimSpace(workers[i].ID)
+		workers[i].ID = id
+		if id == "" {
+			continue
+		}
+		if err := idgen.ValidateCustomID(id); err != nil {
+			return teams.Team{}, err
+		}
+		if id == supervisorID || seen[id] {
+			return teams.Team{}, fmt.Errorf("worker %s is listed twice or is the supervisor", id)
+		}
+		seen[id] = true
+		existing, err := r.Tasks.Get(ctx, id)
+		if err != nil {
+			continue
+		}
+		if existing.Type != "agent" {
+			return teams.Team{}, fmt.Errorf("worker %s is not an agent", id)
+		}
+		if team := schema.GetMetaString(existing.Metadata, "team_id"); team != "" {
+			return teams.Team{}, fmt.Errorf("worker %s is already in team %s", id, team)
+		}
+		if _, ok := teamStateOf(existing); ok {
+			return teams.Team{}, fmt.Errorf("worker %s supervises a team", id)
+		}
+		adopt[id] = true
+	}
+
+	ids := make([]string, 0, len(workers))
+	for _, spec := range workers {
+		id, err := r.addTeamWorker(ctx, supervisorID, spec, adopt[spec.ID])
+		if err != nil {
+			return teams.Team{}, err
+		}
+		ids = append(ids, id)
+	}
+	now := r.now()
+	updated, err := r.Tasks.MergeMetadata(ctx, supervisorID, map[string]any{
+		"team_workers":         ids,
+		"team_max_concurrency": maxConcurrency,
+		"team_active":          map[string]any{},
+		"team_queue":           []any{},
+		"team_created_at":      now.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return teams.Team{}, err
+	}
+	st, _ := teamStateOf(updated)
+	return st.team(), nil
+}
+
+func (r *Runtime) addTeamWorker(ctx context.Context, supervisorID string, spec teams.WorkerSpec, adopt bool) (string, error) {
+	if adopt {
+		if _, err := r.Tasks.Reparent(ctx, spec.ID, supervisorID, map[string]any{"team_id": supervisorID, "notify_target": supervisorID}); err != nil {
+			return "", fmt.Errorf("adopt worker %s: %w", spec.ID, err)
+		}
+	} else {
+		payload := map[string]any{}
+		if system := strings.TrimSpace(spec.System); system != "" {
+			payload["system"] = system
+		}
+		if model := strings.TrimSpace(spec.Model); model != "" {
+			payload["model"] = model
+		}
+		task, err := r.Tasks.Spawn(ctx, tasks.Spec{
+			ID:       spec.ID,
+			Type:     "agent",
+			Owner:    supervisorID,
+			ParentID: supervisorID,
+			Mode:     "async",
+			Metadata: map[string]any{"source": "team", "team_id": supervisorID, "notify_target": supervisorID},
+			Payload:  payload,
+		})
+		if err != nil {
+			return "", fmt.Errorf("spawn worker: %w", err)
+		}
+		_ = r.Tasks.MarkRunning(ctx, task.ID)
+		spec.ID = task.ID
+	}
+	if system := strings.TrimSpace(spec.System); system != "" {
+		r.SetAgentSystem(spec.ID, system)
+	}
+	if model := strings.TrimSpace(spec.Model); model != "" {
+		r.SetAgentModel(spec.ID, model)
+	}
+	r.EnsureAgentLoop(spec.ID)
+	return spec.ID, nil
+}
+
+// Team returns the team supervised by id, or teams.ErrNoTeam.
+func (r *Runtime) Team(ctx context.Context, id string) (teams.Team, error) {
+	st, err := r.loadTeam(ctx, id)
+	if err != nil {
+		return teams.Team{}, err
+	}
+	return st.team(), nil
+}
+
+// Teams lists every team.
+func (r *Runtime) Teams(ctx context.Context) ([]teams.Team, error) {
+	if r.Tasks == nil {
+		return nil, fmt.Errorf("task manager unavailable")
+	}
+	agents, err := r.Tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return nil, err
+	}
+	out := []teams.Team{}
+	for _, agent := range agents {
+		if st, ok := teamStateOf(agent); ok {
+			out = append(out, st.team())
+		}
+	}
+	return out, nil
+}
+
+// TeamOf returns the team agentID supervises or works in.
+func (r *Runtime) TeamOf(ctx context.Context, agentID string) (string, bool) {
+	if r.Tasks == nil {
+		return "", false
+	}
+	task, err := r.Tasks.Get(ctx, strings.TrimSpace(agentID))
+	if err != nil {
+		return "", false
+	}
+	if _, ok := teamStateOf(task); ok {
+		return task.ID, true
+	}
+	if team := schema.GetMetaString(task.Metadata, "team_id"); team != "" {
+		return team, true
+	}
+	return "", false
+}
+
+// AssignToTeam hands body to worker to of team id, or to the first free
+// worker when to is empty. from, the supervisor when empty, must be on the
+// team; the worker's reply goes to it. The assignment waits in the team's
+// queue while the team is at its concurrency or the worker is busy.
+func (r *Runtime) AssignToTeam(ctx context.Context, id, from, to, body string) (teams.Assignment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return teams.Assignment{}, fmt.Errorf("body is required")
+	}
+	r.teamMu.Lock()
+	defer r.teamMu.Unlock()
+	st, err := r.loadTeam(ctx, id)
+	if err != nil {
+		return teams.Assignment{}, err
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" {
+		from = st.id
+	}
+	if !st.hasMember(from) {
+		return teams.Assignment{}, fmt.Errorf("%s is not on team %s", from, st.id)
+	}
+	if to != "" && !slices.Contains(st.workers, to) {
+		return teams.Assignment{}, fmt.Errorf("%s is not a worker of team %s", to, st.id)
+	}
+	if to != "" && to == from {
+		return teams.Assignment{}, fmt.Errorf("a worker cannot assign work to itself")
+	}
+	if len(st.queue) >= maxTeamQueue {
+		return teams.Assignment{}, teams.ErrQueueFull
+	}
+	assignment := teams.Assignment{
+		ID:        idgen.New(),
+		To:        to,
+		From:      from,
+		Body:      body,
+		CreatedAt: r.now(),
+	}
+	st.queue = append(st.queue, assignment)
+	r.dispatchTeam(ctx, &st)
+	if err := r.saveTeam(ctx, st); err != nil {
+		return teams.Assignment{}, err
+	}
+	for _, a := range st.active {
+		if a.ID == assignment.ID {
+			return a, nil
+		}
+	}
+	return assignment, nil
+}
+
+// TeamOutputs returns the last limit outputs of team id's assignments,
+// oldest first.
+func (r *Runtime) TeamOutputs(ctx context.Context, id string, limit int) ([]teams.Output, error) {
+	if _, err := r.loadTeam(ctx, id); err != nil {
+		return nil, err
+	}
+	updates, err := r.Tasks.ListUpdatesSince(ctx, id, "", teamOutputKind, 10000)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(updates) > limit {
+		updates = updates[len(updates)-limit:]
+	}
+	out := make([]teams.Output, 0, len(updates))
+	for _, upd := range updates {
+		var output teams.Output
+		decodeTeamMeta(upd.Payload, &output)
+		output.At = upd.CreatedAt
+		out = append(out, output)
+	}
+	return out, nil
+}
+
+// DisbandTeam dissolves team id and drops its queued assignments. The
+// workers keep running as ordinary children of the supervisor.
+func (r *Runtime) DisbandTeam(ctx context.Context, id string) error {
+	r.teamMu.Lock()
+	defer r.teamMu.Unlock()
+	st, err := r.loadTeam(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, worker := range st.workers {
+		if _, err := r.Tasks.MergeMetadata(ctx, worker, map[string]any{"team_id": nil}); err != nil {
+			return err
+		}
+	}
+	_, err = r.Tasks.MergeMetadata(ctx, st.id, map[string]any{
+		"team_workers":         nil,
+		"team_max_concurrency": nil,
+		"team_active":          nil,
+		"team_queue":           nil,
+		"team_created_at":      nil,
+	})
+	return err
+}
+
+// finishTeamAssignment ends workerID's assignment once a turn that
+// handled it is over: one it started, or one that read its message as
+// context. It records the turn's output or error on the supervisor and
+// starts the next queued assignments.
+func (r *Runtime) finishTeamAssignment(ctx context.Context, workerID string, messageMeta map[string]any, session Session, turnErr error) {
+	if r.Tasks == nil {
+		return
+	}
+	worker, err := r.Tasks.Get(ctx, workerID)
+	if err != nil {
+		return
+	}
+	teamID := schema.GetMetaString(worker.Metadata, "team_id")
+	if teamID == "" {
+		return
+	}
+	r.teamMu.Lock()
+	defer r.teamMu.Unlock()
+	st, err := r.loadTeam(ctx, teamID)
+	if err != nil {
+		return
+	}
+	assignment, ok := st.active[workerID]
+	if !ok {
+		return
+	}
+	if schema.GetMetaString(messageMeta, teamAssignmentMeta) != assignment.ID && !r.readBy(ctx, assignment.EventID, workerID) {
+		return
+	}
+	delete(st.active, workerID)
+	output := teams.Output{AssignmentID: assignment.ID, Worker: workerID, From: assignment.From}
+	output.Text, output.Error = strings.TrimSpace(session.LastOutput), session.LastError
+	if turnErr != nil {
+		output.Error = turnErr.Error()
+	}
+	r.dispatchTeam(ctx, &st)
+	_ = r.saveTeam(ctx, st)
+	r.recordTeamOutput(ctx, st.id, output)
+}
+
+// readBy reports whether agentID has read the task_input event eventID.
+func (r *Runtime) readBy(ctx context.Context, eventID, agentID string) bool {
+	if r.Bus == nil || eventID == "" {
+		return false
+	}
+	events, err := r.Bus.Read(ctx, schema.StreamTaskInput, []string{eventID}, agentID)
+	return err == nil && len(events) == 1 && events[0].Read
+}
+
+// dispatchTeam starts queued assignments in order while the team is under
+// its concurrency and their workers are free.
+func (r *Runtime) dispatchTeam(ctx context.Context, st *teamState) {
+	remaining := st.queue[:0:0]
+	for _, a := range st.queue {
+		if len(st.active) >= max(st.maxConcurrency, 1) {
+			remaining = append(remaining, a)
+			continue
+		}
+		worker := a.To
+		if worker == "" {
+			for _, id := range st.workers {
+				if _, busy := st.active[id]; !busy && id != a.From {
+					worker = id
+					break
+				}
+			}
+		}
+		if _, busy := st.active[worker]; worker == "" || busy {
+			remaining = append(remaining, a)
+			continue
+		}
+		a.To = worker
+		a.StartedAt = r.now()
+		r.EnsureAgentLoop(worker)
+		evt, err := r.SendMessageWithMeta(ctx, worker, a.Body, a.From, map[string]any{
+			"team_id":          st.id,
+			teamAssignmentMeta: a.ID,
+		})
+		if err != nil {
+			r.recordTeamOutput(ctx, st.id, teams.Output{AssignmentID: a.ID, Worker: worker, From: a.From, Error: err.Error()})
+			continue
+		}
+		a.EventID = evt.ID
+		st.active[worker] = a
+	}
+	st.queue = remaining
+}
+
+func (r *Runtime) recordTeamOutput(ctx context.Context, teamID string, output teams.Output) {
+	payload := map[string]any{
+		"assignment_id": output.AssignmentID,
+		"worker":        output.Worker,
+		"from":          output.From,
+	}
+	if output.Text != "" {
+		payload["text"] = output.Text
+	}
+	if output.Error != "" {
+		payload["error"] = output.Error
+	}
+	// Workers' replies already reach whoever assigned the work, so the
+	// aggregate is kept out of agents' context.
+	r.recordTaskUpdate(ctx, teamID, teamOutputKind, payload, tasks.UpdateOptions{EventMetadata: map[string]any{
+		schema.MetaDeliveryExclude: []string{schema.ConsumerAgentContext},
+	}})
+}
+
+func (r *Runtime) loadTeam(ctx context.Context, id string) (teamState, error) {
+	if r.Tasks == nil {
+		return teamState{}, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return teamState{}, teams.ErrNoTeam
+	}
+	st, ok := teamStateOf(task)
+	if !ok {
+		return teamState{}, teams.ErrNoTeam
+	}
+	return st, nil
+}
+
+func (r *Runtime) saveTeam(ctx context.Context, st teamState) error {
+	queue := st.queue
+	if queue == nil {
+		queue = []teams.Assignment{}
+	}
+	_, err := r.Tasks.MergeMetadata(ctx, st.id, map[string]any{
+		"team_active": st.active,
+		"team_queue":  queue,
+	})
+	return err
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/teams"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestTeamQueuesAssignmentsAtMaxConcurrency(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &gatedProvider{release: make(chan struct{})}
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider)})
+	rt.baseCtx = ctx
+	rt.LLMFactory = func() (*llms.LLM, error) { return llms.New(provider), nil }
+	createTestAgent(t, mgr, "lead")
+
+	if _, err := rt.CreateTeam(ctx, "lead", []teams.WorkerSpec{{ID: "lead"}}, 1); err == nil {
+		t.Fatalf("expected the supervisor to be refused as its own worker")
+	}
+	team, err := rt.CreateTeam(ctx, "lead", []teams.WorkerSpec{{ID: "researcher", System: "You research."}, {ID: "writer"}}, 1)
+	if err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	if len(team.Workers) != 2 || team.MaxConcurrency != 1 {
+		t.Fatalf("unexpected team %+v", team)
+	}
+	if worker, err := mgr.Get(ctx, "writer"); err != nil || worker.ParentID != "lead" {
+		t.Fatalf("expected the worker to be a child of the supervisor, got %+v %v", worker, err)
+	}
+	if id, ok := rt.TeamOf(ctx, "writer"); !ok || id != "lead" {
+		t.Fatalf("expected writer on team lead, got %q", id)
+	}
+
+	first, err := rt.AssignToTeam(ctx, "lead", "", "", "Find the llama census.")
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if first.To != "researcher" || first.StartedAt.IsZero() {
+		t.Fatalf("expected the first assignment to start on the first free worker, got %+v", first)
+	}
+	second, err := rt.AssignToTeam(ctx, "lead", "researcher", "writer", "Write it up.")
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if !second.StartedAt.IsZero() {
+		t.Fatalf("expected the second assignment to queue at max concurrency, got %+v", second)
+	}
+	if _, err := rt.AssignToTeam(ctx, "lead", "outsider", "", "Hi."); err == nil {
+		t.Fatalf("expected assignments from outside the team to be refused")
+	}
+	waitFor(t, "the first assignment to run", func() bool {
+		running, _ := provider.counts()
+		return running == 1
+	})
+
+	close(provider.release)
+	var outputs []teams.Output
+	waitFor(t, "both outputs", func() bool {
+		outputs, err = rt.TeamOutputs(ctx, "lead", 10)
+		return err == nil && len(outputs) == 2
+	})
+	if _, peak := provider.counts(); peak != 1 {
+		t.Fatalf("expected one worker at a time, peak was %d", peak)
+	}
+	if outputs[0].Worker != "researcher" || outputs[1].Worker != "writer" || outputs[1].From != "researcher" || outputs[1].Text != "ok" {
+		t.Fatalf("unexpected outputs %+v", outputs)
+	}
+	team, err = rt.Team(ctx, "lead")
+	if err != nil || len(team.Queued) != 0 || team.Workers[0].Assignment != nil || team.Workers[1].Assignment != nil {
+		t.Fatalf("expected an idle team, got %+v %v", team, err)
+	}
+
+	if err := rt.DisbandTeam(ctx, "lead"); err != nil {
+		t.Fatalf("disband: %v", err)
+	}
+	if _, err := rt.Team(ctx, "lead"); !errors.Is(err, teams.ErrNoTeam) {
+		t.Fatalf("expected ErrNoTeam after disbanding, got %v", err)
+	}
+	if _, ok := rt.TeamOf(ctx, "writer"); ok {
+		t.Fatalf("expected writer to leave the team")
+	}
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// TerminatedTask is an open task that terminating an agent kills.
+type TerminatedTask struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Status   tasks.Status `json:"status"`
+	ParentID string       `json:"parent_id,omitempty"`
+}
+
+// TerminateReport describes what terminating an agent stopped, or would
+// stop on a dry run.
+type TerminateReport struct {
+	AgentID string `json:"agent_id"`
+	DryRun  bool   `json:"dry_run"`
+	Reason  string `json:"reason"`
+	// LoopRunning reports whether the agent's loop was running.
+	LoopRunning bool `json:"loop_running"`
+	// Turns are the LLM tasks of the agent's in-flight turns.
+	Turns []string `json:"turns"`
+	// Tasks are the agent's task and its open descendants, parents first.
+	Tasks []TerminatedTask `json:"tasks"`
+	// Killed are the IDs of the tasks that were killed.
+	Killed []string `json:"killed,omitempty"`
+}
+
+// TerminateAgent stops an agent in one step: it stops its loop so nothing
+// restarts it, interrupts its in-flight turns and kills its task and every
+// open descendant task. The loop stays stopped until ResetAgentLoop. A dry
+// run only reports what would be stopped.
+func (r *Runtime) TerminateAgent(ctx context.Context, agentID, reason string, dryRun bool) (TerminateReport, error) {
+	if r.Tasks == nil {
+		return TerminateReport{}, fmt.Errorf("task manager unavailable")
+	}
+	if strings.TrimSpace(reason) == "" {
+		reason = "terminated"
+	}
+	agent, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return TerminateReport{}, err
+	}
+	descendants, err := r.Tasks.Descendants(ctx, agentID)
+	if err != nil {
+		return TerminateReport{}, err
+	}
+	report := TerminateReport{AgentID: agentID, DryRun: dryRun, Reason: reason}
+	var turnCancels []context.CancelFunc
+	r.inflightMu.Lock()
+	for _, task := range descendants {
+		if cancel, ok := r.inflight[task.ID]; ok {
+			report.Turns = append(report.Turns, task.ID)
+			turnCancels = append(turnCancels, cancel)
+		}
+	}
+	r.inflightMu.Unlock()
+	for _, task := range append([]tasks.Task{agent}, descendants...) {
+		if tasks.IsTerminalStatus(task.Status) {
+			continue
+		}
+		report.Tasks = append(report.Tasks, TerminatedTask{ID: task.ID, Type: task.Type, Status: task.Status, ParentID: task.ParentID})
+	}
+
+	r.loopMu.Lock()
+	cancelLoop, running := r.loops[agentID]
+	report.LoopRunning = running
+	if dryRun {
+		r.loopMu.Unlock()
+		return report, nil
+	}
+	// Mark the loop stopped before cancelling it so that no message that
+	// arrives meanwhile starts it again.
+	r.setLoopState(agentID, LoopStopped)
+	if running {
+		cancelLoop()
+	}
+	r.loopMu.Unlock()
+
+	for _, cancel := range turnCancels {
+		cancel()
+	}
+	// Finish the kill even if the caller goes away part way.
+	report.Killed, err = r.Tasks.KillTree(context.WithoutCancel(ctx), agentID, reason)
+	return report, err
+}
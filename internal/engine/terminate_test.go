@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestTerminateAgentStopsLoopTurnsAndDescendants(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	loopRunning := func() bool {
+		rt.loopMu.Lock()
+		defer rt.loopMu.Unlock()
+		_, ok := rt.loops["operator"]
+		return ok
+	}
+
+	spawn := func(spec tasks.Spec) tasks.Task {
+		t.Helper()
+		task, err := mgr.Spawn(ctx, spec)
+		if err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		_ = mgr.MarkRunning(ctx, task.ID)
+		return task
+	}
+	spawn(tasks.Spec{ID: "operator", Type: "agent"})
+	llm := spawn(tasks.Spec{Type: "llm", ParentID: "operator"})
+	exec := spawn(tasks.Spec{Type: "exec", ParentID: llm.ID})
+	done := spawn(tasks.Spec{Type: "exec", ParentID: "operator"})
+	if err := mgr.Complete(ctx, done.ID, nil); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	// An open task under a finished one is still reached.
+	orphan := spawn(tasks.Spec{Type: "exec", ParentID: done.ID})
+
+	rt.EnsureAgentLoop("operator")
+	turnCtx, cancelTurn := context.WithCancel(ctx)
+	rt.registerInflight(llm.ID, cancelTurn)
+
+	report, err := rt.TerminateAgent(ctx, "operator", "", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	var ids []string
+	for _, task := range report.Tasks {
+		ids = append(ids, task.ID)
+	}
+	if !report.LoopRunning || !slices.Equal(report.Turns, []string{llm.ID}) || !slices.Equal(ids, []string{"operator", llm.ID, exec.ID, orphan.ID}) {
+		t.Fatalf("unexpected dry run report %+v", report)
+	}
+	if turnCtx.Err() != nil || !loopRunning() {
+		t.Fatalf("expected a dry run to leave the agent running")
+	}
+	if task, _ := mgr.Get(ctx, exec.ID); task.Status != tasks.StatusRunning {
+		t.Fatalf("expected a dry run to leave tasks open, got %s", task.Status)
+	}
+
+	report, err = rt.TerminateAgent(ctx, "operator", "runaway", false)
+	if err != nil {
+		t.Fatalf("terminate: %v", err)
+	}
+	if !slices.Equal(report.Killed, ids) {
+		t.Fatalf("expected %v killed, got %v", ids, report.Killed)
+	}
+	if turnCtx.Err() == nil {
+		t.Fatalf("expected the in-flight turn to be cancelled")
+	}
+	for _, id := range ids {
+		if task, _ := mgr.Get(ctx, id); task.Status != tasks.StatusCancelled {
+			t.Fatalf("expected %s cancelled, got %s", id, task.Status)
+		}
+	}
+	if task, _ := mgr.Get(ctx, done.ID); task.Status != tasks.StatusCompleted {
+		t.Fatalf("expected the finished task to keep its status, got %s", task.Status)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for loopRunning() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the loop to stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if health, ok := rt.LoopHealth("operator"); !ok || health.State != LoopStopped {
+		t.Fatalf("expected a stopped loop, got %+v", health)
+	}
+	rt.EnsureAgentLoop("operator")
+	if loopRunning() {
+		t.Fatalf("expected a terminated agent's loop to stay stopped")
+	}
+	rt.ResetAgentLoop("operator")
+	if !loopRunning() {
+		t.Fatalf("expected reset to start the loop again")
+	}
+	rt.loopMu.Lock()
+	rt.loops["operator"]()
+	rt.loopMu.Unlock()
+}
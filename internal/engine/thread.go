@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/archive"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const threadListLimit = 1000
+
+// ThreadMessage is one message exchanged between agents. ThreadID is the ID
+// of the message that started the exchange.
+type ThreadMessage struct {
+	ID        string    `json:"id"`
+	ThreadID  string    `json:"thread_id"`
+	ReplyTo   string    `json:"reply_to,omitempty"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Thread struct {
+	Agents   []string        `json:"agents"`
+	ThreadID string          `json:"thread_id,omitempty"`
+	Messages []ThreadMessage `json:"messages"`
+}
+
+// withThreadReply marks a reply to the message described by messageMeta so
+// the exchange can be stitched back together later. A message sent by Ask
+// passes its correlation ID on to the reply.
+func withThreadReply(meta map[string]any, messageMeta map[string]any) map[string]any {
+	replyTo := schema.GetMetaString(messageMeta, "event_id")
+	if replyTo == "" {
+		return meta
+	}
+	threadID := schema.GetMetaString(messageMeta, "thread_id")
+	if threadID == "" {
+		threadID = replyTo
+	}
+	out := map[string]any{}
+	for k, v := range meta {
+		out[k] = v
+	}
+	out["reply_to"] = replyTo
+	out["thread_id"] = threadID
+	if id := schema.GetMetaString(messageMeta, askCorrelationMeta); id != "" {
+		out[askCorrelationMeta] = id
+	}
+	return out
+}
+
+// Thread collects the messages exchanged among agents, oldest first. Only
+// messages whose sender and recipient are both in agents are included. When
+// threadID is set, the result is narrowed to that thread. Archived messages
+// are included when the runtime has an archive.
+func (r *Runtime) Thread(ctx context.Context, agents []string, threadID string) (Thread, error) {
+	members := map[string]struct{}{}
+	var names []string
+	for _, agent := range agents {
+		agent = strings.TrimSpace(agent)
+		if agent == "" {
+			continue
+		}
+		if _, ok := members[agent]; ok {
+			continue
+		}
+		members[agent] = struct{}{}
+		names = append(names, agent)
+	}
+	if len(names) < 2 {
+		return Thread{}, fmt.Errorf("at least two agents are required")
+	}
+	thread := Thread{Agents: names, ThreadID: strings.TrimSpace(threadID), Messages: []ThreadMessage{}}
+	if r.Bus == nil {
+		return thread, nil
+	}
+	for _, target := range names {
+		summaries, err := r.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+			ScopeType: "task",
+			ScopeID:   target,
+			Limit:     threadListLimit,
+			Order:     "fifo",
+			Fields: []eventbus.FieldFilter{
+				{Column: "metadata", Path: []string{"kind"}, Values: []string{"message"}},
+				{Column: "metadata", Path: []string{"source"}, Values: names},
+			},
+		})
+		if err != nil {
+			return Thread{}, err
+		}
+		var events []eventbus.Event
+		if len(summaries) > 0 {
+			ids := make([]string, len(summaries))
+			for i, s := range summaries {
+				ids[i] = s.ID
+			}
+			if events, err = r.Bus.Read(ctx, schema.StreamTaskInput, ids, ""); err != nil {
+				return Thread{}, err
+			}
+		}
+		if r.Archive != nil {
+			archived, err := r.Archive.Events(ctx, archive.Query{Stream: schema.StreamTaskInput, ScopeType: "task", ScopeID: target})
+			if err != nil {
+				return Thread{}, err
+			}
+			for _, evt := range archived {
+				if schema.GetMetaString(evt.Metadata, "kind") == "message" && slices.Contains(names, schema.GetMetaString(evt.Metadata, "source")) {
+					events = append(events, evt)
+				}
+			}
+			events = archive.Merge(events)
+		}
+		for _, evt := range events {
+			msg := threadMessageFromEvent(evt, target)
+			if thread.ThreadID != "" && msg.ThreadID != thread.ThreadID {
+				continue
+			}
+			thread.Messages = append(thread.Messages, msg)
+		}
+	}
+	sort.SliceStable(thread.Messages, func(i, j int) bool {
+		a, b := thread.Messages[i], thread.Messages[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	return thread, nil
+}
+
+func threadMessageFromEvent(evt eventbus.Event, target string) ThreadMessage {
+	msg := ThreadMessage{
+		ID:        evt.ID,
+		ThreadID:  schema.GetMetaString(evt.Metadata, "thread_id"),
+		ReplyTo:   schema.GetMetaString(evt.Metadata, "reply_to"),
+		From:      schema.GetMetaString(evt.Metadata, "source"),
+		To:        target,
+		Body:      evt.Body,
+		CreatedAt: evt.CreatedAt,
+	}
+	if msg.ThreadID == "" {
+		msg.ThreadID = evt.ID
+	}
+	return msg
+}
+
+// RenderThreadMarkdown formats a thread as a Markdown transcript.
+func RenderThreadMarkdown(thread Thread) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation between %s\n", strings.Join(thread.Agents, ", "))
+	if thread.ThreadID != "" {
+		fmt.Fprintf(&b, "\nThread: `%s`\n", thread.ThreadID)
+	}
+	if len(thread.Messages) == 0 {
+		b.WriteString("\n_No messages._\n")
+		return b.String()
+	}
+	for _, msg := range thread.Messages {
+		fmt.Fprintf(&b, "\n## %s → %s\n\n", msg.From, msg.To)
+		fmt.Fprintf(&b, "_%s_", msg.CreatedAt.UTC().Format(time.RFC3339))
+		if msg.ReplyTo != "" {
+			fmt.Fprintf(&b, " · in reply to `%s`", msg.ReplyTo)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(strings.TrimSpace(msg.Body))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// promptTimeLayout renders times for the model in the agent's timezone with
+// the weekday and an explicit offset, so relative scheduling ("tomorrow at
+// 9") does not require converting from UTC.
+const (
+	promptTimeLayout = "Monday 2006-01-02T15:04:05Z07:00"
+	promptDateLayout = "Monday, 2006-01-02"
+)
+
+// SetAgentTimezone sets the IANA timezone (e.g. "Europe/Stockholm") used to
+// render times in an agent's context updates. An empty name resets to UTC.
+func (r *Runtime) SetAgentTimezone(taskID, name string) error {
+	if taskID == "" {
+		return nil
+	}
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		return err
+	}
+	cfg := r.ensureTaskConfig(taskID)
+	cfg.mu.Lock()
+	cfg.Location = loc
+	cfg.mu.Unlock()
+	return nil
+}
+
+// LoadTimezone resolves an IANA timezone name. Empty means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+func (r *Runtime) agentLocation(agentID string) *time.Location {
+	r.configMu.RLock()
+	cfg, ok := r.taskConfigs[agentID]
+	r.configMu.RUnlock()
+	if !ok || cfg == nil {
+		return time.UTC
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.Location == nil {
+		return time.UTC
+	}
+	return cfg.Location
+}
+
+func (tc TurnContext) location() *time.Location {
+	if tc.Location == nil {
+		return time.UTC
+	}
+	return tc.Location
+}
+
+// dateChanged reports whether previous and now fall on different calendar
+// days in loc.
+func dateChanged(previous, now time.Time, loc *time.Location) bool {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return previous.In(loc).Format(time.DateOnly) != now.In(loc).Format(time.DateOnly)
+}
+
+func formatPromptTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(promptTimeLayout)
+}
+
+func formatPromptDate(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(promptDateLayout)
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// defaultToolLoopLimit is how many identical tool calls a turn may make
+// before the watchdog steps in.
+const defaultToolLoopLimit = 3
+
+// toolLoopSyncTimeout bounds how long the watchdog waits for tool results to
+// reach it before judging a turn, so a dropped update cannot stall the LLM.
+const toolLoopSyncTimeout = 2 * time.Second
+
+var errToolLoop = errors.New("tool loop detected")
+
+const (
+	toolLoopRepeated    = "repeated_call"
+	toolLoopAlternating = "alternating_failures"
+)
+
+type toolLoopCall struct {
+	signature string
+	toolName  string
+	failed    bool
+}
+
+type toolLoopVerdict struct {
+	action  string // "nudge" or "stop"
+	pattern string
+	tool    string
+	count   int
+}
+
+// toolLoopWatchdog watches one turn's tool calls. The first detected loop
+// nudges the model; a further call matching the loop stops the turn.
+type toolLoopWatchdog struct {
+	limit int
+
+	mu       sync.Mutex
+	calls    []toolLoopCall
+	observed chan struct{}
+
+	nudgedAt int
+	loopSigs map[string]bool
+}
+
+func newToolLoopWatchdog(limit int) *toolLoopWatchdog {
+	if limit == 0 {
+		limit = defaultToolLoopLimit
+	}
+	if limit < 0 {
+		return nil
+	}
+	return &toolLoopWatchdog{limit: max(limit, 2), observed: make(chan struct{}, 1), nudgedAt: -1}
+}
+
+// observe records a finished tool call.
+func (w *toolLoopWatchdog) observe(toolName, rawArgs string, failed bool) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.calls = append(w.calls, toolLoopCall{
+		signature: toolName + " " + canonicalToolArgs(rawArgs),
+		toolName:  toolName,
+		failed:    failed,
+	})
+	w.mu.Unlock()
+	select {
+	case w.observed <- struct{}{}:
+	default:
+	}
+}
+
+// waitFor blocks until n tool calls have been observed. Tool results reach
+// the LLM before the update stream delivers them here.
+func (w *toolLoopWatchdog) waitFor(ctx context.Context, n int) {
+	timeout := time.NewTimer(toolLoopSyncTimeout)
+	defer timeout.Stop()
+	for {
+		w.mu.Lock()
+		seen := len(w.calls)
+		w.mu.Unlock()
+		if seen >= n {
+			return
+		}
+		select {
+		case <-w.observed:
+		case <-ctx.Done():
+			return
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// check judges the calls so far and returns a verdict when intervention is
+// due. It returns at most one nudge and then, if the loop persists, a stop.
+func (w *toolLoopWatchdog) check() (toolLoopVerdict, bool) {
+	if w == nil {
+		return toolLoopVerdict{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nudgedAt >= 0 {
+		for _, call := range w.calls[w.nudgedAt:] {
+			if w.loopSigs[call.signature] {
+				return toolLoopVerdict{action: "stop", pattern: "persisted", tool: call.toolName, count: len(w.calls)}, true
+			}
+		}
+		return toolLoopVerdict{}, false
+	}
+
+	counts := map[string]int{}
+	for _, call := range w.calls {
+		counts[call.signature]++
+		if counts[call.signature] >= w.limit {
+			w.markLoop(call.signature)
+			return toolLoopVerdict{action: "nudge", pattern: toolLoopRepeated, tool: call.toolName, count: counts[call.signature]}, true
+		}
+	}
+
+	// Consecutive failures cycling through at most two distinct calls.
+	window := w.limit + 1
+	if len(w.calls) >= window {
+		recent := w.calls[len(w.calls)-window:]
+		sigs := map[string]bool{}
+		allFailed := true
+		for _, call := range recent {
+			sigs[call.signature] = true
+			allFailed = allFailed && call.failed
+		}
+		if allFailed && len(sigs) <= 2 {
+			sigList := make([]string, 0, len(sigs))
+			for sig := range sigs {
+				sigList = append(sigList, sig)
+			}
+			w.markLoop(sigList...)
+			return toolLoopVerdict{action: "nudge", pattern: toolLoopAlternating, tool: recent[len(recent)-1].toolName, count: window}, true
+		}
+	}
+	return toolLoopVerdict{}, false
+}
+
+func (w *toolLoopWatchdog) markLoop(sigs ...string) {
+	w.nudgedAt = len(w.calls)
+	w.loopSigs = map[string]bool{}
+	for _, sig := range sigs {
+		w.loopSigs[sig] = true
+	}
+}
+
+func (v toolLoopVerdict) nudge() string {
+	switch v.pattern {
+	case toolLoopAlternating:
+		return fmt.Sprintf("[runtime] Loop detected: your last %d tool calls all failed while cycling between the same calls (latest: %s). Do not retry them as-is. Change approach, or stop and explain what is blocking you.", v.count, v.tool)
+	default:
+		return fmt.Sprintf("[runtime] Loop detected: you have called %s %d times with identical arguments. Do not repeat it. Use the results you already have, change approach, or stop and explain what is blocking you.", v.tool, v.count)
+	}
+}
+
+// canonicalToolArgs re-encodes JSON arguments so key order and whitespace do
+// not hide repeats.
+func canonicalToolArgs(raw string) string {
+	raw = strings.TrimSpace(raw)
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return string(encoded)
+}
+
+// recordToolLoop writes the loop_detected history entry and signal.
+func (r *Runtime) recordToolLoop(ctx context.Context, agentID, llmTaskID string, generation int64, v toolLoopVerdict) {
+	data := map[string]any{
+		"action":    v.action,
+		"pattern":   v.pattern,
+		"tool_name": v.tool,
+		"count":     v.count,
+	}
+	body := fmt.Sprintf("tool loop detected (%s, %s x%d): %s", v.pattern, v.tool, v.count, v.action)
+	r.appendHistory(ctx, agentID, "loop_detected", "system", body, llmTaskID, generation, data)
+	if r.Bus == nil {
+		return
+	}
+	_, _ = r.Bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamSignals,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Subject:   "loop_detected",
+		Body:      body,
+		Metadata: map[string]any{
+			"kind":      "loop_detected",
+			"priority":  string(schema.PriorityLow),
+			"agent_id":  agentID,
+			"task_id":   llmTaskID,
+			"action":    v.action,
+			"pattern":   v.pattern,
+			"tool_name": v.tool,
+		},
+		SourceID: agentID,
+	})
+}
+
+func countToolMessages(messages []llms.Message) int {
+	n := 0
+	for _, msg := range messages {
+		if msg.Role == "tool" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// loopingProvider calls the same tool with the same arguments forever.
+type loopingProvider struct {
+	mu    sync.Mutex
+	calls [][]llms.Message
+}
+
+func (p *loopingProvider) Company() string              { return "loop" }
+func (p *loopingProvider) Model() string                { return "loop" }
+func (p *loopingProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *loopingProvider) SetHTTPClient(_ *http.Client) {}
+func (p *loopingProvider) Generate(_ context.Context, _ content.Content, messages []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	p.mu.Lock()
+	p.calls = append(p.calls, append([]llms.Message(nil), messages...))
+	n := len(p.calls)
+	p.mu.Unlock()
+	return newToolCallsOnlyStream([]llms.ToolCall{
+		{ID: fmt.Sprintf("noop-%d", n), Name: "noop", Arguments: []byte(`{"comment":"again"}`)},
+	})
+}
+
+func (p *loopingProvider) Calls() [][]llms.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]llms.Message(nil), p.calls...)
+}
+
+func TestToolLoopWatchdogDetectsAlternatingFailures(t *testing.T) {
+	w := newToolLoopWatchdog(3)
+	for _, args := range []string{`{"path":"a"}`, `{"path":"b"}`, `{"path":"a"}`} {
+		w.observe("read", args, true)
+	}
+	if _, ok := w.check(); ok {
+		t.Fatalf("three failures should not trip a limit of 3")
+	}
+	w.observe("read", `{ "path": "b" }`, true)
+	verdict, ok := w.check()
+	if !ok || verdict.pattern != toolLoopAlternating || verdict.action != "nudge" {
+		t.Fatalf("expected alternating failure nudge, got %+v ok=%v", verdict, ok)
+	}
+	w.observe("write", `{"path":"c"}`, false)
+	if _, ok := w.check(); ok {
+		t.Fatalf("a new call after the nudge should not stop the turn")
+	}
+	w.observe("read", `{"path":"b"}`, true)
+	if verdict, ok := w.check(); !ok || verdict.action != "stop" {
+		t.Fatalf("expected stop when the loop resumes, got %+v ok=%v", verdict, ok)
+	}
+	if newToolLoopWatchdog(-1) != nil {
+		t.Fatalf("negative limit should disable the watchdog")
+	}
+}
+
+func TestHandleMessageStopsRunawayToolLoop(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	provider := &loopingProvider{}
+	client := &ai.Client{LLM: llms.New(provider, agenttools.NoopTool())}
+	rt := NewRuntime(bus, mgr, client)
+	createTestAgent(t, mgr, "agent-loop")
+
+	ctx := context.Background()
+	_, err := rt.HandleMessage(ctx, "agent-loop", "user", "go", nil)
+	if err == nil || !errors.Is(err, errToolLoop) {
+		t.Fatalf("expected tool loop error, got %v", err)
+	}
+
+	calls := provider.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 provider calls before the stop, got %d", len(calls))
+	}
+	last := calls[3]
+	if got := messageText(last[len(last)-1]); !strings.Contains(got, "Loop detected") {
+		t.Fatalf("expected nudge before the fourth call, got %q", got)
+	}
+
+	summaries, err := bus.List(ctx, "history", eventbus.ListOptions{ScopeType: "task", ScopeID: "agent-loop", Limit: 500})
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	ids := make([]string, len(summaries))
+	for i, s := range summaries {
+		ids[i] = s.ID
+	}
+	events, err := bus.Read(ctx, "history", ids, "")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	actions := map[string]bool{}
+	for _, evt := range events {
+		if entry, ok := HistoryEntryFromEvent(evt); ok && entry.Type == "loop_detected" {
+			action, _ := entry.Data["action"].(string)
+			actions[action] = true
+		}
+	}
+	if !actions["nudge"] || !actions["stop"] {
+		t.Fatalf("expected nudge and stop loop_detected entries, got %v", actions)
+	}
+
+	signals, err := bus.List(ctx, schema.StreamSignals, eventbus.ListOptions{ScopeType: "task", ScopeID: "agent-loop", Limit: 10})
+	if err != nil {
+		t.Fatalf("list signals: %v", err)
+	}
+	found := 0
+	for _, s := range signals {
+		if s.Subject == "loop_detected" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatalf("expected 2 loop_detected signals, got %d", found)
+	}
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// toolSummaryKeyFields are copied verbatim from JSON tool results into the
+// summary so they survive any truncation of the value itself.
+var toolSummaryKeyFields = []string{
+	"ok", "status", "state", "error", "errors", "exit_code",
+	"id", "task_id", "event_id", "agent_id", "request_id",
+}
+
+// Shrink steps for compactJSONValue, tried in order until the value fits.
+var (
+	toolSummaryStringLimits = []int{400, 200, 80, 32}
+	toolSummaryArrayLimits  = []int{20, 8, 3, 1}
+)
+
+// toolSummaryBudget is how many characters of each content item of
+// toolName's results are kept in task updates and history.
+func (r *Runtime) toolSummaryBudget(toolName string) int {
+	if r != nil {
+		if budget, ok := r.ToolSummaryBudgets[toolName]; ok && budget > 0 {
+			return budget
+		}
+	}
+	return maxToolContentChars
+}
+
+// summarizeJSONText shrinks JSON, or a tool result envelope whose value is
+// JSON, to fit budget while keeping it parseable. It reports the shortened
+// text, key fields lifted from the top-level object, and whether the text
+// held JSON at all.
+func summarizeJSONText(text string, budget int) (string, map[string]any, bool) {
+	prefix, raw, suffix, ok := splitToolResultValue(text)
+	if !ok {
+		prefix, raw, suffix = "", strings.TrimSpace(text), ""
+	}
+	var value any
+	if raw == "" || json.Unmarshal([]byte(raw), &value) != nil {
+		return "", nil, false
+	}
+	keys := jsonKeyFields(value)
+	if len(text) <= budget {
+		return text, keys, true
+	}
+	// json.Marshal escapes <, > and &, so the value needs no XML escaping
+	// inside the envelope.
+	return prefix + compactJSONValue(value, budget-len(prefix)-len(suffix), keys) + suffix, keys, true
+}
+
+// splitToolResultValue finds the <value> section of a toolresult envelope.
+func splitToolResultValue(text string) (prefix, value, suffix string, ok bool) {
+	start := strings.Index(text, "<value>\n")
+	end := strings.LastIndex(text, "\n</value>")
+	if start < 0 || end < start+len("<value>\n") {
+		return "", "", "", false
+	}
+	valueStart := start + len("<value>\n")
+	value = strings.TrimSpace(unescapeXMLText(text[valueStart:end]))
+	return text[:valueStart], value, text[end:], true
+}
+
+func unescapeXMLText(s string) string {
+	s = strings.ReplaceAll(s, "&lt;", "<")
+	s = strings.ReplaceAll(s, "&gt;", ">")
+	return strings.ReplaceAll(s, "&amp;", "&")
+}
+
+func jsonKeyFields(value any) map[string]any {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	out := map[string]any{}
+	for _, key := range toolSummaryKeyFields {
+		v, ok := obj[key]
+		if !ok {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			v = clipText(s, 200)
+		}
+		out[key] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// compactJSONValue marshals value within budget by clipping long strings and
+// long arrays, noting how much was dropped. If nothing fits, only the key
+// fields and the object's field names are kept.
+func compactJSONValue(value any, budget int, keys map[string]any) string {
+	for _, strLimit := range toolSummaryStringLimits {
+		for _, arrLimit := range toolSummaryArrayLimits {
+			data, err := json.Marshal(shrinkJSONValue(value, strLimit, arrLimit))
+			if err == nil && len(data) <= budget {
+				return string(data)
+			}
+		}
+	}
+	stub := map[string]any{"truncated": true}
+	for k, v := range keys {
+		stub[k] = v
+	}
+	if obj, ok := value.(map[string]any); ok {
+		fields := make([]string, 0, len(obj))
+		for k := range obj {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		stub["fields"] = fields
+	}
+	data, _ := json.Marshal(stub)
+	return string(data)
+}
+
+func shrinkJSONValue(value any, strLimit, arrLimit int) any {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= strLimit {
+			return v
+		}
+		return fmt.Sprintf("%s…(+%d chars)", strings.ToValidUTF8(v[:strLimit], ""), len(v)-strLimit)
+	case []any:
+		n := len(v)
+		if n > arrLimit {
+			n = arrLimit
+		}
+		out := make([]any, 0, n+1)
+		for _, item := range v[:n] {
+			out = append(out, shrinkJSONValue(item, strLimit, arrLimit))
+		}
+		if len(v) > n {
+			out = append(out, fmt.Sprintf("…(+%d more items)", len(v)-n))
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = shrinkJSONValue(item, strLimit, arrLimit)
+		}
+		return out
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/toolresult"
+)
+
+func TestSummarizeToolResultKeepsJSONValidWithinBudget(t *testing.T) {
+	lines := make([]string, 200)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", 50)
+	}
+	result := toolresult.Success("exec", map[string]any{
+		"task_id": "task-42",
+		"status":  "completed",
+		"output":  strings.Repeat("log line\n", 300),
+		"lines":   lines,
+	})
+
+	summary := summarizeToolResult(result, 600)
+	items := summary["content"].([]map[string]any)
+	if len(items) != 1 {
+		t.Fatalf("expected one content item, got %d", len(items))
+	}
+	item := items[0]
+	text := item["text"].(string)
+	if item["truncated"] != true || len(text) > 700 {
+		t.Fatalf("expected truncated text near budget, got %d chars: %v", len(text), item["truncated"])
+	}
+	_, raw, _, ok := splitToolResultValue(text)
+	if !ok {
+		t.Fatalf("expected envelope to be kept, got %q", text)
+	}
+	var value map[string]any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		t.Fatalf("expected valid JSON value, got %v: %s", err, raw)
+	}
+	if value["task_id"] != "task-42" || value["status"] != "completed" {
+		t.Fatalf("expected short fields intact, got %v", value)
+	}
+	arr, _ := value["lines"].([]any)
+	if len(arr) == 0 || !strings.Contains(arr[len(arr)-1].(string), "more items") {
+		t.Fatalf("expected array truncated with a count, got %v", value["lines"])
+	}
+	keys := item["key_fields"].(map[string]any)
+	if keys["task_id"] != "task-42" || keys["status"] != "completed" {
+		t.Fatalf("expected key fields, got %v", keys)
+	}
+
+	rt := &Runtime{ToolSummaryBudgets: map[string]int{"exec": 4000}}
+	if rt.toolSummaryBudget("exec") != 4000 || rt.toolSummaryBudget("noop") != maxToolContentChars {
+		t.Fatalf("unexpected budgets")
+	}
+	plain := summarizeToolResult(toolresult.Success("noop", map[string]any{"ok": true}), maxToolContentChars)
+	if plain["content"].([]map[string]any)[0]["truncated"] != false {
+		t.Fatalf("small results should not be truncated")
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// Toolset narrows the tools an agent's turns get. With an Allow list only
+// those tools are offered; Deny removes tools either way. A zero Toolset
+// offers every tool.
+type Toolset struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// IsZero reports whether the toolset offers every tool.
+func (t Toolset) IsZero() bool {
+	return len(t.Allow) == 0 && len(t.Deny) == 0
+}
+
+// Allows reports whether the tool named name is offered.
+func (t Toolset) Allows(name string) bool {
+	if len(t.Allow) > 0 && !slices.Contains(t.Allow, name) {
+		return false
+	}
+	return !slices.Contains(t.Deny, name)
+}
+
+// filter returns the names the toolset allows.
+func (t Toolset) filter(names []string) []string {
+	if t.IsZero() {
+		return names
+	}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if t.Allows(name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// ParseToolset reads a toolset object with allow and deny lists of tool
+// names. A nil value is the zero toolset.
+func ParseToolset(raw any) (Toolset, error) {
+	if raw == nil {
+		return Toolset{}, nil
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return Toolset{}, fmt.Errorf("toolset must be an object")
+	}
+	var toolset Toolset
+	for key := range obj {
+		if key != "allow" && key != "deny" {
+			return Toolset{}, fmt.Errorf("unknown toolset field %q", key)
+		}
+	}
+	var err error
+	if toolset.Allow, err = parseToolNames(obj["allow"], "allow"); err != nil {
+		return Toolset{}, err
+	}
+	if toolset.Deny, err = parseToolNames(obj["deny"], "deny"); err != nil {
+		return Toolset{}, err
+	}
+	return toolset, nil
+}
+
+func parseToolNames(raw any, field string) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var names []string
+	switch v := raw.(type) {
+	case []string:
+		names = v
+	case []any:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("toolset.%s must list tool names", field)
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("toolset.%s must list tool names", field)
+	}
+	return normalizePromptToolNames(names), nil
+}
+
+// ToolsetFromMetadata reads the toolset stored in an agent task's metadata.
+// A malformed entry is treated as no toolset.
+func ToolsetFromMetadata(metadata map[string]any) Toolset {
+	toolset, _ := ParseToolset(metadata[schema.MetaToolset])
+	return toolset
+}
+
+// AgentToolset returns the toolset stored on the agent's task.
+func (r *Runtime) AgentToolset(ctx context.Context, agentID string) (Toolset, error) {
+	if r.Tasks == nil {
+		return Toolset{}, fmt.Errorf("task manager unavailable")
+	}
+	task, err := r.Tasks.Get(ctx, agentID)
+	if err != nil {
+		return Toolset{}, err
+	}
+	return ToolsetFromMetadata(task.Metadata), nil
+}
+
+// SetAgentToolset stores the agent's toolset in its task metadata; the zero
+// toolset removes it. It applies from the agent's next turn.
+func (r *Runtime) SetAgentToolset(ctx context.Context, agentID string, toolset Toolset) (Toolset, error) {
+	if r.Tasks == nil {
+		return Toolset{}, fmt.Errorf("task manager unavailable")
+	}
+	toolset.Allow = normalizePromptToolNames(toolset.Allow)
+	toolset.Deny = normalizePromptToolNames(toolset.Deny)
+	var value any
+	if !toolset.IsZero() {
+		entry := map[string]any{}
+		if len(toolset.Allow) > 0 {
+			entry["allow"] = toolset.Allow
+		}
+		if len(toolset.Deny) > 0 {
+			entry["deny"] = toolset.Deny
+		}
+		value = entry
+	}
+	if _, err := r.Tasks.MergeMetadata(ctx, agentID, map[string]any{schema.MetaToolset: value}); err != nil {
+		return Toolset{}, err
+	}
+	return toolset, nil
+}
+
+// toolFilter returns the session tool filter for a toolset, or nil when
+// every tool is offered.
+func (t Toolset) toolFilter() func(string) bool {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Allows
+}
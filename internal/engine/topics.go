@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/topics"
+)
+
+// agentTopics maps the topics an agent subscribes to onto when it
+// subscribed. Publications from before a subscription are not delivered.
+type agentTopics map[string]time.Time
+
+// subscribedTopics loads agentID's topic subscriptions. Lookup errors leave
+// the agent without topic events rather than failing the turn.
+func (r *Runtime) subscribedTopics(ctx context.Context, agentID string) agentTopics {
+	if r.Topics == nil || strings.TrimSpace(agentID) == "" {
+		return nil
+	}
+	subs, err := r.Topics.ForAgent(ctx, agentID)
+	if err != nil || len(subs) == 0 {
+		return nil
+	}
+	out := make(agentTopics, len(subs))
+	for _, sub := range subs {
+		out[sub.Topic] = sub.CreatedAt
+	}
+	return out
+}
+
+func (t agentTopics) names() []string {
+	if len(t) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(t))
+	for name := range t {
+		out = append(out, name)
+	}
+	return out
+}
+
+// delivers reports whether evt is a topic publication the agent receives.
+func (t agentTopics) delivers(evt eventbus.Event) bool {
+	if evt.ScopeType != topics.ScopeType {
+		return false
+	}
+	since, ok := t[evt.ScopeID]
+	return ok && !evt.CreatedAt.Before(since)
+}
+
+// eventReachesAgent extends eventTargetsTask with the agent's topic
+// subscriptions.
+func eventReachesAgent(evt eventbus.Event, agentID string, subscribed agentTopics) bool {
+	if evt.ScopeType == topics.ScopeType {
+		return subscribed.delivers(evt)
+	}
+	return eventTargetsTask(evt, agentID)
+}
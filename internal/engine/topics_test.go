@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-agents/internal/topics"
+)
+
+func TestTopicEventsReachSubscribersWithPerAgentReadState(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock))
+	store := topics.NewStore(db, bus, topics.WithClock(clock))
+	rt := NewRuntime(bus, nil, nil)
+	rt.Topics = store
+
+	if _, err := store.Publish(ctx, topics.PublishInput{Topic: "deployments", Body: "before anyone listened"}); err != nil {
+		t.Fatalf("publish early: %v", err)
+	}
+	now = now.Add(time.Second)
+	for _, agentID := range []string{"agent-a", "agent-b"} {
+		if _, err := store.Subscribe(ctx, agentID, "deployments"); err != nil {
+			t.Fatalf("subscribe %s: %v", agentID, err)
+		}
+	}
+	now = now.Add(time.Second)
+	published, err := store.Publish(ctx, topics.PublishInput{Topic: "deployments", Source: "ci", Body: "web v42 deployed"})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	unreadFor := func(agentID string) []eventbus.Event {
+		t.Helper()
+		var out []eventbus.Event
+		events, err := rt.collectUnreadContextEvents(ctx, agentID, 0)
+		if err != nil {
+			t.Fatalf("collect %s: %v", agentID, err)
+		}
+		for _, evt := range events {
+			if evt.Stream == schema.StreamShared {
+				out = append(out, evt)
+			}
+		}
+		return out
+	}
+
+	for _, agentID := range []string{"agent-a", "agent-b"} {
+		events := unreadFor(agentID)
+		if len(events) != 1 || events[0].ID != published.ID {
+			t.Fatalf("expected %s to receive only the post-subscription event, got %+v", agentID, events)
+		}
+	}
+	if events := unreadFor("agent-c"); len(events) != 0 {
+		t.Fatalf("expected unsubscribed agent to receive nothing, got %+v", events)
+	}
+
+	rt.ackContextEvents(ctx, "agent-a", unreadFor("agent-a"))
+	if events := unreadFor("agent-a"); len(events) != 0 {
+		t.Fatalf("expected agent-a to have read the event, got %+v", events)
+	}
+	if events := unreadFor("agent-b"); len(events) != 1 {
+		t.Fatalf("expected agent-b to keep its own unread state, got %+v", events)
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHandleMessageContinuesTheMessageTrace(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	createTestAgent(t, mgr, "agent-traced")
+	provider := newMultiExecTurnProvider([]llms.ToolCall{
+		makeExecToolCall(t, "exec-call-1", 0, "globalThis.result = 1"),
+	}, "started the job")
+	rt := NewRuntime(bus, mgr, &ai.Client{LLM: llms.New(provider, agenttools.Traced(agenttools.ExecTool(mgr))...)})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	meta := map[string]any{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"}
+	if _, err := rt.HandleMessage(ctx, "agent-traced", "user", "start the job", meta); err != nil {
+		t.Fatalf("handle message: %v", err)
+	}
+
+	for _, kind := range []string{"llm", "exec"} {
+		list, err := mgr.List(ctx, tasks.ListFilter{Type: kind, Limit: 10})
+		if err != nil || len(list) != 1 {
+			t.Fatalf("expected one %s task, got %d, %v", kind, len(list), err)
+		}
+		if list[0].Metadata["trace_id"] != traceID {
+			t.Fatalf("expected the %s task in the message's trace, got %+v", kind, list[0].Metadata)
+		}
+	}
+
+	// The agent created before the turn started a trace of its own.
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	var execSpawn sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
+		if _, ok := spans[span.Name()]; !ok {
+			spans[span.Name()] = span
+		}
+		for _, attr := range span.Attributes() {
+			if span.Name() == "tasks.Spawn" && attr.Key == "task.type" && attr.Value.AsString() == "exec" {
+				execSpawn = span
+			}
+		}
+	}
+	turn, ok := spans["agent.HandleMessage"]
+	if !ok || turn.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("expected the turn span under the message's span, got %v", spans)
+	}
+	for _, name := range []string{"llm.request", "tool exec"} {
+		if span, ok := spans[name]; !ok || span.Parent().SpanID() != turn.SpanContext().SpanID() {
+			t.Fatalf("expected a %s span under the turn, got %v", name, spans)
+		}
+	}
+	if execSpawn == nil || execSpawn.Parent().SpanID() != spans["tool exec"].SpanContext().SpanID() {
+		t.Fatalf("expected the exec task spawned under the tool call's span")
+	}
+	if _, ok := spans["eventbus.Push"]; !ok {
+		t.Fatalf("expected event pushes traced, got %v", spans)
+	}
+}
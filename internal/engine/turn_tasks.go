@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+// InterruptedTaskPolicy decides what happens to a task that a tool spawned
+// during a turn that was interrupted before finishing.
+type InterruptedTaskPolicy string
+
+const (
+	// InterruptedTaskAdopt moves the task under the agent as background work.
+	InterruptedTaskAdopt InterruptedTaskPolicy = "adopt"
+	// InterruptedTaskCancel cancels the task along with the turn.
+	InterruptedTaskCancel InterruptedTaskPolicy = "cancel"
+)
+
+func (r *Runtime) interruptedTaskPolicy(toolName string) InterruptedTaskPolicy {
+	if policy, ok := r.InterruptedTaskPolicies[strings.TrimSpace(toolName)]; ok {
+		return policy
+	}
+	return InterruptedTaskAdopt
+}
+
+// settleInterruptedTurnTasks applies the per-tool policy to unfinished tasks
+// spawned by the interrupted LLM task so none are left parented to a dead
+// turn.
+func (r *Runtime) settleInterruptedTurnTasks(ctx context.Context, agentID, llmTaskID string) {
+	if r.Tasks == nil || llmTaskID == "" {
+		return
+	}
+	children, err := r.Tasks.List(ctx, tasks.ListFilter{ParentID: llmTaskID, Limit: 200})
+	if err != nil {
+		return
+	}
+	for _, child := range children {
+		if tasks.IsTerminalStatus(child.Status) {
+			continue
+		}
+		toolName, _ := child.Metadata["tool_name"].(string)
+		if toolName == "" {
+			toolName = child.Type
+		}
+		switch r.interruptedTaskPolicy(toolName) {
+		case InterruptedTaskCancel:
+			_ = r.Tasks.Cancel(ctx, child.ID, "turn interrupted")
+		default:
+			_, _ = r.Tasks.Reparent(ctx, child.ID, agentID, map[string]any{
+				"background":       true,
+				"adopted_from_llm": llmTaskID,
+			})
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// UI projection kinds, used as the subject of events on an agent's UI topic.
+const (
+	UIHistory  = "history"
+	UIProgress = "progress"
+)
+
+// publishUI mirrors a stored event onto the agent's UI topic so a frontend
+// can follow one agent without subscribing to the global streams.
+func (r *Runtime) publishUI(agentID, kind string, evt eventbus.Event) {
+	agentID = strings.TrimSpace(agentID)
+	if r.Bus == nil || agentID == "" || evt.ID == "" {
+		return
+	}
+	topic := schema.UITopic(agentID)
+	if !r.Bus.HasTopicSubscribers(topic) {
+		return
+	}
+	meta := make(map[string]any, len(evt.Metadata)+1)
+	for k, v := range evt.Metadata {
+		meta[k] = v
+	}
+	meta["source_stream"] = evt.Stream
+	r.Bus.Publish(topic, eventbus.Event{
+		ID:        evt.ID,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Subject:   kind,
+		Body:      evt.Body,
+		Metadata:  meta,
+		Payload:   evt.Payload,
+		CreatedAt: evt.CreatedAt,
+	})
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestHistoryIsProjectedToAgentUITopic(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	rt := NewRuntime(bus, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mine := bus.SubscribeTopic(ctx, schema.UITopic("agent-a"))
+	other := bus.SubscribeTopic(ctx, schema.UITopic("agent-b"))
+
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "hello", "", 1, nil)
+	rt.newProgressReporter("agent-a", "llm-1").report(ctx, ProgressThinking)
+
+	var got []eventbus.Event
+	for len(got) < 2 {
+		select {
+		case evt := <-mine:
+			got = append(got, evt)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for UI events, got %d", len(got))
+		}
+	}
+	if got[0].Subject != UIHistory || got[0].Payload["content"] != "hello" || got[0].Metadata["source_stream"] != schema.StreamHistory {
+		t.Fatalf("unexpected history projection: %+v", got[0])
+	}
+	if got[1].Subject != UIProgress || got[1].Body != ProgressThinking {
+		t.Fatalf("unexpected progress projection: %+v", got[1])
+	}
+	select {
+	case evt := <-other:
+		t.Fatalf("agent-b received agent-a event: %+v", evt)
+	default:
+	}
+}
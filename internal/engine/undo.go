@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+var (
+	ErrTurnNotFound         = errors.New("turn not found")
+	ErrTurnAlreadyRetracted = errors.New("turn already retracted")
+)
+
+// TurnUndo describes a retracted turn.
+type TurnUndo struct {
+	AgentID        string    `json:"agent_id"`
+	LLMTaskID      string    `json:"llm_task_id"`
+	Message        string    `json:"message,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	CancelledTasks []string  `json:"cancelled_tasks"`
+	RetractedAt    time.Time `json:"retracted_at"`
+}
+
+// UndoTurn retracts the turn run by llmTaskID: it stops the turn if it is
+// still running, cancels the tasks it spawned (including ones adopted by the
+// agent after an interrupt), and records a turn_retracted history entry.
+// Later turns no longer see the retracted exchange; they see a note telling
+// the model to disregard it instead.
+func (r *Runtime) UndoTurn(ctx context.Context, agentID, llmTaskID, reason string) (TurnUndo, error) {
+	if r.Tasks == nil {
+		return TurnUndo{}, fmt.Errorf("task manager unavailable")
+	}
+	agentID = strings.TrimSpace(agentID)
+	llmTaskID = strings.TrimSpace(llmTaskID)
+	turn, err := r.Tasks.Get(ctx, llmTaskID)
+	if err != nil || turn.Type != "llm" || turn.Owner != agentID {
+		return TurnUndo{}, ErrTurnNotFound
+	}
+	if _, ok := turn.Metadata["retracted_at"]; ok {
+		return TurnUndo{}, ErrTurnAlreadyRetracted
+	}
+	reason = strings.TrimSpace(reason)
+	now := r.now()
+	if _, err := r.Tasks.MergeMetadata(ctx, llmTaskID, map[string]any{
+		"retracted_at":   now.Format(time.RFC3339Nano),
+		"retract_reason": reason,
+	}); err != nil {
+		return TurnUndo{}, err
+	}
+
+	spawned, err := r.turnSpawnedTasks(ctx, agentID, llmTaskID)
+	if err != nil {
+		return TurnUndo{}, err
+	}
+	if !tasks.IsTerminalStatus(turn.Status) {
+		// Killing the LLM task stops the turn and its children.
+		_ = r.Tasks.Kill(ctx, llmTaskID, "turn undone")
+	}
+	cancelled := []string{}
+	for _, task := range spawned {
+		if current, err := r.Tasks.Get(ctx, task.ID); err == nil && !tasks.IsTerminalStatus(current.Status) {
+			_ = r.Tasks.Kill(ctx, task.ID, "turn undone")
+		}
+		cancelled = append(cancelled, task.ID)
+	}
+
+	undo := TurnUndo{
+		AgentID:        agentID,
+		LLMTaskID:      llmTaskID,
+		Message:        r.turnMessage(ctx, llmTaskID),
+		Reason:         reason,
+		CancelledTasks: cancelled,
+		RetractedAt:    now,
+	}
+	r.appendHistory(ctx, agentID, "turn_retracted", "system", retractionNote(undo), "", 0, map[string]any{
+		"retracted_task_id": llmTaskID,
+		"reason":            reason,
+		"cancelled_tasks":   cancelled,
+	})
+	return undo, nil
+}
+
+// turnSpawnedTasks lists the unfinished tasks a turn spawned, whether still
+// under the turn or adopted by the agent when the turn was interrupted.
+func (r *Runtime) turnSpawnedTasks(ctx context.Context, agentID, llmTaskID string) ([]tasks.Task, error) {
+	children, err := r.Tasks.List(ctx, tasks.ListFilter{ParentID: llmTaskID, Limit: 200})
+	if err != nil {
+		return nil, err
+	}
+	adopted, err := r.Tasks.List(ctx, tasks.ListFilter{ParentID: agentID, Limit: 200})
+	if err != nil {
+		return nil, err
+	}
+	var out []tasks.Task
+	for _, task := range append(children, adopted...) {
+		if task.ParentID == agentID && task.Metadata["adopted_from_llm"] != llmTaskID {
+			continue
+		}
+		if !tasks.IsTerminalStatus(task.Status) {
+			out = append(out, task)
+		}
+	}
+	return out, nil
+}
+
+// turnMessage returns the message a turn was started with.
+func (r *Runtime) turnMessage(ctx context.Context, llmTaskID string) string {
+	updates, err := r.Tasks.ListUpdatesSince(ctx, llmTaskID, "", "input", 1)
+	if err != nil || len(updates) == 0 {
+		return ""
+	}
+	message, _ := updates[0].Payload["message"].(string)
+	return strings.TrimSpace(message)
+}
+
+func retractionNote(undo TurnUndo) string {
+	var b strings.Builder
+	b.WriteString("[Notice: the operator retracted an earlier message")
+	if undo.Message != "" {
+		fmt.Fprintf(&b, " (%q)", clipText(undo.Message, 200))
+	}
+	b.WriteString(" and undid the turn that handled it")
+	if len(undo.CancelledTasks) > 0 {
+		fmt.Fprintf(&b, ", cancelling tasks %s", strings.Join(undo.CancelledTasks, ", "))
+	}
+	b.WriteString(". Disregard that instruction and anything done in response to it")
+	if undo.Reason != "" {
+		fmt.Fprintf(&b, ". Reason: %s", undo.Reason)
+	}
+	b.WriteString(".]")
+	return b.String()
+}
+
+// retractedTaskID returns the LLM task a turn_retracted entry retracts.
+func retractedTaskID(entry AgentHistoryEntry) string {
+	if entry.Type != "turn_retracted" {
+		return ""
+	}
+	id, _ := entry.Data["retracted_task_id"].(string)
+	return strings.TrimSpace(id)
+}
+
+// MarkRetractedEntries flags entries belonging to retracted turns with
+// data.retracted so history views can show them as withdrawn.
+func MarkRetractedEntries(entries []AgentHistoryEntry) {
+	retracted := map[string]bool{}
+	for _, entry := range entries {
+		if id := retractedTaskID(entry); id != "" {
+			retracted[id] = true
+		}
+	}
+	if len(retracted) == 0 {
+		return
+	}
+	for i := range entries {
+		if entries[i].TaskID == "" || !retracted[entries[i].TaskID] {
+			continue
+		}
+		if entries[i].Data == nil {
+			entries[i].Data = map[string]any{}
+		}
+		entries[i].Data["retracted"] = true
+	}
+}
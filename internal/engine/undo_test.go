@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestUndoTurnCancelsSpawnedTasksAndRetractsHistory(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	rt := NewRuntime(bus, mgr, nil)
+	createTestAgent(t, mgr, "agent-a")
+
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "hi", "", 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "hello", "", 1, nil)
+
+	turn, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "agent-a", ParentID: "agent-a"})
+	if err != nil {
+		t.Fatalf("spawn turn: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, turn.ID, "input", map[string]any{"message": "delete the staging db"}); err != nil {
+		t.Fatalf("record input: %v", err)
+	}
+	child, err := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "agent-a", ParentID: turn.ID})
+	if err != nil {
+		t.Fatalf("spawn child: %v", err)
+	}
+	rt.appendHistory(ctx, "agent-a", "user_message", "user", "delete the staging db", turn.ID, 1, nil)
+	rt.appendHistory(ctx, "agent-a", "assistant_message", "assistant", "deleting it now", turn.ID, 1, nil)
+	if err := mgr.Complete(ctx, turn.ID, map[string]any{"output": "deleting it now"}); err != nil {
+		t.Fatalf("complete turn: %v", err)
+	}
+
+	// Load once so the cached conversation already holds the turn.
+	if _, messages, _ := rt.loadConversationMessages(ctx, "agent-a", 1); len(messages) != 4 {
+		t.Fatalf("expected 4 messages before undo, got %d", len(messages))
+	}
+
+	if _, err := rt.UndoTurn(ctx, "agent-b", turn.ID, ""); !errors.Is(err, ErrTurnNotFound) {
+		t.Fatalf("expected another agent's undo to fail, got %v", err)
+	}
+	undo, err := rt.UndoTurn(ctx, "agent-a", turn.ID, "wrong environment")
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if undo.Message != "delete the staging db" || len(undo.CancelledTasks) != 1 || undo.CancelledTasks[0] != child.ID {
+		t.Fatalf("unexpected undo %+v", undo)
+	}
+	if got, _ := mgr.Get(ctx, child.ID); got.Status != tasks.StatusCancelled {
+		t.Fatalf("expected spawned task cancelled, got %s", got.Status)
+	}
+	if _, err := rt.UndoTurn(ctx, "agent-a", turn.ID, ""); !errors.Is(err, ErrTurnAlreadyRetracted) {
+		t.Fatalf("expected second undo to fail, got %v", err)
+	}
+
+	_, messages, err := rt.loadConversationMessages(ctx, "agent-a", 1)
+	if err != nil {
+		t.Fatalf("load after undo: %v", err)
+	}
+	texts := conversationTexts(messages)
+	if len(texts) != 2 || texts[0] != "user: hi" || !strings.HasPrefix(texts[1], "assistant: hello\n\n[Notice: the operator retracted") {
+		t.Fatalf("expected the turn replaced by a retraction note, got %q", texts)
+	}
+	if !strings.Contains(texts[1], "wrong environment") {
+		t.Fatalf("expected the note to carry the reason, got %q", texts[1])
+	}
+	_, rebuilt, _ := NewRuntime(bus, mgr, nil).loadConversationMessages(ctx, "agent-a", 1)
+	if got := conversationTexts(rebuilt); len(got) != len(texts) || got[1] != texts[1] {
+		t.Fatalf("incremental load %q does not match rebuild %q", texts, got)
+	}
+
+	history, err := rt.readHistoryEntries(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	MarkRetractedEntries(history)
+	marked := 0
+	for _, entry := range history {
+		if entry.Data["retracted"] == true {
+			if entry.TaskID != turn.ID {
+				t.Fatalf("unexpected retracted entry %+v", entry)
+			}
+			marked++
+		}
+	}
+	if marked != 2 {
+		t.Fatalf("expected 2 retracted entries, got %d", marked)
+	}
+}
@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"context"
+	"strings"
+)
+
+// ClassifyInbound tags meta with the urgency and sentiment of an inbound
+// external message. When the caller did not set a priority, the urgency
+// decides it: high interrupts, low chatter stays normal.
+func (r *Runtime) ClassifyInbound(ctx context.Context, body string, meta map[string]any) {
+	if r.Urgency == nil || meta == nil {
+		return
+	}
+	result, err := r.Urgency.Classify(ctx, body)
+	if err != nil {
+		return
+	}
+	meta["urgency"] = string(result.Urgency)
+	meta["sentiment"] = string(result.Sentiment)
+	if raw, ok := meta["priority"].(string); ok && strings.TrimSpace(raw) != "" {
+		return
+	}
+	meta["priority"] = string(result.Priority())
+	meta["priority_source"] = "urgency"
+}
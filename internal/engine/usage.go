@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/flitsinc/go-agents/internal/usage"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// recordUsage stores the tokens a turn consumed as an llm_usage update on its
+// llm task, and in the Usage store when there is one. The client may be
+// shared across turns, so the turn's usage is the difference between its
+// running totals. When model has a price the update also carries the
+// estimated cost.
+func (r *Runtime) recordUsage(ctx context.Context, agentID, llmTaskID, model string, before, after llms.Usage) {
+	delta := llms.Usage{
+		InputTokens:              after.InputTokens - before.InputTokens,
+		OutputTokens:             after.OutputTokens - before.OutputTokens,
+		CachedInputTokens:        after.CachedInputTokens - before.CachedInputTokens,
+		CacheCreationInputTokens: after.CacheCreationInputTokens - before.CacheCreationInputTokens,
+	}
+	if delta == (llms.Usage{}) {
+		return
+	}
+	payload := map[string]any{
+		"input_tokens":                delta.InputTokens,
+		"output_tokens":               delta.OutputTokens,
+		"cached_input_tokens":         delta.CachedInputTokens,
+		"cache_creation_input_tokens": delta.CacheCreationInputTokens,
+	}
+	var cost int64
+	if price, ok := r.modelPrice(model); ok {
+		cost = price.Cost(delta)
+		payload["model"] = model
+		payload["cost_microusd"] = cost
+	}
+	r.recordLLMUpdate(ctx, llmTaskID, "llm_usage", payload)
+	if r.Usage == nil {
+		return
+	}
+	_ = r.Usage.Record(ctx, usage.Record{
+		TaskID:                   llmTaskID,
+		AgentID:                  agentID,
+		Model:                    model,
+		InputTokens:              int64(delta.InputTokens),
+		OutputTokens:             int64(delta.OutputTokens),
+		CachedInputTokens:        int64(delta.CachedInputTokens),
+		CacheCreationInputTokens: int64(delta.CacheCreationInputTokens),
+		CostMicroUSD:             cost,
+	})
+}
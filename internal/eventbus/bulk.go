@@ -0,0 +1,181 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBulkLimit is how many events a bulk operation changes when the
+	// caller gives no limit; MaxBulkLimit caps what it may ask for.
+	DefaultBulkLimit = 1000
+	MaxBulkLimit     = 10000
+	bulkPageSize     = 500
+)
+
+// BulkFilter selects the events a bulk operation applies to. Scope and field
+// filters work as in List; Before keeps events created before it.
+type BulkFilter struct {
+	ScopeType string
+	ScopeID   string
+	Fields    []FieldFilter
+	Namespace string
+	Before    time.Time
+	// Limit caps how many events are changed; zero uses DefaultBulkLimit.
+	Limit int
+}
+
+// BulkResult reports a bulk operation. Truncated is set when more events
+// matched than the limit allowed; running the operation again continues.
+type BulkResult struct {
+	Changed   int      `json:"changed"`
+	EventIDs  []string `json:"event_ids"`
+	Truncated bool     `json:"truncated"`
+}
+
+// AckAll marks every event matching filter read for reader.
+func (b *Bus) AckAll(ctx context.Context, stream, reader string, filter BulkFilter) (BulkResult, error) {
+	if strings.TrimSpace(reader) == "" {
+		return BulkResult{}, fmt.Errorf("reader is required")
+	}
+	ids, truncated, err := b.bulkMatch(ctx, stream, reader, filter, false)
+	if err != nil {
+		return BulkResult{}, err
+	}
+	if err := b.Ack(ctx, stream, ids, reader); err != nil {
+		return BulkResult{}, err
+	}
+	return BulkResult{Changed: len(ids), EventIDs: ids, Truncated: truncated}, nil
+}
+
+// MarkUnreadAll marks every event matching filter unread for reader, so
+// consumers that track read state, like agent loops, process them again.
+func (b *Bus) MarkUnreadAll(ctx context.Context, stream, reader string, filter BulkFilter) (BulkResult, error) {
+	if strings.TrimSpace(reader) == "" {
+		return BulkResult{}, fmt.Errorf("reader is required")
+	}
+	ids, truncated, err := b.bulkMatch(ctx, stream, reader, filter, true)
+	if err != nil {
+		return BulkResult{}, err
+	}
+	if err := b.MarkUnread(ctx, stream, ids, reader); err != nil {
+		return BulkResult{}, err
+	}
+	return BulkResult{Changed: len(ids), EventIDs: ids, Truncated: truncated}, nil
+}
+
+// MarkUnread removes reader from the read_by list of the given events.
+func (b *Bus) MarkUnread(ctx context.Context, stream string, ids []string, reader string) error {
+	if reader == "" {
+		return fmt.Errorf("reader is required")
+	}
+	ids = filterEmpty(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(stream) == "" {
+		return fmt.Errorf("stream is required")
+	}
+	if b.mem != nil {
+		b.mem.setRead(stream, ids, reader, false)
+		return nil
+	}
+	return b.eachDB(ctx, reader, ids, func(db *sql.DB, ids []string) ([]string, error) {
+		return b.unackEvents(ctx, db, stream, ids, reader)
+	})
+}
+
+// bulkMatch pages through the stream oldest first and collects up to the
+// limit of events matching filter whose read state for reader is wantRead.
+func (b *Bus) bulkMatch(ctx context.Context, stream, reader string, filter BulkFilter, wantRead bool) ([]string, bool, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultBulkLimit
+	}
+	if limit > MaxBulkLimit {
+		return nil, false, fmt.Errorf("limit must be at most %d", MaxBulkLimit)
+	}
+	opts := ListOptions{
+		Reader:    reader,
+		Limit:     bulkPageSize,
+		Order:     "fifo",
+		ScopeType: filter.ScopeType,
+		ScopeID:   filter.ScopeID,
+		Fields:    filter.Fields,
+		Namespace: filter.Namespace,
+	}
+	ids := []string{}
+	for {
+		page, err := b.List(ctx, stream, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, summary := range page {
+			if !filter.Before.IsZero() && !summary.CreatedAt.Before(filter.Before) {
+				return ids, false, nil
+			}
+			if summary.Read != wantRead {
+				continue
+			}
+			if len(ids) == limit {
+				return ids, true, nil
+			}
+			ids = append(ids, summary.ID)
+		}
+		if len(page) < bulkPageSize {
+			return ids, false, nil
+		}
+		opts.After = page[len(page)-1].ID
+	}
+}
+
+// unackEvents removes reader from read_by of the events in db and returns
+// the IDs it found there.
+func (b *Bus) unackEvents(ctx context.Context, db *sql.DB, stream string, ids []string, reader string) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin unread tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var found []string
+	for _, id := range ids {
+		var readByStr string
+		err := tx.QueryRowContext(ctx, `SELECT read_by FROM events WHERE stream = ? AND id = ?`+b.dialect.LockRows(), stream, id).Scan(&readByStr)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load read_by: %w", err)
+		}
+		found = append(found, id)
+		readBy := decodeReadBy(readByStr)
+		if !readerInList(reader, readBy) {
+			continue
+		}
+		kept := make([]string, 0, len(readBy))
+		for _, r := range readBy {
+			if r != reader {
+				kept = append(kept, r)
+			}
+		}
+		updated, err := json.Marshal(kept)
+		if err != nil {
+			return nil, fmt.Errorf("encode read_by: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE events SET read_by = ? WHERE stream = ? AND id = ?`, string(updated), stream, id); err != nil {
+			return nil, fmt.Errorf("update read_by: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit unread: %w", err)
+	}
+	return found, nil
+}
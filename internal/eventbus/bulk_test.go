@@ -0,0 +1,79 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestBulkAckAndMarkUnread(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := NewBus(db)
+	ctx := context.Background()
+	var ids []string
+	for _, status := range []string{"failed", "ok", "failed", "failed"} {
+		evt, err := bus.Push(ctx, EventInput{Stream: "errors", Body: status, Payload: map[string]any{"status": status}})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		ids = append(ids, evt.ID)
+	}
+	failed := FieldFilter{Column: "payload", Path: []string{"status"}, Values: []string{"failed"}}
+
+	result, err := bus.AckAll(ctx, "errors", "ops", BulkFilter{Fields: []FieldFilter{failed}, Limit: 2})
+	if err != nil {
+		t.Fatalf("ack all: %v", err)
+	}
+	if result.Changed != 2 || !result.Truncated || result.EventIDs[0] != ids[0] || result.EventIDs[1] != ids[2] {
+		t.Fatalf("expected the two oldest failed events and truncation, got %+v", result)
+	}
+	result, err = bus.AckAll(ctx, "errors", "ops", BulkFilter{})
+	if err != nil {
+		t.Fatalf("ack rest: %v", err)
+	}
+	if result.Changed != 2 || result.Truncated {
+		t.Fatalf("expected the remaining two events acked, got %+v", result)
+	}
+	if unread := countUnread(t, bus, "ops"); unread != 0 {
+		t.Fatalf("expected nothing unread, got %d", unread)
+	}
+
+	if err := bus.MarkUnread(ctx, "errors", []string{ids[1]}, "ops"); err != nil {
+		t.Fatalf("mark unread: %v", err)
+	}
+	result, err = bus.MarkUnreadAll(ctx, "errors", "ops", BulkFilter{Fields: []FieldFilter{failed}})
+	if err != nil {
+		t.Fatalf("mark unread all: %v", err)
+	}
+	if result.Changed != 3 {
+		t.Fatalf("expected the three failed events marked unread, got %+v", result)
+	}
+	if unread := countUnread(t, bus, "ops"); unread != 4 {
+		t.Fatalf("expected all four events unread, got %d", unread)
+	}
+
+	if _, err := bus.AckAll(ctx, "errors", "ops", BulkFilter{Limit: MaxBulkLimit + 1}); err == nil {
+		t.Fatalf("expected a limit above the maximum to be rejected")
+	}
+	if _, err := bus.AckAll(ctx, "errors", "", BulkFilter{}); err == nil {
+		t.Fatalf("expected a reader to be required")
+	}
+}
+
+func countUnread(t *testing.T, bus *Bus, reader string) int {
+	t.Helper()
+	list, err := bus.List(context.Background(), "errors", ListOptions{Reader: reader, Limit: 100})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	unread := 0
+	for _, summary := range list {
+		if !summary.Read {
+			unread++
+		}
+	}
+	return unread
+}
@@ -7,16 +7,28 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/flitsinc/go-agents/internal/agentcontext"
 	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Bus struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect state.Dialect
+	shards  ShardRouter
+	// mem replaces the database for buses made by NewMemoryBus.
+	mem *memoryStore
 
-	mu   sync.RWMutex
-	subs map[string]*subscriber
+	mu      sync.RWMutex
+	subs    map[string]*subscriber
+	topics  map[string]map[string]*subscriber
+	dropped atomic.Int64
 
 	nowFn   func() time.Time
 	newIDFn func() string
@@ -37,6 +49,16 @@ func WithClock(nowFn func() time.Time) Option {
 	}
 }
 
+// WithDialect sets the SQL dialect of the bus's database; the default is
+// SQLite.
+func WithDialect(dialect state.Dialect) Option {
+	return func(b *Bus) {
+		if dialect != nil {
+			b.dialect = dialect
+		}
+	}
+}
+
 func WithIDGenerator(newIDFn func() string) Option {
 	return func(b *Bus) {
 		if newIDFn != nil {
@@ -48,7 +70,9 @@ func WithIDGenerator(newIDFn func() string) Option {
 func NewBus(db *sql.DB, opts ...Option) *Bus {
 	b := &Bus{
 		db:      db,
+		dialect: state.SQLite,
 		subs:    map[string]*subscriber{},
+		topics:  map[string]map[string]*subscriber{},
 		nowFn:   func() time.Time { return time.Now().UTC() },
 		newIDFn: idgen.New,
 	}
@@ -60,6 +84,11 @@ func NewBus(db *sql.DB, opts ...Option) *Bus {
 	return b
 }
 
+// Dialect returns the SQL dialect of the bus's database.
+func (b *Bus) Dialect() state.Dialect {
+	return b.dialect
+}
+
 func (b *Bus) now() time.Time {
 	if b.nowFn == nil {
 		return time.Now().UTC()
@@ -74,7 +103,11 @@ func (b *Bus) newID() string {
 	return b.newIDFn()
 }
 
-func (b *Bus) Push(ctx context.Context, input EventInput) (Event, error) {
+func (b *Bus) Push(ctx context.Context, input EventInput) (_ Event, err error) {
+	ctx, span := tracing.Start(ctx, "eventbus.Push",
+		attribute.String("event.stream", input.Stream),
+		attribute.String("event.scope", input.ScopeType+":"+input.ScopeID))
+	defer func() { tracing.End(span, err) }()
 	if strings.TrimSpace(input.Stream) == "" {
 		return Event{}, fmt.Errorf("stream is required")
 	}
@@ -92,7 +125,11 @@ func (b *Bus) Push(ctx context.Context, input EventInput) (Event, error) {
 	}
 
 	id := b.newID()
+	span.SetAttributes(attribute.String("event.id", id))
 	createdAt := b.now()
+	// Stored priorities are canonical so SQL filters can match them.
+	input.Metadata = schema.NormalizePriorityMeta(input.Metadata)
+	input.Metadata = withNamespace(ctx, input.Metadata)
 	metadataJSON, err := encodeJSON(input.Metadata)
 	if err != nil {
 		return Event{}, fmt.Errorf("encode metadata: %w", err)
@@ -111,13 +148,6 @@ func (b *Bus) Push(ctx context.Context, input EventInput) (Event, error) {
 		}
 	}
 
-	if err := execWithRetry(ctx, b.db, `
-		INSERT INTO events (id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, input.Stream, scopeType, scopeID, nullString(input.Subject), input.Body, metadataJSON, payloadJSON, createdAt.Format(time.RFC3339Nano), readByJSON); err != nil {
-		return Event{}, fmt.Errorf("insert event: %w", err)
-	}
-
 	event := Event{
 		ID:        id,
 		Stream:    input.Stream,
@@ -131,11 +161,45 @@ func (b *Bus) Push(ctx context.Context, input EventInput) (Event, error) {
 		Read:      false,
 		ReadBy:    readBy,
 	}
+	if b.mem != nil {
+		b.mem.push(event, metadataJSON, payloadJSON)
+		b.broadcast(event)
+		return event, nil
+	}
+
+	db, err := b.pushDB(ctx, scopeType, scopeID)
+	if err != nil {
+		return Event{}, fmt.Errorf("route event: %w", err)
+	}
+	if err := execWithRetry(ctx, db, `
+		INSERT INTO events (id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, input.Stream, scopeType, scopeID, nullString(input.Subject), input.Body, metadataJSON, payloadJSON, createdAt.Format(time.RFC3339Nano), readByJSON); err != nil {
+		return Event{}, fmt.Errorf("insert event: %w", err)
+	}
 
 	b.broadcast(event)
 	return event, nil
 }
 
+// withNamespace tags metadata with the namespace in ctx unless it already
+// names one.
+func withNamespace(ctx context.Context, metadata map[string]any) map[string]any {
+	namespace := agentcontext.NamespaceFromContext(ctx)
+	if namespace == "" {
+		return metadata
+	}
+	if _, ok := metadata[schema.MetaNamespace]; ok {
+		return metadata
+	}
+	out := make(map[string]any, len(metadata)+1)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[schema.MetaNamespace] = namespace
+	return out
+}
+
 func execWithRetry(ctx context.Context, db *sql.DB, query string, args ...any) error {
 	var err error
 	for attempt := 0; attempt < 5; attempt++ {
@@ -146,6 +210,7 @@ func execWithRetry(ctx context.Context, db *sql.DB, query string, args ...any) e
 		if !isBusyError(err) {
 			return err
 		}
+		state.DefaultTelemetry.RecordBusyRetry()
 		select {
 		case <-ctx.Done():
 			return err
@@ -178,16 +243,56 @@ func (b *Bus) List(ctx context.Context, stream string, opts ListOptions) ([]Even
 	if order != "fifo" && order != "lifo" {
 		order = "lifo"
 	}
+	if b.mem != nil {
+		return b.mem.list(stream, opts, limit, order == "fifo")
+	}
 	orderBy := "created_at DESC"
 	if order == "fifo" {
-		orderBy = "created_at ASC"
+		orderBy = "created_at ASC, id ASC"
 	}
 
+	dbs, err := b.listDBs(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("route list: %w", err)
+	}
 	where, args := buildScopeWhere(stream, opts)
+	for _, filter := range opts.fieldFilters() {
+		clause, filterArgs, err := filter.where(b.dialect)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND " + clause
+		args = append(args, filterArgs...)
+	}
+	if after := strings.TrimSpace(opts.After); after != "" {
+		afterCreatedAt, err := b.eventCreatedAt(ctx, stream, after)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (created_at > ? OR (created_at = ? AND id > ?))"
+		args = append(args, afterCreatedAt, afterCreatedAt, after)
+		orderBy = "created_at ASC, id ASC"
+	}
+	if !opts.Before.IsZero() {
+		where += " AND " + b.dialect.Time("created_at") + " < " + b.dialect.Time("?")
+		args = append(args, opts.Before.UTC().Format(time.RFC3339Nano))
+	}
 	query := fmt.Sprintf(`SELECT id, stream, subject, created_at, read_by FROM events %s ORDER BY %s LIMIT ?`, where, orderBy)
 	args = append(args, limit)
 
-	rows, err := b.db.QueryContext(ctx, query, args...)
+	parts := make([][]EventSummary, 0, len(dbs))
+	for _, db := range dbs {
+		part, err := listEvents(ctx, db, query, args, opts.Reader)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return mergeSummaries(parts, strings.HasPrefix(orderBy, "created_at ASC"), limit), nil
+}
+
+func listEvents(ctx context.Context, db *sql.DB, query string, args []any, reader string) ([]EventSummary, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list events: %w", err)
 	}
@@ -203,7 +308,7 @@ func (b *Bus) List(ctx context.Context, stream string, opts ListOptions) ([]Even
 		}
 		createdAt, _ := time.Parse(time.RFC3339Nano, createdAtStr)
 		readBy := decodeReadBy(readByStr.String)
-		read := readerInList(opts.Reader, readBy)
+		read := readerInList(reader, readBy)
 		out = append(out, EventSummary{
 			ID:        id,
 			Stream:    streamName,
@@ -218,6 +323,47 @@ func (b *Bus) List(ctx context.Context, stream string, opts ListOptions) ([]Even
 	return out, nil
 }
 
+// Count returns how many events match opts, optionally limited to those
+// created in [since, until). Zero times leave that side open.
+func (b *Bus) Count(ctx context.Context, stream string, opts ListOptions, since, until time.Time) (int, error) {
+	if strings.TrimSpace(stream) == "" {
+		return 0, fmt.Errorf("stream is required")
+	}
+	if b.mem != nil {
+		return b.mem.count(stream, opts, since.UTC(), until.UTC())
+	}
+	where, args := buildScopeWhere(stream, opts)
+	for _, filter := range opts.fieldFilters() {
+		clause, filterArgs, err := filter.where(b.dialect)
+		if err != nil {
+			return 0, err
+		}
+		where += " AND " + clause
+		args = append(args, filterArgs...)
+	}
+	if !since.IsZero() {
+		where += " AND " + b.dialect.Time("created_at") + " >= " + b.dialect.Time("?")
+		args = append(args, since.UTC().Format(time.RFC3339Nano))
+	}
+	if !until.IsZero() {
+		where += " AND " + b.dialect.Time("created_at") + " < " + b.dialect.Time("?")
+		args = append(args, until.UTC().Format(time.RFC3339Nano))
+	}
+	dbs, err := b.listDBs(ctx, opts)
+	if err != nil {
+		return 0, fmt.Errorf("route count: %w", err)
+	}
+	total := 0
+	for _, db := range dbs {
+		var n int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events "+where, args...).Scan(&n); err != nil {
+			return 0, fmt.Errorf("count events: %w", err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
 func (b *Bus) Read(ctx context.Context, stream string, ids []string, reader string) ([]Event, error) {
 	ids = filterEmpty(ids)
 	if len(ids) == 0 {
@@ -226,6 +372,29 @@ func (b *Bus) Read(ctx context.Context, stream string, ids []string, reader stri
 	if strings.TrimSpace(stream) == "" {
 		return nil, fmt.Errorf("stream is required")
 	}
+	if b.mem != nil {
+		return b.mem.read(stream, ids, reader), nil
+	}
+	var out []Event
+	err := b.eachDB(ctx, reader, ids, func(db *sql.DB, ids []string) ([]string, error) {
+		events, err := readEvents(ctx, db, stream, ids, reader)
+		if err != nil {
+			return nil, err
+		}
+		found := make([]string, 0, len(events))
+		for _, e := range events {
+			found = append(found, e.ID)
+		}
+		out = append(out, events...)
+		return found, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func readEvents(ctx context.Context, db *sql.DB, stream string, ids []string, reader string) ([]Event, error) {
 	placeholders := strings.Repeat("?,", len(ids))
 	placeholders = strings.TrimSuffix(placeholders, ",")
 	args := []any{stream}
@@ -234,7 +403,7 @@ func (b *Bus) Read(ctx context.Context, stream string, ids []string, reader stri
 	}
 
 	query := fmt.Sprintf(`SELECT id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by FROM events WHERE stream = ? AND id IN (%s)`, placeholders)
-	rows, err := b.db.QueryContext(ctx, query, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("read events: %w", err)
 	}
@@ -263,6 +432,37 @@ func (b *Bus) Read(ctx context.Context, stream string, ids []string, reader stri
 	return out, nil
 }
 
+// Delete removes events from stream and returns how many it removed. It is
+// meant for events that were copied elsewhere, such as an archive.
+func (b *Bus) Delete(ctx context.Context, stream string, ids []string) (int, error) {
+	ids = filterEmpty(ids)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if strings.TrimSpace(stream) == "" {
+		return 0, fmt.Errorf("stream is required")
+	}
+	if b.mem != nil {
+		return b.mem.delete(stream, ids), nil
+	}
+	deleted := 0
+	err := b.eachDB(ctx, "", ids, func(db *sql.DB, ids []string) ([]string, error) {
+		var found []string
+		for _, id := range ids {
+			res, err := db.ExecContext(ctx, `DELETE FROM events WHERE stream = ? AND id = ?`, stream, id)
+			if err != nil {
+				return found, fmt.Errorf("delete event: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				found = append(found, id)
+			}
+		}
+		deleted += len(found)
+		return found, nil
+	})
+	return deleted, err
+}
+
 func (b *Bus) Ack(ctx context.Context, stream string, ids []string, reader string) error {
 	if reader == "" {
 		return fmt.Errorf("reader is required")
@@ -274,24 +474,55 @@ func (b *Bus) Ack(ctx context.Context, stream string, ids []string, reader strin
 	if strings.TrimSpace(stream) == "" {
 		return fmt.Errorf("stream is required")
 	}
+	if b.mem != nil {
+		b.mem.setRead(stream, ids, reader, true)
+		return nil
+	}
+	return b.eachDB(ctx, reader, ids, func(db *sql.DB, ids []string) ([]string, error) {
+		return b.ackEvents(ctx, db, stream, ids, reader)
+	})
+}
 
-	tx, err := b.db.BeginTx(ctx, nil)
+// ackEvents adds reader to read_by of the events in db and returns the IDs
+// it found there, retrying while another writer holds the database.
+func (b *Bus) ackEvents(ctx context.Context, db *sql.DB, stream string, ids []string, reader string) ([]string, error) {
+	var found []string
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		found, err = b.ackEventsOnce(ctx, db, stream, ids, reader)
+		if !isBusyError(err) {
+			return found, err
+		}
+		state.DefaultTelemetry.RecordBusyRetry()
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(time.Duration(25*(attempt+1)) * time.Millisecond):
+		}
+	}
+	return nil, err
+}
+
+func (b *Bus) ackEventsOnce(ctx context.Context, db *sql.DB, stream string, ids []string, reader string) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin ack tx: %w", err)
+		return nil, fmt.Errorf("begin ack tx: %w", err)
 	}
 	defer func() {
 		_ = tx.Rollback()
 	}()
 
+	var found []string
 	for _, id := range ids {
 		var readByStr string
-		err := tx.QueryRowContext(ctx, `SELECT read_by FROM events WHERE stream = ? AND id = ?`, stream, id).Scan(&readByStr)
+		err := tx.QueryRowContext(ctx, `SELECT read_by FROM events WHERE stream = ? AND id = ?`+b.dialect.LockRows(), stream, id).Scan(&readByStr)
 		if err == sql.ErrNoRows {
 			continue
 		}
 		if err != nil {
-			return fmt.Errorf("load read_by: %w", err)
+			return nil, fmt.Errorf("load read_by: %w", err)
 		}
+		found = append(found, id)
 		readBy := decodeReadBy(readByStr)
 		if readerInList(reader, readBy) {
 			continue
@@ -299,17 +530,17 @@ func (b *Bus) Ack(ctx context.Context, stream string, ids []string, reader strin
 		readBy = append(readBy, reader)
 		updated, err := json.Marshal(readBy)
 		if err != nil {
-			return fmt.Errorf("encode read_by: %w", err)
+			return nil, fmt.Errorf("encode read_by: %w", err)
 		}
 		if _, err := tx.ExecContext(ctx, `UPDATE events SET read_by = ? WHERE stream = ? AND id = ?`, string(updated), stream, id); err != nil {
-			return fmt.Errorf("update read_by: %w", err)
+			return nil, fmt.Errorf("update read_by: %w", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit ack: %w", err)
+		return nil, fmt.Errorf("commit ack: %w", err)
 	}
-	return nil
+	return found, nil
 }
 
 func (b *Bus) Subscribe(ctx context.Context, streams []string) <-chan Event {
@@ -339,12 +570,93 @@ func (b *Bus) Subscribe(ctx context.Context, streams []string) <-chan Event {
 	return ch
 }
 
+// SubscribeTopic receives events published to topic. Topics are derived,
+// in-memory fan-outs: nothing is stored, and only that topic's subscribers are
+// visited on publish.
+func (b *Bus) SubscribeTopic(ctx context.Context, topic string) <-chan Event {
+	ch := make(chan Event, 64)
+	id := b.newID()
+
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[string]*subscriber{}
+	}
+	b.topics[topic][id] = &subscriber{ch: ch}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.topics[topic], id)
+		if len(b.topics[topic]) == 0 {
+			delete(b.topics, topic)
+		}
+		b.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Publish delivers event to the subscribers of topic without persisting it.
+func (b *Bus) Publish(topic string, event Event) {
+	event.Stream = topic
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.topics[topic] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// HasTopicSubscribers reports whether anyone listens on topic, so publishers
+// can skip building projections nobody reads.
+func (b *Bus) HasTopicSubscribers(topic string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic]) > 0
+}
+
 func (b *Bus) SubscriberCount() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 	return len(b.subs)
 }
 
+// Stats describes live subscribers. Topics counts topic subscribers, which
+// are not included in Subscribers. Saturated counts subscribers whose buffer
+// is full; Dropped counts events discarded for slow subscribers since
+// the bus was created.
+type Stats struct {
+	Subscribers int   `json:"subscribers"`
+	Topics      int   `json:"topics"`
+	Saturated   int   `json:"saturated"`
+	Dropped     int64 `json:"dropped"`
+}
+
+func (b *Bus) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := Stats{Subscribers: len(b.subs), Dropped: b.dropped.Load()}
+	for _, sub := range b.subs {
+		if len(sub.ch) == cap(sub.ch) {
+			stats.Saturated++
+		}
+	}
+	for _, subs := range b.topics {
+		stats.Topics += len(subs)
+		for _, sub := range subs {
+			if len(sub.ch) == cap(sub.ch) {
+				stats.Saturated++
+			}
+		}
+	}
+	return stats
+}
+
 func (b *Bus) broadcast(event Event) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
@@ -358,6 +670,7 @@ func (b *Bus) broadcast(event Event) {
 		case sub.ch <- event:
 		default:
 			// Drop if subscriber is slow.
+			b.dropped.Add(1)
 		}
 	}
 }
@@ -378,13 +691,23 @@ func buildScopeWhere(stream string, opts ListOptions) (string, []any) {
 		}
 		return where, args
 	}
+	if opts.AllScopes {
+		return where, args
+	}
 
-	// Default: global scope, plus task scope if reader provided.
+	// Default: global scope, plus task scope if reader provided and any
+	// subscribed topics.
 	where += " AND ((scope_type = 'global' AND scope_id = '*')"
 	if opts.Reader != "" {
 		where += " OR (scope_type = 'task' AND scope_id = ?)"
 		args = append(args, opts.Reader)
 	}
+	if topics := filterEmpty(opts.Topics); len(topics) > 0 {
+		where += " OR (scope_type = 'topic' AND scope_id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(topics)), ",") + "))"
+		for _, topic := range topics {
+			args = append(args, topic)
+		}
+	}
 	where += ")"
 	return where, args
 }
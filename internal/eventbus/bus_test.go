@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/flitsinc/go-agents/internal/agentcontext"
 	"github.com/flitsinc/go-agents/internal/testutil"
 )
 
@@ -58,6 +59,36 @@ func TestBusPushListReadAck(t *testing.T) {
 	}
 }
 
+func TestBusListsOneNamespace(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	for name, bus := range map[string]*Bus{"sql": NewBus(db), "memory": NewMemoryBus()} {
+		acme := agentcontext.WithNamespace(context.Background(), "acme")
+		tagged, err := bus.Push(acme, EventInput{Stream: "signals", Body: "acme"})
+		if err != nil {
+			t.Fatalf("%s: push: %v", name, err)
+		}
+		if !InNamespace(tagged, "acme") || InNamespace(tagged, "globex") {
+			t.Fatalf("%s: expected the event tagged with the context's namespace, got %+v", name, tagged.Metadata)
+		}
+		if _, err := bus.Push(acme, EventInput{Stream: "signals", Body: "globex", Metadata: map[string]any{"namespace": "globex"}}); err != nil {
+			t.Fatalf("%s: push: %v", name, err)
+		}
+		if _, err := bus.Push(context.Background(), EventInput{Stream: "signals", Body: "none"}); err != nil {
+			t.Fatalf("%s: push: %v", name, err)
+		}
+
+		listed, err := bus.List(context.Background(), "signals", ListOptions{Namespace: "acme"})
+		if err != nil || len(listed) != 1 || listed[0].ID != tagged.ID {
+			t.Fatalf("%s: expected only acme's event, got %+v, %v", name, listed, err)
+		}
+		if all, _ := bus.List(context.Background(), "signals", ListOptions{}); len(all) != 3 {
+			t.Fatalf("%s: expected every namespace listed without one, got %d", name, len(all))
+		}
+	}
+}
+
 func TestBusScopesAndSubscribe(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -167,3 +198,47 @@ func TestBusPushWithSourceIDPreReads(t *testing.T) {
 		t.Fatalf("expected evt.Read=false for agent-2")
 	}
 }
+
+func TestBusTopicsAreIsolatedAndEphemeral(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := NewBus(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subA := bus.SubscribeTopic(ctx, "ui:a")
+	subB := bus.SubscribeTopic(ctx, "ui:b")
+	streamSub := bus.Subscribe(ctx, nil)
+	if !bus.HasTopicSubscribers("ui:a") || bus.HasTopicSubscribers("ui:c") {
+		t.Fatalf("unexpected topic subscriber state")
+	}
+
+	bus.Publish("ui:a", Event{ID: "e1", Body: "hello"})
+	select {
+	case evt := <-subA:
+		if evt.Stream != "ui:a" || evt.Body != "hello" {
+			t.Fatalf("unexpected topic event: %+v", evt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for topic event")
+	}
+	select {
+	case evt := <-subB:
+		t.Fatalf("other topic received %+v", evt)
+	case evt := <-streamSub:
+		t.Fatalf("stream subscriber received topic event %+v", evt)
+	default:
+	}
+	if stats := bus.Stats(); stats.Topics != 2 || stats.Subscribers != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	items, err := bus.List(context.Background(), "ui:a", ListOptions{})
+	if err != nil {
+		t.Fatalf("list topic: %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected topic events not to be stored, got %d", len(items))
+	}
+}
@@ -0,0 +1,99 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetCursor returns the stored position of consumer in stream. A consumer
+// without a stored position gets a zero cursor with an empty EventID.
+func (b *Bus) GetCursor(ctx context.Context, stream, consumer string) (Cursor, error) {
+	stream = strings.TrimSpace(stream)
+	consumer = strings.TrimSpace(consumer)
+	if stream == "" {
+		return Cursor{}, fmt.Errorf("stream is required")
+	}
+	if consumer == "" {
+		return Cursor{}, fmt.Errorf("consumer is required")
+	}
+	if b.mem != nil {
+		return b.mem.getCursor(stream, consumer), nil
+	}
+	cursor := Cursor{Stream: stream, Consumer: consumer}
+	var updatedAtStr string
+	err := b.db.QueryRowContext(ctx, `SELECT event_id, updated_at FROM stream_cursors WHERE stream = ? AND consumer = ?`, stream, consumer).Scan(&cursor.EventID, &updatedAtStr)
+	if err == sql.ErrNoRows {
+		return cursor, nil
+	}
+	if err != nil {
+		return Cursor{}, fmt.Errorf("load cursor: %w", err)
+	}
+	cursor.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAtStr)
+	return cursor, nil
+}
+
+// SetCursor moves the position of consumer in stream to eventID. An empty
+// eventID clears the cursor so the consumer starts from the beginning.
+func (b *Bus) SetCursor(ctx context.Context, stream, consumer, eventID string) (Cursor, error) {
+	stream = strings.TrimSpace(stream)
+	consumer = strings.TrimSpace(consumer)
+	eventID = strings.TrimSpace(eventID)
+	if stream == "" {
+		return Cursor{}, fmt.Errorf("stream is required")
+	}
+	if consumer == "" {
+		return Cursor{}, fmt.Errorf("consumer is required")
+	}
+	if b.mem != nil {
+		cursor := Cursor{Stream: stream, Consumer: consumer}
+		if eventID != "" {
+			cursor.EventID, cursor.UpdatedAt = eventID, b.now()
+		}
+		if err := b.mem.setCursor(cursor); err != nil {
+			return Cursor{}, err
+		}
+		return cursor, nil
+	}
+	if eventID == "" {
+		if err := execWithRetry(ctx, b.db, `DELETE FROM stream_cursors WHERE stream = ? AND consumer = ?`, stream, consumer); err != nil {
+			return Cursor{}, fmt.Errorf("clear cursor: %w", err)
+		}
+		return Cursor{Stream: stream, Consumer: consumer}, nil
+	}
+	if _, err := b.eventCreatedAt(ctx, stream, eventID); err != nil {
+		return Cursor{}, err
+	}
+	updatedAt := b.now()
+	if err := execWithRetry(ctx, b.db, `
+		INSERT INTO stream_cursors (stream, consumer, event_id, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(stream, consumer) DO UPDATE SET event_id = excluded.event_id, updated_at = excluded.updated_at
+	`, stream, consumer, eventID, updatedAt.Format(time.RFC3339Nano)); err != nil {
+		return Cursor{}, fmt.Errorf("store cursor: %w", err)
+	}
+	return Cursor{Stream: stream, Consumer: consumer, EventID: eventID, UpdatedAt: updatedAt}, nil
+}
+
+func (b *Bus) eventCreatedAt(ctx context.Context, stream, id string) (string, error) {
+	var createdAt string
+	err := b.eachDB(ctx, "", []string{id}, func(db *sql.DB, _ []string) ([]string, error) {
+		err := db.QueryRowContext(ctx, `SELECT created_at FROM events WHERE stream = ? AND id = ?`, stream, id).Scan(&createdAt)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load event: %w", err)
+		}
+		return []string{id}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if createdAt == "" {
+		return "", fmt.Errorf("event %s not found in stream %s", id, stream)
+	}
+	return createdAt, nil
+}
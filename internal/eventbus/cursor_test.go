@@ -0,0 +1,66 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestBusCursorListAfter(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := NewBus(db)
+	ctx := context.Background()
+
+	var ids []string
+	for _, body := range []string{"one", "two", "three"} {
+		evt, err := bus.Push(ctx, EventInput{Stream: "errors", Body: body})
+		if err != nil {
+			t.Fatalf("push %s: %v", body, err)
+		}
+		ids = append(ids, evt.ID)
+	}
+
+	cursor, err := bus.GetCursor(ctx, "errors", "poller")
+	if err != nil {
+		t.Fatalf("get cursor: %v", err)
+	}
+	if cursor.EventID != "" {
+		t.Fatalf("expected empty cursor, got %q", cursor.EventID)
+	}
+
+	if _, err := bus.SetCursor(ctx, "errors", "poller", ids[0]); err != nil {
+		t.Fatalf("set cursor: %v", err)
+	}
+	cursor, err = bus.GetCursor(ctx, "errors", "poller")
+	if err != nil {
+		t.Fatalf("get cursor after set: %v", err)
+	}
+	if cursor.EventID != ids[0] {
+		t.Fatalf("expected cursor %s, got %s", ids[0], cursor.EventID)
+	}
+
+	items, err := bus.List(ctx, "errors", ListOptions{After: cursor.EventID})
+	if err != nil {
+		t.Fatalf("list after: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != ids[1] || items[1].ID != ids[2] {
+		t.Fatalf("expected events after cursor in order, got %#v", items)
+	}
+
+	if _, err := bus.SetCursor(ctx, "errors", "poller", "missing"); err == nil {
+		t.Fatalf("expected error for unknown event")
+	}
+	if _, err := bus.SetCursor(ctx, "errors", "poller", ""); err != nil {
+		t.Fatalf("clear cursor: %v", err)
+	}
+	cursor, err = bus.GetCursor(ctx, "errors", "poller")
+	if err != nil {
+		t.Fatalf("get cursor after clear: %v", err)
+	}
+	if cursor.EventID != "" {
+		t.Fatalf("expected cleared cursor, got %q", cursor.EventID)
+	}
+}
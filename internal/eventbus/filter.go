@@ -0,0 +1,122 @@
+package eventbus
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// FieldFilter matches events whose metadata or payload JSON has Values at
+// Path. Multiple values match any of them.
+type FieldFilter struct {
+	Column string // "metadata" or "payload"
+	Path   []string
+	Values []string
+}
+
+// ParseFieldFilter parses a query key such as "payload.result.status". ok is
+// false when the key does not address a filterable column. Numeric path
+// segments index into arrays.
+func ParseFieldFilter(key string, values []string) (FieldFilter, bool, error) {
+	column, rest, found := strings.Cut(key, ".")
+	if column != "metadata" && column != "payload" {
+		return FieldFilter{}, false, nil
+	}
+	if !found || rest == "" {
+		return FieldFilter{}, true, fmt.Errorf("%s filter requires a field path", column)
+	}
+	path := strings.Split(rest, ".")
+	for _, segment := range path {
+		if !validPathSegment(segment) {
+			return FieldFilter{}, true, fmt.Errorf("invalid field path %q", key)
+		}
+	}
+	if len(values) == 0 {
+		return FieldFilter{}, true, fmt.Errorf("%s filter requires a value", key)
+	}
+	return FieldFilter{Column: column, Path: path, Values: values}, true, nil
+}
+
+// fieldFilters returns Fields plus the namespace filter, if any.
+func (o ListOptions) fieldFilters() []FieldFilter {
+	if o.Namespace == "" {
+		return o.Fields
+	}
+	return append(slices.Clip(o.Fields), FieldFilter{Column: "metadata", Path: []string{schema.MetaNamespace}, Values: []string{o.Namespace}})
+}
+
+// InNamespace reports whether evt belongs to namespace. Every event belongs
+// to the empty namespace.
+func InNamespace(evt Event, namespace string) bool {
+	return namespace == "" || schema.GetMetaString(evt.Metadata, schema.MetaNamespace) == namespace
+}
+
+func validPathSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// where renders the filter. Segments are validated, so the path is safe to
+// inline; inlining lets the database use the expression indexes on events.
+func (f FieldFilter) where(dialect state.Dialect) (string, []any, error) {
+	if err := f.validate(); err != nil {
+		return "", nil, err
+	}
+	expr := dialect.JSONValue(f.Column, f.Path...)
+	var clauses []string
+	var args []any
+	for _, value := range f.Values {
+		// JSON numbers and booleans come back typed from SQLite's
+		// json_extract, so compare against both the text and the typed
+		// form there.
+		clauses = append(clauses, expr+" = ?")
+		args = append(args, value)
+		if typed, ok := typedFilterValue(value); ok && dialect.Driver() == state.DriverSQLite {
+			clauses = append(clauses, expr+" = ?")
+			args = append(args, typed)
+		}
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+}
+
+func (f FieldFilter) validate() error {
+	if f.Column != "metadata" && f.Column != "payload" {
+		return fmt.Errorf("invalid filter column %q", f.Column)
+	}
+	for _, segment := range f.Path {
+		if !validPathSegment(segment) {
+			return fmt.Errorf("invalid field path %q", strings.Join(f.Path, "."))
+		}
+	}
+	if len(f.Path) == 0 || len(f.Values) == 0 {
+		return fmt.Errorf("field filter requires a path and a value")
+	}
+	return nil
+}
+
+func typedFilterValue(value string) (any, bool) {
+	switch value {
+	case "true":
+		return 1, true
+	case "false":
+		return 0, true
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
@@ -0,0 +1,143 @@
+package eventbus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestBusListFiltersByJSONFields(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := NewBus(db)
+	ctx := context.Background()
+
+	inputs := []EventInput{
+		{Stream: "errors", Subject: "a", Body: "a", Metadata: map[string]any{"task_id": "t1"}, Payload: map[string]any{"result": map[string]any{"status": "failed", "code": 2}}},
+		{Stream: "errors", Subject: "b", Body: "b", Metadata: map[string]any{"task_id": "t2"}, Payload: map[string]any{"result": map[string]any{"status": "ok", "code": 0}}},
+		{Stream: "errors", Subject: "c", Body: "c", Metadata: map[string]any{"task_id": "t1", "retry": true}, Payload: map[string]any{"items": []any{"x", "y"}}},
+	}
+	for _, in := range inputs {
+		if _, err := bus.Push(ctx, in); err != nil {
+			t.Fatalf("push %s: %v", in.Body, err)
+		}
+	}
+
+	list := func(filters ...string) string {
+		t.Helper()
+		var fields []FieldFilter
+		for _, f := range filters {
+			key, value, _ := strings.Cut(f, "=")
+			filter, ok, err := ParseFieldFilter(key, []string{value})
+			if err != nil || !ok {
+				t.Fatalf("parse %s: ok=%v err=%v", f, ok, err)
+			}
+			fields = append(fields, filter)
+		}
+		summaries, err := bus.List(ctx, "errors", ListOptions{Order: "fifo", Fields: fields})
+		if err != nil {
+			t.Fatalf("list %v: %v", filters, err)
+		}
+		var subjects []string
+		for _, s := range summaries {
+			subjects = append(subjects, s.Subject)
+		}
+		return strings.Join(subjects, ",")
+	}
+
+	cases := []struct {
+		filters []string
+		want    string
+	}{
+		{[]string{"metadata.task_id=t1"}, "a,c"},
+		{[]string{"metadata.task_id=t1", "payload.result.status=failed"}, "a"},
+		{[]string{"payload.result.code=0"}, "b"},
+		{[]string{"metadata.retry=true"}, "c"},
+		{[]string{"payload.items.1=y"}, "c"},
+		{[]string{"payload.items.1=x"}, ""},
+	}
+	for _, tc := range cases {
+		if got := list(tc.filters...); got != tc.want {
+			t.Fatalf("filters %v: got %q, want %q", tc.filters, got, tc.want)
+		}
+	}
+}
+
+func TestParseFieldFilterRejectsUnsafePaths(t *testing.T) {
+	if _, ok, _ := ParseFieldFilter("limit", []string{"5"}); ok {
+		t.Fatalf("expected non-field key to be ignored")
+	}
+	for _, key := range []string{"payload", "payload.", "metadata.a..b", "payload.a'b", "metadata.$x"} {
+		if _, _, err := ParseFieldFilter(key, []string{"v"}); err == nil {
+			t.Fatalf("expected error for %q", key)
+		}
+	}
+}
+
+func TestBusCountFiltersAndTimeWindow(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	bus := NewBus(db, WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	for i, kind := range []string{"a", "b", "a", "a"} {
+		if _, err := bus.Push(ctx, EventInput{Stream: "history", ScopeType: "task", ScopeID: "agent-1", Body: kind, Payload: map[string]any{"type": kind}}); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	agent := ListOptions{ScopeType: "task", ScopeID: "agent-1"}
+	onlyA := ListOptions{ScopeType: "task", ScopeID: "agent-1", Fields: []FieldFilter{{Column: "payload", Path: []string{"type"}, Values: []string{"a"}}}}
+	cases := []struct {
+		opts        ListOptions
+		since, till time.Time
+		want        int
+	}{
+		{agent, time.Time{}, time.Time{}, 4},
+		{ListOptions{}, time.Time{}, time.Time{}, 0},
+		{onlyA, time.Time{}, time.Time{}, 3},
+		{onlyA, from, time.Time{}, 2},
+		{onlyA, from, from.Add(2 * time.Hour), 1},
+		{ListOptions{ScopeType: "task", ScopeID: "agent-2"}, time.Time{}, time.Time{}, 0},
+	}
+	for i, tc := range cases {
+		got, err := bus.Count(ctx, "history", tc.opts, tc.since, tc.till)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got != tc.want {
+			t.Fatalf("case %d: got %d, want %d", i, got, tc.want)
+		}
+	}
+}
+
+func TestFieldFilterWhereByDialect(t *testing.T) {
+	filter, _, err := ParseFieldFilter("payload.result.code", []string{"2"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	clause, args, err := filter.where(state.SQLite)
+	if err != nil {
+		t.Fatalf("where: %v", err)
+	}
+	if clause != "(json_extract(payload, '$.result.code') = ? OR json_extract(payload, '$.result.code') = ?)" || len(args) != 2 {
+		t.Fatalf("unexpected sqlite clause %s %v", clause, args)
+	}
+	clause, args, err = filter.where(state.Postgres)
+	if err != nil {
+		t.Fatalf("where: %v", err)
+	}
+	// Postgres extracts JSON as text, so only the text form is compared.
+	if clause != "((payload::jsonb #>> '{result,code}') = ?)" || len(args) != 1 || args[0] != "2" {
+		t.Fatalf("unexpected postgres clause %s %v", clause, args)
+	}
+}
@@ -0,0 +1,394 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewMemoryBus returns a bus that keeps events, cursors and sequences in
+// memory instead of a database. Scopes, read state, filters and
+// subscriptions behave as with NewBus; everything is lost when the bus is
+// dropped. It suits tests and embedded uses that need no history across
+// restarts. WithShards and WithDialect have no effect on it.
+func NewMemoryBus(opts ...Option) *Bus {
+	b := NewBus(nil, opts...)
+	b.shards = nil
+	b.mem = &memoryStore{
+		streams:   map[string][]*memoryEvent{},
+		byID:      map[string]*memoryEvent{},
+		cursors:   map[cursorKey]Cursor{},
+		sequences: map[string]int64{},
+	}
+	return b
+}
+
+// memoryStore holds a memory bus's events in push order per stream.
+// Metadata and payloads are kept JSON-encoded, so reads return fresh maps
+// decoded the same way as from the database.
+type memoryStore struct {
+	mu        sync.Mutex
+	seq       int64
+	streams   map[string][]*memoryEvent
+	byID      map[string]*memoryEvent
+	cursors   map[cursorKey]Cursor
+	sequences map[string]int64
+}
+
+type memoryEvent struct {
+	seq       int64
+	id        string
+	stream    string
+	scopeType string
+	scopeID   string
+	subject   string
+	body      string
+	metadata  string
+	payload   string
+	createdAt time.Time
+	readBy    []string
+}
+
+type cursorKey struct {
+	stream, consumer string
+}
+
+func (m *memoryStore) push(e Event, metadataJSON, payloadJSON string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	stored := &memoryEvent{
+		seq:       m.seq,
+		id:        e.ID,
+		stream:    e.Stream,
+		scopeType: e.ScopeType,
+		scopeID:   e.ScopeID,
+		subject:   e.Subject,
+		body:      e.Body,
+		metadata:  metadataJSON,
+		payload:   payloadJSON,
+		createdAt: e.CreatedAt,
+		readBy:    append([]string(nil), e.ReadBy...),
+	}
+	m.streams[e.Stream] = append(m.streams[e.Stream], stored)
+	m.byID[e.ID] = stored
+}
+
+// get returns the event with id in stream. m.mu must be held.
+func (m *memoryStore) get(stream, id string) (*memoryEvent, bool) {
+	e, ok := m.byID[id]
+	if !ok || e.stream != stream {
+		return nil, false
+	}
+	return e, true
+}
+
+// match returns the events of stream matching opts in push order. m.mu must
+// be held.
+func (m *memoryStore) match(stream string, opts ListOptions, since, until time.Time) ([]*memoryEvent, error) {
+	var after *memoryEvent
+	if id := strings.TrimSpace(opts.After); id != "" {
+		e, ok := m.get(stream, id)
+		if !ok {
+			return nil, fmt.Errorf("event %s not found in stream %s", id, stream)
+		}
+		after = e
+	}
+	var out []*memoryEvent
+	for _, e := range m.streams[stream] {
+		if !opts.includesScope(e.scopeType, e.scopeID) {
+			continue
+		}
+		if after != nil && !e.createdAt.After(after.createdAt) && !(e.createdAt.Equal(after.createdAt) && e.id > after.id) {
+			continue
+		}
+		if !since.IsZero() && e.createdAt.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !e.createdAt.Before(until) {
+			continue
+		}
+		ok, err := e.matchesFields(opts.fieldFilters())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// matchesFields mirrors FieldFilter.where on SQLite: strings compare as
+// text, numbers and booleans (as 1 and 0) compare as numbers, and objects
+// and arrays compare as their JSON text.
+func (e *memoryEvent) matchesFields(filters []FieldFilter) (bool, error) {
+	for _, f := range filters {
+		if err := f.validate(); err != nil {
+			return false, err
+		}
+		raw := e.metadata
+		if f.Column == "payload" {
+			raw = e.payload
+		}
+		value, ok := jsonPathValue(raw, f.Path)
+		if !ok {
+			return false, nil
+		}
+		matched := false
+		for _, want := range f.Values {
+			if value.matches(want) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// extracted is a JSON value as SQLite's json_extract returns it.
+type extracted struct {
+	text    string
+	number  float64
+	numeric bool
+}
+
+func (v extracted) matches(want string) bool {
+	if !v.numeric {
+		return v.text == want
+	}
+	typed, ok := typedFilterValue(want)
+	if !ok {
+		return false
+	}
+	switch n := typed.(type) {
+	case int:
+		return v.number == float64(n)
+	case int64:
+		return v.number == float64(n)
+	case float64:
+		return v.number == n
+	}
+	return false
+}
+
+func jsonPathValue(raw string, path []string) (extracted, bool) {
+	if raw == "" {
+		return extracted{}, false
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var cur any
+	if err := dec.Decode(&cur); err != nil {
+		return extracted{}, false
+	}
+	for _, segment := range path {
+		switch node := cur.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return extracted{}, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return extracted{}, false
+			}
+			cur = node[i]
+		default:
+			return extracted{}, false
+		}
+	}
+	switch v := cur.(type) {
+	case nil:
+		return extracted{}, false
+	case string:
+		return extracted{text: v}, true
+	case bool:
+		if v {
+			return extracted{number: 1, numeric: true}, true
+		}
+		return extracted{number: 0, numeric: true}, true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return extracted{}, false
+		}
+		return extracted{number: f, numeric: true}, true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return extracted{}, false
+		}
+		return extracted{text: string(data)}, true
+	}
+}
+
+func (e *memoryEvent) event(reader string) Event {
+	// Never nil, like read_by decoded from the database.
+	readBy := make([]string, len(e.readBy))
+	copy(readBy, e.readBy)
+	return Event{
+		ID:        e.id,
+		Stream:    e.stream,
+		ScopeType: e.scopeType,
+		ScopeID:   e.scopeID,
+		Subject:   e.subject,
+		Body:      e.body,
+		Metadata:  decodeJSONMap(e.metadata),
+		Payload:   decodeJSONMap(e.payload),
+		CreatedAt: e.createdAt,
+		Read:      readerInList(reader, readBy),
+		ReadBy:    readBy,
+	}
+}
+
+func (m *memoryStore) list(stream string, opts ListOptions, limit int, fifo bool) ([]EventSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	matched, err := m.match(stream, opts, time.Time{}, opts.Before)
+	if err != nil {
+		return nil, err
+	}
+	if fifo || strings.TrimSpace(opts.After) != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if !matched[i].createdAt.Equal(matched[j].createdAt) {
+				return matched[i].createdAt.Before(matched[j].createdAt)
+			}
+			return matched[i].id < matched[j].id
+		})
+	} else {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if !matched[i].createdAt.Equal(matched[j].createdAt) {
+				return matched[i].createdAt.After(matched[j].createdAt)
+			}
+			return matched[i].seq > matched[j].seq
+		})
+	}
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	var out []EventSummary
+	for _, e := range matched {
+		out = append(out, EventSummary{
+			ID:        e.id,
+			Stream:    e.stream,
+			Subject:   e.subject,
+			CreatedAt: e.createdAt,
+			Read:      readerInList(opts.Reader, e.readBy),
+		})
+	}
+	return out, nil
+}
+
+func (m *memoryStore) count(stream string, opts ListOptions, since, until time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	opts.After = ""
+	matched, err := m.match(stream, opts, since, until)
+	return len(matched), err
+}
+
+func (m *memoryStore) read(stream string, ids []string, reader string) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found []*memoryEvent
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if e, ok := m.get(stream, id); ok && !seen[id] {
+			seen[id] = true
+			found = append(found, e)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+	var out []Event
+	for _, e := range found {
+		out = append(out, e.event(reader))
+	}
+	return out
+}
+
+func (m *memoryStore) delete(stream string, ids []string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	drop := map[string]bool{}
+	for _, id := range ids {
+		if _, ok := m.get(stream, id); ok {
+			drop[id] = true
+			delete(m.byID, id)
+		}
+	}
+	if len(drop) == 0 {
+		return 0
+	}
+	kept := m.streams[stream][:0]
+	for _, e := range m.streams[stream] {
+		if !drop[e.id] {
+			kept = append(kept, e)
+		}
+	}
+	m.streams[stream] = kept
+	return len(drop)
+}
+
+// setRead adds reader to or removes it from the read state of ids.
+func (m *memoryStore) setRead(stream string, ids []string, reader string, read bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		e, ok := m.get(stream, id)
+		if !ok || readerInList(reader, e.readBy) == read {
+			continue
+		}
+		if read {
+			e.readBy = append(e.readBy, reader)
+			continue
+		}
+		kept := make([]string, 0, len(e.readBy))
+		for _, r := range e.readBy {
+			if r != reader {
+				kept = append(kept, r)
+			}
+		}
+		e.readBy = kept
+	}
+}
+
+func (m *memoryStore) getCursor(stream, consumer string) Cursor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cursor, ok := m.cursors[cursorKey{stream, consumer}]; ok {
+		return cursor
+	}
+	return Cursor{Stream: stream, Consumer: consumer}
+}
+
+func (m *memoryStore) setCursor(cursor Cursor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := cursorKey{cursor.Stream, cursor.Consumer}
+	if cursor.EventID == "" {
+		delete(m.cursors, key)
+		return nil
+	}
+	if _, ok := m.get(cursor.Stream, cursor.EventID); !ok {
+		return fmt.Errorf("event %s not found in stream %s", cursor.EventID, cursor.Stream)
+	}
+	m.cursors[key] = cursor
+	return nil
+}
+
+func (m *memoryStore) nextSequence(key string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sequences[key]++
+	return m.sequences[key]
+}
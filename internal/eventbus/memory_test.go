@@ -0,0 +1,152 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+// TestMemoryBusMatchesDatabaseBus runs the same operations against a
+// database bus and a memory bus and expects the same results.
+func TestMemoryBusMatchesDatabaseBus(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	newBus := func(memory bool) *Bus {
+		now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+		n := 0
+		opts := []Option{
+			WithClock(func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			}),
+			WithIDGenerator(func() string {
+				n++
+				return fmt.Sprintf("evt-%03d", n)
+			}),
+		}
+		if memory {
+			return NewMemoryBus(opts...)
+		}
+		return NewBus(db, opts...)
+	}
+	run := func(bus *Bus) []any {
+		ctx := context.Background()
+		var out []any
+		record := func(v any, err error) {
+			if err != nil {
+				out = append(out, "error: "+err.Error())
+				return
+			}
+			out = append(out, v)
+		}
+		inputs := []EventInput{
+			{Stream: "task_input", ScopeType: "task", ScopeID: "agent-1", Subject: "a", Body: "a", Metadata: map[string]any{"kind": "message", "n": 2}},
+			{Stream: "task_input", ScopeType: "task", ScopeID: "agent-2", Body: "b", Metadata: map[string]any{"kind": "wake"}},
+			{Stream: "task_input", Body: "c", Payload: map[string]any{"ok": true, "items": []any{"x", map[string]any{"y": 1.5}}}},
+			{Stream: "task_input", ScopeType: "topic", ScopeID: "news", Body: "d", SourceID: "agent-1"},
+			{Stream: "errors", Body: "e"},
+		}
+		var ids []string
+		for _, in := range inputs {
+			evt, err := bus.Push(ctx, in)
+			record(evt.ReadBy, err)
+			ids = append(ids, evt.ID)
+		}
+		field := func(key, value string) FieldFilter {
+			f, _, _ := ParseFieldFilter(key, []string{value})
+			return f
+		}
+		for _, opts := range []ListOptions{
+			{Reader: "agent-1"},
+			{Reader: "agent-1", Order: "fifo"},
+			{Reader: "agent-1", Topics: []string{"news"}, Order: "fifo"},
+			{ScopeType: "task", Order: "fifo"},
+			{ScopeType: "task", ScopeID: "agent-2"},
+			{Reader: "agent-1", Limit: 1},
+			{AllScopes: true, Order: "fifo"},
+			{AllScopes: true, Reader: "agent-1", Topics: []string{"news"}},
+			{Reader: "agent-1", Topics: []string{"news"}, After: ids[0]},
+			{AllScopes: true, Order: "fifo", Before: time.Date(2026, 3, 2, 9, 0, 3, 0, time.UTC)},
+			{ScopeType: "task", Fields: []FieldFilter{field("metadata.n", "2")}},
+			{ScopeType: "task", Fields: []FieldFilter{field("metadata.kind", "wake")}},
+			{Fields: []FieldFilter{field("payload.ok", "true")}},
+			{Fields: []FieldFilter{field("payload.items.0", "x")}},
+			{Fields: []FieldFilter{field("payload.items.1.y", "1.5")}},
+			{Fields: []FieldFilter{field("payload.missing", "x")}},
+			{After: "nope"},
+		} {
+			record(bus.List(ctx, "task_input", opts))
+			record(bus.Count(ctx, "task_input", opts, time.Time{}, time.Time{}))
+		}
+		record(bus.Count(ctx, "task_input", ListOptions{ScopeType: "task"},
+			time.Date(2026, 3, 2, 9, 0, 2, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 3, 0, time.UTC)))
+
+		record(bus.Read(ctx, "task_input", []string{ids[1], ids[0], "nope", ids[4]}, "agent-1"))
+		record(nil, bus.Ack(ctx, "task_input", []string{ids[0], ids[1]}, "agent-1"))
+		record(bus.List(ctx, "task_input", ListOptions{Reader: "agent-1", Order: "fifo"}))
+		record(nil, bus.MarkUnread(ctx, "task_input", []string{ids[0]}, "agent-1"))
+		record(bus.Read(ctx, "task_input", []string{ids[0], ids[1]}, "agent-1"))
+		record(bus.AckAll(ctx, "task_input", "agent-3", BulkFilter{ScopeType: "task", Before: time.Date(2026, 3, 2, 9, 0, 2, 0, time.UTC)}))
+		record(bus.MarkUnreadAll(ctx, "task_input", "agent-1", BulkFilter{}))
+
+		record(bus.Delete(ctx, "task_input", []string{ids[2], ids[4], "nope"}))
+		record(bus.List(ctx, "task_input", ListOptions{AllScopes: true, Order: "fifo"}))
+
+		record(bus.GetCursor(ctx, "task_input", "mirror"))
+		record(bus.SetCursor(ctx, "task_input", "mirror", ids[1]))
+		record(bus.GetCursor(ctx, "task_input", "mirror"))
+		record(bus.SetCursor(ctx, "task_input", "mirror", ids[2]))
+		record(bus.SetCursor(ctx, "task_input", "mirror", ""))
+		record(bus.GetCursor(ctx, "task_input", "mirror"))
+		for i := 0; i < 2; i++ {
+			record(bus.NextSequence(ctx, "k"))
+		}
+		return out
+	}
+
+	want := run(newBus(false))
+	got := run(newBus(true))
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("result %d differs:\nmemory:   %#v\ndatabase: %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMemoryBusSubscribe(t *testing.T) {
+	bus := NewMemoryBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := bus.Subscribe(ctx, []string{"errors"})
+	if _, err := bus.Push(ctx, EventInput{Stream: "other", Body: "skip"}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	pushed, err := bus.Push(ctx, EventInput{Stream: "errors", Body: "boom", Metadata: map[string]any{"n": 1}})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	select {
+	case evt := <-ch:
+		if evt.ID != pushed.ID {
+			t.Fatalf("expected %s, got %s", pushed.ID, evt.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the event to be delivered")
+	}
+
+	// Reads decode fresh maps, so callers cannot change stored events.
+	events, _ := bus.Read(ctx, "errors", []string{pushed.ID}, "")
+	events[0].Metadata["n"] = 2
+	events, _ = bus.Read(ctx, "errors", []string{pushed.ID}, "")
+	if events[0].Metadata["n"] != float64(1) {
+		t.Fatalf("expected stored metadata unchanged, got %v", events[0].Metadata)
+	}
+}
@@ -0,0 +1,50 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// MessageSequenceKey names the sequence used for messages from source to
+// target.
+func MessageSequenceKey(source, target string) string {
+	return "message:" + strings.TrimSpace(source) + "->" + strings.TrimSpace(target)
+}
+
+// NextSequence atomically increments the named sequence and returns the new
+// value. The first call for a key returns 1.
+func (b *Bus) NextSequence(ctx context.Context, key string) (int64, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return 0, fmt.Errorf("sequence key is required")
+	}
+	if b.mem != nil {
+		return b.mem.nextSequence(key), nil
+	}
+	var value int64
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		err = b.db.QueryRowContext(ctx, `
+			INSERT INTO sequences (key, value) VALUES (?, 1)
+			ON CONFLICT(key) DO UPDATE SET value = sequences.value + 1
+			RETURNING value
+		`, key).Scan(&value)
+		if err == nil {
+			return value, nil
+		}
+		if !isBusyError(err) {
+			break
+		}
+		state.DefaultTelemetry.RecordBusyRetry()
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("next sequence: %w", err)
+		case <-time.After(time.Duration(25*(attempt+1)) * time.Millisecond):
+		}
+	}
+	return 0, fmt.Errorf("next sequence: %w", err)
+}
@@ -0,0 +1,56 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestBusNextSequenceIsPerKeyAndConcurrent(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := NewBus(db)
+	ctx := context.Background()
+	key := MessageSequenceKey("agent-a", "agent-b")
+
+	var wg sync.WaitGroup
+	seen := make(chan int64, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq, err := bus.NextSequence(ctx, key)
+			if err != nil {
+				t.Errorf("next sequence: %v", err)
+				return
+			}
+			seen <- seq
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	got := map[int64]bool{}
+	for seq := range seen {
+		if got[seq] {
+			t.Fatalf("duplicate sequence %d", seq)
+		}
+		got[seq] = true
+	}
+	for i := int64(1); i <= 20; i++ {
+		if !got[i] {
+			t.Fatalf("missing sequence %d in %v", i, got)
+		}
+	}
+
+	other, err := bus.NextSequence(ctx, MessageSequenceKey("agent-c", "agent-b"))
+	if err != nil {
+		t.Fatalf("next sequence: %v", err)
+	}
+	if other != 1 {
+		t.Fatalf("expected independent sequence per sender, got %d", other)
+	}
+}
@@ -0,0 +1,276 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ShardRouter places an agent's task-scoped events in a database of its
+// own. state.Shards implements it.
+type ShardRouter interface {
+	// ShardFor returns the agent's shard, creating it if needed.
+	ShardFor(ctx context.Context, agentID string) (*sql.DB, error)
+	// Lookup returns the agent's shard if it exists.
+	Lookup(ctx context.Context, agentID string) (*sql.DB, bool, error)
+	All(ctx context.Context) ([]*sql.DB, error)
+}
+
+// WithShards stores task-scoped events in the agent's shard. Global and
+// topic events, cursors and sequences stay in the main database, which is
+// also still read so events from before sharding remain visible.
+func WithShards(router ShardRouter) Option {
+	return func(b *Bus) {
+		b.shards = router
+	}
+}
+
+// pushDB returns the database an event with this scope is written to.
+func (b *Bus) pushDB(ctx context.Context, scopeType, scopeID string) (*sql.DB, error) {
+	if b.shards == nil || scopeType != "task" || scopeID == "*" {
+		return b.db, nil
+	}
+	return b.shards.ShardFor(ctx, scopeID)
+}
+
+// listDBs returns the databases that can hold events matching opts.
+func (b *Bus) listDBs(ctx context.Context, opts ListOptions) ([]*sql.DB, error) {
+	if b.shards == nil {
+		return []*sql.DB{b.db}, nil
+	}
+	owner := strings.TrimSpace(opts.Reader)
+	switch opts.ScopeType {
+	case "":
+	case "task":
+		if strings.TrimSpace(opts.ScopeID) == "" {
+			return b.allDBs(ctx)
+		}
+		owner = strings.TrimSpace(opts.ScopeID)
+	default:
+		return []*sql.DB{b.db}, nil
+	}
+	if owner == "" || owner == "*" {
+		return []*sql.DB{b.db}, nil
+	}
+	// Readers without a shard, such as an agent that never received a
+	// task-scoped event, only have events in the main database.
+	shard, ok, err := b.shards.Lookup(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []*sql.DB{b.db}, nil
+	}
+	return []*sql.DB{b.db, shard}, nil
+}
+
+// readDBs returns the databases to look up events by ID in, most likely
+// first: the main database and the reader's shard. Events not found there
+// are looked up in the remaining shards.
+func (b *Bus) readDBs(ctx context.Context, reader string) ([]*sql.DB, error) {
+	return b.listDBs(ctx, ListOptions{Reader: reader})
+}
+
+// allDBs returns the main database followed by every shard.
+func (b *Bus) allDBs(ctx context.Context) ([]*sql.DB, error) {
+	if b.shards == nil {
+		return []*sql.DB{b.db}, nil
+	}
+	shards, err := b.shards.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*sql.DB{b.db}, shards...), nil
+}
+
+// eachDB calls fn with each database that may hold ids, most likely first,
+// until fn reports every ID found.
+func (b *Bus) eachDB(ctx context.Context, reader string, ids []string, fn func(db *sql.DB, ids []string) ([]string, error)) error {
+	first, err := b.readDBs(ctx, reader)
+	if err != nil {
+		return err
+	}
+	remaining := append([]string(nil), ids...)
+	seen := map[*sql.DB]bool{}
+	visit := func(dbs []*sql.DB) error {
+		for _, db := range dbs {
+			if len(remaining) == 0 || seen[db] {
+				continue
+			}
+			seen[db] = true
+			found, err := fn(db, remaining)
+			if err != nil {
+				return err
+			}
+			remaining = withoutIDs(remaining, found)
+		}
+		return nil
+	}
+	if err := visit(first); err != nil {
+		return err
+	}
+	if len(remaining) == 0 || b.shards == nil {
+		return nil
+	}
+	all, err := b.allDBs(ctx)
+	if err != nil {
+		return err
+	}
+	return visit(all)
+}
+
+func withoutIDs(ids, found []string) []string {
+	if len(found) == 0 {
+		return ids
+	}
+	drop := map[string]bool{}
+	for _, id := range found {
+		drop[id] = true
+	}
+	out := ids[:0]
+	for _, id := range ids {
+		if !drop[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// mergeSummaries combines per-database results in list order and applies
+// the limit again.
+func mergeSummaries(parts [][]EventSummary, ascending bool, limit int) []EventSummary {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	var out []EventSummary
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt) == ascending
+		}
+		return (out[i].ID < out[j].ID) == ascending
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// MoveToShards moves task-scoped events from the main database into their
+// agents' shards, batch by batch, and returns how many moved. It is safe to
+// run again after an interruption.
+func MoveToShards(ctx context.Context, main *sql.DB, router ShardRouter) (int, error) {
+	moved := 0
+	for {
+		rows, err := main.QueryContext(ctx, `
+			SELECT id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by
+			FROM events WHERE scope_type = 'task' AND scope_id != '*' ORDER BY scope_id, id LIMIT ?
+		`, moveBatch)
+		if err != nil {
+			return moved, fmt.Errorf("list task events: %w", err)
+		}
+		batch, err := scanRawEvents(rows)
+		if err != nil {
+			return moved, err
+		}
+		if len(batch) == 0 {
+			return moved, nil
+		}
+		for _, e := range batch {
+			shard, err := router.ShardFor(ctx, e.scopeID)
+			if err != nil {
+				return moved, err
+			}
+			if err := copyRawEvent(ctx, shard, e); err != nil {
+				return moved, err
+			}
+			if _, err := main.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, e.id); err != nil {
+				return moved, fmt.Errorf("delete moved event: %w", err)
+			}
+			moved++
+		}
+	}
+}
+
+// MoveFromShards moves every event in the shards back into the main
+// database and returns how many moved. The emptied shard files are left
+// for the operator to delete.
+func MoveFromShards(ctx context.Context, main *sql.DB, router ShardRouter) (int, error) {
+	shards, err := router.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, shard := range shards {
+		for {
+			rows, err := shard.QueryContext(ctx, `
+				SELECT id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by
+				FROM events ORDER BY id LIMIT ?
+			`, moveBatch)
+			if err != nil {
+				return moved, fmt.Errorf("list shard events: %w", err)
+			}
+			batch, err := scanRawEvents(rows)
+			if err != nil {
+				return moved, err
+			}
+			if len(batch) == 0 {
+				break
+			}
+			for _, e := range batch {
+				if err := copyRawEvent(ctx, main, e); err != nil {
+					return moved, err
+				}
+				if _, err := shard.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, e.id); err != nil {
+					return moved, fmt.Errorf("delete moved event: %w", err)
+				}
+				moved++
+			}
+		}
+	}
+	return moved, nil
+}
+
+const moveBatch = 500
+
+// rawEvent is an events row copied as stored.
+type rawEvent struct {
+	id, stream, scopeType, scopeID string
+	subject                        sql.NullString
+	body                           string
+	metadata, payload              sql.NullString
+	createdAt                      string
+	readBy                         sql.NullString
+}
+
+func scanRawEvents(rows *sql.Rows) ([]rawEvent, error) {
+	defer rows.Close()
+	var out []rawEvent
+	for rows.Next() {
+		var e rawEvent
+		if err := rows.Scan(&e.id, &e.stream, &e.scopeType, &e.scopeID, &e.subject, &e.body, &e.metadata, &e.payload, &e.createdAt, &e.readBy); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events: %w", err)
+	}
+	return out, nil
+}
+
+// copyRawEvent inserts e into db unless an event with its ID is already
+// there, which happens when an earlier move was interrupted.
+func copyRawEvent(ctx context.Context, db *sql.DB, e rawEvent) error {
+	if err := execWithRetry(ctx, db, `
+		INSERT OR IGNORE INTO events (id, stream, scope_type, scope_id, subject, body, metadata, payload, created_at, read_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.id, e.stream, e.scopeType, e.scopeID, e.subject, e.body, e.metadata, e.payload, e.createdAt, e.readBy); err != nil {
+		return fmt.Errorf("copy event %s: %w", e.id, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestShardedBusRoutesTaskEventsPerAgent(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	shards, err := state.OpenShards(t.TempDir())
+	if err != nil {
+		t.Fatalf("open shards: %v", err)
+	}
+	defer shards.Close()
+
+	bus := NewBus(db, WithShards(shards))
+	ctx := context.Background()
+	push := func(scopeType, scopeID, body string) Event {
+		t.Helper()
+		evt, err := bus.Push(ctx, EventInput{Stream: "task_input", ScopeType: scopeType, ScopeID: scopeID, Body: body})
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		return evt
+	}
+	global := push("", "", "everyone")
+	forA := push("task", "agent-a", "for a")
+	forB := push("task", "agent-b", "for b")
+
+	ids, err := shards.AgentIDs()
+	if err != nil || len(ids) != 2 || ids[0] != "agent-a" || ids[1] != "agent-b" {
+		t.Fatalf("expected shards for both agents, got %v (%v)", ids, err)
+	}
+	var inMain int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&inMain); err != nil || inMain != 1 {
+		t.Fatalf("expected only the global event in the main db, got %d (%v)", inMain, err)
+	}
+
+	list, err := bus.List(ctx, "task_input", ListOptions{Reader: "agent-a", Order: "fifo"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != global.ID || list[1].ID != forA.ID {
+		t.Fatalf("expected global then agent-a events, got %+v", list)
+	}
+	if n, err := bus.Count(ctx, "task_input", ListOptions{ScopeType: "task"}, time.Time{}, time.Time{}); err != nil || n != 2 {
+		t.Fatalf("expected both task events across shards, got %d (%v)", n, err)
+	}
+
+	// An operator reading agent-b's event is not agent-b, so the event is
+	// found by searching the other shards.
+	if err := bus.Ack(ctx, "task_input", []string{forB.ID}, "operator"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	events, err := bus.Read(ctx, "task_input", []string{forB.ID, global.ID}, "operator")
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected both events, got %d (%v)", len(events), err)
+	}
+	for _, e := range events {
+		if e.ID == forB.ID && !e.Read {
+			t.Fatalf("expected agent-b's event acked by operator")
+		}
+	}
+}
+
+func TestMoveEventsBetweenStorageModes(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	shards, err := state.OpenShards(t.TempDir())
+	if err != nil {
+		t.Fatalf("open shards: %v", err)
+	}
+	defer shards.Close()
+	ctx := context.Background()
+
+	single := NewBus(db)
+	for _, scope := range []string{"agent-a", "agent-a", "agent-b"} {
+		if _, err := single.Push(ctx, EventInput{Stream: "history", ScopeType: "task", ScopeID: scope, Body: "entry"}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	if _, err := single.Push(ctx, EventInput{Stream: "signals", Body: "global"}); err != nil {
+		t.Fatalf("push global: %v", err)
+	}
+
+	moved, err := MoveToShards(ctx, db, shards)
+	if err != nil || moved != 3 {
+		t.Fatalf("expected 3 events moved to shards, got %d (%v)", moved, err)
+	}
+	shardA, err := shards.ShardFor(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("shard: %v", err)
+	}
+	var n int
+	if err := shardA.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("expected agent-a's two events in its shard, got %d (%v)", n, err)
+	}
+	sharded := NewBus(db, WithShards(shards))
+	if n, err := sharded.Count(ctx, "history", ListOptions{ScopeType: "task", ScopeID: "agent-a"}, time.Time{}, time.Time{}); err != nil || n != 2 {
+		t.Fatalf("expected agent-a history through the sharded bus, got %d (%v)", n, err)
+	}
+
+	moved, err = MoveFromShards(ctx, db, shards)
+	if err != nil || moved != 3 {
+		t.Fatalf("expected 3 events moved back, got %d (%v)", moved, err)
+	}
+	if n, err := single.Count(ctx, "history", ListOptions{ScopeType: "task"}, time.Time{}, time.Time{}); err != nil || n != 3 {
+		t.Fatalf("expected all history back in the main db, got %d (%v)", n, err)
+	}
+}
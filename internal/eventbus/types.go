@@ -41,4 +41,53 @@ type ListOptions struct {
 	Order     string
 	ScopeType string
 	ScopeID   string
+	After     string // event ID; only events after it are listed, oldest first
+	// Before lists only events created before it. Count takes its own
+	// range instead.
+	Before time.Time
+	Fields []FieldFilter
+	// Topics adds events scoped to these topics to the default global and
+	// reader scopes.
+	Topics []string
+	// AllScopes lists events of every scope when ScopeType is empty. Reader
+	// then only decides read state.
+	AllScopes bool
+	// Namespace lists only events tagged with that namespace; empty lists
+	// every namespace.
+	Namespace string
+}
+
+// MatchesScope reports whether an event with the given scope is listed
+// under o, for callers that filter live events the way List does.
+func (o ListOptions) MatchesScope(evt Event) bool {
+	return o.includesScope(evt.ScopeType, evt.ScopeID)
+}
+
+// includesScope mirrors buildScopeWhere.
+func (o ListOptions) includesScope(scopeType, scopeID string) bool {
+	if o.ScopeType != "" {
+		return scopeType == o.ScopeType && (o.ScopeID == "" || scopeID == o.ScopeID)
+	}
+	switch {
+	case o.AllScopes:
+		return true
+	case scopeType == "global" && scopeID == "*":
+		return true
+	case scopeType == "task" && o.Reader != "":
+		return scopeID == o.Reader
+	case scopeType == "topic":
+		for _, topic := range filterEmpty(o.Topics) {
+			if scopeID == topic {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Cursor struct {
+	Stream    string    `json:"stream"`
+	Consumer  string    `json:"consumer"`
+	EventID   string    `json:"event_id"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
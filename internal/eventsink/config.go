@@ -0,0 +1,75 @@
+package eventsink
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config describes one sink in the config file's event_sinks list.
+type Config struct {
+	// Name identifies the sink's stream cursors. It defaults to the sink's
+	// own name and must stay stable across restarts.
+	Name    string   `json:"name"`
+	Type    string   `json:"type"` // "s3" or "kafka"
+	Streams []string `json:"streams"`
+
+	Bucket   string `json:"bucket"`
+	Region   string `json:"region"`
+	Prefix   string `json:"prefix"`
+	Endpoint string `json:"endpoint"`
+
+	RESTURL     string `json:"rest_url"`
+	TopicPrefix string `json:"topic_prefix"`
+}
+
+// New builds the sink described by cfg. S3 credentials come from the
+// standard AWS environment variables.
+func New(cfg Config) (Sink, error) {
+	if len(cfg.Streams) == 0 {
+		return nil, fmt.Errorf("%s sink requires streams", cfg.Type)
+	}
+	var sink Sink
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "s3":
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("s3 sink requires bucket")
+		}
+		region := cfg.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return nil, fmt.Errorf("s3 sink requires region")
+		}
+		sink = &S3Sink{
+			Bucket:          cfg.Bucket,
+			Region:          region,
+			Prefix:          cfg.Prefix,
+			Endpoint:        cfg.Endpoint,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	case "kafka":
+		if cfg.RESTURL == "" {
+			return nil, fmt.Errorf("kafka sink requires rest_url")
+		}
+		sink = &KafkaSink{RESTURL: cfg.RESTURL, TopicPrefix: cfg.TopicPrefix}
+	default:
+		return nil, fmt.Errorf("unsupported sink type: %q", cfg.Type)
+	}
+	if name := strings.TrimSpace(cfg.Name); name != "" {
+		sink = namedSink{Sink: sink, name: name}
+	}
+	return sink, nil
+}
+
+type namedSink struct {
+	Sink
+	name string
+}
+
+func (n namedSink) Name() string {
+	return n.name
+}
@@ -0,0 +1,88 @@
+package eventsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// KafkaSink produces events through a Kafka REST Proxy (v2 JSON API), one
+// topic per stream. Records are keyed by event ID so consumers can drop the
+// duplicates that at-least-once delivery allows.
+type KafkaSink struct {
+	// RESTURL is the proxy base URL, e.g. http://kafka-rest:8082.
+	RESTURL string
+	// TopicPrefix is prepended to the stream name, e.g. "go-agents.".
+	TopicPrefix string
+
+	Client *http.Client
+}
+
+func (k *KafkaSink) Name() string {
+	return "kafka:" + strings.TrimRight(k.TopicPrefix, ".")
+}
+
+func (k *KafkaSink) Topic(stream string) string {
+	return k.TopicPrefix + stream
+}
+
+func (k *KafkaSink) Write(ctx context.Context, stream string, events []eventbus.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	type record struct {
+		Key   string         `json:"key"`
+		Value eventbus.Event `json:"value"`
+	}
+	records := make([]record, 0, len(events))
+	for _, evt := range events {
+		records = append(records, record{Key: evt.ID, Value: evt})
+	}
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return fmt.Errorf("encode kafka records: %w", err)
+	}
+	topic := k.Topic(stream)
+	url := strings.TrimRight(k.RESTURL, "/") + "/topics/" + topic
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build kafka request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("produce to %s: %w", topic, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("produce to %s: %s: %s", topic, resp.Status, strings.TrimSpace(string(raw)))
+	}
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("decode kafka response: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.Error != "" {
+			// Part of the batch failed; resend all of it.
+			return fmt.Errorf("produce to %s: %s", topic, offset.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package eventsink mirrors bus streams to external systems such as Kafka
+// and S3.
+//
+// A Mirror reads events from the database with its own stream cursors rather
+// than from a bus subscription, so a slow or failing sink only falls behind;
+// it never blocks Push. Cursors advance after a batch is written, which gives
+// at-least-once delivery: a batch may be sent again after a crash or a failed
+// write, but is never skipped.
+package eventsink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// Sink receives batches of events from a single stream, oldest first.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, stream string, events []eventbus.Event) error
+}
+
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	maxBackoff          = time.Minute
+)
+
+type Status struct {
+	Sink      string            `json:"sink"`
+	Streams   []string          `json:"streams"`
+	Cursors   map[string]string `json:"cursors"`
+	Delivered int64             `json:"delivered"`
+	LastError string            `json:"last_error,omitempty"`
+	LastErrAt time.Time         `json:"last_error_at,omitempty"`
+}
+
+type Mirror struct {
+	bus     *eventbus.Bus
+	sink    Sink
+	streams []string
+
+	batchSize    int
+	pollInterval time.Duration
+	onError      func(error)
+
+	mu     sync.Mutex
+	status Status
+}
+
+type Option func(*Mirror)
+
+func WithBatchSize(n int) Option {
+	return func(m *Mirror) {
+		if n > 0 {
+			m.batchSize = n
+		}
+	}
+}
+
+func WithPollInterval(d time.Duration) Option {
+	return func(m *Mirror) {
+		if d > 0 {
+			m.pollInterval = d
+		}
+	}
+}
+
+// WithErrorHandler is called for every failed read or write. The mirror keeps
+// retrying regardless.
+func WithErrorHandler(fn func(error)) Option {
+	return func(m *Mirror) {
+		m.onError = fn
+	}
+}
+
+func NewMirror(bus *eventbus.Bus, sink Sink, streams []string, opts ...Option) *Mirror {
+	m := &Mirror{
+		bus:          bus,
+		sink:         sink,
+		batchSize:    DefaultBatchSize,
+		pollInterval: DefaultPollInterval,
+	}
+	for _, stream := range streams {
+		if stream = strings.TrimSpace(stream); stream != "" {
+			m.streams = append(m.streams, stream)
+		}
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	m.status = Status{Sink: sink.Name(), Streams: append([]string{}, m.streams...), Cursors: map[string]string{}}
+	return m
+}
+
+// Consumer is the cursor name the mirror uses in every stream.
+func (m *Mirror) Consumer() string {
+	return "sink:" + m.sink.Name()
+}
+
+func (m *Mirror) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := m.status
+	out.Cursors = make(map[string]string, len(m.status.Cursors))
+	for k, v := range m.status.Cursors {
+		out.Cursors[k] = v
+	}
+	return out
+}
+
+// Run mirrors events until ctx is done. New events are picked up on the next
+// poll, or sooner when the bus announces them.
+func (m *Mirror) Run(ctx context.Context) {
+	// The subscription is only a nudge. The bus drops events for full
+	// subscribers, so a stalled sink cannot hold up publishers.
+	nudge := m.bus.Subscribe(ctx, m.streams)
+	backoff := time.Duration(0)
+	for {
+		written, err := m.Sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		wait := m.pollInterval
+		switch {
+		case err != nil:
+			if backoff == 0 {
+				backoff = m.pollInterval
+			} else {
+				backoff = min(backoff*2, maxBackoff)
+			}
+			wait = backoff
+		case written > 0:
+			backoff = 0
+			continue
+		default:
+			backoff = 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-nudge:
+			if err != nil {
+				// Keep backing off while the sink is failing.
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Sync writes at most one batch per stream and returns how many events were
+// delivered.
+func (m *Mirror) Sync(ctx context.Context) (int, error) {
+	total := 0
+	var firstErr error
+	for _, stream := range m.streams {
+		n, err := m.syncStream(ctx, stream)
+		total += n
+		if err != nil {
+			err = fmt.Errorf("mirror %s to %s: %w", stream, m.sink.Name(), err)
+			m.recordError(err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return total, firstErr
+}
+
+func (m *Mirror) syncStream(ctx context.Context, stream string) (int, error) {
+	consumer := m.Consumer()
+	cursor, err := m.bus.GetCursor(ctx, stream, consumer)
+	if err != nil {
+		return 0, err
+	}
+	summaries, err := m.bus.List(ctx, stream, eventbus.ListOptions{
+		After: cursor.EventID,
+		Order: "fifo",
+		Limit: m.batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	read, err := m.bus.Read(ctx, stream, ids, "")
+	if err != nil {
+		return 0, err
+	}
+	// Read does not keep the listed order.
+	byID := make(map[string]eventbus.Event, len(read))
+	for _, evt := range read {
+		byID[evt.ID] = evt
+	}
+	events := make([]eventbus.Event, 0, len(ids))
+	for _, id := range ids {
+		if evt, ok := byID[id]; ok {
+			events = append(events, evt)
+		}
+	}
+	if err := m.sink.Write(ctx, stream, events); err != nil {
+		return 0, err
+	}
+	last := ids[len(ids)-1]
+	if _, err := m.bus.SetCursor(ctx, stream, consumer, last); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.status.Cursors[stream] = last
+	m.status.Delivered += int64(len(events))
+	m.mu.Unlock()
+	return len(events), nil
+}
+
+func (m *Mirror) recordError(err error) {
+	m.mu.Lock()
+	m.status.LastError = err.Error()
+	m.status.LastErrAt = time.Now().UTC()
+	m.mu.Unlock()
+	if m.onError != nil {
+		m.onError(err)
+	}
+}
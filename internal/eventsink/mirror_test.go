@@ -0,0 +1,122 @@
+package eventsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type recordingSink struct {
+	mu       sync.Mutex
+	failNext int
+	batches  [][]string
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(_ context.Context, stream string, events []eventbus.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failNext > 0 {
+		s.failNext--
+		return errors.New("sink unavailable")
+	}
+	ids := make([]string, 0, len(events))
+	for _, evt := range events {
+		ids = append(ids, stream+"/"+evt.Body)
+	}
+	s.batches = append(s.batches, ids)
+	return nil
+}
+
+func (s *recordingSink) delivered() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, batch := range s.batches {
+		out = append(out, batch...)
+	}
+	return out
+}
+
+func pushEvents(t *testing.T, bus *eventbus.Bus, stream string, bodies ...string) {
+	t.Helper()
+	for _, body := range bodies {
+		if _, err := bus.Push(context.Background(), eventbus.EventInput{Stream: stream, Subject: body, Body: body}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+}
+
+func TestMirrorRetriesBatchUntilSinkAccepts(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	sink := &recordingSink{failNext: 1}
+	mirror := NewMirror(bus, sink, []string{"errors", "external"}, WithBatchSize(2))
+	ctx := context.Background()
+
+	pushEvents(t, bus, "errors", "e1", "e2", "e3")
+	pushEvents(t, bus, "signals", "ignored")
+
+	if _, err := mirror.Sync(ctx); err == nil {
+		t.Fatalf("expected first sync to fail")
+	}
+	if status := mirror.Status(); status.LastError == "" || status.Delivered != 0 {
+		t.Fatalf("unexpected status after failure: %+v", status)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := mirror.Sync(ctx); err != nil {
+			t.Fatalf("sync %d: %v", i, err)
+		}
+	}
+	got := fmt.Sprint(sink.delivered())
+	if got != "[errors/e1 errors/e2 errors/e3]" {
+		t.Fatalf("unexpected deliveries: %s", got)
+	}
+	if len(sink.batches) != 2 {
+		t.Fatalf("expected batches of at most 2, got %v", sink.batches)
+	}
+
+	// A new mirror with the same sink name resumes from the stored cursor.
+	pushEvents(t, bus, "errors", "e4")
+	resumed := &recordingSink{}
+	if _, err := NewMirror(bus, resumed, []string{"errors"}).Sync(ctx); err != nil {
+		t.Fatalf("resume sync: %v", err)
+	}
+	if got := fmt.Sprint(resumed.delivered()); got != "[errors/e4]" {
+		t.Fatalf("expected resume after cursor, got %s", got)
+	}
+}
+
+func TestMirrorRunPicksUpNewEvents(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	sink := &recordingSink{}
+	mirror := NewMirror(bus, sink, []string{"external"}, WithPollInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mirror.Run(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	pushEvents(t, bus, "external", "x1")
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.delivered()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for mirrored event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := fmt.Sprint(sink.delivered()); got != "[external/x1]" {
+		t.Fatalf("unexpected deliveries: %s", got)
+	}
+}
@@ -0,0 +1,74 @@
+package eventsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/objectstore"
+)
+
+// S3Sink writes each batch as one JSONL object. Object keys are derived from
+// the batch's first and last event IDs, so a retried batch overwrites the
+// object it already wrote instead of duplicating it.
+type S3Sink struct {
+	Bucket string
+	Region string
+	// Prefix is prepended to object keys, e.g. "go-agents/".
+	Prefix string
+	// Endpoint overrides the AWS endpoint for S3-compatible stores. Requests
+	// to a custom endpoint use path-style addressing.
+	Endpoint string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	Client *http.Client
+	nowFn  func() time.Time
+}
+
+func (s *S3Sink) Name() string {
+	return "s3:" + s.Bucket
+}
+
+func (s *S3Sink) Write(ctx context.Context, stream string, events []eventbus.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := encodeJSONL(events)
+	if err != nil {
+		return err
+	}
+	store := &objectstore.S3{
+		Bucket:          s.Bucket,
+		Region:          s.Region,
+		Endpoint:        s.Endpoint,
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+		Client:          s.Client,
+		Clock:           s.nowFn,
+	}
+	return store.Put(ctx, s.objectKey(stream, events), body, "application/x-ndjson")
+}
+
+func (s *S3Sink) objectKey(stream string, events []eventbus.Event) string {
+	first, last := events[0], events[len(events)-1]
+	return fmt.Sprintf("%s%s/%s/%s_%s.jsonl", s.Prefix, stream, first.CreatedAt.UTC().Format("2006/01/02"), first.ID, last.ID)
+}
+
+func encodeJSONL(events []eventbus.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, evt := range events {
+		if err := enc.Encode(evt); err != nil {
+			return nil, fmt.Errorf("encode event %s: %w", evt.ID, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package eventsink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+func testEvents() []eventbus.Event {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	return []eventbus.Event{
+		{ID: "evt-1", Stream: "errors", Body: "first", CreatedAt: at},
+		{ID: "evt-2", Stream: "errors", Body: "second", CreatedAt: at.Add(time.Second)},
+	}
+}
+
+func TestS3SinkPutsSignedJSONLObject(t *testing.T) {
+	var gotPath, gotAuth, gotHash string
+	var gotLines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotHash = r.Header.Get("X-Amz-Content-Sha256")
+		body, _ := io.ReadAll(r.Body)
+		gotLines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	sink := &S3Sink{
+		Bucket:          "audit",
+		Region:          "eu-north-1",
+		Prefix:          "agents/",
+		Endpoint:        srv.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		nowFn:           func() time.Time { return time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC) },
+	}
+	if err := sink.Write(context.Background(), "errors", testEvents()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if gotPath != "/audit/agents/errors/2026/03/04/evt-1_evt-2.jsonl" {
+		t.Fatalf("unexpected object path: %s", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/20260304/eu-north-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Fatalf("unexpected authorization header: %s", gotAuth)
+	}
+	if len(gotHash) != 64 {
+		t.Fatalf("expected payload hash header, got %q", gotHash)
+	}
+	if len(gotLines) != 2 {
+		t.Fatalf("expected two JSONL lines, got %v", gotLines)
+	}
+	var first eventbus.Event
+	if err := json.Unmarshal([]byte(gotLines[0]), &first); err != nil || first.ID != "evt-1" {
+		t.Fatalf("unexpected first line %q: %v", gotLines[0], err)
+	}
+}
+
+func TestKafkaSinkProducesKeyedRecords(t *testing.T) {
+	var gotPath, gotType string
+	var body struct {
+		Records []struct {
+			Key   string         `json:"key"`
+			Value eventbus.Event `json:"value"`
+		} `json:"records"`
+	}
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotType = r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if fail {
+			_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":1},{"error_code":50002,"error":"broker unavailable"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":1},{"partition":0,"offset":2}]}`))
+	}))
+	defer srv.Close()
+
+	sink := &KafkaSink{RESTURL: srv.URL, TopicPrefix: "go-agents."}
+	if err := sink.Write(context.Background(), "errors", testEvents()); err == nil || !strings.Contains(err.Error(), "broker unavailable") {
+		t.Fatalf("expected partial failure to fail the batch, got %v", err)
+	}
+	fail = false
+	if err := sink.Write(context.Background(), "errors", testEvents()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if gotPath != "/topics/go-agents.errors" || gotType != "application/vnd.kafka.json.v2+json" {
+		t.Fatalf("unexpected request: path=%s type=%s", gotPath, gotType)
+	}
+	if len(body.Records) != 2 || body.Records[0].Key != "evt-1" || body.Records[1].Value.Body != "second" {
+		t.Fatalf("unexpected records: %+v", body.Records)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	if _, err := New(Config{Type: "kafka", Streams: []string{"errors"}}); err == nil {
+		t.Fatalf("expected kafka without rest_url to fail")
+	}
+	if _, err := New(Config{Type: "s3", Bucket: "b", Region: "r"}); err == nil {
+		t.Fatalf("expected sink without streams to fail")
+	}
+	sink, err := New(Config{Name: "audit", Type: "kafka", RESTURL: "http://proxy", Streams: []string{"errors"}})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if sink.Name() != "audit" {
+		t.Fatalf("expected configured name, got %q", sink.Name())
+	}
+}
@@ -0,0 +1,101 @@
+package facts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+// maxExtractedPerTurn caps how many facts one turn may add.
+const maxExtractedPerTurn = 5
+
+const extractPrompt = `You maintain a short list of durable facts for an AI agent: things that stay true beyond the current task and that the agent must not forget, such as names, preferences, standing instructions, schedules, accounts, and decisions.
+You get the known facts and the latest exchange. Reply with only a JSON object: {"facts": [{"text": "...", "replaces": "fact-..."}]}.
+Include only facts stated or confirmed in the exchange that are not already known. Write each as one short self-contained sentence, like "The user's name is Sam." or "The deploy window is Friday 14:00-16:00 UTC." Set "replaces" to the ID of a known fact the new one corrects or supersedes, and leave it out otherwise.
+Ignore transient details: task progress, one-off requests, tool output, and guesses. Most exchanges have no new facts; then reply {"facts": []}.`
+
+// Extracted is a fact found in a turn, optionally superseding a known one.
+type Extracted struct {
+	Text     string `json:"text"`
+	Replaces string `json:"replaces,omitempty"`
+}
+
+// Extractor finds new durable facts in an exchange, given the facts already
+// known.
+type Extractor interface {
+	Extract(ctx context.Context, known []Fact, exchange string) ([]Extracted, error)
+}
+
+// LLMExtractor asks a model for the facts. NewSession should return a
+// session on a cheap model without tools.
+type LLMExtractor struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (e LLMExtractor) Extract(ctx context.Context, known []Fact, exchange string) ([]Extracted, error) {
+	if e.NewSession == nil {
+		return nil, fmt.Errorf("extractor has no llm")
+	}
+	llm, err := e.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("create fact session: %w", err)
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(extractPrompt) }
+	var b strings.Builder
+	b.WriteString("Known facts:\n")
+	if len(known) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, f := range known {
+		fmt.Fprintf(&b, "- [%s] %s\n", f.ID, f.Text)
+	}
+	b.WriteString("\nLatest exchange:\n")
+	b.WriteString(exchange)
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, b.String()) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return nil, fmt.Errorf("extract facts: %w", err)
+	}
+	return ParseExtracted(out.String())
+}
+
+// ParseExtracted reads an extractor reply. Facts may be objects or plain
+// strings, and the JSON may be wrapped in a code fence or prose.
+func ParseExtracted(reply string) ([]Extracted, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no JSON object in fact reply")
+	}
+	var parsed struct {
+		Facts []json.RawMessage `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("decode fact reply: %w", err)
+	}
+	var out []Extracted
+	for _, raw := range parsed.Facts {
+		var item Extracted
+		if err := json.Unmarshal(raw, &item); err != nil {
+			if json.Unmarshal(raw, &item.Text) != nil {
+				continue
+			}
+		}
+		if item.Text = cleanText(item.Text); item.Text == "" {
+			continue
+		}
+		item.Replaces = strings.TrimSpace(item.Replaces)
+		out = append(out, item)
+		if len(out) == maxExtractedPerTurn {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package facts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreDedupesAndEvictsExtractedFacts(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	store := NewStore(db)
+
+	pinned, added, err := store.Add(ctx, Fact{AgentID: "agent-a", Text: "Deploys happen on Friday.", Source: SourceOperator})
+	if err != nil || !added {
+		t.Fatalf("add: %v %v", added, err)
+	}
+	if again, added, _ := store.Add(ctx, Fact{AgentID: "agent-a", Text: "  deploys happen on  friday", Source: SourceExtracted}); added || again.ID != pinned.ID {
+		t.Fatalf("expected the duplicate to return the existing fact, got %+v %v", again, added)
+	}
+	for i := 1; i < MaxFacts; i++ {
+		if _, _, err := store.Add(ctx, Fact{AgentID: "agent-a", Text: fmt.Sprintf("Fact %d.", i), Source: SourceExtracted}); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if _, _, err := store.Add(ctx, Fact{AgentID: "agent-a", Text: "One more.", Source: SourceAgent}); err != nil {
+		t.Fatalf("add past the cap: %v", err)
+	}
+	list, err := store.List(ctx, "agent-a")
+	if err != nil || len(list) != MaxFacts {
+		t.Fatalf("expected %d facts, got %d %v", MaxFacts, len(list), err)
+	}
+	if list[0].ID != pinned.ID || list[1].Text != "Fact 2." {
+		t.Fatalf("expected the oldest extracted fact evicted, got %q then %q", list[0].Text, list[1].Text)
+	}
+
+	updated, err := store.Update(ctx, "agent-a", pinned.ID, "Deploys happen on Monday.", SourceAgent)
+	if err != nil || updated.Text != "Deploys happen on Monday." || updated.Source != SourceAgent {
+		t.Fatalf("unexpected update %+v %v", updated, err)
+	}
+	if _, err := store.Update(ctx, "agent-b", pinned.ID, "x", SourceAgent); !errors.Is(err, ErrFactNotFound) {
+		t.Fatalf("expected another agent's update to miss, got %v", err)
+	}
+	if err := store.Delete(ctx, "agent-a", pinned.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete(ctx, "agent-a", pinned.ID); !errors.Is(err, ErrFactNotFound) {
+		t.Fatalf("expected second delete to miss, got %v", err)
+	}
+}
+
+func TestParseExtracted(t *testing.T) {
+	reply := "```json\n{\"facts\": [{\"text\": \"The user's name is Sam.\"}, \"Standup is at 9:30.\", {\"text\": \"Prod is eu-west-1.\", \"replaces\": \"fact-1\"}, {\"text\": \"  \"}]}\n```"
+	got, err := ParseExtracted(reply)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(got) != 3 || got[1].Text != "Standup is at 9:30." || got[2].Replaces != "fact-1" {
+		t.Fatalf("unexpected facts %+v", got)
+	}
+	if _, err := ParseExtracted("no facts here"); err == nil {
+		t.Fatalf("expected an error without JSON")
+	}
+}
@@ -0,0 +1,219 @@
+// Package facts keeps a short list of durable facts per agent, such as the
+// user's name or a deploy window. Facts are pinned: the runtime shows them
+// to the agent on every turn, outside the conversation history, so they
+// survive compaction. Most are extracted from finished turns by a cheap
+// model; agents and operators can also pin, edit and remove them.
+package facts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+)
+
+const (
+	// MaxFacts is how many facts an agent keeps. When it is full, adding a
+	// fact evicts the oldest extracted one; pinned facts are never evicted.
+	MaxFacts     = 40
+	MaxFactChars = 300
+)
+
+// Fact sources.
+const (
+	SourceExtracted = "extracted"
+	SourceAgent     = "agent"
+	SourceOperator  = "operator"
+)
+
+var (
+	ErrFactNotFound = errors.New("fact not found")
+	ErrFactsFull    = errors.New("fact list is full")
+)
+
+type Fact struct {
+	ID      string `json:"id"`
+	AgentID string `json:"agent_id"`
+	Text    string `json:"text"`
+	Source  string `json:"source"`
+	// TaskID is the turn an extracted fact came from.
+	TaskID    string    `json:"task_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// List returns agentID's facts, oldest first.
+func (s *Store) List(ctx context.Context, agentID string) ([]Fact, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, agent_id, text, source, task_id, created_at, updated_at FROM facts
+		WHERE agent_id = ? ORDER BY created_at, id
+	`, strings.TrimSpace(agentID))
+	if err != nil {
+		return nil, fmt.Errorf("query facts: %w", err)
+	}
+	defer rows.Close()
+	out := []Fact{}
+	for rows.Next() {
+		var f Fact
+		var createdAt, updatedAt string
+		if err := rows.Scan(&f.ID, &f.AgentID, &f.Text, &f.Source, &f.TaskID, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan fact: %w", err)
+		}
+		f.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		f.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate facts: %w", err)
+	}
+	return out, nil
+}
+
+// Add pins a fact. A fact matching an existing one, ignoring case and
+// spacing, is not added again; the existing fact is returned with false.
+func (s *Store) Add(ctx context.Context, f Fact) (Fact, bool, error) {
+	f.AgentID = strings.TrimSpace(f.AgentID)
+	f.Text = cleanText(f.Text)
+	if f.AgentID == "" {
+		return Fact{}, false, fmt.Errorf("agent_id is required")
+	}
+	if f.Text == "" {
+		return Fact{}, false, fmt.Errorf("text is required")
+	}
+	if f.Source == "" {
+		f.Source = SourceOperator
+	}
+	existing, err := s.List(ctx, f.AgentID)
+	if err != nil {
+		return Fact{}, false, err
+	}
+	for _, e := range existing {
+		if sameText(e.Text, f.Text) {
+			return e, false, nil
+		}
+	}
+	if len(existing) >= MaxFacts {
+		evict := ""
+		for _, e := range existing {
+			if e.Source == SourceExtracted {
+				evict = e.ID
+				break
+			}
+		}
+		if evict == "" {
+			return Fact{}, false, ErrFactsFull
+		}
+		if err := s.Delete(ctx, f.AgentID, evict); err != nil {
+			return Fact{}, false, err
+		}
+	}
+	now := s.now()
+	f.ID = "fact-" + s.newIDFn()
+	f.CreatedAt, f.UpdatedAt = now, now
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO facts (id, agent_id, text, source, task_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, f.ID, f.AgentID, f.Text, f.Source, strings.TrimSpace(f.TaskID),
+		now.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano)); err != nil {
+		return Fact{}, false, fmt.Errorf("insert fact: %w", err)
+	}
+	return f, true, nil
+}
+
+// Update replaces a fact's text. The fact keeps its ID and position; its
+// source becomes source, so an edited extracted fact is no longer evicted.
+func (s *Store) Update(ctx context.Context, agentID, id, text, source string) (Fact, error) {
+	text = cleanText(text)
+	if text == "" {
+		return Fact{}, fmt.Errorf("text is required")
+	}
+	now := s.now()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE facts SET text = ?, source = ?, updated_at = ? WHERE agent_id = ? AND id = ?
+	`, text, source, now.Format(time.RFC3339Nano), strings.TrimSpace(agentID), strings.TrimSpace(id))
+	if err != nil {
+		return Fact{}, fmt.Errorf("update fact: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Fact{}, ErrFactNotFound
+	}
+	list, err := s.List(ctx, agentID)
+	if err != nil {
+		return Fact{}, err
+	}
+	for _, f := range list {
+		if f.ID == strings.TrimSpace(id) {
+			return f, nil
+		}
+	}
+	return Fact{}, ErrFactNotFound
+}
+
+func (s *Store) Delete(ctx context.Context, agentID, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM facts WHERE agent_id = ? AND id = ?`,
+		strings.TrimSpace(agentID), strings.TrimSpace(id))
+	if err != nil {
+		return fmt.Errorf("delete fact: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFactNotFound
+	}
+	return nil
+}
+
+func cleanText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > MaxFactChars {
+		text = strings.TrimSpace(string([]rune(text)[:MaxFactChars-1])) + "…"
+	}
+	return text
+}
+
+func sameText(a, b string) bool {
+	norm := func(s string) string {
+		return strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(s), " ")), ".")
+	}
+	return norm(a) == norm(b)
+}
@@ -0,0 +1,108 @@
+// Package federation lets agents on separate agentd instances message each
+// other. An agent addresses a remote agent as "agent@peer", where peer is a
+// name from the federation config. Messages wait in an outbox until the
+// peer accepts them over HTTP; the peer's answer is the delivery receipt,
+// which is passed back to the sending agent as a signal.
+//
+// Peers authenticate each other with a shared secret per peer. Requests are
+// signed the same way as task callbacks: X-Go-Agents-Signature is "sha256="
+// followed by the hex HMAC-SHA256 of "<X-Go-Agents-Timestamp>.<body>", and
+// X-Go-Agents-Peer names the sending instance.
+package federation
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/callbacks"
+)
+
+// InboundPath is where peers POST messages.
+const InboundPath = "/api/federation/inbound"
+
+// maxClockSkew bounds how far a request's timestamp may be from the
+// receiver's clock, limiting replays of captured requests.
+const maxClockSkew = 5 * time.Minute
+
+// Config is the config file's federation section. Name is how this
+// instance identifies itself to peers; each peer must list it with the
+// same secret.
+type Config struct {
+	Name  string `json:"name"`
+	Peers []Peer `json:"peers"`
+}
+
+type Peer struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+func (c Config) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("federation name is required")
+	}
+	seen := map[string]bool{}
+	for _, peer := range c.Peers {
+		if strings.TrimSpace(peer.Name) == "" || strings.Contains(peer.Name, "@") {
+			return fmt.Errorf("federation peer name %q is invalid", peer.Name)
+		}
+		if seen[peer.Name] {
+			return fmt.Errorf("federation peer %q is listed twice", peer.Name)
+		}
+		seen[peer.Name] = true
+		u, err := url.Parse(peer.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("federation peer %q needs an http(s) url", peer.Name)
+		}
+		if strings.TrimSpace(peer.Secret) == "" {
+			return fmt.Errorf("federation peer %q needs a secret", peer.Name)
+		}
+	}
+	return nil
+}
+
+// ParseAddress splits "agent@peer". ok is false for local task IDs.
+func ParseAddress(address string) (agent, peer string, ok bool) {
+	i := strings.LastIndex(address, "@")
+	if i <= 0 || i == len(address)-1 {
+		return "", "", false
+	}
+	return address[:i], address[i+1:], true
+}
+
+// Envelope is the body of a message sent to a peer. ID is the sender's
+// outbox ID, which the receiver uses to ignore redelivered messages.
+type Envelope struct {
+	ID     string    `json:"id"`
+	Source string    `json:"source"`
+	Target string    `json:"target"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Receipt is a peer's answer to an accepted message.
+type Receipt struct {
+	EventID     string    `json:"event_id"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Duplicate   bool      `json:"duplicate,omitempty"`
+}
+
+// verify checks a request signature and its timestamp's freshness.
+func verify(secret, timestamp, signature string, body []byte, now time.Time) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return fmt.Errorf("timestamp outside allowed clock skew")
+	}
+	if !hmac.Equal([]byte(signature), []byte(callbacks.Sign(secret, timestamp, body))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/callbacks"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+const (
+	DefaultMaxAttempts  = 8
+	DefaultPollInterval = time.Second
+	initialBackoff      = 5 * time.Second
+	maxBackoff          = 10 * time.Minute
+	deliverBatch        = 20
+)
+
+// ErrUnknownPeer is returned for addresses naming a peer that is not
+// configured.
+var ErrUnknownPeer = errors.New("unknown federation peer")
+
+// InboundError is a rejected inbound message; Status is the HTTP status to
+// answer with.
+type InboundError struct {
+	Status int
+	Err    error
+}
+
+func (e *InboundError) Error() string { return e.Err.Error() }
+func (e *InboundError) Unwrap() error { return e.Err }
+
+// Node sends messages to peers and accepts theirs.
+type Node struct {
+	db    *sql.DB
+	bus   *eventbus.Bus
+	tasks *tasks.Manager
+	name  string
+	peers map[string]Peer
+
+	deliverFn   DeliverFunc
+	client      *http.Client
+	maxAttempts int
+	nowFn       func() time.Time
+	newIDFn     func() string
+}
+
+// DeliverFunc hands an accepted message to a local agent.
+type DeliverFunc func(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error)
+
+type Option func(*Node)
+
+// WithDeliverer replaces how accepted messages reach local agents; the
+// runtime uses it to start the target's loop. By default they are pushed to
+// the agent's task_input.
+func WithDeliverer(fn DeliverFunc) Option {
+	return func(n *Node) {
+		if fn != nil {
+			n.deliverFn = fn
+		}
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Node) {
+		if client != nil {
+			n.client = client
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(n *Node) {
+		if nowFn != nil {
+			n.nowFn = nowFn
+		}
+	}
+}
+
+func WithMaxAttempts(attempts int) Option {
+	return func(n *Node) {
+		if attempts > 0 {
+			n.maxAttempts = attempts
+		}
+	}
+}
+
+func NewNode(db *sql.DB, bus *eventbus.Bus, manager *tasks.Manager, cfg Config, opts ...Option) (*Node, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	n := &Node{
+		db:          db,
+		bus:         bus,
+		tasks:       manager,
+		name:        strings.TrimSpace(cfg.Name),
+		peers:       map[string]Peer{},
+		client:      &http.Client{Timeout: callbacks.DefaultTimeout},
+		maxAttempts: DefaultMaxAttempts,
+		nowFn:       func() time.Time { return time.Now().UTC() },
+		newIDFn:     idgen.New,
+	}
+	n.deliverFn = n.pushInput
+	for _, peer := range cfg.Peers {
+		peer.URL = strings.TrimRight(peer.URL, "/")
+		n.peers[peer.Name] = peer
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(n)
+		}
+	}
+	return n, nil
+}
+
+func (n *Node) now() time.Time {
+	return n.nowFn().UTC()
+}
+
+// Name is this instance's name as peers know it.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Peers lists the configured peer names.
+func (n *Node) Peers() []string {
+	out := make([]string, 0, len(n.peers))
+	for name := range n.peers {
+		out = append(out, name)
+	}
+	return out
+}
+
+// SendRemote queues body from the local task source for the agent at
+// address ("agent@peer") and returns the outbox ID.
+func (n *Node) SendRemote(ctx context.Context, source, address, body string) (string, error) {
+	target, peer, ok := ParseAddress(strings.TrimSpace(address))
+	if !ok {
+		return "", fmt.Errorf("invalid federated address %q (expected agent@peer)", address)
+	}
+	if _, ok := n.peers[peer]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPeer, peer)
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("body is required")
+	}
+	now := n.now()
+	msg := Message{
+		ID:            n.newIDFn(),
+		Peer:          peer,
+		Source:        source,
+		Target:        target,
+		Body:          body,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := n.insert(ctx, msg); err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// DeliverDue attempts every due outbox message once and returns how many
+// were delivered.
+func (n *Node) DeliverDue(ctx context.Context) (int, error) {
+	due, err := n.due(ctx, deliverBatch)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, msg := range due {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		receipt, permanent, deliveryErr := n.deliver(ctx, msg)
+		msg.Attempts++
+		switch {
+		case deliveryErr == nil:
+			msg.Status = StatusDelivered
+			msg.RemoteEventID = receipt.EventID
+			msg.LastError = ""
+		case permanent || msg.Attempts >= n.maxAttempts:
+			msg.Status = StatusFailed
+			msg.LastError = deliveryErr.Error()
+		default:
+			msg.LastError = deliveryErr.Error()
+			msg.NextAttemptAt = n.now().Add(backoff(msg.Attempts))
+		}
+		if err := n.update(ctx, msg); err != nil {
+			return delivered, err
+		}
+		if msg.Status != StatusPending {
+			if err := n.pushReceipt(ctx, msg); err != nil {
+				return delivered, err
+			}
+		}
+		if msg.Status == StatusDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// deliver posts msg to its peer. permanent reports failures that retrying
+// cannot fix, such as an unknown target agent.
+func (n *Node) deliver(ctx context.Context, msg Message) (Receipt, bool, error) {
+	peer, ok := n.peers[msg.Peer]
+	if !ok {
+		return Receipt{}, true, fmt.Errorf("%w: %s", ErrUnknownPeer, msg.Peer)
+	}
+	body, err := json.Marshal(Envelope{
+		ID:     msg.ID,
+		Source: msg.Source,
+		Target: msg.Target,
+		Body:   msg.Body,
+		SentAt: msg.CreatedAt,
+	})
+	if err != nil {
+		return Receipt{}, true, fmt.Errorf("encode federated message: %w", err)
+	}
+	timestamp := strconv.FormatInt(n.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer.URL+InboundPath, bytes.NewReader(body))
+	if err != nil {
+		return Receipt{}, true, fmt.Errorf("build federated request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Go-Agents-Peer", n.name)
+	req.Header.Set("X-Go-Agents-Timestamp", timestamp)
+	req.Header.Set("X-Go-Agents-Signature", callbacks.Sign(peer.Secret, timestamp, body))
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return Receipt{}, false, fmt.Errorf("post to %s: %w", msg.Peer, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		permanent := resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+		return Receipt{}, permanent, fmt.Errorf("%s answered %d: %s", msg.Peer, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return Receipt{}, false, fmt.Errorf("decode receipt from %s: %w", msg.Peer, err)
+	}
+	return receipt, false, nil
+}
+
+// pushReceipt tells the sending agent how delivery ended. Failures wake it
+// so it can react; successful deliveries only show up in its context.
+func (n *Node) pushReceipt(ctx context.Context, msg Message) error {
+	if n.bus == nil || msg.Source == "" || msg.Source == "system" {
+		return nil
+	}
+	meta := map[string]any{
+		schema.MetaKind: "delivery_receipt",
+		"message_id":    msg.ID,
+		"target":        msg.Address(),
+		"status":        msg.Status,
+	}
+	subject := fmt.Sprintf("Message to %s delivered", msg.Address())
+	body := subject
+	if msg.Status == StatusFailed {
+		subject = fmt.Sprintf("Message to %s failed", msg.Address())
+		body = fmt.Sprintf("Delivery of message %s to %s failed after %d attempt(s): %s", msg.ID, msg.Address(), msg.Attempts, msg.LastError)
+		meta[schema.MetaPriority] = string(schema.PriorityWake)
+	} else {
+		meta["remote_event_id"] = msg.RemoteEventID
+	}
+	_, err := n.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamSignals,
+		ScopeType: "task",
+		ScopeID:   msg.Source,
+		Subject:   subject,
+		Body:      body,
+		Metadata:  meta,
+	})
+	return err
+}
+
+// Receive verifies and accepts a message POSTed by a peer, delivering it to
+// the target agent's input. Redelivered messages return the original
+// receipt.
+func (n *Node) Receive(ctx context.Context, header http.Header, body []byte) (Receipt, error) {
+	peerName := strings.TrimSpace(header.Get("X-Go-Agents-Peer"))
+	peer, ok := n.peers[peerName]
+	if !ok {
+		return Receipt{}, &InboundError{Status: http.StatusUnauthorized, Err: fmt.Errorf("%w: %q", ErrUnknownPeer, peerName)}
+	}
+	if err := verify(peer.Secret, header.Get("X-Go-Agents-Timestamp"), header.Get("X-Go-Agents-Signature"), body, n.now()); err != nil {
+		return Receipt{}, &InboundError{Status: http.StatusUnauthorized, Err: err}
+	}
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Receipt{}, &InboundError{Status: http.StatusBadRequest, Err: fmt.Errorf("decode message: %w", err)}
+	}
+	if env.ID == "" || env.Target == "" || strings.TrimSpace(env.Body) == "" {
+		return Receipt{}, &InboundError{Status: http.StatusBadRequest, Err: fmt.Errorf("id, target and body are required")}
+	}
+	target, err := n.tasks.Get(ctx, env.Target)
+	if err != nil || target.Type != "agent" {
+		return Receipt{}, &InboundError{Status: http.StatusNotFound, Err: fmt.Errorf("agent %q not found", env.Target)}
+	}
+	federationID := peerName + "/" + env.ID
+	existing, err := n.bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   target.ID,
+		Limit:     1,
+		Fields: []eventbus.FieldFilter{
+			{Column: "metadata", Path: []string{"federation_id"}, Values: []string{federationID}},
+		},
+	})
+	if err != nil {
+		return Receipt{}, err
+	}
+	if len(existing) > 0 {
+		return Receipt{EventID: existing[0].ID, DeliveredAt: existing[0].CreatedAt, Duplicate: true}, nil
+	}
+	evt, err := n.deliverFn(ctx, target.ID, env.Body, env.Source+"@"+peerName, map[string]any{
+		"peer":          peerName,
+		"federation_id": federationID,
+	})
+	if err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{EventID: evt.ID, DeliveredAt: evt.CreatedAt}, nil
+}
+
+func (n *Node) pushInput(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error) {
+	seq, err := n.bus.NextSequence(ctx, eventbus.MessageSequenceKey(source, target))
+	if err != nil {
+		return eventbus.Event{}, err
+	}
+	metadata := map[string]any{
+		schema.MetaKind:   "message",
+		schema.MetaSource: source,
+		"target":          target,
+		"seq":             seq,
+	}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	return n.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   target,
+		Subject:   fmt.Sprintf("Message from %s", source),
+		Body:      body,
+		Metadata:  metadata,
+	})
+}
+
+func backoff(attempts int) time.Duration {
+	wait := initialBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
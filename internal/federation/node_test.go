@@ -0,0 +1,133 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type instance struct {
+	node *Node
+	bus  *eventbus.Bus
+	mgr  *tasks.Manager
+}
+
+func newInstance(t *testing.T, cfg Config) *instance {
+	t.Helper()
+	db, closeFn := testutil.OpenTestDB(t)
+	t.Cleanup(closeFn)
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	node, err := NewNode(db, bus, mgr, cfg)
+	if err != nil {
+		t.Fatalf("new node: %v", err)
+	}
+	return &instance{node: node, bus: bus, mgr: mgr}
+}
+
+// serve answers inbound requests the way the API handler does.
+func serve(node *Node) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receipt, err := node.Receive(r.Context(), r.Header, body)
+		var inbound *InboundError
+		if errors.As(err, &inbound) {
+			http.Error(w, inbound.Error(), inbound.Status)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(mustJSON(receipt))
+	}))
+}
+
+func TestNodesExchangeMessagesWithReceipts(t *testing.T) {
+	ctx := context.Background()
+	beta := newInstance(t, Config{Name: "beta", Peers: []Peer{{Name: "alpha", URL: "http://alpha.invalid", Secret: "s3cret"}}})
+	srv := serve(beta.node)
+	defer srv.Close()
+	alpha := newInstance(t, Config{Name: "alpha", Peers: []Peer{{Name: "beta", URL: srv.URL, Secret: "s3cret"}}})
+
+	if _, err := beta.mgr.Spawn(ctx, tasks.Spec{ID: "reviewer", Type: "agent", Owner: "reviewer", Mode: "async"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if _, err := alpha.node.SendRemote(ctx, "planner", "reviewer@gamma", "hi"); !errors.Is(err, ErrUnknownPeer) {
+		t.Fatalf("expected unknown peer error, got %v", err)
+	}
+	id, err := alpha.node.SendRemote(ctx, "planner", "reviewer@beta", "Please review PR 12")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	missing, err := alpha.node.SendRemote(ctx, "planner", "nobody@beta", "hello?")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if delivered, err := alpha.node.DeliverDue(ctx); err != nil || delivered != 1 {
+		t.Fatalf("expected one delivery, got %d err=%v", delivered, err)
+	}
+
+	summaries, err := beta.bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "reviewer"})
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("expected one inbound message, got %d err=%v", len(summaries), err)
+	}
+	events, _ := beta.bus.Read(ctx, schema.StreamTaskInput, []string{summaries[0].ID}, "")
+	if events[0].Body != "Please review PR 12" || schema.GetMetaString(events[0].Metadata, schema.MetaSource) != "planner@alpha" {
+		t.Fatalf("unexpected inbound event %+v", events[0])
+	}
+
+	sent, _ := alpha.node.Message(ctx, id)
+	if sent.Status != StatusDelivered || sent.RemoteEventID != summaries[0].ID {
+		t.Fatalf("unexpected outbox entry %+v", sent)
+	}
+	failed, _ := alpha.node.Message(ctx, missing)
+	if failed.Status != StatusFailed || failed.Attempts != 1 {
+		t.Fatalf("expected an unknown agent to fail without retries, got %+v", failed)
+	}
+	receipts, _ := alpha.bus.List(ctx, schema.StreamSignals, eventbus.ListOptions{ScopeType: "task", ScopeID: "planner"})
+	if len(receipts) != 2 {
+		t.Fatalf("expected delivered and failed receipts, got %+v", receipts)
+	}
+
+	// A redelivered message is acknowledged with the original receipt.
+	msg := Message{ID: id, Peer: "beta", Source: "planner", Target: "reviewer", Body: "Please review PR 12"}
+	receipt, _, err := alpha.node.deliver(ctx, msg)
+	if err != nil || !receipt.Duplicate || receipt.EventID != summaries[0].ID {
+		t.Fatalf("expected duplicate receipt, got %+v err=%v", receipt, err)
+	}
+	if n, _ := beta.bus.Count(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "reviewer"}, time.Time{}, time.Time{}); n != 1 {
+		t.Fatalf("expected redelivery not to duplicate the message, got %d", n)
+	}
+}
+
+func TestReceiveRejectsBadSignatures(t *testing.T) {
+	beta := newInstance(t, Config{Name: "beta", Peers: []Peer{{Name: "alpha", URL: "http://alpha.invalid", Secret: "right"}}})
+	srv := serve(beta.node)
+	defer srv.Close()
+	alpha := newInstance(t, Config{Name: "alpha", Peers: []Peer{{Name: "beta", URL: srv.URL, Secret: "wrong"}}})
+
+	_, permanent, err := alpha.node.deliver(context.Background(), Message{ID: "m1", Peer: "beta", Source: "planner", Target: "reviewer", Body: "hi"})
+	if err == nil || !permanent {
+		t.Fatalf("expected a permanent auth failure, got permanent=%v err=%v", permanent, err)
+	}
+}
+
+func mustJSON(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
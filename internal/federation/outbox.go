@@ -0,0 +1,137 @@
+package federation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Message is one outbound message and its delivery state.
+type Message struct {
+	ID            string    `json:"id"`
+	Peer          string    `json:"peer"`
+	Source        string    `json:"source"`
+	Target        string    `json:"target"`
+	Body          string    `json:"body"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	RemoteEventID string    `json:"remote_event_id,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Address is the message's "agent@peer" target.
+func (m Message) Address() string {
+	return m.Target + "@" + m.Peer
+}
+
+var ErrMessageNotFound = errors.New("federated message not found")
+
+const messageColumns = `id, peer, source, target, body, status, attempts, next_attempt_at, remote_event_id, last_error, created_at, updated_at`
+
+func (n *Node) insert(ctx context.Context, msg Message) error {
+	_, err := n.db.ExecContext(ctx, `INSERT INTO federation_outbox (`+messageColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.ID, msg.Peer, msg.Source, msg.Target, msg.Body, msg.Status, msg.Attempts,
+		msg.NextAttemptAt.Format(state.TimeLayout), msg.RemoteEventID, msg.LastError,
+		msg.CreatedAt.Format(state.TimeLayout), msg.UpdatedAt.Format(state.TimeLayout))
+	if err != nil {
+		return fmt.Errorf("insert federated message: %w", err)
+	}
+	return nil
+}
+
+// Message returns one outbox entry.
+func (n *Node) Message(ctx context.Context, id string) (Message, error) {
+	row := n.db.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM federation_outbox WHERE id = ?`, strings.TrimSpace(id))
+	msg, err := scanMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Message{}, ErrMessageNotFound
+	}
+	return msg, err
+}
+
+// Messages lists outbox entries newest first, optionally with one status.
+func (n *Node) Messages(ctx context.Context, status string, limit int) ([]Message, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `SELECT ` + messageColumns + ` FROM federation_outbox`
+	var args []any
+	if status = strings.TrimSpace(status); status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	return n.queryMessages(ctx, query+` ORDER BY created_at DESC, id DESC LIMIT ?`, args...)
+}
+
+func (n *Node) due(ctx context.Context, limit int) ([]Message, error) {
+	return n.queryMessages(ctx, `SELECT `+messageColumns+` FROM federation_outbox
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		StatusPending, n.now().Format(state.TimeLayout), limit)
+}
+
+func (n *Node) queryMessages(ctx context.Context, query string, args ...any) ([]Message, error) {
+	rows, err := n.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list federated messages: %w", err)
+	}
+	defer rows.Close()
+	out := []Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate federated messages: %w", err)
+	}
+	return out, nil
+}
+
+func (n *Node) update(ctx context.Context, msg Message) error {
+	msg.UpdatedAt = n.now()
+	_, err := n.db.ExecContext(ctx, `
+		UPDATE federation_outbox SET status = ?, attempts = ?, next_attempt_at = ?, remote_event_id = ?, last_error = ?, updated_at = ?
+		WHERE id = ?
+	`, msg.Status, msg.Attempts, msg.NextAttemptAt.Format(state.TimeLayout), msg.RemoteEventID, msg.LastError, msg.UpdatedAt.Format(state.TimeLayout), msg.ID)
+	if err != nil {
+		return fmt.Errorf("update federated message: %w", err)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (Message, error) {
+	var msg Message
+	var nextAttemptAt, createdAt, updatedAt string
+	if err := row.Scan(&msg.ID, &msg.Peer, &msg.Source, &msg.Target, &msg.Body, &msg.Status, &msg.Attempts,
+		&nextAttemptAt, &msg.RemoteEventID, &msg.LastError, &createdAt, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Message{}, err
+		}
+		return Message{}, fmt.Errorf("scan federated message: %w", err)
+	}
+	msg.NextAttemptAt, _ = time.Parse(state.TimeLayout, nextAttemptAt)
+	msg.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+	msg.UpdatedAt, _ = time.Parse(state.TimeLayout, updatedAt)
+	return msg, nil
+}
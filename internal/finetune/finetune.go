@@ -0,0 +1,256 @@
+// Package finetune turns agent turns into fine-tuning datasets in the chat
+// formats providers accept.
+package finetune
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Formats a dataset can be written in.
+const (
+	// OpenAI is OpenAI's chat fine-tuning JSONL: one {"messages": [...]}
+	// object per line, tool calls as tool_calls and tool messages.
+	OpenAI = "openai"
+	// Anthropic is a {"system": "...", "messages": [...]} object per line,
+	// tool calls as tool_use and tool_result content blocks.
+	Anthropic = "anthropic"
+)
+
+// Splits an example can fall in.
+const (
+	Train      = "train"
+	Validation = "validation"
+)
+
+// ToolCall is a tool the assistant called, with its JSON arguments.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Message is one message of an example. Role is user, assistant or tool;
+// tool messages carry the ToolCallID they answer.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Example is one turn of an agent: the system prompt it ran with, the
+// message it handled, and the tool calls and reply it produced.
+type Example struct {
+	ID        string    `json:"id"`
+	AgentID   string    `json:"agent_id"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidFormat reports whether format is a known dataset format.
+func ValidFormat(format string) bool {
+	return format == OpenAI || format == Anthropic
+}
+
+// A Redactor rewrites text before it goes into a dataset.
+type Redactor func(string) string
+
+// Rule replaces every match of Pattern, a regular expression, with Replace.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+// Config holds the redaction rules applied to every export in addition to
+// the built-in PII redaction.
+type Config struct {
+	Redact []Rule `json:"redact"`
+}
+
+// Redactors compiles the configured rules.
+func (c Config) Redactors() ([]Redactor, error) {
+	out := make([]Redactor, 0, len(c.Redact))
+	for _, rule := range c.Redact {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact %q: %w", rule.Pattern, err)
+		}
+		replace := rule.Replace
+		if replace == "" {
+			replace = "[redacted]"
+		}
+		out = append(out, func(s string) string { return re.ReplaceAllLiteralString(s, replace) })
+	}
+	return out, nil
+}
+
+var piiPatterns = []struct {
+	re      *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[email]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,15}\d\b`), "[card]"},
+	{regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`), "[phone]"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`), "[ip]"},
+}
+
+// RedactPII replaces email addresses, card numbers, phone numbers and IPv4
+// addresses with placeholders.
+func RedactPII(s string) string {
+	for _, p := range piiPatterns {
+		s = p.re.ReplaceAllString(s, p.replace)
+	}
+	return s
+}
+
+// Redact returns ex with every system prompt, message and tool argument
+// passed through redactors in order.
+func Redact(ex Example, redactors ...Redactor) Example {
+	if len(redactors) == 0 {
+		return ex
+	}
+	apply := func(s string) string {
+		for _, redact := range redactors {
+			s = redact(s)
+		}
+		return s
+	}
+	ex.System = apply(ex.System)
+	messages := make([]Message, len(ex.Messages))
+	for i, m := range ex.Messages {
+		m.Content = apply(m.Content)
+		if len(m.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(m.ToolCalls))
+			for j, call := range m.ToolCalls {
+				call.Arguments = apply(call.Arguments)
+				calls[j] = call
+			}
+			m.ToolCalls = calls
+		}
+		messages[i] = m
+	}
+	ex.Messages = messages
+	return ex
+}
+
+// SplitOf assigns the example with id to the validation split with
+// probability validation. The assignment depends only on id, so repeated
+// exports put an example in the same split.
+func SplitOf(id string, validation float64) string {
+	if validation <= 0 {
+		return Train
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	if float64(h.Sum64()%10000)/10000 < validation {
+		return Validation
+	}
+	return Train
+}
+
+// Encode writes examples to w as JSON lines in format.
+func Encode(w io.Writer, format string, examples []Example) error {
+	if !ValidFormat(format) {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	enc := json.NewEncoder(w)
+	for _, ex := range examples {
+		var line any
+		if format == OpenAI {
+			line = openAIExample(ex)
+		} else {
+			line = anthropicExample(ex)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+func openAIExample(ex Example) map[string]any {
+	messages := make([]openAIMessage, 0, len(ex.Messages)+1)
+	if ex.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: &ex.System})
+	}
+	for _, m := range ex.Messages {
+		out := openAIMessage{Role: m.Role, ToolCallID: m.ToolCallID}
+		// An assistant message with only tool calls has null content.
+		if m.Content != "" || len(m.ToolCalls) == 0 {
+			content := m.Content
+			out.Content = &content
+		}
+		for _, call := range m.ToolCalls {
+			tc := openAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			if strings.TrimSpace(tc.Function.Arguments) == "" {
+				tc.Function.Arguments = "{}"
+			}
+			out.ToolCalls = append(out.ToolCalls, tc)
+		}
+		messages = append(messages, out)
+	}
+	return map[string]any{"messages": messages}
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []map[string]any `json:"content"`
+}
+
+func anthropicExample(ex Example) map[string]any {
+	var messages []anthropicMessage
+	add := func(role string, block map[string]any) {
+		// Tool results answer in a user message; consecutive blocks of one
+		// role share a message since roles must alternate.
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, block)
+			return
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: []map[string]any{block}})
+	}
+	for _, m := range ex.Messages {
+		switch m.Role {
+		case "tool":
+			add("user", map[string]any{"type": "tool_result", "tool_use_id": m.ToolCallID, "content": m.Content})
+		default:
+			if m.Content != "" {
+				add(m.Role, map[string]any{"type": "text", "text": m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input := map[string]any{}
+				_ = json.Unmarshal([]byte(call.Arguments), &input)
+				add(m.Role, map[string]any{"type": "tool_use", "id": call.ID, "name": call.Name, "input": input})
+			}
+		}
+	}
+	out := map[string]any{"messages": messages}
+	if ex.System != "" {
+		out["system"] = ex.System
+	}
+	return out
+}
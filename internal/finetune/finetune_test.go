@@ -0,0 +1,93 @@
+package finetune
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRedactsAndWritesEachFormat(t *testing.T) {
+	ex := Example{
+		ID:     "llm-1",
+		System: "You help alice@example.com.",
+		Messages: []Message{
+			{Role: "user", Content: "Call me at (555) 123-4567 about 2026-05-01"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call-1", Name: "lookup", Arguments: `{"ip":"10.0.0.1"}`}}},
+			{Role: "tool", ToolCallID: "call-1", Content: "found"},
+			{Role: "assistant", Content: "Done, ACME-42 filed."},
+		},
+	}
+	rules, err := Config{Redact: []Rule{{Pattern: `ACME-\d+`, Replace: "[ticket]"}}}.Redactors()
+	if err != nil {
+		t.Fatalf("redactors: %v", err)
+	}
+	ex = Redact(ex, append([]Redactor{RedactPII}, rules...)...)
+	if ex.System != "You help [email]." || ex.Messages[0].Content != "Call me at [phone] about 2026-05-01" ||
+		ex.Messages[1].ToolCalls[0].Arguments != `{"ip":"[ip]"}` || ex.Messages[3].Content != "Done, [ticket] filed." {
+		t.Fatalf("unexpected redaction %+v", ex)
+	}
+	if _, err := (Config{Redact: []Rule{{Pattern: "("}}}).Redactors(); err == nil {
+		t.Fatalf("expected an invalid pattern rejected")
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, OpenAI, []Example{ex}); err != nil {
+		t.Fatalf("encode openai: %v", err)
+	}
+	var openai struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &openai); err != nil {
+		t.Fatalf("decode openai: %v", err)
+	}
+	roles := []string{}
+	for _, m := range openai.Messages {
+		roles = append(roles, m["role"].(string))
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,tool,assistant" || openai.Messages[2]["content"] != nil || openai.Messages[3]["tool_call_id"] != "call-1" {
+		t.Fatalf("unexpected openai example %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, Anthropic, []Example{ex}); err != nil {
+		t.Fatalf("encode anthropic: %v", err)
+	}
+	var anthropic struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &anthropic); err != nil {
+		t.Fatalf("decode anthropic: %v", err)
+	}
+	if anthropic.System != ex.System || len(anthropic.Messages) != 4 || anthropic.Messages[1].Content[0]["type"] != "tool_use" ||
+		anthropic.Messages[2].Role != "user" || anthropic.Messages[2].Content[0]["tool_use_id"] != "call-1" {
+		t.Fatalf("unexpected anthropic example %s", buf.String())
+	}
+	if err := Encode(&buf, "llama", nil); err == nil {
+		t.Fatalf("expected an unknown format rejected")
+	}
+}
+
+func TestSplitOfIsStableAndRoughlyProportional(t *testing.T) {
+	held := 0
+	for i := range 1000 {
+		id := "llm-" + strings.Repeat("x", i%7) + string(rune('a'+i%26)) + strings.Repeat("y", i/26)
+		split := SplitOf(id, 0.2)
+		if split != SplitOf(id, 0.2) {
+			t.Fatalf("expected %s to stay in one split", id)
+		}
+		if split == Validation {
+			held++
+		}
+	}
+	if held < 120 || held > 280 {
+		t.Fatalf("expected about a fifth held out, got %d of 1000", held)
+	}
+	if SplitOf("llm-1", 0) != Train {
+		t.Fatalf("expected everything in train without a validation share")
+	}
+}
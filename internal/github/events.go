@@ -0,0 +1,258 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// maxEventBodyChars caps the comment or review text carried by an event.
+const maxEventBodyChars = 2000
+
+// Event is a webhook normalized for agents. Kind is "<subject>.<what
+// happened>", such as "pull_request.opened", "issue.commented" or
+// "ci.failed".
+type Event struct {
+	Kind     string          `json:"kind"`
+	Repo     string          `json:"repo"`
+	Number   int             `json:"number,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	Actor    string          `json:"actor,omitempty"`
+	Branch   string          `json:"branch,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Priority schema.Priority `json:"priority"`
+}
+
+// Text is the message an agent receives for the event.
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString("GitHub " + e.Kind + " in " + e.Repo)
+	if e.Number > 0 {
+		fmt.Fprintf(&b, " #%d", e.Number)
+	}
+	if e.Title != "" {
+		b.WriteString(": " + e.Title)
+	}
+	if e.Branch != "" {
+		b.WriteString(" (branch " + e.Branch + ")")
+	}
+	if e.Actor != "" {
+		b.WriteString(" by " + e.Actor)
+	}
+	if e.Body != "" {
+		b.WriteString("\n\n" + e.Body)
+	}
+	if e.URL != "" {
+		b.WriteString("\n" + e.URL)
+	}
+	return b.String()
+}
+
+// Metadata describes the event in the delivered message's metadata.
+func (e Event) Metadata() map[string]any {
+	meta := map[string]any{
+		"github_event": e.Kind,
+		"repo":         e.Repo,
+	}
+	if e.Number > 0 {
+		meta["number"] = e.Number
+	}
+	if e.URL != "" {
+		meta["url"] = e.URL
+	}
+	if e.Actor != "" {
+		meta["actor"] = e.Actor
+	}
+	return meta
+}
+
+type user struct {
+	Login string `json:"login"`
+}
+
+type issue struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	HTMLURL     string          `json:"html_url"`
+	PullRequest json.RawMessage `json:"pull_request"`
+}
+
+type pullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+type comment struct {
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+type webhookPayload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender      user        `json:"sender"`
+	Issue       issue       `json:"issue"`
+	PullRequest pullRequest `json:"pull_request"`
+	Comment     comment     `json:"comment"`
+	Review      struct {
+		State   string `json:"state"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"review"`
+	RequestedReviewer user `json:"requested_reviewer"`
+	Assignee          user `json:"assignee"`
+	CheckRun          struct {
+		Name       string `json:"name"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+		CheckSuite struct {
+			HeadBranch string `json:"head_branch"`
+		} `json:"check_suite"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_run"`
+	WorkflowRun struct {
+		Name         string `json:"name"`
+		Conclusion   string `json:"conclusion"`
+		HTMLURL      string `json:"html_url"`
+		HeadBranch   string `json:"head_branch"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"workflow_run"`
+}
+
+// failedConclusions are the check and workflow conclusions reported as
+// ci.failed.
+var failedConclusions = map[string]bool{
+	"failure":         true,
+	"timed_out":       true,
+	"startup_failure": true,
+	"action_required": true,
+}
+
+// Normalize turns a webhook of eventType (the X-GitHub-Event header) into
+// an Event with its default priority. It reports false for events and
+// actions agents are not told about.
+func Normalize(eventType string, body []byte) (Event, bool, error) {
+	var p webhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Event{}, false, fmt.Errorf("decode webhook: %w", err)
+	}
+	e := Event{Repo: strings.ToLower(p.Repository.FullName), Actor: p.Sender.Login}
+	switch eventType {
+	case "pull_request":
+		pr := p.PullRequest
+		e.Number, e.Title, e.URL, e.Branch = pr.Number, pr.Title, pr.HTMLURL, pr.Head.Ref
+		switch p.Action {
+		case "opened", "reopened", "ready_for_review":
+			e.Kind, e.Priority = "pull_request."+p.Action, schema.PriorityNormal
+		case "closed":
+			e.Kind, e.Priority = "pull_request.closed", schema.PriorityLow
+			if pr.Merged {
+				e.Kind = "pull_request.merged"
+			}
+		case "review_requested":
+			e.Kind, e.Priority = "pull_request.review_requested", schema.PriorityWake
+			if p.RequestedReviewer.Login != "" {
+				e.Body = "Review requested from " + p.RequestedReviewer.Login + "."
+			}
+		case "synchronize":
+			e.Kind, e.Priority = "pull_request.updated", schema.PriorityLow
+		default:
+			return Event{}, false, nil
+		}
+	case "pull_request_review":
+		if p.Action != "submitted" {
+			return Event{}, false, nil
+		}
+		pr := p.PullRequest
+		e.Number, e.Title, e.URL, e.Branch = pr.Number, pr.Title, p.Review.HTMLURL, pr.Head.Ref
+		e.Body = clip(p.Review.Body)
+		switch strings.ToLower(p.Review.State) {
+		case "changes_requested":
+			e.Kind, e.Priority = "pull_request.changes_requested", schema.PriorityWake
+		case "approved":
+			e.Kind, e.Priority = "pull_request.approved", schema.PriorityNormal
+		default:
+			e.Kind, e.Priority = "pull_request.reviewed", schema.PriorityNormal
+		}
+	case "pull_request_review_comment":
+		if p.Action != "created" {
+			return Event{}, false, nil
+		}
+		pr := p.PullRequest
+		e.Kind, e.Priority = "pull_request.commented", schema.PriorityNormal
+		e.Number, e.Title, e.URL, e.Branch = pr.Number, pr.Title, p.Comment.HTMLURL, pr.Head.Ref
+		e.Body = clip(p.Comment.Body)
+	case "issue_comment":
+		if p.Action != "created" {
+			return Event{}, false, nil
+		}
+		e.Kind, e.Priority = "issue.commented", schema.PriorityNormal
+		if len(p.Issue.PullRequest) > 0 && string(p.Issue.PullRequest) != "null" {
+			e.Kind = "pull_request.commented"
+		}
+		e.Number, e.Title, e.URL = p.Issue.Number, p.Issue.Title, p.Comment.HTMLURL
+		e.Body = clip(p.Comment.Body)
+	case "issues":
+		e.Number, e.Title, e.URL = p.Issue.Number, p.Issue.Title, p.Issue.HTMLURL
+		switch p.Action {
+		case "opened", "reopened":
+			e.Kind, e.Priority = "issue."+p.Action, schema.PriorityNormal
+		case "assigned":
+			e.Kind, e.Priority = "issue.assigned", schema.PriorityWake
+			if p.Assignee.Login != "" {
+				e.Body = "Assigned to " + p.Assignee.Login + "."
+			}
+		case "closed":
+			e.Kind, e.Priority = "issue.closed", schema.PriorityLow
+		default:
+			return Event{}, false, nil
+		}
+	case "check_run":
+		run := p.CheckRun
+		if p.Action != "completed" || !failedConclusions[run.Conclusion] {
+			return Event{}, false, nil
+		}
+		e.Kind, e.Priority = "ci.failed", schema.PriorityWake
+		e.Title = fmt.Sprintf("check %q %s", run.Name, strings.ReplaceAll(run.Conclusion, "_", " "))
+		e.URL, e.Branch = run.HTMLURL, run.CheckSuite.HeadBranch
+		if len(run.PullRequests) > 0 {
+			e.Number = run.PullRequests[0].Number
+		}
+	case "workflow_run":
+		run := p.WorkflowRun
+		if p.Action != "completed" || !failedConclusions[run.Conclusion] {
+			return Event{}, false, nil
+		}
+		e.Kind, e.Priority = "ci.failed", schema.PriorityWake
+		e.Title = fmt.Sprintf("workflow %q %s", run.Name, strings.ReplaceAll(run.Conclusion, "_", " "))
+		e.URL, e.Branch = run.HTMLURL, run.HeadBranch
+		if len(run.PullRequests) > 0 {
+			e.Number = run.PullRequests[0].Number
+		}
+	default:
+		return Event{}, false, nil
+	}
+	return e, true, nil
+}
+
+func clip(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= maxEventBodyChars {
+		return text
+	}
+	return strings.TrimSpace(text[:maxEventBodyChars]) + " …"
+}
@@ -0,0 +1,395 @@
+// Package github connects agents to GitHub repositories. Webhooks from the
+// repositories an agent watches are normalized into events with a priority
+// and delivered to the agent's input; agents comment on issues and pull
+// requests through the GitHub API with a token kept in the secrets store.
+package github
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/secrets"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	// DefaultAPIBase is the GitHub REST API; GitHub Enterprise servers use
+	// https://<host>/api/v3.
+	DefaultAPIBase = "https://api.github.com"
+	// secretName is the secrets store entry holding an agent's credentials.
+	secretName = "github"
+	// maxCommentLength is GitHub's limit on a comment body.
+	maxCommentLength = 65536
+	// deliveryRetention is how long delivery IDs are kept to drop
+	// redelivered webhooks.
+	deliveryRetention = 7 * 24 * time.Hour
+)
+
+var (
+	ErrNotConfigured = errors.New("github not configured")
+	// ErrBadSignature is returned for a webhook that no watching agent's
+	// secret signed.
+	ErrBadSignature = errors.New("webhook signature does not match")
+)
+
+// Account is an agent's GitHub configuration. Credentials are kept apart in
+// the secrets store and never returned.
+type Account struct {
+	AgentID string `json:"agent_id"`
+	// Repos lists the "owner/name" repositories whose webhooks reach the
+	// agent and that it may comment on; "owner/*" matches every repository
+	// of owner.
+	Repos []string `json:"repos"`
+	// Events limits which event kinds are delivered, such as "ci.failed"
+	// or "pull_request.*"; empty delivers every kind.
+	Events []string `json:"events,omitempty"`
+	// Priorities overrides the default priority of event kinds.
+	Priorities map[string]string `json:"priorities,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Credentials authenticate an account. Token is used for API calls and
+// WebhookSecret verifies the signature of incoming webhooks.
+type Credentials struct {
+	Token         string `json:"token,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// Comment is a comment posted on an issue or pull request.
+type Comment struct {
+	ID     int64  `json:"id"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// DeliverFunc hands an event to an agent.
+type DeliverFunc func(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error)
+
+type Service struct {
+	db      *sql.DB
+	bus     *eventbus.Bus
+	secrets *secrets.Store
+
+	client    *http.Client
+	apiBase   string
+	deliverFn DeliverFunc
+	nowFn     func() time.Time
+}
+
+type Option func(*Service)
+
+// WithDeliverer replaces how events reach agents; the runtime uses it to
+// start the agent's loop. By default they are pushed to the agent's
+// task_input.
+func WithDeliverer(fn DeliverFunc) Option {
+	return func(s *Service) {
+		if fn != nil {
+			s.deliverFn = fn
+		}
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithAPIBase points API calls at a GitHub Enterprise server or a test
+// server instead of DefaultAPIBase.
+func WithAPIBase(base string) Option {
+	return func(s *Service) {
+		if base = strings.TrimRight(strings.TrimSpace(base), "/"); base != "" {
+			s.apiBase = base
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Service) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewService(db *sql.DB, bus *eventbus.Bus, store *secrets.Store, opts ...Option) *Service {
+	s := &Service{
+		db:      db,
+		bus:     bus,
+		secrets: store,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		apiBase: DefaultAPIBase,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+	}
+	s.deliverFn = s.pushEvent
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Service) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Configure sets agentID's GitHub account and stores its credentials,
+// replacing any previous account.
+func (s *Service) Configure(ctx context.Context, acct Account, creds Credentials) (Account, error) {
+	if s.secrets == nil {
+		return Account{}, fmt.Errorf("secrets store unavailable")
+	}
+	acct.AgentID = strings.TrimSpace(acct.AgentID)
+	if acct.AgentID == "" {
+		return Account{}, fmt.Errorf("agent_id is required")
+	}
+	repos := make([]string, 0, len(acct.Repos))
+	for _, repo := range acct.Repos {
+		repo = strings.ToLower(strings.TrimSpace(repo))
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return Account{}, fmt.Errorf("repo %q must be owner/name or owner/*", repo)
+		}
+		repos = append(repos, repo)
+	}
+	if len(repos) == 0 {
+		return Account{}, fmt.Errorf("repos is required")
+	}
+	acct.Repos = repos
+	events := make([]string, 0, len(acct.Events))
+	for _, kind := range acct.Events {
+		if kind = strings.ToLower(strings.TrimSpace(kind)); kind != "" {
+			events = append(events, kind)
+		}
+	}
+	acct.Events = events
+	priorities := map[string]string{}
+	for kind, raw := range acct.Priorities {
+		p, err := schema.ValidatePriority(raw)
+		if err != nil {
+			return Account{}, fmt.Errorf("priority for %s: %w", kind, err)
+		}
+		priorities[strings.ToLower(strings.TrimSpace(kind))] = string(p)
+	}
+	acct.Priorities = priorities
+	creds.Token = strings.TrimSpace(creds.Token)
+	creds.WebhookSecret = strings.TrimSpace(creds.WebhookSecret)
+	if creds.WebhookSecret == "" {
+		return Account{}, fmt.Errorf("credentials.webhook_secret is required")
+	}
+
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return Account{}, fmt.Errorf("encode credentials: %w", err)
+	}
+	if err := s.secrets.Put(ctx, acct.AgentID, secretName, data); err != nil {
+		return Account{}, err
+	}
+	reposJSON, _ := json.Marshal(acct.Repos)
+	eventsJSON, _ := json.Marshal(acct.Events)
+	prioritiesJSON, _ := json.Marshal(acct.Priorities)
+	now := s.now().Format(state.TimeLayout)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO github_accounts (agent_id, repos, events, priorities, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id) DO UPDATE SET repos = excluded.repos, events = excluded.events,
+			priorities = excluded.priorities, updated_at = excluded.updated_at
+	`, acct.AgentID, string(reposJSON), string(eventsJSON), string(prioritiesJSON), now, now); err != nil {
+		return Account{}, fmt.Errorf("store github account: %w", err)
+	}
+	return s.Account(ctx, acct.AgentID)
+}
+
+// Account returns agentID's GitHub account, or ErrNotConfigured.
+func (s *Service) Account(ctx context.Context, agentID string) (Account, error) {
+	list, err := s.accounts(ctx, `WHERE agent_id = ?`, strings.TrimSpace(agentID))
+	if err != nil {
+		return Account{}, err
+	}
+	if len(list) == 0 {
+		return Account{}, ErrNotConfigured
+	}
+	return list[0], nil
+}
+
+// Remove deletes agentID's account and credentials and reports whether an
+// account existed.
+func (s *Service) Remove(ctx context.Context, agentID string) (bool, error) {
+	agentID = strings.TrimSpace(agentID)
+	res, err := s.db.ExecContext(ctx, `DELETE FROM github_accounts WHERE agent_id = ?`, agentID)
+	if err != nil {
+		return false, fmt.Errorf("delete github account: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete github account rows affected: %w", err)
+	}
+	if s.secrets != nil {
+		if _, err := s.secrets.Delete(ctx, agentID, secretName); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, nil
+}
+
+// Watches reports whether the account covers repo.
+func (a Account) Watches(repo string) bool {
+	repo = strings.ToLower(strings.TrimSpace(repo))
+	owner, _, _ := strings.Cut(repo, "/")
+	for _, pattern := range a.Repos {
+		if pattern == repo || pattern == owner+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// wants reports whether events of kind are delivered to the account.
+// Patterns ending in ".*" match every kind with that prefix.
+func (a Account) wants(kind string) bool {
+	if len(a.Events) == 0 {
+		return true
+	}
+	for _, pattern := range a.Events {
+		if pattern == kind || (strings.HasSuffix(pattern, ".*") && strings.HasPrefix(kind, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// priority returns the priority events of kind are delivered at.
+func (a Account) priority(kind string, fallback schema.Priority) string {
+	if p, ok := a.Priorities[kind]; ok {
+		return p
+	}
+	return string(fallback)
+}
+
+// Comment posts body as agentID's comment on issue or pull request number
+// of repo, which the agent's account must cover.
+func (s *Service) Comment(ctx context.Context, agentID, repo string, number int, body string) (Comment, error) {
+	repo = strings.ToLower(strings.TrimSpace(repo))
+	body = strings.TrimSpace(body)
+	if number <= 0 {
+		return Comment{}, fmt.Errorf("number must be positive")
+	}
+	if body == "" {
+		return Comment{}, fmt.Errorf("body is required")
+	}
+	if len(body) > maxCommentLength {
+		return Comment{}, fmt.Errorf("body must be at most %d characters", maxCommentLength)
+	}
+	acct, err := s.Account(ctx, agentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	if !acct.Watches(repo) {
+		return Comment{}, fmt.Errorf("repo %s is not configured for this agent", repo)
+	}
+	creds, err := s.credentials(ctx, acct.AgentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	if creds.Token == "" {
+		return Comment{}, fmt.Errorf("github account has no token")
+	}
+	owner, name, _ := strings.Cut(repo, "/")
+	payload, _ := json.Marshal(map[string]string{"body": body})
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments", s.apiBase, url.PathEscape(owner), url.PathEscape(name), number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return Comment{}, fmt.Errorf("build comment request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+creds.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Comment{}, fmt.Errorf("post comment: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return Comment{}, fmt.Errorf("post comment: github returned %d: %s", resp.StatusCode, apiErr.Message)
+	}
+	var created struct {
+		ID      int64  `json:"id"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return Comment{}, fmt.Errorf("decode comment: %w", err)
+	}
+	return Comment{ID: created.ID, Repo: repo, Number: number, URL: created.HTMLURL}, nil
+}
+
+func (s *Service) credentials(ctx context.Context, agentID string) (Credentials, error) {
+	if s.secrets == nil {
+		return Credentials{}, fmt.Errorf("secrets store unavailable")
+	}
+	data, err := s.secrets.Get(ctx, agentID, secretName)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("github credentials: %w", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("decode github credentials: %w", err)
+	}
+	return creds, nil
+}
+
+const accountColumns = `agent_id, repos, events, priorities, created_at, updated_at`
+
+func (s *Service) accounts(ctx context.Context, where string, args ...any) ([]Account, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accountColumns+` FROM github_accounts `+where+` ORDER BY agent_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query github accounts: %w", err)
+	}
+	defer rows.Close()
+	out := []Account{}
+	for rows.Next() {
+		var acct Account
+		var repos, events, priorities, createdAt, updatedAt string
+		if err := rows.Scan(&acct.AgentID, &repos, &events, &priorities, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan github account: %w", err)
+		}
+		_ = json.Unmarshal([]byte(repos), &acct.Repos)
+		_ = json.Unmarshal([]byte(events), &acct.Events)
+		_ = json.Unmarshal([]byte(priorities), &acct.Priorities)
+		acct.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		acct.UpdatedAt, _ = time.Parse(state.TimeLayout, updatedAt)
+		out = append(out, acct)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate github accounts: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,166 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/secrets"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type delivery struct {
+	target, body string
+	meta         map[string]any
+}
+
+func newTestService(t *testing.T, opts ...Option) (*Service, *[]delivery) {
+	t.Helper()
+	db, closeFn := testutil.OpenTestDB(t)
+	t.Cleanup(closeFn)
+	store, err := secrets.NewStore(db, bytes.Repeat([]byte{1}, secrets.KeySize))
+	if err != nil {
+		t.Fatalf("secrets: %v", err)
+	}
+	var got []delivery
+	opts = append([]Option{WithDeliverer(func(_ context.Context, target, body, _ string, meta map[string]any) (eventbus.Event, error) {
+		got = append(got, delivery{target, body, meta})
+		return eventbus.Event{}, nil
+	})}, opts...)
+	return NewService(db, eventbus.NewBus(db), store, opts...), &got
+}
+
+func TestHandleWebhookRoutesByRepoWithPriorities(t *testing.T) {
+	svc, got := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Configure(ctx, Account{AgentID: "reviewer", Repos: []string{"Acme/*"}}, Credentials{WebhookSecret: "s1"}); err != nil {
+		t.Fatalf("configure reviewer: %v", err)
+	}
+	if _, err := svc.Configure(ctx, Account{
+		AgentID:    "ci-watcher",
+		Repos:      []string{"acme/api"},
+		Events:     []string{"ci.*"},
+		Priorities: map[string]string{"ci.failed": "interrupt"},
+	}, Credentials{WebhookSecret: "s2"}); err != nil {
+		t.Fatalf("configure ci-watcher: %v", err)
+	}
+
+	review := []byte(`{"action":"review_requested","repository":{"full_name":"acme/api"},"sender":{"login":"octo"},
+		"requested_reviewer":{"login":"bot"},"pull_request":{"number":7,"title":"Add llamas","html_url":"https://github.com/acme/api/pull/7","head":{"ref":"llamas"}}}`)
+	result, err := svc.HandleWebhook(ctx, Webhook{Event: "pull_request", DeliveryID: "d1", Signature: Sign("s1", review), Body: review})
+	if err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	if len(result.Delivered) != 1 || result.Delivered[0] != "reviewer" {
+		t.Fatalf("expected delivery to reviewer only, got %+v", result)
+	}
+	first := (*got)[0]
+	if first.meta["kind"] != "github" || first.meta["priority"] != "wake" || first.meta["github_event"] != "pull_request.review_requested" || first.meta["number"] != 7 {
+		t.Fatalf("unexpected metadata %+v", first.meta)
+	}
+	if !strings.Contains(first.body, "acme/api #7: Add llamas") || !strings.Contains(first.body, "Review requested from bot.") {
+		t.Fatalf("unexpected body %q", first.body)
+	}
+
+	// A redelivery is dropped.
+	if result, err := svc.HandleWebhook(ctx, Webhook{Event: "pull_request", DeliveryID: "d1", Signature: Sign("s1", review), Body: review}); err != nil || len(result.Delivered) != 0 {
+		t.Fatalf("expected the redelivery to be dropped, got %+v %v", result, err)
+	}
+
+	failed := []byte(`{"action":"completed","repository":{"full_name":"acme/api"},
+		"workflow_run":{"name":"test","conclusion":"failure","head_branch":"main","html_url":"https://github.com/acme/api/actions/runs/1"}}`)
+	result, err = svc.HandleWebhook(ctx, Webhook{Event: "workflow_run", DeliveryID: "d2", Signature: Sign("s2", failed), Body: failed})
+	if err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	if len(result.Delivered) != 1 || result.Delivered[0] != "ci-watcher" {
+		t.Fatalf("expected only ci-watcher's secret to verify, got %+v", result)
+	}
+	if last := (*got)[len(*got)-1]; last.meta["priority"] != "interrupt" || last.meta["github_event"] != "ci.failed" {
+		t.Fatalf("expected the priority override, got %+v", last.meta)
+	}
+
+	// ci-watcher filters out pull request events.
+	opened := []byte(`{"action":"opened","repository":{"full_name":"acme/api"},"pull_request":{"number":8}}`)
+	if result, err := svc.HandleWebhook(ctx, Webhook{Event: "pull_request", DeliveryID: "d3", Signature: Sign("s2", opened), Body: opened}); err != nil || len(result.Delivered) != 0 {
+		t.Fatalf("expected the event to be filtered, got %+v %v", result, err)
+	}
+
+	if _, err := svc.HandleWebhook(ctx, Webhook{Event: "pull_request", DeliveryID: "d4", Signature: Sign("wrong", opened), Body: opened}); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+	other := []byte(`{"action":"opened","repository":{"full_name":"other/repo"},"issue":{"number":1}}`)
+	if result, err := svc.HandleWebhook(ctx, Webhook{Event: "issues", Signature: Sign("wrong", other), Body: other}); err != nil || result.Ignored == "" {
+		t.Fatalf("expected unwatched repositories to be ignored, got %+v %v", result, err)
+	}
+}
+
+func TestNormalizeMapsEvents(t *testing.T) {
+	cases := []struct {
+		event, body, kind, priority string
+	}{
+		{"pull_request", `{"action":"closed","pull_request":{"merged":true}}`, "pull_request.merged", "low"},
+		{"pull_request_review", `{"action":"submitted","review":{"state":"changes_requested"}}`, "pull_request.changes_requested", "wake"},
+		{"issue_comment", `{"action":"created","issue":{"number":3,"pull_request":{"url":"x"}}}`, "pull_request.commented", "normal"},
+		{"issue_comment", `{"action":"created","issue":{"number":3}}`, "issue.commented", "normal"},
+		{"issues", `{"action":"assigned","assignee":{"login":"bot"}}`, "issue.assigned", "wake"},
+		{"check_run", `{"action":"completed","check_run":{"name":"lint","conclusion":"timed_out"}}`, "ci.failed", "wake"},
+	}
+	for _, c := range cases {
+		e, ok, err := Normalize(c.event, []byte(c.body))
+		if err != nil || !ok || e.Kind != c.kind || string(e.Priority) != c.priority {
+			t.Fatalf("Normalize(%s, %s) = %+v %v %v, want %s at %s", c.event, c.body, e, ok, err, c.kind, c.priority)
+		}
+	}
+	for _, body := range []string{
+		`{"action":"completed","check_run":{"conclusion":"success"}}`,
+		`{"action":"labeled"}`,
+	} {
+		if _, ok, err := Normalize("check_run", []byte(body)); ok || err != nil {
+			t.Fatalf("expected %s to be skipped", body)
+		}
+	}
+}
+
+func TestCommentPostsWithStoredToken(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Body string `json:"body"`
+		}
+		_ = json.Unmarshal(data, &payload)
+		gotBody = payload.Body
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":42,"html_url":"https://github.com/acme/api/issues/7#issuecomment-42"}`))
+	}))
+	defer srv.Close()
+	svc, _ := newTestService(t, WithAPIBase(srv.URL), WithHTTPClient(srv.Client()))
+	ctx := context.Background()
+
+	if _, err := svc.Comment(ctx, "agent-a", "acme/api", 7, "Looks good."); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+	if _, err := svc.Configure(ctx, Account{AgentID: "agent-a", Repos: []string{"acme/api"}}, Credentials{Token: "tok", WebhookSecret: "s"}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	c, err := svc.Comment(ctx, "agent-a", "Acme/API", 7, "Looks good.")
+	if err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+	if c.ID != 42 || gotPath != "/repos/acme/api/issues/7/comments" || gotAuth != "Bearer tok" || gotBody != "Looks good." {
+		t.Fatalf("unexpected comment %+v path=%s auth=%s body=%q", c, gotPath, gotAuth, gotBody)
+	}
+	if _, err := svc.Comment(ctx, "agent-a", "acme/web", 1, "Hi."); err == nil {
+		t.Fatalf("expected repositories outside the account to be refused")
+	}
+}
@@ -0,0 +1,199 @@
+package github
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// Webhook is one delivery to the webhook endpoint, with the values of its
+// X-GitHub-Event, X-GitHub-Delivery and X-Hub-Signature-256 headers.
+type Webhook struct {
+	Event      string
+	DeliveryID string
+	Signature  string
+	Body       []byte
+}
+
+// WebhookResult reports what happened to a webhook. Ignored says why
+// nothing was delivered.
+type WebhookResult struct {
+	Event     *Event   `json:"event,omitempty"`
+	Delivered []string `json:"delivered"`
+	Ignored   string   `json:"ignored,omitempty"`
+}
+
+// HandleWebhook verifies hook against the webhook secrets of the agents
+// watching its repository, normalizes it and delivers it to each of them
+// whose events filter takes it, at the account's priority for its kind.
+// Redeliveries of a delivery ID an agent already received are dropped. It
+// returns ErrBadSignature when agents watch the repository but none of
+// their secrets signed the body.
+func (s *Service) HandleWebhook(ctx context.Context, hook Webhook) (WebhookResult, error) {
+	result := WebhookResult{Delivered: []string{}}
+	hook.Event = strings.TrimSpace(hook.Event)
+	if hook.Event == "" {
+		return result, fmt.Errorf("X-GitHub-Event header is required")
+	}
+	var head struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(hook.Body, &head); err != nil {
+		return result, fmt.Errorf("decode webhook: %w", err)
+	}
+	repo := strings.ToLower(head.Repository.FullName)
+	if repo == "" {
+		result.Ignored = "no repository"
+		return result, nil
+	}
+	all, err := s.accounts(ctx, ``)
+	if err != nil {
+		return result, err
+	}
+	var watching []Account
+	for _, acct := range all {
+		if !acct.Watches(repo) {
+			continue
+		}
+		creds, err := s.credentials(ctx, acct.AgentID)
+		if err != nil || !validSignature(creds.WebhookSecret, hook.Signature, hook.Body) {
+			continue
+		}
+		watching = append(watching, acct)
+	}
+	if len(watching) == 0 {
+		for _, acct := range all {
+			if acct.Watches(repo) {
+				return result, ErrBadSignature
+			}
+		}
+		result.Ignored = "no agent watches " + repo
+		return result, nil
+	}
+	if hook.Event == "ping" {
+		result.Ignored = "ping"
+		return result, nil
+	}
+	event, ok, err := Normalize(hook.Event, hook.Body)
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		result.Ignored = "unhandled event " + hook.Event
+		return result, nil
+	}
+	result.Event = &event
+	if err := s.pruneDeliveries(ctx); err != nil {
+		return result, err
+	}
+
+	var errs []error
+	for _, acct := range watching {
+		if !acct.wants(event.Kind) {
+			continue
+		}
+		fresh, err := s.recordDelivery(ctx, hook.DeliveryID, acct.AgentID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !fresh {
+			continue
+		}
+		meta := event.Metadata()
+		meta["kind"] = "github"
+		meta["priority"] = acct.priority(event.Kind, event.Priority)
+		if hook.DeliveryID != "" {
+			meta["delivery_id"] = hook.DeliveryID
+		}
+		if _, err := s.deliverFn(ctx, acct.AgentID, event.Text(), "github", meta); err != nil {
+			errs = append(errs, fmt.Errorf("deliver to %s: %w", acct.AgentID, err))
+			continue
+		}
+		result.Delivered = append(result.Delivered, acct.AgentID)
+	}
+	if len(result.Delivered) == 0 && len(errs) == 0 {
+		result.Ignored = "filtered or already delivered"
+	}
+	return result, errors.Join(errs...)
+}
+
+// validSignature checks an X-Hub-Signature-256 header against body.
+func validSignature(secret, signature string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(strings.TrimSpace(signature), "sha256=")
+	if !ok {
+		return false
+	}
+	sum, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// Sign returns the X-Hub-Signature-256 value GitHub sends for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordDelivery notes that agentID received deliveryID and reports whether
+// it had not before. Webhooks without a delivery ID are always fresh.
+func (s *Service) recordDelivery(ctx context.Context, deliveryID, agentID string) (bool, error) {
+	if strings.TrimSpace(deliveryID) == "" {
+		return true, nil
+	}
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO github_deliveries (delivery_id, agent_id, received_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(delivery_id, agent_id) DO NOTHING
+	`, strings.TrimSpace(deliveryID), agentID, s.now().Format(state.TimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("record github delivery: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Service) pruneDeliveries(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM github_deliveries WHERE received_at < ?`,
+		s.now().Add(-deliveryRetention).Format(state.TimeLayout)); err != nil {
+		return fmt.Errorf("prune github deliveries: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) pushEvent(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error) {
+	if s.bus == nil {
+		return eventbus.Event{}, fmt.Errorf("event bus unavailable")
+	}
+	metadata := map[string]any{"source": source, "target": target}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	return s.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   target,
+		Subject:   "github: " + schema.GetMetaString(meta, "github_event"),
+		Body:      body,
+		Metadata:  metadata,
+	})
+}
@@ -0,0 +1,301 @@
+// Package governor shapes the requests agentd makes to LLM providers. It
+// caps how many requests run at once and how many start per second, both
+// across all providers and per provider, and queues the rest. Queued
+// requests are granted fairly: when a slot frees, the agent that was served
+// least recently goes first, so one busy agent cannot starve the others.
+package governor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits caps requests. Zero leaves that dimension unlimited.
+type Limits struct {
+	MaxConcurrent int     `json:"max_concurrent"`
+	QPS           float64 `json:"qps"`
+}
+
+func (l Limits) validate() error {
+	if l.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent must not be negative")
+	}
+	if l.QPS < 0 {
+		return fmt.Errorf("qps must not be negative")
+	}
+	return nil
+}
+
+// Config is the config file's provider_limits section. The global limits
+// apply to all providers together; Providers adds limits per provider
+// name, such as "anthropic". A request needs room under both.
+type Config struct {
+	MaxConcurrent int               `json:"max_concurrent"`
+	QPS           float64           `json:"qps"`
+	Providers     map[string]Limits `json:"providers"`
+}
+
+// Validate checks that no limit is negative.
+func (c Config) Validate() error {
+	if err := (Limits{MaxConcurrent: c.MaxConcurrent, QPS: c.QPS}).validate(); err != nil {
+		return err
+	}
+	for name, limits := range c.Providers {
+		if err := limits.validate(); err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Stats describes one limiter: the global one when Provider is empty.
+type Stats struct {
+	Provider      string  `json:"provider,omitempty"`
+	MaxConcurrent int     `json:"max_concurrent,omitempty"`
+	QPS           float64 `json:"qps,omitempty"`
+	Active        int     `json:"active"`
+	Queued        int     `json:"queued"`
+	// Requests counts granted requests and Waited those that had to queue.
+	Requests    int64 `json:"requests"`
+	Waited      int64 `json:"waited"`
+	TotalWaitMs int64 `json:"total_wait_ms"`
+	MaxWaitMs   int64 `json:"max_wait_ms"`
+}
+
+// Snapshot is the state of a Governor.
+type Snapshot struct {
+	Global    Stats   `json:"global"`
+	Providers []Stats `json:"providers"`
+}
+
+type limiter struct {
+	limits Limits
+	active int
+	// next is when the rate allows the next request to start.
+	next  time.Time
+	stats Stats
+}
+
+// ready reports whether a request may start now. A request blocked by the
+// rate alone may start at the returned time; one blocked by concurrency
+// waits for a release.
+func (l *limiter) ready(now time.Time) (bool, time.Time) {
+	if l.limits.MaxConcurrent > 0 && l.active >= l.limits.MaxConcurrent {
+		return false, time.Time{}
+	}
+	if l.limits.QPS > 0 && now.Before(l.next) {
+		return false, l.next
+	}
+	return true, time.Time{}
+}
+
+func (l *limiter) take(now time.Time, wait time.Duration) {
+	l.active++
+	if l.limits.QPS > 0 {
+		l.next = now.Add(time.Duration(float64(time.Second) / l.limits.QPS))
+	}
+	l.stats.Requests++
+	l.stats.TotalWaitMs += wait.Milliseconds()
+	l.stats.MaxWaitMs = max(l.stats.MaxWaitMs, wait.Milliseconds())
+}
+
+type waiter struct {
+	provider string
+	key      string
+	enqueued time.Time
+	ready    chan struct{}
+	granted  bool
+	wait     time.Duration
+}
+
+// Governor grants provider requests within its limits. A nil Governor
+// grants every request at once.
+type Governor struct {
+	config Config
+
+	mu        sync.Mutex
+	global    *limiter
+	providers map[string]*limiter
+	queue     []*waiter
+	// served is the grant sequence at which each key was last served.
+	served map[string]uint64
+	seq    uint64
+	timer  *time.Timer
+}
+
+func New(c Config) *Governor {
+	return &Governor{
+		config:    c,
+		global:    &limiter{limits: Limits{MaxConcurrent: c.MaxConcurrent, QPS: c.QPS}},
+		providers: map[string]*limiter{},
+		served:    map[string]uint64{},
+	}
+}
+
+// Permit is a granted request. Release it once the provider has answered.
+type Permit struct {
+	// Wait is how long the request was queued.
+	Wait    time.Duration
+	release func()
+}
+
+// Release frees the permit's slot. It is safe to call more than once.
+func (p Permit) Release() {
+	if p.release != nil {
+		p.release()
+	}
+}
+
+// Acquire waits until a request to provider may start. key identifies who
+// is asking, usually the agent, for fair queueing. It fails only when ctx
+// ends first.
+func (g *Governor) Acquire(ctx context.Context, provider, key string) (Permit, error) {
+	if g == nil {
+		return Permit{}, nil
+	}
+	w := &waiter{provider: provider, key: key, enqueued: time.Now(), ready: make(chan struct{})}
+	g.mu.Lock()
+	g.queue = append(g.queue, w)
+	g.dispatchLocked()
+	if !w.granted {
+		g.global.stats.Waited++
+		g.providerLocked(provider).stats.Waited++
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return Permit{Wait: w.wait, release: sync.OnceFunc(func() { g.release(provider) })}, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		granted := w.granted
+		if !granted {
+			g.queue = slices.DeleteFunc(g.queue, func(q *waiter) bool { return q == w })
+		}
+		g.mu.Unlock()
+		if granted {
+			g.release(provider)
+		}
+		return Permit{}, ctx.Err()
+	}
+}
+
+func (g *Governor) release(provider string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.global.active--
+	g.providerLocked(provider).active--
+	g.dispatchLocked()
+}
+
+func (g *Governor) providerLocked(name string) *limiter {
+	l, ok := g.providers[name]
+	if !ok {
+		l = &limiter{limits: g.config.Providers[name]}
+		g.providers[name] = l
+	}
+	return l
+}
+
+// dispatchLocked grants queued requests while the limits allow, each time
+// to the least recently served key, and arms a timer for requests held
+// back by a rate.
+func (g *Governor) dispatchLocked() {
+	now := time.Now()
+	var wake time.Time
+	for {
+		best := -1
+		heads := map[string]bool{}
+		for i, w := range g.queue {
+			// Only a key's oldest request for each provider competes.
+			head := w.key + "\x00" + w.provider
+			if heads[head] {
+				continue
+			}
+			heads[head] = true
+			ok, at := g.allowLocked(w.provider, now)
+			if !ok {
+				if !at.IsZero() && (wake.IsZero() || at.Before(wake)) {
+					wake = at
+				}
+				continue
+			}
+			if best < 0 || g.served[w.key] < g.served[g.queue[best].key] {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		w := g.queue[best]
+		g.queue = slices.Delete(g.queue, best, best+1)
+		w.wait = now.Sub(w.enqueued)
+		g.global.take(now, w.wait)
+		g.providerLocked(w.provider).take(now, w.wait)
+		g.seq++
+		g.served[w.key] = g.seq
+		w.granted = true
+		close(w.ready)
+	}
+	if wake.IsZero() || len(g.queue) == 0 {
+		return
+	}
+	if g.timer == nil {
+		g.timer = time.AfterFunc(wake.Sub(now), func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.dispatchLocked()
+		})
+	} else {
+		g.timer.Reset(wake.Sub(now))
+	}
+}
+
+func (g *Governor) allowLocked(provider string, now time.Time) (bool, time.Time) {
+	okGlobal, atGlobal := g.global.ready(now)
+	okProvider, atProvider := g.providerLocked(provider).ready(now)
+	switch {
+	case okGlobal && okProvider:
+		return true, time.Time{}
+	case !okGlobal && atGlobal.IsZero(), !okProvider && atProvider.IsZero():
+		return false, time.Time{}
+	}
+	if atGlobal.After(atProvider) {
+		return false, atGlobal
+	}
+	return false, atProvider
+}
+
+// Snapshot returns the current state of every limiter, providers sorted by
+// name.
+func (g *Governor) Snapshot() Snapshot {
+	if g == nil {
+		return Snapshot{Providers: []Stats{}}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	queued := map[string]int{}
+	for _, w := range g.queue {
+		queued[w.provider]++
+	}
+	describe := func(name string, l *limiter) Stats {
+		s := l.stats
+		s.Provider = name
+		s.MaxConcurrent = l.limits.MaxConcurrent
+		s.QPS = l.limits.QPS
+		s.Active = l.active
+		s.Queued = queued[name]
+		return s
+	}
+	out := Snapshot{Global: describe("", g.global), Providers: []Stats{}}
+	out.Global.Queued = len(g.queue)
+	for name, l := range g.providers {
+		out.Providers = append(out.Providers, describe(name, l))
+	}
+	slices.SortFunc(out.Providers, func(a, b Stats) int { return strings.Compare(a.Provider, b.Provider) })
+	return out
+}
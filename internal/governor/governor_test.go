@@ -0,0 +1,112 @@
+package governor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireQueuesBeyondMaxConcurrentAndServesKeysFairly(t *testing.T) {
+	g := New(Config{MaxConcurrent: 1})
+	ctx := context.Background()
+	first, err := g.Acquire(ctx, "anthropic", "busy")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// The busy agent queues two more requests before the quiet one asks.
+	order := make(chan string, 3)
+	acquire := func(key string) {
+		p, err := g.Acquire(ctx, "anthropic", key)
+		if err != nil {
+			t.Errorf("acquire %s: %v", key, err)
+			return
+		}
+		order <- key
+		p.Release()
+	}
+	go acquire("busy")
+	waitQueued(t, g, 1)
+	go acquire("busy")
+	waitQueued(t, g, 2)
+	go acquire("quiet")
+	waitQueued(t, g, 3)
+
+	if s := g.Snapshot(); s.Global.Active != 1 || s.Providers[0].Provider != "anthropic" || s.Providers[0].Queued != 3 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+	first.Release()
+	first.Release()
+
+	var got []string
+	for range 3 {
+		got = append(got, <-order)
+	}
+	if got[0] != "quiet" {
+		t.Fatalf("expected the least recently served agent first, got %v", got)
+	}
+	s := g.Snapshot()
+	if s.Global.Active != 0 || s.Global.Queued != 0 || s.Global.Requests != 4 || s.Global.Waited != 3 {
+		t.Fatalf("unexpected snapshot after release %+v", s.Global)
+	}
+}
+
+func TestAcquireSpacesRequestsByProviderQPS(t *testing.T) {
+	g := New(Config{Providers: map[string]Limits{"openai": {QPS: 20}}})
+	ctx := context.Background()
+	start := time.Now()
+	for range 3 {
+		p, err := g.Acquire(ctx, "openai", "a")
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		p.Release()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected requests spaced 50ms apart, took %v", elapsed)
+	}
+
+	// Another provider is not held back by openai's rate.
+	p, err := g.Acquire(ctx, "anthropic", "a")
+	if err != nil || p.Wait > 10*time.Millisecond {
+		t.Fatalf("expected an unlimited provider granted at once, waited %v: %v", p.Wait, err)
+	}
+	p.Release()
+}
+
+func TestAcquireGivesUpWhenTheContextEnds(t *testing.T) {
+	g := New(Config{MaxConcurrent: 1})
+	held, err := g.Acquire(context.Background(), "anthropic", "a")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.Acquire(ctx, "anthropic", "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline error, got %v", err)
+	}
+	if s := g.Snapshot(); s.Global.Queued != 0 || s.Global.Active != 1 {
+		t.Fatalf("expected the abandoned request dequeued, got %+v", s.Global)
+	}
+	held.Release()
+
+	var nilGovernor *Governor
+	if _, err := nilGovernor.Acquire(context.Background(), "anthropic", "a"); err != nil {
+		t.Fatalf("expected a nil governor to grant at once: %v", err)
+	}
+	if err := (Config{Providers: map[string]Limits{"x": {QPS: -1}}}).Validate(); err == nil {
+		t.Fatalf("expected a negative qps rejected")
+	}
+}
+
+func waitQueued(t *testing.T, g *Governor, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for g.Snapshot().Global.Queued < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued requests", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
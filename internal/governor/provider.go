@@ -0,0 +1,90 @@
+package governor
+
+import (
+	"context"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type waitObserverKey struct{}
+
+// WithWaitObserver returns a context whose governed provider requests
+// report how long they were queued to observe.
+func WithWaitObserver(ctx context.Context, observe func(time.Duration)) context.Context {
+	return context.WithValue(ctx, waitObserverKey{}, observe)
+}
+
+// Wrap returns provider with its requests granted by g under the name
+// name. The agent in each request's context is its fair queueing key. A
+// nil g returns provider unchanged.
+func (g *Governor) Wrap(name string, provider llms.Provider) llms.Provider {
+	if g == nil {
+		return provider
+	}
+	return &governedProvider{Provider: provider, governor: g, name: name}
+}
+
+type governedProvider struct {
+	llms.Provider
+	governor *Governor
+	name     string
+}
+
+func (p *governedProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *llmtools.Toolbox, schema *llmtools.ValueSchema) llms.ProviderStream {
+	permit, err := p.governor.Acquire(ctx, p.name, agentcontext.TaskIDFromContext(ctx))
+	if err != nil {
+		return errStream{err: err}
+	}
+	if observe, ok := ctx.Value(waitObserverKey{}).(func(time.Duration)); ok {
+		observe(permit.Wait)
+	}
+	stream := p.Provider.Generate(ctx, systemPrompt, messages, toolbox, schema)
+	if stream.Err() != nil {
+		permit.Release()
+		return stream
+	}
+	return &governedStream{ProviderStream: stream, release: permit.Release}
+}
+
+// governedStream holds its permit while the provider streams the response.
+// Tools run while the stream is read, so the permit is released once the
+// first tool call is complete rather than after the tool has run.
+type governedStream struct {
+	llms.ProviderStream
+	release func()
+}
+
+func (s *governedStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	inner := s.ProviderStream.Iter()
+	return func(yield func(llms.StreamStatus) bool) {
+		defer s.release()
+		for status := range inner {
+			if status == llms.StreamStatusToolCallReady {
+				s.release()
+			}
+			if !yield(status) {
+				return
+			}
+		}
+	}
+}
+
+// errStream is the stream of a request that was never sent.
+type errStream struct {
+	err error
+}
+
+func (s errStream) Err() error { return s.err }
+func (s errStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(func(llms.StreamStatus) bool) {}
+}
+func (s errStream) Message() llms.Message    { return llms.Message{} }
+func (s errStream) Text() string             { return "" }
+func (s errStream) Image() (string, string)  { return "", "" }
+func (s errStream) Thought() content.Thought { return content.Thought{} }
+func (s errStream) ToolCall() llms.ToolCall  { return llms.ToolCall{} }
+func (s errStream) Usage() llms.Usage        { return llms.Usage{} }
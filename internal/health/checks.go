@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/monitors"
+)
+
+// DBCheck verifies the database accepts writes by touching a probe row.
+func DBCheck(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) (Status, string) {
+		if db == nil {
+			return StatusFailing, "database not configured"
+		}
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO health_probe (id, checked_at) VALUES (1, ?)
+			ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at
+		`, time.Now().UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return StatusFailing, fmt.Sprintf("write probe: %v", err)
+		}
+		return StatusOK, "writable"
+	}
+}
+
+// BusCheck degrades when subscribers are too few or backed up. minSubscribers
+// is the number of long-lived subscriptions the process is expected to hold.
+func BusCheck(bus *eventbus.Bus, minSubscribers int) CheckFunc {
+	return func(ctx context.Context) (Status, string) {
+		if bus == nil {
+			return StatusFailing, "event bus not configured"
+		}
+		stats := bus.Stats()
+		detail := fmt.Sprintf("%d subscribers, %d saturated, %d dropped", stats.Subscribers, stats.Saturated, stats.Dropped)
+		if stats.Subscribers < minSubscribers || stats.Saturated > 0 {
+			return StatusDegraded, detail
+		}
+		return StatusOK, detail
+	}
+}
+
+// MonitorsCheck degrades while any enabled background monitor's last run
+// failed or it is overdue by more than a full interval, and lists paused
+// monitors in the detail.
+func MonitorsCheck(registry *monitors.Registry) CheckFunc {
+	return func(ctx context.Context) (Status, string) {
+		if registry == nil {
+			return StatusOK, "no monitors"
+		}
+		now := time.Now().UTC()
+		var failing, paused []string
+		for _, st := range registry.Statuses() {
+			switch {
+			case !st.Enabled:
+				paused = append(paused, st.Name)
+			case st.LastError != "":
+				failing = append(failing, fmt.Sprintf("%s: %s", st.Name, st.LastError))
+			case st.NextRunAt != nil && !st.Running && now.Sub(*st.NextRunAt) > intervalOf(st):
+				failing = append(failing, st.Name+": overdue")
+			}
+		}
+		detail := "all monitors ok"
+		if len(failing) > 0 {
+			detail = strings.Join(failing, "; ")
+		}
+		if len(paused) > 0 {
+			detail += "; paused: " + strings.Join(paused, ", ")
+		}
+		if len(failing) > 0 {
+			return StatusDegraded, detail
+		}
+		return StatusOK, detail
+	}
+}
+
+func intervalOf(st monitors.Status) time.Duration {
+	d, _ := time.ParseDuration(st.Interval)
+	return d
+}
+
+// Prober is implemented by LLM clients that can check provider reachability.
+type Prober interface {
+	Provider() string
+	Probe(ctx context.Context) error
+}
+
+// LLMCheck degrades when no LLM is configured or its provider is unreachable.
+// Wrap it in Cached so load balancer probes do not hit the provider.
+func LLMCheck(prober Prober) CheckFunc {
+	return func(ctx context.Context) (Status, string) {
+		if prober == nil {
+			return StatusDegraded, "not configured"
+		}
+		if err := prober.Probe(ctx); err != nil {
+			return StatusDegraded, err.Error()
+		}
+		return StatusOK, prober.Provider() + " reachable"
+	}
+}
+
+// DiskCheck reports free space in dir, degrading below warnBytes and failing
+// below failBytes.
+func DiskCheck(dir string, warnBytes, failBytes uint64) CheckFunc {
+	return func(ctx context.Context) (Status, string) {
+		free, err := freeBytes(dir)
+		if err != nil {
+			return StatusDegraded, fmt.Sprintf("stat %s: %v", dir, err)
+		}
+		detail := fmt.Sprintf("%d MiB free", free>>20)
+		switch {
+		case free < failBytes:
+			return StatusFailing, detail
+		case free < warnBytes:
+			return StatusDegraded, detail
+		}
+		return StatusOK, detail
+	}
+}
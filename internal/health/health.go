@@ -0,0 +1,159 @@
+// Package health runs dependency checks for the readiness endpoint.
+//
+// Each check reports ok, degraded or failing. A failing critical check fails
+// the whole report, which load balancers should treat as "take out of
+// rotation"; anything else that is not ok only degrades it.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFailing  Status = "failing"
+)
+
+// CheckFunc reports a check's status and a short human-readable detail.
+type CheckFunc func(ctx context.Context) (Status, string)
+
+type Check struct {
+	Name string
+	// Critical checks fail the report when failing; others degrade it.
+	Critical bool
+	Func     CheckFunc
+}
+
+type Result struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Critical   bool   `json:"critical"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	Status    Status    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// DefaultTimeout bounds each check in a report.
+const DefaultTimeout = 3 * time.Second
+
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+	nowFn   func() time.Time
+}
+
+type Option func(*Checker)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(c *Checker) {
+		if nowFn != nil {
+			c.nowFn = nowFn
+		}
+	}
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(c *Checker) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+func NewChecker(checks []Check, opts ...Option) *Checker {
+	c := &Checker{
+		checks:  append([]Check(nil), checks...),
+		timeout: DefaultTimeout,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+// Run executes every check concurrently and aggregates their statuses.
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results, CheckedAt: c.nowFn()}
+	for _, res := range results {
+		switch {
+		case res.Status == StatusFailing && res.Critical:
+			report.Status = StatusFailing
+		case res.Status != StatusOK && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	type outcome struct {
+		status Status
+		detail string
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		status, detail := check.Func(ctx)
+		done <- outcome{status, detail}
+	}()
+	res := Result{Name: check.Name, Critical: check.Critical}
+	select {
+	case out := <-done:
+		res.Status, res.Detail = out.status, out.detail
+	case <-ctx.Done():
+		res.Status, res.Detail = StatusFailing, "timed out"
+	}
+	if res.Status == "" {
+		res.Status = StatusOK
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	return res
+}
+
+// Cached reuses fn's last result for ttl, for checks that are too slow or
+// costly to run on every probe.
+func Cached(fn CheckFunc, ttl time.Duration) CheckFunc {
+	var (
+		mu      sync.Mutex
+		expires time.Time
+		status  Status
+		detail  string
+	)
+	return func(ctx context.Context) (Status, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Now().Before(expires) {
+			return status, detail
+		}
+		status, detail = fn(ctx)
+		expires = time.Now().Add(ttl)
+		return status, detail
+	}
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func staticCheck(status Status) CheckFunc {
+	return func(context.Context) (Status, string) { return status, "" }
+}
+
+func TestCheckerAggregatesDegradedAndFailing(t *testing.T) {
+	ctx := context.Background()
+
+	report := NewChecker([]Check{
+		{Name: "db", Critical: true, Func: staticCheck(StatusOK)},
+		{Name: "llm", Func: staticCheck(StatusFailing)},
+	}).Run(ctx)
+	if report.Status != StatusDegraded {
+		t.Fatalf("expected non-critical failure to degrade, got %s", report.Status)
+	}
+
+	report = NewChecker([]Check{
+		{Name: "db", Critical: true, Func: staticCheck(StatusFailing)},
+		{Name: "llm", Func: staticCheck(StatusDegraded)},
+	}).Run(ctx)
+	if report.Status != StatusFailing {
+		t.Fatalf("expected critical failure to fail, got %s", report.Status)
+	}
+
+	slow := func(ctx context.Context) (Status, string) {
+		<-ctx.Done()
+		return StatusOK, ""
+	}
+	report = NewChecker([]Check{{Name: "slow", Critical: true, Func: slow}}, WithTimeout(10*time.Millisecond)).Run(ctx)
+	if report.Status != StatusFailing || report.Checks[0].Detail != "timed out" {
+		t.Fatalf("expected timed out check to fail, got %#v", report.Checks[0])
+	}
+}
+
+func TestCachedReusesResultWithinTTL(t *testing.T) {
+	calls := 0
+	check := Cached(func(context.Context) (Status, string) {
+		calls++
+		return StatusDegraded, "unreachable"
+	}, time.Hour)
+	for range 3 {
+		if status, _ := check(context.Background()); status != StatusDegraded {
+			t.Fatalf("unexpected status %s", status)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one probe, got %d", calls)
+	}
+}
+
+func TestDBAndBusChecks(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	if status, detail := DBCheck(db)(ctx); status != StatusOK {
+		t.Fatalf("expected writable db, got %s: %s", status, detail)
+	}
+
+	bus := eventbus.NewBus(db)
+	if status, _ := BusCheck(bus, 1)(ctx); status != StatusDegraded {
+		t.Fatalf("expected missing subscriber to degrade, got %s", status)
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_ = bus.Subscribe(subCtx, []string{"signals"})
+	if status, detail := BusCheck(bus, 1)(ctx); status != StatusOK {
+		t.Fatalf("expected healthy bus, got %s: %s", status, detail)
+	}
+
+	if status, _ := DiskCheck(t.TempDir(), 0, 0)(ctx); status != StatusOK {
+		t.Fatalf("expected disk check to pass with zero thresholds, got %s", status)
+	}
+}
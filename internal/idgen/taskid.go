@@ -28,9 +28,11 @@ func TaskID(db *sql.DB, prefix string) string {
 	var maxN sql.NullInt64
 	// SUBSTR offset is 1-based: skip prefix + dash
 	offset := len(prefix) + 2
+	// Only all-digit suffixes count; Postgres refuses to cast anything else.
 	err := db.QueryRow(
-		`SELECT MAX(CAST(SUBSTR(id, ?) AS INTEGER)) FROM tasks WHERE id LIKE ?`,
-		offset, prefix+"-%",
+		`SELECT MAX(CAST(SUBSTR(id, ?) AS BIGINT)) FROM tasks
+		WHERE id LIKE ? AND SUBSTR(id, ?) <> '' AND LTRIM(SUBSTR(id, ?), '0123456789') = ''`,
+		offset, prefix+"-%", offset, offset,
 	).Scan(&maxN)
 	if err != nil || !maxN.Valid {
 		return fmt.Sprintf("%s-%d", prefix, 1)
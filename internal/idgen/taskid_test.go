@@ -89,6 +89,20 @@ func TestTaskID_CustomName(t *testing.T) {
 	}
 }
 
+func TestTaskID_IgnoresNonNumericSuffixes(t *testing.T) {
+	db := openTestDB(t)
+	for _, id := range []string{"agent-3", "agent-9x", "agent-fetch-weather"} {
+		_, err := db.Exec(`INSERT INTO tasks (id, type, status, created_at, updated_at) VALUES (?, 'agent', 'queued', '2026-01-01T00:00:00Z', '2026-01-01T00:00:00Z')`, id)
+		if err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	got := idgen.TaskID(db, "agent")
+	if got != "agent-4" {
+		t.Fatalf("expected agent-4, got %s", got)
+	}
+}
+
 func TestValidateCustomID(t *testing.T) {
 	valid := []string{
 		"a",
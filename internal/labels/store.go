@@ -0,0 +1,151 @@
+// Package labels keeps a short title and topic tags for each agent's current
+// conversation generation, so agent lists can show what an agent is working
+// on instead of its ID. Labels are written by the runtime's labeling monitor.
+package labels
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Label describes one generation of an agent's conversation. Messages is how
+// many user and assistant messages the generation had when it was labeled.
+type Label struct {
+	AgentID    string    `json:"agent_id"`
+	Generation int64     `json:"generation"`
+	Title      string    `json:"title"`
+	Topics     []string  `json:"topics"`
+	Messages   int       `json:"messages"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Put replaces agentID's label.
+func (s *Store) Put(ctx context.Context, label Label) (Label, error) {
+	label.AgentID = strings.TrimSpace(label.AgentID)
+	label.Title = strings.TrimSpace(label.Title)
+	if label.AgentID == "" {
+		return Label{}, fmt.Errorf("agent_id is required")
+	}
+	if label.Title == "" {
+		return Label{}, fmt.Errorf("title is required")
+	}
+	if label.Topics == nil {
+		label.Topics = []string{}
+	}
+	topicsJSON, err := json.Marshal(label.Topics)
+	if err != nil {
+		return Label{}, fmt.Errorf("encode label topics: %w", err)
+	}
+	label.UpdatedAt = s.now()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO agent_labels (agent_id, generation, title, topics, messages, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id) DO UPDATE SET
+			generation = excluded.generation, title = excluded.title, topics = excluded.topics,
+			messages = excluded.messages, updated_at = excluded.updated_at
+	`, label.AgentID, label.Generation, label.Title, string(topicsJSON), label.Messages, label.UpdatedAt.Format(time.RFC3339Nano)); err != nil {
+		return Label{}, fmt.Errorf("upsert agent label: %w", err)
+	}
+	return label, nil
+}
+
+// Get returns agentID's label, if it has one.
+func (s *Store) Get(ctx context.Context, agentID string) (Label, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT agent_id, generation, title, topics, messages, updated_at FROM agent_labels WHERE agent_id = ?
+	`, strings.TrimSpace(agentID))
+	label, err := scanLabel(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Label{}, false, nil
+	}
+	if err != nil {
+		return Label{}, false, err
+	}
+	return label, true, nil
+}
+
+// All returns every label keyed by agent ID.
+func (s *Store) All(ctx context.Context) (map[string]Label, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT agent_id, generation, title, topics, messages, updated_at FROM agent_labels
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list agent labels: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]Label{}
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[label.AgentID] = label
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate agent labels: %w", err)
+	}
+	return out, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLabel(row scanner) (Label, error) {
+	var label Label
+	var topicsJSON, updatedAt string
+	if err := row.Scan(&label.AgentID, &label.Generation, &label.Title, &topicsJSON, &label.Messages, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Label{}, err
+		}
+		return Label{}, fmt.Errorf("scan agent label: %w", err)
+	}
+	_ = json.Unmarshal([]byte(topicsJSON), &label.Topics)
+	if label.Topics == nil {
+		label.Topics = []string{}
+	}
+	label.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return label, nil
+}
@@ -0,0 +1,102 @@
+package labels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const (
+	maxTitleChars = 80
+	maxTopics     = 5
+)
+
+const titlePrompt = `You label conversations between operators and an AI agent so they can be told apart in a list.
+Reply with only a JSON object: {"title": "...", "topics": ["..."]}.
+The title says what the agent is working on in at most eight words, like "Investigating failed deploy of api-service". No quotes, no trailing period.
+Topics are one to five short lowercase tags such as "deploys" or "billing".`
+
+// Titler names a conversation from its transcript.
+type Titler interface {
+	Title(ctx context.Context, transcript string) (title string, topics []string, err error)
+}
+
+// LLMTitler asks a model for the label. NewSession should return a session
+// on a cheap model without tools.
+type LLMTitler struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (t LLMTitler) Title(ctx context.Context, transcript string) (string, []string, error) {
+	if t.NewSession == nil {
+		return "", nil, fmt.Errorf("titler has no llm")
+	}
+	llm, err := t.NewSession()
+	if err != nil {
+		return "", nil, fmt.Errorf("create title session: %w", err)
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(titlePrompt) }
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, transcript) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return "", nil, fmt.Errorf("generate title: %w", err)
+	}
+	return ParseTitle(out.String())
+}
+
+// ParseTitle reads a titler reply. Models sometimes wrap the JSON in a code
+// fence or add prose around it; a reply without JSON is used as the title.
+func ParseTitle(reply string) (string, []string, error) {
+	reply = strings.TrimSpace(reply)
+	var parsed struct {
+		Title  string   `json:"title"`
+		Topics []string `json:"topics"`
+	}
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start >= 0 && end > start && json.Unmarshal([]byte(reply[start:end+1]), &parsed) == nil {
+		reply = parsed.Title
+	}
+	title := cleanTitle(reply)
+	if title == "" {
+		return "", nil, fmt.Errorf("empty title")
+	}
+	return title, cleanTopics(parsed.Topics), nil
+}
+
+func cleanTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.Trim(s, "\"'`")
+	s = strings.TrimSuffix(s, ".")
+	if len([]rune(s)) > maxTitleChars {
+		s = strings.TrimSpace(string([]rune(s)[:maxTitleChars-1])) + "…"
+	}
+	return s
+}
+
+func cleanTopics(topics []string) []string {
+	out := make([]string, 0, len(topics))
+	seen := map[string]struct{}{}
+	for _, topic := range topics {
+		topic = strings.ToLower(strings.Join(strings.Fields(topic), " "))
+		if topic == "" {
+			continue
+		}
+		if _, ok := seen[topic]; ok {
+			continue
+		}
+		seen[topic] = struct{}{}
+		out = append(out, topic)
+		if len(out) == maxTopics {
+			break
+		}
+	}
+	return out
+}
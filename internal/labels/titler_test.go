@@ -0,0 +1,30 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTitle(t *testing.T) {
+	cases := []struct {
+		reply  string
+		title  string
+		topics []string
+	}{
+		{`{"title": "Investigating failed deploy of api-service", "topics": ["Deploys", "api-service", "deploys"]}`, "Investigating failed deploy of api-service", []string{"deploys", "api-service"}},
+		{"```json\n{\"title\": \"Fixing billing export.\", \"topics\": []}\n```", "Fixing billing export", []string{}},
+		{`"Planning the Q3 offsite"`, "Planning the Q3 offsite", []string{}},
+	}
+	for _, tc := range cases {
+		title, topics, err := ParseTitle(tc.reply)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.reply, err)
+		}
+		if title != tc.title || !reflect.DeepEqual(topics, tc.topics) {
+			t.Fatalf("parse %q: got %q %v", tc.reply, title, topics)
+		}
+	}
+	if _, _, err := ParseTitle(`{"title": ""}`); err == nil {
+		t.Fatalf("expected an empty title to be an error")
+	}
+}
@@ -0,0 +1,194 @@
+// Package maintenance stores time-boxed windows during which agents defer
+// non-interrupt wakes.
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// Window silences non-interrupt wakes between StartsAt and EndsAt. An empty
+// AgentID applies the window to every agent.
+type Window struct {
+	ID        string    `json:"id"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (w Window) ActiveAt(at time.Time) bool {
+	return !at.Before(w.StartsAt) && at.Before(w.EndsAt)
+}
+
+type Input struct {
+	AgentID string
+	Reason  string
+	// StartsAt defaults to now. EndsAt is required unless Duration is set.
+	StartsAt time.Time
+	EndsAt   time.Time
+	Duration time.Duration
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func WithIDGenerator(newIDFn func() string) Option {
+	return func(s *Store) {
+		if newIDFn != nil {
+			s.newIDFn = newIDFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+func (s *Store) newID() string {
+	if s.newIDFn == nil {
+		return idgen.New()
+	}
+	return s.newIDFn()
+}
+
+func (s *Store) Create(ctx context.Context, input Input) (Window, error) {
+	now := s.now()
+	start := input.StartsAt.UTC()
+	if start.IsZero() {
+		start = now
+	}
+	end := input.EndsAt.UTC()
+	if end.IsZero() && input.Duration > 0 {
+		end = start.Add(input.Duration)
+	}
+	if end.IsZero() {
+		return Window{}, fmt.Errorf("ends_at or duration is required")
+	}
+	if !end.After(start) {
+		return Window{}, fmt.Errorf("window must end after it starts")
+	}
+	w := Window{
+		ID:        s.newID(),
+		AgentID:   strings.TrimSpace(input.AgentID),
+		Reason:    strings.TrimSpace(input.Reason),
+		StartsAt:  start,
+		EndsAt:    end,
+		CreatedAt: now,
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO maintenance_windows (id, agent_id, reason, starts_at, ends_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, w.ID, w.AgentID, w.Reason, w.StartsAt.Format(state.TimeLayout), w.EndsAt.Format(state.TimeLayout), w.CreatedAt.Format(state.TimeLayout)); err != nil {
+		return Window{}, fmt.Errorf("insert maintenance window: %w", err)
+	}
+	return w, nil
+}
+
+// List returns windows that have not ended yet, or all windows when
+// includeEnded is set, ordered by start time.
+func (s *Store) List(ctx context.Context, includeEnded bool) ([]Window, error) {
+	query := `SELECT id, agent_id, reason, starts_at, ends_at, created_at FROM maintenance_windows`
+	var args []any
+	if !includeEnded {
+		query += ` WHERE ends_at > ?`
+		args = append(args, s.now().Format(state.TimeLayout))
+	}
+	query += ` ORDER BY starts_at ASC, id ASC`
+	return s.query(ctx, query, args...)
+}
+
+// Active returns the windows covering agentID at the current time, including
+// global ones.
+func (s *Store) Active(ctx context.Context, agentID string) ([]Window, error) {
+	now := s.now().Format(state.TimeLayout)
+	return s.query(ctx, `
+		SELECT id, agent_id, reason, starts_at, ends_at, created_at FROM maintenance_windows
+		WHERE starts_at <= ? AND ends_at > ? AND (agent_id = '' OR agent_id = ?)
+		ORDER BY ends_at DESC, id ASC
+	`, now, now, strings.TrimSpace(agentID))
+}
+
+// End closes a window early. It reports whether the window exists.
+func (s *Store) End(ctx context.Context, id string) (Window, bool, error) {
+	windows, err := s.query(ctx, `SELECT id, agent_id, reason, starts_at, ends_at, created_at FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil || len(windows) == 0 {
+		return Window{}, false, err
+	}
+	w := windows[0]
+	now := s.now()
+	if !w.EndsAt.After(now) {
+		return w, true, nil
+	}
+	if w.StartsAt.After(now) {
+		w.StartsAt = now
+	}
+	w.EndsAt = now
+	if _, err := s.db.ExecContext(ctx, `UPDATE maintenance_windows SET starts_at = ?, ends_at = ? WHERE id = ?`, w.StartsAt.Format(state.TimeLayout), w.EndsAt.Format(state.TimeLayout), w.ID); err != nil {
+		return Window{}, false, fmt.Errorf("end maintenance window: %w", err)
+	}
+	return w, true, nil
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]Window, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Window
+	for rows.Next() {
+		var w Window
+		var startsAt, endsAt, createdAt string
+		if err := rows.Scan(&w.ID, &w.AgentID, &w.Reason, &startsAt, &endsAt, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan maintenance window: %w", err)
+		}
+		w.StartsAt, _ = time.Parse(time.RFC3339Nano, startsAt)
+		w.EndsAt, _ = time.Parse(time.RFC3339Nano, endsAt)
+		w.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate maintenance windows: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreActiveWindows(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	store := NewStore(db, WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	global, err := store.Create(ctx, Input{Reason: "patching", Duration: time.Hour})
+	if err != nil {
+		t.Fatalf("create global: %v", err)
+	}
+	if _, err := store.Create(ctx, Input{AgentID: "agent-b", StartsAt: now.Add(30 * time.Minute), EndsAt: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("create agent window: %v", err)
+	}
+	if _, err := store.Create(ctx, Input{Duration: -time.Minute}); err == nil {
+		t.Fatalf("expected error for window ending before it starts")
+	}
+
+	active, err := store.Active(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("active: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != global.ID {
+		t.Fatalf("expected only the global window, got %+v", active)
+	}
+
+	now = now.Add(45 * time.Minute)
+	active, err = store.Active(ctx, "agent-b")
+	if err != nil {
+		t.Fatalf("active agent-b: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected global and agent windows, got %+v", active)
+	}
+
+	ended, ok, err := store.End(ctx, global.ID)
+	if err != nil || !ok {
+		t.Fatalf("end window: ok=%v err=%v", ok, err)
+	}
+	if !ended.EndsAt.Equal(now) {
+		t.Fatalf("expected window to end now, got %s", ended.EndsAt)
+	}
+	active, err = store.Active(ctx, "agent-a")
+	if err != nil {
+		t.Fatalf("active after end: %v", err)
+	}
+	if len(active) != 0 {
+		t.Fatalf("expected no active windows after end, got %+v", active)
+	}
+	if _, ok, _ := store.End(ctx, "missing"); ok {
+		t.Fatalf("expected missing window to report not found")
+	}
+}
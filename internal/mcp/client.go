@@ -0,0 +1,516 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxMessageBytes caps one JSON-RPC message read from a server.
+const maxMessageBytes = 16 << 20
+
+// ErrClosed is returned by calls on a closed client.
+var ErrClosed = errors.New("mcp client closed")
+
+// message is a JSON-RPC 2.0 request, notification or response. ID is raw so
+// that server requests with string IDs can be answered unchanged.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (m message) isResponse() bool { return len(m.ID) > 0 && m.Method == "" }
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message) }
+
+// transport carries messages to one server. roundTrip sends a request and
+// waits for its response; send delivers a notification or a reply.
+type transport interface {
+	roundTrip(ctx context.Context, req message) (message, error)
+	send(ctx context.Context, msg message) error
+	close() error
+}
+
+// Tool is a tool as a server lists it.
+type Tool struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Content is one item of a tool call result.
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+// CallResult is a server's answer to a tool call. IsError marks a failure
+// the tool reported, as opposed to a protocol error.
+type CallResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Client is a connection to one MCP server.
+type Client struct {
+	name     string
+	t        transport
+	nextID   atomic.Int64
+	onNotify func(method string)
+}
+
+// Dial connects to the server and completes the MCP handshake. onNotify, if
+// set, is called with the method of every notification the server sends.
+func Dial(ctx context.Context, cfg ServerConfig, httpClient *http.Client, logf func(string, ...any), onNotify func(method string)) (*Client, error) {
+	c := &Client{name: cfg.Name, onNotify: onNotify}
+	var err error
+	if strings.TrimSpace(cfg.URL) != "" {
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		c.t = &httpTransport{url: cfg.URL, headers: cfg.Headers, client: httpClient, handle: c.handle}
+	} else {
+		c.t, err = startStdio(cfg, logf, c.handle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := c.initialize(ctx); err != nil {
+		_ = c.t.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "go-agents", "version": "1"},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("initialize %s: %w", c.name, err)
+	}
+	if h, ok := c.t.(*httpTransport); ok {
+		h.setVersion(result.ProtocolVersion)
+	}
+	return c.t.send(ctx, message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// ListTools lists every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var out []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		out = append(out, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return out, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool runs a server tool with JSON arguments.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (CallResult, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	var result CallResult
+	err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result)
+	return result, err
+}
+
+// Close ends the connection, stopping a stdio server.
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	resp, err := c.t.roundTrip(ctx, message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// handle processes a message the server sent that is not a response to one
+// of our requests. Pings are answered; other requests are refused since
+// agentd offers the server no capabilities.
+func (c *Client) handle(msg message) {
+	if len(msg.ID) == 0 {
+		if c.onNotify != nil && msg.Method != "" {
+			c.onNotify(msg.Method)
+		}
+		return
+	}
+	reply := message{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		reply.Result = json.RawMessage(`{}`)
+	} else {
+		reply.Error = &rpcError{Code: -32601, Message: "method not found"}
+	}
+	go func() { _ = c.t.send(context.Background(), reply) }()
+}
+
+// stdioTransport exchanges newline-delimited messages with a child process.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	handle func(message)
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan message
+	err     error
+	done    chan struct{}
+}
+
+func startStdio(cfg ServerConfig, logf func(string, ...any), handle func(message)) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if logf != nil {
+		cmd.Stderr = &lineLogger{prefix: "mcp " + cfg.Name + ": ", logf: logf}
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", cfg.Name, err)
+	}
+	t := &stdioTransport{cmd: cmd, stdin: stdin, handle: handle, pending: map[string]chan message{}, done: make(chan struct{})}
+	go t.read(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		if msg.isResponse() {
+			t.mu.Lock()
+			ch := t.pending[string(msg.ID)]
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+		t.handle(msg)
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("server exited: %w", err)
+	t.mu.Unlock()
+	close(t.done)
+	_ = t.cmd.Wait()
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req message) (message, error) {
+	ch := make(chan message, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		err := t.err
+		t.mu.Unlock()
+		return message{}, err
+	}
+	t.pending[string(req.ID)] = ch
+	t.mu.Unlock()
+	if err := t.send(ctx, req); err != nil {
+		t.forget(req.ID)
+		return message{}, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		t.forget(req.ID)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return message{}, t.err
+	case <-ctx.Done():
+		t.forget(req.ID)
+		return message{}, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) forget(id json.RawMessage) {
+	t.mu.Lock()
+	delete(t.pending, string(id))
+	t.mu.Unlock()
+}
+
+func (t *stdioTransport) send(_ context.Context, msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) close() error {
+	t.mu.Lock()
+	if t.err == nil {
+		t.err = ErrClosed
+	}
+	t.mu.Unlock()
+	_ = t.stdin.Close()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// httpTransport speaks streamable HTTP: every message is POSTed, and the
+// answer is either JSON or an event stream carrying the response along
+// with any notifications the server sends first.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	handle  func(message)
+
+	mu      sync.Mutex
+	session string
+	version string
+	closed  bool
+}
+
+func (t *httpTransport) setVersion(v string) {
+	t.mu.Lock()
+	t.version = v
+	t.mu.Unlock()
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req message) (message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return message{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return message{}, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return t.readStream(resp.Body, req.ID)
+	}
+	var msg message
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMessageBytes)).Decode(&msg); err != nil {
+		return message{}, fmt.Errorf("decode response: %w", err)
+	}
+	return msg, nil
+}
+
+// readStream reads server-sent events until the response to id arrives.
+func (t *httpTransport) readStream(body io.Reader, id json.RawMessage) (message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(rest, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		var msg message
+		err := json.Unmarshal(data.Bytes(), &msg)
+		data.Reset()
+		if err != nil {
+			continue
+		}
+		if msg.isResponse() {
+			if bytes.Equal(msg.ID, id) {
+				return msg, nil
+			}
+			continue
+		}
+		t.handle(msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return message{}, err
+	}
+	return message{}, fmt.Errorf("event stream ended without a response")
+}
+
+func (t *httpTransport) send(ctx context.Context, msg message) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg message) (*http.Response, error) {
+	t.mu.Lock()
+	closed, session, version := t.closed, t.session, t.version
+	t.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	if version != "" {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.session = id
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// close ends the server session, if the server issued one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	session := t.session
+	t.closed = true
+	t.mu.Unlock()
+	if session == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Mcp-Session-Id", session)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// maxLogLine caps a logged stderr line.
+const maxLogLine = 4096
+
+// lineLogger logs a stdio server's stderr line by line.
+type lineLogger struct {
+	prefix string
+	logf   func(string, ...any)
+	buf    []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(l.buf[:i])); line != "" {
+			l.logf("%s%s", l.prefix, line)
+		}
+		l.buf = l.buf[i+1:]
+	}
+	if len(l.buf) > maxLogLine {
+		l.logf("%s%s", l.prefix, l.buf[:maxLogLine])
+		l.buf = nil
+	}
+	return len(p), nil
+}
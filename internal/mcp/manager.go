@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/content"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// Manager keeps a connection to every configured server and the tools each
+// one offers. It is safe for concurrent use.
+type Manager struct {
+	servers    []*server
+	httpClient *http.Client
+	logf       func(string, ...any)
+	onChange   func(names []string)
+
+	// refreshMu serializes refreshes; mu guards the servers' state.
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+	closed    bool
+}
+
+type server struct {
+	cfg    ServerConfig
+	client *Client
+	tools  []Tool
+}
+
+type Option func(*Manager)
+
+// WithHTTPClient sets the client for HTTP servers, e.g. one that applies
+// the egress policy.
+func WithHTTPClient(client *http.Client) Option {
+	return func(m *Manager) { m.httpClient = client }
+}
+
+// WithLogger logs stdio servers' stderr and refresh failures.
+func WithLogger(logf func(string, ...any)) Option {
+	return func(m *Manager) { m.logf = logf }
+}
+
+// WithOnChange is called with every exposed tool name whenever the set of
+// tools changes.
+func WithOnChange(fn func(names []string)) Option {
+	return func(m *Manager) { m.onChange = fn }
+}
+
+func NewManager(servers []ServerConfig, opts ...Option) *Manager {
+	m := &Manager{}
+	for _, cfg := range servers {
+		m.servers = append(m.servers, &server{cfg: cfg})
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Refresh connects to servers that are not connected and lists every
+// server's tools. A server that fails is disconnected and offers no tools
+// until a later refresh reaches it.
+func (m *Manager) Refresh(ctx context.Context) error {
+	var errs []error
+	changed := false
+	for _, s := range m.servers {
+		c, err := m.refresh(ctx, s)
+		changed = changed || c
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if changed {
+		m.notifyChange()
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) refresh(ctx context.Context, s *server) (bool, error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return false, ErrClosed
+	}
+	client := s.client
+	m.mu.Unlock()
+
+	var err error
+	if client == nil {
+		client, err = Dial(ctx, s.cfg, m.httpClient, m.logf, func(method string) {
+			if method == "notifications/tools/list_changed" {
+				go m.refreshServer(s)
+			}
+		})
+	}
+	var tools []Tool
+	if err == nil {
+		tools, err = client.ListTools(ctx)
+	}
+	if err == nil && len(s.cfg.Tools) > 0 {
+		tools = slices.DeleteFunc(tools, func(t Tool) bool { return !slices.Contains(s.cfg.Tools, t.Name) })
+	}
+	if err != nil && client != nil {
+		_ = client.Close()
+		client = nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed && client != nil {
+		_ = client.Close()
+		client = nil
+	}
+	changed := !sameTools(s.tools, tools)
+	s.client, s.tools = client, tools
+	if err != nil {
+		return changed, fmt.Errorf("mcp %s: %w", s.cfg.Name, err)
+	}
+	return changed, nil
+}
+
+// refreshServer refreshes one server after it announced a change.
+func (m *Manager) refreshServer(s *server) {
+	changed, err := m.refresh(context.Background(), s)
+	if err != nil && m.logf != nil {
+		m.logf("%v", err)
+	}
+	if changed {
+		m.notifyChange()
+	}
+}
+
+func (m *Manager) notifyChange() {
+	if m.onChange != nil {
+		m.onChange(m.ToolNames())
+	}
+}
+
+func sameTools(a, b []Tool) bool {
+	return slices.EqualFunc(a, b, func(x, y Tool) bool {
+		return x.Name == y.Name && x.Description == y.Description && string(x.InputSchema) == string(y.InputSchema)
+	})
+}
+
+// ToolNames lists the agent tool names of every tool currently offered,
+// sorted.
+func (m *Manager) ToolNames() []string {
+	var names []string
+	m.each(func(s *server, t Tool) {
+		names = append(names, ToolName(s.cfg.Name, t.Name))
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Tools returns an agent tool for every tool currently offered. Calls go to
+// whichever connection the server has when they run.
+func (m *Manager) Tools() []llmtools.Tool {
+	var out []llmtools.Tool
+	m.each(func(s *server, t Tool) {
+		out = append(out, m.tool(s, t))
+	})
+	return out
+}
+
+// each visits every offered tool in config order. When two tools map to the
+// same agent tool name, the first one wins.
+func (m *Manager) each(fn func(s *server, t Tool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := map[string]bool{}
+	for _, s := range m.servers {
+		for _, t := range s.tools {
+			name := ToolName(s.cfg.Name, t.Name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fn(s, t)
+		}
+	}
+}
+
+func (m *Manager) tool(s *server, t Tool) llmtools.Tool {
+	name := ToolName(s.cfg.Name, t.Name)
+	params := llmtools.ValueSchema{Type: "object"}
+	if len(t.InputSchema) > 0 {
+		if err := json.Unmarshal(t.InputSchema, &params); err != nil || params.Type == "" {
+			params = llmtools.ValueSchema{Type: "object"}
+		}
+	}
+	label := t.Title
+	if label == "" {
+		label = t.Name
+	}
+	description := strings.TrimSpace(t.Description)
+	if description == "" {
+		description = fmt.Sprintf("%s tool from the %s MCP server", t.Name, s.cfg.Name)
+	}
+	schema := &llmtools.FunctionSchema{Name: name, Description: description, Parameters: params}
+	return llmtools.External(label, schema, func(r llmtools.Runner, args json.RawMessage) llmtools.Result {
+		m.mu.Lock()
+		client := s.client
+		m.mu.Unlock()
+		if client == nil {
+			return toolresult.Errorf(name, "MCP server %s is not connected", s.cfg.Name)
+		}
+		result, err := client.CallTool(r.Context(), t.Name, args)
+		if err != nil {
+			return toolresult.ErrorWithLabel(name, name+" failed", err)
+		}
+		return callResult(name, result)
+	})
+}
+
+// callResult turns a tool call result into an agent tool result. Text and
+// resources become the output, images are attached, and structured content
+// is passed along as is.
+func callResult(name string, result CallResult) llmtools.Result {
+	var texts []string
+	var images content.Content
+	for _, item := range result.Content {
+		switch item.Type {
+		case "text":
+			texts = append(texts, item.Text)
+		case "image":
+			images.AddImage("data:" + item.MimeType + ";base64," + item.Data)
+		case "resource":
+			if item.Resource != nil {
+				texts = append(texts, item.Resource.Text)
+			}
+		}
+	}
+	output := strings.Join(texts, "\n")
+	if result.IsError {
+		if output == "" {
+			output = "tool reported an error"
+		}
+		return toolresult.Errorf(name, "%s", output)
+	}
+	value := map[string]any{"output": output}
+	if len(result.StructuredContent) > 0 {
+		value["structured"] = result.StructuredContent
+	}
+	if len(images) > 0 {
+		return toolresult.SuccessWithContent(name, "Success", images, value)
+	}
+	return toolresult.Success(name, value)
+}
+
+// Close disconnects every server.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, s := range m.servers {
+		if s.client != nil {
+			_ = s.client.Close()
+			s.client = nil
+		}
+	}
+	return nil
+}
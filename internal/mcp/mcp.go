@@ -0,0 +1,87 @@
+// Package mcp connects to external Model Context Protocol servers and
+// exposes their tools to agents. Servers are declared in the config file's
+// mcp_servers list and reached over stdio (a command agentd starts) or
+// streamable HTTP (a url). Each server tool becomes an agent tool named
+// "<server>_<tool>".
+//
+// The Manager lists every server's tools on Refresh and again whenever a
+// server announces that its tools changed, so tools a server adds or drops
+// reach agents from their next turn.
+package mcp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultRefreshInterval is how often server tool lists are refreshed when
+// no change notification arrives.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// protocolVersion is the MCP revision agentd speaks.
+const protocolVersion = "2025-06-18"
+
+// maxToolName is the longest function name providers accept.
+const maxToolName = 64
+
+// ServerConfig describes one server in the config file's mcp_servers list.
+// Command starts a stdio server; URL reaches a streamable HTTP server.
+type ServerConfig struct {
+	Name    string            `json:"name"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Tools limits which of the server's tools are exposed, by their MCP
+	// names. Empty exposes all of them.
+	Tools []string `json:"tools,omitempty"`
+}
+
+// Validate checks that every server has a unique name and exactly one of a
+// command or an http(s) url.
+func Validate(servers []ServerConfig) error {
+	seen := map[string]bool{}
+	for _, s := range servers {
+		name := strings.TrimSpace(s.Name)
+		if name == "" {
+			return fmt.Errorf("mcp server name is required")
+		}
+		if seen[name] {
+			return fmt.Errorf("mcp server %q is listed twice", name)
+		}
+		seen[name] = true
+		hasCommand, hasURL := strings.TrimSpace(s.Command) != "", strings.TrimSpace(s.URL) != ""
+		if hasCommand == hasURL {
+			return fmt.Errorf("mcp server %q needs exactly one of command or url", name)
+		}
+		if hasURL {
+			u, err := url.Parse(s.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("mcp server %q needs an http(s) url", name)
+			}
+		}
+	}
+	return nil
+}
+
+// ToolName is the agent tool name for a server's tool. Characters providers
+// reject in function names become underscores.
+func ToolName(server, tool string) string {
+	var b strings.Builder
+	for _, r := range server + "_" + tool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
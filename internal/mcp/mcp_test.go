@@ -0,0 +1,228 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// TestMain lets the test binary act as a stdio server for TestStdioServer.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_FAKE_SERVER") == "1" {
+		serveStdio(&fakeServer{tools: []Tool{{Name: "echo", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)}}})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer answers the requests agentd makes. Its tools are listed one
+// per page to exercise pagination.
+type fakeServer struct {
+	mu    sync.Mutex
+	tools []Tool
+}
+
+func (f *fakeServer) answer(msg message) message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reply := message{JSONRPC: "2.0", ID: msg.ID}
+	var result any
+	switch msg.Method {
+	case "initialize":
+		result = map[string]any{"protocolVersion": protocolVersion, "capabilities": map[string]any{"tools": map[string]any{"listChanged": true}}}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		i := 0
+		fmt.Sscan(params.Cursor, &i)
+		page := map[string]any{"tools": f.tools[i : i+1]}
+		if i+1 < len(f.tools) {
+			page["nextCursor"] = fmt.Sprint(i + 1)
+		}
+		result = page
+	case "tools/call":
+		var params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		if params.Arguments.Text == "" {
+			result = map[string]any{"isError": true, "content": []map[string]any{{"type": "text", "text": "text is required"}}}
+		} else {
+			result = map[string]any{"content": []map[string]any{{"type": "text", "text": "echo: " + params.Arguments.Text}}}
+		}
+	default:
+		reply.Error = &rpcError{Code: -32601, Message: "method not found"}
+		return reply
+	}
+	reply.Result, _ = json.Marshal(result)
+	return reply
+}
+
+func serveStdio(f *fakeServer) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg message
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil || len(msg.ID) == 0 {
+			continue
+		}
+		data, _ := json.Marshal(f.answer(msg))
+		fmt.Fprintf(os.Stdout, "%s\n", data)
+	}
+}
+
+func run(t *testing.T, m *Manager, name, args string) llmtools.Result {
+	t.Helper()
+	for _, tool := range m.Tools() {
+		if tool.FuncName() == name {
+			return tool.Run(llmtools.NopRunner, json.RawMessage(args))
+		}
+	}
+	t.Fatalf("tool %s not offered; have %v", name, m.ToolNames())
+	return nil
+}
+
+func TestHTTPServerToolsRefreshOnListChanged(t *testing.T) {
+	fake := &fakeServer{tools: []Tool{
+		{Name: "search", Description: "Search the docs", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`)},
+		{Name: "fetch.page"},
+	}}
+	var announce atomic.Bool
+	var session atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			return
+		}
+		var msg message
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if msg.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+		} else {
+			session.Store(r.Header.Get("Mcp-Session-Id"))
+		}
+		if len(msg.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		reply, _ := json.Marshal(fake.answer(msg))
+		if msg.Method != "tools/call" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(reply)
+			return
+		}
+		// Tool calls answer over an event stream, announcing a tool list
+		// change first when asked to.
+		w.Header().Set("Content-Type", "text/event-stream")
+		if announce.Load() {
+			fmt.Fprintf(w, "data: %s\n\n", `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+		}
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", reply)
+	}))
+	defer srv.Close()
+
+	changes := make(chan []string, 4)
+	m := NewManager([]ServerConfig{{Name: "docs", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer k"}}},
+		WithHTTPClient(srv.Client()), WithOnChange(func(names []string) { changes <- names }))
+	defer m.Close()
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if names := <-changes; !slices.Equal(names, []string{"docs_fetch_page", "docs_search"}) {
+		t.Fatalf("unexpected tool names %v", names)
+	}
+
+	result := run(t, m, "docs_search", `{"text":"llamas"}`)
+	if result.Error() != nil {
+		t.Fatalf("call: %v", result.Error())
+	}
+	if text, _ := result.Content().AsString(); !strings.Contains(text, "echo: llamas") {
+		t.Fatalf("unexpected result %q", text)
+	}
+	if got := session.Load(); got != "sess-1" {
+		t.Fatalf("expected the session id on later requests, got %v", got)
+	}
+	if result := run(t, m, "docs_search", `{}`); result.Error() == nil || !strings.Contains(result.Error().Error(), "text is required") {
+		t.Fatalf("expected the tool's error, got %v", result.Error())
+	}
+
+	// The server drops a tool and announces it; the manager refreshes.
+	fake.mu.Lock()
+	fake.tools = fake.tools[:1]
+	fake.mu.Unlock()
+	announce.Store(true)
+	run(t, m, "docs_search", `{"text":"again"}`)
+	select {
+	case names := <-changes:
+		if !slices.Equal(names, []string{"docs_search"}) {
+			t.Fatalf("unexpected tool names after the change %v", names)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a refresh after the change notification")
+	}
+}
+
+func TestStdioServer(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("executable: %v", err)
+	}
+	m := NewManager([]ServerConfig{
+		{Name: "local", Command: exe, Env: map[string]string{"MCP_FAKE_SERVER": "1"}},
+		{Name: "broken", Command: exe + "-missing"},
+	})
+	defer m.Close()
+	err = m.Refresh(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "mcp broken") {
+		t.Fatalf("expected the missing server to fail, got %v", err)
+	}
+	if names := m.ToolNames(); !slices.Equal(names, []string{"local_echo"}) {
+		t.Fatalf("unexpected tool names %v", names)
+	}
+	result := run(t, m, "local_echo", `{"text":"hi"}`)
+	if text, _ := result.Content().AsString(); result.Error() != nil || !strings.Contains(text, "echo: hi") {
+		t.Fatalf("unexpected result %q %v", text, result.Error())
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if result := run(t, m, "local_echo", `{"text":"hi"}`); result.Error() == nil {
+		t.Fatalf("expected calls after close to fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, servers := range [][]ServerConfig{
+		{{Command: "x"}},
+		{{Name: "a", Command: "x"}, {Name: "a", URL: "https://a"}},
+		{{Name: "a", Command: "x", URL: "https://a"}},
+		{{Name: "a", URL: "ftp://a"}},
+	} {
+		if err := Validate(servers); err == nil {
+			t.Fatalf("expected %+v to be invalid", servers)
+		}
+	}
+	if err := Validate([]ServerConfig{{Name: "a", Command: "x"}, {Name: "b", URL: "https://b/mcp"}}); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+}
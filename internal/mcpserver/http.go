@@ -0,0 +1,127 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+)
+
+// maxHTTPMessage bounds one POSTed message.
+const maxHTTPMessage = 16 << 20
+
+const sessionHeader = "Mcp-Session-Id"
+
+// Handler serves the streamable HTTP transport on a single endpoint.
+// initialize starts a session whose ID the client sends with every later
+// request, and DELETE ends it. Requests are answered as an SSE stream when
+// the client accepts one, which also carries progress notifications, and
+// as JSON otherwise. There is no server-initiated stream, so GET is
+// refused.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			s.servePost(w, r)
+		case http.MethodDelete:
+			id := r.Header.Get(sessionHeader)
+			if !s.endSession(id) {
+				http.Error(w, "unknown session", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (s *Server) servePost(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPMessage))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		writeJSON(w, http.StatusBadRequest, message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+		return
+	}
+	if msg.Method == "initialize" {
+		w.Header().Set(sessionHeader, s.startSession())
+	} else {
+		id := r.Header.Get(sessionHeader)
+		if id == "" {
+			http.Error(w, "missing "+sessionHeader+" header", http.StatusBadRequest)
+			return
+		}
+		if !s.hasSession(id) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+	if len(msg.ID) == 0 || msg.Method == "" {
+		// Notifications and responses need no answer.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	flusher, canStream := w.(http.Flusher)
+	if !canStream || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		reply, _ := s.handle(r.Context(), msg, nil)
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	var writeMu sync.Mutex
+	send := func(msg message) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	reply, _ := s.handle(r.Context(), msg, send)
+	send(reply)
+}
+
+func (s *Server) startSession() string {
+	id := idgen.New()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = true
+	return id
+}
+
+func (s *Server) hasSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+func (s *Server) endSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sessions[id] {
+		return false
+	}
+	delete(s.sessions, id)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, msg message) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(msg)
+}
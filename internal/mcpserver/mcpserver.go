@@ -0,0 +1,284 @@
+// Package mcpserver lets other agent frameworks use agentd as a Model
+// Context Protocol server. It exposes a fixed set of agent tools, such as
+// send_task, await_task, exec and the stream read/ack tools, over stdio
+// (ServeStdio) or streamable HTTP with SSE responses (Handler).
+//
+// Tools run as the caller set with WithCaller, which is also the default
+// reader for stream tools and the owner of exec tasks.
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-llms/content"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// DefaultCaller is the caller tools run as unless WithCaller is given.
+const DefaultCaller = "mcp"
+
+// protocolVersions are the MCP revisions the server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// message is a JSON-RPC 2.0 request, response or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server answers MCP requests with its tools. It is safe for concurrent use.
+type Server struct {
+	tools   []llmtools.Tool
+	byName  map[string]llmtools.Tool
+	caller  string
+	version string
+	waker   func(ctx context.Context, taskID string)
+	logf    func(string, ...any)
+
+	mu       sync.Mutex
+	sessions map[string]bool
+}
+
+type Option func(*Server)
+
+// WithCaller sets the task ID tools run as.
+func WithCaller(caller string) Option {
+	return func(s *Server) {
+		if caller = strings.TrimSpace(caller); caller != "" {
+			s.caller = caller
+		}
+	}
+}
+
+// WithWaker is called with the task_id of every send_task call before it
+// runs, so an agent without a running loop picks the message up.
+func WithWaker(fn func(ctx context.Context, taskID string)) Option {
+	return func(s *Server) { s.waker = fn }
+}
+
+// WithLogger logs transport errors.
+func WithLogger(logf func(string, ...any)) Option {
+	return func(s *Server) { s.logf = logf }
+}
+
+// New returns a server that offers tools. Tools without a JSON schema, and
+// tools whose name was already taken, are left out.
+func New(tools []llmtools.Tool, opts ...Option) *Server {
+	s := &Server{
+		byName:   map[string]llmtools.Tool{},
+		caller:   DefaultCaller,
+		version:  buildVersion(),
+		sessions: map[string]bool{},
+	}
+	for _, tool := range tools {
+		if tool == nil || toolSchema(tool) == nil || s.byName[tool.FuncName()] != nil {
+			continue
+		}
+		s.tools = append(s.tools, tool)
+		s.byName[tool.FuncName()] = tool
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ToolNames lists the names of the offered tools.
+func (s *Server) ToolNames() []string {
+	names := make([]string, 0, len(s.tools))
+	for _, tool := range s.tools {
+		names = append(names, tool.FuncName())
+	}
+	return names
+}
+
+func (s *Server) logError(format string, args ...any) {
+	if s.logf != nil {
+		s.logf("mcp server: "+format, args...)
+	}
+}
+
+// handle answers one message. Notifications and responses get no reply.
+// notify sends a notification to the client while a request runs; it may
+// be nil when the transport cannot send one.
+func (s *Server) handle(ctx context.Context, msg message, notify func(message)) (message, bool) {
+	if len(msg.ID) == 0 || msg.Method == "" {
+		return message{}, false
+	}
+	reply := message{JSONRPC: "2.0", ID: msg.ID}
+	result, rerr := s.dispatch(ctx, msg, notify)
+	if rerr != nil {
+		reply.Error = rerr
+		return reply, true
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		reply.Error = &rpcError{Code: codeInvalidRequest, Message: err.Error()}
+		return reply, true
+	}
+	reply.Result = data
+	return reply, true
+}
+
+func (s *Server) dispatch(ctx context.Context, msg message, notify func(message)) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "agentd", "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]map[string]any, 0, len(s.tools))
+		for _, tool := range s.tools {
+			entry := map[string]any{
+				"name":        tool.FuncName(),
+				"title":       tool.Label(),
+				"description": tool.Description(),
+				"inputSchema": toolSchema(tool).Parameters,
+			}
+			tools = append(tools, entry)
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+			Meta      struct {
+				ProgressToken json.RawMessage `json:"progressToken"`
+			} `json:"_meta"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		tool := s.byName[params.Name]
+		if tool == nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+			params.Arguments = json.RawMessage(`{}`)
+		}
+		return s.call(ctx, tool, params.Arguments, params.Meta.ProgressToken, notify), nil
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+	}
+}
+
+// call runs a tool as the caller. Status reports become progress
+// notifications when the client asked for them.
+func (s *Server) call(ctx context.Context, tool llmtools.Tool, args json.RawMessage, progressToken json.RawMessage, notify func(message)) map[string]any {
+	ctx = agentcontext.WithTaskID(ctx, s.caller)
+	if tool.FuncName() == "send_task" && s.waker != nil {
+		var target struct {
+			TaskID string `json:"task_id"`
+		}
+		if json.Unmarshal(args, &target) == nil && target.TaskID != "" && !strings.Contains(target.TaskID, "@") {
+			s.waker(ctx, target.TaskID)
+		}
+	}
+	progress := 0
+	report := func(status string) {
+		if notify == nil || len(progressToken) == 0 {
+			return
+		}
+		progress++
+		params, _ := json.Marshal(map[string]any{"progressToken": progressToken, "progress": progress, "message": status})
+		notify(message{JSONRPC: "2.0", Method: "notifications/progress", Params: params})
+	}
+	result := tool.Run(llmtools.NewRunner(ctx, nil, report), args)
+	if result == nil {
+		return map[string]any{"content": []map[string]any{}}
+	}
+	out := map[string]any{"content": callContent(result.Content())}
+	if result.Error() != nil {
+		out["isError"] = true
+	}
+	return out
+}
+
+// callContent converts tool result content to MCP content. Data URL images
+// become image content; other images are given as links.
+func callContent(items content.Content) []map[string]any {
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case *content.Text:
+			out = append(out, map[string]any{"type": "text", "text": v.Text})
+		case *content.JSON:
+			out = append(out, map[string]any{"type": "text", "text": string(v.Data)})
+		case *content.ImageURL:
+			if mimeType, data, ok := parseDataURL(v.URL); ok {
+				out = append(out, map[string]any{"type": "image", "data": data, "mimeType": mimeType})
+			} else {
+				out = append(out, map[string]any{"type": "text", "text": "image: " + v.URL})
+			}
+		}
+	}
+	return out
+}
+
+func parseDataURL(url string) (mimeType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mimeType, found = strings.CutSuffix(header, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
+// buildVersion is the agentd module version reported to clients.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func toolSchema(tool llmtools.Tool) *llmtools.FunctionSchema {
+	grammar, ok := tool.Grammar().(llmtools.JSONGrammar)
+	if !ok {
+		return nil
+	}
+	return grammar.Schema()
+}
@@ -0,0 +1,257 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type slowParams struct {
+	Steps int `json:"steps"`
+}
+
+// slowTool reports a status per step and returns the caller it ran as.
+func slowTool() llmtools.Tool {
+	return llmtools.Func("Slow", "Report progress, then finish", "slow", func(r llmtools.Runner, p slowParams) llmtools.Result {
+		for range p.Steps {
+			r.Report("working")
+		}
+		return toolresult.Success("slow", map[string]any{"caller": agentcontext.TaskIDFromContext(r.Context())})
+	})
+}
+
+// stdioClient drives ServeStdio over pipes.
+type stdioClient struct {
+	t   *testing.T
+	in  io.WriteCloser
+	out *bufio.Scanner
+}
+
+func (c *stdioClient) call(id int, method string, params any) message {
+	c.t.Helper()
+	raw, _ := json.Marshal(params)
+	data, _ := json.Marshal(message{JSONRPC: "2.0", ID: json.RawMessage(mustJSON(id)), Method: method, Params: raw})
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	for c.out.Scan() {
+		var msg message
+		if err := json.Unmarshal(c.out.Bytes(), &msg); err != nil {
+			c.t.Fatalf("decode %s: %v", c.out.Text(), err)
+		}
+		if string(msg.ID) == string(mustJSON(id)) {
+			return msg
+		}
+	}
+	c.t.Fatalf("no reply to %s", method)
+	return message{}
+}
+
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func callText(t *testing.T, reply message) (string, bool) {
+	t.Helper()
+	if reply.Error != nil {
+		t.Fatalf("rpc error: %+v", reply.Error)
+	}
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(reply.Result, &result); err != nil || len(result.Content) == 0 {
+		t.Fatalf("unexpected result %s (%v)", reply.Result, err)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestServeStdioRunsTools(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	bus := eventbus.NewBus(db)
+	manager := tasks.NewManager(db, bus)
+	ctx := context.Background()
+	if _, err := manager.Spawn(ctx, tasks.Spec{ID: "operator", Type: "agent", Owner: "operator"}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+
+	var woken []string
+	server := New([]llmtools.Tool{
+		agenttools.SendTaskTool(manager, bus, nil),
+		agenttools.ReadStreamTool(bus),
+		agenttools.AckStreamTool(bus),
+		slowTool(),
+	}, WithCaller("framework"), WithWaker(func(_ context.Context, taskID string) { woken = append(woken, taskID) }))
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeStdio(ctx, inR, outW)
+		outW.Close()
+	}()
+	client := &stdioClient{t: t, in: inW, out: bufio.NewScanner(outR)}
+
+	init := client.call(1, "initialize", map[string]any{"protocolVersion": "2025-03-26"})
+	var info struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(init.Result, &info)
+	if info.ProtocolVersion != "2025-03-26" {
+		t.Fatalf("expected the client's protocol version, got %s", init.Result)
+	}
+
+	var list struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	_ = json.Unmarshal(client.call(2, "tools/list", nil).Result, &list)
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		if !strings.Contains(string(tool.InputSchema), `"object"`) {
+			t.Fatalf("expected an object schema for %s, got %s", tool.Name, tool.InputSchema)
+		}
+	}
+	if !slices.Equal(names, []string{"send_task", "read_stream", "ack_stream", "slow"}) {
+		t.Fatalf("unexpected tools %v", names)
+	}
+
+	text, isError := callText(t, client.call(3, "tools/call", map[string]any{"name": "send_task", "arguments": map[string]any{"task_id": "operator", "body": "hello from outside"}}))
+	if isError || !strings.Contains(text, "ok") {
+		t.Fatalf("send_task failed: %s", text)
+	}
+	if !slices.Equal(woken, []string{"operator"}) {
+		t.Fatalf("expected the target to be woken, got %v", woken)
+	}
+
+	text, _ = callText(t, client.call(4, "tools/call", map[string]any{"name": "read_stream", "arguments": map[string]any{"stream": schema.StreamTaskInput, "scope_id": "operator", "scope_type": "task"}}))
+	if !strings.Contains(text, "hello from outside") || !strings.Contains(text, "framework") {
+		t.Fatalf("expected the message from the caller, got %s", text)
+	}
+	summaries, err := bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{ScopeType: "task", ScopeID: "operator"})
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("expected one message, got %d (%v)", len(summaries), err)
+	}
+	if _, isError := callText(t, client.call(5, "tools/call", map[string]any{"name": "ack_stream", "arguments": map[string]any{"stream": schema.StreamTaskInput, "ids": []string{summaries[0].ID}}})); isError {
+		t.Fatalf("ack_stream failed")
+	}
+	events, _ := bus.Read(ctx, schema.StreamTaskInput, []string{summaries[0].ID}, "framework")
+	if len(events) != 1 || !events[0].Read {
+		t.Fatalf("expected the event to be read by the caller, got %+v", events)
+	}
+
+	if text, isError := callText(t, client.call(6, "tools/call", map[string]any{"name": "send_task", "arguments": map[string]any{"task_id": "operator"}})); !isError || !strings.Contains(text, "body") {
+		t.Fatalf("expected a tool error, got %s", text)
+	}
+	if reply := client.call(7, "tools/call", map[string]any{"name": "nope"}); reply.Error == nil || reply.Error.Code != codeInvalidParams {
+		t.Fatalf("expected an invalid params error, got %+v", reply)
+	}
+	if reply := client.call(8, "resources/list", nil); reply.Error == nil || reply.Error.Code != codeMethodNotFound {
+		t.Fatalf("expected method not found, got %+v", reply)
+	}
+
+	inW.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected ServeStdio to return when stdin closes")
+	}
+}
+
+func TestHandlerSessionsAndProgress(t *testing.T) {
+	server := New([]llmtools.Tool{slowTool()})
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	post := func(session, accept string, msg any) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(string(mustJSON(msg))))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		if session != "" {
+			req.Header.Set(sessionHeader, session)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		return resp
+	}
+
+	call := map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": map[string]any{
+		"name": "slow", "arguments": map[string]any{"steps": 2}, "_meta": map[string]any{"progressToken": "p1"},
+	}}
+	if resp := post("", "application/json", call); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected requests without a session to fail, got %d", resp.StatusCode)
+	}
+
+	resp := post("", "application/json", map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]any{}})
+	session := resp.Header.Get(sessionHeader)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("expected a session from initialize, got %d %q", resp.StatusCode, session)
+	}
+	if resp := post(session, "application/json", map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"}); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected notifications to be accepted, got %d", resp.StatusCode)
+	}
+
+	resp = post(session, "application/json, text/event-stream", call)
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+	var frames []message
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			t.Fatalf("decode frame: %v", err)
+		}
+		frames = append(frames, msg)
+	}
+	if len(frames) != 3 || frames[0].Method != "notifications/progress" || frames[1].Method != "notifications/progress" {
+		t.Fatalf("expected two progress notifications and a reply, got %+v", frames)
+	}
+	if text, isError := callText(t, frames[2]); isError || !strings.Contains(text, DefaultCaller) {
+		t.Fatalf("expected the tool to run as %s, got %s", DefaultCaller, text)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	req.Header.Set(sessionHeader, session)
+	if resp, err := srv.Client().Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the session to end, got %v %v", resp, err)
+	}
+	if resp := post(session, "application/json", call); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an ended session to be unknown, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,111 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxStdioMessage bounds one newline-delimited message read from the client.
+const maxStdioMessage = 16 << 20
+
+// ServeStdio reads newline-delimited messages from in and writes replies to
+// out until in is closed or ctx ends. Requests run concurrently, so a
+// client can ping or cancel while a long await_task runs.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var writeMu sync.Mutex
+	write := func(msg message) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := out.Write(append(data, '\n')); err != nil {
+			s.logError("write: %v", err)
+		}
+	}
+
+	var inflightMu sync.Mutex
+	inflight := map[string]context.CancelFunc{}
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxStdioMessage)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line = <-lines:
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			write(message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		if msg.Method == "notifications/cancelled" {
+			var params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil {
+				inflightMu.Lock()
+				if cancelRequest := inflight[string(params.RequestID)]; cancelRequest != nil {
+					cancelRequest()
+				}
+				inflightMu.Unlock()
+			}
+			continue
+		}
+		if len(msg.ID) == 0 {
+			continue
+		}
+		reqCtx, cancelRequest := context.WithCancel(ctx)
+		key := string(msg.ID)
+		inflightMu.Lock()
+		inflight[key] = cancelRequest
+		inflightMu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				inflightMu.Lock()
+				delete(inflight, key)
+				inflightMu.Unlock()
+				cancelRequest()
+			}()
+			reply, ok := s.handle(reqCtx, msg, write)
+			// A cancelled request gets no reply.
+			if ok && reqCtx.Err() == nil {
+				write(reply)
+			}
+		}()
+	}
+}
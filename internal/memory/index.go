@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+)
+
+// Sources a semantic search covers.
+const (
+	SourceMemory  = "memory"
+	SourceHistory = "history"
+)
+
+// DefaultSearchLimit and MaxSearchLimit bound how many hits a semantic
+// search returns.
+const (
+	DefaultSearchLimit = 5
+	MaxSearchLimit     = 50
+)
+
+// SearchQuery is a semantic search of an agent's memories and past
+// messages. Sources limits it to SourceMemory or SourceHistory; empty
+// searches both.
+type SearchQuery struct {
+	Text    string
+	Limit   int
+	Sources []string
+}
+
+// Embedder turns text into vectors. Model names what made them; vectors of
+// different models are never compared.
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Hit is a memory or history passage found by a semantic search.
+type Hit struct {
+	Source    string    `json:"source"`
+	ID        string    `json:"id"`
+	Key       string    `json:"key,omitempty"`
+	Text      string    `json:"text"`
+	Tags      []string  `json:"tags,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	TaskID    string    `json:"task_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Score is the cosine similarity of the hit to the query.
+	Score float64 `json:"score"`
+}
+
+// Index is an in-process vector index of one agent's memories and passages.
+// Vectors are kept normalized and a search compares the query with each, so
+// results are exact; an agent's few thousand vectors scan in well under a
+// millisecond. An Index is not changed after it is built, so searches may
+// run concurrently.
+type Index struct {
+	model   string
+	dim     int
+	hits    []Hit
+	vectors [][]float32
+}
+
+// NewIndex returns an empty index of vectors made by model.
+func NewIndex(model string) *Index {
+	return &Index{model: model}
+}
+
+// BuildIndex indexes agentID's memories and passages embedded by model.
+func (s *Store) BuildIndex(ctx context.Context, agentID, model string) (*Index, error) {
+	memories, err := s.List(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	passages, err := s.Passages(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	ix := NewIndex(model)
+	for _, m := range memories {
+		if m.EmbeddingModel == model {
+			ix.Add(Hit{Source: SourceMemory, ID: m.ID, Key: m.Key, Text: m.Text, Tags: m.Tags, TaskID: m.TaskID, CreatedAt: m.UpdatedAt}, m.Embedding)
+		}
+	}
+	for _, p := range passages {
+		if p.EmbeddingModel == model {
+			ix.Add(Hit{Source: SourceHistory, ID: p.ID, Text: p.Text, Role: p.Role, TaskID: p.TaskID, CreatedAt: p.CreatedAt}, p.Embedding)
+		}
+	}
+	return ix, nil
+}
+
+// Model is the model whose vectors the index holds.
+func (ix *Index) Model() string { return ix.model }
+
+// Len is how many vectors the index holds.
+func (ix *Index) Len() int { return len(ix.vectors) }
+
+// Add indexes h under vector. Zero vectors and vectors of another
+// dimension than the first added are skipped; false when v is skipped.
+func (ix *Index) Add(h Hit, vector []float32) bool {
+	v, ok := normalize(vector)
+	if !ok || (ix.dim != 0 && len(v) != ix.dim) {
+		return false
+	}
+	ix.dim = len(v)
+	ix.hits = append(ix.hits, h)
+	ix.vectors = append(ix.vectors, v)
+	return true
+}
+
+// Search returns the k hits most similar to query, best first, from the
+// given sources or from all without any.
+func (ix *Index) Search(query []float32, k int, sources ...string) []Hit {
+	q, ok := normalize(query)
+	if !ok || len(q) != ix.dim || k <= 0 {
+		return nil
+	}
+	var out []Hit
+	for i, v := range ix.vectors {
+		h := ix.hits[i]
+		if len(sources) > 0 && !slices.Contains(sources, h.Source) {
+			continue
+		}
+		var dot float64
+		for j := range v {
+			dot += float64(v[j]) * float64(q[j])
+		}
+		h.Score = math.Round(dot*1e4) / 1e4
+		out = append(out, h)
+	}
+	slices.SortStableFunc(out, func(a, b Hit) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
+
+func normalize(v []float32) ([]float32, bool) {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return nil, false
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = float32(float64(f) / norm)
+	}
+	return out, true
+}
@@ -0,0 +1,359 @@
+// Package memory keeps each agent's long-term memories: notes an agent saves
+// under a key with remember and finds again with recall. Unlike pinned
+// facts, memories are not shown on every turn; they are looked up by
+// relevance, and the most relevant can be added to the system prompt.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	MaxMemoryChars = 4000
+	MaxKeyChars    = 200
+	MaxTags        = 10
+	// DefaultRecallLimit and MaxRecallLimit bound how many memories a
+	// recall returns.
+	DefaultRecallLimit = 5
+	MaxRecallLimit     = 50
+)
+
+var ErrMemoryNotFound = errors.New("memory not found")
+
+type Memory struct {
+	ID      string `json:"id"`
+	AgentID string `json:"agent_id"`
+	// Key names the memory; remembering under a key again replaces it.
+	Key  string   `json:"key"`
+	Text string   `json:"text"`
+	Tags []string `json:"tags,omitempty"`
+	// Embedding is a vector of Text for semantic search, made by
+	// EmbeddingModel.
+	Embedding      []float32 `json:"-"`
+	EmbeddingModel string    `json:"-"`
+	// TaskID is the turn that saved the memory.
+	TaskID    string    `json:"task_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Score is how well the memory matched a recall.
+	Score float64 `json:"score,omitempty"`
+}
+
+// Query selects memories to recall: those carrying every tag in Tags,
+// ranked by how many words of Text they share. Without Text the newest
+// come first.
+type Query struct {
+	Text  string
+	Tags  []string
+	Limit int
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Remember saves m under its key, replacing the agent's memory with that
+// key if there is one; true when the memory is new. Without a key the
+// memory gets its ID as key.
+func (s *Store) Remember(ctx context.Context, m Memory) (Memory, bool, error) {
+	m.AgentID = strings.TrimSpace(m.AgentID)
+	m.Key = strings.TrimSpace(m.Key)
+	m.Text = strings.TrimSpace(m.Text)
+	m.Tags = cleanTags(m.Tags)
+	switch {
+	case m.AgentID == "":
+		return Memory{}, false, fmt.Errorf("agent_id is required")
+	case m.Text == "":
+		return Memory{}, false, fmt.Errorf("text is required")
+	case len([]rune(m.Text)) > MaxMemoryChars:
+		return Memory{}, false, fmt.Errorf("text is longer than %d characters", MaxMemoryChars)
+	case len([]rune(m.Key)) > MaxKeyChars:
+		return Memory{}, false, fmt.Errorf("key is longer than %d characters", MaxKeyChars)
+	case len(m.Tags) > MaxTags:
+		return Memory{}, false, fmt.Errorf("at most %d tags", MaxTags)
+	}
+	created := true
+	var existing Memory
+	if m.Key != "" {
+		found, err := s.Get(ctx, m.AgentID, m.Key)
+		switch {
+		case err == nil:
+			existing, created = found, false
+		case !errors.Is(err, ErrMemoryNotFound):
+			return Memory{}, false, err
+		}
+	}
+	now := s.now()
+	if created {
+		m.ID = "mem-" + s.newIDFn()
+		m.CreatedAt = now
+		if m.Key == "" {
+			m.Key = m.ID
+		}
+	} else {
+		m.ID, m.CreatedAt = existing.ID, existing.CreatedAt
+	}
+	m.UpdatedAt = now
+	if len(m.Embedding) == 0 {
+		m.EmbeddingModel = ""
+	}
+	tags, _ := json.Marshal(m.Tags)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO memories (id, agent_id, key, text, tags, embedding, embedding_model, task_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id, key) DO UPDATE SET
+			text = excluded.text, tags = excluded.tags, embedding = excluded.embedding,
+			embedding_model = excluded.embedding_model, task_id = excluded.task_id, updated_at = excluded.updated_at
+	`, m.ID, m.AgentID, m.Key, m.Text, string(tags), encodeVector(m.Embedding), m.EmbeddingModel, strings.TrimSpace(m.TaskID),
+		m.CreatedAt.Format(state.TimeLayout), m.UpdatedAt.Format(state.TimeLayout)); err != nil {
+		return Memory{}, false, fmt.Errorf("save memory: %w", err)
+	}
+	return m, created, nil
+}
+
+// Get returns agentID's memory with key.
+func (s *Store) Get(ctx context.Context, agentID, key string) (Memory, error) {
+	list, err := s.query(ctx, `WHERE agent_id = ? AND key = ?`, strings.TrimSpace(agentID), strings.TrimSpace(key))
+	if err != nil {
+		return Memory{}, err
+	}
+	if len(list) == 0 {
+		return Memory{}, ErrMemoryNotFound
+	}
+	return list[0], nil
+}
+
+// List returns agentID's memories carrying every tag in tags, newest first.
+func (s *Store) List(ctx context.Context, agentID string, tags ...string) ([]Memory, error) {
+	list, err := s.query(ctx, `WHERE agent_id = ? ORDER BY updated_at DESC, id`, strings.TrimSpace(agentID))
+	if err != nil {
+		return nil, err
+	}
+	tags = cleanTags(tags)
+	return slices.DeleteFunc(list, func(m Memory) bool { return !hasTags(m, tags) }), nil
+}
+
+// Forget deletes agentID's memory with key.
+func (s *Store) Forget(ctx context.Context, agentID, key string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM memories WHERE agent_id = ? AND key = ?`,
+		strings.TrimSpace(agentID), strings.TrimSpace(key))
+	if err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMemoryNotFound
+	}
+	return nil
+}
+
+// Unembedded returns up to limit memories of any agent without a vector by
+// model, least recently updated first.
+func (s *Store) Unembedded(ctx context.Context, model string, limit int) ([]Memory, error) {
+	return s.query(ctx, `WHERE embedding IS NULL OR embedding_model <> ? ORDER BY updated_at, id LIMIT ?`, model, limit)
+}
+
+// SetEmbedding stores vector, made by model, as m's embedding unless m has
+// changed since it was read.
+func (s *Store) SetEmbedding(ctx context.Context, m Memory, model string, vector []float32) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE memories SET embedding = ?, embedding_model = ? WHERE id = ? AND updated_at = ?`,
+		encodeVector(vector), model, m.ID, m.UpdatedAt.Format(state.TimeLayout)); err != nil {
+		return fmt.Errorf("save memory embedding: %w", err)
+	}
+	return nil
+}
+
+// Recall returns agentID's memories that best match q, best first.
+// Memories sharing no word with q.Text are left out.
+func (s *Store) Recall(ctx context.Context, agentID string, q Query) ([]Memory, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultRecallLimit
+	}
+	limit = min(limit, MaxRecallLimit)
+	list, err := s.List(ctx, agentID, q.Tags...)
+	if err != nil {
+		return nil, err
+	}
+	terms := Terms(q.Text)
+	out := make([]Memory, 0, len(list))
+	for _, m := range list {
+		if len(terms) > 0 {
+			if m.Score = score(m, terms); m.Score == 0 {
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	// The list is newest first, so ties keep the newer memory ahead.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *Store) query(ctx context.Context, where string, args ...any) ([]Memory, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, agent_id, key, text, tags, embedding, embedding_model, task_id, created_at, updated_at FROM memories
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query memories: %w", err)
+	}
+	defer rows.Close()
+	out := []Memory{}
+	for rows.Next() {
+		var m Memory
+		var tags, createdAt, updatedAt string
+		var embedding []byte
+		if err := rows.Scan(&m.ID, &m.AgentID, &m.Key, &m.Text, &tags, &embedding, &m.EmbeddingModel, &m.TaskID, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan memory: %w", err)
+		}
+		_ = json.Unmarshal([]byte(tags), &m.Tags)
+		m.Embedding = decodeVector(embedding)
+		m.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		m.UpdatedAt, _ = time.Parse(state.TimeLayout, updatedAt)
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate memories: %w", err)
+	}
+	return out, nil
+}
+
+// stopWords are left out of recall terms.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"me": true, "my": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "we": true, "what": true, "when": true, "with": true, "you": true, "your": true,
+}
+
+// Terms splits text into the lowercase words recall matches on, without
+// stop words or repeats.
+func Terms(text string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		out = append(out, word)
+	}
+	return out
+}
+
+// score counts the terms a memory shares with a query; a term in its key or
+// tags counts double.
+func score(m Memory, terms []string) float64 {
+	text := map[string]bool{}
+	for _, t := range Terms(m.Text) {
+		text[t] = true
+	}
+	label := map[string]bool{}
+	for _, t := range Terms(m.Key + " " + strings.Join(m.Tags, " ")) {
+		label[t] = true
+	}
+	total := 0.0
+	for _, t := range terms {
+		switch {
+		case label[t]:
+			total += 2
+		case text[t]:
+			total++
+		}
+	}
+	return total
+}
+
+func cleanTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func hasTags(m Memory, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(m.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeVector(v []float32) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	out := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(f))
+	}
+	return out
+}
+
+func decodeVector(b []byte) []float32 {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return nil
+	}
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestRememberReplacesByKeyAndRecallRanksMatches(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	store := NewStore(db, WithClock(func() time.Time { return now }))
+	for _, m := range []Memory{
+		{AgentID: "a", Key: "deploy", Text: "Deploys go out from the release branch on Fridays", Tags: []string{"Ops"}},
+		{AgentID: "a", Key: "acme", Text: "ACME runs the self-hosted edition behind a proxy", Tags: []string{"customer", "ops"}},
+		{AgentID: "a", Text: "The staging database is reset every night"},
+		{AgentID: "b", Key: "deploy", Text: "Another agent's deploy notes"},
+	} {
+		now = now.Add(time.Minute)
+		if _, created, err := store.Remember(ctx, m); err != nil || !created {
+			t.Fatalf("remember %q: created %v, %v", m.Key, created, err)
+		}
+	}
+	now = now.Add(time.Minute)
+	m, created, err := store.Remember(ctx, Memory{AgentID: "a", Key: "deploy", Text: "Deploys go out from main on Fridays", Tags: []string{"ops"}, Embedding: []float32{0.5, -1}})
+	if err != nil || created || m.CreatedAt.Equal(m.UpdatedAt) {
+		t.Fatalf("expected the deploy memory replaced, got %+v, %v, %v", m, created, err)
+	}
+	got, err := store.Get(ctx, "a", "deploy")
+	if err != nil || got.Text != "Deploys go out from main on Fridays" || len(got.Embedding) != 2 || got.Embedding[1] != -1 {
+		t.Fatalf("unexpected stored memory %+v, %v", got, err)
+	}
+
+	recalled, err := store.Recall(ctx, "a", Query{Text: "when do deploys go out?"})
+	if err != nil || len(recalled) != 1 || recalled[0].Key != "deploy" || recalled[0].Score != 3 {
+		t.Fatalf("expected the deploy memory recalled, got %+v, %v", recalled, err)
+	}
+	recalled, err = store.Recall(ctx, "a", Query{Text: "ops proxy", Tags: []string{"OPS"}})
+	if err != nil || len(recalled) != 2 || recalled[0].Key != "acme" {
+		t.Fatalf("expected both ops memories with acme first, got %+v, %v", recalled, err)
+	}
+	list, err := store.List(ctx, "a")
+	if err != nil || len(list) != 3 || list[0].Key != "deploy" || list[1].Key != list[1].ID {
+		t.Fatalf("expected three memories newest first, got %+v, %v", list, err)
+	}
+
+	if err := store.Forget(ctx, "a", "acme"); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if err := store.Forget(ctx, "a", "acme"); !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("expected a forgotten memory not found, got %v", err)
+	}
+	if _, _, err := store.Remember(ctx, Memory{AgentID: "a", Key: "empty"}); err == nil {
+		t.Fatalf("expected a memory without text rejected")
+	}
+}
+
+func TestIndexSearchRanksByCosineSimilarity(t *testing.T) {
+	ix := NewIndex("test")
+	if ix.Add(Hit{ID: "zero"}, []float32{0, 0}) {
+		t.Fatalf("expected a zero vector skipped")
+	}
+	ix.Add(Hit{Source: SourceMemory, ID: "east"}, []float32{10, 0})
+	ix.Add(Hit{Source: SourceHistory, ID: "north-east"}, []float32{1, 1})
+	ix.Add(Hit{Source: SourceHistory, ID: "north"}, []float32{0, 3})
+	if ix.Add(Hit{ID: "3d"}, []float32{1, 1, 1}) || ix.Len() != 3 {
+		t.Fatalf("expected a vector of another dimension skipped")
+	}
+
+	hits := ix.Search([]float32{2, 1}, 2)
+	if len(hits) != 2 || hits[0].ID != "north-east" || hits[1].ID != "east" || hits[0].Score != 0.9487 {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	if hits := ix.Search([]float32{2, 1}, 5, SourceHistory); len(hits) != 2 || hits[1].ID != "north" {
+		t.Fatalf("expected only history hits, got %+v", hits)
+	}
+	if hits := ix.Search([]float32{1, 2, 3}, 5); hits != nil {
+		t.Fatalf("expected no hits for a query of another dimension, got %+v", hits)
+	}
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// Passage is a message from an agent's history, kept with its embedding so
+// semantic search can find past conversations as well as memories.
+type Passage struct {
+	// ID is the history entry the passage was taken from.
+	ID             string    `json:"id"`
+	AgentID        string    `json:"agent_id"`
+	TaskID         string    `json:"task_id,omitempty"`
+	Role           string    `json:"role"`
+	Text           string    `json:"text"`
+	Embedding      []float32 `json:"-"`
+	EmbeddingModel string    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SavePassages stores passages, replacing any with the same ID.
+func (s *Store) SavePassages(ctx context.Context, passages []Passage) error {
+	for _, p := range passages {
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO memory_passages (id, agent_id, task_id, role, text, embedding, embedding_model, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				text = excluded.text, embedding = excluded.embedding, embedding_model = excluded.embedding_model
+		`, p.ID, p.AgentID, p.TaskID, p.Role, p.Text, encodeVector(p.Embedding), p.EmbeddingModel,
+			p.CreatedAt.UTC().Format(state.TimeLayout)); err != nil {
+			return fmt.Errorf("save passage: %w", err)
+		}
+	}
+	return nil
+}
+
+// Passages returns agentID's passages, oldest first.
+func (s *Store) Passages(ctx context.Context, agentID string) ([]Passage, error) {
+	return s.queryPassages(ctx, `WHERE agent_id = ? ORDER BY created_at, id`, strings.TrimSpace(agentID))
+}
+
+// LatestPassage returns agentID's newest passage; false when it has none.
+func (s *Store) LatestPassage(ctx context.Context, agentID string) (Passage, bool, error) {
+	list, err := s.queryPassages(ctx, `WHERE agent_id = ? ORDER BY created_at DESC, id DESC LIMIT 1`, strings.TrimSpace(agentID))
+	if err != nil || len(list) == 0 {
+		return Passage{}, false, err
+	}
+	return list[0], true, nil
+}
+
+// ForgetTurn deletes agentID's passages from the turn taskID.
+func (s *Store) ForgetTurn(ctx context.Context, agentID, taskID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM memory_passages WHERE agent_id = ? AND task_id = ?`,
+		strings.TrimSpace(agentID), strings.TrimSpace(taskID)); err != nil {
+		return fmt.Errorf("delete passages: %w", err)
+	}
+	return nil
+}
+
+// Version changes whenever agentID's memories, or its memories and
+// passages embedded by model, do, so an index built from them can tell it
+// is stale.
+func (s *Store) Version(ctx context.Context, agentID, model string) (string, error) {
+	agentID = strings.TrimSpace(agentID)
+	var memories, embedded, passages int
+	var memoryUpdated, passageCreated string
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN embedding IS NOT NULL AND embedding_model = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(MAX(updated_at), '')
+		FROM memories WHERE agent_id = ?
+	`, model, agentID).Scan(&memories, &embedded, &memoryUpdated); err != nil {
+		return "", fmt.Errorf("memory version: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(created_at), '') FROM memory_passages WHERE agent_id = ? AND embedding_model = ?
+	`, agentID, model).Scan(&passages, &passageCreated); err != nil {
+		return "", fmt.Errorf("passage version: %w", err)
+	}
+	return fmt.Sprintf("%d/%d/%s/%d/%s", memories, embedded, memoryUpdated, passages, passageCreated), nil
+}
+
+// EmbeddedPassages returns which of ids are passages embedded by model.
+func (s *Store) EmbeddedPassages(ctx context.Context, model string, ids []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := []any{model}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM memory_passages WHERE embedding_model = ? AND id IN (?`+
+		strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query passages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan passage: %w", err)
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) queryPassages(ctx context.Context, where string, args ...any) ([]Passage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, agent_id, task_id, role, text, embedding, embedding_model, created_at FROM memory_passages
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query passages: %w", err)
+	}
+	defer rows.Close()
+	var out []Passage
+	for rows.Next() {
+		var p Passage
+		var embedding []byte
+		var createdAt string
+		if err := rows.Scan(&p.ID, &p.AgentID, &p.TaskID, &p.Role, &p.Text, &embedding, &p.EmbeddingModel, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan passage: %w", err)
+		}
+		p.Embedding = decodeVector(embedding)
+		p.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate passages: %w", err)
+	}
+	return out, nil
+}
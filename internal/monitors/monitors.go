@@ -0,0 +1,308 @@
+// Package monitors runs named background jobs on an interval. Each monitor
+// can be paused, resumed and re-timed at runtime, and reports when it last
+// ran, when it runs next and the error from its last run.
+package monitors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Func is one run of a monitor.
+type Func func(ctx context.Context) error
+
+// Config is one entry of the monitors section of config.json.
+type Config struct {
+	// Interval overrides the monitor's default interval, as a Go duration.
+	Interval string `json:"interval,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+type Status struct {
+	Name      string     `json:"name"`
+	Enabled   bool       `json:"enabled"`
+	Interval  string     `json:"interval"`
+	Running   bool       `json:"running"`
+	Runs      int64      `json:"runs"`
+	Failures  int64      `json:"failures"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Update changes a monitor at runtime. Nil fields are left alone.
+type Update struct {
+	Enabled  *bool
+	Interval *time.Duration
+}
+
+type monitor struct {
+	name     string
+	fn       Func
+	enabled  bool
+	interval time.Duration
+	running  bool
+	runs     int64
+	failures int64
+	lastRun  time.Time
+	lastErr  string
+	nextRun  time.Time
+	// wake reschedules the loop after an update.
+	wake chan struct{}
+}
+
+type Registry struct {
+	mu       sync.Mutex
+	monitors map[string]*monitor
+	configs  map[string]Config
+	ctx      context.Context
+	nowFn    func() time.Time
+	logf     func(format string, args ...any)
+}
+
+type Option func(*Registry)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(r *Registry) {
+		if nowFn != nil {
+			r.nowFn = nowFn
+		}
+	}
+}
+
+// WithConfig applies per-monitor overrides keyed by monitor name when the
+// monitor registers.
+func WithConfig(configs map[string]Config) Option {
+	return func(r *Registry) {
+		for name, cfg := range configs {
+			r.configs[name] = cfg
+		}
+	}
+}
+
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(r *Registry) {
+		if logf != nil {
+			r.logf = logf
+		}
+	}
+}
+
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{
+		monitors: map[string]*monitor{},
+		configs:  map[string]Config{},
+		nowFn:    func() time.Time { return time.Now().UTC() },
+		logf:     func(string, ...any) {},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	return r
+}
+
+func (r *Registry) now() time.Time {
+	return r.nowFn().UTC()
+}
+
+// Validate reports config entries with unparseable intervals.
+func Validate(configs map[string]Config) error {
+	for name, cfg := range configs {
+		if cfg.Interval == "" {
+			continue
+		}
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return fmt.Errorf("monitor %s: interval: %w", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("monitor %s: interval must be positive", name)
+		}
+	}
+	return nil
+}
+
+// Register adds a monitor that runs fn every interval, applying any
+// configured overrides. Monitors registered after Start begin immediately.
+func (r *Registry) Register(name string, interval time.Duration, fn Func) error {
+	if name == "" || fn == nil {
+		return fmt.Errorf("monitor needs a name and a func")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("monitor %s: interval must be positive", name)
+	}
+	m := &monitor{name: name, fn: fn, enabled: true, interval: interval, wake: make(chan struct{}, 1)}
+	if cfg, ok := r.configs[name]; ok {
+		if cfg.Interval != "" {
+			if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+				m.interval = d
+			}
+		}
+		m.enabled = !cfg.Disabled
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.monitors[name]; exists {
+		return fmt.Errorf("monitor %s already registered", name)
+	}
+	r.monitors[name] = m
+	if r.ctx != nil {
+		r.launch(r.ctx, m)
+	}
+	return nil
+}
+
+// Start runs every registered monitor until ctx is done. Calling it again
+// has no effect.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx != nil {
+		return
+	}
+	r.ctx = ctx
+	for _, m := range r.monitors {
+		r.launch(ctx, m)
+	}
+}
+
+// launch must be called with r.mu held.
+func (r *Registry) launch(ctx context.Context, m *monitor) {
+	if m.enabled {
+		m.nextRun = r.now().Add(m.interval)
+	}
+	go r.loop(ctx, m)
+}
+
+func (r *Registry) loop(ctx context.Context, m *monitor) {
+	for {
+		r.mu.Lock()
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if m.enabled {
+			timer = time.NewTimer(max(m.nextRun.Sub(r.now()), 0))
+			wait = timer.C
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-m.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
+		case <-wait:
+		}
+		r.run(ctx, m)
+	}
+}
+
+func (r *Registry) run(ctx context.Context, m *monitor) {
+	r.mu.Lock()
+	m.running = true
+	r.mu.Unlock()
+
+	err := m.fn(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m.running = false
+	m.runs++
+	m.lastRun = r.now()
+	m.lastErr = ""
+	if err != nil && ctx.Err() == nil {
+		m.failures++
+		m.lastErr = err.Error()
+		r.logf("monitor %s: %v", m.name, err)
+	}
+	m.nextRun = m.lastRun.Add(m.interval)
+}
+
+// Update pauses, resumes or re-times a monitor and returns its new status.
+// A new interval takes effect from the last run, or from now if it has not
+// run yet.
+func (r *Registry) Update(name string, update Update) (Status, error) {
+	if update.Interval != nil && *update.Interval <= 0 {
+		return Status{}, fmt.Errorf("interval must be positive")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.monitors[name]
+	if !ok {
+		return Status{}, fmt.Errorf("monitor %s not found", name)
+	}
+	if update.Enabled != nil {
+		m.enabled = *update.Enabled
+	}
+	if update.Interval != nil {
+		m.interval = *update.Interval
+	}
+	if m.enabled {
+		from := m.lastRun
+		if from.IsZero() {
+			from = r.now()
+		}
+		m.nextRun = from.Add(m.interval)
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return r.status(m), nil
+}
+
+// Get returns one monitor's status.
+func (r *Registry) Get(name string) (Status, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.monitors[name]
+	if !ok {
+		return Status{}, false
+	}
+	return r.status(m), true
+}
+
+// Statuses returns every monitor's status, sorted by name.
+func (r *Registry) Statuses() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Status, 0, len(r.monitors))
+	for _, m := range r.monitors {
+		out = append(out, r.status(m))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// status must be called with r.mu held.
+func (r *Registry) status(m *monitor) Status {
+	st := Status{
+		Name:      m.name,
+		Enabled:   m.enabled,
+		Interval:  m.interval.String(),
+		Running:   m.running,
+		Runs:      m.runs,
+		Failures:  m.failures,
+		LastError: m.lastErr,
+	}
+	if !m.lastRun.IsZero() {
+		at := m.lastRun
+		st.LastRunAt = &at
+	}
+	if m.enabled && r.ctx != nil && !m.nextRun.IsZero() {
+		at := m.nextRun
+		st.NextRunAt = &at
+	}
+	return st
+}
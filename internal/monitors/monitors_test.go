@@ -0,0 +1,99 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRegistryRunsPausesAndRecordsErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int64
+	reg := NewRegistry(WithConfig(map[string]Config{"paused": {Disabled: true}}))
+	if err := reg.Register("ticker", 10*time.Millisecond, func(context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("first run fails")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := reg.Register("ticker", time.Second, func(context.Context) error { return nil }); err == nil {
+		t.Fatalf("expected duplicate name to be rejected")
+	}
+	if err := reg.Register("paused", 10*time.Millisecond, func(context.Context) error {
+		t.Errorf("disabled monitor ran")
+		return nil
+	}); err != nil {
+		t.Fatalf("register paused: %v", err)
+	}
+	reg.Start(ctx)
+
+	waitFor(t, "failed first run", func() bool {
+		st, _ := reg.Get("ticker")
+		return st.Failures == 1
+	})
+	waitFor(t, "error cleared by a later run", func() bool {
+		st, _ := reg.Get("ticker")
+		return st.Runs >= 2 && st.LastError == ""
+	})
+	st, _ := reg.Get("ticker")
+	if st.LastRunAt == nil || st.NextRunAt == nil || st.Interval != "10ms" {
+		t.Fatalf("unexpected status: %#v", st)
+	}
+	if paused, _ := reg.Get("paused"); paused.Enabled || paused.NextRunAt != nil {
+		t.Fatalf("expected configured monitor to start paused: %#v", paused)
+	}
+
+	off := false
+	if st, err := reg.Update("ticker", Update{Enabled: &off}); err != nil || st.Enabled || st.NextRunAt != nil {
+		t.Fatalf("pause: %#v (%v)", st, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	before := runs.Load()
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != before {
+		t.Fatalf("paused monitor kept running")
+	}
+
+	on, interval := true, 5*time.Millisecond
+	if st, err := reg.Update("ticker", Update{Enabled: &on, Interval: &interval}); err != nil || st.Interval != "5ms" {
+		t.Fatalf("resume: %#v (%v)", st, err)
+	}
+	waitFor(t, "resumed run", func() bool { return runs.Load() > before })
+
+	if _, err := reg.Update("missing", Update{Enabled: &on}); err == nil {
+		t.Fatalf("expected unknown monitor to be rejected")
+	}
+	zero := time.Duration(0)
+	if _, err := reg.Update("ticker", Update{Interval: &zero}); err == nil {
+		t.Fatalf("expected non-positive interval to be rejected")
+	}
+}
+
+func TestValidateRejectsBadIntervals(t *testing.T) {
+	if err := Validate(map[string]Config{"a": {Interval: "1m"}, "b": {Disabled: true}}); err != nil {
+		t.Fatalf("expected valid config: %v", err)
+	}
+	if err := Validate(map[string]Config{"a": {Interval: "soon"}}); err == nil {
+		t.Fatalf("expected unparseable interval to fail")
+	}
+	if err := Validate(map[string]Config{"a": {Interval: "-1s"}}); err == nil {
+		t.Fatalf("expected negative interval to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+	// apnsTokenTTL is how long a provider token is reused. Apple rejects
+	// tokens older than an hour and throttles ones refreshed too often.
+	apnsTokenTTL = 50 * time.Minute
+)
+
+// APNs sends notifications to Apple devices with token-based provider
+// authentication. The client negotiates HTTP/2, which APNs requires.
+type APNs struct {
+	KeyID  string
+	TeamID string
+	// Key is the .p8 signing key from the Apple developer account.
+	Key   *ecdsa.PrivateKey
+	Topic string // the app's bundle ID
+	// BaseURL defaults to the production gateway.
+	BaseURL string
+
+	client *http.Client
+	nowFn  func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// ParseAPNsKey reads a PEM-encoded .p8 key.
+func ParseAPNsKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("apns key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse apns key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("apns key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+func (a *APNs) Push(ctx context.Context, device Device, msg Message) error {
+	body, err := json.Marshal(map[string]any{
+		"aps": map[string]any{
+			"alert":              map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound":              "default",
+			"interruption-level": "time-sensitive",
+		},
+		"kind":     msg.Kind,
+		"agent_id": msg.AgentID,
+		"event_id": msg.EventID,
+	})
+	if err != nil {
+		return fmt.Errorf("encode apns payload: %w", err)
+	}
+	token, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+	baseURL := a.BaseURL
+	if baseURL == "" {
+		baseURL = apnsProductionURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/3/device/"+device.Token, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build apns request: %w", err)
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if msg.EventID != "" {
+		req.Header.Set("apns-collapse-id", msg.EventID)
+	}
+	client := a.client
+	if client == nil {
+		client = &http.Client{Timeout: pushTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send apns: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return ErrDeviceGone
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		var reason struct {
+			Reason string `json:"reason"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&reason)
+		if reason.Reason == "BadDeviceToken" || reason.Reason == "Unregistered" {
+			return ErrDeviceGone
+		}
+		return fmt.Errorf("apns push failed: %s: %s", resp.Status, reason.Reason)
+	}
+	if resp.StatusCode >= 300 {
+		return pushError("apns", resp)
+	}
+	return nil
+}
+
+func (a *APNs) providerToken() (string, error) {
+	if a.Key == nil {
+		return "", fmt.Errorf("apns key is not configured")
+	}
+	now := time.Now()
+	if a.nowFn != nil {
+		now = a.nowFn()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && now.Sub(a.issuedAt) < apnsTokenTTL {
+		return a.token, nil
+	}
+	token, err := signJWT(
+		map[string]any{"alg": "ES256", "kid": a.KeyID},
+		map[string]any{"iss": a.TeamID, "iat": now.Unix()},
+		a.Key,
+	)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = token, now
+	return token, nil
+}
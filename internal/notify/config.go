@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// VAPIDPrivateKeyEnv holds the web push VAPID private key, so it does not
+// have to live in config.json.
+const VAPIDPrivateKeyEnv = "GO_AGENTS_VAPID_PRIVATE_KEY"
+
+// Config is the config file's notifications section. Each platform is
+// enabled by its own block.
+type Config struct {
+	WebPush *WebPushConfig `json:"webpush,omitempty"`
+	APNs    *APNsConfig    `json:"apns,omitempty"`
+	FCM     *FCMConfig     `json:"fcm,omitempty"`
+}
+
+type WebPushConfig struct {
+	// Subject is a mailto: or https: contact sent to push services.
+	Subject string `json:"subject"`
+}
+
+type APNsConfig struct {
+	KeyID   string `json:"key_id"`
+	TeamID  string `json:"team_id"`
+	KeyFile string `json:"key_file"`
+	Topic   string `json:"topic"`
+	Sandbox bool   `json:"sandbox"`
+}
+
+type FCMConfig struct {
+	// CredentialsFile is a Google service account key with the Firebase
+	// Cloud Messaging permission.
+	CredentialsFile string `json:"credentials_file"`
+}
+
+// NewPushers builds a Pusher for every configured platform, keyed by
+// platform name.
+func NewPushers(cfg Config) (map[string]Pusher, error) {
+	pushers := map[string]Pusher{}
+	if cfg.WebPush != nil {
+		if strings.TrimSpace(cfg.WebPush.Subject) == "" {
+			return nil, fmt.Errorf("webpush requires subject")
+		}
+		w := &WebPush{PrivateKey: os.Getenv(VAPIDPrivateKeyEnv), Subject: cfg.WebPush.Subject}
+		if _, err := w.PublicKey(); err != nil {
+			return nil, fmt.Errorf("webpush requires %s: %w", VAPIDPrivateKeyEnv, err)
+		}
+		pushers[PlatformWebPush] = w
+	}
+	if cfg.APNs != nil {
+		c := cfg.APNs
+		if c.KeyID == "" || c.TeamID == "" || c.KeyFile == "" || c.Topic == "" {
+			return nil, fmt.Errorf("apns requires key_id, team_id, key_file and topic")
+		}
+		data, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read apns key: %w", err)
+		}
+		key, err := ParseAPNsKey(data)
+		if err != nil {
+			return nil, err
+		}
+		a := &APNs{KeyID: c.KeyID, TeamID: c.TeamID, Key: key, Topic: c.Topic}
+		if c.Sandbox {
+			a.BaseURL = apnsSandboxURL
+		}
+		pushers[PlatformAPNs] = a
+	}
+	if cfg.FCM != nil {
+		data, err := os.ReadFile(cfg.FCM.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read fcm credentials: %w", err)
+		}
+		account, err := ParseServiceAccount(data)
+		if err != nil {
+			return nil, err
+		}
+		pushers[PlatformFCM] = &FCM{Account: account}
+	}
+	return pushers, nil
+}
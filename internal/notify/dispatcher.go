@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	// DefaultPollInterval is how often the dispatcher checks for alerts.
+	DefaultPollInterval = 5 * time.Second
+	// DefaultMaxAge is how old an alert may be and still be pushed. Older
+	// alerts, such as a backlog from before notifications were enabled, are
+	// skipped rather than delivered late.
+	DefaultMaxAge = time.Hour
+
+	dispatchConsumer  = "notify"
+	dispatchBatchSize = 50
+)
+
+// AccessFunc reports whether userID may see alerts about agentID.
+type AccessFunc func(ctx context.Context, userID, agentID string) (bool, error)
+
+// Dispatcher pushes alerts to registered devices. It follows the alerts
+// stream with its own cursor, which advances past an alert once every device
+// has been tried: a device that fails misses that alert rather than holding
+// up the others.
+type Dispatcher struct {
+	bus     *eventbus.Bus
+	store   *Store
+	pushers map[string]Pusher
+
+	allowed AccessFunc
+	maxAge  time.Duration
+	onError func(error)
+	nowFn   func() time.Time
+}
+
+type DispatcherOption func(*Dispatcher)
+
+// WithAccess limits each user's alerts to agents they can view. Without it
+// every user gets every alert.
+func WithAccess(fn AccessFunc) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.allowed = fn
+	}
+}
+
+// WithErrorHandler is called for every failed push.
+func WithErrorHandler(fn func(error)) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.onError = fn
+	}
+}
+
+func WithMaxAge(age time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if age > 0 {
+			d.maxAge = age
+		}
+	}
+}
+
+func WithDispatchClock(nowFn func() time.Time) DispatcherOption {
+	return func(d *Dispatcher) {
+		if nowFn != nil {
+			d.nowFn = nowFn
+		}
+	}
+}
+
+func NewDispatcher(bus *eventbus.Bus, store *Store, pushers map[string]Pusher, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		bus:     bus,
+		store:   store,
+		pushers: pushers,
+		maxAge:  DefaultMaxAge,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d)
+		}
+	}
+	return d
+}
+
+// Deliver pushes alerts raised since the last call and returns how many
+// notifications were sent.
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	cursor, err := d.bus.GetCursor(ctx, schema.StreamAlerts, dispatchConsumer)
+	if err != nil {
+		return 0, err
+	}
+	summaries, err := d.bus.List(ctx, schema.StreamAlerts, eventbus.ListOptions{
+		After: cursor.EventID,
+		Order: "fifo",
+		Limit: dispatchBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	events, err := d.bus.Read(ctx, schema.StreamAlerts, ids, "")
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[string]eventbus.Event, len(events))
+	for _, evt := range events {
+		byID[evt.ID] = evt
+	}
+	devices, err := d.store.Devices(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, id := range ids {
+		evt, ok := byID[id]
+		if ok && d.nowFn().Sub(evt.CreatedAt) <= d.maxAge {
+			sent += d.deliverAlert(ctx, evt, devices)
+		}
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		if _, err := d.bus.SetCursor(ctx, schema.StreamAlerts, dispatchConsumer, id); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (d *Dispatcher) deliverAlert(ctx context.Context, evt eventbus.Event, devices []Device) int {
+	msg := Message{
+		Kind:    schema.GetMetaString(evt.Metadata, schema.MetaKind),
+		AgentID: schema.GetMetaString(evt.Metadata, schema.MetaAgentID),
+		Title:   evt.Subject,
+		Body:    evt.Body,
+		EventID: evt.ID,
+	}
+	wants := map[string]bool{}
+	sent := 0
+	for _, device := range devices {
+		want, seen := wants[device.UserID]
+		if !seen {
+			var err error
+			want, err = d.userWants(ctx, device.UserID, msg)
+			if err != nil {
+				d.report(fmt.Errorf("notify %s: %w", device.UserID, err))
+			}
+			wants[device.UserID] = want
+		}
+		if !want {
+			continue
+		}
+		pusher, ok := d.pushers[device.Platform]
+		if !ok {
+			continue
+		}
+		err := pusher.Push(ctx, device, msg)
+		switch {
+		case errors.Is(err, ErrDeviceGone):
+			if err := d.store.DeleteDevice(ctx, "", device.ID); err != nil && !errors.Is(err, ErrDeviceNotFound) {
+				d.report(err)
+			}
+		case err != nil:
+			d.report(fmt.Errorf("push to %s device %s: %w", device.Platform, device.ID, err))
+			_ = d.store.RecordError(ctx, device.ID, err.Error())
+		default:
+			sent++
+			if device.LastError != "" {
+				_ = d.store.RecordError(ctx, device.ID, "")
+			}
+		}
+	}
+	return sent
+}
+
+func (d *Dispatcher) userWants(ctx context.Context, userID string, msg Message) (bool, error) {
+	prefs, err := d.store.Preferences(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !prefs.Wants(msg.Kind) {
+		return false, nil
+	}
+	if d.allowed == nil || msg.AgentID == "" {
+		return true, nil
+	}
+	return d.allowed(ctx, userID, msg.AgentID)
+}
+
+func (d *Dispatcher) report(err error) {
+	if d.onError != nil {
+		d.onError(err)
+	}
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type fakePusher struct {
+	sent []string
+	gone map[string]bool
+}
+
+func (f *fakePusher) Push(_ context.Context, device Device, msg Message) error {
+	if f.gone[device.Token] {
+		return ErrDeviceGone
+	}
+	f.sent = append(f.sent, device.UserID+":"+msg.Kind+":"+msg.AgentID)
+	return nil
+}
+
+func TestDispatcherHonorsPreferencesAccessAndGoneDevices(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	store := NewStore(db)
+
+	for _, d := range []Device{
+		{UserID: "alice", Platform: PlatformAPNs, Token: "alice-phone"},
+		{UserID: "bob", Platform: PlatformAPNs, Token: "bob-phone"},
+		{UserID: "carol", Platform: PlatformAPNs, Token: "carol-phone"},
+		{UserID: "alice", Platform: PlatformAPNs, Token: "alice-old-phone"},
+	} {
+		if _, err := store.RegisterDevice(ctx, d); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	if _, err := store.SetPreferences(ctx, Preferences{UserID: "bob", Kinds: []string{schema.AlertBudgetExceeded}}); err != nil {
+		t.Fatalf("set preferences: %v", err)
+	}
+	if _, err := store.SetPreferences(ctx, Preferences{UserID: "bob", Kinds: []string{"nope"}}); err == nil {
+		t.Fatalf("expected unknown kinds to be rejected")
+	}
+
+	pusher := &fakePusher{gone: map[string]bool{"alice-old-phone": true}}
+	dispatcher := NewDispatcher(bus, store, map[string]Pusher{PlatformAPNs: pusher},
+		WithAccess(func(_ context.Context, userID, agentID string) (bool, error) {
+			return userID != "carol" || agentID == "agent-shared", nil
+		}))
+
+	push := func(kind, agentID string) {
+		t.Helper()
+		if _, err := bus.Push(ctx, eventbus.EventInput{
+			Stream: schema.StreamAlerts, ScopeType: "global", ScopeID: "*", Subject: kind, Body: kind,
+			Metadata: map[string]any{schema.MetaKind: kind, schema.MetaAgentID: agentID},
+		}); err != nil {
+			t.Fatalf("push alert: %v", err)
+		}
+	}
+	push(schema.AlertAgentQuarantined, "agent-a")
+	push(schema.AlertBudgetExceeded, "agent-shared")
+
+	sent, err := dispatcher.Deliver(ctx)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	want := []string{
+		"alice:agent_quarantined:agent-a",
+		"alice:budget_exceeded:agent-shared",
+		"bob:budget_exceeded:agent-shared",
+		"carol:budget_exceeded:agent-shared",
+	}
+	if sent != len(want) || len(pusher.sent) != len(want) {
+		t.Fatalf("expected %v, got %v", want, pusher.sent)
+	}
+	for i := range want {
+		if pusher.sent[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, pusher.sent)
+		}
+	}
+	devices, _ := store.Devices(ctx, "alice")
+	if len(devices) != 1 || devices[0].Token != "alice-phone" {
+		t.Fatalf("expected the gone device to be removed, got %+v", devices)
+	}
+
+	if sent, err := dispatcher.Deliver(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected alerts to be delivered once, sent %d err=%v", sent, err)
+	}
+
+	// Alerts older than the max age are skipped, not pushed late.
+	late := NewDispatcher(bus, store, map[string]Pusher{PlatformAPNs: pusher},
+		WithDispatchClock(func() time.Time { return time.Now().Add(2 * DefaultMaxAge) }))
+	push(schema.AlertAgentQuarantined, "agent-b")
+	if sent, err := late.Deliver(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected a stale alert to be skipped, sent %d err=%v", sent, err)
+	}
+}
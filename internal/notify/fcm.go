@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fcmBaseURL  = "https://fcm.googleapis.com"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	googleToken = "https://oauth2.googleapis.com/token"
+)
+
+// ServiceAccount is the subset of a Google service account key file FCM
+// needs.
+type ServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating as a service account.
+type FCM struct {
+	Account ServiceAccount
+	// BaseURL defaults to the public FCM endpoint.
+	BaseURL string
+
+	client *http.Client
+	nowFn  func() time.Time
+
+	mu        sync.Mutex
+	key       *rsa.PrivateKey
+	token     string
+	expiresAt time.Time
+}
+
+// ParseServiceAccount reads a service account key file.
+func ParseServiceAccount(data []byte) (ServiceAccount, error) {
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return ServiceAccount{}, fmt.Errorf("parse fcm credentials: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return ServiceAccount{}, fmt.Errorf("fcm credentials need project_id, client_email and private_key")
+	}
+	return account, nil
+}
+
+func (f *FCM) Push(ctx context.Context, device Device, msg Message) error {
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        device.Token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         map[string]string{"kind": msg.Kind, "agent_id": msg.AgentID, "event_id": msg.EventID},
+			"android":      map[string]any{"priority": "high"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("encode fcm message: %w", err)
+	}
+	token, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	baseURL := f.BaseURL
+	if baseURL == "" {
+		baseURL = fcmBaseURL
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + "/v1/projects/" + url.PathEscape(f.Account.ProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build fcm request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("send fcm: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// UNREGISTERED: the app was uninstalled or the token expired.
+		return ErrDeviceGone
+	}
+	if resp.StatusCode >= 300 {
+		return pushError("fcm", resp)
+	}
+	return nil
+}
+
+// accessToken exchanges a signed service account assertion for an OAuth
+// token, reusing it until shortly before it expires.
+func (f *FCM) accessToken(ctx context.Context) (string, error) {
+	now := time.Now()
+	if f.nowFn != nil {
+		now = f.nowFn()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && now.Before(f.expiresAt) {
+		return f.token, nil
+	}
+	if f.key == nil {
+		key, err := parseRSAKey(f.Account.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		f.key = key
+	}
+	tokenURI := f.Account.TokenURI
+	if tokenURI == "" {
+		tokenURI = googleToken
+	}
+	assertion, err := signJWT(
+		map[string]any{"alg": "RS256", "typ": "JWT"},
+		map[string]any{
+			"iss":   f.Account.ClientEmail,
+			"scope": fcmScope,
+			"aud":   tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		},
+		f.key,
+	)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("build fcm token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := f.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch fcm token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", pushError("fcm token", resp)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode fcm token: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", fmt.Errorf("fcm token response has no access_token")
+	}
+	f.token = out.AccessToken
+	f.expiresAt = now.Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return f.token, nil
+}
+
+func (f *FCM) httpClient() *http.Client {
+	if f.client != nil {
+		return f.client
+	}
+	return &http.Client{Timeout: pushTimeout}
+}
+
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("fcm private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse fcm private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("fcm private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrDeviceGone is returned by a Pusher when the push service reports that
+// the device is no longer registered. The dispatcher forgets such devices.
+var ErrDeviceGone = errors.New("device no longer registered")
+
+const pushTimeout = 15 * time.Second
+
+// Message is the notification shown for one alert.
+type Message struct {
+	Kind    string `json:"kind"`
+	AgentID string `json:"agent_id,omitempty"`
+	Title   string `json:"title"`
+	Body    string `json:"body,omitempty"`
+	EventID string `json:"event_id"`
+}
+
+// Pusher delivers messages to the devices of one platform.
+type Pusher interface {
+	Push(ctx context.Context, device Device, msg Message) error
+}
+
+// pushError describes a rejected push from the service's response.
+func pushError(service string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s push failed: %s: %s", service, resp.Status, body)
+}
+
+var b64 = base64.RawURLEncoding
+
+// signJWT encodes header and claims and signs them with key: ES256 for ECDSA
+// keys (as JOSE r||s) and RS256 for RSA keys.
+func signJWT(header, claims map[string]any, key crypto.Signer) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64.EncodeToString(headerJSON) + "." + b64.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	if ecKey, ok := key.(*ecdsa.PrivateKey); ok {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("sign jwt: %w", err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	} else {
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("sign jwt: %w", err)
+		}
+	}
+	return input + "." + b64.EncodeToString(sig), nil
+}
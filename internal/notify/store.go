@@ -0,0 +1,261 @@
+// Package notify pushes critical operator alerts (an agent quarantined, a
+// budget exceeded, an interrupt nobody acted on) to operators' browsers and
+// phones through web push, APNs and FCM.
+//
+// Alerts are events on the alerts stream. A Dispatcher follows that stream
+// with its own cursor and sends each alert to the devices of every user whose
+// preferences include the alert's kind and who can view the agent concerned.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+const (
+	PlatformWebPush = "webpush"
+	PlatformAPNs    = "apns"
+	PlatformFCM     = "fcm"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// Device is one push destination registered by a user. Token is the APNs
+// device token, the FCM registration token, or the web push endpoint URL; web
+// push subscriptions also carry the browser's P256DH key and Auth secret.
+type Device struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	P256DH    string    `json:"p256dh,omitempty"`
+	Auth      string    `json:"auth,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Preferences are the alert kinds a user is notified about. Users who never
+// set preferences get every kind.
+type Preferences struct {
+	UserID    string    `json:"user_id"`
+	Kinds     []string  `json:"kinds"`
+	Disabled  bool      `json:"disabled"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Wants reports whether the user should be notified about kind.
+func (p Preferences) Wants(kind string) bool {
+	return !p.Disabled && slices.Contains(p.Kinds, kind)
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	return s.nowFn().UTC()
+}
+
+// RegisterDevice adds a device, or updates it when its token is already
+// registered. A token moves to the user who registered it last, so a shared
+// browser only notifies whoever signed in most recently.
+func (s *Store) RegisterDevice(ctx context.Context, device Device) (Device, error) {
+	device.UserID = strings.TrimSpace(device.UserID)
+	device.Platform = strings.ToLower(strings.TrimSpace(device.Platform))
+	device.Token = strings.TrimSpace(device.Token)
+	device.P256DH = strings.TrimSpace(device.P256DH)
+	device.Auth = strings.TrimSpace(device.Auth)
+	device.Name = strings.TrimSpace(device.Name)
+	if device.UserID == "" {
+		return Device{}, fmt.Errorf("user_id is required")
+	}
+	switch device.Platform {
+	case PlatformWebPush:
+		if !strings.HasPrefix(device.Token, "https://") {
+			return Device{}, fmt.Errorf("web push endpoint must be an https URL")
+		}
+		if device.P256DH == "" || device.Auth == "" {
+			return Device{}, fmt.Errorf("web push subscription requires p256dh and auth keys")
+		}
+	case PlatformAPNs, PlatformFCM:
+		if device.Token == "" {
+			return Device{}, fmt.Errorf("token is required")
+		}
+	default:
+		return Device{}, fmt.Errorf("unsupported platform %q (expected webpush, apns or fcm)", device.Platform)
+	}
+	device.ID = s.newIDFn()
+	device.CreatedAt = s.now()
+	device.LastError = ""
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO notify_devices (id, user_id, platform, token, p256dh, auth, name, created_at, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, '')
+		ON CONFLICT(platform, token) DO UPDATE SET
+			user_id = excluded.user_id, p256dh = excluded.p256dh, auth = excluded.auth,
+			name = excluded.name, last_error = ''
+		RETURNING id, created_at
+	`, device.ID, device.UserID, device.Platform, device.Token, device.P256DH, device.Auth, device.Name, device.CreatedAt.Format(time.RFC3339Nano))
+	var createdAt string
+	if err := row.Scan(&device.ID, &createdAt); err != nil {
+		return Device{}, fmt.Errorf("register device: %w", err)
+	}
+	device.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	return device, nil
+}
+
+// Devices lists userID's devices, or every device when userID is empty.
+func (s *Store) Devices(ctx context.Context, userID string) ([]Device, error) {
+	query := `SELECT id, user_id, platform, token, p256dh, auth, name, created_at, last_error FROM notify_devices`
+	var args []any
+	if userID = strings.TrimSpace(userID); userID != "" {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Device{}
+	for rows.Next() {
+		var device Device
+		var createdAt string
+		if err := rows.Scan(&device.ID, &device.UserID, &device.Platform, &device.Token, &device.P256DH, &device.Auth, &device.Name, &createdAt, &device.LastError); err != nil {
+			return nil, fmt.Errorf("scan device: %w", err)
+		}
+		device.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		out = append(out, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate devices: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteDevice removes one of userID's devices. An empty userID removes the
+// device whoever owns it.
+func (s *Store) DeleteDevice(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM notify_devices WHERE id = ?`
+	args := []any{strings.TrimSpace(id)}
+	if userID = strings.TrimSpace(userID); userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("delete device: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// RecordError stores the last delivery failure for a device, or clears it.
+func (s *Store) RecordError(ctx context.Context, id, message string) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE notify_devices SET last_error = ? WHERE id = ?`, message, id); err != nil {
+		return fmt.Errorf("record device error: %w", err)
+	}
+	return nil
+}
+
+// Preferences returns userID's preferences, defaulting to every alert kind.
+func (s *Store) Preferences(ctx context.Context, userID string) (Preferences, error) {
+	userID = strings.TrimSpace(userID)
+	prefs := Preferences{UserID: userID, Kinds: append([]string{}, schema.AlertKinds...)}
+	var kindsJSON, updatedAt string
+	var disabled int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT kinds, disabled, updated_at FROM notify_preferences WHERE user_id = ?
+	`, userID).Scan(&kindsJSON, &disabled, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+	if err != nil {
+		return Preferences{}, fmt.Errorf("load notification preferences: %w", err)
+	}
+	prefs.Kinds = nil
+	_ = json.Unmarshal([]byte(kindsJSON), &prefs.Kinds)
+	if prefs.Kinds == nil {
+		prefs.Kinds = []string{}
+	}
+	prefs.Disabled = disabled != 0
+	prefs.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return prefs, nil
+}
+
+// SetPreferences replaces a user's preferences. Kinds must be known alert
+// kinds; an empty list mutes every kind.
+func (s *Store) SetPreferences(ctx context.Context, prefs Preferences) (Preferences, error) {
+	prefs.UserID = strings.TrimSpace(prefs.UserID)
+	if prefs.UserID == "" {
+		return Preferences{}, fmt.Errorf("user_id is required")
+	}
+	kinds := []string{}
+	for _, kind := range prefs.Kinds {
+		kind = strings.TrimSpace(kind)
+		if !slices.Contains(schema.AlertKinds, kind) {
+			return Preferences{}, fmt.Errorf("unknown alert kind %q (expected one of %s)", kind, strings.Join(schema.AlertKinds, ", "))
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	prefs.Kinds = kinds
+	kindsJSON, err := json.Marshal(kinds)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("encode notification kinds: %w", err)
+	}
+	disabled := 0
+	if prefs.Disabled {
+		disabled = 1
+	}
+	prefs.UpdatedAt = s.now()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO notify_preferences (user_id, kinds, disabled, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			kinds = excluded.kinds, disabled = excluded.disabled, updated_at = excluded.updated_at
+	`, prefs.UserID, string(kindsJSON), disabled, prefs.UpdatedAt.Format(time.RFC3339Nano)); err != nil {
+		return Preferences{}, fmt.Errorf("store notification preferences: %w", err)
+	}
+	return prefs, nil
+}
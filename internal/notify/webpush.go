@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webPushRecordSize is the aes128gcm record size; a notification always fits
+// in one record.
+const webPushRecordSize = 4096
+
+// WebPush sends notifications to browser push subscriptions, encrypting them
+// per RFC 8291 and identifying the server with VAPID (RFC 8292).
+type WebPush struct {
+	// PrivateKey is the VAPID key as a raw P-256 scalar, base64url encoded,
+	// as generated by common web push tooling.
+	PrivateKey string
+	// Subject is a mailto: or https: contact for the push service.
+	Subject string
+
+	client *http.Client
+	nowFn  func() time.Time
+}
+
+// PublicKey returns the VAPID public key browsers pass as
+// applicationServerKey when subscribing.
+func (w *WebPush) PublicKey() (string, error) {
+	key, err := w.signingKey()
+	if err != nil {
+		return "", err
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("encode vapid public key: %w", err)
+	}
+	return b64.EncodeToString(pub), nil
+}
+
+func (w *WebPush) signingKey() (*ecdsa.PrivateKey, error) {
+	raw, err := b64.DecodeString(strings.TrimRight(strings.TrimSpace(w.PrivateKey), "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode vapid private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse vapid private key: %w", err)
+	}
+	return key, nil
+}
+
+func (w *WebPush) Push(ctx context.Context, device Device, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode web push payload: %w", err)
+	}
+	body, err := encryptWebPush(payload, device.P256DH, device.Auth)
+	if err != nil {
+		return err
+	}
+	auth, err := w.vapidAuthorization(device.Token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, device.Token, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build web push request: %w", err)
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Urgency", "high")
+	resp, err := w.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("send web push: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrDeviceGone
+	case resp.StatusCode >= 300:
+		return pushError("web", resp)
+	}
+	return nil
+}
+
+// vapidAuthorization signs a VAPID token for the push service that owns
+// endpoint.
+func (w *WebPush) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse web push endpoint: %w", err)
+	}
+	key, err := w.signingKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now
+	if w.nowFn != nil {
+		now = w.nowFn
+	}
+	token, err := signJWT(
+		map[string]any{"typ": "JWT", "alg": "ES256"},
+		map[string]any{"aud": u.Scheme + "://" + u.Host, "exp": now().Add(12 * time.Hour).Unix(), "sub": w.Subject},
+		key,
+	)
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := w.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + publicKey, nil
+}
+
+func (w *WebPush) httpClient() *http.Client {
+	if w.client != nil {
+		return w.client
+	}
+	return &http.Client{Timeout: pushTimeout}
+}
+
+// encryptWebPush encrypts payload for a subscription's public key and auth
+// secret with the aes128gcm content coding from RFC 8291.
+func encryptWebPush(payload []byte, p256dh, authSecret string) ([]byte, error) {
+	uaPublicRaw, err := b64.DecodeString(strings.TrimRight(p256dh, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode p256dh: %w", err)
+	}
+	auth, err := b64.DecodeString(strings.TrimRight(authSecret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode auth secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parse p256dh: %w", err)
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate web push key: %w", err)
+	}
+	secret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("derive web push secret: %w", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate web push salt: %w", err)
+	}
+	asPublicRaw := asPrivate.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicRaw...)
+	keyInfo = append(keyInfo, asPublicRaw...)
+	ikm, err := hkdf.Key(sha256.New, secret, auth, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the final record; no padding follows it.
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, fmt.Errorf("web push payload too large (%d bytes)", len(payload))
+	}
+
+	header := make([]byte, 0, 16+4+1+len(asPublicRaw))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublicRaw)))
+	header = append(header, asPublicRaw...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decryptWebPush is the browser side of RFC 8291.
+func decryptWebPush(t *testing.T, body []byte, uaPrivate *ecdh.PrivateKey, auth []byte) []byte {
+	t.Helper()
+	salt, rs, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if rs != webPushRecordSize {
+		t.Fatalf("unexpected record size %d", rs)
+	}
+	asPublicRaw := body[21 : 21+idLen]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicRaw)
+	if err != nil {
+		t.Fatalf("parse sender key: %v", err)
+	}
+	secret, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		t.Fatalf("ecdh: %v", err)
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPrivate.PublicKey().Bytes()) + string(asPublicRaw)
+	ikm, _ := hkdf.Key(sha256.New, secret, auth, keyInfo, 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("missing final record delimiter")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestWebPushEncryptsForSubscriptionAndSignsVAPID(t *testing.T) {
+	vapid, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vapidRaw, err := vapid.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+
+	var gotBody []byte
+	var gotHeader http.Header
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	pusher := &WebPush{PrivateKey: b64.EncodeToString(vapidRaw), Subject: "mailto:ops@example.com"}
+	device := Device{
+		Platform: PlatformWebPush,
+		Token:    srv.URL + "/push/abc",
+		P256DH:   b64.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		Auth:     b64.EncodeToString(auth),
+	}
+	msg := Message{Kind: "agent_quarantined", AgentID: "agent-a", Title: "Agent quarantined", EventID: "evt-1"}
+	if err := pusher.Push(context.Background(), device, msg); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if gotHeader.Get("Content-Encoding") != "aes128gcm" || gotHeader.Get("TTL") == "" {
+		t.Fatalf("unexpected headers %v", gotHeader)
+	}
+	var decoded Message
+	if err := json.Unmarshal(decryptWebPush(t, gotBody, uaPrivate, auth), &decoded); err != nil || decoded != msg {
+		t.Fatalf("decrypted %+v err=%v", decoded, err)
+	}
+
+	// The VAPID token must verify against the advertised public key.
+	authz := strings.TrimPrefix(gotHeader.Get("Authorization"), "vapid t=")
+	token, key, ok := strings.Cut(authz, ", k=")
+	publicKey, _ := pusher.PublicKey()
+	if !ok || key != publicKey {
+		t.Fatalf("unexpected authorization %q", gotHeader.Get("Authorization"))
+	}
+	parts := strings.Split(token, ".")
+	claimsJSON, _ := b64.DecodeString(parts[1])
+	var claims map[string]any
+	_ = json.Unmarshal(claimsJSON, &claims)
+	if claims["aud"] != srv.URL || claims["sub"] != "mailto:ops@example.com" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+	sig, _ := b64.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&vapid.PublicKey, digest[:], r, s) {
+		t.Fatalf("vapid signature does not verify")
+	}
+
+	status = http.StatusGone
+	if err := pusher.Push(context.Background(), device, msg); !errors.Is(err, ErrDeviceGone) {
+		t.Fatalf("expected ErrDeviceGone for an expired subscription, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package objectstore reads and writes whole objects in a bucket: S3, any
+// S3-compatible store such as Google Cloud Storage's interoperability API,
+// or a local directory.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key with no object.
+var ErrNotFound = errors.New("object not found")
+
+// Store holds objects by key. Keys are slash-separated paths.
+type Store interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Dir stores objects as files under Root, which suits single-node setups
+// and tests.
+type Dir struct {
+	Root string
+}
+
+func (d Dir) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	// Write to a temporary file first so readers never see half an object.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (d Dir) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("get object %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+func (d Dir) path(key string) (string, error) {
+	if strings.TrimSpace(d.Root) == "" {
+		return "", fmt.Errorf("object directory is not set")
+	}
+	// Cleaning the key as an absolute path keeps it under Root.
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,269 @@
+// Package preprocess rewrites inbound external messages before they reach
+// an agent. A pipeline is a list of steps run in order: trimming,
+// markdown-to-text, translation, attachment extraction and regex rewrites.
+// Each step may be limited to messages from certain services (connectors).
+package preprocess
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// Step types.
+const (
+	StepTrim               = "trim"
+	StepMarkdownToText     = "markdown_to_text"
+	StepTranslate          = "translate"
+	StepExtractAttachments = "extract_attachments"
+	StepRegex              = "regex"
+)
+
+// Step is one transformation. Pattern and Replace belong to regex steps,
+// where Replace may refer to groups as $1 or ${name}; Language to translate
+// steps. A step with ServiceIDs only runs on messages from those services.
+type Step struct {
+	Type       string   `json:"type"`
+	Pattern    string   `json:"pattern,omitempty"`
+	Replace    string   `json:"replace,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	ServiceIDs []string `json:"service_ids,omitempty"`
+}
+
+// Validate checks that a step has a known type and the fields it needs.
+func (s Step) Validate() error {
+	switch s.Type {
+	case StepTrim, StepMarkdownToText, StepExtractAttachments:
+	case StepTranslate:
+		if strings.TrimSpace(s.Language) == "" {
+			return fmt.Errorf("translate step needs a language")
+		}
+	case StepRegex:
+		if s.Pattern == "" {
+			return fmt.Errorf("regex step needs a pattern")
+		}
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("regex step pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown preprocessor type %q", s.Type)
+	}
+	return nil
+}
+
+// Applies reports whether the step runs on messages from serviceID.
+func (s Step) Applies(serviceID string) bool {
+	return len(s.ServiceIDs) == 0 || slices.Contains(s.ServiceIDs, serviceID)
+}
+
+// Validate checks every step of a pipeline.
+func Validate(steps []Step) error {
+	for i, step := range steps {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("preprocessor %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ParseSteps reads a pipeline from decoded JSON. A nil value is an empty
+// pipeline.
+func ParseSteps(raw any) ([]Step, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("preprocessors must be a list of steps")
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	var steps []Step
+	if err := dec.Decode(&steps); err != nil {
+		return nil, fmt.Errorf("preprocessors must be a list of steps: %w", err)
+	}
+	if err := Validate(steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// Translator translates text into a language.
+type Translator interface {
+	Translate(ctx context.Context, text, language string) (string, error)
+}
+
+// Result is a preprocessed message. Applied lists the types of the steps
+// that changed it; Errors the steps that failed and were skipped.
+type Result struct {
+	Text    string
+	Parts   []schema.Part
+	Applied []string
+	Errors  []string
+}
+
+// Run passes text from serviceID through the steps that apply to it. A
+// failing step leaves the text as it was and is recorded in Errors, so a
+// message is never lost to its preprocessing. translator may be nil, which
+// makes translate steps fail.
+func Run(ctx context.Context, steps []Step, text, serviceID string, translator Translator) Result {
+	res := Result{Text: text}
+	for _, step := range steps {
+		if !step.Applies(serviceID) {
+			continue
+		}
+		before, parts := res.Text, len(res.Parts)
+		switch step.Type {
+		case StepTrim:
+			res.Text = trim(res.Text)
+		case StepMarkdownToText:
+			res.Text = MarkdownToText(res.Text)
+		case StepTranslate:
+			if translator == nil {
+				res.Errors = append(res.Errors, "translate: no translator configured")
+				continue
+			}
+			translated, err := translator.Translate(ctx, res.Text, step.Language)
+			if err != nil {
+				res.Errors = append(res.Errors, "translate: "+err.Error())
+				continue
+			}
+			if translated = strings.TrimSpace(translated); translated != "" {
+				res.Text = translated
+			}
+		case StepExtractAttachments:
+			for _, part := range ExtractAttachments(res.Text) {
+				if !slices.Contains(res.Parts, part) {
+					res.Parts = append(res.Parts, part)
+				}
+			}
+		case StepRegex:
+			re, err := regexp.Compile(step.Pattern)
+			if err != nil {
+				res.Errors = append(res.Errors, "regex: "+err.Error())
+				continue
+			}
+			res.Text = re.ReplaceAllString(res.Text, step.Replace)
+		default:
+			res.Errors = append(res.Errors, fmt.Sprintf("unknown preprocessor type %q", step.Type))
+			continue
+		}
+		if res.Text != before || len(res.Parts) != parts {
+			res.Applied = append(res.Applied, step.Type)
+		}
+	}
+	return res
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// trim strips surrounding and trailing whitespace on every line and folds
+// runs of blank lines into one.
+func trim(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var (
+	mdFence      = regexp.MustCompile("(?m)^\\s*```[^\\n]*$\\n?")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdHeading    = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	mdQuote      = regexp.MustCompile(`(?m)^\s{0,3}>\s?`)
+	mdBullet     = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+	mdRule       = regexp.MustCompile(`(?m)^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdStrong     = regexp.MustCompile(`(\*\*|__)([^\n]+?)(\*\*|__)`)
+	mdEmphasis   = regexp.MustCompile(`(^|[^\w*])[*_]([^\s*_][^\n*_]*?)[*_]([^\w*]|$)`)
+	mdStrike     = regexp.MustCompile(`~~([^\n]+?)~~`)
+	mdInlineCode = regexp.MustCompile("`([^`\\n]+)`")
+)
+
+// MarkdownToText renders markdown as plain text: markup is dropped, links
+// become "text (url)" and images "alt (url)". Code keeps its content.
+func MarkdownToText(text string) string {
+	text = mdFence.ReplaceAllString(text, "")
+	text = mdImage.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdImage.FindStringSubmatch(m)
+		if alt := strings.TrimSpace(sub[1]); alt != "" {
+			return alt + " (" + sub[2] + ")"
+		}
+		return sub[2]
+	})
+	text = mdLink.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdLink.FindStringSubmatch(m)
+		if sub[1] == sub[2] {
+			return sub[2]
+		}
+		return sub[1] + " (" + sub[2] + ")"
+	})
+	text = mdRule.ReplaceAllString(text, "")
+	text = mdHeading.ReplaceAllString(text, "")
+	text = mdQuote.ReplaceAllString(text, "")
+	text = mdBullet.ReplaceAllString(text, "$1- ")
+	text = mdInlineCode.ReplaceAllString(text, "$1")
+	text = mdStrong.ReplaceAllString(text, "$2")
+	text = mdStrike.ReplaceAllString(text, "$1")
+	text = mdEmphasis.ReplaceAllString(text, "$1$2$3")
+	return text
+}
+
+var (
+	attachmentLine = regexp.MustCompile(`(?m)^\[(image|file)(?::\s*([^\]]+))?\]\s+(https?://\S+)`)
+	bareURL        = regexp.MustCompile(`https?://[^\s<>()\[\]"']+`)
+	imageExts      = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".heic"}
+	fileExts       = []string{".pdf", ".csv", ".txt", ".json", ".zip", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".mp3", ".mp4", ".wav", ".mov"}
+)
+
+// ExtractAttachments finds the images and files a message refers to:
+// markdown images, attachment lines like "[image: chart.png] https://…"
+// and links to URLs with a known image or file extension. The text is left
+// as it is; the attachments become parts.
+func ExtractAttachments(text string) []schema.Part {
+	var parts []schema.Part
+	seen := map[string]bool{}
+	add := func(part schema.Part) {
+		if seen[part.URL] {
+			return
+		}
+		seen[part.URL] = true
+		parts = append(parts, part)
+	}
+	for _, m := range attachmentLine.FindAllStringSubmatch(text, -1) {
+		add(schema.Part{Type: m[1], URL: m[3], Name: strings.TrimSpace(m[2])})
+	}
+	for _, m := range mdImage.FindAllStringSubmatch(text, -1) {
+		add(schema.Part{Type: schema.PartImage, URL: m[2], Name: urlName(m[2])})
+	}
+	for _, url := range bareURL.FindAllString(text, -1) {
+		url = strings.TrimRight(url, ".,;:!?")
+		ext := strings.ToLower(path.Ext(strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0]))
+		switch {
+		case slices.Contains(imageExts, ext):
+			add(schema.Part{Type: schema.PartImage, URL: url, Name: urlName(url)})
+		case slices.Contains(fileExts, ext):
+			add(schema.Part{Type: schema.PartFile, URL: url, Name: urlName(url)})
+		}
+	}
+	return parts
+}
+
+// urlName is the last path segment of url, without its query.
+func urlName(url string) string {
+	url = strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0]
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	}
+	if _, name, ok := strings.Cut(url, "/"); ok && strings.Trim(name, "/") != "" {
+		return path.Base(name)
+	}
+	return ""
+}
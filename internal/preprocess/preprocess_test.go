@@ -0,0 +1,102 @@
+package preprocess
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+type fakeTranslator struct {
+	err error
+}
+
+func (t fakeTranslator) Translate(_ context.Context, text, language string) (string, error) {
+	if t.err != nil {
+		return "", t.err
+	}
+	return "[" + language + "] " + text, nil
+}
+
+func TestRunAppliesStepsInOrder(t *testing.T) {
+	steps := []Step{
+		{Type: StepTrim},
+		{Type: StepExtractAttachments},
+		{Type: StepMarkdownToText},
+		{Type: StepRegex, Pattern: `(?i)ticket #(\d+)`, Replace: "TICKET-$1"},
+		{Type: StepTranslate, Language: "English", ServiceIDs: []string{"telegram-bot"}},
+	}
+	text := "  # Hello\n\n\n\nSee **ticket #42** and ![the graph](https://example.com/graph.png)  \n"
+
+	res := Run(context.Background(), steps, text, "slack-bot", fakeTranslator{})
+	if res.Text != "Hello\n\nSee TICKET-42 and the graph (https://example.com/graph.png)" {
+		t.Fatalf("unexpected text %q", res.Text)
+	}
+	if want := []string{StepTrim, StepExtractAttachments, StepMarkdownToText, StepRegex}; !slices.Equal(res.Applied, want) {
+		t.Fatalf("expected %v applied, got %v", want, res.Applied)
+	}
+	if len(res.Parts) != 1 || res.Parts[0] != (schema.Part{Type: schema.PartImage, URL: "https://example.com/graph.png", Name: "graph.png"}) {
+		t.Fatalf("unexpected parts %+v", res.Parts)
+	}
+
+	res = Run(context.Background(), steps, "hallo", "telegram-bot", fakeTranslator{})
+	if res.Text != "[English] hallo" || !slices.Equal(res.Applied, []string{StepTranslate}) {
+		t.Fatalf("expected the connector's translate step to run, got %+v", res)
+	}
+	res = Run(context.Background(), steps, "hallo", "telegram-bot", fakeTranslator{err: errors.New("quota")})
+	if res.Text != "hallo" || len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "quota") {
+		t.Fatalf("expected a failed translation to keep the text, got %+v", res)
+	}
+}
+
+func TestMarkdownToText(t *testing.T) {
+	cases := map[string]string{
+		"## Title":                        "Title",
+		"> quoted *words*":                "quoted words",
+		"* one\n+ two":                    "- one\n- two",
+		"a [link](https://x.io) here":     "a link (https://x.io) here",
+		"[https://x.io](https://x.io)":    "https://x.io",
+		"run `go test` now":               "run go test now",
+		"```go\nfmt.Println(1)\n```":      "fmt.Println(1)\n",
+		"keep snake_case_names and 2*3*4": "keep snake_case_names and 2*3*4",
+		"~~old~~ __new__":                 "old new",
+	}
+	for in, want := range cases {
+		if got := MarkdownToText(in); got != want {
+			t.Errorf("MarkdownToText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExtractAttachments(t *testing.T) {
+	text := "Report: https://files.example.com/q3/report.pdf?dl=1, see also https://example.com/page.\n[image: chart.png] https://cdn.example.com/abc\n![](https://cdn.example.com/abc)"
+	parts := ExtractAttachments(text)
+	want := []schema.Part{
+		{Type: schema.PartImage, URL: "https://cdn.example.com/abc", Name: "chart.png"},
+		{Type: schema.PartFile, URL: "https://files.example.com/q3/report.pdf?dl=1", Name: "report.pdf"},
+	}
+	if !slices.Equal(parts, want) {
+		t.Fatalf("expected %+v, got %+v", want, parts)
+	}
+}
+
+func TestParseStepsValidates(t *testing.T) {
+	steps, err := ParseSteps([]any{map[string]any{"type": "regex", "pattern": "a+", "replace": "a"}, map[string]any{"type": "trim"}})
+	if err != nil || len(steps) != 2 {
+		t.Fatalf("expected two steps, got %v (%v)", steps, err)
+	}
+	for _, raw := range []any{
+		"trim",
+		[]any{map[string]any{"type": "shout"}},
+		[]any{map[string]any{"type": "regex", "pattern": "("}},
+		[]any{map[string]any{"type": "translate"}},
+		[]any{map[string]any{"type": "trim", "extra": true}},
+	} {
+		if _, err := ParseSteps(raw); err == nil {
+			t.Errorf("expected %v to be rejected", raw)
+		}
+	}
+}
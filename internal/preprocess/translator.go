@@ -0,0 +1,40 @@
+package preprocess
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const translatePrompt = `You translate messages sent to an AI agent. Reply with only the translation of the message into %s, keeping its meaning, tone, formatting, names, links and code unchanged. If the message is already in %s, reply with it unchanged.`
+
+// LLMTranslator asks a model for the translation. NewSession should return
+// a session on a cheap model without tools.
+type LLMTranslator struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (t LLMTranslator) Translate(ctx context.Context, text, language string) (string, error) {
+	if t.NewSession == nil {
+		return "", fmt.Errorf("translator has no llm")
+	}
+	llm, err := t.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("create translation session: %w", err)
+	}
+	prompt := fmt.Sprintf(translatePrompt, language, language)
+	llm.SystemPrompt = func() content.Content { return content.FromText(prompt) }
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, text) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return "", fmt.Errorf("translate: %w", err)
+	}
+	return out.String(), nil
+}
@@ -11,12 +11,17 @@ import (
 
 	"github.com/flitsinc/go-agents/internal/eventbus"
 	"github.com/flitsinc/go-agents/internal/goagents"
+	"github.com/flitsinc/go-agents/internal/memory"
 	"github.com/flitsinc/go-llms/content"
 )
 
 type Manager struct {
 	Home      string
 	ToolNames []string
+	// Memories, with a positive MemoryLimit, adds up to that many of the
+	// agent's memories most relevant to each message to the system prompt.
+	Memories    *memory.Store
+	MemoryLimit int
 }
 
 const (
@@ -162,3 +167,33 @@ func trimPromptContext(text string, limit int) (string, bool) {
 	out := string(runes[:head]) + "\n\n[...truncated...]\n\n" + string(runes[len(runes)-tail:])
 	return out, true
 }
+
+// maxMemoryPromptChars caps each memory shown in the system prompt.
+const maxMemoryPromptChars = 500
+
+// MemoryPrompt renders the memories of agentID most relevant to message as
+// a system prompt section, or "" when memory injection is off or nothing
+// matches.
+func (m *Manager) MemoryPrompt(ctx context.Context, agentID, message string) string {
+	if m.Memories == nil || m.MemoryLimit <= 0 || strings.TrimSpace(message) == "" {
+		return ""
+	}
+	list, err := m.Memories.Recall(ctx, agentID, memory.Query{Text: message, Limit: m.MemoryLimit})
+	if err != nil || len(list) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## Relevant Memories\n")
+	b.WriteString("Memories you saved that may bear on this message; use recall to look for more.\n")
+	for _, mem := range list {
+		text, trimmed := trimPromptContext(mem.Text, maxMemoryPromptChars)
+		if trimmed {
+			text += " [...use recall for the full memory...]"
+		}
+		fmt.Fprintf(&b, "\n- %s: %s", mem.Key, strings.Join(strings.Fields(text), " "))
+		if len(mem.Tags) > 0 {
+			fmt.Fprintf(&b, " (tags: %s)", strings.Join(mem.Tags, ", "))
+		}
+	}
+	return b.String()
+}
@@ -1,8 +1,12 @@
 package prompt
 
 import (
+	"context"
 	"strings"
 	"testing"
+
+	"github.com/flitsinc/go-agents/internal/memory"
+	"github.com/flitsinc/go-agents/internal/testutil"
 )
 
 func TestMergePromptSectionsIncludesManagedContract(t *testing.T) {
@@ -27,3 +31,31 @@ func TestMergePromptSectionsRejectsEmpty(t *testing.T) {
 		t.Fatalf("expected error when all prompt sections are empty")
 	}
 }
+
+func TestMemoryPromptListsRelevantMemories(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	store := memory.NewStore(db)
+	for _, m := range []memory.Memory{
+		{AgentID: "a", Key: "invoices", Text: "Invoices are sent on the first of the month", Tags: []string{"billing"}},
+		{AgentID: "a", Key: "lunch", Text: "The team orders lunch on Thursdays"},
+	} {
+		if _, _, err := store.Remember(ctx, m); err != nil {
+			t.Fatalf("remember: %v", err)
+		}
+	}
+	m := &Manager{Memories: store}
+	if section := m.MemoryPrompt(ctx, "a", "when are invoices sent?"); section != "" {
+		t.Fatalf("expected no memories without a limit, got %q", section)
+	}
+	m.MemoryLimit = 3
+	section := m.MemoryPrompt(ctx, "a", "when are invoices sent?")
+	if !strings.Contains(section, "- invoices: Invoices are sent on the first of the month (tags: billing)") || strings.Contains(section, "lunch") {
+		t.Fatalf("expected only the invoices memory, got %q", section)
+	}
+	if section := m.MemoryPrompt(ctx, "a", "weather today"); section != "" {
+		t.Fatalf("expected no section when nothing matches, got %q", section)
+	}
+}
@@ -0,0 +1,85 @@
+// Package provision decides which unknown agents may be created on demand.
+// A rule matches agent IDs with a glob such as "support-*" and carries the
+// payload template the new agent starts from. Rules are tried in order and
+// the first match wins.
+package provision
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Rule provisions agents whose ID matches Pattern. Payload is the agent
+// payload, as accepted by POST /api/tasks, with "{{agent_id}}" in any string
+// replaced by the new agent's ID. A rule without a payload starts a bare
+// agent.
+type Rule struct {
+	Pattern string         `json:"pattern"`
+	Payload map[string]any `json:"payload,omitempty"`
+}
+
+// Validate checks that the rule has a well-formed pattern.
+func (r Rule) Validate() error {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return fmt.Errorf("provisioning rule needs a pattern")
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return fmt.Errorf("provisioning rule pattern %q: %w", r.Pattern, err)
+	}
+	return nil
+}
+
+// Validate checks every rule.
+func Validate(rules []Rule) error {
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule whose pattern matches id.
+func Match(rules []Rule, id string) (Rule, bool) {
+	if id == "" {
+		return Rule{}, false
+	}
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Pattern, id); ok {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// PayloadFor returns a copy of the rule's payload with "{{agent_id}}"
+// replaced by id.
+func (r Rule) PayloadFor(id string) map[string]any {
+	payload, _ := substitute(r.Payload, id).(map[string]any)
+	return payload
+}
+
+func substitute(v any, id string) any {
+	switch v := v.(type) {
+	case string:
+		return strings.ReplaceAll(v, "{{agent_id}}", id)
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = substitute(item, id)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = substitute(item, id)
+		}
+		return out
+	default:
+		return v
+	}
+}
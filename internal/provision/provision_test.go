@@ -0,0 +1,36 @@
+package provision
+
+import "testing"
+
+func TestMatchUsesFirstMatchingRule(t *testing.T) {
+	rules := []Rule{
+		{Pattern: "support-vip-*", Payload: map[string]any{"model": "large"}},
+		{Pattern: "support-*", Payload: map[string]any{"system": "Help {{agent_id}}.", "tags": []any{"{{agent_id}}"}}},
+	}
+	if err := Validate(rules); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	rule, ok := Match(rules, "support-acme")
+	if !ok || rule.Pattern != "support-*" {
+		t.Fatalf("expected support-* to match, got %+v, %v", rule, ok)
+	}
+	payload := rule.PayloadFor("support-acme")
+	if payload["system"] != "Help support-acme." || payload["tags"].([]any)[0] != "support-acme" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if rules[1].Payload["system"] != "Help {{agent_id}}." {
+		t.Fatalf("expected the rule's payload left unchanged")
+	}
+	if rule, _ := Match(rules, "support-vip-1"); rule.Pattern != "support-vip-*" {
+		t.Fatalf("expected the earlier rule to win, got %+v", rule)
+	}
+	if _, ok := Match(rules, "sales-acme"); ok {
+		t.Fatalf("expected no match")
+	}
+	if err := Validate([]Rule{{Pattern: "support-["}}); err == nil {
+		t.Fatalf("expected a malformed pattern rejected")
+	}
+	if err := Validate([]Rule{{Pattern: " "}}); err == nil {
+		t.Fatalf("expected an empty pattern rejected")
+	}
+}
@@ -0,0 +1,369 @@
+// Package quality keeps how every LLM turn ended and the feedback people
+// left on it, in the turn_outcomes and turn_feedback tables, and rolls them
+// up into per-agent quality metrics.
+package quality
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// Turn outcomes.
+const (
+	// Answered turns replied to their message.
+	Answered = "answered"
+	// Deferred turns left the answer to later: to tasks still running when
+	// the turn ended, to a scheduled task or reminder, or to no reply at all.
+	Deferred = "deferred"
+	// Failed turns ended with an error or were interrupted.
+	Failed = "failed"
+	// NeededHuman turns asked the sender a question before going on.
+	NeededHuman = "needed_human"
+)
+
+// Outcomes lists every outcome, in the order metrics report them.
+var Outcomes = []string{Answered, Deferred, Failed, NeededHuman}
+
+// Feedback ratings.
+const (
+	Up   = "up"
+	Down = "down"
+)
+
+// Outcome is how one turn, keyed by its llm task, ended.
+type Outcome struct {
+	TaskID  string
+	AgentID string
+	Outcome string
+	// Reason says why the turn was classified so, such as the error or the
+	// tool that deferred it.
+	Reason string
+	Model  string
+	// Input is the message the turn answered and Output its reply.
+	Input  string
+	Output string
+	At     time.Time
+}
+
+// Feedback is one person's rating of a turn. Each author rates a turn
+// once; rating it again replaces the earlier rating.
+type Feedback struct {
+	TaskID  string    `json:"task_id"`
+	AgentID string    `json:"agent_id"`
+	Author  string    `json:"author,omitempty"`
+	Rating  string    `json:"rating"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"created_at"`
+}
+
+// Turn is a classified turn with its feedback, as exported for prompt
+// iteration.
+type Turn struct {
+	TaskID   string     `json:"task_id"`
+	AgentID  string     `json:"agent_id"`
+	Outcome  string     `json:"outcome"`
+	Reason   string     `json:"reason,omitempty"`
+	Model    string     `json:"model,omitempty"`
+	Input    string     `json:"input"`
+	Output   string     `json:"output"`
+	At       time.Time  `json:"created_at"`
+	Feedback []Feedback `json:"feedback"`
+}
+
+// Metrics sums the outcomes and feedback of an agent's turns.
+type Metrics struct {
+	AgentID  string           `json:"agent_id"`
+	Turns    int64            `json:"turns"`
+	Outcomes map[string]int64 `json:"outcomes"`
+	// AnsweredRate is the share of turns that answered, zero without turns.
+	AnsweredRate float64 `json:"answered_rate"`
+	ThumbsUp     int64   `json:"thumbs_up"`
+	ThumbsDown   int64   `json:"thumbs_down"`
+	// Approval is the share of ratings that are thumbs up, zero without
+	// ratings.
+	Approval float64 `json:"approval"`
+}
+
+// Filter selects turns. From and To bound when turns ended, [From, To);
+// zero leaves that side open. Rating keeps only turns someone rated so.
+type Filter struct {
+	AgentID string
+	Outcome string
+	Rating  string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+type Store struct {
+	db    *sql.DB
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// ValidOutcome reports whether outcome is one of Outcomes.
+func ValidOutcome(outcome string) bool {
+	return slices.Contains(Outcomes, outcome)
+}
+
+// ParseRating accepts up and down, and their thumbs_ and +1/-1 spellings.
+func ParseRating(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case Up, "thumbs_up", "+1":
+		return Up, nil
+	case Down, "thumbs_down", "-1":
+		return Down, nil
+	}
+	return "", fmt.Errorf("rating must be %q or %q", Up, Down)
+}
+
+// RecordOutcome stores o, replacing an earlier outcome for the same turn. A
+// zero At is the current time.
+func (s *Store) RecordOutcome(ctx context.Context, o Outcome) error {
+	o.TaskID = strings.TrimSpace(o.TaskID)
+	o.AgentID = strings.TrimSpace(o.AgentID)
+	if o.TaskID == "" || o.AgentID == "" {
+		return fmt.Errorf("task_id and agent_id are required")
+	}
+	if !ValidOutcome(o.Outcome) {
+		return fmt.Errorf("unknown outcome %q", o.Outcome)
+	}
+	if o.At.IsZero() {
+		o.At = s.now()
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO turn_outcomes (task_id, agent_id, outcome, reason, model, input, output, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET
+			agent_id = excluded.agent_id, outcome = excluded.outcome, reason = excluded.reason,
+			model = excluded.model, input = excluded.input, output = excluded.output,
+			created_at = excluded.created_at
+	`, o.TaskID, o.AgentID, o.Outcome, strings.TrimSpace(o.Reason), strings.TrimSpace(o.Model),
+		o.Input, o.Output, o.At.UTC().Format(state.TimeLayout)); err != nil {
+		return fmt.Errorf("record outcome: %w", err)
+	}
+	return nil
+}
+
+// RecordFeedback stores f, replacing the author's earlier rating of the
+// same turn, and returns it as stored.
+func (s *Store) RecordFeedback(ctx context.Context, f Feedback) (Feedback, error) {
+	f.TaskID = strings.TrimSpace(f.TaskID)
+	f.AgentID = strings.TrimSpace(f.AgentID)
+	f.Author = strings.TrimSpace(f.Author)
+	f.Comment = strings.TrimSpace(f.Comment)
+	if f.TaskID == "" || f.AgentID == "" {
+		return Feedback{}, fmt.Errorf("task_id and agent_id are required")
+	}
+	rating, err := ParseRating(f.Rating)
+	if err != nil {
+		return Feedback{}, err
+	}
+	f.Rating = rating
+	if f.At.IsZero() {
+		f.At = s.now()
+	}
+	f.At = f.At.UTC()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO turn_feedback (task_id, author, agent_id, rating, comment, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id, author) DO UPDATE SET
+			agent_id = excluded.agent_id, rating = excluded.rating,
+			comment = excluded.comment, created_at = excluded.created_at
+	`, f.TaskID, f.Author, f.AgentID, f.Rating, f.Comment, f.At.Format(state.TimeLayout)); err != nil {
+		return Feedback{}, fmt.Errorf("record feedback: %w", err)
+	}
+	return f, nil
+}
+
+// Metrics rolls up the turns of f.AgentID that match f. Ratings count when
+// the turn they rate matches.
+func (s *Store) Metrics(ctx context.Context, f Filter) (Metrics, error) {
+	where, args := f.where()
+	out := Metrics{AgentID: strings.TrimSpace(f.AgentID), Outcomes: map[string]int64{}}
+	for _, outcome := range Outcomes {
+		out.Outcomes[outcome] = 0
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT outcome, COUNT(*) FROM turn_outcomes`+where+` GROUP BY outcome`, args...)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("count outcomes: %w", err)
+	}
+	for rows.Next() {
+		var outcome string
+		var n int64
+		if err := rows.Scan(&outcome, &n); err != nil {
+			rows.Close()
+			return Metrics{}, fmt.Errorf("scan outcomes: %w", err)
+		}
+		out.Outcomes[outcome] += n
+		out.Turns += n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Metrics{}, fmt.Errorf("iterate outcomes: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT rating, COUNT(*) FROM turn_feedback
+		WHERE task_id IN (SELECT task_id FROM turn_outcomes`+where+`)
+		GROUP BY rating`, args...)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("count feedback: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rating string
+		var n int64
+		if err := rows.Scan(&rating, &n); err != nil {
+			return Metrics{}, fmt.Errorf("scan feedback: %w", err)
+		}
+		switch rating {
+		case Up:
+			out.ThumbsUp += n
+		case Down:
+			out.ThumbsDown += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Metrics{}, fmt.Errorf("iterate feedback: %w", err)
+	}
+	if out.Turns > 0 {
+		out.AnsweredRate = float64(out.Outcomes[Answered]) / float64(out.Turns)
+	}
+	if rated := out.ThumbsUp + out.ThumbsDown; rated > 0 {
+		out.Approval = float64(out.ThumbsUp) / float64(rated)
+	}
+	return out, nil
+}
+
+// Turns lists the turns matching f oldest first, each with its feedback.
+// A zero Limit returns at most 1000 turns.
+func (s *Store) Turns(ctx context.Context, f Filter) ([]Turn, error) {
+	limit := f.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	where, args := f.where()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT task_id, agent_id, outcome, reason, model, input, output, created_at
+		FROM turn_outcomes`+where+`
+		ORDER BY created_at, task_id LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("list turns: %w", err)
+	}
+	var out []Turn
+	index := map[string]int{}
+	for rows.Next() {
+		var turn Turn
+		var at string
+		if err := rows.Scan(&turn.TaskID, &turn.AgentID, &turn.Outcome, &turn.Reason, &turn.Model, &turn.Input, &turn.Output, &at); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan turn: %w", err)
+		}
+		turn.At, _ = time.Parse(state.TimeLayout, at)
+		turn.Feedback = []Feedback{}
+		index[turn.TaskID] = len(out)
+		out = append(out, turn)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate turns: %w", err)
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	placeholders := make([]string, 0, len(out))
+	ids := make([]any, 0, len(out))
+	for _, turn := range out {
+		placeholders = append(placeholders, "?")
+		ids = append(ids, turn.TaskID)
+	}
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT task_id, agent_id, author, rating, comment, created_at
+		FROM turn_feedback WHERE task_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY created_at, author`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("list feedback: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fb Feedback
+		var at string
+		if err := rows.Scan(&fb.TaskID, &fb.AgentID, &fb.Author, &fb.Rating, &fb.Comment, &at); err != nil {
+			return nil, fmt.Errorf("scan feedback: %w", err)
+		}
+		fb.At, _ = time.Parse(state.TimeLayout, at)
+		if i, ok := index[fb.TaskID]; ok {
+			out[i].Feedback = append(out[i].Feedback, fb)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feedback: %w", err)
+	}
+	return out, nil
+}
+
+func (f Filter) where() (string, []any) {
+	var where []string
+	var args []any
+	if id := strings.TrimSpace(f.AgentID); id != "" {
+		where = append(where, "agent_id = ?")
+		args = append(args, id)
+	}
+	if outcome := strings.TrimSpace(f.Outcome); outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, outcome)
+	}
+	if rating := strings.TrimSpace(f.Rating); rating != "" {
+		where = append(where, "task_id IN (SELECT task_id FROM turn_feedback WHERE rating = ?)")
+		args = append(args, rating)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From.UTC().Format(state.TimeLayout))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To.UTC().Format(state.TimeLayout))
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
@@ -0,0 +1,72 @@
+package quality
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestMetricsRollUpOutcomesAndFeedback(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	store := NewStore(db, WithClock(func() time.Time { return now }))
+	for i, o := range []Outcome{
+		{TaskID: "llm-1", AgentID: "a", Outcome: Answered, Input: "hi", Output: "hello"},
+		{TaskID: "llm-2", AgentID: "a", Outcome: Failed, Reason: "boom"},
+		{TaskID: "llm-3", AgentID: "a", Outcome: Answered, Input: "sum?", Output: "4"},
+		{TaskID: "llm-4", AgentID: "a", Outcome: NeededHuman},
+		{TaskID: "llm-5", AgentID: "b", Outcome: Answered},
+	} {
+		o.At = now.Add(time.Duration(i) * time.Minute)
+		if err := store.RecordOutcome(ctx, o); err != nil {
+			t.Fatalf("record outcome: %v", err)
+		}
+	}
+	if err := store.RecordOutcome(ctx, Outcome{TaskID: "llm-6", AgentID: "a", Outcome: "meh"}); err == nil {
+		t.Fatalf("expected an unknown outcome rejected")
+	}
+	for _, fb := range []Feedback{
+		{TaskID: "llm-1", AgentID: "a", Author: "alice", Rating: "down"},
+		// Rating again replaces alice's earlier rating.
+		{TaskID: "llm-1", AgentID: "a", Author: "alice", Rating: "+1", Comment: "good"},
+		{TaskID: "llm-1", AgentID: "a", Author: "bob", Rating: "up"},
+		{TaskID: "llm-3", AgentID: "a", Author: "bob", Rating: "thumbs_down", Comment: "wrong"},
+		{TaskID: "llm-5", AgentID: "b", Author: "bob", Rating: "down"},
+	} {
+		if _, err := store.RecordFeedback(ctx, fb); err != nil {
+			t.Fatalf("record feedback: %v", err)
+		}
+	}
+
+	m, err := store.Metrics(ctx, Filter{AgentID: "a"})
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	if m.Turns != 4 || m.Outcomes[Answered] != 2 || m.Outcomes[Failed] != 1 || m.Outcomes[Deferred] != 0 || m.AnsweredRate != 0.5 {
+		t.Fatalf("unexpected outcome metrics %+v", m)
+	}
+	if m.ThumbsUp != 2 || m.ThumbsDown != 1 || m.Approval < 0.66 || m.Approval > 0.67 {
+		t.Fatalf("unexpected feedback metrics %+v", m)
+	}
+
+	turns, err := store.Turns(ctx, Filter{AgentID: "a", Rating: Down})
+	if err != nil {
+		t.Fatalf("turns: %v", err)
+	}
+	if len(turns) != 1 || turns[0].TaskID != "llm-3" || turns[0].Output != "4" || turns[0].Feedback[0].Comment != "wrong" {
+		t.Fatalf("expected the thumbs-down turn with its feedback, got %+v", turns)
+	}
+	turns, err = store.Turns(ctx, Filter{AgentID: "a", From: now.Add(time.Minute), Limit: 2})
+	if err != nil || len(turns) != 2 || turns[0].TaskID != "llm-2" || len(turns[0].Feedback) != 0 {
+		t.Fatalf("expected two turns from the second on, got %+v, %v", turns, err)
+	}
+
+	if _, err := ParseRating("sideways"); err == nil {
+		t.Fatalf("expected an unknown rating rejected")
+	}
+}
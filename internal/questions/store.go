@@ -0,0 +1,246 @@
+// Package questions tracks questions agents ask their users. A question is
+// recorded when it is asked and stays pending until a reply from the same
+// source answers it, so a clarification can span turns and days.
+package questions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusAnswered  = "answered"
+	StatusCancelled = "cancelled"
+)
+
+var ErrQuestionNotFound = errors.New("question not found")
+
+// Question is one question an agent asked. Source, ServiceID, RequestID and
+// Context describe where it was sent; a reply from the same source answers
+// it.
+type Question struct {
+	ID            string         `json:"id"`
+	AgentID       string         `json:"agent_id"`
+	Question      string         `json:"question"`
+	Options       []string       `json:"options,omitempty"`
+	Source        string         `json:"source,omitempty"`
+	ServiceID     string         `json:"service_id,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+	Context       map[string]any `json:"context,omitempty"`
+	Status        string         `json:"status"`
+	Answer        string         `json:"answer,omitempty"`
+	AnswerEventID string         `json:"answer_event_id,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	AnsweredAt    *time.Time     `json:"answered_at,omitempty"`
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Ask records a pending question.
+func (s *Store) Ask(ctx context.Context, q Question) (Question, error) {
+	q.AgentID = strings.TrimSpace(q.AgentID)
+	if q.AgentID == "" {
+		return Question{}, fmt.Errorf("agent_id is required")
+	}
+	q.Question = strings.TrimSpace(q.Question)
+	if q.Question == "" {
+		return Question{}, fmt.Errorf("question is required")
+	}
+	options := make([]string, 0, len(q.Options))
+	for _, option := range q.Options {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	q.Options = options
+	q.ID = "q-" + s.newIDFn()
+	q.Status = StatusPending
+	q.Answer = ""
+	q.AnswerEventID = ""
+	q.AnsweredAt = nil
+	q.CreatedAt = s.now()
+	optionsJSON, err := json.Marshal(q.Options)
+	if err != nil {
+		return Question{}, fmt.Errorf("encode options: %w", err)
+	}
+	var contextJSON []byte
+	if len(q.Context) > 0 {
+		if contextJSON, err = json.Marshal(q.Context); err != nil {
+			return Question{}, fmt.Errorf("encode context: %w", err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO questions (id, agent_id, question, options, source, service_id, request_id, context, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, q.ID, q.AgentID, q.Question, string(optionsJSON), strings.TrimSpace(q.Source), strings.TrimSpace(q.ServiceID),
+		strings.TrimSpace(q.RequestID), string(contextJSON), q.Status, q.CreatedAt.Format(state.TimeLayout)); err != nil {
+		return Question{}, fmt.Errorf("insert question: %w", err)
+	}
+	return q, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (Question, error) {
+	list, err := s.query(ctx, `SELECT `+questionColumns+` FROM questions WHERE id = ?`, strings.TrimSpace(id))
+	if err != nil {
+		return Question{}, err
+	}
+	if len(list) == 0 {
+		return Question{}, ErrQuestionNotFound
+	}
+	return list[0], nil
+}
+
+// List returns agentID's questions newest first, optionally with one status.
+func (s *Store) List(ctx context.Context, agentID, status string, limit int) ([]Question, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `SELECT ` + questionColumns + ` FROM questions WHERE agent_id = ?`
+	args := []any{strings.TrimSpace(agentID)}
+	if status = strings.TrimSpace(status); status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	return s.query(ctx, query+` ORDER BY created_at DESC, id DESC LIMIT ?`, args...)
+}
+
+// Match finds the pending question a message to agentID answers. A message
+// naming questionID answers that question; otherwise it answers the most
+// recent pending question sent to the message's source and service.
+func (s *Store) Match(ctx context.Context, agentID, source, serviceID, questionID string) (Question, bool, error) {
+	agentID = strings.TrimSpace(agentID)
+	if questionID = strings.TrimSpace(questionID); questionID != "" {
+		q, err := s.Get(ctx, questionID)
+		if errors.Is(err, ErrQuestionNotFound) {
+			return Question{}, false, nil
+		}
+		if err != nil {
+			return Question{}, false, err
+		}
+		if q.AgentID != agentID || q.Status != StatusPending {
+			return Question{}, false, nil
+		}
+		return q, true, nil
+	}
+	list, err := s.query(ctx, `SELECT `+questionColumns+` FROM questions
+		WHERE agent_id = ? AND status = ? AND source = ? AND service_id = ?
+		ORDER BY created_at DESC, id DESC LIMIT 1`,
+		agentID, StatusPending, strings.TrimSpace(source), strings.TrimSpace(serviceID))
+	if err != nil || len(list) == 0 {
+		return Question{}, false, err
+	}
+	return list[0], true, nil
+}
+
+// Answer records the reply to a pending question.
+func (s *Store) Answer(ctx context.Context, id, answer, eventID string) (Question, error) {
+	return s.close(ctx, id, StatusAnswered, strings.TrimSpace(answer), strings.TrimSpace(eventID))
+}
+
+// Cancel withdraws a pending question so no reply is matched to it.
+func (s *Store) Cancel(ctx context.Context, id string) (Question, error) {
+	return s.close(ctx, id, StatusCancelled, "", "")
+}
+
+func (s *Store) close(ctx context.Context, id, status, answer, eventID string) (Question, error) {
+	id = strings.TrimSpace(id)
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE questions SET status = ?, answer = ?, answer_event_id = ?, answered_at = ?
+		WHERE id = ? AND status = ?
+	`, status, answer, eventID, s.now().Format(state.TimeLayout), id, StatusPending)
+	if err != nil {
+		return Question{}, fmt.Errorf("update question: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return Question{}, fmt.Errorf("update question rows affected: %w", err)
+	}
+	q, err := s.Get(ctx, id)
+	if err != nil {
+		return Question{}, err
+	}
+	if affected == 0 {
+		return q, fmt.Errorf("question %s is already %s", id, q.Status)
+	}
+	return q, nil
+}
+
+const questionColumns = `id, agent_id, question, options, source, service_id, request_id, context, status, answer, answer_event_id, created_at, answered_at`
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]Question, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query questions: %w", err)
+	}
+	defer rows.Close()
+	out := []Question{}
+	for rows.Next() {
+		var q Question
+		var options, contextJSON, createdAt string
+		var answeredAt sql.NullString
+		if err := rows.Scan(&q.ID, &q.AgentID, &q.Question, &options, &q.Source, &q.ServiceID, &q.RequestID,
+			&contextJSON, &q.Status, &q.Answer, &q.AnswerEventID, &createdAt, &answeredAt); err != nil {
+			return nil, fmt.Errorf("scan question: %w", err)
+		}
+		_ = json.Unmarshal([]byte(options), &q.Options)
+		if contextJSON != "" {
+			_ = json.Unmarshal([]byte(contextJSON), &q.Context)
+		}
+		q.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		if answeredAt.Valid && answeredAt.String != "" {
+			at, _ := time.Parse(state.TimeLayout, answeredAt.String)
+			q.AnsweredAt = &at
+		}
+		out = append(out, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate questions: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package questions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreMatchesRepliesToPendingQuestions(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := NewStore(db, WithClock(func() time.Time { return now }))
+
+	ask := func(source, serviceID, question string) Question {
+		t.Helper()
+		q, err := store.Ask(ctx, Question{AgentID: "agent-a", Question: question, Source: source, ServiceID: serviceID, Options: []string{" yes ", "", "no"}})
+		if err != nil {
+			t.Fatalf("ask: %v", err)
+		}
+		now = now.Add(time.Minute)
+		return q
+	}
+	older := ask("telegram", "telegram", "Which branch?")
+	latest := ask("telegram", "telegram", "Deploy now?")
+	web := ask("web", "", "Which region?")
+	if len(latest.Options) != 2 || latest.Status != StatusPending {
+		t.Fatalf("unexpected question %+v", latest)
+	}
+
+	if _, ok, _ := store.Match(ctx, "agent-a", "slack", "slack", ""); ok {
+		t.Fatalf("expected no match for a source that was not asked")
+	}
+	if _, ok, _ := store.Match(ctx, "agent-b", "telegram", "telegram", ""); ok {
+		t.Fatalf("expected questions to be per agent")
+	}
+	match, ok, err := store.Match(ctx, "agent-a", "telegram", "telegram", "")
+	if err != nil || !ok || match.ID != latest.ID {
+		t.Fatalf("expected the latest question from the source, got %+v ok=%v err=%v", match, ok, err)
+	}
+	match, ok, _ = store.Match(ctx, "agent-a", "web", "", older.ID)
+	if !ok || match.ID != older.ID {
+		t.Fatalf("expected an explicit question id to win, got %+v", match)
+	}
+
+	answered, err := store.Answer(ctx, latest.ID, "yes", "evt-1")
+	if err != nil {
+		t.Fatalf("answer: %v", err)
+	}
+	if answered.Status != StatusAnswered || answered.Answer != "yes" || answered.AnsweredAt == nil {
+		t.Fatalf("unexpected answered question %+v", answered)
+	}
+	if _, err := store.Answer(ctx, latest.ID, "again", ""); err == nil {
+		t.Fatalf("expected a second answer to be rejected")
+	}
+	if match, _, _ := store.Match(ctx, "agent-a", "telegram", "telegram", ""); match.ID != older.ID {
+		t.Fatalf("expected the older question to be next, got %+v", match)
+	}
+	if _, err := store.Cancel(ctx, web.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	pending, err := store.List(ctx, "agent-a", StatusPending, 0)
+	if err != nil || len(pending) != 1 || pending[0].ID != older.ID {
+		t.Fatalf("expected one pending question, got %+v err=%v", pending, err)
+	}
+}
@@ -0,0 +1,289 @@
+// Package replay runs a recorded slice of bus events against a sandbox copy
+// of an agent. The sandbox has its own database and dry-run tools, so the
+// transcript shows what the agent would have done without side effects.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const defaultSandboxAgentID = "sandbox"
+
+type Options struct {
+	// AgentID is the sandbox agent that receives the replayed events.
+	AgentID string
+	// System and Model override the agent's prompt and model for the run.
+	System string
+	Model  string
+	// Client provides the model and the tool set; tools are wrapped with
+	// agenttools.DryRun before use.
+	Client *ai.Client
+	// LLMFactory overrides Client when set, mainly for tests.
+	LLMFactory func() (*llms.LLM, error)
+	// Dir holds the sandbox database. A temporary directory is used and
+	// removed afterwards when empty.
+	Dir string
+}
+
+type Turn struct {
+	EventID string     `json:"event_id"`
+	Source  string     `json:"source,omitempty"`
+	Input   string     `json:"input"`
+	Output  string     `json:"output,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Tools   []ToolCall `json:"tools,omitempty"`
+	// Repro holds the model snapshot, sampling parameters and fingerprints
+	// of the turn's llm task, for comparing against the original run.
+	Repro   []engine.LLMRepro          `json:"repro,omitempty"`
+	History []engine.AgentHistoryEntry `json:"history,omitempty"`
+}
+
+type ToolCall struct {
+	ToolCallID string         `json:"tool_call_id"`
+	ToolName   string         `json:"tool_name"`
+	Args       any            `json:"args,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+type Transcript struct {
+	AgentID       string    `json:"agent_id"`
+	Events        int       `json:"events"`
+	ContextEvents int       `json:"context_events"`
+	Turns         []Turn    `json:"turns"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+// ReadEvents decodes exported events, either as a JSON array or as one JSON
+// event per line.
+func ReadEvents(r io.Reader) ([]eventbus.Event, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read events: %w", err)
+	}
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil, nil
+	}
+	var events []eventbus.Event
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &events); err != nil {
+			return nil, fmt.Errorf("decode events: %w", err)
+		}
+		return events, nil
+	}
+	for i, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var evt eventbus.Event
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			return nil, fmt.Errorf("decode event on line %d: %w", i+1, err)
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// Run replays events in creation order. Message events become agent turns;
+// everything else is pushed into the sandbox bus so it shows up as context
+// on the next turn.
+func Run(ctx context.Context, events []eventbus.Event, opts Options) (Transcript, error) {
+	agentID := strings.TrimSpace(opts.AgentID)
+	if agentID == "" {
+		agentID = defaultSandboxAgentID
+	}
+	transcript := Transcript{AgentID: agentID, Events: len(events), StartedAt: time.Now().UTC()}
+
+	dir := strings.TrimSpace(opts.Dir)
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "go-agents-replay-")
+		if err != nil {
+			return transcript, fmt.Errorf("create sandbox dir: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+	db, err := state.Open(filepath.Join(dir, "sandbox.db"))
+	if err != nil {
+		return transcript, fmt.Errorf("open sandbox db: %w", err)
+	}
+	defer db.Close()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	var client *ai.Client
+	if opts.Client != nil {
+		client, err = opts.Client.WithTools(agenttools.DryRun(opts.Client.Tools()...)...)
+		if err != nil {
+			return transcript, fmt.Errorf("prepare sandbox client: %w", err)
+		}
+	}
+	rt := engine.NewRuntime(bus, mgr, client)
+	if opts.LLMFactory != nil {
+		rt.LLMFactory = opts.LLMFactory
+	}
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: agentID, Type: "agent", Metadata: map[string]any{"source": "replay"}}); err != nil {
+		return transcript, fmt.Errorf("create sandbox agent: %w", err)
+	}
+	_ = mgr.MarkRunning(ctx, agentID)
+	if strings.TrimSpace(opts.System) != "" {
+		rt.SetAgentSystem(agentID, opts.System)
+	}
+	if strings.TrimSpace(opts.Model) != "" {
+		rt.SetAgentModel(agentID, opts.Model)
+	}
+
+	ordered := append([]eventbus.Event(nil), events...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+	})
+
+	historyCursor := ""
+	for _, evt := range ordered {
+		if err := ctx.Err(); err != nil {
+			return transcript, err
+		}
+		if !isMessageEvent(evt) {
+			if _, err := bus.Push(ctx, sandboxContextEvent(evt, agentID)); err == nil {
+				transcript.ContextEvents++
+			}
+			continue
+		}
+		turn := Turn{
+			EventID: evt.ID,
+			Source:  schema.GetMetaString(evt.Metadata, "source"),
+			Input:   evt.Body,
+		}
+		meta := cloneMap(evt.Metadata)
+		if meta == nil {
+			meta = map[string]any{}
+		}
+		meta["kind"] = "message"
+		session, err := rt.HandleMessage(ctx, agentID, turn.Source, evt.Body, meta)
+		turn.Output = session.LastOutput
+		if err != nil {
+			turn.Error = err.Error()
+		} else if session.LastError != "" {
+			turn.Error = session.LastError
+		}
+		turn.History, historyCursor = readHistorySince(ctx, bus, agentID, historyCursor)
+		turn.Tools = toolCallsFromHistory(turn.History)
+		for _, entry := range turn.History {
+			if repro, ok := engine.LLMReproFromEntry(entry); ok {
+				turn.Repro = append(turn.Repro, repro)
+			}
+		}
+		transcript.Turns = append(transcript.Turns, turn)
+	}
+	transcript.FinishedAt = time.Now().UTC()
+	return transcript, nil
+}
+
+func isMessageEvent(evt eventbus.Event) bool {
+	return evt.Stream == schema.StreamTaskInput && schema.GetMetaString(evt.Metadata, schema.MetaKind) == "message"
+}
+
+// sandboxContextEvent retargets a recorded event at the sandbox agent.
+func sandboxContextEvent(evt eventbus.Event, agentID string) eventbus.EventInput {
+	scopeType, scopeID := evt.ScopeType, evt.ScopeID
+	if scopeType == "task" {
+		scopeID = agentID
+	}
+	meta := cloneMap(evt.Metadata)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta["replayed_event_id"] = evt.ID
+	return eventbus.EventInput{
+		Stream:    evt.Stream,
+		ScopeType: scopeType,
+		ScopeID:   scopeID,
+		Subject:   evt.Subject,
+		Body:      evt.Body,
+		Metadata:  meta,
+		Payload:   evt.Payload,
+	}
+}
+
+func readHistorySince(ctx context.Context, bus *eventbus.Bus, agentID, after string) ([]engine.AgentHistoryEntry, string) {
+	items, err := bus.List(ctx, schema.StreamHistory, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Order:     "fifo",
+		Limit:     2000,
+		After:     after,
+	})
+	if err != nil || len(items) == 0 {
+		return nil, after
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	events, err := bus.Read(ctx, schema.StreamHistory, ids, "")
+	if err != nil {
+		return nil, after
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+	out := make([]engine.AgentHistoryEntry, 0, len(events))
+	for _, evt := range events {
+		if entry, ok := engine.HistoryEntryFromEvent(evt); ok {
+			out = append(out, entry)
+		}
+	}
+	return out, items[len(items)-1].ID
+}
+
+func toolCallsFromHistory(entries []engine.AgentHistoryEntry) []ToolCall {
+	var out []ToolCall
+	for _, entry := range entries {
+		if entry.Type != "tool_result" {
+			continue
+		}
+		call := ToolCall{
+			ToolCallID: entry.ToolCallID,
+			ToolName:   entry.ToolName,
+			Args:       entry.Data["args"],
+		}
+		if result, ok := entry.Data["result"].(map[string]any); ok {
+			call.Data = result
+		}
+		out = append(out, call)
+	}
+	return out
+}
+
+func cloneMap(in map[string]any) map[string]any {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/toolresult"
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+type replayProvider struct {
+	mu       sync.Mutex
+	calls    int
+	debugger llms.Debugger
+}
+
+func (p *replayProvider) Company() string              { return "replay" }
+func (p *replayProvider) Model() string                { return "replay" }
+func (p *replayProvider) SetDebugger(d llms.Debugger)  { p.debugger = d }
+func (p *replayProvider) SetHTTPClient(_ *http.Client) {}
+func (p *replayProvider) Generate(_ context.Context, _ content.Content, messages []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.debugger != nil {
+		p.debugger.RawRequest("https://example.test/v1/chat/completions", []byte(`{"model":"replay-1","seed":7,"temperature":0}`))
+		p.debugger.RawEvent([]byte(`data: {"id":"chatcmpl-1","model":"replay-1-0501","system_fingerprint":"fp_1"}`))
+	}
+	last := messages[len(messages)-1]
+	if last.Role == "user" && strings.Contains(messageText(last), "deploy") {
+		return &replayStream{toolCall: llms.ToolCall{ID: "call-1", Name: "deploy", Arguments: json.RawMessage(`{"env":"prod"}`)}}
+	}
+	return &replayStream{text: "done"}
+}
+
+type replayStream struct {
+	text     string
+	toolCall llms.ToolCall
+}
+
+func (s *replayStream) Err() error { return nil }
+func (s *replayStream) Message() llms.Message {
+	msg := llms.Message{Role: "assistant"}
+	if s.text != "" {
+		msg.Content = content.FromText(s.text)
+	}
+	if s.toolCall.ID != "" {
+		msg.ToolCalls = []llms.ToolCall{s.toolCall}
+	}
+	return msg
+}
+func (s *replayStream) Text() string             { return s.text }
+func (s *replayStream) Image() (string, string)  { return "", "" }
+func (s *replayStream) Thought() content.Thought { return content.Thought{} }
+func (s *replayStream) ToolCall() llms.ToolCall  { return s.toolCall }
+func (s *replayStream) Usage() llms.Usage        { return llms.Usage{} }
+func (s *replayStream) Iter() func(func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if s.text != "" {
+			yield(llms.StreamStatusText)
+			return
+		}
+		if yield(llms.StreamStatusToolCallBegin) {
+			yield(llms.StreamStatusToolCallReady)
+		}
+	}
+}
+
+func messageText(msg llms.Message) string {
+	var parts []string
+	for _, item := range msg.Content {
+		if text, ok := item.(*content.Text); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+type deployParams struct {
+	Env string `json:"env"`
+}
+
+func TestRunProducesTranscriptWithoutRunningTools(t *testing.T) {
+	if _, err := exec.LookPath("bun"); err != nil {
+		t.Skip("bun not installed")
+	}
+
+	var executed atomic.Bool
+	deploy := llmtools.Func("Deploy", "Deploy the service", "deploy", func(_ llmtools.Runner, _ deployParams) llmtools.Result {
+		executed.Store(true)
+		return toolresult.Success("deploy", map[string]any{"ok": true})
+	})
+	provider := &replayProvider{}
+
+	now := time.Now().UTC()
+	events := []eventbus.Event{
+		{ID: "evt-1", Stream: "task_input", ScopeType: "task", ScopeID: "prod-agent", Body: "please deploy", Metadata: map[string]any{"kind": "message", "source": "operator"}, CreatedAt: now},
+		{ID: "evt-2", Stream: "task_output", ScopeType: "task", ScopeID: "prod-agent", Body: "build finished", Metadata: map[string]any{"kind": "progress"}, CreatedAt: now.Add(time.Second)},
+		{ID: "evt-3", Stream: "task_input", ScopeType: "task", ScopeID: "prod-agent", Body: "thanks", Metadata: map[string]any{"kind": "message", "source": "operator"}, CreatedAt: now.Add(2 * time.Second)},
+	}
+
+	transcript, err := Run(context.Background(), events, Options{
+		AgentID: "sandbox-agent",
+		Dir:     t.TempDir(),
+		LLMFactory: func() (*llms.LLM, error) {
+			return llms.New(provider, agenttools.DryRun(deploy)...), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if executed.Load() {
+		t.Fatalf("expected tool not to execute during replay")
+	}
+	if transcript.ContextEvents != 1 {
+		t.Fatalf("expected 1 context event, got %d", transcript.ContextEvents)
+	}
+	if len(transcript.Turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(transcript.Turns))
+	}
+	first := transcript.Turns[0]
+	if first.EventID != "evt-1" || first.Output != "done" {
+		t.Fatalf("unexpected first turn: %#v", first)
+	}
+	if len(first.Tools) != 1 || first.Tools[0].ToolName != "deploy" {
+		t.Fatalf("expected dry-run deploy call, got %#v", first.Tools)
+	}
+	if raw, _ := json.Marshal(first.Tools[0].Data); !strings.Contains(string(raw), "not executed") {
+		t.Fatalf("expected dry-run result, got %#v", first.Tools[0].Data)
+	}
+	if len(first.Repro) != 1 {
+		t.Fatalf("expected repro metadata for the first turn, got %#v", first.Repro)
+	}
+	repro := first.Repro[0]
+	if repro.ModelVersion != "replay-1-0501" || repro.Seed == nil || *repro.Seed != 7 || repro.Requests != 2 {
+		t.Fatalf("unexpected repro metadata: %#v", repro)
+	}
+}
+
+func TestReadEventsAcceptsArrayAndLines(t *testing.T) {
+	array := `[{"id":"a","stream":"task_input","body":"one"},{"id":"b","stream":"task_input","body":"two"}]`
+	events, err := ReadEvents(strings.NewReader(array))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("array: events=%d err=%v", len(events), err)
+	}
+	lines := "{\"id\":\"a\",\"stream\":\"task_input\",\"body\":\"one\"}\n\n{\"id\":\"b\",\"stream\":\"task_input\",\"body\":\"two\"}\n"
+	events, err = ReadEvents(strings.NewReader(lines))
+	if err != nil || len(events) != 2 || events[1].ID != "b" {
+		t.Fatalf("lines: events=%#v err=%v", events, err)
+	}
+}
@@ -0,0 +1,127 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/callbacks"
+)
+
+// SMTPPasswordEnv holds the password for EmailConfig.Username, so it does not
+// have to live in config.json.
+const SMTPPasswordEnv = "GO_AGENTS_SMTP_PASSWORD"
+
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Secret signs deliveries the same way task callbacks are signed.
+	Secret string `json:"secret,omitempty"`
+}
+
+type EmailConfig struct {
+	SMTPAddr string   `json:"smtp_addr"`
+	Username string   `json:"username,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// WebhookPayload is the JSON body POSTed to the report webhook.
+type WebhookPayload struct {
+	Event   string    `json:"event"`
+	Period  string    `json:"period"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Reports []Report  `json:"reports"`
+}
+
+type Webhook struct {
+	cfg    WebhookConfig
+	client *http.Client
+	nowFn  func() time.Time
+}
+
+func NewWebhook(cfg WebhookConfig) *Webhook {
+	return &Webhook{
+		cfg:    cfg,
+		client: &http.Client{Timeout: callbacks.DefaultTimeout},
+		nowFn:  time.Now,
+	}
+}
+
+func (w *Webhook) Deliver(ctx context.Context, period string, from, to time.Time, reports []Report) error {
+	body, err := json.Marshal(WebhookPayload{
+		Event:   "activity_report",
+		Period:  period,
+		From:    from.UTC(),
+		To:      to.UTC(),
+		Reports: reports,
+	})
+	if err != nil {
+		return fmt.Errorf("encode report webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build report webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(w.nowFn().Unix(), 10)
+		req.Header.Set("X-Go-Agents-Timestamp", timestamp)
+		req.Header.Set("X-Go-Agents-Signature", callbacks.Sign(w.cfg.Secret, timestamp, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post report webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post report webhook: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type Email struct {
+	cfg      EmailConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmail(cfg EmailConfig) *Email {
+	return &Email{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+func (e *Email) Deliver(ctx context.Context, period string, from, to time.Time, reports []Report) error {
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		host, _, _ := strings.Cut(e.cfg.SMTPAddr, ":")
+		auth = smtp.PlainAuth("", e.cfg.Username, os.Getenv(SMTPPasswordEnv), host)
+	}
+	if err := e.sendMail(e.cfg.SMTPAddr, auth, e.cfg.From, e.cfg.To, e.message(period, from, to, reports)); err != nil {
+		return fmt.Errorf("send report email: %w", err)
+	}
+	return nil
+}
+
+func (e *Email) message(period string, from, to time.Time, reports []Report) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s agent activity report, %s\r\n", titleCase(period), from.UTC().Format("2006-01-02"))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Activity from %s to %s (UTC).\r\n", from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	for _, report := range reports {
+		fmt.Fprintf(&b, "\r\n== %s ==\r\n", report.AgentID)
+		b.WriteString(strings.ReplaceAll(report.Summary, "\n", "\r\n"))
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,354 @@
+// Package reports builds periodic activity reports for agents. For each
+// period (a UTC day or week) the generator collects per-agent stats, asks a
+// summarizer to turn them into a short digest, pushes one event per agent to
+// the reports stream, and optionally sends the whole fleet's digest to a
+// webhook and by email.
+package reports
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// Config is the activity_reports section of config.json.
+type Config struct {
+	// Period is "daily" or "weekly". Empty disables reports.
+	Period  string         `json:"period"`
+	Webhook *WebhookConfig `json:"webhook,omitempty"`
+	Email   *EmailConfig   `json:"email,omitempty"`
+}
+
+func (c Config) Validate() error {
+	switch c.Period {
+	case PeriodDaily, PeriodWeekly:
+	default:
+		return fmt.Errorf("activity report period must be %s or %s", PeriodDaily, PeriodWeekly)
+	}
+	if c.Webhook != nil && strings.TrimSpace(c.Webhook.URL) == "" {
+		return fmt.Errorf("activity report webhook url is required")
+	}
+	if c.Email != nil {
+		if strings.TrimSpace(c.Email.SMTPAddr) == "" || strings.TrimSpace(c.Email.From) == "" || len(c.Email.To) == 0 {
+			return fmt.Errorf("activity report email needs smtp_addr, from and to")
+		}
+	}
+	return nil
+}
+
+// Stats are the numbers a report is built from.
+type Stats struct {
+	AgentID string    `json:"agent_id"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Messages counts user messages the agent handled.
+	Messages int            `json:"messages"`
+	Tasks    tasks.Activity `json:"tasks"`
+}
+
+// Idle reports whether nothing happened in the period.
+func (s Stats) Idle() bool {
+	a := s.Tasks
+	return s.Messages == 0 && a.Turns == 0 && a.Completed == 0 && a.Failed == 0 && a.Cancelled == 0
+}
+
+// Text renders the stats as plain text. It is the summarizer input and the
+// report body when no summarizer is configured.
+func (s Stats) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agent %s, %s to %s (UTC)\n", s.AgentID, s.From.UTC().Format(time.RFC3339), s.To.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Messages handled: %d\n", s.Messages)
+	fmt.Fprintf(&b, "LLM turns: %d\n", s.Tasks.Turns)
+	fmt.Fprintf(&b, "Tasks completed: %d, failed: %d, cancelled: %d\n", s.Tasks.Completed, s.Tasks.Failed, s.Tasks.Cancelled)
+	u := s.Tasks.Usage
+	fmt.Fprintf(&b, "Tokens: %d input (%d cached, %d cache writes), %d output\n", u.InputTokens, u.CachedInputTokens, u.CacheCreationInputTokens, u.OutputTokens)
+	if len(s.Tasks.Failures) > 0 {
+		b.WriteString("Recent failures:\n")
+		for _, f := range s.Tasks.Failures {
+			msg := strings.TrimSpace(f.Error)
+			if msg == "" {
+				msg = "no error message"
+			}
+			fmt.Fprintf(&b, "- %s (%s) at %s: %s\n", f.ID, f.Type, f.At.UTC().Format(time.RFC3339), msg)
+		}
+	}
+	return b.String()
+}
+
+type Report struct {
+	AgentID string `json:"agent_id"`
+	Period  string `json:"period"`
+	Summary string `json:"summary"`
+	Stats   Stats  `json:"stats"`
+}
+
+// Summarizer turns stats into a human-readable digest.
+type Summarizer interface {
+	Summarize(ctx context.Context, stats Stats) (string, error)
+}
+
+// Deliverer sends a period's reports somewhere outside the bus.
+type Deliverer interface {
+	Deliver(ctx context.Context, period string, from, to time.Time, reports []Report) error
+}
+
+type Generator struct {
+	tasks  *tasks.Manager
+	bus    *eventbus.Bus
+	period string
+
+	summarizer Summarizer
+	deliverers []Deliverer
+	nowFn      func() time.Time
+	logf       func(format string, args ...any)
+}
+
+type Option func(*Generator)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(g *Generator) {
+		if nowFn != nil {
+			g.nowFn = nowFn
+		}
+	}
+}
+
+func WithSummarizer(s Summarizer) Option {
+	return func(g *Generator) {
+		g.summarizer = s
+	}
+}
+
+func WithDeliverer(d Deliverer) Option {
+	return func(g *Generator) {
+		if d != nil {
+			g.deliverers = append(g.deliverers, d)
+		}
+	}
+}
+
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(g *Generator) {
+		if logf != nil {
+			g.logf = logf
+		}
+	}
+}
+
+func NewGenerator(manager *tasks.Manager, bus *eventbus.Bus, period string, opts ...Option) *Generator {
+	g := &Generator{
+		tasks:  manager,
+		bus:    bus,
+		period: period,
+		nowFn:  func() time.Time { return time.Now().UTC() },
+		logf:   log.Printf,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(g)
+		}
+	}
+	return g
+}
+
+// NewGeneratorFromConfig builds a generator that delivers to the webhook and
+// email configured in cfg.
+func NewGeneratorFromConfig(manager *tasks.Manager, bus *eventbus.Bus, cfg Config, opts ...Option) (*Generator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook != nil {
+		opts = append(opts, WithDeliverer(NewWebhook(*cfg.Webhook)))
+	}
+	if cfg.Email != nil {
+		opts = append(opts, WithDeliverer(NewEmail(*cfg.Email)))
+	}
+	return NewGenerator(manager, bus, cfg.Period, opts...), nil
+}
+
+func (g *Generator) now() time.Time {
+	return g.nowFn().UTC()
+}
+
+// Window returns the last complete period before t.
+func (g *Generator) Window(t time.Time) (from, to time.Time) {
+	to = periodStart(g.period, t)
+	if g.period == PeriodWeekly {
+		return to.AddDate(0, 0, -7), to
+	}
+	return to.AddDate(0, 0, -1), to
+}
+
+// periodStart returns the start of the period containing t. Days start at
+// UTC midnight and weeks on Monday.
+func periodStart(period string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if period == PeriodWeekly {
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+// Collect gathers stats for one agent.
+func (g *Generator) Collect(ctx context.Context, agentID string, from, to time.Time) (Stats, error) {
+	stats := Stats{AgentID: agentID, From: from.UTC(), To: to.UTC()}
+	messages, err := g.bus.Count(ctx, schema.StreamHistory, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Fields:    []eventbus.FieldFilter{{Column: "payload", Path: []string{"type"}, Values: []string{"user_message"}}},
+	}, from, to)
+	if err != nil {
+		return Stats{}, fmt.Errorf("count messages: %w", err)
+	}
+	stats.Messages = messages
+	activity, err := g.tasks.Activity(ctx, agentID, from, to)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Tasks = activity
+	return stats, nil
+}
+
+// Generate builds, publishes and delivers reports for every agent that was
+// active in [from, to). Agents that already have a report for the window are
+// skipped, so a retried window does not publish twice.
+func (g *Generator) Generate(ctx context.Context, from, to time.Time) ([]Report, error) {
+	agents, err := g.tasks.List(ctx, tasks.ListFilter{Type: "agent", Limit: 10000})
+	if err != nil {
+		return nil, err
+	}
+	var out []Report
+	for _, agent := range agents {
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		done, err := g.reported(ctx, agent.ID, to)
+		if err != nil {
+			return out, err
+		}
+		if done {
+			continue
+		}
+		stats, err := g.Collect(ctx, agent.ID, from, to)
+		if err != nil {
+			return out, fmt.Errorf("collect %s: %w", agent.ID, err)
+		}
+		if stats.Idle() {
+			continue
+		}
+		report := Report{AgentID: agent.ID, Period: g.period, Stats: stats, Summary: g.summarize(ctx, stats)}
+		if err := g.publish(ctx, report); err != nil {
+			return out, err
+		}
+		out = append(out, report)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	for _, d := range g.deliverers {
+		if err := d.Deliver(ctx, g.period, from, to, out); err != nil {
+			g.logf("activity report delivery: %v", err)
+		}
+	}
+	return out, nil
+}
+
+// summarize falls back to the raw stats when the summarizer fails, so a
+// provider outage still produces a report.
+func (g *Generator) summarize(ctx context.Context, stats Stats) string {
+	if g.summarizer == nil {
+		return stats.Text()
+	}
+	summary, err := g.summarizer.Summarize(ctx, stats)
+	if err != nil || strings.TrimSpace(summary) == "" {
+		if err != nil {
+			g.logf("activity report summary for %s: %v", stats.AgentID, err)
+		}
+		return stats.Text()
+	}
+	return strings.TrimSpace(summary)
+}
+
+func (g *Generator) reported(ctx context.Context, agentID string, to time.Time) (bool, error) {
+	n, err := g.bus.Count(ctx, schema.StreamReports, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Fields: []eventbus.FieldFilter{
+			{Column: "metadata", Path: []string{"period"}, Values: []string{g.period}},
+			{Column: "metadata", Path: []string{"period_end"}, Values: []string{to.UTC().Format(time.RFC3339)}},
+		},
+	}, time.Time{}, time.Time{})
+	if err != nil {
+		return false, fmt.Errorf("check existing report: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (g *Generator) publish(ctx context.Context, report Report) error {
+	stats := report.Stats
+	_, err := g.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamReports,
+		ScopeType: "task",
+		ScopeID:   report.AgentID,
+		Subject:   fmt.Sprintf("%s activity report for %s", titleCase(report.Period), report.AgentID),
+		Body:      report.Summary,
+		Metadata: map[string]any{
+			"kind":         "activity_report",
+			"period":       report.Period,
+			"period_start": stats.From.Format(time.RFC3339),
+			"period_end":   stats.To.Format(time.RFC3339),
+		},
+		Payload: map[string]any{
+			"messages": stats.Messages,
+			"tasks":    stats.Tasks,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("publish report for %s: %w", report.AgentID, err)
+	}
+	return nil
+}
+
+// Run generates the report for each period as it ends until ctx is done. On
+// start it catches up on the most recent complete period.
+func (g *Generator) Run(ctx context.Context) {
+	for {
+		from, to := g.Window(g.now())
+		if _, err := g.Generate(ctx, from, to); err != nil && ctx.Err() == nil {
+			g.logf("activity reports: %v", err)
+		}
+		next := periodStart(g.period, g.now())
+		if g.period == PeriodWeekly {
+			next = next.AddDate(0, 0, 7)
+		} else {
+			next = next.AddDate(0, 0, 1)
+		}
+		timer := time.NewTimer(next.Sub(g.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func titleCase(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
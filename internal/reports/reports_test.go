@@ -0,0 +1,131 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/callbacks"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+type fakeSummarizer struct {
+	got []Stats
+}
+
+func (f *fakeSummarizer) Summarize(_ context.Context, stats Stats) (string, error) {
+	f.got = append(f.got, stats)
+	return "summary for " + stats.AgentID, nil
+}
+
+func TestGeneratePublishesAndDeliversReports(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 4, 14, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock))
+	mgr := tasks.NewManager(db, bus, tasks.WithClock(clock))
+	ctx := context.Background()
+
+	for _, id := range []string{"busy", "idle"} {
+		if _, err := mgr.Spawn(ctx, tasks.Spec{ID: id, Type: "agent"}); err != nil {
+			t.Fatalf("spawn %s: %v", id, err)
+		}
+	}
+	if _, err := bus.Push(ctx, eventbus.EventInput{Stream: schema.StreamHistory, ScopeType: "task", ScopeID: "busy", Body: "hi", Payload: map[string]any{"type": "user_message"}}); err != nil {
+		t.Fatalf("push history: %v", err)
+	}
+	llm, err := mgr.Spawn(ctx, tasks.Spec{Type: "llm", Owner: "busy"})
+	if err != nil {
+		t.Fatalf("spawn llm: %v", err)
+	}
+	if err := mgr.RecordUpdate(ctx, llm.ID, "llm_usage", map[string]any{"input_tokens": 1200, "output_tokens": 300}); err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+	done, _ := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "busy"})
+	_ = mgr.Complete(ctx, done.ID, map[string]any{"ok": true})
+	failed, _ := mgr.Spawn(ctx, tasks.Spec{Type: "exec", Owner: "busy"})
+	_ = mgr.Fail(ctx, failed.ID, "exit status 1")
+
+	var delivered WebhookPayload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Go-Agents-Signature") != callbacks.Sign("s3cret", r.Header.Get("X-Go-Agents-Timestamp"), body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		_ = json.Unmarshal(body, &delivered)
+	}))
+	defer receiver.Close()
+
+	summarizer := &fakeSummarizer{}
+	now = time.Date(2026, 4, 15, 0, 5, 0, 0, time.UTC)
+	gen, err := NewGeneratorFromConfig(mgr, bus, Config{
+		Period:  PeriodDaily,
+		Webhook: &WebhookConfig{URL: receiver.URL, Secret: "s3cret"},
+	}, WithClock(clock), WithSummarizer(summarizer))
+	if err != nil {
+		t.Fatalf("new generator: %v", err)
+	}
+	from, to := gen.Window(now)
+	if !from.Equal(time.Date(2026, 4, 14, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("window = %s..%s", from, to)
+	}
+
+	reports, err := gen.Generate(ctx, from, to)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(reports) != 1 || reports[0].AgentID != "busy" {
+		t.Fatalf("expected one report for busy, got %+v", reports)
+	}
+	stats := summarizer.got[0]
+	if stats.Messages != 1 || stats.Tasks.Turns != 1 || stats.Tasks.Completed != 1 || stats.Tasks.Failed != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.Tasks.Usage.InputTokens != 1200 || stats.Tasks.Usage.OutputTokens != 300 {
+		t.Fatalf("unexpected usage: %+v", stats.Tasks.Usage)
+	}
+	if len(stats.Tasks.Failures) != 1 || stats.Tasks.Failures[0].Error != "exit status 1" {
+		t.Fatalf("unexpected failures: %+v", stats.Tasks.Failures)
+	}
+	if len(delivered.Reports) != 1 || delivered.Reports[0].Summary != "summary for busy" {
+		t.Fatalf("webhook got %+v", delivered)
+	}
+
+	events, err := bus.List(ctx, schema.StreamReports, eventbus.ListOptions{ScopeType: "task", ScopeID: "busy"})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected one report event, got %d (%v)", len(events), err)
+	}
+
+	again, err := gen.Generate(ctx, from, to)
+	if err != nil || len(again) != 0 {
+		t.Fatalf("expected rerun to skip reported window, got %d (%v)", len(again), err)
+	}
+}
+
+func TestWeeklyWindowStartsOnMonday(t *testing.T) {
+	gen := NewGenerator(nil, nil, PeriodWeekly)
+	// Sunday 2026-04-19; the last complete week is Mon 6th to Mon 13th.
+	from, to := gen.Window(time.Date(2026, 4, 19, 18, 0, 0, 0, time.UTC))
+	if !from.Equal(time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("window = %s..%s", from, to)
+	}
+}
+
+func TestSummaryFallsBackToStatsText(t *testing.T) {
+	gen := NewGenerator(nil, nil, PeriodDaily, WithSummarizer(LLMSummarizer{}), WithLogger(func(string, ...any) {}))
+	stats := Stats{AgentID: "a", Messages: 3}
+	if got := gen.summarize(context.Background(), stats); !strings.Contains(got, "Messages handled: 3") {
+		t.Fatalf("expected stats text fallback, got %q", got)
+	}
+}
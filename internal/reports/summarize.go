@@ -0,0 +1,41 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const summaryPrompt = `You write short activity digests for the operators of an AI agent fleet.
+You get one agent's stats for a period. Write a few plain sentences covering how busy the agent was, what it completed, what failed and why, and its token spend.
+Call out anything unusual, such as a high failure rate or repeated errors. Do not invent numbers or details that are not in the stats. No headings, no markdown.`
+
+// LLMSummarizer asks a model to write the digest. NewSession must return a
+// session without tools.
+type LLMSummarizer struct {
+	NewSession func() (*llms.LLM, error)
+}
+
+func (s LLMSummarizer) Summarize(ctx context.Context, stats Stats) (string, error) {
+	if s.NewSession == nil {
+		return "", fmt.Errorf("summarizer has no llm")
+	}
+	llm, err := s.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("create summary session: %w", err)
+	}
+	llm.SystemPrompt = func() content.Content { return content.FromText(summaryPrompt) }
+	var out strings.Builder
+	for update := range llm.ChatWithContext(ctx, stats.Text()) {
+		if u, ok := update.(llms.TextUpdate); ok {
+			out.WriteString(u.Text)
+		}
+	}
+	if err := llm.Err(); err != nil {
+		return "", fmt.Errorf("summarize stats: %w", err)
+	}
+	return out.String(), nil
+}
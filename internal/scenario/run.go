@@ -0,0 +1,372 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agenttools"
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/pkg/agenttest"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const defaultAgentID = "scenario"
+
+type Options struct {
+	// Dir holds the sandbox database. A temporary directory is used and
+	// removed afterwards when empty.
+	Dir string
+}
+
+// Report is the outcome of one scenario.
+type Report struct {
+	Name          string        `json:"name"`
+	Path          string        `json:"path,omitempty"`
+	Passed        bool          `json:"passed"`
+	Failures      []string      `json:"failures,omitempty"`
+	Turns         []Turn        `json:"turns"`
+	ProviderCalls int           `json:"provider_calls"`
+	Duration      time.Duration `json:"duration"`
+}
+
+type Turn struct {
+	Input  string       `json:"input"`
+	Output string       `json:"output,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Tools  []ToolResult `json:"tools,omitempty"`
+}
+
+type ToolResult struct {
+	Name   string         `json:"name"`
+	Args   map[string]any `json:"args,omitempty"`
+	Status string         `json:"status"`
+}
+
+// Run executes sc against a fresh runtime and checks its expectations. The
+// returned error is for setup problems; expectation failures are reported in
+// the Report.
+func Run(ctx context.Context, sc Scenario, opts Options) (Report, error) {
+	started := time.Now()
+	report := Report{Name: sc.Name, Path: sc.Path}
+
+	dir := strings.TrimSpace(opts.Dir)
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "go-agents-scenario-")
+		if err != nil {
+			return report, fmt.Errorf("create sandbox dir: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+	db, err := state.Open(filepath.Join(dir, "scenario.db"))
+	if err != nil {
+		return report, fmt.Errorf("open sandbox db: %w", err)
+	}
+	defer db.Close()
+
+	clock := agenttest.NewSource(agenttest.DefaultStart, time.Second)
+	bus := eventbus.NewBus(db, eventbus.WithClock(clock.Now), eventbus.WithIDGenerator(clock.NewID))
+	mgr := tasks.NewManager(db, bus, tasks.WithClock(clock.Now), tasks.WithIDGenerator(clock.NewPrefixedID))
+	provider := agenttest.NewScriptedProvider(scriptedStreams(sc.Provider)...)
+	llm := llms.New(provider,
+		agenttools.ExecTool(mgr),
+		agenttools.AwaitTaskTool(mgr),
+		agenttools.SendTaskTool(mgr, bus, nil),
+		agenttools.KillTaskTool(mgr),
+		agenttools.RetryTaskTool(mgr),
+		agenttools.NoopTool(),
+	)
+	rt := engine.NewRuntime(bus, mgr, &ai.Client{LLM: llm}, engine.WithClock(clock.Now))
+
+	agentID := strings.TrimSpace(sc.Agent.ID)
+	if agentID == "" {
+		agentID = defaultAgentID
+	}
+	if _, err := mgr.Spawn(ctx, tasks.Spec{ID: agentID, Type: "agent", Metadata: map[string]any{"source": "scenario"}}); err != nil {
+		return report, fmt.Errorf("create scenario agent: %w", err)
+	}
+	_ = mgr.MarkRunning(ctx, agentID)
+	if strings.TrimSpace(sc.Agent.System) != "" {
+		rt.SetAgentSystem(agentID, sc.Agent.System)
+	}
+
+	historyCursor := ""
+	for _, step := range sc.Given {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if step.Event != nil {
+			if _, err := bus.Push(ctx, scenarioEvent(*step.Event, agentID)); err != nil {
+				return report, fmt.Errorf("push %s event: %w", step.Event.Stream, err)
+			}
+			continue
+		}
+		meta := map[string]any{}
+		for k, v := range step.Metadata {
+			meta[k] = v
+		}
+		meta["kind"] = "message"
+		turn := Turn{Input: step.Message}
+		session, err := rt.HandleMessage(ctx, agentID, step.Source, step.Message, meta)
+		turn.Output = session.LastOutput
+		if err != nil {
+			turn.Error = err.Error()
+		} else if session.LastError != "" {
+			turn.Error = session.LastError
+		}
+		var entries []engine.AgentHistoryEntry
+		entries, historyCursor = historySince(ctx, bus, agentID, historyCursor)
+		turn.Tools = toolResults(entries)
+		report.Turns = append(report.Turns, turn)
+	}
+	report.ProviderCalls = provider.Calls()
+
+	taskList, err := mgr.List(ctx, tasks.ListFilter{Limit: 1000})
+	if err != nil {
+		return report, fmt.Errorf("list tasks: %w", err)
+	}
+	report.Failures = check(sc, report, taskList)
+	report.Passed = len(report.Failures) == 0
+	report.Duration = time.Since(started)
+	return report, nil
+}
+
+func scriptedStreams(responses []Response) []llms.ProviderStream {
+	streams := make([]llms.ProviderStream, 0, len(responses))
+	for i, resp := range responses {
+		if resp.Error != "" {
+			streams = append(streams, agenttest.NewStream(agenttest.StreamSpec{Err: errors.New(resp.Error)}))
+			continue
+		}
+		spec := agenttest.StreamSpec{Text: resp.Text}
+		for j, call := range resp.ToolCalls {
+			args, _ := json.Marshal(call.Args)
+			if call.Args == nil {
+				args = []byte("{}")
+			}
+			spec.Message.ToolCalls = append(spec.Message.ToolCalls, llms.ToolCall{
+				ID:        fmt.Sprintf("call-%d-%d", i+1, j+1),
+				Name:      call.Name,
+				Arguments: args,
+			})
+		}
+		streams = append(streams, agenttest.NewStream(spec))
+	}
+	return streams
+}
+
+func scenarioEvent(evt Event, agentID string) eventbus.EventInput {
+	meta := map[string]any{}
+	for k, v := range evt.Metadata {
+		meta[k] = v
+	}
+	if _, ok := meta["source"]; !ok {
+		meta["source"] = "scenario"
+	}
+	return eventbus.EventInput{
+		Stream:    evt.Stream,
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Subject:   evt.Subject,
+		Body:      evt.Body,
+		Metadata:  meta,
+		Payload:   evt.Payload,
+	}
+}
+
+func historySince(ctx context.Context, bus *eventbus.Bus, agentID, after string) ([]engine.AgentHistoryEntry, string) {
+	items, err := bus.List(ctx, schema.StreamHistory, eventbus.ListOptions{
+		ScopeType: "task",
+		ScopeID:   agentID,
+		Order:     "fifo",
+		Limit:     2000,
+		After:     after,
+	})
+	if err != nil || len(items) == 0 {
+		return nil, after
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	events, err := bus.Read(ctx, schema.StreamHistory, ids, "")
+	if err != nil {
+		return nil, after
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+	out := make([]engine.AgentHistoryEntry, 0, len(events))
+	for _, evt := range events {
+		if entry, ok := engine.HistoryEntryFromEvent(evt); ok {
+			out = append(out, entry)
+		}
+	}
+	return out, items[len(items)-1].ID
+}
+
+func toolResults(entries []engine.AgentHistoryEntry) []ToolResult {
+	var out []ToolResult
+	for _, entry := range entries {
+		if entry.Type != "tool_result" {
+			continue
+		}
+		result := ToolResult{Name: entry.ToolName, Status: entry.ToolStatus}
+		if args, ok := entry.Data["args"].(map[string]any); ok {
+			result.Args = args
+		}
+		out = append(out, result)
+	}
+	return out
+}
+
+// check compares a run against the scenario's expectations and returns one
+// message per unmet expectation.
+func check(sc Scenario, report Report, taskList []tasks.Task) []string {
+	var failures []string
+	if !sc.Expect.AllowErrors {
+		for i, turn := range report.Turns {
+			if turn.Error != "" {
+				failures = append(failures, fmt.Sprintf("turn %d failed: %s", i+1, turn.Error))
+			}
+		}
+	}
+	if report.ProviderCalls < len(sc.Provider) {
+		failures = append(failures, fmt.Sprintf("only %d of %d scripted provider responses were used", report.ProviderCalls, len(sc.Provider)))
+	}
+
+	var calls []ToolResult
+	for _, turn := range report.Turns {
+		calls = append(calls, turn.Tools...)
+	}
+	next := 0
+	for _, want := range sc.Expect.ToolCalls {
+		found := false
+		for next < len(calls) {
+			got := calls[next]
+			next++
+			if got.Name == want.Name && (want.Status == "" || got.Status == want.Status) && contains(got.Args, want.Args) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected tool call %s%s not made (in order); calls were %s", want.Name, describeArgs(want.Args), describeCalls(calls)))
+			break
+		}
+	}
+
+	if m := sc.Expect.Output; m != nil {
+		output := ""
+		if len(report.Turns) > 0 {
+			output = report.Turns[len(report.Turns)-1].Output
+		}
+		if m.Equals != nil && strings.TrimSpace(output) != strings.TrimSpace(*m.Equals) {
+			failures = append(failures, fmt.Sprintf("expected output %q, got %q", *m.Equals, output))
+		}
+		for _, s := range m.Contains {
+			if !strings.Contains(output, s) {
+				failures = append(failures, fmt.Sprintf("expected output to contain %q, got %q", s, output))
+			}
+		}
+		for _, s := range m.NotContains {
+			if strings.Contains(output, s) {
+				failures = append(failures, fmt.Sprintf("expected output not to contain %q, got %q", s, output))
+			}
+		}
+	}
+
+	for _, want := range sc.Expect.Tasks {
+		n := 0
+		for _, task := range taskList {
+			if task.Type == want.Type && (want.Status == "" || string(task.Status) == want.Status) {
+				n++
+			}
+		}
+		label := want.Type
+		if want.Status != "" {
+			label += " " + want.Status
+		}
+		switch {
+		case want.Count != nil && n != *want.Count:
+			failures = append(failures, fmt.Sprintf("expected %d %s task(s), found %d", *want.Count, label, n))
+		case want.Count == nil && n == 0:
+			failures = append(failures, fmt.Sprintf("expected a %s task, found none", label))
+		}
+	}
+	return failures
+}
+
+// contains reports whether got includes everything in want. Maps match by
+// subset, other values by equality after a JSON round trip so YAML and JSON
+// numbers compare equal.
+func contains(got, want map[string]any) bool {
+	if len(want) == 0 {
+		return true
+	}
+	return containsValue(normalize(got), normalize(want))
+}
+
+func containsValue(got, want any) bool {
+	wantMap, ok := want.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(got, want)
+	}
+	gotMap, ok := got.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, v := range wantMap {
+		if !containsValue(gotMap[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(v any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func describeArgs(args map[string]any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(args)
+	return " " + string(raw)
+}
+
+func describeCalls(calls []ToolResult) string {
+	if len(calls) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(calls))
+	for _, c := range calls {
+		parts = append(parts, c.Name+describeArgs(c.Args)+" ("+c.Status+")")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,199 @@
+// Package scenario runs declarative agent behavior tests. A scenario is a
+// YAML file that lists what happens to an agent (messages and bus events),
+// what the model answers (scripted provider responses), and what should come
+// out of it (tool calls, the final output and task states). Each scenario
+// runs against a fresh in-process runtime with its own database.
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Scenario struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description,omitempty"`
+	Agent       Agent      `yaml:"agent,omitempty"`
+	Given       []Step     `yaml:"given"`
+	Provider    []Response `yaml:"provider"`
+	Expect      Expect     `yaml:"expect"`
+
+	// Path is the file the scenario was loaded from, if any.
+	Path string `yaml:"-"`
+}
+
+type Agent struct {
+	ID     string `yaml:"id,omitempty"`
+	System string `yaml:"system,omitempty"`
+}
+
+// Step is one thing that happens to the agent: a message that starts a turn,
+// or an event that is pushed to the bus and shows up as context on the next
+// turn. Exactly one of Message and Event is set.
+type Step struct {
+	Message  string         `yaml:"message,omitempty"`
+	Source   string         `yaml:"source,omitempty"`
+	Metadata map[string]any `yaml:"metadata,omitempty"`
+	Event    *Event         `yaml:"event,omitempty"`
+}
+
+type Event struct {
+	Stream   string         `yaml:"stream"`
+	Subject  string         `yaml:"subject,omitempty"`
+	Body     string         `yaml:"body,omitempty"`
+	Metadata map[string]any `yaml:"metadata,omitempty"`
+	Payload  map[string]any `yaml:"payload,omitempty"`
+}
+
+// Response is one scripted model response, consumed in order across turns.
+type Response struct {
+	Text      string     `yaml:"text,omitempty"`
+	ToolCalls []ToolCall `yaml:"tool_calls,omitempty"`
+	// Error makes the provider call fail with this message.
+	Error string `yaml:"error,omitempty"`
+}
+
+type ToolCall struct {
+	Name string         `yaml:"name"`
+	Args map[string]any `yaml:"args,omitempty"`
+}
+
+type Expect struct {
+	// ToolCalls must appear in this order among the calls made; other calls
+	// may come in between.
+	ToolCalls []ExpectedToolCall `yaml:"tool_calls,omitempty"`
+	// Output is matched against the last turn's output.
+	Output *TextMatch     `yaml:"output,omitempty"`
+	Tasks  []ExpectedTask `yaml:"tasks,omitempty"`
+	// AllowErrors lets turns fail without failing the scenario.
+	AllowErrors bool `yaml:"allow_errors,omitempty"`
+}
+
+type ExpectedToolCall struct {
+	Name string `yaml:"name"`
+	// Args must be contained in the call's arguments.
+	Args map[string]any `yaml:"args,omitempty"`
+	// Status is "done" or "failed".
+	Status string `yaml:"status,omitempty"`
+}
+
+type TextMatch struct {
+	Equals      *string  `yaml:"equals,omitempty"`
+	Contains    []string `yaml:"contains,omitempty"`
+	NotContains []string `yaml:"not_contains,omitempty"`
+}
+
+// ExpectedTask matches tasks by type and, optionally, status. Count defaults
+// to at least one match.
+type ExpectedTask struct {
+	Type   string `yaml:"type"`
+	Status string `yaml:"status,omitempty"`
+	Count  *int   `yaml:"count,omitempty"`
+}
+
+// Parse decodes a scenario. Unknown fields are rejected so typos in
+// expectations do not pass silently.
+func Parse(data []byte) (Scenario, error) {
+	var sc Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sc); err != nil {
+		return Scenario{}, fmt.Errorf("decode scenario: %w", err)
+	}
+	if err := sc.Validate(); err != nil {
+		return Scenario{}, err
+	}
+	return sc, nil
+}
+
+func Load(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("read scenario: %w", err)
+	}
+	sc, err := Parse(data)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+	sc.Path = path
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return sc, nil
+}
+
+// Files expands paths into scenario files. Directories contribute their
+// .yaml and .yml files, sorted by name.
+func Files(paths ...string) ([]string, error) {
+	var out []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			out = append(out, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("read dir %s: %w", path, err)
+		}
+		var found []string
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				found = append(found, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(found)
+		out = append(out, found...)
+	}
+	return out, nil
+}
+
+func (sc Scenario) Validate() error {
+	turns := 0
+	for i, step := range sc.Given {
+		hasMessage := strings.TrimSpace(step.Message) != ""
+		if hasMessage == (step.Event != nil) {
+			return fmt.Errorf("given[%d]: exactly one of message and event is required", i)
+		}
+		if step.Event != nil && strings.TrimSpace(step.Event.Stream) == "" {
+			return fmt.Errorf("given[%d]: event stream is required", i)
+		}
+		if hasMessage {
+			turns++
+		}
+	}
+	if turns == 0 {
+		return fmt.Errorf("scenario needs at least one message")
+	}
+	for i, resp := range sc.Provider {
+		for j, call := range resp.ToolCalls {
+			if strings.TrimSpace(call.Name) == "" {
+				return fmt.Errorf("provider[%d].tool_calls[%d]: name is required", i, j)
+			}
+		}
+	}
+	for i, call := range sc.Expect.ToolCalls {
+		if strings.TrimSpace(call.Name) == "" {
+			return fmt.Errorf("expect.tool_calls[%d]: name is required", i)
+		}
+		if call.Status != "" && call.Status != "done" && call.Status != "failed" {
+			return fmt.Errorf("expect.tool_calls[%d]: status must be done or failed", i)
+		}
+	}
+	for i, task := range sc.Expect.Tasks {
+		if strings.TrimSpace(task.Type) == "" {
+			return fmt.Errorf("expect.tasks[%d]: type is required", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package scenario
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunPassesDeployFollowup(t *testing.T) {
+	sc, err := Load("testdata/deploy_followup.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	report, err := Run(context.Background(), sc, Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !report.Passed {
+		t.Fatalf("expected scenario to pass, failures: %v\nturns: %+v", report.Failures, report.Turns)
+	}
+	if report.ProviderCalls != 2 || len(report.Turns) != 1 || len(report.Turns[0].Tools) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestRunReportsUnmetExpectations(t *testing.T) {
+	sc, err := Parse([]byte(`
+name: wrong expectations
+given:
+  - message: hello
+provider:
+  - text: hi there
+  - text: never used
+expect:
+  tool_calls:
+    - name: exec
+  output:
+    equals: goodbye
+  tasks:
+    - type: exec
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	report, err := Run(context.Background(), sc, Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.Passed {
+		t.Fatalf("expected failures")
+	}
+	joined := strings.Join(report.Failures, "\n")
+	for _, want := range []string{
+		"only 1 of 2 scripted provider responses were used",
+		"expected tool call exec not made",
+		`expected output "goodbye"`,
+		"expected a exec task, found none",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected failure %q, got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestParseRejectsUnknownFieldsAndEmptyScenarios(t *testing.T) {
+	if _, err := Parse([]byte("given:\n  - message: hi\nexpect:\n  outptu: {}\n")); err == nil {
+		t.Fatalf("expected unknown field to be rejected")
+	}
+	if _, err := Parse([]byte("given:\n  - event: {stream: signals}\n")); err == nil {
+		t.Fatalf("expected scenario without a message to be rejected")
+	}
+}
@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week. Fields take *, values, ranges (a-b), steps (*/n,
+// a-b/n) and comma lists; months and weekdays also take three-letter names
+// and Sunday is 0 or 7. As in Vixie cron, when both day fields are
+// restricted a day matching either one fires.
+type Expr struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// nextHorizon bounds the search for the next run, so expressions that never
+// fire (February 30th) end.
+const nextHorizon = 5 * 366 * 24 * time.Hour
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseCron parses spec, which is five fields or one of @yearly,
+// @monthly, @weekly, @daily and @hourly.
+func ParseCron(spec string) (Expr, error) {
+	spec = strings.TrimSpace(spec)
+	if alias, ok := cronAliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Expr{}, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week)", spec)
+	}
+	var e Expr
+	var err error
+	if e.minute, err = parseField(fields[0], 0, 59, nil, 0); err != nil {
+		return Expr{}, fmt.Errorf("minute: %w", err)
+	}
+	if e.hour, err = parseField(fields[1], 0, 23, nil, 0); err != nil {
+		return Expr{}, fmt.Errorf("hour: %w", err)
+	}
+	if e.dom, err = parseField(fields[2], 1, 31, nil, 0); err != nil {
+		return Expr{}, fmt.Errorf("day of month: %w", err)
+	}
+	if e.month, err = parseField(fields[3], 1, 12, monthNames, 1); err != nil {
+		return Expr{}, fmt.Errorf("month: %w", err)
+	}
+	if e.dow, err = parseField(fields[4], 0, 7, dayNames, 0); err != nil {
+		return Expr{}, fmt.Errorf("day of week: %w", err)
+	}
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domAny = strings.HasPrefix(fields[2], "*")
+	e.dowAny = strings.HasPrefix(fields[4], "*")
+	return e, nil
+}
+
+// parseField parses one comma-separated field into a bitset of the values
+// in [min, max]. names, when set, name the values from nameBase up.
+func parseField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(a, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(b, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q runs backwards", rangePart)
+			}
+		default:
+			v, err := parseValue(rangePart, min, max, names, nameBase)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, min, max int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first minute strictly after after, in after's location,
+// that e matches. It returns the zero time when nothing matches within
+// five years.
+func (e Expr) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, loc)
+	limit := after.Add(nextHorizon)
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case e.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !e.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case e.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case e.minute&(1<<uint(t.Minute())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+		// Daylight saving changes can normalize a wall time backwards;
+		// always make progress.
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+func (e Expr) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domAny || e.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
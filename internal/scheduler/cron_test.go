@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	cases := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"0 9 * * *", time.Date(2026, 3, 2, 8, 59, 30, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 2, 10, 7, 0, 0, time.UTC), time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC)},
+		{"30 8 * * mon-fri", time.Date(2026, 3, 6, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 2, 10, 7, 0, 0, time.UTC), time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 13th or any Friday.
+		{"0 0 13 * fri", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the spring-forward day in Stockholm.
+		{"30 2 * * *", time.Date(2026, 3, 29, 0, 0, 0, 0, stockholm), time.Date(2026, 3, 30, 2, 30, 0, 0, stockholm)},
+		{"0 9 * * *", time.Date(2026, 3, 29, 0, 0, 0, 0, stockholm), time.Date(2026, 3, 29, 9, 0, 0, 0, stockholm)},
+	}
+	for _, tc := range cases {
+		expr, err := ParseCron(tc.spec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.spec, err)
+		}
+		if got := expr.Next(tc.after); !got.Equal(tc.want) {
+			t.Fatalf("%q after %v: got %v, want %v", tc.spec, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestCronRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+	expr, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if next := expr.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Fatalf("expected February 30th never to fire, got %v", next)
+	}
+}
@@ -0,0 +1,377 @@
+// Package scheduler wakes agents and tasks on cron schedules. Schedules are
+// stored in the database, so they survive restarts; Poll fires the ones
+// that are due by pushing a wake to the task's input.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	DefaultPollInterval = 15 * time.Second
+	// MaxSchedulesPerTask bounds how many schedules one task may hold.
+	MaxSchedulesPerTask = 50
+	maxTextLength       = 2000
+	dueBatch            = 50
+)
+
+var ErrNotFound = errors.New("schedule not found")
+
+// Schedule wakes TaskID with Text at every time Cron matches in Timezone.
+type Schedule struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+	Cron   string `json:"cron"`
+	// Timezone is the IANA name the expression is read in; empty is UTC.
+	Timezone string `json:"timezone,omitempty"`
+	Text     string `json:"text"`
+	Enabled  bool   `json:"enabled"`
+	// NextRunAt is when the schedule fires next; it is zero when the
+	// schedule is disabled.
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	Runs      int        `json:"runs"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Patch changes the set fields of a schedule.
+type Patch struct {
+	Cron     *string `json:"cron,omitempty"`
+	Timezone *string `json:"timezone,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Enabled  *bool   `json:"enabled,omitempty"`
+}
+
+// DeliverFunc hands a wake to a task.
+type DeliverFunc func(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error)
+
+type Service struct {
+	db        *sql.DB
+	bus       *eventbus.Bus
+	deliverFn DeliverFunc
+	nowFn     func() time.Time
+	newIDFn   func() string
+}
+
+type Option func(*Service)
+
+// WithDeliverer replaces how wakes reach tasks; the runtime uses it to start
+// the agent's loop. By default they are pushed to the task's task_input.
+func WithDeliverer(fn DeliverFunc) Option {
+	return func(s *Service) {
+		if fn != nil {
+			s.deliverFn = fn
+		}
+	}
+}
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Service) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewService(db *sql.DB, bus *eventbus.Bus, opts ...Option) *Service {
+	s := &Service{
+		db:      db,
+		bus:     bus,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	s.deliverFn = s.pushWake
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Service) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Create validates and stores a schedule. Enabled is taken as given, so
+// callers set it.
+func (s *Service) Create(ctx context.Context, sched Schedule) (Schedule, error) {
+	sched.TaskID = strings.TrimSpace(sched.TaskID)
+	if sched.TaskID == "" {
+		return Schedule{}, fmt.Errorf("task_id is required")
+	}
+	now := s.now()
+	if err := s.prepare(&sched, now); err != nil {
+		return Schedule{}, err
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schedules WHERE task_id = ?`, sched.TaskID).Scan(&count); err != nil {
+		return Schedule{}, fmt.Errorf("count schedules: %w", err)
+	}
+	if count >= MaxSchedulesPerTask {
+		return Schedule{}, fmt.Errorf("%s already has %d schedules (max %d)", sched.TaskID, count, MaxSchedulesPerTask)
+	}
+	sched.ID = "sched-" + s.newIDFn()
+	sched.LastRunAt = nil
+	sched.LastError = ""
+	sched.Runs = 0
+	sched.CreatedAt, sched.UpdatedAt = now, now
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedules (id, task_id, cron, timezone, text, enabled, next_run_at, last_run_at, last_error, runs, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, '', '', 0, ?, ?)
+	`, sched.ID, sched.TaskID, sched.Cron, sched.Timezone, sched.Text, boolInt(sched.Enabled), formatTime(sched.NextRunAt),
+		now.Format(state.TimeLayout), now.Format(state.TimeLayout)); err != nil {
+		return Schedule{}, fmt.Errorf("insert schedule: %w", err)
+	}
+	return sched, nil
+}
+
+// Get returns a schedule, or ErrNotFound.
+func (s *Service) Get(ctx context.Context, id string) (Schedule, error) {
+	list, err := s.schedules(ctx, `WHERE id = ?`, strings.TrimSpace(id))
+	if err != nil {
+		return Schedule{}, err
+	}
+	if len(list) == 0 {
+		return Schedule{}, ErrNotFound
+	}
+	return list[0], nil
+}
+
+// List returns taskID's schedules, or every schedule when taskID is empty,
+// in firing order with disabled schedules last.
+func (s *Service) List(ctx context.Context, taskID string) ([]Schedule, error) {
+	order := ` ORDER BY enabled DESC, next_run_at, id`
+	if taskID = strings.TrimSpace(taskID); taskID != "" {
+		return s.schedules(ctx, `WHERE task_id = ?`+order, taskID)
+	}
+	return s.schedules(ctx, order)
+}
+
+// Update applies patch to a schedule. Changing the expression, timezone or
+// enabling it recomputes the next run.
+func (s *Service) Update(ctx context.Context, id string, patch Patch) (Schedule, error) {
+	sched, err := s.Get(ctx, id)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if patch.Cron != nil {
+		sched.Cron = *patch.Cron
+	}
+	if patch.Timezone != nil {
+		sched.Timezone = *patch.Timezone
+	}
+	if patch.Text != nil {
+		sched.Text = *patch.Text
+	}
+	if patch.Enabled != nil {
+		sched.Enabled = *patch.Enabled
+	}
+	now := s.now()
+	if err := s.prepare(&sched, now); err != nil {
+		return Schedule{}, err
+	}
+	sched.UpdatedAt = now
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE schedules SET cron = ?, timezone = ?, text = ?, enabled = ?, next_run_at = ?, updated_at = ?
+		WHERE id = ?
+	`, sched.Cron, sched.Timezone, sched.Text, boolInt(sched.Enabled), formatTime(sched.NextRunAt),
+		now.Format(state.TimeLayout), sched.ID); err != nil {
+		return Schedule{}, fmt.Errorf("update schedule: %w", err)
+	}
+	return sched, nil
+}
+
+// Delete removes a schedule and reports whether it existed.
+func (s *Service) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, strings.TrimSpace(id))
+	if err != nil {
+		return false, fmt.Errorf("delete schedule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete schedule rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Poll fires the schedules that are due and returns how many wakes were
+// sent. A schedule that missed several runs, say while agentd was down,
+// fires once and moves on to its next future run. Each run is claimed
+// before its wake is sent, so instances sharing a database fire it once.
+func (s *Service) Poll(ctx context.Context) (int, error) {
+	now := s.now()
+	due, err := s.schedules(ctx, `WHERE enabled = 1 AND next_run_at <= ? ORDER BY next_run_at, id LIMIT ?`,
+		now.Format(state.TimeLayout), dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, sched := range due {
+		next, err := nextRun(sched.Cron, sched.Timezone, now)
+		enabled := sched.Enabled
+		if err != nil || next.IsZero() {
+			// Stored schedules were valid when saved; a timezone database
+			// change is the only way here.
+			enabled = false
+			next = time.Time{}
+		}
+		res, err := s.db.ExecContext(ctx, `
+			UPDATE schedules SET enabled = ?, next_run_at = ?, last_run_at = ?, runs = runs + 1
+			WHERE id = ? AND enabled = 1 AND next_run_at = ?
+		`, boolInt(enabled), formatTime(next), now.Format(state.TimeLayout), sched.ID, sched.NextRunAt.Format(state.TimeLayout))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("claim schedule %s: %w", sched.ID, err))
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		meta := map[string]any{
+			"kind":        "wake",
+			"priority":    "wake",
+			"reason":      "schedule",
+			"schedule_id": sched.ID,
+			"cron":        sched.Cron,
+			"due_at":      sched.NextRunAt.Format(time.RFC3339),
+		}
+		lastError := ""
+		if _, err := s.deliverFn(ctx, sched.TaskID, "Scheduled wake: "+sched.Text, "scheduler", meta); err != nil {
+			lastError = err.Error()
+			errs = append(errs, fmt.Errorf("wake %s for schedule %s: %w", sched.TaskID, sched.ID, err))
+		} else {
+			sent++
+		}
+		if lastError != sched.LastError {
+			if _, err := s.db.ExecContext(ctx, `UPDATE schedules SET last_error = ? WHERE id = ?`, lastError, sched.ID); err != nil {
+				errs = append(errs, fmt.Errorf("record schedule %s error: %w", sched.ID, err))
+			}
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// prepare normalizes and validates sched and sets its next run.
+func (s *Service) prepare(sched *Schedule, now time.Time) error {
+	sched.Cron = strings.Join(strings.Fields(sched.Cron), " ")
+	sched.Timezone = strings.TrimSpace(sched.Timezone)
+	sched.Text = strings.TrimSpace(sched.Text)
+	if sched.Text == "" {
+		return fmt.Errorf("text is required")
+	}
+	if len(sched.Text) > maxTextLength {
+		return fmt.Errorf("text is longer than %d characters", maxTextLength)
+	}
+	next, err := nextRun(sched.Cron, sched.Timezone, now)
+	if err != nil {
+		return err
+	}
+	if next.IsZero() {
+		return fmt.Errorf("cron expression %q never fires", sched.Cron)
+	}
+	sched.NextRunAt = time.Time{}
+	if sched.Enabled {
+		sched.NextRunAt = next
+	}
+	return nil
+}
+
+// nextRun is the first run of spec after now, read in the named timezone,
+// in UTC.
+func nextRun(spec, timezone string, now time.Time) (time.Time, error) {
+	expr, err := ParseCron(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.UTC
+	if timezone != "" {
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return time.Time{}, fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+	next := expr.Next(now.In(loc))
+	if next.IsZero() {
+		return next, nil
+	}
+	return next.UTC(), nil
+}
+
+func (s *Service) pushWake(ctx context.Context, target, body, source string, meta map[string]any) (eventbus.Event, error) {
+	if s.bus == nil {
+		return eventbus.Event{}, fmt.Errorf("event bus unavailable")
+	}
+	metadata := map[string]any{"source": source, "target": target}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	return s.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamTaskInput,
+		ScopeType: "task",
+		ScopeID:   target,
+		Subject:   "wake: schedule",
+		Body:      body,
+		Metadata:  metadata,
+	})
+}
+
+const scheduleColumns = `id, task_id, cron, timezone, text, enabled, next_run_at, last_run_at, last_error, runs, created_at, updated_at`
+
+func (s *Service) schedules(ctx context.Context, where string, args ...any) ([]Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM schedules `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query schedules: %w", err)
+	}
+	defer rows.Close()
+	out := []Schedule{}
+	for rows.Next() {
+		var sched Schedule
+		var enabled int
+		var nextRunAt, lastRunAt, createdAt, updatedAt string
+		if err := rows.Scan(&sched.ID, &sched.TaskID, &sched.Cron, &sched.Timezone, &sched.Text, &enabled,
+			&nextRunAt, &lastRunAt, &sched.LastError, &sched.Runs, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		sched.Enabled = enabled != 0
+		sched.NextRunAt, _ = time.Parse(state.TimeLayout, nextRunAt)
+		if t, err := time.Parse(state.TimeLayout, lastRunAt); err == nil {
+			sched.LastRunAt = &t
+		}
+		sched.CreatedAt, _ = time.Parse(state.TimeLayout, createdAt)
+		sched.UpdatedAt, _ = time.Parse(state.TimeLayout, updatedAt)
+		out = append(out, sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate schedules: %w", err)
+	}
+	return out, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(state.TimeLayout)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestPollFiresDueSchedulesOnce(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	type wake struct {
+		target, body string
+		meta         map[string]any
+	}
+	var wakes []wake
+	svc := NewService(db, eventbus.NewBus(db),
+		WithClock(func() time.Time { return now }),
+		WithDeliverer(func(_ context.Context, target, body, _ string, meta map[string]any) (eventbus.Event, error) {
+			wakes = append(wakes, wake{target, body, meta})
+			return eventbus.Event{}, nil
+		}))
+
+	daily, err := svc.Create(ctx, Schedule{TaskID: "agent-1", Cron: "0 9 * * *", Text: "check the inbox", Enabled: true})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC); !daily.NextRunAt.Equal(want) {
+		t.Fatalf("expected next run %v, got %v", want, daily.NextRunAt)
+	}
+	paused, err := svc.Create(ctx, Schedule{TaskID: "agent-1", Cron: "@hourly", Text: "paused", Enabled: false})
+	if err != nil {
+		t.Fatalf("create paused: %v", err)
+	}
+	if !paused.NextRunAt.IsZero() {
+		t.Fatalf("expected no next run while disabled, got %v", paused.NextRunAt)
+	}
+
+	if n, err := svc.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing due, got %d, %v", n, err)
+	}
+	// Down for two days: the missed runs fire once.
+	now = time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	if n, err := svc.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one wake, got %d, %v", n, err)
+	}
+	if n, err := svc.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("expected the run to be claimed, got %d, %v", n, err)
+	}
+	if len(wakes) != 1 || wakes[0].target != "agent-1" || wakes[0].body != "Scheduled wake: check the inbox" {
+		t.Fatalf("unexpected wakes %+v", wakes)
+	}
+	if wakes[0].meta["schedule_id"] != daily.ID || wakes[0].meta["kind"] != "wake" || wakes[0].meta["reason"] != "schedule" {
+		t.Fatalf("unexpected wake metadata %+v", wakes[0].meta)
+	}
+	got, err := svc.Get(ctx, daily.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Runs != 1 || got.LastRunAt == nil || !got.LastRunAt.Equal(now) {
+		t.Fatalf("expected one recorded run, got %+v", got)
+	}
+	if want := time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC); !got.NextRunAt.Equal(want) {
+		t.Fatalf("expected next run %v, got %v", want, got.NextRunAt)
+	}
+}
+
+func TestScheduleTimezoneAndUpdate(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewService(db, eventbus.NewBus(db), WithClock(func() time.Time { return now }))
+	sched, err := svc.Create(ctx, Schedule{TaskID: "agent-1", Cron: "0 9 * * *", Timezone: "America/New_York", Text: "standup", Enabled: true})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if want := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC); !sched.NextRunAt.Equal(want) {
+		t.Fatalf("expected 9:00 EDT as %v, got %v", want, sched.NextRunAt)
+	}
+
+	bad := "0 25 * * *"
+	if _, err := svc.Update(ctx, sched.ID, Patch{Cron: &bad}); err == nil {
+		t.Fatalf("expected an invalid expression to be rejected")
+	}
+	zone := "Mars/Olympus"
+	if _, err := svc.Update(ctx, sched.ID, Patch{Timezone: &zone}); err == nil {
+		t.Fatalf("expected an unknown timezone to be rejected")
+	}
+	off := false
+	updated, err := svc.Update(ctx, sched.ID, Patch{Enabled: &off})
+	if err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if updated.Enabled || !updated.NextRunAt.IsZero() || updated.Cron != "0 9 * * *" {
+		t.Fatalf("unexpected disabled schedule %+v", updated)
+	}
+
+	list, err := svc.List(ctx, "agent-1")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one schedule, got %d, %v", len(list), err)
+	}
+	if removed, err := svc.Delete(ctx, sched.ID); err != nil || !removed {
+		t.Fatalf("delete: %v %v", removed, err)
+	}
+	if _, err := svc.Get(ctx, sched.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -13,6 +13,21 @@ const (
 	MetaSource       = "source"
 	MetaTaskID       = "task_id"
 	MetaTaskKind     = "task_kind"
+	MetaAgentID      = "agent_id"
+	MetaEventID      = "event_id"
+	// Model overrides apply to the single turn a message starts.
+	MetaModelOverride    = "model_override"
+	MetaProviderOverride = "provider_override"
+	// MetaToolset holds an agent's tool allow and deny lists.
+	MetaToolset = "toolset"
+	// MetaPreprocessors holds the steps an agent's inbound messages pass
+	// through.
+	MetaPreprocessors = "preprocessors"
+	// MetaBudget holds an agent's daily and monthly usage caps.
+	MetaBudget = "budget"
+	// MetaNamespace is the tenant a task or event belongs to. Empty is the
+	// default namespace.
+	MetaNamespace = "namespace"
 	// Delivery controls how an event is routed to consumers.
 	MetaDeliveryMode    = "delivery_mode"    // "default" | "opt_in" | "opt_out"
 	MetaDeliveryInclude = "delivery_include" // []string, []any, or comma-delimited string
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MetaParts carries the parts of a multi-part message in event metadata and
+// assistant_output payloads. The message body or "text" stays a complete
+// text rendering, so text-only channels can ignore parts.
+const MetaParts = "parts"
+
+// Part types.
+const (
+	PartText  = "text"
+	PartImage = "image"
+	PartFile  = "file"
+)
+
+// Part is one piece of an outbound message. Images and files are refs: a
+// URL (http(s) or data:) or an artifact ID, never inline bytes of their own.
+type Part struct {
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	URL        string `json:"url,omitempty"`
+	ArtifactID string `json:"artifact_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	MimeType   string `json:"mime_type,omitempty"`
+}
+
+// Validate checks that a part has a known type and the field it needs.
+func (p Part) Validate() error {
+	switch p.Type {
+	case PartText:
+		if strings.TrimSpace(p.Text) == "" {
+			return fmt.Errorf("text part needs text")
+		}
+	case PartImage, PartFile:
+		if strings.TrimSpace(p.URL) == "" && strings.TrimSpace(p.ArtifactID) == "" {
+			return fmt.Errorf("%s part needs a url or artifact_id", p.Type)
+		}
+	default:
+		return fmt.Errorf("unknown part type %q", p.Type)
+	}
+	return nil
+}
+
+// ParseParts reads parts from metadata or a decoded payload, which may hold
+// them as []Part or as decoded JSON. Invalid parts are dropped.
+func ParseParts(raw any) []Part {
+	var parts []Part
+	switch v := raw.(type) {
+	case nil:
+		return nil
+	case []Part:
+		parts = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil || json.Unmarshal(data, &parts) != nil {
+			return nil
+		}
+	}
+	out := make([]Part, 0, len(parts))
+	for _, p := range parts {
+		if p.Validate() == nil {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// HasAttachments reports whether parts carry anything besides text.
+func HasAttachments(parts []Part) bool {
+	for _, p := range parts {
+		if p.Type != PartText {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentsText renders the non-text parts as lines for text-only
+// channels, e.g. "[image: chart.png] https://…". Data URLs are left out;
+// they only make sense to a renderer.
+func AttachmentsText(parts []Part) string {
+	var lines []string
+	for _, p := range parts {
+		if p.Type == PartText {
+			continue
+		}
+		label := p.Type
+		if name := strings.TrimSpace(p.Name); name != "" {
+			label += ": " + name
+		}
+		line := "[" + label + "]"
+		switch {
+		case p.URL != "" && !strings.HasPrefix(p.URL, "data:"):
+			line += " " + p.URL
+		case p.ArtifactID != "":
+			line += " artifact " + p.ArtifactID
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TextWithAttachments appends the attachment lines to text.
+func TextWithAttachments(text string, parts []Part) string {
+	attachments := AttachmentsText(parts)
+	switch {
+	case attachments == "":
+		return text
+	case strings.TrimSpace(text) == "":
+		return attachments
+	}
+	return text + "\n\n" + attachments
+}
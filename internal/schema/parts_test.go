@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParts(t *testing.T) {
+	raw := []any{
+		map[string]any{"type": "text", "text": "Here is the chart."},
+		map[string]any{"type": "image", "url": "https://example.com/chart.png", "name": "chart.png"},
+		map[string]any{"type": "file"},
+		map[string]any{"type": "video", "url": "https://example.com/a.mp4"},
+	}
+	want := []Part{
+		{Type: PartText, Text: "Here is the chart."},
+		{Type: PartImage, URL: "https://example.com/chart.png", Name: "chart.png"},
+	}
+	if got := ParseParts(raw); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseParts = %+v, want %+v", got, want)
+	}
+	if got := ParseParts(want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected []Part to pass through, got %+v", got)
+	}
+	if got := ParseParts("nope"); got != nil {
+		t.Fatalf("expected nil for malformed parts, got %+v", got)
+	}
+}
+
+func TestTextWithAttachments(t *testing.T) {
+	parts := []Part{
+		{Type: PartText, Text: "ignored"},
+		{Type: PartImage, URL: "https://example.com/chart.png", Name: "chart.png"},
+		{Type: PartImage, URL: "data:image/png;base64,AAAA"},
+		{Type: PartFile, ArtifactID: "art-1", Name: "reply.md"},
+	}
+	want := "Done.\n\n[image: chart.png] https://example.com/chart.png\n[image]\n[file: reply.md] artifact art-1"
+	if got := TextWithAttachments("Done.", parts); got != want {
+		t.Fatalf("TextWithAttachments = %q, want %q", got, want)
+	}
+	if got := TextWithAttachments(" ", parts[3:]); got != "[file: reply.md] artifact art-1" {
+		t.Fatalf("expected only the attachments without text, got %q", got)
+	}
+	if got := TextWithAttachments("Done.", parts[:1]); got != "Done." {
+		t.Fatalf("expected text-only parts to leave text alone, got %q", got)
+	}
+}
@@ -1,6 +1,9 @@
 package schema
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // Priority represents a validated event priority level.
 type Priority string
@@ -12,7 +15,56 @@ const (
 	PriorityLow       Priority = "low"
 )
 
-// ParsePriority validates a raw string. Defaults to PriorityNormal.
+// Priorities lists every priority, most urgent first.
+var Priorities = []Priority{PriorityInterrupt, PriorityWake, PriorityNormal, PriorityLow}
+
+// PriorityError reports a priority outside the enum.
+type PriorityError struct {
+	Value string
+}
+
+func (e *PriorityError) Error() string {
+	return fmt.Sprintf("invalid priority %q: must be one of interrupt, wake, normal or low", e.Value)
+}
+
+// ValidatePriority is the strict parse used at the API edge. Case and
+// surrounding space are ignored and an empty value is PriorityNormal; any
+// other value outside the enum is a *PriorityError.
+func ValidatePriority(raw string) (Priority, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	if value == "" {
+		return PriorityNormal, nil
+	}
+	for _, p := range Priorities {
+		if value == string(p) {
+			return p, nil
+		}
+	}
+	return "", &PriorityError{Value: raw}
+}
+
+// NormalizePriorityMeta returns meta with its "priority" value rewritten to
+// the canonical form ParsePriority gives it, copying meta only when the
+// value changes. Non-string values are left alone.
+func NormalizePriorityMeta(meta map[string]any) map[string]any {
+	raw, ok := meta[MetaPriority].(string)
+	if !ok {
+		return meta
+	}
+	p := string(ParsePriority(raw))
+	if p == raw {
+		return meta
+	}
+	out := make(map[string]any, len(meta))
+	for k, v := range meta {
+		out[k] = v
+	}
+	out[MetaPriority] = p
+	return out
+}
+
+// ParsePriority is the lenient parse used by internal callers: unknown
+// values become PriorityNormal.
 func ParsePriority(raw string) Priority {
 	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case "interrupt":
@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePriority(t *testing.T) {
+	for raw, want := range map[string]Priority{"": PriorityNormal, " WAKE ": PriorityWake, "low": PriorityLow, "interrupt": PriorityInterrupt} {
+		if got, err := ValidatePriority(raw); err != nil || got != want {
+			t.Fatalf("ValidatePriority(%q) = %q, %v", raw, got, err)
+		}
+	}
+	var perr *PriorityError
+	if _, err := ValidatePriority("urgent"); !errors.As(err, &perr) || perr.Value != "urgent" {
+		t.Fatalf("expected a PriorityError, got %v", err)
+	}
+}
+
+func TestNormalizePriorityMeta(t *testing.T) {
+	meta := map[string]any{"priority": " Wake", "kind": "message"}
+	got := NormalizePriorityMeta(meta)
+	if got["priority"] != "wake" || got["kind"] != "message" || meta["priority"] != " Wake" {
+		t.Fatalf("expected a normalized copy, got %v (input %v)", got, meta)
+	}
+	if got := NormalizePriorityMeta(map[string]any{"priority": "bogus"}); got["priority"] != "normal" {
+		t.Fatalf("expected unknown priorities to become normal, got %v", got)
+	}
+	if got := NormalizePriorityMeta(nil); got != nil {
+		t.Fatalf("expected nil metadata to stay nil")
+	}
+}
@@ -7,8 +7,54 @@ const (
 	StreamErrors     = "errors"
 	StreamExternal   = "external"
 	StreamHistory    = "history"
+	// StreamProgress carries per-agent activity states for chat frontends
+	// (typing indicators, tool activity). Agents never wake on it.
+	StreamProgress = "progress"
+	// StreamReports carries periodic per-agent activity reports.
+	StreamReports = "reports"
+	// StreamShared carries events published to named topics. Each event is
+	// scoped to its topic and reaches the agents subscribed to it.
+	StreamShared = "shared"
+	// StreamAlerts carries critical operator alerts, such as an agent being
+	// quarantined or an interrupt going unanswered. Events are scoped to the
+	// agent they concern and name their kind in MetaKind.
+	StreamAlerts = "alerts"
+	// StreamDeadLetter holds events quarantined after an agent's loop kept
+	// crashing while handling them, scoped to the agent with the original
+	// stream, event ID and error in their metadata.
+	StreamDeadLetter = "dead_letter"
 )
 
+// Alert kinds sent on StreamAlerts.
+const (
+	AlertAgentQuarantined        = "agent_quarantined"
+	AlertAgentCrashLoop          = "agent_crash_loop"
+	AlertBudgetExceeded          = "budget_exceeded"
+	AlertInterruptUnacknowledged = "interrupt_unacknowledged"
+)
+
+// AlertKinds lists every alert kind operators can subscribe to.
+var AlertKinds = []string{
+	AlertAgentQuarantined,
+	AlertAgentCrashLoop,
+	AlertBudgetExceeded,
+	AlertInterruptUnacknowledged,
+}
+
+// UITopicPrefix prefixes the derived per-agent topics that carry UI
+// projections (history entries, progress states) for one agent.
+const UITopicPrefix = "ui:"
+
+// UITopic returns the UI topic for agentID.
+func UITopic(agentID string) string {
+	return UITopicPrefix + agentID
+}
+
+// StreamActivity is the derived topic carrying typed agent activity frames
+// (text deltas, thinking, tool calls, turn completion) as a turn streams.
+// Nothing on it is stored.
+const StreamActivity = "activity"
+
 // AgentStreams are the streams the agent loop monitors for context
 // events and that wake awaiting tasks.
 var AgentStreams = []string{
@@ -17,6 +63,7 @@ var AgentStreams = []string{
 	StreamErrors,
 	StreamExternal,
 	StreamTaskInput,
+	StreamShared,
 }
 
 // StreamOrdering returns "fifo" or "lifo" for a given stream.
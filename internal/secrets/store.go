@@ -0,0 +1,164 @@
+// Package secrets keeps credentials encrypted at rest. Values are sealed
+// with AES-256-GCM under a key stored outside the database, so a copy of the
+// database alone does not reveal them.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KeySize is the length in bytes of the store's encryption key.
+const KeySize = 32
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+type Store struct {
+	db   *sql.DB
+	aead cipher.AEAD
+
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+// NewStore returns a store sealing values with key, which must be KeySize
+// bytes.
+func NewStore(db *sql.DB, key []byte, opts ...Option) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("secrets cipher: %w", err)
+	}
+	s := &Store{
+		db:    db,
+		aead:  aead,
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Put stores value as owner's secret called name, replacing any previous
+// value.
+func (s *Store) Put(ctx context.Context, owner, name string, value []byte) error {
+	owner, name = strings.TrimSpace(owner), strings.TrimSpace(name)
+	if owner == "" || name == "" {
+		return fmt.Errorf("secret owner and name are required")
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("secret nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, value, additionalData(owner, name))
+	now := s.now().Format(time.RFC3339Nano)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO secrets (owner, name, value, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(owner, name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, owner, name, sealed, now, now); err != nil {
+		return fmt.Errorf("store secret: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, owner, name string) ([]byte, error) {
+	owner, name = strings.TrimSpace(owner), strings.TrimSpace(name)
+	var sealed []byte
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM secrets WHERE owner = ? AND name = ?`, owner, name).Scan(&sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSecretNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("secret %s/%s is corrupt", owner, name)
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	value, err := s.aead.Open(nil, nonce, ciphertext, additionalData(owner, name))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secret %s/%s: %w", owner, name, err)
+	}
+	return value, nil
+}
+
+// Delete removes owner's secret called name and reports whether it existed.
+func (s *Store) Delete(ctx context.Context, owner, name string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM secrets WHERE owner = ? AND name = ?`,
+		strings.TrimSpace(owner), strings.TrimSpace(name))
+	if err != nil {
+		return false, fmt.Errorf("delete secret: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete secret rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// additionalData binds a sealed value to its row so values cannot be moved
+// between owners or names.
+func additionalData(owner, name string) []byte {
+	return []byte(owner + "\x00" + name)
+}
+
+// LoadOrCreateKey reads the hex-encoded key at path, generating and writing
+// a new one if the file does not exist.
+func LoadOrCreateKey(path string) ([]byte, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("decode secrets key: invalid contents in %s", path)
+		}
+		return key, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read secrets key: %w", err)
+	}
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate secrets key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create secrets key dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("write secrets key: %w", err)
+	}
+	return key, nil
+}
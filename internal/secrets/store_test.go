@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreSealsValuesPerOwnerAndName(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	key, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "secrets.key"))
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	store, err := NewStore(db, key)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if err := store.Put(ctx, "agent-a", "calendar", []byte("token-1")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var raw []byte
+	if err := db.QueryRow(`SELECT value FROM secrets WHERE owner = 'agent-a'`).Scan(&raw); err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if bytes.Contains(raw, []byte("token-1")) {
+		t.Fatalf("expected the stored value to be encrypted")
+	}
+	if got, err := store.Get(ctx, "agent-a", "calendar"); err != nil || string(got) != "token-1" {
+		t.Fatalf("unexpected get %q %v", got, err)
+	}
+
+	// A value moved to another owner does not decrypt.
+	if _, err := db.Exec(`INSERT INTO secrets (owner, name, value, created_at, updated_at) VALUES ('agent-b', 'calendar', ?, '', '')`, raw); err != nil {
+		t.Fatalf("copy raw: %v", err)
+	}
+	if _, err := store.Get(ctx, "agent-b", "calendar"); err == nil {
+		t.Fatalf("expected a value copied to another owner to fail")
+	}
+
+	other, _ := NewStore(db, bytes.Repeat([]byte{7}, KeySize))
+	if _, err := other.Get(ctx, "agent-a", "calendar"); err == nil {
+		t.Fatalf("expected another key to fail")
+	}
+	if removed, err := store.Delete(ctx, "agent-a", "calendar"); err != nil || !removed {
+		t.Fatalf("delete: %v %v", removed, err)
+	}
+	if _, err := store.Get(ctx, "agent-a", "calendar"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
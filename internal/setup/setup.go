@@ -0,0 +1,412 @@
+// Package setup is the interactive first-boot wizard behind `agentd setup`.
+// It asks for a provider, model, API key, data directory and listen address,
+// validates each answer, optionally creates a first agent and sends it a
+// test message, and writes config.json. API keys go to .env, never to
+// config.json.
+package setup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+)
+
+// Provider describes one supported LLM provider.
+type Provider struct {
+	Name         string
+	DefaultModel string
+	// KeyEnv is the environment variable agentd reads the API key from.
+	KeyEnv string
+	// KeyPrefix is the prefix every key from this provider starts with.
+	KeyPrefix string
+}
+
+var Providers = []Provider{
+	{Name: "anthropic", DefaultModel: "claude-sonnet-4-5", KeyEnv: "GO_AGENTS_ANTHROPIC_API_KEY", KeyPrefix: "sk-ant-"},
+	{Name: "openai-responses", DefaultModel: "gpt-4o", KeyEnv: "GO_AGENTS_OPENAI_API_KEY", KeyPrefix: "sk-"},
+	{Name: "openai-chat", DefaultModel: "gpt-4o", KeyEnv: "GO_AGENTS_OPENAI_API_KEY", KeyPrefix: "sk-"},
+	{Name: "google", DefaultModel: "gemini-2.0-flash", KeyEnv: "GO_AGENTS_GOOGLE_API_KEY", KeyPrefix: "AIza"},
+}
+
+// LookupProvider finds a provider by name.
+func LookupProvider(name string) (Provider, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range Providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// Answers are the validated results of a wizard run.
+type Answers struct {
+	Provider string
+	Model    string
+	APIKey   string
+	DataDir  string
+	HTTPAddr string
+	// AgentID is the first agent to create; empty skips it.
+	AgentID string
+}
+
+// Wizard asks its questions on In and Out. Dir is where config.json and
+// .env are written; it defaults to the working directory.
+type Wizard struct {
+	In  io.Reader
+	Out io.Writer
+	Dir string
+
+	// CreateAgent creates the first agent in the chosen data directory.
+	CreateAgent func(ctx context.Context, answers Answers) error
+	// SendTestMessage sends a short message with the chosen provider, model
+	// and key and returns the model's reply.
+	SendTestMessage func(ctx context.Context, answers Answers, message string) (string, error)
+
+	scanner *bufio.Scanner
+}
+
+// Run asks every question, re-asking until the answer validates, and writes
+// the configuration. It returns the answers it wrote.
+func (w *Wizard) Run(ctx context.Context) (Answers, error) {
+	w.scanner = bufio.NewScanner(w.In)
+	existing, err := readConfigMap(w.configPath())
+	if err != nil {
+		return Answers{}, err
+	}
+	w.printf("go-agents setup. Press enter to accept the value in brackets.\n\n")
+
+	var answers Answers
+	names := make([]string, 0, len(Providers))
+	for _, p := range Providers {
+		names = append(names, p.Name)
+	}
+	defaultProvider := stringOr(existing["llm_provider"], Providers[0].Name)
+	answers.Provider, err = w.ask(fmt.Sprintf("LLM provider (%s)", strings.Join(names, ", ")), defaultProvider, ValidateProvider)
+	if err != nil {
+		return Answers{}, err
+	}
+	provider, _ := LookupProvider(answers.Provider)
+	answers.Provider = provider.Name
+
+	defaultModel := provider.DefaultModel
+	if existing["llm_provider"] == provider.Name {
+		defaultModel = stringOr(existing["llm_model"], defaultModel)
+	}
+	answers.Model, err = w.ask("Model", defaultModel, ValidateModel)
+	if err != nil {
+		return Answers{}, err
+	}
+
+	keyDefault := os.Getenv(provider.KeyEnv)
+	keyPrompt := fmt.Sprintf("API key (stored in .env as %s)", provider.KeyEnv)
+	if keyDefault != "" {
+		keyPrompt += "; enter keeps the current key"
+	}
+	answers.APIKey, err = w.askSecret(keyPrompt, keyDefault, func(v string) (string, error) {
+		return ValidateAPIKey(provider, v)
+	})
+	if err != nil {
+		return Answers{}, err
+	}
+
+	answers.DataDir, err = w.ask("Data directory", stringOr(existing["data_dir"], "data"), ValidateDataDir)
+	if err != nil {
+		return Answers{}, err
+	}
+	answers.HTTPAddr, err = w.ask("HTTP listen address", stringOr(existing["http_addr"], ":8080"), ValidateHTTPAddr)
+	if err != nil {
+		return Answers{}, err
+	}
+	answers.AgentID, err = w.ask("First agent ID (- to skip)", "assistant", ValidateAgentID)
+	if err != nil {
+		return Answers{}, err
+	}
+
+	if err := WriteEnvKey(filepath.Join(w.dir(), ".env"), provider.KeyEnv, answers.APIKey); err != nil {
+		return Answers{}, err
+	}
+	if err := WriteConfig(w.configPath(), answers); err != nil {
+		return Answers{}, err
+	}
+	w.printf("\nWrote %s and stored the API key in %s.\n", w.configPath(), filepath.Join(w.dir(), ".env"))
+
+	if answers.AgentID != "" && w.CreateAgent != nil {
+		if err := w.CreateAgent(ctx, answers); err != nil {
+			return answers, fmt.Errorf("create agent %s: %w", answers.AgentID, err)
+		}
+		w.printf("Created agent %q.\n", answers.AgentID)
+	}
+	if w.SendTestMessage != nil {
+		send, err := w.ask("Send a test message to the model? (y/n)", "y", validateYesNo)
+		if err != nil {
+			return answers, err
+		}
+		if send == "y" {
+			reply, err := w.SendTestMessage(ctx, answers, "Reply with a one-line greeting.")
+			if err != nil {
+				return answers, fmt.Errorf("test message failed: %w", err)
+			}
+			w.printf("Model replied: %s\n", strings.TrimSpace(reply))
+		}
+	}
+	w.printf("\nSetup complete. Start the server with `agentd`.\n")
+	return answers, nil
+}
+
+func (w *Wizard) dir() string {
+	if strings.TrimSpace(w.Dir) == "" {
+		return "."
+	}
+	return w.Dir
+}
+
+func (w *Wizard) configPath() string {
+	return filepath.Join(w.dir(), "config.json")
+}
+
+func (w *Wizard) printf(format string, args ...any) {
+	if w.Out != nil {
+		fmt.Fprintf(w.Out, format, args...)
+	}
+}
+
+// ask prompts until validate accepts the answer. An empty answer takes def.
+func (w *Wizard) ask(question, def string, validate func(string) (string, error)) (string, error) {
+	for {
+		if def != "" {
+			w.printf("%s [%s]: ", question, def)
+		} else {
+			w.printf("%s: ", question)
+		}
+		answer, err := w.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		value, err := validate(answer)
+		if err == nil {
+			return value, nil
+		}
+		w.printf("  %v\n", err)
+	}
+}
+
+// askSecret is ask without echoing the default back.
+func (w *Wizard) askSecret(question, def string, validate func(string) (string, error)) (string, error) {
+	for {
+		w.printf("%s: ", question)
+		answer, err := w.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		value, err := validate(answer)
+		if err == nil {
+			return value, nil
+		}
+		w.printf("  %v\n", err)
+	}
+}
+
+func (w *Wizard) readLine() (string, error) {
+	if !w.scanner.Scan() {
+		if err := w.scanner.Err(); err != nil {
+			return "", fmt.Errorf("read answer: %w", err)
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimSpace(w.scanner.Text()), nil
+}
+
+func ValidateProvider(value string) (string, error) {
+	p, ok := LookupProvider(value)
+	if !ok {
+		return "", fmt.Errorf("unknown provider %q", value)
+	}
+	return p.Name, nil
+}
+
+func ValidateModel(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("model is required")
+	}
+	if strings.ContainsAny(value, " \t") {
+		return "", errors.New("model names contain no spaces")
+	}
+	return value, nil
+}
+
+// ValidateAPIKey checks a key's shape for provider. Whether the provider
+// accepts it is only known once a request is made.
+func ValidateAPIKey(p Provider, value string) (string, error) {
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	if value == "" {
+		return "", errors.New("API key is required")
+	}
+	if strings.ContainsAny(value, " \t\r\n") {
+		return "", errors.New("API key must not contain whitespace")
+	}
+	if p.KeyPrefix != "" && !strings.HasPrefix(value, p.KeyPrefix) {
+		return "", fmt.Errorf("%s keys start with %q", p.Name, p.KeyPrefix)
+	}
+	if len(value) < len(p.KeyPrefix)+16 {
+		return "", errors.New("API key is too short")
+	}
+	return value, nil
+}
+
+// ValidateDataDir creates dir if needed and checks that it is writable.
+func ValidateDataDir(value string) (string, error) {
+	dir := strings.TrimSpace(value)
+	if dir == "" {
+		return "", errors.New("data directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	probe, err := os.CreateTemp(dir, ".setup-probe-*")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := probe.Name()
+	_ = probe.Close()
+	_ = os.Remove(name)
+	return dir, nil
+}
+
+// ValidateHTTPAddr checks that value is a host:port with a usable port.
+func ValidateHTTPAddr(value string) (string, error) {
+	addr := strings.TrimSpace(value)
+	if addr == "" {
+		return "", errors.New("listen address is required")
+	}
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address: %w", err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil || port == "" || port == "0" {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	return addr, nil
+}
+
+// ValidateAgentID accepts an agent ID, or "-" to skip creating one.
+func ValidateAgentID(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "-" {
+		return "", nil
+	}
+	if err := idgen.ValidateCustomID(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func validateYesNo(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "y", "yes":
+		return "y", nil
+	case "n", "no":
+		return "n", nil
+	}
+	return "", errors.New("answer y or n")
+}
+
+// WriteConfig writes answers into the config.json at path, keeping any other
+// settings already in it.
+func WriteConfig(path string, answers Answers) error {
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		return err
+	}
+	cfg["llm_provider"] = answers.Provider
+	cfg["llm_model"] = answers.Model
+	cfg["data_dir"] = answers.DataDir
+	cfg["http_addr"] = answers.HTTPAddr
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+func readConfigMap(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	cfg := map[string]any{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("existing %s is not valid JSON: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// WriteEnvKey sets key=value in the .env file at path, replacing an earlier
+// assignment and keeping other lines. The file is readable by its owner only.
+func WriteEnvKey(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" && len(lines) == 0 {
+			continue
+		}
+		name, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if ok && strings.TrimSpace(name) == key {
+			if !replaced {
+				lines = append(lines, key+"="+value)
+				replaced = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !replaced {
+		lines = append(lines, key+"="+value)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("restrict %s: %w", path, err)
+	}
+	return nil
+}
+
+func stringOr(v any, def string) string {
+	if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+		return s
+	}
+	return def
+}
@@ -0,0 +1,86 @@
+package setup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWizardValidatesAnswersAndWritesConfig(t *testing.T) {
+	t.Setenv("GO_AGENTS_OPENAI_API_KEY", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"classify_urgency": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("OTHER_API_KEY=keep\nGO_AGENTS_OPENAI_API_KEY=old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := filepath.Join(dir, "state")
+	input := strings.Join([]string{
+		"mistral",                 // rejected
+		"openai-chat",             //
+		"",                        // default model
+		"not-a-key",               // rejected: prefix
+		"sk-abcdefghijklmnopqrst", //
+		dataDir,
+		"localhost:notaport", // rejected
+		"9090",
+		"my agent", // rejected: space
+		"helper",
+		"y",
+	}, "\n") + "\n"
+
+	var created, sent string
+	var out bytes.Buffer
+	w := &Wizard{
+		In:  strings.NewReader(input),
+		Out: &out,
+		Dir: dir,
+		CreateAgent: func(_ context.Context, a Answers) error {
+			created = a.AgentID
+			return nil
+		},
+		SendTestMessage: func(_ context.Context, a Answers, message string) (string, error) {
+			sent = a.APIKey
+			return "Hello!", nil
+		},
+	}
+	answers, err := w.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out.String())
+	}
+	if answers.Provider != "openai-chat" || answers.Model != "gpt-4o" || answers.HTTPAddr != ":9090" || answers.DataDir != dataDir {
+		t.Fatalf("unexpected answers: %+v", answers)
+	}
+	for _, want := range []string{`unknown provider "mistral"`, `keys start with "sk-"`, `invalid port`, "Model replied: Hello!"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+	if created != "helper" || sent != "sk-abcdefghijklmnopqrst" {
+		t.Fatalf("expected agent and test message, got %q %q", created, sent)
+	}
+
+	var cfg map[string]any
+	data, _ := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("config not valid JSON: %v", err)
+	}
+	if cfg["llm_provider"] != "openai-chat" || cfg["http_addr"] != ":9090" || cfg["classify_urgency"] != true {
+		t.Fatalf("unexpected config: %v", cfg)
+	}
+	if strings.Contains(string(data), "sk-") {
+		t.Fatalf("API key leaked into config.json")
+	}
+	env, _ := os.ReadFile(filepath.Join(dir, ".env"))
+	if string(env) != "OTHER_API_KEY=keep\nGO_AGENTS_OPENAI_API_KEY=sk-abcdefghijklmnopqrst\n" {
+		t.Fatalf("unexpected .env: %q", env)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, ".env")); info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected .env mode 0600, got %v", info.Mode().Perm())
+	}
+}
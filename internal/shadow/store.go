@@ -0,0 +1,318 @@
+// Package shadow stores A/B trials in which an agent's turns are mirrored to
+// a candidate prompt and model. The candidate's outputs are recorded next to
+// the live ones but never delivered.
+package shadow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/idgen"
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+const (
+	StatusActive   = "active"
+	StatusPromoted = "promoted"
+	StatusStopped  = "stopped"
+)
+
+// DefaultDuration is how long a trial runs when no duration is given.
+const DefaultDuration = 24 * time.Hour
+
+// ErrNoActiveTrial is returned when an agent has no running trial.
+var ErrNoActiveTrial = errors.New("no active shadow trial")
+
+// Trial mirrors AgentID's turns to a candidate until EndsAt. System replaces
+// the agent's system prompt addition and Model, when set, its model.
+type Trial struct {
+	ID        string     `json:"id"`
+	AgentID   string     `json:"agent_id"`
+	System    string     `json:"system,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	EndsAt    time.Time  `json:"ends_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+type TrialInput struct {
+	AgentID string
+	System  string
+	Model   string
+	// Duration defaults to DefaultDuration.
+	Duration time.Duration
+}
+
+// Run is one mirrored turn: the input both generations saw and what each
+// produced.
+type Run struct {
+	ID               string     `json:"id"`
+	TrialID          string     `json:"trial_id"`
+	AgentID          string     `json:"agent_id"`
+	LLMTaskID        string     `json:"llm_task_id"`
+	Input            string     `json:"input"`
+	PrimaryOutput    string     `json:"primary_output"`
+	PrimaryError     string     `json:"primary_error,omitempty"`
+	ShadowOutput     string     `json:"shadow_output"`
+	ShadowError      string     `json:"shadow_error,omitempty"`
+	ShadowTools      []string   `json:"shadow_tools"`
+	ShadowDurationMS int64      `json:"shadow_duration_ms"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ShadowDoneAt     *time.Time `json:"shadow_done_at,omitempty"`
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn   func() time.Time
+	newIDFn func() string
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		nowFn:   func() time.Time { return time.Now().UTC() },
+		newIDFn: idgen.New,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Start begins a trial for input.AgentID, stopping any trial already running
+// for that agent.
+func (s *Store) Start(ctx context.Context, input TrialInput) (Trial, error) {
+	agentID := strings.TrimSpace(input.AgentID)
+	if agentID == "" {
+		return Trial{}, fmt.Errorf("agent_id is required")
+	}
+	system := strings.TrimSpace(input.System)
+	model := strings.TrimSpace(input.Model)
+	if system == "" && model == "" {
+		return Trial{}, fmt.Errorf("system or model is required")
+	}
+	if input.Duration < 0 {
+		return Trial{}, fmt.Errorf("duration must be positive")
+	}
+	duration := input.Duration
+	if duration == 0 {
+		duration = DefaultDuration
+	}
+	now := s.now()
+	t := Trial{
+		ID:        s.newIDFn(),
+		AgentID:   agentID,
+		System:    system,
+		Model:     model,
+		Status:    StatusActive,
+		CreatedAt: now,
+		EndsAt:    now.Add(duration),
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE shadow_trials SET status = ?, ended_at = ? WHERE agent_id = ? AND status = ?
+	`, StatusStopped, now.Format(state.TimeLayout), agentID, StatusActive); err != nil {
+		return Trial{}, fmt.Errorf("stop previous shadow trial: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO shadow_trials (id, agent_id, system, model, status, created_at, ends_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.AgentID, t.System, t.Model, t.Status, t.CreatedAt.Format(state.TimeLayout), t.EndsAt.Format(state.TimeLayout)); err != nil {
+		return Trial{}, fmt.Errorf("insert shadow trial: %w", err)
+	}
+	return t, nil
+}
+
+// Active returns agentID's running trial. A trial past its end time is not
+// active even before it is closed.
+func (s *Store) Active(ctx context.Context, agentID string) (Trial, bool, error) {
+	trials, err := s.queryTrials(ctx, `
+		SELECT id, agent_id, system, model, status, created_at, ends_at, ended_at FROM shadow_trials
+		WHERE agent_id = ? AND status = ? AND ends_at > ?
+		ORDER BY created_at DESC LIMIT 1
+	`, strings.TrimSpace(agentID), StatusActive, s.now().Format(state.TimeLayout))
+	if err != nil || len(trials) == 0 {
+		return Trial{}, false, err
+	}
+	return trials[0], true, nil
+}
+
+// List returns agentID's trials, newest first.
+func (s *Store) List(ctx context.Context, agentID string) ([]Trial, error) {
+	return s.queryTrials(ctx, `
+		SELECT id, agent_id, system, model, status, created_at, ends_at, ended_at FROM shadow_trials
+		WHERE agent_id = ? ORDER BY created_at DESC
+	`, strings.TrimSpace(agentID))
+}
+
+// End closes agentID's active trial with status (promoted or stopped) and
+// returns it.
+func (s *Store) End(ctx context.Context, agentID, status string) (Trial, error) {
+	if status != StatusPromoted && status != StatusStopped {
+		return Trial{}, fmt.Errorf("invalid shadow trial status %q", status)
+	}
+	t, ok, err := s.Active(ctx, agentID)
+	if err != nil {
+		return Trial{}, err
+	}
+	if !ok {
+		return Trial{}, ErrNoActiveTrial
+	}
+	now := s.now()
+	if _, err := s.db.ExecContext(ctx, `UPDATE shadow_trials SET status = ?, ended_at = ? WHERE id = ?`, status, now.Format(state.TimeLayout), t.ID); err != nil {
+		return Trial{}, fmt.Errorf("end shadow trial: %w", err)
+	}
+	t.Status = status
+	t.EndedAt = &now
+	return t, nil
+}
+
+// StartRun records that the turn llmTaskID with input is being mirrored to
+// trial.
+func (s *Store) StartRun(ctx context.Context, trial Trial, llmTaskID, input string) (Run, error) {
+	run := Run{
+		ID:          s.newIDFn(),
+		TrialID:     trial.ID,
+		AgentID:     trial.AgentID,
+		LLMTaskID:   llmTaskID,
+		Input:       input,
+		ShadowTools: []string{},
+		CreatedAt:   s.now(),
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO shadow_runs (id, trial_id, agent_id, llm_task_id, input, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, run.ID, run.TrialID, run.AgentID, run.LLMTaskID, run.Input, run.CreatedAt.Format(state.TimeLayout)); err != nil {
+		return Run{}, fmt.Errorf("insert shadow run: %w", err)
+	}
+	return run, nil
+}
+
+// RecordPrimary stores what the live generation produced for runID.
+func (s *Store) RecordPrimary(ctx context.Context, runID, output, errText string) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE shadow_runs SET primary_output = ?, primary_error = ? WHERE id = ?
+	`, output, errText, runID); err != nil {
+		return fmt.Errorf("record primary output: %w", err)
+	}
+	return nil
+}
+
+// RecordShadow stores what the candidate generation produced for runID,
+// including the tools it tried to call.
+func (s *Store) RecordShadow(ctx context.Context, runID, output, errText string, tools []string, duration time.Duration) error {
+	if tools == nil {
+		tools = []string{}
+	}
+	toolsJSON, err := json.Marshal(tools)
+	if err != nil {
+		return fmt.Errorf("encode shadow tools: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE shadow_runs SET shadow_output = ?, shadow_error = ?, shadow_tools = ?, shadow_duration_ms = ?, shadow_done_at = ?
+		WHERE id = ?
+	`, output, errText, string(toolsJSON), duration.Milliseconds(), s.now().Format(state.TimeLayout), runID); err != nil {
+		return fmt.Errorf("record shadow output: %w", err)
+	}
+	return nil
+}
+
+// Runs returns up to limit of trialID's runs, newest first.
+func (s *Store) Runs(ctx context.Context, trialID string, limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trial_id, agent_id, llm_task_id, input, primary_output, primary_error,
+			shadow_output, shadow_error, shadow_tools, shadow_duration_ms, created_at, shadow_done_at
+		FROM shadow_runs WHERE trial_id = ? ORDER BY created_at DESC, id DESC LIMIT ?
+	`, trialID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list shadow runs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Run
+	for rows.Next() {
+		var run Run
+		var toolsJSON, createdAt string
+		var doneAt sql.NullString
+		if err := rows.Scan(&run.ID, &run.TrialID, &run.AgentID, &run.LLMTaskID, &run.Input, &run.PrimaryOutput, &run.PrimaryError,
+			&run.ShadowOutput, &run.ShadowError, &toolsJSON, &run.ShadowDurationMS, &createdAt, &doneAt); err != nil {
+			return nil, fmt.Errorf("scan shadow run: %w", err)
+		}
+		_ = json.Unmarshal([]byte(toolsJSON), &run.ShadowTools)
+		if run.ShadowTools == nil {
+			run.ShadowTools = []string{}
+		}
+		run.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		run.ShadowDoneAt = parseOptionalTime(doneAt)
+		out = append(out, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shadow runs: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Store) queryTrials(ctx context.Context, query string, args ...any) ([]Trial, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list shadow trials: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Trial
+	for rows.Next() {
+		var t Trial
+		var createdAt, endsAt string
+		var endedAt sql.NullString
+		if err := rows.Scan(&t.ID, &t.AgentID, &t.System, &t.Model, &t.Status, &createdAt, &endsAt, &endedAt); err != nil {
+			return nil, fmt.Errorf("scan shadow trial: %w", err)
+		}
+		t.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		t.EndsAt, _ = time.Parse(time.RFC3339Nano, endsAt)
+		t.EndedAt = parseOptionalTime(endedAt)
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shadow trials: %w", err)
+	}
+	return out, nil
+}
+
+func parseOptionalTime(raw sql.NullString) *time.Time {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,149 @@
+// Package share signs read-only transcript links. A token names one agent
+// and an optional time range, expires, and carries an HMAC-SHA256 signature,
+// so holders can view that slice of history without API access.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid share token")
+	ErrExpiredToken = errors.New("share token expired")
+)
+
+// Claims scope a token to an agent's history. A zero From or To leaves that
+// end of the range open.
+type Claims struct {
+	AgentID   string    `json:"agent_id"`
+	From      time.Time `json:"from,omitzero"`
+	To        time.Time `json:"to,omitzero"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// Contains reports whether t falls inside the claimed time range.
+func (c Claims) Contains(t time.Time) bool {
+	if !c.From.IsZero() && t.Before(c.From) {
+		return false
+	}
+	if !c.To.IsZero() && !t.Before(c.To) {
+		return false
+	}
+	return true
+}
+
+type Signer struct {
+	secret []byte
+	nowFn  func() time.Time
+}
+
+type Option func(*Signer)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Signer) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewSigner(secret []byte, opts ...Option) *Signer {
+	s := &Signer{
+		secret: append([]byte(nil), secret...),
+		nowFn:  func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Signer) now() time.Time {
+	return s.nowFn().UTC()
+}
+
+// Sign encodes claims as "<payload>.<signature>", both base64url.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	if strings.TrimSpace(claims.AgentID) == "" {
+		return "", fmt.Errorf("agent id is required")
+	}
+	if claims.ExpiresAt.IsZero() {
+		return "", fmt.Errorf("expiry is required")
+	}
+	if !claims.From.IsZero() && !claims.To.IsZero() && !claims.From.Before(claims.To) {
+		return "", fmt.Errorf("from must be before to")
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encode claims: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), nil
+}
+
+// Verify checks the signature and expiry and returns the token's claims.
+func (s *Signer) Verify(token string) (Claims, error) {
+	encoded, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || encoded == "" || sig == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signature(encoded))) {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.AgentID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if !s.now().Before(claims.ExpiresAt) {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+func (s *Signer) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// LoadOrCreateSecret reads a hex secret from path, creating a random one on
+// first use so links survive restarts.
+func LoadOrCreateSecret(path string) ([]byte, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("decode share secret: invalid contents in %s", path)
+		}
+		return secret, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read share secret: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate share secret: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create share secret dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("write share secret: %w", err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,60 @@
+package share
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerRoundTripTamperAndExpiry(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"), WithClock(func() time.Time { return now }))
+
+	token, err := signer.Sign(Claims{
+		AgentID:   "operator",
+		From:      now.Add(-time.Hour),
+		ExpiresAt: now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.AgentID != "operator" || !claims.Contains(now) || claims.Contains(now.Add(-2*time.Hour)) {
+		t.Fatalf("unexpected claims: %#v", claims)
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	forged, _ := NewSigner([]byte("other")).Sign(Claims{AgentID: "admin", ExpiresAt: now.Add(time.Hour)})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	for _, bad := range []string{forgedPayload + "." + sig, payload + ".x", payload, ""} {
+		if _, err := signer.Verify(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected invalid token for %q, got %v", bad, err)
+		}
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := signer.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+}
+
+func TestLoadOrCreateSecretPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "share-secret")
+	first, err := LoadOrCreateSecret(path)
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	second, err := LoadOrCreateSecret(path)
+	if err != nil {
+		t.Fatalf("load secret: %v", err)
+	}
+	if len(first) != 32 || !bytes.Equal(first, second) {
+		t.Fatalf("expected persisted 32-byte secret")
+	}
+}
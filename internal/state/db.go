@@ -1,25 +1,68 @@
 package state
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/stdlib"
 	_ "modernc.org/sqlite"
 )
 
+// migrateLockID is the Postgres advisory lock held while migrating, so
+// instances starting together do not race to create the schema.
+const migrateLockID = 0x676f2d6167656e74
+
+// OpenDriver opens and migrates a database of the given driver: for SQLite
+// dsn is the file path, for Postgres a connection string or URL. Queries
+// are recorded in DefaultTelemetry.
+func OpenDriver(driverName, dsn string) (*sql.DB, Dialect, error) {
+	dialect, err := DialectFor(driverName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dialect != Postgres {
+		db, err := Open(dsn)
+		return db, dialect, err
+	}
+	if strings.TrimSpace(dsn) == "" {
+		return nil, nil, fmt.Errorf("postgres requires a dsn")
+	}
+	db := sql.OpenDB(instrumentedConnector{
+		dsn:       dsn,
+		telemetry: DefaultTelemetry,
+		driver:    stdlib.GetDefaultDriver(),
+		rebind:    rebindDollar,
+	})
+	db.SetMaxOpenConns(16)
+	db.SetMaxIdleConns(4)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if err := migratePostgres(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return db, dialect, nil
+}
+
+// Open opens and migrates the database at path. Its queries are recorded
+// in DefaultTelemetry.
 func Open(path string) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
 
 	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)", path)
-	db, err := sql.Open("sqlite", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
-	}
+	db := sql.OpenDB(instrumentedConnector{dsn: dsn, telemetry: DefaultTelemetry})
 	db.SetMaxOpenConns(4)
 	db.SetMaxIdleConns(4)
 
@@ -43,6 +86,34 @@ func Open(path string) (*sql.DB, error) {
 	return db, nil
 }
 
+// migratePostgres applies the schema in one transaction under an advisory
+// lock.
+func migratePostgres(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, int64(migrateLockID)); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	for _, raw := range strings.Split(Postgres.Schema(), ";") {
+		stmt := strings.TrimSpace(raw)
+		if stmt == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migrate: %w (statement=%q)", err, stmt)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
 func Migrate(db *sql.DB) error {
 	statements := strings.Split(schemaSQL, ";")
 	for _, raw := range statements {
@@ -0,0 +1,175 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Database drivers.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// TimeLayout formats stored times at a fixed width, so they compare
+// correctly as strings.
+const TimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Dialect renders the SQL that differs between database drivers. Queries
+// are written for SQLite with ? placeholders; Postgres connections opened
+// by OpenDriver rebind the placeholders themselves, so only the expressions
+// below need a dialect.
+type Dialect interface {
+	Driver() string
+	// JSONValue extracts the value at path from a JSON text column. SQLite
+	// returns JSON numbers and booleans typed, Postgres returns text.
+	JSONValue(column string, path ...string) string
+	// JSONInt extracts the integer at path from a JSON text column.
+	JSONInt(column string, path ...string) string
+	// Time makes an RFC 3339 text expression comparable and orderable as a
+	// point in time.
+	Time(expr string) string
+	// SecondsBetween is the number of seconds from the time expression
+	// from to the time expression to.
+	SecondsBetween(from, to string) string
+	// LockRows is appended to a SELECT whose rows are read to be updated
+	// in the same transaction.
+	LockRows() string
+	// Schema is the schema in this dialect.
+	Schema() string
+}
+
+var (
+	SQLite   Dialect = sqliteDialect{}
+	Postgres Dialect = postgresDialect{}
+)
+
+// DialectFor returns the dialect of driver; empty means SQLite.
+func DialectFor(driver string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", DriverSQLite:
+		return SQLite, nil
+	case DriverPostgres, "postgresql", "pgx":
+		return Postgres, nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q", driver)
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Driver() string { return DriverSQLite }
+
+func (sqliteDialect) JSONValue(column string, path ...string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, segment := range path {
+		if isIndex(segment) {
+			b.WriteString("[" + segment + "]")
+			continue
+		}
+		b.WriteString("." + segment)
+	}
+	return fmt.Sprintf("json_extract(%s, '%s')", column, b.String())
+}
+
+func (d sqliteDialect) JSONInt(column string, path ...string) string {
+	return d.JSONValue(column, path...)
+}
+
+func (sqliteDialect) Time(expr string) string {
+	return "julianday(" + expr + ")"
+}
+
+func (sqliteDialect) SecondsBetween(from, to string) string {
+	return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400.0", to, from)
+}
+
+func (sqliteDialect) LockRows() string { return "" }
+
+func (sqliteDialect) Schema() string { return schemaSQL }
+
+type postgresDialect struct{}
+
+func (postgresDialect) Driver() string { return DriverPostgres }
+
+func (postgresDialect) JSONValue(column string, path ...string) string {
+	return fmt.Sprintf("(%s::jsonb #>> '{%s}')", column, strings.Join(path, ","))
+}
+
+func (d postgresDialect) JSONInt(column string, path ...string) string {
+	return "(" + d.JSONValue(column, path...) + ")::bigint"
+}
+
+func (postgresDialect) Time(expr string) string {
+	return "(" + expr + ")::timestamptz"
+}
+
+func (postgresDialect) SecondsBetween(from, to string) string {
+	return fmt.Sprintf("EXTRACT(EPOCH FROM ((%s)::timestamptz - (%s)::timestamptz))", to, from)
+}
+
+func (postgresDialect) LockRows() string { return " FOR UPDATE" }
+
+func (d postgresDialect) Schema() string { return postgresSchema(d) }
+
+func isIndex(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	schemaJSONExtract = regexp.MustCompile(`json_extract\((\w+), '\$\.(\w+)'\)`)
+	schemaInteger     = regexp.MustCompile(`\bINTEGER\b`)
+	schemaBlob        = regexp.MustCompile(`\bBLOB\b`)
+	schemaText        = regexp.MustCompile(`\bTEXT\b`)
+)
+
+// postgresSchema translates the SQLite schema: integers are 64-bit, blobs
+// are bytea, text sorts bytewise as in SQLite, and JSON expression indexes
+// extract text.
+func postgresSchema(d Dialect) string {
+	out := schemaJSONExtract.ReplaceAllStringFunc(schemaSQL, func(m string) string {
+		parts := schemaJSONExtract.FindStringSubmatch(m)
+		return "(" + d.JSONValue(parts[1], parts[2]) + ")"
+	})
+	out = schemaInteger.ReplaceAllString(out, "BIGINT")
+	out = schemaText.ReplaceAllString(out, `TEXT COLLATE "C"`)
+	return schemaBlob.ReplaceAllString(out, "BYTEA")
+}
+
+// rebindDollar numbers the ? placeholders of query as $1, $2, ... for
+// Postgres, leaving quoted strings and identifiers alone.
+func rebindDollar(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
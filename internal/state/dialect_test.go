@@ -0,0 +1,62 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDialectFor(t *testing.T) {
+	for driver, want := range map[string]Dialect{"": SQLite, "sqlite": SQLite, "postgres": Postgres, "PostgreSQL": Postgres, "pgx": Postgres} {
+		got, err := DialectFor(driver)
+		if err != nil || got != want {
+			t.Fatalf("DialectFor(%q) = %v, %v", driver, got, err)
+		}
+	}
+	if _, err := DialectFor("mysql"); err == nil {
+		t.Fatalf("expected an unknown driver to be rejected")
+	}
+}
+
+func TestDialectJSONValue(t *testing.T) {
+	if got := SQLite.JSONValue("payload", "items", "0", "name"); got != "json_extract(payload, '$.items[0].name')" {
+		t.Fatalf("unexpected sqlite expression %s", got)
+	}
+	if got := Postgres.JSONValue("payload", "items", "0", "name"); got != "(payload::jsonb #>> '{items,0,name}')" {
+		t.Fatalf("unexpected postgres expression %s", got)
+	}
+	if got := Postgres.JSONInt("u.payload", "input_tokens"); got != "((u.payload::jsonb #>> '{input_tokens}'))::bigint" {
+		t.Fatalf("unexpected postgres integer expression %s", got)
+	}
+}
+
+func TestRebindDollar(t *testing.T) {
+	cases := map[string]string{
+		`SELECT 1`: `SELECT 1`,
+		`SELECT * FROM tasks WHERE id = ? AND owner = ?`:     `SELECT * FROM tasks WHERE id = $1 AND owner = $2`,
+		`SELECT '?' AS q, "a?b" FROM t WHERE x = ?`:          `SELECT '?' AS q, "a?b" FROM t WHERE x = $1`,
+		`UPDATE t SET body = 'it''s ?' WHERE a = ? OR b = ?`: `UPDATE t SET body = 'it''s ?' WHERE a = $1 OR b = $2`,
+	}
+	for in, want := range cases {
+		if got := rebindDollar(in); got != want {
+			t.Fatalf("rebindDollar(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPostgresSchemaTranslation(t *testing.T) {
+	schema := Postgres.Schema()
+	for _, sqliteOnly := range []string{"json_extract", "INTEGER", "BLOB"} {
+		if strings.Contains(schema, sqliteOnly) {
+			t.Fatalf("expected %s to be translated in the postgres schema", sqliteOnly)
+		}
+	}
+	if !strings.Contains(schema, `id TEXT COLLATE "C" PRIMARY KEY`) {
+		t.Fatalf("expected text columns to sort bytewise")
+	}
+	if !strings.Contains(schema, "(metadata::jsonb #>> '{") {
+		t.Fatalf("expected json expression indexes to extract text")
+	}
+	if SQLite.Schema() != schemaSQL {
+		t.Fatalf("expected the sqlite schema unchanged")
+	}
+}
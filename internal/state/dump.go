@@ -0,0 +1,167 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dump writes every row of db as SQL inserts, one table at a time in name
+// order and rows in insertion order. Inserts name their columns, so a dump
+// still loads after columns are added to the schema.
+func Dump(ctx context.Context, db *sql.DB, w io.Writer) error {
+	tables, err := dumpTables(ctx, db)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, table := range tables {
+		if err := dumpTable(ctx, db, table, bw); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write dump: %w", err)
+	}
+	return nil
+}
+
+// Restore runs the inserts of a dump against db, which must have the
+// current schema and no conflicting rows, in one transaction. Lines
+// starting with "--" between statements are skipped.
+func Restore(ctx context.Context, db *sql.DB, r io.Reader) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin restore tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	// Tables are dumped by name, not in dependency order.
+	if _, err := tx.ExecContext(ctx, `PRAGMA defer_foreign_keys = ON`); err != nil {
+		return fmt.Errorf("defer foreign keys: %w", err)
+	}
+	// A statement ends at a line ending in ";" outside a string literal, so
+	// text values may span lines.
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var stmt strings.Builder
+	inString := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if stmt.Len() == 0 && strings.HasPrefix(line, "--") {
+			continue
+		}
+		if stmt.Len() > 0 {
+			stmt.WriteByte('\n')
+		}
+		stmt.WriteString(line)
+		inString = inString != (strings.Count(line, "'")%2 == 1)
+		if inString || !strings.HasSuffix(line, ";") {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt.String()); err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+		stmt.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read dump: %w", err)
+	}
+	if strings.TrimSpace(stmt.String()) != "" {
+		return fmt.Errorf("restore: unterminated statement")
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit restore: %w", err)
+	}
+	return nil
+}
+
+func dumpTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan table: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func dumpTable(ctx context.Context, db *sql.DB, table string, w *bufio.Writer) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM %s ORDER BY rowid`, quoteIdent(table)))
+	if err != nil {
+		return fmt.Errorf("dump %s: %w", table, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("dump %s columns: %w", table, err)
+	}
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdent(col)
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", quoteIdent(table), strings.Join(quoted, ", "))
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("dump %s row: %w", table, err)
+		}
+		_, _ = w.WriteString(prefix)
+		for i, v := range values {
+			if i > 0 {
+				_, _ = w.WriteString(", ")
+			}
+			_, _ = w.WriteString(sqlLiteral(v))
+		}
+		_, _ = w.WriteString(");\n")
+	}
+	return rows.Err()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqlLiteral(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return "NULL"
+		}
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case bool:
+		if val {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + hex.EncodeToString(val) + "'"
+	case time.Time:
+		return "'" + val.UTC().Format(time.RFC3339Nano) + "'"
+	default:
+		return "'" + strings.ReplaceAll(fmt.Sprint(val), "'", "''") + "'"
+	}
+}
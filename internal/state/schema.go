@@ -47,6 +47,22 @@ CREATE TABLE IF NOT EXISTS events (
 );
 
 CREATE INDEX IF NOT EXISTS idx_events_stream_scope_created ON events(stream, scope_type, scope_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_events_metadata_task_id ON events(stream, json_extract(metadata, '$.task_id'));
+CREATE INDEX IF NOT EXISTS idx_events_metadata_kind ON events(stream, json_extract(metadata, '$.kind'));
+CREATE INDEX IF NOT EXISTS idx_events_metadata_source ON events(stream, json_extract(metadata, '$.source'));
+
+CREATE TABLE IF NOT EXISTS stream_cursors (
+  stream TEXT NOT NULL,
+  consumer TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY(stream, consumer)
+);
+
+CREATE TABLE IF NOT EXISTS sequences (
+  key TEXT PRIMARY KEY,
+  value INTEGER NOT NULL
+);
 
 CREATE TABLE IF NOT EXISTS actions (
   id TEXT PRIMARY KEY,
@@ -57,4 +73,372 @@ CREATE TABLE IF NOT EXISTS actions (
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL
 );
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  reason TEXT,
+  starts_at TEXT NOT NULL,
+  ends_at TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_ends ON maintenance_windows(ends_at, agent_id);
+
+CREATE TABLE IF NOT EXISTS agent_documents (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  title TEXT NOT NULL,
+  content TEXT NOT NULL,
+  token_budget INTEGER NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_documents_agent ON agent_documents(agent_id, created_at);
+
+CREATE TABLE IF NOT EXISTS agent_document_chunks (
+  document_id TEXT NOT NULL,
+  seq INTEGER NOT NULL,
+  content TEXT NOT NULL,
+  PRIMARY KEY(document_id, seq),
+  FOREIGN KEY(document_id) REFERENCES agent_documents(id)
+);
+
+CREATE TABLE IF NOT EXISTS health_probe (
+  id INTEGER PRIMARY KEY,
+  checked_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agent_acl (
+  agent_id TEXT NOT NULL,
+  principal TEXT NOT NULL,
+  level TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(agent_id, principal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_acl_principal ON agent_acl(principal);
+
+CREATE TABLE IF NOT EXISTS task_callbacks (
+  task_id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TEXT,
+  last_error TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  FOREIGN KEY(task_id) REFERENCES tasks(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_callbacks_due ON task_callbacks(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS topic_subscriptions (
+  topic TEXT NOT NULL,
+  agent_id TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(topic, agent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_topic_subscriptions_agent ON topic_subscriptions(agent_id);
+
+CREATE TABLE IF NOT EXISTS shadow_trials (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  system TEXT NOT NULL,
+  model TEXT NOT NULL,
+  status TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  ends_at TEXT NOT NULL,
+  ended_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_trials_agent ON shadow_trials(agent_id, status, ends_at);
+
+CREATE TABLE IF NOT EXISTS shadow_runs (
+  id TEXT PRIMARY KEY,
+  trial_id TEXT NOT NULL,
+  agent_id TEXT NOT NULL,
+  llm_task_id TEXT NOT NULL,
+  input TEXT NOT NULL,
+  primary_output TEXT NOT NULL DEFAULT '',
+  primary_error TEXT NOT NULL DEFAULT '',
+  shadow_output TEXT NOT NULL DEFAULT '',
+  shadow_error TEXT NOT NULL DEFAULT '',
+  shadow_tools TEXT NOT NULL DEFAULT '[]',
+  shadow_duration_ms INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL,
+  shadow_done_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_runs_trial ON shadow_runs(trial_id, created_at);
+
+CREATE TABLE IF NOT EXISTS agent_labels (
+  agent_id TEXT PRIMARY KEY,
+  generation INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  topics TEXT NOT NULL DEFAULT '[]',
+  messages INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS notify_devices (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  platform TEXT NOT NULL,
+  token TEXT NOT NULL,
+  p256dh TEXT NOT NULL DEFAULT '',
+  auth TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  UNIQUE(platform, token)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notify_devices_user ON notify_devices(user_id);
+
+CREATE TABLE IF NOT EXISTS notify_preferences (
+  user_id TEXT PRIMARY KEY,
+  kinds TEXT NOT NULL DEFAULT '[]',
+  disabled INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS federation_outbox (
+  id TEXT PRIMARY KEY,
+  peer TEXT NOT NULL,
+  source TEXT NOT NULL,
+  target TEXT NOT NULL,
+  body TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TEXT NOT NULL,
+  remote_event_id TEXT NOT NULL DEFAULT '',
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_federation_outbox_due ON federation_outbox(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS questions (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  question TEXT NOT NULL,
+  options TEXT NOT NULL DEFAULT '[]',
+  source TEXT NOT NULL DEFAULT '',
+  service_id TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  context TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  answer TEXT NOT NULL DEFAULT '',
+  answer_event_id TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  answered_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_questions_agent_status ON questions(agent_id, status, created_at);
+
+CREATE TABLE IF NOT EXISTS secrets (
+  owner TEXT NOT NULL,
+  name TEXT NOT NULL,
+  value BLOB NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (owner, name)
+);
+
+CREATE TABLE IF NOT EXISTS calendar_accounts (
+  agent_id TEXT PRIMARY KEY,
+  provider TEXT NOT NULL,
+  calendar_id TEXT NOT NULL DEFAULT '',
+  url TEXT NOT NULL DEFAULT '',
+  lead_minutes INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS calendar_reminders (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  text TEXT NOT NULL,
+  event_id TEXT NOT NULL DEFAULT '',
+  remind_at TEXT NOT NULL,
+  status TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_reminders_due ON calendar_reminders(status, remind_at);
+
+CREATE TABLE IF NOT EXISTS calendar_notified (
+  agent_id TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  starts_at TEXT NOT NULL,
+  notified_at TEXT NOT NULL,
+  PRIMARY KEY (agent_id, event_id, starts_at)
+);
+
+CREATE TABLE IF NOT EXISTS github_accounts (
+  agent_id TEXT PRIMARY KEY,
+  repos TEXT NOT NULL,
+  events TEXT NOT NULL DEFAULT '[]',
+  priorities TEXT NOT NULL DEFAULT '{}',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS github_deliveries (
+  delivery_id TEXT NOT NULL,
+  agent_id TEXT NOT NULL,
+  received_at TEXT NOT NULL,
+  PRIMARY KEY (delivery_id, agent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_deliveries_received ON github_deliveries(received_at);
+
+CREATE TABLE IF NOT EXISTS artifacts (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  task_id TEXT NOT NULL DEFAULT '',
+  source TEXT NOT NULL DEFAULT '',
+  chars INTEGER NOT NULL,
+  summary TEXT NOT NULL DEFAULT '',
+  content TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_artifacts_agent ON artifacts(agent_id, created_at);
+
+CREATE TABLE IF NOT EXISTS facts (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  text TEXT NOT NULL,
+  source TEXT NOT NULL,
+  task_id TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_facts_agent ON facts(agent_id, created_at);
+
+CREATE TABLE IF NOT EXISTS memories (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  key TEXT NOT NULL,
+  text TEXT NOT NULL,
+  tags TEXT NOT NULL DEFAULT '[]',
+  embedding BLOB,
+  embedding_model TEXT NOT NULL DEFAULT '',
+  task_id TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  UNIQUE(agent_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memories_agent ON memories(agent_id, updated_at);
+
+CREATE TABLE IF NOT EXISTS memory_passages (
+  id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  task_id TEXT NOT NULL DEFAULT '',
+  role TEXT NOT NULL,
+  text TEXT NOT NULL,
+  embedding BLOB NOT NULL,
+  embedding_model TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_passages_agent ON memory_passages(agent_id, created_at);
+
+CREATE TABLE IF NOT EXISTS task_templates (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  spec TEXT NOT NULL,
+  params TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS contacts (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  data TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+  id TEXT PRIMARY KEY,
+  task_id TEXT NOT NULL,
+  cron TEXT NOT NULL,
+  timezone TEXT NOT NULL DEFAULT '',
+  text TEXT NOT NULL,
+  enabled INTEGER NOT NULL,
+  next_run_at TEXT NOT NULL DEFAULT '',
+  last_run_at TEXT NOT NULL DEFAULT '',
+  last_error TEXT NOT NULL DEFAULT '',
+  runs INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules(enabled, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_schedules_task ON schedules(task_id);
+
+CREATE TABLE IF NOT EXISTS archive_index (
+  key TEXT PRIMARY KEY,
+  kind TEXT NOT NULL,
+  stream TEXT NOT NULL DEFAULT '',
+  scope_type TEXT NOT NULL DEFAULT '',
+  scope_id TEXT NOT NULL DEFAULT '',
+  generation INTEGER NOT NULL DEFAULT 0,
+  first_at TEXT NOT NULL,
+  last_at TEXT NOT NULL,
+  items INTEGER NOT NULL,
+  bytes INTEGER NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_index_scope ON archive_index(kind, scope_id, first_at);
+
+CREATE TABLE IF NOT EXISTS llm_usage (
+  task_id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  model TEXT NOT NULL DEFAULT '',
+  day TEXT NOT NULL,
+  input_tokens INTEGER NOT NULL DEFAULT 0,
+  output_tokens INTEGER NOT NULL DEFAULT 0,
+  cached_input_tokens INTEGER NOT NULL DEFAULT 0,
+  cache_creation_input_tokens INTEGER NOT NULL DEFAULT 0,
+  cost_microusd INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_usage_day ON llm_usage(day, agent_id);
+CREATE INDEX IF NOT EXISTS idx_llm_usage_agent ON llm_usage(agent_id, day);
+
+CREATE TABLE IF NOT EXISTS turn_outcomes (
+  task_id TEXT PRIMARY KEY,
+  agent_id TEXT NOT NULL,
+  outcome TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  model TEXT NOT NULL DEFAULT '',
+  input TEXT NOT NULL DEFAULT '',
+  output TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_turn_outcomes_agent ON turn_outcomes(agent_id, created_at);
+
+CREATE TABLE IF NOT EXISTS turn_feedback (
+  task_id TEXT NOT NULL,
+  author TEXT NOT NULL DEFAULT '',
+  agent_id TEXT NOT NULL,
+  rating TEXT NOT NULL,
+  comment TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (task_id, author)
+);
+
+CREATE INDEX IF NOT EXISTS idx_turn_feedback_agent ON turn_feedback(agent_id, created_at);
 `
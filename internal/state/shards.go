@@ -0,0 +1,152 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const shardExt = ".db"
+
+// Shards opens one database file per agent under a directory. Each file has
+// the full schema, so per-agent data can be moved into it without changing
+// the queries that read it.
+type Shards struct {
+	dir string
+
+	mu  sync.Mutex
+	dbs map[string]*sql.DB
+}
+
+// OpenShards prepares dir for per-agent databases. Files are opened on
+// first use.
+func OpenShards(dir string) (*Shards, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("shard dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create shard dir: %w", err)
+	}
+	return &Shards{dir: dir, dbs: map[string]*sql.DB{}}, nil
+}
+
+// Dir returns the directory holding the shard files.
+func (s *Shards) Dir() string {
+	return s.dir
+}
+
+// ShardFor returns the database for agentID, creating it if needed.
+func (s *Shards) ShardFor(_ context.Context, agentID string) (*sql.DB, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return nil, fmt.Errorf("agent id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.dbs[agentID]; ok {
+		return db, nil
+	}
+	return s.open(agentID)
+}
+
+// Lookup returns the database for agentID if its file exists.
+func (s *Shards) Lookup(_ context.Context, agentID string) (*sql.DB, bool, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return nil, false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.dbs[agentID]; ok {
+		return db, true, nil
+	}
+	if _, err := os.Stat(s.path(agentID)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("stat shard %s: %w", agentID, err)
+	}
+	db, err := s.open(agentID)
+	if err != nil {
+		return nil, false, err
+	}
+	return db, true, nil
+}
+
+// open opens and migrates the shard for agentID. s.mu must be held.
+func (s *Shards) open(agentID string) (*sql.DB, error) {
+	db, err := Open(s.path(agentID))
+	if err != nil {
+		return nil, fmt.Errorf("open shard %s: %w", agentID, err)
+	}
+	// With hundreds of shards open, keep each one's pool small.
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(1)
+	s.dbs[agentID] = db
+	return db, nil
+}
+
+func (s *Shards) path(agentID string) string {
+	return filepath.Join(s.dir, url.PathEscape(agentID)+shardExt)
+}
+
+// AgentIDs lists the agents that have a shard file, sorted.
+func (s *Shards) AgentIDs() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read shard dir: %w", err)
+	}
+	var out []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, shardExt) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, shardExt))
+		if err != nil || id == "" {
+			continue
+		}
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// All opens and returns every shard, ordered by agent ID.
+func (s *Shards) All(ctx context.Context) ([]*sql.DB, error) {
+	ids, err := s.AgentIDs()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*sql.DB, 0, len(ids))
+	for _, id := range ids {
+		db, err := s.ShardFor(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, db)
+	}
+	return out, nil
+}
+
+// Close closes every open shard.
+func (s *Shards) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, db := range s.dbs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close shard %s: %w", id, err))
+		}
+		delete(s.dbs, id)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,584 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telemetry defaults.
+const (
+	DefaultSlowQueryThreshold = 250 * time.Millisecond
+	DefaultWriteHoldThreshold = time.Second
+
+	// telemetryTopN is how many slow queries and write holds are kept.
+	telemetryTopN = 20
+	// maxTelemetryStatements bounds the distinct statements aggregated;
+	// further statements are counted under "<other>".
+	maxTelemetryStatements = 512
+	maxStatementLen        = 240
+)
+
+// DefaultTelemetry records every database opened with Open.
+var DefaultTelemetry = NewTelemetry()
+
+// StatementStats aggregates the executions of one statement.
+type StatementStats struct {
+	SQL       string  `json:"sql"`
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors,omitempty"`
+	Busy      int64   `json:"busy,omitempty"`
+	TotalMs   float64 `json:"total_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	AverageMs float64 `json:"avg_ms"`
+	SlowCount int64   `json:"slow,omitempty"`
+}
+
+// SlowQuery is one execution that took longer than the slow threshold.
+type SlowQuery struct {
+	SQL        string    `json:"sql"`
+	DurationMs float64   `json:"duration_ms"`
+	At         time.Time `json:"at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// WriteHold is one interval during which a connection held the write lock:
+// from its first write in a transaction until commit or rollback, or a
+// single write outside one.
+type WriteHold struct {
+	DurationMs float64   `json:"duration_ms"`
+	Statements int       `json:"statements"`
+	FirstSQL   string    `json:"first_sql"`
+	At         time.Time `json:"at"`
+}
+
+// TelemetrySnapshot is the diagnostics view of Telemetry.
+type TelemetrySnapshot struct {
+	SlowQueryThresholdMs float64          `json:"slow_query_threshold_ms"`
+	WriteHoldThresholdMs float64          `json:"write_hold_threshold_ms"`
+	Queries              int64            `json:"queries"`
+	BusyErrors           int64            `json:"busy_errors"`
+	BusyRetries          int64            `json:"busy_retries"`
+	SlowQueries          []SlowQuery      `json:"slow_queries"`
+	LongestWrites        []WriteHold      `json:"longest_writes"`
+	Statements           []StatementStats `json:"statements"`
+}
+
+// Telemetry records query durations, busy errors and write lock hold
+// times for the SQLite layer, and logs a warning when a query or a write
+// hold exceeds its threshold. It is safe for concurrent use.
+type Telemetry struct {
+	mu          sync.Mutex
+	slowQuery   time.Duration
+	writeHold   time.Duration
+	logf        func(format string, args ...any)
+	nowFn       func() time.Time
+	queries     int64
+	busyErrors  int64
+	busyRetries int64
+	statements  map[string]*StatementStats
+	slowest     []SlowQuery
+	writes      []WriteHold
+}
+
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		slowQuery:  DefaultSlowQueryThreshold,
+		writeHold:  DefaultWriteHoldThreshold,
+		logf:       log.Printf,
+		nowFn:      time.Now,
+		statements: map[string]*StatementStats{},
+	}
+}
+
+// SetThresholds changes the slow query and write hold thresholds; zero
+// keeps the current value.
+func (t *Telemetry) SetThresholds(slowQuery, writeHold time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if slowQuery > 0 {
+		t.slowQuery = slowQuery
+	}
+	if writeHold > 0 {
+		t.writeHold = writeHold
+	}
+}
+
+// SetLogger replaces the function warnings are logged with.
+func (t *Telemetry) SetLogger(logf func(format string, args ...any)) {
+	if t == nil || logf == nil {
+		return
+	}
+	t.mu.Lock()
+	t.logf = logf
+	t.mu.Unlock()
+}
+
+// RecordBusyRetry counts one retry of a statement that failed with
+// SQLITE_BUSY.
+func (t *Telemetry) RecordBusyRetry() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.busyRetries++
+	t.mu.Unlock()
+}
+
+// Reset clears everything recorded so far.
+func (t *Telemetry) Reset() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queries, t.busyErrors, t.busyRetries = 0, 0, 0
+	t.statements = map[string]*StatementStats{}
+	t.slowest, t.writes = nil, nil
+}
+
+func (t *Telemetry) recordQuery(query string, elapsed time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	sqlText := normalizeSQL(query)
+	busy := IsBusyError(err)
+	t.mu.Lock()
+	t.queries++
+	stats, ok := t.statements[sqlText]
+	if !ok {
+		if len(t.statements) >= maxTelemetryStatements {
+			sqlText = "<other>"
+			stats = t.statements[sqlText]
+		}
+		if stats == nil {
+			stats = &StatementStats{SQL: sqlText}
+			t.statements[sqlText] = stats
+		}
+	}
+	ms := durationMs(elapsed)
+	stats.Count++
+	stats.TotalMs += ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		stats.Errors++
+	}
+	if busy {
+		stats.Busy++
+		t.busyErrors++
+	}
+	slow := elapsed >= t.slowQuery
+	logf, threshold := t.logf, t.slowQuery
+	if slow {
+		stats.SlowCount++
+		entry := SlowQuery{SQL: sqlText, DurationMs: ms, At: t.nowFn().UTC()}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		t.slowest = insertTopN(t.slowest, entry, func(a, b SlowQuery) bool { return a.DurationMs > b.DurationMs })
+	}
+	t.mu.Unlock()
+	if slow {
+		logf("storage: slow query duration_ms=%.1f threshold_ms=%.0f busy=%t sql=%q", ms, durationMs(threshold), busy, sqlText)
+	}
+}
+
+func (t *Telemetry) recordWriteHold(firstSQL string, statements int, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	hold := WriteHold{DurationMs: durationMs(elapsed), Statements: statements, FirstSQL: normalizeSQL(firstSQL)}
+	t.mu.Lock()
+	hold.At = t.nowFn().UTC()
+	t.writes = insertTopN(t.writes, hold, func(a, b WriteHold) bool { return a.DurationMs > b.DurationMs })
+	long := elapsed >= t.writeHold
+	logf, threshold := t.logf, t.writeHold
+	t.mu.Unlock()
+	if long {
+		logf("storage: long write hold duration_ms=%.1f threshold_ms=%.0f statements=%d first_sql=%q", hold.DurationMs, durationMs(threshold), statements, hold.FirstSQL)
+	}
+}
+
+// Snapshot returns the slowest queries and write holds, longest first,
+// and up to top statements ordered by total time; top <= 0 returns all.
+func (t *Telemetry) Snapshot(top int) TelemetrySnapshot {
+	if t == nil {
+		return TelemetrySnapshot{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := TelemetrySnapshot{
+		SlowQueryThresholdMs: durationMs(t.slowQuery),
+		WriteHoldThresholdMs: durationMs(t.writeHold),
+		Queries:              t.queries,
+		BusyErrors:           t.busyErrors,
+		BusyRetries:          t.busyRetries,
+		SlowQueries:          append([]SlowQuery{}, t.slowest...),
+		LongestWrites:        append([]WriteHold{}, t.writes...),
+		Statements:           make([]StatementStats, 0, len(t.statements)),
+	}
+	for _, stats := range t.statements {
+		s := *stats
+		s.AverageMs = s.TotalMs / float64(s.Count)
+		out.Statements = append(out.Statements, s)
+	}
+	sort.Slice(out.Statements, func(i, j int) bool {
+		if out.Statements[i].TotalMs != out.Statements[j].TotalMs {
+			return out.Statements[i].TotalMs > out.Statements[j].TotalMs
+		}
+		return out.Statements[i].SQL < out.Statements[j].SQL
+	})
+	if top > 0 && len(out.Statements) > top {
+		out.Statements = out.Statements[:top]
+	}
+	return out
+}
+
+// insertTopN inserts v into list, kept sorted by less and at most
+// telemetryTopN long.
+func insertTopN[T any](list []T, v T, less func(a, b T) bool) []T {
+	i := sort.Search(len(list), func(i int) bool { return less(v, list[i]) })
+	if i >= telemetryTopN {
+		return list
+	}
+	list = append(list, v)
+	copy(list[i+1:], list[i:])
+	list[i] = v
+	if len(list) > telemetryTopN {
+		list = list[:telemetryTopN]
+	}
+	return list
+}
+
+// IsBusyError reports whether err is SQLite reporting a locked database.
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "SQLITE_BUSY")
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// normalizeSQL collapses whitespace so the same statement aggregates
+// across call sites, and truncates long ones.
+func normalizeSQL(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxStatementLen {
+		query = query[:maxStatementLen] + "…"
+	}
+	return query
+}
+
+// isWrite reports whether query modifies the database.
+func isWrite(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexAny(query, " \t\r\n(")
+	if end < 0 {
+		end = len(query)
+	}
+	switch strings.ToUpper(query[:end]) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "UPSERT", "CREATE", "DROP", "ALTER":
+		return true
+	case "WITH":
+		upper := strings.ToUpper(query)
+		return strings.Contains(upper, "INSERT ") || strings.Contains(upper, "UPDATE ") || strings.Contains(upper, "DELETE ")
+	}
+	return false
+}
+
+var (
+	baseDriverOnce sync.Once
+	baseDriver     driver.Driver
+)
+
+// sqliteDriver returns the registered modernc driver.
+func sqliteDriver() driver.Driver {
+	baseDriverOnce.Do(func() {
+		db, err := sql.Open("sqlite", "")
+		if err != nil {
+			panic(err)
+		}
+		baseDriver = db.Driver()
+		_ = db.Close()
+	})
+	return baseDriver
+}
+
+// instrumentedConnector opens connections that report to a Telemetry,
+// SQLite ones unless driver is set. rebind, when set, rewrites each query
+// before it reaches the driver.
+type instrumentedConnector struct {
+	dsn       string
+	telemetry *Telemetry
+	driver    driver.Driver
+	rebind    func(string) string
+}
+
+func (c instrumentedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, telemetry: c.telemetry, rebind: c.rebind}, nil
+}
+
+func (c instrumentedConnector) Driver() driver.Driver {
+	if c.driver != nil {
+		return c.driver
+	}
+	return sqliteDriver()
+}
+
+// instrumentedConn times every statement on conn. database/sql uses a
+// connection from one goroutine at a time, so the write hold state needs
+// no lock.
+type instrumentedConn struct {
+	driver.Conn
+	telemetry *Telemetry
+	rebind    func(string) string
+
+	inTx       bool
+	writeStart time.Time
+	writeFirst string
+	writeCount int
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	start := time.Now()
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.telemetry.recordQuery("BEGIN", time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &instrumentedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, c.driverSQL(query))
+	} else {
+		stmt, err = c.Conn.Prepare(c.driverSQL(query))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	c.beforeStatement(query, start)
+	res, err := execer.ExecContext(ctx, c.driverSQL(query), args)
+	c.afterStatement(query, start, err)
+	return res, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	c.beforeStatement(query, start)
+	rows, err := queryer.QueryContext(ctx, c.driverSQL(query), args)
+	if err != nil {
+		c.afterStatement(query, start, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, conn: c, query: query, start: start, elapsed: time.Since(start)}, nil
+}
+
+// driverSQL is query as the driver expects it.
+func (c *instrumentedConn) driverSQL(query string) string {
+	if c.rebind == nil {
+		return query
+	}
+	return c.rebind(query)
+}
+
+// CheckNamedValue lets the driver convert its own argument types.
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// beforeStatement starts a write hold when query is the first write of a
+// transaction or a write outside one.
+func (c *instrumentedConn) beforeStatement(query string, start time.Time) {
+	if !isWrite(query) {
+		return
+	}
+	if c.writeCount == 0 {
+		c.writeStart = start
+		c.writeFirst = query
+	}
+	c.writeCount++
+}
+
+// afterStatement records query and, outside a transaction, ends the write
+// hold it started.
+func (c *instrumentedConn) afterStatement(query string, start time.Time, err error) {
+	c.telemetry.recordQuery(query, time.Since(start), err)
+	if !c.inTx {
+		c.endWriteHold()
+	}
+}
+
+func (c *instrumentedConn) endWriteHold() {
+	if c.writeCount > 0 {
+		c.telemetry.recordWriteHold(c.writeFirst, c.writeCount, time.Since(c.writeStart))
+	}
+	c.writeCount, c.writeFirst = 0, ""
+}
+
+type instrumentedTx struct {
+	driver.Tx
+	conn *instrumentedConn
+}
+
+func (t *instrumentedTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.conn.telemetry.recordQuery("COMMIT", time.Since(start), err)
+	t.conn.inTx = false
+	t.conn.endWriteHold()
+	return err
+}
+
+func (t *instrumentedTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.conn.inTx = false
+	t.conn.endWriteHold()
+	return err
+}
+
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  *instrumentedConn
+	query string
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	s.conn.beforeStatement(s.query, start)
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedToValues(args))
+	}
+	s.conn.afterStatement(s.query, start, err)
+	return res, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	s.conn.beforeStatement(s.query, start)
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedToValues(args))
+	}
+	if err != nil {
+		s.conn.afterStatement(s.query, start, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, conn: s.conn, query: s.query, start: start, elapsed: time.Since(start)}, nil
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, arg := range args {
+		out[i] = arg.Value
+	}
+	return out
+}
+
+// instrumentedRows adds the time spent stepping through results to the
+// query, but not the time the caller spends between rows.
+type instrumentedRows struct {
+	driver.Rows
+	conn    *instrumentedConn
+	query   string
+	start   time.Time
+	elapsed time.Duration
+	err     error
+	closed  bool
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.Rows.Next(dest)
+	r.elapsed += time.Since(start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.conn.telemetry.recordQuery(r.query, r.elapsed, r.err)
+		if !r.conn.inTx {
+			r.conn.endWriteHold()
+		}
+	}
+	return err
+}
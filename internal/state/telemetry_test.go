@@ -0,0 +1,183 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openInstrumented(t *testing.T, path string, telemetry *Telemetry, pragmas string) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(instrumentedConnector{dsn: path + "?" + pragmas, telemetry: telemetry})
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestTelemetryRecordsQueriesAndWriteHolds(t *testing.T) {
+	ctx := context.Background()
+	telemetry := NewTelemetry()
+	var mu sync.Mutex
+	var logs []string
+	telemetry.SetLogger(func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+	telemetry.SetThresholds(time.Nanosecond, time.Nanosecond)
+	db := openInstrumented(t, filepath.Join(t.TempDir(), "t.db"), telemetry, "_pragma=journal_mode(WAL)")
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(new(int)); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", fmt.Sprint(i)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT name
+		FROM items ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	for rows.Next() {
+	}
+	_ = rows.Close()
+
+	snap := telemetry.Snapshot(0)
+	counts := map[string]int64{}
+	for _, s := range snap.Statements {
+		counts[s.SQL] = s.Count
+	}
+	for sqlText, want := range map[string]int64{
+		"INSERT INTO items (name) VALUES (?)": 2,
+		"SELECT name FROM items ORDER BY id":  1,
+		"SELECT COUNT(*) FROM items":          1,
+		"BEGIN":                               1,
+		"COMMIT":                              1,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)": 1,
+	} {
+		if counts[sqlText] != want {
+			t.Fatalf("expected %q counted %d times, got %+v", sqlText, want, counts)
+		}
+	}
+	if snap.Queries != 7 || len(snap.SlowQueries) != 7 {
+		t.Fatalf("expected 7 queries, all slow, got %d and %d", snap.Queries, len(snap.SlowQueries))
+	}
+	for i := 1; i < len(snap.SlowQueries); i++ {
+		if snap.SlowQueries[i].DurationMs > snap.SlowQueries[i-1].DurationMs {
+			t.Fatalf("expected slow queries longest first, got %+v", snap.SlowQueries)
+		}
+	}
+	if len(snap.LongestWrites) != 2 {
+		t.Fatalf("expected the create and the transaction as write holds, got %+v", snap.LongestWrites)
+	}
+	var txHold *WriteHold
+	for i := range snap.LongestWrites {
+		if snap.LongestWrites[i].Statements == 2 {
+			txHold = &snap.LongestWrites[i]
+		}
+	}
+	if txHold == nil || txHold.FirstSQL != "INSERT INTO items (name) VALUES (?)" {
+		t.Fatalf("expected a two statement hold starting at the insert, got %+v", snap.LongestWrites)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var slow, held int
+	for _, line := range logs {
+		switch {
+		case strings.HasPrefix(line, "storage: slow query "):
+			slow++
+		case strings.HasPrefix(line, "storage: long write hold "):
+			held++
+		}
+	}
+	if slow != 7 || held != 2 {
+		t.Fatalf("expected 7 slow query and 2 write hold warnings, got %d and %d: %v", slow, held, logs)
+	}
+
+	if top := telemetry.Snapshot(2); len(top.Statements) != 2 {
+		t.Fatalf("expected the top two statements, got %d", len(top.Statements))
+	}
+	telemetry.Reset()
+	if snap := telemetry.Snapshot(0); snap.Queries != 0 || len(snap.Statements) != 0 || len(snap.LongestWrites) != 0 {
+		t.Fatalf("expected reset telemetry, got %+v", snap)
+	}
+}
+
+func TestTelemetryCountsBusyErrors(t *testing.T) {
+	ctx := context.Background()
+	telemetry := NewTelemetry()
+	path := filepath.Join(t.TempDir(), "busy.db")
+	holder := openInstrumented(t, path, telemetry, "_pragma=journal_mode(WAL)")
+	if _, err := holder.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	conn, err := holder.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("begin immediate: %v", err)
+	}
+	other := openInstrumented(t, path, telemetry, "_pragma=busy_timeout(0)")
+	if _, err := other.ExecContext(ctx, "INSERT INTO items DEFAULT VALUES"); !IsBusyError(err) {
+		t.Fatalf("expected a busy error, got %v", err)
+	}
+	telemetry.RecordBusyRetry()
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	snap := telemetry.Snapshot(0)
+	if snap.BusyErrors != 1 || snap.BusyRetries != 1 {
+		t.Fatalf("expected one busy error and retry, got %d and %d", snap.BusyErrors, snap.BusyRetries)
+	}
+	for _, s := range snap.Statements {
+		if s.SQL == "INSERT INTO items DEFAULT VALUES" && (s.Busy != 1 || s.Errors != 1) {
+			t.Fatalf("expected the insert marked busy, got %+v", s)
+		}
+	}
+}
+
+func TestInsertTopNKeepsLongest(t *testing.T) {
+	var list []SlowQuery
+	for i := 0; i < telemetryTopN+5; i++ {
+		list = insertTopN(list, SlowQuery{DurationMs: float64(i)}, func(a, b SlowQuery) bool { return a.DurationMs > b.DurationMs })
+	}
+	if len(list) != telemetryTopN || list[0].DurationMs != telemetryTopN+4 || list[len(list)-1].DurationMs != 5 {
+		t.Fatalf("unexpected top list %+v", list)
+	}
+}
+
+func TestIsWrite(t *testing.T) {
+	for query, want := range map[string]bool{
+		"INSERT INTO x VALUES (1)":             true,
+		"\n\t\tupdate x SET a = 1":             true,
+		"DELETE FROM x":                        true,
+		"WITH c AS (SELECT 1) DELETE FROM x":   true,
+		"SELECT * FROM x":                      false,
+		"WITH c AS (SELECT 1) SELECT * FROM c": false,
+		"PRAGMA journal_mode = WAL":            false,
+		"BEGIN IMMEDIATE":                      false,
+	} {
+		if got := isWrite(query); got != want {
+			t.Fatalf("isWrite(%q) = %t, want %t", query, got, want)
+		}
+	}
+}
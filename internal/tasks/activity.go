@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Activity summarizes what an owner's tasks did in a time window.
+type Activity struct {
+	// Turns counts llm tasks started in the window.
+	Turns     int `json:"turns"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// Failures lists the most recent failed tasks, newest first.
+	Failures []FailedTask `json:"failures,omitempty"`
+	Usage    TokenUsage   `json:"usage"`
+}
+
+type FailedTask struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// TokenUsage sums the llm_usage updates recorded on llm tasks.
+type TokenUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CachedInputTokens        int64 `json:"cached_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	// CostMicroUSD is the estimated cost in millionths of a US dollar, for
+	// turns on models with a known price.
+	CostMicroUSD int64 `json:"cost_microusd"`
+}
+
+const activityFailureLimit = 5
+
+// Activity reports on tasks owned by owner within [from, to). Non-llm tasks
+// count once they reach a terminal status in the window; llm tasks count as
+// turns when created in it.
+func (m *Manager) Activity(ctx context.Context, owner string, from, to time.Time) (Activity, error) {
+	var out Activity
+	fromStr := from.UTC().Format(time.RFC3339Nano)
+	toStr := to.UTC().Format(time.RFC3339Nano)
+	d := m.dialect
+	inWindow := func(column string) string {
+		return d.Time(column) + " >= " + d.Time("?") + " AND " + d.Time(column) + " < " + d.Time("?")
+	}
+
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tasks
+		WHERE owner = ? AND type = 'llm'
+		AND `+inWindow("created_at"), owner, fromStr, toStr).Scan(&out.Turns); err != nil {
+		return Activity{}, fmt.Errorf("count turns: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT status, COUNT(*) FROM tasks
+		WHERE owner = ? AND type != 'llm' AND status IN (?, ?, ?, ?)
+		AND `+inWindow("updated_at")+`
+		GROUP BY status
+	`, owner, StatusCompleted, StatusFailed, StatusCancelled, StatusLimitExceeded, fromStr, toStr)
+	if err != nil {
+		return Activity{}, fmt.Errorf("count finished tasks: %w", err)
+	}
+	for rows.Next() {
+		var status Status
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			rows.Close()
+			return Activity{}, fmt.Errorf("scan task count: %w", err)
+		}
+		switch status {
+		case StatusCompleted:
+			out.Completed = n
+		case StatusFailed, StatusLimitExceeded:
+			out.Failed += n
+		case StatusCancelled:
+			out.Cancelled = n
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Activity{}, fmt.Errorf("iterate task counts: %w", err)
+	}
+
+	rows, err = m.db.QueryContext(ctx, `
+		SELECT id, type, error, updated_at FROM tasks
+		WHERE owner = ? AND status IN (?, ?)
+		AND `+inWindow("updated_at")+`
+		ORDER BY `+d.Time("updated_at")+` DESC, id DESC
+		LIMIT ?
+	`, owner, StatusFailed, StatusLimitExceeded, fromStr, toStr, activityFailureLimit)
+	if err != nil {
+		return Activity{}, fmt.Errorf("list failed tasks: %w", err)
+	}
+	for rows.Next() {
+		var failed FailedTask
+		var errorStr sql.NullString
+		var atStr string
+		if err := rows.Scan(&failed.ID, &failed.Type, &errorStr, &atStr); err != nil {
+			rows.Close()
+			return Activity{}, fmt.Errorf("scan failed task: %w", err)
+		}
+		failed.Error = errorStr.String
+		failed.At, _ = time.Parse(time.RFC3339Nano, atStr)
+		out.Failures = append(out.Failures, failed)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Activity{}, fmt.Errorf("iterate failed tasks: %w", err)
+	}
+
+	if out.Usage, err = m.Usage(ctx, owner, from, to); err != nil {
+		return Activity{}, err
+	}
+	return out, nil
+}
+
+// Usage sums the llm_usage updates recorded on tasks owned by owner within
+// [from, to).
+func (m *Manager) Usage(ctx context.Context, owner string, from, to time.Time) (TokenUsage, error) {
+	var out TokenUsage
+	d := m.dialect
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(`+d.JSONInt("u.payload", "input_tokens")+`), 0),
+			COALESCE(SUM(`+d.JSONInt("u.payload", "output_tokens")+`), 0),
+			COALESCE(SUM(`+d.JSONInt("u.payload", "cached_input_tokens")+`), 0),
+			COALESCE(SUM(`+d.JSONInt("u.payload", "cache_creation_input_tokens")+`), 0),
+			COALESCE(SUM(`+d.JSONInt("u.payload", "cost_microusd")+`), 0)
+		FROM task_updates u JOIN tasks t ON t.id = u.task_id
+		WHERE t.owner = ? AND u.kind = 'llm_usage'
+		AND `+d.Time("u.created_at")+` >= `+d.Time("?")+` AND `+d.Time("u.created_at")+` < `+d.Time("?"),
+		owner, from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano)).Scan(
+		&out.InputTokens,
+		&out.OutputTokens,
+		&out.CachedInputTokens,
+		&out.CacheCreationInputTokens,
+		&out.CostMicroUSD,
+	); err != nil {
+		return TokenUsage{}, fmt.Errorf("sum token usage: %w", err)
+	}
+	return out, nil
+}
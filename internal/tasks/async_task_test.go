@@ -2,6 +2,7 @@ package tasks_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -829,3 +830,55 @@ func TestAwaitIgnoresContextSuppressedWakeEvent(t *testing.T) {
 		t.Fatalf("expected suppressed wake event to be acked")
 	}
 }
+
+func TestAwaitWakesOnGenerationChange(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := tasks.NewManager(db, bus)
+	ctx := context.Background()
+
+	agent, err := mgr.Spawn(ctx, tasks.Spec{
+		ID:       "agent-b",
+		Type:     "agent",
+		Metadata: map[string]any{"notify_target": "agent-a"},
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if got := tasks.Generation(agent); got != 1 {
+		t.Fatalf("expected generation 1 before compaction, got %d", got)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mgr.Await(ctx, agent.ID, 2*time.Second)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := mgr.RecordGenerationChange(ctx, agent.ID, 1, 2, "too long"); err != nil {
+		t.Fatalf("record generation change: %v", err)
+	}
+
+	err = <-done
+	wakeErr, ok := tasks.AsWakeError(err)
+	if !ok {
+		t.Fatalf("expected wake error, got %v", err)
+	}
+	if wakeErr.Event.ScopeID != "agent-a" || wakeErr.Event.Metadata["task_kind"] != tasks.GenerationChangedKind {
+		t.Fatalf("expected generation_changed wake for agent-a, got %+v", wakeErr.Event)
+	}
+	if fmt.Sprint(wakeErr.Event.Payload["generation"]) != "2" || fmt.Sprint(wakeErr.Event.Payload["previous_generation"]) != "1" {
+		t.Fatalf("unexpected payload %+v", wakeErr.Event.Payload)
+	}
+
+	updated, err := mgr.Get(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got := tasks.Generation(updated); got != 2 {
+		t.Fatalf("expected generation 2 after compaction, got %d", got)
+	}
+}
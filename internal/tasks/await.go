@@ -0,0 +1,196 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+)
+
+// AwaitOptions refines AwaitTasks. The zero value returns when any task
+// reaches a terminal status, like AwaitAny.
+type AwaitOptions struct {
+	// All waits for every task to reach a terminal status.
+	All bool
+	// UpdateKinds also returns on the first update of one of these kinds
+	// recorded after the await began (e.g. "stdout").
+	UpdateKinds []string
+}
+
+type AwaitTasksResult struct {
+	Tasks        []Task
+	CompletedIDs []string
+	PendingIDs   []string
+	Update       *Update
+	// Progress holds the updates recorded for pending tasks since the await
+	// began. It is only filled in on timeout.
+	Progress     map[string][]Update
+	WakeEvent    *eventbus.Event
+	WakePriority string
+}
+
+const awaitProgressLimit = 20
+
+// AwaitTasks waits on several tasks at once according to opts. Wake
+// tolerance is controlled through WithMinWakePriority.
+func (m *Manager) AwaitTasks(ctx context.Context, taskIDs []string, timeout time.Duration, opts AwaitOptions) (AwaitTasksResult, error) {
+	taskIDs = uniqueContextStrings(taskIDs)
+	if len(taskIDs) == 0 {
+		return AwaitTasksResult{}, fmt.Errorf("task_ids is required")
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	kinds := map[string]struct{}{}
+	for _, kind := range opts.UpdateKinds {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds[kind] = struct{}{}
+		}
+	}
+	baselines := make(map[string]string, len(taskIDs))
+	for _, id := range taskIDs {
+		latest, found, err := m.LatestUpdate(ctx, id, "")
+		if err != nil {
+			return AwaitTasksResult{}, err
+		}
+		if found {
+			baselines[id] = latest.ID
+		}
+	}
+
+	pollInterval := 500 * time.Millisecond
+	if len(kinds) > 0 {
+		pollInterval = 100 * time.Millisecond
+	}
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+
+	var sub <-chan eventbus.Event
+	if m.bus != nil {
+		streams := append([]string{}, wakeStreams...)
+		sub = m.bus.Subscribe(ctx, streams)
+	}
+	ignoredWakeEventIDs := IgnoredWakeEventIDsFromContext(ctx)
+	// Completion wakes for tasks that already finished are left unread for the
+	// agent while waiting on the rest.
+	deferred := map[string]struct{}{}
+
+	for {
+		result := AwaitTasksResult{}
+		targets := map[string]struct{}{}
+		for _, id := range taskIDs {
+			task, err := m.Get(ctx, id)
+			if err != nil {
+				return AwaitTasksResult{}, err
+			}
+			result.Tasks = append(result.Tasks, task)
+			if IsTerminalStatus(task.Status) {
+				result.CompletedIDs = append(result.CompletedIDs, id)
+				continue
+			}
+			result.PendingIDs = append(result.PendingIDs, id)
+			for target := range awaitTargetsForTask(task) {
+				targets[target] = struct{}{}
+			}
+		}
+		if len(result.PendingIDs) == 0 || (!opts.All && len(result.CompletedIDs) > 0) {
+			return result, nil
+		}
+
+		if len(kinds) > 0 {
+			for _, id := range result.PendingIDs {
+				updates, err := m.ListUpdatesSince(ctx, id, baselines[id], "", 50)
+				if err != nil {
+					return result, err
+				}
+				for i := range updates {
+					if _, ok := kinds[updates[i].Kind]; ok {
+						result.Update = &updates[i]
+						return result, nil
+					}
+				}
+			}
+		}
+
+		if m.bus != nil {
+			reader := awaitReaderForTargets(targets)
+			evt, priority, ok, err := m.nextUnreadWakeEvent(ctx, targets, reader, 25, deferred)
+			if err != nil {
+				return result, err
+			}
+			if ok {
+				if shouldIgnoreWakeEvent(evt, ignoredWakeEventIDs) {
+					_ = m.bus.Ack(ctx, evt.Stream, []string{evt.ID}, reader)
+					continue
+				}
+				if isCompletionWakeFor(evt, result.CompletedIDs) {
+					deferred[evt.ID] = struct{}{}
+					continue
+				}
+				if err := applyWakeGrace(ctx, evt); err != nil {
+					return result, err
+				}
+				_ = m.bus.Ack(ctx, evt.Stream, []string{evt.ID}, reader)
+				result.WakeEvent = &evt
+				result.WakePriority = priority
+				return result, &WakeError{Event: evt, Priority: priority}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				recordAwaitTimeouts(result.PendingIDs, timeout, m)
+				result.Progress = m.AwaitProgress(context.Background(), result.PendingIDs, baselines)
+				return result, ErrAwaitTimeout
+			}
+			return result, ctx.Err()
+		case <-pollTicker.C:
+		case _, ok := <-sub:
+			if !ok {
+				sub = nil
+			}
+		}
+	}
+}
+
+func isCompletionWakeFor(evt eventbus.Event, completedIDs []string) bool {
+	for _, id := range completedIDs {
+		if preserveTerminalTaskWakeEvent(evt, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// AwaitProgress returns the most recent updates recorded for each task after
+// its baseline update ID, skipping await bookkeeping.
+func (m *Manager) AwaitProgress(ctx context.Context, taskIDs []string, baselines map[string]string) map[string][]Update {
+	out := map[string][]Update{}
+	for _, id := range taskIDs {
+		updates, err := m.ListUpdatesSince(ctx, id, baselines[id], "", 200)
+		if err != nil {
+			continue
+		}
+		filtered := updates[:0]
+		for _, upd := range updates {
+			if upd.Kind == "await_timeout" {
+				continue
+			}
+			filtered = append(filtered, upd)
+		}
+		if len(filtered) > awaitProgressLimit {
+			filtered = filtered[len(filtered)-awaitProgressLimit:]
+		}
+		if len(filtered) > 0 {
+			out[id] = filtered
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Callback asks for a signed POST to URL once the task reaches a terminal
+// status. Secret signs the payload and is never returned by the API.
+type Callback struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Callback delivery states. A callback waits until its task finishes, is
+// pending while deliveries are attempted, and ends delivered or failed.
+const (
+	CallbackWaiting   = "waiting"
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// CallbackDelivery is a callback that is due for an attempt.
+type CallbackDelivery struct {
+	TaskID   string
+	URL      string
+	Secret   string
+	Attempts int
+}
+
+func validateCallback(cb *Callback) error {
+	if cb == nil {
+		return nil
+	}
+	parsed, err := url.Parse(strings.TrimSpace(cb.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("callback url must be an absolute http(s) URL")
+	}
+	if strings.TrimSpace(cb.Secret) == "" {
+		return fmt.Errorf("callback secret is required")
+	}
+	return nil
+}
+
+func (m *Manager) insertCallback(ctx context.Context, taskID string, cb *Callback, at time.Time) error {
+	if cb == nil {
+		return nil
+	}
+	if err := execWithRetry(ctx, m.db, `
+		INSERT INTO task_callbacks (task_id, url, secret, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, taskID, strings.TrimSpace(cb.URL), cb.Secret, CallbackWaiting, at.Format(time.RFC3339Nano), at.Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("insert callback: %w", err)
+	}
+	return nil
+}
+
+// armCallback makes a finished task's callback due immediately.
+func (m *Manager) armCallback(ctx context.Context, taskID string) error {
+	now := m.now().Format(time.RFC3339Nano)
+	if err := execWithRetry(ctx, m.db, `
+		UPDATE task_callbacks SET status = ?, next_attempt_at = ?, updated_at = ?
+		WHERE task_id = ? AND status = ?
+	`, CallbackPending, now, now, taskID, CallbackWaiting); err != nil {
+		return fmt.Errorf("arm callback: %w", err)
+	}
+	return nil
+}
+
+// DueCallbacks returns pending callbacks whose next attempt is due, oldest
+// first.
+func (m *Manager) DueCallbacks(ctx context.Context, limit int) ([]CallbackDelivery, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT task_id, url, secret, attempts FROM task_callbacks
+		WHERE status = ? AND `+m.dialect.Time("next_attempt_at")+` <= `+m.dialect.Time("?")+`
+		ORDER BY `+m.dialect.Time("next_attempt_at")+` ASC
+		LIMIT ?
+	`, CallbackPending, m.now().Format(time.RFC3339Nano), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query due callbacks: %w", err)
+	}
+	defer rows.Close()
+	var out []CallbackDelivery
+	for rows.Next() {
+		var d CallbackDelivery
+		if err := rows.Scan(&d.TaskID, &d.URL, &d.Secret, &d.Attempts); err != nil {
+			return nil, fmt.Errorf("scan callback: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate callbacks: %w", err)
+	}
+	return out, nil
+}
+
+// RecordCallbackAttempt stores the outcome of one delivery attempt. A nil
+// deliveryErr marks the callback delivered; otherwise it is retried at
+// retryAt, or marked failed when retryAt is zero.
+func (m *Manager) RecordCallbackAttempt(ctx context.Context, taskID string, deliveryErr error, retryAt time.Time) error {
+	now := m.now()
+	status := CallbackDelivered
+	var lastError, nextAttempt sql.NullString
+	if deliveryErr != nil {
+		lastError = sql.NullString{String: deliveryErr.Error(), Valid: true}
+		status = CallbackFailed
+		if !retryAt.IsZero() {
+			status = CallbackPending
+			nextAttempt = sql.NullString{String: retryAt.UTC().Format(time.RFC3339Nano), Valid: true}
+		}
+	}
+	if err := execWithRetry(ctx, m.db, `
+		UPDATE task_callbacks
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_error = ?, updated_at = ?
+		WHERE task_id = ?
+	`, status, nextAttempt, lastError, now.Format(time.RFC3339Nano), taskID); err != nil {
+		return fmt.Errorf("record callback attempt: %w", err)
+	}
+	return nil
+}
+
+// CallbackStatus reports a task's callback state and attempt count.
+func (m *Manager) CallbackStatus(ctx context.Context, taskID string) (status string, attempts int, lastError string, err error) {
+	var lastErr sql.NullString
+	err = m.db.QueryRowContext(ctx, `
+		SELECT status, attempts, last_error FROM task_callbacks WHERE task_id = ?
+	`, taskID).Scan(&status, &attempts, &lastErr)
+	if err == sql.ErrNoRows {
+		return "", 0, "", fmt.Errorf("callback for task %s not found", taskID)
+	}
+	if err != nil {
+		return "", 0, "", fmt.Errorf("load callback: %w", err)
+	}
+	return status, attempts, lastErr.String, nil
+}
@@ -3,12 +3,16 @@ package tasks
 import (
 	"context"
 	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
 )
 
 type contextKey string
 
 const parentTaskIDKey contextKey = "parent_task_id"
 const ignoredWakeEventIDsKey contextKey = "ignored_wake_event_ids"
+const minWakePriorityKey contextKey = "min_wake_priority"
+const inheritedPriorityKey contextKey = "inherited_priority"
 
 func WithParentTaskID(ctx context.Context, taskID string) context.Context {
 	if taskID == "" {
@@ -57,6 +61,53 @@ func IgnoredWakeEventIDsFromContext(ctx context.Context) map[string]struct{} {
 	return out
 }
 
+// WithMinWakePriority makes awaits ignore wake events ranked below priority.
+// Ignored events stay unread so the agent loop still sees them later.
+func WithMinWakePriority(ctx context.Context, priority schema.Priority) context.Context {
+	if priority == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, minWakePriorityKey, priority)
+}
+
+func MinWakePriorityFromContext(ctx context.Context) schema.Priority {
+	if ctx == nil {
+		return ""
+	}
+	if val, ok := ctx.Value(minWakePriorityKey).(schema.Priority); ok {
+		return val
+	}
+	return ""
+}
+
+// WithInheritedPriority records the priority of the event that triggered the
+// current turn. Tasks spawned under ctx keep it in metadata so their results
+// are delivered at least at that priority.
+func WithInheritedPriority(ctx context.Context, priority schema.Priority) context.Context {
+	if priority == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, inheritedPriorityKey, priority)
+}
+
+func InheritedPriorityFromContext(ctx context.Context) schema.Priority {
+	if ctx == nil {
+		return ""
+	}
+	if val, ok := ctx.Value(inheritedPriorityKey).(schema.Priority); ok {
+		return val
+	}
+	return ""
+}
+
+func wakeMeetsMinPriority(ctx context.Context, priority string) bool {
+	min := MinWakePriorityFromContext(ctx)
+	if min == "" {
+		return true
+	}
+	return schema.ParsePriority(priority).Rank() <= min.Rank()
+}
+
 func uniqueContextStrings(values []string) []string {
 	if len(values) == 0 {
 		return nil
@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// GenerationChangedKind is the update kind recorded when an agent's context
+// is compacted and its history generation moves on.
+const GenerationChangedKind = "generation_changed"
+
+// RecordGenerationChange stores an agent task's new history generation in
+// its metadata and records a generation_changed update. The update is a
+// wake for the task's notify target, so an agent awaiting the task returns
+// and can handle the context reset instead of waiting on work the compacted
+// agent no longer remembers. Agents that notify themselves get no update.
+func (m *Manager) RecordGenerationChange(ctx context.Context, taskID string, previous, next int64, reason string) error {
+	task, err := m.MergeMetadata(ctx, taskID, map[string]any{"history_generation": next})
+	if err != nil {
+		return err
+	}
+	if target := schema.GetMetaString(task.Metadata, "notify_target"); target == "" || target == taskID {
+		return nil
+	}
+	payload := map[string]any{
+		"previous_generation": previous,
+		"generation":          next,
+		"priority":            string(schema.PriorityWake),
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		payload["reason"] = reason
+	}
+	return m.RecordUpdate(ctx, taskID, GenerationChangedKind, payload)
+}
+
+// Generation returns the history generation recorded on an agent or llm
+// task. Agent tasks that were never compacted are on generation 1; other
+// task types report 0.
+func Generation(task Task) int64 {
+	switch v := task.Metadata["history_generation"].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	if task.Type == "agent" {
+		return 1
+	}
+	return 0
+}
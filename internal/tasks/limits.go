@@ -0,0 +1,77 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Limits caps the resources an exec task may use. The runner enforces them
+// and reports a breach with ExceedLimit. Zero values mean no limit; a nil
+// Network leaves network access enabled.
+type Limits struct {
+	CPUSeconds     int   `json:"cpu_seconds,omitempty" description:"Maximum CPU time in seconds"`
+	MemoryMB       int   `json:"memory_mb,omitempty" description:"Maximum resident memory in megabytes"`
+	TimeoutSeconds int   `json:"timeout_seconds,omitempty" description:"Maximum wall-clock run time in seconds"`
+	Network        *bool `json:"network,omitempty" description:"Set false to run without network access"`
+}
+
+// Limit names reported in a limit_exceeded result.
+const (
+	LimitCPU     = "cpu"
+	LimitMemory  = "memory"
+	LimitTimeout = "timeout"
+	// LimitBudget ends an llm task whose agent ran out of its token or
+	// spending budget.
+	LimitBudget = "budget"
+)
+
+func (l Limits) Validate() error {
+	if l.CPUSeconds < 0 || l.MemoryMB < 0 || l.TimeoutSeconds < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l.CPUSeconds == 0 && l.MemoryMB == 0 && l.TimeoutSeconds == 0 && l.Network == nil
+}
+
+// Payload returns the limits as stored under the task payload's "limits" key.
+func (l Limits) Payload() map[string]any {
+	out := map[string]any{}
+	if l.CPUSeconds > 0 {
+		out["cpu_seconds"] = l.CPUSeconds
+	}
+	if l.MemoryMB > 0 {
+		out["memory_mb"] = l.MemoryMB
+	}
+	if l.TimeoutSeconds > 0 {
+		out["timeout_seconds"] = l.TimeoutSeconds
+	}
+	if l.Network != nil {
+		out["network"] = *l.Network
+	}
+	return out
+}
+
+// ExceedLimit ends a task that broke one of its limits. The result records
+// which limit was hit and the task's peak usage so the owner can decide how
+// to retry.
+func (m *Manager) ExceedLimit(ctx context.Context, taskID, limit, reason string, usage map[string]any) error {
+	limit = strings.TrimSpace(limit)
+	switch limit {
+	case LimitCPU, LimitMemory, LimitTimeout, LimitBudget:
+	default:
+		return fmt.Errorf("unknown limit %q", limit)
+	}
+	if strings.TrimSpace(reason) == "" {
+		reason = fmt.Sprintf("task exceeded its %s limit", limit)
+	}
+	payload := map[string]any{"error": reason, "limit": limit}
+	if len(usage) > 0 {
+		payload["usage"] = usage
+	}
+	return m.updateStatus(ctx, taskID, StatusLimitExceeded, payload, "limit_exceeded")
+}
@@ -14,6 +14,10 @@ import (
 	"github.com/flitsinc/go-agents/internal/eventbus"
 	"github.com/flitsinc/go-agents/internal/idgen"
 	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/state"
+	"github.com/flitsinc/go-agents/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Status string
@@ -24,6 +28,9 @@ const (
 	StatusCompleted Status = "completed"
 	StatusFailed    Status = "failed"
 	StatusCancelled Status = "cancelled"
+	// StatusLimitExceeded is a failure caused by the task exceeding one of
+	// its resource limits; see Limits.
+	StatusLimitExceeded Status = "limit_exceeded"
 )
 
 type Task struct {
@@ -33,6 +40,8 @@ type Task struct {
 	Owner     string         `json:"owner"`
 	ParentID  string         `json:"parent_id,omitempty"`
 	Mode      string         `json:"mode,omitempty"`
+	Priority  string         `json:"priority,omitempty"`
+	Namespace string         `json:"namespace,omitempty"`
 	Metadata  map[string]any `json:"metadata,omitempty"`
 	Payload   map[string]any `json:"payload,omitempty"`
 	Result    map[string]any `json:"result,omitempty"`
@@ -56,29 +65,41 @@ type UpdateOptions struct {
 }
 
 type Spec struct {
-	ID       string         `json:"id,omitempty"`
-	Type     string         `json:"type"`
-	Name     string         `json:"name,omitempty"`
-	Owner    string         `json:"owner"`
-	ParentID string         `json:"parent_id,omitempty"`
-	Mode     string         `json:"mode,omitempty"`
-	Metadata map[string]any `json:"metadata,omitempty"`
-	Payload  map[string]any `json:"payload,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name,omitempty"`
+	Owner    string `json:"owner"`
+	ParentID string `json:"parent_id,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	// Namespace defaults to the namespace in the context, then the
+	// parent's.
+	Namespace string         `json:"namespace,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	Payload   map[string]any `json:"payload,omitempty"`
+	// Callback is POSTed once the task reaches a terminal status.
+	Callback *Callback `json:"callback,omitempty"`
 }
 
 type ListFilter struct {
-	Type   string
-	Status Status
-	Owner  string
-	Limit  int
+	Type     string
+	Status   Status
+	Owner    string
+	ParentID string
+	// Namespace lists only that namespace's tasks; empty lists every
+	// namespace.
+	Namespace string
+	Limit     int
 }
 
 type Manager struct {
-	db  *sql.DB
-	bus *eventbus.Bus
+	db      *sql.DB
+	dialect state.Dialect
+	bus     *eventbus.Bus
 
-	nowFn   func() time.Time
-	newIDFn func(string) string
+	nowFn      func() time.Time
+	newIDFn    func(string) string
+	queueAging time.Duration
 }
 
 var ErrAwaitTimeout = errors.New("await timeout")
@@ -143,6 +164,16 @@ func WithClock(nowFn func() time.Time) Option {
 	}
 }
 
+// WithDialect sets the SQL dialect of the manager's database. The default
+// is the bus's dialect, or SQLite without a bus.
+func WithDialect(dialect state.Dialect) Option {
+	return func(m *Manager) {
+		if dialect != nil {
+			m.dialect = dialect
+		}
+	}
+}
+
 func WithIDGenerator(newIDFn func(string) string) Option {
 	return func(m *Manager) {
 		if newIDFn != nil {
@@ -153,9 +184,11 @@ func WithIDGenerator(newIDFn func(string) string) Option {
 
 func NewManager(db *sql.DB, bus *eventbus.Bus, opts ...Option) *Manager {
 	m := &Manager{
-		db:    db,
-		bus:   bus,
-		nowFn: func() time.Time { return time.Now().UTC() },
+		db:         db,
+		dialect:    state.SQLite,
+		bus:        bus,
+		nowFn:      func() time.Time { return time.Now().UTC() },
+		queueAging: DefaultQueueAging,
 		newIDFn: func(prefix string) string {
 			if prefix != "" {
 				return idgen.TaskID(db, prefix)
@@ -163,6 +196,9 @@ func NewManager(db *sql.DB, bus *eventbus.Bus, opts ...Option) *Manager {
 			return idgen.New()
 		},
 	}
+	if bus != nil {
+		m.dialect = bus.Dialect()
+	}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(m)
@@ -178,6 +214,12 @@ func (m *Manager) now() time.Time {
 	return m.nowFn().UTC()
 }
 
+// startSpan starts the span of a Manager operation on taskID. Updates are
+// recorded too often to get spans of their own.
+func startSpan(ctx context.Context, op, taskID string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "tasks."+op, attribute.String("task.id", taskID))
+}
+
 func (m *Manager) newID(prefix string) string {
 	if m.newIDFn == nil {
 		if prefix != "" {
@@ -188,7 +230,10 @@ func (m *Manager) newID(prefix string) string {
 	return m.newIDFn(prefix)
 }
 
-func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
+func (m *Manager) Spawn(ctx context.Context, spec Spec) (_ Task, err error) {
+	ctx, span := startSpan(ctx, "Spawn", spec.ID)
+	span.SetAttributes(attribute.String("task.type", spec.Type))
+	defer func() { tracing.End(span, err) }()
 	if strings.TrimSpace(spec.Type) == "" {
 		return Task{}, fmt.Errorf("task type is required")
 	}
@@ -202,11 +247,21 @@ func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
 		}
 		id = m.newID(prefix)
 	}
+	priority, err := normalizeQueuePriority(spec.Priority)
+	if err != nil {
+		return Task{}, err
+	}
+	if err := validateCallback(spec.Callback); err != nil {
+		return Task{}, err
+	}
 	createdAt := m.now()
 	metadata := map[string]any{}
 	for k, v := range spec.Metadata {
 		metadata[k] = v
 	}
+	if priority != "" {
+		metadata["queue_priority"] = priority
+	}
 	if spec.ParentID != "" {
 		if _, ok := metadata["parent_id"]; !ok {
 			metadata["parent_id"] = spec.ParentID
@@ -217,6 +272,21 @@ func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
 			metadata["mode"] = spec.Mode
 		}
 	}
+	namespace := m.spawnNamespace(ctx, spec)
+	if namespace != "" {
+		if _, ok := metadata[schema.MetaNamespace]; !ok {
+			metadata[schema.MetaNamespace] = namespace
+		}
+	}
+	if inherited := InheritedPriorityFromContext(ctx); inherited != "" {
+		if _, ok := metadata["inherited_priority"]; !ok {
+			metadata["inherited_priority"] = string(inherited)
+		}
+	}
+	// The task carries the trace it was spawned in, so whoever runs it can
+	// continue that trace.
+	metadata = tracing.Inject(ctx, metadata)
+	span.SetAttributes(attribute.String("task.id", id))
 	metadataJSON, err := encodeJSON(metadata)
 	if err != nil {
 		return Task{}, fmt.Errorf("encode metadata: %w", err)
@@ -233,6 +303,9 @@ func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
 	if err != nil {
 		return Task{}, fmt.Errorf("insert task: %w", err)
 	}
+	if err := m.insertCallback(ctx, id, spec.Callback, createdAt); err != nil {
+		return Task{}, err
+	}
 
 	task := Task{
 		ID:        id,
@@ -241,6 +314,8 @@ func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
 		Owner:     spec.Owner,
 		ParentID:  spec.ParentID,
 		Mode:      spec.Mode,
+		Priority:  queuePriorityOf(metadata),
+		Namespace: schema.GetMetaString(metadata, schema.MetaNamespace),
 		Metadata:  metadata,
 		Payload:   spec.Payload,
 		CreatedAt: createdAt,
@@ -256,20 +331,24 @@ func (m *Manager) Spawn(ctx context.Context, spec Spec) (Task, error) {
 			target = strings.TrimSpace(spec.Owner)
 		}
 		scopeType, scopeID := scopeForTarget(target)
+		eventMeta := map[string]any{
+			"kind":      "command",
+			"action":    "spawn",
+			"task_id":   id,
+			"task_type": spec.Type,
+		}
+		if task.Namespace != "" {
+			eventMeta[schema.MetaNamespace] = task.Namespace
+		}
 		_, _ = m.bus.Push(ctx, eventbus.EventInput{
 			Stream:    schema.StreamSignals,
 			ScopeType: scopeType,
 			ScopeID:   scopeID,
 			Subject:   fmt.Sprintf("Task request %s", id),
 			Body:      fmt.Sprintf("Spawn task %s (%s)", id, spec.Type),
-			Metadata: map[string]any{
-				"kind":      "command",
-				"action":    "spawn",
-				"task_id":   id,
-				"task_type": spec.Type,
-			},
-			Payload:  spec.Payload,
-			SourceID: strings.TrimSpace(agentcontext.TaskIDFromContext(ctx)),
+			Metadata:  eventMeta,
+			Payload:   spec.Payload,
+			SourceID:  strings.TrimSpace(agentcontext.TaskIDFromContext(ctx)),
 		})
 	}
 
@@ -299,6 +378,8 @@ func (m *Manager) Get(ctx context.Context, taskID string) (Task, error) {
 	task.Result = decodeJSONMap(resultStr.String)
 	task.ParentID = schema.GetMetaString(task.Metadata, "parent_id")
 	task.Mode = schema.GetMetaString(task.Metadata, "mode")
+	task.Priority = queuePriorityOf(task.Metadata)
+	task.Namespace = schema.GetMetaString(task.Metadata, schema.MetaNamespace)
 	if ownerStr.Valid {
 		task.Owner = ownerStr.String
 	}
@@ -326,6 +407,14 @@ func (m *Manager) List(ctx context.Context, filter ListFilter) ([]Task, error) {
 		clauses = append(clauses, "owner = ?")
 		args = append(args, filter.Owner)
 	}
+	if filter.ParentID != "" {
+		clauses = append(clauses, m.dialect.JSONValue("metadata", "parent_id")+" = ?")
+		args = append(args, filter.ParentID)
+	}
+	if filter.Namespace != "" {
+		clauses = append(clauses, m.dialect.JSONValue("metadata", schema.MetaNamespace)+" = ?")
+		args = append(args, filter.Namespace)
+	}
 
 	if len(clauses) > 0 {
 		query += " WHERE " + strings.Join(clauses, " AND ")
@@ -358,6 +447,8 @@ func (m *Manager) List(ctx context.Context, filter ListFilter) ([]Task, error) {
 		task.Result = decodeJSONMap(resultStr.String)
 		task.ParentID = schema.GetMetaString(task.Metadata, "parent_id")
 		task.Mode = schema.GetMetaString(task.Metadata, "mode")
+		task.Priority = queuePriorityOf(task.Metadata)
+		task.Namespace = schema.GetMetaString(task.Metadata, schema.MetaNamespace)
 		if ownerStr.Valid {
 			task.Owner = ownerStr.String
 		}
@@ -406,8 +497,13 @@ func (m *Manager) recordUpdate(ctx context.Context, taskID, kind string, payload
 	}
 
 	if m.bus != nil {
-		scopeType, scopeID := scopeForTarget(m.taskTarget(ctx, taskID, "notify_target"))
+		taskMeta, _ := m.taskMetadata(ctx, taskID)
+		scopeType, scopeID := scopeForTarget(schema.GetMetaString(taskMeta, "notify_target"))
 		priority := taskUpdatePriority(kind, payload)
+		inherited := schema.GetMetaString(taskMeta, "inherited_priority")
+		if inherited != "" && isResultUpdateKind(kind) {
+			priority = higherPriority(priority, inherited)
+		}
 		sourceID := strings.TrimSpace(agentcontext.TaskIDFromContext(ctx))
 		metadata := map[string]any{
 			"kind":      "task_update",
@@ -415,6 +511,15 @@ func (m *Manager) recordUpdate(ctx context.Context, taskID, kind string, payload
 			"task_kind": kind,
 			"priority":  priority,
 		}
+		if namespace := schema.GetMetaString(taskMeta, schema.MetaNamespace); namespace != "" {
+			metadata[schema.MetaNamespace] = namespace
+		}
+		if inherited != "" {
+			metadata["inherited_priority"] = inherited
+			if parentID := schema.GetMetaString(taskMeta, "parent_id"); parentID != "" {
+				metadata["parent_id"] = parentID
+			}
+		}
 		for key, value := range opts.EventMetadata {
 			if strings.TrimSpace(key) == "" || value == nil {
 				continue
@@ -435,7 +540,9 @@ func (m *Manager) recordUpdate(ctx context.Context, taskID, kind string, payload
 	return nil
 }
 
-func (m *Manager) MarkRunning(ctx context.Context, taskID string) error {
+func (m *Manager) MarkRunning(ctx context.Context, taskID string) (err error) {
+	ctx, span := startSpan(ctx, "MarkRunning", taskID)
+	defer func() { tracing.End(span, err) }()
 	if taskID == "" {
 		return fmt.Errorf("task_id is required")
 	}
@@ -470,15 +577,21 @@ func (m *Manager) Fail(ctx context.Context, taskID string, reason string) error
 	return m.updateStatus(ctx, taskID, StatusFailed, payload, "failed")
 }
 
-func (m *Manager) Cancel(ctx context.Context, taskID string, reason string) error {
+func (m *Manager) Cancel(ctx context.Context, taskID string, reason string) (err error) {
+	ctx, span := startSpan(ctx, "Cancel", taskID)
+	defer func() { tracing.End(span, err) }()
 	return m.cancelWithChildren(ctx, taskID, reason, false, map[string]struct{}{})
 }
 
-func (m *Manager) Kill(ctx context.Context, taskID string, reason string) error {
+func (m *Manager) Kill(ctx context.Context, taskID string, reason string) (err error) {
+	ctx, span := startSpan(ctx, "Kill", taskID)
+	defer func() { tracing.End(span, err) }()
 	return m.cancelWithChildren(ctx, taskID, reason, true, map[string]struct{}{})
 }
 
-func (m *Manager) Send(ctx context.Context, taskID string, input map[string]any) error {
+func (m *Manager) Send(ctx context.Context, taskID string, input map[string]any) (err error) {
+	ctx, span := startSpan(ctx, "Send", taskID)
+	defer func() { tracing.End(span, err) }()
 	if input == nil {
 		input = map[string]any{}
 	}
@@ -542,7 +655,9 @@ func (m *Manager) AckTaskOutput(ctx context.Context, taskID, readerID string) {
 	}
 }
 
-func (m *Manager) Await(ctx context.Context, taskID string, timeout time.Duration) (Task, error) {
+func (m *Manager) Await(ctx context.Context, taskID string, timeout time.Duration) (_ Task, err error) {
+	ctx, span := startSpan(ctx, "Await", taskID)
+	defer func() { tracing.End(span, err) }()
 	if timeout <= 0 {
 		timeout = 30 * time.Second
 	}
@@ -583,7 +698,7 @@ func (m *Manager) Await(ctx context.Context, taskID string, timeout time.Duratio
 
 		targets := awaitTargetsForTask(task)
 		reader := awaitReaderForTargets(targets)
-		if evt, priority, ok, err := m.nextUnreadWakeEvent(ctx, targets, reader, 25); err != nil {
+		if evt, priority, ok, err := m.nextUnreadWakeEvent(ctx, targets, reader, 25, nil); err != nil {
 			return task, err
 		} else if ok {
 			if shouldIgnoreWakeEvent(evt, ignoredWakeEventIDs) {
@@ -618,7 +733,7 @@ func (m *Manager) Await(ctx context.Context, taskID string, timeout time.Duratio
 				return task, ctx.Err()
 			}
 			if wake, priority := wakeInfo(evt); wake {
-				if !eventMatchesAwaitTargets(evt, targets) {
+				if !eventMatchesAwaitTargets(evt, targets) || !wakeMeetsMinPriority(ctx, priority) {
 					continue
 				}
 				if shouldIgnoreWakeEvent(evt, ignoredWakeEventIDs) {
@@ -650,7 +765,9 @@ type AwaitAnyResult struct {
 	WakePriority string
 }
 
-func (m *Manager) AwaitAny(ctx context.Context, taskIDs []string, timeout time.Duration) (AwaitAnyResult, error) {
+func (m *Manager) AwaitAny(ctx context.Context, taskIDs []string, timeout time.Duration) (_ AwaitAnyResult, err error) {
+	ctx, span := tracing.Start(ctx, "tasks.AwaitAny", attribute.StringSlice("task.ids", taskIDs))
+	defer func() { tracing.End(span, err) }()
 	if len(taskIDs) == 0 {
 		return AwaitAnyResult{}, fmt.Errorf("task_ids is required")
 	}
@@ -691,7 +808,7 @@ func (m *Manager) AwaitAny(ctx context.Context, taskIDs []string, timeout time.D
 			}
 		}
 		reader := awaitReaderForTargets(targets)
-		if evt, priority, ok, err := m.nextUnreadWakeEvent(ctx, targets, reader, 25); err != nil {
+		if evt, priority, ok, err := m.nextUnreadWakeEvent(ctx, targets, reader, 25, nil); err != nil {
 			return AwaitAnyResult{}, err
 		} else if ok {
 			if shouldIgnoreWakeEvent(evt, ignoredWakeEventIDs) {
@@ -748,7 +865,7 @@ func (m *Manager) AwaitAny(ctx context.Context, taskIDs []string, timeout time.D
 				return AwaitAnyResult{PendingIDs: pending}, ctx.Err()
 			}
 			if wake, priority := wakeInfo(evt); wake {
-				if !eventMatchesAwaitTargets(evt, targets) {
+				if !eventMatchesAwaitTargets(evt, targets) || !wakeMeetsMinPriority(ctx, priority) {
 					continue
 				}
 				if shouldIgnoreWakeEvent(evt, ignoredWakeEventIDs) {
@@ -892,7 +1009,9 @@ func (m *Manager) LatestUpdate(ctx context.Context, taskID, kind string) (Update
 	return upd, true, nil
 }
 
-func (m *Manager) ClaimQueued(ctx context.Context, taskType string, limit int) ([]Task, error) {
+func (m *Manager) ClaimQueued(ctx context.Context, taskType string, limit int) (_ []Task, err error) {
+	ctx, span := tracing.Start(ctx, "tasks.ClaimQueued", attribute.String("task.type", taskType))
+	defer func() { tracing.End(span, err) }()
 	if taskType == "" {
 		return nil, fmt.Errorf("task type is required")
 	}
@@ -908,13 +1027,23 @@ func (m *Manager) ClaimQueued(ctx context.Context, taskType string, limit int) (
 		_ = tx.Rollback()
 	}()
 
+	where := "status = ? AND type = ?"
+	args := []any{StatusQueued, taskType}
+	// Workers holding a namespaced key only take that namespace's work.
+	if namespace := agentcontext.NamespaceFromContext(ctx); namespace != "" {
+		where += " AND " + m.dialect.JSONValue("metadata", schema.MetaNamespace) + " = ?"
+		args = append(args, namespace)
+	}
+	order, orderArgs := m.claimOrder()
+	args = append(args, orderArgs...)
+	args = append(args, limit)
 	rows, err := tx.QueryContext(ctx, `
 		SELECT id, type, status, owner, created_at, updated_at, metadata, payload, result, error
 		FROM tasks
-		WHERE status = ? AND type = ?
-		ORDER BY created_at ASC
+		WHERE `+where+`
+		ORDER BY `+order+`
 		LIMIT ?
-	`, StatusQueued, taskType, limit)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query queued tasks: %w", err)
 	}
@@ -935,6 +1064,8 @@ func (m *Manager) ClaimQueued(ctx context.Context, taskType string, limit int) (
 		task.Result = decodeJSONMap(resultStr.String)
 		task.ParentID = schema.GetMetaString(task.Metadata, "parent_id")
 		task.Mode = schema.GetMetaString(task.Metadata, "mode")
+		task.Priority = queuePriorityOf(task.Metadata)
+		task.Namespace = schema.GetMetaString(task.Metadata, schema.MetaNamespace)
 		if ownerStr.Valid {
 			task.Owner = ownerStr.String
 		}
@@ -987,7 +1118,10 @@ func (m *Manager) ClaimQueued(ctx context.Context, taskType string, limit int) (
 	return claimed, nil
 }
 
-func (m *Manager) updateStatus(ctx context.Context, taskID string, status Status, payload map[string]any, kind string) error {
+func (m *Manager) updateStatus(ctx context.Context, taskID string, status Status, payload map[string]any, kind string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateStatus", taskID)
+	span.SetAttributes(attribute.String("task.status", string(status)))
+	defer func() { tracing.End(span, err) }()
 	current, err := m.currentStatus(ctx, taskID)
 	if err != nil {
 		return err
@@ -1026,6 +1160,11 @@ func (m *Manager) updateStatus(ctx context.Context, taskID string, status Status
 		return &StatusTransitionError{TaskID: taskID, From: latest, To: status}
 	}
 
+	if status != StatusRunning {
+		if err := m.armCallback(ctx, taskID); err != nil {
+			return err
+		}
+	}
 	return m.RecordUpdate(ctx, taskID, kind, payload)
 }
 
@@ -1038,6 +1177,23 @@ func (m *Manager) cancelWithChildren(ctx context.Context, taskID string, reason
 	}
 	visited[taskID] = struct{}{}
 
+	if err := m.cancelOne(ctx, taskID, reason, kill); err != nil {
+		return err
+	}
+	children, err := m.childTaskIDs(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := m.cancelWithChildren(ctx, child, reason, kill, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOne signals and cancels taskID alone, leaving its children be.
+func (m *Manager) cancelOne(ctx context.Context, taskID string, reason string, kill bool) error {
 	payload := map[string]any{"reason": reason}
 	kind := "cancelled"
 	action := "cancel"
@@ -1067,19 +1223,82 @@ func (m *Manager) cancelWithChildren(ctx context.Context, taskID string, reason
 			SourceID: strings.TrimSpace(agentcontext.TaskIDFromContext(ctx)),
 		})
 	}
-	if err := m.updateStatus(ctx, taskID, StatusCancelled, payload, kind); err != nil {
-		return err
+	return m.updateStatus(ctx, taskID, StatusCancelled, payload, kind)
+}
+
+// Descendants returns the tasks parented to taskID, directly or through
+// other tasks, with parents before their children.
+func (m *Manager) Descendants(ctx context.Context, taskID string) ([]Task, error) {
+	if taskID == "" {
+		return nil, fmt.Errorf("task_id is required")
 	}
-	children, err := m.childTaskIDs(ctx, taskID)
+	rows, err := m.db.QueryContext(ctx, `SELECT id, metadata FROM tasks ORDER BY created_at, id`)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("list tasks for descendants: %w", err)
 	}
-	for _, child := range children {
-		if err := m.cancelWithChildren(ctx, child, reason, kill, visited); err != nil {
-			return err
+	defer rows.Close()
+	children := map[string][]string{}
+	for rows.Next() {
+		var id, metadataStr string
+		if err := rows.Scan(&id, &metadataStr); err != nil {
+			return nil, fmt.Errorf("scan descendant task: %w", err)
+		}
+		if parentID := schema.GetMetaString(decodeJSONMap(metadataStr), "parent_id"); parentID != "" {
+			children[parentID] = append(children[parentID], id)
 		}
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate descendant tasks: %w", err)
+	}
+	rows.Close()
+
+	var out []Task
+	visited := map[string]struct{}{taskID: {}}
+	queue := children[taskID]
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[id]; ok {
+			continue
+		}
+		visited[id] = struct{}{}
+		task, err := m.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, task)
+		queue = append(queue, children[id]...)
+	}
+	return out, nil
+}
+
+// KillTree kills taskID and every descendant that has not finished yet,
+// parents first, and returns the IDs it killed. Unlike Kill it skips
+// finished tasks in the tree instead of stopping at them.
+func (m *Manager) KillTree(ctx context.Context, taskID string, reason string) ([]string, error) {
+	root, err := m.Get(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := m.Descendants(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	var killed []string
+	for _, task := range append([]Task{root}, descendants...) {
+		if IsTerminalStatus(task.Status) {
+			continue
+		}
+		if err := m.cancelOne(ctx, task.ID, reason, true); err != nil {
+			// The task finished after it was listed.
+			if errors.Is(err, ErrInvalidStatusTransition) {
+				continue
+			}
+			return killed, err
+		}
+		killed = append(killed, task.ID)
+	}
+	return killed, nil
 }
 
 func (m *Manager) childTaskIDs(ctx context.Context, parentID string) ([]string, error) {
@@ -1127,10 +1346,10 @@ func canTransition(from, to Status) bool {
 	}
 	switch from {
 	case StatusQueued:
-		return to == StatusRunning || to == StatusCompleted || to == StatusFailed || to == StatusCancelled
+		return to == StatusRunning || to == StatusCompleted || to == StatusFailed || to == StatusCancelled || to == StatusLimitExceeded
 	case StatusRunning:
-		return to == StatusCompleted || to == StatusFailed || to == StatusCancelled
-	case StatusCompleted, StatusFailed, StatusCancelled:
+		return to == StatusCompleted || to == StatusFailed || to == StatusCancelled || to == StatusLimitExceeded
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusLimitExceeded:
 		return false
 	default:
 		return false
@@ -1143,7 +1362,7 @@ type wakeEventRef struct {
 	CreatedAt time.Time
 }
 
-func (m *Manager) nextUnreadWakeEvent(ctx context.Context, targets map[string]struct{}, reader string, limit int) (eventbus.Event, string, bool, error) {
+func (m *Manager) nextUnreadWakeEvent(ctx context.Context, targets map[string]struct{}, reader string, limit int, skip map[string]struct{}) (eventbus.Event, string, bool, error) {
 	if m.bus == nil {
 		return eventbus.Event{}, "", false, nil
 	}
@@ -1215,7 +1434,10 @@ func (m *Manager) nextUnreadWakeEvent(ctx context.Context, targets map[string]st
 		if evt.Read {
 			continue
 		}
-		if wake, priority := wakeInfo(evt); wake && eventMatchesAwaitTargets(evt, targets) {
+		if _, ok := skip[evt.ID]; ok {
+			continue
+		}
+		if wake, priority := wakeInfo(evt); wake && eventMatchesAwaitTargets(evt, targets) && wakeMeetsMinPriority(ctx, priority) {
 			return evt, priority, true, nil
 		}
 	}
@@ -1245,6 +1467,25 @@ func (m *Manager) taskMetadata(ctx context.Context, taskID string) (map[string]a
 	return decodeJSONMap(metadataStr), nil
 }
 
+// spawnNamespace picks a new task's namespace: the spec's, else the one in
+// ctx, else its parent's.
+func (m *Manager) spawnNamespace(ctx context.Context, spec Spec) string {
+	if namespace := strings.TrimSpace(spec.Namespace); namespace != "" {
+		return namespace
+	}
+	if namespace := agentcontext.NamespaceFromContext(ctx); namespace != "" {
+		return namespace
+	}
+	if spec.ParentID == "" {
+		return ""
+	}
+	parentMeta, err := m.taskMetadata(ctx, spec.ParentID)
+	if err != nil {
+		return ""
+	}
+	return schema.GetMetaString(parentMeta, schema.MetaNamespace)
+}
+
 func scopeForTarget(target string) (string, string) {
 	if strings.TrimSpace(target) == "" {
 		return "", ""
@@ -1284,7 +1525,7 @@ func preserveTerminalTaskWakeEvent(evt eventbus.Event, taskID string) bool {
 		return false
 	}
 	switch strings.ToLower(strings.TrimSpace(schema.GetMetaString(evt.Metadata, "task_kind"))) {
-	case "completed", "failed", "cancelled", "killed":
+	case "completed", "failed", "cancelled", "killed", "limit_exceeded":
 		return true
 	default:
 		return false
@@ -1356,7 +1597,7 @@ func removeID(list []string, id string) []string {
 
 func IsTerminalStatus(status Status) bool {
 	switch status {
-	case StatusCompleted, StatusFailed, StatusCancelled:
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusLimitExceeded:
 		return true
 	default:
 		return false
@@ -1370,12 +1611,29 @@ func taskUpdatePriority(kind string, payload map[string]any) string {
 			return string(p)
 		}
 	}
-	switch strings.ToLower(strings.TrimSpace(kind)) {
-	case "completed", "failed", "cancelled", "killed":
+	if isResultUpdateKind(kind) {
 		return string(schema.PriorityWake)
+	}
+	return string(schema.PriorityNormal)
+}
+
+// isResultUpdateKind reports whether an update carries a task's final result.
+// Only these inherit the spawning turn's priority; streaming output does not.
+func isResultUpdateKind(kind string) bool {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "completed", "failed", "cancelled", "killed", "limit_exceeded":
+		return true
 	default:
-		return string(schema.PriorityNormal)
+		return false
+	}
+}
+
+func higherPriority(a, b string) string {
+	pa, pb := schema.ParsePriority(a), schema.ParsePriority(b)
+	if pb.Rank() < pa.Rank() {
+		return string(pb)
 	}
+	return string(pa)
 }
 
 func (m *Manager) firstCompletedTask(ctx context.Context, taskIDs []string) (Task, bool, []string, error) {
@@ -1468,6 +1726,7 @@ func execWithRetry(ctx context.Context, db *sql.DB, query string, args ...any) e
 		if !isBusyError(err) {
 			return err
 		}
+		state.DefaultTelemetry.RecordBusyRetry()
 		select {
 		case <-ctx.Done():
 			return err
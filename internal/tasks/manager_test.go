@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/flitsinc/go-agents/internal/agentcontext"
 	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
 	"github.com/flitsinc/go-agents/internal/testutil"
 )
 
@@ -92,6 +95,38 @@ func TestManagerLifecycleAndEvents(t *testing.T) {
 	}
 }
 
+func TestManagerScopesTasksToNamespaces(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	mgr := NewManager(db, eventbus.NewBus(db))
+	acme := agentcontext.WithNamespace(context.Background(), "acme")
+	agent, err := mgr.Spawn(acme, Spec{ID: "acme-bot", Type: "agent"})
+	if err != nil || agent.Namespace != "acme" {
+		t.Fatalf("expected the agent in the context's namespace, got %+v, %v", agent, err)
+	}
+	child, err := mgr.Spawn(context.Background(), Spec{Type: "exec", ParentID: "acme-bot"})
+	if err != nil || child.Namespace != "acme" {
+		t.Fatalf("expected the child in its parent's namespace, got %+v, %v", child, err)
+	}
+	other, err := mgr.Spawn(context.Background(), Spec{Type: "exec", Namespace: "globex"})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if got, _ := mgr.Get(context.Background(), other.ID); got.Namespace != "globex" {
+		t.Fatalf("expected the namespace stored, got %+v", got)
+	}
+
+	list, err := mgr.List(context.Background(), ListFilter{Namespace: "acme"})
+	if err != nil || len(list) != 2 {
+		t.Fatalf("expected acme's two tasks, got %d, %v", len(list), err)
+	}
+	claimed, err := mgr.ClaimQueued(agentcontext.WithNamespace(context.Background(), "globex"), "exec", 5)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != other.ID {
+		t.Fatalf("expected only globex's exec task claimed, got %+v, %v", claimed, err)
+	}
+}
+
 func TestManagerCancelKill(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -147,6 +182,57 @@ func TestManagerCancelKill(t *testing.T) {
 	}
 }
 
+func TestManagerKillTreeSkipsFinishedTasks(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := NewManager(db, bus)
+	ctx := context.Background()
+
+	spawn := func(parentID string) Task {
+		t.Helper()
+		task, err := mgr.Spawn(ctx, Spec{Type: "exec", ParentID: parentID})
+		if err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		return task
+	}
+	root := spawn("")
+	done := spawn(root.ID)
+	open := spawn(done.ID)
+	sibling := spawn(root.ID)
+	if err := mgr.Complete(ctx, done.ID, nil); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	descendants, err := mgr.Descendants(ctx, root.ID)
+	if err != nil {
+		t.Fatalf("descendants: %v", err)
+	}
+	var ids []string
+	for _, task := range descendants {
+		ids = append(ids, task.ID)
+	}
+	if want := []string{done.ID, sibling.ID, open.ID}; !slices.Equal(ids, want) {
+		t.Fatalf("expected descendants %v, got %v", want, ids)
+	}
+
+	killed, err := mgr.KillTree(ctx, root.ID, "stop")
+	if err != nil {
+		t.Fatalf("kill tree: %v", err)
+	}
+	if want := []string{root.ID, sibling.ID, open.ID}; !slices.Equal(killed, want) {
+		t.Fatalf("expected %v killed, got %v", want, killed)
+	}
+	if task, _ := mgr.Get(ctx, open.ID); task.Status != StatusCancelled {
+		t.Fatalf("expected the task under a finished one to be killed, got %s", task.Status)
+	}
+	if task, _ := mgr.Get(ctx, done.ID); task.Status != StatusCompleted {
+		t.Fatalf("expected the finished task to keep its status, got %s", task.Status)
+	}
+}
+
 func TestManagerRejectsInvalidTerminalTransition(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -177,6 +263,50 @@ func TestManagerRejectsInvalidTerminalTransition(t *testing.T) {
 	}
 }
 
+func TestExceedLimitEndsTaskWithUsage(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := NewManager(db, bus)
+	ctx := context.Background()
+
+	task, err := mgr.Spawn(ctx, Spec{Type: "exec", Owner: "tester"})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := mgr.MarkRunning(ctx, task.ID); err != nil {
+		t.Fatalf("mark running: %v", err)
+	}
+	if err := mgr.ExceedLimit(ctx, task.ID, "disk", "", nil); err == nil {
+		t.Fatalf("expected unknown limit to be rejected")
+	}
+	usage := map[string]any{"peak_memory_mb": 512.0, "cpu_seconds": 1.5}
+	if err := mgr.ExceedLimit(ctx, task.ID, LimitMemory, "", usage); err != nil {
+		t.Fatalf("exceed limit: %v", err)
+	}
+
+	current, err := mgr.Get(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("get task: %v", err)
+	}
+	if current.Status != StatusLimitExceeded || !IsTerminalStatus(current.Status) {
+		t.Fatalf("expected terminal limit_exceeded status, got %s", current.Status)
+	}
+	if current.Result["limit"] != LimitMemory || current.Error != "task exceeded its memory limit" {
+		t.Fatalf("unexpected result %v error %q", current.Result, current.Error)
+	}
+	if got, _ := current.Result["usage"].(map[string]any); got["peak_memory_mb"] != 512.0 {
+		t.Fatalf("expected peak usage in result, got %v", current.Result["usage"])
+	}
+	if err := mgr.Complete(ctx, task.ID, nil); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("expected invalid transition error, got %v", err)
+	}
+	if _, err := mgr.Retry(ctx, task.ID, RetryOptions{}); err != nil {
+		t.Fatalf("expected limit-exceeded task to be retryable: %v", err)
+	}
+}
+
 func TestMarkRunningRejectsTerminalTask(t *testing.T) {
 	db, closeFn := testutil.OpenTestDB(t)
 	defer closeFn()
@@ -198,3 +328,233 @@ func TestMarkRunningRejectsTerminalTask(t *testing.T) {
 		t.Fatalf("expected invalid transition error, got %v", err)
 	}
 }
+
+func TestManagerRetryLinksAndCapsChain(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	mgr := NewManager(db, eventbus.NewBus(db))
+	ctx := context.Background()
+
+	original, err := mgr.Spawn(ctx, Spec{
+		Type:     "exec",
+		Owner:    "agent",
+		Metadata: map[string]any{"notify_target": "agent"},
+		Payload:  map[string]any{"code": "fetch(url)", "timeout": 5},
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if _, err := mgr.Retry(ctx, original.ID, RetryOptions{}); err == nil {
+		t.Fatalf("expected retry of queued task to fail")
+	}
+	if err := mgr.Fail(ctx, original.ID, "timeout"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+
+	retry, err := mgr.Retry(ctx, original.ID, RetryOptions{
+		Payload:    map[string]any{"timeout": 30},
+		MaxRetries: 2,
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	got, err := mgr.Get(ctx, retry.ID)
+	if err != nil {
+		t.Fatalf("get retry: %v", err)
+	}
+	if got.Type != "exec" || got.Owner != "agent" || got.Status != StatusQueued {
+		t.Fatalf("unexpected retry task: %+v", got)
+	}
+	if got.Payload["code"] != "fetch(url)" || got.Payload["timeout"] != float64(30) {
+		t.Fatalf("unexpected retry payload: %v", got.Payload)
+	}
+	if got.Metadata["retry_of"] != original.ID || got.Metadata["retry_root"] != original.ID || got.Metadata["retry_attempt"] != float64(1) {
+		t.Fatalf("unexpected retry metadata: %v", got.Metadata)
+	}
+	if got.Metadata["notify_target"] != "agent" {
+		t.Fatalf("expected metadata to carry over, got %v", got.Metadata)
+	}
+	update, ok, err := mgr.LatestUpdate(ctx, original.ID, "retried")
+	if err != nil || !ok {
+		t.Fatalf("expected retried update on original: ok=%v err=%v", ok, err)
+	}
+	if update.Payload["retry_task_id"] != retry.ID {
+		t.Fatalf("unexpected retried update: %v", update.Payload)
+	}
+
+	if err := mgr.Fail(ctx, retry.ID, "timeout"); err != nil {
+		t.Fatalf("fail retry: %v", err)
+	}
+	second, err := mgr.Retry(ctx, retry.ID, RetryOptions{MaxRetries: 2})
+	if err != nil {
+		t.Fatalf("second retry: %v", err)
+	}
+	second, _ = mgr.Get(ctx, second.ID)
+	if second.Metadata["retry_root"] != original.ID || second.Metadata["retry_attempt"] != float64(2) {
+		t.Fatalf("unexpected second retry metadata: %v", second.Metadata)
+	}
+	if err := mgr.Fail(ctx, second.ID, "timeout"); err != nil {
+		t.Fatalf("fail second retry: %v", err)
+	}
+	if _, err := mgr.Retry(ctx, second.ID, RetryOptions{MaxRetries: 2}); !errors.Is(err, ErrRetryLimit) {
+		t.Fatalf("expected ErrRetryLimit, got %v", err)
+	}
+}
+
+func TestSpawnedTaskResultsInheritTurnPriority(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := NewManager(db, bus)
+	ctx := WithInheritedPriority(WithParentTaskID(context.Background(), "llm-1"), schema.PriorityInterrupt)
+
+	task, err := mgr.Spawn(ctx, Spec{
+		Type:     "exec",
+		Owner:    "agent",
+		ParentID: ParentTaskIDFromContext(ctx),
+		Metadata: map[string]any{"notify_target": "agent"},
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if task.Metadata["inherited_priority"] != "interrupt" {
+		t.Fatalf("expected inherited_priority in metadata, got %v", task.Metadata)
+	}
+
+	sub := bus.Subscribe(context.Background(), []string{"task_output"})
+	if err := mgr.RecordUpdate(context.Background(), task.ID, "stdout", map[string]any{"text": "working"}); err != nil {
+		t.Fatalf("record stdout: %v", err)
+	}
+	if err := mgr.Complete(context.Background(), task.ID, map[string]any{"ok": true}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	priorities := map[string]string{}
+	for len(priorities) < 2 {
+		select {
+		case evt := <-sub:
+			kind, _ := evt.Metadata["task_kind"].(string)
+			priority, _ := evt.Metadata["priority"].(string)
+			priorities[kind] = priority
+			if evt.Metadata["inherited_priority"] != "interrupt" || evt.Metadata["parent_id"] != "llm-1" {
+				t.Fatalf("expected inheritance metadata on %s event, got %v", kind, evt.Metadata)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for task_output events, got %v", priorities)
+		}
+	}
+	if priorities["stdout"] != "normal" {
+		t.Fatalf("expected stdout to stay normal, got %q", priorities["stdout"])
+	}
+	if priorities["completed"] != "interrupt" {
+		t.Fatalf("expected completion to inherit interrupt, got %q", priorities["completed"])
+	}
+}
+
+func TestClaimQueuedOrdersByPriorityWithAging(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	mgr := NewManager(db, nil, WithClock(func() time.Time { return now }), WithQueueAging(time.Minute))
+	ctx := context.Background()
+
+	spawn := func(id, priority string) {
+		t.Helper()
+		if _, err := mgr.Spawn(ctx, Spec{ID: id, Type: "exec", Priority: priority}); err != nil {
+			t.Fatalf("spawn %s: %v", id, err)
+		}
+	}
+	spawn("old-low", QueuePriorityLow)
+	now = now.Add(90 * time.Second)
+	spawn("batch", "")
+	spawn("urgent", QueuePriorityHigh)
+	if _, err := mgr.Spawn(ctx, Spec{Type: "exec", Priority: "critical"}); err == nil {
+		t.Fatalf("expected invalid priority to be rejected")
+	}
+
+	depths, err := mgr.QueueDepths(ctx, "exec")
+	if err != nil {
+		t.Fatalf("queue depths: %v", err)
+	}
+	if depths[QueuePriorityHigh] != 1 || depths[QueuePriorityNormal] != 1 || depths[QueuePriorityLow] != 1 {
+		t.Fatalf("unexpected depths: %#v", depths)
+	}
+
+	// old-low has aged 1.5 levels, passing the fresh normal task but not high.
+	var order []string
+	for range 3 {
+		claimed, err := mgr.ClaimQueued(ctx, "exec", 1)
+		if err != nil {
+			t.Fatalf("claim queued: %v", err)
+		}
+		if len(claimed) != 1 {
+			t.Fatalf("expected 1 claimed task, got %d", len(claimed))
+		}
+		order = append(order, claimed[0].ID)
+	}
+	if order[0] != "urgent" || order[1] != "old-low" || order[2] != "batch" {
+		t.Fatalf("unexpected claim order: %v", order)
+	}
+	if task, _ := mgr.Get(ctx, "urgent"); task.Priority != QueuePriorityHigh {
+		t.Fatalf("expected priority to round-trip, got %q", task.Priority)
+	}
+}
+
+func TestReprioritizeMovesQueuedTaskAhead(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	bus := eventbus.NewBus(db)
+	mgr := NewManager(db, bus, WithQueueAging(0))
+	ctx := context.Background()
+	for _, id := range []string{"batch-1", "batch-2", "stuck"} {
+		if _, err := mgr.Spawn(ctx, Spec{ID: id, Type: "exec", Owner: "operator", Metadata: map[string]any{"labels": map[string]any{"batch": "nightly"}}}); err != nil {
+			t.Fatalf("spawn %s: %v", id, err)
+		}
+	}
+
+	if _, err := mgr.Reprioritize(ctx, "stuck", "critical", nil); err == nil {
+		t.Fatalf("expected an invalid priority to be rejected")
+	}
+	task, err := mgr.Reprioritize(ctx, "stuck", "HIGH", map[string]any{"batch": nil, "expedited_by": "oncall"})
+	if err != nil {
+		t.Fatalf("reprioritize: %v", err)
+	}
+	labels, _ := task.Metadata["labels"].(map[string]any)
+	if task.Priority != QueuePriorityHigh || labels["expedited_by"] != "oncall" || labels["batch"] != nil {
+		t.Fatalf("unexpected task after reprioritize: %+v", task)
+	}
+
+	claimed, err := mgr.ClaimQueued(ctx, "exec", 1)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != "stuck" {
+		t.Fatalf("expected the boosted task to be claimed first, got %+v (%v)", claimed, err)
+	}
+	if _, err := mgr.Reprioritize(ctx, "stuck", QueuePriorityLow, nil); !errors.Is(err, ErrNotQueued) {
+		t.Fatalf("expected ErrNotQueued for a claimed task, got %v", err)
+	}
+
+	summaries, err := bus.List(ctx, schema.StreamSignals, eventbus.ListOptions{ScopeType: "task", ScopeID: "operator"})
+	if err != nil {
+		t.Fatalf("list signals: %v", err)
+	}
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	events, err := bus.Read(ctx, schema.StreamSignals, ids, "")
+	if err != nil {
+		t.Fatalf("read signals: %v", err)
+	}
+	found := false
+	for _, evt := range events {
+		if evt.Metadata["action"] == "reprioritize" && evt.Metadata["task_id"] == "stuck" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a reprioritize signal, got %+v", events)
+	}
+}
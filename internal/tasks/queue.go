@@ -0,0 +1,218 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/agentcontext"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// Queue priorities order ClaimQueued. An empty priority means normal.
+const (
+	QueuePriorityHigh   = "high"
+	QueuePriorityNormal = "normal"
+	QueuePriorityLow    = "low"
+)
+
+// ErrNotQueued is returned when a change only applies to queued tasks.
+var ErrNotQueued = errors.New("task is not queued")
+
+// DefaultQueueAging is how long a queued task waits before it is ranked one
+// priority level higher.
+const DefaultQueueAging = 30 * time.Second
+
+// WithQueueAging sets how long a queued task waits per priority level gained.
+// Zero disables aging, making claims strictly priority-then-FIFO.
+func WithQueueAging(d time.Duration) Option {
+	return func(m *Manager) {
+		if d >= 0 {
+			m.queueAging = d
+		}
+	}
+}
+
+func normalizeQueuePriority(priority string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(priority)); p {
+	case "":
+		return "", nil
+	case QueuePriorityHigh, QueuePriorityNormal, QueuePriorityLow:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid priority %q", priority)
+	}
+}
+
+func queuePriorityOf(metadata map[string]any) string {
+	return schema.GetMetaString(metadata, "queue_priority")
+}
+
+// claimOrder returns the ORDER BY clause for ClaimQueued and its arguments.
+// A task's rank is its priority level plus one level per queueAging spent
+// waiting; ties go to the oldest task.
+func (m *Manager) claimOrder() (string, []any) {
+	rank := `CASE ` + m.dialect.JSONValue("metadata", "queue_priority") + ` WHEN 'high' THEN 2 WHEN 'low' THEN 0 ELSE 1 END`
+	if m.queueAging <= 0 {
+		return rank + ` DESC, created_at ASC`, nil
+	}
+	aged := rank + ` + ` + m.dialect.SecondsBetween("created_at", "?") + ` / ?`
+	return aged + ` DESC, created_at ASC`, []any{m.now().Format(time.RFC3339Nano), m.queueAging.Seconds()}
+}
+
+// QueueDepths counts queued tasks of taskType by priority. An empty taskType
+// counts every type. A namespace in ctx counts only that namespace's tasks.
+func (m *Manager) QueueDepths(ctx context.Context, taskType string) (map[string]int, error) {
+	query := `
+		SELECT COALESCE(` + m.dialect.JSONValue("metadata", "queue_priority") + `, ''), COUNT(*)
+		FROM tasks
+		WHERE status = ?`
+	args := []any{StatusQueued}
+	if taskType != "" {
+		query += " AND type = ?"
+		args = append(args, taskType)
+	}
+	if namespace := agentcontext.NamespaceFromContext(ctx); namespace != "" {
+		query += " AND " + m.dialect.JSONValue("metadata", schema.MetaNamespace) + " = ?"
+		args = append(args, namespace)
+	}
+	query += " GROUP BY 1"
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query queue depths: %w", err)
+	}
+	defer rows.Close()
+
+	depths := map[string]int{
+		QueuePriorityHigh:   0,
+		QueuePriorityNormal: 0,
+		QueuePriorityLow:    0,
+	}
+	for rows.Next() {
+		var priority string
+		var count int
+		if err := rows.Scan(&priority, &count); err != nil {
+			return nil, fmt.Errorf("scan queue depth: %w", err)
+		}
+		if priority == "" {
+			priority = QueuePriorityNormal
+		}
+		depths[priority] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate queue depths: %w", err)
+	}
+	return depths, nil
+}
+
+// Reprioritize changes a queued task's priority and merges labels into its
+// "labels" metadata, where a nil value removes a label. An empty priority
+// keeps the current one. The claim queue is notified with a reprioritize
+// signal so pollers can pick the task up in its new order.
+func (m *Manager) Reprioritize(ctx context.Context, taskID, priority string, labels map[string]any) (Task, error) {
+	priority, err := normalizeQueuePriority(priority)
+	if err != nil {
+		return Task{}, err
+	}
+	task, err := m.Get(ctx, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	if task.Status != StatusQueued {
+		return Task{}, fmt.Errorf("reprioritize task %s: %w (status %s)", taskID, ErrNotQueued, task.Status)
+	}
+	previous := queuePriorityOf(task.Metadata)
+	merged := map[string]any{}
+	for k, v := range task.Metadata {
+		merged[k] = v
+	}
+	if priority != "" {
+		merged["queue_priority"] = priority
+	}
+	if len(labels) > 0 {
+		current := map[string]any{}
+		if existing, ok := merged["labels"].(map[string]any); ok {
+			for k, v := range existing {
+				current[k] = v
+			}
+		}
+		for k, v := range labels {
+			if v == nil {
+				delete(current, k)
+				continue
+			}
+			current[k] = v
+		}
+		if len(current) == 0 {
+			delete(merged, "labels")
+		} else {
+			merged["labels"] = current
+		}
+	}
+	metadataJSON, err := encodeJSON(merged)
+	if err != nil {
+		return Task{}, fmt.Errorf("encode metadata: %w", err)
+	}
+	updatedAt := m.now()
+	res, err := m.db.ExecContext(ctx, `UPDATE tasks SET metadata = ?, updated_at = ? WHERE id = ? AND status = ?`, metadataJSON, updatedAt.Format(time.RFC3339Nano), taskID, StatusQueued)
+	if err != nil {
+		return Task{}, fmt.Errorf("reprioritize task: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return Task{}, fmt.Errorf("reprioritize task rows affected: %w", err)
+	}
+	if affected == 0 {
+		// Claimed or cancelled since it was loaded.
+		current, err := m.currentStatus(ctx, taskID)
+		if err != nil {
+			return Task{}, err
+		}
+		return Task{}, fmt.Errorf("reprioritize task %s: %w (status %s)", taskID, ErrNotQueued, current)
+	}
+	task.Metadata = merged
+	task.Priority = queuePriorityOf(merged)
+	task.UpdatedAt = updatedAt
+	_ = m.RecordUpdate(ctx, taskID, "reprioritized", map[string]any{
+		"previous_priority": previous,
+		"priority":          task.Priority,
+		"labels":            merged["labels"],
+	})
+
+	if m.bus != nil {
+		target := schema.GetMetaString(merged, "input_target")
+		if target == "" {
+			target = schema.GetMetaString(merged, "notify_target")
+		}
+		if target == "" {
+			target = strings.TrimSpace(task.Owner)
+		}
+		scopeType, scopeID := scopeForTarget(target)
+		_, _ = m.bus.Push(ctx, eventbus.EventInput{
+			Stream:    schema.StreamSignals,
+			ScopeType: scopeType,
+			ScopeID:   scopeID,
+			Subject:   fmt.Sprintf("Task reprioritized %s", taskID),
+			Body:      fmt.Sprintf("Reprioritize task %s (%s) to %s", taskID, task.Type, displayPriority(task.Priority)),
+			Metadata: map[string]any{
+				"kind":      "command",
+				"action":    "reprioritize",
+				"task_id":   taskID,
+				"task_type": task.Type,
+				"priority":  displayPriority(task.Priority),
+			},
+			SourceID: strings.TrimSpace(agentcontext.TaskIDFromContext(ctx)),
+		})
+	}
+	return task, nil
+}
+
+func displayPriority(priority string) string {
+	if priority == "" {
+		return QueuePriorityNormal
+	}
+	return priority
+}
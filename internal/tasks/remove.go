@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// FinishedTrees returns up to limit top-level tasks whose whole tree
+// finished and was last updated before cutoff, oldest first. Each tree is
+// its root followed by its descendants, parents before children.
+func (m *Manager) FinishedTrees(ctx context.Context, before time.Time, limit int) ([][]Task, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT id, status, updated_at, metadata FROM tasks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks for trees: %w", err)
+	}
+	defer rows.Close()
+	type node struct {
+		finished  bool
+		updatedAt time.Time
+	}
+	nodes := map[string]node{}
+	children := map[string][]string{}
+	var roots []string
+	for rows.Next() {
+		var id, status, updatedAtStr string
+		var metadataStr sql.NullString
+		if err := rows.Scan(&id, &status, &updatedAtStr, &metadataStr); err != nil {
+			return nil, fmt.Errorf("scan tree task: %w", err)
+		}
+		updatedAt, _ := time.Parse(time.RFC3339Nano, updatedAtStr)
+		nodes[id] = node{finished: IsTerminalStatus(Status(status)), updatedAt: updatedAt}
+		if parentID := schema.GetMetaString(decodeJSONMap(metadataStr.String), "parent_id"); parentID == "" {
+			roots = append(roots, id)
+		} else {
+			children[parentID] = append(children[parentID], id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tree tasks: %w", err)
+	}
+	rows.Close()
+
+	type candidate struct {
+		ids  []string
+		last time.Time
+	}
+	var candidates []candidate
+	for _, root := range roots {
+		c := candidate{}
+		ok := true
+		visited := map[string]bool{}
+		queue := []string{root}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			n := nodes[id]
+			if !n.finished || !n.updatedAt.Before(before) {
+				ok = false
+				break
+			}
+			if n.updatedAt.After(c.last) {
+				c.last = n.updatedAt
+			}
+			c.ids = append(c.ids, id)
+			queue = append(queue, children[id]...)
+		}
+		if ok {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].last.Before(candidates[j].last) })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	out := make([][]Task, 0, len(candidates))
+	for _, c := range candidates {
+		tree := make([]Task, 0, len(c.ids))
+		for _, id := range c.ids {
+			task, err := m.Get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			tree = append(tree, task)
+		}
+		out = append(out, tree)
+	}
+	return out, nil
+}
+
+// Remove deletes tasks with their updates and callbacks, for trees that
+// were archived.
+func (m *Manager) Remove(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin remove tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	for _, id := range ids {
+		for _, query := range []string{
+			`DELETE FROM task_callbacks WHERE task_id = ?`,
+			`DELETE FROM task_updates WHERE task_id = ?`,
+			`DELETE FROM tasks WHERE id = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, query, id); err != nil {
+				return fmt.Errorf("remove task %s: %w", id, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit remove: %w", err)
+	}
+	return nil
+}
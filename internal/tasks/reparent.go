@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reparent moves a task under parentID and merges metadata into the task's
+// metadata. An empty parentID detaches the task.
+func (m *Manager) Reparent(ctx context.Context, taskID, parentID string, metadata map[string]any) (Task, error) {
+	task, err := m.Get(ctx, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	merged := map[string]any{}
+	for k, v := range task.Metadata {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	parentID = strings.TrimSpace(parentID)
+	previous := task.ParentID
+	if parentID == "" {
+		delete(merged, "parent_id")
+	} else {
+		merged["parent_id"] = parentID
+	}
+	metadataJSON, err := encodeJSON(merged)
+	if err != nil {
+		return Task{}, fmt.Errorf("encode metadata: %w", err)
+	}
+	updatedAt := m.now()
+	if err := execWithRetry(ctx, m.db, `UPDATE tasks SET metadata = ?, updated_at = ? WHERE id = ?`, metadataJSON, updatedAt.Format(time.RFC3339Nano), taskID); err != nil {
+		return Task{}, fmt.Errorf("reparent task: %w", err)
+	}
+	task.Metadata = merged
+	task.ParentID = parentID
+	task.UpdatedAt = updatedAt
+	_ = m.RecordUpdate(ctx, taskID, "reparented", map[string]any{
+		"previous_parent_id": previous,
+		"parent_id":          parentID,
+	})
+	return task, nil
+}
+
+// MergeMetadata merges metadata into the task's metadata without moving it.
+// A nil value removes its key.
+func (m *Manager) MergeMetadata(ctx context.Context, taskID string, metadata map[string]any) (Task, error) {
+	task, err := m.Get(ctx, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	merged := map[string]any{}
+	for k, v := range task.Metadata {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	metadataJSON, err := encodeJSON(merged)
+	if err != nil {
+		return Task{}, fmt.Errorf("encode metadata: %w", err)
+	}
+	updatedAt := m.now()
+	if err := execWithRetry(ctx, m.db, `UPDATE tasks SET metadata = ?, updated_at = ? WHERE id = ?`, metadataJSON, updatedAt.Format(time.RFC3339Nano), taskID); err != nil {
+		return Task{}, fmt.Errorf("update task metadata: %w", err)
+	}
+	task.Metadata = merged
+	task.UpdatedAt = updatedAt
+	return task, nil
+}
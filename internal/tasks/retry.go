@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxRetries caps how many times a task chain can be retried when
+// RetryOptions.MaxRetries is not set.
+const DefaultMaxRetries = 3
+
+var ErrRetryLimit = errors.New("retry limit reached")
+
+type RetryOptions struct {
+	// Payload entries replace the original payload's top-level keys.
+	Payload map[string]any
+	// ID sets a custom ID for the new task.
+	ID string
+	// Metadata entries replace the original task's metadata keys.
+	Metadata map[string]any
+	// ParentID overrides the original task's parent.
+	ParentID   string
+	MaxRetries int
+}
+
+// Retry spawns a copy of a failed, cancelled or limit-exceeded task. The copy records the
+// task it retries (retry_of), the first task in the chain (retry_root), and
+// its position in the chain (retry_attempt).
+func (m *Manager) Retry(ctx context.Context, taskID string, opts RetryOptions) (Task, error) {
+	original, err := m.Get(ctx, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	if original.Status != StatusFailed && original.Status != StatusCancelled && original.Status != StatusLimitExceeded {
+		return Task{}, fmt.Errorf("task %s is %s; only failed, cancelled or limit-exceeded tasks can be retried", taskID, original.Status)
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	root := strings.TrimSpace(fmt.Sprint(original.Metadata["retry_root"]))
+	if original.Metadata["retry_root"] == nil || root == "" {
+		root = original.ID
+	}
+	attempt := retryAttempt(original.Metadata) + 1
+	if attempt > maxRetries {
+		return Task{}, fmt.Errorf("retry task %s: %w (%d of %d)", taskID, ErrRetryLimit, attempt-1, maxRetries)
+	}
+
+	metadata := map[string]any{}
+	for k, v := range original.Metadata {
+		switch k {
+		case "parent_id", "mode":
+			continue
+		}
+		metadata[k] = v
+	}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	metadata["retry_of"] = original.ID
+	metadata["retry_root"] = root
+	metadata["retry_attempt"] = attempt
+
+	payload := map[string]any{}
+	for k, v := range original.Payload {
+		payload[k] = v
+	}
+	for k, v := range opts.Payload {
+		payload[k] = v
+	}
+
+	parentID := strings.TrimSpace(opts.ParentID)
+	if parentID == "" {
+		parentID = original.ParentID
+	}
+	retry, err := m.Spawn(ctx, Spec{
+		ID:       strings.TrimSpace(opts.ID),
+		Type:     original.Type,
+		Owner:    original.Owner,
+		ParentID: parentID,
+		Mode:     original.Mode,
+		Metadata: metadata,
+		Payload:  payload,
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	_ = m.RecordUpdate(ctx, original.ID, "retried", map[string]any{
+		"retry_task_id": retry.ID,
+		"retry_attempt": attempt,
+	})
+	return retry, nil
+}
+
+func retryAttempt(metadata map[string]any) int {
+	switch v := metadata["retry_attempt"].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
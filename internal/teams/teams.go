@@ -0,0 +1,66 @@
+// Package teams describes teams of agents: a supervisor agent that owns
+// worker agents and hands them assignments, at most MaxConcurrency at a
+// time. The engine runs them; this package holds the shapes shared with
+// tools and the API.
+package teams
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxWorkers caps the workers of a team.
+const MaxWorkers = 16
+
+var (
+	ErrNoTeam    = errors.New("team not found")
+	ErrQueueFull = errors.New("team queue is full")
+)
+
+// Team is a supervisor agent and the worker agents it owns. Its ID is the
+// supervisor's task ID. At most MaxConcurrency workers hold an assignment
+// at once; the rest of the assignments queue in order.
+type Team struct {
+	ID             string       `json:"id"`
+	MaxConcurrency int          `json:"max_concurrency"`
+	Workers        []Worker     `json:"workers"`
+	Queued         []Assignment `json:"queued"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// Worker is a worker with the assignment it is on, if any.
+type Worker struct {
+	ID         string      `json:"id"`
+	Assignment *Assignment `json:"assignment,omitempty"`
+}
+
+// WorkerSpec describes a worker to add to a new team. An ID naming an
+// existing agent adopts it; otherwise a new agent is spawned.
+type WorkerSpec struct {
+	ID     string `json:"id,omitempty"`
+	System string `json:"system,omitempty"`
+	Model  string `json:"model,omitempty"`
+}
+
+// Assignment is a message for a worker. To is empty until an assignment
+// for any worker is dispatched. The worker's reply goes to From. EventID is
+// the message that handed it to the worker.
+type Assignment struct {
+	ID        string    `json:"id"`
+	To        string    `json:"to,omitempty"`
+	From      string    `json:"from"`
+	Body      string    `json:"body"`
+	EventID   string    `json:"event_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+}
+
+// Output is what a worker said at the end of an assignment.
+type Output struct {
+	AssignmentID string    `json:"assignment_id"`
+	Worker       string    `json:"worker"`
+	From         string    `json:"from"`
+	Text         string    `json:"text,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	At           time.Time `json:"at"`
+}
@@ -0,0 +1,275 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+)
+
+var (
+	paramNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+	// placeholderPattern matches {{name}} and {{json name}}.
+	placeholderPattern = regexp.MustCompile(`\{\{\s*(json\s+)?([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// ArgsError lists every problem with the arguments given to a template.
+type ArgsError struct {
+	Template string
+	Problems []string
+}
+
+func (e *ArgsError) Error() string {
+	return fmt.Sprintf("template %s: %s", e.Template, strings.Join(e.Problems, "; "))
+}
+
+// Instantiate checks args against t's parameters, fills in defaults and
+// returns the spec with every placeholder substituted. A string that is
+// exactly "{{name}}" becomes the typed value; inside longer strings
+// "{{name}}" inserts the value as text and "{{json name}}" inserts it JSON
+// encoded, which quotes strings for code. The spec's metadata records the
+// template name.
+func Instantiate(t Template, args map[string]any) (tasks.Spec, error) {
+	values, err := t.resolve(args)
+	if err != nil {
+		return tasks.Spec{}, err
+	}
+	payload, _ := substitute(t.Payload, values).(map[string]any)
+	metadata, _ := substitute(t.Metadata, values).(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["template"] = t.Name
+	return tasks.Spec{
+		Type:     t.Type,
+		Mode:     t.Mode,
+		Priority: t.Priority,
+		Payload:  payload,
+		Metadata: metadata,
+	}, nil
+}
+
+func (t Template) resolve(args map[string]any) (map[string]any, error) {
+	var problems []string
+	declared := map[string]bool{}
+	values := map[string]any{}
+	for _, p := range t.Params {
+		declared[p.Name] = true
+		raw, ok := args[p.Name]
+		if !ok || raw == nil {
+			switch {
+			case p.Default != nil:
+				values[p.Name] = p.Default
+			case p.Required:
+				problems = append(problems, fmt.Sprintf("missing required parameter %q (%s)", p.Name, p.Type))
+			default:
+				values[p.Name] = zeroValue(p.Type)
+			}
+			continue
+		}
+		value, err := coerce(p, raw)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		values[p.Name] = value
+	}
+	var unknown []string
+	for name := range args {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown parameter %q", name))
+	}
+	if len(problems) > 0 {
+		return nil, &ArgsError{Template: t.Name, Problems: problems}
+	}
+	return values, nil
+}
+
+// coerce checks that v has p's type, as decoded from JSON, and returns it
+// in canonical form: integers as int64, other numbers as float64.
+func coerce(p Param, v any) (any, error) {
+	mismatch := func() error {
+		return fmt.Errorf("parameter %q must be %s %s, got %s", p.Name, article(p.Type), p.Type, jsonType(v))
+	}
+	switch p.Type {
+	case TypeString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case TypeBoolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case TypeNumber, TypeInteger:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, mismatch()
+		}
+		if p.Type == TypeNumber {
+			return f, nil
+		}
+		if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, fmt.Errorf("parameter %q must be an integer, got %v", p.Name, v)
+		}
+		return int64(f), nil
+	case TypeObject:
+		if m, ok := v.(map[string]any); ok {
+			return m, nil
+		}
+	case TypeArray:
+		if a, ok := v.([]any); ok {
+			return a, nil
+		}
+	}
+	return nil, mismatch()
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func zeroValue(typ string) any {
+	switch typ {
+	case TypeNumber:
+		return float64(0)
+	case TypeInteger:
+		return int64(0)
+	case TypeBoolean:
+		return false
+	case TypeObject:
+		return map[string]any{}
+	case TypeArray:
+		return []any{}
+	default:
+		return ""
+	}
+}
+
+func article(typ string) string {
+	if typ == TypeInteger || typ == TypeObject || typ == TypeArray {
+		return "an"
+	}
+	return "a"
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64, float32, int, int64, json.Number:
+		return "a number"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func substitute(v any, values map[string]any) any {
+	switch typed := v.(type) {
+	case string:
+		if m := placeholderPattern.FindStringSubmatch(typed); m != nil && m[0] == typed && m[1] == "" {
+			return values[m[2]]
+		}
+		return placeholderPattern.ReplaceAllStringFunc(typed, func(match string) string {
+			m := placeholderPattern.FindStringSubmatch(match)
+			if m[1] != "" {
+				data, _ := json.Marshal(values[m[2]])
+				return string(data)
+			}
+			return textValue(values[m[2]])
+		})
+	case map[string]any:
+		if typed == nil {
+			return typed
+		}
+		out := make(map[string]any, len(typed))
+		for k, item := range typed {
+			out[k] = substitute(item, values)
+		}
+		return out
+	case []any:
+		out := make([]any, len(typed))
+		for i, item := range typed {
+			out[i] = substitute(item, values)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func textValue(v any) string {
+	switch typed := v.(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(typed, 10)
+	case bool:
+		return strconv.FormatBool(typed)
+	default:
+		data, _ := json.Marshal(typed)
+		return string(data)
+	}
+}
+
+// references returns the parameter names used by placeholders in vs.
+func references(vs ...any) []string {
+	seen := map[string]bool{}
+	var out []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch typed := v.(type) {
+		case string:
+			for _, m := range placeholderPattern.FindAllStringSubmatch(typed, -1) {
+				if !seen[m[2]] {
+					seen[m[2]] = true
+					out = append(out, m[2])
+				}
+			}
+		case map[string]any:
+			for _, item := range typed {
+				walk(item)
+			}
+		case []any:
+			for _, item := range typed {
+				walk(item)
+			}
+		}
+	}
+	for _, v := range vs {
+		walk(v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,255 @@
+// Package templates keeps named task spec templates. A template is a spec
+// skeleton whose payload and metadata strings may reference typed
+// parameters; instantiating it checks the arguments, fills in defaults and
+// substitutes them into a tasks.Spec ready to spawn. Agents use templates
+// through spawn_from_template instead of rebuilding complex exec payloads
+// by hand.
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Parameter types.
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// Template is a named spec skeleton. Type, Mode and Priority are copied to
+// the spawned spec as they are; Payload and Metadata go through
+// substitution.
+type Template struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Type        string         `json:"type"`
+	Mode        string         `json:"mode,omitempty"`
+	Priority    string         `json:"priority,omitempty"`
+	Params      []Param        `json:"params"`
+	Payload     map[string]any `json:"payload,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Param is one template parameter. A required parameter has no default.
+type Param struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     any    `json:"default,omitempty"`
+}
+
+// templateSpec is the JSON stored in the spec column.
+type templateSpec struct {
+	Type     string         `json:"type"`
+	Mode     string         `json:"mode,omitempty"`
+	Priority string         `json:"priority,omitempty"`
+	Payload  map[string]any `json:"payload,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+type Store struct {
+	db    *sql.DB
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{db: db, nowFn: func() time.Time { return time.Now().UTC() }}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Put creates or replaces the template named t.Name after validating it.
+// A replaced template keeps its creation time.
+func (s *Store) Put(ctx context.Context, t Template) (Template, error) {
+	if err := t.normalize(); err != nil {
+		return Template{}, err
+	}
+	spec, err := json.Marshal(templateSpec{Type: t.Type, Mode: t.Mode, Priority: t.Priority, Payload: t.Payload, Metadata: t.Metadata})
+	if err != nil {
+		return Template{}, fmt.Errorf("encode template spec: %w", err)
+	}
+	params, err := json.Marshal(t.Params)
+	if err != nil {
+		return Template{}, fmt.Errorf("encode template params: %w", err)
+	}
+	now := s.now().Format(time.RFC3339Nano)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO task_templates (name, description, spec, params, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET description = excluded.description, spec = excluded.spec,
+			params = excluded.params, updated_at = excluded.updated_at
+	`, t.Name, t.Description, string(spec), string(params), now, now); err != nil {
+		return Template{}, fmt.Errorf("save template: %w", err)
+	}
+	return s.Get(ctx, t.Name)
+}
+
+func (s *Store) Get(ctx context.Context, name string) (Template, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT name, description, spec, params, created_at, updated_at FROM task_templates WHERE name = ?
+	`, strings.TrimSpace(name))
+	t, err := scanTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// List returns every template by name.
+func (s *Store) List(ctx context.Context) ([]Template, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, description, spec, params, created_at, updated_at FROM task_templates ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query templates: %w", err)
+	}
+	defer rows.Close()
+	out := []Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate templates: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Store) Delete(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM task_templates WHERE name = ?`, strings.TrimSpace(name))
+	if err != nil {
+		return fmt.Errorf("delete template: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTemplate(row scanner) (Template, error) {
+	var t Template
+	var specJSON, paramsJSON, createdAt, updatedAt string
+	if err := row.Scan(&t.Name, &t.Description, &specJSON, &paramsJSON, &createdAt, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Template{}, err
+		}
+		return Template{}, fmt.Errorf("scan template: %w", err)
+	}
+	var spec templateSpec
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		return Template{}, fmt.Errorf("decode template %s: %w", t.Name, err)
+	}
+	if err := json.Unmarshal([]byte(paramsJSON), &t.Params); err != nil {
+		return Template{}, fmt.Errorf("decode template %s params: %w", t.Name, err)
+	}
+	if t.Params == nil {
+		t.Params = []Param{}
+	}
+	t.Type, t.Mode, t.Priority, t.Payload, t.Metadata = spec.Type, spec.Mode, spec.Priority, spec.Payload, spec.Metadata
+	t.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	t.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return t, nil
+}
+
+// normalize trims t and checks that its name, parameters and references
+// are valid.
+func (t *Template) normalize() error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Description = strings.TrimSpace(t.Description)
+	t.Type = strings.TrimSpace(t.Type)
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("template name %q must be lowercase letters, digits, _ or -, starting with a letter", t.Name)
+	}
+	if t.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if t.Type == "agent" {
+		return fmt.Errorf("templates cannot spawn agents")
+	}
+	if t.Params == nil {
+		t.Params = []Param{}
+	}
+	declared := map[string]bool{}
+	for i := range t.Params {
+		p := &t.Params[i]
+		p.Name = strings.TrimSpace(p.Name)
+		p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+		if p.Type == "" {
+			p.Type = TypeString
+		}
+		if !paramNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("parameter name %q must be a letter followed by letters, digits or _", p.Name)
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("parameter %q is declared twice", p.Name)
+		}
+		declared[p.Name] = true
+		switch p.Type {
+		case TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeObject, TypeArray:
+		default:
+			return fmt.Errorf("parameter %q has unknown type %q", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if p.Required {
+				return fmt.Errorf("parameter %q is required and cannot have a default", p.Name)
+			}
+			value, err := coerce(*p, p.Default)
+			if err != nil {
+				return fmt.Errorf("default of %w", err)
+			}
+			p.Default = value
+		}
+	}
+	for _, name := range references(t.Payload, t.Metadata) {
+		if !declared[name] {
+			return fmt.Errorf("template references undeclared parameter %q", name)
+		}
+	}
+	return nil
+}
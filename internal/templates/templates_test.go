@@ -0,0 +1,107 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func deployTemplate() Template {
+	return Template{
+		Name:        "deploy",
+		Description: "Deploy a service",
+		Type:        "exec",
+		Params: []Param{
+			{Name: "service", Type: TypeString, Required: true},
+			{Name: "replicas", Type: TypeInteger, Default: float64(2)},
+			{Name: "flags", Type: TypeObject},
+		},
+		Payload: map[string]any{
+			"code":     "await deploy({{json service}}, {{replicas}})",
+			"replicas": "{{replicas}}",
+			"env":      map[string]any{"flags": "{{flags}}"},
+		},
+		Metadata: map[string]any{"label": "deploy {{service}}"},
+	}
+}
+
+func TestStorePutGetListDelete(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+	store := NewStore(db)
+
+	saved, err := store.Put(ctx, deployTemplate())
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if saved.Params[1].Default != float64(2) || saved.Payload["code"] != "await deploy({{json service}}, {{replicas}})" {
+		t.Fatalf("unexpected saved template %+v", saved)
+	}
+	replaced := deployTemplate()
+	replaced.Description = "Deploy a service to staging"
+	if _, err := store.Put(ctx, replaced); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 1 || list[0].Description != "Deploy a service to staging" {
+		t.Fatalf("expected one replaced template, got %+v %v", list, err)
+	}
+	if err := store.Delete(ctx, "deploy"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "deploy"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected the template gone, got %v", err)
+	}
+
+	bad := deployTemplate()
+	bad.Payload["extra"] = "{{region}}"
+	if _, err := store.Put(ctx, bad); err == nil || !strings.Contains(err.Error(), `undeclared parameter "region"`) {
+		t.Fatalf("expected an undeclared reference to be rejected, got %v", err)
+	}
+	bad = deployTemplate()
+	bad.Params[1].Default = "two"
+	if _, err := store.Put(ctx, bad); err == nil {
+		t.Fatalf("expected a mistyped default to be rejected")
+	}
+}
+
+func TestInstantiateSubstitutesTypedValues(t *testing.T) {
+	tmpl := deployTemplate()
+	if err := tmpl.normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	spec, err := Instantiate(tmpl, map[string]any{"service": `api "v2"`, "flags": map[string]any{"canary": true}})
+	if err != nil {
+		t.Fatalf("instantiate: %v", err)
+	}
+	if got := spec.Payload["code"]; got != `await deploy("api \"v2\"", 2)` {
+		t.Fatalf("unexpected code %q", got)
+	}
+	if spec.Payload["replicas"] != int64(2) {
+		t.Fatalf("expected the default kept as an integer, got %#v", spec.Payload["replicas"])
+	}
+	if flags := spec.Payload["env"].(map[string]any)["flags"].(map[string]any); flags["canary"] != true {
+		t.Fatalf("expected the object substituted whole, got %#v", flags)
+	}
+	if spec.Type != "exec" || spec.Metadata["template"] != "deploy" || spec.Metadata["label"] != `deploy api "v2"` {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	if tmpl.Payload["code"] != "await deploy({{json service}}, {{replicas}})" {
+		t.Fatalf("expected the template left unchanged")
+	}
+
+	_, err = Instantiate(tmpl, map[string]any{"replicas": 1.5, "region": "eu"})
+	var argsErr *ArgsError
+	if !errors.As(err, &argsErr) || len(argsErr.Problems) != 3 {
+		t.Fatalf("expected three problems, got %v", err)
+	}
+	for _, want := range []string{`missing required parameter "service"`, `"replicas" must be an integer`, `unknown parameter "region"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %q", want, err)
+		}
+	}
+}
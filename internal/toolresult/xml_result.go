@@ -78,6 +78,23 @@ func ErrorWithLabel(toolName, label string, err error) llmtools.Result {
 	}
 }
 
+// ErrorWithDetails is an error result that also carries a structured value,
+// rendered after the error message, for errors the model should act on.
+func ErrorWithDetails(toolName, label string, err error, details any) llmtools.Result {
+	if err == nil {
+		panic("toolresult: cannot create error result with nil error")
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = fmt.Sprintf("Error: %s", err.Error())
+	}
+	return &xmlResult{
+		label:   label,
+		content: content.FromText(renderErrorDetails(toolName, err.Error(), details)),
+		err:     err,
+	}
+}
+
 func Render(toolName string, value any) string {
 	root := tagName(toolName) + "_result"
 	var b strings.Builder
@@ -109,6 +126,24 @@ func renderError(toolName, message string) string {
 	return b.String()
 }
 
+func renderErrorDetails(toolName, message string, details any) string {
+	root := tagName(toolName) + "_result"
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(root)
+	b.WriteString(">\n")
+	b.WriteString("<error>")
+	b.WriteString(escapeXMLText(strings.TrimSpace(message)))
+	b.WriteString("</error>\n")
+	b.WriteString("<details>\n")
+	b.WriteString(escapeXMLText(formatValue(details)))
+	b.WriteString("\n</details>\n")
+	b.WriteString("</")
+	b.WriteString(root)
+	b.WriteString(">")
+	return b.String()
+}
+
 func tagName(raw string) string {
 	raw = strings.TrimSpace(strings.ToLower(raw))
 	if raw == "" {
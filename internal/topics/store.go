@@ -0,0 +1,256 @@
+// Package topics lets agents subscribe to named shared topics. An event
+// published to a topic is stored once on the shared stream and reaches every
+// subscribed agent as a context event, with read state kept per agent.
+package topics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+// ScopeType is the event scope of topic publications; the scope ID is the
+// topic name.
+const ScopeType = "topic"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Subscription records that AgentID receives events published to Topic
+// since CreatedAt.
+type Subscription struct {
+	Topic     string    `json:"topic"`
+	AgentID   string    `json:"agent_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Topic summarizes one topic with at least one subscriber.
+type Topic struct {
+	Name        string `json:"name"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// PublishInput is one event for a topic. Priority may be low, normal or
+// wake; wake starts a turn for every subscriber.
+type PublishInput struct {
+	Topic    string
+	Source   string
+	Subject  string
+	Body     string
+	Priority string
+	Payload  map[string]any
+}
+
+type Store struct {
+	db  *sql.DB
+	bus *eventbus.Bus
+
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, bus *eventbus.Bus, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		bus:   bus,
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// NormalizeName lowercases and validates a topic name: letters, digits,
+// dots, dashes and underscores, at most 64 characters.
+func NormalizeName(topic string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(topic))
+	if name == "" {
+		return "", fmt.Errorf("topic is required")
+	}
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid topic %q: use lowercase letters, digits, dots, dashes or underscores (max 64)", topic)
+	}
+	return name, nil
+}
+
+// Subscribe adds agentID to topic. Subscribing again keeps the original
+// subscription, so earlier events do not reappear.
+func (s *Store) Subscribe(ctx context.Context, agentID, topic string) (Subscription, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return Subscription{}, fmt.Errorf("agent_id is required")
+	}
+	name, err := NormalizeName(topic)
+	if err != nil {
+		return Subscription{}, err
+	}
+	now := s.now()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO topic_subscriptions (topic, agent_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(topic, agent_id) DO NOTHING
+	`, name, agentID, now.Format(time.RFC3339Nano)); err != nil {
+		return Subscription{}, fmt.Errorf("insert topic subscription: %w", err)
+	}
+	subs, err := s.query(ctx, `SELECT topic, agent_id, created_at FROM topic_subscriptions WHERE topic = ? AND agent_id = ?`, name, agentID)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if len(subs) == 0 {
+		return Subscription{}, fmt.Errorf("topic subscription not stored")
+	}
+	return subs[0], nil
+}
+
+// Unsubscribe removes agentID from topic and reports whether it was
+// subscribed.
+func (s *Store) Unsubscribe(ctx context.Context, agentID, topic string) (bool, error) {
+	name, err := NormalizeName(topic)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx, `DELETE FROM topic_subscriptions WHERE topic = ? AND agent_id = ?`, name, strings.TrimSpace(agentID))
+	if err != nil {
+		return false, fmt.Errorf("delete topic subscription: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ForAgent lists the topics agentID is subscribed to.
+func (s *Store) ForAgent(ctx context.Context, agentID string) ([]Subscription, error) {
+	return s.query(ctx, `
+		SELECT topic, agent_id, created_at FROM topic_subscriptions
+		WHERE agent_id = ? ORDER BY topic ASC
+	`, strings.TrimSpace(agentID))
+}
+
+// Subscribers lists the agents subscribed to topic.
+func (s *Store) Subscribers(ctx context.Context, topic string) ([]Subscription, error) {
+	name, err := NormalizeName(topic)
+	if err != nil {
+		return nil, err
+	}
+	return s.query(ctx, `
+		SELECT topic, agent_id, created_at FROM topic_subscriptions
+		WHERE topic = ? ORDER BY created_at ASC, agent_id ASC
+	`, name)
+}
+
+// List returns every topic that has subscribers.
+func (s *Store) List(ctx context.Context) ([]Topic, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT topic, COUNT(*) FROM topic_subscriptions GROUP BY topic ORDER BY topic ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list topics: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Topic
+	for rows.Next() {
+		var t Topic
+		if err := rows.Scan(&t.Name, &t.Subscribers); err != nil {
+			return nil, fmt.Errorf("scan topic: %w", err)
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate topics: %w", err)
+	}
+	return out, nil
+}
+
+// Publish stores one event for topic on the shared stream. The publisher,
+// when it is an agent, starts out having read it.
+func (s *Store) Publish(ctx context.Context, input PublishInput) (eventbus.Event, error) {
+	if s.bus == nil {
+		return eventbus.Event{}, fmt.Errorf("event bus unavailable")
+	}
+	name, err := NormalizeName(input.Topic)
+	if err != nil {
+		return eventbus.Event{}, err
+	}
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		return eventbus.Event{}, fmt.Errorf("body is required")
+	}
+	priority := schema.PriorityNormal
+	if raw := strings.TrimSpace(input.Priority); raw != "" {
+		priority = schema.Priority(strings.ToLower(raw))
+		switch priority {
+		case schema.PriorityLow, schema.PriorityNormal, schema.PriorityWake:
+		default:
+			return eventbus.Event{}, fmt.Errorf("priority must be low, normal or wake")
+		}
+	}
+	source := strings.TrimSpace(input.Source)
+	if source == "" {
+		source = "external"
+	}
+	subject := strings.TrimSpace(input.Subject)
+	if subject == "" {
+		subject = fmt.Sprintf("Topic %s", name)
+	}
+	return s.bus.Push(ctx, eventbus.EventInput{
+		Stream:    schema.StreamShared,
+		ScopeType: ScopeType,
+		ScopeID:   name,
+		Subject:   subject,
+		Body:      body,
+		Payload:   input.Payload,
+		Metadata: map[string]any{
+			"kind":     "topic_event",
+			"topic":    name,
+			"source":   source,
+			"priority": string(priority),
+		},
+		SourceID: source,
+	})
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list topic subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Subscription
+	for rows.Next() {
+		var sub Subscription
+		var createdAt string
+		if err := rows.Scan(&sub.Topic, &sub.AgentID, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan topic subscription: %w", err)
+		}
+		sub.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		out = append(out, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate topic subscriptions: %w", err)
+	}
+	return out, nil
+}
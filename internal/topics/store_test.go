@@ -0,0 +1,92 @@
+package topics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestStoreSubscribePublishAndList(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+
+	ctx := context.Background()
+	bus := eventbus.NewBus(db)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := NewStore(db, bus, WithClock(func() time.Time { return now }))
+
+	first, err := store.Subscribe(ctx, "agent-a", " Deployments ")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if first.Topic != "deployments" {
+		t.Fatalf("expected normalized topic, got %q", first.Topic)
+	}
+	now = now.Add(time.Minute)
+	again, err := store.Subscribe(ctx, "agent-a", "deployments")
+	if err != nil {
+		t.Fatalf("resubscribe: %v", err)
+	}
+	if !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("expected resubscribe to keep original time, got %s vs %s", again.CreatedAt, first.CreatedAt)
+	}
+	if _, err := store.Subscribe(ctx, "agent-b", "deployments"); err != nil {
+		t.Fatalf("subscribe b: %v", err)
+	}
+	if _, err := store.Subscribe(ctx, "agent-a", "bad topic!"); err == nil {
+		t.Fatalf("expected invalid topic name to be rejected")
+	}
+
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "deployments" || list[0].Subscribers != 2 {
+		t.Fatalf("unexpected topics: %+v", list)
+	}
+
+	evt, err := store.Publish(ctx, PublishInput{Topic: "deployments", Source: "agent-a", Body: "api v2 rolled out"})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if evt.Stream != schema.StreamShared || evt.ScopeType != ScopeType || evt.ScopeID != "deployments" {
+		t.Fatalf("unexpected event scope: %+v", evt)
+	}
+	if len(evt.ReadBy) != 1 || evt.ReadBy[0] != "agent-a" {
+		t.Fatalf("expected publisher to have read its event, got %v", evt.ReadBy)
+	}
+	if _, err := store.Publish(ctx, PublishInput{Topic: "deployments", Body: "x", Priority: "interrupt"}); err == nil {
+		t.Fatalf("expected interrupt priority to be rejected")
+	}
+
+	summaries, err := bus.List(ctx, schema.StreamShared, eventbus.ListOptions{Reader: "agent-b", Topics: []string{"deployments"}})
+	if err != nil {
+		t.Fatalf("list shared: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Read {
+		t.Fatalf("expected one unread event for agent-b, got %+v", summaries)
+	}
+	summaries, err = bus.List(ctx, schema.StreamShared, eventbus.ListOptions{Reader: "agent-c"})
+	if err != nil {
+		t.Fatalf("list shared unsubscribed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Fatalf("expected unsubscribed reader to see nothing, got %+v", summaries)
+	}
+
+	removed, err := store.Unsubscribe(ctx, "agent-b", "deployments")
+	if err != nil || !removed {
+		t.Fatalf("unsubscribe: removed=%v err=%v", removed, err)
+	}
+	subs, err := store.ForAgent(ctx, "agent-b")
+	if err != nil {
+		t.Fatalf("for agent: %v", err)
+	}
+	if len(subs) != 0 {
+		t.Fatalf("expected no subscriptions after unsubscribe, got %+v", subs)
+	}
+}
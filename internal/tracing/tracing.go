@@ -0,0 +1,162 @@
+// Package tracing instruments agentd with OpenTelemetry spans so one inbound
+// message can be followed through the turns, tool calls and tasks it causes.
+//
+// Spans go to the global tracer provider, which Setup replaces with an OTLP
+// exporter when tracing is configured. Without it spans are not recorded,
+// but a trace context that arrived with a W3C traceparent header is still
+// carried along and stored in task and message metadata.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/flitsinc/go-agents"
+
+// Metadata keys the trace context is stored under in task and event
+// metadata.
+const (
+	MetaTraceparent = "traceparent"
+	MetaTraceID     = "trace_id"
+)
+
+const defaultServiceName = "agentd"
+
+// propagator reads and writes W3C traceparent headers regardless of the
+// global propagator.
+var propagator = propagation.TraceContext{}
+
+// Config is the config file's tracing section.
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces URL, e.g.
+	// http://localhost:4318/v1/traces. Empty falls back to the standard
+	// OTEL_EXPORTER_OTLP_* environment variables.
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	// ServiceName defaults to "agentd".
+	ServiceName string `json:"service_name"`
+	// SampleRatio is the fraction of new traces recorded; zero records all
+	// of them. Traces started upstream follow the caller's decision.
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// Validate checks the sample ratio.
+func (c Config) Validate() error {
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// Setup installs a tracer provider exporting spans over OTLP/HTTP as the
+// global one. The returned function flushes and stops it.
+func Setup(ctx context.Context, c Config) (func(context.Context) error, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var opts []otlptracehttp.Option
+	if endpoint := strings.TrimSpace(c.Endpoint); endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+	name := strings.TrimSpace(c.ServiceName)
+	if name == "" {
+		name = defaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if c.SampleRatio > 0 && c.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(c.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed when err is set.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// FromHeader continues the trace named by the traceparent header in h, if
+// any.
+func FromHeader(ctx context.Context, h http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// FromMetadata continues the trace stored in metadata by Inject, unless ctx
+// already has a span of its own.
+func FromMetadata(ctx context.Context, metadata map[string]any) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	traceparent, _ := metadata[MetaTraceparent].(string)
+	if strings.TrimSpace(traceparent) == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{MetaTraceparent: traceparent})
+}
+
+// Inject returns metadata with the trace context of ctx added as a
+// traceparent and a trace ID. metadata is returned as is when ctx has no
+// trace or metadata already names one; it is never modified in place.
+func Inject(ctx context.Context, metadata map[string]any) map[string]any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return metadata
+	}
+	if _, ok := metadata[MetaTraceparent]; ok {
+		return metadata
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	out := maps.Clone(metadata)
+	if out == nil {
+		out = map[string]any{}
+	}
+	out[MetaTraceparent] = carrier.Get(MetaTraceparent)
+	out[MetaTraceID] = sc.TraceID().String()
+	return out
+}
+
+// TraceID returns the ID of the trace ctx is part of, or "".
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTraceContextRoundTripsThroughHeadersAndMetadata(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+	ctx := FromHeader(context.Background(), header)
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected trace id %q", got)
+	}
+
+	in := map[string]any{"kind": "message"}
+	out := Inject(ctx, in)
+	if len(in) != 1 {
+		t.Fatalf("expected the input left alone, got %+v", in)
+	}
+	if out[MetaTraceparent] != traceparent || out[MetaTraceID] != "4bf92f3577b34da6a3ce929d0e0e4736" || out["kind"] != "message" {
+		t.Fatalf("unexpected metadata %+v", out)
+	}
+	if again := Inject(context.Background(), out); again[MetaTraceparent] != traceparent {
+		t.Fatalf("expected metadata without a trace kept, got %+v", again)
+	}
+	if got := TraceID(FromMetadata(context.Background(), out)); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected the trace continued from metadata, got %q", got)
+	}
+	if got := TraceID(FromMetadata(context.Background(), map[string]any{MetaTraceparent: "garbage"})); got != "" {
+		t.Fatalf("expected an invalid traceparent ignored, got %q", got)
+	}
+	if err := (Config{SampleRatio: 2}).Validate(); err == nil {
+		t.Fatalf("expected a sample ratio above one rejected")
+	}
+}
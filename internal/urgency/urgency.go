@@ -0,0 +1,177 @@
+// Package urgency tags inbound messages with an urgency level and sentiment
+// so the runtime can pick a priority when callers don't set one.
+package urgency
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+type Level string
+
+const (
+	LevelHigh   Level = "high"
+	LevelMedium Level = "medium"
+	LevelLow    Level = "low"
+)
+
+type Sentiment string
+
+const (
+	SentimentNegative Sentiment = "negative"
+	SentimentNeutral  Sentiment = "neutral"
+	SentimentPositive Sentiment = "positive"
+)
+
+type Result struct {
+	Urgency   Level
+	Sentiment Sentiment
+}
+
+// Priority maps the urgency level to an event priority.
+func (r Result) Priority() schema.Priority {
+	switch r.Urgency {
+	case LevelHigh:
+		return schema.PriorityInterrupt
+	case LevelLow:
+		return schema.PriorityNormal
+	default:
+		return schema.PriorityWake
+	}
+}
+
+type Classifier interface {
+	Classify(ctx context.Context, text string) (Result, error)
+}
+
+// KeywordClassifier is a cheap heuristic classifier. Messages it cannot place
+// are treated as medium urgency so they keep the default wake behavior.
+type KeywordClassifier struct{}
+
+var (
+	// highUrgencyTerms are matched as whole words, and not when negated,
+	// e.g. "not urgent" or "non-critical".
+	highUrgencyTerms = []string{
+		"urgent", "asap", "emergency", "immediately", "right now", "outage",
+		"is down", "are down", "critical", "sev1", "sev0", "p0", "production down",
+		"data loss", "security incident",
+	}
+	negations = map[string]struct{}{
+		"not": {}, "no": {}, "non": {}, "never": {}, "nothing": {},
+	}
+	// negationFillers may sit between a negation and the term it negates,
+	// as in "not an emergency" or "not very urgent".
+	negationFillers = map[string]struct{}{
+		"a": {}, "an": {}, "the": {}, "very": {}, "really": {}, "that": {}, "so": {},
+	}
+	chatterTerms = map[string]struct{}{
+		"ok": {}, "okay": {}, "k": {}, "thanks": {}, "thank you": {}, "thx": {},
+		"ty": {}, "cool": {}, "nice": {}, "lol": {}, "haha": {}, "great": {},
+		"got it": {}, "sounds good": {}, "good morning": {}, "good night": {},
+		"hi": {}, "hello": {}, "hey": {}, "bye": {}, "np": {}, "sure": {},
+	}
+	negativeTerms = []string{
+		"angry", "frustrat", "annoyed", "terrible", "awful", "broken", "worst",
+		"hate", "unacceptable", "failing", "failed", "disappointed",
+	}
+	positiveTerms = []string{
+		"thank", "great", "awesome", "love", "nice", "perfect", "appreciate",
+		"excellent", "well done",
+	}
+)
+
+func (KeywordClassifier) Classify(_ context.Context, text string) (Result, error) {
+	normalized := strings.ToLower(strings.TrimSpace(text))
+	result := Result{Urgency: LevelMedium, Sentiment: sentimentOf(normalized)}
+	if normalized == "" {
+		result.Urgency = LevelLow
+		return result, nil
+	}
+	switch {
+	case mentionsAny(words(normalized), highUrgencyTerms) || strings.Contains(normalized, "!!!"):
+		result.Urgency = LevelHigh
+	case isChatter(normalized):
+		result.Urgency = LevelLow
+	}
+	return result, nil
+}
+
+// isChatter reports whether every phrase in text is a known acknowledgement
+// or greeting, e.g. "thanks, got it".
+func isChatter(text string) bool {
+	phrases := strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	for _, phrase := range phrases {
+		phrase = strings.Join(strings.Fields(phrase), " ")
+		if phrase == "" {
+			continue
+		}
+		if _, ok := chatterTerms[phrase]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sentimentOf(text string) Sentiment {
+	negative := containsAny(text, negativeTerms)
+	positive := containsAny(text, positiveTerms)
+	switch {
+	case negative && !positive:
+		return SentimentNegative
+	case positive && !negative:
+		return SentimentPositive
+	default:
+		return SentimentNeutral
+	}
+}
+
+func containsAny(text string, terms []string) bool {
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits text into runs of letters and digits, so "non-critical" is
+// "non" and "critical".
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// mentionsAny reports whether any term occurs in words as whole words
+// without a negation before it.
+func mentionsAny(words []string, terms []string) bool {
+	for _, term := range terms {
+		want := strings.Fields(term)
+		for i := 0; i+len(want) <= len(words); i++ {
+			if slices.Equal(words[i:i+len(want)], want) && !negated(words[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// negated reports whether the words before a term end in a negation,
+// skipping fillers such as articles.
+func negated(before []string) bool {
+	for i := len(before) - 1; i >= 0; i-- {
+		if _, ok := negations[before[i]]; ok {
+			return true
+		}
+		if _, ok := negationFillers[before[i]]; !ok {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package urgency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/schema"
+)
+
+func TestKeywordClassifier(t *testing.T) {
+	cases := []struct {
+		text      string
+		urgency   Level
+		sentiment Sentiment
+		priority  schema.Priority
+	}{
+		{"URGENT: checkout is down for all users", LevelHigh, SentimentNeutral, schema.PriorityInterrupt},
+		{"thanks!", LevelLow, SentimentPositive, schema.PriorityNormal},
+		{"ok 👍", LevelLow, SentimentNeutral, schema.PriorityNormal},
+		{"can you look at the failed deploy? I'm frustrated", LevelMedium, SentimentNegative, schema.PriorityWake},
+		{"what's the weather tomorrow?", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"not urgent, whenever you get a chance", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"a non-critical typo in the footer", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"no outage, just checking the dashboard", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"this is not an emergency", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"the nightly backup is downloading slowly", LevelMedium, SentimentNeutral, schema.PriorityWake},
+		{"not urgent for me, but billing has an outage", LevelHigh, SentimentNeutral, schema.PriorityInterrupt},
+		{"critical: the API is down", LevelHigh, SentimentNeutral, schema.PriorityInterrupt},
+		{"we have a sev1, production down", LevelHigh, SentimentNeutral, schema.PriorityInterrupt},
+		{"is it urgent? no", LevelHigh, SentimentNeutral, schema.PriorityInterrupt},
+	}
+	for _, tc := range cases {
+		got, err := KeywordClassifier{}.Classify(context.Background(), tc.text)
+		if err != nil {
+			t.Fatalf("classify %q: %v", tc.text, err)
+		}
+		if got.Urgency != tc.urgency || got.Sentiment != tc.sentiment {
+			t.Fatalf("classify %q: got %+v", tc.text, got)
+		}
+		if got.Priority() != tc.priority {
+			t.Fatalf("classify %q: expected priority %s, got %s", tc.text, tc.priority, got.Priority())
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package usage keeps the tokens and estimated cost of every LLM turn in
+// the llm_usage table and rolls them up by agent, model and day.
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// Roll-up dimensions.
+const (
+	ByAgent = "agent"
+	ByModel = "model"
+	ByDay   = "day"
+)
+
+const dayLayout = "2006-01-02"
+
+// Record is the usage of one turn, keyed by its llm task.
+type Record struct {
+	TaskID                   string
+	AgentID                  string
+	Model                    string
+	InputTokens              int64
+	OutputTokens             int64
+	CachedInputTokens        int64
+	CacheCreationInputTokens int64
+	// CostMicroUSD is the estimated cost in millionths of a US dollar, zero
+	// when the model has no price.
+	CostMicroUSD int64
+	At           time.Time
+}
+
+// Totals sums the usage of some turns.
+type Totals struct {
+	Turns                    int64 `json:"turns"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CachedInputTokens        int64 `json:"cached_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CostMicroUSD             int64 `json:"cost_microusd"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.Turns += o.Turns
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.CachedInputTokens += o.CachedInputTokens
+	t.CacheCreationInputTokens += o.CacheCreationInputTokens
+	t.CostMicroUSD += o.CostMicroUSD
+}
+
+// Row is the usage of one group. Only the fields grouped by are set; Day is
+// a UTC date such as 2026-01-31.
+type Row struct {
+	AgentID string `json:"agent_id,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Day     string `json:"day,omitempty"`
+	Totals
+}
+
+// Filter selects the turns to roll up. From and To bound when turns were
+// recorded, [From, To); zero leaves that side open.
+type Filter struct {
+	AgentID string
+	Model   string
+	From    time.Time
+	To      time.Time
+}
+
+type Store struct {
+	db *sql.DB
+
+	nowFn func() time.Time
+}
+
+type Option func(*Store)
+
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Store) {
+		if nowFn != nil {
+			s.nowFn = nowFn
+		}
+	}
+}
+
+func NewStore(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		nowFn: func() time.Time { return time.Now().UTC() },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+func (s *Store) now() time.Time {
+	if s.nowFn == nil {
+		return time.Now().UTC()
+	}
+	return s.nowFn().UTC()
+}
+
+// Record stores rec, replacing an earlier record for the same task. A zero
+// At is the current time.
+func (s *Store) Record(ctx context.Context, rec Record) error {
+	rec.TaskID = strings.TrimSpace(rec.TaskID)
+	rec.AgentID = strings.TrimSpace(rec.AgentID)
+	if rec.TaskID == "" || rec.AgentID == "" {
+		return fmt.Errorf("task_id and agent_id are required")
+	}
+	if rec.At.IsZero() {
+		rec.At = s.now()
+	}
+	at := rec.At.UTC()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO llm_usage (task_id, agent_id, model, day, input_tokens, output_tokens,
+			cached_input_tokens, cache_creation_input_tokens, cost_microusd, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET
+			agent_id = excluded.agent_id, model = excluded.model, day = excluded.day,
+			input_tokens = excluded.input_tokens, output_tokens = excluded.output_tokens,
+			cached_input_tokens = excluded.cached_input_tokens,
+			cache_creation_input_tokens = excluded.cache_creation_input_tokens,
+			cost_microusd = excluded.cost_microusd, created_at = excluded.created_at
+	`, rec.TaskID, rec.AgentID, strings.TrimSpace(rec.Model), at.Format(dayLayout),
+		rec.InputTokens, rec.OutputTokens, rec.CachedInputTokens, rec.CacheCreationInputTokens,
+		rec.CostMicroUSD, at.Format(state.TimeLayout)); err != nil {
+		return fmt.Errorf("record usage: %w", err)
+	}
+	return nil
+}
+
+// Rollup sums the turns matching f per agent, model and day, ordered by
+// day, agent and model. Group merges the rows into coarser groups.
+func (s *Store) Rollup(ctx context.Context, f Filter) ([]Row, error) {
+	var where []string
+	var args []any
+	if id := strings.TrimSpace(f.AgentID); id != "" {
+		where = append(where, "agent_id = ?")
+		args = append(args, id)
+	}
+	if model := strings.TrimSpace(f.Model); model != "" {
+		where = append(where, "model = ?")
+		args = append(args, model)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From.UTC().Format(state.TimeLayout))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To.UTC().Format(state.TimeLayout))
+	}
+	query := `
+		SELECT agent_id, model, day, COUNT(*),
+			SUM(input_tokens), SUM(output_tokens), SUM(cached_input_tokens),
+			SUM(cache_creation_input_tokens), SUM(cost_microusd)
+		FROM llm_usage`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY agent_id, model, day ORDER BY day, agent_id, model"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("roll up usage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Row
+	for rows.Next() {
+		var row Row
+		if err := rows.Scan(&row.AgentID, &row.Model, &row.Day, &row.Turns,
+			&row.InputTokens, &row.OutputTokens, &row.CachedInputTokens,
+			&row.CacheCreationInputTokens, &row.CostMicroUSD); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate usage: %w", err)
+	}
+	return out, nil
+}
+
+// ValidateGroupBy checks that every dimension is known and appears once.
+func ValidateGroupBy(by []string) error {
+	seen := map[string]bool{}
+	for _, dim := range by {
+		switch dim {
+		case ByAgent, ByModel, ByDay:
+		default:
+			return fmt.Errorf("unknown usage dimension %q", dim)
+		}
+		if seen[dim] {
+			return fmt.Errorf("usage dimension %q repeated", dim)
+		}
+		seen[dim] = true
+	}
+	return nil
+}
+
+// Group merges rows that agree on the dimensions in by, clearing the
+// others, and returns the groups in the order of by. No dimensions yields
+// a single row with the grand total.
+func Group(rows []Row, by ...string) []Row {
+	keep := map[string]bool{}
+	for _, dim := range by {
+		keep[dim] = true
+	}
+	index := map[Row]int{}
+	var out []Row
+	for _, row := range rows {
+		key := Row{}
+		if keep[ByAgent] {
+			key.AgentID = row.AgentID
+		}
+		if keep[ByModel] {
+			key.Model = row.Model
+		}
+		if keep[ByDay] {
+			key.Day = row.Day
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			out = append(out, key)
+		}
+		out[i].add(row.Totals)
+	}
+	slices.SortFunc(out, func(a, b Row) int {
+		for _, dim := range by {
+			var c int
+			switch dim {
+			case ByAgent:
+				c = strings.Compare(a.AgentID, b.AgentID)
+			case ByModel:
+				c = strings.Compare(a.Model, b.Model)
+			case ByDay:
+				c = strings.Compare(a.Day, b.Day)
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return out
+}
+
+// Total sums rows.
+func Total(rows []Row) Totals {
+	var t Totals
+	for _, row := range rows {
+		t.add(row.Totals)
+	}
+	return t
+}
@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/testutil"
+)
+
+func TestRollupGroupsByAgentModelAndDay(t *testing.T) {
+	db, closeFn := testutil.OpenTestDB(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	now := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	store := NewStore(db, WithClock(func() time.Time { return now }))
+	record := func(rec Record) {
+		t.Helper()
+		if err := store.Record(ctx, rec); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	record(Record{TaskID: "llm-1", AgentID: "a", Model: "small", InputTokens: 100, OutputTokens: 10, CostMicroUSD: 5})
+	record(Record{TaskID: "llm-2", AgentID: "b", Model: "large", InputTokens: 200, OutputTokens: 20, CachedInputTokens: 50, CostMicroUSD: 40})
+	now = now.Add(2 * time.Hour)
+	record(Record{TaskID: "llm-3", AgentID: "a", Model: "large", InputTokens: 300, OutputTokens: 30, CostMicroUSD: 60})
+	// A second record for a turn replaces the first.
+	record(Record{TaskID: "llm-3", AgentID: "a", Model: "large", InputTokens: 400, OutputTokens: 40, CostMicroUSD: 80})
+
+	rows, err := store.Rollup(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	if len(rows) != 3 || rows[0].Day != "2026-01-01" || rows[2].Day != "2026-01-02" || rows[2].InputTokens != 400 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+
+	byAgent := Group(rows, ByAgent)
+	if len(byAgent) != 2 || byAgent[0].AgentID != "a" || byAgent[0].Turns != 2 || byAgent[0].InputTokens != 500 || byAgent[0].CostMicroUSD != 85 || byAgent[0].Model != "" {
+		t.Fatalf("unexpected agent roll-up %+v", byAgent)
+	}
+	byModelDay := Group(rows, ByModel, ByDay)
+	if len(byModelDay) != 3 || byModelDay[0].Model != "large" || byModelDay[0].Day != "2026-01-01" || byModelDay[2].Model != "small" {
+		t.Fatalf("unexpected model and day roll-up %+v", byModelDay)
+	}
+	total := Total(rows)
+	if total.Turns != 3 || total.InputTokens != 700 || total.CachedInputTokens != 50 || total.CostMicroUSD != 125 {
+		t.Fatalf("unexpected total %+v", total)
+	}
+
+	filtered, err := store.Rollup(ctx, Filter{Model: "large", From: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("filtered rollup: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].AgentID != "a" || filtered[0].OutputTokens != 40 {
+		t.Fatalf("unexpected filtered rows %+v", filtered)
+	}
+
+	if err := ValidateGroupBy([]string{ByDay, "week"}); err == nil {
+		t.Fatalf("expected an unknown dimension rejected")
+	}
+	if err := ValidateGroupBy([]string{ByDay, ByDay}); err == nil {
+		t.Fatalf("expected a repeated dimension rejected")
+	}
+}
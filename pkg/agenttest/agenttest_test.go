@@ -0,0 +1,69 @@
+package agenttest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+func TestSourceIsDeterministic(t *testing.T) {
+	src := NewSource(time.Time{}, 2*time.Second)
+	if got := src.Now(); !got.Equal(DefaultStart) {
+		t.Fatalf("first Now = %s, want %s", got, DefaultStart)
+	}
+	if got := src.Now(); !got.Equal(DefaultStart.Add(2 * time.Second)) {
+		t.Fatalf("second Now = %s", got)
+	}
+	if a, b := src.NewID(), src.NewPrefixedID("task"); a != "id-000001" || b != "id-000002" {
+		t.Fatalf("ids = %q, %q", a, b)
+	}
+}
+
+func TestScriptedProviderReplaysThenFails(t *testing.T) {
+	p := NewScriptedProvider(NewStream(StreamSpec{Text: "hello"}))
+	stream := p.Generate(context.Background(), nil, nil, nil, nil)
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+	}
+	if len(statuses) != 1 || statuses[0] != llms.StreamStatusText {
+		t.Fatalf("statuses = %v", statuses)
+	}
+	if stream.Text() != "hello" || stream.Message().Role != "assistant" {
+		t.Fatalf("unexpected stream: text=%q role=%q", stream.Text(), stream.Message().Role)
+	}
+	if err := p.Generate(context.Background(), nil, nil, nil, nil).Err(); err == nil {
+		t.Fatalf("expected error once script is exhausted")
+	}
+	if p.Calls() != 1 {
+		t.Fatalf("calls = %d, want 1", p.Calls())
+	}
+}
+
+func TestCanonicalizeXML(t *testing.T) {
+	got := CanonicalizeXML(`<event id="abc" priority="wake" created_at="2026-01-01T00:00:00Z">hi</event>`)
+	want := `<event created_at="&lt;time&gt;" id="&lt;id&gt;" priority="wake">hi</event>`
+	if got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+	if raw := "<broken"; CanonicalizeXML(raw) != raw {
+		t.Fatalf("expected unparsable input to be returned unchanged")
+	}
+}
+
+func TestFixtureSharesClock(t *testing.T) {
+	fx := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}})
+	task, err := fx.Tasks.Spawn(context.Background(), tasks.Spec{Type: "exec", Owner: "agent"})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if task.ID != "id-000001" {
+		t.Fatalf("task id = %q", task.ID)
+	}
+	if !task.CreatedAt.Equal(DefaultStart) {
+		t.Fatalf("created_at = %s", task.CreatedAt)
+	}
+}
@@ -0,0 +1,103 @@
+// Package agenttest provides deterministic building blocks for testing code
+// that runs on top of the agent runtime: a fake clock and ID source,
+// scripted LLM providers, and snapshot helpers.
+package agenttest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/ai"
+	"github.com/flitsinc/go-agents/internal/engine"
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-agents/internal/testutil"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// ToolFactory builds a tool once the fixture's task manager exists.
+type ToolFactory func(*tasks.Manager) llmtools.Tool
+
+// Tool wraps a tool that does not need the task manager.
+func Tool(tool llmtools.Tool) ToolFactory {
+	return func(*tasks.Manager) llmtools.Tool { return tool }
+}
+
+type Options struct {
+	// Start defaults to DefaultStart and Tick to one second.
+	Start    time.Time
+	Tick     time.Duration
+	Provider llms.Provider
+	Tools    []ToolFactory
+	// Home sets the runtime's context home directory, if non-empty.
+	Home string
+	// State seeds the database before the runtime starts: the name of a
+	// shipped state (see StateNames) or the path of a dump ending in .sql.
+	// The clock resumes where the dump left off.
+	State string
+}
+
+// Fixture is a runtime backed by a fresh SQLite database in which every
+// component shares one deterministic clock and ID source.
+type Fixture struct {
+	DB      *sql.DB
+	Bus     *eventbus.Bus
+	Tasks   *tasks.Manager
+	Runtime *engine.Runtime
+	Clock   *Source
+}
+
+func NewFixture(t *testing.T, opts Options) *Fixture {
+	t.Helper()
+	if opts.Provider == nil {
+		t.Fatalf("agenttest fixture requires a provider")
+	}
+	start := opts.Start
+	if start.IsZero() {
+		start = DefaultStart
+	}
+	clock := NewSource(start, opts.Tick)
+	t.Setenv("GO_AGENTS_PROMPT_DATE_LABEL", start.UTC().Format("Monday, January 2, 2006"))
+	db, closeFn := testutil.OpenTestDB(t)
+	t.Cleanup(closeFn)
+	if opts.State != "" {
+		data, err := LoadState(opts.State)
+		if err != nil {
+			t.Fatalf("agenttest state: %v", err)
+		}
+		if err := RestoreState(context.Background(), db, data, clock); err != nil {
+			t.Fatalf("agenttest state %s: %v", opts.State, err)
+		}
+	}
+
+	bus := eventbus.NewBus(db,
+		eventbus.WithClock(clock.Now),
+		eventbus.WithIDGenerator(clock.NewID),
+	)
+	mgr := tasks.NewManager(db, bus,
+		tasks.WithClock(clock.Now),
+		tasks.WithIDGenerator(clock.NewPrefixedID),
+	)
+	tools := make([]llmtools.Tool, 0, len(opts.Tools))
+	for _, factory := range opts.Tools {
+		if factory == nil {
+			continue
+		}
+		tools = append(tools, factory(mgr))
+	}
+	client := &ai.Client{LLM: llms.New(opts.Provider, tools...)}
+	rt := engine.NewRuntime(bus, mgr, client, engine.WithClock(clock.Now))
+	if opts.Home != "" {
+		rt.Context.Home = opts.Home
+	}
+	return &Fixture{
+		DB:      db,
+		Bus:     bus,
+		Tasks:   mgr,
+		Runtime: rt,
+		Clock:   clock,
+	}
+}
@@ -0,0 +1,140 @@
+package agenttest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/flitsinc/go-llms/content"
+	"github.com/flitsinc/go-llms/llms"
+	llmtools "github.com/flitsinc/go-llms/tools"
+)
+
+// ScriptedProvider replays a fixed list of streams, one per Generate call,
+// and fails any call beyond the script.
+type ScriptedProvider struct {
+	mu      sync.Mutex
+	streams []llms.ProviderStream
+	next    int
+}
+
+func NewScriptedProvider(streams ...llms.ProviderStream) *ScriptedProvider {
+	return &ScriptedProvider{streams: append([]llms.ProviderStream(nil), streams...)}
+}
+
+func (p *ScriptedProvider) Company() string              { return "test" }
+func (p *ScriptedProvider) Model() string                { return "test" }
+func (p *ScriptedProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *ScriptedProvider) SetHTTPClient(_ *http.Client) {}
+
+func (p *ScriptedProvider) Generate(_ context.Context, _ content.Content, _ []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next >= len(p.streams) {
+		return NewStream(StreamSpec{Err: fmt.Errorf("unexpected provider call #%d", p.next+1)})
+	}
+	stream := p.streams[p.next]
+	p.next++
+	return stream
+}
+
+// Calls returns how many streams have been handed out.
+func (p *ScriptedProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.next
+}
+
+// TextProvider answers every call with the same text.
+type TextProvider struct {
+	Text string
+}
+
+func (p *TextProvider) Company() string              { return "test" }
+func (p *TextProvider) Model() string                { return "test" }
+func (p *TextProvider) SetDebugger(_ llms.Debugger)  {}
+func (p *TextProvider) SetHTTPClient(_ *http.Client) {}
+
+func (p *TextProvider) Generate(_ context.Context, _ content.Content, _ []llms.Message, _ *llmtools.Toolbox, _ *llmtools.ValueSchema) llms.ProviderStream {
+	return NewStream(StreamSpec{Text: p.Text})
+}
+
+// StreamSpec describes a single scripted provider response. Statuses are
+// derived from the other fields when left empty. Several tool calls can be
+// given in Message.ToolCalls; each ToolCallBegin status moves to the next.
+type StreamSpec struct {
+	Message   llms.Message
+	Text      string
+	Thought   content.Thought
+	ToolCall  llms.ToolCall
+	Statuses  []llms.StreamStatus
+	Usage     llms.Usage
+	ImageURL  string
+	ImageMIME string
+	Err       error
+}
+
+type scriptedStream struct {
+	spec    StreamSpec
+	current int
+}
+
+func NewStream(spec StreamSpec) llms.ProviderStream {
+	if spec.Message.Role == "" {
+		spec.Message.Role = "assistant"
+	}
+	if spec.Text != "" {
+		spec.Message.Content = content.FromText(spec.Text)
+	}
+	if spec.ToolCall.ID != "" && len(spec.Message.ToolCalls) == 0 {
+		spec.Message.ToolCalls = []llms.ToolCall{spec.ToolCall}
+	}
+	if len(spec.Statuses) == 0 {
+		var statuses []llms.StreamStatus
+		if strings.TrimSpace(spec.Thought.ID) != "" || strings.TrimSpace(spec.Thought.Text) != "" {
+			statuses = append(statuses, llms.StreamStatusThinking)
+		}
+		if spec.Text != "" {
+			statuses = append(statuses, llms.StreamStatusText)
+		}
+		for range spec.Message.ToolCalls {
+			statuses = append(statuses, llms.StreamStatusToolCallBegin, llms.StreamStatusToolCallReady)
+		}
+		spec.Statuses = statuses
+	}
+	return &scriptedStream{spec: spec, current: -1}
+}
+
+func (s *scriptedStream) Err() error               { return s.spec.Err }
+func (s *scriptedStream) Message() llms.Message    { return s.spec.Message }
+func (s *scriptedStream) Text() string             { return s.spec.Text }
+func (s *scriptedStream) Image() (string, string)  { return s.spec.ImageURL, s.spec.ImageMIME }
+func (s *scriptedStream) Thought() content.Thought { return s.spec.Thought }
+func (s *scriptedStream) ToolCall() llms.ToolCall {
+	if calls := s.spec.Message.ToolCalls; s.current >= 0 && s.current < len(calls) {
+		return calls[s.current]
+	}
+	if s.spec.ToolCall.ID != "" {
+		return s.spec.ToolCall
+	}
+	if len(s.spec.Message.ToolCalls) > 0 {
+		return s.spec.Message.ToolCalls[0]
+	}
+	return llms.ToolCall{}
+}
+func (s *scriptedStream) Usage() llms.Usage { return s.spec.Usage }
+func (s *scriptedStream) Iter() func(func(llms.StreamStatus) bool) {
+	statuses := append([]llms.StreamStatus(nil), s.spec.Statuses...)
+	return func(yield func(llms.StreamStatus) bool) {
+		for _, status := range statuses {
+			if status == llms.StreamStatusToolCallBegin {
+				s.current++
+			}
+			if !yield(status) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package agenttest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// UpdateSnapshotsEnv rewrites snapshot files instead of comparing them when
+// set to "1".
+const UpdateSnapshotsEnv = "UPDATE_SNAPSHOTS"
+
+// AssertSnapshot compares got with the file at path, relative to the test's
+// working directory.
+func AssertSnapshot(t testing.TB, path string, got []byte) {
+	t.Helper()
+	path = filepath.Clean(path)
+	if os.Getenv(UpdateSnapshotsEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir snapshot dir: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write snapshot: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot %s: %v", path, err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("snapshot mismatch for %s\n\n--- got ---\n%s\n--- want ---\n%s", path, string(got), string(want))
+	}
+}
+
+// CanonicalizeXML replaces volatile attribute values (IDs, timestamps,
+// elapsed times) with placeholders and sorts attributes, so rendered LLM
+// input can be compared across runs. Input that fails to parse is returned
+// unchanged.
+func CanonicalizeXML(raw string) string {
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	var out strings.Builder
+	enc := xml.NewEncoder(&out)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return raw
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := make([]xml.Attr, 0, len(t.Attr))
+			for _, attr := range t.Attr {
+				val := attr.Value
+				switch attr.Name.Local {
+				case "id":
+					val = "<id>"
+				case "created_at", "generated_at", "previous", "current":
+					val = "<time>"
+				case "elapsed_seconds":
+					val = "<seconds>"
+				}
+				attrs = append(attrs, xml.Attr{Name: attr.Name, Value: val})
+			}
+			sort.Slice(attrs, func(i, j int) bool {
+				li := attrs[i].Name.Space + ":" + attrs[i].Name.Local
+				lj := attrs[j].Name.Space + ":" + attrs[j].Name.Local
+				return li < lj
+			})
+			t.Attr = attrs
+			if err := enc.EncodeToken(t); err != nil {
+				return raw
+			}
+		default:
+			if err := enc.EncodeToken(tok); err != nil {
+				return raw
+			}
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return raw
+	}
+	return out.String()
+}
+
+// AppendJSONBlock writes v as an indented fenced JSON block for markdown
+// snapshots.
+func AppendJSONBlock(b *strings.Builder, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.WriteString("```text\n")
+		b.WriteString(err.Error())
+		b.WriteString("\n```\n\n")
+		return
+	}
+	b.WriteString("```json\n")
+	b.Write(data)
+	b.WriteString("\n```\n\n")
+}
+
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
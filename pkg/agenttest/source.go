@@ -0,0 +1,50 @@
+package agenttest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultStart is the clock start used when none is given.
+var DefaultStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Source is a deterministic clock and ID generator. Every call to Now
+// advances the clock by a fixed step, and NewID returns sequential IDs.
+type Source struct {
+	mu     sync.Mutex
+	now    time.Time
+	step   time.Duration
+	nextID int
+}
+
+func NewSource(start time.Time, step time.Duration) *Source {
+	if start.IsZero() {
+		start = DefaultStart
+	}
+	if step <= 0 {
+		step = time.Second
+	}
+	return &Source{now: start.UTC(), step: step}
+}
+
+func (s *Source) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.now.UTC()
+	s.now = s.now.Add(s.step)
+	return current
+}
+
+func (s *Source) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	return fmt.Sprintf("id-%06d", s.nextID)
+}
+
+// NewPrefixedID ignores the prefix so it can be passed where a task ID
+// generator is expected.
+func (s *Source) NewPrefixedID(string) string {
+	return s.NewID()
+}
@@ -0,0 +1,137 @@
+package agenttest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flitsinc/go-agents/internal/state"
+)
+
+// States holds the seeded database states shipped with the repo. Each
+// states/<name>.sql file is a dump written by DumpState and can be loaded by
+// name through Options.State.
+//
+//go:embed states/*.sql
+var States embed.FS
+
+const stateHeader = "-- agenttest state"
+
+// StateNames lists the shipped seeded states.
+func StateNames() []string {
+	entries, err := States.ReadDir("states")
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".sql"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DumpState writes db with state.Dump under a header that records the
+// clock's position, so a fixture restored from the dump keeps producing
+// later times and unused IDs.
+func DumpState(ctx context.Context, db *sql.DB, clock *Source) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(stateHeader + "\n")
+	if clock != nil {
+		clock.mu.Lock()
+		fmt.Fprintf(&b, "-- clock %s %d\n", clock.now.UTC().Format(time.RFC3339Nano), clock.nextID)
+		clock.mu.Unlock()
+	}
+	if err := state.Dump(ctx, db, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// SaveState writes DumpState's output to path.
+func SaveState(ctx context.Context, db *sql.DB, clock *Source, path string) error {
+	data, err := DumpState(ctx, db, clock)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// RestoreState loads a dump into db. Plain dumps from "agentd state dump"
+// load too. When the dump recorded a clock position later than clock's,
+// clock moves there.
+func RestoreState(ctx context.Context, db *sql.DB, data []byte, clock *Source) error {
+	if clock != nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(line, "--") {
+				break
+			}
+			if rest, ok := strings.CutPrefix(line, "-- clock "); ok {
+				if err := resumeClock(clock, rest); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := state.Restore(ctx, db, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("restore state: %w", err)
+	}
+	return nil
+}
+
+// LoadState returns the dump for a shipped state name, or reads it from a
+// file when name is a path ending in .sql.
+func LoadState(name string) ([]byte, error) {
+	if strings.HasSuffix(name, ".sql") {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read state: %w", err)
+		}
+		return data, nil
+	}
+	data, err := States.ReadFile("states/" + name + ".sql")
+	if err != nil {
+		return nil, fmt.Errorf("unknown state %q (have %s)", name, strings.Join(StateNames(), ", "))
+	}
+	return data, nil
+}
+
+func resumeClock(clock *Source, spec string) error {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return fmt.Errorf("bad clock line %q", spec)
+	}
+	now, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return fmt.Errorf("bad clock time: %w", err)
+	}
+	nextID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return fmt.Errorf("bad clock id: %w", err)
+	}
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	if now.After(clock.now) {
+		clock.now = now
+	}
+	if nextID > clock.nextID {
+		clock.nextID = nextID
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package agenttest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flitsinc/go-agents/internal/eventbus"
+	"github.com/flitsinc/go-agents/internal/schema"
+	"github.com/flitsinc/go-agents/internal/tasks"
+	"github.com/flitsinc/go-llms/llms"
+)
+
+const stateAgent = "assistant"
+
+// stateBuilders build the shipped states. Dumps keep column names, so they
+// stay loadable as columns are added; regenerate them with
+// UPDATE_SNAPSHOTS=1 after changing a builder or to pick up new columns.
+var stateBuilders = map[string]struct {
+	provider llms.Provider
+	build    func(t *testing.T, fx *Fixture)
+}{
+	"mid_conversation": {
+		provider: NewScriptedProvider(
+			NewStream(StreamSpec{Text: "Hi! The deploy to staging finished at 09:12 and all checks passed."}),
+			NewStream(StreamSpec{Text: "Production is still on last week's build. Want me to promote staging?"}),
+		),
+		build: func(t *testing.T, fx *Fixture) {
+			spawnAgent(t, fx)
+			for _, msg := range []string{"How did the staging deploy go?", "And production?"} {
+				if _, err := fx.Runtime.HandleMessage(context.Background(), stateAgent, "user", msg, map[string]any{"kind": "message"}); err != nil {
+					t.Fatalf("handle message: %v", err)
+				}
+			}
+		},
+	},
+	"pending_tasks": {
+		provider: &TextProvider{Text: "ok"},
+		build: func(t *testing.T, fx *Fixture) {
+			ctx := context.Background()
+			spawnAgent(t, fx)
+			spawn := func(name string) tasks.Task {
+				task, err := fx.Tasks.Spawn(ctx, tasks.Spec{Type: "exec", Name: name, Owner: stateAgent, ParentID: stateAgent, Payload: map[string]any{"code": "await " + name + "()"}})
+				if err != nil {
+					t.Fatalf("spawn %s: %v", name, err)
+				}
+				return task
+			}
+			done := spawn("fetch_logs")
+			running := spawn("run_migrations")
+			spawn("smoke_test")
+			spawn("notify_oncall")
+			if err := fx.Tasks.MarkRunning(ctx, done.ID); err != nil {
+				t.Fatalf("mark running: %v", err)
+			}
+			if err := fx.Tasks.Complete(ctx, done.ID, map[string]any{"lines": 120}); err != nil {
+				t.Fatalf("complete: %v", err)
+			}
+			if err := fx.Tasks.MarkRunning(ctx, running.ID); err != nil {
+				t.Fatalf("mark running: %v", err)
+			}
+			if err := fx.Tasks.RecordUpdate(ctx, running.ID, "progress", map[string]any{"message": "3 of 7 migrations applied"}); err != nil {
+				t.Fatalf("record update: %v", err)
+			}
+		},
+	},
+	"unread_events": {
+		provider: &TextProvider{Text: "ok"},
+		build: func(t *testing.T, fx *Fixture) {
+			ctx := context.Background()
+			spawnAgent(t, fx)
+			read, err := fx.Runtime.SendMessageWithMeta(ctx, stateAgent, "Morning! Anything on fire?", "user", nil)
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if err := fx.Bus.Ack(ctx, read.Stream, []string{read.ID}, stateAgent); err != nil {
+				t.Fatalf("ack: %v", err)
+			}
+			for _, body := range []string{"The checkout service is returning 502s.", "Can you look at it before standup?"} {
+				if _, err := fx.Runtime.SendMessageWithMeta(ctx, stateAgent, body, "user", nil); err != nil {
+					t.Fatalf("send: %v", err)
+				}
+			}
+			if _, err := fx.Bus.Push(ctx, eventbus.EventInput{
+				Stream:    schema.StreamSignals,
+				ScopeType: "task",
+				ScopeID:   stateAgent,
+				Subject:   "alert",
+				Body:      "checkout-api error rate above 5% for 10 minutes",
+				Metadata:  map[string]any{"kind": "alert", "source": "monitoring"},
+			}); err != nil {
+				t.Fatalf("push signal: %v", err)
+			}
+		},
+	},
+}
+
+func spawnAgent(t *testing.T, fx *Fixture) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := fx.Tasks.Spawn(ctx, tasks.Spec{ID: stateAgent, Type: "agent", Metadata: map[string]any{"source": "agenttest"}}); err != nil {
+		t.Fatalf("spawn agent: %v", err)
+	}
+	if err := fx.Tasks.MarkRunning(ctx, stateAgent); err != nil {
+		t.Fatalf("mark agent running: %v", err)
+	}
+}
+
+func TestBuildShippedStates(t *testing.T) {
+	if os.Getenv(UpdateSnapshotsEnv) != "1" {
+		t.Skip("set UPDATE_SNAPSHOTS=1 to regenerate the shipped states")
+	}
+	for name, builder := range stateBuilders {
+		t.Run(name, func(t *testing.T) {
+			fx := NewFixture(t, Options{Provider: builder.provider, Home: filepath.Join("testdata", "state_home")})
+			builder.build(t, fx)
+			dump, err := DumpState(context.Background(), fx.DB, fx.Clock)
+			if err != nil {
+				t.Fatalf("dump: %v", err)
+			}
+			AssertSnapshot(t, filepath.Join("states", name+".sql"), dump)
+		})
+	}
+}
+
+func TestShippedStatesLoad(t *testing.T) {
+	names := StateNames()
+	if len(names) != len(stateBuilders) {
+		t.Fatalf("shipped states %v do not match the builders", names)
+	}
+	for _, name := range names {
+		fx := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}, State: name})
+		if _, err := fx.Tasks.Get(context.Background(), stateAgent); err != nil {
+			t.Fatalf("%s: expected the seeded agent: %v", name, err)
+		}
+	}
+}
+
+func TestFixtureRestoresState(t *testing.T) {
+	fx := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}, State: "pending_tasks"})
+	ctx := context.Background()
+	queued, err := fx.Tasks.List(ctx, tasks.ListFilter{Type: "exec", Status: tasks.StatusQueued})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("expected two queued tasks, got %d", len(queued))
+	}
+	fresh, err := fx.Tasks.Spawn(ctx, tasks.Spec{Type: "exec", Owner: stateAgent})
+	if err != nil {
+		t.Fatalf("spawn after restore: %v", err)
+	}
+	for _, task := range queued {
+		if task.ID == fresh.ID || !fresh.CreatedAt.After(task.CreatedAt) {
+			t.Fatalf("expected the clock to resume after the seeded state, got %s at %s", fresh.ID, fresh.CreatedAt)
+		}
+	}
+
+	unread := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}, State: "unread_events"})
+	inputs, err := unread.Bus.List(ctx, schema.StreamTaskInput, eventbus.ListOptions{Reader: stateAgent, ScopeType: "task", ScopeID: stateAgent})
+	if err != nil {
+		t.Fatalf("list inputs: %v", err)
+	}
+	unreadCount := 0
+	for _, input := range inputs {
+		if !input.Read {
+			unreadCount++
+		}
+	}
+	if len(inputs) != 3 || unreadCount != 2 {
+		t.Fatalf("expected two of three messages unread, got %d of %d", unreadCount, len(inputs))
+	}
+
+	if _, err := LoadState("no_such_state"); err == nil {
+		t.Fatalf("expected an unknown state to be rejected")
+	}
+}
+
+func TestRestoreStateKeepsMultilineText(t *testing.T) {
+	fx := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}})
+	ctx := context.Background()
+	body := "line one;\nit's line two;\n\nend"
+	evt, err := fx.Bus.Push(ctx, eventbus.EventInput{Stream: "notes", Body: body, Payload: map[string]any{"raw": []any{1.5, "x"}}})
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	dump, err := DumpState(ctx, fx.DB, fx.Clock)
+	if err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	restored := NewFixture(t, Options{Provider: &TextProvider{Text: "ok"}})
+	if err := RestoreState(ctx, restored.DB, dump, restored.Clock); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	events, err := restored.Bus.Read(ctx, "notes", []string{evt.ID}, "")
+	if err != nil || len(events) != 1 || events[0].Body != body {
+		t.Fatalf("expected the event restored intact, got %+v (%v)", events, err)
+	}
+}
@@ -0,0 +1,81 @@
+-- agenttest state
+-- clock 2026-01-01T00:01:20Z 62
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000001', 'signals', 'global', '*', 'Task request assistant', 'Spawn task assistant (agent)', '{"action":"spawn","kind":"command","task_id":"assistant","task_type":"agent"}', 'null', '2026-01-01T00:00:01Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000003', 'task_output', 'global', '*', 'Task assistant update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:03Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000005', 'task_output', 'global', '*', 'Task assistant update', 'started', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:06Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000007', 'signals', 'task', 'assistant', 'Task request id-000006', 'Spawn task id-000006 (llm)', '{"action":"spawn","kind":"command","task_id":"id-000006","task_type":"llm"}', 'null', '2026-01-01T00:00:08Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000009', 'task_output', 'task', 'assistant', 'Task id-000006 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:10Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000011', 'task_output', 'task', 'assistant', 'Task id-000006 update', 'started', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:13Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000012', 'task_input', 'task', 'assistant', 'Task input id-000006', 'Send input to task id-000006', '{"action":"send","kind":"command","task_id":"id-000006"}', '{"message":"How did the staging deploy go?"}', '2026-01-01T00:00:14Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000014', 'task_output', 'task', 'assistant', 'Task id-000006 update', 'input', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"input"}', '{"message":"How did the staging deploy go?"}', '2026-01-01T00:00:16Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000015', 'history', 'task', 'assistant', 'system:tools_config', 'tools_config', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"system","type":"tools_config"}', '{"agent_id":"assistant","content":"","created_at":"2026-01-01T00:00:18Z","generation":1,"role":"system","task_id":"id-000006","tools":[],"type":"tools_config"}', '2026-01-01T00:00:19Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000016', 'history', 'task', 'assistant', 'system:system_prompt', 'You are a test agent.
+
+## Managed Harness API Context
+The following section is managed by the runtime and is authoritative for harness API behavior.
+
+You are a test agent.', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"system","type":"system_prompt"}', '{"agent_id":"assistant","content":"You are a test agent.\n\n## Managed Harness API Context\nThe following section is managed by the runtime and is authoritative for harness API behavior.\n\nYou are a test agent.","created_at":"2026-01-01T00:00:20Z","generation":1,"role":"system","task_id":"id-000006","type":"system_prompt"}', '2026-01-01T00:00:21Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000017', 'history', 'task', 'assistant', 'user:user_message', 'How did the staging deploy go?', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"user","type":"user_message"}', '{"agent_id":"assistant","content":"How did the staging deploy go?","created_at":"2026-01-01T00:00:22Z","event_id":"","generation":1,"priority":"normal","request_id":"","role":"user","service_id":"","source":"user","task_id":"id-000006","type":"user_message"}', '2026-01-01T00:00:23Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000018', 'history', 'task', 'assistant', 'system:context_event', 'Task request assistant', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"system","type":"context_event"}', '{"agent_id":"assistant","body":"Spawn task assistant (agent)","content":"Task request assistant","created_at":"2026-01-01T00:00:01Z","event_id":"id-000001","generation":1,"kind":"context_event","metadata":"{\"action\":\"spawn\",\"kind\":\"command\",\"task_id\":\"assistant\",\"task_type\":\"agent\"}","priority":"normal","ref":"e1","role":"system","stream":"signals","subject":"Task request assistant","task_id":"id-000006","type":"context_event"}', '2026-01-01T00:00:25Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000019', 'history', 'task', 'assistant', 'system:context_event', 'Task assistant summary', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"system","type":"context_event"}', '{"agent_id":"assistant","body":"summary\n{\"count\":2,\"kinds\":[\"spawn\",\"started\"],\"latest\":{\"status\":\"running\"},\"latest_kind\":\"started\"}","content":"Task assistant summary","created_at":"2026-01-01T00:00:06Z","event_id":"id-000005","generation":1,"kind":"context_event","metadata":"{\"kind\":\"task_update_summary\",\"priority\":\"normal\",\"supersedes_count\":1,\"task_id\":\"assistant\",\"task_kind\":\"summary\"}","payload":"{\"count\":2,\"kinds\":[\"spawn\",\"started\"],\"latest\":{\"status\":\"running\"},\"latest_kind\":\"started\"}","priority":"normal","ref":"e2","role":"system","stream":"task_output","subject":"Task assistant summary","task_id":"id-000006","type":"context_event"}', '2026-01-01T00:00:27Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000020', 'progress', 'task', 'assistant', 'turn_started', 'turn_started', '{"agent_id":"assistant","state":"turn_started","task_id":"id-000006"}', 'null', '2026-01-01T00:00:28Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000021', 'signals', 'task', 'assistant', 'agent_run_start', 'agent run started', '{"agent_id":"assistant"}', 'null', '2026-01-01T00:00:29Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000024', 'history', 'task', 'assistant', 'system:llm_input', '<system_updates source="user" priority="normal">
+  <message>How did the staging deploy go?</message>
+  <context_updates timezone="UTC">
+    <event ref="e1" stream="signals" task_id="assistant" created_at="Thursday 2026-01-01T00:00:01Z">
+      <subject>Task request assistant</subject>
+      <body>Spawn task assistant (agent)</body>
+      <metadata>{&quot;action&quot;:&quot;spawn&quot;}</metadata>
+    </event>
+    <event ref="e2" stream="task_output" task_id="assistant" task_kind="summary" created_at="Thursday 2026-01-01T00:00:06Z">
+      <subject>Task assistant summary</subject>
+      <body>summary
+  {&quot;count&quot;:2,&quot;kinds&quot;:[&quot;spawn&quot;,&quot;started&quot;],&quot;latest&quot;:{&quot;status&quot;:&quot;running&quot;},&quot;latest_kind&quot;:&quot;started&quot;}</body>
+    </event>
+  </context_updates>
+</system_updates>', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"system","type":"llm_input"}', '{"agent_id":"assistant","content":"\u003csystem_updates source=\"user\" priority=\"normal\"\u003e\n  \u003cmessage\u003eHow did the staging deploy go?\u003c/message\u003e\n  \u003ccontext_updates timezone=\"UTC\"\u003e\n    \u003cevent ref=\"e1\" stream=\"signals\" task_id=\"assistant\" created_at=\"Thursday 2026-01-01T00:00:01Z\"\u003e\n      \u003csubject\u003eTask request assistant\u003c/subject\u003e\n      \u003cbody\u003eSpawn task assistant (agent)\u003c/body\u003e\n      \u003cmetadata\u003e{\u0026quot;action\u0026quot;:\u0026quot;spawn\u0026quot;}\u003c/metadata\u003e\n    \u003c/event\u003e\n    \u003cevent ref=\"e2\" stream=\"task_output\" task_id=\"assistant\" task_kind=\"summary\" created_at=\"Thursday 2026-01-01T00:00:06Z\"\u003e\n      \u003csubject\u003eTask assistant summary\u003c/subject\u003e\n      \u003cbody\u003esummary\n  {\u0026quot;count\u0026quot;:2,\u0026quot;kinds\u0026quot;:[\u0026quot;spawn\u0026quot;,\u0026quot;started\u0026quot;],\u0026quot;latest\u0026quot;:{\u0026quot;status\u0026quot;:\u0026quot;running\u0026quot;},\u0026quot;latest_kind\u0026quot;:\u0026quot;started\u0026quot;}\u003c/body\u003e\n    \u003c/event\u003e\n  \u003c/context_updates\u003e\n\u003c/system_updates\u003e","created_at":"2026-01-01T00:00:30Z","emitted":2,"generation":1,"priority":"normal","role":"system","scanned":3,"source":"user","superseded":1,"task_id":"id-000006","to_event_id":"id-000005","turn":1,"type":"llm_input"}', '2026-01-01T00:00:31Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000025', 'progress', 'task', 'assistant', 'responding', 'responding', '{"agent_id":"assistant","state":"responding","task_id":"id-000006"}', 'null', '2026-01-01T00:00:32Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000027', 'task_output', 'task', 'assistant', 'Task id-000006 update', 'llm_text', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"llm_text"}', '{"text":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:34Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000028', 'history', 'task', 'assistant', 'assistant:assistant_message', 'Hi! The deploy to staging finished at 09:12 and all checks passed.', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"assistant","type":"assistant_message"}', '{"agent_id":"assistant","content":"Hi! The deploy to staging finished at 09:12 and all checks passed.","created_at":"2026-01-01T00:00:35Z","generation":1,"role":"assistant","task_id":"id-000006","turn":1,"type":"assistant_message"}', '2026-01-01T00:00:36Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000030', 'task_output', 'global', '*', 'Task assistant update', 'assistant_output', '{"delivery_exclude":["agent_context"],"kind":"task_update","priority":"normal","source":"user","task_id":"assistant","task_kind":"assistant_output"}', '{"text":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:38Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000032', 'task_output', 'task', 'assistant', 'Task id-000006 update', 'completed', '{"kind":"task_update","priority":"wake","task_id":"id-000006","task_kind":"completed"}', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:42Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000034', 'task_output', 'global', '*', 'Task assistant update', 'completed', '{"kind":"task_update","priority":"wake","task_id":"assistant","task_kind":"completed"}', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:46Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000035', 'signals', 'task', 'assistant', 'agent_run_complete', 'agent run complete', '{"agent_id":"assistant"}', 'null', '2026-01-01T00:00:47Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000036', 'task_input', 'task', 'user', 'Message from assistant', 'Hi! The deploy to staging finished at 09:12 and all checks passed.', '{"kind":"message","priority":"wake","seq":1,"source":"assistant","target":"user"}', 'null', '2026-01-01T00:00:48Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000037', 'progress', 'task', 'assistant', 'turn_finished', 'turn_finished', '{"agent_id":"assistant","state":"turn_finished","task_id":"id-000006"}', 'null', '2026-01-01T00:00:49Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000039', 'signals', 'task', 'assistant', 'Task request id-000038', 'Spawn task id-000038 (llm)', '{"action":"spawn","kind":"command","task_id":"id-000038","task_type":"llm"}', 'null', '2026-01-01T00:00:51Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000041', 'task_output', 'task', 'assistant', 'Task id-000038 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000038","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:53Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000043', 'task_output', 'task', 'assistant', 'Task id-000038 update', 'started', '{"kind":"task_update","priority":"normal","task_id":"id-000038","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:56Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000044', 'task_input', 'task', 'assistant', 'Task input id-000038', 'Send input to task id-000038', '{"action":"send","kind":"command","task_id":"id-000038"}', '{"message":"And production?"}', '2026-01-01T00:00:57Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000046', 'task_output', 'task', 'assistant', 'Task id-000038 update', 'input', '{"kind":"task_update","priority":"normal","task_id":"id-000038","task_kind":"input"}', '{"message":"And production?"}', '2026-01-01T00:00:59Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000047', 'history', 'task', 'assistant', 'user:user_message', 'And production?', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"user","type":"user_message"}', '{"agent_id":"assistant","content":"And production?","created_at":"2026-01-01T00:01:01Z","event_id":"","generation":1,"priority":"normal","request_id":"","role":"user","service_id":"","source":"user","task_id":"id-000038","type":"user_message"}', '2026-01-01T00:01:02Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000048', 'progress', 'task', 'assistant', 'turn_started', 'turn_started', '{"agent_id":"assistant","state":"turn_started","task_id":"id-000038"}', 'null', '2026-01-01T00:01:03Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000049', 'signals', 'task', 'assistant', 'agent_run_start', 'agent run started', '{"agent_id":"assistant"}', 'null', '2026-01-01T00:01:04Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000052', 'progress', 'task', 'assistant', 'responding', 'responding', '{"agent_id":"assistant","state":"responding","task_id":"id-000038"}', 'null', '2026-01-01T00:01:06Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000054', 'task_output', 'task', 'assistant', 'Task id-000038 update', 'llm_text', '{"kind":"task_update","priority":"normal","task_id":"id-000038","task_kind":"llm_text"}', '{"text":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:08Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000055', 'history', 'task', 'assistant', 'assistant:assistant_message', 'Production is still on last week''s build. Want me to promote staging?', '{"agent_id":"assistant","generation":1,"kind":"history_entry","priority":"low","role":"assistant","type":"assistant_message"}', '{"agent_id":"assistant","content":"Production is still on last week''s build. Want me to promote staging?","created_at":"2026-01-01T00:01:09Z","generation":1,"role":"assistant","task_id":"id-000038","turn":2,"type":"assistant_message"}', '2026-01-01T00:01:10Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000057', 'task_output', 'global', '*', 'Task assistant update', 'assistant_output', '{"delivery_exclude":["agent_context"],"kind":"task_update","priority":"normal","source":"user","task_id":"assistant","task_kind":"assistant_output"}', '{"text":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:12Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000059', 'task_output', 'task', 'assistant', 'Task id-000038 update', 'completed', '{"kind":"task_update","priority":"wake","task_id":"id-000038","task_kind":"completed"}', '{"output":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:16Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000060', 'signals', 'task', 'assistant', 'agent_run_complete', 'agent run complete', '{"agent_id":"assistant"}', 'null', '2026-01-01T00:01:17Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000061', 'task_input', 'task', 'user', 'Message from assistant', 'Production is still on last week''s build. Want me to promote staging?', '{"kind":"message","priority":"wake","seq":2,"source":"assistant","target":"user"}', 'null', '2026-01-01T00:01:18Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000062', 'progress', 'task', 'assistant', 'turn_finished', 'turn_finished', '{"agent_id":"assistant","state":"turn_finished","task_id":"id-000038"}', 'null', '2026-01-01T00:01:19Z', '["assistant"]');
+INSERT INTO "sequences" ("key", "value") VALUES ('message:assistant->user', 2);
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000002', 'assistant', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:02Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000004', 'assistant', 'started', '{"status":"running"}', '2026-01-01T00:00:05Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000008', 'id-000006', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:09Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000010', 'id-000006', 'started', '{"status":"running"}', '2026-01-01T00:00:12Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000013', 'id-000006', 'input', '{"message":"How did the staging deploy go?"}', '2026-01-01T00:00:15Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000026', 'id-000006', 'llm_text', '{"text":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:33Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000029', 'assistant', 'assistant_output', '{"text":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:37Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000031', 'id-000006', 'completed', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:41Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000033', 'assistant', 'completed', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', '2026-01-01T00:00:45Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000040', 'id-000038', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:52Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000042', 'id-000038', 'started', '{"status":"running"}', '2026-01-01T00:00:55Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000045', 'id-000038', 'input', '{"message":"And production?"}', '2026-01-01T00:00:58Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000053', 'id-000038', 'llm_text', '{"text":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:07Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000056', 'assistant', 'assistant_output', '{"text":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:11Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000058', 'id-000038', 'completed', '{"output":"Production is still on last week''s build. Want me to promote staging?"}', '2026-01-01T00:01:15Z');
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('assistant', 'agent', 'completed', NULL, '2026-01-01T00:00:00Z', '2026-01-01T00:01:11Z', '{"source":"agenttest"}', 'null', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000006', 'llm', 'completed', 'assistant', '2026-01-01T00:00:07Z', '2026-01-01T00:00:41Z', '{"event_id":"","history_generation":1,"input_target":"assistant","mode":"sync","notify_target":"assistant","parent_id":"assistant","priority":"normal","request_id":"","service_id":"","source":"user"}', 'null', '{"output":"Hi! The deploy to staging finished at 09:12 and all checks passed."}', NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000038', 'llm', 'completed', 'assistant', '2026-01-01T00:00:50Z', '2026-01-01T00:01:15Z', '{"event_id":"","history_generation":1,"input_target":"assistant","mode":"sync","notify_target":"assistant","parent_id":"assistant","priority":"normal","request_id":"","service_id":"","source":"user"}', 'null', '{"output":"Production is still on last week''s build. Want me to promote staging?"}', NULL);
@@ -0,0 +1,32 @@
+-- agenttest state
+-- clock 2026-01-01T00:00:35Z 29
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000001', 'signals', 'global', '*', 'Task request assistant', 'Spawn task assistant (agent)', '{"action":"spawn","kind":"command","task_id":"assistant","task_type":"agent"}', 'null', '2026-01-01T00:00:01Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000003', 'task_output', 'global', '*', 'Task assistant update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:03Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000005', 'task_output', 'global', '*', 'Task assistant update', 'started', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:06Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000007', 'signals', 'task', 'assistant', 'Task request id-000006', 'Spawn task id-000006 (exec)', '{"action":"spawn","kind":"command","task_id":"id-000006","task_type":"exec"}', '{"code":"await fetch_logs()"}', '2026-01-01T00:00:08Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000009', 'task_output', 'global', '*', 'Task id-000006 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:10Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000011', 'signals', 'task', 'assistant', 'Task request id-000010', 'Spawn task id-000010 (exec)', '{"action":"spawn","kind":"command","task_id":"id-000010","task_type":"exec"}', '{"code":"await run_migrations()"}', '2026-01-01T00:00:12Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000013', 'task_output', 'global', '*', 'Task id-000010 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000010","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:14Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000015', 'signals', 'task', 'assistant', 'Task request id-000014', 'Spawn task id-000014 (exec)', '{"action":"spawn","kind":"command","task_id":"id-000014","task_type":"exec"}', '{"code":"await smoke_test()"}', '2026-01-01T00:00:16Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000017', 'task_output', 'global', '*', 'Task id-000014 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000014","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:18Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000019', 'signals', 'task', 'assistant', 'Task request id-000018', 'Spawn task id-000018 (exec)', '{"action":"spawn","kind":"command","task_id":"id-000018","task_type":"exec"}', '{"code":"await notify_oncall()"}', '2026-01-01T00:00:20Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000021', 'task_output', 'global', '*', 'Task id-000018 update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"id-000018","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:22Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000023', 'task_output', 'global', '*', 'Task id-000006 update', 'started', '{"kind":"task_update","priority":"normal","task_id":"id-000006","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:25Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000025', 'task_output', 'global', '*', 'Task id-000006 update', 'completed', '{"kind":"task_update","priority":"wake","task_id":"id-000006","task_kind":"completed"}', '{"lines":120}', '2026-01-01T00:00:29Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000027', 'task_output', 'global', '*', 'Task id-000010 update', 'started', '{"kind":"task_update","priority":"normal","task_id":"id-000010","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:32Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000029', 'task_output', 'global', '*', 'Task id-000010 update', 'progress', '{"kind":"task_update","priority":"normal","task_id":"id-000010","task_kind":"progress"}', '{"message":"3 of 7 migrations applied"}', '2026-01-01T00:00:34Z', '[]');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000002', 'assistant', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:02Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000004', 'assistant', 'started', '{"status":"running"}', '2026-01-01T00:00:05Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000008', 'id-000006', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:09Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000012', 'id-000010', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:13Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000016', 'id-000014', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:17Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000020', 'id-000018', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:21Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000022', 'id-000006', 'started', '{"status":"running"}', '2026-01-01T00:00:24Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000024', 'id-000006', 'completed', '{"lines":120}', '2026-01-01T00:00:28Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000026', 'id-000010', 'started', '{"status":"running"}', '2026-01-01T00:00:31Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000028', 'id-000010', 'progress', '{"message":"3 of 7 migrations applied"}', '2026-01-01T00:00:33Z');
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('assistant', 'agent', 'running', NULL, '2026-01-01T00:00:00Z', '2026-01-01T00:00:05Z', '{"source":"agenttest"}', 'null', NULL, NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000006', 'exec', 'completed', 'assistant', '2026-01-01T00:00:07Z', '2026-01-01T00:00:28Z', '{"parent_id":"assistant"}', '{"code":"await fetch_logs()"}', '{"lines":120}', NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000010', 'exec', 'running', 'assistant', '2026-01-01T00:00:11Z', '2026-01-01T00:00:33Z', '{"parent_id":"assistant"}', '{"code":"await run_migrations()"}', NULL, NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000014', 'exec', 'queued', 'assistant', '2026-01-01T00:00:15Z', '2026-01-01T00:00:17Z', '{"parent_id":"assistant"}', '{"code":"await smoke_test()"}', NULL, NULL);
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('id-000018', 'exec', 'queued', 'assistant', '2026-01-01T00:00:19Z', '2026-01-01T00:00:21Z', '{"parent_id":"assistant"}', '{"code":"await notify_oncall()"}', NULL, NULL);
@@ -0,0 +1,13 @@
+-- agenttest state
+-- clock 2026-01-01T00:00:11Z 9
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000001', 'signals', 'global', '*', 'Task request assistant', 'Spawn task assistant (agent)', '{"action":"spawn","kind":"command","task_id":"assistant","task_type":"agent"}', 'null', '2026-01-01T00:00:01Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000003', 'task_output', 'global', '*', 'Task assistant update', 'spawn', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"spawn"}', '{"status":"queued"}', '2026-01-01T00:00:03Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000005', 'task_output', 'global', '*', 'Task assistant update', 'started', '{"kind":"task_update","priority":"normal","task_id":"assistant","task_kind":"started"}', '{"status":"running"}', '2026-01-01T00:00:06Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000006', 'task_input', 'task', 'assistant', 'Message from user', 'Morning! Anything on fire?', '{"kind":"message","priority":"wake","seq":1,"source":"user","target":"assistant"}', 'null', '2026-01-01T00:00:07Z', '["assistant"]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000007', 'task_input', 'task', 'assistant', 'Message from user', 'The checkout service is returning 502s.', '{"kind":"message","priority":"wake","seq":2,"source":"user","target":"assistant"}', 'null', '2026-01-01T00:00:08Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000008', 'task_input', 'task', 'assistant', 'Message from user', 'Can you look at it before standup?', '{"kind":"message","priority":"wake","seq":3,"source":"user","target":"assistant"}', 'null', '2026-01-01T00:00:09Z', '[]');
+INSERT INTO "events" ("id", "stream", "scope_type", "scope_id", "subject", "body", "metadata", "payload", "created_at", "read_by") VALUES ('id-000009', 'signals', 'task', 'assistant', 'alert', 'checkout-api error rate above 5% for 10 minutes', '{"kind":"alert","source":"monitoring"}', 'null', '2026-01-01T00:00:10Z', '[]');
+INSERT INTO "sequences" ("key", "value") VALUES ('message:user->assistant', 3);
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000002', 'assistant', 'spawn', '{"status":"queued"}', '2026-01-01T00:00:02Z');
+INSERT INTO "task_updates" ("id", "task_id", "kind", "payload", "created_at") VALUES ('id-000004', 'assistant', 'started', '{"status":"running"}', '2026-01-01T00:00:05Z');
+INSERT INTO "tasks" ("id", "type", "status", "owner", "created_at", "updated_at", "metadata", "payload", "result", "error") VALUES ('assistant', 'agent', 'running', NULL, '2026-01-01T00:00:00Z', '2026-01-01T00:00:05Z', '{"source":"agenttest"}', 'null', NULL, NULL);
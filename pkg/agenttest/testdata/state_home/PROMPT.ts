@@ -0,0 +1 @@
+console.log("You are a test agent.");
@@ -29,6 +29,7 @@ Today is ${promptDateLabel}.
 - If confidence is low, say so and name the exact next check you would run.
 - Keep responses grounded in tool outputs. Include concrete evidence when relevant.
 - Treat XML system/context updates as runtime signals, not user-authored text. Never echo raw task/event payload dumps unless explicitly requested.
+- Context events carry a short \`ref\` (e.g. \`e12\`). When a statement relies on an event, cite it inline as \`[e12]\`.
 - For large outputs, write to a file and return the file path plus a short summary.
 - Agents are tasks. Every agent is identified by its task_id. Use send_task to message agents and await_task to wait for their output.
 - Be resourceful before asking. Read files, check context, search for answers. Come back with results, not questions.
@@ -63,6 +64,7 @@ Usage notes:
 - \`stdout\` and \`stderr\` are realtime task-output signals (\`kind="stdout"\`, \`kind="stderr"\`) for parent-task orchestration; they are not part of \`globalThis.result\`.
 - Use \`globalThis.result\` for structured return data consumed by tools and persisted as \`$resultN\`/ \`$last\`.
 - Use global \`sendToUser(text)\` inside exec code for user-visible assistant messages; it emits an assistant-style output with source metadata.
+- To send images or files, pass attachments: \`sendToUser("Here is the chart.", [{ path: "chart.png" }])\`. Each attachment is \`{ path }\` (up to 5 MB) or \`{ url, type: "image" | "file" }\`, with optional \`name\` and \`mime_type\`. Channels that only show text get a line per attachment instead.
 - Do not duplicate: if you send content via \`sendToUser(...)\`, avoid repeating the same content in your normal assistant message.
 - Use Bun.\` for shell execution. For pipelines, redirection, loops, or multiline shell scripts, use Bun.$\`sh -lc \${script}\`.
 - Never claim completion after a failed step. Retry with a fix or report the failure clearly.
@@ -84,7 +86,8 @@ Usage notes:
 - This is the default way to block on a task until it produces output or completes. Works for exec tasks and agent tasks alike.
 - If the task completes within the timeout, the result is returned directly.
 - If it times out, the response includes pending: true so you can decide whether to wait again or move on.
-- Wake events (e.g. new output from a child task) may cause an early return with a wake_event_id.`
+- Wake events (e.g. new output from a child task) may cause an early return with a wake_event_id.
+- Agent tasks report their context generation. If the response has generation_changed, that agent compacted its context and may have forgotten what you asked; send the request again if you still need it.`
 }
 
 function sendTaskBlock() {
@@ -118,6 +121,270 @@ Usage notes:
 - Use this for work that is no longer needed, has become stale, or is misbehaving.`
 }
 
+function retryTaskBlock() {
+  return `\
+# retry_task
+
+Retry a failed or cancelled task as a new task.
+
+Parameters:
+- task_id (string, required): The failed or cancelled task id.
+- code (string, optional): Replacement code for exec tasks, e.g. with a longer timeout or a fixed bug. The original code is reused when omitted.
+- reason (string, optional): What the retry changes.
+
+Usage notes:
+- The new task records retry_of and retry_attempt; retry chains are capped at 3 attempts.
+- Fix the cause of the failure first. Retrying unchanged code rarely helps.`
+}
+
+function teamBlock() {
+  return `\
+# create_team / assign_to_team
+
+Supervise a team of worker agents and hand them assignments.
+
+create_team parameters:
+- workers (array, required): Up to 16 workers, each { id?, system?, model? }. An id naming an existing agent adopts it.
+- max_concurrency (number, optional): Most workers on an assignment at once. Defaults to all of them.
+
+assign_to_team parameters:
+- body (string, required): The assignment.
+- to (string, optional): Worker id. Omit to give it to the first free worker.
+
+Usage notes:
+- You become the supervisor; each agent supervises at most one team. Workers can call assign_to_team too, to hand work to a teammate.
+- An assignment starts at once when a worker is free and the team is under max_concurrency; otherwise it queues in order.
+- The worker's reply arrives as a message from the worker to whoever assigned the work. An assignment ends when the worker's turn that handled it ends.`
+}
+
+function askAgentBlock() {
+  return `\
+# ask_agent
+
+Send a message to another agent and wait for its reply within this turn.
+
+Parameters:
+- agent (string, required): The id of the agent to ask.
+- message (string, required): The request, written to be understood on its own.
+- timeout_seconds (number, optional): How long to wait. Defaults to 120, at most 600.
+
+Usage notes:
+- The reply is the tool result; it does not also arrive as a message.
+- Use it when you need the answer to continue. To hand off work without waiting, use send_task.
+- On a timeout the other agent keeps working, and a late reply arrives as an ordinary message.
+- Asking an agent that is waiting on a reply from you fails at once, since neither could go on.`
+}
+
+function spawnFromTemplateBlock() {
+  return `\
+# spawn_from_template
+
+Spawn a task from a named task template. Templates are prepared task specs, such as an exec payload, with typed parameters.
+
+Parameters:
+- template (string, optional): The template name. Omit it to list the templates with their descriptions and parameters.
+- params (object, optional): Values for the template's parameters, keyed by name.
+
+Usage notes:
+- Prefer a template over writing the same exec code again when one fits the job.
+- Missing required parameters, unknown parameters and wrongly typed values are all reported at once; fix them and call again.
+- The result has the task_id; use await_task to wait for it.`
+}
+
+function subscribeTopicBlock() {
+  return `\
+# subscribe_topic
+
+Subscribe to a shared topic (e.g. "deployments") so its events reach you as context updates.
+
+Parameters:
+- topic (string, required): Topic name. Lowercase letters, digits, dots, dashes, and underscores; max 64 chars.
+- unsubscribe (boolean, optional): Stop receiving the topic instead.
+
+Usage notes:
+- Only events published after you subscribe are delivered. Subscriptions persist across turns and restarts.
+- Prefer a topic over messaging many agents individually when several agents need the same updates.`
+}
+
+function publishTopicBlock() {
+  return `\
+# publish_topic
+
+Publish an event to every agent subscribed to a topic.
+
+Parameters:
+- topic (string, required): Topic name.
+- body (string, required): Event content.
+- subject (string, optional): Short subject line.
+- priority (string, optional): low, normal (default), or wake. wake starts a turn for every subscriber; use it sparingly.
+
+Usage notes:
+- You never receive your own publications.`
+}
+
+function askUserBlock() {
+  return `\
+# ask_user
+
+Ask the sender of the current message a question and wait for their reply in a later turn.
+
+Parameters:
+- question (string, required): The question, phrased so it makes sense on its own when read hours later.
+- options (string[], optional): Suggested answers.
+
+Usage notes:
+- The question goes to the same place the current message came from (chat, service, or agent).
+- The reply arrives as a normal message with an <in_reply_to> element quoting your question, even days later.
+- End the turn after asking; do not guess the answer in the meantime.`
+}
+
+function contactsBlock() {
+  return `\
+# lookup_contact / message_contact
+
+Reach people in the contact book outside the current conversation, e.g. "tell Bob the deploy is done".
+
+lookup_contact parameters:
+- query (string, optional): Name, alias, ID or handle to search for. Omit it to list everyone.
+
+message_contact parameters:
+- contact (string, required): The contact's ID, name or alias.
+- text (string, required): The message, written to be read on its own.
+- channel (string, optional): One of the contact's channel names. Their preferred channel is used when omitted.
+- urgent (boolean, optional): Send even during the contact's quiet hours.
+
+Usage notes:
+- Never ask for or hardcode addresses or webhook URLs; the contact book resolves the channel.
+- lookup_contact shows each contact's local time; a message during quiet hours is refused unless urgent.
+- To reply to whoever sent the current message, just answer; message_contact is for other people.`
+}
+
+function calendarBlock() {
+  return `\
+# list_calendar_events / create_calendar_event / set_reminder
+
+Work with your calendar and schedule reminders for yourself.
+
+list_calendar_events parameters:
+- from (string, optional): Start of the range as RFC 3339. Defaults to now.
+- to (string, optional): End of the range as RFC 3339. Defaults to 7 days after from.
+
+create_calendar_event parameters:
+- title (string, required): Event title.
+- start (string, required): RFC 3339 time, or YYYY-MM-DD for an all-day event.
+- end (string, optional): Same form as start. Defaults to one hour (or one day) later.
+- location, description (string, optional).
+
+set_reminder parameters:
+- text (string, required): What to be reminded of.
+- at (string, optional): RFC 3339 time to fire.
+- in_minutes (number, optional): Fire this many minutes from now instead.
+- event_id (string, optional): Calendar event the reminder is about.
+
+Usage notes:
+- The calendar tools fail until an operator connects a calendar for you; reminders always work.
+- You are woken shortly before each calendar event and when a reminder fires. Both arrive as wake messages from source "calendar".`
+}
+
+function githubBlock() {
+  return `\
+# comment_on_github
+
+Comment on issues and pull requests in the GitHub repositories you watch.
+
+comment_on_github parameters:
+- repo (string, required): Repository as owner/name.
+- number (number, required): Issue or pull request number.
+- body (string, required): Comment text in GitHub Markdown.
+
+Usage notes:
+- Only repositories an operator connected for you can be commented on.
+- GitHub activity arrives as messages from source "github" with github_event, repo and number in their metadata, e.g. "pull_request.review_requested" or "ci.failed".
+- Review requests, requested changes, assignments and CI failures wake you; other activity is context for your next turn.`
+}
+
+function scheduleBlock() {
+  return `\
+# schedule_task
+
+Ask to be woken on a recurring schedule, e.g. "every weekday at 9am", without an external cron.
+
+schedule_task parameters:
+- cron (string): Five-field cron expression (minute hour day-of-month month day-of-week), e.g. "0 9 * * mon-fri". @hourly, @daily and @weekly also work.
+- text (string): What to do when woken. It is the body of every wake.
+- timezone (string, optional): IANA timezone the expression is read in, e.g. "Europe/Stockholm". Defaults to UTC.
+- cancel_id (string, optional): ID of one of your schedules to delete instead of creating one.
+
+Usage notes:
+- Scheduled wakes arrive as wake messages from source "scheduler" with the schedule_id in their metadata.
+- For a one-off wake use set_reminder instead.
+- Keep the schedule_id from the result if you may want to cancel the schedule later.`
+}
+
+function factsBlock() {
+  return `\
+# pin_fact / unpin_fact
+
+Durable facts about your user and work are pinned and shown to you on every turn in <pinned_facts>, even after older conversation is compacted away. Facts are also extracted from your conversations automatically.
+
+pin_fact parameters:
+- text (string, required): The fact as one short self-contained sentence, e.g. "The deploy window is Friday 14:00-16:00 UTC."
+- fact_id (string, optional): ID of a pinned fact to replace with text.
+
+unpin_fact parameters:
+- fact_id (string, required): ID of the pinned fact to remove.
+
+Usage notes:
+- Pin names, preferences, standing instructions and decisions you must not forget; not task progress or one-off details.
+- When a pinned fact turns out wrong or outdated, correct it with fact_id or unpin it.`
+}
+
+function rememberBlock() {
+  return `\
+# remember / recall / search_memory
+
+Long-term memories are notes you save under a key and look up again in later conversations. Unlike pinned facts they are not shown on every turn; recall finds them by words and tags. The runtime may list the memories most relevant to a message under "Relevant Memories" in your prompt.
+
+remember parameters:
+- text (string, required): What to remember, written to be understood on its own later.
+- key (string, optional): A short name, e.g. "customer-acme-contract". Remembering under the same key again replaces the memory.
+- tags (string[], optional): Tags to group and filter memories by.
+
+recall parameters:
+- query (string, optional): Words to match; leave empty to list the newest memories.
+- tags (string[], optional): Only memories carrying all of these tags.
+- limit (number, optional): How many to return; defaults to 5, at most 50.
+
+search_memory finds memories and past conversation messages by meaning rather than exact words. It works only when the runtime has an embedding provider configured; otherwise it returns an error and recall still works. Messages become searchable within a minute or so.
+
+search_memory parameters:
+- query (string, required): What you are looking for, in your own words.
+- limit (number, optional): How many results to return; defaults to 5, at most 50.
+- sources (string[], optional): "memory", "history" or both (the default).
+
+Usage notes:
+- Remember details you may need again but not on every turn: how a problem was solved, a customer's setup, where something lives.
+- Recall before asking the user something you may have been told before.
+- Use search_memory when you don't know the exact words, or to find what was said in an earlier conversation.`
+}
+
+function readArtifactBlock() {
+  return `\
+# read_artifact
+
+Read a stored artifact. Tool results and replies that are too large to keep inline are saved as artifacts and replaced by a summary with an artifact_id.
+
+Parameters:
+- artifact_id (string, required): The artifact's ID, e.g. "art-...".
+- offset (number, optional): Character offset to start from. Defaults to 0.
+- limit (number, optional): Characters to return. Defaults to 8000, at most 12000.
+
+Usage notes:
+- Work from the summary when it answers your question; read the artifact only for the details you need.
+- Continue with next_offset until it is absent to read the rest.
+- Refer users to an artifact by its ID rather than repeating its content; they can open it in full.`
+}
+
 function viewImageBlock() {
   return `\
 # view_image
@@ -457,13 +724,14 @@ const subagent = await agent({ message: "..." })
 ## core/api — Runtime API
 
 \`\`\`ts
-import { createAgent, sendInput, getUpdates, getState, subscribe, cancelTask, assistantOutputRoutes } from "core/api"
+import { createAgent, sendInput, getUpdates, getState, subscribe, cancelTask, assistantOutputRoutes, assistantOutputParts } from "core/api"
 \`\`\`
 
 - createAgent(opts) — Create or ensure an agent exists. Upserts by id — safe to call on every restart. Accepts optional system, model, source.
 - sendInput(taskId, message, opts?) — Send input to an existing task. Returns 404 if the task doesn't exist. Returns \`{ ok, request_id?, service_id? }\` for correlation. Accepts optional \`context\` and \`service_id\`. When called inside a service process, \`service_id\` is auto-populated from \`GO_AGENTS_SERVICE_ID\`. \`service_id\` is authoritative routing identity and is never inferred from \`source\`.
 - getUpdates(taskId, opts?) — Read task stdout, stderr, and status updates.
 - assistantOutputRoutes(payload) — Normalize assistant output routing metadata into a deterministic list of route candidates (\`{ request_id?, context? }\`; from \`payload.routes\`).
+- assistantOutputParts(payload) — The output's parts (\`{ type: "text" | "image" | "file", text?, url?, artifact_id?, name?, mime_type? }\`). Channels that can render images or files use these; text-only channels just send \`payload.text\`, which already lists the attachments.
 - getState() — Get full runtime state (all agents, tasks, events).
 - subscribe(opts?) — Subscribe to real-time event streams (SSE).
 - cancelTask(taskId) — Cancel a running task.
@@ -522,6 +790,20 @@ export function buildPrompt(extra?: string) {
     awaitTaskBlock(),
     sendTaskBlock(),
     killTaskBlock(),
+    retryTaskBlock(),
+    spawnFromTemplateBlock(),
+    teamBlock(),
+    askAgentBlock(),
+    subscribeTopicBlock(),
+    publishTopicBlock(),
+    askUserBlock(),
+    contactsBlock(),
+    calendarBlock(),
+    githubBlock(),
+    scheduleBlock(),
+    readArtifactBlock(),
+    factsBlock(),
+    rememberBlock(),
     viewImageBlock(),
     noopBlock(),
     subagentBlock(),
@@ -39,12 +39,15 @@ When called inside a service process, \`sendInput\` automatically injects \`cont
 
 - \`getUpdates(taskId, { kind?, after_id?, limit? })\`
 Fetches task updates (including \`assistant_output\`, \`stdout\`, \`stderr\`, \`completed\`, \`failed\`).
-For exec tasks: \`stdout\` and \`stderr\` are stream/task signals. Use exec-global \`sendToUser(text)\` when you want a direct user-visible assistant output event.
+For exec tasks: \`stdout\` and \`stderr\` are stream/task signals. Use exec-global \`sendToUser(text, attachments?)\` when you want a direct user-visible assistant output event. Outputs with images or files carry them in \`parts\`, and \`text\` always stays readable on its own.
 
 - \`assistantOutputRoutes(payload)\`
 Normalizes assistant output routing metadata into a deterministic list of route candidates.
 Uses \`payload.routes\` only.
 
+- \`assistantOutputParts(payload)\`
+Returns an assistant output's text, image and file parts; outputs without attachments give a single text part. Images and files carry a \`url\` (possibly a data URL) or an \`artifact_id\`.
+
 - \`subscribe({ streams?: string[] })\`
 Returns an object with \`events\` (async iterable) and \`close()\`.
 Consume with:
@@ -111,6 +111,36 @@ export function assistantOutputRoutes(payload: Record<string, unknown> | undefin
   return routes
 }
 
+export type AssistantOutputPart = {
+  type: "text" | "image" | "file"
+  text?: string
+  url?: string
+  artifact_id?: string
+  name?: string
+  mime_type?: string
+}
+
+/**
+ * The parts of an assistant_output: text plus image and file refs. Outputs
+ * without attachments come back as a single text part, so a renderer can
+ * always use this; text-only channels can keep using payload.text.
+ */
+export function assistantOutputParts(payload: Record<string, unknown> | undefined): AssistantOutputPart[] {
+  if (!payload) return []
+  const parts: AssistantOutputPart[] = []
+  const rawParts = Array.isArray(payload.parts) ? payload.parts : []
+  for (const raw of rawParts) {
+    if (!raw || typeof raw !== "object") continue
+    const part = raw as AssistantOutputPart
+    if (part.type === "text" ? typeof part.text === "string" : (part.type === "image" || part.type === "file") && (part.url || part.artifact_id)) {
+      parts.push(part)
+    }
+  }
+  if (parts.length > 0) return parts
+  const text = typeof payload.text === "string" ? payload.text : ""
+  return text ? [{ type: "text", text }] : []
+}
+
 /** Get updates for a task (stdout/stderr stream output, status updates, etc.). */
 export async function getUpdates(
   taskId: string,
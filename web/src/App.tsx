@@ -114,7 +114,7 @@ export function App(): React.ReactElement {
     setSendStatus("sending");
     try {
       // Upsert: create the agent if it doesn't exist (idempotent).
-      const createRes = await fetch("/api/tasks", {
+      const createRes = await fetch("/api/v1/tasks", {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ id: targetAgent, type: "agent" }),
@@ -125,7 +125,7 @@ export function App(): React.ReactElement {
         return;
       }
       // Send the message to the agent.
-      const res = await fetch(`/api/tasks/${encodeURIComponent(targetAgent)}/send`, {
+      const res = await fetch(`/api/v1/tasks/${encodeURIComponent(targetAgent)}/send`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ message: trimmed, source: "web", priority: "wake" }),
@@ -155,7 +155,7 @@ export function App(): React.ReactElement {
     }
     setCompactStatus("compacting");
     try {
-      const res = await fetch(`/api/tasks/${encodeURIComponent(selectedAgent)}/compact`, {
+      const res = await fetch(`/api/v1/tasks/${encodeURIComponent(selectedAgent)}/compact`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ reason: "manual compact from UI" }),
@@ -31,6 +31,31 @@ function renderMarkdown(text: string): string {
   return sanitizer ? sanitizer.sanitize(html) : html;
 }
 
+function Attachments({ parts }: { parts: unknown }): React.ReactElement | null {
+  if (!Array.isArray(parts)) return null;
+  const items = parts.filter(
+    (part): part is Record<string, string> =>
+      Boolean(part) && typeof part === "object" && (part.type === "image" || part.type === "file"),
+  );
+  if (items.length === 0) return null;
+  return (
+    <div className="attachments">
+      {items.map((part, index) => {
+        const href = part.url || (part.artifact_id ? `/api/artifacts/${encodeURIComponent(part.artifact_id)}?raw=1` : "");
+        const name = part.name || part.artifact_id || part.type;
+        if (part.type === "image" && part.url) {
+          return <img className="attachment-image" key={index} src={part.url} alt={name} />;
+        }
+        return (
+          <a className="attachment-file" key={index} href={href} target="_blank" rel="noreferrer">
+            {name}
+          </a>
+        );
+      })}
+    </div>
+  );
+}
+
 function Markdown({ text }: { text: string }): React.ReactElement {
   if (!text || text.trim() === "") {
     return <div className="muted">Empty</div>;
@@ -417,6 +442,7 @@ export function EntryCard({ entry, darkMode }: { entry: DisplayEntry; darkMode:
       <div className="history-card history-assistant">
         <div className="history-meta">{metaLabel}</div>
         <Markdown text={typed.content || ""} />
+        <Attachments parts={typed.data?.parts} />
       </div>
     );
   }
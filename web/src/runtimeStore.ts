@@ -182,7 +182,7 @@ class RuntimeStore {
 
     this.refreshInFlight = (async () => {
       try {
-        const data = await fetchJSON(`/api/state?tasks=250&updates=400&streams=${STREAM_LIMIT}&history=1200`);
+        const data = await fetchJSON(`/api/v1/state?tasks=250&updates=400&streams=${STREAM_LIMIT}&history=1200`);
         const next = normalizeState(data);
         this.snapshot = {
           state: {
@@ -240,7 +240,7 @@ class RuntimeStore {
       return;
     }
 
-    const src = new EventSource(`/api/streams/subscribe?streams=${STREAMS.join(",")}`);
+    const src = new EventSource(`/api/v1/streams/subscribe?streams=${STREAMS.join(",")}`);
     this.eventSource = src;
 
     src.onopen = () => {